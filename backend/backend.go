@@ -0,0 +1,118 @@
+// Package backend is the public interface for Cleanroom execution backends.
+//
+// A third-party backend implements Adapter (and optionally the richer
+// interfaces below), registers a Factory with Register, and builds its own
+// cleanroom binary around github.com/buildkite/cleanroom/cleanroomcli:
+//
+//	func main() {
+//		backend.Register("gvisor", func() backend.Adapter { return gvisor.New() })
+//		cleanroomcli.Main(version)
+//	}
+package backend
+
+import (
+	internalbackend "github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/policy"
+)
+
+type Adapter = internalbackend.Adapter
+type StreamingAdapter = internalbackend.StreamingAdapter
+type PersistentSandboxAdapter = internalbackend.PersistentSandboxAdapter
+type SandboxFileDownloadAdapter = internalbackend.SandboxFileDownloadAdapter
+type SandboxFileDeltaAdapter = internalbackend.SandboxFileDeltaAdapter
+type SandboxCommitAdapter = internalbackend.SandboxCommitAdapter
+type SandboxResolutionAdapter = internalbackend.SandboxResolutionAdapter
+type SandboxGroupAdapter = internalbackend.SandboxGroupAdapter
+type SandboxEnvironmentAdapter = internalbackend.SandboxEnvironmentAdapter
+type SandboxAgentUpgradeAdapter = internalbackend.SandboxAgentUpgradeAdapter
+type SandboxPauseAdapter = internalbackend.SandboxPauseAdapter
+type VMStatsAdapter = internalbackend.VMStatsAdapter
+type ArtifactManifestAdapter = internalbackend.ArtifactManifestAdapter
+type CapabilityReporter = internalbackend.CapabilityReporter
+type HostResourceReporter = internalbackend.HostResourceReporter
+type Factory = internalbackend.Factory
+
+type RunRequest = internalbackend.RunRequest
+type RunResult = internalbackend.RunResult
+type RunTimings = internalbackend.RunTimings
+type ExitMetadata = internalbackend.ExitMetadata
+type GuestFailure = internalbackend.GuestFailure
+type GuestFailureReason = internalbackend.GuestFailureReason
+type ProvisionRequest = internalbackend.ProvisionRequest
+type HostResolution = internalbackend.HostResolution
+type SandboxGroupRequest = internalbackend.SandboxGroupRequest
+type SandboxGroupMember = internalbackend.SandboxGroupMember
+type SandboxEnvironment = internalbackend.SandboxEnvironment
+type VMStats = internalbackend.VMStats
+type OutputStream = internalbackend.OutputStream
+type AttachIO = internalbackend.AttachIO
+type ResourceLimits = internalbackend.ResourceLimits
+type FirecrackerConfig = internalbackend.FirecrackerConfig
+type DoctorRequest = internalbackend.DoctorRequest
+type DoctorReport = internalbackend.DoctorReport
+type DoctorCheck = internalbackend.DoctorCheck
+
+type CompiledPolicy = policy.CompiledPolicy
+type AllowRule = policy.AllowRule
+type Services = policy.Services
+type DockerService = policy.DockerService
+
+const (
+	CapabilityExecStreaming          = internalbackend.CapabilityExecStreaming
+	CapabilitySandboxPersistent      = internalbackend.CapabilitySandboxPersistent
+	CapabilitySandboxFileDownload    = internalbackend.CapabilitySandboxFileDownload
+	CapabilitySandboxCommit          = internalbackend.CapabilitySandboxCommit
+	CapabilityNetworkDefaultDeny     = internalbackend.CapabilityNetworkDefaultDeny
+	CapabilityNetworkAllowlistEgress = internalbackend.CapabilityNetworkAllowlistEgress
+	CapabilityNetworkGuestInterface  = internalbackend.CapabilityNetworkGuestInterface
+	CapabilityDeviceVFIO             = internalbackend.CapabilityDeviceVFIO
+	CapabilityNestedVirtualization   = internalbackend.CapabilityNestedVirtualization
+	CapabilityDockerPreload          = internalbackend.CapabilityDockerPreload
+	CapabilityReadOnlyRootFS         = internalbackend.CapabilityReadOnlyRootFS
+	CapabilityExecCaptureChanges     = internalbackend.CapabilityExecCaptureChanges
+	CapabilitySandboxSetup           = internalbackend.CapabilitySandboxSetup
+	CapabilitySandboxAgentUpgrade    = internalbackend.CapabilitySandboxAgentUpgrade
+	CapabilitySandboxPause           = internalbackend.CapabilitySandboxPause
+	CapabilityNetworkPinnedDNS       = internalbackend.CapabilityNetworkPinnedDNS
+	CapabilityNetworkSandboxGroups   = internalbackend.CapabilityNetworkSandboxGroups
+	CapabilityObservabilityVMStats   = internalbackend.CapabilityObservabilityVMStats
+	CapabilitySandboxFileDelta       = internalbackend.CapabilitySandboxFileDelta
+)
+
+const (
+	GuestFailureOOM         = internalbackend.GuestFailureOOM
+	GuestFailureKernelPanic = internalbackend.GuestFailureKernelPanic
+)
+
+const (
+	ExecLauncherAuto    = internalbackend.ExecLauncherAuto
+	ExecLauncherDirect  = internalbackend.ExecLauncherDirect
+	ExecLauncherSystemd = internalbackend.ExecLauncherSystemd
+)
+
+const (
+	ChangeCaptureNone     = internalbackend.ChangeCaptureNone
+	ChangeCaptureManifest = internalbackend.ChangeCaptureManifest
+	ChangeCaptureArchive  = internalbackend.ChangeCaptureArchive
+)
+
+const (
+	DefaultVCPUs                = internalbackend.DefaultVCPUs
+	DefaultMemoryMiB            = internalbackend.DefaultMemoryMiB
+	DefaultLaunchSeconds        = internalbackend.DefaultLaunchSeconds
+	DefaultDockerStartupSeconds = internalbackend.DefaultDockerStartupSeconds
+	DefaultDockerStorageDriver  = internalbackend.DefaultDockerStorageDriver
+)
+
+const ArtifactManifestPath = internalbackend.ArtifactManifestPath
+
+// Register adds a backend next to the built-in firecracker and darwin-vz
+// backends. It panics on an empty name, a nil factory, or a duplicate name.
+func Register(name string, factory Factory) {
+	internalbackend.Register(name, factory)
+}
+
+// Registered returns the names of registered (non-built-in) backends.
+func Registered() []string {
+	return internalbackend.Registered()
+}
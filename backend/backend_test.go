@@ -0,0 +1,45 @@
+package backend_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buildkite/cleanroom/backend"
+)
+
+type echoAdapter struct{}
+
+func (echoAdapter) Name() string { return "echo" }
+
+func (echoAdapter) Run(_ context.Context, req backend.RunRequest) (*backend.RunResult, error) {
+	return &backend.RunResult{RunID: req.RunID, Stdout: req.Policy.ImageRef}, nil
+}
+
+func (echoAdapter) Capabilities() map[string]bool {
+	return map[string]bool{backend.CapabilityNetworkDefaultDeny: true}
+}
+
+var (
+	_ backend.Adapter            = echoAdapter{}
+	_ backend.CapabilityReporter = echoAdapter{}
+)
+
+func TestRegisterExposesBackend(t *testing.T) {
+	backend.Register("echo", func() backend.Adapter { return echoAdapter{} })
+
+	names := backend.Registered()
+	if len(names) != 1 || names[0] != "echo" {
+		t.Fatalf("unexpected registered backends: got %v want [echo]", names)
+	}
+
+	result, err := echoAdapter{}.Run(context.Background(), backend.RunRequest{
+		RunID:  "run-1",
+		Policy: &backend.CompiledPolicy{ImageRef: "example.com/image@sha256:abc"},
+	})
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if result.Stdout != "example.com/image@sha256:abc" {
+		t.Fatalf("unexpected stdout: got %q", result.Stdout)
+	}
+}
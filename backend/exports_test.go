@@ -0,0 +1,65 @@
+package backend_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestPublicPackageAliasesInternalExports keeps the public package in step
+// with internal/backend: every exported type and constant there must be
+// declared here too, or out-of-tree backends cannot name it.
+func TestPublicPackageAliasesInternalExports(t *testing.T) {
+	t.Parallel()
+
+	public := exportedTypesAndConsts(t, ".")
+	for name := range exportedTypesAndConsts(t, filepath.Join("..", "internal", "backend")) {
+		if !public[name] {
+			t.Errorf("internal/backend exports %s but the public backend package does not alias it", name)
+		}
+	}
+}
+
+func exportedTypesAndConsts(t *testing.T, dir string) map[string]bool {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read %s: %v", dir, err)
+	}
+	names := map[string]bool{}
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") || strings.HasSuffix(entry.Name(), "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, entry.Name()), nil, parser.SkipObjectResolution)
+		if err != nil {
+			t.Fatalf("parse %s: %v", entry.Name(), err)
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || (gen.Tok != token.TYPE && gen.Tok != token.CONST) {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Name.IsExported() {
+						names[spec.Name.Name] = true
+					}
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						if name.IsExported() {
+							names[name.Name] = true
+						}
+					}
+				}
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,36 @@
+// Package cleanroomcli runs the cleanroom command line. It lets custom
+// binaries register extra backends (see package backend) and then hand off to
+// the stock CLI.
+package cleanroomcli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/buildkite/cleanroom/internal/caphelper"
+	"github.com/buildkite/cleanroom/internal/cli"
+)
+
+// Run parses args (without the program name) and runs the selected command.
+func Run(args []string, version string) error {
+	return cli.Run(args, version)
+}
+
+// ExitCode maps an error returned by Run to a process exit code.
+func ExitCode(err error) int {
+	return cli.ExitCode(err)
+}
+
+// Main runs the CLI with os.Args and exits the process on error. Installed
+// as cleanroom-cap-helper (see install-cap-helper), the binary runs the
+// capability helper instead.
+func Main(version string) {
+	if filepath.Base(os.Args[0]) == caphelper.Name {
+		os.Exit(caphelper.Main(os.Args[1:]))
+	}
+	if err := Run(os.Args[1:], version); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(ExitCode(err))
+	}
+}
@@ -36,7 +36,30 @@ type TLSOptions struct {
 type Option func(*options)
 
 type options struct {
-	tls tlsconfig.Options
+	tls   tlsconfig.Options
+	retry *RetryPolicy
+}
+
+// RetryPolicy controls how read-only calls (GetSandbox, ListSandboxes,
+// DownloadSandboxFile, GetExecution and ListPendingApprovals) are retried
+// while the server is unavailable. Calls that change state are never
+// retried.
+type RetryPolicy = controlclient.RetryPolicy
+
+// DefaultRetryPolicy is used unless WithRetryPolicy is given.
+var DefaultRetryPolicy = controlclient.DefaultRetryPolicy
+
+// SyncStats describes one SyncSandboxFile transfer.
+type SyncStats = controlclient.SyncStats
+
+// ErrChecksumMismatch is returned by SyncSandboxFile when the downloaded
+// file does not match the SHA-256 the guest agent computed.
+var ErrChecksumMismatch = controlclient.ErrChecksumMismatch
+
+// IsRetriable reports whether err is a transient failure that may succeed
+// if the call is made again. Other errors are terminal.
+func IsRetriable(err error) bool {
+	return controlclient.IsRetriable(err)
 }
 
 // WithTLS configures TLS options for HTTPS endpoints.
@@ -50,6 +73,14 @@ func WithTLS(opts TLSOptions) Option {
 	}
 }
 
+// WithRetryPolicy replaces DefaultRetryPolicy. A zero policy disables
+// retries.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = &policy
+	}
+}
+
 // New creates a client for the provided endpoint.
 //
 // Supported endpoint formats match the CLI:
@@ -74,7 +105,11 @@ func New(host string, opts ...Option) (*Client, error) {
 	if ep.Scheme == "tssvc" {
 		return nil, errors.New("tssvc:// endpoints are listen-only; use https://<service>.<your-tailnet>.ts.net")
 	}
-	inner, err := controlclient.New(ep, controlclient.WithTLS(o.tls))
+	innerOpts := []controlclient.Option{controlclient.WithTLS(o.tls)}
+	if o.retry != nil {
+		innerOpts = append(innerOpts, controlclient.WithRetryPolicy(*o.retry))
+	}
+	inner, err := controlclient.New(ep, innerOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -113,6 +148,30 @@ func (c *Client) DownloadSandboxFile(ctx context.Context, req *DownloadSandboxFi
 	return c.inner.DownloadSandboxFile(ctx, req)
 }
 
+// SyncSandboxFile copies the sandbox file at path to localPath. When
+// localPath already holds an earlier copy, only the blocks that changed
+// are transferred.
+func (c *Client) SyncSandboxFile(ctx context.Context, sandboxID, path, localPath string, maxBytes int64) (SyncStats, error) {
+	if c == nil || c.inner == nil {
+		return SyncStats{}, errors.New("nil client")
+	}
+	return c.inner.SyncSandboxFile(ctx, sandboxID, path, localPath, maxBytes)
+}
+
+func (c *Client) CommitSandbox(ctx context.Context, req *CommitSandboxRequest) (*CommitSandboxResponse, error) {
+	if c == nil || c.inner == nil {
+		return nil, errors.New("nil client")
+	}
+	return c.inner.CommitSandbox(ctx, req)
+}
+
+func (c *Client) UpgradeSandboxAgent(ctx context.Context, req *UpgradeSandboxAgentRequest) (*UpgradeSandboxAgentResponse, error) {
+	if c == nil || c.inner == nil {
+		return nil, errors.New("nil client")
+	}
+	return c.inner.UpgradeSandboxAgent(ctx, req)
+}
+
 func (c *Client) TerminateSandbox(ctx context.Context, req *TerminateSandboxRequest) (*TerminateSandboxResponse, error) {
 	if c == nil || c.inner == nil {
 		return nil, errors.New("nil client")
@@ -155,9 +214,37 @@ func (c *Client) CancelExecution(ctx context.Context, req *CancelExecutionReques
 	return c.inner.CancelExecution(ctx, req)
 }
 
+func (c *Client) WriteExecutionStdin(ctx context.Context, req *WriteExecutionStdinRequest) (*WriteExecutionStdinResponse, error) {
+	if c == nil || c.inner == nil {
+		return nil, errors.New("nil client")
+	}
+	return c.inner.WriteExecutionStdin(ctx, req)
+}
+
 func (c *Client) StreamExecution(ctx context.Context, req *StreamExecutionRequest) (*connect.ServerStreamForClient[ExecutionStreamEvent], error) {
 	if c == nil || c.inner == nil {
 		return nil, errors.New("nil client")
 	}
 	return c.inner.StreamExecution(ctx, req)
 }
+
+func (c *Client) ListPendingApprovals(ctx context.Context, req *ListPendingApprovalsRequest) (*ListPendingApprovalsResponse, error) {
+	if c == nil || c.inner == nil {
+		return nil, errors.New("nil client")
+	}
+	return c.inner.ListPendingApprovals(ctx, req)
+}
+
+func (c *Client) ResolveExecutionApproval(ctx context.Context, req *ResolveExecutionApprovalRequest) (*ResolveExecutionApprovalResponse, error) {
+	if c == nil || c.inner == nil {
+		return nil, errors.New("nil client")
+	}
+	return c.inner.ResolveExecutionApproval(ctx, req)
+}
+
+func (c *Client) GetServerInfo(ctx context.Context, req *GetServerInfoRequest) (*GetServerInfoResponse, error) {
+	if c == nil || c.inner == nil {
+		return nil, errors.New("nil client")
+	}
+	return c.inner.GetServerInfo(ctx, req)
+}
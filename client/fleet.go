@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fleetProbeTimeout bounds how long Pick waits for each server's info, so
+// an unreachable server costs a placement little.
+const fleetProbeTimeout = 2 * time.Second
+
+// Fleet places new sandboxes across several cleanroom servers, preferring
+// one that already has the sandbox's image cached so it boots without a
+// registry pull. Sandboxes live on the server that created them; use the
+// client CreateSandbox returns for every later call about the sandbox.
+type Fleet struct {
+	hosts   []string
+	clients []*Client
+}
+
+// NewFleet returns a fleet of the servers at hosts. Servers earlier in the
+// list are preferred when several are equally suitable.
+func NewFleet(hosts []string, opts ...Option) (*Fleet, error) {
+	if len(hosts) == 0 {
+		return nil, errors.New("a fleet needs at least one host")
+	}
+	f := &Fleet{}
+	for _, host := range hosts {
+		c, err := New(host, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("host %s: %w", host, err)
+		}
+		f.hosts = append(f.hosts, host)
+		f.clients = append(f.clients, c)
+	}
+	return f, nil
+}
+
+// Pick returns the first server whose cache holds imageDigest, or the
+// first server that answers if none does. An empty imageDigest picks the
+// first server that answers.
+func (f *Fleet) Pick(ctx context.Context, imageDigest string) (*Client, error) {
+	imageDigest = strings.ToLower(strings.TrimSpace(imageDigest))
+	cached := make([]bool, len(f.clients))
+	reachable := make([]bool, len(f.clients))
+	errs := make([]error, len(f.clients))
+
+	var wg sync.WaitGroup
+	for i, c := range f.clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, fleetProbeTimeout)
+			defer cancel()
+			info, err := c.GetServerInfo(probeCtx, &GetServerInfoRequest{})
+			if err != nil {
+				errs[i] = fmt.Errorf("host %s: %w", f.hosts[i], err)
+				return
+			}
+			reachable[i] = true
+			cached[i] = imageDigest != "" && slices.Contains(info.GetCachedImageDigests(), imageDigest)
+		}()
+	}
+	wg.Wait()
+
+	if i := slices.Index(cached, true); i >= 0 {
+		return f.clients[i], nil
+	}
+	if i := slices.Index(reachable, true); i >= 0 {
+		return f.clients[i], nil
+	}
+	return nil, fmt.Errorf("no fleet host is reachable: %w", errors.Join(errs...))
+}
+
+// CreateSandbox creates the sandbox on the server Pick chooses for its
+// policy's image, and returns that server's client with the response.
+func (f *Fleet) CreateSandbox(ctx context.Context, req *CreateSandboxRequest) (*Client, *CreateSandboxResponse, error) {
+	c, err := f.Pick(ctx, req.GetPolicy().GetImageDigest())
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := c.CreateSandbox(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, resp, nil
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/controlserver"
+	"github.com/buildkite/cleanroom/internal/controlservice"
+	"github.com/buildkite/cleanroom/internal/imagemgr"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+)
+
+type stubImageCache []string
+
+func (c stubImageCache) List(context.Context) ([]imagemgr.Record, error) {
+	records := make([]imagemgr.Record, 0, len(c))
+	for _, digest := range c {
+		records = append(records, imagemgr.Record{Digest: digest})
+	}
+	return records, nil
+}
+
+func startFleetServer(t *testing.T, cached ...string) string {
+	t.Helper()
+
+	svc := &controlservice.Service{
+		Config:   runtimeconfig.Config{DefaultBackend: "firecracker"},
+		Backends: map[string]backend.Adapter{"firecracker": integrationAdapter{}},
+		Images:   stubImageCache(cached),
+	}
+	httpServer := httptest.NewServer(controlserver.New(svc, nil).Handler())
+	t.Cleanup(httpServer.Close)
+	return httpServer.URL
+}
+
+func TestFleetPrefersHostWithCachedImage(t *testing.T) {
+	cold := startFleetServer(t)
+	warm := startFleetServer(t, testPolicy().GetImageDigest())
+
+	fleet, err := NewFleet([]string{"http://127.0.0.1:1", cold, warm})
+	if err != nil {
+		t.Fatalf("NewFleet returned error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	picked, resp, err := fleet.CreateSandbox(ctx, &CreateSandboxRequest{Policy: testPolicy(), Backend: "firecracker"})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	if picked != fleet.clients[2] {
+		t.Fatal("expected the sandbox to be placed on the host with the image cached")
+	}
+	if _, err := picked.GetSandbox(ctx, &GetSandboxRequest{SandboxId: resp.GetSandbox().GetSandboxId()}); err != nil {
+		t.Fatalf("GetSandbox on the picked host returned error: %v", err)
+	}
+
+	fallback, err := fleet.Pick(ctx, "sha256:ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+	if err != nil {
+		t.Fatalf("Pick returned error: %v", err)
+	}
+	if fallback != fleet.clients[1] {
+		t.Fatal("expected an uncached image to fall back to the first reachable host")
+	}
+}
+
+func TestFleetPickFailsWhenNoHostAnswers(t *testing.T) {
+	fleet, err := NewFleet([]string{"http://127.0.0.1:1"}, WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	if err != nil {
+		t.Fatalf("NewFleet returned error: %v", err)
+	}
+	if _, err := fleet.Pick(context.Background(), ""); err == nil {
+		t.Fatal("expected an error when no host is reachable")
+	}
+}
@@ -26,6 +26,10 @@ type ListSandboxesRequest = cleanroomv1.ListSandboxesRequest
 type ListSandboxesResponse = cleanroomv1.ListSandboxesResponse
 type DownloadSandboxFileRequest = cleanroomv1.DownloadSandboxFileRequest
 type DownloadSandboxFileResponse = cleanroomv1.DownloadSandboxFileResponse
+type CommitSandboxRequest = cleanroomv1.CommitSandboxRequest
+type CommitSandboxResponse = cleanroomv1.CommitSandboxResponse
+type UpgradeSandboxAgentRequest = cleanroomv1.UpgradeSandboxAgentRequest
+type UpgradeSandboxAgentResponse = cleanroomv1.UpgradeSandboxAgentResponse
 type TerminateSandboxRequest = cleanroomv1.TerminateSandboxRequest
 type TerminateSandboxResponse = cleanroomv1.TerminateSandboxResponse
 type StreamSandboxEventsRequest = cleanroomv1.StreamSandboxEventsRequest
@@ -36,13 +40,16 @@ type Execution = cleanroomv1.Execution
 type ExecutionStatus = cleanroomv1.ExecutionStatus
 
 const (
-	ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED
-	ExecutionStatus_EXECUTION_STATUS_QUEUED      = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_QUEUED
-	ExecutionStatus_EXECUTION_STATUS_RUNNING     = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_RUNNING
-	ExecutionStatus_EXECUTION_STATUS_SUCCEEDED   = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SUCCEEDED
-	ExecutionStatus_EXECUTION_STATUS_FAILED      = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_FAILED
-	ExecutionStatus_EXECUTION_STATUS_CANCELED    = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_CANCELED
-	ExecutionStatus_EXECUTION_STATUS_TIMED_OUT   = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_TIMED_OUT
+	ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED      = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED
+	ExecutionStatus_EXECUTION_STATUS_QUEUED           = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_QUEUED
+	ExecutionStatus_EXECUTION_STATUS_RUNNING          = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_RUNNING
+	ExecutionStatus_EXECUTION_STATUS_SUCCEEDED        = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SUCCEEDED
+	ExecutionStatus_EXECUTION_STATUS_FAILED           = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_FAILED
+	ExecutionStatus_EXECUTION_STATUS_CANCELED         = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_CANCELED
+	ExecutionStatus_EXECUTION_STATUS_TIMED_OUT        = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_TIMED_OUT
+	ExecutionStatus_EXECUTION_STATUS_PENDING_APPROVAL = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_PENDING_APPROVAL
+	ExecutionStatus_EXECUTION_STATUS_SKIPPED          = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SKIPPED
+	ExecutionStatus_EXECUTION_STATUS_SOFT_FAILED      = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SOFT_FAILED
 )
 
 type ExecutionKind = cleanroomv1.ExecutionKind
@@ -54,6 +61,7 @@ const (
 )
 
 type ExecutionOptions = cleanroomv1.ExecutionOptions
+type ExecutionResourceLimits = cleanroomv1.ExecutionResourceLimits
 type CreateExecutionRequest = cleanroomv1.CreateExecutionRequest
 type CreateExecutionResponse = cleanroomv1.CreateExecutionResponse
 type OpenInteractiveExecutionRequest = cleanroomv1.OpenInteractiveExecutionRequest
@@ -62,6 +70,17 @@ type GetExecutionRequest = cleanroomv1.GetExecutionRequest
 type GetExecutionResponse = cleanroomv1.GetExecutionResponse
 type CancelExecutionRequest = cleanroomv1.CancelExecutionRequest
 type CancelExecutionResponse = cleanroomv1.CancelExecutionResponse
+type WriteExecutionStdinRequest = cleanroomv1.WriteExecutionStdinRequest
+type WriteExecutionStdinResponse = cleanroomv1.WriteExecutionStdinResponse
 type StreamExecutionRequest = cleanroomv1.StreamExecutionRequest
 type ExecutionExit = cleanroomv1.ExecutionExit
 type ExecutionStreamEvent = cleanroomv1.ExecutionStreamEvent
+type ExecutionApproval = cleanroomv1.ExecutionApproval
+type PendingApproval = cleanroomv1.PendingApproval
+type ListPendingApprovalsRequest = cleanroomv1.ListPendingApprovalsRequest
+type ListPendingApprovalsResponse = cleanroomv1.ListPendingApprovalsResponse
+type ResolveExecutionApprovalRequest = cleanroomv1.ResolveExecutionApprovalRequest
+type ResolveExecutionApprovalResponse = cleanroomv1.ResolveExecutionApprovalResponse
+
+type GetServerInfoRequest = cleanroomv1.GetServerInfoRequest
+type GetServerInfoResponse = cleanroomv1.GetServerInfoResponse
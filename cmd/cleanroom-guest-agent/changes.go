@@ -0,0 +1,362 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+const (
+	// changesDir holds the most recent change capture. It is on the /run
+	// tmpfs, which change capture never walks.
+	changesDir = "/run/cleanroom/changes"
+	// artifactManifestPath matches backend.ArtifactManifestPath.
+	artifactManifestPath = "/cleanroom/artifacts.json"
+
+	maxChangeEntries            = 100000
+	maxChangeArchiveBytes int64 = 256 * 1024 * 1024
+	// maxSnapshotEntries bounds how many paths one snapshot holds in memory.
+	maxSnapshotEntries = 1000000
+)
+
+// changeSkipRoots are never walked: kernel filesystems, the agent's runtime
+// state and the artifact manifest directory.
+var changeSkipRoots = []string{"/cleanroom", "/dev", "/proc", "/run", "/sys"}
+
+type fileState struct {
+	Mode    fs.FileMode
+	Size    int64
+	ModTime int64
+}
+
+// fsSnapshot maps guest paths to their metadata at one point in time.
+type fsSnapshot map[string]fileState
+
+// fileChange is one entry of the change manifest.
+type fileChange struct {
+	Path     string `json:"path"`
+	Change   string `json:"change"` // added|modified|deleted
+	Type     string `json:"type"`   // file|dir|symlink|other
+	Size     int64  `json:"size,omitempty"`
+	Archived bool   `json:"archived,omitempty"`
+}
+
+type changeManifest struct {
+	Changes []fileChange `json:"changes"`
+	// Truncated is set when more than maxChangeEntries paths changed, when
+	// a snapshot hit maxSnapshotEntries, or when the archive hit its size
+	// limit.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// errSnapshotFull stops a walk that reached its entry limit.
+var errSnapshotFull = errors.New("snapshot entry limit reached")
+
+// takeSnapshot records the metadata of everything under root except the
+// skipped roots, staying on root's filesystem so mounts such as tmpfs,
+// container overlays and host shares are not walked. It stops after limit
+// entries and reports the snapshot as truncated. Paths are recorded as the
+// guest sees them, so a root other than "/" is only useful in tests.
+// Entries that vanish mid-walk are ignored.
+func takeSnapshot(root string, skip []string, limit int) (fsSnapshot, bool, error) {
+	rootInfo, err := os.Stat(root)
+	if err != nil {
+		return nil, false, err
+	}
+	rootDev, _, _, haveDev := fileIdentity(rootInfo)
+
+	snap := fsSnapshot{}
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		rel, relErr := filepath.Rel(root, p)
+		if relErr != nil {
+			return relErr
+		}
+		guestPath := path.Join("/", filepath.ToSlash(rel))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+				return nil
+			}
+			return err
+		}
+		if guestPath != "/" && isSkippedChangePath(guestPath, skip) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && guestPath != "/" && haveDev {
+			if dev, _, _, ok := fileIdentity(info); ok && dev != rootDev {
+				return fs.SkipDir
+			}
+		}
+		if len(snap) >= limit {
+			return errSnapshotFull
+		}
+		snap[guestPath] = fileState{Mode: info.Mode(), Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+		return nil
+	})
+	if errors.Is(err, errSnapshotFull) {
+		return snap, true, nil
+	}
+	return snap, false, err
+}
+
+func isSkippedChangePath(p string, skip []string) bool {
+	for _, root := range skip {
+		if p == root || strings.HasPrefix(p, root+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// diffSnapshots lists what changed between two snapshots, sorted by path.
+// A directory only counts as modified when its mode changed, since its
+// mtime moves with every entry added or removed inside it.
+func diffSnapshots(before, after fsSnapshot) []fileChange {
+	var changes []fileChange
+	for p, now := range after {
+		prev, existed := before[p]
+		switch {
+		case !existed:
+			changes = append(changes, fileChange{Path: p, Change: "added", Type: fileType(now.Mode), Size: regularSize(now)})
+		case prev.Mode != now.Mode:
+			changes = append(changes, fileChange{Path: p, Change: "modified", Type: fileType(now.Mode), Size: regularSize(now)})
+		case now.Mode.IsDir():
+		case prev.Size != now.Size || prev.ModTime != now.ModTime:
+			changes = append(changes, fileChange{Path: p, Change: "modified", Type: fileType(now.Mode), Size: regularSize(now)})
+		}
+	}
+	for p, prev := range before {
+		if _, ok := after[p]; !ok {
+			changes = append(changes, fileChange{Path: p, Change: "deleted", Type: fileType(prev.Mode)})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+func fileType(mode fs.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return "file"
+	case mode.IsDir():
+		return "dir"
+	case mode&fs.ModeSymlink != 0:
+		return "symlink"
+	default:
+		return "other"
+	}
+}
+
+func regularSize(state fileState) int64 {
+	if state.Mode.IsRegular() {
+		return state.Size
+	}
+	return 0
+}
+
+// changeCapture snapshots the guest filesystem before a command runs and
+// publishes what it changed once it exits.
+type changeCapture struct {
+	mode   string
+	root   string
+	outDir string
+	before fsSnapshot
+	// truncated is set when the before snapshot hit maxSnapshotEntries.
+	truncated bool
+}
+
+// startChangeCapture returns nil when the request does not ask for capture.
+func startChangeCapture(mode string) (*changeCapture, error) {
+	switch mode {
+	case "":
+		return nil, nil
+	case vsockexec.CaptureChangesManifest, vsockexec.CaptureChangesArchive:
+	default:
+		return nil, fmt.Errorf("unsupported change capture mode %q", mode)
+	}
+	// Only the latest capture is kept so repeated captures do not fill the
+	// /run tmpfs.
+	_ = os.RemoveAll(changesDir)
+	before, truncated, err := takeSnapshot("/", changeSkipRoots, maxSnapshotEntries)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot filesystem: %w", err)
+	}
+	return &changeCapture{mode: mode, root: "/", outDir: changesDir, before: before, truncated: truncated}, nil
+}
+
+// finish writes the change manifest, and the archive when asked for, and
+// lists them in the artifact manifest for the server to pick up.
+func (c *changeCapture) finish() error {
+	if c == nil {
+		return nil
+	}
+	after, truncated, err := takeSnapshot(c.root, changeSkipRoots, maxSnapshotEntries)
+	if err != nil {
+		return fmt.Errorf("snapshot filesystem: %w", err)
+	}
+	manifest := changeManifest{Changes: diffSnapshots(c.before, after), Truncated: c.truncated || truncated}
+	if len(manifest.Changes) > maxChangeEntries {
+		manifest.Changes = manifest.Changes[:maxChangeEntries]
+		manifest.Truncated = true
+	}
+	if err := os.MkdirAll(c.outDir, 0o755); err != nil {
+		return err
+	}
+
+	var artifacts []json.RawMessage
+	if c.mode == vsockexec.CaptureChangesArchive {
+		archivePath := filepath.Join(c.outDir, "changes.tar.gz")
+		truncated, err := writeChangeArchive(c.root, archivePath, manifest.Changes)
+		if err != nil {
+			return fmt.Errorf("write change archive: %w", err)
+		}
+		manifest.Truncated = manifest.Truncated || truncated
+		artifacts = append(artifacts, artifactEntry(archivePath, "changes.tar.gz", "application/gzip"))
+	}
+	manifestPath := filepath.Join(c.outDir, "changes.json")
+	raw, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(manifestPath, raw, 0o644); err != nil {
+		return err
+	}
+	artifacts = append([]json.RawMessage{artifactEntry(manifestPath, "changes.json", "application/json")}, artifacts...)
+	return appendArtifacts(artifactManifestPath, artifacts)
+}
+
+// writeChangeArchive packs the added and modified entries into a gzipped
+// tarball, marking each one archived. It reports whether it stopped early
+// at maxChangeArchiveBytes.
+func writeChangeArchive(root, archivePath string, changes []fileChange) (bool, error) {
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	var total int64
+	truncated := false
+	for i := range changes {
+		change := &changes[i]
+		if change.Change == "deleted" || change.Type == "other" {
+			continue
+		}
+		if change.Size > 0 && total+change.Size > maxChangeArchiveBytes {
+			truncated = true
+			continue
+		}
+		ok, err := addToArchive(tw, root, change.Path)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			change.Archived = true
+			total += change.Size
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return false, err
+	}
+	if err := gz.Close(); err != nil {
+		return false, err
+	}
+	return truncated, f.Close()
+}
+
+// addToArchive adds one entry. It returns false without an error when the
+// entry disappeared or changed type since the snapshot.
+func addToArchive(tw *tar.Writer, root, guestPath string) (bool, error) {
+	hostPath := filepath.Join(root, filepath.FromSlash(guestPath))
+	info, err := os.Lstat(hostPath)
+	if err != nil {
+		return false, nil
+	}
+	link := ""
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if link, err = os.Readlink(hostPath); err != nil {
+			return false, nil
+		}
+	} else if !info.Mode().IsRegular() && !info.IsDir() {
+		return false, nil
+	}
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return false, err
+	}
+	hdr.Name = strings.TrimPrefix(guestPath, "/")
+	if info.IsDir() {
+		hdr.Name += "/"
+	}
+	if !info.Mode().IsRegular() {
+		return true, tw.WriteHeader(hdr)
+	}
+	src, err := os.Open(hostPath)
+	if err != nil {
+		return false, nil
+	}
+	defer src.Close()
+	if err := tw.WriteHeader(hdr); err != nil {
+		return false, err
+	}
+	// The header carries the size from Lstat; copy exactly that much even if
+	// the file is still growing.
+	if _, err := io.CopyN(tw, src, hdr.Size); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func artifactEntry(p, name, mediaType string) json.RawMessage {
+	raw, _ := json.Marshal(map[string]string{"path": p, "name": name, "media_type": mediaType})
+	return raw
+}
+
+// appendArtifacts adds entries to the artifact manifest at manifestPath,
+// keeping whatever the command itself listed there. A manifest the command
+// left unparseable is not touched, so the server reports it as written.
+func appendArtifacts(manifestPath string, entries []json.RawMessage) error {
+	var manifest struct {
+		Artifacts []json.RawMessage `json:"artifacts"`
+	}
+	raw, err := os.ReadFile(manifestPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	case len(strings.TrimSpace(string(raw))) > 0:
+		if err := json.Unmarshal(raw, &manifest); err != nil {
+			return fmt.Errorf("artifact manifest %s is not valid JSON: %w", manifestPath, err)
+		}
+	}
+	manifest.Artifacts = append(manifest.Artifacts, entries...)
+	out, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(manifestPath), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(manifestPath, out, 0o644)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffSnapshotsReportsAddedModifiedAndDeleted(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	mustWrite(t, filepath.Join(root, "usr/bin/tool"), "v1")
+	mustWrite(t, filepath.Join(root, "etc/config"), "keep")
+	mustWrite(t, filepath.Join(root, "var/old"), "gone soon")
+	mustWrite(t, filepath.Join(root, "proc/ignored"), "x")
+
+	before, _, err := takeSnapshot(root, []string{"/proc"}, maxSnapshotEntries)
+	if err != nil {
+		t.Fatalf("takeSnapshot returned error: %v", err)
+	}
+	if _, ok := before["/proc/ignored"]; ok {
+		t.Fatal("expected skipped root to be left out of the snapshot")
+	}
+
+	mustWrite(t, filepath.Join(root, "usr/bin/tool"), "v2 is longer")
+	mustWrite(t, filepath.Join(root, "build/out.bin"), "artifact")
+	if err := os.Remove(filepath.Join(root, "var/old")); err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, filepath.Join(root, "proc/new"), "x")
+
+	after, _, err := takeSnapshot(root, []string{"/proc"}, maxSnapshotEntries)
+	if err != nil {
+		t.Fatalf("takeSnapshot returned error: %v", err)
+	}
+	got := diffSnapshots(before, after)
+	want := []fileChange{
+		{Path: "/build", Change: "added", Type: "dir"},
+		{Path: "/build/out.bin", Change: "added", Type: "file", Size: 8},
+		{Path: "/usr/bin/tool", Change: "modified", Type: "file", Size: 12},
+		{Path: "/var/old", Change: "deleted", Type: "file"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected changes:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestTakeSnapshotStopsAtEntryLimit(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		mustWrite(t, filepath.Join(root, name), name)
+	}
+
+	snap, truncated, err := takeSnapshot(root, nil, 3)
+	if err != nil {
+		t.Fatalf("takeSnapshot returned error: %v", err)
+	}
+	if !truncated {
+		t.Fatal("expected snapshot over the limit to be truncated")
+	}
+	if len(snap) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(snap))
+	}
+
+	if _, truncated, err := takeSnapshot(root, nil, 5); err != nil || truncated {
+		t.Fatalf("expected snapshot within the limit to be complete, truncated=%v err=%v", truncated, err)
+	}
+}
+
+func TestWriteChangeArchivePacksChangedFiles(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	mustWrite(t, filepath.Join(root, "build/out.bin"), "artifact")
+	changes := []fileChange{
+		{Path: "/build", Change: "added", Type: "dir"},
+		{Path: "/build/out.bin", Change: "added", Type: "file", Size: 8},
+		{Path: "/build/vanished", Change: "added", Type: "file", Size: 3},
+		{Path: "/var/old", Change: "deleted", Type: "file"},
+	}
+	archivePath := filepath.Join(t.TempDir(), "changes.tar.gz")
+	truncated, err := writeChangeArchive(root, archivePath, changes)
+	if err != nil {
+		t.Fatalf("writeChangeArchive returned error: %v", err)
+	}
+	if truncated {
+		t.Fatal("expected small archive not to be truncated")
+	}
+	if !changes[0].Archived || !changes[1].Archived || changes[2].Archived || changes[3].Archived {
+		t.Fatalf("unexpected archived flags: %+v", changes)
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, hdr.Name)
+	}
+	if want := []string{"build/", "build/out.bin"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("unexpected archive entries: got %v want %v", names, want)
+	}
+}
+
+func TestAppendArtifactsKeepsCommandEntries(t *testing.T) {
+	t.Parallel()
+
+	manifestPath := filepath.Join(t.TempDir(), "cleanroom", "artifacts.json")
+	entry := artifactEntry("/run/cleanroom/changes/changes.json", "changes.json", "application/json")
+	if err := appendArtifacts(manifestPath, []json.RawMessage{entry}); err != nil {
+		t.Fatalf("appendArtifacts returned error: %v", err)
+	}
+	mustWrite(t, manifestPath, `{"artifacts":[{"path":"/workspace/app.tar.gz"}]}`)
+	if err := appendArtifacts(manifestPath, []json.RawMessage{entry}); err != nil {
+		t.Fatalf("appendArtifacts returned error: %v", err)
+	}
+	var manifest struct {
+		Artifacts []struct {
+			Path string `json:"path"`
+		} `json:"artifacts"`
+	}
+	raw, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Artifacts) != 2 || manifest.Artifacts[0].Path != "/workspace/app.tar.gz" {
+		t.Fatalf("unexpected manifest: %s", raw)
+	}
+
+	mustWrite(t, manifestPath, "not json")
+	if err := appendArtifacts(manifestPath, []json.RawMessage{entry}); err == nil {
+		t.Fatal("expected an unparseable manifest to be left alone")
+	}
+}
+
+func mustWrite(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure rewrites within the same clock tick still look modified.
+	future := time.Now().Add(time.Duration(len(content)) * time.Second)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/blockdelta"
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+// writeFileDelta writes the file req names to w as a delta against the
+// base req carries signatures of.
+func writeFileDelta(req vsockexec.FileDelta, w io.Writer) error {
+	if !strings.HasPrefix(req.Path, "/") {
+		return errors.New("invalid path: must be absolute")
+	}
+	f, err := os.Open(req.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", req.Path)
+	}
+	if req.MaxBytes > 0 && info.Size() > req.MaxBytes {
+		return fmt.Errorf("file %q exceeds max_bytes=%d", req.Path, req.MaxBytes)
+	}
+	return blockdelta.Diff(bufio.NewReaderSize(f, 256*1024), req.BlockSize, req.Signatures, req.MaxBytes, w)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/blockdelta"
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+func TestWriteFileDeltaPatchesBase(t *testing.T) {
+	t.Parallel()
+
+	base := bytes.Repeat([]byte("cleanroom"), 4096)
+	file := append([]byte("header"), base...)
+	path := filepath.Join(t.TempDir(), "app.bin")
+	if err := os.WriteFile(path, file, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sigs, err := blockdelta.Sign(bytes.NewReader(base), 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var delta bytes.Buffer
+	if err := writeFileDelta(vsockexec.FileDelta{Path: path, BlockSize: 512, Signatures: sigs}, &delta); err != nil {
+		t.Fatalf("writeFileDelta returned error: %v", err)
+	}
+	var patched bytes.Buffer
+	if _, err := blockdelta.Patch(bytes.NewReader(base), 512, delta.Bytes(), &patched); err != nil || !bytes.Equal(patched.Bytes(), file) {
+		t.Fatalf("Patch returned %v", err)
+	}
+
+	for name, req := range map[string]vsockexec.FileDelta{
+		"directory": {Path: filepath.Dir(path), BlockSize: 512},
+		"too large": {Path: path, BlockSize: 512, MaxBytes: 100},
+		"relative":  {Path: "app.bin", BlockSize: 512},
+	} {
+		if err := writeFileDelta(req, &bytes.Buffer{}); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
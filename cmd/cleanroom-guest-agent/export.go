@@ -0,0 +1,149 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// exportRootFSCommand is the agent subcommand the host runs to read the
+// guest's root filesystem as a tarball on stdout.
+const exportRootFSCommand = "export-rootfs"
+
+// exportSkipRoots hold agent runtime state that lives on the root filesystem
+// but never belongs in an image.
+var exportSkipRoots = []string{"/cleanroom"}
+
+func exportRootFS(w io.Writer) error {
+	buf := bufio.NewWriterSize(w, 1<<20)
+	if err := writeRootFSTar(buf, "/", exportSkipRoots); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+type inodeKey struct {
+	dev, ino uint64
+}
+
+// writeRootFSTar writes everything under root that lives on root's own
+// filesystem to w as a tarball. Other mounts (kernel filesystems, tmpfs and
+// the scratch disks backing writable paths) appear as empty directories.
+// Hard links are kept, sockets and entries that vanish mid-walk are left out.
+func writeRootFSTar(w io.Writer, root string, skip []string) error {
+	rootInfo, err := os.Lstat(root)
+	if err != nil {
+		return err
+	}
+	rootDev, _, _, haveDev := fileIdentity(rootInfo)
+
+	tw := tar.NewWriter(w)
+	seen := map[inodeKey]string{}
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		guestPath := path.Join("/", filepath.ToSlash(rel))
+		if isSkippedChangePath(guestPath, skip) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSocket != 0 {
+			return nil
+		}
+
+		dev, ino, nlink, ok := fileIdentity(info)
+		otherMount := haveDev && ok && dev != rootDev
+		if otherMount && !info.IsDir() {
+			// A file bind-mounted over the rootfs, such as resolv.conf.
+			return nil
+		}
+
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return nil
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return fmt.Errorf("%s: %w", guestPath, err)
+		}
+		hdr.Name = strings.TrimPrefix(guestPath, "/")
+		if info.IsDir() {
+			hdr.Name += "/"
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if otherMount {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return tw.WriteHeader(hdr)
+		}
+
+		if ok && nlink > 1 {
+			key := inodeKey{dev: dev, ino: ino}
+			if first, dup := seen[key]; dup {
+				hdr.Typeflag = tar.TypeLink
+				hdr.Linkname = first
+				hdr.Size = 0
+				return tw.WriteHeader(hdr)
+			}
+			seen[key] = hdr.Name
+		}
+		return writeTarFile(tw, hdr, p)
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func writeTarFile(tw *tar.Writer, hdr *tar.Header, p string) error {
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	// The header carries the size from Lstat; copy exactly that much even if
+	// the file is still growing.
+	if _, err := io.CopyN(tw, f, hdr.Size); err != nil {
+		return fmt.Errorf("/%s changed while exporting: %w", hdr.Name, err)
+	}
+	return nil
+}
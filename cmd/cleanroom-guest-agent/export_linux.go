@@ -0,0 +1,17 @@
+//go:build linux
+
+package main
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileIdentity returns the device, inode and link count behind info.
+func fileIdentity(info fs.FileInfo) (dev, ino, nlink uint64, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	return uint64(st.Dev), st.Ino, uint64(st.Nlink), true
+}
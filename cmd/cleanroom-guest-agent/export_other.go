@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "io/fs"
+
+func fileIdentity(fs.FileInfo) (dev, ino, nlink uint64, ok bool) {
+	return 0, 0, 0, false
+}
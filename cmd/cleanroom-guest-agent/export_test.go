@@ -0,0 +1,69 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestWriteRootFSTarKeepsLinksAndSkipsRuntimeState(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	mustWrite(t, filepath.Join(root, "usr/bin/tool"), "binary")
+	mustWrite(t, filepath.Join(root, "cleanroom/artifacts.json"), "{}")
+	if err := os.Link(filepath.Join(root, "usr/bin/tool"), filepath.Join(root, "usr/bin/tool-alias")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("tool", filepath.Join(root, "usr/bin/tool-link")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeRootFSTar(&buf, root, []string{"/cleanroom"}); err != nil {
+		t.Fatalf("writeRootFSTar returned error: %v", err)
+	}
+
+	type entry struct {
+		Name     string
+		Type     byte
+		Linkname string
+		Body     string
+	}
+	var got []entry
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, entry{Name: hdr.Name, Type: hdr.Typeflag, Linkname: hdr.Linkname, Body: string(body)})
+	}
+
+	aliasType, aliasLink, aliasBody := byte(tar.TypeLink), "usr/bin/tool", ""
+	if runtime.GOOS != "linux" {
+		aliasType, aliasLink, aliasBody = tar.TypeReg, "", "binary"
+	}
+	want := []entry{
+		{Name: "usr/", Type: tar.TypeDir},
+		{Name: "usr/bin/", Type: tar.TypeDir},
+		{Name: "usr/bin/tool", Type: tar.TypeReg, Body: "binary"},
+		{Name: "usr/bin/tool-alias", Type: aliasType, Linkname: aliasLink, Body: aliasBody},
+		{Name: "usr/bin/tool-link", Type: tar.TypeSymlink, Linkname: "tool"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected tar entries:\n got %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,49 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+)
+
+const freshHomeBaseDir = "/run/cleanroom/home"
+
+var freshHomeSeq atomic.Uint64
+
+// freshHome is a per-execution tmpfs used as HOME and default working
+// directory, so persistent sandboxes can give each job a clean home without
+// re-provisioning the rootfs.
+type freshHome struct {
+	dir string
+}
+
+func prepareFreshHome() (*freshHome, error) {
+	if err := os.MkdirAll(freshHomeBaseDir, 0o755); err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(freshHomeBaseDir, fmt.Sprintf("exec-%d", freshHomeSeq.Add(1)))
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		return nil, err
+	}
+	if err := unix.Mount("tmpfs", dir, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=0700"); err != nil {
+		_ = os.Remove(dir)
+		return nil, fmt.Errorf("mount tmpfs at %s: %w", dir, err)
+	}
+	return &freshHome{dir: dir}, nil
+}
+
+// release unmounts the home. A lazy unmount lets background processes that
+// were kept running finish with their open files.
+func (h *freshHome) release() {
+	if h == nil || h.dir == "" {
+		return
+	}
+	_ = unix.Unmount(h.dir, unix.MNT_DETACH)
+	_ = os.Remove(h.dir)
+	h.dir = ""
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+const (
+	guestCgroupRoot   = "/sys/fs/cgroup"
+	guestCgroupParent = "cleanroom"
+)
+
+// Linux ioprio encoding from include/uapi/linux/ioprio.h.
+const (
+	ioprioClassShift = 13
+	ioprioClassRT    = 1
+	ioprioClassBE    = 2
+	ioprioClassIdle  = 3
+	ioprioWhoProcess = 1
+)
+
+type cgroupWrite struct {
+	File  string
+	Value string
+}
+
+// cgroupLimitWrites returns the cgroup v2 interface files that enforce the
+// requested limits, in the order they should be written.
+func cgroupLimitWrites(limits vsockexec.ResourceLimits) []cgroupWrite {
+	var writes []cgroupWrite
+	if limits.CPUWeight > 0 {
+		writes = append(writes, cgroupWrite{File: "cpu.weight", Value: strconv.FormatInt(limits.CPUWeight, 10)})
+	}
+	if limits.MemoryMaxBytes > 0 {
+		writes = append(writes,
+			cgroupWrite{File: "memory.max", Value: strconv.FormatInt(limits.MemoryMaxBytes, 10)},
+			// Kill the whole command tree together rather than leaving a
+			// partially OOM-killed build behind.
+			cgroupWrite{File: "memory.oom.group", Value: "1"},
+		)
+	}
+	return writes
+}
+
+// cgroupControllers returns the controllers that must be delegated to the
+// execution cgroup for the requested limits.
+func cgroupControllers(limits vsockexec.ResourceLimits) []string {
+	var controllers []string
+	if limits.CPUWeight > 0 {
+		controllers = append(controllers, "cpu")
+	}
+	if limits.MemoryMaxBytes > 0 {
+		controllers = append(controllers, "memory")
+	}
+	return controllers
+}
+
+// parseCgroupProcs parses the newline-separated pid list from cgroup.procs.
+func parseCgroupProcs(data string) []int {
+	var pids []int
+	for _, field := range strings.Fields(data) {
+		pid, err := strconv.Atoi(field)
+		if err != nil || pid <= 0 {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	return pids
+}
+
+// ioprioValue encodes an ioprio_set(2) priority. The second return value is
+// false when no IO class is requested.
+func ioprioValue(class string, level int) (int, bool, error) {
+	var classID int
+	switch strings.ToLower(strings.TrimSpace(class)) {
+	case "":
+		return 0, false, nil
+	case "realtime":
+		classID = ioprioClassRT
+	case "best-effort":
+		classID = ioprioClassBE
+	case "idle":
+		classID = ioprioClassIdle
+		level = 0
+	default:
+		return 0, false, fmt.Errorf("unknown io class %q", class)
+	}
+	if level < 0 || level > 7 {
+		return 0, false, fmt.Errorf("io priority %d out of range", level)
+	}
+	return classID<<ioprioClassShift | level, true, nil
+}
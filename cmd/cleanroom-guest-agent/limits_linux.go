@@ -0,0 +1,55 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+	"golang.org/x/sys/unix"
+)
+
+// applyProcessPriority applies scheduling priorities that can only be set on
+// a running process.
+func applyProcessPriority(pid int, limits vsockexec.ResourceLimits) error {
+	if limits.Nice != 0 {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, pid, limits.Nice); err != nil {
+			return fmt.Errorf("set nice %d: %w", limits.Nice, err)
+		}
+	}
+	prio, ok, err := ioprioValue(limits.IOClass, limits.IOPriority)
+	if err != nil {
+		return err
+	}
+	if ok {
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), uintptr(prio)); errno != 0 {
+			return fmt.Errorf("set io priority: %w", errno)
+		}
+	}
+	return nil
+}
+
+// cgroup2Mounted reports whether the guest init mounted the unified cgroup
+// hierarchy at guestCgroupRoot.
+func cgroup2Mounted() bool {
+	_, err := os.Stat(filepath.Join(guestCgroupRoot, "cgroup.controllers"))
+	return err == nil
+}
+
+func enableCgroupControllers(dir string, controllers []string) error {
+	if len(controllers) == 0 {
+		return nil
+	}
+	entries := make([]string, 0, len(controllers))
+	for _, controller := range controllers {
+		entries = append(entries, "+"+controller)
+	}
+	path := filepath.Join(dir, "cgroup.subtree_control")
+	if err := os.WriteFile(path, []byte(strings.Join(entries, " ")), 0o644); err != nil {
+		return fmt.Errorf("enable cgroup controllers %v in %s: %w", controllers, dir, err)
+	}
+	return nil
+}
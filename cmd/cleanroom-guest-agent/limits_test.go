@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+func TestCgroupLimitWritesForCPUAndMemory(t *testing.T) {
+	t.Parallel()
+
+	got := cgroupLimitWrites(vsockexec.ResourceLimits{CPUWeight: 50, MemoryMaxBytes: 1 << 30})
+	want := []cgroupWrite{
+		{File: "cpu.weight", Value: "50"},
+		{File: "memory.max", Value: "1073741824"},
+		{File: "memory.oom.group", Value: "1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected cgroup writes: got %+v want %+v", got, want)
+	}
+	if got, want := cgroupControllers(vsockexec.ResourceLimits{CPUWeight: 50, MemoryMaxBytes: 1 << 30}), []string{"cpu", "memory"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected controllers: got %v want %v", got, want)
+	}
+}
+
+func TestCgroupLimitWritesSkipsPriorityOnlyLimits(t *testing.T) {
+	t.Parallel()
+
+	limits := vsockexec.ResourceLimits{Nice: 10, IOClass: "idle"}
+	if got := cgroupLimitWrites(limits); len(got) != 0 {
+		t.Fatalf("expected no cgroup writes, got %+v", got)
+	}
+	if got := cgroupControllers(limits); len(got) != 0 {
+		t.Fatalf("expected no controllers, got %v", got)
+	}
+}
+
+func TestParseCgroupProcs(t *testing.T) {
+	t.Parallel()
+
+	got := parseCgroupProcs("12\n345\n\nbogus\n0\n")
+	if want := []int{12, 345}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected pids: got %v want %v", got, want)
+	}
+}
+
+func TestIOPrioValue(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		class string
+		level int
+		want  int
+		ok    bool
+	}{
+		{class: "", level: 0, want: 0, ok: false},
+		{class: "realtime", level: 2, want: 1<<13 | 2, ok: true},
+		{class: "best-effort", level: 7, want: 2<<13 | 7, ok: true},
+		{class: "idle", level: 4, want: 3 << 13, ok: true},
+	}
+	for _, tc := range cases {
+		got, ok, err := ioprioValue(tc.class, tc.level)
+		if err != nil {
+			t.Fatalf("ioprioValue(%q, %d) returned error: %v", tc.class, tc.level, err)
+		}
+		if got != tc.want || ok != tc.ok {
+			t.Fatalf("ioprioValue(%q, %d) = %d, %t; want %d, %t", tc.class, tc.level, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestIOPrioValueRejectsInvalidInput(t *testing.T) {
+	t.Parallel()
+
+	if _, _, err := ioprioValue("turbo", 0); err == nil {
+		t.Fatal("expected error for unknown io class")
+	}
+	if _, _, err := ioprioValue("best-effort", 8); err == nil {
+		t.Fatal("expected error for out-of-range io priority")
+	}
+}
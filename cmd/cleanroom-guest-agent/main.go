@@ -3,9 +3,9 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -14,6 +14,8 @@ import (
 	"os/exec"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 	"unsafe"
 
 	"github.com/buildkite/cleanroom/internal/vsockexec"
@@ -22,9 +24,22 @@ import (
 	"golang.org/x/sys/unix"
 )
 
+// backgroundOutputGrace bounds how long output is forwarded after the command
+// exits when background processes are kept running.
+const backgroundOutputGrace = 500 * time.Millisecond
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == exportRootFSCommand {
+		if err := exportRootFS(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		return
+	}
 	if strings.EqualFold(strings.TrimSpace(os.Getenv("CLEANROOM_GUEST_TRANSPORT")), "stdio") {
-		handleConn(stdioConn{})
+		// Each stdio connection is a fresh agent process, so an upgraded
+		// binary takes over from the next connection without a restart.
+		handleConn(stdioConn{}, nil)
 		return
 	}
 
@@ -50,29 +65,45 @@ func main() {
 			fmt.Fprintf(os.Stderr, "accept: %v\n", err)
 			continue
 		}
-		handleConn(conn)
+		if upgraded := handleConn(conn, func() { _ = ln.Close() }); upgraded != "" {
+			restartAgent(upgraded)
+		}
 	}
 }
 
-func handleConn(conn io.ReadWriteCloser) {
+// handleConn serves one request. After an agent upgrade it returns the path
+// of the new binary for the caller to restart into, once the connection is
+// closed.
+func handleConn(conn io.ReadWriteCloser, stopAccepting func()) string {
 	defer conn.Close()
 
-	// Use a single json.Decoder so buffered bytes from the request aren't
-	// lost when reading subsequent input frames.
-	dec := json.NewDecoder(conn)
+	// Use a single decoder so buffered bytes from the request aren't lost
+	// when reading subsequent input frames.
+	dec := vsockexec.NewFrameDecoder(conn)
 
-	var req vsockexec.ExecRequest
-	if err := dec.Decode(&req); err != nil {
+	req, err := dec.DecodeRequest()
+	if err != nil {
 		_ = vsockexec.EncodeResponse(conn, vsockexec.ExecResponse{ExitCode: 1, Error: err.Error()})
-		return
+		return ""
+	}
+	if req.AgentUpgrade != nil {
+		return handleAgentUpgrade(conn, dec, *req.AgentUpgrade, stopAccepting)
+	}
+	if req.FileDelta != nil {
+		handleFileDelta(conn, req)
+		return ""
 	}
 	if len(req.Command) == 0 {
 		_ = vsockexec.EncodeResponse(conn, vsockexec.ExecResponse{ExitCode: 1, Error: "missing command"})
-		return
+		return ""
 	}
 	if strings.TrimSpace(req.Command[0]) == "" {
 		_ = vsockexec.EncodeResponse(conn, vsockexec.ExecResponse{ExitCode: 1, Error: "missing command executable"})
-		return
+		return ""
+	}
+	if err := vsockexec.CheckCommand(req.Command, req.Env); err != nil {
+		_ = vsockexec.EncodeResponse(conn, vsockexec.ExecResponse{ExitCode: 1, Error: err.Error()})
+		return ""
 	}
 	if len(req.EntropySeed) > 0 {
 		_ = injectEntropy(req.EntropySeed)
@@ -83,108 +114,280 @@ func handleConn(conn io.ReadWriteCloser) {
 	} else {
 		handleConnPipes(conn, dec, req)
 	}
+	return ""
 }
 
-func handleConnTTY(conn io.ReadWriteCloser, dec *json.Decoder, req vsockexec.ExecRequest) {
+// buildCommand creates the command for req using the given launcher. When a
+// fresh home is requested the returned home must be released after the
+// command exits.
+func buildCommand(req vsockexec.ExecRequest, launcher string) (*exec.Cmd, *freshHome, error) {
+	dir := req.Dir
+	env := req.Env
+	if req.RequestID != "" {
+		env = append(append([]string(nil), env...), "CLEANROOM_REQUEST_ID="+req.RequestID)
+	}
+	var home *freshHome
+	if req.FreshHome {
+		var err error
+		home, err = prepareFreshHome()
+		if err != nil {
+			return nil, nil, fmt.Errorf("prepare fresh home: %w", err)
+		}
+		env = append(append([]string(nil), env...), "HOME="+home.dir)
+		if dir == "" {
+			dir = home.dir
+		}
+	}
+
+	if launcher == vsockexec.LauncherSystemd {
+		// The unit starts from the service manager's environment, so only the
+		// request's variables are forwarded. systemd-run gets their values
+		// through its own environment rather than its arguments.
+		args := systemdRunArgs(req, newSystemdUnitName(), dir, env)
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Env = buildCommandEnv(env)
+		return cmd, home, nil
+	}
+
 	cmd := exec.Command(req.Command[0], req.Command[1:]...)
-	if req.Dir != "" {
-		cmd.Dir = req.Dir
+	cmd.Dir = dir
+	cmd.Env = buildCommandEnv(env)
+	return cmd, home, nil
+}
+
+func handleConnTTY(conn io.ReadWriteCloser, dec *vsockexec.FrameDecoder, req vsockexec.ExecRequest) {
+	launcher, err := resolveLauncher(req.Launcher)
+	if err != nil {
+		sendErrorResponse(conn, err)
+		return
 	}
-	env := buildCommandEnv(req.Env)
-	if !envHasKey(env, "TERM") {
-		env = append(env, "TERM=xterm-256color")
+	cmd, home, err := buildCommand(req, launcher)
+	if err != nil {
+		sendErrorResponse(conn, err)
+		return
+	}
+	defer home.release()
+	if !envHasKey(cmd.Env, "TERM") {
+		cmd.Env = append(cmd.Env, "TERM=xterm-256color")
 	}
-	cmd.Env = env
 
+	scope, err := prepareExecScope(cmd, req, launcher)
+	if err != nil {
+		sendErrorResponse(conn, fmt.Errorf("apply resource limits: %w", err))
+		return
+	}
+	defer scope.finish()
+
+	capture, err := startChangeCapture(req.CaptureChanges)
+	if err != nil {
+		sendErrorResponse(conn, fmt.Errorf("capture changes: %w", err))
+		return
+	}
+
+	sender := newFrameSender(conn, req.Compression)
+	if req.Banner != "" {
+		_, _ = io.WriteString(streamFrameWriter{send: sender.Send, kind: "stdout"}, terminalBanner(req.Banner))
+	}
+
+	started := time.Now()
 	ptmx, err := pty.Start(cmd)
 	if err != nil {
 		sendErrorResponse(conn, err)
 		return
 	}
 	defer ptmx.Close()
+	if err := scope.started(cmd.Process.Pid); err != nil {
+		abortStartedCommand(cmd, scope)
+		sendErrorResponse(conn, fmt.Errorf("apply resource limits: %w", err))
+		return
+	}
 
-	sender := newFrameSender(conn)
-
-	go readInputFrames(dec, ptmx, func() { _ = ptmx.Close() }, func(cols, rows uint16) {
+	session := newSessionTimer(req.SessionLimits, func(line string) {
+		_, _ = fmt.Fprintf(streamFrameWriter{send: sender.Send, kind: "stdout"}, "\r\ncleanroom: %s\r\n", line)
+	}, func() { _ = cmd.Process.Kill() })
+	var input io.Writer = ptmx
+	if session.active() {
+		input = inputWriter{w: ptmx, touch: session.touch}
+		go session.run()
+		defer session.stop()
+	}
+	go readInputFrames(dec, input, func() { _ = ptmx.Close() }, func(cols, rows uint16) {
 		_ = pty.Setsize(ptmx, &pty.Winsize{Cols: cols, Rows: rows})
 	})
 
-	// PTY read returns EIO when the slave side closes; ignore the error.
-	_, _ = io.Copy(streamFrameWriter{send: sender.Send, kind: "stdout"}, ptmx)
+	outputDone := make(chan struct{})
+	go func() {
+		defer close(outputDone)
+		// PTY read returns EIO when the slave side closes; ignore the error.
+		_, _ = io.Copy(streamFrameWriter{send: sender.Send, kind: "stdout"}, ptmx)
+	}()
+
+	waitErr := cmd.Wait()
+	scope.finish()
+	waitForOutput(outputDone, scope.keepBackground, ptmx)
+	metadata := exitMetadata(cmd.ProcessState, started)
+	if err := capture.finish(); err != nil {
+		_, _ = fmt.Fprintf(streamFrameWriter{send: sender.Send, kind: "stdout"}, "cleanroom: capture changes: %v\r\n", err)
+	}
 
-	sendExitResult(sender, conn, cmd.Wait())
+	sendExitResult(sender, conn, waitErr, metadata)
 }
 
-func handleConnPipes(conn io.ReadWriteCloser, dec *json.Decoder, req vsockexec.ExecRequest) {
-	cmd := exec.Command(req.Command[0], req.Command[1:]...)
-	if req.Dir != "" {
-		cmd.Dir = req.Dir
+// terminalBanner formats a console banner for a raw terminal: lines end in
+// CRLF and the banner is followed by a blank line. The host has already
+// rejected control characters.
+func terminalBanner(banner string) string {
+	return strings.ReplaceAll(banner, "\n", "\r\n") + "\r\n\r\n"
+}
+
+func handleConnPipes(conn io.ReadWriteCloser, dec *vsockexec.FrameDecoder, req vsockexec.ExecRequest) {
+	launcher, err := resolveLauncher(req.Launcher)
+	if err != nil {
+		sendErrorResponse(conn, err)
+		return
+	}
+	cmd, home, err := buildCommand(req, launcher)
+	if err != nil {
+		sendErrorResponse(conn, err)
+		return
+	}
+	defer home.release()
+
+	scope, err := prepareExecScope(cmd, req, launcher)
+	if err != nil {
+		sendErrorResponse(conn, fmt.Errorf("apply resource limits: %w", err))
+		return
+	}
+	defer scope.finish()
+
+	capture, err := startChangeCapture(req.CaptureChanges)
+	if err != nil {
+		sendErrorResponse(conn, fmt.Errorf("capture changes: %w", err))
+		return
 	}
-	cmd.Env = buildCommandEnv(req.Env)
 
 	stdinPipe, err := cmd.StdinPipe()
 	if err != nil {
 		sendErrorResponse(conn, err)
 		return
 	}
-	stdout, err := cmd.StdoutPipe()
+	// Use plain pipes rather than StdoutPipe/StderrPipe so Wait returns when
+	// the command exits, even if a background process still holds the write
+	// side open.
+	stdout, stdoutWriter, err := os.Pipe()
 	if err != nil {
 		sendErrorResponse(conn, err)
 		return
 	}
-	stderr, err := cmd.StderrPipe()
+	defer stdout.Close()
+	stderr, stderrWriter, err := os.Pipe()
 	if err != nil {
+		_ = stdoutWriter.Close()
 		sendErrorResponse(conn, err)
 		return
 	}
+	defer stderr.Close()
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
 
-	if err := cmd.Start(); err != nil {
-		sendErrorResponse(conn, err)
+	started := time.Now()
+	startErr := cmd.Start()
+	_ = stdoutWriter.Close()
+	_ = stderrWriter.Close()
+	if startErr != nil {
+		sendErrorResponse(conn, startErr)
+		return
+	}
+	if err := scope.started(cmd.Process.Pid); err != nil {
+		abortStartedCommand(cmd, scope)
+		sendErrorResponse(conn, fmt.Errorf("apply resource limits: %w", err))
 		return
 	}
 
-	sender := newFrameSender(conn)
+	sender := newFrameSender(conn, req.Compression)
 
 	go readInputFrames(dec, stdinPipe, func() { _ = stdinPipe.Close() }, nil)
 
 	var stdoutBuf bytes.Buffer
 	var stderrBuf bytes.Buffer
+	var stdoutSink io.Writer = &stdoutBuf
+	if req.StreamStdout {
+		stdoutSink = io.Discard
+	}
 	var wg sync.WaitGroup
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
-		_, _ = io.Copy(io.MultiWriter(&stdoutBuf, streamFrameWriter{send: sender.Send, kind: "stdout"}), stdout)
+		_, _ = io.Copy(io.MultiWriter(stdoutSink, streamFrameWriter{send: sender.Send, kind: "stdout"}), stdout)
 	}()
 	go func() {
 		defer wg.Done()
 		_, _ = io.Copy(io.MultiWriter(&stderrBuf, streamFrameWriter{send: sender.Send, kind: "stderr"}), stderr)
 	}()
+	outputDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(outputDone)
+	}()
 
-	wg.Wait()
 	waitErr := cmd.Wait()
+	scope.finish()
+	waitForOutput(outputDone, scope.keepBackground, stdout, stderr)
 	exitCode, errMsg := exitResult(waitErr)
+	metadata := exitMetadata(cmd.ProcessState, started)
+	if err := capture.finish(); err != nil {
+		_, _ = fmt.Fprintf(io.MultiWriter(&stderrBuf, streamFrameWriter{send: sender.Send, kind: "stderr"}), "cleanroom: capture changes: %v\n", err)
+	}
 
 	if err := sender.Send(vsockexec.ExecStreamFrame{
 		Type:     "exit",
 		ExitCode: exitCode,
 		Error:    errMsg,
+		Metadata: metadata,
 	}); err != nil {
 		_ = vsockexec.EncodeResponse(conn, vsockexec.ExecResponse{
 			ExitCode: exitCode,
 			Error:    errMsg,
 			Stdout:   stdoutBuf.String(),
 			Stderr:   stderrBuf.String(),
+			Metadata: metadata,
 		})
 	}
 }
 
-func readInputFrames(dec *json.Decoder, w io.Writer, closeStdin func(), resizeFn func(cols, rows uint16)) {
+// waitForOutput waits for output forwarding to finish after the command has
+// exited. Background processes that were allowed to outlive the command can
+// hold the output open indefinitely, so in that case output is only drained
+// for a short grace period.
+func waitForOutput(done <-chan struct{}, keepBackground bool, readers ...*os.File) {
+	if !keepBackground {
+		<-done
+		return
+	}
+	select {
+	case <-done:
+		return
+	case <-time.After(backgroundOutputGrace):
+	}
+	for _, r := range readers {
+		_ = r.SetReadDeadline(time.Now())
+	}
+	select {
+	case <-done:
+	case <-time.After(backgroundOutputGrace):
+	}
+}
+
+func readInputFrames(dec *vsockexec.FrameDecoder, w io.Writer, closeStdin func(), resizeFn func(cols, rows uint16)) {
 	if closeStdin != nil {
 		defer closeStdin()
 	}
 	for {
-		var frame vsockexec.ExecInputFrame
-		if err := dec.Decode(&frame); err != nil {
+		frame, err := dec.DecodeInputFrame()
+		if err != nil {
+			if errors.Is(err, vsockexec.ErrFrameTooLarge) {
+				fmt.Fprintf(os.Stderr, "read input frame: %v\n", err)
+			}
 			return
 		}
 		switch frame.Type {
@@ -204,24 +407,73 @@ func readInputFrames(dec *json.Decoder, w io.Writer, closeStdin func(), resizeFn
 	}
 }
 
+// handleFileDelta sends the delta req asks for as stdout frames, then an
+// exit frame that reports whether it is complete.
+func handleFileDelta(conn io.Writer, req vsockexec.ExecRequest) {
+	sender := newFrameSender(conn, req.Compression)
+	out := bufio.NewWriterSize(streamFrameWriter{send: sender.Send, kind: "stdout"}, 64*1024)
+	err := writeFileDelta(*req.FileDelta, out)
+	if err == nil {
+		err = out.Flush()
+	}
+	exit := vsockexec.ExecStreamFrame{Type: "exit"}
+	if err != nil {
+		exit.ExitCode = 1
+		exit.Error = fmt.Sprintf("file delta: %v", err)
+	}
+	_ = sender.Send(exit)
+}
+
+// abortStartedCommand kills a command that started but could not be placed
+// under its requested limits.
+func abortStartedCommand(cmd *exec.Cmd, scope *execScope) {
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+	scope.finish()
+}
+
 func sendErrorResponse(w io.Writer, err error) {
 	_ = vsockexec.EncodeResponse(w, vsockexec.ExecResponse{ExitCode: 1, Error: err.Error()})
 }
 
-func sendExitResult(sender *frameSender, w io.Writer, waitErr error) {
+func sendExitResult(sender *frameSender, w io.Writer, waitErr error, metadata *vsockexec.ExitMetadata) {
 	exitCode, errMsg := exitResult(waitErr)
 	if err := sender.Send(vsockexec.ExecStreamFrame{
 		Type:     "exit",
 		ExitCode: exitCode,
 		Error:    errMsg,
+		Metadata: metadata,
 	}); err != nil {
 		_ = vsockexec.EncodeResponse(w, vsockexec.ExecResponse{
 			ExitCode: exitCode,
 			Error:    errMsg,
+			Metadata: metadata,
 		})
 	}
 }
 
+// exitMetadata summarises a finished command for the exit frame. With the
+// systemd launcher the rusage is that of systemd-run rather than the command
+// itself, so CPU time and max RSS are only indicative there.
+func exitMetadata(state *os.ProcessState, started time.Time) *vsockexec.ExitMetadata {
+	if state == nil {
+		return nil
+	}
+	metadata := &vsockexec.ExitMetadata{
+		UserCPUMillis:   state.UserTime().Milliseconds(),
+		SystemCPUMillis: state.SystemTime().Milliseconds(),
+		WallMillis:      time.Since(started).Milliseconds(),
+	}
+	if usage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		metadata.MaxRSSBytes = usage.Maxrss * 1024 // KiB on Linux
+	}
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		metadata.Signal = int(status.Signal())
+		metadata.SignalName = unix.SignalName(status.Signal())
+	}
+	return metadata
+}
+
 type stdioConn struct{}
 
 func (stdioConn) Read(p []byte) (int, error)  { return os.Stdin.Read(p) }
@@ -249,15 +501,21 @@ func envHasKey(env []string, key string) bool {
 }
 
 type frameSender struct {
-	w  io.Writer
-	mu sync.Mutex
+	w        io.Writer
+	compress bool // zstd-compress output frames
+	mu       sync.Mutex
 }
 
-func newFrameSender(w io.Writer) *frameSender {
-	return &frameSender{w: w}
+// newFrameSender sends frames to w, compressing output frames when the
+// request asked for a compression the agent supports.
+func newFrameSender(w io.Writer, compression string) *frameSender {
+	return &frameSender{w: w, compress: compression == vsockexec.CompressionZstd}
 }
 
 func (s *frameSender) Send(frame vsockexec.ExecStreamFrame) error {
+	if s.compress {
+		frame = vsockexec.CompressStreamFrame(frame)
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	return vsockexec.EncodeStreamFrame(s.w, frame)
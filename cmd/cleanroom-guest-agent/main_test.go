@@ -0,0 +1,75 @@
+//go:build linux
+
+package main
+
+import (
+	"os/exec"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+func TestExitMetadataReportsTerminatingSignal(t *testing.T) {
+	t.Parallel()
+
+	cmd := exec.Command("sh", "-c", "kill -KILL $$")
+	started := time.Now()
+	_ = cmd.Run()
+
+	metadata := exitMetadata(cmd.ProcessState, started)
+	if metadata == nil {
+		t.Fatal("expected exit metadata")
+	}
+	if metadata.Signal != 9 || metadata.SignalName != "SIGKILL" {
+		t.Fatalf("unexpected signal: got %d %q want 9 %q", metadata.Signal, metadata.SignalName, "SIGKILL")
+	}
+	if metadata.MaxRSSBytes <= 0 {
+		t.Fatalf("expected max RSS to be reported, got %d", metadata.MaxRSSBytes)
+	}
+}
+
+func TestExitMetadataForNormalExit(t *testing.T) {
+	t.Parallel()
+
+	cmd := exec.Command("sh", "-c", "exit 3")
+	started := time.Now()
+	_ = cmd.Run()
+
+	metadata := exitMetadata(cmd.ProcessState, started)
+	if metadata == nil || metadata.Signal != 0 || metadata.SignalName != "" {
+		t.Fatalf("unexpected metadata for normal exit: %+v", metadata)
+	}
+	if metadata.WallMillis < 0 {
+		t.Fatalf("unexpected wall time: %d", metadata.WallMillis)
+	}
+	if exitMetadata(nil, started) != nil {
+		t.Fatal("expected nil metadata for a command that never ran")
+	}
+}
+
+func TestBuildCommandExportsRequestID(t *testing.T) {
+	t.Parallel()
+
+	cmd, home, err := buildCommand(vsockexec.ExecRequest{Command: []string{"true"}, Env: []string{"FOO=bar"}, RequestID: "req-1"}, vsockexec.LauncherDirect)
+	if err != nil {
+		t.Fatalf("buildCommand returned error: %v", err)
+	}
+	if home != nil {
+		t.Fatal("expected no fresh home")
+	}
+	for _, want := range []string{"FOO=bar", "CLEANROOM_REQUEST_ID=req-1"} {
+		if !slices.Contains(cmd.Env, want) {
+			t.Fatalf("expected %s in command env, got %v", want, cmd.Env)
+		}
+	}
+}
+
+func TestTerminalBannerUsesCRLF(t *testing.T) {
+	t.Parallel()
+
+	if got, want := terminalBanner("one\ntwo"), "one\r\ntwo\r\n\r\n"; got != want {
+		t.Fatalf("unexpected terminal banner: got %q want %q", got, want)
+	}
+}
@@ -0,0 +1,175 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	scopeKillTimeout  = 2 * time.Second
+	scopeKillInterval = 10 * time.Millisecond
+)
+
+var execCgroupSeq atomic.Uint64
+
+// execScope tracks the processes started by one execution. Each execution runs
+// in its own transient cgroup so resource limits apply to the whole command
+// tree and anything it leaves behind can be killed when the command exits.
+type execScope struct {
+	limits         vsockexec.ResourceLimits
+	keepBackground bool
+	cgroupDir      string
+	cgroupFD       *os.File
+	pid            int
+	finished       bool
+}
+
+// prepareExecScope creates the execution cgroup and arranges for cmd to start
+// inside it. When the guest kernel has no usable cgroup v2 hierarchy and no
+// limits were requested, cleanup falls back to the command's process group.
+// Commands launched through systemd get an empty scope because the transient
+// unit already owns limits and teardown.
+func prepareExecScope(cmd *exec.Cmd, req vsockexec.ExecRequest, launcher string) (*execScope, error) {
+	if launcher == vsockexec.LauncherSystemd {
+		return &execScope{keepBackground: true}, nil
+	}
+	scope := &execScope{keepBackground: req.KeepBackground}
+	if req.Limits != nil {
+		scope.limits = *req.Limits
+		if _, _, err := ioprioValue(scope.limits.IOClass, scope.limits.IOPriority); err != nil {
+			return nil, err
+		}
+	}
+	controllers := cgroupControllers(scope.limits)
+	if scope.keepBackground && len(controllers) == 0 {
+		return scope, nil
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	if err := scope.createCgroup(controllers); err != nil {
+		if len(controllers) > 0 {
+			return nil, err
+		}
+		// TTY commands already lead their own session and process group.
+		if !req.TTY {
+			cmd.SysProcAttr.Setpgid = true
+		}
+		return scope, nil
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(scope.cgroupFD.Fd())
+	return scope, nil
+}
+
+func (s *execScope) createCgroup(controllers []string) error {
+	if !cgroup2Mounted() {
+		return fmt.Errorf("cgroup2 is not mounted at %s", guestCgroupRoot)
+	}
+	parent := filepath.Join(guestCgroupRoot, guestCgroupParent)
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return fmt.Errorf("create cgroup %s: %w", parent, err)
+	}
+	if err := enableCgroupControllers(guestCgroupRoot, controllers); err != nil {
+		return err
+	}
+	if err := enableCgroupControllers(parent, controllers); err != nil {
+		return err
+	}
+
+	dir := filepath.Join(parent, fmt.Sprintf("exec-%d", execCgroupSeq.Add(1)))
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		return fmt.Errorf("create cgroup %s: %w", dir, err)
+	}
+	s.cgroupDir = dir
+	for _, write := range cgroupLimitWrites(s.limits) {
+		if err := os.WriteFile(filepath.Join(dir, write.File), []byte(write.Value), 0o644); err != nil {
+			s.removeCgroup()
+			return fmt.Errorf("set %s: %w", write.File, err)
+		}
+	}
+	fd, err := os.Open(dir)
+	if err != nil {
+		s.removeCgroup()
+		return fmt.Errorf("open cgroup %s: %w", dir, err)
+	}
+	s.cgroupFD = fd
+	return nil
+}
+
+// started records the command's pid and applies scheduling priorities.
+func (s *execScope) started(pid int) error {
+	s.pid = pid
+	s.closeCgroupFD()
+	return applyProcessPriority(pid, s.limits)
+}
+
+// finish kills anything the command left running, unless the execution opted
+// to keep background processes, and removes the execution cgroup.
+func (s *execScope) finish() {
+	if s.finished {
+		return
+	}
+	s.finished = true
+	s.closeCgroupFD()
+	if !s.keepBackground {
+		if s.cgroupDir != "" {
+			killCgroup(s.cgroupDir)
+		} else if s.pid > 0 {
+			_ = unix.Kill(-s.pid, unix.SIGKILL)
+		}
+	}
+	s.removeCgroup()
+}
+
+func (s *execScope) closeCgroupFD() {
+	if s.cgroupFD != nil {
+		_ = s.cgroupFD.Close()
+		s.cgroupFD = nil
+	}
+}
+
+func (s *execScope) removeCgroup() {
+	if s.cgroupDir == "" {
+		return
+	}
+	// Removal fails while background processes are still running in the
+	// cgroup; they keep it alive until they exit.
+	_ = os.Remove(s.cgroupDir)
+	s.cgroupDir = ""
+}
+
+// killCgroup kills every process in the cgroup and waits briefly for it to
+// drain. cgroup.kill needs Linux 5.14; older kernels fall back to signalling
+// each listed process.
+func killCgroup(dir string) {
+	useKillFile := os.WriteFile(filepath.Join(dir, "cgroup.kill"), []byte("1"), 0o644) == nil
+	deadline := time.Now().Add(scopeKillTimeout)
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+		if err != nil {
+			return
+		}
+		pids := parseCgroupProcs(string(data))
+		if len(pids) == 0 || time.Now().After(deadline) {
+			return
+		}
+		if !useKillFile {
+			for _, pid := range pids {
+				_ = unix.Kill(pid, unix.SIGKILL)
+			}
+		}
+		time.Sleep(scopeKillInterval)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+// sessionWarnings are how long before a console session reaches a limit
+// the agent warns in its terminal.
+var sessionWarnings = []time.Duration{5 * time.Minute, time.Minute}
+
+// sessionTimer ends an interactive session that runs longer than maxLength
+// or goes longer than idle without input. A zero duration is no limit.
+type sessionTimer struct {
+	maxLength time.Duration
+	idle      time.Duration
+	warnings  []time.Duration
+	// notify writes a line to the session's terminal.
+	notify func(string)
+	// expire ends the session.
+	expire func()
+
+	activity chan struct{}
+	done     chan struct{}
+}
+
+func newSessionTimer(limits *vsockexec.SessionLimits, notify func(string), expire func()) *sessionTimer {
+	t := &sessionTimer{
+		warnings: sessionWarnings,
+		notify:   notify,
+		expire:   expire,
+		activity: make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	if limits != nil {
+		t.maxLength = time.Duration(limits.MaxSeconds) * time.Second
+		t.idle = time.Duration(limits.IdleSeconds) * time.Second
+	}
+	return t
+}
+
+// active reports whether the timer has a limit to enforce.
+func (t *sessionTimer) active() bool {
+	return t.maxLength > 0 || t.idle > 0
+}
+
+// touch records input, restarting the idle limit.
+func (t *sessionTimer) touch() {
+	select {
+	case t.activity <- struct{}{}:
+	default:
+	}
+}
+
+// stop ends the timer without expiring the session.
+func (t *sessionTimer) stop() {
+	close(t.done)
+}
+
+// run enforces the limits until stop is called or the session expires.
+func (t *sessionTimer) run() {
+	started := time.Now()
+	lastInput := started
+	lengthWarned := t.warnedBefore(t.maxLength)
+	idleWarned := t.warnedBefore(t.idle)
+	for {
+		now := time.Now()
+		lengthLeft := t.maxLength - now.Sub(started)
+		idleLeft := t.idle - now.Sub(lastInput)
+		switch {
+		case t.maxLength > 0 && lengthLeft <= 0:
+			t.notify(fmt.Sprintf("Session ended: it reached the maximum length of %s.", describeDuration(t.maxLength)))
+			t.expire()
+			return
+		case t.idle > 0 && idleLeft <= 0:
+			t.notify(fmt.Sprintf("Session ended: there was no input for %s.", describeDuration(t.idle)))
+			t.expire()
+			return
+		}
+		if t.maxLength > 0 && lengthWarned < len(t.warnings) && lengthLeft <= t.warnings[lengthWarned] {
+			t.notify(fmt.Sprintf("This session ends in %s, when it reaches the maximum length of %s.", describeDuration(t.warnings[lengthWarned]), describeDuration(t.maxLength)))
+			lengthWarned = t.warnedBefore(lengthLeft)
+		}
+		if t.idle > 0 && idleWarned < len(t.warnings) && idleLeft <= t.warnings[idleWarned] {
+			t.notify(fmt.Sprintf("This session ends in %s unless there is input.", describeDuration(t.warnings[idleWarned])))
+			idleWarned = t.warnedBefore(idleLeft)
+		}
+
+		wait := time.Duration(1<<63 - 1)
+		if t.maxLength > 0 {
+			wait = min(wait, t.untilNext(lengthLeft, lengthWarned))
+		}
+		if t.idle > 0 {
+			wait = min(wait, t.untilNext(idleLeft, idleWarned))
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-t.done:
+			timer.Stop()
+			return
+		case <-t.activity:
+			timer.Stop()
+			lastInput = time.Now()
+			idleWarned = t.warnedBefore(t.idle)
+		case <-timer.C:
+		}
+	}
+}
+
+// warnedBefore returns the index of the first warning still ahead when
+// left remains, skipping warnings that would fall at or before it.
+func (t *sessionTimer) warnedBefore(left time.Duration) int {
+	i := 0
+	for i < len(t.warnings) && t.warnings[i] >= left {
+		i++
+	}
+	return i
+}
+
+// untilNext returns how long until the next warning or, once they have
+// all been given, the limit itself.
+func (t *sessionTimer) untilNext(left time.Duration, warned int) time.Duration {
+	if warned < len(t.warnings) {
+		return left - t.warnings[warned]
+	}
+	return left
+}
+
+// describeDuration formats whole hours and minutes as words for warnings and falls
+// back to Go's duration format otherwise.
+func describeDuration(d time.Duration) string {
+	switch {
+	case d == time.Hour:
+		return "1 hour"
+	case d > 0 && d%time.Hour == 0:
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	case d == time.Minute:
+		return "1 minute"
+	case d > 0 && d%time.Minute == 0:
+		return fmt.Sprintf("%d minutes", d/time.Minute)
+	}
+	return d.String()
+}
+
+// inputWriter passes a session's input to w, calling touch for each write.
+type inputWriter struct {
+	w     io.Writer
+	touch func()
+}
+
+func (w inputWriter) Write(p []byte) (int, error) {
+	w.touch()
+	return w.w.Write(p)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type sessionRecorder struct {
+	mu      sync.Mutex
+	lines   []string
+	expired chan struct{}
+}
+
+func newTestSessionTimer(maxLength, idle time.Duration) (*sessionTimer, *sessionRecorder) {
+	rec := &sessionRecorder{expired: make(chan struct{})}
+	t := newSessionTimer(nil, func(line string) {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.lines = append(rec.lines, line)
+	}, func() { close(rec.expired) })
+	t.maxLength = maxLength
+	t.idle = idle
+	t.warnings = []time.Duration{200 * time.Millisecond, 100 * time.Millisecond}
+	return t, rec
+}
+
+func (r *sessionRecorder) text() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.lines, "\n")
+}
+
+func TestSessionTimerWarnsThenExpiresAtMaxLength(t *testing.T) {
+	t.Parallel()
+
+	timer, rec := newTestSessionTimer(400*time.Millisecond, 0)
+	started := time.Now()
+	go timer.run()
+	select {
+	case <-rec.expired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("session did not expire")
+	}
+	if elapsed := time.Since(started); elapsed < 400*time.Millisecond {
+		t.Fatalf("session expired early, after %s", elapsed)
+	}
+	got := rec.text()
+	if strings.Count(got, "This session ends in") != 2 || !strings.Contains(got, "Session ended: it reached the maximum length") {
+		t.Fatalf("expected two warnings then the end of the session, got:\n%s", got)
+	}
+	if strings.Index(got, "200ms") > strings.Index(got, "100ms") {
+		t.Fatalf("expected warnings in order, got:\n%s", got)
+	}
+}
+
+func TestSessionTimerInputRestartsIdleLimit(t *testing.T) {
+	t.Parallel()
+
+	timer, rec := newTestSessionTimer(0, 300*time.Millisecond)
+	go timer.run()
+	defer timer.stop()
+	var input strings.Builder
+	w := inputWriter{w: &input, touch: timer.touch}
+	for range 5 {
+		time.Sleep(50 * time.Millisecond)
+		_, _ = w.Write([]byte("x"))
+	}
+	select {
+	case <-rec.expired:
+		t.Fatalf("session expired despite input:\n%s", rec.text())
+	default:
+	}
+	if input.String() != "xxxxx" {
+		t.Fatalf("expected input to pass through, got %q", input.String())
+	}
+	select {
+	case <-rec.expired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle session did not expire")
+	}
+	if got := rec.text(); !strings.Contains(got, "unless there is input") || !strings.Contains(got, "no input for 300ms") {
+		t.Fatalf("expected idle warnings and expiry, got:\n%s", got)
+	}
+}
+
+func TestSessionTimerSkipsWarningsLongerThanLimit(t *testing.T) {
+	t.Parallel()
+
+	timer, rec := newTestSessionTimer(150*time.Millisecond, 0)
+	go timer.run()
+	select {
+	case <-rec.expired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("session did not expire")
+	}
+	if got := rec.text(); strings.Contains(got, "200ms") || strings.Count(got, "This session ends in") != 1 {
+		t.Fatalf("expected only the warning shorter than the limit, got:\n%s", got)
+	}
+}
+
+func TestDescribeDuration(t *testing.T) {
+	t.Parallel()
+
+	for d, want := range map[time.Duration]string{
+		time.Minute:      "1 minute",
+		5 * time.Minute:  "5 minutes",
+		8 * time.Hour:    "8 hours",
+		90 * time.Minute: "90 minutes",
+		90 * time.Second: "1m30s",
+	} {
+		if got := describeDuration(d); got != want {
+			t.Fatalf("describeDuration(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+// systemdRunArgs builds a systemd-run invocation that runs req as a transient
+// service unit. Resource limits map onto unit properties so systemd owns the
+// unit's cgroup, journald sees the unit, and stopping the unit tears down the
+// whole command tree. systemd-run must run with env in its environment.
+func systemdRunArgs(req vsockexec.ExecRequest, unit, dir string, env []string) []string {
+	args := []string{
+		"systemd-run",
+		"--quiet",
+		"--collect",
+		"--wait",
+		"--service-type=exec",
+		"--unit=" + unit,
+	}
+	if req.TTY {
+		args = append(args, "--pty")
+	} else {
+		args = append(args, "--pipe")
+	}
+	if dir != "" {
+		args = append(args, "--working-directory="+dir)
+	}
+	// Only names go on the command line, where any guest process can read
+	// them; systemd-run copies each value from its own environment.
+	seen := map[string]bool{}
+	for _, entry := range env {
+		key, _, _ := strings.Cut(entry, "=")
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		args = append(args, "--setenv="+key)
+	}
+	if limits := req.Limits; limits != nil {
+		if limits.Nice != 0 {
+			args = append(args, "--property=Nice="+strconv.Itoa(limits.Nice))
+		}
+		if limits.IOClass != "" {
+			args = append(args, "--property=IOSchedulingClass="+limits.IOClass)
+			if limits.IOClass != "idle" {
+				args = append(args, "--property=IOSchedulingPriority="+strconv.Itoa(limits.IOPriority))
+			}
+		}
+		if limits.CPUWeight > 0 {
+			args = append(args, "--property=CPUWeight="+strconv.FormatInt(limits.CPUWeight, 10))
+		}
+		if limits.MemoryMaxBytes > 0 {
+			args = append(args, "--property=MemoryMax="+strconv.FormatInt(limits.MemoryMaxBytes, 10))
+		}
+	}
+	if req.KeepBackground {
+		args = append(args, "--property=KillMode=process")
+	}
+	args = append(args, "--")
+	return append(args, req.Command...)
+}
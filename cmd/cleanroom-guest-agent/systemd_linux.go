@@ -0,0 +1,49 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync/atomic"
+
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+var systemdUnitSeq atomic.Uint64
+
+// resolveLauncher picks how to start a command. Auto-detection uses systemd
+// only when the image booted it, following sd_booted(3).
+func resolveLauncher(requested string) (string, error) {
+	switch requested {
+	case vsockexec.LauncherDirect:
+		return vsockexec.LauncherDirect, nil
+	case vsockexec.LauncherSystemd:
+		if !systemdBooted() {
+			return "", errors.New("systemd launcher requested but the guest was not booted with systemd")
+		}
+		return vsockexec.LauncherSystemd, nil
+	case vsockexec.LauncherAuto:
+		if systemdBooted() {
+			return vsockexec.LauncherSystemd, nil
+		}
+		return vsockexec.LauncherDirect, nil
+	default:
+		return "", fmt.Errorf("unknown launcher %q", requested)
+	}
+}
+
+func systemdBooted() bool {
+	info, err := os.Stat("/run/systemd/system")
+	if err != nil || !info.IsDir() {
+		return false
+	}
+	_, err = exec.LookPath("systemd-run")
+	return err == nil
+}
+
+func newSystemdUnitName() string {
+	return fmt.Sprintf("cleanroom-exec-%d-%d", os.Getpid(), systemdUnitSeq.Add(1))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+func TestSystemdRunArgsPipesBatchCommands(t *testing.T) {
+	t.Parallel()
+
+	got := systemdRunArgs(vsockexec.ExecRequest{Command: []string{"make", "test"}}, "cleanroom-exec-1-1", "/workspace", []string{"CI=true", "TOKEN=secret", "CI=false"})
+	want := []string{
+		"systemd-run", "--quiet", "--collect", "--wait", "--service-type=exec", "--unit=cleanroom-exec-1-1",
+		"--pipe",
+		"--working-directory=/workspace",
+		"--setenv=CI",
+		"--setenv=TOKEN",
+		"--", "make", "test",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected systemd-run args:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestSystemdRunArgsMapsLimitsToUnitProperties(t *testing.T) {
+	t.Parallel()
+
+	got := systemdRunArgs(vsockexec.ExecRequest{
+		Command:        []string{"sh"},
+		TTY:            true,
+		KeepBackground: true,
+		Limits: &vsockexec.ResourceLimits{
+			Nice:           5,
+			IOClass:        "best-effort",
+			IOPriority:     6,
+			CPUWeight:      50,
+			MemoryMaxBytes: 1 << 30,
+		},
+	}, "unit", "", nil)
+	want := []string{
+		"systemd-run", "--quiet", "--collect", "--wait", "--service-type=exec", "--unit=unit",
+		"--pty",
+		"--property=Nice=5",
+		"--property=IOSchedulingClass=best-effort",
+		"--property=IOSchedulingPriority=6",
+		"--property=CPUWeight=50",
+		"--property=MemoryMax=1073741824",
+		"--property=KillMode=process",
+		"--", "sh",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected systemd-run args:\ngot  %q\nwant %q", got, want)
+	}
+}
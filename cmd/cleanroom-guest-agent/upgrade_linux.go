@@ -0,0 +1,106 @@
+//go:build linux
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+// handleAgentUpgrade installs the agent binary streamed as input frames over
+// the running executable. On success it stops accepting connections before
+// reporting the exit, so the host's next dial waits for the restarted agent
+// rather than reaching this one, and returns the path to re-execute.
+func handleAgentUpgrade(conn io.Writer, dec *vsockexec.FrameDecoder, upgrade vsockexec.AgentUpgrade, stopAccepting func()) string {
+	target, err := os.Executable()
+	if err != nil {
+		sendErrorResponse(conn, fmt.Errorf("locate guest agent: %w", err))
+		return ""
+	}
+
+	pr, pw := io.Pipe()
+	go readInputFrames(dec, pw, func() { _ = pw.Close() }, nil)
+	err = installAgent(pr, target, upgrade)
+	// Unblock the frame reader if the install stopped before eof.
+	_ = pr.CloseWithError(errors.New("agent upgrade finished"))
+	if err != nil {
+		sendErrorResponse(conn, fmt.Errorf("upgrade guest agent: %w", err))
+		return ""
+	}
+
+	if stopAccepting != nil {
+		stopAccepting()
+	}
+	_ = newFrameSender(conn, "").Send(vsockexec.ExecStreamFrame{Type: "exit"})
+	return target
+}
+
+// installAgent writes r next to target, checks its size and SHA-256 against
+// upgrade, and renames it over target. A binary that does not match is
+// removed and target is left alone.
+func installAgent(r io.Reader, target string, upgrade vsockexec.AgentUpgrade) error {
+	want := strings.ToLower(strings.TrimSpace(upgrade.SHA256))
+	if want == "" {
+		return errors.New("missing sha256")
+	}
+	if upgrade.Size <= 0 {
+		return errors.New("missing size")
+	}
+
+	staged := filepath.Join(filepath.Dir(target), "."+filepath.Base(target)+".new")
+	f, err := os.OpenFile(staged, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+	if err != nil {
+		return err
+	}
+	installed := false
+	defer func() {
+		if !installed {
+			_ = os.Remove(staged)
+		}
+	}()
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, hash), io.LimitReader(r, upgrade.Size+1))
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	if n != upgrade.Size {
+		_ = f.Close()
+		return fmt.Errorf("received %d bytes, want %d", n, upgrade.Size)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		_ = f.Close()
+		return fmt.Errorf("sha256 mismatch: got %s, want %s", got, want)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(staged, target); err != nil {
+		return err
+	}
+	installed = true
+	return nil
+}
+
+// restartAgent replaces the process with the upgraded agent at path. The PID
+// stays the same, so whatever supervises the agent does not notice. If the
+// exec fails the agent exits and its supervisor starts the new binary.
+func restartAgent(path string) {
+	err := syscall.Exec(path, append([]string{path}, os.Args[1:]...), os.Environ())
+	fmt.Fprintf(os.Stderr, "restart upgraded guest agent: %v\n", err)
+	os.Exit(1)
+}
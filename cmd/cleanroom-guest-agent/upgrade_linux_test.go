@@ -0,0 +1,79 @@
+//go:build linux
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+func agentUpgradeFor(data string) vsockexec.AgentUpgrade {
+	sum := sha256.Sum256([]byte(data))
+	return vsockexec.AgentUpgrade{SHA256: hex.EncodeToString(sum[:]), Size: int64(len(data))}
+}
+
+func TestInstallAgentReplacesTarget(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	target := filepath.Join(dir, "cleanroom-guest-agent")
+	if err := os.WriteFile(target, []byte("old agent"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := installAgent(strings.NewReader("new agent"), target, agentUpgradeFor("new agent")); err != nil {
+		t.Fatalf("installAgent returned error: %v", err)
+	}
+	got, err := os.ReadFile(target)
+	if err != nil || string(got) != "new agent" {
+		t.Fatalf("target = %q, %v", got, err)
+	}
+	info, err := os.Stat(target)
+	if err != nil || info.Mode().Perm() != 0o755 {
+		t.Fatalf("unexpected target mode %v, %v", info.Mode(), err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("expected the staged binary to be renamed away, got %v", entries)
+	}
+}
+
+func TestInstallAgentRejectsMismatchedBinary(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		data    string
+		upgrade vsockexec.AgentUpgrade
+		wantErr string
+	}{
+		"hash":      {data: "bad agent", upgrade: agentUpgradeFor("new agent"), wantErr: "sha256 mismatch"},
+		"truncated": {data: "new", upgrade: agentUpgradeFor("new agent"), wantErr: "received 3 bytes, want 9"},
+		"oversized": {data: "new agent and more", upgrade: agentUpgradeFor("new agent"), wantErr: "want 9"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			target := filepath.Join(dir, "cleanroom-guest-agent")
+			if err := os.WriteFile(target, []byte("old agent"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			err := installAgent(strings.NewReader(tc.data), target, tc.upgrade)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected %q error, got %v", tc.wantErr, err)
+			}
+			if got, _ := os.ReadFile(target); string(got) != "old agent" {
+				t.Fatalf("target replaced despite the failed install: %q", got)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+				t.Fatalf("expected the staged binary to be removed, got %v", entries)
+			}
+		})
+	}
+}
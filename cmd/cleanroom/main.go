@@ -1,17 +1,9 @@
 package main
 
-import (
-	"fmt"
-	"os"
-
-	"github.com/buildkite/cleanroom/internal/cli"
-)
+import "github.com/buildkite/cleanroom/cleanroomcli"
 
 var version = "dev"
 
 func main() {
-	if err := cli.Run(os.Args[1:], version); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(cli.ExitCode(err))
-	}
+	cleanroomcli.Main(version)
 }
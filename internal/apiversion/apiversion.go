@@ -0,0 +1,44 @@
+// Package apiversion records which control API schema this build speaks, so
+// a client and server from different releases can tell whether they work
+// together before a request fails in a confusing way.
+package apiversion
+
+import (
+	"fmt"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+const (
+	// Schema is bumped for incompatible changes to the control API, such as
+	// a field changing meaning or an RPC being removed. Additive changes do
+	// not bump it.
+	Schema = 1
+	// MinClientSchema is the oldest client schema this server works with.
+	MinClientSchema = 1
+	// MinServerSchema is the oldest server schema this client works with.
+	MinServerSchema = 1
+)
+
+// Info describes this build for GetServerInfo.
+func Info(version string) *cleanroomv1.GetServerInfoResponse {
+	return &cleanroomv1.GetServerInfoResponse{
+		Version:                version,
+		SchemaVersion:          Schema,
+		MinClientSchemaVersion: MinClientSchema,
+	}
+}
+
+// Check returns why a client of this build cannot use the server described
+// by info, or nil if it can. clientVersion is only used in the message.
+func Check(info *cleanroomv1.GetServerInfoResponse, clientVersion string) error {
+	switch {
+	case info.GetMinClientSchemaVersion() > Schema:
+		return fmt.Errorf("this client (%s, API schema %d) is too old for the server (%s), which needs API schema %d or newer: upgrade the client",
+			clientVersion, Schema, info.GetVersion(), info.GetMinClientSchemaVersion())
+	case info.GetSchemaVersion() < MinServerSchema:
+		return fmt.Errorf("the server (%s, API schema %d) is too old for this client (%s), which needs API schema %d or newer: upgrade the server",
+			info.GetVersion(), info.GetSchemaVersion(), clientVersion, MinServerSchema)
+	}
+	return nil
+}
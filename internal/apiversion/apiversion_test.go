@@ -0,0 +1,30 @@
+package apiversion
+
+import (
+	"strings"
+	"testing"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+func TestCheckAcceptsSameBuild(t *testing.T) {
+	if err := Check(Info("v1.0.0"), "v1.0.0"); err != nil {
+		t.Fatalf("Check rejected its own build: %v", err)
+	}
+}
+
+func TestCheckRejectsClientOlderThanServerMinimum(t *testing.T) {
+	info := &cleanroomv1.GetServerInfoResponse{Version: "v9.0.0", SchemaVersion: Schema + 1, MinClientSchemaVersion: Schema + 1}
+	err := Check(info, "v1.0.0")
+	if err == nil || !strings.Contains(err.Error(), "upgrade the client") {
+		t.Fatalf("expected the client to be refused, got %v", err)
+	}
+}
+
+func TestCheckRejectsServerOlderThanClientMinimum(t *testing.T) {
+	info := &cleanroomv1.GetServerInfoResponse{Version: "v0.1.0", SchemaVersion: MinServerSchema - 1}
+	err := Check(info, "v1.0.0")
+	if err == nil || !strings.Contains(err.Error(), "upgrade the server") {
+		t.Fatalf("expected the server to be refused, got %v", err)
+	}
+}
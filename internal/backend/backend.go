@@ -2,30 +2,78 @@ package backend
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"maps"
 	"sort"
+	"time"
 
+	"github.com/buildkite/cleanroom/internal/bootassets"
 	"github.com/buildkite/cleanroom/internal/policy"
+	"github.com/buildkite/cleanroom/internal/vsockexec"
 )
 
 const (
 	CapabilityExecStreaming          = "exec.streaming"
 	CapabilitySandboxPersistent      = "sandbox.persistent"
 	CapabilitySandboxFileDownload    = "sandbox.file_download"
+	CapabilitySandboxCommit          = "sandbox.commit"
 	CapabilityNetworkDefaultDeny     = "network.default_deny"
 	CapabilityNetworkAllowlistEgress = "network.allowlist_egress"
 	CapabilityNetworkGuestInterface  = "network.guest_interface"
+	CapabilityDeviceVFIO             = "device.vfio"
+	CapabilityNestedVirtualization   = "sandbox.nested_virtualization"
+	CapabilityDockerPreload          = "services.docker_preload"
+	CapabilityReadOnlyRootFS         = "sandbox.read_only_rootfs"
+	CapabilityExecCaptureChanges     = "exec.capture_changes"
+	CapabilitySandboxSetup           = "sandbox.setup"
+	CapabilitySandboxAgentUpgrade    = "sandbox.agent_upgrade"
+	CapabilitySandboxPause           = "sandbox.pause"
+	CapabilityNetworkPinnedDNS       = "network.pinned_dns"
+	CapabilityNetworkSandboxGroups   = "network.sandbox_groups"
+	CapabilityObservabilityVMStats   = "observability.vm_stats"
+	CapabilitySandboxFileDelta       = "sandbox.file_delta"
 )
 
 var knownCapabilityKeys = []string{
 	CapabilityExecStreaming,
 	CapabilitySandboxPersistent,
 	CapabilitySandboxFileDownload,
+	CapabilitySandboxCommit,
 	CapabilityNetworkDefaultDeny,
 	CapabilityNetworkAllowlistEgress,
 	CapabilityNetworkGuestInterface,
+	CapabilityDeviceVFIO,
+	CapabilityNestedVirtualization,
+	CapabilityDockerPreload,
+	CapabilityReadOnlyRootFS,
+	CapabilityExecCaptureChanges,
+	CapabilitySandboxSetup,
+	CapabilitySandboxAgentUpgrade,
+	CapabilitySandboxPause,
+	CapabilityNetworkPinnedDNS,
+	CapabilityNetworkSandboxGroups,
+	CapabilityObservabilityVMStats,
+	CapabilitySandboxFileDelta,
 }
 
+// Guest execution launchers. ExecLauncherAuto uses systemd when the guest
+// image booted it and falls back to starting the command directly otherwise.
+const (
+	ExecLauncherAuto    = ""
+	ExecLauncherDirect  = "direct"
+	ExecLauncherSystemd = "systemd"
+)
+
+// Filesystem change capture modes. ChangeCaptureManifest lists the paths an
+// execution created, modified or deleted; ChangeCaptureArchive also packs
+// the new contents into a tarball. Both are published as artifacts.
+const (
+	ChangeCaptureNone     = ""
+	ChangeCaptureManifest = "manifest"
+	ChangeCaptureArchive  = "archive"
+)
+
 type Adapter interface {
 	Name() string
 	Run(ctx context.Context, req RunRequest) (*RunResult, error)
@@ -43,6 +91,13 @@ type CapabilityReporter interface {
 // - StreamingAdapter => exec.streaming
 // - PersistentSandboxAdapter => sandbox.persistent
 // - SandboxFileDownloadAdapter => sandbox.file_download
+// - SandboxCommitAdapter => sandbox.commit
+// - SandboxAgentUpgradeAdapter => sandbox.agent_upgrade
+// - SandboxPauseAdapter => sandbox.pause
+// - SandboxResolutionAdapter => network.pinned_dns
+// - SandboxGroupAdapter => network.sandbox_groups
+// - VMStatsAdapter => observability.vm_stats
+// - SandboxFileDeltaAdapter => sandbox.file_delta
 //
 // Additional backend-specific capabilities can be provided by implementing
 // CapabilityReporter.
@@ -64,6 +119,27 @@ func CapabilitiesForAdapter(adapter Adapter) map[string]bool {
 	if _, ok := adapter.(SandboxFileDownloadAdapter); ok {
 		caps[CapabilitySandboxFileDownload] = true
 	}
+	if _, ok := adapter.(SandboxCommitAdapter); ok {
+		caps[CapabilitySandboxCommit] = true
+	}
+	if _, ok := adapter.(SandboxAgentUpgradeAdapter); ok {
+		caps[CapabilitySandboxAgentUpgrade] = true
+	}
+	if _, ok := adapter.(SandboxPauseAdapter); ok {
+		caps[CapabilitySandboxPause] = true
+	}
+	if _, ok := adapter.(SandboxResolutionAdapter); ok {
+		caps[CapabilityNetworkPinnedDNS] = true
+	}
+	if _, ok := adapter.(SandboxGroupAdapter); ok {
+		caps[CapabilityNetworkSandboxGroups] = true
+	}
+	if _, ok := adapter.(VMStatsAdapter); ok {
+		caps[CapabilityObservabilityVMStats] = true
+	}
+	if _, ok := adapter.(SandboxFileDeltaAdapter); ok {
+		caps[CapabilitySandboxFileDelta] = true
+	}
 
 	if reporter, ok := adapter.(CapabilityReporter); ok {
 		for key, value := range reporter.Capabilities() {
@@ -105,14 +181,180 @@ type SandboxFileDownloadAdapter interface {
 	DownloadSandboxFile(ctx context.Context, sandboxID, path string, maxBytes int64) ([]byte, error)
 }
 
+// SandboxFileDeltaAdapter can copy a file out of a persistent sandbox as a
+// blockdelta delta against a base the caller signed with blockSize. It
+// returns ErrFileDeltaUnsupported when the sandbox's guest agent predates
+// deltas.
+type SandboxFileDeltaAdapter interface {
+	DownloadSandboxFileDelta(ctx context.Context, sandboxID, path string, maxBytes int64, blockSize int, signatures []byte) ([]byte, error)
+}
+
+// ErrFileDeltaUnsupported is returned by SandboxFileDeltaAdapter when the
+// sandbox cannot produce a delta and the file must be downloaded in full.
+var ErrFileDeltaUnsupported = errors.New("guest agent does not support file deltas")
+
+// SandboxCommitAdapter can publish a persistent sandbox's current root
+// filesystem as a new OCI image tagged ref. It returns the pushed reference
+// pinned to its digest.
+type SandboxCommitAdapter interface {
+	CommitSandbox(ctx context.Context, sandboxID, ref string) (string, error)
+}
+
+// SandboxResolutionAdapter records the addresses each policy allow host
+// resolved to when a sandbox's egress rules were built, and uses
+// ProvisionRequest.PinnedResolutions in place of DNS.
+type SandboxResolutionAdapter interface {
+	SandboxResolutions(sandboxID string) ([]HostResolution, error)
+}
+
+// SandboxGroupAdapter links running persistent sandboxes so their guests
+// can connect to each other on the ports each member declares. Links are
+// removed when any member they involve is terminated.
+type SandboxGroupAdapter interface {
+	// LinkSandboxGroup returns the address each member's guest is reached
+	// on by the others, keyed by sandbox ID.
+	LinkSandboxGroup(ctx context.Context, req SandboxGroupRequest) (map[string]string, error)
+}
+
+type SandboxGroupRequest struct {
+	GroupID string
+	Members []SandboxGroupMember
+	FirecrackerConfig
+}
+
+// SandboxGroupMember is a sandbox in a group and the ports its guest
+// accepts tcp and udp connections on from the other members.
+type SandboxGroupMember struct {
+	SandboxID string
+	Ports     []int
+}
+
+// SandboxEnvironmentAdapter describes what a persistent sandbox was booted
+// with, for the environment event at the start of each execution.
+type SandboxEnvironmentAdapter interface {
+	SandboxEnvironment(sandboxID string) (SandboxEnvironment, error)
+}
+
+// SandboxEnvironment is what a sandbox's commands run on. Fields the
+// backend could not determine are left empty.
+type SandboxEnvironment struct {
+	// GuestAgentSHA256 is the SHA-256 of the guest agent the sandbox runs.
+	GuestAgentSHA256 string
+	KernelVersion    string
+	VCPUs            int64
+	MemoryMiB        int64
+	DiskMiB          int64
+}
+
+// SandboxAgentUpgradeAdapter can replace the guest agent of a running
+// persistent sandbox with the host's current one, restarting the agent in
+// place without rebooting the VM.
+type SandboxAgentUpgradeAdapter interface {
+	// SandboxAgentHash returns the SHA-256 of the guest agent the sandbox
+	// runs.
+	SandboxAgentHash(sandboxID string) (string, error)
+	// UpgradeSandboxAgent installs the host's guest agent in the sandbox and
+	// returns its SHA-256. A sandbox that already runs it is left alone.
+	UpgradeSandboxAgent(ctx context.Context, sandboxID string) (string, error)
+}
+
+// SandboxPauseAdapter can stop a persistent sandbox's vCPUs while keeping
+// its memory, devices and network, and later resume it where it left off.
+type SandboxPauseAdapter interface {
+	PauseSandbox(ctx context.Context, sandboxID string) error
+	ResumeSandbox(ctx context.Context, sandboxID string) error
+}
+
+// VMStatsAdapter reports counters the VMM keeps for each running sandbox,
+// keyed by sandbox ID.
+type VMStatsAdapter interface {
+	SandboxVMStats(ctx context.Context) map[string]VMStats
+}
+
+// VMStats are cumulative device and vCPU counters for one VM.
+type VMStats struct {
+	NetRxBytes      int64 `json:"net_rx_bytes"`
+	NetTxBytes      int64 `json:"net_tx_bytes"`
+	NetRxPackets    int64 `json:"net_rx_packets"`
+	NetTxPackets    int64 `json:"net_tx_packets"`
+	BlockReadBytes  int64 `json:"block_read_bytes"`
+	BlockWriteBytes int64 `json:"block_write_bytes"`
+	BlockReads      int64 `json:"block_reads"`
+	BlockWrites     int64 `json:"block_writes"`
+	VCPUExits       int64 `json:"vcpu_exits"`
+}
+
+// Add returns the field-wise sum of s and o.
+func (s VMStats) Add(o VMStats) VMStats {
+	return VMStats{
+		NetRxBytes:      s.NetRxBytes + o.NetRxBytes,
+		NetTxBytes:      s.NetTxBytes + o.NetTxBytes,
+		NetRxPackets:    s.NetRxPackets + o.NetRxPackets,
+		NetTxPackets:    s.NetTxPackets + o.NetTxPackets,
+		BlockReadBytes:  s.BlockReadBytes + o.BlockReadBytes,
+		BlockWriteBytes: s.BlockWriteBytes + o.BlockWriteBytes,
+		BlockReads:      s.BlockReads + o.BlockReads,
+		BlockWrites:     s.BlockWrites + o.BlockWrites,
+		VCPUExits:       s.VCPUExits + o.VCPUExits,
+	}
+}
+
+// Sub returns the field-wise difference s - o, such as the counters
+// accumulated between two readings.
+func (s VMStats) Sub(o VMStats) VMStats {
+	return VMStats{
+		NetRxBytes:      s.NetRxBytes - o.NetRxBytes,
+		NetTxBytes:      s.NetTxBytes - o.NetTxBytes,
+		NetRxPackets:    s.NetRxPackets - o.NetRxPackets,
+		NetTxPackets:    s.NetTxPackets - o.NetTxPackets,
+		BlockReadBytes:  s.BlockReadBytes - o.BlockReadBytes,
+		BlockWriteBytes: s.BlockWriteBytes - o.BlockWriteBytes,
+		BlockReads:      s.BlockReads - o.BlockReads,
+		BlockWrites:     s.BlockWrites - o.BlockWrites,
+		VCPUExits:       s.VCPUExits - o.VCPUExits,
+	}
+}
+
+// ArtifactManifestPath is where a command running in a sandbox lists the
+// artifacts it produced, as {"artifacts": [{"path": "/abs/file"}, ...]}.
+const ArtifactManifestPath = "/cleanroom/artifacts.json"
+
+// ArtifactManifestAdapter can read the artifact manifest a command left in a
+// persistent sandbox. Reading removes it, so the next execution starts clean.
+// A sandbox without a manifest yields nil data and no error.
+type ArtifactManifestAdapter interface {
+	TakeArtifactManifest(ctx context.Context, sandboxID string, maxBytes int64) ([]byte, error)
+}
+
+// HostResourceReporter can count the host-side resources (tap devices, VMM
+// processes, ...) the backend currently holds, keyed by resource name. Soak
+// tests compare the counts before and after a run to detect leaks.
+type HostResourceReporter interface {
+	HostResources(ctx context.Context) (map[string]int, error)
+}
+
 type ProvisionRequest struct {
 	SandboxID string
+	// Namespace is the sandbox's namespace. Images built by sandbox.setup
+	// are only reused within it.
+	Namespace string
 	Policy    *policy.CompiledPolicy
+	// PinnedResolutions replaces DNS for the listed allow hosts, so a
+	// sandbox can reuse the addresses another one was built with.
+	PinnedResolutions []HostResolution
 	FirecrackerConfig
 }
 
+// HostResolution is the set of IPv4 addresses a policy allow host resolved
+// to.
+type HostResolution struct {
+	Host      string   `json:"host"`
+	Addresses []string `json:"addresses"`
+}
+
 type AttachIO struct {
 	WriteStdin func([]byte) error
+	CloseStdin func() error
 	ResizeTTY  func(cols, rows uint32) error
 }
 
@@ -132,24 +374,112 @@ type StreamingAdapter interface {
 type RunRequest struct {
 	SandboxID string
 	RunID     string
+	// Namespace is the sandbox's namespace; see ProvisionRequest.
+	Namespace string
 	Command   []string
 	TTY       bool
 	Policy    *policy.CompiledPolicy
+	Limits    ResourceLimits
+	// KeepBackgroundProcesses leaves processes started by the command running
+	// after it exits instead of killing the command's whole process tree.
+	KeepBackgroundProcesses bool
+	// FreshHome gives the command a new tmpfs HOME and default working
+	// directory that is discarded after it exits.
+	FreshHome bool
+	// Launcher selects how the guest starts the command: ExecLauncherAuto,
+	// ExecLauncherDirect, or ExecLauncherSystemd.
+	Launcher string
+	// Stdin keeps a non-TTY command's stdin open until AttachIO.CloseStdin is
+	// called instead of closing it as soon as the command starts.
+	Stdin bool
+	// CaptureChanges is one of the ChangeCapture modes.
+	CaptureChanges string
 	FirecrackerConfig
 }
 
+// ConsoleBanner returns the policy's banner for an interactive (TTY) run,
+// or "" for other runs.
+func (r RunRequest) ConsoleBanner() string {
+	if !r.TTY {
+		return ""
+	}
+	return r.Policy.RenderConsoleBanner()
+}
+
+// ConsoleLimits returns the policy's session limits for an interactive (TTY)
+// run, or nil for other runs and policies without limits.
+func (r RunRequest) ConsoleLimits() *vsockexec.SessionLimits {
+	if !r.TTY || r.Policy == nil || (r.Policy.ConsoleMaxSessionSeconds == 0 && r.Policy.ConsoleIdleTimeoutSeconds == 0) {
+		return nil
+	}
+	return &vsockexec.SessionLimits{
+		MaxSeconds:  r.Policy.ConsoleMaxSessionSeconds,
+		IdleSeconds: r.Policy.ConsoleIdleTimeoutSeconds,
+	}
+}
+
+// ResourceLimits constrains a single execution inside the guest so a heavy
+// command cannot starve the guest agent or long-lived guest services. Zero
+// values leave the corresponding limit unset.
+type ResourceLimits struct {
+	Nice           int
+	IOClass        string // realtime|best-effort|idle
+	IOPriority     int
+	CPUWeight      int64
+	MemoryMaxBytes int64
+}
+
+// Guest converts the limits into the guest protocol form, returning nil
+// when no limits are set so older guest agents see an unchanged request.
+func (l ResourceLimits) Guest() *vsockexec.ResourceLimits {
+	out := &vsockexec.ResourceLimits{
+		Nice:           l.Nice,
+		IOClass:        l.IOClass,
+		IOPriority:     l.IOPriority,
+		CPUWeight:      l.CPUWeight,
+		MemoryMaxBytes: l.MemoryMaxBytes,
+	}
+	if out.IsZero() {
+		return nil
+	}
+	return out
+}
+
+// Defaults backends apply to unset VM settings in FirecrackerConfig.
+const (
+	DefaultVCPUs                int64 = 1
+	DefaultMemoryMiB            int64 = 512
+	DefaultLaunchSeconds        int64 = 30
+	DefaultDockerStartupSeconds int64 = 20
+	DefaultDockerStorageDriver        = "vfs"
+)
+
 type FirecrackerConfig struct {
 	BinaryPath           string
 	KernelImagePath      string
+	KernelSource         bootassets.Source // used when KernelImagePath is empty
 	RootFSPath           string
+	RootFSSource         bootassets.Source // used when RootFSPath is empty
 	DockerStartupSeconds int64
 	DockerStorageDriver  string
 	DockerIPTables       bool
 	PrivilegedMode       string
 	PrivilegedHelperPath string
 	RunDir               string
+	SocketDir            string // binds VM sockets here, linked from the run dir; empty binds them in the run dir
 	VCPUs                int64
 	MemoryMiB            int64
+	DiskMiB              int64  // grow the rootfs to at least this size; 0 keeps the image size
+	NetworkPool          string // IPv4 CIDR split into per-sandbox /24s; empty uses the backend default
+	MTU                  int    // TAP and guest interface MTU; 0 keeps 1500
+	EgressMbps           int64  // guest transmit bandwidth limit; 0 is unlimited
+	IngressMbps          int64  // guest receive bandwidth limit; 0 is unlimited
+	DiskIOPS             int64  // per-drive operations per second limit; 0 is unlimited
+	DiskMiBps            int64  // per-drive MiB per second limit; 0 is unlimited
+	NetworkNamespaces    bool   // put each sandbox's TAP and rules in its own network namespace
+	ScratchFiles         int64  // per-run rootfs files kept for reuse by later runs; 0 removes them
+	CPUTemplate          string
+	SMT                  bool
 	GuestCID             uint32
 	GuestPort            uint32
 	Launch               bool
@@ -167,10 +497,85 @@ type RunResult struct {
 	Message     string
 	Stdout      string
 	Stderr      string
+	// ExitMetadata is nil when the backend could not observe the command's
+	// resource usage, for example with an older guest agent.
+	ExitMetadata *ExitMetadata
+	// Failure is set when the guest kernel, rather than the command, ended
+	// the run, for example by OOM-killing the command.
+	Failure *GuestFailure
+	// Timings is nil when the backend does not break down run phases.
+	Timings *RunTimings
+}
+
+// RunTimings breaks down where a run's wall time went. Phases the run did
+// not go through, such as VM boot inside an existing sandbox, are zero.
+type RunTimings struct {
+	PolicyResolve time.Duration
+	RootFSCopy    time.Duration
+	VMStart       time.Duration
+	NetworkSetup  time.Duration
+	VMReady       time.Duration
+	VsockWait     time.Duration
+	GuestExec     time.Duration
+	Total         time.Duration
+	ImageCacheHit bool
+}
+
+// GuestFailureReason classifies a failure the guest kernel reported.
+type GuestFailureReason string
+
+const (
+	GuestFailureOOM         GuestFailureReason = "oom"
+	GuestFailureKernelPanic GuestFailureReason = "kernel_panic"
+)
+
+// GuestFailure is returned, or attached to a RunResult, when the guest
+// console shows the kernel panicked or OOM-killed the workload. It replaces
+// the transport error the failure caused, which is kept for errors.Is.
+type GuestFailure struct {
+	Reason GuestFailureReason
+	// Excerpt is the console line that identified the failure.
+	Excerpt   string
+	MemoryMiB int64
+	Err       error
+}
+
+func (e *GuestFailure) Error() string {
+	var msg string
+	switch e.Reason {
+	case GuestFailureOOM:
+		msg = "guest OOM-killed"
+		if e.MemoryMiB > 0 {
+			msg += fmt.Sprintf(" (memory_mib=%d)", e.MemoryMiB)
+		}
+		msg += "; consider raising sandbox.resources.memory_mib"
+	case GuestFailureKernelPanic:
+		msg = "guest kernel panic"
+	default:
+		msg = "guest failure"
+	}
+	if e.Excerpt != "" {
+		msg += ": " + e.Excerpt
+	}
+	return msg
+}
+
+func (e *GuestFailure) Unwrap() error { return e.Err }
+
+// ExitMetadata describes how a command ended and the resources it used.
+type ExitMetadata struct {
+	UserCPU     time.Duration
+	SystemCPU   time.Duration
+	Wall        time.Duration
+	MaxRSSBytes int64
+	Signal      int    // signal that terminated the command, 0 if it exited
+	SignalName  string // for example SIGKILL
 }
 
 type DoctorRequest struct {
 	Policy *policy.CompiledPolicy
+	// Deep asks the backend to go beyond static checks and boot a canary VM.
+	Deep bool
 	FirecrackerConfig
 }
 
@@ -180,7 +585,15 @@ type DoctorReport struct {
 }
 
 type DoctorCheck struct {
+	// ID is a stable dotted identifier such as "firecracker.kvm" for scripts
+	// to match on. Messages are for humans and may change between releases.
+	ID      string `json:"id"`
 	Name    string `json:"name"`
 	Status  string `json:"status"` // pass|warn|fail
 	Message string `json:"message"`
+	// Remediation suggests how to fix a check that did not pass.
+	Remediation string `json:"remediation,omitempty"`
+	// DurationsMS holds measured timings for checks that exercise the
+	// backend, keyed by phase.
+	DurationsMS map[string]int64 `json:"durations_ms,omitempty"`
 }
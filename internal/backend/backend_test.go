@@ -0,0 +1,14 @@
+package backend
+
+import "testing"
+
+func TestResourceLimitsGuestOmitsUnsetLimits(t *testing.T) {
+	t.Parallel()
+
+	if got := (ResourceLimits{}).Guest(); got != nil {
+		t.Fatalf("expected nil guest limits, got %+v", got)
+	}
+	if got := (ResourceLimits{CPUWeight: 50}).Guest(); got == nil || got.CPUWeight != 50 {
+		t.Fatalf("expected guest limits with cpu weight 50, got %+v", got)
+	}
+}
@@ -26,7 +26,9 @@ import (
 	"github.com/buildkite/cleanroom/internal/gateway"
 	"github.com/buildkite/cleanroom/internal/hosttools"
 	"github.com/buildkite/cleanroom/internal/imagemgr"
+	"github.com/buildkite/cleanroom/internal/logging"
 	"github.com/buildkite/cleanroom/internal/paths"
+	"github.com/buildkite/cleanroom/internal/rundir"
 	"github.com/buildkite/cleanroom/internal/vsockexec"
 	"github.com/charmbracelet/log"
 )
@@ -53,12 +55,26 @@ type Adapter struct {
 	GatewayRegistry gatewayRegistry
 	GatewayPort     int
 	GatewayHost     string
+
+	// ImagePeers, if set, is asked for images missing from the local cache
+	// before the registry. Set it before the first launch.
+	ImagePeers imagemgr.PeerSource
+
+	// Logger receives the adapter's notices; nil uses the default logger.
+	Logger *log.Logger
 }
 
 type imageEnsurer interface {
 	Ensure(context.Context, string) (imagemgr.EnsureResult, error)
 }
 
+// preparedRecorder is implemented by image managers that track the prepared
+// rootfs derived from their images, so they can be removed and exported
+// along with them.
+type preparedRecorder interface {
+	RecordPrepared(ctx context.Context, digest, path string) error
+}
+
 type imageManagerFactory func() (imageEnsurer, error)
 
 type preparedRootFS struct {
@@ -87,6 +103,8 @@ mkdir -p /dev/pts /run /tmp
 mount -t devpts devpts /dev/pts 2>/dev/null || true
 mount -t tmpfs tmpfs /run 2>/dev/null || true
 mount -t tmpfs tmpfs /tmp 2>/dev/null || true
+mkdir -p /sys/fs/cgroup
+mount -t cgroup2 none /sys/fs/cgroup 2>/dev/null || true
 
 export HOME=/root
 export PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin:/root/.local/bin
@@ -156,8 +174,7 @@ if [ "$DOCKER_REQUIRED" = "1" ] && command -v dockerd >/dev/null 2>&1; then
     DOCKER_ARGS="$DOCKER_ARGS --iptables=false"
   fi
 
-  mkdir -p /var/log /var/lib/docker /etc/docker /var/run /sys/fs/cgroup
-  mount -t cgroup2 none /sys/fs/cgroup 2>/dev/null || true
+  mkdir -p /var/log /var/lib/docker /etc/docker /var/run
   if [ ! -S /var/run/docker.sock ]; then
     dockerd $DOCKER_ARGS >/var/log/dockerd.log 2>&1 &
   fi
@@ -202,13 +219,13 @@ done
 `
 
 func New() *Adapter {
-	return &Adapter{
-		newImageManager: defaultImageManagerFactory,
-	}
+	a := &Adapter{}
+	a.newImageManager = a.defaultImageManager
+	return a
 }
 
-func defaultImageManagerFactory() (imageEnsurer, error) {
-	return imagemgr.New(imagemgr.Options{})
+func (a *Adapter) defaultImageManager() (imageEnsurer, error) {
+	return imagemgr.New(imagemgr.Options{Peers: a.ImagePeers, Logger: a.Logger})
 }
 
 func (a *Adapter) Name() string {
@@ -234,7 +251,11 @@ func (a *Adapter) RunStream(ctx context.Context, req backend.RunRequest, stream
 func (a *Adapter) Doctor(_ context.Context, req backend.DoctorRequest) (*backend.DoctorReport, error) {
 	report := &backend.DoctorReport{Backend: a.Name()}
 	appendCheck := func(name, status, message string) {
-		report.Checks = append(report.Checks, backend.DoctorCheck{Name: name, Status: status, Message: message})
+		check := backend.DoctorCheck{Name: name, Status: status, Message: message}
+		if status != "pass" {
+			check.Remediation = doctorRemediation(name)
+		}
+		report.Checks = append(report.Checks, check)
 	}
 
 	if runtime.GOOS == "darwin" {
@@ -244,7 +265,13 @@ func (a *Adapter) Doctor(_ context.Context, req backend.DoctorRequest) (*backend
 	}
 	appendCheck("guest_networking", "warn", guestNetworkUnavailableWarning)
 
-	if configured := strings.TrimSpace(req.KernelImagePath); configured == "" {
+	if configured := strings.TrimSpace(req.KernelImagePath); configured == "" && !req.KernelSource.IsZero() {
+		if err := req.KernelSource.Validate(); err != nil {
+			appendCheck("kernel_image", "fail", fmt.Sprintf("kernel_source: %v", err))
+		} else {
+			appendCheck("kernel_image", "pass", fmt.Sprintf("kernel image will be fetched from %s and pinned to sha256 %s", req.KernelSource.Location(), req.KernelSource.SHA256))
+		}
+	} else if configured == "" {
 		if spec, ok := bootassets.LookupManagedKernelForHost(a.Name()); ok {
 			path, _ := bootassets.ManagedKernelPathForHost(a.Name())
 			appendCheck("kernel_image", "pass", fmt.Sprintf("kernel image will be auto-managed (%s -> %s)", spec.ID, path))
@@ -262,7 +289,13 @@ func (a *Adapter) Doctor(_ context.Context, req backend.DoctorRequest) (*backend
 		appendCheck("kernel_image", "pass", fmt.Sprintf("kernel image configured: %s", configured))
 	}
 
-	if strings.TrimSpace(req.RootFSPath) == "" {
+	if strings.TrimSpace(req.RootFSPath) == "" && !req.RootFSSource.IsZero() {
+		if err := req.RootFSSource.Validate(); err != nil {
+			appendCheck("rootfs", "fail", fmt.Sprintf("rootfs_source: %v", err))
+		} else {
+			appendCheck("rootfs", "pass", fmt.Sprintf("rootfs will be fetched from %s and pinned to sha256 %s", req.RootFSSource.Location(), req.RootFSSource.SHA256))
+		}
+	} else if strings.TrimSpace(req.RootFSPath) == "" {
 		if req.Policy != nil && strings.TrimSpace(req.Policy.ImageRef) != "" {
 			appendCheck("rootfs", "pass", "rootfs will be derived from sandbox.image.ref")
 		} else {
@@ -299,7 +332,7 @@ func (a *Adapter) Doctor(_ context.Context, req backend.DoctorRequest) (*backend
 		}
 	}
 
-	requiresDerivedRootFS := true
+	requiresDerivedRootFS := req.RootFSSource.IsZero()
 	if configuredRootFS := strings.TrimSpace(req.RootFSPath); configuredRootFS != "" {
 		if _, err := os.Stat(configuredRootFS); err == nil {
 			requiresDerivedRootFS = false
@@ -363,6 +396,9 @@ func (a *Adapter) Doctor(_ context.Context, req backend.DoctorRequest) (*backend
 			)
 		}
 	}
+	if req.Deep {
+		appendCheck("canary_boot", "warn", "deep canary boot is not implemented for darwin-vz yet")
+	}
 	return report, nil
 }
 
@@ -389,24 +425,24 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 		}
 		runDir = filepath.Join(baseDir, req.RunID)
 	}
-	if err := os.MkdirAll(runDir, 0o755); err != nil {
-		return nil, fmt.Errorf("create run directory: %w", err)
+	if err := rundir.Create(runDir, rundir.Manifest{RunID: req.RunID, Backend: a.Name(), SandboxID: req.SandboxID}); err != nil {
+		return nil, err
 	}
 
 	if req.VCPUs <= 0 {
-		req.VCPUs = 1
+		req.VCPUs = backend.DefaultVCPUs
 	}
 	if req.MemoryMiB <= 0 {
-		req.MemoryMiB = 512
+		req.MemoryMiB = backend.DefaultMemoryMiB
 	}
 	if req.GuestPort == 0 {
 		req.GuestPort = vsockexec.DefaultPort
 	}
 	if req.LaunchSeconds <= 0 {
-		req.LaunchSeconds = 30
+		req.LaunchSeconds = backend.DefaultLaunchSeconds
 	}
 
-	cmdPath := filepath.Join(runDir, "requested-command.json")
+	cmdPath := filepath.Join(runDir, rundir.RequestedCommandFile)
 	if err := writeJSON(cmdPath, req.Command); err != nil {
 		return nil, err
 	}
@@ -426,7 +462,7 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 	resolvedImageDigest := req.Policy.ImageDigest
 
 	if !req.Launch {
-		planPath := filepath.Join(runDir, "plan.json")
+		planPath := filepath.Join(runDir, rundir.PlanFile)
 		plan := map[string]any{
 			"backend":      a.Name(),
 			"mode":         "plan-only",
@@ -448,17 +484,17 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 		}, nil
 	}
 
-	kernelPath, kernelNotice, err := a.resolveKernelPath(ctx, req.KernelImagePath)
+	kernelPath, kernelNotice, err := a.resolveKernelPath(ctx, req.KernelImagePath, req.KernelSource)
 	if err != nil {
 		return nil, err
 	}
-	logRunNotice(a.Name(), req.RunID, kernelNotice)
+	a.logRunNotice(ctx, req.RunID, kernelNotice)
 
 	rootFSPath, imageRef, imageDigest, rootFSNotice, err := a.resolveRootFSPath(ctx, req)
 	if err != nil {
 		return nil, err
 	}
-	logRunNotice(a.Name(), req.RunID, rootFSNotice)
+	a.logRunNotice(ctx, req.RunID, rootFSNotice)
 	if strings.TrimSpace(imageRef) != "" {
 		resolvedImageRef = imageRef
 	}
@@ -483,7 +519,7 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 	}()
 
 	guestInitPath, guestInitNotice := guestInitExecutableForRootFS(vmRootFSPath)
-	logRunNotice(a.Name(), req.RunID, guestInitNotice)
+	a.logRunNotice(ctx, req.RunID, guestInitNotice)
 	bootArgs := fmt.Sprintf(
 		"console=hvc0 root=/dev/vda rw init=%s cleanroom_guest_port=%d %s",
 		guestInitPath,
@@ -506,7 +542,8 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 		return nil, err
 	}
 
-	helper, err := startHelperSession(ctx, runDir, req.LaunchSeconds)
+	defer rundir.RemoveSockets(runDir, req.SocketDir)
+	helper, err := startHelperSession(ctx, runDir, req.SocketDir, req.LaunchSeconds)
 	if err != nil {
 		return nil, fmt.Errorf("start darwin-vz helper: %w", err)
 	}
@@ -516,9 +553,9 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 		}
 	}()
 
-	proxySocketPath := filepath.Join(runDir, helperProxySocketName)
-	if err := ensureUnixSocketPathFits(proxySocketPath); err != nil {
-		return nil, fmt.Errorf("proxy socket path %q is too long: %w", proxySocketPath, err)
+	proxySocketPath, err := rundir.SocketPath(runDir, req.SocketDir, helperProxySocketName)
+	if err != nil {
+		return nil, fmt.Errorf("proxy socket: %w", err)
 	}
 	_ = os.Remove(proxySocketPath)
 
@@ -551,8 +588,8 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 	if strings.TrimSpace(proxySocketPath) == "" {
 		return nil, errors.New("darwin-vz helper returned empty proxy socket path")
 	}
-	if err := ensureUnixSocketPathFits(proxySocketPath); err != nil {
-		return nil, fmt.Errorf("proxy socket path %q is too long: %w", proxySocketPath, err)
+	if err := rundir.CheckSocketPath(proxySocketPath); err != nil {
+		return nil, fmt.Errorf("proxy socket: %w", err)
 	}
 	defer func() {
 		stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -600,7 +637,19 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 		defer a.GatewayRegistry.ReleaseScopeToken(gatewayScopeToken)
 	}
 
-	guestReq := vsockexec.ExecRequest{Command: append([]string(nil), req.Command...), TTY: req.TTY}
+	guestReq := vsockexec.ExecRequest{
+		Command:        append([]string(nil), req.Command...),
+		TTY:            req.TTY,
+		Banner:         req.ConsoleBanner(),
+		SessionLimits:  req.ConsoleLimits(),
+		Limits:         req.Limits.Guest(),
+		KeepBackground: req.KeepBackgroundProcesses,
+		FreshHome:      req.FreshHome,
+		Launcher:       req.Launcher,
+		Stdin:          req.Stdin,
+		RequestID:      logging.RequestID(ctx),
+		Compression:    vsockexec.CompressionZstd,
+	}
 	if a.GatewayRegistry != nil && gatewayScopeToken != "" {
 		gwPort := a.GatewayPort
 		if gwPort <= 0 {
@@ -624,14 +673,22 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 	if stream.OnAttach != nil {
 		stream.OnAttach(backend.AttachIO{
 			WriteStdin: func(data []byte) error {
-				return inputSender.Send(vsockexec.ExecInputFrame{Type: "stdin", Data: data})
+				for _, frame := range vsockexec.StdinFrames(data) {
+					if err := inputSender.Send(frame); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			CloseStdin: func() error {
+				return inputSender.Send(vsockexec.ExecInputFrame{Type: "eof"})
 			},
 			ResizeTTY: func(cols, rows uint32) error {
 				return inputSender.Send(vsockexec.ExecInputFrame{Type: "resize", Cols: cols, Rows: rows})
 			},
 		})
 	}
-	if !req.TTY {
+	if !req.TTY && !req.Stdin {
 		_ = inputSender.Send(vsockexec.ExecInputFrame{Type: "eof"})
 	}
 
@@ -649,19 +706,36 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 	message := darwinVZResultMessage(guestRes.Error)
 
 	return &backend.RunResult{
-		RunID:       req.RunID,
-		ExitCode:    guestRes.ExitCode,
-		LaunchedVM:  true,
-		PlanPath:    vmPlanPath,
-		RunDir:      runDir,
-		ImageRef:    resolvedImageRef,
-		ImageDigest: resolvedImageDigest,
-		Message:     message,
-		Stdout:      guestRes.Stdout,
-		Stderr:      stderrPrefix + guestRes.Stderr,
+		RunID:        req.RunID,
+		ExitCode:     guestRes.ExitCode,
+		LaunchedVM:   true,
+		PlanPath:     vmPlanPath,
+		RunDir:       runDir,
+		ImageRef:     resolvedImageRef,
+		ImageDigest:  resolvedImageDigest,
+		Message:      message,
+		Stdout:       guestRes.Stdout,
+		Stderr:       stderrPrefix + guestRes.Stderr,
+		ExitMetadata: guestExitMetadata(guestRes.Metadata),
 	}, nil
 }
 
+// guestExitMetadata converts the guest agent's exit metadata, which is nil
+// for agents that predate it.
+func guestExitMetadata(m *vsockexec.ExitMetadata) *backend.ExitMetadata {
+	if m == nil {
+		return nil
+	}
+	return &backend.ExitMetadata{
+		UserCPU:     time.Duration(m.UserCPUMillis) * time.Millisecond,
+		SystemCPU:   time.Duration(m.SystemCPUMillis) * time.Millisecond,
+		Wall:        time.Duration(m.WallMillis) * time.Millisecond,
+		MaxRSSBytes: m.MaxRSSBytes,
+		Signal:      m.Signal,
+		SignalName:  m.SignalName,
+	}
+}
+
 func darwinVZResultMessage(guestErr string) string {
 	guestErr = strings.TrimSpace(guestErr)
 	if guestErr == "" {
@@ -688,6 +762,14 @@ type imageArtifact struct {
 
 func (a *Adapter) resolveRootFSPath(ctx context.Context, req backend.RunRequest) (path, imageRef, imageDigest, notice string, err error) {
 	configuredPath := strings.TrimSpace(req.RootFSPath)
+	if configuredPath == "" && !req.RootFSSource.IsZero() {
+		ensured, err := bootassets.EnsureSourceForHost(ctx, "rootfs", req.RootFSSource)
+		if err != nil {
+			return "", "", "", "", err
+		}
+		notice = fmt.Sprintf("using rootfs from %s (%s)", ensured.Spec.URL, map[bool]string{true: "cache hit", false: "cache miss"}[ensured.CacheHit])
+		return ensured.Path, strings.TrimSpace(req.Policy.ImageRef), strings.TrimSpace(req.Policy.ImageDigest), notice, nil
+	}
 	if configuredPath != "" {
 		if _, statErr := os.Stat(configuredPath); statErr == nil {
 			return configuredPath, strings.TrimSpace(req.Policy.ImageRef), strings.TrimSpace(req.Policy.ImageDigest), "", nil
@@ -804,6 +886,11 @@ func (a *Adapter) ensurePreparedRuntimeRootFSFromImage(ctx context.Context, imag
 		}
 		return preparedRootFS{}, fmt.Errorf("store prepared runtime rootfs %q: %w", preparedPath, err)
 	}
+	if recorder, ok := a.imageManager.(preparedRecorder); ok {
+		// Tracking is best effort; an untracked file is still used, it is
+		// just left behind by image rm and image save.
+		_ = recorder.RecordPrepared(ctx, artifact.Digest, preparedPath)
+	}
 
 	return preparedRootFS{
 		Ref:    artifact.Ref,
@@ -877,7 +964,7 @@ func (a *Adapter) ensureImageArtifact(ctx context.Context, imageRef string) (ima
 
 func (a *Adapter) getImageManager() (imageEnsurer, error) {
 	if a.newImageManager == nil {
-		a.newImageManager = defaultImageManagerFactory
+		a.newImageManager = a.defaultImageManager
 	}
 	a.imageManagerOnce.Do(func() {
 		a.imageManager, a.imageManagerErr = a.newImageManager()
@@ -1150,8 +1237,8 @@ func debugFSCommandOutputError(output string) string {
 	return ""
 }
 
-func (a *Adapter) resolveKernelPath(ctx context.Context, configuredPath string) (path, notice string, err error) {
-	resolved, err := bootassets.ResolveKernelPathForHost(ctx, a.Name(), configuredPath)
+func (a *Adapter) resolveKernelPath(ctx context.Context, configuredPath string, source bootassets.Source) (path, notice string, err error) {
+	resolved, err := bootassets.ResolveKernelPathForHost(ctx, a.Name(), configuredPath, source)
 	if err != nil {
 		return "", "", err
 	}
@@ -1201,17 +1288,25 @@ func copyFile(src, dst string) error {
 	return out.Sync()
 }
 
-func logRunNotice(backendName, runID, notice string) {
+// logger returns the adapter's logger, or the default one, with the
+// request, sandbox and execution IDs attached to ctx.
+func (a *Adapter) logger(ctx context.Context) *log.Logger {
+	base := a.Logger
+	if base == nil {
+		base = log.Default()
+	}
+	return logging.Logger(ctx, base)
+}
+
+func (a *Adapter) logRunNotice(ctx context.Context, runID, notice string) {
 	msg := strings.TrimSpace(notice)
 	if msg == "" {
 		return
 	}
-	fields := []any{"backend", strings.TrimSpace(backendName)}
-	id := strings.TrimSpace(runID)
-	if id != "" {
-		fields = append(fields, "run_id", id)
+	if id := strings.TrimSpace(runID); id != "" {
+		ctx = logging.WithFields(ctx, "run_id", id)
 	}
-	log.Info(msg, fields...)
+	a.logger(ctx).Info(msg, "backend", a.Name())
 }
 
 func randomScopeToken() (string, error) {
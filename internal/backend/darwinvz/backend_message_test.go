@@ -4,9 +4,11 @@ package darwinvz
 
 import (
 	"bytes"
+	"context"
 	"strings"
 	"testing"
 
+	"github.com/buildkite/cleanroom/internal/logging"
 	"github.com/charmbracelet/log"
 )
 
@@ -51,19 +53,14 @@ func TestBuildRuntimeWarningsIncludesPolicyWarningWhenPresent(t *testing.T) {
 }
 
 func TestLogRunNoticeUsesCharmLogger(t *testing.T) {
-	prev := log.Default()
-	t.Cleanup(func() {
-		log.SetDefault(prev)
-	})
-
 	var buf bytes.Buffer
 	logger := log.NewWithOptions(&buf, log.Options{
 		Level:     log.InfoLevel,
 		Formatter: log.TextFormatter,
 	})
-	log.SetDefault(logger)
 
-	logRunNotice("darwin-vz", "run-123", "using managed kernel asset my-kernel (cache hit)")
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+	(&Adapter{Logger: logger}).logRunNotice(ctx, "run-123", "using managed kernel asset my-kernel (cache hit)")
 
 	out := buf.String()
 	if !strings.Contains(out, "using managed kernel asset my-kernel (cache hit)") {
@@ -75,4 +72,7 @@ func TestLogRunNoticeUsesCharmLogger(t *testing.T) {
 	if !strings.Contains(out, "run_id=run-123") {
 		t.Fatalf("expected run_id field in logger output, got %q", out)
 	}
+	if !strings.Contains(out, "request_id=req-1") {
+		t.Fatalf("expected request_id field in logger output, got %q", out)
+	}
 }
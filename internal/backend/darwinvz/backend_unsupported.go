@@ -8,12 +8,16 @@ import (
 	"runtime"
 
 	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/imagemgr"
+	"github.com/charmbracelet/log"
 )
 
 type Adapter struct {
 	GatewayRegistry gatewayRegistry
 	GatewayPort     int
 	GatewayHost     string
+	ImagePeers      imagemgr.PeerSource
+	Logger          *log.Logger
 }
 
 func New() *Adapter {
@@ -45,14 +49,16 @@ func (a *Adapter) Doctor(_ context.Context, _ backend.DoctorRequest) (*backend.D
 		Backend: a.Name(),
 		Checks: []backend.DoctorCheck{
 			{
-				Name:    "os",
-				Status:  "fail",
-				Message: fmt.Sprintf("darwin-vz backend requires macOS, current OS is %s", runtime.GOOS),
+				Name:        "os",
+				Status:      "fail",
+				Message:     fmt.Sprintf("darwin-vz backend requires macOS, current OS is %s", runtime.GOOS),
+				Remediation: doctorRemediation("os"),
 			},
 			{
-				Name:    "guest_networking",
-				Status:  "warn",
-				Message: guestNetworkUnavailableWarning,
+				Name:        "guest_networking",
+				Status:      "warn",
+				Message:     guestNetworkUnavailableWarning,
+				Remediation: doctorRemediation("guest_networking"),
 			},
 		},
 	}, nil
@@ -15,12 +15,12 @@ func dockerServiceBootArgs(compiled *policy.CompiledPolicy, cfg backend.Firecrac
 
 	startupSeconds := cfg.DockerStartupSeconds
 	if startupSeconds <= 0 {
-		startupSeconds = 20
+		startupSeconds = backend.DefaultDockerStartupSeconds
 	}
 
 	storageDriver := sanitizeKernelArgValue(strings.TrimSpace(cfg.DockerStorageDriver))
 	if storageDriver == "" {
-		storageDriver = "vfs"
+		storageDriver = backend.DefaultDockerStorageDriver
 	}
 
 	iptables := 0
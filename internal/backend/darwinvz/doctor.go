@@ -0,0 +1,26 @@
+package darwinvz
+
+// doctorRemediation returns a fix-it hint for a doctor check that did not
+// pass. Hints are keyed by check name so they stay attached to the stable
+// check ID rather than to a particular failure message.
+func doctorRemediation(name string) string {
+	switch name {
+	case "os":
+		return "run the darwin-vz backend on macOS, or select firecracker on Linux"
+	case "guest_networking":
+		return "restrict sandbox egress another way until darwin-vz supports host-side filtering"
+	case "kernel_image":
+		return "unset backends.darwin-vz.kernel_image to use the managed kernel, or point it at a readable kernel image"
+	case "rootfs", "sandbox_image_ref":
+		return "set sandbox.image.ref in cleanroom.yaml, or point backends.darwin-vz.rootfs at a readable ext4 image"
+	case "policy", "policy_network_default", "policy_network_allow":
+		return "run doctor from a repository with a valid cleanroom.yaml (see cleanroom policy validate)"
+	case "mkfs_ext4", "debugfs":
+		return "install e2fsprogs (brew install e2fsprogs)"
+	case "guest_agent_binary":
+		return "install the linux cleanroom-guest-agent alongside cleanroom (mise run install)"
+	case "helper_binary", "vm_entitlement":
+		return "install and sign cleanroom-darwin-vz with mise run install, or set " + helperEnvVar
+	}
+	return ""
+}
@@ -41,16 +41,13 @@ func gatewayEnvVars(compiled *policy.CompiledPolicy, gatewayHost string, gateway
 		if host == "" {
 			continue
 		}
-		for _, port := range rule.Ports {
-			if port != 443 {
-				continue
-			}
-			entries = append(entries, configEntry{
-				key:   fmt.Sprintf("url.http://%s:%d/git/%s/.insteadOf", gatewayHost, gatewayPort, host),
-				value: fmt.Sprintf("https://%s/", host),
-			})
-			break
+		if !rule.AllowsPort("tcp", 443) {
+			continue
 		}
+		entries = append(entries, configEntry{
+			key:   fmt.Sprintf("url.http://%s:%d/git/%s/.insteadOf", gatewayHost, gatewayPort, host),
+			value: fmt.Sprintf("https://%s/", host),
+		})
 	}
 
 	if len(entries) == 0 {
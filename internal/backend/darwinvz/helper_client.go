@@ -10,11 +10,12 @@ import (
 	"net"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
+
+	"github.com/buildkite/cleanroom/internal/rundir"
 )
 
 const (
@@ -63,15 +64,15 @@ type helperSession struct {
 	mu   sync.Mutex
 }
 
-func startHelperSession(ctx context.Context, runDir string, launchSeconds int64) (*helperSession, error) {
+func startHelperSession(ctx context.Context, runDir, socketDir string, launchSeconds int64) (*helperSession, error) {
 	helperPath, err := resolveHelperBinaryPath()
 	if err != nil {
 		return nil, err
 	}
 
-	socketPath := filepath.Join(runDir, helperControlSocketName)
-	if err := ensureUnixSocketPathFits(socketPath); err != nil {
-		return nil, fmt.Errorf("helper control socket path %q is too long: %w", socketPath, err)
+	socketPath, err := rundir.SocketPath(runDir, socketDir, helperControlSocketName)
+	if err != nil {
+		return nil, fmt.Errorf("helper control socket: %w", err)
 	}
 	_ = os.Remove(socketPath)
 
@@ -296,11 +297,3 @@ func (s *helperSession) decorateError(err error) error {
 	}
 	return fmt.Errorf("%w (helper stderr: %s)", err, stderr)
 }
-
-func ensureUnixSocketPathFits(path string) error {
-	const maxUnixSocketPath = 103
-	if len(path) <= maxUnixSocketPath {
-		return nil
-	}
-	return fmt.Errorf("max length is %d bytes, got %d", maxUnixSocketPath, len(path))
-}
@@ -0,0 +1,155 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+// agentUpgradeChunkBytes bounds each stdin frame carrying the new agent.
+const agentUpgradeChunkBytes = 256 << 10
+
+func (a *Adapter) SandboxAgentHash(sandboxID string) (string, error) {
+	a.sandboxMu.Lock()
+	defer a.sandboxMu.Unlock()
+	instance, ok := a.sandboxes[strings.TrimSpace(sandboxID)]
+	if !ok {
+		return "", fmt.Errorf("unknown sandbox %q", sandboxID)
+	}
+	return instance.AgentHash, nil
+}
+
+// SandboxEnvironment reports the guest agent, kernel and VM size the
+// sandbox runs with.
+func (a *Adapter) SandboxEnvironment(sandboxID string) (backend.SandboxEnvironment, error) {
+	a.sandboxMu.Lock()
+	defer a.sandboxMu.Unlock()
+	instance, ok := a.sandboxes[strings.TrimSpace(sandboxID)]
+	if !ok {
+		return backend.SandboxEnvironment{}, fmt.Errorf("unknown sandbox %q", sandboxID)
+	}
+	return backend.SandboxEnvironment{
+		GuestAgentSHA256: instance.AgentHash,
+		KernelVersion:    instance.KernelVersion,
+		VCPUs:            instance.VCPUs,
+		MemoryMiB:        instance.MemoryMiB,
+		DiskMiB:          instance.DiskMiB,
+	}, nil
+}
+
+// UpgradeSandboxAgent streams the host's guest agent binary into a running
+// sandbox over vsock. The running agent installs it over itself and
+// re-executes, so the VM and anything running in it are left alone. The
+// binary is looked up and hashed afresh rather than taken from the copy
+// cached for new sandboxes, so an agent replaced by self-update is picked up
+// without restarting the server.
+func (a *Adapter) UpgradeSandboxAgent(ctx context.Context, sandboxID string) (string, error) {
+	sandboxID = strings.TrimSpace(sandboxID)
+	if sandboxID == "" {
+		return "", errors.New("missing sandbox_id")
+	}
+	a.sandboxMu.Lock()
+	instance, ok := a.sandboxes[sandboxID]
+	a.sandboxMu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("unknown sandbox %q", sandboxID)
+	}
+	if err := instance.exitedErrOrNil(); err != nil {
+		return "", fmt.Errorf("sandbox %q is not running: %w", sandboxID, err)
+	}
+
+	discover := a.discoverGuestAgentFn
+	if discover == nil {
+		discover = discoverGuestAgentBinary
+	}
+	path, err := discover()
+	if err != nil {
+		return "", err
+	}
+	hash, err := hashFileSHA256(path)
+	if err != nil {
+		return "", err
+	}
+	a.sandboxMu.Lock()
+	current := instance.AgentHash
+	a.sandboxMu.Unlock()
+	if hash == current {
+		return hash, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	var sendErr error
+	resp, _, err := a.executeInSandbox(ctx, instance, 0, vsockexec.ExecRequest{
+		AgentUpgrade: &vsockexec.AgentUpgrade{SHA256: hash, Size: info.Size()},
+		Stdin:        true,
+	}, backend.OutputStream{
+		OnAttach: func(attach backend.AttachIO) {
+			sendErr = streamAgentBinary(f, attach)
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("upgrade guest agent: %w", err)
+	}
+	if resp.ExitCode != 0 {
+		msg := strings.TrimSpace(resp.Error)
+		if msg == "missing command" {
+			return "", fmt.Errorf("sandbox %q runs a guest agent that cannot be upgraded in place; recreate the sandbox", sandboxID)
+		}
+		if msg == "" {
+			msg = fmt.Sprintf("guest agent exited with code %d", resp.ExitCode)
+		}
+		return "", errors.New(msg)
+	}
+	if sendErr != nil {
+		return "", fmt.Errorf("upgrade guest agent: send binary: %w", sendErr)
+	}
+
+	a.sandboxMu.Lock()
+	instance.AgentHash = hash
+	a.sandboxMu.Unlock()
+
+	// The new agent listens once it has restarted; wait for it so the
+	// sandbox is usable again when the upgrade returns.
+	probe, _, err := a.executeInSandbox(ctx, instance, 0, vsockexec.ExecRequest{Command: []string{"true"}}, backend.OutputStream{})
+	if err != nil {
+		return "", fmt.Errorf("wait for upgraded guest agent: %w", err)
+	}
+	if probe.ExitCode != 0 {
+		return "", fmt.Errorf("wait for upgraded guest agent: %s", strings.TrimSpace(probe.Error))
+	}
+	return hash, nil
+}
+
+// streamAgentBinary sends r to the guest as stdin frames followed by eof.
+func streamAgentBinary(r io.Reader, attach backend.AttachIO) error {
+	buf := make([]byte, agentUpgradeChunkBytes)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if writeErr := attach.WriteStdin(buf[:n]); writeErr != nil {
+				return writeErr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return attach.CloseStdin()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
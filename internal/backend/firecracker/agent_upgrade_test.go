@@ -0,0 +1,119 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+func writeTestAgent(t *testing.T, content string) (string, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cleanroom-guest-agent")
+	if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(content))
+	return path, hex.EncodeToString(sum[:])
+}
+
+func TestUpgradeSandboxAgentStreamsBinaryAndRecordsHash(t *testing.T) {
+	t.Parallel()
+
+	agentPath, agentHash := writeTestAgent(t, strings.Repeat("agent", agentUpgradeChunkBytes/4))
+	adapter := &Adapter{
+		discoverGuestAgentFn: func() (string, error) { return agentPath, nil },
+		sandboxes: map[string]*sandboxInstance{
+			"cr-test": {SandboxID: "cr-test", GuestPort: 10700, AgentHash: "old"},
+		},
+	}
+	var requests []vsockexec.ExecRequest
+	var received bytes.Buffer
+	closed := false
+	adapter.runGuestCommandFn = func(_ context.Context, _ context.Context, _ <-chan struct{}, _ func() error, _ string, _ uint32, req vsockexec.ExecRequest, stream backend.OutputStream) (vsockexec.ExecResponse, guestExecTiming, error) {
+		requests = append(requests, req)
+		if stream.OnAttach != nil {
+			stream.OnAttach(backend.AttachIO{
+				WriteStdin: func(data []byte) error { _, err := received.Write(data); return err },
+				CloseStdin: func() error { closed = true; return nil },
+			})
+		}
+		return vsockexec.ExecResponse{}, guestExecTiming{}, nil
+	}
+
+	hash, err := adapter.UpgradeSandboxAgent(context.Background(), "cr-test")
+	if err != nil {
+		t.Fatalf("UpgradeSandboxAgent returned error: %v", err)
+	}
+	if hash != agentHash {
+		t.Fatalf("hash = %q, want %q", hash, agentHash)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected an upgrade and a readiness probe, got %d requests", len(requests))
+	}
+	upgrade := requests[0].AgentUpgrade
+	if upgrade == nil || upgrade.SHA256 != agentHash || upgrade.Size != int64(received.Len()) || !requests[0].Stdin {
+		t.Fatalf("unexpected upgrade request %+v", requests[0])
+	}
+	want, _ := os.ReadFile(agentPath)
+	if !bytes.Equal(received.Bytes(), want) || !closed {
+		t.Fatalf("guest received %d bytes (closed=%v), want %d", received.Len(), closed, len(want))
+	}
+	if requests[1].AgentUpgrade != nil || strings.Join(requests[1].Command, " ") != "true" {
+		t.Fatalf("unexpected probe request %+v", requests[1])
+	}
+	if got, _ := adapter.SandboxAgentHash("cr-test"); got != agentHash {
+		t.Fatalf("recorded hash = %q, want %q", got, agentHash)
+	}
+}
+
+func TestUpgradeSandboxAgentSkipsCurrentAgent(t *testing.T) {
+	t.Parallel()
+
+	agentPath, agentHash := writeTestAgent(t, "agent")
+	adapter := &Adapter{
+		discoverGuestAgentFn: func() (string, error) { return agentPath, nil },
+		sandboxes: map[string]*sandboxInstance{
+			"cr-test": {SandboxID: "cr-test", GuestPort: 10700, AgentHash: agentHash},
+		},
+	}
+	adapter.runGuestCommandFn = func(context.Context, context.Context, <-chan struct{}, func() error, string, uint32, vsockexec.ExecRequest, backend.OutputStream) (vsockexec.ExecResponse, guestExecTiming, error) {
+		t.Fatal("expected no guest request")
+		return vsockexec.ExecResponse{}, guestExecTiming{}, nil
+	}
+
+	hash, err := adapter.UpgradeSandboxAgent(context.Background(), "cr-test")
+	if err != nil || hash != agentHash {
+		t.Fatalf("UpgradeSandboxAgent = %q, %v", hash, err)
+	}
+}
+
+func TestUpgradeSandboxAgentExplainsAgentsWithoutUpgradeSupport(t *testing.T) {
+	t.Parallel()
+
+	agentPath, _ := writeTestAgent(t, "agent")
+	adapter := &Adapter{
+		discoverGuestAgentFn: func() (string, error) { return agentPath, nil },
+		sandboxes: map[string]*sandboxInstance{
+			"cr-test": {SandboxID: "cr-test", GuestPort: 10700, AgentHash: "old"},
+		},
+	}
+	adapter.runGuestCommandFn = func(context.Context, context.Context, <-chan struct{}, func() error, string, uint32, vsockexec.ExecRequest, backend.OutputStream) (vsockexec.ExecResponse, guestExecTiming, error) {
+		return vsockexec.ExecResponse{ExitCode: 1, Error: "missing command"}, guestExecTiming{}, nil
+	}
+
+	_, err := adapter.UpgradeSandboxAgent(context.Background(), "cr-test")
+	if err == nil || !strings.Contains(err.Error(), "cannot be upgraded in place") {
+		t.Fatalf("expected an unsupported agent error, got %v", err)
+	}
+	if got, _ := adapter.SandboxAgentHash("cr-test"); got != "old" {
+		t.Fatalf("recorded hash changed to %q", got)
+	}
+}
@@ -0,0 +1,98 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// apiClient talks to a running Firecracker process over its API socket. The
+// VM is still configured from --config-file at launch; the API is used for
+// actions on the running VM.
+type apiClient struct {
+	socketPath string
+	http       *http.Client
+}
+
+func newAPIClient(socketPath string) *apiClient {
+	return &apiClient{
+		socketPath: socketPath,
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+// pause stops the VM's vCPUs. Guest memory and devices are kept, so a
+// resumed guest carries on where it left off.
+func (c *apiClient) pause(ctx context.Context) error {
+	return c.do(ctx, http.MethodPatch, "/vm", map[string]string{"state": "Paused"})
+}
+
+func (c *apiClient) resume(ctx context.Context) error {
+	return c.do(ctx, http.MethodPatch, "/vm", map[string]string{"state": "Resumed"})
+}
+
+// updateBalloon inflates or deflates the balloon device to amountMiB,
+// reclaiming that much guest memory for the host. The VM must have been
+// launched with a balloon device.
+func (c *apiClient) updateBalloon(ctx context.Context, amountMiB int64) error {
+	return c.do(ctx, http.MethodPatch, "/balloon", map[string]int64{"amount_mib": amountMiB})
+}
+
+// flushMetrics writes Firecracker's current metrics to its metrics file or
+// FIFO. The VM must have been launched with metrics configured.
+func (c *apiClient) flushMetrics(ctx context.Context) error {
+	return c.do(ctx, http.MethodPut, "/actions", map[string]string{"action_type": "FlushMetrics"})
+}
+
+// createSnapshot writes the VM state to snapshotPath and guest memory to
+// memPath. The VM must be paused.
+func (c *apiClient) createSnapshot(ctx context.Context, snapshotPath, memPath string) error {
+	return c.do(ctx, http.MethodPut, "/snapshot/create", map[string]string{
+		"snapshot_type": "Full",
+		"snapshot_path": snapshotPath,
+		"mem_file_path": memPath,
+	})
+}
+
+// do sends one API request. Firecracker answers 204 on success and a JSON
+// fault_message otherwise.
+func (c *apiClient) do(ctx context.Context, method, path string, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, "http://firecracker"+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("firecracker API %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var fault struct {
+		FaultMessage string `json:"fault_message"`
+	}
+	if json.Unmarshal(raw, &fault) == nil && fault.FaultMessage != "" {
+		return fmt.Errorf("firecracker API %s %s: %s", method, path, fault.FaultMessage)
+	}
+	return fmt.Errorf("firecracker API %s %s: %s", method, path, resp.Status)
+}
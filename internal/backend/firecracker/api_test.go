@@ -0,0 +1,58 @@
+package firecracker
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAPIClientSendsActionsAndReportsFaults(t *testing.T) {
+	t.Parallel()
+
+	socketPath := filepath.Join(t.TempDir(), "fc.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	var got []string
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		raw, _ := json.Marshal(body)
+		got = append(got, r.Method+" "+r.URL.Path+" "+string(raw))
+		if r.URL.Path == "/balloon" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"fault_message":"Invalid request method and/or path: PATCH /balloon."}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = server.Close() })
+
+	client := newAPIClient(socketPath)
+	ctx := context.Background()
+	if err := client.pause(ctx); err != nil {
+		t.Fatalf("pause returned error: %v", err)
+	}
+	if err := client.createSnapshot(ctx, "/run/vm.snap", "/run/vm.mem"); err != nil {
+		t.Fatalf("createSnapshot returned error: %v", err)
+	}
+	err = client.updateBalloon(ctx, 256)
+	if err == nil || !strings.Contains(err.Error(), "PATCH /balloon: Invalid request method") {
+		t.Fatalf("expected the fault message, got %v", err)
+	}
+
+	want := []string{
+		`PATCH /vm {"state":"Paused"}`,
+		`PUT /snapshot/create {"mem_file_path":"/run/vm.mem","snapshot_path":"/run/vm.snap","snapshot_type":"Full"}`,
+		`PATCH /balloon {"amount_mib":256}`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected requests:\n got %q\nwant %q", got, want)
+	}
+}
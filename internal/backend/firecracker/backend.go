@@ -2,6 +2,7 @@ package firecracker
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	cryptorand "crypto/rand"
 	"crypto/sha1"
@@ -11,33 +12,47 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"math"
 	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/buildkite/cleanroom/internal/backend"
 	"github.com/buildkite/cleanroom/internal/bootassets"
+	"github.com/buildkite/cleanroom/internal/caphelper"
 	"github.com/buildkite/cleanroom/internal/gateway"
 	"github.com/buildkite/cleanroom/internal/hosttools"
 	"github.com/buildkite/cleanroom/internal/imagemgr"
+	"github.com/buildkite/cleanroom/internal/logging"
 	"github.com/buildkite/cleanroom/internal/paths"
 	"github.com/buildkite/cleanroom/internal/policy"
+	"github.com/buildkite/cleanroom/internal/rundir"
 	"github.com/buildkite/cleanroom/internal/vsockexec"
+	"github.com/charmbracelet/log"
 	fcvsock "github.com/firecracker-microvm/firecracker-go-sdk/vsock"
+	"golang.org/x/sync/singleflight"
 )
 
 type imageEnsurer interface {
 	Ensure(context.Context, string) (imagemgr.EnsureResult, error)
 }
 
+// preparedRecorder is implemented by image managers that track the prepared
+// rootfs derived from their images, so they can be removed and exported
+// along with them.
+type preparedRecorder interface {
+	RecordPrepared(ctx context.Context, digest, path string) error
+}
+
 type imageManagerFactory func() (imageEnsurer, error)
 
 type Adapter struct {
@@ -46,21 +61,50 @@ type Adapter struct {
 	imageManagerErr  error
 	newImageManager  imageManagerFactory
 
+	scratchOnce sync.Once
+	scratch     *scratchPool
+
+	networkAllocOnce sync.Once
+	networkAlloc     *networkAllocator
+	networkAllocErr  error
+
 	guestAgentOnce sync.Once
 	guestAgentPath string
 	guestAgentHash string
 	guestAgentErr  error
+	// discoverGuestAgentFn finds the agent binary for in-place upgrades;
+	// tests replace it.
+	discoverGuestAgentFn func() (string, error)
 
 	runtimeImageMu sync.Mutex
+	setupBuilds    singleflight.Group
 
 	sandboxMu         sync.Mutex
 	sandboxes         map[string]*sandboxInstance
 	provisioning      map[string]struct{}
-	launchSandboxVMFn func(context.Context, string, *policy.CompiledPolicy, backend.FirecrackerConfig) (*sandboxInstance, error)
+	launchSandboxVMFn func(context.Context, string, string, *policy.CompiledPolicy, backend.FirecrackerConfig, []backend.HostResolution) (*sandboxInstance, error)
 	runGuestCommandFn func(context.Context, context.Context, <-chan struct{}, func() error, string, uint32, vsockexec.ExecRequest, backend.OutputStream) (vsockexec.ExecResponse, guestExecTiming, error)
 
 	GatewayRegistry gatewayRegistry
 	GatewayPort     int
+
+	// ImagePeers, if set, is asked for images missing from the local cache
+	// before the registry. Set it before the first launch.
+	ImagePeers imagemgr.PeerSource
+
+	// Logger receives the adapter's notices; nil uses the default logger.
+	Logger *log.Logger
+	// NetworkLogger receives host network setup and teardown; nil uses Logger.
+	NetworkLogger *log.Logger
+
+	// LeakCheck reports resources that outlive terminated sandboxes; see
+	// LeakCheckEnv.
+	LeakCheck bool
+
+	// Faults injects failures for integration tests; see FaultPlan.
+	Faults     FaultPlan
+	faultsErr  error
+	faultDials atomic.Int64
 }
 
 // gatewayRegistry is the subset of gateway.Registry used by the adapter.
@@ -81,21 +125,47 @@ type sandboxInstance struct {
 	CommandTimeout int64
 	HostIP         string
 	GuestIP        string
+	SourceIP       string // registered with the gateway
+	TapName        string
+	Namespace      string
+	VCPUs          int64
+	MemoryMiB      int64
+	DiskMiB        int64
+	KernelVersion  string
+	AgentHash      string
+	Resolutions    []backend.HostResolution
 	fcCmd          *exec.Cmd
+	api            *apiClient
+	metrics        *vmMetrics
 	exitedCh       chan struct{}
 	exitMu         sync.RWMutex
 	exitErr        error
 	exitReady      bool
 	cleanupNetwork func()
-	vmRootFSPath   string
+	// cleanupGroup removes the sandbox group links the sandbox is part of.
+	cleanupGroup func()
+	vmRootFSPath string
+	socketDir    string
+	// listRules lists an iptables chain for the leak checker; it is set
+	// only when leak checking is on.
+	listRules func(ctx context.Context, table, chain string) (string, error)
 }
 
-const runObservabilityFile = "run-observability.json"
 const vsockDialRetryInterval = 50 * time.Millisecond
 const preparedRuntimeRootFSVersion = "v1"
 const privilegedModeSudo = "sudo"
 const privilegedModeHelper = "helper"
-const defaultPrivilegedHelperPath = "/usr/local/sbin/cleanroom-root-helper"
+
+// privilegedModeCaps runs privileged commands through the capability
+// helper, which needs neither sudo nor root; see package caphelper.
+const privilegedModeCaps = "caps"
+
+// Defaults for unset firecracker runtime config settings.
+const (
+	DefaultBinaryPath           = "firecracker"
+	DefaultPrivilegedMode       = privilegedModeSudo
+	DefaultPrivilegedHelperPath = "/usr/local/sbin/cleanroom-root-helper"
+)
 const defaultDownloadMaxBytes int64 = 10 * 1024 * 1024
 
 const guestInitScriptTemplate = `#!/bin/sh
@@ -108,6 +178,8 @@ mkdir -p /dev/pts /run /tmp
 mount -t devpts devpts /dev/pts 2>/dev/null || true
 mount -t tmpfs tmpfs /run 2>/dev/null || true
 mount -t tmpfs tmpfs /tmp 2>/dev/null || true
+mkdir -p /sys/fs/cgroup
+mount -t cgroup2 none /sys/fs/cgroup 2>/dev/null || true
 
 export HOME=/root
 export PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin:/root/.local/bin
@@ -128,9 +200,13 @@ GUEST_GW="$(arg_value cleanroom_guest_gw || true)"
 GUEST_MASK="$(arg_value cleanroom_guest_mask || true)"
 GUEST_DNS="$(arg_value cleanroom_guest_dns || true)"
 GUEST_PORT="$(arg_value cleanroom_guest_port || true)"
+GUEST_MTU="$(arg_value cleanroom_guest_mtu || true)"
 
 if command -v ip >/dev/null 2>&1 && [ -n "$GUEST_IP" ]; then
   [ -n "$GUEST_MASK" ] || GUEST_MASK="24"
+  if [ -n "$GUEST_MTU" ]; then
+    ip link set dev eth0 mtu "$GUEST_MTU" 2>/dev/null || true
+  fi
   ip link set dev eth0 up 2>/dev/null || true
   ip addr flush dev eth0 2>/dev/null || true
   ip addr add "$GUEST_IP/$GUEST_MASK" dev eth0 2>/dev/null || true
@@ -138,10 +214,38 @@ if command -v ip >/dev/null 2>&1 && [ -n "$GUEST_IP" ]; then
     ip route add default via "$GUEST_GW" dev eth0 2>/dev/null || true
   fi
   if [ -n "$GUEST_DNS" ]; then
-    printf 'nameserver %s\n' "$GUEST_DNS" > /etc/resolv.conf 2>/dev/null || true
+    if ! printf 'nameserver %s\n' "$GUEST_DNS" > /etc/resolv.conf 2>/dev/null; then
+      printf 'nameserver %s\n' "$GUEST_DNS" > /run/resolv.conf 2>/dev/null || true
+      mount --bind /run/resolv.conf /etc/resolv.conf 2>/dev/null || true
+    fi
   fi
 fi
 
+if [ "$(arg_value cleanroom_rootfs_read_only || true)" = "1" ]; then
+  WRITABLE="$(arg_value cleanroom_writable || true)"
+  for spec in $(echo "$WRITABLE" | tr ',' ' '); do
+    dir="${spec%%:*}"
+    kind="${spec#*:}"
+    if [ ! -d "$dir" ]; then
+      echo "cleanroom-init: writable path $dir does not exist in the image" >&2
+      continue
+    fi
+    case "$kind" in
+      tmpfs:*)
+        size="${kind#tmpfs:}"
+        if [ -n "$size" ] && [ "$size" != "0" ]; then
+          mount -t tmpfs -o "mode=0755,size=${size}m" tmpfs "$dir" || echo "cleanroom-init: mount tmpfs at $dir failed" >&2
+        else
+          mount -t tmpfs -o mode=0755 tmpfs "$dir" || echo "cleanroom-init: mount tmpfs at $dir failed" >&2
+        fi
+        ;;
+      *)
+        mount -t ext4 "/dev/$kind" "$dir" || echo "cleanroom-init: mount /dev/$kind at $dir failed" >&2
+        ;;
+    esac
+  done
+fi
+
 if [ -z "$GUEST_PORT" ]; then
   GUEST_PORT="10700"
 fi
@@ -167,8 +271,7 @@ if [ "$DOCKER_REQUIRED" = "1" ] && command -v dockerd >/dev/null 2>&1; then
     DOCKER_ARGS="$DOCKER_ARGS --iptables=false"
   fi
 
-  mkdir -p /var/log /var/lib/docker /etc/docker /var/run /sys/fs/cgroup
-  mount -t cgroup2 none /sys/fs/cgroup 2>/dev/null || true
+  mkdir -p /var/log /var/lib/docker /etc/docker /var/run
   if [ ! -S /var/run/docker.sock ]; then
     dockerd $DOCKER_ARGS >/var/log/dockerd.log 2>&1 &
   fi
@@ -196,11 +299,15 @@ done
 `
 
 func New() *Adapter {
-	return &Adapter{newImageManager: defaultImageManagerFactory}
+	a := &Adapter{}
+	a.newImageManager = a.defaultImageManager
+	a.Faults, a.faultsErr = FaultPlanFromEnv()
+	a.LeakCheck = leakCheckFromEnv()
+	return a
 }
 
-func defaultImageManagerFactory() (imageEnsurer, error) {
-	return imagemgr.New(imagemgr.Options{})
+func (a *Adapter) defaultImageManager() (imageEnsurer, error) {
+	return imagemgr.New(imagemgr.Options{Peers: a.ImagePeers, Logger: a.Logger})
 }
 
 func (a *Adapter) Name() string {
@@ -212,6 +319,11 @@ func (a *Adapter) Capabilities() map[string]bool {
 		backend.CapabilityNetworkDefaultDeny:     true,
 		backend.CapabilityNetworkAllowlistEgress: true,
 		backend.CapabilityNetworkGuestInterface:  true,
+		backend.CapabilityNestedVirtualization:   nestedVirtSupport(readHostCPU(), os.ReadFile) == nil,
+		backend.CapabilityDockerPreload:          true,
+		backend.CapabilityReadOnlyRootFS:         true,
+		backend.CapabilityExecCaptureChanges:     true,
+		backend.CapabilitySandboxSetup:           true,
 	}
 }
 
@@ -228,6 +340,9 @@ func (a *Adapter) ProvisionSandbox(ctx context.Context, req backend.ProvisionReq
 	if sandboxID == "" {
 		return errors.New("missing sandbox_id")
 	}
+	if a.faultsErr != nil {
+		return a.faultsErr
+	}
 	if req.Policy == nil {
 		return errors.New("missing compiled policy")
 	}
@@ -255,7 +370,15 @@ func (a *Adapter) ProvisionSandbox(ctx context.Context, req backend.ProvisionReq
 		launch = a.launchSandboxVM
 	}
 
-	instance, err := launch(ctx, sandboxID, req.Policy, req.FirecrackerConfig)
+	instance, err := launch(ctx, sandboxID, req.Namespace, req.Policy, req.FirecrackerConfig, req.PinnedResolutions)
+	if err == nil && req.Policy != nil {
+		if err = a.preloadDockerImages(ctx, instance, req.Policy.Services.Docker.Preload); err != nil {
+			if a.GatewayRegistry != nil && instance.SourceIP != "" {
+				a.GatewayRegistry.Release(instance.SourceIP)
+			}
+			instance.shutdown()
+		}
+	}
 	if err != nil {
 		a.sandboxMu.Lock()
 		delete(a.provisioning, sandboxID)
@@ -317,43 +440,84 @@ func (a *Adapter) RunInSandbox(ctx context.Context, req backend.RunRequest, stre
 			return
 		}
 		observation.TotalMS = time.Since(runStart).Milliseconds()
-		obsPath := filepath.Join(runDir, runObservabilityFile)
+		obsPath := filepath.Join(runDir, rundir.ObservabilityFile)
 		_ = writeJSON(obsPath, observation)
 	}
 	defer writeObservation()
 	if runDir != "" {
-		if err := os.MkdirAll(runDir, 0o755); err != nil {
-			return nil, fmt.Errorf("create run directory: %w", err)
+		if err := rundir.Create(runDir, rundir.Manifest{RunID: req.RunID, Backend: a.Name(), SandboxID: sandboxID}); err != nil {
+			return nil, err
 		}
 	}
 
-	guestResult, timing, err := a.executeInSandbox(ctx, instance, req.LaunchSeconds, req.Command, req.TTY, stream)
+	guestReq := vsockexec.ExecRequest{
+		Command:        append([]string(nil), req.Command...),
+		TTY:            req.TTY,
+		Banner:         req.ConsoleBanner(),
+		SessionLimits:  req.ConsoleLimits(),
+		Limits:         req.Limits.Guest(),
+		KeepBackground: req.KeepBackgroundProcesses,
+		FreshHome:      req.FreshHome,
+		Launcher:       req.Launcher,
+		Stdin:          req.Stdin,
+		CaptureChanges: req.CaptureChanges,
+		Compression:    vsockexec.CompressionZstd,
+	}
+	consoleOffset := consoleLogSize(instance.RunDir)
+	var statsBefore backend.VMStats
+	if instance.metrics != nil {
+		statsBefore = instance.metrics.flush(ctx, instance.api)
+	}
+	guestResult, timing, err := a.executeInSandbox(ctx, instance, req.LaunchSeconds, guestReq, stream)
 	if err != nil {
+		if failure := guestFailureFromConsole(instance.RunDir, consoleOffset, instance.MemoryMiB, err); failure != nil && ctx.Err() == nil {
+			err = failure
+		}
 		observation.ExitCode = 1
 		observation.GuestError = err.Error()
-		return nil, err
+		return nil, withDiagnostics(ctx, err, runDir, vmDiagnostics{
+			LogDir:  instance.RunDir,
+			Exited:  instance.exitedCh,
+			ExitErr: instance.exitedErrOrNil,
+			Network: hostNetworkConfig{TapName: instance.TapName, HostIP: instance.HostIP, GuestIP: instance.GuestIP, Namespace: instance.Namespace},
+			Exec: guestExecCollector(func(ctx context.Context, execReq vsockexec.ExecRequest, stream backend.OutputStream) (vsockexec.ExecResponse, error) {
+				resp, _, err := a.executeInSandbox(ctx, instance, 0, execReq, stream)
+				return resp, err
+			}),
+		})
 	}
 	observation.ExitCode = guestResult.ExitCode
 	observation.GuestError = guestResult.Error
 	observation.GuestExecMS = timing.CommandRun.Milliseconds()
 	observation.VsockWaitMS = timing.WaitForAgent.Milliseconds()
+	if instance.metrics != nil {
+		stats := instance.metrics.flush(ctx, instance.api).Sub(statsBefore)
+		observation.VMStats = &stats
+	}
 
 	message := runResultMessage("guest command execution complete")
 	if guestResult.Error != "" {
 		message = runResultMessage("guest command execution completed with guest-side error detail: " + guestResult.Error)
 	}
+	failure := commandGuestFailure(instance.RunDir, consoleOffset, instance.MemoryMiB, guestResult.Metadata)
+	if failure != nil {
+		message = failure.Error()
+	}
 
 	return &backend.RunResult{
-		RunID:       req.RunID,
-		ExitCode:    guestResult.ExitCode,
-		LaunchedVM:  false,
-		PlanPath:    instance.ConfigPath,
-		RunDir:      runDir,
-		ImageRef:    instance.ImageRef,
-		ImageDigest: instance.ImageDigest,
-		Message:     message,
-		Stdout:      guestResult.Stdout,
-		Stderr:      guestResult.Stderr,
+		RunID:        req.RunID,
+		ExitCode:     guestResult.ExitCode,
+		LaunchedVM:   false,
+		PlanPath:     instance.ConfigPath,
+		RunDir:       runDir,
+		ImageRef:     instance.ImageRef,
+		ImageDigest:  instance.ImageDigest,
+		Message:      message,
+		Stdout:       guestResult.Stdout,
+		Stderr:       guestResult.Stderr,
+		ExitMetadata: guestExitMetadata(guestResult.Metadata),
+		Failure:      failure,
+		Timings:      observation.runTimings(time.Since(runStart)),
 	}, nil
 }
 
@@ -372,6 +536,89 @@ func (a *Adapter) DownloadSandboxFile(ctx context.Context, sandboxID, path strin
 		maxBytes = defaultDownloadMaxBytes
 	}
 
+	limit := maxBytes + 1
+	if maxBytes == math.MaxInt64 {
+		limit = maxBytes
+	}
+	data, err := a.readFromSandbox(ctx, sandboxID, []string{"head", "-c", strconv.FormatInt(limit, 10), "--", path}, "read file command failed")
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("file %q exceeds max_bytes=%d", path, maxBytes)
+	}
+	return data, nil
+}
+
+// DownloadSandboxFileDelta has the guest agent diff path against the base
+// signatures describe, so only the blocks the base lacks cross the vsock.
+func (a *Adapter) DownloadSandboxFileDelta(ctx context.Context, sandboxID, path string, maxBytes int64, blockSize int, signatures []byte) ([]byte, error) {
+	sandboxID = strings.TrimSpace(sandboxID)
+	if sandboxID == "" {
+		return nil, errors.New("missing sandbox_id")
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, errors.New("invalid path: must be absolute")
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultDownloadMaxBytes
+	}
+
+	req := vsockexec.ExecRequest{
+		FileDelta: &vsockexec.FileDelta{
+			Path:       path,
+			BlockSize:  blockSize,
+			Signatures: signatures,
+			MaxBytes:   maxBytes,
+		},
+		Compression: vsockexec.CompressionZstd,
+	}
+	delta, err := a.requestFromSandbox(ctx, sandboxID, req, "file delta failed")
+	if err != nil {
+		// Agents that predate deltas see a request without a command.
+		if err.Error() == "missing command" {
+			return nil, backend.ErrFileDeltaUnsupported
+		}
+		return nil, err
+	}
+	return delta, nil
+}
+
+func (a *Adapter) TakeArtifactManifest(ctx context.Context, sandboxID string, maxBytes int64) ([]byte, error) {
+	sandboxID = strings.TrimSpace(sandboxID)
+	if sandboxID == "" {
+		return nil, errors.New("missing sandbox_id")
+	}
+	if maxBytes <= 0 || maxBytes == math.MaxInt64 {
+		maxBytes = defaultDownloadMaxBytes
+	}
+	// The manifest is removed only after it was read in full, so a failed
+	// read leaves it in place for inspection.
+	script := `if [ -f "$1" ]; then head -c "$2" -- "$1" && rm -f -- "$1"; fi`
+	cmd := []string{"sh", "-c", script, "sh", backend.ArtifactManifestPath, strconv.FormatInt(maxBytes+1, 10)}
+	data, err := a.readFromSandbox(ctx, sandboxID, cmd, "read artifact manifest failed")
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("artifact manifest exceeds %d bytes", maxBytes)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return data, nil
+}
+
+// readFromSandbox runs cmd in a running sandbox and returns its stdout. A
+// non-zero exit is reported with the command's stderr, or failureMsg when it
+// printed nothing.
+func (a *Adapter) readFromSandbox(ctx context.Context, sandboxID string, cmd []string, failureMsg string) ([]byte, error) {
+	return a.requestFromSandbox(ctx, sandboxID, vsockexec.ExecRequest{Command: cmd}, failureMsg)
+}
+
+// requestFromSandbox sends req to a running sandbox's guest agent and
+// returns the stdout it answers with, like readFromSandbox.
+func (a *Adapter) requestFromSandbox(ctx context.Context, sandboxID string, req vsockexec.ExecRequest, failureMsg string) ([]byte, error) {
 	a.sandboxMu.Lock()
 	instance, ok := a.sandboxes[sandboxID]
 	a.sandboxMu.Unlock()
@@ -383,12 +630,7 @@ func (a *Adapter) DownloadSandboxFile(ctx context.Context, sandboxID, path strin
 	}
 
 	var stdout bytes.Buffer
-	limit := maxBytes + 1
-	if maxBytes == math.MaxInt64 {
-		limit = maxBytes
-	}
-	cmd := []string{"head", "-c", strconv.FormatInt(limit, 10), "--", path}
-	result, _, err := a.executeInSandbox(ctx, instance, 0, cmd, false, backend.OutputStream{OnStdout: func(chunk []byte) {
+	result, _, err := a.executeInSandbox(ctx, instance, 0, req, backend.OutputStream{OnStdout: func(chunk []byte) {
 		_, _ = stdout.Write(chunk)
 	}})
 	if err != nil {
@@ -400,7 +642,7 @@ func (a *Adapter) DownloadSandboxFile(ctx context.Context, sandboxID, path strin
 			msg = strings.TrimSpace(result.Error)
 		}
 		if msg == "" {
-			msg = "read file command failed"
+			msg = failureMsg
 		}
 		return nil, errors.New(msg)
 	}
@@ -409,13 +651,10 @@ func (a *Adapter) DownloadSandboxFile(ctx context.Context, sandboxID, path strin
 	if len(data) == 0 && result.Stdout != "" {
 		data = []byte(result.Stdout)
 	}
-	if int64(len(data)) > maxBytes {
-		return nil, fmt.Errorf("file %q exceeds max_bytes=%d", path, maxBytes)
-	}
 	return append([]byte(nil), data...), nil
 }
 
-func (a *Adapter) TerminateSandbox(_ context.Context, sandboxID string) error {
+func (a *Adapter) TerminateSandbox(ctx context.Context, sandboxID string) error {
 	sandboxID = strings.TrimSpace(sandboxID)
 	if sandboxID == "" {
 		return errors.New("missing sandbox_id")
@@ -431,19 +670,22 @@ func (a *Adapter) TerminateSandbox(_ context.Context, sandboxID string) error {
 		return nil
 	}
 
-	if a.GatewayRegistry != nil && instance.GuestIP != "" {
-		a.GatewayRegistry.Release(instance.GuestIP)
+	if a.GatewayRegistry != nil && instance.SourceIP != "" {
+		a.GatewayRegistry.Release(instance.SourceIP)
 	}
 	instance.shutdown()
+	if a.LeakCheck {
+		a.checkSandboxLeaks(ctx, instance)
+	}
 	return nil
 }
 
-func (a *Adapter) executeInSandbox(ctx context.Context, instance *sandboxInstance, launchSeconds int64, command []string, tty bool, stream backend.OutputStream) (vsockexec.ExecResponse, guestExecTiming, error) {
-	guestReq := vsockexec.ExecRequest{Command: append([]string(nil), command...), TTY: tty}
+func (a *Adapter) executeInSandbox(ctx context.Context, instance *sandboxInstance, launchSeconds int64, guestReq vsockexec.ExecRequest, stream backend.OutputStream) (vsockexec.ExecResponse, guestExecTiming, error) {
 	seed := make([]byte, 64)
 	if _, err := cryptorand.Read(seed); err == nil {
 		guestReq.EntropySeed = seed
 	}
+	guestReq.RequestID = logging.RequestID(ctx)
 	if a.GatewayRegistry != nil && instance.HostIP != "" {
 		gwPort := a.GatewayPort
 		if gwPort == 0 {
@@ -467,20 +709,28 @@ func (a *Adapter) executeInSandbox(ctx context.Context, instance *sandboxInstanc
 		runGuestCommandFn = runGuestCommand
 	}
 
+	if err := a.injectVsockDialFault(bootCtx); err != nil {
+		return vsockexec.ExecResponse{}, guestExecTiming{}, err
+	}
+	defer a.injectVMKill(instance.fcCmd)()
 	return runGuestCommandFn(bootCtx, ctx, instance.exitedCh, instance.exitedErrOrNil, instance.VsockPath, instance.GuestPort, guestReq, stream)
 }
 
-func (a *Adapter) Doctor(_ context.Context, req backend.DoctorRequest) (*backend.DoctorReport, error) {
+func (a *Adapter) Doctor(ctx context.Context, req backend.DoctorRequest) (*backend.DoctorReport, error) {
 	report := &backend.DoctorReport{
 		Backend: a.Name(),
 	}
 
 	appendCheck := func(name, status, message string) {
-		report.Checks = append(report.Checks, backend.DoctorCheck{
+		check := backend.DoctorCheck{
 			Name:    name,
 			Status:  status,
 			Message: message,
-		})
+		}
+		if status != "pass" {
+			check.Remediation = doctorRemediation(name)
+		}
+		report.Checks = append(report.Checks, check)
 	}
 
 	if runtime.GOOS == "linux" {
@@ -491,7 +741,7 @@ func (a *Adapter) Doctor(_ context.Context, req backend.DoctorRequest) (*backend
 
 	binary := req.BinaryPath
 	if binary == "" {
-		binary = "firecracker"
+		binary = DefaultBinaryPath
 	}
 	if _, err := exec.LookPath(binary); err != nil {
 		appendCheck("binary", "fail", fmt.Sprintf("firecracker binary %q not found in PATH", binary))
@@ -510,7 +760,13 @@ func (a *Adapter) Doctor(_ context.Context, req backend.DoctorRequest) (*backend
 		}
 	}
 
-	if configured := strings.TrimSpace(req.KernelImagePath); configured == "" {
+	if configured := strings.TrimSpace(req.KernelImagePath); configured == "" && !req.KernelSource.IsZero() {
+		if err := req.KernelSource.Validate(); err != nil {
+			appendCheck("kernel_image", "fail", fmt.Sprintf("kernel_source: %v", err))
+		} else {
+			appendCheck("kernel_image", "pass", fmt.Sprintf("kernel image will be fetched from %s and pinned to sha256 %s", req.KernelSource.Location(), req.KernelSource.SHA256))
+		}
+	} else if configured == "" {
 		if spec, ok := bootassets.LookupManagedKernelForHost(a.Name()); ok {
 			path, _ := bootassets.ManagedKernelPathForHost(a.Name())
 			appendCheck("kernel_image", "pass", fmt.Sprintf("kernel image will be auto-managed (%s -> %s)", spec.ID, path))
@@ -526,6 +782,15 @@ func (a *Adapter) Doctor(_ context.Context, req backend.DoctorRequest) (*backend
 		}
 	} else {
 		appendCheck("kernel_image", "pass", fmt.Sprintf("kernel image configured: %s", configured))
+		if compat, err := bootassets.CheckKernel(configured, kernelFeatures(req.Policy)); err != nil {
+			appendCheck("kernel_compat", "warn", err.Error())
+		} else if err := compat.Err(configured); err != nil {
+			appendCheck("kernel_compat", "fail", err.Error())
+		} else if warnings := compat.Warnings(); len(warnings) > 0 {
+			appendCheck("kernel_compat", "warn", strings.Join(warnings, "; "))
+		} else {
+			appendCheck("kernel_compat", "pass", fmt.Sprintf("kernel %s has the features this build needs", cmp.Or(compat.Version, configured)))
+		}
 	}
 	if guestAgentPath, _, err := a.getGuestAgentBinary(); err != nil {
 		appendCheck("guest_agent_binary", "fail", err.Error())
@@ -556,6 +821,21 @@ func (a *Adapter) Doctor(_ context.Context, req backend.DoctorRequest) (*backend
 		appendCheck("mkfs_ext4", "pass", fmt.Sprintf("found mkfs.ext4 (%s) for OCI rootfs materialisation", mkfsPath))
 	}
 
+	hostCPU := readHostCPU()
+	cpuTemplateStatus, cpuTemplateMessage := checkCPUTemplate(req.CPUTemplate, hostCPU)
+	appendCheck("cpu_template", cpuTemplateStatus, cpuTemplateMessage)
+	smtStatus, smtMessage := checkSMT(req.SMT, hostCPU)
+	appendCheck("smt", smtStatus, smtMessage)
+	if err := nestedVirtSupport(hostCPU, os.ReadFile); err != nil {
+		status := "warn"
+		if req.Policy != nil && req.Policy.NestedVirtualization {
+			status = "fail"
+		}
+		appendCheck("nested_virtualization", status, fmt.Sprintf("policies cannot enable nested virtualization: %v", err))
+	} else {
+		appendCheck("nested_virtualization", "pass", "host KVM allows nested virtualization for policies that enable it")
+	}
+
 	if req.GuestPort == 0 {
 		appendCheck("vsock_port", "pass", fmt.Sprintf("using default guest vsock port %d", vsockexec.DefaultPort))
 	} else {
@@ -574,7 +854,10 @@ func (a *Adapter) Doctor(_ context.Context, req backend.DoctorRequest) (*backend
 	privilegedMode, privilegedHelperPath := resolvePrivilegedExecution(req.FirecrackerConfig)
 	appendCheck("network_privileged_mode", "pass", fmt.Sprintf("using privileged command mode %q", privilegedMode))
 
-	requiredCommands := []string{"ip", "iptables", "sysctl", "sudo"}
+	requiredCommands := []string{"ip", "iptables", "sysctl"}
+	if privilegedMode != privilegedModeCaps {
+		requiredCommands = append(requiredCommands, "sudo")
+	}
 	for _, cmd := range requiredCommands {
 		if _, err := exec.LookPath(cmd); err != nil {
 			appendCheck("network_cmd_"+cmd, "fail", fmt.Sprintf("missing required host command %q", cmd))
@@ -590,6 +873,11 @@ func (a *Adapter) Doctor(_ context.Context, req backend.DoctorRequest) (*backend
 			appendCheck("network_helper", "pass", fmt.Sprintf("using privileged helper %q", privilegedHelperPath))
 		}
 	}
+	if privilegedMode == privilegedModeCaps {
+		for _, check := range capHelperChecks(privilegedHelperPath) {
+			appendCheck(check.Name, check.Status, check.Message)
+		}
+	}
 
 	if err := runRootCommand(context.Background(), req.FirecrackerConfig, "true"); err != nil {
 		appendCheck("network_privileged_probe", "warn", fmt.Sprintf("privileged command probe failed: %v", err))
@@ -601,6 +889,15 @@ func (a *Adapter) Doctor(_ context.Context, req backend.DoctorRequest) (*backend
 	} else {
 		appendCheck("network_privileged_ip", "pass", "privileged ip command execution succeeded")
 	}
+	if out, err := rootCommandOutput(context.Background(), req.FirecrackerConfig, "iptables", "-S", "FORWARD"); err != nil {
+		appendCheck("host_firewall_forward", "warn", fmt.Sprintf("privileged iptables -S FORWARD failed: %v", err))
+	} else {
+		report.Checks = append(report.Checks, hostFirewallChecks(string(out))...)
+	}
+
+	if req.Deep {
+		report.Checks = append(report.Checks, a.doctorCanary(ctx, req)...)
+	}
 
 	return report, nil
 }
@@ -614,6 +911,9 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 		ExitCode:   1,
 	}
 
+	if a.faultsErr != nil {
+		return nil, a.faultsErr
+	}
 	if req.Policy == nil {
 		return nil, errors.New("missing compiled policy")
 	}
@@ -637,10 +937,10 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 		}
 		runDir = filepath.Join(baseDir, req.RunID)
 	}
-	if err := os.MkdirAll(runDir, 0o755); err != nil {
+	if err := rundir.Create(runDir, rundir.Manifest{RunID: req.RunID, Backend: a.Name(), SandboxID: req.SandboxID}); err != nil {
 		return nil, err
 	}
-	observationPath := filepath.Join(runDir, runObservabilityFile)
+	observationPath := filepath.Join(runDir, rundir.ObservabilityFile)
 	writeObservation := func() {
 		observation.TotalMS = time.Since(runStart).Milliseconds()
 		_ = writeJSON(observationPath, observation)
@@ -648,10 +948,10 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 	defer writeObservation()
 
 	if req.VCPUs <= 0 {
-		req.VCPUs = 1
+		req.VCPUs = backend.DefaultVCPUs
 	}
 	if req.MemoryMiB <= 0 {
-		req.MemoryMiB = 512
+		req.MemoryMiB = backend.DefaultMemoryMiB
 	}
 	if req.GuestCID == 0 {
 		req.GuestCID = randomGuestCID()
@@ -660,16 +960,16 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 		req.GuestPort = vsockexec.DefaultPort
 	}
 	if req.LaunchSeconds <= 0 {
-		req.LaunchSeconds = 30
+		req.LaunchSeconds = backend.DefaultLaunchSeconds
 	}
-	cmdPath := filepath.Join(runDir, "requested-command.json")
+	cmdPath := filepath.Join(runDir, rundir.RequestedCommandFile)
 	if err := writeJSON(cmdPath, req.Command); err != nil {
 		return nil, err
 	}
 
 	if !req.Launch {
 		observation.Phase = "plan"
-		planPath := filepath.Join(runDir, "plan.json")
+		planPath := filepath.Join(runDir, rundir.PlanFile)
 		plan := map[string]any{
 			"backend":      "firecracker",
 			"mode":         "plan-only",
@@ -695,7 +995,7 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 
 	binary := req.BinaryPath
 	if binary == "" {
-		binary = "firecracker"
+		binary = DefaultBinaryPath
 	}
 	firecrackerPath, err := exec.LookPath(binary)
 	if err != nil {
@@ -703,13 +1003,13 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 	}
 	observation.Phase = "launch"
 
-	kernelPath, kernelNotice, err := a.resolveKernelPath(ctx, req.KernelImagePath)
+	kernelPath, kernelNotice, err := a.resolveKernelPath(ctx, req.KernelImagePath, req.KernelSource, req.Policy)
 	if err != nil {
 		return nil, err
 	}
-	logRunNotice(a.Name(), req.RunID, kernelNotice)
+	a.logRunNotice(ctx, req.RunID, kernelNotice)
 
-	imageArtifact, err := a.ensureImageArtifact(ctx, req.Policy.ImageRef)
+	imageArtifact, err := a.resolveSandboxImage(ctx, req.Namespace, req.Policy, req.FirecrackerConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -731,22 +1031,28 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 	}
 
 	vmRootFSPath := filepath.Join(runDir, "rootfs-ephemeral.ext4")
-	defer os.Remove(vmRootFSPath)
+	scratch := a.scratchFiles(req.FirecrackerConfig)
+	var vmExited <-chan struct{} // set once firecracker starts
+	defer func() { scratch.releaseRootFS(vmRootFSPath, req.ScratchFiles, vmExited) }()
 	rootfsCopyStart := time.Now()
-	if err := copyFile(rootfsPath, vmRootFSPath); err != nil {
+	if err := scratch.prepareRootFS(rootfsPath, vmRootFSPath); err != nil {
 		observation.RootFSCopyMS = durationMillisCeil(time.Since(rootfsCopyStart))
 		return nil, fmt.Errorf("prepare per-run rootfs: %w", err)
 	}
+	if err := growRootFS(ctx, vmRootFSPath, req.DiskMiB, resize2fs); err != nil {
+		observation.RootFSCopyMS = durationMillisCeil(time.Since(rootfsCopyStart))
+		return nil, err
+	}
 	observation.RootFSCopyMS = durationMillisCeil(time.Since(rootfsCopyStart))
 
 	networkSetupStart := time.Now()
-	networkRunCommand := func(ctx context.Context, args ...string) error {
+	networkRunCommand := a.withRootCommandFaults(func(ctx context.Context, args ...string) error {
 		return runRootCommand(ctx, req.FirecrackerConfig, args...)
-	}
+	})
 	networkRunBatch := func(ctx context.Context, commands [][]string) error {
 		return runRootCommandBatch(ctx, req.FirecrackerConfig, commands)
 	}
-	networkCfg, cleanupNetwork, err := setupHostNetwork(ctx, req.RunID, req.Policy.Allow, 0, networkRunCommand, networkRunBatch)
+	networkCfg, cleanupNetwork, err := a.setupHostNetwork(ctx, req.RunID, req.FirecrackerConfig, req.Policy.Allow, nil, 0, networkRunCommand, networkRunBatch)
 	if err != nil {
 		return nil, fmt.Errorf("setup host network: %w", err)
 	}
@@ -755,6 +1061,7 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 	observation.NetworkTap = networkCfg.TapName
 	observation.NetworkGuestIP = networkCfg.GuestIP
 	observation.NetworkHostIP = networkCfg.HostIP
+	_ = writeJSON(filepath.Join(runDir, rundir.ResolutionsFile), networkCfg.Resolutions)
 	cleanupMeasured := func() {
 		cleanupStart := time.Now()
 		cleanupNetwork()
@@ -762,15 +1069,19 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 	}
 	defer cleanupMeasured()
 
-	vsockPath := filepath.Join(runDir, "vsock.sock")
+	socketDir := req.FirecrackerConfig.SocketDir
+	defer rundir.RemoveSockets(runDir, socketDir)
+	vsockPath, err := rundir.SocketPath(runDir, socketDir, "vsock.sock")
+	if err != nil {
+		return nil, err
+	}
 	dockerBootArgs := dockerServiceBootArgs(req.Policy, req.FirecrackerConfig)
 	fcCfg := firecrackerConfig{
 		BootSource: bootSource{
 			KernelImagePath: kernelPath,
 			BootArgs: fmt.Sprintf(
-				"console=ttyS0 reboot=k panic=1 pci=off init=/sbin/cleanroom-init random.trust_cpu=on cleanroom_guest_ip=%s cleanroom_guest_gw=%s cleanroom_guest_mask=24 cleanroom_guest_dns=1.1.1.1 cleanroom_guest_port=%d %s",
-				networkCfg.GuestIP,
-				networkCfg.HostIP,
+				"console=ttyS0 reboot=k panic=1 pci=off init=/sbin/cleanroom-init random.trust_cpu=on %s cleanroom_guest_port=%d %s",
+				guestNetworkBootArgs(networkCfg),
 				req.GuestPort,
 				dockerBootArgs,
 			),
@@ -784,9 +1095,10 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 			},
 		},
 		MachineConfig: machineConfig{
-			VCPUCount:  req.VCPUs,
-			MemSizeMiB: req.MemoryMiB,
-			SMT:        false,
+			VCPUCount:   req.VCPUs,
+			MemSizeMiB:  req.MemoryMiB,
+			SMT:         req.SMT,
+			CPUTemplate: req.CPUTemplate,
 		},
 		Vsock: &vsockConfig{
 			VsockID:  "cleanroom-vsock",
@@ -795,20 +1107,40 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 		},
 		NetworkInterfaces: []networkInterface{
 			{
-				IfaceID:     "eth0",
-				HostDevName: networkCfg.TapName,
-				GuestMac:    guestMACFromRunID(req.RunID),
+				IfaceID:       "eth0",
+				HostDevName:   networkCfg.TapName,
+				GuestMac:      guestMACFromRunID(req.RunID),
+				RxRateLimiter: bandwidthLimiter(req.IngressMbps),
+				TxRateLimiter: bandwidthLimiter(req.EgressMbps),
 			},
 		},
 		Entropy: &entropyConfig{},
 	}
 
+	if err := applyNestedVirtualization(&fcCfg, req.Policy, runDir, readHostCPU()); err != nil {
+		return nil, err
+	}
+	cleanupScratch, err := applyReadOnlyRootFS(ctx, &fcCfg, req.Policy, runDir, createScratchDisk)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanupScratch()
+	applyDriveRateLimits(&fcCfg, req.DiskIOPS, req.DiskMiBps)
+	// Metrics are best effort; a run goes ahead without them.
+	metrics, metricsPath, _ := openVMMetrics(runDir)
+	defer metrics.close()
+	if metrics != nil {
+		fcCfg.Metrics = &metricsConfig{MetricsPath: metricsPath}
+	}
 	cfgPath := filepath.Join(runDir, "firecracker-config.json")
 	if err := writeJSON(cfgPath, fcCfg); err != nil {
 		return nil, err
 	}
 
-	apiSocket := filepath.Join(runDir, "firecracker.sock")
+	apiSocket, err := rundir.SocketPath(runDir, socketDir, "firecracker.sock")
+	if err != nil {
+		return nil, err
+	}
 	stdoutPath := filepath.Join(runDir, "firecracker.stdout.log")
 	stderrPath := filepath.Join(runDir, "firecracker.stderr.log")
 
@@ -827,7 +1159,7 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 	launchCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	fcCmd := exec.CommandContext(launchCtx, firecrackerPath, "--api-sock", apiSocket, "--config-file", cfgPath)
+	fcCmd := firecrackerCommand(launchCtx, networkCfg, firecrackerPath, "--api-sock", apiSocket, "--config-file", cfgPath)
 	fcCmd.Stdout = stdoutFile
 	fcCmd.Stderr = stderrFile
 
@@ -840,6 +1172,7 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 	vmProcessStart := time.Now()
 
 	processExited := make(chan struct{})
+	vmExited = processExited
 	var (
 		processExitMu  sync.RWMutex
 		processExitErr error
@@ -865,24 +1198,56 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 	defer bootCancel()
 
 	guestReq := vsockexec.ExecRequest{
-		Command: req.Command,
-		TTY:     req.TTY,
+		Command:        req.Command,
+		TTY:            req.TTY,
+		Banner:         req.ConsoleBanner(),
+		SessionLimits:  req.ConsoleLimits(),
+		Limits:         req.Limits.Guest(),
+		KeepBackground: req.KeepBackgroundProcesses,
+		FreshHome:      req.FreshHome,
+		Launcher:       req.Launcher,
+		Stdin:          req.Stdin,
+		CaptureChanges: req.CaptureChanges,
+		RequestID:      logging.RequestID(ctx),
+		Compression:    vsockexec.CompressionZstd,
 	}
 	seed := make([]byte, 64)
 	if _, err := cryptorand.Read(seed); err == nil {
 		guestReq.EntropySeed = seed
 	}
+	if err := a.injectVsockDialFault(bootCtx); err != nil {
+		return nil, err
+	}
+	stopKill := a.injectVMKill(fcCmd)
 	guestResult, guestTiming, err := runGuestCommand(bootCtx, ctx, processExited, processExitErrFn, vsockPath, req.GuestPort, guestReq, stream)
+	stopKill()
 	if err != nil {
-		return nil, err
+		if failure := guestFailureFromConsole(runDir, 0, req.MemoryMiB, err); failure != nil && ctx.Err() == nil {
+			err = failure
+		}
+		return nil, withDiagnostics(ctx, err, runDir, vmDiagnostics{
+			LogDir:  runDir,
+			Exited:  processExited,
+			ExitErr: processExitErrFn,
+			Network: networkCfg,
+			Exec: guestExecCollector(func(ctx context.Context, execReq vsockexec.ExecRequest, stream backend.OutputStream) (vsockexec.ExecResponse, error) {
+				resp, _, err := runGuestCommand(ctx, ctx, processExited, processExitErrFn, vsockPath, req.GuestPort, execReq, stream)
+				return resp, err
+			}),
+		})
 	}
 	vmReady := guestTiming.AgentReadyAt.Sub(vmProcessStart)
 	if vmReady < 0 {
 		vmReady = 0
 	}
 	observation.VMReadyMS = vmReady.Milliseconds()
+	observation.SocketReadyMS = max(guestTiming.SocketReadyAt.Sub(vmProcessStart), 0).Milliseconds()
 	observation.VsockWaitMS = guestTiming.WaitForAgent.Milliseconds()
 	observation.GuestExecMS = guestTiming.CommandRun.Milliseconds()
+	if metrics != nil {
+		stats := metrics.flush(ctx, newAPIClient(apiSocket))
+		observation.VMStats = &stats
+	}
 	if guestResult.Error != "" && strings.TrimSpace(guestResult.Stderr) == "" {
 		guestResult.Stderr = guestResult.Error + "\n"
 	}
@@ -891,6 +1256,10 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 	if guestResult.Error != "" {
 		message = runResultMessage("firecracker launch and guest command execution completed with guest-side error detail: " + guestResult.Error)
 	}
+	failure := commandGuestFailure(runDir, 0, req.MemoryMiB, guestResult.Metadata)
+	if failure != nil {
+		message = failure.Error()
+	}
 
 	observation.PlanPath = cfgPath
 	observation.RunDir = runDir
@@ -900,19 +1269,38 @@ func (a *Adapter) run(ctx context.Context, req backend.RunRequest, stream backen
 	timingSummary := fmt.Sprintf("timings boot=%s vsock_wait=%s exec=%s", vmReady, guestTiming.WaitForAgent, guestTiming.CommandRun)
 
 	return &backend.RunResult{
-		RunID:       req.RunID,
-		ExitCode:    guestResult.ExitCode,
-		LaunchedVM:  true,
-		PlanPath:    cfgPath,
-		RunDir:      runDir,
-		ImageRef:    imageArtifact.Ref,
-		ImageDigest: imageArtifact.Digest,
-		Message:     message + "; " + timingSummary,
-		Stdout:      guestResult.Stdout,
-		Stderr:      guestResult.Stderr,
+		RunID:        req.RunID,
+		ExitCode:     guestResult.ExitCode,
+		LaunchedVM:   true,
+		PlanPath:     cfgPath,
+		RunDir:       runDir,
+		ImageRef:     imageArtifact.Ref,
+		ImageDigest:  imageArtifact.Digest,
+		Message:      message + "; " + timingSummary,
+		Stdout:       guestResult.Stdout,
+		Stderr:       guestResult.Stderr,
+		ExitMetadata: guestExitMetadata(guestResult.Metadata),
+		Failure:      failure,
+		Timings:      observation.runTimings(time.Since(runStart)),
 	}, nil
 }
 
+// guestExitMetadata converts the guest agent's exit metadata, which is nil
+// for agents that predate it.
+func guestExitMetadata(m *vsockexec.ExitMetadata) *backend.ExitMetadata {
+	if m == nil {
+		return nil
+	}
+	return &backend.ExitMetadata{
+		UserCPU:     time.Duration(m.UserCPUMillis) * time.Millisecond,
+		SystemCPU:   time.Duration(m.SystemCPUMillis) * time.Millisecond,
+		Wall:        time.Duration(m.WallMillis) * time.Millisecond,
+		MaxRSSBytes: m.MaxRSSBytes,
+		Signal:      m.Signal,
+		SignalName:  m.SignalName,
+	}
+}
+
 type firecrackerRunObservation struct {
 	RunID              string `json:"run_id"`
 	Backend            string `json:"backend"`
@@ -931,21 +1319,47 @@ type firecrackerRunObservation struct {
 	PolicyResolveMS    int64  `json:"policy_resolve_ms,omitempty"`
 	RootFSCopyMS       int64  `json:"rootfs_copy_ms,omitempty"`
 	FirecrackerStartMS int64  `json:"firecracker_start_ms,omitempty"`
-	NetworkSetupMS     int64  `json:"network_setup_ms,omitempty"`
-	VMReadyMS          int64  `json:"vm_ready_ms,omitempty"`
-	VsockWaitMS        int64  `json:"vsock_wait_ms,omitempty"`
-	GuestExecMS        int64  `json:"guest_exec_ms,omitempty"`
-	CleanupMS          int64  `json:"cleanup_ms,omitempty"`
-	TotalMS            int64  `json:"total_ms,omitempty"`
+	// SocketReadyMS is how long after it started Firecracker created the
+	// vsock socket.
+	SocketReadyMS  int64 `json:"socket_ready_ms,omitempty"`
+	NetworkSetupMS int64 `json:"network_setup_ms,omitempty"`
+	VMReadyMS      int64 `json:"vm_ready_ms,omitempty"`
+	VsockWaitMS    int64 `json:"vsock_wait_ms,omitempty"`
+	GuestExecMS    int64 `json:"guest_exec_ms,omitempty"`
+	CleanupMS      int64 `json:"cleanup_ms,omitempty"`
+	TotalMS        int64 `json:"total_ms,omitempty"`
+	// VMStats are the VM's device and vCPU counters over the run, or over
+	// the execution for a command in a persistent sandbox.
+	VMStats *backend.VMStats `json:"vm_stats,omitempty"`
+}
+
+// runTimings reports the phase timings recorded so far. Cleanup runs after
+// the result is returned, so it is only in the observability file.
+func (o firecrackerRunObservation) runTimings(total time.Duration) *backend.RunTimings {
+	ms := func(v int64) time.Duration { return time.Duration(v) * time.Millisecond }
+	return &backend.RunTimings{
+		PolicyResolve: ms(o.PolicyResolveMS),
+		RootFSCopy:    ms(o.RootFSCopyMS),
+		VMStart:       ms(o.FirecrackerStartMS),
+		NetworkSetup:  ms(o.NetworkSetupMS),
+		VMReady:       ms(o.VMReadyMS),
+		VsockWait:     ms(o.VsockWaitMS),
+		GuestExec:     ms(o.GuestExecMS),
+		Total:         total,
+		ImageCacheHit: o.ImageCacheHit,
+	}
 }
 
 type firecrackerConfig struct {
 	BootSource        bootSource         `json:"boot-source"`
 	Drives            []drive            `json:"drives"`
 	MachineConfig     machineConfig      `json:"machine-config"`
+	CPUConfig         string             `json:"cpu-config,omitempty"`
 	Vsock             *vsockConfig       `json:"vsock,omitempty"`
 	NetworkInterfaces []networkInterface `json:"network-interfaces,omitempty"`
 	Entropy           *entropyConfig     `json:"entropy,omitempty"`
+	Balloon           *balloonConfig     `json:"balloon,omitempty"`
+	Metrics           *metricsConfig     `json:"metrics,omitempty"`
 }
 
 type bootSource struct {
@@ -954,16 +1368,18 @@ type bootSource struct {
 }
 
 type drive struct {
-	DriveID      string `json:"drive_id"`
-	PathOnHost   string `json:"path_on_host"`
-	IsRootDevice bool   `json:"is_root_device"`
-	IsReadOnly   bool   `json:"is_read_only"`
+	DriveID      string       `json:"drive_id"`
+	PathOnHost   string       `json:"path_on_host"`
+	IsRootDevice bool         `json:"is_root_device"`
+	IsReadOnly   bool         `json:"is_read_only"`
+	RateLimiter  *rateLimiter `json:"rate_limiter,omitempty"`
 }
 
 type machineConfig struct {
-	VCPUCount  int64 `json:"vcpu_count"`
-	MemSizeMiB int64 `json:"mem_size_mib"`
-	SMT        bool  `json:"smt"`
+	VCPUCount   int64  `json:"vcpu_count"`
+	MemSizeMiB  int64  `json:"mem_size_mib"`
+	SMT         bool   `json:"smt"`
+	CPUTemplate string `json:"cpu_template,omitempty"`
 }
 
 type vsockConfig struct {
@@ -973,13 +1389,63 @@ type vsockConfig struct {
 }
 
 type networkInterface struct {
-	IfaceID     string `json:"iface_id"`
-	HostDevName string `json:"host_dev_name"`
-	GuestMac    string `json:"guest_mac,omitempty"`
+	IfaceID       string       `json:"iface_id"`
+	HostDevName   string       `json:"host_dev_name"`
+	GuestMac      string       `json:"guest_mac,omitempty"`
+	RxRateLimiter *rateLimiter `json:"rx_rate_limiter,omitempty"`
+	TxRateLimiter *rateLimiter `json:"tx_rate_limiter,omitempty"`
+}
+
+type rateLimiter struct {
+	Bandwidth *tokenBucket `json:"bandwidth,omitempty"`
+	Ops       *tokenBucket `json:"ops,omitempty"`
+}
+
+// tokenBucket holds Size tokens and refills them every RefillTime
+// milliseconds.
+type tokenBucket struct {
+	Size       int64 `json:"size"`
+	RefillTime int64 `json:"refill_time"`
+}
+
+// bandwidthLimiter caps a network device at mbps megabits per second by
+// refilling a second's worth of bytes every second. It returns nil, leaving
+// the device unlimited, when mbps is zero.
+func bandwidthLimiter(mbps int64) *rateLimiter {
+	if mbps <= 0 {
+		return nil
+	}
+	return &rateLimiter{Bandwidth: &tokenBucket{Size: mbps * 1_000_000 / 8, RefillTime: 1000}}
+}
+
+// applyDriveRateLimits caps every drive, including scratch disks, at iops
+// operations and mibps MiB per second each. A zero limit leaves that
+// dimension unlimited.
+func applyDriveRateLimits(fcCfg *firecrackerConfig, iops, mibps int64) {
+	if iops <= 0 && mibps <= 0 {
+		return
+	}
+	for i := range fcCfg.Drives {
+		limiter := &rateLimiter{}
+		if iops > 0 {
+			limiter.Ops = &tokenBucket{Size: iops, RefillTime: 1000}
+		}
+		if mibps > 0 {
+			limiter.Bandwidth = &tokenBucket{Size: mibps << 20, RefillTime: 1000}
+		}
+		fcCfg.Drives[i].RateLimiter = limiter
+	}
 }
 
 type entropyConfig struct{}
 
+// balloonConfig adds a balloon device that starts deflated, so host memory
+// can later be reclaimed from a running sandbox through the API socket.
+type balloonConfig struct {
+	AmountMiB    int64 `json:"amount_mib"`
+	DeflateOnOOM bool  `json:"deflate_on_oom"`
+}
+
 func writeJSON(path string, v any) error {
 	b, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
@@ -1107,6 +1573,11 @@ func (a *Adapter) ensurePreparedRuntimeRootFS(ctx context.Context, cfg backend.F
 		}
 		return "", fmt.Errorf("store prepared runtime rootfs %q: %w", preparedPath, err)
 	}
+	if recorder, ok := a.imageManager.(preparedRecorder); ok && image.Digest != "" {
+		// Tracking is best effort; an untracked file is still used, it is
+		// just left behind by image rm and image save.
+		_ = recorder.RecordPrepared(ctx, image.Digest, preparedPath)
+	}
 	return preparedPath, nil
 }
 
@@ -1234,7 +1705,7 @@ func hashFileSHA256(path string) (string, error) {
 
 func (a *Adapter) getImageManager() (imageEnsurer, error) {
 	if a.newImageManager == nil {
-		a.newImageManager = defaultImageManagerFactory
+		a.newImageManager = a.defaultImageManager
 	}
 	a.imageManagerOnce.Do(func() {
 		a.imageManager, a.imageManagerErr = a.newImageManager()
@@ -1248,7 +1719,7 @@ func (a *Adapter) getImageManager() (imageEnsurer, error) {
 	return a.imageManager, nil
 }
 
-func (a *Adapter) launchSandboxVM(ctx context.Context, sandboxID string, compiled *policy.CompiledPolicy, cfg backend.FirecrackerConfig) (*sandboxInstance, error) {
+func (a *Adapter) launchSandboxVM(ctx context.Context, sandboxID, namespace string, compiled *policy.CompiledPolicy, cfg backend.FirecrackerConfig, pinned []backend.HostResolution) (*sandboxInstance, error) {
 	if compiled == nil {
 		return nil, errors.New("missing compiled policy")
 	}
@@ -1260,10 +1731,10 @@ func (a *Adapter) launchSandboxVM(ctx context.Context, sandboxID string, compile
 	}
 
 	if cfg.VCPUs <= 0 {
-		cfg.VCPUs = 1
+		cfg.VCPUs = backend.DefaultVCPUs
 	}
 	if cfg.MemoryMiB <= 0 {
-		cfg.MemoryMiB = 512
+		cfg.MemoryMiB = backend.DefaultMemoryMiB
 	}
 	if cfg.GuestCID == 0 {
 		cfg.GuestCID = randomGuestCID()
@@ -1272,23 +1743,23 @@ func (a *Adapter) launchSandboxVM(ctx context.Context, sandboxID string, compile
 		cfg.GuestPort = vsockexec.DefaultPort
 	}
 	if cfg.LaunchSeconds <= 0 {
-		cfg.LaunchSeconds = 30
+		cfg.LaunchSeconds = backend.DefaultLaunchSeconds
 	}
 
 	binary := cfg.BinaryPath
 	if binary == "" {
-		binary = "firecracker"
+		binary = DefaultBinaryPath
 	}
 	firecrackerPath, err := exec.LookPath(binary)
 	if err != nil {
 		return nil, fmt.Errorf("firecracker binary not found (%q): %w", binary, err)
 	}
-	kernelPath, _, err := a.resolveKernelPath(ctx, cfg.KernelImagePath)
+	kernelPath, _, err := a.resolveKernelPath(ctx, cfg.KernelImagePath, cfg.KernelSource, compiled)
 	if err != nil {
 		return nil, err
 	}
 
-	imageArtifact, err := a.ensureImageArtifact(ctx, compiled.ImageRef)
+	imageArtifact, err := a.resolveSandboxImage(ctx, namespace, compiled, cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -1324,10 +1795,13 @@ func (a *Adapter) launchSandboxVM(ctx context.Context, sandboxID string, compile
 	if err := copyFile(rootfsPath, vmRootFSPath); err != nil {
 		return nil, fmt.Errorf("prepare persistent rootfs: %w", err)
 	}
+	if err := growRootFS(ctx, vmRootFSPath, cfg.DiskMiB, resize2fs); err != nil {
+		return nil, err
+	}
 
-	networkRunCommand := func(ctx context.Context, args ...string) error {
+	networkRunCommand := a.withRootCommandFaults(func(ctx context.Context, args ...string) error {
 		return runRootCommand(ctx, cfg, args...)
-	}
+	})
 	networkRunBatch := func(ctx context.Context, commands [][]string) error {
 		return runRootCommandBatch(ctx, cfg, commands)
 	}
@@ -1338,37 +1812,48 @@ func (a *Adapter) launchSandboxVM(ctx context.Context, sandboxID string, compile
 			gwPort = gateway.DefaultPort
 		}
 	}
-	networkCfg, cleanupNetwork, err := setupHostNetwork(ctx, sandboxID, compiled.Allow, gwPort, networkRunCommand, networkRunBatch)
+	networkCfg, cleanupNetwork, err := a.setupHostNetwork(ctx, sandboxID, cfg, compiled.Allow, pinned, gwPort, networkRunCommand, networkRunBatch)
 	if err != nil {
 		_ = os.Remove(vmRootFSPath)
 		return nil, fmt.Errorf("setup host network: %w", err)
 	}
+	_ = writeJSON(filepath.Join(runDir, rundir.ResolutionsFile), networkCfg.Resolutions)
 
 	if a.GatewayRegistry != nil {
-		if err := a.GatewayRegistry.Register(networkCfg.GuestIP, sandboxID, compiled); err != nil {
+		if err := a.GatewayRegistry.Register(networkCfg.SourceIP, sandboxID, compiled); err != nil {
 			cleanupNetwork()
 			_ = os.Remove(vmRootFSPath)
 			return nil, fmt.Errorf("register sandbox in gateway: %w", err)
 		}
 	}
 
+	// Goroutines started from here on belong to the sandbox.
+	defer a.labelSandboxGoroutines(ctx, sandboxID)()
+
+	// Metrics are best effort; the sandbox starts without them.
+	metrics, metricsPath, _ := openVMMetrics(runDir)
 	cleanupAll := func() {
+		metrics.close()
 		if a.GatewayRegistry != nil {
-			a.GatewayRegistry.Release(networkCfg.GuestIP)
+			a.GatewayRegistry.Release(networkCfg.SourceIP)
 		}
 		cleanupNetwork()
 		_ = os.Remove(vmRootFSPath)
+		rundir.RemoveSockets(runDir, cfg.SocketDir)
 	}
 
-	vsockPath := filepath.Join(runDir, "vsock.sock")
+	vsockPath, err := rundir.SocketPath(runDir, cfg.SocketDir, "vsock.sock")
+	if err != nil {
+		cleanupAll()
+		return nil, err
+	}
 	dockerBootArgs := dockerServiceBootArgs(compiled, cfg)
 	fcCfg := firecrackerConfig{
 		BootSource: bootSource{
 			KernelImagePath: kernelPath,
 			BootArgs: fmt.Sprintf(
-				"console=ttyS0 reboot=k panic=1 pci=off init=/sbin/cleanroom-init random.trust_cpu=on cleanroom_guest_ip=%s cleanroom_guest_gw=%s cleanroom_guest_mask=24 cleanroom_guest_dns=1.1.1.1 cleanroom_guest_port=%d %s",
-				networkCfg.GuestIP,
-				networkCfg.HostIP,
+				"console=ttyS0 reboot=k panic=1 pci=off init=/sbin/cleanroom-init random.trust_cpu=on %s cleanroom_guest_port=%d %s",
+				guestNetworkBootArgs(networkCfg),
 				cfg.GuestPort,
 				dockerBootArgs,
 			),
@@ -1380,9 +1865,10 @@ func (a *Adapter) launchSandboxVM(ctx context.Context, sandboxID string, compile
 			IsReadOnly:   false,
 		}},
 		MachineConfig: machineConfig{
-			VCPUCount:  cfg.VCPUs,
-			MemSizeMiB: cfg.MemoryMiB,
-			SMT:        false,
+			VCPUCount:   cfg.VCPUs,
+			MemSizeMiB:  cfg.MemoryMiB,
+			SMT:         cfg.SMT,
+			CPUTemplate: cfg.CPUTemplate,
 		},
 		Vsock: &vsockConfig{
 			VsockID:  "cleanroom-vsock",
@@ -1390,20 +1876,40 @@ func (a *Adapter) launchSandboxVM(ctx context.Context, sandboxID string, compile
 			UDSPath:  vsockPath,
 		},
 		NetworkInterfaces: []networkInterface{{
-			IfaceID:     "eth0",
-			HostDevName: networkCfg.TapName,
-			GuestMac:    guestMACFromRunID(sandboxID),
+			IfaceID:       "eth0",
+			HostDevName:   networkCfg.TapName,
+			GuestMac:      guestMACFromRunID(sandboxID),
+			RxRateLimiter: bandwidthLimiter(cfg.IngressMbps),
+			TxRateLimiter: bandwidthLimiter(cfg.EgressMbps),
 		}},
 		Entropy: &entropyConfig{},
+		Balloon: &balloonConfig{DeflateOnOOM: true},
 	}
 
+	if err := applyNestedVirtualization(&fcCfg, compiled, runDir, readHostCPU()); err != nil {
+		cleanupAll()
+		return nil, err
+	}
+	// Scratch disks live in runDir, which is removed with the sandbox.
+	if _, err := applyReadOnlyRootFS(ctx, &fcCfg, compiled, runDir, createScratchDisk); err != nil {
+		cleanupAll()
+		return nil, err
+	}
+	applyDriveRateLimits(&fcCfg, cfg.DiskIOPS, cfg.DiskMiBps)
+	if metrics != nil {
+		fcCfg.Metrics = &metricsConfig{MetricsPath: metricsPath}
+	}
 	configPath := filepath.Join(runDir, "firecracker-config.json")
 	if err := writeJSON(configPath, fcCfg); err != nil {
 		cleanupAll()
 		return nil, err
 	}
 
-	apiSocket := filepath.Join(runDir, "firecracker.sock")
+	apiSocket, err := rundir.SocketPath(runDir, cfg.SocketDir, "firecracker.sock")
+	if err != nil {
+		cleanupAll()
+		return nil, err
+	}
 	stdoutPath := filepath.Join(runDir, "firecracker.stdout.log")
 	stderrPath := filepath.Join(runDir, "firecracker.stderr.log")
 	stdoutFile, err := os.Create(stdoutPath)
@@ -1419,7 +1925,7 @@ func (a *Adapter) launchSandboxVM(ctx context.Context, sandboxID string, compile
 	}
 	defer stderrFile.Close()
 
-	fcCmd := exec.Command(firecrackerPath, "--api-sock", apiSocket, "--config-file", configPath)
+	fcCmd := firecrackerCommand(context.Background(), networkCfg, firecrackerPath, "--api-sock", apiSocket, "--config-file", configPath)
 	fcCmd.Stdout = stdoutFile
 	fcCmd.Stderr = stderrFile
 	if err := fcCmd.Start(); err != nil {
@@ -1439,11 +1945,29 @@ func (a *Adapter) launchSandboxVM(ctx context.Context, sandboxID string, compile
 		CommandTimeout: cfg.LaunchSeconds,
 		HostIP:         networkCfg.HostIP,
 		GuestIP:        networkCfg.GuestIP,
+		SourceIP:       networkCfg.SourceIP,
+		TapName:        networkCfg.TapName,
+		Namespace:      networkCfg.Namespace,
+		VCPUs:          cfg.VCPUs,
+		MemoryMiB:      cfg.MemoryMiB,
+		DiskMiB:        cfg.DiskMiB,
+		KernelVersion:  bootassets.KernelVersion(kernelPath),
+		AgentHash:      a.guestAgentHash, // resolved while preparing the rootfs
+		Resolutions:    networkCfg.Resolutions,
 		fcCmd:          fcCmd,
+		api:            newAPIClient(apiSocket),
+		metrics:        metrics,
 		exitedCh:       make(chan struct{}),
 		cleanupNetwork: cleanupNetwork,
+		socketDir:      cfg.SocketDir,
 		vmRootFSPath:   vmRootFSPath,
 	}
+	if a.LeakCheck {
+		instance.listRules = func(ctx context.Context, table, chain string) (string, error) {
+			out, err := rootCommandOutput(ctx, cfg, "iptables", "-t", table, "-S", chain)
+			return string(out), err
+		}
+	}
 	go func() {
 		err := fcCmd.Wait()
 		instance.setExited(err)
@@ -1452,6 +1976,11 @@ func (a *Adapter) launchSandboxVM(ctx context.Context, sandboxID string, compile
 
 	bootCtx, cancel := context.WithTimeout(ctx, time.Duration(cfg.LaunchSeconds)*time.Second)
 	defer cancel()
+	if err := a.injectVsockDialFault(bootCtx); err != nil {
+		stopVM(fcCmd, instance.exitedCh)
+		cleanupAll()
+		return nil, err
+	}
 	conn, err := dialVsockUntilReady(bootCtx, instance.exitedCh, instance.exitedErrOrNil, vsockPath, cfg.GuestPort)
 	if err != nil {
 		stopVM(fcCmd, instance.exitedCh)
@@ -1463,12 +1992,36 @@ func (a *Adapter) launchSandboxVM(ctx context.Context, sandboxID string, compile
 	return instance, nil
 }
 
-func (a *Adapter) resolveKernelPath(ctx context.Context, configuredPath string) (path, notice string, err error) {
-	resolved, err := bootassets.ResolveKernelPathForHost(ctx, a.Name(), configuredPath)
+// resolveKernelPath finds the guest kernel and checks it against the
+// features this build needs, so an incompatible kernel fails here rather
+// than as a vsock dial timeout after boot.
+func (a *Adapter) resolveKernelPath(ctx context.Context, configuredPath string, source bootassets.Source, compiled *policy.CompiledPolicy) (path, notice string, err error) {
+	resolved, err := bootassets.ResolveKernelPathForHost(ctx, a.Name(), configuredPath, source)
+	if err != nil {
+		return "", "", err
+	}
+	if resolved.Managed {
+		// Managed kernels are part of the compatibility matrix already.
+		return resolved.Path, resolved.Notice, nil
+	}
+	compat, err := bootassets.CheckKernel(resolved.Path, kernelFeatures(compiled))
 	if err != nil {
 		return "", "", err
 	}
-	return resolved.Path, resolved.Notice, nil
+	if err := compat.Err(resolved.Path); err != nil {
+		return "", "", err
+	}
+	notices := append([]string{resolved.Notice}, compat.Warnings()...)
+	return resolved.Path, strings.TrimSpace(strings.Join(notices, "; ")), nil
+}
+
+// kernelFeatures lists the kernel features a launch with compiled needs.
+func kernelFeatures(compiled *policy.CompiledPolicy) []bootassets.KernelFeature {
+	features := bootassets.RequiredKernelFeatures
+	if compiled != nil && compiled.NestedVirtualization {
+		features = append(slices.Clone(features), bootassets.NestedVirtualizationFeature)
+	}
+	return features
 }
 
 func sandboxRuntimeBaseDir() (string, error) {
@@ -1484,10 +2037,15 @@ func (s *sandboxInstance) shutdown() {
 		return
 	}
 	stopVM(s.fcCmd, s.exitedCh)
+	s.metrics.close()
+	if s.cleanupGroup != nil {
+		s.cleanupGroup()
+	}
 	if s.cleanupNetwork != nil {
 		s.cleanupNetwork()
 	}
 	if strings.TrimSpace(s.RunDir) != "" {
+		rundir.RemoveSockets(s.RunDir, s.socketDir)
 		_ = os.RemoveAll(s.RunDir)
 		return
 	}
@@ -1523,21 +2081,28 @@ func runResultMessage(base string) string {
 }
 
 type guestExecTiming struct {
-	WaitForAgent time.Duration
-	AgentReadyAt time.Time
-	CommandRun   time.Duration
+	// SocketReadyAt is when the VM's vsock socket was found to exist.
+	SocketReadyAt time.Time
+	WaitForAgent  time.Duration
+	AgentReadyAt  time.Time
+	CommandRun    time.Duration
 }
 
 func runGuestCommand(bootCtx context.Context, execCtx context.Context, processExited <-chan struct{}, processExitErr func() error, vsockPath string, guestPort uint32, req vsockexec.ExecRequest, stream backend.OutputStream) (vsockexec.ExecResponse, guestExecTiming, error) {
 	waitStart := time.Now()
+	if err := waitForPath(bootCtx, processExited, vsockPath); err != nil {
+		return vsockexec.ExecResponse{}, guestExecTiming{}, vsockNotReadyError(bootCtx, processExitErr, vsockPath)
+	}
+	socketReadyAt := time.Now()
 	conn, err := dialVsockUntilReady(bootCtx, processExited, processExitErr, vsockPath, guestPort)
 	if err != nil {
 		return vsockexec.ExecResponse{}, guestExecTiming{}, err
 	}
 	readyAt := time.Now()
 	timing := guestExecTiming{
-		WaitForAgent: readyAt.Sub(waitStart),
-		AgentReadyAt: readyAt,
+		SocketReadyAt: socketReadyAt,
+		WaitForAgent:  readyAt.Sub(waitStart),
+		AgentReadyAt:  readyAt,
 	}
 	defer conn.Close()
 	if dl, ok := execCtx.Deadline(); ok {
@@ -1563,10 +2128,15 @@ func runGuestCommand(bootCtx context.Context, execCtx context.Context, processEx
 	if stream.OnAttach != nil {
 		stream.OnAttach(backend.AttachIO{
 			WriteStdin: func(data []byte) error {
-				return inputSender.Send(vsockexec.ExecInputFrame{
-					Type: "stdin",
-					Data: data,
-				})
+				for _, frame := range vsockexec.StdinFrames(data) {
+					if err := inputSender.Send(frame); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			CloseStdin: func() error {
+				return inputSender.Send(vsockexec.ExecInputFrame{Type: "eof"})
 			},
 			ResizeTTY: func(cols, rows uint32) error {
 				return inputSender.Send(vsockexec.ExecInputFrame{
@@ -1577,7 +2147,7 @@ func runGuestCommand(bootCtx context.Context, execCtx context.Context, processEx
 			},
 		})
 	}
-	if !req.TTY {
+	if !req.TTY && !req.Stdin {
 		// Non-TTY commands don't expect interactive stdin. Send eof
 		// immediately so the guest process sees stdin EOF rather than
 		// blocking. The control service always sets OnAttach even for
@@ -1587,8 +2157,9 @@ func runGuestCommand(bootCtx context.Context, execCtx context.Context, processEx
 
 	commandStart := time.Now()
 	res, err := vsockexec.DecodeStreamResponse(conn, vsockexec.StreamCallbacks{
-		OnStdout: stream.OnStdout,
-		OnStderr: stream.OnStderr,
+		OnStdout:      stream.OnStdout,
+		OnStderr:      stream.OnStderr,
+		DiscardStdout: req.StreamStdout,
 	})
 	if err != nil {
 		if ctxErr := execCtx.Err(); ctxErr != nil {
@@ -1625,7 +2196,13 @@ func (w callbackWriter) Write(p []byte) (int, error) {
 	return len(p), nil
 }
 
+// dialVsockUntilReady waits for Firecracker to create the vsock socket,
+// then dials until the guest agent accepts. Only the second wait polls:
+// nothing on the host shows when the guest starts listening.
 func dialVsockUntilReady(ctx context.Context, processExited <-chan struct{}, processExitErr func() error, vsockPath string, guestPort uint32) (io.ReadWriteCloser, error) {
+	if err := waitForPath(ctx, processExited, vsockPath); err != nil {
+		return nil, vsockNotReadyError(ctx, processExitErr, vsockPath)
+	}
 	ticker := time.NewTicker(vsockDialRetryInterval)
 	defer ticker.Stop()
 
@@ -1637,25 +2214,43 @@ func dialVsockUntilReady(ctx context.Context, processExited <-chan struct{}, pro
 
 		select {
 		case <-processExited:
-			waitErr := processExitErr()
-			if waitErr == nil {
-				return nil, errors.New("firecracker exited before vsock guest agent became ready")
-			}
-			return nil, fmt.Errorf("firecracker exited before vsock guest agent became ready: %w", waitErr)
+			return nil, vsockNotReadyError(ctx, processExitErr, vsockPath)
 		case <-ctx.Done():
-			return nil, fmt.Errorf("timed out waiting for vsock guest agent (%s): %w", vsockPath, ctx.Err())
+			return nil, vsockNotReadyError(ctx, processExitErr, vsockPath)
 		case <-ticker.C:
 		}
 	}
 }
 
+// vsockNotReadyError explains why the guest agent never became reachable:
+// ctx ended, or else Firecracker exited.
+func vsockNotReadyError(ctx context.Context, processExitErr func() error, vsockPath string) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("timed out waiting for vsock guest agent (%s): %w", vsockPath, ctx.Err())
+	}
+	waitErr := processExitErr()
+	if waitErr == nil {
+		return errors.New("firecracker exited before vsock guest agent became ready")
+	}
+	return fmt.Errorf("firecracker exited before vsock guest agent became ready: %w", waitErr)
+}
+
 func stopVM(fcCmd *exec.Cmd, processExited <-chan struct{}) {
 	if fcCmd == nil {
 		return
 	}
-	if fcCmd.Process != nil {
-		_ = fcCmd.Process.Kill()
+	if fcCmd.Process == nil {
+		return
+	}
+	// SIGTERM first: a namespaced firecracker runs under sudo, which relays
+	// it but cannot relay SIGKILL.
+	_ = fcCmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-processExited:
+		return
+	case <-time.After(time.Second):
 	}
+	_ = fcCmd.Process.Kill()
 	select {
 	case <-processExited:
 	case <-time.After(2 * time.Second):
@@ -1663,35 +2258,146 @@ func stopVM(fcCmd *exec.Cmd, processExited <-chan struct{}) {
 }
 
 type hostNetworkConfig struct {
-	TapName         string
-	HostIP          string
-	GuestIP         string
+	TapName   string
+	HostIP    string
+	GuestIP   string
+	MTU       int    // 0 leaves the kernel default
+	Namespace string // network namespace holding the TAP; empty for the host namespace
+	// SourceIP is the address the host sees the guest's traffic from: the
+	// guest address, or the namespace's veth address when namespaced.
+	SourceIP        string
 	PolicyResolveMS int64
+	Resolutions     []backend.HostResolution
 }
 
 type iptablesForwardRule struct {
 	Protocol string
 	DestIP   string
-	DestPort int
+	// DestPort is the port, or the first port of a range ending at
+	// DestPortEnd. Both are zero for icmp.
+	DestPort    int
+	DestPortEnd int
+	// Host is the policy allow entry the rule was resolved from.
+	Host string
+}
+
+// matchArgs returns the iptables match for the rule's protocol, destination
+// and ports.
+func (r iptablesForwardRule) matchArgs() []string {
+	args := []string{"-p", r.Protocol, "-d", r.DestIP}
+	if r.Protocol == "icmp" {
+		return args
+	}
+	ports := strconv.Itoa(r.DestPort)
+	if r.DestPortEnd > r.DestPort {
+		ports += ":" + strconv.Itoa(r.DestPortEnd)
+	}
+	return append(args, "--dport", ports)
+}
+
+// matches reports whether the rule accepts protocol traffic to ip and port.
+func (r iptablesForwardRule) matches(protocol, ip string, port int) bool {
+	if r.Protocol != protocol || r.DestIP != ip {
+		return false
+	}
+	return protocol == "icmp" || (port >= r.DestPort && port <= max(r.DestPort, r.DestPortEnd))
 }
 
+func (r iptablesForwardRule) String() string {
+	switch {
+	case r.Protocol == "icmp":
+		return "icmp " + r.DestIP
+	case r.DestPortEnd > r.DestPort:
+		return fmt.Sprintf("%s %s:%d-%d", r.Protocol, r.DestIP, r.DestPort, r.DestPortEnd)
+	default:
+		return fmt.Sprintf("%s %s:%d", r.Protocol, r.DestIP, r.DestPort)
+	}
+}
+
+// guestDNSServer is the resolver the guest is configured with and allowed
+// to reach on port 53 regardless of policy.
+const guestDNSServer = "1.1.1.1"
+
 type ipLookupFunc func(ctx context.Context, host string) ([]net.IP, error)
 type rootCommandFunc func(ctx context.Context, args ...string) error
 type rootCommandBatchFunc func(ctx context.Context, commands [][]string) error
 
-func setupHostNetwork(ctx context.Context, runID string, allow []policy.AllowRule, gatewayPort int, runCommand rootCommandFunc, runBatchCommand rootCommandBatchFunc) (hostNetworkConfig, func(), error) {
-	lookup := func(ctx context.Context, host string) ([]net.IP, error) {
+// setupHostNetwork leases a guest subnet and TAP device from the configured
+// pool, wraps the
+// package-level setupHostNetwork with logging to the network logger, and
+// returns the lease to the pool on cleanup.
+func (a *Adapter) setupHostNetwork(ctx context.Context, id string, cfg backend.FirecrackerConfig, allow []policy.AllowRule, pinned []backend.HostResolution, gatewayPort int, runCommand rootCommandFunc, runBatchCommand rootCommandBatchFunc) (hostNetworkConfig, func(), error) {
+	logger := a.networkLogger(ctx)
+	if mode, _ := resolvePrivilegedExecution(cfg); cfg.NetworkNamespaces && mode != privilegedModeSudo {
+		return hostNetworkConfig{}, func() {}, fmt.Errorf("network namespaces require privileged mode %q, not %q", privilegedModeSudo, mode)
+	}
+	alloc, err := a.guestNetworkAllocator()
+	if err != nil {
+		return hostNetworkConfig{}, func() {}, fmt.Errorf("open network leases: %w", err)
+	}
+	lease, err := alloc.allocate(cfg.NetworkPool, id)
+	if err != nil {
+		logger.Warn("guest network allocation failed", "id", id, "pool", cfg.NetworkPool, "error", err)
+		return hostNetworkConfig{}, func() {}, err
+	}
+	netCfg, cleanup, err := setupHostNetwork(ctx, lease, cfg, allow, pinned, gatewayPort, runCommand, runBatchCommand)
+	if err != nil {
+		_ = alloc.release(lease)
+		logger.Warn("host network setup failed", "id", id, "error", err)
+		return netCfg, cleanup, err
+	}
+	logger.Debug("host network ready",
+		"id", id,
+		"tap", netCfg.TapName,
+		"subnet", lease.Subnet,
+		"host_ip", netCfg.HostIP,
+		"guest_ip", netCfg.GuestIP,
+		"mtu", netCfg.MTU,
+		"namespace", netCfg.Namespace,
+		"allow_rules", len(allow),
+		"policy_resolve_ms", netCfg.PolicyResolveMS,
+	)
+	return netCfg, func() {
+		cleanup()
+		if err := alloc.release(lease); err != nil {
+			logger.Warn("release guest network lease failed", "id", id, "subnet", lease.Subnet, "error", err)
+		}
+		logger.Debug("host network released", "id", id, "tap", netCfg.TapName)
+	}, nil
+}
+
+// guestNetworkAllocator returns the allocator backed by the lease file in
+// the state directory.
+func (a *Adapter) guestNetworkAllocator() (*networkAllocator, error) {
+	a.networkAllocOnce.Do(func() {
+		if a.networkAlloc != nil {
+			return
+		}
+		base, err := paths.StateBaseDir()
+		if err != nil {
+			a.networkAllocErr = err
+			return
+		}
+		a.networkAlloc = newNetworkAllocator(filepath.Join(base, "firecracker", "network-leases.json"))
+	})
+	return a.networkAlloc, a.networkAllocErr
+}
+
+func setupHostNetwork(ctx context.Context, lease networkLease, cfg backend.FirecrackerConfig, allow []policy.AllowRule, pinned []backend.HostResolution, gatewayPort int, runCommand rootCommandFunc, runBatchCommand rootCommandBatchFunc) (hostNetworkConfig, func(), error) {
+	lookup := pinnedLookup(pinned, func(ctx context.Context, host string) ([]net.IP, error) {
 		return net.DefaultResolver.LookupIP(ctx, "ip4", host)
+	})
+	if cfg.NetworkNamespaces {
+		return setupNamespacedNetworkWithDeps(ctx, lease, cfg.MTU, allow, gatewayPort, lookup, runCommand, runBatchCommand)
 	}
-	return setupHostNetworkWithDeps(ctx, runID, allow, gatewayPort, lookup, runCommand, runBatchCommand)
+	return setupHostNetworkWithDeps(ctx, lease, cfg.MTU, allow, gatewayPort, lookup, runCommand, runBatchCommand)
 }
 
-func setupHostNetworkWithDeps(ctx context.Context, runID string, allow []policy.AllowRule, gatewayPort int, lookup ipLookupFunc, runCommand rootCommandFunc, runBatchCommand rootCommandBatchFunc) (hostNetworkConfig, func(), error) {
-	tapName := tapNameFromRunID(runID)
-	hostIP, guestIP := hostGuestIPs(runID)
+func setupHostNetworkWithDeps(ctx context.Context, lease networkLease, mtu int, allow []policy.AllowRule, gatewayPort int, lookup ipLookupFunc, runCommand rootCommandFunc, runBatchCommand rootCommandBatchFunc) (hostNetworkConfig, func(), error) {
+	tapName := lease.TapName
+	hostIP, guestIP := lease.addresses()
 	hostCIDR := hostIP + "/24"
 	guestCIDR := guestIP + "/32"
-	const dnsServer = "1.1.1.1"
 
 	if runBatchCommand == nil {
 		runBatchCommand = func(ctx context.Context, commands [][]string) error {
@@ -1703,7 +2409,8 @@ func setupHostNetworkWithDeps(ctx context.Context, runID string, allow []policy.
 	}
 
 	policyResolveStart := time.Now()
-	forwardRules, err := resolveForwardRulesWithLookup(ctx, allow, lookup)
+	var resolutions []backend.HostResolution
+	forwardRules, err := resolveForwardRulesWithLookup(ctx, allow, recordingLookup(lookup, &resolutions))
 	policyResolveMS := durationMillisCeil(time.Since(policyResolveStart))
 	if err != nil {
 		return hostNetworkConfig{}, func() {}, err
@@ -1712,6 +2419,9 @@ func setupHostNetworkWithDeps(ctx context.Context, runID string, allow []policy.
 	setupRun := func(args ...string) error {
 		return runCommand(ctx, args...)
 	}
+	if err := ensureCleanroomChains(ctx, runCommand); err != nil {
+		return hostNetworkConfig{}, func() {}, err
+	}
 	cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	cleanupCmds := make([][]string, 0, 16)
 	cleanup := func() {
@@ -1734,6 +2444,12 @@ func setupHostNetworkWithDeps(ctx context.Context, runID string, allow []policy.
 		cleanup()
 		return hostNetworkConfig{}, func() {}, fmt.Errorf("assign host ip to %s: %w", tapName, err)
 	}
+	if mtu > 0 {
+		if err := setupRun("ip", "link", "set", "dev", tapName, "mtu", strconv.Itoa(mtu)); err != nil {
+			cleanup()
+			return hostNetworkConfig{}, func() {}, fmt.Errorf("set mtu %d on %s: %w", mtu, tapName, err)
+		}
+	}
 	if err := setupRun("ip", "link", "set", "dev", tapName, "up"); err != nil {
 		cleanup()
 		return hostNetworkConfig{}, func() {}, fmt.Errorf("bring tap %s up: %w", tapName, err)
@@ -1750,34 +2466,34 @@ func setupHostNetworkWithDeps(ctx context.Context, runID string, allow []policy.
 	}
 
 	// Anti-spoof: drop anything from this TAP not sourced from assigned guest IP.
-	if err := setupRun("iptables", "-A", "INPUT", "-i", tapName, "!", "-s", guestIP, "-j", "DROP"); err != nil {
+	if err := setupRun("iptables", "-A", inputChain, "-i", tapName, "!", "-s", guestIP, "-j", "DROP"); err != nil {
 		cleanup()
 		return hostNetworkConfig{}, func() {}, fmt.Errorf("install anti-spoof rule for %s: %w", tapName, err)
 	}
-	addCleanup("iptables", "-D", "INPUT", "-i", tapName, "!", "-s", guestIP, "-j", "DROP")
+	addCleanup("iptables", "-D", inputChain, "-i", tapName, "!", "-s", guestIP, "-j", "DROP")
 
 	// Allow guest to reach gateway port on host.
 	if gatewayPort > 0 {
 		port := strconv.Itoa(gatewayPort)
-		if err := setupRun("iptables", "-A", "INPUT", "-i", tapName, "-s", guestIP, "-p", "tcp", "--dport", port, "-j", "ACCEPT"); err != nil {
+		if err := setupRun("iptables", "-A", inputChain, "-i", tapName, "-s", guestIP, "-p", "tcp", "--dport", port, "-j", "ACCEPT"); err != nil {
 			cleanup()
 			return hostNetworkConfig{}, func() {}, fmt.Errorf("install gateway accept rule for %s: %w", tapName, err)
 		}
-		addCleanup("iptables", "-D", "INPUT", "-i", tapName, "-s", guestIP, "-p", "tcp", "--dport", port, "-j", "ACCEPT")
+		addCleanup("iptables", "-D", inputChain, "-i", tapName, "-s", guestIP, "-p", "tcp", "--dport", port, "-j", "ACCEPT")
 	}
 
 	// Drop all other host INPUT from this TAP.
-	if err := setupRun("iptables", "-A", "INPUT", "-i", tapName, "-j", "DROP"); err != nil {
+	if err := setupRun("iptables", "-A", inputChain, "-i", tapName, "-j", "DROP"); err != nil {
 		cleanup()
 		return hostNetworkConfig{}, func() {}, fmt.Errorf("install input deny rule for %s: %w", tapName, err)
 	}
-	addCleanup("iptables", "-D", "INPUT", "-i", tapName, "-j", "DROP")
+	addCleanup("iptables", "-D", inputChain, "-i", tapName, "-j", "DROP")
 
-	if err := setupRun("iptables", "-t", "nat", "-A", "POSTROUTING", "-s", guestCIDR, "-j", "MASQUERADE"); err != nil {
+	if err := setupRun("iptables", "-t", "nat", "-A", postroutingChain, "-s", guestCIDR, "-j", "MASQUERADE"); err != nil {
 		cleanup()
 		return hostNetworkConfig{}, func() {}, fmt.Errorf("install nat rule for %s: %w", guestCIDR, err)
 	}
-	addCleanup("iptables", "-t", "nat", "-D", "POSTROUTING", "-s", guestCIDR, "-j", "MASQUERADE")
+	addCleanup("iptables", "-t", "nat", "-D", postroutingChain, "-s", guestCIDR, "-j", "MASQUERADE")
 	returnPathCleanup, err := installForwardReturnPathRule(setupRun, tapName)
 	if err != nil {
 		cleanup()
@@ -1786,50 +2502,53 @@ func setupHostNetworkWithDeps(ctx context.Context, runID string, allow []policy.
 	addCleanup(returnPathCleanup...)
 
 	// Allow guest DNS to the configured resolver so host-based policy entries remain usable.
-	if err := setupRun("iptables", "-A", "FORWARD", "-i", tapName, "-p", "udp", "-d", dnsServer, "--dport", "53", "-j", "ACCEPT"); err != nil {
+	if err := setupRun("iptables", "-A", forwardChain, "-i", tapName, "-p", "udp", "-d", guestDNSServer, "--dport", "53", "-j", "ACCEPT"); err != nil {
 		cleanup()
 		return hostNetworkConfig{}, func() {}, fmt.Errorf("install dns udp rule for %s: %w", tapName, err)
 	}
-	addCleanup("iptables", "-D", "FORWARD", "-i", tapName, "-p", "udp", "-d", dnsServer, "--dport", "53", "-j", "ACCEPT")
-	if err := setupRun("iptables", "-A", "FORWARD", "-i", tapName, "-p", "tcp", "-d", dnsServer, "--dport", "53", "-j", "ACCEPT"); err != nil {
+	addCleanup("iptables", "-D", forwardChain, "-i", tapName, "-p", "udp", "-d", guestDNSServer, "--dport", "53", "-j", "ACCEPT")
+	if err := setupRun("iptables", "-A", forwardChain, "-i", tapName, "-p", "tcp", "-d", guestDNSServer, "--dport", "53", "-j", "ACCEPT"); err != nil {
 		cleanup()
 		return hostNetworkConfig{}, func() {}, fmt.Errorf("install dns tcp rule for %s: %w", tapName, err)
 	}
-	addCleanup("iptables", "-D", "FORWARD", "-i", tapName, "-p", "tcp", "-d", dnsServer, "--dport", "53", "-j", "ACCEPT")
+	addCleanup("iptables", "-D", forwardChain, "-i", tapName, "-p", "tcp", "-d", guestDNSServer, "--dport", "53", "-j", "ACCEPT")
 
 	for _, rule := range forwardRules {
-		port := strconv.Itoa(rule.DestPort)
-		if err := setupRun("iptables", "-A", "FORWARD", "-i", tapName, "-p", rule.Protocol, "-d", rule.DestIP, "--dport", port, "-j", "ACCEPT"); err != nil {
+		match := rule.matchArgs()
+		if err := setupRun(slices.Concat([]string{"iptables", "-A", forwardChain, "-i", tapName}, match, []string{"-j", "ACCEPT"})...); err != nil {
 			cleanup()
-			return hostNetworkConfig{}, func() {}, fmt.Errorf("install allow rule %s %s:%d: %w", rule.Protocol, rule.DestIP, rule.DestPort, err)
+			return hostNetworkConfig{}, func() {}, fmt.Errorf("install allow rule %s: %w", rule, err)
 		}
-		addCleanup("iptables", "-D", "FORWARD", "-i", tapName, "-p", rule.Protocol, "-d", rule.DestIP, "--dport", port, "-j", "ACCEPT")
+		addCleanup(slices.Concat([]string{"iptables", "-D", forwardChain, "-i", tapName}, match, []string{"-j", "ACCEPT"})...)
 	}
-	if err := setupRun("iptables", "-A", "FORWARD", "-i", tapName, "-j", "DROP"); err != nil {
+	if err := setupRun("iptables", "-A", forwardChain, "-i", tapName, "-j", "DROP"); err != nil {
 		cleanup()
 		return hostNetworkConfig{}, func() {}, fmt.Errorf("install default deny forward rule for %s: %w", tapName, err)
 	}
-	addCleanup("iptables", "-D", "FORWARD", "-i", tapName, "-j", "DROP")
+	addCleanup("iptables", "-D", forwardChain, "-i", tapName, "-j", "DROP")
 
 	return hostNetworkConfig{
 		TapName:         tapName,
 		HostIP:          hostIP,
 		GuestIP:         guestIP,
+		SourceIP:        guestIP,
+		MTU:             mtu,
 		PolicyResolveMS: policyResolveMS,
+		Resolutions:     resolutions,
 	}, cleanup, nil
 }
 
 func installForwardReturnPathRule(setupRun func(args ...string) error, tapName string) ([]string, error) {
-	conntrackAdd := []string{"iptables", "-A", "FORWARD", "-o", tapName, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT"}
+	conntrackAdd := []string{"iptables", "-A", forwardChain, "-o", tapName, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT"}
 	if err := setupRun(conntrackAdd...); err == nil {
-		return []string{"iptables", "-D", "FORWARD", "-o", tapName, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT"}, nil
+		return []string{"iptables", "-D", forwardChain, "-o", tapName, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT"}, nil
 	}
 
-	stateAdd := []string{"iptables", "-A", "FORWARD", "-o", tapName, "-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"}
+	stateAdd := []string{"iptables", "-A", forwardChain, "-o", tapName, "-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"}
 	if err := setupRun(stateAdd...); err != nil {
 		return nil, err
 	}
-	return []string{"iptables", "-D", "FORWARD", "-o", tapName, "-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"}, nil
+	return []string{"iptables", "-D", forwardChain, "-o", tapName, "-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"}, nil
 }
 
 func resolveForwardRules(ctx context.Context, allow []policy.AllowRule) ([]iptablesForwardRule, error) {
@@ -1856,18 +2575,28 @@ func resolveForwardRulesWithLookup(ctx context.Context, allow []policy.AllowRule
 				continue
 			}
 			ipStr := ipv4.String()
-			for _, port := range entry.Ports {
-				for _, proto := range []string{"tcp", "udp"} {
-					key := fmt.Sprintf("%s|%s|%d", proto, ipStr, port)
-					if _, ok := seen[key]; ok {
-						continue
-					}
-					seen[key] = struct{}{}
-					rules = append(rules, iptablesForwardRule{
-						Protocol: proto,
-						DestIP:   ipStr,
-						DestPort: port,
-					})
+			add := func(rule iptablesForwardRule) {
+				key := fmt.Sprintf("%s|%s|%d|%d", rule.Protocol, rule.DestIP, rule.DestPort, rule.DestPortEnd)
+				if _, ok := seen[key]; ok {
+					return
+				}
+				seen[key] = struct{}{}
+				rules = append(rules, rule)
+			}
+			protocols := []string{"tcp", "udp"}
+			if entry.Protocol != "" {
+				protocols = []string{entry.Protocol}
+			}
+			for _, proto := range protocols {
+				if proto == "icmp" {
+					add(iptablesForwardRule{Protocol: proto, DestIP: ipStr, Host: entry.Host})
+					continue
+				}
+				for _, port := range entry.Ports {
+					add(iptablesForwardRule{Protocol: proto, DestIP: ipStr, DestPort: port, Host: entry.Host})
+				}
+				for _, pr := range entry.PortRanges {
+					add(iptablesForwardRule{Protocol: proto, DestIP: ipStr, DestPort: pr.From, DestPortEnd: pr.To, Host: entry.Host})
 				}
 			}
 		}
@@ -1882,27 +2611,56 @@ func resolvePrivilegedExecution(cfg backend.FirecrackerConfig) (mode string, hel
 	}
 	helperPath = strings.TrimSpace(cfg.PrivilegedHelperPath)
 	if helperPath == "" {
-		helperPath = defaultPrivilegedHelperPath
+		helperPath = DefaultPrivilegedHelperPath
+		if mode == privilegedModeCaps {
+			helperPath = caphelper.DefaultPath
+		}
 	}
 	return mode, helperPath
 }
 
-func logRunNotice(backendName, runID, notice string) {
+// logger returns the adapter's logger, or the default one, with the
+// request, sandbox and execution IDs attached to ctx.
+func (a *Adapter) logger(ctx context.Context) *log.Logger {
+	base := a.Logger
+	if base == nil {
+		base = log.Default()
+	}
+	return logging.Logger(ctx, base)
+}
+
+// networkLogger is logger for host network setup, using NetworkLogger when set.
+func (a *Adapter) networkLogger(ctx context.Context) *log.Logger {
+	if a.NetworkLogger == nil {
+		return a.logger(ctx)
+	}
+	return logging.Logger(ctx, a.NetworkLogger)
+}
+
+func (a *Adapter) logRunNotice(ctx context.Context, runID, notice string) {
 	msg := strings.TrimSpace(notice)
 	if msg == "" {
 		return
 	}
-	id := strings.TrimSpace(runID)
-	if id == "" {
-		log.Printf("%s: %s", backendName, msg)
-		return
+	if id := strings.TrimSpace(runID); id != "" {
+		ctx = logging.WithFields(ctx, "run_id", id)
 	}
-	log.Printf("%s run_id=%s: %s", backendName, id, msg)
+	a.logger(ctx).Info(msg, "backend", a.Name())
 }
 
 func runRootCommand(ctx context.Context, cfg backend.FirecrackerConfig, args ...string) error {
+	_, err := rootCommandOutput(ctx, cfg, args...)
+	return err
+}
+
+// rootCommandOutput runs a privileged command like runRootCommand and
+// returns its combined output.
+func rootCommandOutput(ctx context.Context, cfg backend.FirecrackerConfig, args ...string) ([]byte, error) {
 	if len(args) == 0 {
-		return errors.New("missing privileged command")
+		return nil, errors.New("missing privileged command")
+	}
+	if err := validateRootCommandArgs(args); err != nil {
+		return nil, fmt.Errorf("refusing privileged command %s: %w", args[0], err)
 	}
 
 	mode, helperPath := resolvePrivilegedExecution(cfg)
@@ -1911,11 +2669,16 @@ func runRootCommand(ctx context.Context, cfg backend.FirecrackerConfig, args ...
 		return runCombinedCommand(ctx, append([]string{"sudo", "-n"}, args...), args)
 	case privilegedModeHelper:
 		if strings.TrimSpace(helperPath) == "" {
-			return errors.New("privileged helper mode requires helper path")
+			return nil, errors.New("privileged helper mode requires helper path")
 		}
 		return runCombinedCommand(ctx, append([]string{"sudo", "-n", helperPath}, args...), append([]string{"helper"}, args...))
+	case privilegedModeCaps:
+		if strings.TrimSpace(helperPath) == "" {
+			return nil, errors.New("privileged caps mode requires helper path")
+		}
+		return runCombinedCommand(ctx, append([]string{helperPath}, args...), append([]string{"helper"}, args...))
 	default:
-		return fmt.Errorf("unsupported privileged command mode %q", mode)
+		return nil, fmt.Errorf("unsupported privileged command mode %q", mode)
 	}
 }
 
@@ -1929,7 +2692,7 @@ func runRootCommandBatch(ctx context.Context, cfg backend.FirecrackerConfig, com
 	return nil
 }
 
-func runCombinedCommand(ctx context.Context, command []string, errorContext []string) error {
+func runCombinedCommand(ctx context.Context, command []string, errorContext []string) ([]byte, error) {
 	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -1937,9 +2700,9 @@ func runCombinedCommand(ctx context.Context, command []string, errorContext []st
 		if msg == "" {
 			msg = "no stderr output"
 		}
-		return fmt.Errorf("%s: %w (%s)", strings.Join(errorContext, " "), err, msg)
+		return out, fmt.Errorf("%s: %w (%s)", strings.Join(errorContext, " "), err, msg)
 	}
-	return nil
+	return out, nil
 }
 
 func durationMillisCeil(d time.Duration) int64 {
@@ -1970,27 +2733,49 @@ func gatewayEnvVars(instance *sandboxInstance, gwPort int) []string {
 
 	var gitHosts []string
 	for _, rule := range instance.Policy.Allow {
-		for _, port := range rule.Ports {
-			if port == 443 {
-				gitHosts = append(gitHosts, rule.Host)
-				break
-			}
+		if rule.AllowsPort("tcp", 443) {
+			gitHosts = append(gitHosts, rule.Host)
 		}
 	}
-	if len(gitHosts) == 0 {
-		return nil
-	}
 
-	gatewayAddr := fmt.Sprintf("http://%s:%d", instance.HostIP, gwPort)
-	env := make([]string, 0, 1+len(gitHosts)*2)
-	env = append(env, fmt.Sprintf("GIT_CONFIG_COUNT=%d", len(gitHosts)))
-	for i, host := range gitHosts {
-		env = append(env, fmt.Sprintf("GIT_CONFIG_KEY_%d=url.%s/git/%s/.insteadOf", i, gatewayAddr, host))
-		env = append(env, fmt.Sprintf("GIT_CONFIG_VALUE_%d=https://%s/", i, host))
+	var env []string
+	if len(gitHosts) > 0 {
+		gatewayAddr := fmt.Sprintf("http://%s:%d", instance.HostIP, gwPort)
+		env = append(env, fmt.Sprintf("GIT_CONFIG_COUNT=%d", len(gitHosts)))
+		for i, host := range gitHosts {
+			env = append(env, fmt.Sprintf("GIT_CONFIG_KEY_%d=url.%s/git/%s/.insteadOf", i, gatewayAddr, host))
+			env = append(env, fmt.Sprintf("GIT_CONFIG_VALUE_%d=https://%s/", i, host))
+		}
+	}
+	if instance.Policy.Services.OCIRegistry != nil {
+		// Images pull as $CLEANROOM_OCI_REGISTRY/<registry-host>/<repository>.
+		env = append(env, fmt.Sprintf("CLEANROOM_OCI_REGISTRY=%s:%d", instance.HostIP, gwPort))
+	}
+	packagesAddr := fmt.Sprintf("http://%s:%d/registry", instance.HostIP, gwPort)
+	if len(instance.Policy.PackagePatterns(policy.PackageEcosystemNPM)) > 0 {
+		env = append(env, "npm_config_registry="+packagesAddr+"/npm/")
+	}
+	if len(instance.Policy.PackagePatterns(policy.PackageEcosystemPyPI)) > 0 {
+		env = append(env,
+			"PIP_INDEX_URL="+packagesAddr+"/pypi/simple/",
+			fmt.Sprintf("PIP_TRUSTED_HOST=%s:%d", instance.HostIP, gwPort),
+		)
+	}
+	if len(instance.Policy.PackagePatterns(policy.PackageEcosystemGo)) > 0 {
+		env = append(env, "GOPROXY="+packagesAddr+"/go")
 	}
 	return env
 }
 
+// guestNetworkBootArgs tells the guest init script how to configure eth0.
+func guestNetworkBootArgs(cfg hostNetworkConfig) string {
+	args := fmt.Sprintf("cleanroom_guest_ip=%s cleanroom_guest_gw=%s cleanroom_guest_mask=24 cleanroom_guest_dns=%s", cfg.GuestIP, cfg.HostIP, guestDNSServer)
+	if cfg.MTU > 0 {
+		args += fmt.Sprintf(" cleanroom_guest_mtu=%d", cfg.MTU)
+	}
+	return args
+}
+
 func dockerServiceBootArgs(compiled *policy.CompiledPolicy, cfg backend.FirecrackerConfig) string {
 	if compiled == nil || !compiled.RequiresDockerService() {
 		return "cleanroom_service_docker_required=0"
@@ -1998,12 +2783,12 @@ func dockerServiceBootArgs(compiled *policy.CompiledPolicy, cfg backend.Firecrac
 
 	startupSeconds := cfg.DockerStartupSeconds
 	if startupSeconds <= 0 {
-		startupSeconds = 20
+		startupSeconds = backend.DefaultDockerStartupSeconds
 	}
 
 	storageDriver := sanitizeKernelArgValue(strings.TrimSpace(cfg.DockerStorageDriver))
 	if storageDriver == "" {
-		storageDriver = "vfs"
+		storageDriver = backend.DefaultDockerStorageDriver
 	}
 
 	iptables := 0
@@ -2031,38 +2816,6 @@ func sanitizeKernelArgValue(value string) string {
 	return b.String()
 }
 
-func tapNameFromRunID(runID string) string {
-	filtered := strings.Map(func(r rune) rune {
-		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
-			return r
-		}
-		return -1
-	}, runID)
-	filtered = strings.ToLower(filtered)
-	if len(filtered) > 13 {
-		filtered = filtered[len(filtered)-13:]
-	}
-	if filtered == "" {
-		filtered = "cleanroomtap"
-	}
-	return "cr" + filtered
-}
-
-func hostGuestIPs(runID string) (string, string) {
-	sum := sha1.Sum([]byte(runID))
-	o2 := int(sum[0])
-	o3 := int(sum[1])
-	if o2 == 0 {
-		o2 = 1
-	}
-	if o3 == 0 {
-		o3 = 1
-	}
-	hostIP := fmt.Sprintf("10.%d.%d.1", o2, o3)
-	guestIP := fmt.Sprintf("10.%d.%d.2", o2, o3)
-	return hostIP, guestIP
-}
-
 func guestMACFromRunID(runID string) string {
 	sum := sha1.Sum([]byte(runID))
 	return fmt.Sprintf("02:fc:%02x:%02x:%02x:%02x", sum[0], sum[1], sum[2], sum[3])
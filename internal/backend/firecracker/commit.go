@@ -0,0 +1,129 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/imagemgr"
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+// imageCommitter is implemented by image managers that can publish a root
+// filesystem tarball as a new image.
+type imageCommitter interface {
+	Commit(ctx context.Context, req imagemgr.CommitRequest) (imagemgr.Record, error)
+}
+
+// guestExportRootFSCommand has the guest agent write the root filesystem as
+// a tarball on stdout, leaving out every other mount.
+var guestExportRootFSCommand = []string{"/usr/local/bin/cleanroom-guest-agent", "export-rootfs"}
+
+// CommitSandbox streams the sandbox's root filesystem out of the guest and
+// publishes it as ref. Declared writable paths are separate mounts in the
+// guest, so they are left out along with /proc, /run and the other kernel
+// filesystems.
+func (a *Adapter) CommitSandbox(ctx context.Context, sandboxID, ref string) (string, error) {
+	sandboxID = strings.TrimSpace(sandboxID)
+	if sandboxID == "" {
+		return "", errors.New("missing sandbox_id")
+	}
+	a.sandboxMu.Lock()
+	instance, ok := a.sandboxes[sandboxID]
+	a.sandboxMu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("unknown sandbox %q", sandboxID)
+	}
+	if err := instance.exitedErrOrNil(); err != nil {
+		return "", fmt.Errorf("sandbox %q is not running: %w", sandboxID, err)
+	}
+
+	manager, err := a.getImageManager()
+	if err != nil {
+		return "", err
+	}
+	committer, ok := manager.(imageCommitter)
+	if !ok {
+		return "", errors.New("image manager cannot commit images")
+	}
+
+	var record imagemgr.Record
+	err = a.withRootFSExport(ctx, instance, func(rootfs io.Reader) error {
+		var err error
+		record, err = committer.Commit(ctx, imagemgr.CommitRequest{
+			Ref:     ref,
+			BaseRef: instance.ImageRef,
+			RootFS:  rootfs,
+		})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return record.Ref, nil
+}
+
+// withRootFSExport streams the guest's root filesystem to consume. The
+// export is stopped if consume returns before reading all of it.
+func (a *Adapter) withRootFSExport(ctx context.Context, instance *sandboxInstance, consume func(io.Reader) error) error {
+	exportCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pr, pw := io.Pipe()
+	exported := make(chan error, 1)
+	go func() {
+		err := a.exportRootFS(exportCtx, instance, pw)
+		_ = pw.CloseWithError(err)
+		exported <- err
+	}()
+
+	err := consume(pr)
+	_ = pr.CloseWithError(errors.New("export consumer finished"))
+	cancel()
+	exportErr := <-exported
+	if err != nil && exportErr != nil && !errors.Is(exportErr, context.Canceled) {
+		return fmt.Errorf("export rootfs: %w", exportErr)
+	}
+	return err
+}
+
+// exportRootFS writes the guest's root filesystem to w as a tarball. The
+// stream can be far larger than memory, so the guest agent and the decoder
+// forward it without keeping a copy.
+func (a *Adapter) exportRootFS(ctx context.Context, instance *sandboxInstance, w io.Writer) error {
+	var stderr bytes.Buffer
+	var writeErr error
+	resp, _, err := a.executeInSandbox(ctx, instance, 0, vsockexec.ExecRequest{
+		Command:      guestExportRootFSCommand,
+		StreamStdout: true,
+	}, backend.OutputStream{
+		OnStdout: func(chunk []byte) {
+			if writeErr == nil {
+				_, writeErr = w.Write(chunk)
+			}
+		},
+		OnStderr: func(chunk []byte) {
+			_, _ = stderr.Write(chunk)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if resp.ExitCode != 0 {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(resp.Error)
+		}
+		if msg == "" {
+			msg = fmt.Sprintf("export exited %d", resp.ExitCode)
+		}
+		return errors.New(msg)
+	}
+	return nil
+}
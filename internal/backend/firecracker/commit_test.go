@@ -0,0 +1,91 @@
+package firecracker
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/imagemgr"
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+type fakeImageCommitter struct {
+	req    imagemgr.CommitRequest
+	rootfs string
+}
+
+func (f *fakeImageCommitter) Ensure(context.Context, string) (imagemgr.EnsureResult, error) {
+	return imagemgr.EnsureResult{}, nil
+}
+
+func (f *fakeImageCommitter) Commit(_ context.Context, req imagemgr.CommitRequest) (imagemgr.Record, error) {
+	f.req = req
+	data, err := io.ReadAll(req.RootFS)
+	if err != nil {
+		return imagemgr.Record{}, err
+	}
+	f.rootfs = string(data)
+	return imagemgr.Record{Ref: req.Ref + "@sha256:abc"}, nil
+}
+
+func TestCommitSandboxStreamsGuestRootFS(t *testing.T) {
+	t.Parallel()
+
+	const baseRef = "ghcr.io/acme/base@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	committer := &fakeImageCommitter{}
+	adapter := &Adapter{
+		newImageManager: func() (imageEnsurer, error) { return committer, nil },
+		sandboxes: map[string]*sandboxInstance{
+			"cr-test": {SandboxID: "cr-test", GuestPort: 10700, ImageRef: baseRef},
+		},
+	}
+	var guestReq vsockexec.ExecRequest
+	adapter.runGuestCommandFn = func(_ context.Context, _ context.Context, _ <-chan struct{}, _ func() error, _ string, _ uint32, req vsockexec.ExecRequest, stream backend.OutputStream) (vsockexec.ExecResponse, guestExecTiming, error) {
+		guestReq = req
+		stream.OnStdout([]byte("tar-"))
+		stream.OnStdout([]byte("bytes"))
+		return vsockexec.ExecResponse{}, guestExecTiming{}, nil
+	}
+
+	ref, err := adapter.CommitSandbox(context.Background(), "cr-test", "ghcr.io/acme/img:ci")
+	if err != nil {
+		t.Fatalf("CommitSandbox returned error: %v", err)
+	}
+	if ref != "ghcr.io/acme/img:ci@sha256:abc" {
+		t.Fatalf("unexpected ref %q", ref)
+	}
+	if got, want := strings.Join(guestReq.Command, " "), "/usr/local/bin/cleanroom-guest-agent export-rootfs"; got != want {
+		t.Fatalf("unexpected guest command: got %q want %q", got, want)
+	}
+	if !guestReq.StreamStdout {
+		t.Fatal("expected the export to stream stdout without buffering")
+	}
+	if committer.rootfs != "tar-bytes" {
+		t.Fatalf("unexpected rootfs stream %q", committer.rootfs)
+	}
+	if committer.req.BaseRef != baseRef {
+		t.Fatalf("unexpected base ref %q", committer.req.BaseRef)
+	}
+}
+
+func TestCommitSandboxReportsExportFailure(t *testing.T) {
+	t.Parallel()
+
+	adapter := &Adapter{
+		newImageManager: func() (imageEnsurer, error) { return &fakeImageCommitter{}, nil },
+		sandboxes: map[string]*sandboxInstance{
+			"cr-test": {SandboxID: "cr-test", GuestPort: 10700},
+		},
+	}
+	adapter.runGuestCommandFn = func(_ context.Context, _ context.Context, _ <-chan struct{}, _ func() error, _ string, _ uint32, _ vsockexec.ExecRequest, stream backend.OutputStream) (vsockexec.ExecResponse, guestExecTiming, error) {
+		stream.OnStderr([]byte("export rootfs: disk on fire\n"))
+		return vsockexec.ExecResponse{ExitCode: 1}, guestExecTiming{}, nil
+	}
+
+	_, err := adapter.CommitSandbox(context.Background(), "cr-test", "ghcr.io/acme/img:ci")
+	if err == nil || !strings.Contains(err.Error(), "disk on fire") {
+		t.Fatalf("expected export failure to be reported, got %v", err)
+	}
+}
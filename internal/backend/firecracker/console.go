@@ -0,0 +1,110 @@
+package firecracker
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+// consoleLogFile is where Firecracker's stdout, and so the guest serial
+// console, is written in the VM's run directory.
+const consoleLogFile = "firecracker.stdout.log"
+
+// consoleScanLimit bounds how much console output is scanned after a run.
+const consoleScanLimit = 256 * 1024
+
+// consoleLogSize returns the current size of the console log in dir, so a
+// later scan can skip output from earlier executions.
+func consoleLogSize(dir string) int64 {
+	info, err := os.Stat(filepath.Join(dir, consoleLogFile))
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// readConsoleSince returns console output written after offset, capped to the
+// most recent consoleScanLimit bytes.
+func readConsoleSince(dir string, offset int64) []byte {
+	f, err := os.Open(filepath.Join(dir, consoleLogFile))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.Size() <= offset {
+		return nil
+	}
+	if info.Size()-offset > consoleScanLimit {
+		offset = info.Size() - consoleScanLimit
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil
+	}
+	data, _ := io.ReadAll(f)
+	return data
+}
+
+// detectGuestFailure looks for a kernel panic or OOM-killer report in console
+// output and returns the reason with the line that showed it. A panic wins
+// over an OOM kill, since an OOM can be what made the kernel panic.
+func detectGuestFailure(console []byte) (backend.GuestFailureReason, string) {
+	var reason backend.GuestFailureReason
+	var excerpt string
+	scanner := bufio.NewScanner(bytes.NewReader(console))
+	scanner.Buffer(make([]byte, 0, 4096), consoleScanLimit)
+	for scanner.Scan() {
+		line := consoleMessage(scanner.Text())
+		switch {
+		case strings.Contains(line, "Kernel panic - not syncing"):
+			return backend.GuestFailureKernelPanic, line
+		case reason == "" && (strings.Contains(line, "Out of memory: Killed process") ||
+			strings.Contains(line, "Memory cgroup out of memory: Killed process")):
+			reason, excerpt = backend.GuestFailureOOM, line
+		}
+	}
+	return reason, excerpt
+}
+
+// consoleMessage strips the printk timestamp and surrounding whitespace.
+func consoleMessage(line string) string {
+	line = strings.TrimSpace(strings.TrimRight(line, "\r"))
+	if strings.HasPrefix(line, "[") {
+		if end := strings.Index(line, "]"); end > 0 {
+			line = strings.TrimSpace(line[end+1:])
+		}
+	}
+	return line
+}
+
+// guestFailureFromConsole returns a GuestFailure when console output written
+// after offset in dir shows the kernel panicked or OOM-killed a process.
+func guestFailureFromConsole(dir string, offset, memoryMiB int64, cause error) *backend.GuestFailure {
+	reason, excerpt := detectGuestFailure(readConsoleSince(dir, offset))
+	if reason == "" {
+		return nil
+	}
+	return &backend.GuestFailure{Reason: reason, Excerpt: excerpt, MemoryMiB: memoryMiB, Err: cause}
+}
+
+// sigkill is the signal the OOM killer sends.
+const sigkill = 9
+
+// commandGuestFailure reports an OOM kill when the command died from SIGKILL
+// and the console shows the OOM killer ran during the execution.
+func commandGuestFailure(dir string, offset, memoryMiB int64, m *vsockexec.ExitMetadata) *backend.GuestFailure {
+	if m == nil || m.Signal != sigkill {
+		return nil
+	}
+	failure := guestFailureFromConsole(dir, offset, memoryMiB, nil)
+	if failure == nil || failure.Reason != backend.GuestFailureOOM {
+		return nil
+	}
+	return failure
+}
@@ -0,0 +1,159 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+func TestDetectGuestFailure(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		console     string
+		wantReason  backend.GuestFailureReason
+		wantExcerpt string
+	}{
+		{
+			name:    "clean boot",
+			console: "[    0.000000] Linux version 6.1\n[    1.200000] cleanroom-init: ready\n",
+		},
+		{
+			name:        "oom kill",
+			console:     "[   12.345678] node invoked oom-killer: gfp_mask=0x140cca\n[   12.345999] Out of memory: Killed process 412 (node) total-vm:912340kB, anon-rss:480000kB\n",
+			wantReason:  backend.GuestFailureOOM,
+			wantExcerpt: "Out of memory: Killed process 412 (node) total-vm:912340kB, anon-rss:480000kB",
+		},
+		{
+			name:        "cgroup oom kill",
+			console:     "[    3.1] Memory cgroup out of memory: Killed process 77 (cc1plus)\r\n",
+			wantReason:  backend.GuestFailureOOM,
+			wantExcerpt: "Memory cgroup out of memory: Killed process 77 (cc1plus)",
+		},
+		{
+			name:        "panic wins over oom",
+			console:     "[    5.0] Out of memory: Killed process 1 (init)\n[    5.1] Kernel panic - not syncing: Attempted to kill init! exitcode=0x00000009\n",
+			wantReason:  backend.GuestFailureKernelPanic,
+			wantExcerpt: "Kernel panic - not syncing: Attempted to kill init! exitcode=0x00000009",
+		},
+	}
+	for _, tc := range cases {
+		reason, excerpt := detectGuestFailure([]byte(tc.console))
+		if reason != tc.wantReason || excerpt != tc.wantExcerpt {
+			t.Fatalf("%s: got (%q, %q) want (%q, %q)", tc.name, reason, excerpt, tc.wantReason, tc.wantExcerpt)
+		}
+	}
+}
+
+func TestReadConsoleSinceSkipsEarlierOutput(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, consoleLogFile)
+	if err := os.WriteFile(path, []byte("Out of memory: Killed process 9 (old)\n"), 0o644); err != nil {
+		t.Fatalf("write console log: %v", err)
+	}
+	offset := consoleLogSize(dir)
+	if failure := guestFailureFromConsole(dir, offset, 512, nil); failure != nil {
+		t.Fatalf("expected earlier output to be skipped, got %v", failure)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open console log: %v", err)
+	}
+	if _, err := io.WriteString(f, "Kernel panic - not syncing: VFS\n"); err != nil {
+		t.Fatalf("append console log: %v", err)
+	}
+	_ = f.Close()
+	failure := guestFailureFromConsole(dir, offset, 512, nil)
+	if failure == nil || failure.Reason != backend.GuestFailureKernelPanic {
+		t.Fatalf("expected kernel panic after offset, got %v", failure)
+	}
+}
+
+func TestRunInSandboxReportsGuestOOMInsteadOfTransportError(t *testing.T) {
+	t.Parallel()
+
+	sandboxDir := t.TempDir()
+	transportErr := errors.New("decode guest exec response: EOF")
+	adapter := &Adapter{}
+	adapter.runGuestCommandFn = func(_ context.Context, _ context.Context, _ <-chan struct{}, _ func() error, _ string, _ uint32, req vsockexec.ExecRequest, _ backend.OutputStream) (vsockexec.ExecResponse, guestExecTiming, error) {
+		if req.Command[0] == "dmesg" {
+			return vsockexec.ExecResponse{}, guestExecTiming{}, errors.New("agent gone")
+		}
+		console := "[   40.1] Out of memory: Killed process 93 (cleanroom-guest) total-vm:1200kB\n"
+		if err := os.WriteFile(filepath.Join(sandboxDir, consoleLogFile), []byte(console), 0o644); err != nil {
+			t.Errorf("write console log: %v", err)
+		}
+		return vsockexec.ExecResponse{}, guestExecTiming{}, transportErr
+	}
+	adapter.sandboxes = map[string]*sandboxInstance{
+		"cr-test": {SandboxID: "cr-test", RunDir: sandboxDir, GuestPort: 10700, MemoryMiB: 512},
+	}
+
+	_, err := adapter.RunInSandbox(context.Background(), backend.RunRequest{
+		SandboxID:         "cr-test",
+		RunID:             "run-oom",
+		Command:           []string{"node", "build.js"},
+		FirecrackerConfig: backend.FirecrackerConfig{RunDir: t.TempDir()},
+	}, backend.OutputStream{})
+
+	var failure *backend.GuestFailure
+	if !errors.As(err, &failure) {
+		t.Fatalf("expected GuestFailure, got %v", err)
+	}
+	if got, want := failure.Reason, backend.GuestFailureOOM; got != want {
+		t.Fatalf("unexpected reason: got %q want %q", got, want)
+	}
+	if !errors.Is(err, transportErr) {
+		t.Fatalf("expected transport error to be wrapped, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "guest OOM-killed (memory_mib=512); consider raising sandbox.resources.memory_mib: Out of memory: Killed process 93") {
+		t.Fatalf("unexpected error message: %v", err)
+	}
+}
+
+func TestRunInSandboxFlagsOOMKilledCommand(t *testing.T) {
+	t.Parallel()
+
+	sandboxDir := t.TempDir()
+	adapter := &Adapter{}
+	adapter.runGuestCommandFn = func(_ context.Context, _ context.Context, _ <-chan struct{}, _ func() error, _ string, _ uint32, _ vsockexec.ExecRequest, _ backend.OutputStream) (vsockexec.ExecResponse, guestExecTiming, error) {
+		console := "[   40.1] Out of memory: Killed process 120 (cc1plus)\n"
+		if err := os.WriteFile(filepath.Join(sandboxDir, consoleLogFile), []byte(console), 0o644); err != nil {
+			t.Errorf("write console log: %v", err)
+		}
+		return vsockexec.ExecResponse{
+			ExitCode: 137,
+			Metadata: &vsockexec.ExitMetadata{Signal: 9, SignalName: "SIGKILL"},
+		}, guestExecTiming{}, nil
+	}
+	adapter.sandboxes = map[string]*sandboxInstance{
+		"cr-test": {SandboxID: "cr-test", RunDir: sandboxDir, GuestPort: 10700, MemoryMiB: 1024},
+	}
+
+	result, err := adapter.RunInSandbox(context.Background(), backend.RunRequest{
+		SandboxID:         "cr-test",
+		RunID:             "run-oom-cmd",
+		Command:           []string{"make"},
+		FirecrackerConfig: backend.FirecrackerConfig{RunDir: t.TempDir()},
+	}, backend.OutputStream{})
+	if err != nil {
+		t.Fatalf("RunInSandbox returned error: %v", err)
+	}
+	if result.Failure == nil || result.Failure.Reason != backend.GuestFailureOOM {
+		t.Fatalf("expected OOM failure on result, got %+v", result.Failure)
+	}
+	if !strings.Contains(result.Message, "memory_mib=1024") {
+		t.Fatalf("expected message to name the memory size, got %q", result.Message)
+	}
+}
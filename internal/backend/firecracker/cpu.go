@@ -0,0 +1,105 @@
+package firecracker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// cpuTemplateVendors maps Firecracker's static CPU templates to the host CPU
+// vendor each one is built for.
+var cpuTemplateVendors = map[string]string{
+	"C3":   "GenuineIntel",
+	"T2":   "GenuineIntel",
+	"T2S":  "GenuineIntel",
+	"T2CL": "GenuineIntel",
+	"T2A":  "AuthenticAMD",
+	"V1N1": "ARM",
+}
+
+// hostCPU is what doctor needs to know about the host processor.
+type hostCPU struct {
+	Arch   string
+	Vendor string
+	// SMTControl is the content of /sys/devices/system/cpu/smt/control:
+	// on, off, forceoff, notsupported or notimplemented. Empty when unknown.
+	SMTControl string
+}
+
+func readHostCPU() hostCPU {
+	cpu := hostCPU{Arch: runtime.GOARCH}
+	if data, err := os.ReadFile("/proc/cpuinfo"); err == nil {
+		cpu.Vendor = cpuVendor(data)
+	}
+	if data, err := os.ReadFile("/sys/devices/system/cpu/smt/control"); err == nil {
+		cpu.SMTControl = strings.TrimSpace(string(data))
+	}
+	return cpu
+}
+
+// cpuVendor returns the vendor_id from /proc/cpuinfo, or "ARM" on hosts that
+// report a CPU implementer instead.
+func cpuVendor(cpuinfo []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(cpuinfo))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "vendor_id":
+			return strings.TrimSpace(value)
+		case "CPU implementer":
+			return "ARM"
+		}
+	}
+	return ""
+}
+
+// checkCPUTemplate reports whether template can run on cpu. An empty
+// template, or "None", leaves the host CPU features unmasked.
+func checkCPUTemplate(template string, cpu hostCPU) (string, string) {
+	template = strings.TrimSpace(template)
+	if template == "" || template == "None" {
+		return "pass", fmt.Sprintf("no cpu_template configured; guests see the host CPU features (vendor %s)", vendorOrUnknown(cpu.Vendor))
+	}
+	want, ok := cpuTemplateVendors[template]
+	if !ok {
+		return "fail", fmt.Sprintf("unknown cpu_template %q", template)
+	}
+	if cpu.Vendor == "" {
+		return "warn", fmt.Sprintf("cpu_template %s needs a %s host; could not read the host CPU vendor", template, want)
+	}
+	if cpu.Vendor != want {
+		return "fail", fmt.Sprintf("cpu_template %s needs a %s host, this host is %s", template, want, cpu.Vendor)
+	}
+	return "pass", fmt.Sprintf("cpu_template %s is supported on this %s host", template, cpu.Vendor)
+}
+
+// checkSMT reports whether guests can be given SMT siblings.
+func checkSMT(enabled bool, cpu hostCPU) (string, string) {
+	if !enabled {
+		return "pass", fmt.Sprintf("guest SMT disabled (host smt control: %s)", vendorOrUnknown(cpu.SMTControl))
+	}
+	if cpu.Arch != "amd64" {
+		return "fail", fmt.Sprintf("guest SMT is only supported on x86_64 hosts, this host is %s", cpu.Arch)
+	}
+	switch cpu.SMTControl {
+	case "on":
+		return "pass", "guest SMT enabled and host SMT is on"
+	case "":
+		return "warn", "guest SMT enabled; could not read host SMT state"
+	default:
+		return "warn", fmt.Sprintf("guest SMT enabled but host SMT is %s; guest siblings will not map to host siblings", cpu.SMTControl)
+	}
+}
+
+func vendorOrUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
@@ -0,0 +1,61 @@
+package firecracker
+
+import "testing"
+
+func TestCPUVendor(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		cpuinfo string
+		want    string
+	}{
+		"intel": {cpuinfo: "processor\t: 0\nvendor_id\t: GenuineIntel\n", want: "GenuineIntel"},
+		"amd":   {cpuinfo: "processor\t: 0\nvendor_id\t: AuthenticAMD\n", want: "AuthenticAMD"},
+		"arm":   {cpuinfo: "processor\t: 0\nCPU implementer\t: 0x41\n", want: "ARM"},
+		"empty": {cpuinfo: "", want: ""},
+	} {
+		if got := cpuVendor([]byte(tc.cpuinfo)); got != tc.want {
+			t.Fatalf("%s: unexpected vendor: got %q want %q", name, got, tc.want)
+		}
+	}
+}
+
+func TestCheckCPUTemplate(t *testing.T) {
+	t.Parallel()
+
+	intel := hostCPU{Arch: "amd64", Vendor: "GenuineIntel"}
+	for _, tc := range []struct {
+		template string
+		cpu      hostCPU
+		want     string
+	}{
+		{template: "", cpu: intel, want: "pass"},
+		{template: "T2S", cpu: intel, want: "pass"},
+		{template: "T2A", cpu: intel, want: "fail"},
+		{template: "T2A", cpu: hostCPU{Arch: "amd64"}, want: "warn"},
+		{template: "bogus", cpu: intel, want: "fail"},
+	} {
+		if got, msg := checkCPUTemplate(tc.template, tc.cpu); got != tc.want {
+			t.Fatalf("template %q on %+v: got %s (%s) want %s", tc.template, tc.cpu, got, msg, tc.want)
+		}
+	}
+}
+
+func TestCheckSMT(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		enabled bool
+		cpu     hostCPU
+		want    string
+	}{
+		{enabled: false, cpu: hostCPU{Arch: "arm64"}, want: "pass"},
+		{enabled: true, cpu: hostCPU{Arch: "arm64", SMTControl: "notsupported"}, want: "fail"},
+		{enabled: true, cpu: hostCPU{Arch: "amd64", SMTControl: "on"}, want: "pass"},
+		{enabled: true, cpu: hostCPU{Arch: "amd64", SMTControl: "forceoff"}, want: "warn"},
+	} {
+		if got, msg := checkSMT(tc.enabled, tc.cpu); got != tc.want {
+			t.Fatalf("smt=%v on %+v: got %s (%s) want %s", tc.enabled, tc.cpu, got, msg, tc.want)
+		}
+	}
+}
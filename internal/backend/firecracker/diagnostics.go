@@ -0,0 +1,189 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/rundir"
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+const (
+	diagnosticsLogTailBytes = 64 * 1024
+	diagnosticsDmesgTimeout = 3 * time.Second
+)
+
+// vmDiagnostics describes the VM behind a failed execution.
+type vmDiagnostics struct {
+	// LogDir holds the serial console log and firecracker.stderr.log.
+	LogDir  string
+	Exited  <-chan struct{}
+	ExitErr func() error
+	Network hostNetworkConfig
+	// Exec runs a command through the guest agent.
+	Exec func(ctx context.Context, req vsockexec.ExecRequest) (vsockexec.ExecResponse, error)
+}
+
+type diagnosticsSummary struct {
+	Error       string    `json:"error"`
+	CollectedAt time.Time `json:"collected_at"`
+	VMExited    bool      `json:"vm_exited"`
+	VMExitError string    `json:"vm_exit_error,omitempty"`
+	TapName     string    `json:"tap_name,omitempty"`
+	HostIP      string    `json:"host_ip,omitempty"`
+	GuestIP     string    `json:"guest_ip,omitempty"`
+}
+
+// withDiagnostics collects a diagnostics bundle for a boot or guest agent
+// failure and points to it from the returned error. Failures the caller
+// caused by canceling ctx, and injected faults, are returned unchanged.
+func withDiagnostics(ctx context.Context, err error, runDir string, d vmDiagnostics) error {
+	if err == nil || ctx.Err() != nil || runDir == "" || errors.Is(err, ErrInjectedFault) {
+		return err
+	}
+	dir, collectErr := collectDiagnostics(runDir, err, d)
+	if collectErr != nil {
+		return err
+	}
+	return fmt.Errorf("%w (diagnostics: %s)", err, dir)
+}
+
+// collectDiagnostics writes a best-effort bundle to runDir/diagnostics and
+// returns its path. A piece that cannot be gathered is recorded as such
+// instead of failing the bundle.
+func collectDiagnostics(runDir string, cause error, d vmDiagnostics) (string, error) {
+	dir := filepath.Join(runDir, rundir.DiagnosticsDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	summary := diagnosticsSummary{
+		Error:       cause.Error(),
+		CollectedAt: time.Now().UTC(),
+		TapName:     d.Network.TapName,
+		HostIP:      d.Network.HostIP,
+		GuestIP:     d.Network.GuestIP,
+	}
+	select {
+	case <-d.Exited:
+		summary.VMExited = true
+		if d.ExitErr != nil {
+			if exitErr := d.ExitErr(); exitErr != nil {
+				summary.VMExitError = exitErr.Error()
+			}
+		}
+	default:
+	}
+
+	files := map[string][]byte{
+		"console.log":            tailFile(filepath.Join(d.LogDir, consoleLogFile), diagnosticsLogTailBytes),
+		"firecracker.stderr.log": tailFile(filepath.Join(d.LogDir, "firecracker.stderr.log"), diagnosticsLogTailBytes),
+		"network.txt":            networkDiagnostics(d.Network),
+	}
+	if summary.VMExited {
+		files["dmesg.txt"] = []byte("unavailable: vm exited\n")
+	} else {
+		files["dmesg.txt"] = guestDmesg(d.Exec)
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			return "", err
+		}
+	}
+	if err := writeJSON(filepath.Join(dir, "summary.json"), summary); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// tailFile returns up to the last limit bytes of path.
+func tailFile(path string, limit int64) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		return []byte(fmt.Sprintf("unavailable: %v\n", err))
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return []byte(fmt.Sprintf("unavailable: %v\n", err))
+	}
+	if info.Size() > limit {
+		if _, err := f.Seek(-limit, io.SeekEnd); err != nil {
+			return []byte(fmt.Sprintf("unavailable: %v\n", err))
+		}
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return []byte(fmt.Sprintf("unavailable: %v\n", err))
+	}
+	return data
+}
+
+func guestDmesg(run func(context.Context, vsockexec.ExecRequest) (vsockexec.ExecResponse, error)) []byte {
+	if run == nil {
+		return []byte("unavailable: no guest agent connection\n")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsDmesgTimeout)
+	defer cancel()
+	resp, err := run(ctx, vsockexec.ExecRequest{Command: []string{"dmesg"}})
+	if err != nil {
+		return []byte(fmt.Sprintf("unavailable: %v\n", err))
+	}
+	if resp.ExitCode != 0 {
+		return []byte(fmt.Sprintf("unavailable: dmesg exited %d: %s\n", resp.ExitCode, resp.Stderr))
+	}
+	return []byte(resp.Stdout)
+}
+
+func networkDiagnostics(cfg hostNetworkConfig) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "tap=%s host_ip=%s guest_ip=%s\n", cfg.TapName, cfg.HostIP, cfg.GuestIP)
+	if cfg.Namespace != "" {
+		// Reading another namespace needs root, which diagnostics do not use.
+		fmt.Fprintf(&buf, "netns=%s (inspect with: sudo ip -n %s -s link show dev %s)\n", cfg.Namespace, cfg.Namespace, cfg.TapName)
+		return buf.Bytes()
+	}
+	if cfg.TapName == "" {
+		return buf.Bytes()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsDmesgTimeout)
+	defer cancel()
+	for _, args := range [][]string{
+		{"ip", "-s", "link", "show", "dev", cfg.TapName},
+		{"ip", "addr", "show", "dev", cfg.TapName},
+	} {
+		out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+		fmt.Fprintf(&buf, "\n$ %s\n%s", strings.Join(args, " "), out)
+		if err != nil {
+			fmt.Fprintf(&buf, "(%v)\n", err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// guestExecCollector adapts a guest command runner for diagnostics, which
+// only need buffered output.
+func guestExecCollector(run func(ctx context.Context, req vsockexec.ExecRequest, stream backend.OutputStream) (vsockexec.ExecResponse, error)) func(context.Context, vsockexec.ExecRequest) (vsockexec.ExecResponse, error) {
+	return func(ctx context.Context, req vsockexec.ExecRequest) (vsockexec.ExecResponse, error) {
+		var stdout bytes.Buffer
+		resp, err := run(ctx, req, backend.OutputStream{OnStdout: func(chunk []byte) {
+			_, _ = stdout.Write(chunk)
+		}})
+		if err != nil {
+			return resp, err
+		}
+		if stdout.Len() > 0 {
+			resp.Stdout = stdout.String()
+		}
+		return resp, nil
+	}
+}
@@ -0,0 +1,147 @@
+package firecracker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/rundir"
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+func TestCollectDiagnosticsWritesBundle(t *testing.T) {
+	t.Parallel()
+
+	logDir := t.TempDir()
+	console := strings.Repeat("x", diagnosticsLogTailBytes) + "Kernel panic - not syncing\n"
+	if err := os.WriteFile(filepath.Join(logDir, "firecracker.stdout.log"), []byte(console), 0o644); err != nil {
+		t.Fatalf("write console log: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(logDir, "firecracker.stderr.log"), []byte("vmm error\n"), 0o644); err != nil {
+		t.Fatalf("write stderr log: %v", err)
+	}
+
+	runDir := t.TempDir()
+	dir, err := collectDiagnostics(runDir, errors.New("guest agent did not respond"), vmDiagnostics{
+		LogDir:  logDir,
+		Network: hostNetworkConfig{HostIP: "10.1.2.1", GuestIP: "10.1.2.2"},
+		Exec: func(_ context.Context, req vsockexec.ExecRequest) (vsockexec.ExecResponse, error) {
+			if got, want := strings.Join(req.Command, " "), "dmesg"; got != want {
+				t.Errorf("unexpected diagnostics command: got %q want %q", got, want)
+			}
+			return vsockexec.ExecResponse{Stdout: "[    0.000000] Linux version\n"}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("collectDiagnostics returned error: %v", err)
+	}
+	if got, want := dir, filepath.Join(runDir, rundir.DiagnosticsDir); got != want {
+		t.Fatalf("unexpected bundle dir: got %q want %q", got, want)
+	}
+
+	read := func(name string) string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		return string(b)
+	}
+	if got := read("console.log"); int64(len(got)) != diagnosticsLogTailBytes || !strings.HasSuffix(got, "Kernel panic - not syncing\n") {
+		t.Fatalf("expected console tail of %d bytes ending in the panic, got %d bytes", diagnosticsLogTailBytes, len(got))
+	}
+	if got, want := read("firecracker.stderr.log"), "vmm error\n"; got != want {
+		t.Fatalf("unexpected stderr log: got %q want %q", got, want)
+	}
+	if got := read("dmesg.txt"); !strings.Contains(got, "Linux version") {
+		t.Fatalf("unexpected dmesg: %q", got)
+	}
+	if got := read("network.txt"); !strings.Contains(got, "guest_ip=10.1.2.2") {
+		t.Fatalf("unexpected network state: %q", got)
+	}
+
+	var summary diagnosticsSummary
+	if err := json.Unmarshal([]byte(read("summary.json")), &summary); err != nil {
+		t.Fatalf("parse summary: %v", err)
+	}
+	if got, want := summary.Error, "guest agent did not respond"; got != want {
+		t.Fatalf("unexpected summary error: got %q want %q", got, want)
+	}
+}
+
+func TestCollectDiagnosticsSkipsDmesgAfterVMExit(t *testing.T) {
+	t.Parallel()
+
+	exited := make(chan struct{})
+	close(exited)
+	dir, err := collectDiagnostics(t.TempDir(), errors.New("boot failed"), vmDiagnostics{
+		LogDir:  t.TempDir(),
+		Exited:  exited,
+		ExitErr: func() error { return errors.New("exit status 1") },
+		Exec: func(context.Context, vsockexec.ExecRequest) (vsockexec.ExecResponse, error) {
+			t.Error("dmesg should not be requested from an exited vm")
+			return vsockexec.ExecResponse{}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("collectDiagnostics returned error: %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "summary.json"))
+	if err != nil {
+		t.Fatalf("read summary: %v", err)
+	}
+	var summary diagnosticsSummary
+	if err := json.Unmarshal(b, &summary); err != nil {
+		t.Fatalf("parse summary: %v", err)
+	}
+	if !summary.VMExited || summary.VMExitError != "exit status 1" {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestWithDiagnosticsLeavesCanceledRunsAlone(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runDir := t.TempDir()
+	cause := errors.New("context canceled")
+	if got := withDiagnostics(ctx, cause, runDir, vmDiagnostics{}); got != cause {
+		t.Fatalf("expected error unchanged, got %v", got)
+	}
+	if _, err := os.Stat(filepath.Join(runDir, rundir.DiagnosticsDir)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no bundle, got err=%v", err)
+	}
+}
+
+func TestRunInSandboxReferencesDiagnosticsOnAgentFailure(t *testing.T) {
+	t.Parallel()
+
+	runDir := t.TempDir()
+	adapter := &Adapter{}
+	adapter.runGuestCommandFn = func(_ context.Context, _ context.Context, _ <-chan struct{}, _ func() error, _ string, _ uint32, _ vsockexec.ExecRequest, _ backend.OutputStream) (vsockexec.ExecResponse, guestExecTiming, error) {
+		return vsockexec.ExecResponse{}, guestExecTiming{}, errors.New("guest agent connection reset")
+	}
+	adapter.sandboxes = map[string]*sandboxInstance{
+		"cr-test": {SandboxID: "cr-test", RunDir: t.TempDir(), GuestPort: 10700},
+	}
+
+	_, err := adapter.RunInSandbox(context.Background(), backend.RunRequest{
+		SandboxID:         "cr-test",
+		RunID:             "run-diag",
+		Command:           []string{"true"},
+		FirecrackerConfig: backend.FirecrackerConfig{RunDir: runDir},
+	}, backend.OutputStream{})
+	bundle := filepath.Join(runDir, rundir.DiagnosticsDir)
+	if err == nil || !strings.Contains(err.Error(), "diagnostics: "+bundle) {
+		t.Fatalf("expected error to reference %s, got %v", bundle, err)
+	}
+	if _, err := os.Stat(filepath.Join(bundle, "summary.json")); err != nil {
+		t.Fatalf("expected diagnostics summary: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package firecracker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/hosttools"
+)
+
+// growRootFS extends the per-VM rootfs copy at path to diskMiB and grows its
+// ext4 filesystem to match. Images already at least that large are left
+// alone; rootfs images are never shrunk.
+func growRootFS(ctx context.Context, path string, diskMiB int64, resize func(ctx context.Context, path string) error) error {
+	if diskMiB <= 0 {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	size := diskMiB * 1024 * 1024
+	if info.Size() >= size {
+		return nil
+	}
+	if err := os.Truncate(path, size); err != nil {
+		return fmt.Errorf("extend rootfs to %d MiB: %w", diskMiB, err)
+	}
+	if err := resize(ctx, path); err != nil {
+		return fmt.Errorf("grow rootfs filesystem to %d MiB: %w", diskMiB, err)
+	}
+	return nil
+}
+
+// resize2fs grows the ext4 filesystem in the image at path to fill the file.
+func resize2fs(ctx context.Context, path string) error {
+	bin, err := hosttools.ResolveE2FSProgsBinary("resize2fs")
+	if err != nil {
+		return err
+	}
+	out, err := exec.CommandContext(ctx, bin, "-f", path).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// createScratchDisk writes an empty ext4 filesystem of sizeMiB to path. The
+// file is sparse, so unused space costs nothing on the host.
+func createScratchDisk(ctx context.Context, path string, sizeMiB int64) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(sizeMiB * 1024 * 1024); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	bin, err := hosttools.ResolveE2FSProgsBinary("mkfs.ext4")
+	if err != nil {
+		return err
+	}
+	out, err := exec.CommandContext(ctx, bin, "-q", "-F", path).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
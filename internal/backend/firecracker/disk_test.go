@@ -0,0 +1,66 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGrowRootFSExtendsSmallerImages(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "rootfs.ext4")
+	if err := os.WriteFile(path, make([]byte, 1024*1024), 0o644); err != nil {
+		t.Fatalf("write rootfs: %v", err)
+	}
+	resized := 0
+	resize := func(context.Context, string) error {
+		resized++
+		return nil
+	}
+
+	if err := growRootFS(context.Background(), path, 4, resize); err != nil {
+		t.Fatalf("growRootFS returned error: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat rootfs: %v", err)
+	}
+	if got, want := info.Size(), int64(4*1024*1024); got != want {
+		t.Fatalf("unexpected rootfs size: got %d want %d", got, want)
+	}
+
+	// A request no larger than the image is a no-op.
+	if err := growRootFS(context.Background(), path, 2, resize); err != nil {
+		t.Fatalf("growRootFS returned error: %v", err)
+	}
+	if got, want := resized, 1; got != want {
+		t.Fatalf("unexpected resize calls: got %d want %d", got, want)
+	}
+}
+
+func TestApplyDriveRateLimitsCapsEveryDrive(t *testing.T) {
+	t.Parallel()
+
+	cfg := firecrackerConfig{Drives: []drive{{DriveID: "rootfs", IsRootDevice: true}, {DriveID: "scratch0"}}}
+	applyDriveRateLimits(&cfg, 0, 0)
+	if cfg.Drives[0].RateLimiter != nil {
+		t.Fatalf("expected no rate limiter without limits, got %+v", cfg.Drives[0].RateLimiter)
+	}
+
+	applyDriveRateLimits(&cfg, 0, 50)
+	for _, d := range cfg.Drives {
+		if d.RateLimiter == nil || d.RateLimiter.Ops != nil {
+			t.Fatalf("expected a bandwidth-only limiter on %s, got %+v", d.DriveID, d.RateLimiter)
+		}
+		if want := (tokenBucket{Size: 50 << 20, RefillTime: 1000}); *d.RateLimiter.Bandwidth != want {
+			t.Fatalf("unexpected bandwidth bucket on %s: got %+v want %+v", d.DriveID, *d.RateLimiter.Bandwidth, want)
+		}
+	}
+
+	applyDriveRateLimits(&cfg, 3000, 0)
+	if got := cfg.Drives[1].RateLimiter; got.Bandwidth != nil || got.Ops == nil || got.Ops.Size != 3000 {
+		t.Fatalf("unexpected ops-only limiter: %+v", got)
+	}
+}
@@ -0,0 +1,104 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+// dockerArchiver is implemented by image managers that can produce
+// `docker load` tarballs from the host image cache.
+type dockerArchiver interface {
+	DockerArchive(ctx context.Context, ref string) (string, error)
+}
+
+const dockerLoadChunkSize = 256 * 1024
+
+// preloadDockerImages loads each ref into the guest's dockerd, streaming the
+// cached archive over vsock so the guest needs no registry access.
+func (a *Adapter) preloadDockerImages(ctx context.Context, instance *sandboxInstance, refs []string) error {
+	if len(refs) == 0 {
+		return nil
+	}
+	manager, err := a.getImageManager()
+	if err != nil {
+		return err
+	}
+	archiver, ok := manager.(dockerArchiver)
+	if !ok {
+		return errors.New("image manager cannot produce docker archives")
+	}
+	for _, ref := range refs {
+		path, err := archiver.DockerArchive(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("preload docker image %s: %w", ref, err)
+		}
+		if err := a.loadDockerArchive(ctx, instance, path); err != nil {
+			return fmt.Errorf("preload docker image %s: %w", ref, err)
+		}
+	}
+	return nil
+}
+
+func (a *Adapter) loadDockerArchive(ctx context.Context, instance *sandboxInstance, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var stderr bytes.Buffer
+	copied := make(chan error, 1)
+	resp, _, err := a.executeInSandbox(ctx, instance, 0, vsockexec.ExecRequest{
+		Command: []string{"docker", "load", "--quiet"},
+		Stdin:   true,
+	}, backend.OutputStream{
+		OnStderr: func(chunk []byte) {
+			_, _ = stderr.Write(chunk)
+		},
+		OnAttach: func(attach backend.AttachIO) {
+			go func() {
+				copied <- streamToStdin(f, attach)
+			}()
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if resp.ExitCode != 0 {
+		msg := strings.TrimSpace(stderr.String() + resp.Stderr)
+		if msg == "" {
+			msg = fmt.Sprintf("docker load exited %d", resp.ExitCode)
+		}
+		return errors.New(msg)
+	}
+	if err := <-copied; err != nil {
+		return fmt.Errorf("stream archive: %w", err)
+	}
+	return nil
+}
+
+func streamToStdin(r io.Reader, attach backend.AttachIO) error {
+	buf := make([]byte, dockerLoadChunkSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if writeErr := attach.WriteStdin(append([]byte(nil), buf[:n]...)); writeErr != nil {
+				return writeErr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return attach.CloseStdin()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
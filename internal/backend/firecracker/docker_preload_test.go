@@ -0,0 +1,111 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/imagemgr"
+	"github.com/buildkite/cleanroom/internal/policy"
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+type fakeDockerArchiver struct {
+	paths map[string]string
+}
+
+func (f *fakeDockerArchiver) Ensure(context.Context, string) (imagemgr.EnsureResult, error) {
+	return imagemgr.EnsureResult{}, nil
+}
+
+func (f *fakeDockerArchiver) DockerArchive(_ context.Context, ref string) (string, error) {
+	return f.paths[ref], nil
+}
+
+func TestProvisionSandboxPreloadsDockerImages(t *testing.T) {
+	t.Parallel()
+
+	const ref = "ghcr.io/acme/tool:1.2@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	archive := filepath.Join(t.TempDir(), "tool.tar")
+	payload := bytes.Repeat([]byte("layer"), dockerLoadChunkSize/2)
+	if err := os.WriteFile(archive, payload, 0o644); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+
+	var loaded bytes.Buffer
+	var command []string
+	adapter := &Adapter{
+		newImageManager: func() (imageEnsurer, error) {
+			return &fakeDockerArchiver{paths: map[string]string{ref: archive}}, nil
+		},
+		launchSandboxVMFn: func(_ context.Context, sandboxID, _ string, _ *policy.CompiledPolicy, _ backend.FirecrackerConfig, _ []backend.HostResolution) (*sandboxInstance, error) {
+			return &sandboxInstance{SandboxID: sandboxID, GuestPort: 10700}, nil
+		},
+	}
+	adapter.runGuestCommandFn = func(_ context.Context, _ context.Context, _ <-chan struct{}, _ func() error, _ string, _ uint32, req vsockexec.ExecRequest, stream backend.OutputStream) (vsockexec.ExecResponse, guestExecTiming, error) {
+		command = req.Command
+		closed := make(chan struct{})
+		stream.OnAttach(backend.AttachIO{
+			WriteStdin: func(data []byte) error {
+				loaded.Write(data)
+				return nil
+			},
+			CloseStdin: func() error {
+				close(closed)
+				return nil
+			},
+		})
+		<-closed
+		return vsockexec.ExecResponse{ExitCode: 0}, guestExecTiming{}, nil
+	}
+
+	compiled := &policy.CompiledPolicy{Services: policy.Services{Docker: policy.DockerService{Required: true, Preload: []string{ref}}}}
+	if err := adapter.ProvisionSandbox(context.Background(), backend.ProvisionRequest{SandboxID: "cr-test", Policy: compiled}); err != nil {
+		t.Fatalf("ProvisionSandbox returned error: %v", err)
+	}
+	if got, want := strings.Join(command, " "), "docker load --quiet"; got != want {
+		t.Fatalf("unexpected guest command: got %q want %q", got, want)
+	}
+	if !bytes.Equal(loaded.Bytes(), payload) {
+		t.Fatalf("unexpected archive bytes streamed: got %d want %d", loaded.Len(), len(payload))
+	}
+}
+
+func TestProvisionSandboxFailsWhenDockerLoadFails(t *testing.T) {
+	t.Parallel()
+
+	const ref = "alpine@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	archive := filepath.Join(t.TempDir(), "alpine.tar")
+	if err := os.WriteFile(archive, []byte("not a tarball"), 0o644); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+	adapter := &Adapter{
+		newImageManager: func() (imageEnsurer, error) {
+			return &fakeDockerArchiver{paths: map[string]string{ref: archive}}, nil
+		},
+		launchSandboxVMFn: func(_ context.Context, sandboxID, _ string, _ *policy.CompiledPolicy, _ backend.FirecrackerConfig, _ []backend.HostResolution) (*sandboxInstance, error) {
+			return &sandboxInstance{SandboxID: sandboxID}, nil
+		},
+	}
+	adapter.runGuestCommandFn = func(_ context.Context, _ context.Context, _ <-chan struct{}, _ func() error, _ string, _ uint32, _ vsockexec.ExecRequest, stream backend.OutputStream) (vsockexec.ExecResponse, guestExecTiming, error) {
+		stream.OnAttach(backend.AttachIO{
+			WriteStdin: func([]byte) error { return nil },
+			CloseStdin: func() error { return nil },
+		})
+		stream.OnStderr([]byte("open /var/lib/docker/tmp/docker-import: unexpected EOF\n"))
+		return vsockexec.ExecResponse{ExitCode: 1}, guestExecTiming{}, nil
+	}
+
+	compiled := &policy.CompiledPolicy{Services: policy.Services{Docker: policy.DockerService{Required: true, Preload: []string{ref}}}}
+	err := adapter.ProvisionSandbox(context.Background(), backend.ProvisionRequest{SandboxID: "cr-test", Policy: compiled})
+	if err == nil || !strings.Contains(err.Error(), "preload docker image "+ref+": open /var/lib/docker/tmp/docker-import: unexpected EOF") {
+		t.Fatalf("expected docker load error, got %v", err)
+	}
+	if _, ok := adapter.sandboxes["cr-test"]; ok {
+		t.Fatal("expected failed sandbox not to be registered")
+	}
+}
@@ -0,0 +1,233 @@
+package firecracker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/caphelper"
+	"github.com/buildkite/cleanroom/internal/paths"
+	"github.com/buildkite/cleanroom/internal/rundir"
+)
+
+// doctorCanary boots a throwaway VM from the repository policy's image and
+// runs a trivial guest command. It catches problems that static checks
+// cannot see, such as nested KVM quirks or a kernel that never brings up
+// vsock, and reports where boot time went.
+func (a *Adapter) doctorCanary(ctx context.Context, req backend.DoctorRequest) []backend.DoctorCheck {
+	if req.Policy == nil || strings.TrimSpace(req.Policy.ImageRef) == "" {
+		return []backend.DoctorCheck{{
+			Name:        "canary_boot",
+			Status:      "warn",
+			Message:     "skipped: canary VM needs a policy with sandbox.image.ref",
+			Remediation: "run doctor --deep from a repository whose cleanroom.yaml sets sandbox.image.ref",
+		}}
+	}
+
+	baseDir, err := paths.RunBaseDir()
+	if err != nil {
+		return []backend.DoctorCheck{{Name: "canary_boot", Status: "fail", Message: fmt.Sprintf("resolve run base directory: %v", err)}}
+	}
+	runID := fmt.Sprintf("doctor-canary-%d", time.Now().UnixNano())
+	runDir := filepath.Join(baseDir, runID)
+
+	cfg := req.FirecrackerConfig
+	cfg.RunDir = runDir
+	cfg.Launch = true
+	cfg.GuestCID = 0
+	result, runErr := a.run(ctx, backend.RunRequest{
+		RunID:             runID,
+		Command:           []string{"true"},
+		Policy:            req.Policy,
+		FirecrackerConfig: cfg,
+	}, backend.OutputStream{})
+
+	var observation firecrackerRunObservation
+	if raw, err := os.ReadFile(filepath.Join(runDir, rundir.ObservabilityFile)); err == nil {
+		_ = json.Unmarshal(raw, &observation)
+	}
+	checks := canaryChecks(observation, result, runErr, runDir, len(req.Policy.Allow))
+	if runErr == nil && result != nil && result.ExitCode == 0 {
+		_ = os.RemoveAll(runDir)
+	}
+	return checks
+}
+
+// canaryChecks turns the outcome of a canary run into doctor checks. It is
+// separate from doctorCanary so the reporting can be tested without a VM.
+func canaryChecks(observation firecrackerRunObservation, result *backend.RunResult, runErr error, runDir string, allowRules int) []backend.DoctorCheck {
+	durations := map[string]int64{
+		"firecracker_start": observation.FirecrackerStartMS,
+		"socket_ready":      observation.SocketReadyMS,
+		"network_setup":     observation.NetworkSetupMS,
+		"vm_ready":          observation.VMReadyMS,
+		"vsock_wait":        observation.VsockWaitMS,
+		"guest_exec":        observation.GuestExecMS,
+		"cleanup":           observation.CleanupMS,
+		"total":             observation.TotalMS,
+	}
+	for key, value := range durations {
+		if value == 0 {
+			delete(durations, key)
+		}
+	}
+
+	failed := func(name, message string) backend.DoctorCheck {
+		return backend.DoctorCheck{
+			Name:        name,
+			Status:      "fail",
+			Message:     message,
+			Remediation: doctorRemediation(name),
+		}
+	}
+
+	var checks []backend.DoctorCheck
+	if observation.NetworkTap != "" {
+		checks = append(checks, backend.DoctorCheck{
+			Name:        "canary_network_policy",
+			Status:      "pass",
+			Message:     fmt.Sprintf("programmed %d policy allow entries on %s", allowRules, observation.NetworkTap),
+			DurationsMS: pickDurations(durations, "network_setup"),
+		})
+	} else if runErr != nil && strings.Contains(runErr.Error(), "setup host network") {
+		checks = append(checks, failed("canary_network_policy", runErr.Error()))
+	}
+
+	if runErr != nil {
+		message := fmt.Sprintf("canary VM failed: %v (logs in %s)", runErr, runDir)
+		check := failed("canary_boot", message)
+		check.DurationsMS = durations
+		checks = append(checks, check)
+		if observation.FirecrackerStartMS > 0 && observation.VMReadyMS == 0 {
+			checks = append(checks, failed("canary_vsock", "guest agent never answered over vsock"))
+		}
+		return checks
+	}
+
+	checks = append(checks, backend.DoctorCheck{
+		Name:        "canary_vsock",
+		Status:      "pass",
+		Message:     fmt.Sprintf("guest agent answered over vsock after %dms", observation.VMReadyMS),
+		DurationsMS: pickDurations(durations, "vm_ready", "vsock_wait"),
+	})
+	if result.ExitCode != 0 {
+		check := failed("canary_boot", fmt.Sprintf("canary command exited %d (logs in %s)", result.ExitCode, runDir))
+		check.DurationsMS = durations
+		return append(checks, check)
+	}
+	return append(checks, backend.DoctorCheck{
+		Name:        "canary_boot",
+		Status:      "pass",
+		Message:     fmt.Sprintf("canary VM booted and ran a guest command in %dms", observation.TotalMS),
+		DurationsMS: durations,
+	})
+}
+
+func pickDurations(durations map[string]int64, keys ...string) map[string]int64 {
+	out := map[string]int64{}
+	for _, key := range keys {
+		if value, ok := durations[key]; ok {
+			out[key] = value
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// capHelperChecks checks that the capability helper at path, and the root
+// helper script it runs, are installed for privileged_mode caps.
+func capHelperChecks(path string) []backend.DoctorCheck {
+	helper := backend.DoctorCheck{Name: "network_cap_helper", Status: "pass", Message: fmt.Sprintf("capability helper %q has %s", path, caphelper.SetcapSpec())}
+	if caps, err := caphelper.ReadFileCaps(path); err != nil {
+		helper.Status, helper.Message = "fail", fmt.Sprintf("read file capabilities of %q: %v", path, err)
+	} else if missing := caphelper.Missing(caps.Permitted); len(missing) > 0 || !caps.Effective {
+		helper.Status, helper.Message = "fail", fmt.Sprintf("capability helper %q lacks %s (missing %s)", path, caphelper.SetcapSpec(), strings.Join(missing, ","))
+	} else if err := checkRootOwned(path); err != nil {
+		helper.Status, helper.Message = "fail", err.Error()
+	}
+
+	access := backend.DoctorCheck{Name: "network_cap_helper_access", Status: "pass", Message: fmt.Sprintf("capability helper %q is not executable by other users", path)}
+	if info, err := os.Stat(path); err != nil {
+		access.Status, access.Message = "fail", err.Error()
+	} else if info.Mode().Perm()&0o001 != 0 {
+		access.Status, access.Message = "fail", fmt.Sprintf("capability helper %q is executable by any user", path)
+	}
+
+	script := backend.DoctorCheck{Name: "network_cap_helper_script", Status: "pass", Message: fmt.Sprintf("found root helper script %q", caphelper.ScriptPath)}
+	if err := checkRootOwned(caphelper.ScriptPath); err != nil {
+		script.Status, script.Message = "fail", err.Error()
+	}
+	return []backend.DoctorCheck{helper, access, script}
+}
+
+// checkRootOwned fails unless path is a regular file that only root can
+// change.
+func checkRootOwned(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !info.Mode().IsRegular() || !ok || st.Uid != 0 || info.Mode().Perm()&0o022 != 0 {
+		return fmt.Errorf("%q must be a regular file owned by root and writable only by root", path)
+	}
+	return nil
+}
+
+// doctorRemediation returns a fix-it hint for a doctor check that did not
+// pass. Hints are keyed by check name so they stay attached to the stable
+// check ID rather than to a particular failure message.
+func doctorRemediation(name string) string {
+	if cmd, ok := strings.CutPrefix(name, "network_cmd_"); ok {
+		return "install " + cmd + " on the host and make sure it is on PATH"
+	}
+	switch name {
+	case "os":
+		return "run the firecracker backend on a Linux host, or select darwin-vz on macOS"
+	case "binary":
+		return "install Firecracker and put it on PATH, or set backends.firecracker.binary_path"
+	case "kvm":
+		return "enable KVM (load kvm_intel or kvm_amd) and give this user read-write access to /dev/kvm, for example via the kvm group"
+	case "kernel_image":
+		return "unset backends.firecracker.kernel_image to use the managed kernel, or point it at a readable vmlinux"
+	case "kernel_compat":
+		return "rebuild the kernel with the listed options built in and CONFIG_IKCONFIG=y, or unset backends.firecracker.kernel_image to use the managed kernel"
+	case "guest_agent_binary":
+		return "install cleanroom-guest-agent alongside cleanroom (mise run install)"
+	case "sandbox_image_ref":
+		return "set sandbox.image.ref in cleanroom.yaml"
+	case "cpu_template":
+		return "set backends.firecracker.cpu_template to a template for this host's CPU vendor, or unset it"
+	case "nested_virtualization":
+		return "load kvm_intel or kvm_amd with nested=1, or remove sandbox.nested_virtualization from the policy"
+	case "smt":
+		return "unset backends.firecracker.smt, or enable SMT on the host"
+	case "mkfs_ext4":
+		return "install e2fsprogs so mkfs.ext4 is available"
+	case "network_policy_rules":
+		return "run doctor from a repository with a cleanroom.yaml"
+	case "network_helper":
+		return "install the privileged helper or set backends.firecracker.privileged_mode: sudo"
+	case "network_cap_helper":
+		return "run sudo cleanroom install-cap-helper"
+	case "network_cap_helper_access":
+		return "run sudo cleanroom install-cap-helper to reinstall it as mode 0750 for the cleanroom group"
+	case "network_cap_helper_script":
+		return "sudo install -o root -g root -m 0755 scripts/cleanroom-root-helper.sh " + caphelper.ScriptPath
+	case "network_privileged_probe", "network_privileged_ip", "canary_network_policy":
+		return "allow passwordless sudo for ip, iptables and sysctl, or configure the privileged helper"
+	case "canary_boot":
+		return "fix the failing static checks first, then inspect firecracker.stderr.log in the canary run directory"
+	case "canary_vsock":
+		return "check that the kernel has virtio-vsock enabled and that the guest agent is installed in the rootfs"
+	}
+	return ""
+}
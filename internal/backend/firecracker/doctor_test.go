@@ -0,0 +1,83 @@
+package firecracker
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+)
+
+func canaryCheckByName(checks []backend.DoctorCheck, name string) (backend.DoctorCheck, bool) {
+	for _, check := range checks {
+		if check.Name == name {
+			return check, true
+		}
+	}
+	return backend.DoctorCheck{}, false
+}
+
+func TestCanaryChecksReportTimingsOnSuccess(t *testing.T) {
+	t.Parallel()
+
+	checks := canaryChecks(firecrackerRunObservation{
+		NetworkTap:         "crcanary",
+		FirecrackerStartMS: 3,
+		NetworkSetupMS:     40,
+		VMReadyMS:          180,
+		VsockWaitMS:        170,
+		GuestExecMS:        2,
+		TotalMS:            260,
+	}, &backend.RunResult{ExitCode: 0}, nil, "/tmp/run", 2)
+
+	for _, name := range []string{"canary_network_policy", "canary_vsock", "canary_boot"} {
+		check, ok := canaryCheckByName(checks, name)
+		if !ok {
+			t.Fatalf("missing %s check in %v", name, checks)
+		}
+		if check.Status != "pass" {
+			t.Fatalf("unexpected %s status: got %q want pass (%s)", name, check.Status, check.Message)
+		}
+	}
+	boot, _ := canaryCheckByName(checks, "canary_boot")
+	if got, want := boot.DurationsMS["vm_ready"], int64(180); got != want {
+		t.Fatalf("unexpected vm_ready duration: got %d want %d", got, want)
+	}
+	if _, ok := boot.DurationsMS["cleanup"]; ok {
+		t.Fatalf("expected unmeasured phases to be omitted, got %v", boot.DurationsMS)
+	}
+}
+
+func TestCanaryChecksFlagsMissingVsockHandshake(t *testing.T) {
+	t.Parallel()
+
+	checks := canaryChecks(firecrackerRunObservation{
+		NetworkTap:         "crcanary",
+		FirecrackerStartMS: 3,
+	}, nil, errors.New("context deadline exceeded"), "/tmp/run", 0)
+
+	boot, ok := canaryCheckByName(checks, "canary_boot")
+	if !ok || boot.Status != "fail" || boot.Remediation == "" {
+		t.Fatalf("expected failing canary_boot with remediation, got %+v", boot)
+	}
+	vsock, ok := canaryCheckByName(checks, "canary_vsock")
+	if !ok || vsock.Status != "fail" {
+		t.Fatalf("expected failing canary_vsock, got %+v", vsock)
+	}
+	if network, ok := canaryCheckByName(checks, "canary_network_policy"); !ok || network.Status != "pass" {
+		t.Fatalf("expected network programming to pass, got %+v", network)
+	}
+}
+
+func TestCanaryChecksFlagsNetworkSetupFailure(t *testing.T) {
+	t.Parallel()
+
+	checks := canaryChecks(firecrackerRunObservation{}, nil, errors.New("setup host network: iptables: permission denied"), "/tmp/run", 1)
+
+	network, ok := canaryCheckByName(checks, "canary_network_policy")
+	if !ok || network.Status != "fail" {
+		t.Fatalf("expected failing canary_network_policy, got %+v", network)
+	}
+	if _, ok := canaryCheckByName(checks, "canary_vsock"); ok {
+		t.Fatal("did not expect a vsock check when the VM never started")
+	}
+}
@@ -0,0 +1,113 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/policy"
+)
+
+// EgressVerdict says whether a guest connection to one resolved
+// destination address would pass the sandbox's forward rules.
+type EgressVerdict struct {
+	Protocol string `json:"protocol"`
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	Allowed  bool   `json:"allowed"`
+	// Rule is the allow entry's host, or "dns" for the guest resolver rule.
+	// It is empty when the default deny rule applies.
+	Rule string `json:"rule,omitempty"`
+}
+
+// SimulateEgress resolves allow the way sandbox network setup does and
+// reports a verdict for each IPv4 address dest resolves to. port is ignored
+// for icmp. Nothing on the
+// host is changed. An allow entry that does not resolve fails the
+// simulation, as it would fail sandbox creation.
+func SimulateEgress(ctx context.Context, allow []policy.AllowRule, dest string, port int, protocol string) ([]EgressVerdict, error) {
+	lookup := func(ctx context.Context, host string) ([]net.IP, error) {
+		return net.DefaultResolver.LookupIP(ctx, "ip4", host)
+	}
+	return simulateEgressWithLookup(ctx, allow, dest, port, protocol, lookup)
+}
+
+func simulateEgressWithLookup(ctx context.Context, allow []policy.AllowRule, dest string, port int, protocol string, lookup ipLookupFunc) ([]EgressVerdict, error) {
+	dest = strings.TrimSpace(strings.ToLower(dest))
+	if dest == "" {
+		return nil, errors.New("missing destination host")
+	}
+	switch protocol {
+	case "tcp", "udp":
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid destination port %d", port)
+		}
+	case "icmp":
+		port = 0
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q: use tcp, udp or icmp", protocol)
+	}
+
+	// Answers are reused so a destination named in the policy is checked
+	// against the same addresses its rules were built from.
+	answers := map[string][]net.IP{}
+	cached := func(ctx context.Context, host string) ([]net.IP, error) {
+		if ips, ok := answers[host]; ok {
+			return ips, nil
+		}
+		ips, err := lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		answers[host] = ips
+		return ips, nil
+	}
+	rules, err := resolveForwardRulesWithLookup(ctx, allow, cached)
+	if err != nil {
+		return nil, err
+	}
+
+	var destIPs []string
+	if ip := net.ParseIP(dest); ip != nil {
+		if ip.To4() == nil {
+			return nil, fmt.Errorf("destination %s is IPv6; guests only have IPv4 egress", dest)
+		}
+		destIPs = []string{ip.To4().String()}
+	} else {
+		ips, err := cached(ctx, dest)
+		if err != nil {
+			return nil, fmt.Errorf("resolve destination %q: %w", dest, err)
+		}
+		seen := map[string]bool{}
+		for _, ip := range ips {
+			if ipv4 := ip.To4(); ipv4 != nil && !seen[ipv4.String()] {
+				seen[ipv4.String()] = true
+				destIPs = append(destIPs, ipv4.String())
+			}
+		}
+		if len(destIPs) == 0 {
+			return nil, fmt.Errorf("resolve destination %q: no ipv4 addresses", dest)
+		}
+	}
+
+	verdicts := make([]EgressVerdict, 0, len(destIPs))
+	for _, ip := range destIPs {
+		verdict := EgressVerdict{Protocol: protocol, IP: ip, Port: port}
+		// Rules are checked in the order setupHostNetwork installs them.
+		if ip == guestDNSServer && port == 53 {
+			verdict.Allowed, verdict.Rule = true, "dns"
+		}
+		for _, rule := range rules {
+			if verdict.Allowed {
+				break
+			}
+			if rule.matches(protocol, ip, port) {
+				verdict.Allowed, verdict.Rule = true, rule.Host
+			}
+		}
+		verdicts = append(verdicts, verdict)
+	}
+	return verdicts, nil
+}
@@ -0,0 +1,135 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FaultsEnv enables fault injection when set. It exists so integration tests
+// can exercise failure handling without relying on flaky hardware; never set
+// it on a production host.
+const FaultsEnv = "CLEANROOM_FIRECRACKER_FAULTS"
+
+// ErrInjectedFault wraps every error produced by fault injection.
+var ErrInjectedFault = errors.New("injected fault")
+
+// FaultPlan lists the failures the adapter injects. The zero value injects
+// nothing.
+//
+// The text form accepted by ParseFaultPlan is a comma-separated list of
+// key=value pairs:
+//
+//	vsock_dial_delay=2s    wait before every guest agent dial
+//	vsock_dial_fail=3      fail the first 3 guest agent dials
+//	kill_vm_after=500ms    kill firecracker this long after a command is sent
+//	iptables_fail=FORWARD  fail privileged commands whose arguments contain
+//	                       the value ("*" matches every command)
+type FaultPlan struct {
+	VsockDialDelay    time.Duration
+	VsockDialFailures int64
+	KillVMAfter       time.Duration
+	IptablesFail      string
+}
+
+// FaultPlanFromEnv parses FaultsEnv. An unset variable yields the zero plan.
+func FaultPlanFromEnv() (FaultPlan, error) {
+	spec := strings.TrimSpace(os.Getenv(FaultsEnv))
+	if spec == "" {
+		return FaultPlan{}, nil
+	}
+	plan, err := ParseFaultPlan(spec)
+	if err != nil {
+		return FaultPlan{}, fmt.Errorf("invalid %s: %w", FaultsEnv, err)
+	}
+	return plan, nil
+}
+
+func ParseFaultPlan(spec string) (FaultPlan, error) {
+	var plan FaultPlan
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return FaultPlan{}, fmt.Errorf("fault %q is not key=value", field)
+		}
+		var err error
+		switch key {
+		case "vsock_dial_delay":
+			plan.VsockDialDelay, err = time.ParseDuration(value)
+		case "vsock_dial_fail":
+			plan.VsockDialFailures, err = strconv.ParseInt(value, 10, 64)
+			if err == nil && plan.VsockDialFailures < 0 {
+				err = errors.New("must not be negative")
+			}
+		case "kill_vm_after":
+			plan.KillVMAfter, err = time.ParseDuration(value)
+		case "iptables_fail":
+			if value == "" {
+				err = errors.New("must not be empty")
+			}
+			plan.IptablesFail = value
+		default:
+			return FaultPlan{}, fmt.Errorf("unknown fault %q", key)
+		}
+		if err != nil {
+			return FaultPlan{}, fmt.Errorf("fault %s: %w", key, err)
+		}
+	}
+	return plan, nil
+}
+
+// injectVsockDialFault runs before the adapter connects to a guest agent.
+func (a *Adapter) injectVsockDialFault(ctx context.Context) error {
+	if d := a.Faults.VsockDialDelay; d > 0 {
+		a.logger(ctx).Warn("fault injection: delaying vsock dial", "delay", d)
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for vsock guest agent: %w", ctx.Err())
+		}
+	}
+	if n := a.faultDials.Add(1); n <= a.Faults.VsockDialFailures {
+		a.logger(ctx).Warn("fault injection: failing vsock dial", "attempt", n, "failures", a.Faults.VsockDialFailures)
+		return fmt.Errorf("dial vsock guest agent: %w", ErrInjectedFault)
+	}
+	return nil
+}
+
+// injectVMKill schedules the firecracker process to be killed and returns a
+// function that cancels the kill if the command finishes first.
+func (a *Adapter) injectVMKill(fcCmd *exec.Cmd) func() {
+	if a.Faults.KillVMAfter <= 0 || fcCmd == nil || fcCmd.Process == nil {
+		return func() {}
+	}
+	timer := time.AfterFunc(a.Faults.KillVMAfter, func() {
+		a.logger(context.Background()).Warn("fault injection: killing firecracker", "pid", fcCmd.Process.Pid)
+		_ = fcCmd.Process.Kill()
+	})
+	return func() { timer.Stop() }
+}
+
+// withRootCommandFaults wraps the privileged command runner used for host
+// network setup so matching commands fail without running.
+func (a *Adapter) withRootCommandFaults(run rootCommandFunc) rootCommandFunc {
+	match := a.Faults.IptablesFail
+	if match == "" {
+		return run
+	}
+	return func(ctx context.Context, args ...string) error {
+		joined := strings.Join(args, " ")
+		if match == "*" || strings.Contains(joined, match) {
+			a.logger(ctx).Warn("fault injection: failing privileged command", "command", joined)
+			return fmt.Errorf("%s: %w", joined, ErrInjectedFault)
+		}
+		return run(ctx, args...)
+	}
+}
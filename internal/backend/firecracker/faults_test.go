@@ -0,0 +1,119 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+func TestParseFaultPlan(t *testing.T) {
+	t.Parallel()
+
+	plan, err := ParseFaultPlan("vsock_dial_delay=2s, vsock_dial_fail=3,kill_vm_after=500ms,iptables_fail=FORWARD")
+	if err != nil {
+		t.Fatalf("ParseFaultPlan returned error: %v", err)
+	}
+	want := FaultPlan{
+		VsockDialDelay:    2 * time.Second,
+		VsockDialFailures: 3,
+		KillVMAfter:       500 * time.Millisecond,
+		IptablesFail:      "FORWARD",
+	}
+	if plan != want {
+		t.Fatalf("unexpected plan: got %+v want %+v", plan, want)
+	}
+
+	for _, spec := range []string{"bogus=1", "vsock_dial_fail", "vsock_dial_fail=-1", "kill_vm_after=soon", "iptables_fail="} {
+		if _, err := ParseFaultPlan(spec); err == nil {
+			t.Fatalf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestFaultPlanFromEnvFailsRuns(t *testing.T) {
+	t.Setenv(FaultsEnv, "nope")
+
+	adapter := New()
+	err := adapter.ProvisionSandbox(context.Background(), backend.ProvisionRequest{SandboxID: "cr-test"})
+	if err == nil || !strings.Contains(err.Error(), FaultsEnv) {
+		t.Fatalf("expected invalid fault plan error, got %v", err)
+	}
+}
+
+func TestRunInSandboxInjectsVsockDialFailures(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	adapter := &Adapter{Faults: FaultPlan{VsockDialFailures: 1}}
+	adapter.runGuestCommandFn = func(_ context.Context, _ context.Context, _ <-chan struct{}, _ func() error, _ string, _ uint32, _ vsockexec.ExecRequest, _ backend.OutputStream) (vsockexec.ExecResponse, guestExecTiming, error) {
+		calls++
+		return vsockexec.ExecResponse{ExitCode: 0}, guestExecTiming{}, nil
+	}
+	adapter.sandboxes = map[string]*sandboxInstance{
+		"cr-test": {SandboxID: "cr-test", RunDir: t.TempDir(), CommandTimeout: 1},
+	}
+	req := backend.RunRequest{SandboxID: "cr-test", RunID: "run-1", Command: []string{"true"}}
+
+	if _, err := adapter.RunInSandbox(context.Background(), req, backend.OutputStream{}); !errors.Is(err, ErrInjectedFault) {
+		t.Fatalf("expected injected fault on first run, got %v", err)
+	}
+	req.RunID = "run-2"
+	if _, err := adapter.RunInSandbox(context.Background(), req, backend.OutputStream{}); err != nil {
+		t.Fatalf("expected second run to succeed, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("unexpected guest command calls: got %d want 1", calls)
+	}
+}
+
+func TestInjectVMKillKillsProcess(t *testing.T) {
+	t.Parallel()
+
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("sleep unavailable: %v", err)
+	}
+	adapter := &Adapter{Faults: FaultPlan{KillVMAfter: 10 * time.Millisecond}}
+	stop := adapter.injectVMKill(cmd)
+	defer stop()
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected killed process to exit with an error")
+		}
+	case <-time.After(5 * time.Second):
+		_ = cmd.Process.Kill()
+		t.Fatal("process was not killed")
+	}
+}
+
+func TestWithRootCommandFaultsFailsMatchingCommands(t *testing.T) {
+	t.Parallel()
+
+	var ran []string
+	run := func(_ context.Context, args ...string) error {
+		ran = append(ran, strings.Join(args, " "))
+		return nil
+	}
+	adapter := &Adapter{Faults: FaultPlan{IptablesFail: "FORWARD"}}
+	wrapped := adapter.withRootCommandFaults(run)
+
+	if err := wrapped(context.Background(), "ip", "link", "set", "crtap", "up"); err != nil {
+		t.Fatalf("unexpected error for non-matching command: %v", err)
+	}
+	if err := wrapped(context.Background(), "iptables", "-A", "FORWARD", "-i", "crtap"); !errors.Is(err, ErrInjectedFault) {
+		t.Fatalf("expected injected fault, got %v", err)
+	}
+	if len(ran) != 1 || ran[0] != "ip link set crtap up" {
+		t.Fatalf("unexpected commands run: %v", ran)
+	}
+}
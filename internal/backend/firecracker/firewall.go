@@ -0,0 +1,119 @@
+package firecracker
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+)
+
+// Cleanroom keeps its host iptables rules in chains of its own, entered by a
+// single jump from each built-in chain. Rules written by ufw, firewalld or
+// Docker then cannot interleave with them, and the jumps sit at the top of
+// the built-in chains so a DROP policy or rule set by another tool does not
+// shadow sandbox traffic.
+const (
+	inputChain       = "CLEANROOM-INPUT"
+	forwardChain     = "CLEANROOM-FORWARD"
+	postroutingChain = "CLEANROOM-POSTROUTING"
+)
+
+var cleanroomChains = []struct {
+	table, parent, chain string
+}{
+	{"filter", "INPUT", inputChain},
+	{"filter", "FORWARD", forwardChain},
+	{"nat", "POSTROUTING", postroutingChain},
+}
+
+// chainsMu keeps concurrent setups in this process from both inserting a
+// missing jump.
+var chainsMu sync.Mutex
+
+// ensureCleanroomChains creates any missing Cleanroom chain and the jump to
+// it. It runs before every setup, so chains flushed by a firewall reload or
+// removed by hand are restored for the next sandbox.
+func ensureCleanroomChains(ctx context.Context, run rootCommandFunc) error {
+	chainsMu.Lock()
+	defer chainsMu.Unlock()
+	for _, c := range cleanroomChains {
+		if run(ctx, "iptables", "-t", c.table, "-C", c.parent, "-j", c.chain) == nil {
+			continue
+		}
+		// -N fails when the chain already exists and only its jump is missing.
+		_ = run(ctx, "iptables", "-t", c.table, "-N", c.chain)
+		if err := run(ctx, "iptables", "-t", c.table, "-I", c.parent, "1", "-j", c.chain); err != nil {
+			return fmt.Errorf("jump from %s to %s: %w", c.parent, c.chain, err)
+		}
+	}
+	return nil
+}
+
+// hostFirewallChecks reports how other firewall managers' FORWARD rules,
+// as printed by iptables -S FORWARD, interact with Cleanroom's.
+func hostFirewallChecks(forwardRules string) []backend.DoctorCheck {
+	policy := ""
+	jumped := false
+	var managers []string
+	addManager := func(name string) {
+		if !slices.Contains(managers, name) {
+			managers = append(managers, name)
+		}
+	}
+	for _, line := range strings.Split(forwardRules, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 3 && fields[0] == "-P":
+			policy = fields[2]
+		case strings.Contains(line, "-j "+forwardChain):
+			jumped = true
+		case strings.Contains(line, "-j DOCKER"):
+			addManager("docker")
+		case strings.Contains(line, "-j ufw-"):
+			addManager("ufw")
+		case strings.Contains(line, "-j FORWARD_"):
+			addManager("firewalld")
+		}
+	}
+
+	var checks []backend.DoctorCheck
+	switch {
+	case policy == "DROP" && !jumped:
+		checks = append(checks, backend.DoctorCheck{
+			Name:        "host_firewall_forward",
+			Status:      "warn",
+			Message:     fmt.Sprintf("FORWARD policy is DROP and has no jump to %s yet", forwardChain),
+			Remediation: "the jump is inserted at the top of FORWARD when the first sandbox starts; rerun doctor with a sandbox running to confirm",
+		})
+	case policy == "DROP":
+		checks = append(checks, backend.DoctorCheck{
+			Name:    "host_firewall_forward",
+			Status:  "pass",
+			Message: fmt.Sprintf("FORWARD policy is DROP; sandbox traffic is accepted in %s", forwardChain),
+		})
+	case policy == "":
+		checks = append(checks, backend.DoctorCheck{Name: "host_firewall_forward", Status: "warn", Message: "could not read the FORWARD policy"})
+	default:
+		checks = append(checks, backend.DoctorCheck{Name: "host_firewall_forward", Status: "pass", Message: "FORWARD policy is " + policy})
+	}
+
+	if len(managers) == 0 {
+		return append(checks, backend.DoctorCheck{Name: "host_firewall_managers", Status: "pass", Message: "no other firewall manager rules in FORWARD"})
+	}
+	status, remediation := "pass", ""
+	for _, m := range managers {
+		if m == "ufw" || m == "firewalld" {
+			status = "warn"
+			remediation = fmt.Sprintf("reloading %s can flush Cleanroom's chains and cut off running sandboxes; new sandboxes restore them", m)
+		}
+	}
+	return append(checks, backend.DoctorCheck{
+		Name:        "host_firewall_managers",
+		Status:      status,
+		Message:     fmt.Sprintf("FORWARD also has rules from %s", strings.Join(managers, ", ")),
+		Remediation: remediation,
+	})
+}
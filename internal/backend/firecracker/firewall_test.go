@@ -0,0 +1,83 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEnsureCleanroomChainsRestoresMissingJumps(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	run := func(_ context.Context, args ...string) error {
+		call := strings.Join(args, " ")
+		calls = append(calls, call)
+		// Only the FORWARD jump is missing, as after a firewall reload.
+		if call == "iptables -t filter -C FORWARD -j CLEANROOM-FORWARD" || call == "iptables -t filter -N CLEANROOM-FORWARD" {
+			return errors.New("exit status 1")
+		}
+		return nil
+	}
+	if err := ensureCleanroomChains(context.Background(), run); err != nil {
+		t.Fatalf("ensureCleanroomChains: %v", err)
+	}
+	want := []string{
+		"iptables -t filter -C INPUT -j CLEANROOM-INPUT",
+		"iptables -t filter -C FORWARD -j CLEANROOM-FORWARD",
+		"iptables -t filter -N CLEANROOM-FORWARD",
+		"iptables -t filter -I FORWARD 1 -j CLEANROOM-FORWARD",
+		"iptables -t nat -C POSTROUTING -j CLEANROOM-POSTROUTING",
+	}
+	if got := strings.Join(calls, "\n"); got != strings.Join(want, "\n") {
+		t.Fatalf("unexpected calls:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+
+	failing := func(_ context.Context, args ...string) error {
+		return errors.New("iptables: permission denied")
+	}
+	if err := ensureCleanroomChains(context.Background(), failing); err == nil || !strings.Contains(err.Error(), "jump from INPUT to CLEANROOM-INPUT") {
+		t.Fatalf("expected a jump error, got %v", err)
+	}
+}
+
+func TestHostFirewallChecks(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		rules string
+		want  string
+	}{
+		{
+			name:  "docker drop policy before first sandbox",
+			rules: "-P FORWARD DROP\n-A FORWARD -j DOCKER-USER\n-A FORWARD -j DOCKER-FORWARD\n",
+			want:  "host_firewall_forward=warn host_firewall_managers=pass",
+		},
+		{
+			name:  "docker drop policy with cleanroom jump",
+			rules: "-P FORWARD DROP\n-A FORWARD -j CLEANROOM-FORWARD\n-A FORWARD -j DOCKER-USER\n",
+			want:  "host_firewall_forward=pass host_firewall_managers=pass",
+		},
+		{
+			name:  "ufw",
+			rules: "-P FORWARD DROP\n-A FORWARD -j CLEANROOM-FORWARD\n-A FORWARD -j ufw-before-forward\n",
+			want:  "host_firewall_forward=pass host_firewall_managers=warn",
+		},
+		{
+			name:  "plain accept",
+			rules: "-P FORWARD ACCEPT\n",
+			want:  "host_firewall_forward=pass host_firewall_managers=pass",
+		},
+	}
+	for _, tc := range cases {
+		var got []string
+		for _, check := range hostFirewallChecks(tc.rules) {
+			got = append(got, check.Name+"="+check.Status)
+		}
+		if strings.Join(got, " ") != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, strings.Join(got, " "), tc.want)
+		}
+	}
+}
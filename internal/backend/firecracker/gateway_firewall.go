@@ -9,7 +9,7 @@ import (
 	"github.com/buildkite/cleanroom/internal/backend"
 )
 
-// SetupGatewayFirewall installs global iptables rules that restrict access to
+// SetupGatewayFirewall installs iptables rules in CLEANROOM-INPUT that restrict access to
 // the gateway port to loopback and TAP interfaces (cr+) only. All other
 // interfaces (e.g. eth0) are blocked from reaching the gateway.
 //
@@ -28,25 +28,28 @@ func SetupGatewayFirewall(ctx context.Context, port int, cfg backend.Firecracker
 
 func setupGatewayFirewall(ctx context.Context, port int, run rootCommandFunc) (cleanup func(), err error) {
 	portStr := strconv.Itoa(port)
+	if err := ensureCleanroomChains(ctx, run); err != nil {
+		return nil, err
+	}
 
 	// Allow loopback access to gateway port.
-	if err := run(ctx, "iptables", "-A", "INPUT", "-i", "lo", "-p", "tcp", "--dport", portStr, "-j", "ACCEPT"); err != nil {
+	if err := run(ctx, "iptables", "-A", inputChain, "-i", "lo", "-p", "tcp", "--dport", portStr, "-j", "ACCEPT"); err != nil {
 		return nil, fmt.Errorf("install gateway loopback rule: %w", err)
 	}
 
 	// Drop gateway traffic from non-TAP interfaces (eth0, docker0, etc.).
 	// TAP traffic (cr*) is intentionally NOT matched here so it falls through
 	// to the per-TAP anti-spoof rules installed by setupHostNetwork.
-	if err := run(ctx, "iptables", "-A", "INPUT", "!", "-i", "cr+", "-p", "tcp", "--dport", portStr, "-j", "DROP"); err != nil {
-		_ = run(ctx, "iptables", "-D", "INPUT", "-i", "lo", "-p", "tcp", "--dport", portStr, "-j", "ACCEPT")
+	if err := run(ctx, "iptables", "-A", inputChain, "!", "-i", "cr+", "-p", "tcp", "--dport", portStr, "-j", "DROP"); err != nil {
+		_ = run(ctx, "iptables", "-D", inputChain, "-i", "lo", "-p", "tcp", "--dport", portStr, "-j", "ACCEPT")
 		return nil, fmt.Errorf("install gateway drop rule: %w", err)
 	}
 
 	cleanup = func() {
 		cleanupCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-		_ = run(cleanupCtx, "iptables", "-D", "INPUT", "!", "-i", "cr+", "-p", "tcp", "--dport", portStr, "-j", "DROP")
-		_ = run(cleanupCtx, "iptables", "-D", "INPUT", "-i", "lo", "-p", "tcp", "--dport", portStr, "-j", "ACCEPT")
+		_ = run(cleanupCtx, "iptables", "-D", inputChain, "!", "-i", "cr+", "-p", "tcp", "--dport", portStr, "-j", "DROP")
+		_ = run(cleanupCtx, "iptables", "-D", inputChain, "-i", "lo", "-p", "tcp", "--dport", portStr, "-j", "ACCEPT")
 	}
 	return cleanup, nil
 }
@@ -21,8 +21,11 @@ func TestSetupGatewayFirewall(t *testing.T) {
 	}
 
 	want := []string{
-		"iptables -A INPUT -i lo -p tcp --dport 8170 -j ACCEPT",
-		"iptables -A INPUT ! -i cr+ -p tcp --dport 8170 -j DROP",
+		"iptables -t filter -C INPUT -j CLEANROOM-INPUT",
+		"iptables -t filter -C FORWARD -j CLEANROOM-FORWARD",
+		"iptables -t nat -C POSTROUTING -j CLEANROOM-POSTROUTING",
+		"iptables -A CLEANROOM-INPUT -i lo -p tcp --dport 8170 -j ACCEPT",
+		"iptables -A CLEANROOM-INPUT ! -i cr+ -p tcp --dport 8170 -j DROP",
 	}
 	if len(calls) != len(want) {
 		t.Fatalf("expected %d setup calls, got %d:\n%s", len(want), len(calls), strings.Join(calls, "\n"))
@@ -36,8 +39,8 @@ func TestSetupGatewayFirewall(t *testing.T) {
 	calls = nil
 	cleanup()
 	wantCleanup := []string{
-		"iptables -D INPUT ! -i cr+ -p tcp --dport 8170 -j DROP",
-		"iptables -D INPUT -i lo -p tcp --dport 8170 -j ACCEPT",
+		"iptables -D CLEANROOM-INPUT ! -i cr+ -p tcp --dport 8170 -j DROP",
+		"iptables -D CLEANROOM-INPUT -i lo -p tcp --dport 8170 -j ACCEPT",
 	}
 	if len(calls) != len(wantCleanup) {
 		t.Fatalf("expected %d cleanup calls, got %d:\n%s", len(wantCleanup), len(calls), strings.Join(calls, "\n"))
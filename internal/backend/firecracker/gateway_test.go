@@ -68,3 +68,42 @@ func TestGatewayEnvVarsNilPolicy(t *testing.T) {
 		t.Fatalf("expected nil for nil policy, got %v", env)
 	}
 }
+
+func TestGatewayEnvVarsAdvertisesOCIRegistry(t *testing.T) {
+	t.Parallel()
+
+	instance := &sandboxInstance{
+		HostIP: "10.1.1.1",
+		Policy: &policy.CompiledPolicy{
+			Version:        1,
+			NetworkDefault: "deny",
+			Services: policy.Services{
+				OCIRegistry: &policy.OCIRegistryService{Allow: []string{"ghcr.io/org/tool"}},
+			},
+		},
+	}
+	env := gatewayEnvVars(instance, 8170)
+	if len(env) != 1 || env[0] != "CLEANROOM_OCI_REGISTRY=10.1.1.1:8170" {
+		t.Fatalf("unexpected env vars: %v", env)
+	}
+}
+
+func TestGatewayEnvVarsPointsPackageManagersAtGateway(t *testing.T) {
+	t.Parallel()
+
+	instance := &sandboxInstance{
+		HostIP: "10.1.1.1",
+		Policy: &policy.CompiledPolicy{
+			Version:        1,
+			NetworkDefault: "deny",
+			Services: policy.Services{
+				Packages: &policy.PackagesService{NPM: []string{"left-pad"}, Go: []string{"golang.org/x"}},
+			},
+		},
+	}
+	got := strings.Join(gatewayEnvVars(instance, 8170), " ")
+	want := "npm_config_registry=http://10.1.1.1:8170/registry/npm/ GOPROXY=http://10.1.1.1:8170/registry/go"
+	if got != want {
+		t.Fatalf("unexpected env vars: got %q want %q", got, want)
+	}
+}
@@ -0,0 +1,107 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+)
+
+// groupLink is a group member's TAP, guest address and the ports it
+// accepts connections on from the other members.
+type groupLink struct {
+	TapName string
+	GuestIP string
+	Ports   []int
+}
+
+// LinkSandboxGroup routes traffic between the members' TAP links on the
+// ports each member declares. Each member keeps its own /24 and TAP, so
+// the links sit beside its existing rules rather than on a shared bridge.
+// The rules are removed when any member is terminated.
+func (a *Adapter) LinkSandboxGroup(ctx context.Context, req backend.SandboxGroupRequest) (map[string]string, error) {
+	if len(req.Members) < 2 {
+		return nil, errors.New("a sandbox group needs at least two members")
+	}
+
+	a.sandboxMu.Lock()
+	instances := make([]*sandboxInstance, 0, len(req.Members))
+	links := make([]groupLink, 0, len(req.Members))
+	for _, member := range req.Members {
+		instance, ok := a.sandboxes[strings.TrimSpace(member.SandboxID)]
+		switch {
+		case !ok:
+			a.sandboxMu.Unlock()
+			return nil, fmt.Errorf("unknown sandbox %q", member.SandboxID)
+		case instance.Namespace != "":
+			a.sandboxMu.Unlock()
+			return nil, fmt.Errorf("sandbox %s has its own network namespace; sandbox groups need backends.firecracker.network_namespaces off", member.SandboxID)
+		case instance.cleanupGroup != nil:
+			a.sandboxMu.Unlock()
+			return nil, fmt.Errorf("sandbox %s is already in a group", member.SandboxID)
+		case instance.TapName == "" || instance.GuestIP == "":
+			a.sandboxMu.Unlock()
+			return nil, fmt.Errorf("sandbox %s has no guest network", member.SandboxID)
+		}
+		instances = append(instances, instance)
+		links = append(links, groupLink{TapName: instance.TapName, GuestIP: instance.GuestIP, Ports: member.Ports})
+	}
+	a.sandboxMu.Unlock()
+
+	setup, undo := sandboxGroupRules(links)
+	cleanup := sync.OnceFunc(func() {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = runRootCommandBatch(cleanupCtx, req.FirecrackerConfig, undo)
+	})
+	for i, args := range setup {
+		if err := runRootCommand(ctx, req.FirecrackerConfig, args...); err != nil {
+			undo = undo[len(undo)-i:]
+			cleanup()
+			return nil, fmt.Errorf("link sandbox group %s: %w", req.GroupID, err)
+		}
+	}
+
+	addresses := make(map[string]string, len(instances))
+	a.sandboxMu.Lock()
+	for _, instance := range instances {
+		instance.cleanupGroup = cleanup
+		addresses[instance.SandboxID] = instance.GuestIP
+	}
+	a.sandboxMu.Unlock()
+	return addresses, nil
+}
+
+// sandboxGroupRules returns the commands that let each member connect to
+// the others' declared ports, and the commands that remove them in reverse
+// order. The rules are inserted at the top of Cleanroom's chains, ahead of
+// each TAP's default deny, and traffic between members is not masqueraded
+// so each guest sees its peers' own addresses.
+func sandboxGroupRules(links []groupLink) (setup, undo [][]string) {
+	add := func(chain string, table []string, match []string, target string) {
+		setup = append(setup, slices.Concat([]string{"iptables"}, table, []string{"-I", chain, "1"}, match, []string{"-j", target}))
+		undo = append(undo, slices.Concat([]string{"iptables"}, table, []string{"-D", chain}, match, []string{"-j", target}))
+	}
+	for _, src := range links {
+		for _, dst := range links {
+			if src.TapName == dst.TapName || len(dst.Ports) == 0 {
+				continue
+			}
+			for _, port := range dst.Ports {
+				for _, proto := range []string{"tcp", "udp"} {
+					add(forwardChain, nil, []string{"-i", src.TapName, "-s", src.GuestIP, "-o", dst.TapName, "-d", dst.GuestIP, "-p", proto, "--dport", strconv.Itoa(port)}, "ACCEPT")
+				}
+			}
+			add(forwardChain, nil, []string{"-i", dst.TapName, "-o", src.TapName, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED"}, "ACCEPT")
+			add(postroutingChain, []string{"-t", "nat"}, []string{"-s", src.GuestIP + "/32", "-d", dst.GuestIP + "/32"}, "RETURN")
+		}
+	}
+	slices.Reverse(undo)
+	return setup, undo
+}
@@ -0,0 +1,50 @@
+package firecracker
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+)
+
+func TestSandboxGroupRulesOnlyOpenDeclaredPorts(t *testing.T) {
+	t.Parallel()
+
+	setup, undo := sandboxGroupRules([]groupLink{
+		{TapName: "cr-10-200-0", GuestIP: "10.200.0.2"},
+		{TapName: "cr-10-200-1", GuestIP: "10.200.1.2", Ports: []int{5432}},
+	})
+	var got []string
+	for _, args := range setup {
+		got = append(got, strings.Join(args, " "))
+	}
+	want := []string{
+		"iptables -I CLEANROOM-FORWARD 1 -i cr-10-200-0 -s 10.200.0.2 -o cr-10-200-1 -d 10.200.1.2 -p tcp --dport 5432 -j ACCEPT",
+		"iptables -I CLEANROOM-FORWARD 1 -i cr-10-200-0 -s 10.200.0.2 -o cr-10-200-1 -d 10.200.1.2 -p udp --dport 5432 -j ACCEPT",
+		"iptables -I CLEANROOM-FORWARD 1 -i cr-10-200-1 -o cr-10-200-0 -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT",
+		"iptables -t nat -I CLEANROOM-POSTROUTING 1 -s 10.200.0.2/32 -d 10.200.1.2/32 -j RETURN",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected setup:\n got %q\nwant %q", got, want)
+	}
+	if len(undo) != len(setup) || strings.Join(undo[0], " ") != "iptables -t nat -D CLEANROOM-POSTROUTING -s 10.200.0.2/32 -d 10.200.1.2/32 -j RETURN" {
+		t.Fatalf("expected undo to remove the rules in reverse order, got %q", undo)
+	}
+}
+
+func TestLinkSandboxGroupRejectsNamespacedSandboxes(t *testing.T) {
+	t.Parallel()
+
+	adapter := &Adapter{sandboxes: map[string]*sandboxInstance{
+		"cr_app": {SandboxID: "cr_app", TapName: "cr-10-200-0", GuestIP: "10.200.0.2"},
+		"cr_db":  {SandboxID: "cr_db", TapName: "cr-10-200-1", GuestIP: "10.200.1.2", Namespace: "cr-10-200-1"},
+	}}
+	_, err := adapter.LinkSandboxGroup(context.Background(), backend.SandboxGroupRequest{
+		GroupID: "crg_1",
+		Members: []backend.SandboxGroupMember{{SandboxID: "cr_app"}, {SandboxID: "cr_db", Ports: []int{5432}}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "network_namespaces") {
+		t.Fatalf("expected a network namespace error, got %v", err)
+	}
+}
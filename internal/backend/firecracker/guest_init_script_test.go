@@ -22,3 +22,35 @@ func TestGuestInitScriptAutostartsDockerWhenAvailable(t *testing.T) {
 		t.Fatal("expected init script to wait for dockerd API readiness")
 	}
 }
+
+func TestGuestInitScriptMountsCgroup2BeforeAgent(t *testing.T) {
+	mountIdx := strings.Index(guestInitScriptTemplate, "mount -t cgroup2 none /sys/fs/cgroup")
+	if mountIdx < 0 {
+		t.Fatal("expected init script to mount cgroup2 for per-execution cgroups")
+	}
+	dockerIdx := strings.Index(guestInitScriptTemplate, "DOCKER_REQUIRED=")
+	if dockerIdx >= 0 && mountIdx > dockerIdx {
+		t.Fatal("expected cgroup2 to be mounted independently of docker startup")
+	}
+}
+
+func TestGuestInitScriptMountsWritablePathsBeforeDocker(t *testing.T) {
+	mountIdx := strings.Index(guestInitScriptTemplate, "arg_value cleanroom_writable")
+	if mountIdx < 0 {
+		t.Fatal("expected init script to mount writable paths for a read-only rootfs")
+	}
+	dockerIdx := strings.Index(guestInitScriptTemplate, "DOCKER_REQUIRED=")
+	if mountIdx > dockerIdx {
+		t.Fatal("expected writable paths to be mounted before dockerd starts")
+	}
+}
+
+func TestGuestInitScriptSetsMTUBeforeLinkUp(t *testing.T) {
+	mtuIdx := strings.Index(guestInitScriptTemplate, `ip link set dev eth0 mtu "$GUEST_MTU"`)
+	if mtuIdx < 0 {
+		t.Fatal("expected init script to apply cleanroom_guest_mtu to eth0")
+	}
+	if upIdx := strings.Index(guestInitScriptTemplate, "ip link set dev eth0 up"); mtuIdx > upIdx {
+		t.Fatal("expected the guest mtu to be set before eth0 comes up")
+	}
+}
@@ -0,0 +1,74 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	hostResourceTaps      = "tap_devices"
+	hostResourceProcesses = "firecracker_processes"
+)
+
+// HostResources counts cleanroom tap devices and running firecracker
+// processes on the host. It sees every sandbox on the host, not just those
+// started by this adapter.
+func (a *Adapter) HostResources(_ context.Context) (map[string]int, error) {
+	taps, err := countTapDevices("/sys/class/net")
+	if err != nil {
+		return nil, err
+	}
+	procs, err := countProcessesByName("/proc", "firecracker")
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int{
+		hostResourceTaps:      taps,
+		hostResourceProcesses: procs,
+	}, nil
+}
+
+func countTapDevices(netDir string) (int, error) {
+	entries, err := os.ReadDir(netDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	count := 0
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "cr") {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func countProcessesByName(procDir, name string) (int, error) {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	count := 0
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.TrimLeft(entry.Name(), "0123456789") != "" {
+			continue
+		}
+		comm, err := os.ReadFile(filepath.Join(procDir, entry.Name(), "comm"))
+		if err != nil {
+			// Processes exit between ReadDir and ReadFile; skip them.
+			continue
+		}
+		if strings.TrimSpace(string(comm)) == name {
+			count++
+		}
+	}
+	return count, nil
+}
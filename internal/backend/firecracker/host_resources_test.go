@@ -0,0 +1,39 @@
+package firecracker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCountHostResourcesMatchesTapsAndProcesses(t *testing.T) {
+	t.Parallel()
+
+	netDir := t.TempDir()
+	for _, name := range []string{"cr1234", "crabc", "eth0", "lo"} {
+		if err := os.Mkdir(filepath.Join(netDir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := countTapDevices(netDir); err != nil || got != 2 {
+		t.Fatalf("unexpected tap count: got %d (err %v) want 2", got, err)
+	}
+
+	procDir := t.TempDir()
+	for pid, comm := range map[string]string{"10": "firecracker\n", "11": "bash\n", "12": "firecracker\n", "self": "firecracker\n"} {
+		dir := filepath.Join(procDir, pid)
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "comm"), []byte(comm), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := countProcessesByName(procDir, "firecracker"); err != nil || got != 2 {
+		t.Fatalf("unexpected process count: got %d (err %v) want 2", got, err)
+	}
+
+	if got, err := countTapDevices(filepath.Join(netDir, "missing")); err != nil || got != 0 {
+		t.Fatalf("unexpected count for missing dir: got %d (err %v) want 0", got, err)
+	}
+}
@@ -0,0 +1,287 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/paths"
+)
+
+// LeakCheckEnv turns on the leak checker when set to 1 or true. After each
+// sandbox is terminated the adapter checks that its TAP device, network
+// namespace, iptables rules, network lease, mounts, loop devices, files and
+// goroutines are gone, and reports any that are not. It is meant for
+// development and CI hosts: the checks run privileged commands and slow
+// every termination.
+const LeakCheckEnv = "CLEANROOM_FIRECRACKER_LEAK_CHECK"
+
+// leakGoroutineLabel is the pprof label on the goroutines a sandbox owns
+// while leak checking is on.
+const leakGoroutineLabel = "cleanroom_sandbox"
+
+// leakGoroutineGrace is how long a terminated sandbox's goroutines have to
+// exit before they are reported.
+const leakGoroutineGrace = 2 * time.Second
+
+// Leak is one resource created for a sandbox that outlived it.
+type Leak struct {
+	Kind     string `json:"kind"` // tap, netns, iptables, lease, mount, loop, file or goroutine
+	Resource string `json:"resource"`
+}
+
+// LeakReport lists what one terminated sandbox leaked.
+type LeakReport struct {
+	SandboxID string    `json:"sandbox_id"`
+	CheckedAt time.Time `json:"checked_at"`
+	Leaks     []Leak    `json:"leaks"`
+	// Goroutines holds the stacks of leaked goroutines.
+	Goroutines string `json:"goroutines,omitempty"`
+}
+
+func leakCheckFromEnv() bool {
+	on, _ := strconv.ParseBool(strings.TrimSpace(os.Getenv(LeakCheckEnv)))
+	return on
+}
+
+// leakProbes reads the host state the leak checker looks at. Tests point
+// it at fake trees.
+type leakProbes struct {
+	netDir    string // network devices, /sys/class/net
+	netnsDir  string // named network namespaces, /run/netns
+	mountInfo string // /proc/self/mountinfo
+	blockDir  string // block devices, /sys/block
+	// rules lists the rules in one of Cleanroom's iptables chains, as
+	// iptables -S prints them. Nil skips the iptables check.
+	rules func(ctx context.Context, table, chain string) (string, error)
+	// leased reports whether the sandbox still holds a network lease. Nil
+	// skips the lease check.
+	leased func(owner string) (bool, error)
+	// goroutines returns the goroutine profile in pprof's debug=1 form.
+	goroutines func() string
+	// grace is how long the sandbox's goroutines have to exit.
+	grace time.Duration
+}
+
+func hostLeakProbes() leakProbes {
+	return leakProbes{
+		netDir:    "/sys/class/net",
+		netnsDir:  "/run/netns",
+		mountInfo: "/proc/self/mountinfo",
+		blockDir:  "/sys/block",
+		grace:     leakGoroutineGrace,
+		goroutines: func() string {
+			var buf bytes.Buffer
+			_ = pprof.Lookup("goroutine").WriteTo(&buf, 1)
+			return buf.String()
+		},
+	}
+}
+
+// labelSandboxGoroutines labels the calling goroutine, and so every
+// goroutine it starts, as the sandbox's until the returned func is called.
+// It does nothing unless leak checking is on.
+func (a *Adapter) labelSandboxGoroutines(ctx context.Context, sandboxID string) func() {
+	if !a.LeakCheck {
+		return func() {}
+	}
+	pprof.SetGoroutineLabels(pprof.WithLabels(ctx, pprof.Labels(leakGoroutineLabel, sandboxID)))
+	return func() { pprof.SetGoroutineLabels(ctx) }
+}
+
+// checkSandboxLeaks reports what instance left behind after shutdown: it
+// logs each leak and writes a LeakReport under the state directory.
+func (a *Adapter) checkSandboxLeaks(ctx context.Context, instance *sandboxInstance) {
+	probes := hostLeakProbes()
+	probes.rules = instance.listRules
+	if alloc, err := a.guestNetworkAllocator(); err == nil {
+		probes.leased = alloc.leasedTo
+	}
+	report := findLeaks(ctx, probes, instance)
+	if len(report.Leaks) == 0 {
+		return
+	}
+	logger := a.logger(ctx)
+	for _, leak := range report.Leaks {
+		logger.Error("sandbox leaked resource", "sandbox_id", report.SandboxID, "kind", leak.Kind, "resource", leak.Resource)
+	}
+	path, err := writeLeakReport(report)
+	if err != nil {
+		logger.Error("write leak report failed", "sandbox_id", report.SandboxID, "error", err)
+		return
+	}
+	logger.Error("sandbox leaked resources", "sandbox_id", report.SandboxID, "leaks", len(report.Leaks), "report", path)
+}
+
+func findLeaks(ctx context.Context, probes leakProbes, instance *sandboxInstance) LeakReport {
+	report := LeakReport{SandboxID: instance.SandboxID, CheckedAt: time.Now().UTC()}
+	add := func(kind, resource string) {
+		report.Leaks = append(report.Leaks, Leak{Kind: kind, Resource: resource})
+	}
+
+	// Goroutines exit asynchronously once the VM has stopped.
+	var stacks string
+	deadline := time.Now().Add(probes.grace)
+	for {
+		stacks = labelledGoroutines(probes.goroutines(), instance.SandboxID)
+		if stacks == "" || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if stacks != "" {
+		add("goroutine", fmt.Sprintf("%d stacks labelled %s=%s", strings.Count(stacks, "\n\n")+1, leakGoroutineLabel, instance.SandboxID))
+		report.Goroutines = stacks
+	}
+
+	var links []string
+	if instance.TapName != "" {
+		links = append(links, instance.TapName)
+		if instance.Namespace != "" {
+			vethHost, _ := networkLease{TapName: instance.TapName}.vethNames()
+			links = append(links, vethHost)
+		}
+	}
+	for _, link := range links {
+		if exists(filepath.Join(probes.netDir, link)) {
+			add("tap", link)
+		}
+	}
+	if instance.Namespace != "" && exists(filepath.Join(probes.netnsDir, instance.Namespace)) {
+		add("netns", instance.Namespace)
+	}
+	if probes.rules != nil && len(links) > 0 {
+		match := slices.Concat(links, []string{instance.GuestIP, instance.GuestIP + "/32"})
+		for _, c := range cleanroomChains {
+			rules, err := probes.rules(ctx, c.table, c.chain)
+			if err != nil {
+				add("iptables", fmt.Sprintf("could not list %s: %v", c.chain, err))
+				continue
+			}
+			for _, rule := range strings.Split(rules, "\n") {
+				if ruleMentions(rule, match) {
+					add("iptables", "-t "+c.table+" "+strings.TrimSpace(rule))
+				}
+			}
+		}
+	}
+	if probes.leased != nil {
+		if leased, err := probes.leased(instance.SandboxID); err != nil || leased {
+			add("lease", "network lease owned by "+instance.SandboxID)
+		}
+	}
+
+	if instance.RunDir != "" {
+		for _, mount := range mountsUnder(probes.mountInfo, instance.RunDir) {
+			add("mount", mount)
+		}
+		for _, loop := range loopDevicesBacking(probes.blockDir, instance.RunDir) {
+			add("loop", loop)
+		}
+	}
+	for _, path := range []string{instance.RunDir, instance.vmRootFSPath} {
+		if path != "" && exists(path) {
+			add("file", path)
+		}
+	}
+	return report
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// ruleMentions reports whether an iptables -S rule has any of values as a
+// whole argument.
+func ruleMentions(rule string, values []string) bool {
+	for _, field := range strings.Fields(rule) {
+		for _, v := range values {
+			if v != "" && field == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// labelledGoroutines returns the records of a debug=1 goroutine profile
+// whose goroutines carry the sandbox's label.
+func labelledGoroutines(profile, sandboxID string) string {
+	label := fmt.Sprintf("%q:%q", leakGoroutineLabel, sandboxID)
+	var records []string
+	for _, record := range strings.Split(profile, "\n\n") {
+		for _, line := range strings.Split(record, "\n") {
+			if strings.HasPrefix(line, "# labels: ") && strings.Contains(line, label) {
+				records = append(records, strings.TrimSpace(record))
+				break
+			}
+		}
+	}
+	return strings.Join(records, "\n\n")
+}
+
+// mountsUnder returns the mount points at or below dir.
+func mountsUnder(mountInfo, dir string) []string {
+	data, err := os.ReadFile(mountInfo)
+	if err != nil {
+		return nil
+	}
+	var mounts []string
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 4 && withinDir(fields[4], dir) {
+			mounts = append(mounts, fields[4])
+		}
+	}
+	return mounts
+}
+
+// loopDevicesBacking returns the loop devices whose backing file is at or
+// below dir.
+func loopDevicesBacking(blockDir, dir string) []string {
+	backing, _ := filepath.Glob(filepath.Join(blockDir, "loop*", "loop", "backing_file"))
+	var devices []string
+	for _, path := range backing {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if file := strings.TrimSpace(string(data)); withinDir(file, dir) {
+			device := filepath.Base(filepath.Dir(filepath.Dir(path)))
+			devices = append(devices, "/dev/"+device+" ("+file+")")
+		}
+	}
+	return devices
+}
+
+func withinDir(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// writeLeakReport saves report as firecracker/leaks/<sandbox>.json in the
+// state directory and returns its path.
+func writeLeakReport(report LeakReport) (string, error) {
+	base, err := paths.StateBaseDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(base, "firecracker", "leaks")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, report.SandboxID+".json")
+	return path, os.WriteFile(path, append(data, '\n'), 0o644)
+}
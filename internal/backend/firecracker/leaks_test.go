@@ -0,0 +1,125 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func fakeLeakProbes(t *testing.T) leakProbes {
+	t.Helper()
+	root := t.TempDir()
+	probes := leakProbes{
+		netDir:     filepath.Join(root, "net"),
+		netnsDir:   filepath.Join(root, "netns"),
+		mountInfo:  filepath.Join(root, "mountinfo"),
+		blockDir:   filepath.Join(root, "block"),
+		goroutines: func() string { return "" },
+	}
+	for _, dir := range []string{probes.netDir, probes.netnsDir, probes.blockDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(probes.mountInfo, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return probes
+}
+
+func writeLeakFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindLeaksReportsLeftoverResources(t *testing.T) {
+	t.Parallel()
+
+	probes := fakeLeakProbes(t)
+	runDir := filepath.Join(t.TempDir(), "sandbox-1")
+	if err := os.MkdirAll(runDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	instance := &sandboxInstance{
+		SandboxID: "sandbox-1",
+		TapName:   "cr-tap1",
+		GuestIP:   "10.0.0.2",
+		Namespace: "cr-ns1",
+		RunDir:    runDir,
+	}
+	vethHost, _ := networkLease{TapName: instance.TapName}.vethNames()
+
+	writeLeakFile(t, filepath.Join(probes.netDir, vethHost), "")
+	writeLeakFile(t, filepath.Join(probes.netnsDir, "cr-ns1"), "")
+	writeLeakFile(t, probes.mountInfo, "36 35 98:0 / / rw - ext4 /dev/root rw\n"+
+		"40 36 0:50 / "+runDir+"/rootfs rw - ext4 /dev/loop3 rw\n")
+	writeLeakFile(t, filepath.Join(probes.blockDir, "loop3", "loop", "backing_file"), runDir+"/rootfs.ext4\n")
+	writeLeakFile(t, filepath.Join(probes.blockDir, "loop4", "loop", "backing_file"), "/var/lib/other.img\n")
+	probes.rules = func(_ context.Context, table, chain string) (string, error) {
+		if chain != "CLEANROOM-FORWARD" {
+			return "-N " + chain + "\n", nil
+		}
+		return "-N CLEANROOM-FORWARD\n" +
+			"-A CLEANROOM-FORWARD -i " + vethHost + " -d 1.1.1.1/32 -j ACCEPT\n" +
+			"-A CLEANROOM-FORWARD -s 10.0.0.20/32 -j ACCEPT\n", nil
+	}
+	probes.leased = func(owner string) (bool, error) { return owner == "sandbox-1", nil }
+	probes.goroutines = func() string {
+		return "goroutine profile: total 3\n" +
+			"1 @ 0x1 0x2\n# labels: {\"cleanroom_sandbox\":\"sandbox-1\"}\n#\t0x1\tmain.copyConsole+0x10\n\n" +
+			"2 @ 0x3\n#\t0x3\tmain.serve+0x10\n"
+	}
+
+	report := findLeaks(context.Background(), probes, instance)
+	want := []Leak{
+		{Kind: "goroutine", Resource: "1 stacks labelled cleanroom_sandbox=sandbox-1"},
+		{Kind: "tap", Resource: vethHost},
+		{Kind: "netns", Resource: "cr-ns1"},
+		{Kind: "iptables", Resource: "-t filter -A CLEANROOM-FORWARD -i " + vethHost + " -d 1.1.1.1/32 -j ACCEPT"},
+		{Kind: "lease", Resource: "network lease owned by sandbox-1"},
+		{Kind: "mount", Resource: runDir + "/rootfs"},
+		{Kind: "loop", Resource: "/dev/loop3 (" + runDir + "/rootfs.ext4)"},
+		{Kind: "file", Resource: runDir},
+	}
+	if !reflect.DeepEqual(report.Leaks, want) {
+		t.Fatalf("unexpected leaks:\n got %+v\nwant %+v", report.Leaks, want)
+	}
+	if report.Goroutines == "" {
+		t.Fatal("expected the leaked goroutine stacks in the report")
+	}
+}
+
+func TestFindLeaksCleanTermination(t *testing.T) {
+	t.Parallel()
+
+	probes := fakeLeakProbes(t)
+	probes.rules = func(context.Context, string, string) (string, error) { return "", nil }
+	probes.leased = func(string) (bool, error) { return false, nil }
+	instance := &sandboxInstance{
+		SandboxID: "sandbox-1",
+		TapName:   "cr-tap1",
+		GuestIP:   "10.0.0.2",
+		RunDir:    filepath.Join(t.TempDir(), "gone"),
+	}
+	if report := findLeaks(context.Background(), probes, instance); len(report.Leaks) != 0 {
+		t.Fatalf("expected no leaks, got %+v", report.Leaks)
+	}
+}
+
+func TestLeakCheckFromEnv(t *testing.T) {
+	t.Setenv(LeakCheckEnv, "1")
+	if !leakCheckFromEnv() {
+		t.Fatal("expected leak checking on")
+	}
+	t.Setenv(LeakCheckEnv, "")
+	if leakCheckFromEnv() {
+		t.Fatal("expected leak checking off")
+	}
+}
@@ -0,0 +1,170 @@
+package firecracker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+)
+
+// metricsFlushWait bounds how long a caller waits for the metrics written by
+// a FlushMetrics action to be read from the FIFO.
+const metricsFlushWait = 250 * time.Millisecond
+
+type metricsConfig struct {
+	MetricsPath string `json:"metrics_path"`
+}
+
+// vmMetrics reads the metrics Firecracker writes to a VM's FIFO. Firecracker
+// writes one JSON object per flush, every 60 seconds and on FlushMetrics,
+// and most of its counters hold the change since the previous flush, so
+// they are summed here into totals for the VM's lifetime.
+type vmMetrics struct {
+	fifo *os.File
+
+	mu      sync.Mutex
+	totals  backend.VMStats
+	flushes uint64
+	updated chan struct{}
+}
+
+// openVMMetrics creates the metrics FIFO in runDir and starts reading it.
+// The FIFO is opened read-write so Firecracker's non-blocking open for
+// writing finds a reader, and reads never see end-of-file between flushes.
+func openVMMetrics(runDir string) (*vmMetrics, string, error) {
+	path := filepath.Join(runDir, "firecracker.metrics")
+	_ = os.Remove(path)
+	if err := syscall.Mkfifo(path, 0o600); err != nil {
+		return nil, "", fmt.Errorf("create metrics fifo: %w", err)
+	}
+	fifo, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, "", fmt.Errorf("open metrics fifo: %w", err)
+	}
+	m := &vmMetrics{fifo: fifo, updated: make(chan struct{})}
+	go m.read()
+	return m, path, nil
+}
+
+func (m *vmMetrics) read() {
+	scanner := bufio.NewScanner(m.fifo)
+	scanner.Buffer(make([]byte, 64<<10), 4<<20)
+	for scanner.Scan() {
+		delta, err := parseFirecrackerMetrics(scanner.Bytes())
+		if err != nil {
+			continue
+		}
+		m.mu.Lock()
+		m.totals = m.totals.Add(delta)
+		m.flushes++
+		close(m.updated)
+		m.updated = make(chan struct{})
+		m.mu.Unlock()
+	}
+}
+
+// snapshot returns the totals so far and how many flushes they cover.
+func (m *vmMetrics) snapshot() (backend.VMStats, uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.totals, m.flushes
+}
+
+// flush asks Firecracker to write its metrics now and returns the totals
+// once they have been read, or the totals so far if that takes too long.
+func (m *vmMetrics) flush(ctx context.Context, api *apiClient) backend.VMStats {
+	m.mu.Lock()
+	updated := m.updated
+	m.mu.Unlock()
+	if api != nil && api.flushMetrics(ctx) == nil {
+		select {
+		case <-updated:
+		case <-ctx.Done():
+		case <-time.After(metricsFlushWait):
+		}
+	}
+	stats, _ := m.snapshot()
+	return stats
+}
+
+func (m *vmMetrics) close() {
+	if m != nil {
+		_ = m.fifo.Close()
+	}
+}
+
+// parseFirecrackerMetrics reads the device and vCPU counters from one
+// flush. Only the aggregate net and block groups are used, which sum every
+// interface and drive.
+func parseFirecrackerMetrics(line []byte) (backend.VMStats, error) {
+	var raw struct {
+		Net   map[string]json.RawMessage `json:"net"`
+		Block map[string]json.RawMessage `json:"block"`
+		VCPU  map[string]json.RawMessage `json:"vcpu"`
+	}
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return backend.VMStats{}, err
+	}
+	counter := func(group map[string]json.RawMessage, key string) int64 {
+		var v int64
+		_ = json.Unmarshal(group[key], &v)
+		return v
+	}
+	stats := backend.VMStats{
+		NetRxBytes:      counter(raw.Net, "rx_bytes_count"),
+		NetTxBytes:      counter(raw.Net, "tx_bytes_count"),
+		NetRxPackets:    counter(raw.Net, "rx_packets_count"),
+		NetTxPackets:    counter(raw.Net, "tx_packets_count"),
+		BlockReadBytes:  counter(raw.Block, "read_bytes"),
+		BlockWriteBytes: counter(raw.Block, "write_bytes"),
+		BlockReads:      counter(raw.Block, "read_count"),
+		BlockWrites:     counter(raw.Block, "write_count"),
+	}
+	// The exit counters differ by architecture; latency aggregates that
+	// share the exit_ prefix are objects and are skipped.
+	for key := range raw.VCPU {
+		if strings.HasPrefix(key, "exit_") {
+			stats.VCPUExits += counter(raw.VCPU, key)
+		}
+	}
+	return stats, nil
+}
+
+// SandboxVMStats flushes and reads the metrics of every running sandbox.
+// Sandboxes launched without a metrics FIFO are left out.
+func (a *Adapter) SandboxVMStats(ctx context.Context) map[string]backend.VMStats {
+	a.sandboxMu.Lock()
+	instances := make([]*sandboxInstance, 0, len(a.sandboxes))
+	for _, instance := range a.sandboxes {
+		if instance.metrics != nil && instance.exitedErrOrNil() == nil {
+			instances = append(instances, instance)
+		}
+	}
+	a.sandboxMu.Unlock()
+
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		stats = make(map[string]backend.VMStats, len(instances))
+	)
+	for _, instance := range instances {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := instance.metrics.flush(ctx, instance.api)
+			mu.Lock()
+			stats[instance.SandboxID] = s
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return stats
+}
@@ -0,0 +1,74 @@
+package firecracker
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+)
+
+const sampleFirecrackerMetrics = `{"utc_timestamp_ms":1,"net":{"rx_bytes_count":100,"tx_bytes_count":40,"rx_packets_count":3,"tx_packets_count":2,"rx_fails":0},"block":{"read_bytes":4096,"write_bytes":8192,"read_count":1,"write_count":2,"read_agg":{"min_us":1,"max_us":3,"sum_us":4}},"vcpu":{"exit_io_in":5,"exit_io_out":6,"exit_mmio_read":1,"exit_mmio_write":2,"failures":0,"exit_io_in_agg":{"min_us":0,"max_us":1,"sum_us":1}}}`
+
+func TestParseFirecrackerMetrics(t *testing.T) {
+	t.Parallel()
+
+	stats, err := parseFirecrackerMetrics([]byte(sampleFirecrackerMetrics))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := backend.VMStats{
+		NetRxBytes:      100,
+		NetTxBytes:      40,
+		NetRxPackets:    3,
+		NetTxPackets:    2,
+		BlockReadBytes:  4096,
+		BlockWriteBytes: 8192,
+		BlockReads:      1,
+		BlockWrites:     2,
+		VCPUExits:       14,
+	}
+	if stats != want {
+		t.Fatalf("stats = %+v, want %+v", stats, want)
+	}
+
+	if _, err := parseFirecrackerMetrics([]byte("not json")); err == nil {
+		t.Fatal("expected an error for a malformed line")
+	}
+}
+
+func TestVMMetricsAccumulatesFlushes(t *testing.T) {
+	t.Parallel()
+
+	metrics, path, err := openVMMetrics(t.TempDir())
+	if err != nil {
+		t.Fatalf("open metrics: %v", err)
+	}
+	defer metrics.close()
+
+	writer, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open fifo for writing: %v", err)
+	}
+	defer writer.Close()
+	for range 2 {
+		if _, err := writer.WriteString(sampleFirecrackerMetrics + "\n"); err != nil {
+			t.Fatalf("write metrics: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stats, flushes := metrics.snapshot()
+		if flushes == 2 {
+			if stats.NetRxBytes != 200 || stats.VCPUExits != 28 {
+				t.Fatalf("unexpected totals: %+v", stats)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("read %d flushes, want 2", flushes)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,73 @@
+package firecracker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/policy"
+)
+
+// nestedCPUTemplates are custom CPU templates that expose the hardware
+// virtualization extension Firecracker otherwise hides from guests: VMX
+// (CPUID.1:ECX bit 5) on Intel and SVM (CPUID.80000001h:ECX bit 2) on AMD.
+var nestedCPUTemplates = map[string]string{
+	"GenuineIntel": `{"cpuid_modifiers":[{"leaf":"0x1","subleaf":"0x0","flags":0,"modifiers":[{"register":"ecx","bitmap":"0bxxxxxxxxxxxxxxxxxxxxxxxxxx1xxxxx"}]}]}`,
+	"AuthenticAMD": `{"cpuid_modifiers":[{"leaf":"0x80000001","subleaf":"0x0","flags":0,"modifiers":[{"register":"ecx","bitmap":"0bxxxxxxxxxxxxxxxxxxxxxxxxxxxxx1xx"}]}]}`,
+}
+
+// nestedKVMParams is where each KVM module reports whether it allows guests
+// to run their own VMs.
+var nestedKVMParams = map[string]string{
+	"GenuineIntel": "/sys/module/kvm_intel/parameters/nested",
+	"AuthenticAMD": "/sys/module/kvm_amd/parameters/nested",
+}
+
+// nestedVirtSupport reports whether the host can give guests /dev/kvm. It
+// returns nil when it can, and the reason otherwise.
+func nestedVirtSupport(cpu hostCPU, readFile func(string) ([]byte, error)) error {
+	if cpu.Arch != "amd64" {
+		return fmt.Errorf("nested virtualization needs an x86_64 host, this host is %s", cpu.Arch)
+	}
+	param, ok := nestedKVMParams[cpu.Vendor]
+	if !ok {
+		return fmt.Errorf("nested virtualization is not supported on %s CPUs", vendorOrUnknown(cpu.Vendor))
+	}
+	data, err := readFile(param)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", param, err)
+	}
+	switch strings.TrimSpace(string(data)) {
+	case "Y", "1":
+		return nil
+	default:
+		return fmt.Errorf("nested virtualization is disabled in KVM (%s is %s)", param, strings.TrimSpace(string(data)))
+	}
+}
+
+// applyNestedVirtualization exposes hardware virtualization to the guest when
+// the policy opts in, by pointing Firecracker at a custom CPU template
+// written to runDir. Guests can then use /dev/kvm if their kernel has KVM.
+func applyNestedVirtualization(fcCfg *firecrackerConfig, compiled *policy.CompiledPolicy, runDir string, cpu hostCPU) error {
+	if compiled == nil || !compiled.NestedVirtualization {
+		return nil
+	}
+	if template := fcCfg.MachineConfig.CPUTemplate; template != "" && template != "None" {
+		return fmt.Errorf("policy enables nested virtualization, which cannot be combined with backends.firecracker.cpu_template %s", template)
+	}
+	if err := nestedVirtSupport(cpu, os.ReadFile); err != nil {
+		return fmt.Errorf("policy enables nested virtualization: %w", err)
+	}
+	template, ok := nestedCPUTemplates[cpu.Vendor]
+	if !ok {
+		return errors.New("policy enables nested virtualization: no CPU template for this host")
+	}
+	path := filepath.Join(runDir, "nested-cpu-template.json")
+	if err := os.WriteFile(path, []byte(template), 0o644); err != nil {
+		return fmt.Errorf("write nested virtualization cpu template: %w", err)
+	}
+	fcCfg.CPUConfig = path
+	return nil
+}
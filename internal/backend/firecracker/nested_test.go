@@ -0,0 +1,79 @@
+package firecracker
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/policy"
+)
+
+func TestNestedVirtSupport(t *testing.T) {
+	t.Parallel()
+
+	params := map[string]string{
+		"/sys/module/kvm_intel/parameters/nested": "Y\n",
+		"/sys/module/kvm_amd/parameters/nested":   "0\n",
+	}
+	readFile := func(path string) ([]byte, error) {
+		if v, ok := params[path]; ok {
+			return []byte(v), nil
+		}
+		return nil, os.ErrNotExist
+	}
+
+	if err := nestedVirtSupport(hostCPU{Arch: "amd64", Vendor: "GenuineIntel"}, readFile); err != nil {
+		t.Fatalf("expected intel host with nested=Y to be supported, got %v", err)
+	}
+	if err := nestedVirtSupport(hostCPU{Arch: "amd64", Vendor: "AuthenticAMD"}, readFile); err == nil || !strings.Contains(err.Error(), "disabled in KVM") {
+		t.Fatalf("expected disabled error for amd host, got %v", err)
+	}
+	if err := nestedVirtSupport(hostCPU{Arch: "amd64", Vendor: "GenuineIntel"}, func(string) ([]byte, error) {
+		return nil, errors.New("no kvm_intel module")
+	}); err == nil {
+		t.Fatal("expected missing module to be unsupported")
+	}
+	if err := nestedVirtSupport(hostCPU{Arch: "arm64", Vendor: "ARM"}, readFile); err == nil {
+		t.Fatal("expected arm64 host to be unsupported")
+	}
+}
+
+func TestApplyNestedVirtualizationRejectsStaticCPUTemplate(t *testing.T) {
+	t.Parallel()
+
+	fcCfg := firecrackerConfig{MachineConfig: machineConfig{CPUTemplate: "T2S"}}
+	err := applyNestedVirtualization(&fcCfg, &policy.CompiledPolicy{NestedVirtualization: true}, t.TempDir(), hostCPU{Arch: "amd64", Vendor: "GenuineIntel"})
+	if err == nil || !strings.Contains(err.Error(), "cpu_template T2S") {
+		t.Fatalf("expected cpu_template conflict, got %v", err)
+	}
+	if fcCfg.CPUConfig != "" {
+		t.Fatalf("expected no cpu config, got %q", fcCfg.CPUConfig)
+	}
+
+	if err := applyNestedVirtualization(&fcCfg, &policy.CompiledPolicy{}, t.TempDir(), hostCPU{}); err != nil {
+		t.Fatalf("expected policies without nested virtualization to be left alone, got %v", err)
+	}
+}
+
+func TestNestedCPUTemplatesAreValidJSON(t *testing.T) {
+	t.Parallel()
+
+	for vendor, template := range nestedCPUTemplates {
+		var parsed struct {
+			CPUIDModifiers []struct {
+				Modifiers []struct {
+					Bitmap string `json:"bitmap"`
+				} `json:"modifiers"`
+			} `json:"cpuid_modifiers"`
+		}
+		if err := json.Unmarshal([]byte(template), &parsed); err != nil {
+			t.Fatalf("%s template: %v", vendor, err)
+		}
+		bitmap := parsed.CPUIDModifiers[0].Modifiers[0].Bitmap
+		if got, want := len(bitmap), len("0b")+32; got != want {
+			t.Fatalf("%s bitmap length: got %d want %d", vendor, got, want)
+		}
+	}
+}
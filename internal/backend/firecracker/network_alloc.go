@@ -0,0 +1,264 @@
+package firecracker
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultNetworkPool is split into the /24 each sandbox's TAP link uses when
+// backends.firecracker.network_pool is unset.
+const DefaultNetworkPool = "10.200.0.0/16"
+
+// networkLease reserves one /24 from the pool and the TAP device named after
+// it for a sandbox or run.
+type networkLease struct {
+	Subnet  string    `json:"subnet"`
+	TapName string    `json:"tap"`
+	Owner   string    `json:"owner"`
+	PID     int       `json:"pid"`
+	Created time.Time `json:"created"`
+}
+
+// addresses returns the host (.1) and guest (.2) addresses of the lease.
+func (l networkLease) addresses() (string, string) {
+	ip, _, _ := net.ParseCIDR(l.Subnet)
+	ip4 := ip.To4()
+	host := net.IPv4(ip4[0], ip4[1], ip4[2], 1).String()
+	guest := net.IPv4(ip4[0], ip4[1], ip4[2], 2).String()
+	return host, guest
+}
+
+// networkAllocator hands out non-overlapping guest subnets and TAP names.
+// Leases live in a JSON file guarded by a file lock, so concurrent cleanroom
+// processes on one host do not collide. Leases held by a process that has
+// exited are reclaimed on the next allocation.
+type networkAllocator struct {
+	mu   sync.Mutex
+	path string
+	pid  int
+
+	// Tests replace these.
+	hostNetworksFn func() ([]*net.IPNet, error)
+	linkExistsFn   func(name string) bool
+	processAliveFn func(pid int) bool
+}
+
+func newNetworkAllocator(path string) *networkAllocator {
+	return &networkAllocator{
+		path:           path,
+		pid:            os.Getpid(),
+		hostNetworksFn: hostNetworks,
+		linkExistsFn:   linkExists,
+		processAliveFn: processAlive,
+	}
+}
+
+// allocate leases the first /24 in pool that no live lease holds, that does
+// not overlap an address or route already on the host, and whose TAP device
+// does not already exist.
+func (n *networkAllocator) allocate(pool, owner string) (networkLease, error) {
+	if strings.TrimSpace(pool) == "" {
+		pool = DefaultNetworkPool
+	}
+	_, poolNet, err := net.ParseCIDR(pool)
+	if err != nil || poolNet.IP.To4() == nil {
+		return networkLease{}, fmt.Errorf("invalid network pool %q: expected an IPv4 CIDR", pool)
+	}
+	ones, _ := poolNet.Mask.Size()
+	if ones > 24 {
+		return networkLease{}, fmt.Errorf("network pool %s is smaller than a /24", pool)
+	}
+	hostNets, err := n.hostNetworksFn()
+	if err != nil {
+		return networkLease{}, fmt.Errorf("list host networks: %w", err)
+	}
+
+	var lease networkLease
+	err = n.update(func(leases []networkLease) ([]networkLease, error) {
+		inUse := map[string]bool{}
+		for _, l := range leases {
+			inUse[l.Subnet] = true
+		}
+		base := binary.BigEndian.Uint32(poolNet.IP.To4())
+		for i := uint32(0); i < 1<<(24-ones); i++ {
+			subnet := &net.IPNet{IP: make(net.IP, 4), Mask: net.CIDRMask(24, 32)}
+			binary.BigEndian.PutUint32(subnet.IP, base+i<<8)
+			if inUse[subnet.String()] || overlapsAny(subnet, hostNets) {
+				continue
+			}
+			tap := tapNameForSubnet(subnet)
+			if n.linkExistsFn(tap) {
+				continue
+			}
+			lease = networkLease{Subnet: subnet.String(), TapName: tap, Owner: owner, PID: n.pid, Created: time.Now().UTC()}
+			return append(leases, lease), nil
+		}
+		return nil, fmt.Errorf("network pool %s has no free /24 (%d leased)", pool, len(leases))
+	})
+	return lease, err
+}
+
+// release drops the lease for subnet, if this process still holds it.
+func (n *networkAllocator) release(lease networkLease) error {
+	return n.update(func(leases []networkLease) ([]networkLease, error) {
+		kept := leases[:0]
+		for _, l := range leases {
+			if l.Subnet == lease.Subnet && l.PID == lease.PID {
+				continue
+			}
+			kept = append(kept, l)
+		}
+		return kept, nil
+	})
+}
+
+// leasedTo reports whether any live lease belongs to owner.
+func (n *networkAllocator) leasedTo(owner string) (bool, error) {
+	leased := false
+	err := n.update(func(leases []networkLease) ([]networkLease, error) {
+		for _, l := range leases {
+			leased = leased || l.Owner == owner
+		}
+		return leases, nil
+	})
+	return leased, err
+}
+
+// update applies fn to the live leases under the in-process and file locks
+// and writes the result back.
+func (n *networkAllocator) update(fn func([]networkLease) ([]networkLease, error)) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(n.path), 0o755); err != nil {
+		return err
+	}
+	lock, err := os.OpenFile(n.path+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("lock %s: %w", n.path, err)
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	var leases []networkLease
+	data, err := os.ReadFile(n.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(data, &leases); err != nil {
+			return fmt.Errorf("parse %s: %w", n.path, err)
+		}
+	}
+	live := leases[:0]
+	for _, l := range leases {
+		if l.PID == n.pid || n.processAliveFn(l.PID) {
+			live = append(live, l)
+		}
+	}
+
+	updated, err := fn(live)
+	if err != nil {
+		return err
+	}
+	if updated == nil {
+		updated = []networkLease{}
+	}
+	data, err = json.MarshalIndent(updated, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := n.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, n.path)
+}
+
+// tapNameForSubnet names the TAP after the leased /24, e.g. cr-10-200-3,
+// which fits the kernel's 15 character limit for any IPv4 subnet.
+func tapNameForSubnet(subnet *net.IPNet) string {
+	ip := subnet.IP.To4()
+	return fmt.Sprintf("cr-%d-%d-%d", ip[0], ip[1], ip[2])
+}
+
+func overlapsAny(subnet *net.IPNet, networks []*net.IPNet) bool {
+	for _, other := range networks {
+		if subnet.Contains(other.IP) || other.Contains(subnet.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostNetworks lists the IPv4 networks on the host's interfaces and in its
+// routing table, ignoring default routes.
+func hostNetworks() ([]*net.IPNet, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	var networks []*net.IPNet
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+			networks = append(networks, &net.IPNet{IP: ipnet.IP.Mask(ipnet.Mask).To4(), Mask: ipnet.Mask})
+		}
+	}
+	if data, err := os.ReadFile("/proc/net/route"); err == nil {
+		networks = append(networks, parseRouteTable(string(data))...)
+	}
+	return networks, nil
+}
+
+// parseRouteTable reads the destinations out of /proc/net/route, whose
+// addresses are little-endian hex.
+func parseRouteTable(data string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, line := range strings.Split(data, "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 8 {
+			continue
+		}
+		dest, err := strconv.ParseUint(fields[1], 16, 32)
+		if err != nil {
+			continue
+		}
+		mask, err := strconv.ParseUint(fields[7], 16, 32)
+		if err != nil || mask == 0 {
+			continue
+		}
+		ip := make(net.IP, 4)
+		binary.LittleEndian.PutUint32(ip, uint32(dest))
+		m := make(net.IPMask, 4)
+		binary.LittleEndian.PutUint32(m, uint32(mask))
+		networks = append(networks, &net.IPNet{IP: ip, Mask: m})
+	}
+	return networks
+}
+
+func linkExists(name string) bool {
+	_, err := os.Stat(filepath.Join("/sys/class/net", name))
+	return err == nil
+}
+
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
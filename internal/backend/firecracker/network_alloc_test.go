@@ -0,0 +1,105 @@
+package firecracker
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestNetworkAllocator(t *testing.T, pid int) *networkAllocator {
+	t.Helper()
+	alloc := newNetworkAllocator(filepath.Join(t.TempDir(), "network-leases.json"))
+	alloc.pid = pid
+	alloc.hostNetworksFn = func() ([]*net.IPNet, error) { return nil, nil }
+	alloc.linkExistsFn = func(string) bool { return false }
+	alloc.processAliveFn = func(int) bool { return true }
+	return alloc
+}
+
+func TestNetworkAllocatorSkipsLeasedAndHostSubnets(t *testing.T) {
+	t.Parallel()
+
+	alloc := newTestNetworkAllocator(t, 100)
+	_, hostRoute, _ := net.ParseCIDR("10.200.1.0/24")
+	alloc.hostNetworksFn = func() ([]*net.IPNet, error) { return []*net.IPNet{hostRoute}, nil }
+	alloc.linkExistsFn = func(name string) bool { return name == "cr-10-200-2" }
+
+	first, err := alloc.allocate("10.200.0.0/22", "sandbox-a")
+	if err != nil {
+		t.Fatalf("allocate: %v", err)
+	}
+	second, err := alloc.allocate("10.200.0.0/22", "sandbox-b")
+	if err != nil {
+		t.Fatalf("allocate: %v", err)
+	}
+	if first.Subnet != "10.200.0.0/24" || first.TapName != "cr-10-200-0" {
+		t.Fatalf("unexpected first lease %+v", first)
+	}
+	if second.Subnet != "10.200.3.0/24" || second.TapName != "cr-10-200-3" {
+		t.Fatalf("expected the host route and leftover tap to be skipped, got %+v", second)
+	}
+	if host, guest := second.addresses(); host != "10.200.3.1" || guest != "10.200.3.2" {
+		t.Fatalf("unexpected addresses %s %s", host, guest)
+	}
+	if _, err := alloc.allocate("10.200.0.0/22", "sandbox-c"); err == nil || !strings.Contains(err.Error(), "no free /24") {
+		t.Fatalf("expected pool exhaustion, got %v", err)
+	}
+
+	if err := alloc.release(first); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	reused, err := alloc.allocate("10.200.0.0/22", "sandbox-c")
+	if err != nil || reused.Subnet != first.Subnet {
+		t.Fatalf("expected the released subnet to be reused, got %+v, %v", reused, err)
+	}
+}
+
+func TestNetworkAllocatorReclaimsLeasesOfExitedProcesses(t *testing.T) {
+	t.Parallel()
+
+	dead := newTestNetworkAllocator(t, 200)
+	if _, err := dead.allocate("10.201.0.0/24", "sandbox-old"); err != nil {
+		t.Fatalf("allocate: %v", err)
+	}
+
+	alloc := newNetworkAllocator(dead.path)
+	alloc.pid = 300
+	alloc.hostNetworksFn = dead.hostNetworksFn
+	alloc.linkExistsFn = dead.linkExistsFn
+	alloc.processAliveFn = func(pid int) bool { return pid != 200 }
+	lease, err := alloc.allocate("10.201.0.0/24", "sandbox-new")
+	if err != nil {
+		t.Fatalf("expected the stale lease to be reclaimed, got %v", err)
+	}
+	if lease.Subnet != "10.201.0.0/24" || lease.PID != 300 {
+		t.Fatalf("unexpected lease %+v", lease)
+	}
+}
+
+func TestNetworkAllocatorRejectsInvalidPools(t *testing.T) {
+	t.Parallel()
+
+	alloc := newTestNetworkAllocator(t, 100)
+	for _, pool := range []string{"10.200.0.0/25", "fd00::/64", "not-a-cidr"} {
+		if _, err := alloc.allocate(pool, "sandbox"); err == nil {
+			t.Fatalf("expected pool %q to be rejected", pool)
+		}
+	}
+}
+
+func TestParseRouteTable(t *testing.T) {
+	t.Parallel()
+
+	table := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+		"eth0\t00000000\t0100A8C0\t0003\t0\t0\t0\t00000000\t0\t0\t0\n" +
+		"eth0\t0000A8C0\t00000000\t0001\t0\t0\t0\t00FFFFFF\t0\t0\t0\n" +
+		"docker0\t000011AC\t00000000\t0001\t0\t0\t0\t0000FFFF\t0\t0\t0\n"
+	var got []string
+	for _, network := range parseRouteTable(table) {
+		got = append(got, network.String())
+	}
+	if strings.Join(got, ",") != "192.168.0.0/24,172.17.0.0/16" {
+		t.Fatalf("unexpected routes %v", got)
+	}
+}
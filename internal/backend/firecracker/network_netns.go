@@ -0,0 +1,207 @@
+package firecracker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/policy"
+)
+
+// vethNames returns the host and namespace ends of the veth pair joining a
+// sandbox's namespace to the host, named after the lease's TAP.
+func (l networkLease) vethNames() (string, string) {
+	suffix := strings.TrimPrefix(l.TapName, "cr")
+	return "crh" + suffix, "crn" + suffix
+}
+
+// vethAddresses returns the host (.253) and namespace (.254) ends of the
+// /30 at the top of the lease's /24 that carries traffic out of the
+// namespace.
+func (l networkLease) vethAddresses() (string, string) {
+	ip, _, _ := net.ParseCIDR(l.Subnet)
+	ip4 := ip.To4()
+	host := net.IPv4(ip4[0], ip4[1], ip4[2], 253).String()
+	peer := net.IPv4(ip4[0], ip4[1], ip4[2], 254).String()
+	return host, peer
+}
+
+// setupNamespacedNetworkWithDeps builds the sandbox's TAP and policy rules
+// inside a network namespace of its own, named after the TAP, so they cannot
+// interfere with other sandboxes or the host firewall. The namespace reaches
+// the host over a veth pair and masquerades the guest behind its end of it;
+// the host only forwards and masquerades that one address. Gateway traffic
+// to the TAP address is redirected to the host end of the veth pair.
+// Deleting the namespace removes the TAP, the veth pair and every rule
+// inside it.
+func setupNamespacedNetworkWithDeps(ctx context.Context, lease networkLease, mtu int, allow []policy.AllowRule, gatewayPort int, lookup ipLookupFunc, runCommand rootCommandFunc, runBatchCommand rootCommandBatchFunc) (hostNetworkConfig, func(), error) {
+	ns := lease.TapName
+	tapName := lease.TapName
+	hostIP, guestIP := lease.addresses()
+	vethHost, vethPeer := lease.vethNames()
+	vethHostIP, vethPeerIP := lease.vethAddresses()
+
+	if runBatchCommand == nil {
+		runBatchCommand = func(ctx context.Context, commands [][]string) error {
+			for _, args := range commands {
+				_ = runCommand(ctx, args...)
+			}
+			return nil
+		}
+	}
+
+	policyResolveStart := time.Now()
+	var resolutions []backend.HostResolution
+	forwardRules, err := resolveForwardRulesWithLookup(ctx, allow, recordingLookup(lookup, &resolutions))
+	policyResolveMS := durationMillisCeil(time.Since(policyResolveStart))
+	if err != nil {
+		return hostNetworkConfig{}, func() {}, err
+	}
+
+	cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	cleanupCmds := make([][]string, 0, 12)
+	cleanup := func() {
+		defer cleanupCancel()
+		reversed := make([][]string, 0, len(cleanupCmds))
+		for i := len(cleanupCmds) - 1; i >= 0; i-- {
+			reversed = append(reversed, cleanupCmds[i])
+		}
+		_ = runBatchCommand(cleanupCtx, reversed)
+	}
+	addCleanup := func(args ...string) {
+		cleanupCmds = append(cleanupCmds, append([]string(nil), args...))
+	}
+	hostRun := func(args ...string) error {
+		return runCommand(ctx, args...)
+	}
+	nsRun := func(args ...string) error {
+		return runCommand(ctx, append([]string{"ip", "netns", "exec", ns}, args...)...)
+	}
+
+	if err := ensureCleanroomChains(ctx, runCommand); err != nil {
+		return hostNetworkConfig{}, func() {}, err
+	}
+	if err := hostRun("ip", "netns", "add", ns); err != nil {
+		return hostNetworkConfig{}, func() {}, fmt.Errorf("create network namespace %s: %w", ns, err)
+	}
+	addCleanup("ip", "netns", "del", ns)
+
+	type step struct {
+		run  func(args ...string) error
+		args []string
+		undo []string
+	}
+	steps := []step{
+		{run: hostRun, args: []string{"ip", "link", "add", vethHost, "type", "veth", "peer", "name", vethPeer, "netns", ns}, undo: []string{"ip", "link", "del", vethHost}},
+		{run: hostRun, args: []string{"ip", "addr", "add", vethHostIP + "/30", "dev", vethHost}},
+		{run: hostRun, args: []string{"sysctl", "-w", fmt.Sprintf("net.ipv6.conf.%s.disable_ipv6=1", vethHost)}},
+		{run: hostRun, args: []string{"ip", "link", "set", "dev", vethHost, "up"}},
+		{run: hostRun, args: []string{"sysctl", "-w", "net.ipv4.ip_forward=1"}},
+
+		{run: nsRun, args: []string{"ip", "link", "set", "dev", "lo", "up"}},
+		{run: nsRun, args: []string{"ip", "addr", "add", vethPeerIP + "/30", "dev", vethPeer}},
+		{run: nsRun, args: []string{"ip", "link", "set", "dev", vethPeer, "up"}},
+		{run: nsRun, args: []string{"ip", "route", "add", "default", "via", vethHostIP}},
+		{run: nsRun, args: []string{"sysctl", "-w", "net.ipv4.ip_forward=1"}},
+		{run: nsRun, args: []string{"ip", "tuntap", "add", "dev", tapName, "mode", "tap", "user", strconv.Itoa(os.Getuid())}},
+		{run: nsRun, args: []string{"ip", "addr", "add", hostIP + "/24", "dev", tapName}},
+	}
+	if mtu > 0 {
+		steps = append(steps,
+			step{run: hostRun, args: []string{"ip", "link", "set", "dev", vethHost, "mtu", strconv.Itoa(mtu)}},
+			step{run: nsRun, args: []string{"ip", "link", "set", "dev", vethPeer, "mtu", strconv.Itoa(mtu)}},
+			step{run: nsRun, args: []string{"ip", "link", "set", "dev", tapName, "mtu", strconv.Itoa(mtu)}},
+		)
+	}
+	steps = append(steps,
+		step{run: nsRun, args: []string{"ip", "link", "set", "dev", tapName, "up"}},
+		step{run: nsRun, args: []string{"sysctl", "-w", fmt.Sprintf("net.ipv6.conf.%s.disable_ipv6=1", tapName)}},
+
+		// Inside the namespace: nothing from the TAP reaches the namespace
+		// itself, and only policy traffic from the guest address is forwarded.
+		step{run: nsRun, args: []string{"iptables", "-A", "INPUT", "-i", tapName, "-j", "DROP"}},
+		step{run: nsRun, args: []string{"iptables", "-A", "FORWARD", "-i", tapName, "!", "-s", guestIP, "-j", "DROP"}},
+		step{run: nsRun, args: []string{"iptables", "-A", "FORWARD", "-o", tapName, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT"}},
+		step{run: nsRun, args: []string{"iptables", "-A", "FORWARD", "-i", tapName, "-p", "udp", "-d", guestDNSServer, "--dport", "53", "-j", "ACCEPT"}},
+		step{run: nsRun, args: []string{"iptables", "-A", "FORWARD", "-i", tapName, "-p", "tcp", "-d", guestDNSServer, "--dport", "53", "-j", "ACCEPT"}},
+	)
+	if gatewayPort > 0 {
+		port := strconv.Itoa(gatewayPort)
+		steps = append(steps,
+			step{run: nsRun, args: []string{"iptables", "-t", "nat", "-A", "PREROUTING", "-i", tapName, "-p", "tcp", "-d", hostIP, "--dport", port, "-j", "DNAT", "--to-destination", vethHostIP + ":" + port}},
+			step{run: nsRun, args: []string{"iptables", "-A", "FORWARD", "-i", tapName, "-p", "tcp", "-d", vethHostIP, "--dport", port, "-j", "ACCEPT"}},
+		)
+	}
+	for _, rule := range forwardRules {
+		steps = append(steps, step{run: nsRun, args: slices.Concat([]string{"iptables", "-A", "FORWARD", "-i", tapName}, rule.matchArgs(), []string{"-j", "ACCEPT"})})
+	}
+	steps = append(steps,
+		step{run: nsRun, args: []string{"iptables", "-A", "FORWARD", "-i", tapName, "-j", "DROP"}},
+		step{run: nsRun, args: []string{"iptables", "-t", "nat", "-A", "POSTROUTING", "-s", guestIP + "/32", "-o", vethPeer, "-j", "MASQUERADE"}},
+
+		// On the host: only the namespace's veth address is accepted, it may
+		// reach the gateway port and nothing else on the host, and its
+		// forwarded traffic is masqueraded like any other host traffic.
+		step{run: hostRun, args: []string{"iptables", "-A", inputChain, "-i", vethHost, "!", "-s", vethPeerIP, "-j", "DROP"}, undo: []string{"iptables", "-D", inputChain, "-i", vethHost, "!", "-s", vethPeerIP, "-j", "DROP"}},
+	)
+	if gatewayPort > 0 {
+		port := strconv.Itoa(gatewayPort)
+		steps = append(steps, step{run: hostRun, args: []string{"iptables", "-A", inputChain, "-i", vethHost, "-s", vethPeerIP, "-p", "tcp", "--dport", port, "-j", "ACCEPT"}, undo: []string{"iptables", "-D", inputChain, "-i", vethHost, "-s", vethPeerIP, "-p", "tcp", "--dport", port, "-j", "ACCEPT"}})
+	}
+	steps = append(steps,
+		step{run: hostRun, args: []string{"iptables", "-A", inputChain, "-i", vethHost, "-j", "DROP"}, undo: []string{"iptables", "-D", inputChain, "-i", vethHost, "-j", "DROP"}},
+		step{run: hostRun, args: []string{"iptables", "-A", forwardChain, "-i", vethHost, "-s", vethPeerIP, "-j", "ACCEPT"}, undo: []string{"iptables", "-D", forwardChain, "-i", vethHost, "-s", vethPeerIP, "-j", "ACCEPT"}},
+		step{run: hostRun, args: []string{"iptables", "-A", forwardChain, "-o", vethHost, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT"}, undo: []string{"iptables", "-D", forwardChain, "-o", vethHost, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT"}},
+		step{run: hostRun, args: []string{"iptables", "-t", "nat", "-A", postroutingChain, "-s", vethPeerIP + "/32", "-j", "MASQUERADE"}, undo: []string{"iptables", "-t", "nat", "-D", postroutingChain, "-s", vethPeerIP + "/32", "-j", "MASQUERADE"}},
+	)
+
+	for _, s := range steps {
+		if err := s.run(s.args...); err != nil {
+			cleanup()
+			return hostNetworkConfig{}, func() {}, fmt.Errorf("configure network namespace %s: %w", ns, err)
+		}
+		if len(s.undo) > 0 {
+			addCleanup(s.undo...)
+		}
+	}
+
+	return hostNetworkConfig{
+		TapName:         tapName,
+		HostIP:          hostIP,
+		GuestIP:         guestIP,
+		Namespace:       ns,
+		SourceIP:        vethPeerIP,
+		MTU:             mtu,
+		PolicyResolveMS: policyResolveMS,
+		Resolutions:     resolutions,
+	}, cleanup, nil
+}
+
+// firecrackerCommand starts firecracker directly, or inside the sandbox's
+// network namespace when it has one. Entering a namespace needs root, so the
+// process is started through sudo and drops back to the current user before
+// firecracker runs.
+func firecrackerCommand(ctx context.Context, netCfg hostNetworkConfig, firecrackerPath string, args ...string) *exec.Cmd {
+	if netCfg.Namespace == "" {
+		return exec.CommandContext(ctx, firecrackerPath, args...)
+	}
+	wrapped := []string{
+		"-n", "ip", "netns", "exec", netCfg.Namespace,
+		"setpriv", "--reuid", strconv.Itoa(os.Getuid()), "--regid", strconv.Itoa(os.Getgid()), "--init-groups", "--",
+		firecrackerPath,
+	}
+	cmd := exec.CommandContext(ctx, "sudo", append(wrapped, args...)...)
+	// sudo relays SIGTERM to firecracker but cannot relay SIGKILL.
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	return cmd
+}
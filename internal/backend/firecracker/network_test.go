@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"net"
+	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/buildkite/cleanroom/internal/backend"
 	"github.com/buildkite/cleanroom/internal/policy"
 )
 
@@ -84,7 +87,7 @@ func TestSetupHostNetworkWithDepsAddsDenyDefaultAndCleanupIndependentContext(t *
 	}
 
 	reqCtx, cancel := context.WithCancel(context.Background())
-	cfg, cleanup, err := setupHostNetworkWithDeps(reqCtx, "run-12345", []policy.AllowRule{{Host: "proxy.golang.org", Ports: []int{443}}}, 8170, lookup, run, runBatch)
+	cfg, cleanup, err := setupHostNetworkWithDeps(reqCtx, networkLease{Subnet: "10.200.3.0/24", TapName: "cr-10-200-3"}, 1400, []policy.AllowRule{{Host: "proxy.golang.org", Ports: []int{443}}}, 8170, lookup, run, runBatch)
 	if err != nil {
 		t.Fatalf("setupHostNetworkWithDeps: %v", err)
 	}
@@ -98,38 +101,48 @@ func TestSetupHostNetworkWithDepsAddsDenyDefaultAndCleanupIndependentContext(t *
 	if cfg.PolicyResolveMS <= 0 {
 		t.Fatalf("expected positive policy resolve timing, got %d", cfg.PolicyResolveMS)
 	}
+	if len(cfg.Resolutions) != 1 || cfg.Resolutions[0].Host != "proxy.golang.org" || strings.Join(cfg.Resolutions[0].Addresses, ",") != "142.251.41.17" {
+		t.Fatalf("unexpected recorded resolutions %+v", cfg.Resolutions)
+	}
 	haystack := make([]string, 0, len(calls))
 	for _, c := range calls {
 		haystack = append(haystack, strings.Join(c.args, " "))
 	}
 	joined := strings.Join(haystack, "\n")
-	if !strings.Contains(joined, "iptables -A FORWARD -i "+tap+" -j DROP") {
-		t.Fatalf("expected default deny FORWARD rule for tap %s\ncalls:\n%s", tap, joined)
+	if !strings.Contains(joined, "iptables -A CLEANROOM-FORWARD -i "+tap+" -j DROP") {
+		t.Fatalf("expected default deny CLEANROOM-FORWARD rule for tap %s\ncalls:\n%s", tap, joined)
 	}
-	if strings.Contains(joined, "iptables -A FORWARD -i "+tap+" -j ACCEPT") {
+	if strings.Contains(joined, "iptables -A CLEANROOM-FORWARD -i "+tap+" -j ACCEPT") {
 		t.Fatalf("unexpected blanket ACCEPT FORWARD rule for tap %s\ncalls:\n%s", tap, joined)
 	}
-	if !strings.Contains(joined, "iptables -A FORWARD -i "+tap+" -p tcp -d 142.251.41.17 --dport 443 -j ACCEPT") {
+	if !strings.Contains(joined, "iptables -A CLEANROOM-FORWARD -i "+tap+" -p tcp -d 142.251.41.17 --dport 443 -j ACCEPT") {
 		t.Fatalf("expected tcp allow rule for policy host\ncalls:\n%s", joined)
 	}
-	if !strings.Contains(joined, "iptables -A FORWARD -i "+tap+" -p udp -d 142.251.41.17 --dport 443 -j ACCEPT") {
+	if !strings.Contains(joined, "iptables -A CLEANROOM-FORWARD -i "+tap+" -p udp -d 142.251.41.17 --dport 443 -j ACCEPT") {
 		t.Fatalf("expected udp allow rule for policy host\ncalls:\n%s", joined)
 	}
 
+	if !strings.Contains(joined, "ip link set dev "+tap+" mtu 1400") || cfg.MTU != 1400 {
+		t.Fatalf("expected tap mtu to be set to 1400\ncalls:\n%s", joined)
+	}
+	if args := guestNetworkBootArgs(cfg); !strings.Contains(args, "cleanroom_guest_ip=10.200.3.2 cleanroom_guest_gw=10.200.3.1") || !strings.HasSuffix(args, " cleanroom_guest_mtu=1400") {
+		t.Fatalf("unexpected guest network boot args %q", args)
+	}
+
 	// Verify anti-spoof INPUT rules.
-	if !strings.Contains(joined, "iptables -A INPUT -i "+tap+" ! -s "+cfg.GuestIP+" -j DROP") {
+	if !strings.Contains(joined, "iptables -A CLEANROOM-INPUT -i "+tap+" ! -s "+cfg.GuestIP+" -j DROP") {
 		t.Fatalf("expected anti-spoof INPUT rule for tap %s\ncalls:\n%s", tap, joined)
 	}
-	if !strings.Contains(joined, "iptables -A INPUT -i "+tap+" -s "+cfg.GuestIP+" -p tcp --dport 8170 -j ACCEPT") {
+	if !strings.Contains(joined, "iptables -A CLEANROOM-INPUT -i "+tap+" -s "+cfg.GuestIP+" -p tcp --dport 8170 -j ACCEPT") {
 		t.Fatalf("expected gateway INPUT ACCEPT rule for tap %s\ncalls:\n%s", tap, joined)
 	}
-	if !strings.Contains(joined, "iptables -A INPUT -i "+tap+" -j DROP") {
+	if !strings.Contains(joined, "iptables -A CLEANROOM-INPUT -i "+tap+" -j DROP") {
 		t.Fatalf("expected INPUT catch-all DROP rule for tap %s\ncalls:\n%s", tap, joined)
 	}
 
 	// Verify INPUT rules appear before FORWARD rules.
-	inputAntiSpoofIdx := strings.Index(joined, "iptables -A INPUT -i "+tap+" ! -s ")
-	forwardIdx := strings.Index(joined, "iptables -A FORWARD -i "+tap)
+	inputAntiSpoofIdx := strings.Index(joined, "iptables -A CLEANROOM-INPUT -i "+tap+" ! -s ")
+	forwardIdx := strings.Index(joined, "iptables -A CLEANROOM-FORWARD -i "+tap)
 	if inputAntiSpoofIdx < 0 || forwardIdx < 0 || inputAntiSpoofIdx > forwardIdx {
 		t.Fatalf("INPUT rules must appear before FORWARD rules\ncalls:\n%s", joined)
 	}
@@ -149,6 +162,80 @@ func TestSetupHostNetworkWithDepsAddsDenyDefaultAndCleanupIndependentContext(t *
 	}
 }
 
+func TestSetupNamespacedNetworkKeepsPolicyRulesInsideTheNamespace(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	run := func(_ context.Context, args ...string) error {
+		calls = append(calls, strings.Join(args, " "))
+		return nil
+	}
+	var cleanupCalls []string
+	runBatch := func(_ context.Context, commands [][]string) error {
+		for _, args := range commands {
+			cleanupCalls = append(cleanupCalls, strings.Join(args, " "))
+		}
+		return nil
+	}
+	lookup := func(context.Context, string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("142.251.41.17")}, nil
+	}
+
+	lease := networkLease{Subnet: "10.200.3.0/24", TapName: "cr-10-200-3"}
+	cfg, cleanup, err := setupNamespacedNetworkWithDeps(context.Background(), lease, 0, []policy.AllowRule{{Host: "proxy.golang.org", Ports: []int{443}}}, 8170, lookup, run, runBatch)
+	if err != nil {
+		t.Fatalf("setupNamespacedNetworkWithDeps: %v", err)
+	}
+	if cfg.Namespace != "cr-10-200-3" || cfg.GuestIP != "10.200.3.2" || cfg.SourceIP != "10.200.3.254" {
+		t.Fatalf("unexpected network config %+v", cfg)
+	}
+
+	const inNS = "ip netns exec cr-10-200-3 "
+	for _, want := range []string{
+		"ip netns add cr-10-200-3",
+		"ip link add crh-10-200-3 type veth peer name crn-10-200-3 netns cr-10-200-3",
+		inNS + "ip tuntap add dev cr-10-200-3 mode tap user " + strconv.Itoa(os.Getuid()),
+		inNS + "iptables -A FORWARD -i cr-10-200-3 -p tcp -d 142.251.41.17 --dport 443 -j ACCEPT",
+		inNS + "iptables -t nat -A PREROUTING -i cr-10-200-3 -p tcp -d 10.200.3.1 --dport 8170 -j DNAT --to-destination 10.200.3.253:8170",
+		inNS + "iptables -A FORWARD -i cr-10-200-3 -j DROP",
+		"iptables -A CLEANROOM-INPUT -i crh-10-200-3 -s 10.200.3.254 -p tcp --dport 8170 -j ACCEPT",
+	} {
+		if !slices.Contains(calls, want) {
+			t.Fatalf("expected %q\ncalls:\n%s", want, strings.Join(calls, "\n"))
+		}
+	}
+	for _, call := range calls {
+		if strings.HasPrefix(call, "iptables") && (strings.Contains(call, "cr-10-200-3") || !strings.Contains(call, "CLEANROOM-")) {
+			t.Fatalf("tap rule installed in the host namespace: %s", call)
+		}
+	}
+
+	cleanup()
+	if len(cleanupCalls) == 0 || cleanupCalls[len(cleanupCalls)-1] != "ip netns del cr-10-200-3" {
+		t.Fatalf("expected cleanup to end by deleting the namespace, got %v", cleanupCalls)
+	}
+	for _, call := range cleanupCalls {
+		if strings.HasPrefix(call, inNS) {
+			t.Fatalf("namespace rules should go with the namespace, got %s", call)
+		}
+	}
+}
+
+func TestFirecrackerCommandEntersSandboxNamespace(t *testing.T) {
+	t.Parallel()
+
+	direct := firecrackerCommand(context.Background(), hostNetworkConfig{}, "/usr/bin/firecracker", "--api-sock", "fc.sock")
+	if got := strings.Join(direct.Args, " "); got != "/usr/bin/firecracker --api-sock fc.sock" {
+		t.Fatalf("unexpected direct command %q", got)
+	}
+
+	namespaced := firecrackerCommand(context.Background(), hostNetworkConfig{Namespace: "cr-10-200-3"}, "/usr/bin/firecracker", "--api-sock", "fc.sock")
+	want := "sudo -n ip netns exec cr-10-200-3 setpriv --reuid " + strconv.Itoa(os.Getuid()) + " --regid " + strconv.Itoa(os.Getgid()) + " --init-groups -- /usr/bin/firecracker --api-sock fc.sock"
+	if got := strings.Join(namespaced.Args, " "); got != want {
+		t.Fatalf("unexpected namespaced command %q, want %q", got, want)
+	}
+}
+
 func TestInstallForwardReturnPathRuleFallsBackToStateModule(t *testing.T) {
 	t.Parallel()
 
@@ -180,3 +267,168 @@ func TestInstallForwardReturnPathRuleFallsBackToStateModule(t *testing.T) {
 		t.Fatalf("expected cleanup rule for state module, got %q", got)
 	}
 }
+
+func TestSimulateEgressReportsMatchingRule(t *testing.T) {
+	t.Parallel()
+
+	allow := []policy.AllowRule{
+		{Host: "api.github.com", Ports: []int{443}},
+		{Host: "registry.npmjs.org", Ports: []int{443}},
+	}
+	lookups := map[string]int{}
+	lookup := func(_ context.Context, host string) ([]net.IP, error) {
+		lookups[host]++
+		switch host {
+		case "api.github.com":
+			return []net.IP{net.ParseIP("203.0.113.10"), net.ParseIP("203.0.113.11")}, nil
+		case "registry.npmjs.org":
+			return []net.IP{net.ParseIP("203.0.113.20")}, nil
+		case "example.com":
+			return []net.IP{net.ParseIP("198.51.100.1")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	verdicts, err := simulateEgressWithLookup(context.Background(), allow, "API.github.com", 443, "tcp", lookup)
+	if err != nil {
+		t.Fatalf("simulate: %v", err)
+	}
+	if len(verdicts) != 2 || !verdicts[0].Allowed || verdicts[0].Rule != "api.github.com" || verdicts[1].IP != "203.0.113.11" || !verdicts[1].Allowed {
+		t.Fatalf("unexpected verdicts %+v", verdicts)
+	}
+	if lookups["api.github.com"] != 1 {
+		t.Fatalf("expected the destination to reuse the policy's answer, got %d lookups", lookups["api.github.com"])
+	}
+
+	cases := []struct {
+		dest     string
+		port     int
+		protocol string
+		allowed  bool
+		rule     string
+	}{
+		{dest: "api.github.com", port: 80, protocol: "tcp"},
+		{dest: "example.com", port: 443, protocol: "tcp"},
+		{dest: "203.0.113.20", port: 443, protocol: "udp", allowed: true, rule: "registry.npmjs.org"},
+		{dest: "1.1.1.1", port: 53, protocol: "udp", allowed: true, rule: "dns"},
+	}
+	for _, tc := range cases {
+		verdicts, err := simulateEgressWithLookup(context.Background(), allow, tc.dest, tc.port, tc.protocol, lookup)
+		if err != nil {
+			t.Fatalf("simulate %s:%d: %v", tc.dest, tc.port, err)
+		}
+		for _, verdict := range verdicts {
+			if verdict.Allowed != tc.allowed || verdict.Rule != tc.rule {
+				t.Fatalf("simulate %s:%d/%s: unexpected verdicts %+v", tc.dest, tc.port, tc.protocol, verdicts)
+			}
+		}
+	}
+}
+
+func TestSimulateEgressFailsLikeSandboxCreation(t *testing.T) {
+	t.Parallel()
+
+	lookup := func(_ context.Context, _ string) ([]net.IP, error) {
+		return nil, errors.New("dns down")
+	}
+	_, err := simulateEgressWithLookup(context.Background(), []policy.AllowRule{{Host: "example.com", Ports: []int{443}}}, "203.0.113.1", 443, "tcp", lookup)
+	if err == nil || !strings.Contains(err.Error(), `resolve policy host "example.com"`) {
+		t.Fatalf("expected a policy resolution error, got %v", err)
+	}
+}
+
+func TestPinnedLookupOverridesListedHosts(t *testing.T) {
+	t.Parallel()
+
+	lookup := pinnedLookup([]backend.HostResolution{
+		{Host: "API.github.com", Addresses: []string{"203.0.113.10", "203.0.113.11"}},
+		{Host: "bad.example", Addresses: []string{"2001:db8::1"}},
+	}, func(_ context.Context, host string) ([]net.IP, error) {
+		if host != "registry.npmjs.org" {
+			t.Fatalf("unexpected DNS lookup for %q", host)
+		}
+		return []net.IP{net.ParseIP("203.0.113.20")}, nil
+	})
+
+	var recorded []backend.HostResolution
+	rules, err := resolveForwardRulesWithLookup(context.Background(), []policy.AllowRule{
+		{Host: "api.github.com", Ports: []int{443}},
+		{Host: "registry.npmjs.org", Ports: []int{443}},
+	}, recordingLookup(lookup, &recorded))
+	if err != nil {
+		t.Fatalf("resolve rules: %v", err)
+	}
+	if len(rules) != 6 {
+		t.Fatalf("expected tcp and udp rules for three addresses, got %+v", rules)
+	}
+	if len(recorded) != 2 || strings.Join(recorded[0].Addresses, ",") != "203.0.113.10,203.0.113.11" || recorded[1].Host != "registry.npmjs.org" {
+		t.Fatalf("unexpected recorded resolutions %+v", recorded)
+	}
+
+	_, err = resolveForwardRulesWithLookup(context.Background(), []policy.AllowRule{{Host: "bad.example", Ports: []int{443}}}, lookup)
+	if err == nil || !strings.Contains(err.Error(), "not an IPv4 address") {
+		t.Fatalf("expected an invalid pin error, got %v", err)
+	}
+}
+
+func TestBandwidthLimiterRefillsOneSecondOfBytes(t *testing.T) {
+	t.Parallel()
+
+	if got := bandwidthLimiter(0); got != nil {
+		t.Fatalf("expected no limiter for 0 mbps, got %+v", got)
+	}
+	got := bandwidthLimiter(100)
+	if got == nil || got.Bandwidth == nil {
+		t.Fatal("expected a bandwidth limiter")
+	}
+	if want := (tokenBucket{Size: 12_500_000, RefillTime: 1000}); *got.Bandwidth != want {
+		t.Fatalf("unexpected token bucket: got %+v want %+v", *got.Bandwidth, want)
+	}
+}
+
+func TestResolveForwardRulesHonoursProtocolAndPortRanges(t *testing.T) {
+	t.Parallel()
+
+	allow := []policy.AllowRule{
+		{Host: "ntp.example.com", Protocol: "udp", Ports: []int{123}, PortRanges: []policy.PortRange{{From: 8000, To: 8100}}},
+		{Host: "ntp.example.com", Protocol: "icmp"},
+	}
+	lookup := func(context.Context, string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("203.0.113.5")}, nil
+	}
+	rules, err := resolveForwardRulesWithLookup(context.Background(), allow, lookup)
+	if err != nil {
+		t.Fatalf("resolve rules: %v", err)
+	}
+	var got []string
+	for _, rule := range rules {
+		got = append(got, strings.Join(rule.matchArgs(), " "))
+	}
+	want := []string{
+		"-p udp -d 203.0.113.5 --dport 123",
+		"-p udp -d 203.0.113.5 --dport 8000:8100",
+		"-p icmp -d 203.0.113.5",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("unexpected rules:\n got %q\nwant %q", got, want)
+	}
+
+	for _, tc := range []struct {
+		port     int
+		protocol string
+		allowed  bool
+	}{
+		{port: 8050, protocol: "udp", allowed: true},
+		{port: 8050, protocol: "tcp"},
+		{port: 8101, protocol: "udp"},
+		{protocol: "icmp", allowed: true},
+	} {
+		verdicts, err := simulateEgressWithLookup(context.Background(), allow, "ntp.example.com", tc.port, tc.protocol, lookup)
+		if err != nil {
+			t.Fatalf("simulate %d/%s: %v", tc.port, tc.protocol, err)
+		}
+		if len(verdicts) != 1 || verdicts[0].Allowed != tc.allowed {
+			t.Fatalf("simulate %d/%s: unexpected verdicts %+v", tc.port, tc.protocol, verdicts)
+		}
+	}
+}
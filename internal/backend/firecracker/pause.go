@@ -0,0 +1,51 @@
+package firecracker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// PauseSandbox stops the sandbox's vCPUs through the Firecracker API. The
+// guest's memory, devices and network links are kept, and its clock does
+// not advance until it is resumed.
+func (a *Adapter) PauseSandbox(ctx context.Context, sandboxID string) error {
+	instance, err := a.runningSandbox(sandboxID)
+	if err != nil {
+		return err
+	}
+	if err := instance.api.pause(ctx); err != nil {
+		return fmt.Errorf("pause sandbox %q: %w", sandboxID, err)
+	}
+	return nil
+}
+
+func (a *Adapter) ResumeSandbox(ctx context.Context, sandboxID string) error {
+	instance, err := a.runningSandbox(sandboxID)
+	if err != nil {
+		return err
+	}
+	if err := instance.api.resume(ctx); err != nil {
+		return fmt.Errorf("resume sandbox %q: %w", sandboxID, err)
+	}
+	return nil
+}
+
+// runningSandbox returns the sandbox's instance if its Firecracker process
+// is still running.
+func (a *Adapter) runningSandbox(sandboxID string) (*sandboxInstance, error) {
+	sandboxID = strings.TrimSpace(sandboxID)
+	a.sandboxMu.Lock()
+	instance, ok := a.sandboxes[sandboxID]
+	a.sandboxMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown sandbox %q", sandboxID)
+	}
+	if err := instance.exitedErrOrNil(); err != nil {
+		return nil, fmt.Errorf("sandbox %q is not running: %w", sandboxID, err)
+	}
+	if instance.api == nil {
+		return nil, fmt.Errorf("sandbox %q has no firecracker API socket", sandboxID)
+	}
+	return instance, nil
+}
@@ -5,7 +5,9 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"math"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,6 +16,7 @@ import (
 
 	"github.com/buildkite/cleanroom/internal/backend"
 	"github.com/buildkite/cleanroom/internal/policy"
+	"github.com/buildkite/cleanroom/internal/rundir"
 	"github.com/buildkite/cleanroom/internal/vsockexec"
 )
 
@@ -23,7 +26,7 @@ func TestProvisionSandboxRejectsConcurrentProvisionForSameID(t *testing.T) {
 	block := make(chan struct{})
 	started := make(chan struct{})
 	adapter := &Adapter{
-		launchSandboxVMFn: func(_ context.Context, sandboxID string, _ *policy.CompiledPolicy, _ backend.FirecrackerConfig) (*sandboxInstance, error) {
+		launchSandboxVMFn: func(_ context.Context, sandboxID, _ string, _ *policy.CompiledPolicy, _ backend.FirecrackerConfig, _ []backend.HostResolution) (*sandboxInstance, error) {
 			if sandboxID != "cr-test" {
 				t.Fatalf("unexpected sandbox id %q", sandboxID)
 			}
@@ -153,8 +156,11 @@ func TestRunInSandboxWritesRunObservabilityForStatusCommand(t *testing.T) {
 	if got, want := result.RunDir, runDir; got != want {
 		t.Fatalf("unexpected run dir in result: got %q want %q", got, want)
 	}
+	if timings := result.Timings; timings == nil || timings.VsockWait != 5*time.Millisecond || timings.GuestExec != 8*time.Millisecond || timings.VMReady != 0 {
+		t.Fatalf("unexpected result timings: %+v", timings)
+	}
 
-	obsPath := filepath.Join(runDir, runObservabilityFile)
+	obsPath := filepath.Join(runDir, rundir.ObservabilityFile)
 	b, err := os.ReadFile(obsPath)
 	if err != nil {
 		t.Fatalf("read observability file: %v", err)
@@ -199,7 +205,7 @@ func TestRunInSandboxWritesRunObservabilityOnError(t *testing.T) {
 		t.Fatal("expected RunInSandbox to fail")
 	}
 
-	obsPath := filepath.Join(runDir, runObservabilityFile)
+	obsPath := filepath.Join(runDir, rundir.ObservabilityFile)
 	b, readErr := os.ReadFile(obsPath)
 	if readErr != nil {
 		t.Fatalf("read observability file: %v", readErr)
@@ -344,3 +350,256 @@ func TestDownloadSandboxFileEnforcesMaxBytes(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestDownloadSandboxFileDeltaReportsOldAgents(t *testing.T) {
+	t.Parallel()
+
+	for name, res := range map[string]vsockexec.ExecResponse{
+		"delta":     {ExitCode: 0, Stdout: "delta"},
+		"old agent": {ExitCode: 1, Error: "missing command"},
+	} {
+		adapter := &Adapter{}
+		adapter.runGuestCommandFn = func(_ context.Context, _ context.Context, _ <-chan struct{}, _ func() error, _ string, _ uint32, req vsockexec.ExecRequest, _ backend.OutputStream) (vsockexec.ExecResponse, guestExecTiming, error) {
+			if req.FileDelta == nil || req.FileDelta.Path != "/out/app.bin" || req.FileDelta.BlockSize != 4096 || len(req.Command) != 0 {
+				t.Fatalf("%s: unexpected request: %+v", name, req)
+			}
+			return res, guestExecTiming{}, nil
+		}
+		adapter.sandboxes = map[string]*sandboxInstance{
+			"cr-test": {SandboxID: "cr-test", exitedCh: make(chan struct{})},
+		}
+
+		delta, err := adapter.DownloadSandboxFileDelta(context.Background(), "cr-test", "/out/app.bin", 0, 4096, nil)
+		if name == "old agent" {
+			if !errors.Is(err, backend.ErrFileDeltaUnsupported) {
+				t.Fatalf("%s: expected ErrFileDeltaUnsupported, got %v", name, err)
+			}
+			continue
+		}
+		if err != nil || string(delta) != "delta" {
+			t.Fatalf("%s: got %q, %v", name, delta, err)
+		}
+	}
+}
+
+func TestRunInSandboxForwardsExecutionScopeToGuest(t *testing.T) {
+	t.Parallel()
+
+	var got *vsockexec.ResourceLimits
+	var keepBackground, freshHome bool
+	var launcher string
+	adapter := &Adapter{}
+	adapter.runGuestCommandFn = func(_ context.Context, _ context.Context, _ <-chan struct{}, _ func() error, _ string, _ uint32, req vsockexec.ExecRequest, _ backend.OutputStream) (vsockexec.ExecResponse, guestExecTiming, error) {
+		got = req.Limits
+		keepBackground = req.KeepBackground
+		freshHome = req.FreshHome
+		launcher = req.Launcher
+		return vsockexec.ExecResponse{ExitCode: 0}, guestExecTiming{}, nil
+	}
+	adapter.sandboxes = map[string]*sandboxInstance{
+		"cr-test": {
+			SandboxID: "cr-test",
+			VsockPath: "/tmp/fake.sock",
+			GuestPort: 10700,
+			exitedCh:  make(chan struct{}),
+		},
+	}
+
+	_, err := adapter.RunInSandbox(context.Background(), backend.RunRequest{
+		SandboxID:               "cr-test",
+		RunID:                   "run-limits",
+		Command:                 []string{"make", "test"},
+		Limits:                  backend.ResourceLimits{Nice: 10, CPUWeight: 20, MemoryMaxBytes: 64 * 1024 * 1024},
+		KeepBackgroundProcesses: true,
+		FreshHome:               true,
+		Launcher:                backend.ExecLauncherDirect,
+		FirecrackerConfig: backend.FirecrackerConfig{
+			RunDir: t.TempDir(),
+		},
+	}, backend.OutputStream{})
+	if err != nil {
+		t.Fatalf("RunInSandbox returned error: %v", err)
+	}
+	want := vsockexec.ResourceLimits{Nice: 10, CPUWeight: 20, MemoryMaxBytes: 64 * 1024 * 1024}
+	if got == nil || *got != want {
+		t.Fatalf("unexpected guest limits: got %+v want %+v", got, want)
+	}
+	if !keepBackground {
+		t.Fatal("expected keep_background to be forwarded to the guest")
+	}
+	if !freshHome {
+		t.Fatal("expected fresh_home to be forwarded to the guest")
+	}
+	if got, want := launcher, vsockexec.LauncherDirect; got != want {
+		t.Fatalf("unexpected guest launcher: got %q want %q", got, want)
+	}
+}
+
+// startFakeGuestVsock listens like firecracker's vsock proxy: it answers the
+// host's CONNECT line and hands the connection to serve as the guest agent.
+func startFakeGuestVsock(t *testing.T, serve func(conn net.Conn, dec *json.Decoder)) string {
+	t.Helper()
+	// Unix socket paths are short; t.TempDir can exceed the limit.
+	dir, err := os.MkdirTemp("", "fcvsock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, "v.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Read the handshake a byte at a time so no request bytes are buffered.
+		var line []byte
+		buf := make([]byte, 1)
+		for len(line) == 0 || line[len(line)-1] != '\n' {
+			if _, err := io.ReadFull(conn, buf); err != nil {
+				return
+			}
+			line = append(line, buf[0])
+		}
+		if !strings.HasPrefix(string(line), "CONNECT ") {
+			t.Errorf("unexpected handshake %q", line)
+			return
+		}
+		if _, err := io.WriteString(conn, "OK 1\n"); err != nil {
+			return
+		}
+		serve(conn, json.NewDecoder(conn))
+	}()
+	return path
+}
+
+func TestRunInSandboxForwardsTTYInputAndResizeToGuest(t *testing.T) {
+	t.Parallel()
+
+	type guestSaw struct {
+		req    vsockexec.ExecRequest
+		frames []vsockexec.ExecInputFrame
+	}
+	sawCh := make(chan guestSaw, 1)
+	vsockPath := startFakeGuestVsock(t, func(conn net.Conn, dec *json.Decoder) {
+		var saw guestSaw
+		defer func() { sawCh <- saw }()
+		if err := dec.Decode(&saw.req); err != nil {
+			t.Errorf("decode request: %v", err)
+			return
+		}
+		var stdin []byte
+		for {
+			var frame vsockexec.ExecInputFrame
+			if err := dec.Decode(&frame); err != nil {
+				t.Errorf("decode input frame: %v", err)
+				return
+			}
+			saw.frames = append(saw.frames, frame)
+			if frame.Type == "stdin" {
+				stdin = append(stdin, frame.Data...)
+			}
+			if frame.Type == "eof" {
+				break
+			}
+		}
+		_ = vsockexec.EncodeStreamFrame(conn, vsockexec.ExecStreamFrame{Type: "stdout", Data: stdin})
+		_ = vsockexec.EncodeStreamFrame(conn, vsockexec.ExecStreamFrame{Type: "exit", ExitCode: 7})
+	})
+
+	adapter := &Adapter{sandboxes: map[string]*sandboxInstance{
+		"cr-test": {SandboxID: "cr-test", VsockPath: vsockPath, GuestPort: 10700, exitedCh: make(chan struct{})},
+	}}
+	var stdout bytes.Buffer
+	result, err := adapter.RunInSandbox(context.Background(), backend.RunRequest{
+		SandboxID:         "cr-test",
+		RunID:             "run-tty",
+		Command:           []string{"sh"},
+		TTY:               true,
+		FirecrackerConfig: backend.FirecrackerConfig{RunDir: t.TempDir()},
+	}, backend.OutputStream{
+		OnStdout: func(chunk []byte) { stdout.Write(chunk) },
+		OnAttach: func(attach backend.AttachIO) {
+			go func() {
+				_ = attach.ResizeTTY(120, 40)
+				_ = attach.WriteStdin([]byte("echo hi\n"))
+				_ = attach.CloseStdin()
+			}()
+		},
+	})
+	if err != nil {
+		t.Fatalf("RunInSandbox returned error: %v", err)
+	}
+	saw := <-sawCh
+	if !saw.req.TTY || strings.Join(saw.req.Command, " ") != "sh" {
+		t.Fatalf("unexpected guest request %+v", saw.req)
+	}
+	if len(saw.frames) != 3 || saw.frames[0].Type != "resize" || saw.frames[0].Cols != 120 || saw.frames[0].Rows != 40 {
+		t.Fatalf("unexpected input frames %+v", saw.frames)
+	}
+	if result.ExitCode != 7 || stdout.String() != "echo hi\n" {
+		t.Fatalf("unexpected result exit=%d stdout=%q", result.ExitCode, stdout.String())
+	}
+}
+
+func TestRunInSandboxKeepsStdinOpenUntilClosed(t *testing.T) {
+	t.Parallel()
+
+	framesCh := make(chan []vsockexec.ExecInputFrame, 1)
+	vsockPath := startFakeGuestVsock(t, func(conn net.Conn, dec *json.Decoder) {
+		var frames []vsockexec.ExecInputFrame
+		defer func() { framesCh <- frames }()
+		var req vsockexec.ExecRequest
+		if err := dec.Decode(&req); err != nil || !req.Stdin || req.TTY {
+			t.Errorf("unexpected request %+v (%v)", req, err)
+			return
+		}
+		for {
+			var frame vsockexec.ExecInputFrame
+			if err := dec.Decode(&frame); err != nil {
+				t.Errorf("decode input frame: %v", err)
+				return
+			}
+			frames = append(frames, frame)
+			if frame.Type == "eof" {
+				break
+			}
+		}
+		_ = vsockexec.EncodeStreamFrame(conn, vsockexec.ExecStreamFrame{Type: "exit"})
+	})
+
+	adapter := &Adapter{sandboxes: map[string]*sandboxInstance{
+		"cr-test": {SandboxID: "cr-test", VsockPath: vsockPath, GuestPort: 10700, exitedCh: make(chan struct{})},
+	}}
+	_, err := adapter.RunInSandbox(context.Background(), backend.RunRequest{
+		SandboxID:         "cr-test",
+		RunID:             "run-stdin",
+		Command:           []string{"cat"},
+		Stdin:             true,
+		FirecrackerConfig: backend.FirecrackerConfig{RunDir: t.TempDir()},
+	}, backend.OutputStream{
+		OnAttach: func(attach backend.AttachIO) {
+			go func() {
+				_ = attach.WriteStdin([]byte("a"))
+				_ = attach.WriteStdin([]byte("b"))
+				_ = attach.CloseStdin()
+			}()
+		},
+	})
+	if err != nil {
+		t.Fatalf("RunInSandbox returned error: %v", err)
+	}
+	frames := <-framesCh
+	var got []string
+	for _, frame := range frames {
+		got = append(got, frame.Type+":"+string(frame.Data))
+	}
+	if strings.Join(got, ",") != "stdin:a,stdin:b,eof:" {
+		t.Fatalf("expected stdin to stay open until the caller closed it, got %v", got)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/caphelper"
 )
 
 func setupFakeSudo(t *testing.T, logPath string) {
@@ -111,6 +112,52 @@ func TestRunRootCommandBatchHelperModeInvokesHelperPerCommand(t *testing.T) {
 	}
 }
 
+func TestRunRootCommandCapsModeInvokesHelperWithoutSudo(t *testing.T) {
+	tmpDir := t.TempDir()
+	sudoLogPath := filepath.Join(tmpDir, "sudo.log")
+	logPath := filepath.Join(tmpDir, "helper.log")
+	helperPath := filepath.Join(tmpDir, "cleanroom-cap-helper")
+	setupFakeSudo(t, sudoLogPath)
+
+	helperScript := "#!/bin/sh\nset -eu\nprintf '%s\\n' \"$*\" >> \"$HELPER_LOG_PATH\"\n"
+	if err := os.WriteFile(helperPath, []byte(helperScript), 0o755); err != nil {
+		t.Fatalf("write helper script: %v", err)
+	}
+	t.Setenv("HELPER_LOG_PATH", logPath)
+
+	cfg := backend.FirecrackerConfig{
+		PrivilegedMode:       privilegedModeCaps,
+		PrivilegedHelperPath: helperPath,
+	}
+
+	if err := runRootCommand(context.Background(), cfg, "ip", "link", "show"); err != nil {
+		t.Fatalf("runRootCommand: %v", err)
+	}
+
+	logBytes, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("read helper log: %v", err)
+	}
+	if got := strings.TrimSpace(string(logBytes)); got != "ip link show" {
+		t.Fatalf("unexpected helper invocation: got %q want %q", got, "ip link show")
+	}
+	if _, err := os.Stat(sudoLogPath); !os.IsNotExist(err) {
+		t.Fatalf("expected caps mode not to use sudo, stat sudo log: %v", err)
+	}
+}
+
+func TestResolvePrivilegedExecutionCapsDefaultsToCapHelper(t *testing.T) {
+	t.Parallel()
+
+	mode, helperPath := resolvePrivilegedExecution(backend.FirecrackerConfig{PrivilegedMode: "caps"})
+	if got, want := mode, privilegedModeCaps; got != want {
+		t.Fatalf("unexpected mode: got %q want %q", got, want)
+	}
+	if got, want := helperPath, caphelper.DefaultPath; got != want {
+		t.Fatalf("unexpected helper path: got %q want %q", got, want)
+	}
+}
+
 func TestResolvePrivilegedExecutionDefaultsToSudo(t *testing.T) {
 	t.Parallel()
 
@@ -118,7 +165,7 @@ func TestResolvePrivilegedExecutionDefaultsToSudo(t *testing.T) {
 	if got, want := mode, privilegedModeSudo; got != want {
 		t.Fatalf("unexpected mode: got %q want %q", got, want)
 	}
-	if got, want := helperPath, defaultPrivilegedHelperPath; got != want {
+	if got, want := helperPath, DefaultPrivilegedHelperPath; got != want {
 		t.Fatalf("unexpected helper path: got %q want %q", got, want)
 	}
 }
@@ -0,0 +1,76 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+)
+
+// SandboxResolutions returns the addresses each policy allow host resolved
+// to when the sandbox's egress rules were installed.
+func (a *Adapter) SandboxResolutions(sandboxID string) ([]backend.HostResolution, error) {
+	a.sandboxMu.Lock()
+	defer a.sandboxMu.Unlock()
+	instance, ok := a.sandboxes[strings.TrimSpace(sandboxID)]
+	if !ok {
+		return nil, fmt.Errorf("unknown sandbox %q", sandboxID)
+	}
+	return slices.Clone(instance.Resolutions), nil
+}
+
+// pinnedLookup answers from pinned for the hosts it lists and falls back to
+// lookup for the rest.
+func pinnedLookup(pinned []backend.HostResolution, lookup ipLookupFunc) ipLookupFunc {
+	if len(pinned) == 0 {
+		return lookup
+	}
+	byHost := make(map[string][]string, len(pinned))
+	for _, entry := range pinned {
+		host := strings.TrimSpace(strings.ToLower(entry.Host))
+		byHost[host] = append(byHost[host], entry.Addresses...)
+	}
+	return func(ctx context.Context, host string) ([]net.IP, error) {
+		addresses, ok := byHost[host]
+		if !ok {
+			return lookup(ctx, host)
+		}
+		if len(addresses) == 0 {
+			return nil, errors.New("pinned resolution has no addresses")
+		}
+		ips := make([]net.IP, 0, len(addresses))
+		for _, address := range addresses {
+			ip := net.ParseIP(strings.TrimSpace(address))
+			if ip == nil || ip.To4() == nil {
+				return nil, fmt.Errorf("pinned address %q is not an IPv4 address", address)
+			}
+			ips = append(ips, ip)
+		}
+		return ips, nil
+	}
+}
+
+// recordingLookup wraps lookup and appends the IPv4 answer for each host,
+// once per host, to resolutions.
+func recordingLookup(lookup ipLookupFunc, resolutions *[]backend.HostResolution) ipLookupFunc {
+	recorded := map[string]bool{}
+	return func(ctx context.Context, host string) ([]net.IP, error) {
+		ips, err := lookup(ctx, host)
+		if err != nil || recorded[host] {
+			return ips, err
+		}
+		recorded[host] = true
+		entry := backend.HostResolution{Host: host}
+		for _, ip := range ips {
+			if ipv4 := ip.To4(); ipv4 != nil && !slices.Contains(entry.Addresses, ipv4.String()) {
+				entry.Addresses = append(entry.Addresses, ipv4.String())
+			}
+		}
+		*resolutions = append(*resolutions, entry)
+		return ips, nil
+	}
+}
@@ -0,0 +1,130 @@
+package firecracker
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// validateRootCommandArgs checks a privileged command before it runs. Policy
+// compilation already validates hosts and ports, so this is defence in
+// depth: no argument may carry control characters, and the values iptables
+// takes from policy and network setup must be well-formed addresses, ports
+// and interface names rather than something iptables could read as options.
+func validateRootCommandArgs(args []string) error {
+	for _, arg := range args {
+		if strings.IndexFunc(arg, func(r rune) bool { return r < 0x20 || r == 0x7f }) >= 0 {
+			return fmt.Errorf("argument %q contains a control character", arg)
+		}
+	}
+	switch {
+	case len(args) > 0 && args[0] == "iptables":
+		return validateIptablesArgs(args[1:])
+	case len(args) > 4 && args[0] == "ip" && args[1] == "netns" && args[2] == "exec" && args[4] == "iptables":
+		return validateIptablesArgs(args[5:])
+	}
+	return nil
+}
+
+func validateIptablesArgs(args []string) error {
+	for i := 0; i < len(args); i++ {
+		var check func(string) error
+		switch args[i] {
+		case "-s", "-d":
+			check = validateIptablesAddress
+		case "--dport", "--sport":
+			check = validateIptablesPorts
+		case "-p":
+			check = validateIptablesProtocol
+		case "-i", "-o":
+			check = validateInterfaceName
+		case "--to-destination":
+			check = validateIptablesDestination
+		default:
+			continue
+		}
+		if i+1 == len(args) {
+			return fmt.Errorf("iptables %s is missing its value", args[i])
+		}
+		if err := check(args[i+1]); err != nil {
+			return fmt.Errorf("iptables %s: %w", args[i], err)
+		}
+		i++
+	}
+	return nil
+}
+
+// validateIptablesAddress accepts an IPv4 address or CIDR.
+func validateIptablesAddress(value string) error {
+	if prefix, err := netip.ParsePrefix(value); err == nil && prefix.Addr().Is4() {
+		return nil
+	}
+	if addr, err := netip.ParseAddr(value); err == nil && addr.Is4() {
+		return nil
+	}
+	return fmt.Errorf("%q is not an IPv4 address or CIDR", value)
+}
+
+// validateIptablesPorts accepts a port or an inclusive from:to range.
+func validateIptablesPorts(value string) error {
+	fromText, toText, isRange := strings.Cut(value, ":")
+	from, err := parseIptablesPort(fromText)
+	if err != nil {
+		return fmt.Errorf("%q: %w", value, err)
+	}
+	if !isRange {
+		return nil
+	}
+	to, err := parseIptablesPort(toText)
+	if err != nil {
+		return fmt.Errorf("%q: %w", value, err)
+	}
+	if from > to {
+		return fmt.Errorf("%q is not an ascending port range", value)
+	}
+	return nil
+}
+
+func parseIptablesPort(text string) (int, error) {
+	if text == "" || strings.Trim(text, "0123456789") != "" {
+		return 0, fmt.Errorf("%q is not a port number", text)
+	}
+	port, err := strconv.Atoi(text)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("port %s is out of range", text)
+	}
+	return port, nil
+}
+
+func validateIptablesProtocol(value string) error {
+	switch value {
+	case "tcp", "udp", "icmp":
+		return nil
+	}
+	return fmt.Errorf("unsupported protocol %q", value)
+}
+
+// validateInterfaceName accepts a Linux interface name, optionally ending in
+// iptables' "+" wildcard.
+func validateInterfaceName(value string) error {
+	name := strings.TrimSuffix(value, "+")
+	if name == "" || len(value) > 15 || strings.HasPrefix(name, "-") {
+		return fmt.Errorf("invalid interface name %q", value)
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return fmt.Errorf("invalid interface name %q", value)
+		}
+	}
+	return nil
+}
+
+// validateIptablesDestination accepts the DNAT target form address:port.
+func validateIptablesDestination(value string) error {
+	addrPort, err := netip.ParseAddrPort(value)
+	if err != nil || !addrPort.Addr().Is4() || addrPort.Port() == 0 {
+		return fmt.Errorf("%q is not an IPv4 address and port", value)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package firecracker
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+)
+
+func TestValidateRootCommandArgsAcceptsGeneratedRules(t *testing.T) {
+	t.Parallel()
+
+	rule := iptablesForwardRule{Protocol: "tcp", DestIP: "140.82.112.6", DestPort: 8000, DestPortEnd: 8100}
+	for _, args := range [][]string{
+		append([]string{"iptables", "-A", forwardChain, "-i", "cr-10-0-5"}, append(rule.matchArgs(), "-j", "ACCEPT")...),
+		{"iptables", "-A", inputChain, "!", "-i", "cr+", "-p", "tcp", "--dport", "9000", "-j", "DROP"},
+		{"iptables", "-t", "nat", "-A", postroutingChain, "-s", "10.0.5.0/24", "-j", "MASQUERADE"},
+		{"iptables", "-A", forwardChain, "-o", "cr-10-0-5", "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
+		{"ip", "netns", "exec", "cr-ns1", "iptables", "-t", "nat", "-A", "PREROUTING", "-i", "cr-10-0-5", "-p", "tcp", "-d", "10.0.5.1", "--dport", "8080", "-j", "DNAT", "--to-destination", "10.0.5.253:8080"},
+		{"sysctl", "-w", "net.ipv4.ip_forward=1"},
+	} {
+		if err := validateRootCommandArgs(args); err != nil {
+			t.Fatalf("validateRootCommandArgs(%q): %v", args, err)
+		}
+	}
+}
+
+func TestValidateRootCommandArgsRejectsHostileValues(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"iptables", "-A", forwardChain, "-p", "tcp", "-d", "1.1.1.1\n-A INPUT", "--dport", "443"}, want: "control character"},
+		{args: []string{"iptables", "-A", forwardChain, "-p", "tcp", "-d", "-j", "ACCEPT"}, want: "not an IPv4 address"},
+		{args: []string{"iptables", "-A", forwardChain, "-p", "tcp", "-d", "example.com", "--dport", "443"}, want: "not an IPv4 address"},
+		{args: []string{"iptables", "-A", forwardChain, "-p", "tcp", "-d", "::1", "--dport", "443"}, want: "not an IPv4 address"},
+		{args: []string{"iptables", "-A", forwardChain, "-p", "tcp", "-d", "1.1.1.1", "--dport", "0"}, want: "out of range"},
+		{args: []string{"iptables", "-A", forwardChain, "-p", "tcp", "-d", "1.1.1.1", "--dport", "65536"}, want: "out of range"},
+		{args: []string{"iptables", "-A", forwardChain, "-p", "tcp", "-d", "1.1.1.1", "--dport", "9000:8000"}, want: "ascending"},
+		{args: []string{"iptables", "-A", forwardChain, "-p", "tcp", "-d", "1.1.1.1", "--dport", "+443"}, want: "not a port number"},
+		{args: []string{"iptables", "-A", forwardChain, "-p", "all", "-d", "1.1.1.1"}, want: "unsupported protocol"},
+		{args: []string{"iptables", "-A", forwardChain, "-i", "--goto", "-j", "ACCEPT"}, want: "invalid interface name"},
+		{args: []string{"iptables", "-A", forwardChain, "-i", "averyveryverylongtap", "-j", "ACCEPT"}, want: "invalid interface name"},
+		{args: []string{"iptables", "-A", forwardChain, "-d"}, want: "missing its value"},
+		{args: []string{"ip", "netns", "exec", "cr-ns1", "iptables", "-j", "DNAT", "--to-destination", "10.0.0.1"}, want: "not an IPv4 address and port"},
+	} {
+		err := validateRootCommandArgs(tc.args)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("validateRootCommandArgs(%q): expected %q error, got %v", tc.args, tc.want, err)
+		}
+	}
+}
+
+func TestRunRootCommandRefusesInvalidArgsBeforeRunning(t *testing.T) {
+	t.Parallel()
+
+	cfg := backend.FirecrackerConfig{PrivilegedMode: privilegedModeCaps, PrivilegedHelperPath: "/nonexistent/cleanroom-cap-helper"}
+	err := runRootCommand(context.Background(), cfg, "iptables", "-A", forwardChain, "-d", "1.1.1.1 -j ACCEPT")
+	if err == nil || !strings.Contains(err.Error(), "refusing privileged command iptables") {
+		t.Fatalf("expected the command to be refused, got %v", err)
+	}
+}
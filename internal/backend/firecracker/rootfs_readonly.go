@@ -0,0 +1,62 @@
+package firecracker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/policy"
+)
+
+// applyReadOnlyRootFS attaches the root drive read-only when the policy asks
+// for it. Each disk-backed writable path gets a scratch disk in runDir, and
+// the guest init is told where to mount tmpfs and scratch disks through
+// cleanroom_writable entries of the form "<path>:tmpfs:<MiB>" or
+// "<path>:<device>". The returned cleanup removes the scratch disks.
+func applyReadOnlyRootFS(ctx context.Context, fcCfg *firecrackerConfig, compiled *policy.CompiledPolicy, runDir string, createDisk func(ctx context.Context, path string, sizeMiB int64) error) (func(), error) {
+	noop := func() {}
+	if compiled == nil || compiled.ReadOnlyRootFS == nil {
+		return noop, nil
+	}
+	for i := range fcCfg.Drives {
+		if fcCfg.Drives[i].IsRootDevice {
+			fcCfg.Drives[i].IsReadOnly = true
+		}
+	}
+
+	var scratch []string
+	cleanup := func() {
+		for _, path := range scratch {
+			_ = os.Remove(path)
+		}
+	}
+	specs := make([]string, 0, len(compiled.ReadOnlyRootFS.Writable))
+	for _, entry := range compiled.ReadOnlyRootFS.Writable {
+		if !entry.Disk {
+			specs = append(specs, entry.Path+":tmpfs:"+strconv.FormatInt(entry.SizeMiB, 10))
+			continue
+		}
+		// The root drive is vda; further drives follow in config order.
+		device := fmt.Sprintf("vd%c", 'a'+len(fcCfg.Drives))
+		path := filepath.Join(runDir, fmt.Sprintf("scratch-%d.ext4", len(scratch)))
+		if err := createDisk(ctx, path, entry.SizeMiB); err != nil {
+			cleanup()
+			return noop, fmt.Errorf("create scratch disk for %s: %w", entry.Path, err)
+		}
+		scratch = append(scratch, path)
+		fcCfg.Drives = append(fcCfg.Drives, drive{
+			DriveID:    fmt.Sprintf("scratch%d", len(scratch)-1),
+			PathOnHost: path,
+		})
+		specs = append(specs, entry.Path+":"+device)
+	}
+
+	fcCfg.BootSource.BootArgs += " cleanroom_rootfs_read_only=1"
+	if len(specs) > 0 {
+		fcCfg.BootSource.BootArgs += " cleanroom_writable=" + strings.Join(specs, ",")
+	}
+	return cleanup, nil
+}
@@ -0,0 +1,68 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/policy"
+)
+
+func TestApplyReadOnlyRootFS(t *testing.T) {
+	t.Parallel()
+
+	runDir := t.TempDir()
+	createDisk := func(_ context.Context, path string, _ int64) error {
+		return os.WriteFile(path, nil, 0o600)
+	}
+	newConfig := func() firecrackerConfig {
+		return firecrackerConfig{
+			BootSource: bootSource{BootArgs: "console=ttyS0"},
+			Drives:     []drive{{DriveID: "rootfs", PathOnHost: "/rootfs.ext4", IsRootDevice: true}},
+		}
+	}
+
+	cfg := newConfig()
+	if _, err := applyReadOnlyRootFS(context.Background(), &cfg, &policy.CompiledPolicy{}, runDir, createDisk); err != nil {
+		t.Fatalf("applyReadOnlyRootFS returned error: %v", err)
+	}
+	if cfg.Drives[0].IsReadOnly || cfg.BootSource.BootArgs != "console=ttyS0" {
+		t.Fatalf("expected writable rootfs policy to leave config alone, got %+v", cfg)
+	}
+
+	compiled := &policy.CompiledPolicy{ReadOnlyRootFS: &policy.ReadOnlyRootFS{Writable: []policy.WritablePath{
+		{Path: "/var/cache"},
+		{Path: "/workspace", Disk: true, SizeMiB: 1024},
+		{Path: "/var/tmp", SizeMiB: 64},
+	}}}
+	cfg = newConfig()
+	cleanup, err := applyReadOnlyRootFS(context.Background(), &cfg, compiled, runDir, createDisk)
+	if err != nil {
+		t.Fatalf("applyReadOnlyRootFS returned error: %v", err)
+	}
+	if !cfg.Drives[0].IsReadOnly {
+		t.Fatal("expected root drive to be read-only")
+	}
+	if len(cfg.Drives) != 2 || cfg.Drives[1].IsReadOnly || cfg.Drives[1].PathOnHost != filepath.Join(runDir, "scratch-0.ext4") {
+		t.Fatalf("unexpected drives: %+v", cfg.Drives)
+	}
+	want := "console=ttyS0 cleanroom_rootfs_read_only=1 cleanroom_writable=/var/cache:tmpfs:0,/workspace:vdb,/var/tmp:tmpfs:64"
+	if cfg.BootSource.BootArgs != want {
+		t.Fatalf("unexpected boot args:\n got %q\nwant %q", cfg.BootSource.BootArgs, want)
+	}
+	cleanup()
+	if _, err := os.Stat(cfg.Drives[1].PathOnHost); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected cleanup to remove the scratch disk, got %v", err)
+	}
+
+	cfg = newConfig()
+	_, err = applyReadOnlyRootFS(context.Background(), &cfg, compiled, runDir, func(context.Context, string, int64) error {
+		return errors.New("mkfs.ext4 not found")
+	})
+	if err == nil || !strings.Contains(err.Error(), "/workspace") {
+		t.Fatalf("expected scratch disk error naming the path, got %v", err)
+	}
+}
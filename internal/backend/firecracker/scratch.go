@@ -0,0 +1,155 @@
+package firecracker
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/paths"
+)
+
+// scratchPool keeps per-run rootfs files after their runs so later runs can
+// overwrite them in place instead of allocating new ones. The files live in
+// the state directory, next to the run directories, so moving them in and
+// out is a rename.
+type scratchPool struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// scratchFiles returns the adapter's scratch pool, or nil when cfg keeps no
+// scratch files.
+func (a *Adapter) scratchFiles(cfg backend.FirecrackerConfig) *scratchPool {
+	if cfg.ScratchFiles <= 0 {
+		return nil
+	}
+	a.scratchOnce.Do(func() {
+		if a.scratch != nil {
+			return
+		}
+		base, err := paths.StateBaseDir()
+		if err != nil {
+			return
+		}
+		a.scratch = &scratchPool{dir: filepath.Join(base, "firecracker", "scratch")}
+	})
+	return a.scratch
+}
+
+// prepareRootFS writes a copy of src to dst for one run, reusing a pooled
+// file when there is one.
+func (p *scratchPool) prepareRootFS(src, dst string) error {
+	if p != nil && p.take(dst) {
+		if err := resetScratchFile(src, dst); err == nil {
+			return nil
+		}
+		_ = os.Remove(dst)
+	}
+	return copyFile(src, dst)
+}
+
+// releaseRootFS returns a run's rootfs at path to the pool, or removes it
+// when the pool is full or disabled. A file is only pooled once vmExited is
+// closed, or when it is nil because no VM was started: a VM that outlived
+// its run could otherwise write into the next run's disk.
+func (p *scratchPool) releaseRootFS(path string, keep int64, vmExited <-chan struct{}) {
+	if p != nil && vmStopped(vmExited) && p.give(path, keep) {
+		return
+	}
+	_ = os.Remove(path)
+}
+
+func vmStopped(exited <-chan struct{}) bool {
+	if exited == nil {
+		return true
+	}
+	select {
+	case <-exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// take moves a pooled file to dst, reporting whether there was one.
+func (p *scratchPool) take(dst string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, name := range p.files() {
+		if os.Rename(filepath.Join(p.dir, name), dst) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// give moves path into the pool unless it already holds keep files.
+func (p *scratchPool) give(path string, keep int64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if int64(len(p.files())) >= keep {
+		return false
+	}
+	if err := os.MkdirAll(p.dir, 0o700); err != nil {
+		return false
+	}
+	name := fmt.Sprintf("%d.ext4", time.Now().UnixNano())
+	return os.Rename(path, filepath.Join(p.dir, name)) == nil
+}
+
+// files lists the pooled files, oldest first.
+func (p *scratchPool) files() []string {
+	entries, err := os.ReadDir(p.dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".ext4") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resetScratchFile overwrites dst, a rootfs left by an earlier run, with
+// src. Every byte up to src's size is rewritten in place and the file is
+// then cut to that size, so nothing the earlier run wrote survives.
+func resetScratchFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := out.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+	n, err := io.Copy(out, in)
+	if err != nil {
+		return err
+	}
+	if n != info.Size() {
+		return fmt.Errorf("copied %d of %d bytes", n, info.Size())
+	}
+	if err := out.Truncate(n); err != nil {
+		return err
+	}
+	return out.Sync()
+}
@@ -0,0 +1,92 @@
+package firecracker
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScratchPoolReusesRootFSFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	pool := &scratchPool{dir: filepath.Join(dir, "scratch")}
+	base := filepath.Join(dir, "base.ext4")
+	if err := os.WriteFile(base, bytes.Repeat([]byte("b"), 4096), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	first := filepath.Join(dir, "run-1", "rootfs.ext4")
+	if err := os.MkdirAll(filepath.Dir(first), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.prepareRootFS(base, first); err != nil {
+		t.Fatalf("prepareRootFS: %v", err)
+	}
+	// The run writes past the image and over its start.
+	if err := os.WriteFile(first, bytes.Repeat([]byte("x"), 10000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	exited := make(chan struct{})
+	close(exited)
+	pool.releaseRootFS(first, 1, exited)
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Fatalf("expected the rootfs to move into the pool, stat err %v", err)
+	}
+	if got := pool.files(); len(got) != 1 {
+		t.Fatalf("expected one pooled file, got %v", got)
+	}
+
+	second := filepath.Join(dir, "run-1", "rootfs-2.ext4")
+	if err := pool.prepareRootFS(base, second); err != nil {
+		t.Fatalf("prepareRootFS: %v", err)
+	}
+	if got := pool.files(); len(got) != 0 {
+		t.Fatalf("expected the pooled file to be taken, got %v", got)
+	}
+	got, err := os.ReadFile(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := os.ReadFile(base); !bytes.Equal(got, want) {
+		t.Fatalf("reused rootfs holds %d bytes that differ from the image", len(got))
+	}
+}
+
+func TestScratchPoolReleaseLimits(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	pool := &scratchPool{dir: filepath.Join(dir, "scratch")}
+	write := func(name string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("rootfs"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	pool.releaseRootFS(write("a.ext4"), 1, nil)
+	full := write("b.ext4")
+	pool.releaseRootFS(full, 1, nil)
+	if _, err := os.Stat(full); !os.IsNotExist(err) {
+		t.Fatalf("expected a rootfs past the limit to be removed, stat err %v", err)
+	}
+
+	running := write("c.ext4")
+	pool.releaseRootFS(running, 5, make(chan struct{}))
+	if _, err := os.Stat(running); !os.IsNotExist(err) {
+		t.Fatalf("expected a rootfs whose VM is running to be removed, stat err %v", err)
+	}
+	if got := pool.files(); len(got) != 1 {
+		t.Fatalf("expected one pooled file, got %v", got)
+	}
+
+	var disabled *scratchPool
+	off := write("d.ext4")
+	disabled.releaseRootFS(off, 5, nil)
+	if _, err := os.Stat(off); !os.IsNotExist(err) {
+		t.Fatalf("expected a disabled pool to remove the rootfs, stat err %v", err)
+	}
+}
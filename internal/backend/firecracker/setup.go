@@ -0,0 +1,138 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/imagemgr"
+	"github.com/buildkite/cleanroom/internal/policy"
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+// setupImageCache is implemented by image managers that can keep the
+// filesystems sandbox.setup produces.
+type setupImageCache interface {
+	LookupSetupImage(ctx context.Context, digest string) (imagemgr.Record, bool, error)
+	StoreSetupImage(ctx context.Context, req imagemgr.SetupImageRequest) (imagemgr.Record, error)
+}
+
+// setupErrorTail bounds how much of a failed setup command's stderr is
+// reported.
+const setupErrorTail = 2048
+
+// resolveSandboxImage ensures the policy's image. When the policy declares
+// setup commands it returns the image those commands produce instead,
+// running them once per namespace and policy in a throwaway VM on the first
+// miss.
+func (a *Adapter) resolveSandboxImage(ctx context.Context, namespace string, compiled *policy.CompiledPolicy, cfg backend.FirecrackerConfig) (imageArtifact, error) {
+	image, err := a.ensureImageArtifact(ctx, compiled.ImageRef)
+	if err != nil || len(compiled.Setup) == 0 {
+		return image, err
+	}
+
+	manager, err := a.getImageManager()
+	if err != nil {
+		return imageArtifact{}, err
+	}
+	cache, ok := manager.(setupImageCache)
+	if !ok {
+		return imageArtifact{}, errors.New("image manager cannot cache sandbox.setup images")
+	}
+	policyHash, err := compiled.SetupEnvironmentHash()
+	if err != nil {
+		return imageArtifact{}, fmt.Errorf("hash sandbox.setup policy: %w", err)
+	}
+	digest := imagemgr.SetupDigest(image.Digest, policyHash, namespace, compiled.Setup)
+	if record, found, err := cache.LookupSetupImage(ctx, digest); err != nil || found {
+		return setupImageArtifact(record, true), err
+	}
+
+	// Concurrent sandboxes with the same setup wait for one build rather
+	// than each running it; builds of different images run in parallel.
+	built, err, _ := a.setupBuilds.Do(digest, func() (any, error) {
+		if record, found, err := cache.LookupSetupImage(ctx, digest); err != nil || found {
+			return setupImageArtifact(record, true), err
+		}
+		a.logRunNotice(ctx, "", fmt.Sprintf("running %d sandbox.setup commands on %s", len(compiled.Setup), image.Ref))
+		record, err := a.buildSetupImage(ctx, namespace, compiled, cfg, image, digest, cache)
+		if err != nil {
+			return nil, fmt.Errorf("sandbox.setup: %w", err)
+		}
+		return setupImageArtifact(record, false), nil
+	})
+	if err != nil {
+		return imageArtifact{}, err
+	}
+	return built.(imageArtifact), nil
+}
+
+func setupImageArtifact(record imagemgr.Record, cacheHit bool) imageArtifact {
+	return imageArtifact{
+		Ref:        record.Ref,
+		Digest:     record.Digest,
+		RootFSPath: record.RootFSPath,
+		CacheHit:   cacheHit,
+	}
+}
+
+// buildSetupImage boots the base image under the sandbox's own network
+// policy, runs each setup command, and caches the resulting rootfs.
+func (a *Adapter) buildSetupImage(ctx context.Context, namespace string, compiled *policy.CompiledPolicy, cfg backend.FirecrackerConfig, base imageArtifact, digest string, cache setupImageCache) (imagemgr.Record, error) {
+	setupPolicy := *compiled
+	setupPolicy.Setup = nil
+	// Setup writes to the image itself, so the root drive must be writable.
+	setupPolicy.ReadOnlyRootFS = nil
+
+	launch := a.launchSandboxVMFn
+	if launch == nil {
+		launch = a.launchSandboxVM
+	}
+	instance, err := launch(ctx, "setup-"+strings.TrimPrefix(digest, "sha256:")[:12], namespace, &setupPolicy, cfg, nil)
+	if err != nil {
+		return imagemgr.Record{}, err
+	}
+	defer func() {
+		if a.GatewayRegistry != nil && instance.SourceIP != "" {
+			a.GatewayRegistry.Release(instance.SourceIP)
+		}
+		instance.shutdown()
+	}()
+
+	for i, command := range compiled.Setup {
+		// Only stderr is kept for the error message; package managers can
+		// print a lot on stdout.
+		resp, _, err := a.executeInSandbox(ctx, instance, 0, vsockexec.ExecRequest{
+			Command:      []string{"sh", "-c", command},
+			StreamStdout: true,
+		}, backend.OutputStream{})
+		if err != nil {
+			return imagemgr.Record{}, fmt.Errorf("command %d (%q): %w", i+1, command, err)
+		}
+		if resp.ExitCode != 0 {
+			msg := strings.TrimSpace(resp.Stderr)
+			if len(msg) > setupErrorTail {
+				msg = "..." + msg[len(msg)-setupErrorTail:]
+			}
+			if msg == "" {
+				msg = strings.TrimSpace(resp.Error)
+			}
+			return imagemgr.Record{}, fmt.Errorf("command %d (%q) exited %d: %s", i+1, command, resp.ExitCode, msg)
+		}
+	}
+
+	var record imagemgr.Record
+	err = a.withRootFSExport(ctx, instance, func(rootfs io.Reader) error {
+		var err error
+		record, err = cache.StoreSetupImage(ctx, imagemgr.SetupImageRequest{
+			BaseRef: base.Ref,
+			Digest:  digest,
+			RootFS:  rootfs,
+		})
+		return err
+	})
+	return record, err
+}
@@ -0,0 +1,135 @@
+package firecracker
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/imagemgr"
+	"github.com/buildkite/cleanroom/internal/policy"
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+const setupTestBaseRef = "ghcr.io/acme/base@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+type fakeSetupCache struct {
+	records map[string]imagemgr.Record
+	stored  string
+}
+
+func (f *fakeSetupCache) Ensure(_ context.Context, ref string) (imagemgr.EnsureResult, error) {
+	return imagemgr.EnsureResult{Record: imagemgr.Record{
+		Ref:        ref,
+		Digest:     "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		RootFSPath: "/cache/base.ext4",
+	}}, nil
+}
+
+func (f *fakeSetupCache) LookupSetupImage(_ context.Context, digest string) (imagemgr.Record, bool, error) {
+	record, ok := f.records[digest]
+	return record, ok, nil
+}
+
+func (f *fakeSetupCache) StoreSetupImage(_ context.Context, req imagemgr.SetupImageRequest) (imagemgr.Record, error) {
+	data, err := io.ReadAll(req.RootFS)
+	if err != nil {
+		return imagemgr.Record{}, err
+	}
+	f.stored = string(data)
+	record := imagemgr.Record{Ref: "setup@" + req.Digest, Digest: req.Digest, RootFSPath: "/cache/setup.ext4"}
+	f.records[req.Digest] = record
+	return record, nil
+}
+
+func TestResolveSandboxImageBuildsSetupImageOnce(t *testing.T) {
+	t.Parallel()
+
+	cache := &fakeSetupCache{records: map[string]imagemgr.Record{}}
+	var launches int
+	var launchedPolicy *policy.CompiledPolicy
+	var commands []string
+	adapter := &Adapter{
+		newImageManager: func() (imageEnsurer, error) { return cache, nil },
+		launchSandboxVMFn: func(_ context.Context, sandboxID, _ string, compiled *policy.CompiledPolicy, _ backend.FirecrackerConfig, _ []backend.HostResolution) (*sandboxInstance, error) {
+			launches++
+			launchedPolicy = compiled
+			return &sandboxInstance{SandboxID: sandboxID, GuestPort: 10700}, nil
+		},
+	}
+	adapter.runGuestCommandFn = func(_ context.Context, _ context.Context, _ <-chan struct{}, _ func() error, _ string, _ uint32, req vsockexec.ExecRequest, stream backend.OutputStream) (vsockexec.ExecResponse, guestExecTiming, error) {
+		commands = append(commands, strings.Join(req.Command, " "))
+		if req.Command[len(req.Command)-1] == "export-rootfs" {
+			stream.OnStdout([]byte("rootfs-tar"))
+		}
+		return vsockexec.ExecResponse{}, guestExecTiming{}, nil
+	}
+
+	compiled := &policy.CompiledPolicy{
+		ImageRef:       setupTestBaseRef,
+		Setup:          []string{"apt-get update", "apt-get install -y jq"},
+		ReadOnlyRootFS: &policy.ReadOnlyRootFS{},
+	}
+	image, err := adapter.resolveSandboxImage(context.Background(), "default", compiled, backend.FirecrackerConfig{})
+	if err != nil {
+		t.Fatalf("resolveSandboxImage returned error: %v", err)
+	}
+	policyHash, err := compiled.SetupEnvironmentHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDigest := imagemgr.SetupDigest("sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", policyHash, "default", compiled.Setup)
+	if image.Digest != wantDigest || image.RootFSPath != "/cache/setup.ext4" || image.CacheHit {
+		t.Fatalf("unexpected setup image: %+v", image)
+	}
+	if got, want := strings.Join(commands, "; "), "sh -c apt-get update; sh -c apt-get install -y jq; /usr/local/bin/cleanroom-guest-agent export-rootfs"; got != want {
+		t.Fatalf("unexpected guest commands:\n got %q\nwant %q", got, want)
+	}
+	if cache.stored != "rootfs-tar" {
+		t.Fatalf("unexpected stored rootfs %q", cache.stored)
+	}
+	if launchedPolicy.Setup != nil || launchedPolicy.ReadOnlyRootFS != nil {
+		t.Fatalf("expected the setup VM to boot a writable rootfs without setup, got %+v", launchedPolicy)
+	}
+
+	image, err = adapter.resolveSandboxImage(context.Background(), "default", compiled, backend.FirecrackerConfig{})
+	if err != nil {
+		t.Fatalf("resolveSandboxImage (cached) returned error: %v", err)
+	}
+	if !image.CacheHit || launches != 1 {
+		t.Fatalf("expected the second resolve to reuse the setup image, got %+v after %d launches", image, launches)
+	}
+
+	image, err = adapter.resolveSandboxImage(context.Background(), "team-a", compiled, backend.FirecrackerConfig{})
+	if err != nil {
+		t.Fatalf("resolveSandboxImage (other namespace) returned error: %v", err)
+	}
+	if image.CacheHit || launches != 2 {
+		t.Fatalf("expected another namespace to build its own setup image, got %+v after %d launches", image, launches)
+	}
+}
+
+func TestResolveSandboxImageReportsFailedSetupCommand(t *testing.T) {
+	t.Parallel()
+
+	cache := &fakeSetupCache{records: map[string]imagemgr.Record{}}
+	adapter := &Adapter{
+		newImageManager: func() (imageEnsurer, error) { return cache, nil },
+		launchSandboxVMFn: func(_ context.Context, sandboxID, _ string, _ *policy.CompiledPolicy, _ backend.FirecrackerConfig, _ []backend.HostResolution) (*sandboxInstance, error) {
+			return &sandboxInstance{SandboxID: sandboxID, GuestPort: 10700}, nil
+		},
+	}
+	adapter.runGuestCommandFn = func(_ context.Context, _ context.Context, _ <-chan struct{}, _ func() error, _ string, _ uint32, _ vsockexec.ExecRequest, _ backend.OutputStream) (vsockexec.ExecResponse, guestExecTiming, error) {
+		return vsockexec.ExecResponse{ExitCode: 100, Stderr: "E: Unable to locate package nope\n"}, guestExecTiming{}, nil
+	}
+
+	compiled := &policy.CompiledPolicy{ImageRef: setupTestBaseRef, Setup: []string{"apt-get install -y nope"}}
+	_, err := adapter.resolveSandboxImage(context.Background(), "default", compiled, backend.FirecrackerConfig{})
+	if err == nil || !strings.Contains(err.Error(), "exited 100") || !strings.Contains(err.Error(), "Unable to locate package") {
+		t.Fatalf("expected failed setup command to be reported, got %v", err)
+	}
+	if len(cache.records) != 0 {
+		t.Fatal("expected a failed setup not to be cached")
+	}
+}
@@ -0,0 +1,67 @@
+//go:build linux
+
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// errWaitStopped is returned by waitForPath when its stop channel closes.
+var errWaitStopped = errors.New("stopped waiting")
+
+// waitForPath returns once path exists. It watches the parent directory
+// with inotify, so it wakes as soon as Firecracker creates a socket rather
+// than on the next tick of a poll. If the directory cannot be watched it
+// returns at once and leaves the caller's dial loop to retry.
+func waitForPath(ctx context.Context, stop <-chan struct{}, path string) error {
+	if _, err := os.Lstat(path); err == nil {
+		return nil
+	}
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil
+	}
+	// A non-blocking fd joins the runtime poller, so Close interrupts Read.
+	events := os.NewFile(uintptr(fd), "inotify")
+	defer events.Close()
+	if _, err := unix.InotifyAddWatch(fd, filepath.Dir(path), unix.IN_CREATE|unix.IN_MOVED_TO); err != nil {
+		return nil
+	}
+
+	// Any entry created in the directory wakes the loop, which then checks
+	// for path itself.
+	created := make(chan struct{}, 1)
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		buf := make([]byte, 4096)
+		for {
+			if _, err := events.Read(buf); err != nil {
+				return
+			}
+			select {
+			case created <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	for {
+		if _, err := os.Lstat(path); err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-stop:
+			return errWaitStopped
+		case <-readDone:
+			return nil
+		case <-created:
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWaitForPathWakesOnCreate(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "fc.vsock")
+	done := make(chan error, 1)
+	go func() { done <- waitForPath(context.Background(), nil, path) }()
+
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("waitForPath returned before the socket existed: %v", err)
+	default:
+	}
+	if err := os.WriteFile(filepath.Join(filepath.Dir(path), "other"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("waitForPath returned error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waitForPath did not notice the socket")
+	}
+
+	// An existing path returns at once.
+	if err := waitForPath(context.Background(), nil, path); err != nil {
+		t.Fatalf("waitForPath on existing path returned error: %v", err)
+	}
+}
+
+func TestWaitForPathStops(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "fc.vsock")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := waitForPath(ctx, nil, path); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	stop := make(chan struct{})
+	close(stop)
+	if err := waitForPath(context.Background(), stop, path); !errors.Is(err, errWaitStopped) {
+		t.Fatalf("expected errWaitStopped, got %v", err)
+	}
+}
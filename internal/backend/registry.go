@@ -0,0 +1,68 @@
+package backend
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Factory constructs an adapter. It is called once per process, when the CLI
+// assembles its backend set.
+type Factory func() Adapter
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a backend available under name in addition to the built-in
+// ones. Call it from main before handing control to the CLI. Registering the
+// same name twice, or an empty name or nil factory, panics.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if name == "" {
+		panic("backend: Register called with empty name")
+	}
+	if factory == nil {
+		panic(fmt.Sprintf("backend: Register called with nil factory for %q", name))
+	}
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("backend: Register called twice for %q", name))
+	}
+	registry[name] = factory
+}
+
+// Registered returns the names of registered backends in sorted order.
+func Registered() []string {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithRegistered returns builtins plus a fresh adapter from every registered
+// factory. A registered name that collides with a built-in is an error.
+func WithRegistered(builtins map[string]Adapter) (map[string]Adapter, error) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	out := make(map[string]Adapter, len(builtins)+len(registry))
+	for name, adapter := range builtins {
+		out[name] = adapter
+	}
+	for name, factory := range registry {
+		if _, exists := out[name]; exists {
+			return nil, fmt.Errorf("registered backend %q conflicts with a built-in backend", name)
+		}
+		adapter := factory()
+		if adapter == nil {
+			return nil, fmt.Errorf("registered backend %q returned a nil adapter", name)
+		}
+		out[name] = adapter
+	}
+	return out, nil
+}
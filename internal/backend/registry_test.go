@@ -0,0 +1,66 @@
+package backend
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type registryTestAdapter struct{ name string }
+
+func (a registryTestAdapter) Name() string { return a.name }
+
+func (a registryTestAdapter) Run(context.Context, RunRequest) (*RunResult, error) {
+	return &RunResult{}, nil
+}
+
+func resetRegistry(t *testing.T) {
+	t.Helper()
+	registryMu.Lock()
+	saved := registry
+	registry = map[string]Factory{}
+	registryMu.Unlock()
+	t.Cleanup(func() {
+		registryMu.Lock()
+		registry = saved
+		registryMu.Unlock()
+	})
+}
+
+func TestWithRegisteredMergesBuiltins(t *testing.T) {
+	resetRegistry(t)
+	Register("gvisor", func() Adapter { return registryTestAdapter{name: "gvisor"} })
+
+	adapters, err := WithRegistered(map[string]Adapter{"firecracker": registryTestAdapter{name: "firecracker"}})
+	if err != nil {
+		t.Fatalf("WithRegistered returned error: %v", err)
+	}
+	if len(adapters) != 2 || adapters["gvisor"].Name() != "gvisor" {
+		t.Fatalf("unexpected adapters: %v", adapters)
+	}
+	if got := strings.Join(Registered(), ","); got != "gvisor" {
+		t.Fatalf("unexpected registered names: got %q want %q", got, "gvisor")
+	}
+}
+
+func TestWithRegisteredRejectsBuiltinCollision(t *testing.T) {
+	resetRegistry(t)
+	Register("firecracker", func() Adapter { return registryTestAdapter{name: "firecracker"} })
+
+	_, err := WithRegistered(map[string]Adapter{"firecracker": registryTestAdapter{name: "firecracker"}})
+	if err == nil || !strings.Contains(err.Error(), "conflicts") {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+}
+
+func TestRegisterPanicsOnDuplicate(t *testing.T) {
+	resetRegistry(t)
+	Register("gvisor", func() Adapter { return registryTestAdapter{name: "gvisor"} })
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected duplicate Register to panic")
+		}
+	}()
+	Register("gvisor", func() Adapter { return registryTestAdapter{name: "gvisor"} })
+}
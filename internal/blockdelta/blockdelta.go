@@ -0,0 +1,421 @@
+// Package blockdelta transfers a file as an rsync-style delta against a
+// copy the receiver already has. The receiver signs its copy block by
+// block, the sender finds those blocks anywhere in the new file with a
+// rolling checksum, and sends only the bytes between them.
+package blockdelta
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// MinBlockSize is the block size for bases up to MaxBlocks of them.
+	MinBlockSize = 64 * 1024
+	// MaxBlocks bounds the signatures of one base. Larger bases use larger
+	// blocks.
+	MaxBlocks = 32 * 1024
+	// MaxBlockSize bounds the block size a sender accepts.
+	MaxBlockSize = 64 * 1024 * 1024
+	// SignatureSize is the encoded size of one block's signature: its
+	// big-endian rolling checksum, then the first 16 bytes of its SHA-256.
+	SignatureSize = 4 + strongSize
+
+	strongSize = 16
+	// maxLiteral bounds the literal bytes the sender holds before writing
+	// them out.
+	maxLiteral = 1024 * 1024
+)
+
+// Delta operations.
+const (
+	opCopy    = 1 // uvarint first block, uvarint block count
+	opLiteral = 2 // uvarint length, bytes
+	opEnd     = 3 // uvarint file size, 32-byte SHA-256
+)
+
+// ErrMismatch is returned when a patched file does not match the sender's.
+var ErrMismatch = errors.New("patched file does not match")
+
+// BlockSize returns the block size to sign a base of size bytes with.
+func BlockSize(size int64) int {
+	block := int64(MinBlockSize)
+	for size/block > MaxBlocks {
+		block *= 2
+	}
+	return int(block)
+}
+
+// Sign returns the signatures of r's full blocks. A trailing partial block
+// is not signed; the sender sends those bytes as they are.
+func Sign(r io.Reader, blockSize int) ([]byte, error) {
+	if err := checkBlockSize(blockSize); err != nil {
+		return nil, err
+	}
+	var sigs []byte
+	block := make([]byte, blockSize)
+	for {
+		if _, err := io.ReadFull(r, block); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return sigs, nil
+			}
+			return nil, err
+		}
+		if len(sigs)/SignatureSize >= MaxBlocks {
+			return nil, fmt.Errorf("base has more than %d blocks of %d bytes", MaxBlocks, blockSize)
+		}
+		sigs = binary.BigEndian.AppendUint32(sigs, newRolling(block).sum())
+		strong := sha256.Sum256(block)
+		sigs = append(sigs, strong[:strongSize]...)
+	}
+}
+
+func checkBlockSize(blockSize int) error {
+	if blockSize < 1 || blockSize > MaxBlockSize {
+		return fmt.Errorf("invalid block size %d: must be between 1 and %d", blockSize, MaxBlockSize)
+	}
+	return nil
+}
+
+// Diff writes the delta that turns the base signed as sigs into what r
+// holds. It fails once r has yielded more than maxBytes, when maxBytes is
+// positive.
+func Diff(r io.Reader, blockSize int, sigs []byte, maxBytes int64, w io.Writer) error {
+	if err := checkBlockSize(blockSize); err != nil {
+		return err
+	}
+	if len(sigs)%SignatureSize != 0 {
+		return fmt.Errorf("invalid signatures: %d bytes is not a multiple of %d", len(sigs), SignatureSize)
+	}
+	blocks := map[uint32][]int{}
+	for i := 0; i < len(sigs)/SignatureSize; i++ {
+		weak := binary.BigEndian.Uint32(sigs[i*SignatureSize:])
+		blocks[weak] = append(blocks[weak], i)
+	}
+	match := func(weak uint32, block []byte) (int, bool) {
+		candidates := blocks[weak]
+		if len(candidates) == 0 {
+			return 0, false
+		}
+		strong := sha256.Sum256(block)
+		for _, i := range candidates {
+			if bytes.Equal(sigs[i*SignatureSize+4:(i+1)*SignatureSize], strong[:strongSize]) {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+
+	hash := sha256.New()
+	src := io.TeeReader(r, hash)
+	var total int64
+	enc := &encoder{w: bufio.NewWriter(w)}
+
+	// data holds the pending literal, data[lit:p], and the window after it,
+	// data[p:p+blockSize].
+	data := make([]byte, 0, 2*blockSize+maxLiteral)
+	p, lit := 0, 0
+	var roll rolling
+	rolled := false // roll holds the checksum of data[p:p+blockSize]
+	eof := false
+	for {
+		if len(data)-p < blockSize && !eof {
+			if err := enc.literal(data[lit:p]); err != nil {
+				return err
+			}
+			n := copy(data, data[p:])
+			data, p, lit = data[:n], 0, 0
+			for len(data) < cap(data) && !eof {
+				m, err := src.Read(data[len(data):cap(data)])
+				data = data[:len(data)+m]
+				total += int64(m)
+				if maxBytes > 0 && total > maxBytes {
+					return fmt.Errorf("file exceeds max_bytes=%d", maxBytes)
+				}
+				if errors.Is(err, io.EOF) {
+					eof = true
+				} else if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if len(data)-p < blockSize {
+			break
+		}
+		window := data[p : p+blockSize]
+		if !rolled {
+			roll = newRolling(window)
+			rolled = true
+		}
+		if i, ok := match(roll.sum(), window); ok {
+			if err := enc.literal(data[lit:p]); err != nil {
+				return err
+			}
+			if err := enc.copyBlock(i); err != nil {
+				return err
+			}
+			p += blockSize
+			lit, rolled = p, false
+			continue
+		}
+		if p-lit >= maxLiteral {
+			if err := enc.literal(data[lit:p]); err != nil {
+				return err
+			}
+			lit = p
+		}
+		if p+blockSize < len(data) {
+			roll.roll(data[p], data[p+blockSize], blockSize)
+		} else {
+			rolled = false
+		}
+		p++
+	}
+	if err := enc.literal(data[lit:]); err != nil {
+		return err
+	}
+	return enc.end(total, hash.Sum(nil))
+}
+
+// encoder writes delta operations, merging copies of consecutive blocks.
+type encoder struct {
+	w          *bufio.Writer
+	copyStart  int
+	copyBlocks int
+}
+
+func (e *encoder) copyBlock(i int) error {
+	if e.copyBlocks > 0 && e.copyStart+e.copyBlocks == i {
+		e.copyBlocks++
+		return nil
+	}
+	if err := e.flushCopy(); err != nil {
+		return err
+	}
+	e.copyStart, e.copyBlocks = i, 1
+	return nil
+}
+
+func (e *encoder) flushCopy() error {
+	if e.copyBlocks == 0 {
+		return nil
+	}
+	op := binary.AppendUvarint([]byte{opCopy}, uint64(e.copyStart))
+	op = binary.AppendUvarint(op, uint64(e.copyBlocks))
+	e.copyBlocks = 0
+	_, err := e.w.Write(op)
+	return err
+}
+
+func (e *encoder) literal(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	if err := e.flushCopy(); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(binary.AppendUvarint([]byte{opLiteral}, uint64(len(b)))); err != nil {
+		return err
+	}
+	_, err := e.w.Write(b)
+	return err
+}
+
+func (e *encoder) end(size int64, sum []byte) error {
+	if err := e.flushCopy(); err != nil {
+		return err
+	}
+	op := binary.AppendUvarint([]byte{opEnd}, uint64(size))
+	if _, err := e.w.Write(append(op, sum...)); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// Stats describes a delta.
+type Stats struct {
+	Size         int64  // bytes in the file
+	LiteralBytes int64  // bytes the delta carries
+	CopiedBytes  int64  // bytes taken from the base
+	SHA256       string // hex digest of the file
+}
+
+// Inspect reads a delta's operations without applying them.
+func Inspect(delta []byte, blockSize int) (Stats, error) {
+	var stats Stats
+	err := walk(delta, func(op byte, a, b uint64, literal []byte) error {
+		switch op {
+		case opCopy:
+			stats.CopiedBytes += int64(b) * int64(blockSize)
+		case opLiteral:
+			stats.LiteralBytes += int64(len(literal))
+		case opEnd:
+			stats.Size = int64(a)
+			stats.SHA256 = hex.EncodeToString(literal)
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// Patch applies delta to base, which was signed with blockSize, writing the
+// file to w. It fails with ErrMismatch unless what it wrote matches the
+// size and SHA-256 the sender recorded.
+func Patch(base io.ReaderAt, blockSize int, delta []byte, w io.Writer) (Stats, error) {
+	if err := checkBlockSize(blockSize); err != nil {
+		return Stats{}, err
+	}
+	hash := sha256.New()
+	out := io.MultiWriter(w, hash)
+	var stats Stats
+	var written int64
+	ended := false
+	err := walk(delta, func(op byte, a, b uint64, literal []byte) error {
+		switch op {
+		case opCopy:
+			n := int64(b) * int64(blockSize)
+			copied, err := io.Copy(out, io.NewSectionReader(base, int64(a)*int64(blockSize), n))
+			if err != nil {
+				return fmt.Errorf("read base: %w", err)
+			}
+			if copied != n {
+				return fmt.Errorf("read base: blocks %d-%d are past its end", a, a+b-1)
+			}
+			written += n
+			stats.CopiedBytes += n
+		case opLiteral:
+			if _, err := out.Write(literal); err != nil {
+				return err
+			}
+			written += int64(len(literal))
+			stats.LiteralBytes += int64(len(literal))
+		case opEnd:
+			ended = true
+			stats.Size = int64(a)
+			stats.SHA256 = hex.EncodeToString(literal)
+			if written != stats.Size || !bytes.Equal(hash.Sum(nil), literal) {
+				return ErrMismatch
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+	if !ended {
+		return Stats{}, errors.New("invalid delta: missing end")
+	}
+	return stats, nil
+}
+
+// Assemble returns the file a delta against an empty base describes, as
+// sent to a receiver with no copy. Rather than copying the file, it moves
+// the literals together at the start of delta, overwriting it, and returns
+// that prefix. Like Patch it fails with ErrMismatch unless the result
+// matches the sender's size and SHA-256.
+func Assemble(delta []byte) ([]byte, Stats, error) {
+	hash := sha256.New()
+	var stats Stats
+	var written int
+	ended := false
+	err := walk(delta, func(op byte, a, b uint64, literal []byte) error {
+		switch op {
+		case opCopy:
+			return fmt.Errorf("invalid delta: copies blocks %d-%d from an empty base", a, a+b-1)
+		case opLiteral:
+			// A literal always starts after its own header, so moving it
+			// down never overwrites an operation not yet read.
+			hash.Write(literal)
+			written += copy(delta[written:], literal)
+			stats.LiteralBytes += int64(len(literal))
+		case opEnd:
+			ended = true
+			stats.Size = int64(a)
+			stats.SHA256 = hex.EncodeToString(literal)
+			if int64(written) != stats.Size || !bytes.Equal(hash.Sum(nil), literal) {
+				return ErrMismatch
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, Stats{}, err
+	}
+	if !ended {
+		return nil, Stats{}, errors.New("invalid delta: missing end")
+	}
+	return delta[:written], stats, nil
+}
+
+// walk calls fn for each operation in delta. Copies pass their first block
+// and count, literals their bytes, and the end its size and SHA-256.
+func walk(delta []byte, fn func(op byte, a, b uint64, data []byte) error) error {
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+		a, n := binary.Uvarint(delta)
+		if n <= 0 {
+			return errors.New("invalid delta: truncated operation")
+		}
+		delta = delta[n:]
+		switch op {
+		case opCopy:
+			b, n := binary.Uvarint(delta)
+			if n <= 0 {
+				return errors.New("invalid delta: truncated copy")
+			}
+			delta = delta[n:]
+			if err := fn(op, a, b, nil); err != nil {
+				return err
+			}
+		case opLiteral:
+			if a > uint64(len(delta)) {
+				return errors.New("invalid delta: truncated literal")
+			}
+			if err := fn(op, a, 0, delta[:a]); err != nil {
+				return err
+			}
+			delta = delta[a:]
+		case opEnd:
+			if len(delta) != sha256.Size {
+				return errors.New("invalid delta: malformed end")
+			}
+			return fn(op, a, 0, delta)
+		default:
+			return fmt.Errorf("invalid delta: unknown operation %d", op)
+		}
+	}
+	return nil
+}
+
+// rolling is rsync's weak checksum, which can slide along a byte at a time.
+type rolling struct {
+	a, b uint32
+}
+
+func newRolling(block []byte) rolling {
+	var r rolling
+	n := uint32(len(block))
+	for i, c := range block {
+		r.a += uint32(c)
+		r.b += (n - uint32(i)) * uint32(c)
+	}
+	return r
+}
+
+// roll slides the window one byte: out leaves it and in enters it.
+func (r *rolling) roll(out, in byte, blockSize int) {
+	r.a = r.a - uint32(out) + uint32(in)
+	r.b = r.b - uint32(blockSize)*uint32(out) + r.a
+}
+
+func (r rolling) sum() uint32 {
+	return r.a&0xffff | r.b<<16
+}
@@ -0,0 +1,161 @@
+package blockdelta
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func randomBytes(seed int64, n int) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(b)
+	return b
+}
+
+func diffAndPatch(t *testing.T, base, next []byte, blockSize int) ([]byte, Stats) {
+	t.Helper()
+	sigs, err := Sign(bytes.NewReader(base), blockSize)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	var delta bytes.Buffer
+	if err := Diff(bytes.NewReader(next), blockSize, sigs, 0, &delta); err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	var out bytes.Buffer
+	stats, err := Patch(bytes.NewReader(base), blockSize, delta.Bytes(), &out)
+	if err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), next) {
+		t.Fatalf("patched file differs from the new file")
+	}
+	inspected, err := Inspect(delta.Bytes(), blockSize)
+	if err != nil || inspected != stats {
+		t.Fatalf("Inspect = %+v, %v; Patch reported %+v", inspected, err, stats)
+	}
+	return delta.Bytes(), stats
+}
+
+func TestDeltaSendsOnlyChangedBytes(t *testing.T) {
+	t.Parallel()
+
+	const blockSize = 4096
+	base := randomBytes(1, 3*maxLiteral+123)
+
+	// Insert bytes near the start so every later block shifts, and change a
+	// few bytes in the middle.
+	next := append(append(append([]byte(nil), base[:1000]...), []byte("inserted")...), base[1000:]...)
+	copy(next[2*maxLiteral:], "changed")
+
+	delta, stats := diffAndPatch(t, base, next, blockSize)
+	if stats.Size != int64(len(next)) {
+		t.Fatalf("unexpected size %d", stats.Size)
+	}
+	// The blocks around each change, and the unsigned tail, go as literals.
+	if stats.LiteralBytes > 4*blockSize || len(delta) > 5*blockSize {
+		t.Fatalf("delta carries too much: %d literal bytes, %d bytes in all", stats.LiteralBytes, len(delta))
+	}
+}
+
+func TestDeltaWithoutUsableBase(t *testing.T) {
+	t.Parallel()
+
+	next := randomBytes(2, 50000)
+	for name, base := range map[string][]byte{
+		"empty base":     nil,
+		"unrelated base": randomBytes(3, 50000),
+	} {
+		_, stats := diffAndPatch(t, base, next, 1024)
+		if stats.LiteralBytes != int64(len(next)) {
+			t.Fatalf("%s: expected every byte as a literal, got %+v", name, stats)
+		}
+	}
+	_, stats := diffAndPatch(t, next, nil, 1024)
+	if stats.Size != 0 {
+		t.Fatalf("expected an empty file, got %+v", stats)
+	}
+}
+
+func TestAssembleRebuildsFileInPlace(t *testing.T) {
+	t.Parallel()
+
+	next := randomBytes(5, 3*maxLiteral+123)
+	var delta bytes.Buffer
+	if err := Diff(bytes.NewReader(next), MinBlockSize, nil, 0, &delta); err != nil {
+		t.Fatal(err)
+	}
+	want, err := Inspect(delta.Bytes(), MinBlockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := delta.Bytes()
+	data, stats, err := Assemble(raw)
+	if err != nil {
+		t.Fatalf("Assemble: %v", err)
+	}
+	if !bytes.Equal(data, next) || stats != want {
+		t.Fatalf("Assemble = %d bytes, %+v; want %d bytes, %+v", len(data), stats, len(next), want)
+	}
+	if &data[0] != &raw[0] {
+		t.Fatal("expected the file to reuse the delta's memory")
+	}
+
+	delta.Reset()
+	if err := Diff(bytes.NewReader(next), MinBlockSize, nil, 0, &delta); err != nil {
+		t.Fatal(err)
+	}
+	corrupt := delta.Bytes()
+	corrupt[len(corrupt)-1] ^= 0xff
+	if _, _, err := Assemble(corrupt); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("expected a corrupt delta to be caught, got %v", err)
+	}
+}
+
+func TestPatchRejectsChangedBase(t *testing.T) {
+	t.Parallel()
+
+	base := randomBytes(4, 10000)
+	sigs, err := Sign(bytes.NewReader(base), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var delta bytes.Buffer
+	if err := Diff(bytes.NewReader(base), 1000, sigs, 0, &delta); err != nil {
+		t.Fatal(err)
+	}
+	changed := append([]byte(nil), base...)
+	changed[0] ^= 0xff
+	if _, err := Patch(bytes.NewReader(changed), 1000, delta.Bytes(), &bytes.Buffer{}); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("expected a changed base to be caught, got %v", err)
+	}
+	if err := Diff(bytes.NewReader(base), 1000, sigs, 9999, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected max bytes to be enforced")
+	}
+}
+
+func TestRollingMatchesFreshChecksum(t *testing.T) {
+	t.Parallel()
+
+	data := randomBytes(5, 300)
+	const n = 64
+	r := newRolling(data[:n])
+	for p := 0; p+n < len(data); p++ {
+		r.roll(data[p], data[p+n], n)
+		if want := newRolling(data[p+1 : p+1+n]).sum(); r.sum() != want {
+			t.Fatalf("rolled checksum at %d = %x, want %x", p+1, r.sum(), want)
+		}
+	}
+}
+
+func TestBlockSize(t *testing.T) {
+	t.Parallel()
+
+	if got := BlockSize(0); got != MinBlockSize {
+		t.Fatalf("BlockSize(0) = %d", got)
+	}
+	if got := BlockSize(4 << 30); got != 128*1024 {
+		t.Fatalf("BlockSize(4 GiB) = %d", got)
+	}
+}
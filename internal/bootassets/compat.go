@@ -0,0 +1,253 @@
+package bootassets
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KernelFeature is a kernel capability this build's guest agent and init
+// script rely on. Config is the kconfig symbol that must be built in; the
+// VM boots without an initramfs, so modules are not loaded. Marker is a
+// string the feature leaves in the kernel image, used when the image does
+// not embed its config.
+type KernelFeature struct {
+	Name     string
+	Config   string
+	Marker   string
+	Guidance string
+}
+
+// MinKernelVersion is the oldest guest kernel series this build is tested
+// against.
+var MinKernelVersion = [2]int{5, 10}
+
+// RequiredKernelFeatures is the compatibility matrix for this build: every
+// guest kernel must provide these. A kernel missing one typically boots but
+// never answers on vsock, so launches fail with a dial timeout.
+var RequiredKernelFeatures = []KernelFeature{
+	{
+		Name:     "virtio-mmio",
+		Config:   "CONFIG_VIRTIO_MMIO",
+		Marker:   "virtio-mmio",
+		Guidance: "Firecracker exposes every device over virtio-mmio",
+	},
+	{
+		Name:     "vsock",
+		Config:   "CONFIG_VIRTIO_VSOCKETS",
+		Marker:   "vmw_vsock_virtio_transport",
+		Guidance: "the guest agent is reached over virtio-vsock",
+	},
+	{
+		Name:     "virtio-blk",
+		Config:   "CONFIG_VIRTIO_BLK",
+		Marker:   "virtio_blk",
+		Guidance: "the rootfs and scratch disks are virtio block devices",
+	},
+	{
+		Name:     "virtio-net",
+		Config:   "CONFIG_VIRTIO_NET",
+		Marker:   "virtio_net",
+		Guidance: "the guest network interface is a virtio-net device",
+	},
+	{
+		Name:     "ext4",
+		Config:   "CONFIG_EXT4_FS",
+		Marker:   "EXT4-fs",
+		Guidance: "the rootfs is formatted as ext4",
+	},
+	{
+		Name:     "devtmpfs",
+		Config:   "CONFIG_DEVTMPFS",
+		Marker:   "devtmpfs",
+		Guidance: "cleanroom-init mounts /dev as devtmpfs",
+	},
+}
+
+// NestedVirtualizationFeature is needed in addition when a policy enables
+// nested virtualization.
+var NestedVirtualizationFeature = KernelFeature{
+	Name:     "kvm",
+	Config:   "CONFIG_KVM",
+	Guidance: "nested virtualization exposes /dev/kvm inside the guest",
+}
+
+// KernelCompatibility is the result of checking a kernel image against a
+// set of features.
+type KernelCompatibility struct {
+	// Version is the kernel release, such as 6.1.155, if it was found.
+	Version string
+	// Missing are features the kernel is known to lack.
+	Missing []KernelFeature
+	// Unverified are features that could not be confirmed, because the
+	// image has no embedded config and the feature left no marker.
+	Unverified []KernelFeature
+	// TooOld is set when Version is older than MinKernelVersion.
+	TooOld bool
+}
+
+// Err returns why the kernel cannot be used, or nil.
+func (c KernelCompatibility) Err(path string) error {
+	if len(c.Missing) == 0 {
+		return nil
+	}
+	lines := make([]string, 0, len(c.Missing))
+	for _, feature := range c.Missing {
+		lines = append(lines, fmt.Sprintf("%s (%s=y): %s", feature.Name, feature.Config, feature.Guidance))
+	}
+	return fmt.Errorf("kernel image %q lacks features this cleanroom build needs; rebuild it with:\n  %s\nor unset kernel_image to use the managed kernel",
+		path, strings.Join(lines, "\n  "))
+}
+
+// Warnings describes anything that might stop the kernel working but was
+// not certain enough to refuse it.
+func (c KernelCompatibility) Warnings() []string {
+	var warnings []string
+	if c.TooOld {
+		warnings = append(warnings, fmt.Sprintf("kernel %s is older than %d.%d, the oldest guest kernel this build is tested with",
+			c.Version, MinKernelVersion[0], MinKernelVersion[1]))
+	}
+	if len(c.Unverified) > 0 {
+		names := make([]string, 0, len(c.Unverified))
+		for _, feature := range c.Unverified {
+			names = append(names, feature.Name)
+		}
+		warnings = append(warnings, fmt.Sprintf("could not confirm kernel support for %s; build the kernel with CONFIG_IKCONFIG=y so it can be checked",
+			strings.Join(names, ", ")))
+	}
+	return warnings
+}
+
+type compatCacheKey struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+var (
+	compatCacheMu sync.Mutex
+	compatCache   = map[compatCacheKey]kernelFacts{}
+)
+
+// kernelFacts is what is read from a kernel image once.
+type kernelFacts struct {
+	version string
+	// config holds the embedded kconfig, or nil if the image has none.
+	config  map[string]string
+	markers map[string]bool
+}
+
+// CheckKernel checks the kernel image at path for features. Images are
+// read once per path, size and modification time.
+func CheckKernel(path string, features []KernelFeature) (KernelCompatibility, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return KernelCompatibility{}, fmt.Errorf("stat kernel image %q: %w", path, err)
+	}
+	key := compatCacheKey{path: path, size: st.Size(), modTime: st.ModTime()}
+	compatCacheMu.Lock()
+	facts, ok := compatCache[key]
+	compatCacheMu.Unlock()
+	if !ok {
+		image, err := os.ReadFile(path)
+		if err != nil {
+			return KernelCompatibility{}, fmt.Errorf("read kernel image %q: %w", path, err)
+		}
+		facts = readKernelFacts(image)
+		compatCacheMu.Lock()
+		compatCache[key] = facts
+		compatCacheMu.Unlock()
+	}
+	return facts.check(features), nil
+}
+
+// KernelVersion returns the release of the kernel image at path, such as
+// 6.1.155, or "" if it cannot be read from the image.
+func KernelVersion(path string) string {
+	compat, err := CheckKernel(path, nil)
+	if err != nil {
+		return ""
+	}
+	return compat.Version
+}
+
+var kernelVersionPattern = regexp.MustCompile(`Linux version (\d+)\.(\d+)(\.\d+)?`)
+
+func readKernelFacts(image []byte) kernelFacts {
+	facts := kernelFacts{markers: map[string]bool{}}
+	if m := kernelVersionPattern.FindSubmatch(image); m != nil {
+		facts.version = string(m[1]) + "." + string(m[2]) + string(m[3])
+	}
+	facts.config = embeddedKernelConfig(image)
+	if facts.config == nil {
+		for _, feature := range slices.Concat(RequiredKernelFeatures, []KernelFeature{NestedVirtualizationFeature}) {
+			if feature.Marker != "" {
+				facts.markers[feature.Marker] = bytes.Contains(image, []byte(feature.Marker))
+			}
+		}
+	}
+	return facts
+}
+
+// embeddedKernelConfig returns the kconfig a kernel built with
+// CONFIG_IKCONFIG carries as gzip between IKCFG_ST and IKCFG_ED.
+func embeddedKernelConfig(image []byte) map[string]string {
+	start := bytes.Index(image, []byte("IKCFG_ST"))
+	if start < 0 {
+		return nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(image[start+len("IKCFG_ST"):]))
+	if err != nil {
+		return nil
+	}
+	zr.Multistream(false)
+	config := map[string]string{}
+	scanner := bufio.NewScanner(io.LimitReader(zr, 16<<20))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if ok && strings.HasPrefix(key, "CONFIG_") {
+			config[key] = value
+		}
+	}
+	if len(config) == 0 {
+		return nil
+	}
+	return config
+}
+
+func (f kernelFacts) check(features []KernelFeature) KernelCompatibility {
+	result := KernelCompatibility{Version: f.version}
+	if f.version != "" {
+		major, minor := parseKernelVersion(f.version)
+		result.TooOld = major < MinKernelVersion[0] || (major == MinKernelVersion[0] && minor < MinKernelVersion[1])
+	}
+	for _, feature := range features {
+		switch {
+		case f.config != nil:
+			if f.config[feature.Config] != "y" {
+				result.Missing = append(result.Missing, feature)
+			}
+		case feature.Marker == "" || !f.markers[feature.Marker]:
+			result.Unverified = append(result.Unverified, feature)
+		}
+	}
+	return result
+}
+
+func parseKernelVersion(version string) (major, minor int) {
+	parts := strings.SplitN(version, ".", 3)
+	major, _ = strconv.Atoi(parts[0])
+	if len(parts) > 1 {
+		minor, _ = strconv.Atoi(parts[1])
+	}
+	return major, minor
+}
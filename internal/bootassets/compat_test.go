@@ -0,0 +1,102 @@
+package bootassets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeKernelImage(t *testing.T, parts ...[]byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "vmlinux")
+	if err := os.WriteFile(path, bytes.Join(parts, []byte{0}), 0o644); err != nil {
+		t.Fatalf("write kernel image: %v", err)
+	}
+	return path
+}
+
+func embeddedConfig(t *testing.T, config string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.WriteString("IKCFG_ST")
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(config)); err != nil {
+		t.Fatalf("gzip config: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip config: %v", err)
+	}
+	buf.WriteString("IKCFG_ED")
+	return buf.Bytes()
+}
+
+func TestCheckKernelReportsMissingConfigOptions(t *testing.T) {
+	t.Parallel()
+
+	path := writeKernelImage(t,
+		[]byte("Linux version 6.1.155 (builder@host)"),
+		embeddedConfig(t, strings.Join([]string{
+			"CONFIG_VIRTIO_MMIO=y",
+			"CONFIG_VIRTIO_VSOCKETS=m",
+			"CONFIG_VIRTIO_BLK=y",
+			"CONFIG_VIRTIO_NET=y",
+			"CONFIG_EXT4_FS=y",
+			"CONFIG_DEVTMPFS=y",
+			"# CONFIG_KVM is not set",
+		}, "\n")),
+	)
+
+	compat, err := CheckKernel(path, RequiredKernelFeatures)
+	if err != nil {
+		t.Fatalf("CheckKernel: %v", err)
+	}
+	if compat.Version != "6.1.155" || compat.TooOld {
+		t.Fatalf("unexpected version: %+v", compat)
+	}
+	if len(compat.Missing) != 1 || compat.Missing[0].Name != "vsock" {
+		t.Fatalf("expected vsock to be missing, got %+v", compat.Missing)
+	}
+	err = compat.Err(path)
+	if err == nil || !strings.Contains(err.Error(), "vsock (CONFIG_VIRTIO_VSOCKETS=y)") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	compat, err = CheckKernel(path, []KernelFeature{NestedVirtualizationFeature})
+	if err != nil {
+		t.Fatalf("CheckKernel: %v", err)
+	}
+	if len(compat.Missing) != 1 || compat.Missing[0].Name != "kvm" {
+		t.Fatalf("expected kvm to be missing, got %+v", compat.Missing)
+	}
+}
+
+func TestCheckKernelFallsBackToMarkers(t *testing.T) {
+	t.Parallel()
+
+	path := writeKernelImage(t,
+		[]byte("Linux version 4.14.200 (builder@host)"),
+		[]byte("virtio-mmio"),
+		[]byte("vmw_vsock_virtio_transport"),
+		[]byte("virtio_blk"),
+		[]byte("EXT4-fs"),
+		[]byte("devtmpfs"),
+	)
+
+	compat, err := CheckKernel(path, RequiredKernelFeatures)
+	if err != nil {
+		t.Fatalf("CheckKernel: %v", err)
+	}
+	if err := compat.Err(path); err != nil {
+		t.Fatalf("markers alone should not refuse a kernel: %v", err)
+	}
+	if len(compat.Unverified) != 1 || compat.Unverified[0].Name != "virtio-net" {
+		t.Fatalf("expected virtio-net to be unverified, got %+v", compat.Unverified)
+	}
+	warnings := strings.Join(compat.Warnings(), "\n")
+	if !compat.TooOld || !strings.Contains(warnings, "older than 5.10") || !strings.Contains(warnings, "virtio-net") {
+		t.Fatalf("unexpected warnings: %q", warnings)
+	}
+}
@@ -165,28 +165,34 @@ func (m *Manager) EnsureKernel(ctx context.Context, backendName, goos, goarch st
 		return EnsureResult{Path: dest, CacheHit: true, Spec: spec}, nil
 	}
 
-	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
-		return EnsureResult{}, fmt.Errorf("create kernel asset directory %q: %w", filepath.Dir(dest), err)
-	}
-
-	tmp := dest + fmt.Sprintf(".tmp-%d", time.Now().UnixNano())
-	if err := m.downloadAndVerify(ctx, spec, tmp); err != nil {
-		_ = os.Remove(tmp)
-		return EnsureResult{}, err
-	}
-
-	if err := os.Rename(tmp, dest); err != nil {
-		_ = os.Remove(tmp)
+	if err := m.store(ctx, dest, spec.SHA256, func(ctx context.Context) (io.ReadCloser, error) {
+		return m.openURL(ctx, spec.URL)
+	}); err != nil {
 		if valid, vErr := fileMatchesSHA256(dest, spec.SHA256); vErr == nil && valid {
 			return EnsureResult{Path: dest, CacheHit: true, Spec: spec}, nil
 		}
-		return EnsureResult{}, fmt.Errorf("store kernel asset %q: %w", dest, err)
+		return EnsureResult{}, fmt.Errorf("fetch kernel asset %s: %w", spec.ID, err)
 	}
 
 	return EnsureResult{Path: dest, CacheHit: false, Spec: spec}, nil
 }
 
-func (m *Manager) ResolveKernelPath(ctx context.Context, backendName, goos, goarch, configuredPath string) (ResolveResult, error) {
+// ResolveKernelPath picks the guest kernel: configuredPath if it exists,
+// then the operator's source if one is set, then the managed kernel.
+func (m *Manager) ResolveKernelPath(ctx context.Context, backendName, goos, goarch, configuredPath string, source Source) (ResolveResult, error) {
+	if strings.TrimSpace(configuredPath) == "" && !source.IsZero() {
+		ensured, err := m.EnsureSource(ctx, "kernel", source)
+		if err != nil {
+			return ResolveResult{}, err
+		}
+		return ResolveResult{
+			Path:     ensured.Path,
+			CacheHit: ensured.CacheHit,
+			Spec:     ensured.Spec,
+			Notice:   fmt.Sprintf("using kernel from %s (%s)", ensured.Spec.URL, cacheState(ensured.CacheHit)),
+		}, nil
+	}
+
 	trimmed := strings.TrimSpace(configuredPath)
 	if trimmed != "" {
 		absPath, err := filepath.Abs(trimmed)
@@ -228,41 +234,67 @@ func (m *Manager) ResolveKernelPath(ctx context.Context, backendName, goos, goar
 	}, nil
 }
 
-func (m *Manager) downloadAndVerify(ctx context.Context, spec KernelSpec, tmpPath string) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, spec.URL, nil)
-	if err != nil {
-		return fmt.Errorf("create kernel asset request: %w", err)
+// store writes the asset opened by open to dest through a temporary file,
+// keeping it only if its SHA-256 matches wantSHA256.
+func (m *Manager) store(ctx context.Context, dest, wantSHA256 string, open func(context.Context) (io.ReadCloser, error)) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return fmt.Errorf("create asset directory %q: %w", filepath.Dir(dest), err)
 	}
-	req.Header.Set("User-Agent", "cleanroom")
-
-	res, err := m.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("download kernel asset from %s: %w", spec.URL, err)
+	tmp := dest + fmt.Sprintf(".tmp-%d", time.Now().UnixNano())
+	if err := writeVerified(ctx, tmp, wantSHA256, open); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("store asset %q: %w", dest, err)
 	}
-	defer res.Body.Close()
+	return nil
+}
 
-	if res.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
-		return fmt.Errorf("download kernel asset from %s: unexpected status %d: %s", spec.URL, res.StatusCode, strings.TrimSpace(string(body)))
+func writeVerified(ctx context.Context, tmpPath, wantSHA256 string, open func(context.Context) (io.ReadCloser, error)) error {
+	src, err := open(ctx)
+	if err != nil {
+		return err
 	}
+	defer src.Close()
 
 	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
 	if err != nil {
-		return fmt.Errorf("create temporary kernel asset %q: %w", tmpPath, err)
+		return fmt.Errorf("create temporary asset %q: %w", tmpPath, err)
 	}
 	defer out.Close()
 
 	hash := sha256.New()
-	if _, err := io.Copy(io.MultiWriter(out, hash), res.Body); err != nil {
-		return fmt.Errorf("write kernel asset %q: %w", tmpPath, err)
+	if _, err := io.Copy(io.MultiWriter(out, hash), src); err != nil {
+		return fmt.Errorf("write asset %q: %w", tmpPath, err)
 	}
 	got := hex.EncodeToString(hash.Sum(nil))
-	if !strings.EqualFold(got, spec.SHA256) {
-		return fmt.Errorf("kernel asset checksum mismatch for %s: got %s want %s", spec.URL, got, spec.SHA256)
+	if !strings.EqualFold(got, wantSHA256) {
+		return fmt.Errorf("asset checksum mismatch: got %s want %s", got, wantSHA256)
 	}
 	return nil
 }
 
+func (m *Manager) openURL(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create asset request: %w", err)
+	}
+	req.Header.Set("User-Agent", "cleanroom")
+
+	res, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download asset from %s: %w", url, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		_ = res.Body.Close()
+		return nil, fmt.Errorf("download asset from %s: unexpected status %d: %s", url, res.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return res.Body, nil
+}
+
 func fileMatchesSHA256(path, wantSHA256 string) (bool, error) {
 	st, err := os.Stat(path)
 	if errors.Is(err, os.ErrNotExist) {
@@ -314,6 +346,6 @@ func ManagedKernelPathForHost(backendName string) (string, error) {
 	return defaultManager.KernelPath(backendName, runtime.GOOS, runtime.GOARCH)
 }
 
-func ResolveKernelPathForHost(ctx context.Context, backendName, configuredPath string) (ResolveResult, error) {
-	return defaultManager.ResolveKernelPath(ctx, backendName, runtime.GOOS, runtime.GOARCH, configuredPath)
+func ResolveKernelPathForHost(ctx context.Context, backendName, configuredPath string, source Source) (ResolveResult, error) {
+	return defaultManager.ResolveKernelPath(ctx, backendName, runtime.GOOS, runtime.GOARCH, configuredPath, source)
 }
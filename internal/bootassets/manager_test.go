@@ -44,7 +44,7 @@ func TestResolveKernelPathUsesConfiguredPathWhenPresent(t *testing.T) {
 		},
 	})
 
-	got, err := mgr.ResolveKernelPath(context.Background(), "darwin-vz", "darwin", "arm64", configured)
+	got, err := mgr.ResolveKernelPath(context.Background(), "darwin-vz", "darwin", "arm64", configured, Source{})
 	if err != nil {
 		t.Fatalf("ResolveKernelPath returned error: %v", err)
 	}
@@ -86,7 +86,7 @@ func TestResolveKernelPathDownloadsAndCachesManagedKernel(t *testing.T) {
 		},
 	})
 
-	first, err := mgr.ResolveKernelPath(context.Background(), "darwin-vz", "darwin", "arm64", "")
+	first, err := mgr.ResolveKernelPath(context.Background(), "darwin-vz", "darwin", "arm64", "", Source{})
 	if err != nil {
 		t.Fatalf("ResolveKernelPath first call returned error: %v", err)
 	}
@@ -100,7 +100,7 @@ func TestResolveKernelPathDownloadsAndCachesManagedKernel(t *testing.T) {
 		t.Fatalf("expected managed notice, got %q", first.Notice)
 	}
 
-	second, err := mgr.ResolveKernelPath(context.Background(), "darwin-vz", "darwin", "arm64", "")
+	second, err := mgr.ResolveKernelPath(context.Background(), "darwin-vz", "darwin", "arm64", "", Source{})
 	if err != nil {
 		t.Fatalf("ResolveKernelPath second call returned error: %v", err)
 	}
@@ -141,7 +141,7 @@ func TestResolveKernelPathFallsBackFromMissingConfiguredPath(t *testing.T) {
 		},
 	})
 
-	res, err := mgr.ResolveKernelPath(context.Background(), "firecracker", "linux", "amd64", "/tmp/missing-kernel")
+	res, err := mgr.ResolveKernelPath(context.Background(), "firecracker", "linux", "amd64", "/tmp/missing-kernel", Source{})
 	if err != nil {
 		t.Fatalf("ResolveKernelPath returned error: %v", err)
 	}
@@ -163,7 +163,7 @@ func TestResolveKernelPathReturnsErrorWhenUnsupported(t *testing.T) {
 		Specs: map[Selector]KernelSpec{},
 	})
 
-	_, err := mgr.ResolveKernelPath(context.Background(), "darwin-vz", "darwin", "arm64", "")
+	_, err := mgr.ResolveKernelPath(context.Background(), "darwin-vz", "darwin", "arm64", "", Source{})
 	if err == nil {
 		t.Fatal("expected unsupported-platform error")
 	}
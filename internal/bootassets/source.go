@@ -0,0 +1,179 @@
+package bootassets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Source is an operator-managed location for a boot asset, such as an
+// internal mirror or an OCI artifact, used instead of the managed assets.
+// SHA256 pins the asset file itself; a cached copy that matches it is used
+// without contacting the source, so hosts without network access only need
+// the file in the assets directory.
+type Source struct {
+	// URL is an https://, http:// or file:// location of the file.
+	URL string
+	// OCI is a digest-pinned artifact reference, repo@sha256:<digest>,
+	// whose layer is the file. Artifacts with several layers are matched
+	// on SHA256.
+	OCI    string
+	SHA256 string
+}
+
+func (s Source) IsZero() bool {
+	return strings.TrimSpace(s.URL) == "" && strings.TrimSpace(s.OCI) == ""
+}
+
+// Location is the URL or OCI reference the asset is fetched from.
+func (s Source) Location() string {
+	if u := strings.TrimSpace(s.URL); u != "" {
+		return u
+	}
+	return strings.TrimSpace(s.OCI)
+}
+
+var sourceSHA256Pattern = regexp.MustCompile(`^[a-f0-9]{64}$`)
+
+// Validate reports a source that names no location, both locations, or no
+// valid pin.
+func (s Source) Validate() error {
+	hasURL, hasOCI := strings.TrimSpace(s.URL) != "", strings.TrimSpace(s.OCI) != ""
+	switch {
+	case hasURL && hasOCI:
+		return errors.New("set one of url or oci, not both")
+	case !hasURL && !hasOCI:
+		return errors.New("set url or oci")
+	case !sourceSHA256Pattern.MatchString(strings.TrimSpace(s.SHA256)):
+		return errors.New("sha256 must be 64 lowercase hex characters")
+	}
+	if hasURL {
+		u, err := url.Parse(strings.TrimSpace(s.URL))
+		if err != nil {
+			return fmt.Errorf("invalid url: %w", err)
+		}
+		switch u.Scheme {
+		case "https", "http", "file":
+		default:
+			return fmt.Errorf("url scheme %q is not supported (expected https, http or file)", u.Scheme)
+		}
+		return nil
+	}
+	if _, err := name.NewDigest(strings.TrimSpace(s.OCI)); err != nil {
+		return fmt.Errorf("oci must be a digest-pinned reference: %w", err)
+	}
+	return nil
+}
+
+// EnsureSource returns the path of the asset from src, fetching it into
+// the assets directory under kind if no verified copy is cached there.
+func (m *Manager) EnsureSource(ctx context.Context, kind string, src Source) (EnsureResult, error) {
+	if err := src.Validate(); err != nil {
+		return EnsureResult{}, fmt.Errorf("%s source: %w", kind, err)
+	}
+	base, err := m.assetsDir()
+	if err != nil {
+		return EnsureResult{}, fmt.Errorf("resolve assets directory: %w", err)
+	}
+	sum := strings.TrimSpace(src.SHA256)
+	spec := KernelSpec{
+		ID:       "sha256-" + sum,
+		Filename: sourceFilename(kind, src),
+		URL:      src.Location(),
+		SHA256:   sum,
+	}
+	dest := filepath.Join(base, kind+"s", spec.ID, spec.Filename)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	valid, err := fileMatchesSHA256(dest, sum)
+	if err != nil {
+		return EnsureResult{}, err
+	}
+	if valid {
+		return EnsureResult{Path: dest, CacheHit: true, Spec: spec}, nil
+	}
+	if err := m.store(ctx, dest, sum, func(ctx context.Context) (io.ReadCloser, error) {
+		return m.openSource(ctx, src)
+	}); err != nil {
+		return EnsureResult{}, fmt.Errorf("fetch %s from %s: %w", kind, spec.URL, err)
+	}
+	return EnsureResult{Path: dest, Spec: spec}, nil
+}
+
+func (m *Manager) openSource(ctx context.Context, src Source) (io.ReadCloser, error) {
+	if ref := strings.TrimSpace(src.OCI); ref != "" {
+		return openOCIArtifact(ctx, ref, strings.TrimSpace(src.SHA256))
+	}
+	u, err := url.Parse(strings.TrimSpace(src.URL))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "file" {
+		return os.Open(u.Path)
+	}
+	return m.openURL(ctx, u.String())
+}
+
+// openOCIArtifact opens the layer of a single-file artifact, as pushed by
+// tools such as oras. The layer whose digest is the pin is preferred, so a
+// kernel and its config can share an artifact.
+func openOCIArtifact(ctx context.Context, ref, wantSHA256 string) (io.ReadCloser, error) {
+	digestRef, err := name.NewDigest(ref)
+	if err != nil {
+		return nil, fmt.Errorf("parse digest reference %q: %w", ref, err)
+	}
+	img, err := remote.Image(digestRef, remote.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("pull OCI artifact %q: %w", ref, err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("read OCI artifact %q: %w", ref, err)
+	}
+	var layer v1.Layer
+	for _, l := range layers {
+		if d, err := l.Digest(); err == nil && d.Hex == wantSHA256 {
+			layer = l
+			break
+		}
+	}
+	if layer == nil {
+		if len(layers) != 1 {
+			return nil, fmt.Errorf("OCI artifact %q has %d layers and none matches sha256 %s", ref, len(layers), wantSHA256)
+		}
+		layer = layers[0]
+	}
+	// The artifact's layer is the file itself, so it is read as stored
+	// rather than decompressed.
+	return layer.Compressed()
+}
+
+// sourceFilename keeps the file name from a URL source so cached assets
+// are recognisable, and falls back to the kind.
+func sourceFilename(kind string, src Source) string {
+	if raw := strings.TrimSpace(src.URL); raw != "" {
+		if u, err := url.Parse(raw); err == nil {
+			if base := path.Base(u.Path); base != "." && base != "/" && base != "" {
+				return base
+			}
+		}
+	}
+	return kind
+}
+
+// EnsureSourceForHost is EnsureSource with the default manager.
+func EnsureSourceForHost(ctx context.Context, kind string, src Source) (EnsureResult, error) {
+	return defaultManager.EnsureSource(ctx, kind, src)
+}
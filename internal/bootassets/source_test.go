@@ -0,0 +1,149 @@
+package bootassets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func testManager(t *testing.T, client *http.Client) (*Manager, string) {
+	t.Helper()
+	assets := filepath.Join(t.TempDir(), "assets")
+	return New(Options{
+		HTTPClient: client,
+		AssetsDir:  func() (string, error) { return assets, nil },
+		Specs:      map[Selector]KernelSpec{},
+	}), assets
+}
+
+func TestResolveKernelPathFetchesSourceAndUsesCacheOffline(t *testing.T) {
+	t.Parallel()
+
+	const payload = "mirrored-kernel"
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		_, _ = w.Write([]byte(payload))
+	}))
+	mgr, assets := testManager(t, srv.Client())
+	source := Source{URL: srv.URL + "/kernels/vmlinux-6.1", SHA256: sha256Hex([]byte(payload))}
+
+	first, err := mgr.ResolveKernelPath(context.Background(), "firecracker", "linux", "amd64", "", source)
+	if err != nil {
+		t.Fatalf("ResolveKernelPath: %v", err)
+	}
+	if first.Managed || first.CacheHit || !strings.HasPrefix(first.Path, filepath.Join(assets, "kernels", "sha256-")) || filepath.Base(first.Path) != "vmlinux-6.1" {
+		t.Fatalf("unexpected first result: %+v", first)
+	}
+
+	srv.Close()
+	second, err := mgr.ResolveKernelPath(context.Background(), "firecracker", "linux", "amd64", "", source)
+	if err != nil {
+		t.Fatalf("ResolveKernelPath with the source offline: %v", err)
+	}
+	if !second.CacheHit || second.Path != first.Path || hits.Load() != 1 {
+		t.Fatalf("expected a cache hit without fetching, got %+v after %d fetches", second, hits.Load())
+	}
+}
+
+func TestEnsureSourceRejectsChecksumMismatch(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "rootfs.ext4")
+	if err := os.WriteFile(file, []byte("tampered"), 0o644); err != nil {
+		t.Fatalf("write asset: %v", err)
+	}
+	mgr, _ := testManager(t, nil)
+	source := Source{URL: (&url.URL{Scheme: "file", Path: file}).String(), SHA256: sha256Hex([]byte("expected"))}
+
+	_, err := mgr.EnsureSource(context.Background(), "rootfs", source)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+
+	source.SHA256 = sha256Hex([]byte("tampered"))
+	got, err := mgr.EnsureSource(context.Background(), "rootfs", source)
+	if err != nil {
+		t.Fatalf("EnsureSource: %v", err)
+	}
+	if b, _ := os.ReadFile(got.Path); string(b) != "tampered" {
+		t.Fatalf("unexpected asset contents %q", b)
+	}
+}
+
+func TestEnsureSourceFetchesOCIArtifactLayer(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(registry.New())
+	t.Cleanup(srv.Close)
+
+	const payload = "kernel-in-an-artifact"
+	artifact, err := mutate.AppendLayers(empty.Image,
+		static.NewLayer([]byte("kernel config"), types.MediaType("application/vnd.example.config")),
+		static.NewLayer([]byte(payload), types.MediaType("application/vnd.example.kernel")),
+	)
+	if err != nil {
+		t.Fatalf("build artifact: %v", err)
+	}
+	host := strings.TrimPrefix(srv.URL, "http://")
+	tag, err := name.NewTag(host + "/kernels/vmlinux:6.1")
+	if err != nil {
+		t.Fatalf("parse tag: %v", err)
+	}
+	if err := remote.Write(tag, artifact); err != nil {
+		t.Fatalf("push artifact: %v", err)
+	}
+	digest, err := artifact.Digest()
+	if err != nil {
+		t.Fatalf("artifact digest: %v", err)
+	}
+
+	mgr, _ := testManager(t, nil)
+	got, err := mgr.EnsureSource(context.Background(), "kernel", Source{
+		OCI:    host + "/kernels/vmlinux@" + digest.String(),
+		SHA256: sha256Hex([]byte(payload)),
+	})
+	if err != nil {
+		t.Fatalf("EnsureSource: %v", err)
+	}
+	if b, _ := os.ReadFile(got.Path); string(b) != payload {
+		t.Fatalf("unexpected asset contents %q", b)
+	}
+}
+
+func TestSourceValidate(t *testing.T) {
+	t.Parallel()
+
+	sum := strings.Repeat("a", 64)
+	for _, tc := range []struct {
+		source Source
+		want   string
+	}{
+		{Source{URL: "https://mirror/vmlinux", SHA256: sum}, ""},
+		{Source{OCI: "registry.internal/kernels/vmlinux@sha256:" + sum, SHA256: sum}, ""},
+		{Source{SHA256: sum}, "set url or oci"},
+		{Source{URL: "https://mirror/vmlinux", OCI: "registry.internal/k@sha256:" + sum, SHA256: sum}, "not both"},
+		{Source{URL: "https://mirror/vmlinux", SHA256: "ABC"}, "sha256 must be"},
+		{Source{OCI: "registry.internal/kernels/vmlinux:6.1", SHA256: sum}, "digest-pinned"},
+	} {
+		err := tc.source.Validate()
+		if tc.want == "" && err != nil || tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)) {
+			t.Fatalf("Validate(%+v) = %v, want %q", tc.source, err, tc.want)
+		}
+	}
+}
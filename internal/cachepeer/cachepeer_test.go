@@ -0,0 +1,189 @@
+package cachepeer
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/imagemgr"
+	"github.com/buildkite/cleanroom/internal/tlsconfig"
+)
+
+const testImageRef = "ghcr.io/buildkite/cleanroom-base/alpine@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestEnsureFetchesFromPeerInsteadOfRegistry(t *testing.T) {
+	t.Parallel()
+
+	tlsCfg := testPeerTLS(t)
+
+	warm := newTestManager(t, nil)
+	if _, err := warm.Import(context.Background(), testImageRef, "-", bytes.NewReader(testRootFSTar(t))); err != nil {
+		t.Fatalf("Import returned error: %v", err)
+	}
+	server := NewServer(ServerConfig{ListenAddr: "127.0.0.1:0", TLS: tlsCfg, Cache: warm})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	t.Cleanup(func() { _ = server.Stop(context.Background()) })
+
+	client := NewClient([]string{"https://127.0.0.1:1", "https://" + server.Addr()}, tlsCfg, nil)
+	cold := newTestManager(t, client)
+
+	result, err := cold.Ensure(context.Background(), testImageRef)
+	if err != nil {
+		t.Fatalf("Ensure returned error: %v", err)
+	}
+	if got, want := result.Record.Source, "peer"; got != want {
+		t.Fatalf("unexpected source: got %q want %q", got, want)
+	}
+	if got, err := os.ReadFile(result.Record.RootFSPath); err != nil || string(got) != "fake-ext4" {
+		t.Fatalf("unexpected rootfs %q: %v", got, err)
+	}
+
+	if _, _, err := client.FetchArchive(context.Background(), "sha256:"+string(bytes.Repeat([]byte("f"), 64))); err == nil {
+		t.Fatal("expected an error for a digest no peer has")
+	}
+}
+
+func TestServerRequiresClientCertificate(t *testing.T) {
+	t.Parallel()
+
+	tlsCfg := testPeerTLS(t)
+	server := NewServer(ServerConfig{ListenAddr: "127.0.0.1:0", TLS: tlsCfg, Cache: newTestManager(t, nil)})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	t.Cleanup(func() { _ = server.Stop(context.Background()) })
+
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: tlsCfg.RootCAs}}}
+	if resp, err := anonymous.Get("https://" + server.Addr() + DigestsPath); err == nil {
+		resp.Body.Close()
+		t.Fatal("expected a request without a client certificate to fail")
+	}
+}
+
+func newTestManager(t *testing.T, peers imagemgr.PeerSource) *imagemgr.Manager {
+	t.Helper()
+
+	dir := t.TempDir()
+	manager, err := imagemgr.New(imagemgr.Options{
+		CacheDir:       filepath.Join(dir, "cache"),
+		MetadataDBPath: filepath.Join(dir, "metadata.db"),
+		PreparedDir:    filepath.Join(dir, "prepared"),
+		Peers:          peers,
+		PullImage: func(context.Context, string) (io.ReadCloser, imagemgr.OCIConfig, error) {
+			t.Error("unexpected registry pull")
+			return nil, imagemgr.OCIConfig{}, os.ErrNotExist
+		},
+		MaterializeRootFS: func(_ context.Context, stream io.Reader, outputPath string) (int64, error) {
+			if _, err := io.Copy(io.Discard, stream); err != nil {
+				return 0, err
+			}
+			return 9, os.WriteFile(outputPath, []byte("fake-ext4"), 0o644)
+		},
+	})
+	if err != nil {
+		t.Fatalf("create image manager: %v", err)
+	}
+	return manager
+}
+
+func testRootFSTar(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	content := []byte("hello rootfs\n")
+	if err := tw.WriteHeader(&tar.Header{Name: "etc/hello", Mode: 0o644, Size: int64(len(content))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testPeerTLS writes a CA and a peer certificate for 127.0.0.1 signed by
+// it, and loads them the way serve does.
+func testPeerTLS(t *testing.T) *tls.Config {
+	t.Helper()
+
+	dir := t.TempDir()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test cache ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peerDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "host-a"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}, caCert, &peerKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peerKeyDER, err := x509.MarshalECPrivateKey(peerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(name, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	cfg, err := tlsconfig.ResolvePeer(tlsconfig.Options{
+		CertPath: write("peer.pem", "CERTIFICATE", peerDER),
+		KeyPath:  write("peer.key", "EC PRIVATE KEY", peerKeyDER),
+		CAPath:   write("ca.pem", "CERTIFICATE", caDER),
+	})
+	if err != nil {
+		t.Fatalf("ResolvePeer returned error: %v", err)
+	}
+	return cfg
+}
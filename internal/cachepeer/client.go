@@ -0,0 +1,154 @@
+package cachepeer
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// advertTTL is how long a peer's digest list is trusted before it is
+	// fetched again.
+	advertTTL = 30 * time.Second
+	// advertTimeout bounds a digest list request, so an unreachable peer
+	// delays a cache miss only briefly before the next peer or the
+	// registry is tried.
+	advertTimeout = 2 * time.Second
+)
+
+// Client fetches images from peers' caches. It implements
+// imagemgr.PeerSource.
+type Client struct {
+	peers  []string
+	http   *http.Client
+	logger *log.Logger
+	now    func() time.Time
+
+	mu      sync.Mutex
+	adverts map[string]advert
+}
+
+type advert struct {
+	digests map[string]bool
+	fetched time.Time
+}
+
+// NewClient returns a client for peers, given as https://host:port base
+// URLs, tried in order.
+func NewClient(peers []string, tlsCfg *tls.Config, logger *log.Logger) *Client {
+	trimmed := make([]string, 0, len(peers))
+	for _, peer := range peers {
+		if peer = strings.TrimRight(strings.TrimSpace(peer), "/"); peer != "" {
+			trimmed = append(trimmed, peer)
+		}
+	}
+	return &Client{
+		peers: trimmed,
+		http: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig:   tlsCfg,
+				ForceAttemptHTTP2: true,
+			},
+		},
+		logger:  logger,
+		now:     time.Now,
+		adverts: map[string]advert{},
+	}
+}
+
+// FetchArchive returns the cache archive for digest, and the peer serving
+// it, from the first peer that advertises it.
+func (c *Client) FetchArchive(ctx context.Context, digest string) (io.ReadCloser, string, error) {
+	for _, peer := range c.peers {
+		if !c.advertises(ctx, peer, digest) {
+			continue
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, peer+ArchivePathPrefix+digest, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			c.warn("cache sharing fetch failed", peer, digest, err)
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			c.warn("cache sharing fetch failed", peer, digest, fmt.Errorf("peer answered %s", resp.Status))
+			c.forget(peer)
+			continue
+		}
+		if c.logger != nil {
+			c.logger.Info("fetching image from peer cache", "peer", peer, "digest", digest)
+		}
+		return resp.Body, peer, nil
+	}
+	return nil, "", fmt.Errorf("no cache sharing peer has %s", digest)
+}
+
+// advertises reports whether peer's digest list, fetched at most advertTTL
+// ago, includes digest. An unreachable peer advertises nothing.
+func (c *Client) advertises(ctx context.Context, peer, digest string) bool {
+	c.mu.Lock()
+	ad, ok := c.adverts[peer]
+	c.mu.Unlock()
+	if ok && c.now().Sub(ad.fetched) < advertTTL {
+		return ad.digests[digest]
+	}
+
+	ad = advert{digests: map[string]bool{}, fetched: c.now()}
+	list, err := c.fetchDigests(ctx, peer)
+	if err != nil {
+		c.warn("cache sharing peer unavailable", peer, digest, err)
+	}
+	for _, d := range list.Digests {
+		ad.digests[d] = true
+	}
+	c.mu.Lock()
+	c.adverts[peer] = ad
+	c.mu.Unlock()
+	return ad.digests[digest]
+}
+
+func (c *Client) fetchDigests(ctx context.Context, peer string) (DigestList, error) {
+	ctx, cancel := context.WithTimeout(ctx, advertTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, peer+DigestsPath, nil)
+	if err != nil {
+		return DigestList{}, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return DigestList{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return DigestList{}, fmt.Errorf("peer answered %s", resp.Status)
+	}
+	var list DigestList
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 8<<20)).Decode(&list); err != nil {
+		return DigestList{}, fmt.Errorf("decode digest list: %w", err)
+	}
+	return list, nil
+}
+
+// forget drops peer's digest list, so it is fetched again on next use.
+func (c *Client) forget(peer string) {
+	c.mu.Lock()
+	delete(c.adverts, peer)
+	c.mu.Unlock()
+}
+
+func (c *Client) warn(msg, peer, digest string, err error) {
+	if c.logger != nil {
+		c.logger.Warn(msg, "peer", peer, "digest", digest, "error", err)
+	}
+}
@@ -0,0 +1,172 @@
+// Package cachepeer shares the image cache between serve hosts. Each host
+// can serve its cached images to peers, and fetch images it lacks from a
+// peer instead of the registry. Peers authenticate each other with mutual
+// TLS.
+package cachepeer
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/imagemgr"
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// DigestsPath lists the digests a host has cached.
+	DigestsPath = "/v1/cache/digests"
+	// ArchivePathPrefix is followed by a digest and streams that image's
+	// cache archive, as written by imagemgr's Save.
+	ArchivePathPrefix = "/v1/cache/archives/"
+)
+
+// Cache is the part of the image manager a Server reads from.
+type Cache interface {
+	List(ctx context.Context) ([]imagemgr.Record, error)
+	Save(ctx context.Context, selectors []string, w io.Writer) ([]imagemgr.Record, error)
+}
+
+// DigestList is the body of DigestsPath.
+type DigestList struct {
+	Digests []string `json:"digests"`
+}
+
+type ServerConfig struct {
+	ListenAddr string
+	TLS        *tls.Config
+	Cache      Cache
+	Logger     *log.Logger
+}
+
+// Server serves a host's image cache to its peers.
+type Server struct {
+	cache      Cache
+	logger     *log.Logger
+	tls        *tls.Config
+	httpServer *http.Server
+
+	mu   sync.Mutex
+	addr string
+}
+
+func NewServer(cfg ServerConfig) *Server {
+	s := &Server{
+		cache:  cfg.Cache,
+		logger: cfg.Logger,
+		tls:    cfg.TLS,
+		addr:   cfg.ListenAddr,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+DigestsPath, s.handleDigests)
+	mux.HandleFunc("GET "+ArchivePathPrefix+"{digest}", s.handleArchive)
+	s.httpServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+// Start listens with mutual TLS and serves in the background.
+func (s *Server) Start() error {
+	if s.tls == nil || s.tls.ClientAuth != tls.RequireAndVerifyClientCert {
+		return errors.New("cache sharing requires mutual TLS")
+	}
+	ln, err := tls.Listen("tcp", s.addr, s.tls)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.addr = ln.Addr().String()
+	s.mu.Unlock()
+
+	go func() {
+		if err := s.httpServer.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) && s.logger != nil {
+			s.logger.Error("cache sharing server error", "error", err)
+		}
+	}()
+	if s.logger != nil {
+		s.logger.Info("cache sharing server started", "addr", s.Addr())
+	}
+	return nil
+}
+
+// Addr returns the listen address, resolved once started.
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addr
+}
+
+// Stop gracefully shuts down the server.
+func (s *Server) Stop(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
+
+func (s *Server) handleDigests(w http.ResponseWriter, r *http.Request) {
+	records, err := s.cache.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	list := DigestList{Digests: make([]string, 0, len(records))}
+	for _, record := range records {
+		list.Digests = append(list.Digests, record.Digest)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+func (s *Server) handleArchive(w http.ResponseWriter, r *http.Request) {
+	digest := strings.ToLower(r.PathValue("digest"))
+	if !strings.HasPrefix(digest, "sha256:") {
+		http.Error(w, "digest must be sha256:<hex>", http.StatusBadRequest)
+		return
+	}
+	records, err := s.cache.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	found := false
+	for _, record := range records {
+		found = found || record.Digest == digest
+	}
+	if !found {
+		http.Error(w, "digest is not cached", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zstd")
+	// A failure part way through leaves the archive without its trailing
+	// manifest, which the peer refuses.
+	if _, err := s.cache.Save(r.Context(), []string{digest}, w); err != nil && s.logger != nil {
+		s.logger.Warn("cache sharing archive failed", "digest", digest, "peer", peerName(r), "error", err)
+		return
+	}
+	if s.logger != nil {
+		s.logger.Debug("served cache archive", "digest", digest, "peer", peerName(r))
+	}
+}
+
+// peerName is the common name of the peer's client certificate, or its
+// address.
+func peerName(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		if cn := r.TLS.PeerCertificates[0].Subject.CommonName; cn != "" {
+			return cn
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
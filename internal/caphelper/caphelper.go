@@ -0,0 +1,122 @@
+// Package caphelper is the privileged component of privileged_mode caps.
+// A copy of the cleanroom binary installed as cleanroom-cap-helper, with
+// file capabilities instead of setuid root, runs the root helper script
+// with just those capabilities, so neither sudo nor a root serve process
+// is needed.
+package caphelper
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Name is the file name the cleanroom binary runs as the helper under.
+const Name = "cleanroom-cap-helper"
+
+const (
+	// DefaultPath is where the installer puts the helper.
+	DefaultPath = "/usr/local/sbin/" + Name
+	// ScriptPath is the root helper script the helper runs. It is fixed so
+	// that callers cannot point the helper's capabilities elsewhere.
+	ScriptPath = "/usr/local/sbin/cleanroom-root-helper"
+)
+
+// Capability is one Linux capability.
+type Capability struct {
+	Name string
+	Bit  uint
+}
+
+// Required lists the capabilities the helper is installed with: creating
+// TAP devices and firewall rules, loop-mounting rootfs images, and writing
+// root-owned files such as sysctls and, owned by root, the guest agent in a
+// mounted rootfs.
+var Required = []Capability{
+	{Name: "cap_chown", Bit: 0},
+	{Name: "cap_dac_override", Bit: 1},
+	{Name: "cap_fowner", Bit: 3},
+	{Name: "cap_net_admin", Bit: 12},
+	{Name: "cap_net_raw", Bit: 13},
+	{Name: "cap_sys_admin", Bit: 21},
+}
+
+// SetcapSpec returns Required in setcap(8) syntax.
+func SetcapSpec() string {
+	names := make([]string, len(Required))
+	for i, c := range Required {
+		names[i] = c.Name
+	}
+	return strings.Join(names, ",") + "+ep"
+}
+
+// Missing returns the names of the Required capabilities not in mask.
+func Missing(mask uint64) []string {
+	var missing []string
+	for _, c := range Required {
+		if mask&(1<<c.Bit) == 0 {
+			missing = append(missing, c.Name)
+		}
+	}
+	return missing
+}
+
+func requiredMask() uint64 {
+	var mask uint64
+	for _, c := range Required {
+		mask |= 1 << c.Bit
+	}
+	return mask
+}
+
+// File capabilities are stored in the security.capability extended
+// attribute as a vfs_cap_data: a revision and flags word, then permitted
+// and inheritable words for capabilities 0-31 and, from revision 2, 32-63.
+const (
+	capabilityXattr = "security.capability"
+
+	vfsCapRevisionMask = 0xff000000
+	vfsCapRevision1    = 0x01000000
+	vfsCapRevision2    = 0x02000000
+	vfsCapRevision3    = 0x03000000
+	vfsCapEffective    = 0x000001
+)
+
+// FileCaps are the capabilities granted by a file.
+type FileCaps struct {
+	Permitted uint64
+	Effective bool
+}
+
+func encodeFileCaps(permitted uint64) []byte {
+	data := binary.LittleEndian.AppendUint32(nil, vfsCapRevision2|vfsCapEffective)
+	data = binary.LittleEndian.AppendUint32(data, uint32(permitted))
+	data = binary.LittleEndian.AppendUint32(data, 0)
+	data = binary.LittleEndian.AppendUint32(data, uint32(permitted>>32))
+	return binary.LittleEndian.AppendUint32(data, 0)
+}
+
+func decodeFileCaps(data []byte) (FileCaps, error) {
+	if len(data) < 4 {
+		return FileCaps{}, errors.New("truncated file capabilities")
+	}
+	magic := binary.LittleEndian.Uint32(data)
+	words := 0
+	switch magic & vfsCapRevisionMask {
+	case vfsCapRevision1:
+		words = 1
+	case vfsCapRevision2, vfsCapRevision3:
+		words = 2
+	default:
+		return FileCaps{}, fmt.Errorf("unknown file capabilities revision %#x", magic&vfsCapRevisionMask)
+	}
+	if len(data) < 4+8*words {
+		return FileCaps{}, errors.New("truncated file capabilities")
+	}
+	caps := FileCaps{Effective: magic&vfsCapEffective != 0}
+	for i := 0; i < words; i++ {
+		caps.Permitted |= uint64(binary.LittleEndian.Uint32(data[4+8*i:])) << (32 * i)
+	}
+	return caps, nil
+}
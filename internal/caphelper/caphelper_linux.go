@@ -0,0 +1,139 @@
+//go:build linux
+
+package caphelper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Main runs the helper with args, the root helper script's arguments, and
+// returns its exit code. Other than mounts, which it does itself, it only
+// returns if the script could not be started.
+func Main(args []string) int {
+	if err := run(args); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", Name, err)
+		return 2
+	}
+	return 0
+}
+
+func run(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "mount":
+			return runMount(args[1:])
+		case "umount":
+			return runUmount(args[1:])
+		}
+	}
+	if err := checkScript(ScriptPath); err != nil {
+		return err
+	}
+	// Capabilities are per thread, and the exec must come from the thread
+	// that raised them.
+	runtime.LockOSThread()
+	if err := raiseAmbient(); err != nil {
+		return err
+	}
+	// The script and the tools it runs get a fixed environment: variables
+	// like BASH_ENV would otherwise run the caller's code with the
+	// capabilities.
+	env := []string{"PATH=/usr/sbin:/usr/bin:/sbin:/bin", "LC_ALL=C"}
+	err := unix.Exec(ScriptPath, append([]string{ScriptPath}, args...), env)
+	return fmt.Errorf("run %s: %w", ScriptPath, err)
+}
+
+// checkScript refuses a script that anyone but root could have changed.
+func checkScript(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !info.Mode().IsRegular() || !ok || st.Uid != 0 || info.Mode().Perm()&0o022 != 0 {
+		return fmt.Errorf("%s must be a regular file owned by root and writable only by root", path)
+	}
+	return nil
+}
+
+// raiseAmbient makes the Required capabilities ambient so they survive the
+// exec of the script, which has no file capabilities of its own.
+func raiseAmbient() error {
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return fmt.Errorf("read capabilities: %w", err)
+	}
+	permitted := uint64(data[0].Permitted) | uint64(data[1].Permitted)<<32
+	if missing := Missing(permitted); len(missing) > 0 {
+		return fmt.Errorf("missing capabilities %v; install the helper with sudo cleanroom install-cap-helper", missing)
+	}
+	for _, c := range Required {
+		data[c.Bit/32].Inheritable |= 1 << (c.Bit % 32)
+	}
+	if err := unix.Capset(&hdr, &data[0]); err != nil {
+		return fmt.Errorf("set inheritable capabilities: %w", err)
+	}
+	for _, c := range Required {
+		if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_RAISE, uintptr(c.Bit), 0, 0); err != nil {
+			return fmt.Errorf("raise ambient %s: %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
+// ReadFileCaps returns the file capabilities of path; a file without any
+// has none permitted.
+func ReadFileCaps(path string) (FileCaps, error) {
+	buf := make([]byte, 64)
+	n, err := unix.Getxattr(path, capabilityXattr, buf)
+	if errors.Is(err, unix.ENODATA) {
+		return FileCaps{}, nil
+	}
+	if err != nil {
+		return FileCaps{}, err
+	}
+	return decodeFileCaps(buf[:n])
+}
+
+// Install copies the executable at src to dst, owned by root and group
+// gid, and grants it the Required capabilities. Only root and members of
+// gid can run it. It must run as root.
+func Install(src, dst string, gid int) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if _, err := io.Copy(tmp, in); err != nil {
+		return err
+	}
+	// Changing the owner clears file capabilities, so set them last.
+	if err := tmp.Chown(0, gid); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0o750); err != nil {
+		return err
+	}
+	if err := unix.Fsetxattr(int(tmp.Fd()), capabilityXattr, encodeFileCaps(requiredMask()), 0); err != nil {
+		return fmt.Errorf("set file capabilities: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
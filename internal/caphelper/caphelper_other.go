@@ -0,0 +1,27 @@
+//go:build !linux
+
+package caphelper
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+var errUnsupported = errors.New("file capabilities are linux-only")
+
+// Main reports that the helper only runs on Linux.
+func Main([]string) int {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", Name, errUnsupported)
+	return 2
+}
+
+// ReadFileCaps reports that file capabilities are linux-only.
+func ReadFileCaps(string) (FileCaps, error) {
+	return FileCaps{}, errUnsupported
+}
+
+// Install reports that file capabilities are linux-only.
+func Install(string, string, int) error {
+	return errUnsupported
+}
@@ -0,0 +1,42 @@
+package caphelper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFileCapsRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	caps, err := decodeFileCaps(encodeFileCaps(requiredMask()))
+	if err != nil {
+		t.Fatalf("decodeFileCaps: %v", err)
+	}
+	if !caps.Effective || len(Missing(caps.Permitted)) != 0 {
+		t.Fatalf("unexpected caps %+v", caps)
+	}
+
+	// setcap cap_net_admin+ep writes a revision 2 attribute.
+	rev2 := []byte{0x01, 0x00, 0x00, 0x02, 0x00, 0x10, 0x00, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	caps, err = decodeFileCaps(rev2)
+	if err != nil {
+		t.Fatalf("decodeFileCaps: %v", err)
+	}
+	if got, want := Missing(caps.Permitted), []string{"cap_chown", "cap_dac_override", "cap_fowner", "cap_net_raw", "cap_sys_admin"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Missing = %v, want %v", got, want)
+	}
+
+	for _, bad := range [][]byte{nil, {0, 0, 0, 0x09}, rev2[:8]} {
+		if _, err := decodeFileCaps(bad); err == nil {
+			t.Fatalf("expected an error decoding %x", bad)
+		}
+	}
+}
+
+func TestSetcapSpec(t *testing.T) {
+	t.Parallel()
+
+	if got := SetcapSpec(); got != "cap_chown,cap_dac_override,cap_fowner,cap_net_admin,cap_net_raw,cap_sys_admin+ep" {
+		t.Fatalf("SetcapSpec = %q", got)
+	}
+}
@@ -0,0 +1,171 @@
+//go:build linux
+
+package caphelper
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/paths"
+	"golang.org/x/sys/unix"
+)
+
+// mount(8) refuses options to callers whose user ID is not root, even with
+// CAP_SYS_ADMIN, so the helper loop-mounts rootfs images itself. The paths
+// are held to what the root helper script allows, and both are opened
+// without following symlinks and must belong to the caller.
+const (
+	mountTmpDir     = "/tmp"
+	mountDirPattern = mountTmpDir + "/cleanroom-firecracker-rootfs-*"
+)
+
+// runMount handles "mount -o loop <image> <dir>".
+func runMount(args []string) error {
+	if len(args) != 4 || args[0] != "-o" || args[1] != "loop" {
+		return errors.New("mount: expected '-o loop <image> <mount-dir>'")
+	}
+	image, dir := args[2], args[3]
+	cacheRoot, err := paths.CacheBaseDir()
+	if err != nil {
+		return fmt.Errorf("mount: %w", err)
+	}
+	backing, err := openImage(image, cacheRoot, os.Getuid())
+	if err != nil {
+		return fmt.Errorf("mount: %w", err)
+	}
+	defer backing.Close()
+	target, err := openMountDir(dir, os.Getuid())
+	if err != nil {
+		return fmt.Errorf("mount: %w", err)
+	}
+	defer unix.Close(target)
+	device, err := attachLoop(backing)
+	if err != nil {
+		return fmt.Errorf("mount: %w", err)
+	}
+	// Mounting through the descriptor keeps the checked directory from
+	// being swapped for a symlink in between.
+	if err := unix.Mount(device, fmt.Sprintf("/proc/self/fd/%d", target), "ext4", unix.MS_NOSUID|unix.MS_NODEV, ""); err != nil {
+		return fmt.Errorf("mount %s on %s: %w", device, dir, err)
+	}
+	return nil
+}
+
+// runUmount handles "umount <dir>". The loop device clears itself once
+// the filesystem is unmounted.
+func runUmount(args []string) error {
+	if len(args) != 1 {
+		return errors.New("umount: expected '<mount-dir>'")
+	}
+	if !isMountDir(args[0]) {
+		return errors.New("umount: unsupported mount path")
+	}
+	// The mounted root hides the directory's own owner, so only refuse
+	// symlinks here.
+	return unix.Unmount(args[0], unix.UMOUNT_NOFOLLOW)
+}
+
+func isMountDir(path string) bool {
+	ok, _ := filepath.Match(mountDirPattern, path)
+	return ok
+}
+
+// openMountDir opens the mount directory under /tmp without following a
+// symlink and checks it is a directory owned by uid. The returned
+// descriptor is opened O_PATH, only good for naming the directory.
+func openMountDir(path string, uid int) (int, error) {
+	if !isMountDir(path) {
+		return -1, errors.New("unsupported mount path")
+	}
+	tmp, err := unix.Open(mountTmpDir, unix.O_PATH|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, fmt.Errorf("open %s: %w", mountTmpDir, err)
+	}
+	defer unix.Close(tmp)
+	fd, err := unix.Openat(tmp, filepath.Base(path), unix.O_PATH|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, fmt.Errorf("open %s: %w", path, err)
+	}
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		unix.Close(fd)
+		return -1, fmt.Errorf("stat %s: %w", path, err)
+	}
+	if int(st.Uid) != uid {
+		unix.Close(fd)
+		return -1, fmt.Errorf("%s is not owned by the caller", path)
+	}
+	return fd, nil
+}
+
+// isRuntimeRootFSTmp matches the temporary images runtime rootfs
+// preparation writes, firecracker/runtime-rootfs/<key>.ext4.tmp-<n> under
+// cacheRoot.
+func isRuntimeRootFSTmp(path, cacheRoot string) bool {
+	if !filepath.IsAbs(path) || filepath.Clean(path) != path || !filepath.IsAbs(cacheRoot) {
+		return false
+	}
+	dir, name := filepath.Split(path)
+	return strings.Contains(name, ".ext4.tmp-") && dir == filepath.Join(cacheRoot, "firecracker", "runtime-rootfs")+"/"
+}
+
+// openImage opens a runtime rootfs image under cacheRoot without following
+// a symlink and checks it is a regular file owned by uid.
+func openImage(path, cacheRoot string, uid int) (*os.File, error) {
+	if !isRuntimeRootFSTmp(path, cacheRoot) {
+		return nil, errors.New("unsupported image path")
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|unix.O_NOFOLLOW, 0)
+	if err != nil {
+		return nil, err
+	}
+	var st unix.Stat_t
+	if err := unix.Fstat(int(f.Fd()), &st); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("stat %s: %w", path, err)
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFREG || int(st.Uid) != uid {
+		f.Close()
+		return nil, fmt.Errorf("%s is not a regular file owned by the caller", path)
+	}
+	return f, nil
+}
+
+// attachLoop backs a free loop device with backing and returns its path.
+func attachLoop(backing *os.File) (string, error) {
+	control, err := os.OpenFile("/dev/loop-control", os.O_RDWR, 0)
+	if err != nil {
+		return "", err
+	}
+	defer control.Close()
+
+	// Another process can take the free device first; try again then.
+	for attempt := 0; attempt < 5; attempt++ {
+		n, err := unix.IoctlRetInt(int(control.Fd()), unix.LOOP_CTL_GET_FREE)
+		if err != nil {
+			return "", fmt.Errorf("find a free loop device: %w", err)
+		}
+		device := fmt.Sprintf("/dev/loop%d", n)
+		loop, err := os.OpenFile(device, os.O_RDWR, 0)
+		if err != nil {
+			return "", err
+		}
+		err = unix.IoctlSetInt(int(loop.Fd()), unix.LOOP_SET_FD, int(backing.Fd()))
+		if errors.Is(err, unix.EBUSY) {
+			loop.Close()
+			continue
+		}
+		if err == nil {
+			err = unix.IoctlLoopSetStatus64(int(loop.Fd()), &unix.LoopInfo64{Flags: unix.LO_FLAGS_AUTOCLEAR})
+		}
+		loop.Close()
+		if err != nil {
+			return "", fmt.Errorf("attach %s: %w", device, err)
+		}
+		return device, nil
+	}
+	return "", errors.New("no free loop device")
+}
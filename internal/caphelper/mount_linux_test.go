@@ -0,0 +1,103 @@
+package caphelper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestMountPaths(t *testing.T) {
+	t.Parallel()
+
+	const cacheRoot = "/home/ci/.cache/cleanroom"
+	for path, want := range map[string]bool{
+		"/home/ci/.cache/cleanroom/firecracker/runtime-rootfs/abc.ext4.tmp-123":  true,
+		"/home/ci/.cache/cleanroom/firecracker/runtime-rootfs/abc.ext4":          false,
+		"/home/ci/.cache/cleanroom/firecracker/other/abc.ext4.tmp-123":           false,
+		"/home/ci/../../etc/firecracker/runtime-rootfs/abc.ext4.tmp-1":           false,
+		"/home/other/.cache/cleanroom/firecracker/runtime-rootfs/abc.ext4.tmp-1": false,
+		"/etc/firecracker/runtime-rootfs/abc.ext4.tmp-1":                         false,
+		"relative/firecracker/runtime-rootfs/abc.ext4.tmp-1":                     false,
+	} {
+		if got := isRuntimeRootFSTmp(path, cacheRoot); got != want {
+			t.Fatalf("isRuntimeRootFSTmp(%q) = %v, want %v", path, got, want)
+		}
+	}
+	for path, want := range map[string]bool{
+		"/tmp/cleanroom-firecracker-rootfs-123":     true,
+		"/tmp/cleanroom-firecracker-rootfs-123/etc": false,
+		"/var/tmp/cleanroom-firecracker-rootfs-1":   false,
+	} {
+		if got := isMountDir(path); got != want {
+			t.Fatalf("isMountDir(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestOpenMountDirRejectsSymlinksAndForeignOwners(t *testing.T) {
+	t.Parallel()
+
+	dir, err := os.MkdirTemp(mountTmpDir, "cleanroom-firecracker-rootfs-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(dir) })
+	fd, err := openMountDir(dir, os.Getuid())
+	if err != nil {
+		t.Fatalf("openMountDir(%q): %v", dir, err)
+	}
+	unix.Close(fd)
+	if _, err := openMountDir(dir, os.Getuid()+1); err == nil {
+		t.Fatalf("openMountDir accepted a directory owned by another user")
+	}
+
+	link := dir + "-link"
+	if err := os.Symlink(t.TempDir(), link); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(link) })
+	if fd, err := openMountDir(link, os.Getuid()); err == nil {
+		unix.Close(fd)
+		t.Fatalf("openMountDir followed the symlink %q", link)
+	}
+}
+
+func TestOpenImageRejectsSymlinksAndForeignPaths(t *testing.T) {
+	t.Parallel()
+
+	cacheRoot := t.TempDir()
+	imageDir := filepath.Join(cacheRoot, "firecracker", "runtime-rootfs")
+	if err := os.MkdirAll(imageDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	image := filepath.Join(imageDir, "abc.ext4.tmp-1")
+	if err := os.WriteFile(image, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := openImage(image, cacheRoot, os.Getuid())
+	if err != nil {
+		t.Fatalf("openImage(%q): %v", image, err)
+	}
+	f.Close()
+	if _, err := openImage(image, cacheRoot, os.Getuid()+1); err == nil {
+		t.Fatalf("openImage accepted an image owned by another user")
+	}
+	if _, err := openImage(image, t.TempDir(), os.Getuid()); err == nil {
+		t.Fatalf("openImage accepted an image outside the cache root")
+	}
+
+	target := filepath.Join(t.TempDir(), "passwd")
+	if err := os.WriteFile(target, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(imageDir, "def.ext4.tmp-2")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+	if f, err := openImage(link, cacheRoot, os.Getuid()); err == nil {
+		f.Close()
+		t.Fatalf("openImage followed the symlink %q", link)
+	}
+}
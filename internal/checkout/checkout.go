@@ -0,0 +1,165 @@
+// Package checkout fetches a git ref on the host and packs its tree as a tar
+// archive for streaming into a sandbox, so repository credentials never
+// reach the guest.
+package checkout
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultPath is where a checkout lands in the guest when no path is given.
+const DefaultPath = "/workspace"
+
+// CredentialResolver returns the token to present to a git host, or "" for
+// anonymous access. gateway.CredentialProvider satisfies it.
+type CredentialResolver interface {
+	Resolve(ctx context.Context, upstreamHost string) (string, error)
+}
+
+// Fetcher runs git on the host.
+type Fetcher struct {
+	Credentials CredentialResolver
+	// GitBinary defaults to git on PATH.
+	GitBinary string
+	// TempDir holds the scratch repository and archive; "" uses os.TempDir.
+	TempDir string
+}
+
+// Archive is a fetched ref packed as an uncompressed tar of its tree.
+type Archive struct {
+	Commit string
+	Path   string
+	dir    string
+}
+
+// Close removes the archive and the scratch repository it came from.
+func (a *Archive) Close() error {
+	return os.RemoveAll(a.dir)
+}
+
+// RepositoryHost validates a checkout repository URL and returns its host.
+// Only https URLs are accepted so the host is what sandbox policy names.
+func RepositoryHost(repository string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(repository))
+	if err != nil {
+		return "", fmt.Errorf("invalid checkout repository: %w", err)
+	}
+	if u.Scheme != "https" || u.Host == "" || u.Path == "" || u.Path == "/" {
+		return "", fmt.Errorf("invalid checkout repository %q: expected https://<host>/<path>", repository)
+	}
+	if u.User != nil {
+		return "", fmt.Errorf("invalid checkout repository %q: credentials in the URL are not allowed", repository)
+	}
+	return strings.ToLower(u.Hostname()), nil
+}
+
+// Fetch shallow-fetches ref from repository and archives its tree. ref may
+// be a branch, tag or commit SHA; "" fetches the remote's HEAD.
+func (f *Fetcher) Fetch(ctx context.Context, repository, ref string) (*Archive, error) {
+	if strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("invalid checkout ref %q", ref)
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	dir, err := os.MkdirTemp(f.TempDir, "cleanroom-checkout-*")
+	if err != nil {
+		return nil, err
+	}
+	archive := &Archive{Path: filepath.Join(dir, "tree.tar"), dir: dir}
+	repo := filepath.Join(dir, "repo.git")
+
+	env, err := f.gitEnv(ctx, repository)
+	if err != nil {
+		_ = archive.Close()
+		return nil, err
+	}
+	steps := [][]string{
+		{"init", "--quiet", "--bare", repo},
+		{"-C", repo, "fetch", "--quiet", "--depth=1", "--no-tags", "--", repository, ref},
+	}
+	for _, args := range steps {
+		if _, err := f.git(ctx, env, args...); err != nil {
+			_ = archive.Close()
+			return nil, err
+		}
+	}
+	commit, err := f.git(ctx, env, "-C", repo, "rev-parse", "--verify", "FETCH_HEAD^{commit}")
+	if err != nil {
+		_ = archive.Close()
+		return nil, err
+	}
+	if _, err := f.git(ctx, env, "-C", repo, "archive", "--format=tar", "--output", archive.Path, commit); err != nil {
+		_ = archive.Close()
+		return nil, err
+	}
+	archive.Commit = commit
+	return archive, nil
+}
+
+// gitEnv isolates git from the host's configuration and, when a credential
+// is configured for the repository's host, adds it as a header scoped to
+// that host. Passing it through the environment keeps it out of argv.
+func (f *Fetcher) gitEnv(ctx context.Context, repository string) ([]string, error) {
+	env := append(os.Environ(),
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_CONFIG_GLOBAL="+os.DevNull,
+	)
+	u, err := url.Parse(repository)
+	if err != nil || f.Credentials == nil || u.Scheme != "https" {
+		return env, nil
+	}
+	token, err := f.Credentials.Resolve(ctx, u.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("resolve credentials for %s: %w", u.Hostname(), err)
+	}
+	if token == "" {
+		return env, nil
+	}
+	return append(env,
+		"GIT_CONFIG_COUNT=1",
+		fmt.Sprintf("GIT_CONFIG_KEY_0=http.https://%s/.extraHeader", u.Host),
+		"GIT_CONFIG_VALUE_0=Authorization: Bearer "+token,
+	), nil
+}
+
+func (f *Fetcher) git(ctx context.Context, env []string, args ...string) (string, error) {
+	bin := f.GitBinary
+	if bin == "" {
+		bin = "git"
+	}
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Env = env
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", errors.New("git " + gitSubcommand(args) + ": " + msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func gitSubcommand(args []string) string {
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-C" {
+			i++
+			continue
+		}
+		return args[i]
+	}
+	return ""
+}
@@ -0,0 +1,270 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/controlclient"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+type ApprovalCommand struct {
+	List    ApprovalListCommand    `name:"ls" aliases:"list" cmd:"" help:"List executions waiting for approval"`
+	Approve ApprovalApproveCommand `cmd:"" help:"Approve a pending execution"`
+	Deny    ApprovalDenyCommand    `cmd:"" help:"Deny a pending execution"`
+	Watch   ApprovalWatchCommand   `cmd:"" help:"Prompt for each execution as it starts waiting for approval"`
+}
+
+type ApprovalListCommand struct {
+	clientFlags
+	JSON bool `help:"Print pending approvals as JSON"`
+}
+
+type approvalDecisionArgs struct {
+	clientFlags
+	SandboxID   string `arg:"" completion:"sandbox" help:"Sandbox ID"`
+	ExecutionID string `arg:"" help:"Execution ID"`
+	Reason      string `help:"Reason recorded with the decision"`
+}
+
+type ApprovalApproveCommand struct {
+	approvalDecisionArgs
+}
+
+type ApprovalDenyCommand struct {
+	approvalDecisionArgs
+}
+
+type ApprovalWatchCommand struct {
+	clientFlags
+	Interval time.Duration `default:"2s" help:"How often to poll for new pending executions"`
+}
+
+func (c *ApprovalListCommand) Run(ctx *runtimeContext) error {
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	resp, err := client.ListPendingApprovals(ctx.commandContext(), &cleanroomv1.ListPendingApprovalsRequest{})
+	if err != nil {
+		return err
+	}
+
+	if c.JSON {
+		enc := json.NewEncoder(ctx.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(resp.GetApprovals())
+	}
+	if len(resp.GetApprovals()) == 0 {
+		_, err := fmt.Fprintln(ctx.Stdout, "no executions pending approval")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(ctx.Stdout, 0, 2, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "SANDBOX\tEXECUTION\tREQUESTED BY\tRULES\tCOMMAND"); err != nil {
+		return err
+	}
+	for _, pending := range resp.GetApprovals() {
+		ex := pending.GetExecution()
+		approval := ex.GetApproval()
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			ex.GetSandboxId(),
+			ex.GetExecutionId(),
+			approval.GetRequestedBy(),
+			strings.Join(approval.GetRules(), ", "),
+			strings.Join(ex.GetCommand(), " "),
+		); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+func (c *ApprovalApproveCommand) Run(ctx *runtimeContext) error {
+	return c.resolve(ctx, true)
+}
+
+func (c *ApprovalDenyCommand) Run(ctx *runtimeContext) error {
+	return c.resolve(ctx, false)
+}
+
+func (c *approvalDecisionArgs) resolve(ctx *runtimeContext, approve bool) error {
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	resp, err := client.ResolveExecutionApproval(ctx.commandContext(), &cleanroomv1.ResolveExecutionApprovalRequest{
+		SandboxId:   c.SandboxID,
+		ExecutionId: c.ExecutionID,
+		Approve:     approve,
+		Reason:      c.Reason,
+	})
+	if err != nil {
+		return err
+	}
+	verb := "denied"
+	if approve {
+		verb = "approved"
+	}
+	_, err = fmt.Fprintf(ctx.Stdout, "%s execution %s\n", verb, resp.GetExecution().GetExecutionId())
+	return err
+}
+
+func (c *ApprovalWatchCommand) Run(ctx *runtimeContext) error {
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	interval := c.Interval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	prompted := map[string]bool{}
+	fmt.Fprintln(os.Stderr, "watching for executions pending approval (Ctrl-C to stop)")
+	for {
+		resp, err := client.ListPendingApprovals(ctx.commandContext(), &cleanroomv1.ListPendingApprovalsRequest{})
+		if err != nil {
+			return err
+		}
+		for _, pending := range resp.GetApprovals() {
+			ex := pending.GetExecution()
+			key := ex.GetSandboxId() + "/" + ex.GetExecutionId()
+			if prompted[key] {
+				continue
+			}
+			prompted[key] = true
+
+			writeApprovalSummary(os.Stderr, pending)
+			approve, reason, err := promptApproval(reader, os.Stderr)
+			if err != nil {
+				return err
+			}
+			_, err = client.ResolveExecutionApproval(ctx.commandContext(), &cleanroomv1.ResolveExecutionApprovalRequest{
+				SandboxId:   ex.GetSandboxId(),
+				ExecutionId: ex.GetExecutionId(),
+				Approve:     approve,
+				Reason:      reason,
+			})
+			if err != nil {
+				// Another approver or the timeout may have got there first.
+				fmt.Fprintf(os.Stderr, "resolve %s: %v\n", key, err)
+				continue
+			}
+			verb := "denied"
+			if approve {
+				verb = "approved"
+			}
+			if _, err := fmt.Fprintf(ctx.Stdout, "%s %s\n", verb, key); err != nil {
+				return err
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+// writeApprovalSummary prints what a pending execution will run and the
+// policy it runs under.
+func writeApprovalSummary(out io.Writer, pending *cleanroomv1.PendingApproval) {
+	ex := pending.GetExecution()
+	sb := pending.GetSandbox()
+	approval := ex.GetApproval()
+
+	fmt.Fprintf(out, "\nexecution %s in sandbox %s", ex.GetExecutionId(), ex.GetSandboxId())
+	if sb.GetName() != "" {
+		fmt.Fprintf(out, " (%s)", sb.GetName())
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "  command:      %s\n", strings.Join(ex.GetCommand(), " "))
+	fmt.Fprintf(out, "  requested by: %s\n", approval.GetRequestedBy())
+	fmt.Fprintf(out, "  rules:        %s\n", strings.Join(approval.GetRules(), ", "))
+	if len(sb.GetLabels()) > 0 {
+		labels := make([]string, 0, len(sb.GetLabels()))
+		for key, value := range sb.GetLabels() {
+			labels = append(labels, key+"="+value)
+		}
+		sort.Strings(labels)
+		fmt.Fprintf(out, "  labels:       %s\n", strings.Join(labels, ", "))
+	}
+	fmt.Fprintf(out, "  image:        %s\n", pending.GetImageRef())
+	fmt.Fprintf(out, "  policy:       %s (network %s)\n", sb.GetPolicyHash(), pending.GetNetworkDefault())
+	if len(pending.GetAllowedHosts()) > 0 {
+		fmt.Fprintf(out, "  allowed:      %s\n", strings.Join(pending.GetAllowedHosts(), ", "))
+	}
+}
+
+// promptApproval asks whether to approve. Anything other than y or yes
+// denies; after a denial it asks for an optional reason.
+func promptApproval(reader *bufio.Reader, out io.Writer) (bool, string, error) {
+	fmt.Fprint(out, "Approve? [y/N] ")
+	answer, err := reader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, "", err
+	}
+	if errors.Is(err, io.EOF) && strings.TrimSpace(answer) == "" {
+		return false, "", errors.New("stdin closed while waiting for an approval decision")
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, "", nil
+	}
+	fmt.Fprint(out, "Reason (optional): ")
+	reason, err := reader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, "", err
+	}
+	return false, strings.TrimSpace(reason), nil
+}
+
+const approvalPollInterval = time.Second
+
+// waitForExecutionApproval blocks while a just-created execution is held
+// for approval, so exec and console only attach once it may run.
+func waitForExecutionApproval(ctx context.Context, client *controlclient.Client, execution *cleanroomv1.Execution, notice io.Writer) error {
+	if execution.GetStatus() != cleanroomv1.ExecutionStatus_EXECUTION_STATUS_PENDING_APPROVAL {
+		return nil
+	}
+	fmt.Fprintf(notice, "execution %s is waiting for approval (%s); approve it with: cleanroom approval approve %s %s\n",
+		execution.GetExecutionId(),
+		strings.Join(execution.GetApproval().GetRules(), ", "),
+		execution.GetSandboxId(),
+		execution.GetExecutionId(),
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(approvalPollInterval):
+		}
+		resp, err := client.GetExecution(ctx, &cleanroomv1.GetExecutionRequest{
+			SandboxId:   execution.GetSandboxId(),
+			ExecutionId: execution.GetExecutionId(),
+		})
+		if err != nil {
+			return fmt.Errorf("wait for approval: %w", err)
+		}
+		current := resp.GetExecution()
+		if current.GetStatus() == cleanroomv1.ExecutionStatus_EXECUTION_STATUS_PENDING_APPROVAL {
+			continue
+		}
+		approval := current.GetApproval()
+		if approval.GetDecided() && !approval.GetApproved() {
+			msg := "execution denied by " + approval.GetDecidedBy()
+			if approval.GetReason() != "" {
+				msg += ": " + approval.GetReason()
+			}
+			return errors.New(msg)
+		}
+		return nil
+	}
+}
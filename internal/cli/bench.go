@@ -0,0 +1,249 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/paths"
+	"github.com/buildkite/cleanroom/internal/rundir"
+)
+
+type BenchCommand struct {
+	Latency BenchLatencyCommand `cmd:"" default:"withargs" help:"Measure sandbox boot and exec latency (default)"`
+	Soak    BenchSoakCommand    `cmd:"" help:"Drive many concurrent sandboxes with injected faults and check for leaks"`
+}
+
+type BenchLatencyCommand struct {
+	Chdir      string `short:"c" help:"Change to this directory before running commands"`
+	Backend    string `help:"Execution backend to benchmark (defaults to runtime config or host default)"`
+	Image      string `help:"Override sandbox image ref (tag, digest, or local Docker image)"`
+	Iterations int    `default:"10" help:"Number of sandboxes to boot"`
+	Output     string `short:"o" help:"Write the JSON report to this file"`
+	JSON       bool   `help:"Print the report as JSON instead of a table"`
+	KeepRuns   bool   `name:"keep-runs" help:"Keep per-iteration run directories instead of removing them"`
+}
+
+// benchReport is the JSON written by cleanroom bench. Field names are part of
+// the file format used to compare results across releases.
+type benchReport struct {
+	RecordedAt  time.Time                  `json:"recorded_at"`
+	Backend     string                     `json:"backend"`
+	ImageRef    string                     `json:"image_ref,omitempty"`
+	ImageDigest string                     `json:"image_digest,omitempty"`
+	Host        benchHost                  `json:"host"`
+	Iterations  int                        `json:"iterations"`
+	Phases      map[string]benchPhaseStats `json:"phases"`
+	Runs        []benchRun                 `json:"runs"`
+}
+
+type benchHost struct {
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+	CPUs int    `json:"cpus"`
+}
+
+type benchRun struct {
+	RunID    string           `json:"run_id"`
+	PhasesMS map[string]int64 `json:"phases_ms"`
+}
+
+type benchPhaseStats struct {
+	Samples int   `json:"samples"`
+	P50MS   int64 `json:"p50_ms"`
+	P95MS   int64 `json:"p95_ms"`
+	MinMS   int64 `json:"min_ms"`
+	MaxMS   int64 `json:"max_ms"`
+}
+
+// benchPhaseOrder lists the phases shown first in the table; any other
+// measured phases follow in name order.
+var benchPhaseOrder = []string{"network_setup", "rootfs_copy", "firecracker_start", "socket_ready", "vm_ready", "vsock_wait", "guest_exec", "cleanup", "total"}
+
+func (b *BenchLatencyCommand) Run(ctx *runtimeContext) error {
+	if b.Iterations <= 0 {
+		return errors.New("--iterations must be positive")
+	}
+	cwd, err := resolveCWD(ctx.CWD, b.Chdir)
+	if err != nil {
+		return err
+	}
+	backendName := resolveBackendName(b.Backend, ctx.Config.DefaultBackend)
+	adapter, ok := ctx.Backends[backendName]
+	if !ok {
+		return fmt.Errorf("unknown backend %q", backendName)
+	}
+	compiled, _, err := ctx.Loader.LoadAndCompile(cwd)
+	if err != nil {
+		return err
+	}
+	compiled, err = overrideCompiledPolicyImage(ctx.commandContext(), compiled, b.Image, true)
+	if err != nil {
+		return err
+	}
+	baseDir, err := paths.RunBaseDir()
+	if err != nil {
+		return fmt.Errorf("resolve run base directory: %w", err)
+	}
+
+	report := benchReport{
+		RecordedAt: time.Now().UTC(),
+		Backend:    backendName,
+		Host: benchHost{
+			OS:   runtime.GOOS,
+			Arch: runtime.GOARCH,
+			CPUs: runtime.NumCPU(),
+		},
+		Iterations: b.Iterations,
+	}
+	stamp := report.RecordedAt.Format("20060102T150405")
+	for i := 0; i < b.Iterations; i++ {
+		runID := fmt.Sprintf("bench-%s-%03d", stamp, i+1)
+		runDir := filepath.Join(baseDir, runID)
+		cfg := mergeBackendConfig(backendName, 0, ctx.Config)
+		cfg.RunDir = runDir
+
+		result, err := adapter.Run(context.Background(), backend.RunRequest{
+			RunID:             runID,
+			Command:           []string{"true"},
+			Policy:            compiled,
+			FirecrackerConfig: cfg,
+		})
+		if err != nil {
+			return fmt.Errorf("bench iteration %d: %w (run dir %s)", i+1, err, runDir)
+		}
+		if result.ExitCode != 0 {
+			return fmt.Errorf("bench iteration %d: command exited %d (run dir %s)", i+1, result.ExitCode, runDir)
+		}
+		report.ImageRef = result.ImageRef
+		report.ImageDigest = result.ImageDigest
+
+		phases, err := readBenchPhases(runDir)
+		if err != nil {
+			return fmt.Errorf("bench iteration %d: %w", i+1, err)
+		}
+		report.Runs = append(report.Runs, benchRun{RunID: runID, PhasesMS: phases})
+		if !b.KeepRuns {
+			_ = os.RemoveAll(runDir)
+		}
+	}
+	report.Phases = summarizeBenchRuns(report.Runs)
+
+	if b.Output != "" {
+		raw, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(b.Output, append(raw, '\n'), 0o644); err != nil {
+			return fmt.Errorf("write bench report: %w", err)
+		}
+	}
+	if b.JSON {
+		enc := json.NewEncoder(ctx.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	return writeBenchTable(ctx.Stdout, report)
+}
+
+// readBenchPhases extracts the *_ms timings from a run's observability file.
+func readBenchPhases(runDir string) (map[string]int64, error) {
+	obsPath := filepath.Join(runDir, rundir.ObservabilityFile)
+	raw, err := os.ReadFile(obsPath)
+	if err != nil {
+		return nil, fmt.Errorf("read run observability: %w", err)
+	}
+	var obs map[string]any
+	if err := json.Unmarshal(raw, &obs); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", obsPath, err)
+	}
+	phases := map[string]int64{}
+	for key, value := range obs {
+		name, ok := strings.CutSuffix(key, "_ms")
+		if !ok {
+			continue
+		}
+		if ms, ok := value.(float64); ok {
+			phases[name] = int64(ms)
+		}
+	}
+	return phases, nil
+}
+
+func summarizeBenchRuns(runs []benchRun) map[string]benchPhaseStats {
+	samples := map[string][]int64{}
+	for _, run := range runs {
+		for phase, ms := range run.PhasesMS {
+			samples[phase] = append(samples[phase], ms)
+		}
+	}
+	stats := make(map[string]benchPhaseStats, len(samples))
+	for phase, values := range samples {
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+		stats[phase] = benchPhaseStats{
+			Samples: len(values),
+			P50MS:   percentile(values, 50),
+			P95MS:   percentile(values, 95),
+			MinMS:   values[0],
+			MaxMS:   values[len(values)-1],
+		}
+	}
+	return stats
+}
+
+// percentile returns the nearest-rank percentile of sorted values.
+func percentile(sorted []int64, p float64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+func orderedBenchPhases(stats map[string]benchPhaseStats) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, phase := range benchPhaseOrder {
+		if _, ok := stats[phase]; ok {
+			out = append(out, phase)
+			seen[phase] = true
+		}
+	}
+	var rest []string
+	for phase := range stats {
+		if !seen[phase] {
+			rest = append(rest, phase)
+		}
+	}
+	sort.Strings(rest)
+	return append(out, rest...)
+}
+
+func writeBenchTable(stdout *os.File, report benchReport) error {
+	if _, err := fmt.Fprintf(stdout, "bench (%s, %d iterations)\n", report.Backend, report.Iterations); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "PHASE\tP50\tP95\tMIN\tMAX")
+	for _, phase := range orderedBenchPhases(report.Phases) {
+		s := report.Phases[phase]
+		fmt.Fprintf(tw, "%s\t%dms\t%dms\t%dms\t%dms\n", phase, s.P50MS, s.P95MS, s.MinMS, s.MaxMS)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,474 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/controlclient"
+	"github.com/buildkite/cleanroom/internal/controlserver"
+	"github.com/buildkite/cleanroom/internal/controlservice"
+	"github.com/buildkite/cleanroom/internal/endpoint"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/policy"
+)
+
+const (
+	soakResourceGoroutines = "goroutines"
+	soakExecutionTimeout   = 2 * time.Minute
+	soakFaultMaxDelay      = 500 * time.Millisecond
+	soakMaxReportedErrors  = 20
+)
+
+type BenchSoakCommand struct {
+	clientFlags
+	Chdir          string        `short:"c" help:"Change to this directory before running commands"`
+	Backend        string        `help:"Execution backend (defaults to runtime config or host default)"`
+	Image          string        `help:"Override sandbox image ref (tag, digest, or local Docker image)"`
+	InProcess      bool          `name:"in-process" help:"Run the control service inside this process instead of connecting to --host; enables the goroutine leak check"`
+	Sandboxes      int           `default:"100" help:"Number of sandboxes to run concurrently"`
+	Executions     int           `default:"5" help:"Executions to run in each sandbox"`
+	CancelRate     float64       `name:"cancel-rate" default:"0.2" help:"Fraction of executions cancelled at a random point"`
+	DisconnectRate float64       `name:"disconnect-rate" default:"0.2" help:"Fraction of executions whose output stream is dropped at a random point"`
+	Seed           int64         `help:"Seed for fault injection (defaults to the current time)"`
+	Settle         time.Duration `default:"30s" help:"How long to wait for resources to be released after the run"`
+	GoroutineSlack int           `name:"goroutine-slack" default:"10" help:"Extra goroutines tolerated after the run before reporting a leak"`
+	JSON           bool          `help:"Print the report as JSON instead of a table"`
+	Command        []string      `arg:"" passthrough:"" optional:"" help:"Command to run in each execution (default: sh -c 'sleep 1')"`
+}
+
+// soakReport is the JSON printed by cleanroom bench soak.
+type soakReport struct {
+	Backend        string                       `json:"backend"`
+	Seed           int64                        `json:"seed"`
+	Sandboxes      int                          `json:"sandboxes"`
+	Executions     int                          `json:"executions"`
+	DurationMS     int64                        `json:"duration_ms"`
+	Outcomes       map[string]int               `json:"outcomes"`
+	Faults         map[string]int               `json:"faults"`
+	Resources      map[string]soakResourceCount `json:"resources"`
+	Leaks          []string                     `json:"leaks,omitempty"`
+	ErrorCount     int                          `json:"error_count"`
+	Errors         []string                     `json:"errors,omitempty"`
+	GoroutineSlack int                          `json:"goroutine_slack,omitempty"`
+}
+
+type soakResourceCount struct {
+	Before int `json:"before"`
+	After  int `json:"after"`
+}
+
+// soakTally collects outcomes from concurrent workers.
+type soakTally struct {
+	mu         sync.Mutex
+	outcomes   map[string]int
+	faults     map[string]int
+	errors     []string
+	errorCount int
+}
+
+func newSoakTally() *soakTally {
+	return &soakTally{outcomes: map[string]int{}, faults: map[string]int{}}
+}
+
+func (t *soakTally) outcome(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.outcomes[name]++
+}
+
+func (t *soakTally) fault(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.faults[name]++
+}
+
+func (t *soakTally) fail(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.errorCount++
+	if len(t.errors) < soakMaxReportedErrors {
+		t.errors = append(t.errors, err.Error())
+	}
+}
+
+func (s *BenchSoakCommand) Run(ctx *runtimeContext) error {
+	if s.Sandboxes <= 0 {
+		return errors.New("--sandboxes must be positive")
+	}
+	if s.Executions <= 0 {
+		return errors.New("--executions must be positive")
+	}
+	if s.CancelRate < 0 || s.DisconnectRate < 0 || s.CancelRate+s.DisconnectRate > 1 {
+		return errors.New("--cancel-rate and --disconnect-rate must be non-negative and sum to at most 1")
+	}
+	cwd, err := resolveCWD(ctx.CWD, s.Chdir)
+	if err != nil {
+		return err
+	}
+	backendName := resolveBackendName(s.Backend, ctx.Config.DefaultBackend)
+	compiled, _, err := ctx.Loader.LoadAndCompile(cwd)
+	if err != nil {
+		return err
+	}
+	compiled, err = overrideCompiledPolicyImage(ctx.commandContext(), compiled, s.Image, true)
+	if err != nil {
+		return err
+	}
+	command := s.Command
+	if len(command) == 0 {
+		command = []string{"sh", "-c", "sleep 1"}
+	}
+	seed := s.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	runCtx, cancel := signal.NotifyContext(ctx.commandContext(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var (
+		client   *controlclient.Client
+		shutdown = func() {}
+	)
+	if s.InProcess {
+		client, shutdown, err = startSoakServer(ctx)
+	} else {
+		client, err = s.connect()
+	}
+	if err != nil {
+		return err
+	}
+	defer shutdown()
+
+	// Only the local adapter can be probed; against a remote server the
+	// host counts would describe the wrong machine.
+	var reporter backend.HostResourceReporter
+	if s.InProcess || endpointIsLocal(s.Host) {
+		reporter, _ = ctx.Backends[backendName].(backend.HostResourceReporter)
+	}
+	before, err := soakResourceSnapshot(runCtx, reporter, s.InProcess)
+	if err != nil {
+		return err
+	}
+
+	tally := newSoakTally()
+	started := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < s.Sandboxes; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed + int64(worker)))
+			s.runSoakWorker(runCtx, client, backendName, compiled, command, rng, tally)
+		}(i)
+	}
+	wg.Wait()
+	client.CloseIdleConnections()
+
+	report := soakReport{
+		Backend:    backendName,
+		Seed:       seed,
+		Sandboxes:  s.Sandboxes,
+		Executions: s.Executions,
+		DurationMS: time.Since(started).Milliseconds(),
+		Outcomes:   tally.outcomes,
+		Faults:     tally.faults,
+		ErrorCount: tally.errorCount,
+		Errors:     tally.errors,
+	}
+	if s.InProcess {
+		report.GoroutineSlack = s.GoroutineSlack
+	}
+	after, err := s.waitForSoakSettle(runCtx, reporter, before)
+	if err != nil {
+		return err
+	}
+	report.Resources, report.Leaks = diffSoakResources(before, after, map[string]int{soakResourceGoroutines: s.GoroutineSlack})
+
+	if s.JSON {
+		enc := json.NewEncoder(ctx.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else if err := writeSoakTable(ctx.Stdout, report); err != nil {
+		return err
+	}
+	if len(report.Leaks) > 0 || report.ErrorCount > 0 {
+		return fmt.Errorf("soak finished with %d leaked resource(s) and %d error(s)", len(report.Leaks), report.ErrorCount)
+	}
+	return nil
+}
+
+// runSoakWorker owns one sandbox for its whole life: create, run the
+// configured executions one at a time with injected faults, then terminate.
+func (s *BenchSoakCommand) runSoakWorker(ctx context.Context, client *controlclient.Client, backendName string, compiled *policy.CompiledPolicy, command []string, rng *rand.Rand, tally *soakTally) {
+	resp, err := client.CreateSandbox(ctx, &cleanroomv1.CreateSandboxRequest{
+		Backend: backendName,
+		Policy:  compiled.ToProto(),
+	})
+	if err != nil {
+		tally.fail(fmt.Errorf("create sandbox: %w", err))
+		return
+	}
+	sandboxID := resp.GetSandbox().GetSandboxId()
+	defer func() {
+		// Terminate even if the run was interrupted so the settle check
+		// measures real leaks rather than abandoned sandboxes.
+		termCtx, cancel := context.WithTimeout(context.Background(), soakExecutionTimeout)
+		defer cancel()
+		if _, err := client.TerminateSandbox(termCtx, &cleanroomv1.TerminateSandboxRequest{SandboxId: sandboxID}); err != nil {
+			tally.fail(fmt.Errorf("terminate sandbox %s: %w", sandboxID, err))
+		}
+	}()
+
+	for i := 0; i < s.Executions; i++ {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.runSoakExecution(ctx, client, sandboxID, command, rng, tally); err != nil {
+			tally.fail(err)
+		}
+	}
+}
+
+func (s *BenchSoakCommand) runSoakExecution(ctx context.Context, client *controlclient.Client, sandboxID string, command []string, rng *rand.Rand, tally *soakTally) error {
+	resp, err := client.CreateExecution(ctx, &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   append([]string(nil), command...),
+		Kind:      cleanroomv1.ExecutionKind_EXECUTION_KIND_BATCH,
+	})
+	if err != nil {
+		return fmt.Errorf("create execution in %s: %w", sandboxID, err)
+	}
+	executionID := resp.GetExecution().GetExecutionId()
+
+	streamCtx, dropStream := context.WithCancel(ctx)
+	defer dropStream()
+	delay := time.Duration(rng.Int63n(int64(soakFaultMaxDelay)))
+	switch roll := rng.Float64(); {
+	case roll < s.CancelRate:
+		tally.fault("cancel")
+		timer := time.AfterFunc(delay, func() {
+			_, _ = client.CancelExecution(ctx, &cleanroomv1.CancelExecutionRequest{
+				SandboxId:   sandboxID,
+				ExecutionId: executionID,
+			})
+		})
+		defer timer.Stop()
+	case roll < s.CancelRate+s.DisconnectRate:
+		tally.fault("disconnect")
+		timer := time.AfterFunc(delay, dropStream)
+		defer timer.Stop()
+	}
+
+	stream, err := client.StreamExecution(streamCtx, &cleanroomv1.StreamExecutionRequest{
+		SandboxId:   sandboxID,
+		ExecutionId: executionID,
+		Follow:      true,
+	})
+	if err == nil {
+		for stream.Receive() {
+		}
+		_ = stream.Close()
+	}
+
+	status, err := waitForSoakExecution(ctx, client, sandboxID, executionID)
+	if err != nil {
+		return err
+	}
+	tally.outcome(soakOutcomeName(status))
+	return nil
+}
+
+func waitForSoakExecution(ctx context.Context, client *controlclient.Client, sandboxID, executionID string) (cleanroomv1.ExecutionStatus, error) {
+	deadline := time.Now().Add(soakExecutionTimeout)
+	for {
+		resp, err := client.GetExecution(ctx, &cleanroomv1.GetExecutionRequest{
+			SandboxId:   sandboxID,
+			ExecutionId: executionID,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("get execution %s/%s: %w", sandboxID, executionID, err)
+		}
+		if status := resp.GetExecution().GetStatus(); isFinalExecutionStatus(status) {
+			return status, nil
+		}
+		if time.Now().After(deadline) {
+			return 0, fmt.Errorf("execution %s/%s did not finish within %s", sandboxID, executionID, soakExecutionTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func soakOutcomeName(status cleanroomv1.ExecutionStatus) string {
+	return strings.ToLower(strings.TrimPrefix(status.String(), "EXECUTION_STATUS_"))
+}
+
+// startSoakServer serves a control service backed by ctx's adapters on a
+// loopback port and returns a client connected to it.
+func startSoakServer(ctx *runtimeContext) (*controlclient.Client, func(), error) {
+	service := &controlservice.Service{
+		Loader:   ctx.Loader,
+		Config:   ctx.Config,
+		Backends: ctx.Backends,
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, fmt.Errorf("listen for in-process server: %w", err)
+	}
+	server := &http.Server{Handler: controlserver.New(service, nil).Handler()}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	ep, err := endpoint.Resolve("http://" + listener.Addr().String())
+	if err != nil {
+		_ = server.Close()
+		return nil, nil, err
+	}
+	client, err := controlclient.New(ep)
+	if err != nil {
+		_ = server.Close()
+		return nil, nil, err
+	}
+	return client, func() { _ = server.Close() }, nil
+}
+
+func endpointIsLocal(host string) bool {
+	ep, err := endpoint.Resolve(host)
+	return err == nil && ep.Scheme == "unix"
+}
+
+func soakResourceSnapshot(ctx context.Context, reporter backend.HostResourceReporter, countGoroutines bool) (map[string]int, error) {
+	counts := map[string]int{}
+	if reporter != nil {
+		host, err := reporter.HostResources(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("count host resources: %w", err)
+		}
+		for name, n := range host {
+			counts[name] = n
+		}
+	}
+	if countGoroutines {
+		counts[soakResourceGoroutines] = runtime.NumGoroutine()
+	}
+	return counts, nil
+}
+
+// waitForSoakSettle polls resource counts until nothing is above its
+// starting value or the settle timeout passes, and returns the last counts.
+func (s *BenchSoakCommand) waitForSoakSettle(ctx context.Context, reporter backend.HostResourceReporter, before map[string]int) (map[string]int, error) {
+	deadline := time.Now().Add(s.Settle)
+	slack := map[string]int{soakResourceGoroutines: s.GoroutineSlack}
+	for {
+		after, err := soakResourceSnapshot(ctx, reporter, s.InProcess)
+		if err != nil {
+			return nil, err
+		}
+		if _, leaks := diffSoakResources(before, after, slack); len(leaks) == 0 || time.Now().After(deadline) {
+			return after, nil
+		}
+		select {
+		case <-ctx.Done():
+			return after, nil
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+// diffSoakResources pairs before/after counts and names every resource whose
+// count grew by more than its slack.
+func diffSoakResources(before, after, slack map[string]int) (map[string]soakResourceCount, []string) {
+	counts := map[string]soakResourceCount{}
+	for name, n := range before {
+		counts[name] = soakResourceCount{Before: n, After: after[name]}
+	}
+	for name, n := range after {
+		if _, ok := counts[name]; !ok {
+			counts[name] = soakResourceCount{After: n}
+		}
+	}
+	var leaks []string
+	for name, c := range counts {
+		if c.After-c.Before > slack[name] {
+			leaks = append(leaks, fmt.Sprintf("%s: %d before, %d after", name, c.Before, c.After))
+		}
+	}
+	sort.Strings(leaks)
+	return counts, leaks
+}
+
+func writeSoakTable(w io.Writer, report soakReport) error {
+	if _, err := fmt.Fprintf(w, "soak (%s, %d sandboxes x %d executions, seed %d) in %s\n",
+		report.Backend, report.Sandboxes, report.Executions, report.Seed,
+		time.Duration(report.DurationMS)*time.Millisecond); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "OUTCOME\tCOUNT")
+	for _, name := range sortedKeys(report.Outcomes) {
+		fmt.Fprintf(tw, "%s\t%d\n", name, report.Outcomes[name])
+	}
+	for _, name := range sortedKeys(report.Faults) {
+		fmt.Fprintf(tw, "injected %s\t%d\n", name, report.Faults[name])
+	}
+	fmt.Fprintf(tw, "errors\t%d\n", report.ErrorCount)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(report.Resources) > 0 {
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+		fmt.Fprintln(tw, "RESOURCE\tBEFORE\tAFTER")
+		names := make([]string, 0, len(report.Resources))
+		for name := range report.Resources {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			c := report.Resources[name]
+			fmt.Fprintf(tw, "%s\t%d\t%d\n", name, c.Before, c.After)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	for _, msg := range report.Errors {
+		fmt.Fprintf(w, "error: %s\n", msg)
+	}
+	for _, leak := range report.Leaks {
+		fmt.Fprintf(w, "leak: %s\n", leak)
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
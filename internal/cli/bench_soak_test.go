@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+)
+
+type soakTestAdapter struct {
+	mu           sync.Mutex
+	active       int
+	leaked       int
+	leakOnCancel bool
+}
+
+func (a *soakTestAdapter) Name() string { return "soak-test" }
+
+func (a *soakTestAdapter) Run(ctx context.Context, req backend.RunRequest) (*backend.RunResult, error) {
+	a.mu.Lock()
+	a.active++
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		a.active--
+		a.mu.Unlock()
+	}()
+
+	select {
+	case <-time.After(20 * time.Millisecond):
+		return &backend.RunResult{RunID: req.RunID, Message: "ok"}, nil
+	case <-ctx.Done():
+		if a.leakOnCancel {
+			a.mu.Lock()
+			a.leaked++
+			a.mu.Unlock()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (a *soakTestAdapter) HostResources(context.Context) (map[string]int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return map[string]int{"vms": a.active + a.leaked}, nil
+}
+
+func runSoakForTest(t *testing.T, adapter *soakTestAdapter) (string, error) {
+	t.Helper()
+	stdout, readStdout := makeStdoutCapture(t)
+	cmd := BenchSoakCommand{
+		Backend:        "soak-test",
+		InProcess:      true,
+		Sandboxes:      8,
+		Executions:     4,
+		CancelRate:     0.5,
+		DisconnectRate: 0.25,
+		Seed:           1,
+		Settle:         2 * time.Second,
+		GoroutineSlack: 50,
+		Command:        []string{"true"},
+	}
+	err := cmd.Run(&runtimeContext{
+		CWD:    t.TempDir(),
+		Stdout: stdout,
+		Loader: integrationLoader{},
+		Config: runtimeconfig.Config{},
+		Backends: map[string]backend.Adapter{
+			"soak-test": adapter,
+		},
+	})
+	return readStdout(), err
+}
+
+func TestBenchSoakReportsCleanRun(t *testing.T) {
+	out, err := runSoakForTest(t, &soakTestAdapter{})
+	if err != nil {
+		t.Fatalf("BenchSoakCommand.Run returned error: %v\n%s", err, out)
+	}
+	for _, want := range []string{"8 sandboxes x 4 executions", "injected cancel", "vms"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected soak output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "leak:") {
+		t.Fatalf("unexpected leak reported:\n%s", out)
+	}
+}
+
+func TestBenchSoakDetectsLeakedHostResources(t *testing.T) {
+	out, err := runSoakForTest(t, &soakTestAdapter{leakOnCancel: true})
+	if err == nil {
+		t.Fatalf("expected leak error, got output:\n%s", out)
+	}
+	if !strings.Contains(out, "leak: vms:") {
+		t.Fatalf("expected vms leak in output, got:\n%s", out)
+	}
+}
+
+func TestDiffSoakResourcesAppliesSlack(t *testing.T) {
+	t.Parallel()
+
+	counts, leaks := diffSoakResources(
+		map[string]int{"goroutines": 10, "taps": 0},
+		map[string]int{"goroutines": 14, "taps": 1, "procs": 2},
+		map[string]int{"goroutines": 5},
+	)
+	if got, want := counts["procs"], (soakResourceCount{After: 2}); got != want {
+		t.Fatalf("unexpected procs count: got %+v want %+v", got, want)
+	}
+	want := []string{"procs: 0 before, 2 after", "taps: 0 before, 1 after"}
+	if strings.Join(leaks, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected leaks: got %v want %v", leaks, want)
+	}
+}
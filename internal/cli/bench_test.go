@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+)
+
+type benchTestAdapter struct {
+	runs int
+}
+
+func (a *benchTestAdapter) Name() string { return "bench-test" }
+
+func (a *benchTestAdapter) Run(_ context.Context, req backend.RunRequest) (*backend.RunResult, error) {
+	a.runs++
+	if err := os.MkdirAll(req.RunDir, 0o755); err != nil {
+		return nil, err
+	}
+	obs := map[string]any{
+		"run_id":      req.RunID,
+		"phase":       "launch",
+		"vm_ready_ms": a.runs * 100,
+		"total_ms":    a.runs*100 + 50,
+	}
+	raw, err := json.Marshal(obs)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(req.RunDir, "run-observability.json"), raw, 0o644); err != nil {
+		return nil, err
+	}
+	return &backend.RunResult{RunID: req.RunID, ImageRef: req.Policy.ImageRef, Message: "ok"}, nil
+}
+
+func TestBenchLatencyWritesPercentileReport(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	reportPath := filepath.Join(tmpDir, "bench.json")
+	stdout, readStdout := makeStdoutCapture(t)
+	adapter := &benchTestAdapter{}
+
+	cmd := BenchLatencyCommand{Backend: "bench-test", Iterations: 20, Output: reportPath}
+	if err := cmd.Run(&runtimeContext{
+		CWD:    tmpDir,
+		Stdout: stdout,
+		Loader: integrationLoader{},
+		Config: runtimeconfig.Config{},
+		Backends: map[string]backend.Adapter{
+			"bench-test": adapter,
+		},
+	}); err != nil {
+		t.Fatalf("BenchLatencyCommand.Run returned error: %v", err)
+	}
+	if got, want := adapter.runs, 20; got != want {
+		t.Fatalf("unexpected run count: got %d want %d", got, want)
+	}
+
+	raw, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("read bench report: %v", err)
+	}
+	var report benchReport
+	if err := json.Unmarshal(raw, &report); err != nil {
+		t.Fatalf("unmarshal bench report: %v", err)
+	}
+	vmReady, ok := report.Phases["vm_ready"]
+	if !ok {
+		t.Fatalf("expected vm_ready phase, got %v", report.Phases)
+	}
+	if vmReady.Samples != 20 || vmReady.P50MS != 1000 || vmReady.P95MS != 1900 || vmReady.MinMS != 100 || vmReady.MaxMS != 2000 {
+		t.Fatalf("unexpected vm_ready stats: %+v", vmReady)
+	}
+	if len(report.Runs) != 20 {
+		t.Fatalf("unexpected run count in report: %d", len(report.Runs))
+	}
+	if _, err := os.Stat(filepath.Join(os.Getenv("XDG_STATE_HOME"), "cleanroom", "runs", report.Runs[0].RunID)); !os.IsNotExist(err) {
+		t.Fatalf("expected run directory to be removed, stat err %v", err)
+	}
+
+	out := readStdout()
+	if want := "vm_ready  1000ms  1900ms  100ms  2000ms"; !strings.Contains(out, want) {
+		t.Fatalf("expected table row %q, got:\n%s", want, out)
+	}
+}
+
+func TestPercentileUsesNearestRank(t *testing.T) {
+	t.Parallel()
+
+	values := []int64{10, 20, 30, 40}
+	for _, tc := range []struct {
+		p    float64
+		want int64
+	}{
+		{p: 50, want: 20},
+		{p: 95, want: 40},
+		{p: 0, want: 10},
+	} {
+		if got := percentile(values, tc.p); got != tc.want {
+			t.Fatalf("unexpected p%.0f: got %d want %d", tc.p, got, tc.want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Fatalf("unexpected percentile of empty slice: %d", got)
+	}
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/backend/darwinvz"
+	"github.com/buildkite/cleanroom/internal/backend/firecracker"
+	"github.com/buildkite/cleanroom/internal/cachepeer"
+	"github.com/buildkite/cleanroom/internal/imagemgr"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+	"github.com/buildkite/cleanroom/internal/tlsconfig"
+	"github.com/charmbracelet/log"
+)
+
+// startCacheSharing serves this host's image cache to peers when
+// cache_sharing.listen is set, and has the backends fetch missing images
+// from cache_sharing.peers before the registry. The returned func stops
+// the server.
+func startCacheSharing(cfg runtimeconfig.CacheSharing, cache *imagemgr.Manager, backends map[string]backend.Adapter, logger *log.Logger) (func(), error) {
+	tlsCfg, err := tlsconfig.ResolvePeer(tlsconfig.Options{
+		CertPath: cfg.TLSCert,
+		KeyPath:  cfg.TLSKey,
+		CAPath:   cfg.TLSCA,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cache sharing: %w", err)
+	}
+
+	stop := func() {}
+	if listen := strings.TrimSpace(cfg.Listen); listen != "" {
+		server := cachepeer.NewServer(cachepeer.ServerConfig{
+			ListenAddr: listen,
+			TLS:        tlsCfg,
+			Cache:      cache,
+			Logger:     logger,
+		})
+		if err := server.Start(); err != nil {
+			return nil, fmt.Errorf("start cache sharing server: %w", err)
+		}
+		stop = func() {
+			stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = server.Stop(stopCtx)
+		}
+	}
+
+	if len(cfg.Peers) > 0 {
+		peers := cachepeer.NewClient(cfg.Peers, tlsCfg, logger)
+		if fcAdapter, ok := backends["firecracker"].(*firecracker.Adapter); ok {
+			fcAdapter.ImagePeers = peers
+		}
+		if darwinAdapter, ok := backends["darwin-vz"].(*darwinvz.Adapter); ok {
+			darwinAdapter.ImagePeers = peers
+		}
+		logger.Info("fetching missing images from cache sharing peers", "peers", strings.Join(cfg.Peers, ","))
+	}
+	return stop, nil
+}
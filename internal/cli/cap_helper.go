@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+
+	"github.com/buildkite/cleanroom/internal/caphelper"
+)
+
+type InstallCapHelperCommand struct {
+	Path  string `default:"/usr/local/sbin/cleanroom-cap-helper" help:"Where to install the helper"`
+	Group string `default:"cleanroom" help:"Group allowed to run the helper"`
+}
+
+// Run copies this binary to Path, owned by root and Group, with the file
+// capabilities privileged_mode caps needs. The copy runs as the helper
+// because of its name, and only Group members can execute it.
+func (c *InstallCapHelperCommand) Run(ctx *runtimeContext) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate cleanroom binary: %w", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return fmt.Errorf("locate cleanroom binary: %w", err)
+	}
+	if filepath.Base(c.Path) != caphelper.Name {
+		return fmt.Errorf("helper path must end in %s so the binary runs as the helper", caphelper.Name)
+	}
+	group, err := user.LookupGroup(c.Group)
+	if err != nil {
+		return fmt.Errorf("look up group %q: %w (create it with groupadd and add the user running cleanroom serve)", c.Group, err)
+	}
+	gid, err := strconv.Atoi(group.Gid)
+	if err != nil {
+		return fmt.Errorf("look up group %q: invalid gid %q", c.Group, group.Gid)
+	}
+	if err := caphelper.Install(exe, c.Path, gid); err != nil {
+		return fmt.Errorf("install capability helper: %w", err)
+	}
+	_, err = fmt.Fprintf(ctx.Stdout, "installed %s for group %s with %s\n", c.Path, c.Group, caphelper.SetcapSpec())
+	return err
+}
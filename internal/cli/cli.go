@@ -2,12 +2,14 @@ package cli
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net"
 	"net/url"
 	"os"
@@ -26,19 +28,26 @@ import (
 	"github.com/buildkite/cleanroom/internal/backend"
 	"github.com/buildkite/cleanroom/internal/backend/darwinvz"
 	"github.com/buildkite/cleanroom/internal/backend/firecracker"
+	"github.com/buildkite/cleanroom/internal/checkout"
 	"github.com/buildkite/cleanroom/internal/controlclient"
 	"github.com/buildkite/cleanroom/internal/controlserver"
 	"github.com/buildkite/cleanroom/internal/controlservice"
 	"github.com/buildkite/cleanroom/internal/endpoint"
+	"github.com/buildkite/cleanroom/internal/eventbus"
 	"github.com/buildkite/cleanroom/internal/gateway"
 	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
 	"github.com/buildkite/cleanroom/internal/imagemgr"
 	"github.com/buildkite/cleanroom/internal/interactivequic"
+	"github.com/buildkite/cleanroom/internal/logging"
+	"github.com/buildkite/cleanroom/internal/logship"
 	"github.com/buildkite/cleanroom/internal/ociref"
 	"github.com/buildkite/cleanroom/internal/paths"
 	"github.com/buildkite/cleanroom/internal/policy"
+	"github.com/buildkite/cleanroom/internal/rundir"
 	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+	"github.com/buildkite/cleanroom/internal/schedule"
 	"github.com/buildkite/cleanroom/internal/tlsconfig"
+	"github.com/buildkite/cleanroom/internal/usage"
 	"github.com/charmbracelet/log"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
@@ -55,6 +64,9 @@ const (
 	launchdServiceName      = "com.buildkite.cleanroom"
 	defaultDaemonListen     = "unix://" + endpoint.DefaultSystemSocketPath
 	sandboxTerminateTimeout = 2 * time.Second
+	execStdinChunkBytes     = 32 * 1024
+	execStartPollInterval   = 50 * time.Millisecond
+	execStdinFailureGrace   = time.Second
 )
 
 type policyLoader interface {
@@ -68,20 +80,47 @@ type runtimeContext struct {
 	Config     runtimeconfig.Config
 	ConfigPath string
 	Backends   map[string]backend.Adapter
+	// Context bounds the command's RPCs and carries the --timeout deadline
+	// of client commands. Nil means no deadline.
+	Context context.Context
+	// Version is this binary's release, as printed by cleanroom version.
+	Version string
+}
+
+func (c *runtimeContext) commandContext() context.Context {
+	if c.Context == nil {
+		return context.Background()
+	}
+	return c.Context
 }
 
 type CLI struct {
-	Policy  PolicyCommand  `cmd:"" help:"Policy commands"`
-	Config  ConfigCommand  `cmd:"" help:"Runtime config commands"`
-	Image   ImageCommand   `cmd:"" help:"Manage OCI image cache artifacts"`
-	Create  CreateCommand  `cmd:"" help:"Create a sandbox"`
-	Exec    ExecCommand    `cmd:"" help:"Execute a command in a cleanroom backend"`
-	Console ConsoleCommand `cmd:"" help:"Attach an interactive console to a cleanroom execution"`
-	Serve   ServeCommand   `cmd:"" help:"Run the cleanroom control-plane server"`
-	Doctor  DoctorCommand  `cmd:"" help:"Run environment and backend diagnostics"`
-	Status  StatusCommand  `cmd:"" help:"Inspect run artifacts"`
-	Sandbox SandboxCommand `cmd:"" help:"Manage sandboxes"`
-	Version VersionCommand `cmd:"" help:"Print version information"`
+	Profile string            `help:"Runtime config profile to apply" env:"CLEANROOM_PROFILE"`
+	Vars    map[string]string `name:"var" placeholder:"NAME=VALUE" help:"Set a variable declared in the policy's variables section (repeatable; overrides the environment)"`
+
+	Policy    PolicyCommand    `cmd:"" help:"Policy commands"`
+	Config    ConfigCommand    `cmd:"" help:"Runtime config commands"`
+	Image     ImageCommand     `cmd:"" help:"Manage OCI image cache artifacts"`
+	Create    CreateCommand    `cmd:"" help:"Create a sandbox"`
+	Exec      ExecCommand      `cmd:"" help:"Execute a command in a cleanroom backend"`
+	Console   ConsoleCommand   `cmd:"" help:"Attach an interactive console to a cleanroom execution"`
+	Serve     ServeCommand     `cmd:"" help:"Run the cleanroom control-plane server"`
+	Doctor    DoctorCommand    `cmd:"" help:"Run environment and backend diagnostics"`
+	Ping      PingCommand      `cmd:"" help:"Check the connection to the server and print hints for fixing it"`
+	Status    StatusCommand    `cmd:"" help:"Inspect run artifacts"`
+	Bench     BenchCommand     `cmd:"" help:"Benchmark sandbox latency and soak-test the control plane"`
+	Sandbox   SandboxCommand   `cmd:"" help:"Manage sandboxes"`
+	Compose   ComposeCommand   `cmd:"" help:"Run several services as a sandbox group"`
+	Approval  ApprovalCommand  `cmd:"" help:"Review executions held for approval"`
+	Execution ExecutionCommand `cmd:"" help:"List and annotate executions"`
+	Usage     UsageCommand     `cmd:"" help:"Report the resources sandboxes reserved, for chargeback"`
+	Schedule  ScheduleCommand  `cmd:"" help:"Run commands in fresh sandboxes on cron schedules"`
+	Version   VersionCommand   `cmd:"" help:"Print version information"`
+
+	SelfUpdate       SelfUpdateCommand       `cmd:"" name:"self-update" help:"Replace cleanroom and its companion binaries with another release"`
+	InstallCapHelper InstallCapHelperCommand `cmd:"" name:"install-cap-helper" help:"Install the capability helper used by privileged_mode caps (run as root)"`
+	Completion       CompletionCommand       `cmd:"" help:"Print a shell completion script (bash, zsh, fish)"`
+	Complete         CompleteCommand         `cmd:"" name:"__complete" hidden:"" help:"Print completion candidates for the given words"`
 }
 
 type VersionCommand struct {
@@ -98,6 +137,8 @@ type ImageCommand struct {
 	List    ImageListCommand    `name:"ls" aliases:"list" cmd:"" help:"List cached images"`
 	Remove  ImageRemoveCommand  `name:"rm" aliases:"remove" cmd:"" help:"Remove a cached image by ref or digest"`
 	Import  ImageImportCommand  `cmd:"" help:"Import a rootfs tar stream into the cache for a digest-pinned ref"`
+	Save    ImageSaveCommand    `cmd:"" help:"Export cached images and their prepared rootfs to an archive for another host"`
+	Load    ImageLoadCommand    `cmd:"" help:"Load an archive written by image save into the cache"`
 	BumpRef ImageBumpRefCommand `name:"bump-ref" aliases:"set-ref" cmd:"" help:"Resolve an image tag to digest and update sandbox.image.ref in cleanroom policy"`
 }
 
@@ -118,23 +159,49 @@ type ImageImportCommand struct {
 	TarPath string `arg:"" optional:"" help:"Tar/tar.gz path, or '-' for stdin (default: '-')"`
 }
 
+type ImageSaveCommand struct {
+	Selectors []string `arg:"" required:"" help:"Cached image refs or digests to export"`
+	Output    string   `short:"o" required:"" help:"Archive path (.tar.zst), or '-' for stdout"`
+}
+
+type ImageLoadCommand struct {
+	Path string `arg:"" optional:"" help:"Archive path, or '-' for stdin (default: '-')"`
+}
+
 type ImageBumpRefCommand struct {
 	Source     string `arg:"" optional:"" help:"Image ref to resolve (default: ghcr.io/buildkite/cleanroom-base/alpine:latest)"`
 	Chdir      string `short:"c" help:"Change to this directory before running commands"`
-	PolicyPath string `help:"Policy file path (default: cleanroom.yaml, or .buildkite/cleanroom.yaml when primary is missing)"`
+	PolicyPath string `help:"Policy file path (default: the nearest cleanroom.yaml or .buildkite/cleanroom.yaml up to the repository root)"`
 }
 
 type PolicyCommand struct {
 	Validate PolicyValidateCommand `cmd:"" help:"Validate policy configuration"`
+	Simulate PolicySimulateCommand `cmd:"" help:"Check whether the policy lets a sandbox connect to a destination"`
+	Schema   PolicySchemaCommand   `cmd:"" help:"Print the JSON Schema for policy files"`
 }
 
 type PolicyValidateCommand struct {
-	Chdir string `short:"c" help:"Change to this directory before running commands"`
-	JSON  bool   `help:"Print compiled policy as JSON"`
+	Chdir     string `short:"c" help:"Change to this directory before running commands"`
+	PolicyDir string `name:"policy-dir" help:"Validate the policy for this directory (the nearest cleanroom.yaml at or above it) instead of the working directory's"`
+	JSON      bool   `help:"Print compiled policy as JSON"`
 }
 
+type PolicySchemaCommand struct{}
+
 type ConfigCommand struct {
-	Init ConfigInitCommand `cmd:"" help:"Create a runtime config file with defaults"`
+	Init     ConfigInitCommand     `cmd:"" help:"Create a runtime config file with defaults"`
+	Validate ConfigValidateCommand `cmd:"" help:"Check the runtime config for unknown keys, invalid values and missing paths"`
+	Show     ConfigShowCommand     `cmd:"" help:"Print the runtime config in effect"`
+}
+
+type ConfigValidateCommand struct {
+	Path string `help:"Config file to validate (default: $XDG_CONFIG_HOME/cleanroom/config.yaml)"`
+	JSON bool   `help:"Print problems as JSON"`
+}
+
+type ConfigShowCommand struct {
+	Effective bool `help:"Also apply environment overrides and built-in defaults for unset settings"`
+	JSON      bool `help:"Print config as JSON"`
 }
 
 type ConfigInitCommand struct {
@@ -144,12 +211,43 @@ type ConfigInitCommand struct {
 }
 
 type clientFlags struct {
-	Host     string `help:"Control-plane endpoint (unix://path, http://host:port, or https://host:port)" env:"CLEANROOM_HOST"`
-	LogLevel string `help:"Client log level (debug|info|warn|error)"`
-	TLSCA    string `name:"tls-ca" aliases:"tlsca" help:"Path to CA certificate for server verification (auto-discovered from XDG config for https)" env:"CLEANROOM_TLS_CA"`
+	Host      string        `help:"Control-plane endpoint (unix://path, http://host:port, or https://host:port)" env:"CLEANROOM_HOST"`
+	LogLevel  string        `help:"Client log level (debug|info|warn|error)"`
+	LogFormat string        `help:"Client log format (text|json)"`
+	TLSCA     string        `name:"tls-ca" aliases:"tlsca" help:"Path to CA certificate for server verification (auto-discovered from XDG config for https)" env:"CLEANROOM_TLS_CA"`
+	Timeout   time.Duration `help:"Give up if the command has not finished after this long, for example 30s or 10m (default no limit)" env:"CLEANROOM_TIMEOUT"`
+
+	version string
 }
 
+func (f *clientFlags) setClientVersion(version string) {
+	f.version = version
+}
+
+func (f *clientFlags) applyClientDefaults(cfg runtimeconfig.Config) {
+	if strings.TrimSpace(f.Host) == "" {
+		f.Host = cfg.Host
+	}
+	if strings.TrimSpace(f.TLSCA) == "" {
+		f.TLSCA = cfg.TLSCA
+	}
+}
+
+// connect dials the server and checks that it speaks a compatible API.
 func (f *clientFlags) connect() (*controlclient.Client, error) {
+	client, err := f.dial()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkServerCompatibility(client, cmp.Or(f.version, "dev"), os.Stderr); err != nil {
+		client.CloseIdleConnections()
+		return nil, err
+	}
+	return client, nil
+}
+
+// dial returns a client for the server without contacting it.
+func (f *clientFlags) dial() (*controlclient.Client, error) {
 	ep, err := endpoint.Resolve(f.Host)
 	if err != nil {
 		return nil, err
@@ -161,43 +259,92 @@ func (f *clientFlags) connect() (*controlclient.Client, error) {
 
 type ExecCommand struct {
 	clientFlags
-	Chdir          string `short:"c" help:"Change to this directory before running commands"`
-	Backend        string `help:"Execution backend (defaults to runtime config or host default)"`
-	SandboxID      string `help:"Reuse an existing sandbox instead of creating a new one"`
-	Image          string `help:"Override sandbox image ref for newly created sandboxes (tag, digest, or local Docker image)"`
-	Remove         bool   `name:"rm" help:"Terminate the sandbox after command completion"`
-	PrintSandboxID bool   `name:"print-sandbox-id" help:"Print resolved sandbox_id=<id> to stderr before streaming output"`
+	Chdir          string        `short:"c" help:"Change to this directory before running commands"`
+	Backend        string        `help:"Execution backend (defaults to runtime config or host default)"`
+	SandboxID      string        `completion:"sandbox" help:"Reuse an existing sandbox instead of creating a new one"`
+	Image          string        `help:"Override sandbox image ref for newly created sandboxes (tag, digest, or local Docker image)"`
+	PolicyDir      string        `name:"policy-dir" help:"Use the policy for this directory (the nearest cleanroom.yaml at or above it) instead of the working directory's"`
+	Allow          []string      `sep:"none" placeholder:"HOST:PORT" help:"Allow egress to this host and port (or from-to range) in an inline policy built from --image when there is no policy file (repeatable)"`
+	Remove         bool          `name:"rm" help:"Terminate the sandbox after command completion"`
+	PrintSandboxID bool          `name:"print-sandbox-id" help:"Print resolved sandbox_id=<id> to stderr before streaming output"`
+	Reuse          bool          `help:"Reuse this repository's leased sandbox while its policy is unchanged, creating one if needed"`
+	ReuseTTL       time.Duration `name:"reuse-ttl" default:"1h" help:"How long a --reuse lease stays valid after its last use"`
+	RunID          string        `name:"run-id" help:"Record the run under this ID instead of a generated one, such as a CI job's UUID (a TypeID or UUID not used before)"`
+
+	Annotations map[string]string `name:"annotate" help:"Annotation to attach to the execution, searchable with execution ls (key=value, repeatable)"`
 
 	LaunchSeconds int64 `help:"VM boot/guest-agent readiness timeout in seconds"`
 
+	Nice         int32  `help:"Scheduling niceness for the command inside the guest (-20..19)"`
+	IOClass      string `name:"io-class" help:"IO scheduling class for the command inside the guest (realtime|best-effort|idle)"`
+	IOPriority   int32  `name:"io-priority" help:"IO scheduling priority within --io-class (0..7)"`
+	CPUWeight    int64  `name:"cpu-weight" help:"CPU weight for the command's guest cgroup (1..10000)"`
+	MemoryMaxMiB int64  `name:"memory-max-mib" help:"Memory limit in MiB for the command's guest cgroup"`
+
+	VMVCPUs     int64 `name:"vm-vcpus" help:"vCPUs for this execution's VM, up to the policy's sandbox.resources (backends that boot a VM per execution only)"`
+	VMMemoryMiB int64 `name:"vm-memory-mib" help:"Memory in MiB for this execution's VM, up to the policy's sandbox.resources (backends that boot a VM per execution only)"`
+
+	KeepBackground bool   `name:"keep-background" help:"Leave background processes started by the command running after it exits"`
+	FreshHome      bool   `name:"fresh-home" help:"Run the command with a fresh tmpfs HOME that is discarded when it exits"`
+	Launcher       string `enum:"auto,direct,systemd" default:"auto" help:"How the guest starts the command (auto uses systemd-run when the image booted systemd)"`
+	StdinFile      string `name:"stdin-file" type:"existingfile" help:"Stream this file to the command's stdin, then close it"`
+	CaptureChanges string `name:"capture-changes" enum:"none,manifest,archive" default:"none" help:"Record the files the command created, modified or deleted and publish the record as artifacts (archive also packs their contents)"`
+	Format         string `enum:"text,json" default:"text" help:"Output format: text streams output as it arrives, json prints a single result object with captured output and exit metadata"`
+
+	GoTestJSON bool     `name:"go-test-json" help:"Summarize the go test -json events the command prints into the execution's test results"`
+	JUnit      []string `name:"junit" sep:"none" placeholder:"PATH" help:"Summarize this JUnit XML report, an absolute path in the sandbox, into the execution's test results once the command exits (repeatable)"`
+
 	Command []string `arg:"" passthrough:"" required:"" help:"Command to execute"`
 }
 
 type SandboxCreateCommand struct {
 	clientFlags
-	Chdir         string `short:"c" help:"Change to this directory before running commands"`
-	Backend       string `help:"Execution backend (defaults to runtime config or host default)"`
-	Image         string `help:"Override sandbox image ref (tag, digest, or local Docker image)"`
-	LaunchSeconds int64  `help:"VM boot/guest-agent readiness timeout in seconds"`
-	JSON          bool   `help:"Print sandbox as JSON"`
+	Chdir          string            `short:"c" help:"Change to this directory before running commands"`
+	Backend        string            `help:"Execution backend (defaults to runtime config or host default)"`
+	Image          string            `help:"Override sandbox image ref (tag, digest, or local Docker image)"`
+	PolicyDir      string            `name:"policy-dir" help:"Use the policy for this directory (the nearest cleanroom.yaml at or above it) instead of the working directory's"`
+	LaunchSeconds  int64             `help:"VM boot/guest-agent readiness timeout in seconds"`
+	JSON           bool              `help:"Print sandbox as JSON"`
+	Name           string            `help:"Unique name for the sandbox on this server"`
+	NameFromBranch bool              `name:"name-from-branch" help:"Name the sandbox <repo>-<branch> from the current git checkout and label it with repo and branch"`
+	Labels         map[string]string `name:"label" help:"Label to attach to the sandbox (key=value, repeatable)"`
+	Checkout       string            `help:"Fetch this https git repository on the server and unpack it into the sandbox"`
+	CheckoutRef    string            `name:"checkout-ref" help:"Branch, tag or commit to check out (defaults to the remote HEAD)"`
+	CheckoutPath   string            `name:"checkout-path" help:"Directory in the sandbox to unpack the checkout into (default /workspace)"`
+	PinResolutions string            `name:"pin-resolutions" help:"JSON file of policy host resolutions to use instead of DNS, as recorded in another sandbox's resolutions"`
+	Namespace      string            `help:"Create the sandbox in this namespace instead of your own (admins only)"`
 }
 
 type CreateCommand struct {
 	clientFlags
-	Chdir         string `short:"c" help:"Change to this directory before running commands"`
-	Backend       string `help:"Execution backend (defaults to runtime config or host default)"`
-	Image         string `help:"Override sandbox image ref (tag, digest, or local Docker image)"`
-	LaunchSeconds int64  `help:"VM boot/guest-agent readiness timeout in seconds"`
-	JSON          bool   `help:"Print sandbox as JSON"`
+	Chdir          string            `short:"c" help:"Change to this directory before running commands"`
+	Backend        string            `help:"Execution backend (defaults to runtime config or host default)"`
+	Image          string            `help:"Override sandbox image ref (tag, digest, or local Docker image)"`
+	PolicyDir      string            `name:"policy-dir" help:"Use the policy for this directory (the nearest cleanroom.yaml at or above it) instead of the working directory's"`
+	LaunchSeconds  int64             `help:"VM boot/guest-agent readiness timeout in seconds"`
+	JSON           bool              `help:"Print sandbox as JSON"`
+	Name           string            `help:"Unique name for the sandbox on this server"`
+	NameFromBranch bool              `name:"name-from-branch" help:"Name the sandbox <repo>-<branch> from the current git checkout and label it with repo and branch"`
+	Labels         map[string]string `name:"label" help:"Label to attach to the sandbox (key=value, repeatable)"`
+	Checkout       string            `help:"Fetch this https git repository on the server and unpack it into the sandbox"`
+	CheckoutRef    string            `name:"checkout-ref" help:"Branch, tag or commit to check out (defaults to the remote HEAD)"`
+	CheckoutPath   string            `name:"checkout-path" help:"Directory in the sandbox to unpack the checkout into (default /workspace)"`
+	PinResolutions string            `name:"pin-resolutions" help:"JSON file of policy host resolutions to use instead of DNS, as recorded in another sandbox's resolutions"`
+	Namespace      string            `help:"Create the sandbox in this namespace instead of your own (admins only)"`
 }
 
 type ConsoleCommand struct {
 	clientFlags
 	Chdir     string `short:"c" help:"Change to this directory before running commands"`
 	Backend   string `help:"Execution backend (defaults to runtime config or host default)"`
-	SandboxID string `help:"Reuse an existing sandbox instead of creating a new one"`
+	SandboxID string `completion:"sandbox" help:"Reuse an existing sandbox instead of creating a new one"`
 	Image     string `help:"Override sandbox image ref for newly created sandboxes (tag, digest, or local Docker image)"`
+	PolicyDir string `name:"policy-dir" help:"Use the policy for this directory (the nearest cleanroom.yaml at or above it) instead of the working directory's"`
 	Remove    bool   `name:"rm" help:"Terminate the sandbox after console exits"`
+	ForceTTY  bool   `name:"force-tty" help:"Use raw terminal passthrough even when stdin or stdout is not a terminal"`
+
+	MountClipboard bool   `name:"mount-clipboard" help:"Exchange small files with the guest: save OSC 52 clipboard writes locally and enable ~v (paste) and ~u (upload) escapes"`
+	ClipboardDir   string `name:"clipboard-dir" help:"Local directory holding the handoff clipboard file (default: current directory)"`
 
 	LaunchSeconds int64 `help:"VM boot/guest-agent readiness timeout in seconds"`
 
@@ -210,6 +357,7 @@ type ServeCommand struct {
 	Listen        string `help:"Listen endpoint for control API (defaults to runtime endpoint)"`
 	GatewayListen string `help:"Listen address for the host gateway (default :8170, use :0 for ephemeral port)"`
 	LogLevel      string `help:"Server log level (debug|info|warn|error)"`
+	LogFormat     string `help:"Server log format (text|json); json suits log aggregation"`
 	TLSCert       string `help:"Path to TLS server certificate (auto-discovered from XDG config for https)" env:"CLEANROOM_TLS_CERT"`
 	TLSKey        string `help:"Path to TLS server private key (auto-discovered from XDG config for https)" env:"CLEANROOM_TLS_KEY"`
 }
@@ -223,22 +371,78 @@ type DoctorCommand struct {
 	Chdir   string `short:"c" help:"Change to this directory before running commands"`
 	Backend string `help:"Execution backend to diagnose (defaults to runtime config or host default)"`
 	JSON    bool   `help:"Print doctor report as JSON"`
+	FailOn  string `name:"fail-on" enum:"fail,warn" default:"fail" help:"Exit non-zero when any check reaches this severity (fail|warn)"`
+	Deep    bool   `help:"Also boot a canary VM to verify launch, vsock, and network setup, and report boot timings"`
 }
 
 type SandboxCommand struct {
 	Create    SandboxCreateCommand    `cmd:"" help:"Create a sandbox"`
 	List      SandboxListCommand      `name:"ls" aliases:"list" cmd:"" help:"List active sandboxes"`
 	Terminate SandboxTerminateCommand `name:"rm" aliases:"terminate" cmd:"" help:"Terminate a sandbox"`
+	Commit    SandboxCommitCommand    `cmd:"" help:"Push a sandbox's current rootfs as a new OCI image"`
+	Clone     SandboxCloneCommand     `cmd:"" help:"Create sandboxes with the same policy, backend, labels and checkout as an existing one"`
+	Download  SandboxDownloadCommand  `cmd:"" help:"Copy a file out of a sandbox, sending only changed blocks when a local copy exists"`
+	Upgrade   SandboxUpgradeCommand   `name:"upgrade-agent" cmd:"" help:"Replace running sandboxes' guest agent with the server's without recreating them"`
+	Pause     SandboxPauseCommand     `cmd:"" help:"Stop a sandbox's vCPUs, keeping it in memory until it is resumed"`
+	Resume    SandboxResumeCommand    `cmd:"" help:"Resume a paused sandbox"`
 }
 
 type SandboxListCommand struct {
 	clientFlags
-	JSON bool `help:"Print sandboxes as JSON"`
+	JSON          bool   `help:"Print sandboxes as JSON"`
+	Namespace     string `help:"List this namespace instead of your own (admins only)"`
+	AllNamespaces bool   `name:"all-namespaces" help:"List sandboxes in every namespace (admins only)"`
 }
 
 type SandboxTerminateCommand struct {
 	clientFlags
-	SandboxID string `arg:"" required:"" help:"Sandbox ID to terminate"`
+	SandboxIDs []string          `arg:"" optional:"" name:"sandbox" completion:"sandbox" help:"Sandbox IDs or names to terminate (prompts with a picker in a terminal when omitted)"`
+	All        bool              `help:"Terminate every active sandbox"`
+	Labels     map[string]string `name:"label" help:"Only terminate sandboxes with this label (key=value, repeatable; all must match)"`
+	OlderThan  time.Duration     `name:"older-than" help:"Only terminate sandboxes created longer ago than this (for example 24h)"`
+	DryRun     bool              `name:"dry-run" help:"Print the sandboxes that would be terminated without terminating them"`
+}
+
+type SandboxCommitCommand struct {
+	clientFlags
+	SandboxID string `arg:"" name:"sandbox" completion:"sandbox" help:"Sandbox ID or name to commit"`
+	Ref       string `arg:"" name:"ref" help:"Image tag to push to, for example ghcr.io/org/img:tag"`
+}
+
+type SandboxCloneCommand struct {
+	clientFlags
+	SandboxID   string            `arg:"" name:"sandbox" completion:"sandbox" help:"Sandbox ID or name to clone"`
+	Count       int32             `short:"n" default:"1" help:"How many clones to create (at most 16)"`
+	SnapshotRef string            `name:"snapshot-ref" help:"Commit the sandbox's rootfs to this image tag first and boot the clones from it, keeping its current state"`
+	Labels      map[string]string `name:"label" help:"Label to add to each clone (key=value, repeatable)"`
+	JSON        bool              `help:"Print the clones as JSON"`
+}
+
+type SandboxDownloadCommand struct {
+	clientFlags
+	SandboxID string `arg:"" name:"sandbox" completion:"sandbox" help:"Sandbox ID or name to download from"`
+	Path      string `arg:"" name:"path" help:"Absolute path of the file in the sandbox"`
+	Output    string `short:"o" help:"Local file to write (defaults to the file's base name); an existing copy is updated in place"`
+	MaxBytes  int64  `name:"max-bytes" help:"Fail when the file is larger than this (defaults to the server's 10 MiB)"`
+
+	Verify bool `help:"Fail unless the guest agent checksummed the file, and re-check the written copy against it"`
+}
+
+type SandboxUpgradeCommand struct {
+	clientFlags
+	SandboxIDs []string          `arg:"" optional:"" name:"sandbox" completion:"sandbox" help:"Sandbox IDs or names to upgrade"`
+	All        bool              `help:"Upgrade every active sandbox"`
+	Labels     map[string]string `name:"label" help:"Only upgrade sandboxes with this label (key=value, repeatable; all must match)"`
+}
+
+type SandboxPauseCommand struct {
+	clientFlags
+	SandboxID string `arg:"" name:"sandbox" completion:"sandbox" help:"Sandbox ID or name to pause"`
+}
+
+type SandboxResumeCommand struct {
+	clientFlags
+	SandboxID string `arg:"" name:"sandbox" completion:"sandbox" help:"Sandbox ID or name to resume"`
 }
 
 type exitCodeError struct {
@@ -253,6 +457,25 @@ func (e exitCodeError) ExitCode() int {
 	return e.code
 }
 
+// doctorThresholdError reports that doctor checks reached the --fail-on
+// severity so provisioning scripts can gate on the exit status.
+type doctorThresholdError struct {
+	count  int
+	failOn string
+}
+
+func (e doctorThresholdError) Error() string {
+	noun := "checks"
+	if e.count == 1 {
+		noun = "check"
+	}
+	return fmt.Sprintf("doctor: %d %s at or above %s", e.count, noun, e.failOn)
+}
+
+func (e doctorThresholdError) ExitCode() int {
+	return 1
+}
+
 type hasExitCode interface {
 	ExitCode() int
 }
@@ -320,22 +543,14 @@ var (
 )
 
 func Run(args []string, version string) error {
-	cfg, cfgPath, err := runtimeconfig.Load()
+	backends, err := backend.WithRegistered(map[string]backend.Adapter{
+		"firecracker": firecracker.New(),
+		"darwin-vz":   darwinvz.New(),
+	})
 	if err != nil {
 		return err
 	}
 
-	runtimeCtx := &runtimeContext{
-		Stdout:     os.Stdout,
-		Loader:     policy.Loader{},
-		Config:     cfg,
-		ConfigPath: cfgPath,
-		Backends: map[string]backend.Adapter{
-			"firecracker": firecracker.New(),
-			"darwin-vz":   darwinvz.New(),
-		},
-	}
-
 	cli := CLI{}
 	cli.Version.version = version
 	parser, err := kong.New(
@@ -356,9 +571,90 @@ func Run(args []string, version string) error {
 	if err != nil {
 		return err
 	}
-	runtimeCtx.CWD = cwd
 
-	return ctx.Run(runtimeCtx)
+	cfg, cfgPath, repoCfg, err := loadRuntimeConfig(cli.Profile, cwd)
+	if err != nil {
+		return err
+	}
+	var cmd any
+	if node := ctx.Selected(); node != nil && node.Target.CanAddr() {
+		cmd = node.Target.Addr().Interface()
+		applyConfigDefaults(cmd, cfg, repoCfg)
+		if c, ok := cmd.(interface{ setClientVersion(string) }); ok {
+			c.setClientVersion(version)
+		}
+	}
+	cmdCtx, cancel := newCommandContext(cmd)
+	defer cancel()
+
+	return timedOut(cmdCtx, ctx.Run(&runtimeContext{
+		CWD:        cwd,
+		Stdout:     os.Stdout,
+		Loader:     newPolicyLoader(cli.Vars),
+		Config:     cfg,
+		ConfigPath: cfgPath,
+		Backends:   backends,
+		Context:    cmdCtx,
+		Version:    version,
+	}))
+}
+
+// newPolicyLoader returns the loader commands use: repository policies,
+// then the user default policy, with vars set by --var.
+func newPolicyLoader(vars map[string]string) policy.Loader {
+	userPolicy, err := policy.UserPolicyPath()
+	if err != nil {
+		userPolicy = ""
+	}
+	return policy.Loader{UserPolicyPath: userPolicy, Vars: vars}
+}
+
+// loadRuntimeConfig loads the user's runtime config with profile applied and
+// overlays the default backend from the repository config found at cwd.
+func loadRuntimeConfig(profile, cwd string) (runtimeconfig.Config, string, runtimeconfig.RepoConfig, error) {
+	cfg, cfgPath, err := runtimeconfig.LoadProfile(profile)
+	if err != nil {
+		return runtimeconfig.Config{}, cfgPath, runtimeconfig.RepoConfig{}, err
+	}
+	repoCfg, _, err := runtimeconfig.LoadRepo(cwd)
+	if err != nil {
+		return runtimeconfig.Config{}, cfgPath, runtimeconfig.RepoConfig{}, err
+	}
+	if repoCfg.DefaultBackend != "" {
+		cfg.DefaultBackend = repoCfg.DefaultBackend
+	}
+	return cfg, cfgPath, repoCfg, nil
+}
+
+// applyConfigDefaults fills flags the user left unset on the selected
+// command: the server and CA come from the runtime config (after the
+// profile is applied), the backend and launch timeout from the repository's
+// config. Flags and environment variables always win.
+func applyConfigDefaults(cmd any, cfg runtimeconfig.Config, repo runtimeconfig.RepoConfig) {
+	if c, ok := cmd.(interface{ applyClientDefaults(runtimeconfig.Config) }); ok {
+		c.applyClientDefaults(cfg)
+	}
+	switch c := cmd.(type) {
+	case *ExecCommand:
+		defaultSandboxLaunchFlags(&c.Backend, &c.LaunchSeconds, repo)
+	case *ConsoleCommand:
+		defaultSandboxLaunchFlags(&c.Backend, &c.LaunchSeconds, repo)
+	case *CreateCommand:
+		defaultSandboxLaunchFlags(&c.Backend, &c.LaunchSeconds, repo)
+	case *SandboxCreateCommand:
+		defaultSandboxLaunchFlags(&c.Backend, &c.LaunchSeconds, repo)
+	case *ComposeUpCommand:
+		defaultSandboxLaunchFlags(&c.Backend, &c.LaunchSeconds, repo)
+	}
+}
+
+func defaultSandboxLaunchFlags(backendName *string, launchSeconds *int64, repo runtimeconfig.RepoConfig) {
+	if strings.TrimSpace(*backendName) == "" {
+		*backendName = repo.DefaultBackend
+	}
+	if *launchSeconds == 0 {
+		*launchSeconds = repo.LaunchSeconds
+	}
 }
 
 func ExitCode(err error) int {
@@ -369,27 +665,69 @@ func ExitCode(err error) int {
 	return 1
 }
 
+func (c *PolicySchemaCommand) Run(ctx *runtimeContext) error {
+	_, err := ctx.Stdout.Write(policy.Schema())
+	return err
+}
+
 func (c *PolicyValidateCommand) Run(ctx *runtimeContext) error {
 	cwd, err := resolveCWD(ctx.CWD, c.Chdir)
 	if err != nil {
 		return err
 	}
-	compiled, source, err := ctx.Loader.LoadAndCompile(cwd)
+	policyDir, err := resolvePolicyDir(cwd, c.PolicyDir)
+	if err != nil {
+		return err
+	}
+	compiled, source, err := ctx.Loader.LoadAndCompile(policyDir)
 	if err != nil {
 		return err
 	}
+	var candidates []policy.Candidate
+	if finder, ok := ctx.Loader.(interface {
+		Candidates(string) ([]policy.Candidate, error)
+	}); ok {
+		if candidates, err = finder.Candidates(policyDir); err != nil {
+			return err
+		}
+	}
 
 	if c.JSON {
 		payload := map[string]any{
 			"source": source,
 			"policy": compiled,
 		}
+		if candidates != nil {
+			payload["candidates"] = candidates
+		}
 		enc := json.NewEncoder(ctx.Stdout)
 		enc.SetIndent("", "  ")
 		return enc.Encode(payload)
 	}
 
-	_, err = fmt.Fprintf(ctx.Stdout, "policy valid: %s\npolicy hash: %s\n", source, compiled.Hash)
+	if _, err := fmt.Fprintf(ctx.Stdout, "policy valid: %s\npolicy hash: %s\n", source, compiled.Hash); err != nil {
+		return err
+	}
+	if len(candidates) > 0 {
+		if _, err := fmt.Fprintln(ctx.Stdout, "search order:"); err != nil {
+			return err
+		}
+		for _, candidate := range candidates {
+			state := "missing"
+			switch {
+			case candidate.Path == source:
+				state = "used"
+			case candidate.Exists:
+				state = "shadowed"
+			}
+			if _, err := fmt.Fprintf(ctx.Stdout, "  %s (%s)\n", candidate.Path, state); err != nil {
+				return err
+			}
+		}
+	}
+	if compiled.NestedVirtualization {
+		_, err = fmt.Fprintln(ctx.Stdout, "warning: sandbox.nested_virtualization gives the guest /dev/kvm, which reduces isolation from the host")
+	}
 	return err
 }
 
@@ -597,6 +935,83 @@ func (c *ImageImportCommand) Run(ctx *runtimeContext) error {
 	return err
 }
 
+func (c *ImageSaveCommand) Run(ctx *runtimeContext) error {
+	mgr, err := newImageManager()
+	if err != nil {
+		return err
+	}
+
+	summary := io.Writer(ctx.Stdout)
+	var records []imagemgr.Record
+	if c.Output == "-" {
+		if term.IsTerminal(int(ctx.Stdout.Fd())) {
+			return errors.New("refusing to write an image archive to a terminal; redirect stdout or use -o <path>")
+		}
+		summary = os.Stderr
+		records, err = mgr.Save(context.Background(), c.Selectors, ctx.Stdout)
+		if err != nil {
+			return err
+		}
+	} else {
+		out, err := os.CreateTemp(filepath.Dir(c.Output), filepath.Base(c.Output)+".tmp-*")
+		if err != nil {
+			return fmt.Errorf("create image archive: %w", err)
+		}
+		defer os.Remove(out.Name())
+		records, err = mgr.Save(context.Background(), c.Selectors, out)
+		if closeErr := out.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("write image archive: %w", closeErr)
+		}
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(out.Name(), c.Output); err != nil {
+			return fmt.Errorf("write image archive %s: %w", c.Output, err)
+		}
+	}
+
+	if _, err := fmt.Fprintf(summary, "saved images\noutput=%s\n", c.Output); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if _, err := fmt.Fprintf(summary, "digest=%s ref=%s\n", record.Digest, record.Ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ImageLoadCommand) Run(ctx *runtimeContext) error {
+	mgr, err := newImageManager()
+	if err != nil {
+		return err
+	}
+
+	in := io.Reader(os.Stdin)
+	if path := strings.TrimSpace(c.Path); path != "" && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("open image archive: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	records, err := mgr.Load(context.Background(), in)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(ctx.Stdout, "loaded images\ncount=%d\n", len(records)); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if _, err := fmt.Fprintf(ctx.Stdout, "digest=%s ref=%s rootfs=%s\n", record.Digest, record.Ref, record.RootFSPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *ImageBumpRefCommand) Run(ctx *runtimeContext) error {
 	cwd, err := resolveCWD(ctx.CWD, c.Chdir)
 	if err != nil {
@@ -646,7 +1061,10 @@ func (c *SandboxListCommand) Run(ctx *runtimeContext) error {
 		return err
 	}
 
-	resp, err := client.ListSandboxes(context.Background(), &cleanroomv1.ListSandboxesRequest{})
+	resp, err := client.ListSandboxes(ctx.commandContext(), &cleanroomv1.ListSandboxesRequest{
+		Namespace:     c.Namespace,
+		AllNamespaces: c.AllNamespaces,
+	})
 	if err != nil {
 		return err
 	}
@@ -663,7 +1081,11 @@ func (c *SandboxListCommand) Run(ctx *runtimeContext) error {
 	}
 
 	tw := tabwriter.NewWriter(ctx.Stdout, 0, 2, 2, ' ', 0)
-	if _, err := fmt.Fprintln(tw, "ID\tSTATUS\tBACKEND\tCREATED"); err != nil {
+	header := "ID\tNAME\tSTATUS\tBACKEND\tCREATED"
+	if c.AllNamespaces {
+		header = "NAMESPACE\t" + header
+	}
+	if _, err := fmt.Fprintln(tw, header); err != nil {
 		return err
 	}
 	for _, sb := range resp.Sandboxes {
@@ -672,59 +1094,104 @@ func (c *SandboxListCommand) Run(ctx *runtimeContext) error {
 		if sb.CreatedAt != nil {
 			created = sb.CreatedAt.AsTime().Format(time.RFC3339)
 		}
-		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", sb.SandboxId, status, sb.Backend, created); err != nil {
+		if c.AllNamespaces {
+			if _, err := fmt.Fprintf(tw, "%s\t", sb.Namespace); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", sb.SandboxId, sb.Name, status, sb.Backend, created); err != nil {
 			return err
 		}
 	}
 	return tw.Flush()
 }
 
-func (c *SandboxTerminateCommand) Run(ctx *runtimeContext) error {
-	client, err := c.connect()
+type sandboxCreateOptions struct {
+	Chdir          string
+	Backend        string
+	Image          string
+	PolicyDir      string
+	LaunchSeconds  int64
+	JSON           bool
+	Name           string
+	NameFromBranch bool
+	Labels         map[string]string
+	Checkout       string
+	CheckoutRef    string
+	CheckoutPath   string
+	PinResolutions string
+	Namespace      string
+}
+
+func runSandboxCreate(ctx *runtimeContext, connectFlags clientFlags, opts sandboxCreateOptions) error {
+	client, err := connectFlags.connect()
 	if err != nil {
 		return err
 	}
 
-	resp, err := client.TerminateSandbox(context.Background(), &cleanroomv1.TerminateSandboxRequest{
-		SandboxId: c.SandboxID,
-	})
+	cwd, err := resolveCWD(ctx.CWD, opts.Chdir)
 	if err != nil {
 		return err
 	}
-
-	_, err = fmt.Fprintln(ctx.Stdout, resp.Message)
-	return err
-}
-
-func runSandboxCreate(ctx *runtimeContext, connectFlags clientFlags, chdir, backend, imageRefOverride string, launchSeconds int64, outputJSON bool) error {
-	client, err := connectFlags.connect()
+	policyDir, err := resolvePolicyDir(cwd, opts.PolicyDir)
 	if err != nil {
 		return err
 	}
-
-	cwd, err := resolveCWD(ctx.CWD, chdir)
+	compiled, _, err := compileSandboxPolicy(ctx.commandContext(), ctx.Loader, policyDir, connectFlags.Host, opts.Image, nil)
 	if err != nil {
 		return err
 	}
-	compiled, _, err := ctx.Loader.LoadAndCompile(cwd)
-	if err != nil {
-		return err
+
+	name := strings.TrimSpace(opts.Name)
+	labels := maps.Clone(opts.Labels)
+	if opts.NameFromBranch {
+		if name != "" {
+			return errors.New("--name cannot be used with --name-from-branch")
+		}
+		checkout, err := gitCheckoutForDir(cwd)
+		if err != nil {
+			return err
+		}
+		name = checkout.sandboxName()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels["repo"] = checkout.Repo
+		labels["branch"] = checkout.Branch
 	}
-	allowLocalImageOverride, err := isLocalControlPlaneEndpoint(connectFlags.Host)
-	if err != nil {
-		return err
+
+	var repoCheckout *cleanroomv1.SandboxCheckout
+	if repository := strings.TrimSpace(opts.Checkout); repository != "" {
+		repoCheckout = &cleanroomv1.SandboxCheckout{
+			Repository: repository,
+			Ref:        strings.TrimSpace(opts.CheckoutRef),
+			Path:       strings.TrimSpace(opts.CheckoutPath),
+		}
+	} else if opts.CheckoutRef != "" || opts.CheckoutPath != "" {
+		return errors.New("--checkout-ref and --checkout-path require --checkout")
 	}
-	compiled, err = overrideCompiledPolicyImage(compiled, imageRefOverride, allowLocalImageOverride)
-	if err != nil {
-		return err
+
+	var pinned []*cleanroomv1.HostResolution
+	if path := opts.PinResolutions; path != "" {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(ctx.CWD, path)
+		}
+		if pinned, err = readPinnedResolutions(path); err != nil {
+			return err
+		}
 	}
 
-	resp, err := client.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{
-		Backend: backend,
+	resp, err := client.CreateSandbox(ctx.commandContext(), &cleanroomv1.CreateSandboxRequest{
+		Backend: opts.Backend,
 		Options: &cleanroomv1.SandboxOptions{
-			LaunchSeconds: launchSeconds,
+			LaunchSeconds: opts.LaunchSeconds,
 		},
-		Policy: compiled.ToProto(),
+		Policy:            compiled.ToProto(),
+		Name:              name,
+		Labels:            labels,
+		Checkout:          repoCheckout,
+		PinnedResolutions: pinned,
+		Namespace:         opts.Namespace,
 	})
 	if err != nil {
 		return fmt.Errorf("create sandbox: %w", err)
@@ -736,7 +1203,7 @@ func runSandboxCreate(ctx *runtimeContext, connectFlags clientFlags, chdir, back
 		return errors.New("create sandbox: response missing sandbox id")
 	}
 
-	if outputJSON {
+	if opts.JSON {
 		enc := json.NewEncoder(ctx.Stdout)
 		enc.SetIndent("", "  ")
 		return enc.Encode(sandbox)
@@ -746,16 +1213,60 @@ func runSandboxCreate(ctx *runtimeContext, connectFlags clientFlags, chdir, back
 	return err
 }
 
+// readPinnedResolutions reads a JSON array of {"host", "addresses"} objects,
+// the shape of a sandbox's resolutions in sandbox create --json.
+func readPinnedResolutions(path string) ([]*cleanroomv1.HostResolution, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read pinned resolutions: %w", err)
+	}
+	var pinned []*cleanroomv1.HostResolution
+	if err := json.Unmarshal(raw, &pinned); err != nil {
+		return nil, fmt.Errorf("parse pinned resolutions %s: %w", path, err)
+	}
+	return pinned, nil
+}
+
 func (c *SandboxCreateCommand) Run(ctx *runtimeContext) error {
-	return runSandboxCreate(ctx, c.clientFlags, c.Chdir, c.Backend, c.Image, c.LaunchSeconds, c.JSON)
+	return runSandboxCreate(ctx, c.clientFlags, sandboxCreateOptions{
+		Chdir:          c.Chdir,
+		Backend:        c.Backend,
+		Image:          c.Image,
+		PolicyDir:      c.PolicyDir,
+		LaunchSeconds:  c.LaunchSeconds,
+		JSON:           c.JSON,
+		Name:           c.Name,
+		NameFromBranch: c.NameFromBranch,
+		Labels:         c.Labels,
+		Checkout:       c.Checkout,
+		CheckoutRef:    c.CheckoutRef,
+		CheckoutPath:   c.CheckoutPath,
+		PinResolutions: c.PinResolutions,
+		Namespace:      c.Namespace,
+	})
 }
 
 func (c *CreateCommand) Run(ctx *runtimeContext) error {
-	return runSandboxCreate(ctx, c.clientFlags, c.Chdir, c.Backend, c.Image, c.LaunchSeconds, c.JSON)
+	return runSandboxCreate(ctx, c.clientFlags, sandboxCreateOptions{
+		Chdir:          c.Chdir,
+		Backend:        c.Backend,
+		Image:          c.Image,
+		PolicyDir:      c.PolicyDir,
+		LaunchSeconds:  c.LaunchSeconds,
+		JSON:           c.JSON,
+		Name:           c.Name,
+		NameFromBranch: c.NameFromBranch,
+		Labels:         c.Labels,
+		Checkout:       c.Checkout,
+		CheckoutRef:    c.CheckoutRef,
+		CheckoutPath:   c.CheckoutPath,
+		PinResolutions: c.PinResolutions,
+		Namespace:      c.Namespace,
+	})
 }
 
 func (e *ExecCommand) Run(ctx *runtimeContext) error {
-	logger, err := newLogger(e.LogLevel, "client")
+	logger, err := newLogger(e.LogLevel, e.LogFormat, "client")
 	if err != nil {
 		return err
 	}
@@ -768,6 +1279,18 @@ func (e *ExecCommand) Run(ctx *runtimeContext) error {
 	if err != nil {
 		return err
 	}
+	policyDir, err := resolvePolicyDir(cwd, e.PolicyDir)
+	if err != nil {
+		return err
+	}
+	var stdinFile *os.File
+	if e.StdinFile != "" {
+		stdinFile, err = os.Open(e.StdinFile)
+		if err != nil {
+			return fmt.Errorf("open stdin file: %w", err)
+		}
+		defer stdinFile.Close()
+	}
 
 	logger.Debug("sending execution request",
 		"host", e.Host,
@@ -775,7 +1298,19 @@ func (e *ExecCommand) Run(ctx *runtimeContext) error {
 		"sandbox_id", strings.TrimSpace(e.SandboxID),
 		"command_argc", len(e.Command),
 	)
-	sandboxID, err := ensureSandboxID(client, ctx.Loader, cwd, e.Host, e.Backend, strings.TrimSpace(e.SandboxID), e.Image, e.LaunchSeconds)
+	cmdCtx := ctx.commandContext()
+	var sandboxID string
+	if e.Reuse {
+		if strings.TrimSpace(e.SandboxID) != "" {
+			return errors.New("--reuse cannot be used with --sandbox-id")
+		}
+		if e.Remove {
+			return errors.New("--reuse cannot be used with --rm")
+		}
+		sandboxID, err = reuseSandboxID(cmdCtx, client, ctx.Loader, cwd, policyDir, e.Host, e.Backend, e.Image, e.Allow, e.LaunchSeconds, e.ReuseTTL, logger)
+	} else {
+		sandboxID, err = ensureSandboxID(cmdCtx, client, ctx.Loader, policyDir, e.Host, e.Backend, strings.TrimSpace(e.SandboxID), e.Image, e.Allow, e.LaunchSeconds)
+	}
 	if err != nil {
 		return err
 	}
@@ -793,22 +1328,55 @@ func (e *ExecCommand) Run(ctx *runtimeContext) error {
 		terminateSandboxBestEffort(client, sandboxID, 0, logger, "terminate sandbox after exec failed")
 	}()
 
-	createExecutionResp, err := client.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
-		SandboxId: sandboxID,
-		Command:   append([]string(nil), e.Command...),
-		Kind:      cleanroomv1.ExecutionKind_EXECUTION_KIND_BATCH,
+	createExecutionResp, err := client.CreateExecution(cmdCtx, &cleanroomv1.CreateExecutionRequest{
+		SandboxId:   sandboxID,
+		Command:     append([]string(nil), e.Command...),
+		Kind:        cleanroomv1.ExecutionKind_EXECUTION_KIND_BATCH,
+		RunId:       e.RunID,
+		Annotations: e.Annotations,
 		Options: &cleanroomv1.ExecutionOptions{
-			LaunchSeconds: e.LaunchSeconds,
+			LaunchSeconds:           e.LaunchSeconds,
+			Limits:                  e.resourceLimits(),
+			KeepBackgroundProcesses: e.KeepBackground,
+			FreshHome:               e.FreshHome,
+			Launcher:                executionLauncherFromFlag(e.Launcher),
+			Stdin:                   stdinFile != nil,
+			Vcpus:                   e.VMVCPUs,
+			MemoryMib:               e.VMMemoryMiB,
+			CaptureChanges:          changeCaptureFromFlag(e.CaptureChanges),
+			ResultParsers:           e.resultParsers(),
 		},
 	})
 	if err != nil {
 		return fmt.Errorf("create execution: %w", err)
 	}
 	executionID := createExecutionResp.GetExecution().GetExecutionId()
+	if err := waitForExecutionApproval(cmdCtx, client, createExecutionResp.GetExecution(), os.Stderr); err != nil {
+		return err
+	}
 
 	logger.Debug("execution started", "sandbox_id", sandboxID, "execution_id", executionID)
 
-	streamCtx, streamCancel := context.WithCancel(context.Background())
+	// A failed stdin write would leave the command waiting for EOF forever,
+	// so it cancels the execution and becomes the command's error.
+	stdinErr := make(chan error, 1)
+	if stdinFile != nil {
+		go func() {
+			err := streamExecutionStdin(cmdCtx, client, sandboxID, executionID, stdinFile)
+			if err == nil || executionFinished(cmdCtx, client, sandboxID, executionID, execStdinFailureGrace) {
+				return
+			}
+			stdinErr <- fmt.Errorf("stream stdin file: %w", err)
+			if _, cancelErr := client.CancelExecution(context.Background(), &cleanroomv1.CancelExecutionRequest{
+				SandboxId:   sandboxID,
+				ExecutionId: executionID,
+			}); cancelErr != nil {
+				logger.Warn("cancel execution after stdin failure failed", "sandbox_id", sandboxID, "execution_id", executionID, "error", cancelErr)
+			}
+		}()
+	}
+
+	streamCtx, streamCancel := context.WithCancel(cmdCtx)
 	defer streamCancel()
 	stream, err := client.StreamExecution(streamCtx, &cleanroomv1.StreamExecutionRequest{
 		SandboxId:   sandboxID,
@@ -856,22 +1424,38 @@ func (e *ExecCommand) Run(ctx *runtimeContext) error {
 		}
 	}()
 
+	var stdout, stderr io.Writer = ctx.Stdout, os.Stderr
+	var report *execReport
+	if e.Format == "json" {
+		report = &execReport{SandboxID: sandboxID, ExecutionID: executionID}
+		stdout, stderr = &report.stdout, &report.stderr
+	}
+
 	var exitCode int
 	haveExitCode := false
 	for stream.Receive() {
 		event := stream.Msg()
 		switch payload := event.Payload.(type) {
 		case *cleanroomv1.ExecutionStreamEvent_Stdout:
-			if _, err := fmt.Fprint(ctx.Stdout, string(payload.Stdout)); err != nil {
+			if _, err := stdout.Write(payload.Stdout); err != nil {
 				return err
 			}
 		case *cleanroomv1.ExecutionStreamEvent_Stderr:
-			if _, err := fmt.Fprint(os.Stderr, string(payload.Stderr)); err != nil {
+			if _, err := stderr.Write(payload.Stderr); err != nil {
 				return err
 			}
+		case *cleanroomv1.ExecutionStreamEvent_Environment:
+			if report != nil {
+				report.setEnvironment(payload.Environment)
+			}
 		case *cleanroomv1.ExecutionStreamEvent_Exit:
 			exitCode = int(payload.Exit.GetExitCode())
 			haveExitCode = true
+			if report != nil {
+				report.setExit(payload.Exit)
+			} else if results := payload.Exit.GetTestResults(); results != nil {
+				fmt.Fprintln(os.Stderr, "cleanroom: "+formatTestResults(results))
+			}
 		}
 	}
 
@@ -886,12 +1470,23 @@ func (e *ExecCommand) Run(ctx *runtimeContext) error {
 	default:
 	}
 
+	select {
+	case err := <-stdinErr:
+		return err
+	default:
+	}
+
+	awaitServerDeadline(cmdCtx, streamErr)
+	if !haveExitCode && cmdCtx.Err() != nil {
+		cancelTimedOutExecution(client, sandboxID, executionID, logger)
+		return cmdCtx.Err()
+	}
 	if streamErr != nil && !isCanceledStreamErr(streamErr) {
 		return fmt.Errorf("stream execution: %w", streamErr)
 	}
 
 	if !haveExitCode {
-		if fetchedExitCode, ok := getFinalExecutionExitCode(client, sandboxID, executionID); ok {
+		if fetchedExitCode, ok := getFinalExecutionExitCode(cmdCtx, client, sandboxID, executionID); ok {
 			exitCode = fetchedExitCode
 			haveExitCode = true
 		}
@@ -907,14 +1502,156 @@ func (e *ExecCommand) Run(ctx *runtimeContext) error {
 	if !haveExitCode {
 		return errors.New("execution stream ended without exit status")
 	}
+	if report != nil {
+		report.ExitCode = exitCode
+		if err := report.write(ctx.Stdout); err != nil {
+			return err
+		}
+	}
 	if exitCode != 0 {
 		return exitCodeError{code: exitCode}
 	}
 	return nil
 }
 
+func executionLauncherFromFlag(value string) cleanroomv1.ExecutionLauncher {
+	switch value {
+	case "direct":
+		return cleanroomv1.ExecutionLauncher_EXECUTION_LAUNCHER_DIRECT
+	case "systemd":
+		return cleanroomv1.ExecutionLauncher_EXECUTION_LAUNCHER_SYSTEMD
+	default:
+		return cleanroomv1.ExecutionLauncher_EXECUTION_LAUNCHER_UNSPECIFIED
+	}
+}
+
+func changeCaptureFromFlag(value string) cleanroomv1.ExecutionChangeCapture {
+	switch value {
+	case "manifest":
+		return cleanroomv1.ExecutionChangeCapture_EXECUTION_CHANGE_CAPTURE_MANIFEST
+	case "archive":
+		return cleanroomv1.ExecutionChangeCapture_EXECUTION_CHANGE_CAPTURE_ARCHIVE
+	default:
+		return cleanroomv1.ExecutionChangeCapture_EXECUTION_CHANGE_CAPTURE_UNSPECIFIED
+	}
+}
+
+// streamExecutionStdin copies r to the execution's stdin in chunks and then
+// closes it, so the command sees the same bytes a shell redirect would give.
+// It waits for the execution to start first, since a queued or booting
+// execution has no stdin to write to yet.
+func streamExecutionStdin(ctx context.Context, client *controlclient.Client, sandboxID, executionID string, r io.Reader) error {
+	if err := waitForExecutionRunning(ctx, client, sandboxID, executionID); err != nil {
+		return err
+	}
+	buf := make([]byte, execStdinChunkBytes)
+	for {
+		n, readErr := r.Read(buf)
+		eof := errors.Is(readErr, io.EOF)
+		if readErr != nil && !eof {
+			return fmt.Errorf("read stdin file: %w", readErr)
+		}
+		if n > 0 || eof {
+			if _, err := client.WriteExecutionStdin(ctx, &cleanroomv1.WriteExecutionStdinRequest{
+				SandboxId:   sandboxID,
+				ExecutionId: executionID,
+				Data:        append([]byte(nil), buf[:n]...),
+				Eof:         eof,
+			}); err != nil {
+				return err
+			}
+		}
+		if eof {
+			return nil
+		}
+	}
+}
+
+// waitForExecutionRunning polls until the execution is running, failing if
+// it finishes first.
+func waitForExecutionRunning(ctx context.Context, client *controlclient.Client, sandboxID, executionID string) error {
+	for {
+		resp, err := client.GetExecution(ctx, &cleanroomv1.GetExecutionRequest{
+			SandboxId:   sandboxID,
+			ExecutionId: executionID,
+		})
+		if err != nil {
+			return fmt.Errorf("wait for execution to start: %w", err)
+		}
+		switch status := resp.GetExecution().GetStatus(); status {
+		case cleanroomv1.ExecutionStatus_EXECUTION_STATUS_RUNNING:
+			return nil
+		case cleanroomv1.ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED,
+			cleanroomv1.ExecutionStatus_EXECUTION_STATUS_QUEUED,
+			cleanroomv1.ExecutionStatus_EXECUTION_STATUS_PENDING_APPROVAL:
+		default:
+			return fmt.Errorf("execution ended with status %s before it started", status)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(execStartPollInterval):
+		}
+	}
+}
+
+// executionFinished reports whether the execution reaches a final status
+// within grace. A failed stdin write is expected then: the command exited
+// without reading all of it.
+func executionFinished(ctx context.Context, client *controlclient.Client, sandboxID, executionID string, grace time.Duration) bool {
+	deadline := time.Now().Add(grace)
+	for {
+		resp, err := client.GetExecution(ctx, &cleanroomv1.GetExecutionRequest{
+			SandboxId:   sandboxID,
+			ExecutionId: executionID,
+		})
+		if err != nil {
+			return false
+		}
+		switch resp.GetExecution().GetStatus() {
+		case cleanroomv1.ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED,
+			cleanroomv1.ExecutionStatus_EXECUTION_STATUS_QUEUED,
+			cleanroomv1.ExecutionStatus_EXECUTION_STATUS_RUNNING,
+			cleanroomv1.ExecutionStatus_EXECUTION_STATUS_PENDING_APPROVAL:
+		default:
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(execStartPollInterval):
+		}
+	}
+}
+
+// resourceLimits returns the guest limits requested via flags, or nil when
+// none were set.
+func (e *ExecCommand) resultParsers() *cleanroomv1.ExecutionResultParsers {
+	if !e.GoTestJSON && len(e.JUnit) == 0 {
+		return nil
+	}
+	return &cleanroomv1.ExecutionResultParsers{GoTestJson: e.GoTestJSON, JunitPaths: e.JUnit}
+}
+
+func (e *ExecCommand) resourceLimits() *cleanroomv1.ExecutionResourceLimits {
+	ioClass := strings.TrimSpace(e.IOClass)
+	if e.Nice == 0 && ioClass == "" && e.IOPriority == 0 && e.CPUWeight == 0 && e.MemoryMaxMiB == 0 {
+		return nil
+	}
+	return &cleanroomv1.ExecutionResourceLimits{
+		Nice:           e.Nice,
+		IoClass:        ioClass,
+		IoPriority:     e.IOPriority,
+		CpuWeight:      e.CPUWeight,
+		MemoryMaxBytes: e.MemoryMaxMiB * 1024 * 1024,
+	}
+}
+
 func (c *ConsoleCommand) Run(ctx *runtimeContext) error {
-	logger, err := newLogger(c.LogLevel, "client")
+	logger, err := newLogger(c.LogLevel, c.LogFormat, "client")
 	if err != nil {
 		return err
 	}
@@ -927,6 +1664,10 @@ func (c *ConsoleCommand) Run(ctx *runtimeContext) error {
 	if err != nil {
 		return err
 	}
+	policyDir, err := resolvePolicyDir(cwd, c.PolicyDir)
+	if err != nil {
+		return err
+	}
 
 	command := append([]string(nil), c.Command...)
 	if len(command) == 0 {
@@ -938,7 +1679,8 @@ func (c *ConsoleCommand) Run(ctx *runtimeContext) error {
 		"sandbox_id", strings.TrimSpace(c.SandboxID),
 		"command_argc", len(command),
 	)
-	sandboxID, err := ensureSandboxID(client, ctx.Loader, cwd, c.Host, c.Backend, strings.TrimSpace(c.SandboxID), c.Image, c.LaunchSeconds)
+	cmdCtx := ctx.commandContext()
+	sandboxID, err := ensureSandboxID(cmdCtx, client, ctx.Loader, policyDir, c.Host, c.Backend, strings.TrimSpace(c.SandboxID), c.Image, nil, c.LaunchSeconds)
 	if err != nil {
 		return err
 	}
@@ -950,7 +1692,7 @@ func (c *ConsoleCommand) Run(ctx *runtimeContext) error {
 		terminateSandboxBestEffort(client, sandboxID, 0, logger, "terminate sandbox after console failed")
 	}()
 
-	createExecutionResp, err := client.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+	createExecutionResp, err := client.CreateExecution(cmdCtx, &cleanroomv1.CreateExecutionRequest{
 		SandboxId: sandboxID,
 		Command:   command,
 		Kind:      cleanroomv1.ExecutionKind_EXECUTION_KIND_INTERACTIVE,
@@ -963,11 +1705,14 @@ func (c *ConsoleCommand) Run(ctx *runtimeContext) error {
 		return fmt.Errorf("create execution: %w", err)
 	}
 	executionID := createExecutionResp.GetExecution().GetExecutionId()
+	if err := waitForExecutionApproval(cmdCtx, client, createExecutionResp.GetExecution(), os.Stderr); err != nil {
+		return err
+	}
 	logger.Debug("console execution started", "sandbox_id", sandboxID, "execution_id", executionID)
 
 	stdinFD := int(os.Stdin.Fd())
 	initialCols, initialRows := attachTTYSize(stdinFD)
-	openResp, err := client.OpenInteractiveExecution(context.Background(), &cleanroomv1.OpenInteractiveExecutionRequest{
+	openResp, err := client.OpenInteractiveExecution(cmdCtx, &cleanroomv1.OpenInteractiveExecutionRequest{
 		SandboxId:   sandboxID,
 		ExecutionId: executionID,
 		InitialCols: initialCols,
@@ -975,12 +1720,12 @@ func (c *ConsoleCommand) Run(ctx *runtimeContext) error {
 	})
 	if err != nil {
 		if isExecutionNoLongerActiveErr(err) {
-			exitCode, haveExitCode, replayErr := replayExecutionHistory(client, sandboxID, executionID, ctx.Stdout, os.Stderr)
+			exitCode, haveExitCode, replayErr := replayExecutionHistory(cmdCtx, client, sandboxID, executionID, ctx.Stdout, os.Stderr)
 			if replayErr != nil {
 				return fmt.Errorf("open interactive execution: %w", err)
 			}
 			if !haveExitCode {
-				if fetchedExitCode, ok := getFinalExecutionExitCode(client, sandboxID, executionID); ok {
+				if fetchedExitCode, ok := getFinalExecutionExitCode(cmdCtx, client, sandboxID, executionID); ok {
 					exitCode = fetchedExitCode
 					haveExitCode = true
 				}
@@ -1001,7 +1746,7 @@ func (c *ConsoleCommand) Run(ctx *runtimeContext) error {
 	}
 	quicEndpoint := resolveInteractiveDialEndpoint(controlEndpoint, openResp.GetQuicEndpoint())
 	interactiveSession, err := interactivequic.Dial(
-		context.Background(),
+		cmdCtx,
 		quicEndpoint,
 		openResp.GetAlpn(),
 		openResp.GetServerCertPinSha256(),
@@ -1012,17 +1757,27 @@ func (c *ConsoleCommand) Run(ctx *runtimeContext) error {
 		return resolveConsoleDialFailure(
 			err,
 			func() (int, bool, error) {
-				return replayExecutionHistory(client, sandboxID, executionID, ctx.Stdout, os.Stderr)
+				return replayExecutionHistory(cmdCtx, client, sandboxID, executionID, ctx.Stdout, os.Stderr)
 			},
 			func() (int, bool) {
-				return getFinalExecutionExitCode(client, sandboxID, executionID)
+				return getFinalExecutionExitCode(cmdCtx, client, sandboxID, executionID)
 			},
 		)
 	}
 	defer interactiveSession.Close()
+	stopTimeout := context.AfterFunc(cmdCtx, func() {
+		cancelTimedOutExecution(client, sandboxID, executionID, logger)
+		_ = interactiveSession.Close()
+	})
+	defer stopTimeout()
 
+	// Without a terminal on both ends (for example in CI logs), fall back to
+	// line mode: no raw mode, stdin forwarded line by line, and cursor-control
+	// sequences stripped from output.
+	lineMode := useConsoleLineMode(c.ForceTTY, os.Stdin, ctx.Stdout)
+	var lineFilter consoleLineFilter
 	rawMode := false
-	if term.IsTerminal(stdinFD) {
+	if !lineMode && term.IsTerminal(stdinFD) {
 		oldState, rawErr := term.MakeRaw(stdinFD)
 		if rawErr != nil {
 			logger.Warn("failed to enter raw mode", "error", rawErr)
@@ -1037,6 +1792,19 @@ func (c *ConsoleCommand) Run(ctx *runtimeContext) error {
 		}
 	}
 
+	writeStdin := interactiveSession.WriteStdin
+	var handoff *consoleHandoff
+	if c.MountClipboard {
+		clipboardDir := cwd
+		if strings.TrimSpace(c.ClipboardDir) != "" {
+			clipboardDir = c.ClipboardDir
+		}
+		handoff = newConsoleHandoff(clipboardDir, os.Stderr, rawMode)
+		writeStdin = func(data []byte) error {
+			return interactiveSession.WriteStdin(handoff.FilterInput(data))
+		}
+	}
+
 	signalCh := newSignalChannel()
 	notifySignals(signalCh, os.Interrupt, syscall.SIGTERM)
 	defer stopSignals(signalCh)
@@ -1066,22 +1834,26 @@ func (c *ConsoleCommand) Run(ctx *runtimeContext) error {
 		}
 	}()
 
-	go func() {
-		buf := make([]byte, 4096)
-		for {
-			n, readErr := os.Stdin.Read(buf)
-			if n > 0 {
-				payload := append([]byte(nil), buf[:n]...)
-				if sendErr := interactiveSession.WriteStdin(payload); sendErr != nil {
+	if lineMode {
+		go forwardConsoleLines(os.Stdin, writeStdin, interactiveSession.CloseStdin)
+	} else {
+		go func() {
+			buf := make([]byte, 4096)
+			for {
+				n, readErr := os.Stdin.Read(buf)
+				if n > 0 {
+					payload := append([]byte(nil), buf[:n]...)
+					if sendErr := writeStdin(payload); sendErr != nil {
+						return
+					}
+				}
+				if readErr != nil {
+					_ = interactiveSession.CloseStdin()
 					return
 				}
 			}
-			if readErr != nil {
-				_ = interactiveSession.CloseStdin()
-				return
-			}
-		}
-	}()
+		}()
+	}
 
 	exitCodeCh := make(chan int, 1)
 	controlErrCh := make(chan error, 1)
@@ -1116,8 +1888,13 @@ func (c *ConsoleCommand) Run(ctx *runtimeContext) error {
 		n, readErr := interactiveSession.ReadPTY(buf)
 		if n > 0 {
 			chunk := append([]byte(nil), buf[:n]...)
+			if handoff != nil {
+				chunk = handoff.FilterOutput(chunk)
+			}
 			if rawMode {
 				chunk, endedCR = normalizeLineEndingsForRawTTY(chunk, endedCR)
+			} else if lineMode {
+				chunk = lineFilter.Filter(chunk)
 			}
 			if _, err := ctx.Stdout.Write(chunk); err != nil {
 				return err
@@ -1136,6 +1913,21 @@ func (c *ConsoleCommand) Run(ctx *runtimeContext) error {
 			break
 		}
 	}
+	if handoff != nil {
+		if tail := handoff.Flush(); len(tail) > 0 {
+			if lineMode {
+				tail = lineFilter.Filter(tail)
+			}
+			if _, err := ctx.Stdout.Write(tail); err != nil {
+				return err
+			}
+		}
+	}
+	if tail := lineFilter.Flush(); len(tail) > 0 {
+		if _, err := ctx.Stdout.Write(tail); err != nil {
+			return err
+		}
+	}
 
 	if !haveExitCode {
 		waitedExitCode, gotExitCode, waitErr := waitForInteractiveExitOrControlErr(exitCodeCh, &controlErrCh, 2*time.Second)
@@ -1149,7 +1941,7 @@ func (c *ConsoleCommand) Run(ctx *runtimeContext) error {
 	}
 
 	if !haveExitCode {
-		if fetchedExitCode, ok := getFinalExecutionExitCode(client, sandboxID, executionID); ok {
+		if fetchedExitCode, ok := getFinalExecutionExitCode(cmdCtx, client, sandboxID, executionID); ok {
 			exitCode = fetchedExitCode
 			haveExitCode = true
 		}
@@ -1242,6 +2034,9 @@ func (s *ServeCommand) daemonRunArgs(cwd string) ([]string, error) {
 	if value := strings.TrimSpace(s.LogLevel); value != "" {
 		args = append(args, "--log-level", value)
 	}
+	if value := strings.TrimSpace(s.LogFormat); value != "" {
+		args = append(args, "--log-format", value)
+	}
 	if value := strings.TrimSpace(s.TLSCert); value != "" {
 		resolved, err := resolveDaemonInstallPath(cwd, value)
 		if err != nil {
@@ -1499,6 +2294,7 @@ Wants=network-online.target
 [Service]
 Type=simple
 ExecStart=%s
+ExecReload=/bin/kill -HUP $MAINPID
 Restart=on-failure
 RestartSec=5
 
@@ -1579,6 +2375,8 @@ func (s *ServeCommand) runServer(ctx *runtimeContext) error {
 	if err != nil {
 		return err
 	}
+	logCfg := ctx.Config.Logging
+	logLevel := cmp.Or(strings.TrimSpace(s.LogLevel), logCfg.Level)
 	if shouldShowStartupHeader(os.Stderr) {
 		gatewayListen := strings.TrimSpace(s.GatewayListen)
 		if gatewayListen == "" {
@@ -1591,26 +2389,43 @@ func (s *ServeCommand) runServer(ctx *runtimeContext) error {
 				{Key: "listen", Value: endpointDisplay(ep)},
 				{Key: "gateway_listen", Value: gatewayListen},
 				{Key: "runtime_config", Value: ctx.ConfigPath},
-				{Key: "log_level", Value: effectiveLogLevel(s.LogLevel)},
+				{Key: "log_level", Value: effectiveLogLevel(logLevel)},
+				{Key: "log_file", Value: logCfg.File},
 			},
 		}, shouldUseANSI(os.Stderr)); err != nil {
 			return err
 		}
 	}
 
-	logger, err := newLogger(s.LogLevel, "server")
+	logger, logFile, err := newServeLogger(logLevel, cmp.Or(strings.TrimSpace(s.LogFormat), logCfg.Format), logCfg)
 	if err != nil {
 		return err
 	}
+	if logFile != nil {
+		defer logFile.Close()
+	}
 	log.SetDefault(logger)
+	subsystemLogger := func(name string) *log.Logger {
+		return logging.ForSubsystem(logger, name, logCfg.Levels)
+	}
 
 	gwRegistry := gateway.NewRegistry()
 	gwCredentials := gateway.NewEnvCredentialProvider()
+	ociRegistryCacheDir, err := paths.OCIRegistryCacheDir()
+	if err != nil {
+		return fmt.Errorf("resolve oci registry cache dir: %w", err)
+	}
+	packageCacheDir, err := paths.PackageCacheDir()
+	if err != nil {
+		return fmt.Errorf("resolve package cache dir: %w", err)
+	}
 	gwServer := gateway.NewServer(gateway.ServerConfig{
-		ListenAddr:  s.GatewayListen,
-		Registry:    gwRegistry,
-		Credentials: gwCredentials,
-		Logger:      logger.With("subsystem", "gateway"),
+		ListenAddr:          s.GatewayListen,
+		Registry:            gwRegistry,
+		Credentials:         gwCredentials,
+		Logger:              subsystemLogger("gateway"),
+		OCIRegistryCacheDir: ociRegistryCacheDir,
+		PackageCacheDir:     packageCacheDir,
 	})
 	if err := gwServer.Start(); err != nil {
 		return fmt.Errorf("start gateway: %w", err)
@@ -1626,6 +2441,8 @@ func (s *ServeCommand) runServer(ctx *runtimeContext) error {
 	if fcAdapter, ok := ctx.Backends["firecracker"].(*firecracker.Adapter); ok {
 		fcAdapter.GatewayRegistry = gwRegistry
 		fcAdapter.GatewayPort = gwPort
+		fcAdapter.Logger = subsystemLogger("firecracker")
+		fcAdapter.NetworkLogger = subsystemLogger("network")
 
 		if shouldInstallGatewayFirewall(runtime.GOOS) {
 			fwCfg := backend.FirecrackerConfig{
@@ -1634,7 +2451,7 @@ func (s *ServeCommand) runServer(ctx *runtimeContext) error {
 			}
 			fwCleanup, err := firecracker.SetupGatewayFirewall(context.Background(), gwPort, fwCfg)
 			if err != nil {
-				logger.Warn("failed to install gateway firewall rules", "error", err)
+				fcAdapter.NetworkLogger.Warn("failed to install gateway firewall rules", "error", err)
 			} else {
 				defer fwCleanup()
 			}
@@ -1643,31 +2460,121 @@ func (s *ServeCommand) runServer(ctx *runtimeContext) error {
 	if darwinAdapter, ok := ctx.Backends["darwin-vz"].(*darwinvz.Adapter); ok {
 		darwinAdapter.GatewayRegistry = gwRegistry
 		darwinAdapter.GatewayPort = gwPort
+		darwinAdapter.Logger = subsystemLogger("darwin-vz")
 		if host := strings.TrimSpace(os.Getenv("CLEANROOM_DARWIN_GATEWAY_HOST")); host != "" {
 			darwinAdapter.GatewayHost = host
 		}
 	}
 
+	imageCache, err := imagemgr.New(imagemgr.Options{})
+	if err != nil {
+		return err
+	}
+	if sharing := ctx.Config.CacheSharing; sharing.Enabled() {
+		stopSharing, err := startCacheSharing(sharing, imageCache, ctx.Backends, subsystemLogger("cache-sharing"))
+		if err != nil {
+			return err
+		}
+		defer stopSharing()
+	}
+
 	var serverTLS *controlserver.TLSOptions
 	if ep.Scheme == "https" {
 		serverTLS = &controlserver.TLSOptions{
 			CertPath: s.TLSCert,
 			KeyPath:  s.TLSKey,
 		}
+		if stateCfg := ctx.Config.State; stateCfg.Enabled() {
+			serverTLS.PeerCAPath = stateCfg.TLSCA
+		}
 	}
 
 	service := &controlservice.Service{
 		Loader:   ctx.Loader,
 		Config:   ctx.Config,
 		Backends: ctx.Backends,
-		Logger:   logger.With("subsystem", "service"),
+		Logger:   subsystemLogger("service"),
+		Checkout: &checkout.Fetcher{Credentials: gwCredentials},
+		Version:  ctx.Version,
+		Images:   imageCache,
+	}
+	if natsURL := ctx.Config.Events.NATSURL; natsURL != "" {
+		publisher, err := eventbus.DialNATS(natsURL, cmp.Or(ctx.Config.Events.SubjectPrefix, defaultEventSubjectPrefix), subsystemLogger("events"))
+		if err != nil {
+			return err
+		}
+		defer publisher.Close()
+		service.Events = publisher
+	}
+	if sinkURL := ctx.Config.LogShipping.URL; sinkURL != "" {
+		sink, err := logship.NewSink(sinkURL, logship.Options{
+			Region:      ctx.Config.LogShipping.Region,
+			Endpoint:    ctx.Config.LogShipping.Endpoint,
+			Credentials: gwCredentials,
+		})
+		if err != nil {
+			return err
+		}
+		spoolDir, err := paths.LogSpoolDir()
+		if err != nil {
+			return err
+		}
+		shipper, err := logship.New(sink, spoolDir, subsystemLogger("log-shipping"))
+		if err != nil {
+			return err
+		}
+		defer shipper.Close()
+		service.Logs = shipper
+	}
+	if usagePath, err := paths.UsageDBPath(); err != nil {
+		logger.Warn("usage accounting disabled", "error", err)
+	} else if usageStore, err := usage.Open(context.Background(), usagePath); err != nil {
+		logger.Warn("usage accounting disabled", "error", err)
+	} else {
+		defer usageStore.Close()
+		service.Usage = usageStore
+	}
+	if schedulePath, err := paths.ScheduleDBPath(); err != nil {
+		logger.Warn("schedules disabled", "error", err)
+	} else if scheduleStore, err := schedule.Open(context.Background(), schedulePath); err != nil {
+		logger.Warn("schedules disabled", "error", err)
+	} else {
+		defer scheduleStore.Close()
+		service.Schedules = scheduleStore
+	}
+	runStore, err := openRunStore(ctx.Config.Storage, gwCredentials)
+	if err != nil {
+		return err
+	}
+	service.RunStore = runStore
+	server := controlserver.New(service, subsystemLogger("http"))
+	if stateCfg := ctx.Config.State; stateCfg.Enabled() {
+		shared, err := openSharedState(stateCfg, subsystemLogger("shared-state"))
+		if err != nil {
+			return err
+		}
+		defer shared.Close()
+		service.Shared = shared
+		server.ForwardToOwners(shared.HostID(), tlsconfig.Options{
+			CertPath: stateCfg.TLSCert,
+			KeyPath:  stateCfg.TLSKey,
+			CAPath:   stateCfg.TLSCA,
+		})
 	}
-	server := controlserver.New(service, logger.With("subsystem", "http"))
 
 	runCtx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
+	go reloadConfigOnSIGHUP(runCtx, ctx, service, subsystemLogger("config"))
+	if runBaseDir, err := paths.RunBaseDir(); err == nil {
+		go service.SweepRunDirs(runCtx, runBaseDir)
+	} else {
+		logger.Warn("run directory retention disabled", "error", err)
+	}
+	if service.Schedules != nil {
+		go service.RunSchedules(runCtx)
+	}
 	interactiveListen, interactiveHost := resolveInteractiveQUICEndpoint(ep)
-	interactiveServer, err := interactivequic.Start(runCtx, interactiveListen, service, logger.With("subsystem", "interactive-quic"))
+	interactiveServer, err := interactivequic.Start(runCtx, interactiveListen, service, subsystemLogger("interactive-quic"))
 	if err != nil {
 		return fmt.Errorf("start interactive quic server: %w", err)
 	}
@@ -1840,9 +2747,10 @@ func (d *DoctorCommand) Run(ctx *runtimeContext) error {
 	compiled, source, err := ctx.Loader.LoadAndCompile(cwd)
 	if err != nil {
 		checks = append(checks, backend.DoctorCheck{
-			Name:    "repository_policy",
-			Status:  "warn",
-			Message: fmt.Sprintf("policy not loaded from %s: %v", cwd, err),
+			Name:        "repository_policy",
+			Status:      "warn",
+			Message:     fmt.Sprintf("policy not loaded from %s: %v", cwd, err),
+			Remediation: "add a cleanroom.yaml to the repository and check it with cleanroom policy validate",
 		})
 	} else {
 		checks = append(checks, backend.DoctorCheck{
@@ -1851,6 +2759,9 @@ func (d *DoctorCommand) Run(ctx *runtimeContext) error {
 			Message: fmt.Sprintf("policy loaded from %s (hash %s)", source, compiled.Hash),
 		})
 	}
+	checks = append(checks, vfioDoctorChecks(pciDevicesDir, ctx.Config.Devices.VFIO, backendName, capabilities[backend.CapabilityDeviceVFIO], compiled)...)
+
+	assignDoctorCheckIDs("cleanroom", checks)
 
 	type doctorCapable interface {
 		Doctor(context.Context, backend.DoctorRequest) (*backend.DoctorReport, error)
@@ -1858,23 +2769,38 @@ func (d *DoctorCommand) Run(ctx *runtimeContext) error {
 	if checker, ok := adapter.(doctorCapable); ok {
 		report, err := checker.Doctor(context.Background(), backend.DoctorRequest{
 			Policy:            compiled,
+			Deep:              d.Deep,
 			FirecrackerConfig: mergeBackendConfig(backendName, 0, ctx.Config),
 		})
 		if err != nil {
 			return err
 		}
-		checks = append(checks, report.Checks...)
+		checks = append(checks, assignDoctorCheckIDs(backendName, report.Checks)...)
 	} else {
 		checks = append(checks, backend.DoctorCheck{
+			ID:      "cleanroom.backend_doctor",
 			Name:    "backend_doctor",
 			Status:  "warn",
 			Message: "selected backend does not expose doctor diagnostics",
 		})
 	}
 
+	failOn := d.FailOn
+	if failOn == "" {
+		failOn = "fail"
+	}
+	summary := summarizeDoctorChecks(checks)
+	var thresholdErr error
+	if count := summary.atOrAbove(failOn); count > 0 {
+		thresholdErr = doctorThresholdError{count: count, failOn: failOn}
+	}
+
 	if d.JSON {
 		payload := map[string]any{
 			"backend":      backendName,
+			"status":       summary.status(),
+			"fail_on":      failOn,
+			"summary":      summary,
 			"capabilities": backend.CloneCapabilities(capabilities),
 			"checks":       checks,
 			"gateway": map[string]any{
@@ -1886,11 +2812,67 @@ func (d *DoctorCommand) Run(ctx *runtimeContext) error {
 		}
 		enc := json.NewEncoder(ctx.Stdout)
 		enc.SetIndent("", "  ")
-		return enc.Encode(payload)
+		if err := enc.Encode(payload); err != nil {
+			return err
+		}
+		return thresholdErr
 	}
 
-	_, err = fmt.Fprint(ctx.Stdout, renderDoctorReport(backendName, checks, shouldUseANSI(ctx.Stdout)))
-	return err
+	if _, err := fmt.Fprint(ctx.Stdout, renderDoctorReport(backendName, checks, shouldUseANSI(ctx.Stdout))); err != nil {
+		return err
+	}
+	return thresholdErr
+}
+
+// assignDoctorCheckIDs fills in missing check IDs as "<scope>.<name>".
+func assignDoctorCheckIDs(scope string, checks []backend.DoctorCheck) []backend.DoctorCheck {
+	for i := range checks {
+		if strings.TrimSpace(checks[i].ID) == "" {
+			checks[i].ID = scope + "." + checks[i].Name
+		}
+	}
+	return checks
+}
+
+type doctorSummary struct {
+	Pass int `json:"pass"`
+	Warn int `json:"warn"`
+	Fail int `json:"fail"`
+}
+
+func summarizeDoctorChecks(checks []backend.DoctorCheck) doctorSummary {
+	var summary doctorSummary
+	for _, check := range checks {
+		switch normalizeDoctorStatus(check.Status) {
+		case "pass":
+			summary.Pass++
+		case "warn":
+			summary.Warn++
+		case "fail":
+			summary.Fail++
+		}
+	}
+	return summary
+}
+
+// status returns the worst status across all checks.
+func (s doctorSummary) status() string {
+	switch {
+	case s.Fail > 0:
+		return "fail"
+	case s.Warn > 0:
+		return "warn"
+	default:
+		return "pass"
+	}
+}
+
+// atOrAbove counts checks whose severity is at least threshold.
+func (s doctorSummary) atOrAbove(threshold string) int {
+	if threshold == "warn" {
+		return s.Warn + s.Fail
+	}
+	return s.Fail
 }
 
 func resolveBackendName(requested, configuredDefault string) string {
@@ -1932,6 +2914,16 @@ func mergeBackendConfig(backendName string, launchSeconds int64, cfg runtimeconf
 		GuestCID:             cfg.Backends.Firecracker.GuestCID,
 		GuestPort:            cfg.Backends.Firecracker.GuestPort,
 		LaunchSeconds:        cfg.Backends.Firecracker.LaunchSeconds,
+		CPUTemplate:          cfg.Backends.Firecracker.CPUTemplate,
+		NetworkPool:          cfg.Backends.Firecracker.NetworkPool,
+		MTU:                  cfg.Backends.Firecracker.MTU,
+		EgressMbps:           cfg.Backends.Firecracker.EgressMbps,
+		IngressMbps:          cfg.Backends.Firecracker.IngressMbps,
+		DiskIOPS:             cfg.Backends.Firecracker.DiskIOPS,
+		DiskMiBps:            cfg.Backends.Firecracker.DiskMiBps,
+		NetworkNamespaces:    cfg.Backends.Firecracker.NetworkNamespaces,
+		ScratchFiles:         cfg.Backends.Firecracker.ScratchFiles,
+		SMT:                  cfg.Backends.Firecracker.SMT,
 	}
 	if backendName == "darwin-vz" {
 		out.KernelImagePath = cfg.Backends.DarwinVZ.KernelImage
@@ -1960,7 +2952,21 @@ func (s *StatusCommand) Run(ctx *runtimeContext) error {
 	if s.RunID != "" && s.LastRun {
 		return errors.New("choose either --run-id or --last-run")
 	}
+	store, err := statusRunStore(ctx.Config.Storage)
+	if err != nil {
+		return err
+	}
+	storeLocation := strings.TrimSpace(ctx.Config.Storage.Runs)
 	if s.RunID != "" {
+		if store != nil {
+			if _, err := os.Stat(filepath.Join(baseDir, s.RunID)); errors.Is(err, os.ErrNotExist) {
+				err := inspectArchivedRun(context.Background(), ctx.Stdout, store, storeLocation, s.RunID)
+				if errors.Is(err, os.ErrNotExist) {
+					return fmt.Errorf("run %q not found in %s or %s", s.RunID, baseDir, storeLocation)
+				}
+				return err
+			}
+		}
 		return inspectRun(ctx.Stdout, baseDir, s.RunID)
 	}
 	if s.LastRun {
@@ -1995,7 +3001,7 @@ func (s *StatusCommand) Run(ctx *runtimeContext) error {
 	}
 
 	entries, err := os.ReadDir(baseDir)
-	if err != nil {
+	if err != nil && !(errors.Is(err, os.ErrNotExist) && store != nil) {
 		if errors.Is(err, os.ErrNotExist) {
 			_, werr := fmt.Fprintf(ctx.Stdout, "no runs found (%s does not exist)\n", baseDir)
 			return werr
@@ -2003,22 +3009,40 @@ func (s *StatusCommand) Run(ctx *runtimeContext) error {
 		return err
 	}
 
-	if len(entries) == 0 {
+	local := map[string]bool{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			local[entry.Name()] = true
+		}
+	}
+	if len(local) == 0 && store == nil {
 		_, err := fmt.Fprintf(ctx.Stdout, "no runs found in %s\n", baseDir)
 		return err
 	}
 
-	_, err = fmt.Fprintf(ctx.Stdout, "runs in %s:\n", baseDir)
+	if len(local) > 0 {
+		if _, err := fmt.Fprintf(ctx.Stdout, "runs in %s:\n", baseDir); err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			if _, err := fmt.Fprintf(ctx.Stdout, "- %s\n", entry.Name()); err != nil {
+				return err
+			}
+		}
+	}
+	if store == nil {
+		return nil
+	}
+	archived, err := listArchivedRuns(context.Background(), ctx.Stdout, store, storeLocation, local)
 	if err != nil {
 		return err
 	}
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
-		if _, err := fmt.Fprintf(ctx.Stdout, "- %s\n", entry.Name()); err != nil {
-			return err
-		}
+	if len(local) == 0 && archived == 0 {
+		_, err := fmt.Fprintf(ctx.Stdout, "no runs found in %s or %s\n", baseDir, storeLocation)
+		return err
 	}
 	return nil
 }
@@ -2034,7 +3058,7 @@ func inspectRun(stdout *os.File, baseDir, runID string) error {
 	if _, err := fmt.Fprintf(stdout, "run: %s\n", runDir); err != nil {
 		return err
 	}
-	obsPath := filepath.Join(runDir, "run-observability.json")
+	obsPath := filepath.Join(runDir, rundir.ObservabilityFile)
 	b, err := os.ReadFile(obsPath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -2055,28 +3079,73 @@ func inspectRun(stdout *os.File, baseDir, runID string) error {
 	return err
 }
 
-func ensureSandboxID(client *controlclient.Client, loader policyLoader, cwd, host, backendName, existingSandboxID, imageRefOverride string, launchSeconds int64) (string, error) {
+func ensureSandboxID(ctx context.Context, client *controlclient.Client, loader policyLoader, policyDir, host, backendName, existingSandboxID, imageRefOverride string, allow []string, launchSeconds int64) (string, error) {
 	sandboxID := strings.TrimSpace(existingSandboxID)
 	if sandboxID != "" {
 		if strings.TrimSpace(imageRefOverride) != "" {
 			return "", errors.New("--image cannot be used with --sandbox-id")
 		}
+		if len(allow) > 0 {
+			return "", errors.New("--allow cannot be used with --sandbox-id")
+		}
 		return sandboxID, nil
 	}
 
-	compiled, _, err := loader.LoadAndCompile(cwd)
+	compiled, _, err := compileSandboxPolicy(ctx, loader, policyDir, host, imageRefOverride, allow)
 	if err != nil {
 		return "", err
 	}
+	return createSandboxForPolicy(ctx, client, backendName, compiled, launchSeconds)
+}
+
+// compileSandboxPolicy loads the policy for cwd and applies --image. With
+// no policy file, an --image builds an inline policy allowing egress only
+// to allow; allow is refused alongside a policy file, whose own allow list
+// applies.
+func compileSandboxPolicy(ctx context.Context, loader policyLoader, cwd, host, imageRefOverride string, allow []string) (*policy.CompiledPolicy, string, error) {
 	allowLocalImageOverride, err := isLocalControlPlaneEndpoint(host)
 	if err != nil {
-		return "", err
+		return nil, "", err
+	}
+	compiled, source, err := loader.LoadAndCompile(cwd)
+	if errors.Is(err, policy.ErrPolicyNotFound) && strings.TrimSpace(imageRefOverride) != "" {
+		compiled, err := inlineSandboxPolicy(ctx, imageRefOverride, allow, allowLocalImageOverride)
+		return compiled, inlinePolicySource, err
+	}
+	if errors.Is(err, policy.ErrPolicyNotFound) && len(allow) > 0 {
+		return nil, "", fmt.Errorf("%w; --allow builds an inline policy and needs --image", err)
 	}
-	compiled, err = overrideCompiledPolicyImage(compiled, imageRefOverride, allowLocalImageOverride)
 	if err != nil {
-		return "", err
+		return nil, "", err
+	}
+	if len(allow) > 0 {
+		return nil, "", fmt.Errorf("--allow only applies to inline policies; add the hosts to sandbox.network.allow in %s", source)
+	}
+	compiled, err = overrideCompiledPolicyImage(ctx, compiled, imageRefOverride, allowLocalImageOverride)
+	if err != nil {
+		return nil, "", err
+	}
+	return compiled, source, nil
+}
+
+// inlinePolicySource is reported as the policy source of runs without a
+// policy file.
+const inlinePolicySource = "inline"
+
+func inlineSandboxPolicy(ctx context.Context, imageRef string, allow []string, allowLocal bool) (*policy.CompiledPolicy, error) {
+	resolvedRef, err := resolveReferenceForImageOverride(ctx, strings.TrimSpace(imageRef), allowLocal)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --image value: %w", err)
 	}
-	createSandboxResp, err := client.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{
+	compiled, err := policy.Inline(resolvedRef, allow)
+	if err != nil {
+		return nil, fmt.Errorf("inline policy: %w", err)
+	}
+	return compiled, nil
+}
+
+func createSandboxForPolicy(ctx context.Context, client *controlclient.Client, backendName string, compiled *policy.CompiledPolicy, launchSeconds int64) (string, error) {
+	createSandboxResp, err := client.CreateSandbox(ctx, &cleanroomv1.CreateSandboxRequest{
 		Backend: backendName,
 		Options: &cleanroomv1.SandboxOptions{
 			LaunchSeconds: launchSeconds,
@@ -2097,13 +3166,13 @@ func isLocalControlPlaneEndpoint(host string) (bool, error) {
 	return ep.Scheme == "unix", nil
 }
 
-func overrideCompiledPolicyImage(compiled *policy.CompiledPolicy, imageRefOverride string, allowLocal bool) (*policy.CompiledPolicy, error) {
+func overrideCompiledPolicyImage(ctx context.Context, compiled *policy.CompiledPolicy, imageRefOverride string, allowLocal bool) (*policy.CompiledPolicy, error) {
 	imageRefOverride = strings.TrimSpace(imageRefOverride)
 	if imageRefOverride == "" {
 		return compiled, nil
 	}
 
-	resolvedRef, err := resolveReferenceForImageOverride(context.Background(), imageRefOverride, allowLocal)
+	resolvedRef, err := resolveReferenceForImageOverride(ctx, imageRefOverride, allowLocal)
 	if err != nil {
 		return nil, fmt.Errorf("invalid --image value: %w", err)
 	}
@@ -2228,8 +3297,8 @@ func importLocalDockerImageForOverride(ctx context.Context, source string) (stri
 	return digestRef.Original, nil
 }
 
-func getFinalExecutionExitCode(client *controlclient.Client, sandboxID, executionID string) (int, bool) {
-	getResp, err := client.GetExecution(context.Background(), &cleanroomv1.GetExecutionRequest{
+func getFinalExecutionExitCode(ctx context.Context, client *controlclient.Client, sandboxID, executionID string) (int, bool) {
+	getResp, err := client.GetExecution(ctx, &cleanroomv1.GetExecutionRequest{
 		SandboxId:   sandboxID,
 		ExecutionId: executionID,
 	})
@@ -2243,8 +3312,8 @@ func getFinalExecutionExitCode(client *controlclient.Client, sandboxID, executio
 	return int(execution.GetExitCode()), true
 }
 
-func replayExecutionHistory(client *controlclient.Client, sandboxID, executionID string, stdout, stderr io.Writer) (int, bool, error) {
-	stream, err := client.StreamExecution(context.Background(), &cleanroomv1.StreamExecutionRequest{
+func replayExecutionHistory(ctx context.Context, client *controlclient.Client, sandboxID, executionID string, stdout, stderr io.Writer) (int, bool, error) {
+	stream, err := client.StreamExecution(ctx, &cleanroomv1.StreamExecutionRequest{
 		SandboxId:   sandboxID,
 		ExecutionId: executionID,
 		Follow:      false,
@@ -2429,6 +3498,8 @@ func sandboxStatusString(s cleanroomv1.SandboxStatus) string {
 		return "provisioning"
 	case cleanroomv1.SandboxStatus_SANDBOX_STATUS_READY:
 		return "ready"
+	case cleanroomv1.SandboxStatus_SANDBOX_STATUS_PAUSED:
+		return "paused"
 	case cleanroomv1.SandboxStatus_SANDBOX_STATUS_STOPPING:
 		return "stopping"
 	case cleanroomv1.SandboxStatus_SANDBOX_STATUS_STOPPED:
@@ -2444,6 +3515,8 @@ func isFinalExecutionStatus(status cleanroomv1.ExecutionStatus) bool {
 	switch status {
 	case cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SUCCEEDED,
 		cleanroomv1.ExecutionStatus_EXECUTION_STATUS_FAILED,
+		cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SKIPPED,
+		cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SOFT_FAILED,
 		cleanroomv1.ExecutionStatus_EXECUTION_STATUS_CANCELED,
 		cleanroomv1.ExecutionStatus_EXECUTION_STATUS_TIMED_OUT:
 		return true
@@ -2462,44 +3535,37 @@ func resolveCWD(base, chdir string) (string, error) {
 	return filepath.Join(base, chdir), nil
 }
 
-func resolvePolicyPathForUpdate(cwd, candidate string) (string, error) {
-	if strings.TrimSpace(candidate) != "" {
-		if filepath.IsAbs(candidate) {
-			return filepath.Clean(candidate), nil
-		}
-		return filepath.Join(cwd, candidate), nil
+// resolvePolicyDir returns the directory whose policy applies: --policy-dir
+// relative to cwd when set, otherwise cwd itself.
+func resolvePolicyDir(cwd, policyDir string) (string, error) {
+	if strings.TrimSpace(policyDir) == "" {
+		return cwd, nil
 	}
-
-	primary := filepath.Join(cwd, policy.PrimaryPolicyPath)
-	primaryExists, err := fileExists(primary)
+	dir, err := resolveCWD(cwd, policyDir)
 	if err != nil {
-		return "", fmt.Errorf("check policy %s: %w", primary, err)
-	}
-	if primaryExists {
-		return primary, nil
+		return "", err
 	}
-
-	fallback := filepath.Join(cwd, policy.FallbackPolicyPath)
-	fallbackExists, err := fileExists(fallback)
+	st, err := os.Stat(dir)
 	if err != nil {
-		return "", fmt.Errorf("check policy %s: %w", fallback, err)
+		return "", fmt.Errorf("--policy-dir: %w", err)
 	}
-	if fallbackExists {
-		return fallback, nil
+	if !st.IsDir() {
+		return "", fmt.Errorf("--policy-dir %s is not a directory", dir)
 	}
-
-	return "", fmt.Errorf("policy not found: expected %s or %s", primary, fallback)
+	return dir, nil
 }
 
-func fileExists(path string) (bool, error) {
-	_, err := os.Stat(path)
-	if err == nil {
-		return true, nil
-	}
-	if errors.Is(err, os.ErrNotExist) {
-		return false, nil
+func resolvePolicyPathForUpdate(cwd, candidate string) (string, error) {
+	if strings.TrimSpace(candidate) != "" {
+		if filepath.IsAbs(candidate) {
+			return filepath.Clean(candidate), nil
+		}
+		return filepath.Join(cwd, candidate), nil
 	}
-	return false, err
+
+	// The user default is shared by every repository, so bump-ref only
+	// edits a policy the repository owns.
+	return policy.Loader{}.Find(cwd)
 }
 
 func setSandboxImageRef(raw []byte, ref string) ([]byte, error) {
@@ -2597,16 +3663,48 @@ func setMapInt(parent *yaml.Node, key string, value int) {
 	)
 }
 
-func newLogger(rawLevel, component string) (*log.Logger, error) {
-	levelName := effectiveLogLevel(rawLevel)
-	level, err := log.ParseLevel(levelName)
+// newServeLogger returns serve's logger, writing to cfg.File when set and
+// to stderr otherwise. The returned file, if any, must be closed on exit.
+func newServeLogger(rawLevel, rawFormat string, cfg runtimeconfig.Logging) (*log.Logger, *logging.RotatingFile, error) {
+	path := strings.TrimSpace(cfg.File)
+	if path == "" {
+		logger, err := newLogger(rawLevel, rawFormat, "server")
+		return logger, nil, err
+	}
+	maxSizeMiB := cfg.MaxSizeMiB
+	if maxSizeMiB <= 0 {
+		maxSizeMiB = defaultLogFileMaxSizeMiB
+	}
+	maxFiles := cfg.MaxFiles
+	if maxFiles <= 0 {
+		maxFiles = defaultLogFileMaxFiles
+	}
+	file, err := logging.OpenRotatingFile(path, maxSizeMiB*1024*1024, int(maxFiles))
+	if err != nil {
+		return nil, nil, err
+	}
+	logger, err := logging.New(file, rawLevel, rawFormat)
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+	return logger.With("component", "server"), file, nil
+}
+
+const (
+	defaultLogFileMaxSizeMiB = 100
+	defaultLogFileMaxFiles   = 5
+
+	defaultEventSubjectPrefix = "cleanroom"
+)
+
+func newLogger(rawLevel, rawFormat, component string) (*log.Logger, error) {
+	logger, err := logging.New(os.Stderr, rawLevel, rawFormat)
 	if err != nil {
-		return nil, fmt.Errorf("invalid --log-level %q: %w", rawLevel, err)
+		return nil, err
+	}
+	if !strings.EqualFold(strings.TrimSpace(rawFormat), logging.FormatJSON) {
+		applyPolishedLoggerStyles(logger, shouldUseANSI(os.Stderr))
 	}
-	logger := log.NewWithOptions(os.Stderr, log.Options{
-		Level:     level,
-		Formatter: log.TextFormatter,
-	})
-	applyPolishedLoggerStyles(logger, shouldUseANSI(os.Stderr))
 	return logger.With("component", component), nil
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/alecthomas/kong"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
 )
 
 func newParserForTest(t *testing.T, c *CLI) *kong.Kong {
@@ -152,6 +153,43 @@ func TestExecParsesImageOverride(t *testing.T) {
 	}
 }
 
+func TestExecParsesResourceLimits(t *testing.T) {
+	c := &CLI{}
+	parser := newParserForTest(t, c)
+
+	if _, err := parser.Parse([]string{"exec", "--nice", "10", "--io-class", "idle", "--cpu-weight", "50", "--memory-max-mib", "512", "--", "make", "test"}); err != nil {
+		t.Fatalf("parse exec resource limits returned error: %v", err)
+	}
+	limits := c.Exec.resourceLimits()
+	if limits == nil {
+		t.Fatal("expected resource limits")
+	}
+	if got, want := limits.GetNice(), int32(10); got != want {
+		t.Fatalf("unexpected nice: got %d want %d", got, want)
+	}
+	if got, want := limits.GetIoClass(), "idle"; got != want {
+		t.Fatalf("unexpected io class: got %q want %q", got, want)
+	}
+	if got, want := limits.GetCpuWeight(), int64(50); got != want {
+		t.Fatalf("unexpected cpu weight: got %d want %d", got, want)
+	}
+	if got, want := limits.GetMemoryMaxBytes(), int64(512*1024*1024); got != want {
+		t.Fatalf("unexpected memory max bytes: got %d want %d", got, want)
+	}
+}
+
+func TestExecWithoutResourceLimitFlagsSendsNoLimits(t *testing.T) {
+	c := &CLI{}
+	parser := newParserForTest(t, c)
+
+	if _, err := parser.Parse([]string{"exec", "--", "echo", "ok"}); err != nil {
+		t.Fatalf("parse exec returned error: %v", err)
+	}
+	if limits := c.Exec.resourceLimits(); limits != nil {
+		t.Fatalf("expected no resource limits, got %v", limits)
+	}
+}
+
 func TestConsoleParsesImageOverride(t *testing.T) {
 	c := &CLI{}
 	parser := newParserForTest(t, c)
@@ -203,3 +241,58 @@ func TestServeInstallForceParses(t *testing.T) {
 		t.Fatal("expected --force to set Serve.Force")
 	}
 }
+
+func TestSandboxRmParsesSelectors(t *testing.T) {
+	c := &CLI{}
+	parser := newParserForTest(t, c)
+
+	if _, err := parser.Parse([]string{"sandbox", "rm", "--label", "branch=main", "--label", "repo=web", "--older-than", "24h"}); err != nil {
+		t.Fatalf("parse sandbox rm selectors returned error: %v", err)
+	}
+	rm := c.Sandbox.Terminate
+	if len(rm.SandboxIDs) != 0 || rm.Labels["branch"] != "main" || rm.Labels["repo"] != "web" || rm.OlderThan.Hours() != 24 {
+		t.Fatalf("unexpected parsed sandbox rm command: %+v", rm)
+	}
+}
+
+func TestApplyConfigDefaultsFillsOnlyUnsetFlags(t *testing.T) {
+	c := &CLI{}
+	parser := newParserForTest(t, c)
+
+	kctx, err := parser.Parse([]string{"--profile", "work", "exec", "--launch-seconds", "5", "--", "true"})
+	if err != nil {
+		t.Fatalf("parse exec returned error: %v", err)
+	}
+	if got, want := c.Profile, "work"; got != want {
+		t.Fatalf("unexpected profile: got %q want %q", got, want)
+	}
+	applyConfigDefaults(
+		kctx.Selected().Target.Addr().Interface(),
+		runtimeconfig.Config{Host: "https://cleanroom.example.com", TLSCA: "/etc/ca.pem"},
+		runtimeconfig.RepoConfig{DefaultBackend: "darwin-vz", LaunchSeconds: 90},
+	)
+	if got, want := c.Exec.Host, "https://cleanroom.example.com"; got != want {
+		t.Fatalf("unexpected host: got %q want %q", got, want)
+	}
+	if got, want := c.Exec.TLSCA, "/etc/ca.pem"; got != want {
+		t.Fatalf("unexpected tls ca: got %q want %q", got, want)
+	}
+	if got, want := c.Exec.Backend, "darwin-vz"; got != want {
+		t.Fatalf("unexpected backend: got %q want %q", got, want)
+	}
+	if got, want := c.Exec.LaunchSeconds, int64(5); got != want {
+		t.Fatalf("expected explicit launch seconds to win: got %d want %d", got, want)
+	}
+}
+
+func TestVarFlagParsesAfterSubcommand(t *testing.T) {
+	c := &CLI{}
+	parser := newParserForTest(t, c)
+
+	if _, err := parser.Parse([]string{"exec", "--var", "IMAGE_TAG=v1.2", "--var", "REGION=eu-west-1", "--", "true"}); err != nil {
+		t.Fatalf("parse exec --var returned error: %v", err)
+	}
+	if got := c.Vars; got["IMAGE_TAG"] != "v1.2" || got["REGION"] != "eu-west-1" {
+		t.Fatalf("unexpected vars %v", got)
+	}
+}
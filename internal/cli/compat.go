@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/buildkite/cleanroom/internal/apiversion"
+	"github.com/buildkite/cleanroom/internal/controlclient"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// serverInfoTimeout bounds the compatibility probe made before a client
+// command's first RPC.
+const serverInfoTimeout = 5 * time.Second
+
+// checkServerCompatibility refuses a server whose API schema this client
+// cannot use, rather than letting requests fail with decoding errors after
+// a partial upgrade. A server that predates GetServerInfo gets a warning.
+// Other probe errors are left for the command's own RPCs to report.
+func checkServerCompatibility(client *controlclient.Client, clientVersion string, stderr io.Writer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), serverInfoTimeout)
+	defer cancel()
+	info, err := client.GetServerInfo(ctx, &cleanroomv1.GetServerInfoRequest{})
+	if connect.CodeOf(err) == connect.CodeUnimplemented {
+		_, _ = fmt.Fprintf(stderr, "warning: the server does not report its version, so it predates this client (%s); upgrade it if requests fail\n", clientVersion)
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+	return apiversion.Check(info, clientVersion)
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/buildkite/cleanroom/internal/apiversion"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/gen/cleanroom/v1/cleanroomv1connect"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+type fixedServerInfo struct {
+	cleanroomv1connect.UnimplementedServerServiceHandler
+	info *cleanroomv1.GetServerInfoResponse
+}
+
+func (f fixedServerInfo) GetServerInfo(context.Context, *connect.Request[cleanroomv1.GetServerInfoRequest]) (*connect.Response[cleanroomv1.GetServerInfoResponse], error) {
+	return connect.NewResponse(f.info), nil
+}
+
+func startServerInfoServer(t *testing.T, handler cleanroomv1connect.ServerServiceHandler) string {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle(cleanroomv1connect.NewServerServiceHandler(handler))
+	server := httptest.NewServer(h2c.NewHandler(mux, &http2.Server{}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestConnectRefusesServerRequiringNewerClient(t *testing.T) {
+	host := startServerInfoServer(t, fixedServerInfo{info: &cleanroomv1.GetServerInfoResponse{
+		Version:                "v9.0.0",
+		SchemaVersion:          apiversion.Schema + 1,
+		MinClientSchemaVersion: apiversion.Schema + 1,
+	}})
+
+	_, err := (&clientFlags{Host: host, version: "v1.0.0"}).connect()
+	if err == nil || !strings.Contains(err.Error(), "upgrade the client") {
+		t.Fatalf("expected connect to refuse the server, got %v", err)
+	}
+}
+
+func TestConnectAcceptsCompatibleServerFromAnotherRelease(t *testing.T) {
+	host := startServerInfoServer(t, fixedServerInfo{info: apiversion.Info("v0.9.0")})
+
+	client, err := (&clientFlags{Host: host, version: "v1.0.0"}).connect()
+	if err != nil {
+		t.Fatalf("connect returned error: %v", err)
+	}
+	client.CloseIdleConnections()
+}
+
+func TestServerCompatibilityWarnsWhenServerPredatesServerInfo(t *testing.T) {
+	host := startServerInfoServer(t, cleanroomv1connect.UnimplementedServerServiceHandler{})
+	client, err := (&clientFlags{Host: host}).dial()
+	if err != nil {
+		t.Fatalf("dial returned error: %v", err)
+	}
+	defer client.CloseIdleConnections()
+
+	var stderr bytes.Buffer
+	if err := checkServerCompatibility(client, "v1.0.0", &stderr); err != nil {
+		t.Fatalf("an older server should only warn: %v", err)
+	}
+	if !strings.Contains(stderr.String(), "predates this client (v1.0.0)") {
+		t.Fatalf("unexpected warning %q", stderr.String())
+	}
+}
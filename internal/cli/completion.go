@@ -0,0 +1,278 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kong"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// completionSandbox is the value of the `completion` struct tag on flags and
+// arguments that take a sandbox ID or name.
+const completionSandbox = "sandbox"
+
+const completionListTimeout = 2 * time.Second
+
+type CompletionCommand struct {
+	Shell string `arg:"" enum:"bash,zsh,fish" help:"Shell to generate completions for (bash, zsh, fish)"`
+}
+
+// CompleteCommand is the hidden entry point the generated shell scripts call.
+// It receives the words typed after the program name, the last one being the
+// word under the cursor, and prints one candidate per line.
+type CompleteCommand struct {
+	Words []string `arg:"" optional:"" passthrough:"" help:"Command line words after the program name"`
+}
+
+const bashCompletionScript = `# bash completion for cleanroom
+_cleanroom() {
+  local IFS=$'\n'
+  COMPREPLY=($(cleanroom __complete -- "${COMP_WORDS[@]:1:$COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _cleanroom cleanroom
+`
+
+const zshCompletionScript = `#compdef cleanroom
+_cleanroom() {
+  local out
+  out="$(cleanroom __complete -- "${words[@]:1:$((CURRENT-1))}" 2>/dev/null)"
+  if [[ -n "$out" ]]; then
+    compadd -- ${(f)out}
+  else
+    _files
+  fi
+}
+compdef _cleanroom cleanroom
+`
+
+const fishCompletionScript = `# fish completion for cleanroom
+function __cleanroom_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    cleanroom __complete -- $tokens[2..-1] 2>/dev/null
+end
+complete -c cleanroom -f -a '(__cleanroom_complete)'
+`
+
+func (c *CompletionCommand) Run(ctx *runtimeContext) error {
+	script := map[string]string{
+		"bash": bashCompletionScript,
+		"zsh":  zshCompletionScript,
+		"fish": fishCompletionScript,
+	}[c.Shell]
+	_, err := io.WriteString(ctx.Stdout, script)
+	return err
+}
+
+func (c *CompleteCommand) Run(ctx *runtimeContext, k *kong.Kong) error {
+	words := c.Words
+	if len(words) > 0 && words[0] == "--" {
+		words = words[1:]
+	}
+	for _, candidate := range completeWords(k.Model.Node, words, func() []string {
+		host := completionHost(words)
+		if host == "" {
+			host = ctx.Config.Host
+		}
+		return listSandboxCompletions(host, ctx.Config.TLSCA)
+	}) {
+		if _, err := fmt.Fprintln(ctx.Stdout, candidate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// completeWords returns the candidates for the last word in words, walking the
+// command tree rooted at root with the words before it. sandboxes is only
+// called when a sandbox ID is expected.
+func completeWords(root *kong.Node, words []string, sandboxes func() []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+	node := root
+	var pendingFlag *kong.Flag
+	for _, word := range words[:len(words)-1] {
+		if pendingFlag != nil {
+			pendingFlag = nil
+			continue
+		}
+		if word == "--" {
+			// Everything after -- belongs to the sandboxed command.
+			return nil
+		}
+		if strings.HasPrefix(word, "-") {
+			if strings.Contains(word, "=") {
+				continue
+			}
+			if flag := lookupFlag(node, word); flag != nil && !flag.IsBool() {
+				pendingFlag = flag
+			}
+			continue
+		}
+		if child := lookupChild(node, word); child != nil {
+			node = child
+			continue
+		}
+		if node.Passthrough || hasPassthroughPositional(node) {
+			return nil
+		}
+	}
+
+	if pendingFlag != nil {
+		return filterPrefix(valueCandidates(pendingFlag.Value, sandboxes), current)
+	}
+	if name, value, ok := strings.Cut(current, "="); ok && strings.HasPrefix(name, "-") {
+		flag := lookupFlag(node, name)
+		if flag == nil {
+			return nil
+		}
+		var out []string
+		for _, v := range filterPrefix(valueCandidates(flag.Value, sandboxes), value) {
+			out = append(out, name+"="+v)
+		}
+		return out
+	}
+	if strings.HasPrefix(current, "-") {
+		return filterPrefix(flagCandidates(node), current)
+	}
+
+	var out []string
+	for _, child := range node.Children {
+		if child.Hidden {
+			continue
+		}
+		out = append(out, child.Name)
+		out = append(out, child.Aliases...)
+	}
+	for _, positional := range node.Positional {
+		out = append(out, valueCandidates(positional, sandboxes)...)
+	}
+	return filterPrefix(out, current)
+}
+
+func lookupChild(node *kong.Node, word string) *kong.Node {
+	for _, child := range node.Children {
+		if child.Name == word {
+			return child
+		}
+		for _, alias := range child.Aliases {
+			if alias == word {
+				return child
+			}
+		}
+	}
+	return nil
+}
+
+// lookupFlag resolves --name or -x against node and its ancestors, since
+// kong accepts parent flags on subcommands.
+func lookupFlag(node *kong.Node, word string) *kong.Flag {
+	for n := node; n != nil; n = n.Parent {
+		for _, flag := range n.Flags {
+			if word == "--"+flag.Name || (flag.Short != 0 && word == "-"+string(flag.Short)) {
+				return flag
+			}
+			for _, alias := range flag.Aliases {
+				if word == "--"+alias {
+					return flag
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func flagCandidates(node *kong.Node) []string {
+	var out []string
+	for n := node; n != nil; n = n.Parent {
+		for _, flag := range n.Flags {
+			if !flag.Hidden {
+				out = append(out, "--"+flag.Name)
+			}
+		}
+	}
+	return out
+}
+
+func hasPassthroughPositional(node *kong.Node) bool {
+	for _, positional := range node.Positional {
+		if positional.Passthrough {
+			return true
+		}
+	}
+	return false
+}
+
+func valueCandidates(value *kong.Value, sandboxes func() []string) []string {
+	if value.Tag != nil && value.Tag.Get("completion") == completionSandbox {
+		return sandboxes()
+	}
+	if value.Enum != "" {
+		return value.EnumSlice()
+	}
+	return nil
+}
+
+func filterPrefix(candidates []string, prefix string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, candidate := range candidates {
+		if candidate == "" || seen[candidate] || !strings.HasPrefix(candidate, prefix) {
+			continue
+		}
+		seen[candidate] = true
+		out = append(out, candidate)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// completionHost picks --host from the words being completed, falling back
+// to CLEANROOM_HOST like the real command would.
+func completionHost(words []string) string {
+	for i, word := range words {
+		if value, ok := strings.CutPrefix(word, "--host="); ok {
+			return value
+		}
+		if word == "--host" && i+1 < len(words)-1 {
+			return words[i+1]
+		}
+	}
+	return os.Getenv("CLEANROOM_HOST")
+}
+
+// listSandboxCompletions returns IDs and names of active sandboxes. Errors
+// yield no candidates: a completion must never print to the terminal.
+func listSandboxCompletions(host, tlsCA string) []string {
+	if env := os.Getenv("CLEANROOM_TLS_CA"); env != "" {
+		tlsCA = env
+	}
+	client, err := (&clientFlags{Host: host, TLSCA: tlsCA}).dial()
+	if err != nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), completionListTimeout)
+	defer cancel()
+	resp, err := client.ListSandboxes(ctx, &cleanroomv1.ListSandboxesRequest{})
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, sb := range resp.GetSandboxes() {
+		if !sandboxIsActive(sb.GetStatus()) {
+			continue
+		}
+		out = append(out, sb.GetSandboxId())
+		if sb.GetName() != "" {
+			out = append(out, sb.GetName())
+		}
+	}
+	return out
+}
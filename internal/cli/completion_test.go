@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCompleteWords(t *testing.T) {
+	t.Parallel()
+
+	root := newParserForTest(t, &CLI{}).Model.Node
+	sandboxes := func() []string { return []string{"cr_123", "web-main"} }
+
+	tests := []struct {
+		name  string
+		words []string
+		want  string
+	}{
+		{name: "top level", words: []string{"sa"}, want: "sandbox"},
+		{name: "hidden command omitted", words: []string{"__"}, want: ""},
+		{name: "subcommand with alias", words: []string{"sandbox", "l"}, want: "list,ls"},
+		{name: "flags include parents", words: []string{"sandbox", "rm", "--ol"}, want: "--older-than"},
+		{name: "sandbox positional", words: []string{"sandbox", "rm", ""}, want: "cr_123,web-main"},
+		{name: "sandbox flag value", words: []string{"exec", "--sandbox-id", "w"}, want: "web-main"},
+		{name: "sandbox flag inline value", words: []string{"console", "--sandbox-id=cr"}, want: "--sandbox-id=cr_123"},
+		{name: "enum value", words: []string{"completion", "z"}, want: "zsh"},
+		{name: "bool flag takes no value", words: []string{"sandbox", "rm", "--all", "c"}, want: "cr_123"},
+		{name: "stops after separator", words: []string{"exec", "--", "ls", "-"}, want: ""},
+	}
+	for _, tc := range tests {
+		got := strings.Join(completeWords(root, tc.words, sandboxes), ",")
+		if got != tc.want {
+			t.Fatalf("%s: unexpected candidates: got %q want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCompleteWordsOnlyListsSandboxesWhenExpected(t *testing.T) {
+	t.Parallel()
+
+	root := newParserForTest(t, &CLI{}).Model.Node
+	called := false
+	completeWords(root, []string{"exec", "--backend", ""}, func() []string {
+		called = true
+		return nil
+	})
+	if called {
+		t.Fatal("expected sandbox listing to be skipped for --backend")
+	}
+}
+
+func TestCompletionHostPrefersFlag(t *testing.T) {
+	t.Parallel()
+
+	if got := completionHost([]string{"sandbox", "rm", "--host", "http://a:1", ""}); got != "http://a:1" {
+		t.Fatalf("unexpected host: got %q want %q", got, "http://a:1")
+	}
+	if got := completionHost([]string{"--host=http://b:2", "sandbox", "rm", ""}); got != "http://b:2" {
+		t.Fatalf("unexpected host: got %q want %q", got, "http://b:2")
+	}
+	if got := completionHost([]string{"sandbox", "rm", "--host"}); got != os.Getenv("CLEANROOM_HOST") {
+		t.Fatalf("unexpected host for incomplete flag: got %q", got)
+	}
+}
+
+func TestCompletionCommandPrintsScript(t *testing.T) {
+	t.Parallel()
+
+	for shell, want := range map[string]string{
+		"bash": "complete -o default -F _cleanroom cleanroom",
+		"zsh":  "compdef _cleanroom cleanroom",
+		"fish": "complete -c cleanroom",
+	} {
+		stdout, readStdout := makeStdoutCapture(t)
+		if err := (&CompletionCommand{Shell: shell}).Run(&runtimeContext{Stdout: stdout}); err != nil {
+			t.Fatalf("%s: completion returned error: %v", shell, err)
+		}
+		got := readStdout()
+		if !strings.Contains(got, want) || !strings.Contains(got, "cleanroom __complete --") {
+			t.Fatalf("%s: unexpected script: %q", shell, got)
+		}
+	}
+}
@@ -0,0 +1,474 @@
+package cli
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/controlclient"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/policy"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultComposeReadyInterval = time.Second
+	defaultComposeReadyTimeout  = 2 * time.Minute
+)
+
+// composeServiceNamePattern keeps service names usable in sandbox names and
+// environment variable names.
+var composeServiceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,30}$`)
+
+type ComposeCommand struct {
+	Up ComposeUpCommand `cmd:"" help:"Start every service in a compose file as a sandbox group and stream their output"`
+}
+
+type ComposeUpCommand struct {
+	clientFlags
+	Chdir         string `short:"c" help:"Change to this directory before running commands"`
+	File          string `short:"f" default:"cleanroom-compose.yaml" help:"Compose file describing the services"`
+	ProjectName   string `name:"project-name" short:"p" help:"Prefix for the services' sandbox names (defaults to the compose file's directory name)"`
+	Backend       string `help:"Execution backend (defaults to runtime config or host default)"`
+	LaunchSeconds int64  `help:"VM boot/guest-agent readiness timeout in seconds"`
+	ExitCodeFrom  string `name:"exit-code-from" help:"Stop every service when this service's command exits, and exit with its exit code"`
+	Keep          bool   `help:"Leave the sandboxes running when up exits"`
+}
+
+// composeFile is a cleanroom-compose.yaml: the services started together as
+// one sandbox group.
+type composeFile struct {
+	Services map[string]*composeService `yaml:"services"`
+}
+
+type composeService struct {
+	// Dir holds the cleanroom.yaml the service's sandbox uses, relative to
+	// the compose file. It defaults to the compose file's directory.
+	Dir       string            `yaml:"dir"`
+	Image     string            `yaml:"image"`
+	Resources *composeResources `yaml:"resources"`
+	Ports     []int32           `yaml:"ports"`
+	DependsOn []string          `yaml:"depends_on"`
+	Command   []string          `yaml:"command"`
+	Ready     *composeReady     `yaml:"ready"`
+}
+
+// composeResources overrides the sandbox.resources of the service's policy.
+type composeResources struct {
+	VCPUs     int64 `yaml:"vcpus"`
+	MemoryMiB int64 `yaml:"memory_mib"`
+	DiskMiB   int64 `yaml:"disk_mib"`
+}
+
+// composeReady is a command run in the service's sandbox until it exits 0,
+// which holds back the services that depend on it.
+type composeReady struct {
+	Command  []string      `yaml:"command"`
+	Interval time.Duration `yaml:"interval"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// loadComposeFile reads and checks a compose file. It returns the services
+// in the order they start, each after the services it depends on.
+func loadComposeFile(path string) (*composeFile, []string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read compose file: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	var file composeFile
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, fmt.Errorf("parse compose file %s: %w", path, err)
+	}
+	if len(file.Services) < 2 {
+		return nil, nil, fmt.Errorf("compose file %s needs at least two services; use cleanroom exec for one", path)
+	}
+	for name, svc := range file.Services {
+		if !composeServiceNamePattern.MatchString(name) {
+			return nil, nil, fmt.Errorf("service %q: names must be 1-31 characters from [a-z0-9_-] and start with a letter or digit", name)
+		}
+		if svc == nil {
+			return nil, nil, fmt.Errorf("service %q: missing definition", name)
+		}
+		for _, port := range svc.Ports {
+			if port < 1 || port > 65535 {
+				return nil, nil, fmt.Errorf("service %q: invalid port %d", name, port)
+			}
+		}
+		if r := svc.Resources; r != nil && (r.VCPUs < 0 || r.MemoryMiB < 0 || r.DiskMiB < 0) {
+			return nil, nil, fmt.Errorf("service %q: resources must not be negative", name)
+		}
+		if r := svc.Ready; r != nil {
+			if len(r.Command) == 0 {
+				return nil, nil, fmt.Errorf("service %q: ready.command is required", name)
+			}
+			if r.Interval < 0 || r.Timeout < 0 {
+				return nil, nil, fmt.Errorf("service %q: ready interval and timeout must not be negative", name)
+			}
+		}
+		for _, dep := range svc.DependsOn {
+			if _, ok := file.Services[dep]; !ok {
+				return nil, nil, fmt.Errorf("service %q depends on unknown service %q", name, dep)
+			}
+		}
+	}
+	order, err := composeStartOrder(file.Services)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &file, order, nil
+}
+
+// composeStartOrder sorts the services so each follows its dependencies,
+// breaking ties by name.
+func composeStartOrder(services map[string]*composeService) ([]string, error) {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var order []string
+	started := map[string]bool{}
+	for len(order) < len(names) {
+		progressed := false
+		for _, name := range names {
+			if started[name] {
+				continue
+			}
+			ready := true
+			for _, dep := range services[name].DependsOn {
+				ready = ready && started[dep]
+			}
+			if ready {
+				order = append(order, name)
+				started[name] = true
+				progressed = true
+			}
+		}
+		if !progressed {
+			var blocked []string
+			for _, name := range names {
+				if !started[name] {
+					blocked = append(blocked, name)
+				}
+			}
+			return nil, fmt.Errorf("services %s depend on each other in a cycle", strings.Join(blocked, ", "))
+		}
+	}
+	return order, nil
+}
+
+// composeEnvName is the variable holding a service's group address in the
+// other services' commands, e.g. DB_HOST for db.
+func composeEnvName(service string) string {
+	return strings.ToUpper(strings.ReplaceAll(service, "-", "_")) + "_HOST"
+}
+
+// Run creates every service's sandbox as one group, then starts the
+// services in dependency order. A service with a ready command holds back
+// the services that depend on it until that command succeeds. Output from
+// every service is interleaved on stdout and stderr, each line prefixed with
+// the service name.
+func (c *ComposeUpCommand) Run(ctx *runtimeContext) error {
+	logger, err := newLogger(c.LogLevel, c.LogFormat, "client")
+	if err != nil {
+		return err
+	}
+	cwd, err := resolveCWD(ctx.CWD, c.Chdir)
+	if err != nil {
+		return err
+	}
+	path := c.File
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cwd, path)
+	}
+	file, order, err := loadComposeFile(path)
+	if err != nil {
+		return err
+	}
+	if c.ExitCodeFrom != "" {
+		if svc, ok := file.Services[c.ExitCodeFrom]; !ok || len(svc.Command) == 0 {
+			return fmt.Errorf("--exit-code-from %q must name a service with a command", c.ExitCodeFrom)
+		}
+	}
+	project := cmp.Or(strings.TrimSpace(c.ProjectName), strings.ToLower(filepath.Base(filepath.Dir(path))))
+	if !composeServiceNamePattern.MatchString(project) {
+		return fmt.Errorf("project name %q must be 1-31 characters from [a-z0-9_-]; set one with --project-name", project)
+	}
+
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	cmdCtx := ctx.commandContext()
+
+	members := make([]*cleanroomv1.SandboxGroupMember, 0, len(order))
+	for _, name := range order {
+		svc := file.Services[name]
+		compiled, _, err := compileSandboxPolicy(cmdCtx, ctx.Loader, filepath.Join(filepath.Dir(path), svc.Dir), c.Host, svc.Image, nil)
+		if err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+		if compiled, err = overrideCompiledPolicyResources(compiled, svc.Resources); err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+		members = append(members, &cleanroomv1.SandboxGroupMember{
+			Sandbox: &cleanroomv1.CreateSandboxRequest{
+				Backend: c.Backend,
+				Options: &cleanroomv1.SandboxOptions{LaunchSeconds: c.LaunchSeconds},
+				Policy:  compiled.ToProto(),
+				Name:    project + "-" + name,
+				Labels:  map[string]string{"compose.project": project, "compose.service": name},
+			},
+			Ports: svc.Ports,
+		})
+	}
+
+	groupResp, err := client.CreateSandboxGroup(cmdCtx, &cleanroomv1.CreateSandboxGroupRequest{Members: members})
+	if err != nil {
+		return fmt.Errorf("create sandbox group: %w", err)
+	}
+	sandboxIDs := map[string]string{}
+	var env []string
+	for i, resp := range groupResp.GetSandboxes() {
+		sb := resp.GetSandbox()
+		sandboxIDs[order[i]] = sb.GetSandboxId()
+		env = append(env, composeEnvName(order[i])+"="+sb.GetGroupAddress())
+		fmt.Fprintf(os.Stderr, "%s: sandbox %s at %s\n", order[i], sb.GetSandboxId(), sb.GetGroupAddress())
+	}
+	if !c.Keep {
+		defer func() {
+			for _, name := range order {
+				terminateSandboxBestEffort(client, sandboxIDs[name], sandboxTerminateTimeout, logger, "terminate compose sandbox failed")
+			}
+		}()
+	}
+
+	upCtx, cancel := context.WithCancel(cmdCtx)
+	defer cancel()
+	signalCh := newSignalChannel()
+	notifySignals(signalCh, os.Interrupt, syscall.SIGTERM)
+	defer stopSignals(signalCh)
+	go func() {
+		select {
+		case <-signalCh:
+			cancel()
+		case <-upCtx.Done():
+		}
+	}()
+
+	width := 0
+	for _, name := range order {
+		width = max(width, len(name))
+	}
+	var outMu sync.Mutex
+	type exit struct {
+		service string
+		code    int
+		err     error
+	}
+	exits := make(chan exit, len(order))
+	running, idle := 0, false
+	for _, name := range order {
+		svc := file.Services[name]
+		if len(svc.Command) > 0 {
+			stdout := &composeLogWriter{mu: &outMu, w: ctx.Stdout, prefix: fmt.Sprintf("%-*s | ", width, name)}
+			stderr := &composeLogWriter{mu: &outMu, w: os.Stderr, prefix: stdout.prefix}
+			command := slices.Concat([]string{"env"}, env, svc.Command)
+			running++
+			go func() {
+				code, err := runComposeCommand(upCtx, client, sandboxIDs[name], command, stdout, stderr)
+				stdout.Flush()
+				stderr.Flush()
+				exits <- exit{service: name, code: code, err: err}
+			}()
+		} else {
+			idle = true
+		}
+		if svc.Ready != nil {
+			if err := waitComposeServiceReady(upCtx, client, sandboxIDs[name], svc.Ready); err != nil {
+				return fmt.Errorf("service %s: %w", name, err)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%s: ready\n", name)
+	}
+
+	firstFailure := 0
+	for running > 0 {
+		select {
+		case <-upCtx.Done():
+			return exitCodeError{code: 130}
+		case e := <-exits:
+			running--
+			if e.err != nil {
+				return fmt.Errorf("service %s: %w", e.service, e.err)
+			}
+			fmt.Fprintf(os.Stderr, "%s: exited with code %d\n", e.service, e.code)
+			if e.service == c.ExitCodeFrom {
+				if e.code != 0 {
+					return exitCodeError{code: e.code}
+				}
+				return nil
+			}
+			if e.code != 0 && firstFailure == 0 {
+				firstFailure = e.code
+			}
+		}
+	}
+	if firstFailure != 0 {
+		return exitCodeError{code: firstFailure}
+	}
+	if idle {
+		// Services without a command run whatever their image starts at
+		// boot, so keep them up until interrupted.
+		<-upCtx.Done()
+	}
+	return nil
+}
+
+// overrideCompiledPolicyResources applies a service's resources on top of
+// its policy's, keeping any the service leaves unset.
+func overrideCompiledPolicyResources(compiled *policy.CompiledPolicy, res *composeResources) (*policy.CompiledPolicy, error) {
+	if res == nil || (res.VCPUs == 0 && res.MemoryMiB == 0 && res.DiskMiB == 0) {
+		return compiled, nil
+	}
+	pb := compiled.ToProto()
+	if pb.Resources == nil {
+		pb.Resources = &cleanroomv1.PolicyResources{}
+	}
+	if res.VCPUs > 0 {
+		pb.Resources.Vcpus = res.VCPUs
+	}
+	if res.MemoryMiB > 0 {
+		pb.Resources.MemoryMib = res.MemoryMiB
+	}
+	if res.DiskMiB > 0 {
+		pb.Resources.DiskMib = res.DiskMiB
+	}
+	pb.Hash = ""
+	overridden, err := policy.FromProto(pb)
+	if err != nil {
+		return nil, fmt.Errorf("apply resources: %w", err)
+	}
+	return overridden, nil
+}
+
+// waitComposeServiceReady runs the ready command until it exits 0 or the
+// timeout passes.
+func waitComposeServiceReady(ctx context.Context, client *controlclient.Client, sandboxID string, ready *composeReady) error {
+	interval := cmp.Or(ready.Interval, defaultComposeReadyInterval)
+	timeout := cmp.Or(ready.Timeout, defaultComposeReadyTimeout)
+	deadline := time.Now().Add(timeout)
+	for {
+		code, err := runComposeCommand(ctx, client, sandboxID, ready.Command, io.Discard, io.Discard)
+		if err != nil {
+			return fmt.Errorf("ready check: %w", err)
+		}
+		if code == 0 {
+			return nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return fmt.Errorf("not ready after %s; ready command last exited with code %d", timeout, code)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// runComposeCommand runs a batch execution in the sandbox, copying its
+// output to stdout and stderr, and returns its exit code.
+func runComposeCommand(ctx context.Context, client *controlclient.Client, sandboxID string, command []string, stdout, stderr io.Writer) (int, error) {
+	createResp, err := client.CreateExecution(ctx, &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   command,
+		Kind:      cleanroomv1.ExecutionKind_EXECUTION_KIND_BATCH,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("create execution: %w", err)
+	}
+	if err := waitForExecutionApproval(ctx, client, createResp.GetExecution(), os.Stderr); err != nil {
+		return 0, err
+	}
+	executionID := createResp.GetExecution().GetExecutionId()
+	stream, err := client.StreamExecution(ctx, &cleanroomv1.StreamExecutionRequest{
+		SandboxId:   sandboxID,
+		ExecutionId: executionID,
+		Follow:      true,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("stream execution: %w", err)
+	}
+	for stream.Receive() {
+		switch payload := stream.Msg().Payload.(type) {
+		case *cleanroomv1.ExecutionStreamEvent_Stdout:
+			_, _ = stdout.Write(payload.Stdout)
+		case *cleanroomv1.ExecutionStreamEvent_Stderr:
+			_, _ = stderr.Write(payload.Stderr)
+		case *cleanroomv1.ExecutionStreamEvent_Exit:
+			return int(payload.Exit.GetExitCode()), nil
+		}
+	}
+	if err := stream.Err(); err != nil && !isCanceledStreamErr(err) {
+		return 0, fmt.Errorf("stream execution: %w", err)
+	}
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	if code, ok := getFinalExecutionExitCode(ctx, client, sandboxID, executionID); ok {
+		return code, nil
+	}
+	return 0, errors.New("execution stream ended without exit status")
+}
+
+// composeLogWriter prefixes each line written to it, holding back a partial
+// line until it is completed or flushed. Writers for every service share mu
+// so their lines do not interleave.
+type composeLogWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (l *composeLogWriter) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		l.emit(l.buf[:i+1])
+		l.buf = l.buf[i+1:]
+	}
+}
+
+// Flush writes any partial line left in the buffer.
+func (l *composeLogWriter) Flush() {
+	if len(l.buf) > 0 {
+		l.emit(append(l.buf, '\n'))
+		l.buf = nil
+	}
+}
+
+func (l *composeLogWriter) emit(line []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = io.WriteString(l.w, l.prefix)
+	_, _ = l.w.Write(line)
+}
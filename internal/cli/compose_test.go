@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// composeIntegrationAdapter links groups by handing out one address per
+// member and records the commands it runs.
+type composeIntegrationAdapter struct {
+	integrationAdapter
+	mu       sync.Mutex
+	commands []string
+}
+
+func (a *composeIntegrationAdapter) LinkSandboxGroup(_ context.Context, req backend.SandboxGroupRequest) (map[string]string, error) {
+	addresses := map[string]string{}
+	for i, member := range req.Members {
+		addresses[member.SandboxID] = "10.200." + string(rune('0'+i)) + ".2"
+	}
+	return addresses, nil
+}
+
+func writeComposeFile(t *testing.T, content string) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "shop")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cleanroom-compose.yaml"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLoadComposeFileOrdersServicesAfterDependencies(t *testing.T) {
+	t.Parallel()
+
+	dir := writeComposeFile(t, `
+services:
+  app:
+    depends_on: [db, cache]
+    command: ["make", "test"]
+  cache:
+    ports: [6379]
+  db:
+    ports: [5432]
+    ready:
+      command: ["pg_isready"]
+      interval: 500ms
+`)
+	file, order, err := loadComposeFile(filepath.Join(dir, "cleanroom-compose.yaml"))
+	if err != nil {
+		t.Fatalf("loadComposeFile returned error: %v", err)
+	}
+	if got := strings.Join(order, ","); got != "cache,db,app" {
+		t.Fatalf("unexpected start order %q", got)
+	}
+	if got := file.Services["db"].Ready.Interval.String(); got != "500ms" {
+		t.Fatalf("unexpected ready interval %q", got)
+	}
+}
+
+func TestLoadComposeFileRejectsInvalidDefinitions(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		content string
+		want    string
+	}{
+		{content: "services:\n  app: {}\n", want: "at least two services"},
+		{content: "services:\n  app: {depends_on: [db]}\n  db: {depends_on: [app]}\n", want: "app, db depend on each other in a cycle"},
+		{content: "services:\n  app: {depends_on: [redis]}\n  db: {}\n", want: `unknown service "redis"`},
+		{content: "services:\n  App: {}\n  db: {}\n", want: `service "App": names must be`},
+		{content: "services:\n  app: {}\n  db: {ports: [0]}\n", want: "invalid port 0"},
+		{content: "services:\n  app: {}\n  db: {ready: {interval: 1s}}\n", want: "ready.command is required"},
+		{content: "services:\n  app: {build: .}\n  db: {}\n", want: "field build not found"},
+	}
+	for _, tc := range cases {
+		dir := writeComposeFile(t, tc.content)
+		_, _, err := loadComposeFile(filepath.Join(dir, "cleanroom-compose.yaml"))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("expected %q error for %q, got %v", tc.want, tc.content, err)
+		}
+	}
+}
+
+func TestComposeLogWriterPrefixesWholeLines(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+	w := &composeLogWriter{mu: &sync.Mutex{}, w: &out, prefix: "db  | "}
+	_, _ = w.Write([]byte("starting\nlisten"))
+	_, _ = w.Write([]byte("ing on 5432\nbye"))
+	w.Flush()
+	if got, want := out.String(), "db  | starting\ndb  | listening on 5432\ndb  | bye\n"; got != want {
+		t.Fatalf("unexpected output %q, want %q", got, want)
+	}
+}
+
+func TestComposeUpIntegrationStartsServicesInOrder(t *testing.T) {
+	adapter := &composeIntegrationAdapter{}
+	adapter.runStreamFn = func(_ context.Context, req backend.RunRequest, stream backend.OutputStream) (*backend.RunResult, error) {
+		command := strings.Join(req.Command, " ")
+		adapter.mu.Lock()
+		adapter.commands = append(adapter.commands, command)
+		adapter.mu.Unlock()
+		exitCode := 0
+		switch {
+		case strings.HasSuffix(command, "run-tests"):
+			stream.OnStdout([]byte("ok 3 tests\n"))
+			exitCode = 3
+		case strings.HasSuffix(command, "serve-db"):
+			stream.OnStdout([]byte("listening\n"))
+		}
+		return &backend.RunResult{RunID: req.RunID, ExitCode: exitCode}, nil
+	}
+	host, _ := startIntegrationServer(t, adapter)
+	dir := writeComposeFile(t, `
+services:
+  app:
+    depends_on: [db]
+    command: ["run-tests"]
+  db:
+    ports: [5432]
+    command: ["serve-db"]
+    ready:
+      command: ["db-ready"]
+`)
+
+	cmd := ComposeUpCommand{clientFlags: clientFlags{Host: host}, Chdir: dir, File: "cleanroom-compose.yaml", ExitCodeFrom: "app"}
+	outcome := runWithCapture(cmd.Run, nil, runtimeContext{CWD: dir, Loader: integrationLoader{}})
+	if outcome.cause != nil {
+		t.Fatalf("capture failure: %v", outcome.cause)
+	}
+	if ExitCode(outcome.err) != 3 {
+		t.Fatalf("expected app's exit code 3, got %v (stderr %q)", outcome.err, outcome.stderr)
+	}
+	for _, want := range []string{"app | ok 3 tests\n", "db  | listening\n"} {
+		if !strings.Contains(outcome.stdout, want) {
+			t.Fatalf("expected %q in output %q", want, outcome.stdout)
+		}
+	}
+
+	adapter.mu.Lock()
+	commands := strings.Join(adapter.commands, "\n")
+	adapter.mu.Unlock()
+	ready := strings.Index(commands, "db-ready")
+	tests := strings.Index(commands, "env DB_HOST=10.200.0.2 APP_HOST=10.200.1.2 run-tests")
+	if ready < 0 || tests < 0 || tests < ready {
+		t.Fatalf("expected app to start after db's ready check with both addresses, got commands %q", commands)
+	}
+
+	client := mustNewControlClient(t, host)
+	listResp, err := client.ListSandboxes(context.Background(), &cleanroomv1.ListSandboxesRequest{})
+	if err != nil {
+		t.Fatalf("ListSandboxes returned error: %v", err)
+	}
+	for _, sb := range listResp.GetSandboxes() {
+		if sb.GetStatus() != cleanroomv1.SandboxStatus_SANDBOX_STATUS_STOPPED {
+			t.Fatalf("expected compose sandboxes to be terminated, %s (%s) is %s", sb.GetName(), sb.GetSandboxId(), sb.GetStatus())
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/backend/firecracker"
+	"github.com/buildkite/cleanroom/internal/endpoint"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+	"gopkg.in/yaml.v3"
+)
+
+func (c *ConfigValidateCommand) Run(ctx *runtimeContext) error {
+	path := strings.TrimSpace(c.Path)
+	if path == "" {
+		path = ctx.ConfigPath
+	} else if !filepath.IsAbs(path) {
+		path = filepath.Join(ctx.CWD, path)
+	}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && strings.TrimSpace(c.Path) == "" {
+		_, err := fmt.Fprintf(ctx.Stdout, "no runtime config at %s; built-in defaults apply\n", path)
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+
+	problems, err := validateRuntimeConfig(raw, backendNames(ctx.Backends))
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	if c.JSON {
+		enc := json.NewEncoder(ctx.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(map[string]any{"path": path, "valid": len(problems) == 0, "problems": problems}); err != nil {
+			return err
+		}
+	} else if len(problems) == 0 {
+		_, err := fmt.Fprintf(ctx.Stdout, "runtime config valid: %s\n", path)
+		return err
+	} else {
+		for _, problem := range problems {
+			if _, err := fmt.Fprintf(ctx.Stdout, "%s: %s\n", path, problem); err != nil {
+				return err
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("runtime config has %d problem(s)", len(problems))
+	}
+	return nil
+}
+
+// validateRuntimeConfig checks keys, then the values of the top-level config
+// and of every profile applied on top of it.
+func validateRuntimeConfig(raw []byte, backends []string) ([]runtimeconfig.Problem, error) {
+	problems, err := runtimeconfig.CheckKeys(raw)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := runtimeconfig.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	base := map[string]bool{}
+	for _, p := range cfg.CheckValues(backends) {
+		problems = append(problems, p)
+		base[p.String()] = true
+	}
+
+	for _, name := range cfg.ProfileNames() {
+		profiled, err := cfg.WithProfile(name)
+		if err != nil {
+			problems = append(problems, runtimeconfig.Problem{Key: "profiles." + name, Message: err.Error()})
+			continue
+		}
+		for _, p := range profiled.CheckValues(backends) {
+			// Only report problems the profile introduces.
+			if !base[p.String()] {
+				p.Key = "profiles." + name + "." + p.Key
+				problems = append(problems, p)
+			}
+		}
+	}
+	return problems, nil
+}
+
+func backendNames(backends map[string]backend.Adapter) []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *ConfigShowCommand) Run(ctx *runtimeContext) error {
+	cfg := ctx.Config
+	cfg.Profiles = nil
+	if c.Effective {
+		var err error
+		if cfg, err = effectiveRuntimeConfig(cfg); err != nil {
+			return err
+		}
+	}
+
+	if c.JSON {
+		enc := json.NewEncoder(ctx.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]any{
+			"path":     ctx.ConfigPath,
+			"profile":  cfg.Profile,
+			"settings": cfg.Settings(),
+		})
+	}
+
+	header := "# runtime config: " + ctx.ConfigPath + "\n"
+	if cfg.Profile != "" {
+		header += "# profile: " + cfg.Profile + "\n"
+	}
+	if _, repoPath, err := runtimeconfig.LoadRepo(ctx.CWD); err == nil && repoPath != "" {
+		header += "# repo config: " + repoPath + "\n"
+	}
+	payload, err := yaml.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("marshal runtime config: %w", err)
+	}
+	_, err = fmt.Fprint(ctx.Stdout, header+string(payload))
+	return err
+}
+
+// effectiveRuntimeConfig applies the environment overrides client commands
+// honour and fills unset settings with the defaults the backends use.
+// Kernel and rootfs stay empty when unset because they are resolved per
+// sandbox, and guest_cid stays 0 because firecracker picks one per VM.
+func effectiveRuntimeConfig(cfg runtimeconfig.Config) (runtimeconfig.Config, error) {
+	if host := strings.TrimSpace(os.Getenv("CLEANROOM_HOST")); host != "" {
+		cfg.Host = host
+	}
+	if ca := strings.TrimSpace(os.Getenv("CLEANROOM_TLS_CA")); ca != "" {
+		cfg.TLSCA = ca
+	}
+	ep, err := endpoint.Resolve(cfg.Host)
+	if err != nil {
+		return runtimeconfig.Config{}, fmt.Errorf("host: %w", err)
+	}
+	cfg.Host = endpointDisplay(ep)
+	if cfg.DefaultBackend == "" {
+		cfg.DefaultBackend = runtimeconfig.DefaultBackendForHost()
+	}
+
+	fc := &cfg.Backends.Firecracker
+	setDefault(&fc.BinaryPath, firecracker.DefaultBinaryPath)
+	setDefault(&fc.PrivilegedMode, firecracker.DefaultPrivilegedMode)
+	setDefault(&fc.PrivilegedHelperPath, firecracker.DefaultPrivilegedHelperPath)
+	setDefault(&fc.VCPUs, backend.DefaultVCPUs)
+	setDefault(&fc.MemoryMiB, backend.DefaultMemoryMiB)
+	setDefault(&fc.GuestPort, vsockexec.DefaultPort)
+	setDefault(&fc.LaunchSeconds, backend.DefaultLaunchSeconds)
+	setDefault(&fc.Services.Docker.StartupTimeoutSeconds, backend.DefaultDockerStartupSeconds)
+	setDefault(&fc.Services.Docker.StorageDriver, backend.DefaultDockerStorageDriver)
+
+	vz := &cfg.Backends.DarwinVZ
+	setDefault(&vz.VCPUs, backend.DefaultVCPUs)
+	setDefault(&vz.MemoryMiB, backend.DefaultMemoryMiB)
+	setDefault(&vz.GuestPort, vsockexec.DefaultPort)
+	setDefault(&vz.LaunchSeconds, backend.DefaultLaunchSeconds)
+	setDefault(&vz.Services.Docker.StartupTimeoutSeconds, backend.DefaultDockerStartupSeconds)
+	setDefault(&vz.Services.Docker.StorageDriver, backend.DefaultDockerStorageDriver)
+	return cfg, nil
+}
+
+func setDefault[T comparable](field *T, value T) {
+	var zero T
+	if *field == zero {
+		*field = value
+	}
+}
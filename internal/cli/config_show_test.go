@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+)
+
+func TestValidateRuntimeConfigReportsProfileProblemsOnce(t *testing.T) {
+	t.Parallel()
+
+	raw := []byte(`default_backend: firecracker
+backends:
+  firecracker:
+    privileged_mode: root
+profiles:
+  work:
+    default_backend: qemu
+  home: {}
+`)
+	problems, err := validateRuntimeConfig(raw, []string{"darwin-vz", "firecracker"})
+	if err != nil {
+		t.Fatalf("validateRuntimeConfig returned error: %v", err)
+	}
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	want := strings.Join([]string{
+		`backends.firecracker.privileged_mode: unsupported value "root" (expected sudo, helper or caps)`,
+		`profiles.work.default_backend: unknown backend "qemu" (expected one of darwin-vz, firecracker)`,
+	}, "\n")
+	if strings.Join(got, "\n") != want {
+		t.Fatalf("unexpected problems:\ngot:\n%s\nwant:\n%s", strings.Join(got, "\n"), want)
+	}
+}
+
+func TestEffectiveRuntimeConfigFillsDefaults(t *testing.T) {
+	t.Setenv("CLEANROOM_HOST", "")
+	t.Setenv("CLEANROOM_TLS_CA", "/env/ca.pem")
+
+	cfg := runtimeconfig.Config{Host: "http://127.0.0.1:7777"}
+	cfg.Backends.Firecracker.VCPUs = 4
+
+	got, err := effectiveRuntimeConfig(cfg)
+	if err != nil {
+		t.Fatalf("effectiveRuntimeConfig returned error: %v", err)
+	}
+	if got.Host == "" || !strings.Contains(got.Host, "127.0.0.1:7777") {
+		t.Fatalf("unexpected host: %q", got.Host)
+	}
+	if got, want := got.TLSCA, "/env/ca.pem"; got != want {
+		t.Fatalf("unexpected tls ca: got %q want %q", got, want)
+	}
+	fc := got.Backends.Firecracker
+	if fc.VCPUs != 4 || fc.MemoryMiB != 512 || fc.LaunchSeconds != 30 || fc.BinaryPath != "firecracker" || fc.PrivilegedMode != "sudo" {
+		t.Fatalf("unexpected effective firecracker config: %+v", fc)
+	}
+	if fc.GuestCID != 0 || fc.KernelImage != "" {
+		t.Fatalf("expected per-VM settings to stay unset: %+v", fc)
+	}
+	if got.Backends.DarwinVZ.Services.Docker.StorageDriver != "vfs" {
+		t.Fatalf("unexpected darwin-vz docker storage driver: %q", got.Backends.DarwinVZ.Services.Docker.StorageDriver)
+	}
+}
@@ -0,0 +1,230 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// maxHandoffBytes caps the size of a clipboard transfer in either
+	// direction; the handoff is meant for snippets and small files.
+	maxHandoffBytes = 1 << 20
+
+	handoffClipboardFile = "clipboard"
+	handoffGuestFile     = "cleanroom-clipboard"
+	handoffHeredocMarker = "CLEANROOM_HANDOFF_EOF"
+)
+
+var osc52Prefix = []byte("\x1b]52;")
+
+// consoleHandoff moves small payloads between the local terminal and the
+// guest over the console attach stream. Guest output carrying an OSC 52
+// clipboard write is saved to <dir>/clipboard instead of being printed, and
+// "~v" or "~u" typed at the start of a line pastes that file as keystrokes
+// or uploads it to the guest working directory.
+type consoleHandoff struct {
+	dir    string
+	notice io.Writer
+	// newline is used to end notices; raw terminals need CRLF.
+	newline string
+
+	atLineStart bool
+	sawEscape   bool
+	pending     []byte
+}
+
+func newConsoleHandoff(dir string, notice io.Writer, rawMode bool) *consoleHandoff {
+	newline := "\n"
+	if rawMode {
+		newline = "\r\n"
+	}
+	return &consoleHandoff{dir: dir, notice: notice, newline: newline, atLineStart: true}
+}
+
+func (h *consoleHandoff) clipboardPath() string {
+	return filepath.Join(h.dir, handoffClipboardFile)
+}
+
+func (h *consoleHandoff) notify(format string, args ...any) {
+	_, _ = fmt.Fprintf(h.notice, "[cleanroom] "+format+h.newline, args...)
+}
+
+// FilterInput interprets escape sequences in local stdin and returns the
+// bytes to forward to the guest.
+func (h *consoleHandoff) FilterInput(chunk []byte) []byte {
+	out := make([]byte, 0, len(chunk))
+	for _, b := range chunk {
+		if h.sawEscape {
+			h.sawEscape = false
+			switch b {
+			case 'v':
+				out = append(out, h.pasteClipboard()...)
+				continue
+			case 'u':
+				out = append(out, h.uploadClipboard()...)
+				continue
+			case '?':
+				h.notify("escapes: ~v paste %s, ~u upload it to ./%s in the guest, ~~ send ~", h.clipboardPath(), handoffGuestFile)
+				continue
+			case '~':
+				out = append(out, '~')
+				h.atLineStart = false
+				continue
+			default:
+				out = append(out, '~')
+			}
+		} else if h.atLineStart && b == '~' {
+			h.sawEscape = true
+			continue
+		}
+		out = append(out, b)
+		h.atLineStart = b == '\r' || b == '\n'
+	}
+	return out
+}
+
+func (h *consoleHandoff) readClipboard() ([]byte, bool) {
+	path := h.clipboardPath()
+	info, err := os.Stat(path)
+	if err != nil {
+		h.notify("clipboard unavailable: %v", err)
+		return nil, false
+	}
+	if info.Size() > maxHandoffBytes {
+		h.notify("clipboard %s is %d bytes, limit is %d", path, info.Size(), maxHandoffBytes)
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		h.notify("clipboard unavailable: %v", err)
+		return nil, false
+	}
+	return data, true
+}
+
+func (h *consoleHandoff) pasteClipboard() []byte {
+	data, ok := h.readClipboard()
+	if !ok {
+		return nil
+	}
+	if len(data) > 0 {
+		h.atLineStart = data[len(data)-1] == '\n'
+	}
+	return data
+}
+
+// uploadClipboard types a shell heredoc that recreates the clipboard file in
+// the guest. It relies on a POSIX shell with base64 sitting at a prompt.
+func (h *consoleHandoff) uploadClipboard() []byte {
+	data, ok := h.readClipboard()
+	if !ok {
+		return nil
+	}
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "base64 -d > %s <<'%s'\n", handoffGuestFile, handoffHeredocMarker)
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76])
+		buf.WriteByte('\n')
+		encoded = encoded[76:]
+	}
+	if encoded != "" {
+		buf.WriteString(encoded)
+		buf.WriteByte('\n')
+	}
+	buf.WriteString(handoffHeredocMarker + "\n")
+	h.atLineStart = true
+	return buf.Bytes()
+}
+
+// FilterOutput removes OSC 52 clipboard writes from guest output and saves
+// their payload locally. Sequences split across chunks are held back until
+// complete.
+func (h *consoleHandoff) FilterOutput(chunk []byte) []byte {
+	data := chunk
+	if len(h.pending) > 0 {
+		data = append(h.pending, chunk...)
+		h.pending = nil
+	}
+	out := make([]byte, 0, len(data))
+	for {
+		start := bytes.IndexByte(data, 0x1b)
+		if start < 0 {
+			return append(out, data...)
+		}
+		out = append(out, data[:start]...)
+		data = data[start:]
+		if len(data) < len(osc52Prefix) && bytes.HasPrefix(osc52Prefix, data) {
+			h.pending = append([]byte(nil), data...)
+			return out
+		}
+		if !bytes.HasPrefix(data, osc52Prefix) {
+			out = append(out, data[0])
+			data = data[1:]
+			continue
+		}
+		end, termLen := findOSCTerminator(data)
+		if end < 0 {
+			// Base64 inflates by a third; allow for the selection prefix.
+			if len(data) <= maxHandoffBytes*4/3+64 {
+				h.pending = append([]byte(nil), data...)
+				return out
+			}
+			h.notify("dropped oversized clipboard transfer")
+			data = nil
+			continue
+		}
+		h.saveOSC52(data[len(osc52Prefix):end])
+		data = data[end+termLen:]
+	}
+}
+
+// Flush returns output held back waiting for a sequence to complete.
+func (h *consoleHandoff) Flush() []byte {
+	pending := h.pending
+	h.pending = nil
+	return pending
+}
+
+func findOSCTerminator(data []byte) (int, int) {
+	for i := 2; i < len(data); i++ {
+		if data[i] == 0x07 {
+			return i, 1
+		}
+		if data[i] == 0x1b && i+1 < len(data) && data[i+1] == '\\' {
+			return i, 2
+		}
+	}
+	return -1, 0
+}
+
+// saveOSC52 handles the "<selection>;<base64>" body of an OSC 52 sequence.
+// Clipboard queries ("?") are ignored so guest contents are never read back.
+func (h *consoleHandoff) saveOSC52(body []byte) {
+	sep := bytes.IndexByte(body, ';')
+	if sep < 0 {
+		return
+	}
+	payload := body[sep+1:]
+	if len(payload) == 1 && payload[0] == '?' {
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(string(payload))
+	if err != nil {
+		h.notify("ignored malformed clipboard transfer: %v", err)
+		return
+	}
+	if len(data) > maxHandoffBytes {
+		h.notify("dropped oversized clipboard transfer")
+		return
+	}
+	if err := os.WriteFile(h.clipboardPath(), data, 0o600); err != nil {
+		h.notify("save clipboard: %v", err)
+		return
+	}
+	h.notify("saved %d bytes from guest clipboard to %s", len(data), h.clipboardPath())
+}
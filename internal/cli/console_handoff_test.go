@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConsoleHandoffSavesOSC52ClipboardWrites(t *testing.T) {
+	dir := t.TempDir()
+	var notices bytes.Buffer
+	h := newConsoleHandoff(dir, &notices, false)
+
+	payload := base64.StdEncoding.EncodeToString([]byte("hello from guest\n"))
+	seq := "\x1b]52;c;" + payload + "\x07"
+	split := len(seq) / 2
+	first := h.FilterOutput([]byte("before " + seq[:split]))
+	second := h.FilterOutput([]byte(seq[split:] + " after"))
+
+	if got, want := string(first)+string(second), "before  after"; got != want {
+		t.Fatalf("unexpected output: got %q want %q", got, want)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, handoffClipboardFile))
+	if err != nil {
+		t.Fatalf("read clipboard: %v", err)
+	}
+	if string(data) != "hello from guest\n" {
+		t.Fatalf("unexpected clipboard contents: %q", data)
+	}
+	if !strings.Contains(notices.String(), "saved 17 bytes") {
+		t.Fatalf("expected save notice, got %q", notices.String())
+	}
+}
+
+func TestConsoleHandoffPassesOtherEscapeSequences(t *testing.T) {
+	h := newConsoleHandoff(t.TempDir(), &bytes.Buffer{}, false)
+	in := "\x1b[32mok\x1b[0m \x1b]0;title\x07"
+	if got := h.FilterOutput([]byte(in)); string(got) != in {
+		t.Fatalf("unexpected output: got %q want %q", got, in)
+	}
+	if tail := h.Flush(); len(tail) != 0 {
+		t.Fatalf("unexpected held-back output %q", tail)
+	}
+}
+
+func TestConsoleHandoffIgnoresClipboardQueries(t *testing.T) {
+	dir := t.TempDir()
+	h := newConsoleHandoff(dir, &bytes.Buffer{}, false)
+	if got := h.FilterOutput([]byte("\x1b]52;c;?\x1b\\")); len(got) != 0 {
+		t.Fatalf("expected query to be swallowed, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, handoffClipboardFile)); !os.IsNotExist(err) {
+		t.Fatalf("expected no clipboard file, stat err=%v", err)
+	}
+}
+
+func TestConsoleHandoffEscapesAtLineStart(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, handoffClipboardFile), []byte("echo hi\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h := newConsoleHandoff(dir, &bytes.Buffer{}, true)
+
+	got := string(h.FilterInput([]byte("~v"))) + string(h.FilterInput([]byte("a~v\r~~x\r~q")))
+	if want := "echo hi\na~v\r~x\r~q"; got != want {
+		t.Fatalf("unexpected forwarded input: got %q want %q", got, want)
+	}
+}
+
+func TestConsoleHandoffUploadWritesHeredoc(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, handoffClipboardFile), []byte("payload"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h := newConsoleHandoff(dir, &bytes.Buffer{}, false)
+
+	got := string(h.FilterInput([]byte("~u")))
+	want := "base64 -d > cleanroom-clipboard <<'CLEANROOM_HANDOFF_EOF'\n" +
+		base64.StdEncoding.EncodeToString([]byte("payload")) + "\nCLEANROOM_HANDOFF_EOF\n"
+	if got != want {
+		t.Fatalf("unexpected upload input: got %q want %q", got, want)
+	}
+}
+
+func TestConsoleHandoffReportsMissingClipboard(t *testing.T) {
+	var notices bytes.Buffer
+	h := newConsoleHandoff(t.TempDir(), &notices, false)
+	if got := h.FilterInput([]byte("~v")); len(got) != 0 {
+		t.Fatalf("expected nothing forwarded, got %q", got)
+	}
+	if !strings.Contains(notices.String(), "clipboard unavailable") {
+		t.Fatalf("expected notice, got %q", notices.String())
+	}
+}
@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"bufio"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// maxPendingEscapeBytes bounds how much of an unterminated escape sequence is
+// held back between chunks before it is dropped.
+const maxPendingEscapeBytes = 4096
+
+// useConsoleLineMode reports whether console should fall back to line mode:
+// stdin or stdout is not a terminal (for example CI logs) and the caller has
+// not forced TTY behaviour.
+func useConsoleLineMode(forceTTY bool, stdin, stdout *os.File) bool {
+	if forceTTY {
+		return false
+	}
+	return !term.IsTerminal(int(stdin.Fd())) || !term.IsTerminal(int(stdout.Fd()))
+}
+
+// consoleLineFilter strips cursor-control sequences from PTY output so a
+// console captured in a log stays readable. SGR colour sequences are kept,
+// CRLF becomes LF, and a lone CR becomes LF so progress redraws end up on
+// separate lines. Sequences split across chunks are held back until complete.
+type consoleLineFilter struct {
+	pending []byte
+}
+
+func (f *consoleLineFilter) Filter(chunk []byte) []byte {
+	data := chunk
+	if len(f.pending) > 0 {
+		data = append(f.pending, chunk...)
+		f.pending = nil
+	}
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		b := data[i]
+		switch {
+		case b == 0x1b:
+			end, keep, complete := scanEscapeSequence(data[i:])
+			if !complete {
+				if len(data)-i <= maxPendingEscapeBytes {
+					f.pending = append([]byte(nil), data[i:]...)
+				}
+				return out
+			}
+			if keep {
+				out = append(out, data[i:i+end]...)
+			}
+			i += end
+		case b == '\r':
+			if i+1 == len(data) {
+				f.pending = []byte{'\r'}
+				return out
+			}
+			if data[i+1] != '\n' {
+				out = append(out, '\n')
+			}
+			i++
+		case b == 0x07:
+			i++
+		default:
+			out = append(out, b)
+			i++
+		}
+	}
+	return out
+}
+
+// Flush returns any held-back bytes that never completed.
+func (f *consoleLineFilter) Flush() []byte {
+	pending := f.pending
+	f.pending = nil
+	if len(pending) == 1 && pending[0] == '\r' {
+		return []byte{'\n'}
+	}
+	return nil
+}
+
+// scanEscapeSequence measures the escape sequence at the start of data. It
+// reports the sequence length, whether it should be kept (SGR only), and
+// whether the sequence is complete.
+func scanEscapeSequence(data []byte) (int, bool, bool) {
+	if len(data) < 2 {
+		return 0, false, false
+	}
+	switch data[1] {
+	case '[':
+		for j := 2; j < len(data); j++ {
+			c := data[j]
+			if c >= 0x40 && c <= 0x7e {
+				return j + 1, c == 'm', true
+			}
+			if c < 0x20 || c > 0x3f {
+				// Malformed sequence: drop the introducer and resume.
+				return j, false, true
+			}
+		}
+		return 0, false, false
+	case ']':
+		for j := 2; j < len(data); j++ {
+			if data[j] == 0x07 {
+				return j + 1, false, true
+			}
+			if data[j] == 0x1b && j+1 < len(data) && data[j+1] == '\\' {
+				return j + 2, false, true
+			}
+		}
+		return 0, false, false
+	case '(', ')', '*', '+':
+		if len(data) < 3 {
+			return 0, false, false
+		}
+		return 3, false, true
+	default:
+		return 2, false, true
+	}
+}
+
+// forwardConsoleLines forwards stdin to the session one line at a time and
+// closes stdin on EOF.
+func forwardConsoleLines(r io.Reader, write func([]byte) error, closeStdin func() error) {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if sendErr := write(line); sendErr != nil {
+				return
+			}
+		}
+		if err != nil {
+			_ = closeStdin()
+			return
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConsoleLineFilterStripsCursorControlAndKeepsColor(t *testing.T) {
+	var f consoleLineFilter
+	got := f.Filter([]byte("\x1b[2J\x1b[H\x1b[32mok\x1b[0m\r\n\x1b]0;title\x07done\x1b[?25l\n"))
+	if want := "\x1b[32mok\x1b[0m\ndone\n"; string(got) != want {
+		t.Fatalf("unexpected filtered output: got %q want %q", got, want)
+	}
+}
+
+func TestConsoleLineFilterHoldsSequencesSplitAcrossChunks(t *testing.T) {
+	var f consoleLineFilter
+	first := f.Filter([]byte("step 1\x1b[1"))
+	second := f.Filter([]byte("0Dstep 2\r"))
+	third := f.Filter([]byte("\nstep 3\r"))
+	tail := f.Flush()
+
+	got := string(first) + string(second) + string(third) + string(tail)
+	if want := "step 1step 2\nstep 3\n"; got != want {
+		t.Fatalf("unexpected filtered output: got %q want %q", got, want)
+	}
+}
+
+func TestConsoleLineFilterTurnsLoneCarriageReturnIntoNewline(t *testing.T) {
+	var f consoleLineFilter
+	got := f.Filter([]byte("10%\r50%\r100%\n"))
+	if want := "10%\n50%\n100%\n"; string(got) != want {
+		t.Fatalf("unexpected filtered output: got %q want %q", got, want)
+	}
+}
+
+func TestForwardConsoleLinesSendsWholeLinesThenCloses(t *testing.T) {
+	var sent []string
+	closed := false
+	forwardConsoleLines(strings.NewReader("select 1;\nselect 2;\npartial"), func(b []byte) error {
+		sent = append(sent, string(b))
+		return nil
+	}, func() error {
+		closed = true
+		return nil
+	})
+
+	if got, want := strings.Join(sent, "|"), "select 1;\n|select 2;\n|partial"; got != want {
+		t.Fatalf("unexpected forwarded lines: got %q want %q", got, want)
+	}
+	if !closed {
+		t.Fatal("expected stdin to be closed at EOF")
+	}
+}
+
+func TestForwardConsoleLinesStopsOnWriteError(t *testing.T) {
+	calls := 0
+	closed := false
+	forwardConsoleLines(strings.NewReader("a\nb\n"), func([]byte) error {
+		calls++
+		return errors.New("session closed")
+	}, func() error {
+		closed = true
+		return nil
+	})
+	if calls != 1 {
+		t.Fatalf("expected forwarding to stop after first failed write, got %d calls", calls)
+	}
+	if closed {
+		t.Fatal("expected stdin not to be closed after a write failure")
+	}
+}
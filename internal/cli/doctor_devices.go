@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/policy"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+)
+
+// pciDevicesDir is where the kernel lists PCI devices.
+const pciDevicesDir = "/sys/bus/pci/devices"
+
+// vfioDoctorChecks reports whether each configured passthrough device under
+// pciDir is bound to vfio-pci, and whether the selected backend can attach
+// the policy's devices.
+func vfioDoctorChecks(pciDir string, devices []runtimeconfig.VFIODevice, backendName string, supported bool, compiled *policy.CompiledPolicy) []backend.DoctorCheck {
+	var checks []backend.DoctorCheck
+	for _, device := range devices {
+		check := backend.DoctorCheck{Name: "vfio_device_" + capabilityNameReplacer.Replace(device.Name)}
+		driver, err := os.Readlink(filepath.Join(pciDir, device.PCIAddress, "driver"))
+		switch {
+		case err != nil:
+			check.Status = "fail"
+			check.Message = fmt.Sprintf("%s (%s) not found: %v", device.Name, device.PCIAddress, err)
+			check.Remediation = "check devices.vfio[].pci_address against lspci -D"
+		case filepath.Base(driver) != "vfio-pci":
+			check.Status = "fail"
+			check.Message = fmt.Sprintf("%s (%s) is bound to %s, not vfio-pci", device.Name, device.PCIAddress, filepath.Base(driver))
+			check.Remediation = "bind the device to vfio-pci, for example with driverctl set-override " + device.PCIAddress + " vfio-pci"
+		default:
+			check.Status = "pass"
+			check.Message = fmt.Sprintf("%s (%s) is bound to vfio-pci", device.Name, device.PCIAddress)
+		}
+		checks = append(checks, check)
+	}
+	if compiled != nil && len(compiled.VFIODevices) > 0 && !supported {
+		checks = append(checks, backend.DoctorCheck{
+			Name:        "vfio_policy",
+			Status:      "fail",
+			Message:     fmt.Sprintf("policy requests vfio devices %v, which backend %s cannot attach", compiled.VFIODevices, backendName),
+			Remediation: "remove sandbox.devices.vfio from the policy; device passthrough is experimental and no backend supports it yet",
+		})
+	}
+	return checks
+}
@@ -115,8 +115,8 @@ func TestDoctorCommandJSONIncludesCapabilities(t *testing.T) {
 	if payload.Gateway.DefaultPort != 8170 {
 		t.Fatalf("unexpected gateway default port: %d", payload.Gateway.DefaultPort)
 	}
-	if len(payload.Gateway.Routes) != 4 {
-		t.Fatalf("expected 4 gateway routes, got %d (%v)", len(payload.Gateway.Routes), payload.Gateway.Routes)
+	if len(payload.Gateway.Routes) != 5 {
+		t.Fatalf("expected 5 gateway routes, got %d (%v)", len(payload.Gateway.Routes), payload.Gateway.Routes)
 	}
 	foundGitHub := false
 	for _, h := range payload.Gateway.CredentialHosts {
@@ -186,3 +186,143 @@ func TestDoctorCommandTextUsesPolishedPlainOutput(t *testing.T) {
 		t.Fatalf("expected plain output without ANSI escapes, got: %q", out)
 	}
 }
+
+type doctorFailingCheckAdapter struct {
+	doctorTestAdapter
+}
+
+func (doctorFailingCheckAdapter) Doctor(context.Context, backend.DoctorRequest) (*backend.DoctorReport, error) {
+	return &backend.DoctorReport{
+		Backend: "doctor-test",
+		Checks: []backend.DoctorCheck{
+			{Name: "kvm", Status: "fail", Message: "missing /dev/kvm", Remediation: "enable KVM"},
+		},
+	}, nil
+}
+
+func TestDoctorCommandExitStatusFollowsFailOn(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		adapter  backend.Adapter
+		failOn   string
+		wantFail bool
+	}{
+		{name: "warnings pass by default", adapter: doctorTestAdapter{}, wantFail: false},
+		{name: "warnings fail with fail-on warn", adapter: doctorTestAdapter{}, failOn: "warn", wantFail: true},
+		{name: "failures fail by default", adapter: doctorFailingCheckAdapter{}, failOn: "fail", wantFail: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			stdout, _ := makeStdoutCapture(t)
+
+			cmd := DoctorCommand{Backend: "doctor-test", FailOn: tc.failOn}
+			err := cmd.Run(&runtimeContext{
+				CWD:        tmpDir,
+				Stdout:     stdout,
+				Loader:     doctorFailingLoader{},
+				ConfigPath: filepath.Join(tmpDir, "config.yaml"),
+				Backends: map[string]backend.Adapter{
+					"doctor-test": tc.adapter,
+				},
+			})
+			if !tc.wantFail {
+				if err != nil {
+					t.Fatalf("DoctorCommand.Run returned error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected doctor to fail")
+			}
+			if got, want := ExitCode(err), 1; got != want {
+				t.Fatalf("unexpected exit code: got %d want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestDoctorCommandJSONIncludesStableIDsAndRemediation(t *testing.T) {
+	tmpDir := t.TempDir()
+	stdout, readStdout := makeStdoutCapture(t)
+
+	cmd := DoctorCommand{Backend: "doctor-test", JSON: true}
+	err := cmd.Run(&runtimeContext{
+		CWD:        tmpDir,
+		Stdout:     stdout,
+		Loader:     doctorFailingLoader{},
+		ConfigPath: filepath.Join(tmpDir, "config.yaml"),
+		Backends: map[string]backend.Adapter{
+			"doctor-test": doctorFailingCheckAdapter{},
+		},
+	})
+	if ExitCode(err) != 1 {
+		t.Fatalf("expected doctor failure exit code, got err %v", err)
+	}
+
+	var payload struct {
+		Status  string `json:"status"`
+		FailOn  string `json:"fail_on"`
+		Summary struct {
+			Fail int `json:"fail"`
+		} `json:"summary"`
+		Checks []backend.DoctorCheck `json:"checks"`
+	}
+	if err := json.Unmarshal([]byte(readStdout()), &payload); err != nil {
+		t.Fatalf("unmarshal doctor JSON: %v", err)
+	}
+	if payload.Status != "fail" || payload.FailOn != "fail" || payload.Summary.Fail != 1 {
+		t.Fatalf("unexpected doctor verdict: status %q fail_on %q fail %d", payload.Status, payload.FailOn, payload.Summary.Fail)
+	}
+
+	byID := map[string]backend.DoctorCheck{}
+	for _, check := range payload.Checks {
+		if check.ID == "" {
+			t.Fatalf("check %q has no id", check.Name)
+		}
+		byID[check.ID] = check
+	}
+	if got := byID["doctor-test.kvm"].Remediation; got != "enable KVM" {
+		t.Fatalf("unexpected backend check remediation: got %q", got)
+	}
+	if got := byID["cleanroom.repository_policy"].Remediation; got == "" {
+		t.Fatal("expected remediation for repository_policy warning")
+	}
+	if _, ok := byID["cleanroom.runtime_config"]; !ok {
+		t.Fatalf("expected cleanroom.runtime_config check, got %v", payload.Checks)
+	}
+}
+
+func TestVFIODoctorChecksReportDriverBinding(t *testing.T) {
+	t.Parallel()
+
+	pciDir := t.TempDir()
+	bind := func(address, driver string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(pciDir, address), 0o755); err != nil {
+			t.Fatalf("mkdir device: %v", err)
+		}
+		if err := os.Symlink(filepath.Join("../../../bus/pci/drivers", driver), filepath.Join(pciDir, address, "driver")); err != nil {
+			t.Fatalf("symlink driver: %v", err)
+		}
+	}
+	bind("0000:65:00.0", "vfio-pci")
+	bind("0000:66:00.0", "nvidia")
+
+	checks := vfioDoctorChecks(pciDir, []runtimeconfig.VFIODevice{
+		{Name: "gpu", PCIAddress: "0000:65:00.0"},
+		{Name: "gpu-2", PCIAddress: "0000:66:00.0"},
+		{Name: "missing", PCIAddress: "0000:67:00.0"},
+	}, "firecracker", false, &policy.CompiledPolicy{VFIODevices: []string{"gpu"}})
+
+	got := make([]string, 0, len(checks))
+	for _, check := range checks {
+		got = append(got, check.Name+"="+check.Status)
+	}
+	want := "vfio_device_gpu=pass vfio_device_gpu_2=fail vfio_device_missing=fail vfio_policy=fail"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("unexpected checks: got %q want %q", strings.Join(got, " "), want)
+	}
+	if !strings.Contains(checks[1].Message, "bound to nvidia") {
+		t.Fatalf("unexpected driver message: %q", checks[1].Message)
+	}
+}
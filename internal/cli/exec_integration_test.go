@@ -2,6 +2,7 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -331,6 +332,121 @@ func TestExecIntegrationStreamsOutput(t *testing.T) {
 	}
 }
 
+func TestExecIntegrationStdinFileStreamsFileThenEOF(t *testing.T) {
+	input := strings.Repeat("select 1;\n", 8*1024)
+	adapter := &integrationAdapter{
+		runStreamFn: func(_ context.Context, req backend.RunRequest, stream backend.OutputStream) (*backend.RunResult, error) {
+			if !req.Stdin {
+				return nil, errors.New("expected stdin to be kept open")
+			}
+			var (
+				mu       sync.Mutex
+				received strings.Builder
+			)
+			eof := make(chan struct{})
+			stream.OnAttach(backend.AttachIO{
+				WriteStdin: func(data []byte) error {
+					mu.Lock()
+					defer mu.Unlock()
+					received.Write(data)
+					return nil
+				},
+				CloseStdin: func() error {
+					close(eof)
+					return nil
+				},
+			})
+			select {
+			case <-eof:
+			case <-time.After(5 * time.Second):
+				return nil, errors.New("timed out waiting for stdin eof")
+			}
+			mu.Lock()
+			got := received.String()
+			mu.Unlock()
+			stream.OnStdout([]byte(fmt.Sprintf("bytes=%d\n", len(got))))
+			exitCode := 0
+			if got != input {
+				exitCode = 1
+			}
+			return &backend.RunResult{RunID: req.RunID, ExitCode: exitCode, Message: "ok"}, nil
+		},
+	}
+
+	host, _ := startIntegrationServer(t, adapter)
+	cwd := t.TempDir()
+	stdinPath := filepath.Join(cwd, "input.sql")
+	if err := os.WriteFile(stdinPath, []byte(input), 0o644); err != nil {
+		t.Fatalf("write stdin file: %v", err)
+	}
+	outcome := runExecWithCapture(ExecCommand{
+		clientFlags: clientFlags{Host: host},
+		Chdir:       cwd,
+		StdinFile:   stdinPath,
+		Command:     []string{"psql"},
+	}, runtimeContext{
+		CWD:    cwd,
+		Loader: integrationLoader{},
+	})
+
+	if outcome.cause != nil {
+		t.Fatalf("capture failure: %v", outcome.cause)
+	}
+	if outcome.err != nil {
+		t.Fatalf("ExecCommand.Run returned error: %v (stderr %q)", outcome.err, outcome.stderr)
+	}
+	if got, want := outcome.stdout, fmt.Sprintf("bytes=%d\n", len(input)); got != want {
+		t.Fatalf("unexpected stdout: got %q want %q", got, want)
+	}
+}
+
+func TestExecIntegrationStdinFileFailureCancelsExecution(t *testing.T) {
+	canceled := make(chan struct{})
+	adapter := &integrationAdapter{
+		runStreamFn: func(ctx context.Context, req backend.RunRequest, stream backend.OutputStream) (*backend.RunResult, error) {
+			stream.OnAttach(backend.AttachIO{
+				WriteStdin: func([]byte) error { return errors.New("guest stdin closed") },
+				CloseStdin: func() error { return nil },
+			})
+			select {
+			case <-ctx.Done():
+				close(canceled)
+				return nil, ctx.Err()
+			case <-time.After(5 * time.Second):
+				return nil, errors.New("timed out waiting for cancellation")
+			}
+		},
+	}
+
+	host, _ := startIntegrationServer(t, adapter)
+	cwd := t.TempDir()
+	stdinPath := filepath.Join(cwd, "input.sql")
+	if err := os.WriteFile(stdinPath, []byte("select 1;\n"), 0o644); err != nil {
+		t.Fatalf("write stdin file: %v", err)
+	}
+	outcome := runExecWithCapture(ExecCommand{
+		clientFlags: clientFlags{Host: host},
+		Chdir:       cwd,
+		StdinFile:   stdinPath,
+		Command:     []string{"psql"},
+	}, runtimeContext{
+		CWD:    cwd,
+		Loader: integrationLoader{},
+	})
+
+	if outcome.cause != nil {
+		t.Fatalf("capture failure: %v", outcome.cause)
+	}
+	if outcome.err == nil || !strings.Contains(outcome.err.Error(), "stream stdin file") {
+		t.Fatalf("expected a stdin streaming error, got %v", outcome.err)
+	}
+	select {
+	case <-canceled:
+	default:
+		t.Fatal("expected the execution to be canceled")
+	}
+}
+
 func TestExecIntegrationPropagatesExitAndStderr(t *testing.T) {
 	adapter := &integrationAdapter{
 		runFn: func(_ context.Context, req backend.RunRequest) (*backend.RunResult, error) {
@@ -372,6 +488,81 @@ func TestExecIntegrationPropagatesExitAndStderr(t *testing.T) {
 	}
 }
 
+func TestExecIntegrationJSONFormatReportsExitMetadata(t *testing.T) {
+	adapter := &integrationAdapter{
+		runFn: func(_ context.Context, req backend.RunRequest) (*backend.RunResult, error) {
+			return &backend.RunResult{
+				RunID:    req.RunID,
+				ExitCode: 137,
+				Stdout:   "out\n",
+				Stderr:   "err\n",
+				ExitMetadata: &backend.ExitMetadata{
+					UserCPU:     1500 * time.Millisecond,
+					SystemCPU:   250 * time.Millisecond,
+					Wall:        3 * time.Second,
+					MaxRSSBytes: 64 << 20,
+					Signal:      9,
+					SignalName:  "SIGKILL",
+				},
+			}, nil
+		},
+	}
+
+	host, _ := startIntegrationServer(t, adapter)
+	cwd := t.TempDir()
+	outcome := runExecWithCapture(ExecCommand{
+		clientFlags: clientFlags{Host: host},
+		Chdir:       cwd,
+		Format:      "json",
+		Command:     []string{"echo", "ignored-by-adapter"},
+	}, runtimeContext{
+		CWD:    cwd,
+		Loader: integrationLoader{},
+	})
+
+	if outcome.cause != nil {
+		t.Fatalf("capture failure: %v", outcome.cause)
+	}
+	if got, want := ExitCode(outcome.err), 137; got != want {
+		t.Fatalf("unexpected cli exit code: got %d want %d", got, want)
+	}
+	if outcome.stderr != "" {
+		t.Fatalf("expected stderr to be captured in the report, got %q", outcome.stderr)
+	}
+
+	var report struct {
+		ExecutionID string `json:"execution_id"`
+		Status      string `json:"status"`
+		ExitCode    int    `json:"exit_code"`
+		Stdout      string `json:"stdout"`
+		Stderr      string `json:"stderr"`
+		Metadata    struct {
+			UserCPUMillis int64  `json:"user_cpu_ms"`
+			SysCPUMillis  int64  `json:"sys_cpu_ms"`
+			MaxRSSBytes   int64  `json:"max_rss_bytes"`
+			WallMillis    int64  `json:"wall_ms"`
+			Signaled      bool   `json:"signaled"`
+			SignalName    string `json:"signal_name"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(outcome.stdout), &report); err != nil {
+		t.Fatalf("decode exec report %q: %v", outcome.stdout, err)
+	}
+	if report.ExecutionID == "" || report.ExitCode != 137 || report.Status != "failed" {
+		t.Fatalf("unexpected exec report: %+v", report)
+	}
+	if report.Stdout != "out\n" || !strings.Contains(report.Stderr, "err\n") {
+		t.Fatalf("unexpected captured output: stdout=%q stderr=%q", report.Stdout, report.Stderr)
+	}
+	m := report.Metadata
+	if m.UserCPUMillis != 1500 || m.SysCPUMillis != 250 || m.WallMillis != 3000 || m.MaxRSSBytes != 64<<20 {
+		t.Fatalf("unexpected resource metadata: %+v", m)
+	}
+	if !m.Signaled || m.SignalName != "SIGKILL" {
+		t.Fatalf("unexpected signal metadata: %+v", m)
+	}
+}
+
 func TestExecIntegrationFirstInterruptCancelsExecution(t *testing.T) {
 	started := make(chan struct{}, 1)
 	adapter := &integrationAdapter{
@@ -416,6 +607,50 @@ func TestExecIntegrationFirstInterruptCancelsExecution(t *testing.T) {
 	}
 }
 
+func TestExecIntegrationTimeoutCancelsExecution(t *testing.T) {
+	canceled := make(chan struct{})
+	adapter := &integrationAdapter{
+		runFn: func(ctx context.Context, _ backend.RunRequest) (*backend.RunResult, error) {
+			<-ctx.Done()
+			close(canceled)
+			return nil, ctx.Err()
+		},
+	}
+
+	host, _ := startIntegrationServer(t, adapter)
+	cwd := t.TempDir()
+	cmd := ExecCommand{
+		clientFlags: clientFlags{Host: host, Timeout: 200 * time.Millisecond},
+		Chdir:       cwd,
+		Command:     []string{"sleep", "300"},
+	}
+	cmdCtx, cancel := newCommandContext(&cmd)
+	defer cancel()
+
+	done := make(chan execOutcome, 1)
+	go func() {
+		outcome := runExecWithCapture(cmd, runtimeContext{
+			CWD:     cwd,
+			Loader:  integrationLoader{},
+			Context: cmdCtx,
+		})
+		outcome.err = timedOut(cmdCtx, outcome.err)
+		done <- outcome
+	}()
+
+	outcome := mustReceiveWithin(t, done, 5*time.Second, "timed out waiting for exec to give up")
+	if outcome.cause != nil {
+		t.Fatalf("capture failure: %v", outcome.cause)
+	}
+	if got, want := ExitCode(outcome.err), timeoutExitCode; got != want {
+		t.Fatalf("unexpected cli exit code: got %d want %d (err=%v)", got, want, outcome.err)
+	}
+	if !strings.Contains(outcome.err.Error(), "timed out after 200ms") {
+		t.Fatalf("unexpected error: %v", outcome.err)
+	}
+	_ = mustReceiveWithin(t, canceled, 5*time.Second, "expected the timed out execution to be canceled on the server")
+}
+
 func TestExecIntegrationSecondInterruptTerminatesSandboxWithRemove(t *testing.T) {
 	started := make(chan struct{}, 1)
 	releaseRun := make(chan struct{})
@@ -751,3 +986,71 @@ func TestExecRejectsUnsupportedHostScheme(t *testing.T) {
 		t.Fatalf("expected unsupported endpoint error, got %v", outcome.err)
 	}
 }
+
+func TestExecIntegrationReuseKeepsSandboxUntilPolicyChanges(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	host, svc := startIntegrationServer(t, &integrationAdapter{})
+	cwd := t.TempDir()
+
+	runReuse := func() {
+		t.Helper()
+		outcome := runExecWithCapture(ExecCommand{
+			clientFlags: clientFlags{Host: host},
+			Chdir:       cwd,
+			Reuse:       true,
+			ReuseTTL:    time.Hour,
+			Command:     []string{"true"},
+		}, runtimeContext{
+			CWD:    cwd,
+			Loader: integrationLoader{},
+		})
+		if outcome.cause != nil {
+			t.Fatalf("capture failure: %v", outcome.cause)
+		}
+		if outcome.err != nil {
+			t.Fatalf("ExecCommand.Run returned error: %v (stderr %q)", outcome.err, outcome.stderr)
+		}
+	}
+	readLease := func() *sandboxLease {
+		t.Helper()
+		leasePath, err := sandboxLeasePath(cwd, host, "")
+		if err != nil {
+			t.Fatalf("sandboxLeasePath returned error: %v", err)
+		}
+		lease, err := readSandboxLease(leasePath)
+		if err != nil || lease == nil {
+			t.Fatalf("expected lease at %s, got %v (err %v)", leasePath, lease, err)
+		}
+		return lease
+	}
+
+	runReuse()
+	first := readLease()
+	runReuse()
+	if got := readLease().SandboxID; got != first.SandboxID {
+		t.Fatalf("expected --reuse to keep sandbox %q, got %q", first.SandboxID, got)
+	}
+
+	// Simulate a policy edit by recording a different hash in the lease.
+	leasePath, _ := sandboxLeasePath(cwd, host, "")
+	stale := *first
+	stale.PolicyHash = "sha256:old"
+	if err := writeSandboxLease(leasePath, stale); err != nil {
+		t.Fatalf("writeSandboxLease returned error: %v", err)
+	}
+	runReuse()
+	replaced := readLease()
+	if replaced.SandboxID == first.SandboxID {
+		t.Fatalf("expected a new sandbox after policy change, still %q", replaced.SandboxID)
+	}
+
+	resp, err := svc.ListSandboxes(context.Background(), &cleanroomv1.ListSandboxesRequest{})
+	if err != nil {
+		t.Fatalf("ListSandboxes returned error: %v", err)
+	}
+	for _, sb := range resp.GetSandboxes() {
+		if sb.GetSandboxId() == first.SandboxID && sb.GetStatus() == cleanroomv1.SandboxStatus_SANDBOX_STATUS_READY {
+			t.Fatalf("expected replaced sandbox %q to be terminated", first.SandboxID)
+		}
+	}
+}
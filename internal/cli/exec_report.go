@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// execReport is the object printed by exec --format json. Field names are
+// part of the CLI's output contract.
+type execReport struct {
+	SandboxID   string            `json:"sandbox_id"`
+	ExecutionID string            `json:"execution_id"`
+	Status      string            `json:"status,omitempty"`
+	ExitCode    int               `json:"exit_code"`
+	Failure     string            `json:"failure,omitempty"`
+	Retriable   bool              `json:"retriable,omitempty"`
+	Stdout      string            `json:"stdout"`
+	Stderr      string            `json:"stderr"`
+	Metadata    *execExitMetadata `json:"metadata,omitempty"`
+	Timings     *execTimings      `json:"timings,omitempty"`
+	Artifacts   []execArtifact    `json:"artifacts,omitempty"`
+	Tests       *execTestResults  `json:"tests,omitempty"`
+	Environment *execEnvironment  `json:"environment,omitempty"`
+
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+}
+
+type execExitMetadata struct {
+	UserCPUMillis   int64  `json:"user_cpu_ms"`
+	SystemCPUMillis int64  `json:"sys_cpu_ms"`
+	MaxRSSBytes     int64  `json:"max_rss_bytes"`
+	WallMillis      int64  `json:"wall_ms"`
+	Signaled        bool   `json:"signaled"`
+	Signal          int32  `json:"signal,omitempty"`
+	SignalName      string `json:"signal_name,omitempty"`
+}
+
+type execTimings struct {
+	PolicyResolveMillis int64 `json:"policy_resolve_ms,omitempty"`
+	RootFSCopyMillis    int64 `json:"rootfs_copy_ms,omitempty"`
+	VMStartMillis       int64 `json:"vm_start_ms,omitempty"`
+	NetworkSetupMillis  int64 `json:"network_setup_ms,omitempty"`
+	VMReadyMillis       int64 `json:"vm_ready_ms,omitempty"`
+	VsockWaitMillis     int64 `json:"vsock_wait_ms,omitempty"`
+	GuestExecMillis     int64 `json:"guest_exec_ms,omitempty"`
+	TotalMillis         int64 `json:"total_ms"`
+	ImageCacheHit       bool  `json:"image_cache_hit,omitempty"`
+}
+
+type execArtifact struct {
+	Path      string `json:"path"`
+	Name      string `json:"name"`
+	MediaType string `json:"media_type,omitempty"`
+}
+
+// execEnvironment is what the execution ran on, from the stream's
+// environment event.
+type execEnvironment struct {
+	ImageRef         string `json:"image_ref,omitempty"`
+	ImageDigest      string `json:"image_digest,omitempty"`
+	PolicyHash       string `json:"policy_hash,omitempty"`
+	Backend          string `json:"backend,omitempty"`
+	ServerVersion    string `json:"server_version,omitempty"`
+	GuestAgentSHA256 string `json:"guest_agent_sha256,omitempty"`
+	KernelVersion    string `json:"kernel_version,omitempty"`
+	VCPUs            int64  `json:"vcpus,omitempty"`
+	MemoryMiB        int64  `json:"memory_mib,omitempty"`
+	DiskMiB          int64  `json:"disk_mib,omitempty"`
+}
+
+type execTestResults struct {
+	Total       int32    `json:"total"`
+	Passed      int32    `json:"passed"`
+	Failed      int32    `json:"failed"`
+	Skipped     int32    `json:"skipped"`
+	FailedTests []string `json:"failed_tests,omitempty"`
+	Sources     []string `json:"sources,omitempty"`
+	Errors      []string `json:"errors,omitempty"`
+}
+
+// formatTestResults renders a one-line summary of an execution's test
+// results.
+func formatTestResults(t *cleanroomv1.ExecutionTestResults) string {
+	line := fmt.Sprintf("tests: %d passed, %d failed, %d skipped", t.GetPassed(), t.GetFailed(), t.GetSkipped())
+	if len(t.GetSources()) > 0 {
+		line += " (" + strings.Join(t.GetSources(), ", ") + ")"
+	}
+	for _, msg := range t.GetErrors() {
+		line += "; " + msg
+	}
+	return line
+}
+
+func (r *execReport) setExit(exit *cleanroomv1.ExecutionExit) {
+	r.Status = strings.ToLower(strings.TrimPrefix(exit.GetStatus().String(), "EXECUTION_STATUS_"))
+	if reason := exit.GetFailureReason(); reason != cleanroomv1.ExecutionFailureReason_EXECUTION_FAILURE_REASON_UNSPECIFIED {
+		r.Failure = strings.ToLower(strings.TrimPrefix(reason.String(), "EXECUTION_FAILURE_REASON_"))
+	}
+	r.Retriable = exit.GetRetriable()
+	for _, artifact := range exit.GetArtifacts() {
+		r.Artifacts = append(r.Artifacts, execArtifact{
+			Path:      artifact.GetPath(),
+			Name:      artifact.GetName(),
+			MediaType: artifact.GetMediaType(),
+		})
+	}
+	if t := exit.GetTestResults(); t != nil {
+		r.Tests = &execTestResults{
+			Total:       t.GetTotal(),
+			Passed:      t.GetPassed(),
+			Failed:      t.GetFailed(),
+			Skipped:     t.GetSkipped(),
+			FailedTests: t.GetFailedTests(),
+			Sources:     t.GetSources(),
+			Errors:      t.GetErrors(),
+		}
+	}
+	if t := exit.GetTimings(); t != nil {
+		r.Timings = &execTimings{
+			PolicyResolveMillis: t.GetPolicyResolveMs(),
+			RootFSCopyMillis:    t.GetRootfsCopyMs(),
+			VMStartMillis:       t.GetVmStartMs(),
+			NetworkSetupMillis:  t.GetNetworkSetupMs(),
+			VMReadyMillis:       t.GetVmReadyMs(),
+			VsockWaitMillis:     t.GetVsockWaitMs(),
+			GuestExecMillis:     t.GetGuestExecMs(),
+			TotalMillis:         t.GetTotalMs(),
+			ImageCacheHit:       t.GetImageCacheHit(),
+		}
+	}
+	m := exit.GetMetadata()
+	if m == nil {
+		return
+	}
+	r.Metadata = &execExitMetadata{
+		UserCPUMillis:   m.GetUserCpuMs(),
+		SystemCPUMillis: m.GetSystemCpuMs(),
+		MaxRSSBytes:     m.GetMaxRssBytes(),
+		WallMillis:      m.GetWallMs(),
+		Signaled:        m.GetSignaled(),
+		Signal:          m.GetSignal(),
+		SignalName:      m.GetSignalName(),
+	}
+}
+
+func (r *execReport) setEnvironment(env *cleanroomv1.ExecutionEnvironment) {
+	r.Environment = &execEnvironment{
+		ImageRef:         env.GetImageRef(),
+		ImageDigest:      env.GetImageDigest(),
+		PolicyHash:       env.GetPolicyHash(),
+		Backend:          env.GetBackend(),
+		ServerVersion:    env.GetServerVersion(),
+		GuestAgentSHA256: env.GetGuestAgentSha256(),
+		KernelVersion:    env.GetKernelVersion(),
+		VCPUs:            env.GetVcpus(),
+		MemoryMiB:        env.GetMemoryMib(),
+		DiskMiB:          env.GetDiskMib(),
+	}
+}
+
+func (r *execReport) write(w io.Writer) error {
+	r.Stdout = r.stdout.String()
+	r.Stderr = r.stderr.String()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+type ExecutionCommand struct {
+	List     ExecutionListCommand     `name:"ls" aliases:"list" cmd:"" help:"List executions, optionally only those with given annotations"`
+	Annotate ExecutionAnnotateCommand `cmd:"" help:"Set annotations on an execution"`
+}
+
+type ExecutionListCommand struct {
+	clientFlags
+	SandboxID   string            `name:"sandbox-id" completion:"sandbox" help:"Only list this sandbox's executions"`
+	Annotations map[string]string `name:"annotation" help:"Only list executions with this annotation (key=value, repeatable; all must match)"`
+	JSON        bool              `help:"Print executions as JSON"`
+}
+
+type ExecutionAnnotateCommand struct {
+	clientFlags
+	SandboxID   string   `arg:"" completion:"sandbox" help:"Sandbox ID"`
+	ExecutionID string   `arg:"" help:"Execution ID"`
+	Annotations []string `arg:"" placeholder:"KEY=VALUE" help:"Annotations to set; an empty value (KEY=) removes the key"`
+}
+
+func (c *ExecutionListCommand) Run(ctx *runtimeContext) error {
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	resp, err := client.ListExecutions(ctx.commandContext(), &cleanroomv1.ListExecutionsRequest{
+		SandboxId:   c.SandboxID,
+		Annotations: c.Annotations,
+	})
+	if err != nil {
+		return err
+	}
+
+	if c.JSON {
+		enc := json.NewEncoder(ctx.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(resp.GetExecutions())
+	}
+	if len(resp.GetExecutions()) == 0 {
+		_, err := fmt.Fprintln(ctx.Stdout, "no executions")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(ctx.Stdout, 0, 2, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "SANDBOX\tEXECUTION\tSTATUS\tEXIT\tANNOTATIONS\tCOMMAND"); err != nil {
+		return err
+	}
+	for _, ex := range resp.GetExecutions() {
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n",
+			ex.GetSandboxId(),
+			ex.GetExecutionId(),
+			strings.ToLower(strings.TrimPrefix(ex.GetStatus().String(), "EXECUTION_STATUS_")),
+			ex.GetExitCode(),
+			formatAnnotations(ex.GetAnnotations()),
+			strings.Join(ex.GetCommand(), " "),
+		); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+func (c *ExecutionAnnotateCommand) Run(ctx *runtimeContext) error {
+	annotations, err := parseAnnotations(c.Annotations)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	resp, err := client.AnnotateExecution(ctx.commandContext(), &cleanroomv1.AnnotateExecutionRequest{
+		SandboxId:   c.SandboxID,
+		ExecutionId: c.ExecutionID,
+		Annotations: annotations,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(ctx.Stdout, "annotated execution %s: %s\n", resp.GetExecution().GetExecutionId(), formatAnnotations(resp.GetExecution().GetAnnotations()))
+	return err
+}
+
+// parseAnnotations parses KEY=VALUE arguments. The value may be empty.
+func parseAnnotations(args []string) (map[string]string, error) {
+	out := make(map[string]string, len(args))
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid annotation %q: expected KEY=VALUE", arg)
+		}
+		out[key] = value
+	}
+	return out, nil
+}
+
+// formatAnnotations renders annotations as sorted key=value pairs.
+func formatAnnotations(annotations map[string]string) string {
+	if len(annotations) == 0 {
+		return "-"
+	}
+	pairs := make([]string, 0, len(annotations))
+	for _, key := range slices.Sorted(maps.Keys(annotations)) {
+		pairs = append(pairs, key+"="+annotations[key])
+	}
+	return strings.Join(pairs, ",")
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/policy"
 )
 
 const (
@@ -238,3 +239,62 @@ func TestConsoleIntegrationRejectsImageOverrideWhenSandboxProvided(t *testing.T)
 		t.Fatalf("expected error to contain %q, got %q", want, got)
 	}
 }
+
+func TestExecIntegrationBuildsInlinePolicyWithoutPolicyFile(t *testing.T) {
+	restore := stubImageOverrideResolver(t, func(context.Context, string, bool) (string, error) {
+		return testImageOverrideRef, nil
+	})
+	defer restore()
+
+	policyCh := make(chan *policy.CompiledPolicy, 1)
+	adapter := &integrationAdapter{
+		runFn: func(_ context.Context, req backend.RunRequest) (*backend.RunResult, error) {
+			policyCh <- req.Policy
+			return &backend.RunResult{RunID: req.RunID, ExitCode: 0, Message: "ok"}, nil
+		},
+	}
+
+	host, _ := startIntegrationServer(t, adapter)
+	cwd := t.TempDir()
+	outcome := runExecWithCapture(ExecCommand{
+		clientFlags: clientFlags{Host: host},
+		Chdir:       cwd,
+		Image:       testImageOverrideTag,
+		Allow:       []string{"github.com:443"},
+		Command:     []string{"echo", "ok"},
+	}, runtimeContext{
+		CWD:    cwd,
+		Loader: policy.Loader{},
+	})
+	if outcome.cause != nil {
+		t.Fatalf("capture failure: %v", outcome.cause)
+	}
+	if outcome.err != nil {
+		t.Fatalf("ExecCommand.Run returned error: %v", outcome.err)
+	}
+
+	got := mustReceiveWithin(t, policyCh, 2*time.Second, "timed out waiting for run request policy")
+	if got.ImageRef != testImageOverrideRef {
+		t.Fatalf("unexpected image ref: got %q want %q", got.ImageRef, testImageOverrideRef)
+	}
+	if !got.Allows("github.com", 443) || got.Allows("example.com", 443) {
+		t.Fatalf("unexpected inline allow rules: %v", got.Allow)
+	}
+}
+
+func TestExecRejectsAllowWithPolicyFile(t *testing.T) {
+	outcome := runExecWithCapture(ExecCommand{
+		clientFlags: clientFlags{Host: "http://127.0.0.1:1"},
+		Allow:       []string{"github.com:443"},
+		Command:     []string{"echo", "ok"},
+	}, runtimeContext{
+		CWD:    t.TempDir(),
+		Loader: integrationLoader{},
+	})
+	if outcome.cause != nil {
+		t.Fatalf("capture failure: %v", outcome.cause)
+	}
+	if outcome.err == nil || !strings.Contains(outcome.err.Error(), "--allow only applies to inline policies") {
+		t.Fatalf("expected --allow to be refused alongside a policy file, got %v", outcome.err)
+	}
+}
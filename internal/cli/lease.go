@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/controlclient"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/paths"
+	"github.com/charmbracelet/log"
+)
+
+// sandboxLease records the sandbox that `exec --reuse` last created for a
+// repository so later invocations can run in it instead of booting a new one.
+type sandboxLease struct {
+	SandboxID  string    `json:"sandbox_id"`
+	PolicyHash string    `json:"policy_hash"`
+	RepoPath   string    `json:"repo_path"`
+	Host       string    `json:"host,omitempty"`
+	Backend    string    `json:"backend,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// sandboxLeasePath returns the lease file for a repository. Host and backend
+// are part of the key so leases for different servers never collide.
+func sandboxLeasePath(repoPath, host, backendName string) (string, error) {
+	stateDir, err := paths.StateBaseDir()
+	if err != nil {
+		return "", fmt.Errorf("resolve state directory: %w", err)
+	}
+	sum := sha256.Sum256([]byte(repoPath + "\x00" + host + "\x00" + backendName))
+	return filepath.Join(stateDir, "leases", hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+func readSandboxLease(path string) (*sandboxLease, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read sandbox lease: %w", err)
+	}
+	var lease sandboxLease
+	if err := json.Unmarshal(raw, &lease); err != nil {
+		// A corrupt lease is no worse than a missing one.
+		return nil, nil
+	}
+	return &lease, nil
+}
+
+func writeSandboxLease(path string, lease sandboxLease) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create lease directory: %w", err)
+	}
+	raw, err := json.MarshalIndent(lease, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(raw, '\n'), 0o600); err != nil {
+		return fmt.Errorf("write sandbox lease: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("write sandbox lease: %w", err)
+	}
+	return nil
+}
+
+// reuseSandboxID returns the leased sandbox for cwd while the hash of the
+// policy for policyDir matches and it is still READY, extending the lease.
+// Otherwise it terminates the stale sandbox (if any), creates a new one and
+// records it.
+func reuseSandboxID(ctx context.Context, client *controlclient.Client, loader policyLoader, cwd, policyDir, host, backendName, imageRefOverride string, allow []string, launchSeconds int64, ttl time.Duration, logger *log.Logger) (string, error) {
+	compiled, _, err := compileSandboxPolicy(ctx, loader, policyDir, host, imageRefOverride, allow)
+	if err != nil {
+		return "", err
+	}
+	leasePath, err := sandboxLeasePath(cwd, host, backendName)
+	if err != nil {
+		return "", err
+	}
+	lease, err := readSandboxLease(leasePath)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	if lease != nil {
+		reason := staleLeaseReason(ctx, client, lease, compiled.Hash, now)
+		if reason == "" {
+			lease.ExpiresAt = now.Add(ttl)
+			if err := writeSandboxLease(leasePath, *lease); err != nil {
+				return "", err
+			}
+			logger.Debug("reusing leased sandbox", "sandbox_id", lease.SandboxID)
+			return lease.SandboxID, nil
+		}
+		logger.Debug("replacing leased sandbox", "sandbox_id", lease.SandboxID, "reason", reason)
+		terminateSandboxBestEffort(client, lease.SandboxID, 30*time.Second, nil, "")
+	}
+
+	sandboxID, err := createSandboxForPolicy(ctx, client, backendName, compiled, launchSeconds)
+	if err != nil {
+		return "", err
+	}
+	if err := writeSandboxLease(leasePath, sandboxLease{
+		SandboxID:  sandboxID,
+		PolicyHash: compiled.Hash,
+		RepoPath:   cwd,
+		Host:       host,
+		Backend:    backendName,
+		ExpiresAt:  now.Add(ttl),
+	}); err != nil {
+		return "", err
+	}
+	return sandboxID, nil
+}
+
+// staleLeaseReason explains why a lease cannot be reused, or returns "" if
+// it can.
+func staleLeaseReason(ctx context.Context, client *controlclient.Client, lease *sandboxLease, policyHash string, now time.Time) string {
+	if strings.TrimSpace(lease.SandboxID) == "" {
+		return "lease has no sandbox"
+	}
+	if lease.PolicyHash != policyHash {
+		return "policy changed"
+	}
+	if !now.Before(lease.ExpiresAt) {
+		return "lease expired"
+	}
+	resp, err := client.GetSandbox(ctx, &cleanroomv1.GetSandboxRequest{SandboxId: lease.SandboxID})
+	if err != nil {
+		return "sandbox not found"
+	}
+	if status := resp.GetSandbox().GetStatus(); status != cleanroomv1.SandboxStatus_SANDBOX_STATUS_READY {
+		return "sandbox is " + strings.ToLower(strings.TrimPrefix(status.String(), "SANDBOX_STATUS_"))
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"golang.org/x/term"
+)
+
+const pickerMaxRows = 20
+
+// canPromptForSandbox reports whether commands that take a sandbox may ask
+// the user to pick one instead of failing on a missing argument.
+func canPromptForSandbox() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// pickSandbox lists active sandboxes on out and reads selections from in.
+// A number selects that row, an empty line selects the only remaining row,
+// and any other text narrows the list to sandboxes whose ID, name or one of
+// whose labels fuzzily matches it.
+func pickSandbox(in io.Reader, out io.Writer, sandboxes []*cleanroomv1.Sandbox) (string, error) {
+	var active []*cleanroomv1.Sandbox
+	for _, sb := range sandboxes {
+		if sandboxIsActive(sb.GetStatus()) {
+			active = append(active, sb)
+		}
+	}
+	if len(active) == 0 {
+		return "", errors.New("no active sandboxes")
+	}
+
+	reader := bufio.NewReader(in)
+	query := ""
+	for {
+		matches := filterSandboxes(active, query)
+		if len(matches) == 0 {
+			fmt.Fprintf(out, "no sandboxes match %q\n", query)
+			query = ""
+			continue
+		}
+		for i, sb := range matches {
+			if i == pickerMaxRows {
+				fmt.Fprintf(out, "  ... %d more, type to filter\n", len(matches)-pickerMaxRows)
+				break
+			}
+			fmt.Fprintf(out, "%3d) %s\n", i+1, pickerRow(sb))
+		}
+		fmt.Fprint(out, "Select sandbox (number, or text to filter): ")
+
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if err != nil && line == "" {
+			if errors.Is(err, io.EOF) {
+				return "", errors.New("no sandbox selected")
+			}
+			return "", err
+		}
+
+		if n, convErr := strconv.Atoi(line); convErr == nil {
+			if n >= 1 && n <= len(matches) {
+				return matches[n-1].GetSandboxId(), nil
+			}
+			fmt.Fprintf(out, "choose a number between 1 and %d\n", len(matches))
+			continue
+		}
+		if line == "" {
+			if len(matches) == 1 {
+				return matches[0].GetSandboxId(), nil
+			}
+			continue
+		}
+		query = line
+	}
+}
+
+func pickerRow(sb *cleanroomv1.Sandbox) string {
+	row := sb.GetSandboxId()
+	if name := sb.GetName(); name != "" {
+		row += "  " + name
+	}
+	return row + "  " + strings.ToLower(strings.TrimPrefix(sb.GetStatus().String(), "SANDBOX_STATUS_"))
+}
+
+func filterSandboxes(sandboxes []*cleanroomv1.Sandbox, query string) []*cleanroomv1.Sandbox {
+	if query == "" {
+		return sandboxes
+	}
+	var out []*cleanroomv1.Sandbox
+	for _, sb := range sandboxes {
+		fields := []string{sb.GetSandboxId(), sb.GetName()}
+		for key, value := range sb.GetLabels() {
+			fields = append(fields, key+"="+value)
+		}
+		for _, field := range fields {
+			if fuzzyMatch(field, query) {
+				out = append(out, sb)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// fuzzyMatch reports whether the characters of query appear in s in order,
+// ignoring case.
+func fuzzyMatch(s, query string) bool {
+	s = strings.ToLower(s)
+	for _, r := range strings.ToLower(query) {
+		i := strings.IndexRune(s, r)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(string(r)):]
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+func pickerTestSandboxes() []*cleanroomv1.Sandbox {
+	ready := cleanroomv1.SandboxStatus_SANDBOX_STATUS_READY
+	return []*cleanroomv1.Sandbox{
+		{SandboxId: "cr_aaa", Name: "web-main", Status: ready, Labels: map[string]string{"branch": "main"}},
+		{SandboxId: "cr_bbb", Name: "api-dev", Status: ready, Labels: map[string]string{"branch": "dev"}},
+		{SandboxId: "cr_ccc", Status: cleanroomv1.SandboxStatus_SANDBOX_STATUS_STOPPED},
+	}
+}
+
+func TestPickSandboxSelectsByNumber(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	got, err := pickSandbox(strings.NewReader("2\n"), &out, pickerTestSandboxes())
+	if err != nil {
+		t.Fatalf("pickSandbox returned error: %v", err)
+	}
+	if got != "cr_bbb" {
+		t.Fatalf("unexpected selection: got %q want %q", got, "cr_bbb")
+	}
+	if strings.Contains(out.String(), "cr_ccc") {
+		t.Fatalf("expected stopped sandbox to be hidden, got %q", out.String())
+	}
+}
+
+func TestPickSandboxFiltersThenAcceptsSingleMatch(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	got, err := pickSandbox(strings.NewReader("wbmn\n\n"), &out, pickerTestSandboxes())
+	if err != nil {
+		t.Fatalf("pickSandbox returned error: %v", err)
+	}
+	if got != "cr_aaa" {
+		t.Fatalf("unexpected selection: got %q want %q", got, "cr_aaa")
+	}
+}
+
+func TestPickSandboxRecoversFromBadInput(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	got, err := pickSandbox(strings.NewReader("9\nzzz\nbranch=dev\n1\n"), &out, pickerTestSandboxes())
+	if err != nil {
+		t.Fatalf("pickSandbox returned error: %v", err)
+	}
+	if got != "cr_bbb" {
+		t.Fatalf("unexpected selection: got %q want %q", got, "cr_bbb")
+	}
+	for _, want := range []string{"choose a number between 1 and 2", `no sandboxes match "zzz"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected picker output to contain %q, got %q", want, out.String())
+		}
+	}
+}
+
+func TestPickSandboxErrors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := pickSandbox(strings.NewReader(""), &bytes.Buffer{}, pickerTestSandboxes()); err == nil || err.Error() != "no sandbox selected" {
+		t.Fatalf("unexpected error on EOF: got %v want %q", err, "no sandbox selected")
+	}
+	if _, err := pickSandbox(strings.NewReader("1\n"), &bytes.Buffer{}, pickerTestSandboxes()[2:]); err == nil || err.Error() != "no active sandboxes" {
+		t.Fatalf("unexpected error with no active sandboxes: got %v want %q", err, "no active sandboxes")
+	}
+}
@@ -0,0 +1,357 @@
+package cli
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/buildkite/cleanroom/internal/apiversion"
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/controlclient"
+	"github.com/buildkite/cleanroom/internal/controlserver"
+	"github.com/buildkite/cleanroom/internal/endpoint"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/tlsconfig"
+)
+
+const (
+	pingDialTimeout = 5 * time.Second
+	// pingMaxClockSkew is how far the server's clock may drift from ours
+	// before ping warns.
+	pingMaxClockSkew = 2 * time.Second
+	// pingCertExpiryWarning is how close to expiry a server certificate
+	// must be for ping to warn.
+	pingCertExpiryWarning = 14 * 24 * time.Hour
+)
+
+type PingCommand struct {
+	clientFlags
+	JSON bool `help:"Print the ping report as JSON"`
+}
+
+// pingFailedError reports failed ping checks so scripts can gate on the
+// exit status.
+type pingFailedError struct {
+	count int
+}
+
+func (e pingFailedError) Error() string {
+	noun := "checks"
+	if e.count == 1 {
+		noun = "check"
+	}
+	return fmt.Sprintf("ping: %d %s failed", e.count, noun)
+}
+
+func (e pingFailedError) ExitCode() int {
+	return 1
+}
+
+func (c *PingCommand) Run(ctx *runtimeContext) error {
+	target := strings.TrimSpace(c.Host)
+	if target == "" {
+		target = "(default)"
+	}
+	checks := assignDoctorCheckIDs("ping", runPingChecks(ctx.commandContext(), c.clientFlags, ctx.Version))
+	summary := summarizeDoctorChecks(checks)
+	var failedErr error
+	if summary.Fail > 0 {
+		failedErr = pingFailedError{count: summary.Fail}
+	}
+
+	if c.JSON {
+		enc := json.NewEncoder(ctx.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(map[string]any{
+			"host":    target,
+			"status":  summary.status(),
+			"summary": summary,
+			"checks":  checks,
+		}); err != nil {
+			return err
+		}
+		return failedErr
+	}
+	if _, err := fmt.Fprint(ctx.Stdout, renderCheckReport(fmt.Sprintf("ping %s", target), checks, shouldUseANSI(ctx.Stdout))); err != nil {
+		return err
+	}
+	return failedErr
+}
+
+// runPingChecks works outward from the endpoint: resolving it, opening a
+// connection, the TLS handshake, then asking the server about itself. It
+// stops at the first check that fails, since later ones cannot pass.
+func runPingChecks(ctx context.Context, flags clientFlags, localVersion string) []backend.DoctorCheck {
+	var checks []backend.DoctorCheck
+	ep, err := endpoint.Resolve(flags.Host)
+	if err != nil {
+		return append(checks, backend.DoctorCheck{
+			Name:        "endpoint",
+			Status:      "fail",
+			Message:     err.Error(),
+			Remediation: "pass --host (or CLEANROOM_HOST) as unix:///path, http://host:port or https://host:port",
+		})
+	}
+	network, address, hostname := "unix", ep.Address, ""
+	if ep.Scheme != "unix" {
+		network = "tcp"
+		address, hostname = endpointHostPort(ep)
+	}
+	checks = append(checks, backend.DoctorCheck{
+		Name:    "endpoint",
+		Status:  "pass",
+		Message: describeEndpoint(ep),
+	})
+
+	dialer := &net.Dialer{Timeout: pingDialTimeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return append(checks, backend.DoctorCheck{
+			Name:        "connect",
+			Status:      "fail",
+			Message:     err.Error(),
+			Remediation: pingConnectRemediation(ep, address, hostname, err),
+		})
+	}
+	_ = conn.Close()
+	checks = append(checks, backend.DoctorCheck{
+		Name:    "connect",
+		Status:  "pass",
+		Message: fmt.Sprintf("connected to %s in %s", address, time.Since(start).Round(time.Millisecond)),
+	})
+
+	if ep.Scheme == "https" {
+		check := pingTLSCheck(ctx, dialer, address, hostname, flags.TLSCA)
+		checks = append(checks, check)
+		if check.Status == "fail" {
+			return checks
+		}
+	}
+
+	client, err := flags.dial()
+	if err != nil {
+		return append(checks, backend.DoctorCheck{Name: "server", Status: "fail", Message: err.Error()})
+	}
+	defer client.CloseIdleConnections()
+	health, err := client.Health(ctx)
+	if err != nil {
+		return append(checks, backend.DoctorCheck{
+			Name:        "server",
+			Status:      "fail",
+			Message:     err.Error(),
+			Remediation: "the connection opened but the server did not answer: check --host uses the scheme the server listens with (https:// when it serves TLS)",
+		})
+	}
+	message := fmt.Sprintf("answered in %s", health.Received.Sub(health.Sent).Round(time.Millisecond))
+	if identity := health.Header.Get(controlserver.CallerIdentityHeader); identity != "" {
+		message += ", identifies this client as " + identity
+	}
+	checks = append(checks,
+		backend.DoctorCheck{Name: "server", Status: "pass", Message: message},
+		pingVersionCheck(ctx, client, localVersion),
+	)
+	if check, ok := pingClockCheck(health.Header, health.Sent, health.Received); ok {
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+func describeEndpoint(ep endpoint.Endpoint) string {
+	if ep.Scheme == "unix" {
+		return "unix socket " + ep.Address
+	}
+	return ep.BaseURL
+}
+
+// endpointHostPort returns the host:port to dial for an http or https
+// endpoint, and the bare host name to verify its certificate against.
+func endpointHostPort(ep endpoint.Endpoint) (string, string) {
+	u, err := url.Parse(ep.BaseURL)
+	if err != nil || u.Host == "" {
+		return ep.Address, ""
+	}
+	if u.Port() != "" {
+		return u.Host, u.Hostname()
+	}
+	port := "80"
+	if ep.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port), u.Hostname()
+}
+
+func pingConnectRemediation(ep endpoint.Endpoint, address, hostname string, err error) string {
+	tailnet := strings.HasSuffix(strings.TrimSuffix(hostname, "."), ".ts.net")
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr) && tailnet:
+		return "this machine cannot resolve tailnet names: join the tailnet with tailscale up and check MagicDNS is enabled"
+	case errors.As(err, &dnsErr):
+		return "check the host name in --host"
+	case ep.Scheme == "unix" && errors.Is(err, fs.ErrNotExist):
+		return fmt.Sprintf("no server socket at %s: start the server with cleanroom serve, or point --host at a running one", address)
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Sprintf("this user cannot open %s: run as a user with access to the socket", address)
+	case ep.Scheme == "unix" && errors.Is(err, syscall.ECONNREFUSED):
+		return "the socket exists but nothing is listening on it: restart cleanroom serve"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Sprintf("nothing is listening on %s: check cleanroom serve is running with --listen on this address", address)
+	case errors.As(err, &netErr) && netErr.Timeout() && tailnet:
+		return "the server did not answer: check it is up and that tailnet ACLs let this machine reach it"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "the server did not answer: check it is up and that no firewall blocks the port"
+	}
+	return ""
+}
+
+func pingTLSCheck(ctx context.Context, dialer *net.Dialer, address, hostname, caPath string) backend.DoctorCheck {
+	tlsCfg, err := tlsconfig.ResolveClient(tlsconfig.Options{CAPath: caPath})
+	if err != nil {
+		return backend.DoctorCheck{
+			Name:        "tls",
+			Status:      "fail",
+			Message:     err.Error(),
+			Remediation: "check the CA file passed with --tls-ca (or CLEANROOM_TLS_CA)",
+		}
+	}
+	tlsCfg.ServerName = hostname
+	conn, err := (&tls.Dialer{NetDialer: dialer, Config: tlsCfg}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return backend.DoctorCheck{
+			Name:        "tls",
+			Status:      "fail",
+			Message:     err.Error(),
+			Remediation: pingTLSRemediation(hostname, err, time.Now()),
+		}
+	}
+	state := conn.(*tls.Conn).ConnectionState()
+	_ = conn.Close()
+	leaf := state.PeerCertificates[0]
+	check := backend.DoctorCheck{
+		Name:   "tls",
+		Status: "pass",
+		Message: fmt.Sprintf("%s, certificate for %s issued by %s, expires %s",
+			tls.VersionName(state.Version), certificateNames(leaf), leaf.Issuer.String(), leaf.NotAfter.UTC().Format(time.RFC3339)),
+	}
+	if time.Until(leaf.NotAfter) < pingCertExpiryWarning {
+		check.Status = "warn"
+		check.Remediation = "renew the server certificate before it expires"
+	}
+	return check
+}
+
+func pingTLSRemediation(hostname string, err error, now time.Time) string {
+	var invalid x509.CertificateInvalidError
+	var hostErr x509.HostnameError
+	var unknownCA x509.UnknownAuthorityError
+	var recordErr tls.RecordHeaderError
+	switch {
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired && now.After(invalid.Cert.NotAfter):
+		return fmt.Sprintf("the server certificate expired at %s: renew it, or fix this machine's clock if that time has not passed", invalid.Cert.NotAfter.UTC().Format(time.RFC3339))
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		return fmt.Sprintf("the server certificate is not valid until %s: check this machine's clock", invalid.Cert.NotBefore.UTC().Format(time.RFC3339))
+	case errors.As(err, &hostErr):
+		return fmt.Sprintf("the certificate is for %s, not %s: connect using one of those names, or reissue the certificate for this one", certificateNames(hostErr.Certificate), hostname)
+	case errors.As(err, &unknownCA):
+		return "the server certificate is not signed by a CA this machine trusts: pass the server's CA with --tls-ca (or CLEANROOM_TLS_CA)"
+	case errors.As(err, &recordErr):
+		return "the server is not speaking TLS: use http:// in --host"
+	}
+	return ""
+}
+
+func certificateNames(cert *x509.Certificate) string {
+	var names []string
+	names = append(names, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	if len(names) == 0 && cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	if len(names) == 0 {
+		return "no names"
+	}
+	return strings.Join(names, ", ")
+}
+
+// pingVersionCheck fails when the server's API schema is incompatible with
+// this client, and warns when the two run different releases.
+func pingVersionCheck(ctx context.Context, client *controlclient.Client, localVersion string) backend.DoctorCheck {
+	info, err := client.GetServerInfo(ctx, &cleanroomv1.GetServerInfoRequest{})
+	switch {
+	case connect.CodeOf(err) == connect.CodeUnimplemented:
+		return backend.DoctorCheck{
+			Name:        "version",
+			Status:      "warn",
+			Message:     "server does not report its version",
+			Remediation: fmt.Sprintf("the server predates this client (%s): upgrade it", localVersion),
+		}
+	case err != nil:
+		return backend.DoctorCheck{Name: "version", Status: "warn", Message: fmt.Sprintf("could not ask the server for its version: %v", err)}
+	}
+	if err := apiversion.Check(info, localVersion); err != nil {
+		return backend.DoctorCheck{Name: "version", Status: "fail", Message: err.Error()}
+	}
+	if info.GetVersion() != localVersion {
+		return backend.DoctorCheck{
+			Name:        "version",
+			Status:      "warn",
+			Message:     fmt.Sprintf("server runs %s, this client %s (both API schema %d)", info.GetVersion(), localVersion, apiversion.Schema),
+			Remediation: "upgrade whichever is older so both run the same release",
+		}
+	}
+	return backend.DoctorCheck{
+		Name:    "version",
+		Status:  "pass",
+		Message: fmt.Sprintf("server and client both run %s (API schema %d)", info.GetVersion(), apiversion.Schema),
+	}
+}
+
+// pingClockCheck compares the server's clock with the midpoint of the
+// request. It falls back to the one-second Date header for servers that
+// do not send ServerTimeHeader.
+func pingClockCheck(header http.Header, sent, received time.Time) (backend.DoctorCheck, bool) {
+	tolerance := pingMaxClockSkew
+	serverTime, err := time.Parse(time.RFC3339Nano, header.Get(controlserver.ServerTimeHeader))
+	if err != nil {
+		serverTime, err = http.ParseTime(header.Get("Date"))
+		if err != nil {
+			return backend.DoctorCheck{}, false
+		}
+		tolerance += time.Second
+	}
+	skew := serverTime.Sub(sent.Add(received.Sub(sent) / 2)).Round(time.Millisecond)
+	direction := "ahead of"
+	magnitude := skew
+	if skew < 0 {
+		direction = "behind"
+		magnitude = -skew
+	}
+	if magnitude > tolerance {
+		return backend.DoctorCheck{
+			Name:        "clock",
+			Status:      "warn",
+			Message:     fmt.Sprintf("server clock is %s %s this machine", magnitude, direction),
+			Remediation: "sync both clocks, for example with NTP: skew makes certificates look expired or not yet valid and shifts expiry times",
+		}, true
+	}
+	return backend.DoctorCheck{
+		Name:    "clock",
+		Status:  "pass",
+		Message: fmt.Sprintf("server clock is within %s of this machine", tolerance),
+	}, true
+}
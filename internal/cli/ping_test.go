@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/controlserver"
+)
+
+type pingReport struct {
+	Status string                `json:"status"`
+	Checks []backend.DoctorCheck `json:"checks"`
+}
+
+func runPingJSON(t *testing.T, cmd PingCommand, version string) (pingReport, error) {
+	t.Helper()
+	stdoutPath := filepath.Join(t.TempDir(), "ping.json")
+	stdout, err := os.Create(stdoutPath)
+	if err != nil {
+		t.Fatalf("create stdout file: %v", err)
+	}
+	defer stdout.Close()
+
+	cmd.JSON = true
+	runErr := cmd.Run(&runtimeContext{Stdout: stdout, Version: version})
+	raw, err := os.ReadFile(stdoutPath)
+	if err != nil {
+		t.Fatalf("read ping output: %v", err)
+	}
+	var report pingReport
+	if err := json.Unmarshal(raw, &report); err != nil {
+		t.Fatalf("parse ping output %q: %v", raw, err)
+	}
+	return report, runErr
+}
+
+func pingCheck(t *testing.T, report pingReport, name string) backend.DoctorCheck {
+	t.Helper()
+	for _, check := range report.Checks {
+		if check.Name == name {
+			return check
+		}
+	}
+	t.Fatalf("ping report has no %s check: %+v", name, report.Checks)
+	return backend.DoctorCheck{}
+}
+
+func TestPingCommandReportsServerVersionAndClock(t *testing.T) {
+	host, svc := startIntegrationServer(t, &integrationAdapter{})
+	svc.Version = "v1.2.3"
+
+	report, err := runPingJSON(t, PingCommand{clientFlags: clientFlags{Host: host}}, "v1.2.3")
+	if err != nil {
+		t.Fatalf("ping returned error: %v (%+v)", err, report.Checks)
+	}
+	if report.Status != "pass" {
+		t.Fatalf("expected pass, got %s: %+v", report.Status, report.Checks)
+	}
+	if got := pingCheck(t, report, "version").Message; !strings.Contains(got, "v1.2.3") {
+		t.Fatalf("unexpected version message %q", got)
+	}
+	if got := pingCheck(t, report, "server").Message; !strings.Contains(got, "answered in") {
+		t.Fatalf("unexpected server message %q", got)
+	}
+	pingCheck(t, report, "clock")
+}
+
+func TestPingCommandWarnsOnVersionMismatch(t *testing.T) {
+	host, svc := startIntegrationServer(t, &integrationAdapter{})
+	svc.Version = "v1.0.0"
+
+	report, err := runPingJSON(t, PingCommand{clientFlags: clientFlags{Host: host}}, "v1.1.0")
+	if err != nil {
+		t.Fatalf("a version mismatch should not fail ping: %v", err)
+	}
+	check := pingCheck(t, report, "version")
+	if check.Status != "warn" || !strings.Contains(check.Message, "server runs v1.0.0, this client v1.1.0") {
+		t.Fatalf("unexpected version check: %+v", check)
+	}
+}
+
+func TestPingCommandMissingSocketSuggestsServe(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "missing.sock")
+	report, err := runPingJSON(t, PingCommand{clientFlags: clientFlags{Host: "unix://" + socket}}, "dev")
+	if ExitCode(err) != 1 {
+		t.Fatalf("expected exit code 1, got %d (%v)", ExitCode(err), err)
+	}
+	check := pingCheck(t, report, "connect")
+	if check.Status != "fail" || !strings.Contains(check.Remediation, "cleanroom serve") {
+		t.Fatalf("unexpected connect check: %+v", check)
+	}
+	for _, later := range report.Checks {
+		if later.Name == "server" {
+			t.Fatalf("ping should stop after a failed connect: %+v", report.Checks)
+		}
+	}
+}
+
+func TestPingCommandUntrustedCertificateSuggestsTLSCA(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+
+	report, err := runPingJSON(t, PingCommand{clientFlags: clientFlags{Host: server.URL}}, "dev")
+	if ExitCode(err) != 1 {
+		t.Fatalf("expected exit code 1, got %d (%v)", ExitCode(err), err)
+	}
+	check := pingCheck(t, report, "tls")
+	if check.Status != "fail" || !strings.Contains(check.Remediation, "--tls-ca") {
+		t.Fatalf("unexpected tls check: %+v", check)
+	}
+}
+
+func TestPingClockCheckWarnsOnSkew(t *testing.T) {
+	sent := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	received := sent.Add(20 * time.Millisecond)
+	header := http.Header{}
+	header.Set(controlserver.ServerTimeHeader, sent.Add(-time.Minute).Format(time.RFC3339Nano))
+
+	check, ok := pingClockCheck(header, sent, received)
+	if !ok {
+		t.Fatal("expected a clock check")
+	}
+	if check.Status != "warn" || !strings.Contains(check.Message, "1m0.01s behind") {
+		t.Fatalf("unexpected clock check: %+v", check)
+	}
+
+	header.Set(controlserver.ServerTimeHeader, sent.Add(10*time.Millisecond).Format(time.RFC3339Nano))
+	if check, _ := pingClockCheck(header, sent, received); check.Status != "pass" {
+		t.Fatalf("expected pass within tolerance, got %+v", check)
+	}
+}
+
+func TestPingTLSRemediationNamesCertificateHosts(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+
+	cfg := server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	cfg.ServerName = "cleanroom.internal"
+	_, err := (&tls.Dialer{Config: cfg}).DialContext(context.Background(), "tcp", server.Listener.Addr().String())
+	if err == nil {
+		t.Fatal("expected a hostname mismatch")
+	}
+	got := pingTLSRemediation("cleanroom.internal", err, time.Now())
+	if !strings.Contains(got, "not cleanroom.internal") || !strings.Contains(got, "127.0.0.1") {
+		t.Fatalf("unexpected remediation %q", got)
+	}
+}
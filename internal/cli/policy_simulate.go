@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/backend/firecracker"
+)
+
+type PolicySimulateCommand struct {
+	Chdir     string `short:"c" help:"Change to this directory before running commands"`
+	PolicyDir string `name:"policy-dir" help:"Simulate the policy for this directory (the nearest cleanroom.yaml at or above it) instead of the working directory's"`
+	Dest      string `required:"" placeholder:"HOST:PORT" help:"Destination to check, for example api.github.com:443; just the host for icmp"`
+	Protocol  string `default:"tcp" enum:"tcp,udp,icmp" help:"Protocol (tcp|udp|icmp)"`
+	JSON      bool   `help:"Print the verdicts as JSON"`
+}
+
+// policyDeniedError reports that the simulated connection would be blocked
+// so scripts can gate on the exit status.
+type policyDeniedError struct {
+	dest string
+}
+
+func (e policyDeniedError) Error() string {
+	return fmt.Sprintf("policy simulate: connection to %s would be denied", e.dest)
+}
+
+func (e policyDeniedError) ExitCode() int {
+	return 1
+}
+
+// Run resolves the policy's allow rules as sandbox network setup does and
+// reports whether a guest could reach each address the destination
+// resolves to. No sandbox is created and the host is not changed.
+func (c *PolicySimulateCommand) Run(ctx *runtimeContext) error {
+	host, port := strings.TrimSpace(c.Dest), 0
+	if c.Protocol != "icmp" {
+		hostText, portText, err := net.SplitHostPort(host)
+		if err != nil {
+			return fmt.Errorf("invalid --dest %q: expected HOST:PORT", c.Dest)
+		}
+		if port, err = strconv.Atoi(portText); err != nil {
+			return fmt.Errorf("invalid --dest %q: port must be a number", c.Dest)
+		}
+		host = hostText
+	}
+	cwd, err := resolveCWD(ctx.CWD, c.Chdir)
+	if err != nil {
+		return err
+	}
+	policyDir, err := resolvePolicyDir(cwd, c.PolicyDir)
+	if err != nil {
+		return err
+	}
+	compiled, source, err := ctx.Loader.LoadAndCompile(policyDir)
+	if err != nil {
+		return err
+	}
+
+	verdicts, err := firecracker.SimulateEgress(ctx.commandContext(), compiled.Allow, host, port, c.Protocol)
+	if err != nil {
+		return err
+	}
+	var deniedErr error
+	for _, verdict := range verdicts {
+		if !verdict.Allowed {
+			deniedErr = policyDeniedError{dest: c.Dest}
+		}
+	}
+
+	if c.JSON {
+		enc := json.NewEncoder(ctx.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(map[string]any{
+			"source":   source,
+			"dest":     c.Dest,
+			"verdicts": verdicts,
+		}); err != nil {
+			return err
+		}
+		return deniedErr
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "policy: %s\n", source)
+	for _, verdict := range verdicts {
+		addr := verdict.IP + "/icmp"
+		if verdict.Protocol != "icmp" {
+			addr = fmt.Sprintf("%s/%s", net.JoinHostPort(verdict.IP, strconv.Itoa(verdict.Port)), verdict.Protocol)
+		}
+		switch {
+		case verdict.Rule == "dns":
+			fmt.Fprintf(&b, "%s allowed: guest DNS resolver\n", addr)
+		case verdict.Allowed:
+			fmt.Fprintf(&b, "%s allowed by sandbox.network.allow host %s\n", addr, verdict.Rule)
+		default:
+			fmt.Fprintf(&b, "%s denied: no sandbox.network.allow entry matches (default %s)\n", addr, compiled.NetworkDefault)
+		}
+	}
+	if _, err := fmt.Fprint(ctx.Stdout, b.String()); err != nil {
+		return err
+	}
+	return deniedErr
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/policy"
+)
+
+// allowLiteralLoader allows an IP literal so simulations need no DNS.
+type allowLiteralLoader struct{}
+
+func (allowLiteralLoader) LoadAndCompile(string) (*policy.CompiledPolicy, string, error) {
+	return &policy.CompiledPolicy{
+		Version:        1,
+		NetworkDefault: "deny",
+		Allow:          []policy.AllowRule{{Host: "203.0.113.7", Ports: []int{443}}},
+	}, "/repo/cleanroom.yaml", nil
+}
+
+func TestPolicySimulateReportsVerdict(t *testing.T) {
+	t.Parallel()
+
+	run := func(cmd PolicySimulateCommand) execOutcome {
+		return runWithCapture(cmd.Run, nil, runtimeContext{CWD: t.TempDir(), Loader: allowLiteralLoader{}})
+	}
+
+	outcome := run(PolicySimulateCommand{Dest: "203.0.113.7:443", Protocol: "tcp"})
+	if outcome.cause != nil || outcome.err != nil {
+		t.Fatalf("simulate returned %v (capture: %v)", outcome.err, outcome.cause)
+	}
+	if !strings.Contains(outcome.stdout, "203.0.113.7:443/tcp allowed by sandbox.network.allow host 203.0.113.7") {
+		t.Fatalf("unexpected output %q", outcome.stdout)
+	}
+
+	outcome = run(PolicySimulateCommand{Dest: "203.0.113.7:80", Protocol: "tcp"})
+	var denied policyDeniedError
+	if !errors.As(outcome.err, &denied) || ExitCode(outcome.err) != 1 {
+		t.Fatalf("expected a denied error, got %v", outcome.err)
+	}
+	if !strings.Contains(outcome.stdout, "203.0.113.7:80/tcp denied: no sandbox.network.allow entry matches (default deny)") {
+		t.Fatalf("unexpected output %q", outcome.stdout)
+	}
+
+	outcome = run(PolicySimulateCommand{Dest: "api.github.com", Protocol: "tcp"})
+	if outcome.err == nil || !strings.Contains(outcome.err.Error(), "expected HOST:PORT") {
+		t.Fatalf("expected a dest format error, got %v", outcome.err)
+	}
+}
+
+func TestPolicyValidateReportsSearchOrder(t *testing.T) {
+	t.Parallel()
+
+	repo := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(repo, "sub")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	content := "version: 1\nsandbox:\n  image:\n    ref: ghcr.io/buildkite/cleanroom-base/alpine@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef\n  network:\n    default: deny\n"
+	if err := os.WriteFile(filepath.Join(repo, policy.PrimaryPolicyPath), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	userPolicy := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(userPolicy, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := PolicyValidateCommand{}
+	outcome := runWithCapture(cmd.Run, nil, runtimeContext{CWD: sub, Loader: policy.Loader{UserPolicyPath: userPolicy}})
+	if outcome.cause != nil || outcome.err != nil {
+		t.Fatalf("validate returned %v (capture: %v)", outcome.err, outcome.cause)
+	}
+	for _, want := range []string{
+		"policy valid: " + filepath.Join(repo, policy.PrimaryPolicyPath),
+		filepath.Join(sub, policy.PrimaryPolicyPath) + " (missing)",
+		filepath.Join(repo, policy.PrimaryPolicyPath) + " (used)",
+		userPolicy + " (shadowed)",
+	} {
+		if !strings.Contains(outcome.stdout, want) {
+			t.Fatalf("expected %q in output %q", want, outcome.stdout)
+		}
+	}
+}
+
+func TestPolicyValidateUsesPolicyDir(t *testing.T) {
+	t.Parallel()
+
+	repo := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	service := filepath.Join(repo, "services", "api")
+	if err := os.MkdirAll(service, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	content := "version: 1\nsandbox:\n  image:\n    ref: ghcr.io/buildkite/cleanroom-base/alpine@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef\n  network:\n    default: deny\n"
+	if err := os.WriteFile(filepath.Join(service, policy.PrimaryPolicyPath), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := PolicyValidateCommand{PolicyDir: "services/api", JSON: true}
+	outcome := runWithCapture(cmd.Run, nil, runtimeContext{CWD: repo, Loader: policy.Loader{}})
+	if outcome.cause != nil || outcome.err != nil {
+		t.Fatalf("validate returned %v (capture: %v)", outcome.err, outcome.cause)
+	}
+	if !strings.Contains(outcome.stdout, `"source": "services/api/cleanroom.yaml"`) {
+		t.Fatalf("expected the service policy's provenance in %q", outcome.stdout)
+	}
+
+	cmd = PolicyValidateCommand{PolicyDir: "services/missing"}
+	outcome = runWithCapture(cmd.Run, nil, runtimeContext{CWD: repo, Loader: policy.Loader{}})
+	if outcome.err == nil || !strings.Contains(outcome.err.Error(), "--policy-dir") {
+		t.Fatalf("expected a --policy-dir error, got %v", outcome.err)
+	}
+}
+
+func TestPolicySchemaPrintsEmbeddedSchema(t *testing.T) {
+	t.Parallel()
+
+	cmd := PolicySchemaCommand{}
+	outcome := runWithCapture(cmd.Run, nil, runtimeContext{CWD: t.TempDir()})
+	if outcome.cause != nil || outcome.err != nil {
+		t.Fatalf("schema returned %v (capture: %v)", outcome.err, outcome.cause)
+	}
+	if outcome.stdout != string(policy.Schema()) {
+		t.Fatalf("unexpected schema output %q", outcome.stdout)
+	}
+}
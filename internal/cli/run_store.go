@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/gateway"
+	"github.com/buildkite/cleanroom/internal/objectstore"
+	"github.com/buildkite/cleanroom/internal/rundir"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+)
+
+// openRunStore returns the store storage.runs names, or nil when run
+// records are kept only on the host.
+func openRunStore(cfg runtimeconfig.Storage, credentials objectstore.CredentialResolver) (objectstore.Store, error) {
+	if strings.TrimSpace(cfg.Runs) == "" {
+		return nil, nil
+	}
+	store, err := objectstore.Open(cfg.Runs, objectstore.Options{
+		Region:      cfg.Region,
+		Endpoint:    cfg.Endpoint,
+		Credentials: credentials,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("storage.runs: %w", err)
+	}
+	return store, nil
+}
+
+// statusRunStore opens the run store for cleanroom status, with the same
+// credentials serve uses.
+func statusRunStore(cfg runtimeconfig.Storage) (objectstore.Store, error) {
+	return openRunStore(cfg, gateway.NewEnvCredentialProvider())
+}
+
+// listArchivedRuns prints the runs in store that are not in local, and
+// returns how many it printed.
+func listArchivedRuns(ctx context.Context, stdout io.Writer, store objectstore.Store, location string, local map[string]bool) (int, error) {
+	runs, err := rundir.ArchivedRuns(ctx, store)
+	if err != nil {
+		return 0, fmt.Errorf("list archived runs: %w", err)
+	}
+	var archived []string
+	for _, runID := range runs {
+		if !local[runID] {
+			archived = append(archived, runID)
+		}
+	}
+	if len(archived) == 0 {
+		return 0, nil
+	}
+	if _, err := fmt.Fprintf(stdout, "archived runs in %s:\n", location); err != nil {
+		return 0, err
+	}
+	for _, runID := range archived {
+		if _, err := fmt.Fprintf(stdout, "- %s\n", runID); err != nil {
+			return 0, err
+		}
+	}
+	return len(archived), nil
+}
+
+// inspectArchivedRun prints runID's archived observability, returning an
+// error satisfying errors.Is(err, fs.ErrNotExist) when the run was never
+// archived.
+func inspectArchivedRun(ctx context.Context, stdout io.Writer, store objectstore.Store, location, runID string) error {
+	if _, err := rundir.ReadArchived(ctx, store, runID, rundir.ManifestFile); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(stdout, "run: %s (archived in %s)\n", runID, location); err != nil {
+		return err
+	}
+	b, err := rundir.ReadArchived(ctx, store, runID, rundir.ObservabilityFile)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			_, werr := fmt.Fprintln(stdout, "observability: not archived")
+			return werr
+		}
+		return err
+	}
+	var obs map[string]any
+	if err := json.Unmarshal(b, &obs); err != nil {
+		return fmt.Errorf("parse archived %s: %w", rundir.ObservabilityFile, err)
+	}
+	out, err := json.MarshalIndent(obs, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(stdout, "observability:\n%s\n", out)
+	return err
+}
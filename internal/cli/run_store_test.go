@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/objectstore"
+	"github.com/buildkite/cleanroom/internal/rundir"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+)
+
+func TestStatusReadsArchivedRuns(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	storeDir := t.TempDir()
+
+	runDir := filepath.Join(t.TempDir(), "run-archived")
+	if err := rundir.Create(runDir, rundir.Manifest{RunID: "run-archived"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(runDir, rundir.ObservabilityFile), []byte(`{"outcome":"succeeded"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := rundir.Archive(context.Background(), &objectstore.Dir{Root: storeDir}, runDir, "run-archived"); err != nil {
+		t.Fatal(err)
+	}
+	cfg := runtimeconfig.Config{Storage: runtimeconfig.Storage{Runs: storeDir}}
+
+	list := StatusCommand{}
+	outcome := runWithCapture(list.Run, nil, runtimeContext{CWD: t.TempDir(), Config: cfg})
+	if outcome.cause != nil || outcome.err != nil {
+		t.Fatalf("status returned %v (capture: %v)", outcome.err, outcome.cause)
+	}
+	if want := "archived runs in " + storeDir + ":\n- run-archived\n"; outcome.stdout != want {
+		t.Fatalf("expected %q, got %q", want, outcome.stdout)
+	}
+
+	inspect := StatusCommand{RunID: "run-archived"}
+	outcome = runWithCapture(inspect.Run, nil, runtimeContext{CWD: t.TempDir(), Config: cfg})
+	if outcome.cause != nil || outcome.err != nil {
+		t.Fatalf("status returned %v (capture: %v)", outcome.err, outcome.cause)
+	}
+	if !strings.Contains(outcome.stdout, "(archived in "+storeDir+")") || !strings.Contains(outcome.stdout, `"outcome": "succeeded"`) {
+		t.Fatalf("expected the archived observability, got %q", outcome.stdout)
+	}
+
+	missing := StatusCommand{RunID: "run-missing"}
+	outcome = runWithCapture(missing.Run, nil, runtimeContext{CWD: t.TempDir(), Config: cfg})
+	if outcome.err == nil || !strings.Contains(outcome.err.Error(), "not found") {
+		t.Fatalf("expected a not found error, got %v", outcome.err)
+	}
+}
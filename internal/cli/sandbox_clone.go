@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// Run creates the clones and prints their IDs, one per line.
+func (c *SandboxCloneCommand) Run(ctx *runtimeContext) error {
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	listResp, err := client.ListSandboxes(ctx.commandContext(), &cleanroomv1.ListSandboxesRequest{})
+	if err != nil {
+		return err
+	}
+	resp, err := client.CloneSandbox(ctx.commandContext(), &cleanroomv1.CloneSandboxRequest{
+		SandboxId:   resolveSandboxRef(listResp.GetSandboxes(), c.SandboxID),
+		Count:       c.Count,
+		SnapshotRef: c.SnapshotRef,
+		Labels:      c.Labels,
+	})
+	if err != nil {
+		return fmt.Errorf("clone sandbox: %w", err)
+	}
+	if c.JSON {
+		enc := json.NewEncoder(ctx.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(resp)
+	}
+	for _, sb := range resp.GetSandboxes() {
+		if _, err := fmt.Fprintln(ctx.Stdout, sb.GetSandboxId()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+func TestSandboxCloneIntegrationPrintsClones(t *testing.T) {
+	host, _ := startIntegrationServer(t, &integrationAdapter{})
+	client := mustNewControlClient(t, host)
+	source := mustCreateSandbox(t, client)
+
+	outcome := runWithCapture((&SandboxCloneCommand{
+		clientFlags: clientFlags{Host: host},
+		SandboxID:   source,
+		Count:       2,
+		Labels:      map[string]string{"worker": "bisect"},
+	}).Run, nil, runtimeContext{})
+	if outcome.cause != nil || outcome.err != nil {
+		t.Fatalf("clone returned %v (capture: %v)", outcome.err, outcome.cause)
+	}
+	clones := strings.Fields(outcome.stdout)
+	if len(clones) != 2 || clones[0] == clones[1] {
+		t.Fatalf("expected two clone IDs, got %q", outcome.stdout)
+	}
+	for _, id := range clones {
+		resp, err := client.GetSandbox(context.Background(), &cleanroomv1.GetSandboxRequest{SandboxId: id})
+		if err != nil {
+			t.Fatalf("GetSandbox(%s) returned error: %v", id, err)
+		}
+		if labels := resp.GetSandbox().GetLabels(); labels["cloned-from"] != source || labels["worker"] != "bisect" {
+			t.Fatalf("unexpected labels on clone %s: %v", id, labels)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"fmt"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// Run pushes the sandbox's rootfs and prints the digest-pinned reference,
+// ready to use as sandbox.image.ref.
+func (c *SandboxCommitCommand) Run(ctx *runtimeContext) error {
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	listResp, err := client.ListSandboxes(ctx.commandContext(), &cleanroomv1.ListSandboxesRequest{})
+	if err != nil {
+		return err
+	}
+	resp, err := client.CommitSandbox(ctx.commandContext(), &cleanroomv1.CommitSandboxRequest{
+		SandboxId: resolveSandboxRef(listResp.GetSandboxes(), c.SandboxID),
+		Ref:       c.Ref,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(ctx.Stdout, resp.GetImageRef())
+	return err
+}
@@ -2,6 +2,8 @@ package cli
 
 import (
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -97,3 +99,34 @@ func TestCreateAliasIntegrationPrintsSandboxID(t *testing.T) {
 		t.Fatalf("expected sandbox id output, got %q", outcome.stdout)
 	}
 }
+
+func TestReadPinnedResolutionsAcceptsRecordedResolutions(t *testing.T) {
+	t.Parallel()
+
+	// Round-trip through the sandbox JSON that sandbox create --json prints.
+	recorded, err := json.Marshal(&cleanroomv1.Sandbox{
+		SandboxId:   "cr_1",
+		Resolutions: []*cleanroomv1.HostResolution{{Host: "api.github.com", Addresses: []string{"203.0.113.10", "203.0.113.11"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sandbox struct {
+		Resolutions json.RawMessage `json:"resolutions"`
+	}
+	if err := json.Unmarshal(recorded, &sandbox); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "pins.json")
+	if err := os.WriteFile(path, sandbox.Resolutions, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	pinned, err := readPinnedResolutions(path)
+	if err != nil {
+		t.Fatalf("readPinnedResolutions returned error: %v", err)
+	}
+	if len(pinned) != 1 || pinned[0].GetHost() != "api.github.com" || strings.Join(pinned[0].GetAddresses(), ",") != "203.0.113.10,203.0.113.11" {
+		t.Fatalf("unexpected pinned resolutions %v", pinned)
+	}
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+func (c *SandboxDownloadCommand) Run(ctx *runtimeContext) error {
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	listResp, err := client.ListSandboxes(ctx.commandContext(), &cleanroomv1.ListSandboxesRequest{})
+	if err != nil {
+		return err
+	}
+	output := c.Output
+	if output == "" {
+		output = path.Base(c.Path)
+	}
+	stats, err := client.SyncSandboxFile(ctx.commandContext(), resolveSandboxRef(listResp.GetSandboxes(), c.SandboxID), c.Path, output, c.MaxBytes)
+	if err != nil {
+		return err
+	}
+	if c.Verify {
+		if err := verifyDownload(output, stats.SHA256); err != nil {
+			return err
+		}
+	}
+	how := "in full"
+	if stats.Delta {
+		how = "as a delta"
+	}
+	_, err = fmt.Fprintf(ctx.Stdout, "%s: %d bytes, transferred %d bytes %s\n", output, stats.SizeBytes, stats.TransferredBytes, how)
+	if err == nil && stats.SHA256 != "" {
+		_, err = fmt.Fprintf(ctx.Stdout, "sha256: %s\n", stats.SHA256)
+	}
+	return err
+}
+
+// verifyDownload re-reads the file written to path and compares it with
+// the SHA-256 the guest agent computed.
+func verifyDownload(path, want string) error {
+	if want == "" {
+		return errors.New("--verify: the server sent no checksum; its backend or the sandbox's guest agent predates checksums")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return fmt.Errorf("--verify: %s has sha256 %s, the sandbox's file has %s", path, got, want)
+	}
+	return nil
+}
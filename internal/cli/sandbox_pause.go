@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"fmt"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+func (c *SandboxPauseCommand) Run(ctx *runtimeContext) error {
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	listResp, err := client.ListSandboxes(ctx.commandContext(), &cleanroomv1.ListSandboxesRequest{})
+	if err != nil {
+		return err
+	}
+	resp, err := client.PauseSandbox(ctx.commandContext(), &cleanroomv1.PauseSandboxRequest{
+		SandboxId: resolveSandboxRef(listResp.GetSandboxes(), c.SandboxID),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(ctx.Stdout, "%s: paused\n", resp.GetSandbox().GetSandboxId())
+	return err
+}
+
+func (c *SandboxResumeCommand) Run(ctx *runtimeContext) error {
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	listResp, err := client.ListSandboxes(ctx.commandContext(), &cleanroomv1.ListSandboxesRequest{})
+	if err != nil {
+		return err
+	}
+	resp, err := client.ResumeSandbox(ctx.commandContext(), &cleanroomv1.ResumeSandboxRequest{
+		SandboxId: resolveSandboxRef(listResp.GetSandboxes(), c.SandboxID),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(ctx.Stdout, "%s: resumed\n", resp.GetSandbox().GetSandboxId())
+	return err
+}
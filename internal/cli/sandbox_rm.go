@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+func (c *SandboxTerminateCommand) Run(ctx *runtimeContext) error {
+	bulk := c.All || len(c.Labels) > 0 || c.OlderThan > 0
+	pick := len(c.SandboxIDs) == 0 && !bulk
+	if pick && !canPromptForSandbox() {
+		return errors.New("specify sandbox IDs or names, or select sandboxes with --all, --label, or --older-than")
+	}
+	if len(c.SandboxIDs) > 0 && bulk {
+		return errors.New("sandbox IDs cannot be combined with --all, --label, or --older-than")
+	}
+
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	listResp, err := client.ListSandboxes(ctx.commandContext(), &cleanroomv1.ListSandboxesRequest{})
+	if err != nil {
+		return err
+	}
+
+	var targets []string
+	if pick {
+		id, err := pickSandbox(os.Stdin, os.Stderr, listResp.GetSandboxes())
+		if err != nil {
+			return err
+		}
+		targets = []string{id}
+	} else if bulk {
+		targets = selectSandboxes(listResp.GetSandboxes(), c.Labels, c.OlderThan, time.Now())
+		if len(targets) == 0 {
+			_, err := fmt.Fprintln(ctx.Stdout, "no matching sandboxes")
+			return err
+		}
+	} else {
+		for _, ref := range c.SandboxIDs {
+			targets = append(targets, resolveSandboxRef(listResp.GetSandboxes(), ref))
+		}
+	}
+
+	if c.DryRun {
+		for _, id := range targets {
+			if _, err := fmt.Fprintln(ctx.Stdout, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// A single explicit sandbox keeps the server's message as output; bulk
+	// removal prints one ID per terminated sandbox so it can be piped.
+	if !bulk && len(targets) == 1 {
+		resp, err := client.TerminateSandbox(ctx.commandContext(), &cleanroomv1.TerminateSandboxRequest{SandboxId: targets[0]})
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(ctx.Stdout, resp.Message)
+		return err
+	}
+
+	var failures []error
+	for _, id := range targets {
+		if _, err := client.TerminateSandbox(ctx.commandContext(), &cleanroomv1.TerminateSandboxRequest{SandboxId: id}); err != nil {
+			failures = append(failures, fmt.Errorf("terminate %s: %w", id, err))
+			continue
+		}
+		if _, err := fmt.Fprintln(ctx.Stdout, id); err != nil {
+			return err
+		}
+	}
+	return errors.Join(failures...)
+}
+
+// selectSandboxes returns the IDs of active sandboxes that carry every label
+// in labels and, when olderThan is set, were created before now-olderThan.
+func selectSandboxes(sandboxes []*cleanroomv1.Sandbox, labels map[string]string, olderThan time.Duration, now time.Time) []string {
+	var ids []string
+	for _, sb := range sandboxes {
+		if !sandboxIsActive(sb.GetStatus()) {
+			continue
+		}
+		if !sandboxHasLabels(sb, labels) {
+			continue
+		}
+		if olderThan > 0 {
+			if sb.GetCreatedAt() == nil || now.Sub(sb.GetCreatedAt().AsTime()) < olderThan {
+				continue
+			}
+		}
+		ids = append(ids, sb.GetSandboxId())
+	}
+	return ids
+}
+
+func sandboxHasLabels(sb *cleanroomv1.Sandbox, labels map[string]string) bool {
+	for key, value := range labels {
+		got, ok := sb.GetLabels()[key]
+		if !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
+
+func sandboxIsActive(status cleanroomv1.SandboxStatus) bool {
+	switch status {
+	case cleanroomv1.SandboxStatus_SANDBOX_STATUS_STOPPED, cleanroomv1.SandboxStatus_SANDBOX_STATUS_FAILED:
+		return false
+	default:
+		return true
+	}
+}
+
+// resolveSandboxRef maps a sandbox name to its ID. IDs, and references that
+// match nothing, are returned unchanged so the server reports unknown ones.
+func resolveSandboxRef(sandboxes []*cleanroomv1.Sandbox, ref string) string {
+	ref = strings.TrimSpace(ref)
+	for _, sb := range sandboxes {
+		if sb.GetSandboxId() == ref {
+			return ref
+		}
+	}
+	for _, sb := range sandboxes {
+		if sb.GetName() == ref && sandboxIsActive(sb.GetStatus()) {
+			return sb.GetSandboxId()
+		}
+	}
+	return ref
+}
+
+type gitCheckout struct {
+	Repo   string
+	Branch string
+}
+
+func gitCheckoutForDir(dir string) (gitCheckout, error) {
+	top, err := gitOutput(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return gitCheckout{}, fmt.Errorf("--name-from-branch: %w", err)
+	}
+	branch, err := gitOutput(dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return gitCheckout{}, fmt.Errorf("--name-from-branch: %w", err)
+	}
+	if branch == "HEAD" {
+		return gitCheckout{}, errors.New("--name-from-branch: HEAD is detached; pass --name instead")
+	}
+	return gitCheckout{Repo: filepath.Base(top), Branch: branch}, nil
+}
+
+func gitOutput(dir string, args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// sandboxName turns repo and branch into a valid sandbox name: runs of
+// characters outside [A-Za-z0-9._-] become '-', and the result is trimmed
+// to 63 characters.
+func (c gitCheckout) sandboxName() string {
+	var b strings.Builder
+	dash := false
+	for _, r := range c.Repo + "-" + c.Branch {
+		ok := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-'
+		if !ok {
+			if !dash {
+				b.WriteByte('-')
+				dash = true
+			}
+			continue
+		}
+		b.WriteRune(r)
+		dash = r == '-'
+	}
+	name := strings.Trim(b.String(), "-._")
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-._")
+	}
+	return name
+}
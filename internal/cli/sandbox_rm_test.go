@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestSelectSandboxesMatchesLabelsAndAge(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	sandboxes := []*cleanroomv1.Sandbox{
+		{SandboxId: "cr_old_main", Status: cleanroomv1.SandboxStatus_SANDBOX_STATUS_READY, CreatedAt: timestamppb.New(now.Add(-48 * time.Hour)), Labels: map[string]string{"branch": "main"}},
+		{SandboxId: "cr_new_main", Status: cleanroomv1.SandboxStatus_SANDBOX_STATUS_READY, CreatedAt: timestamppb.New(now.Add(-time.Hour)), Labels: map[string]string{"branch": "main"}},
+		{SandboxId: "cr_old_dev", Status: cleanroomv1.SandboxStatus_SANDBOX_STATUS_READY, CreatedAt: timestamppb.New(now.Add(-48 * time.Hour)), Labels: map[string]string{"branch": "dev"}},
+		{SandboxId: "cr_stopped", Status: cleanroomv1.SandboxStatus_SANDBOX_STATUS_STOPPED, CreatedAt: timestamppb.New(now.Add(-48 * time.Hour)), Labels: map[string]string{"branch": "main"}},
+	}
+
+	tests := []struct {
+		name      string
+		labels    map[string]string
+		olderThan time.Duration
+		want      string
+	}{
+		{name: "all", want: "cr_old_main,cr_new_main,cr_old_dev"},
+		{name: "label", labels: map[string]string{"branch": "main"}, want: "cr_old_main,cr_new_main"},
+		{name: "age", olderThan: 24 * time.Hour, want: "cr_old_main,cr_old_dev"},
+		{name: "label and age", labels: map[string]string{"branch": "main"}, olderThan: 24 * time.Hour, want: "cr_old_main"},
+	}
+	for _, tc := range tests {
+		got := strings.Join(selectSandboxes(sandboxes, tc.labels, tc.olderThan, now), ",")
+		if got != tc.want {
+			t.Fatalf("%s: unexpected selection: got %q want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestGitCheckoutSandboxName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		checkout gitCheckout
+		want     string
+	}{
+		{gitCheckout{Repo: "web", Branch: "main"}, "web-main"},
+		{gitCheckout{Repo: "web", Branch: "feature/login@v2"}, "web-feature-login-v2"},
+		{gitCheckout{Repo: ".hidden", Branch: "x"}, "hidden-x"},
+		{gitCheckout{Repo: "r", Branch: strings.Repeat("b", 80)}, "r-" + strings.Repeat("b", 61)},
+	}
+	for _, tc := range tests {
+		if got := tc.checkout.sandboxName(); got != tc.want {
+			t.Fatalf("unexpected name for %+v: got %q want %q", tc.checkout, got, tc.want)
+		}
+	}
+}
+
+func TestSandboxRmIntegrationByNameAndLabel(t *testing.T) {
+	host, svc := startIntegrationServer(t, &integrationAdapter{})
+	compiled, _, err := integrationLoader{}.LoadAndCompile(t.TempDir())
+	if err != nil {
+		t.Fatalf("load policy: %v", err)
+	}
+	create := func(name, branch string) string {
+		t.Helper()
+		resp, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{
+			Policy: compiled.ToProto(),
+			Name:   name,
+			Labels: map[string]string{"branch": branch},
+		})
+		if err != nil {
+			t.Fatalf("CreateSandbox returned error: %v", err)
+		}
+		return resp.GetSandbox().GetSandboxId()
+	}
+	namedID := create("web-main", "main")
+	devID := create("", "dev")
+	otherDevID := create("", "dev")
+
+	run := func(cmd SandboxTerminateCommand) execOutcome {
+		t.Helper()
+		cmd.clientFlags = clientFlags{Host: host}
+		outcome := runWithCapture(cmd.Run, nil, runtimeContext{})
+		if outcome.cause != nil {
+			t.Fatalf("capture failure: %v", outcome.cause)
+		}
+		if outcome.err != nil {
+			t.Fatalf("SandboxTerminateCommand.Run returned error: %v (stderr %q)", outcome.err, outcome.stderr)
+		}
+		return outcome
+	}
+
+	run(SandboxTerminateCommand{SandboxIDs: []string{"web-main"}})
+	outcome := run(SandboxTerminateCommand{Labels: map[string]string{"branch": "dev"}})
+	got := strings.Fields(outcome.stdout)
+	sort.Strings(got)
+	want := []string{devID, otherDevID}
+	sort.Strings(want)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected bulk output: got %v want %v", got, want)
+	}
+
+	for _, id := range []string{namedID, devID, otherDevID} {
+		resp, err := svc.GetSandbox(context.Background(), &cleanroomv1.GetSandboxRequest{SandboxId: id})
+		if err != nil {
+			t.Fatalf("GetSandbox returned error: %v", err)
+		}
+		if got := resp.GetSandbox().GetStatus(); got != cleanroomv1.SandboxStatus_SANDBOX_STATUS_STOPPED {
+			t.Fatalf("unexpected status for %s: got %v want STOPPED", id, got)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// Run upgrades the guest agent of each selected sandbox in turn, so a
+// rolling upgrade leaves the rest usable while one sandbox is busy.
+func (c *SandboxUpgradeCommand) Run(ctx *runtimeContext) error {
+	bulk := c.All || len(c.Labels) > 0
+	if len(c.SandboxIDs) == 0 && !bulk {
+		return errors.New("specify sandbox IDs or names, or select sandboxes with --all or --label")
+	}
+	if len(c.SandboxIDs) > 0 && bulk {
+		return errors.New("sandbox IDs cannot be combined with --all or --label")
+	}
+
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	listResp, err := client.ListSandboxes(ctx.commandContext(), &cleanroomv1.ListSandboxesRequest{})
+	if err != nil {
+		return err
+	}
+	var targets []string
+	if bulk {
+		targets = selectSandboxes(listResp.GetSandboxes(), c.Labels, 0, time.Now())
+		if len(targets) == 0 {
+			_, err := fmt.Fprintln(ctx.Stdout, "no matching sandboxes")
+			return err
+		}
+	} else {
+		for _, ref := range c.SandboxIDs {
+			targets = append(targets, resolveSandboxRef(listResp.GetSandboxes(), ref))
+		}
+	}
+
+	var failures []error
+	for _, id := range targets {
+		resp, err := client.UpgradeSandboxAgent(ctx.commandContext(), &cleanroomv1.UpgradeSandboxAgentRequest{SandboxId: id})
+		if err != nil {
+			failures = append(failures, fmt.Errorf("upgrade %s: %w", id, err))
+			continue
+		}
+		hash := shortHash(resp.GetSandbox().GetAgentHash())
+		line := fmt.Sprintf("%s: guest agent %s is current", id, hash)
+		if resp.GetUpgraded() {
+			line = fmt.Sprintf("%s: upgraded guest agent to %s", id, hash)
+		}
+		if _, err := fmt.Fprintln(ctx.Stdout, line); err != nil {
+			return err
+		}
+	}
+	return errors.Join(failures...)
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
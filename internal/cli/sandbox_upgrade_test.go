@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// agentUpgradeIntegrationAdapter upgrades every sandbox to hostHash except
+// those listed in fail.
+type agentUpgradeIntegrationAdapter struct {
+	integrationAdapter
+	mu       sync.Mutex
+	hostHash string
+	hashes   map[string]string
+	fail     map[string]bool
+}
+
+func (a *agentUpgradeIntegrationAdapter) SandboxAgentHash(sandboxID string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.hashes[sandboxID], nil
+}
+
+func (a *agentUpgradeIntegrationAdapter) UpgradeSandboxAgent(_ context.Context, sandboxID string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.fail[sandboxID] {
+		return "", errors.New("guest agent cannot be upgraded in place")
+	}
+	if a.hashes == nil {
+		a.hashes = map[string]string{}
+	}
+	a.hashes[sandboxID] = a.hostHash
+	return a.hostHash, nil
+}
+
+func TestSandboxUpgradeIntegrationUpgradesEachSandbox(t *testing.T) {
+	adapter := &agentUpgradeIntegrationAdapter{hostHash: "abcdef0123456789abcdef", fail: map[string]bool{}}
+	host, _ := startIntegrationServer(t, adapter)
+	client := mustNewControlClient(t, host)
+	first := mustCreateSandbox(t, client)
+	second := mustCreateSandbox(t, client)
+	broken := mustCreateSandbox(t, client)
+	adapter.mu.Lock()
+	adapter.fail[broken] = true
+	adapter.mu.Unlock()
+
+	run := func(cmd SandboxUpgradeCommand) execOutcome {
+		cmd.clientFlags = clientFlags{Host: host}
+		return runWithCapture(cmd.Run, nil, runtimeContext{})
+	}
+
+	outcome := run(SandboxUpgradeCommand{SandboxIDs: []string{first}})
+	if outcome.cause != nil || outcome.err != nil {
+		t.Fatalf("upgrade returned %v (capture: %v)", outcome.err, outcome.cause)
+	}
+	if got, want := strings.TrimSpace(outcome.stdout), first+": upgraded guest agent to abcdef012345"; got != want {
+		t.Fatalf("unexpected output %q, want %q", got, want)
+	}
+
+	outcome = run(SandboxUpgradeCommand{All: true})
+	if outcome.cause != nil {
+		t.Fatalf("capture failure: %v", outcome.cause)
+	}
+	if outcome.err == nil || !strings.Contains(outcome.err.Error(), "upgrade "+broken) {
+		t.Fatalf("expected the broken sandbox to be reported, got %v", outcome.err)
+	}
+	for _, want := range []string{first + ": guest agent abcdef012345 is current", second + ": upgraded guest agent to abcdef012345"} {
+		if !strings.Contains(outcome.stdout, want) {
+			t.Fatalf("expected %q in output %q", want, outcome.stdout)
+		}
+	}
+}
+
+func TestSandboxUpgradeRequiresSelection(t *testing.T) {
+	t.Parallel()
+
+	err := (&SandboxUpgradeCommand{}).Run(&runtimeContext{})
+	if err == nil || !strings.Contains(err.Error(), "--all or --label") {
+		t.Fatalf("expected a selection error, got %v", err)
+	}
+	err = (&SandboxUpgradeCommand{SandboxIDs: []string{"cr_1"}, All: true}).Run(&runtimeContext{})
+	if err == nil || !strings.Contains(err.Error(), "cannot be combined") {
+		t.Fatalf("expected a conflicting selection error, got %v", err)
+	}
+}
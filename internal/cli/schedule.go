@@ -0,0 +1,251 @@
+package cli
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type ScheduleCommand struct {
+	Create ScheduleCreateCommand `cmd:"" help:"Run a command in a fresh sandbox on a cron schedule"`
+	List   ScheduleListCommand   `name:"ls" aliases:"list" cmd:"" help:"List schedules and their last runs"`
+	Show   ScheduleShowCommand   `cmd:"" help:"Show a schedule and its recent runs"`
+	Remove ScheduleRemoveCommand `name:"rm" aliases:"remove" cmd:"" help:"Delete schedules"`
+}
+
+type ScheduleCreateCommand struct {
+	clientFlags
+	Cron        string            `required:"" help:"When to run, as a five-field cron expression in UTC (for example '0 3 * * *') or @hourly, @daily, @weekly, @monthly"`
+	Chdir       string            `short:"c" help:"Change to this directory before running commands"`
+	Backend     string            `help:"Execution backend (defaults to runtime config or host default)"`
+	Image       string            `help:"Override sandbox image ref (tag, digest, or local Docker image)"`
+	PolicyDir   string            `name:"policy-dir" help:"Use the policy for this directory (the nearest cleanroom.yaml at or above it) instead of the working directory's"`
+	Labels      map[string]string `name:"label" help:"Label to attach to each run's sandbox (key=value, repeatable)"`
+	MaxDuration time.Duration     `name:"max-duration" help:"Cancel a run whose command is still going after this long (default 1h)"`
+	Namespace   string            `help:"Run in this namespace instead of your own (admins only)"`
+	JSON        bool              `help:"Print the schedule as JSON"`
+
+	Command []string `arg:"" passthrough:"" required:"" help:"Command to run"`
+}
+
+type ScheduleListCommand struct {
+	clientFlags
+	JSON          bool   `help:"Print schedules as JSON"`
+	Namespace     string `help:"List this namespace instead of your own (admins only)"`
+	AllNamespaces bool   `name:"all-namespaces" help:"List schedules in every namespace (admins only)"`
+}
+
+type ScheduleShowCommand struct {
+	clientFlags
+	ScheduleID string `arg:"" name:"schedule" help:"Schedule ID"`
+	Runs       int32  `default:"20" help:"How many recent runs to show"`
+	JSON       bool   `help:"Print the schedule and its runs as JSON"`
+}
+
+type ScheduleRemoveCommand struct {
+	clientFlags
+	ScheduleIDs []string `arg:"" name:"schedule" help:"Schedule IDs to delete"`
+}
+
+func (c *ScheduleCreateCommand) Run(ctx *runtimeContext) error {
+	if c.MaxDuration < 0 {
+		return fmt.Errorf("invalid --max-duration %s: must not be negative", c.MaxDuration)
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	cwd, err := resolveCWD(ctx.CWD, c.Chdir)
+	if err != nil {
+		return err
+	}
+	policyDir, err := resolvePolicyDir(cwd, c.PolicyDir)
+	if err != nil {
+		return err
+	}
+	compiled, _, err := compileSandboxPolicy(ctx.commandContext(), ctx.Loader, policyDir, c.Host, c.Image, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.CreateSchedule(ctx.commandContext(), &cleanroomv1.CreateScheduleRequest{
+		Cron:           c.Cron,
+		Policy:         compiled.ToProto(),
+		Command:        append([]string(nil), c.Command...),
+		Backend:        c.Backend,
+		Labels:         c.Labels,
+		TimeoutSeconds: int64(c.MaxDuration.Round(time.Second) / time.Second),
+		Namespace:      c.Namespace,
+	})
+	if err != nil {
+		return fmt.Errorf("create schedule: %w", err)
+	}
+	if c.JSON {
+		enc := json.NewEncoder(ctx.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(resp.GetSchedule())
+	}
+	sch := resp.GetSchedule()
+	_, err = fmt.Fprintf(ctx.Stdout, "%s: next run %s\n", sch.GetScheduleId(), formatScheduleTime(sch.GetNextRunAt()))
+	return err
+}
+
+func (c *ScheduleListCommand) Run(ctx *runtimeContext) error {
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	resp, err := client.ListSchedules(ctx.commandContext(), &cleanroomv1.ListSchedulesRequest{
+		Namespace:     c.Namespace,
+		AllNamespaces: c.AllNamespaces,
+	})
+	if err != nil {
+		return err
+	}
+	if c.JSON {
+		enc := json.NewEncoder(ctx.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(resp.GetSchedules())
+	}
+	return writeScheduleList(ctx.Stdout, resp.GetSchedules(), c.AllNamespaces)
+}
+
+func (c *ScheduleShowCommand) Run(ctx *runtimeContext) error {
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	resp, err := client.GetSchedule(ctx.commandContext(), &cleanroomv1.GetScheduleRequest{
+		ScheduleId: c.ScheduleID,
+		RunLimit:   c.Runs,
+	})
+	if err != nil {
+		return err
+	}
+	if c.JSON {
+		enc := json.NewEncoder(ctx.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(resp)
+	}
+	return writeScheduleDetail(ctx.Stdout, resp)
+}
+
+func (c *ScheduleRemoveCommand) Run(ctx *runtimeContext) error {
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	for _, id := range c.ScheduleIDs {
+		if _, err := client.DeleteSchedule(ctx.commandContext(), &cleanroomv1.DeleteScheduleRequest{ScheduleId: id}); err != nil {
+			return fmt.Errorf("delete schedule %s: %w", id, err)
+		}
+		if _, err := fmt.Fprintf(ctx.Stdout, "%s: deleted\n", id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatScheduleTime(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return "-"
+	}
+	return ts.AsTime().Format(time.RFC3339)
+}
+
+// scheduleRunOutcome summarizes a run as its execution status and exit
+// code, or why it could not start.
+func scheduleRunOutcome(run *cleanroomv1.ScheduleRun) string {
+	if run == nil {
+		return "-"
+	}
+	outcome := strings.ToLower(strings.TrimPrefix(run.GetStatus().String(), "EXECUTION_STATUS_"))
+	if run.GetStatus() == cleanroomv1.ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED {
+		outcome = "error"
+	} else if run.GetExitCode() != 0 {
+		outcome += " (exit " + strconv.Itoa(int(run.GetExitCode())) + ")"
+	}
+	return outcome
+}
+
+func writeScheduleList(w io.Writer, schedules []*cleanroomv1.Schedule, allNamespaces bool) error {
+	if len(schedules) == 0 {
+		_, err := fmt.Fprintln(w, "no schedules")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	header := "ID\tCRON\tNEXT RUN\tLAST RUN\tLAST OUTCOME\tCOMMAND"
+	if allNamespaces {
+		header = "NAMESPACE\t" + header
+	}
+	if _, err := fmt.Fprintln(tw, header); err != nil {
+		return err
+	}
+	for _, sch := range schedules {
+		if allNamespaces {
+			if _, err := fmt.Fprintf(tw, "%s\t", sch.GetNamespace()); err != nil {
+				return err
+			}
+		}
+		lastRun := "-"
+		if run := sch.GetLastRun(); run != nil {
+			lastRun = formatScheduleTime(run.GetStartedAt())
+		}
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			sch.GetScheduleId(), sch.GetCron(), formatScheduleTime(sch.GetNextRunAt()), lastRun,
+			scheduleRunOutcome(sch.GetLastRun()), strings.Join(sch.GetCommand(), " ")); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+func writeScheduleDetail(w io.Writer, resp *cleanroomv1.GetScheduleResponse) error {
+	sch := resp.GetSchedule()
+	tw := tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	for _, field := range [][2]string{
+		{"ID", sch.GetScheduleId()},
+		{"Cron", sch.GetCron() + " (UTC)"},
+		{"Command", strings.Join(sch.GetCommand(), " ")},
+		{"Namespace", sch.GetNamespace()},
+		{"Created by", sch.GetIdentity()},
+		{"Next run", formatScheduleTime(sch.GetNextRunAt())},
+	} {
+		if field[1] == "" {
+			continue
+		}
+		if _, err := fmt.Fprintf(tw, "%s:\t%s\n", field[0], field[1]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(resp.GetRuns()) == 0 {
+		_, err := fmt.Fprintln(w, "\nno runs yet")
+		return err
+	}
+	if _, err := fmt.Fprintln(w); err != nil {
+		return err
+	}
+	tw = tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "STARTED\tDURATION\tOUTCOME\tRUN ID\tERROR"); err != nil {
+		return err
+	}
+	for _, run := range resp.GetRuns() {
+		duration := run.GetFinishedAt().AsTime().Sub(run.GetStartedAt().AsTime()).Round(time.Second)
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			formatScheduleTime(run.GetStartedAt()), duration, scheduleRunOutcome(run),
+			cmp.Or(run.GetRunId(), "-"), cmp.Or(run.GetError(), "-")); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/schedule"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestScheduleCommandsIntegration(t *testing.T) {
+	host, svc := startIntegrationServer(t, &integrationAdapter{})
+	store, err := schedule.Open(context.Background(), filepath.Join(t.TempDir(), "schedules.db"))
+	if err != nil {
+		t.Fatalf("open schedule store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	svc.Schedules = store
+	cwd := t.TempDir()
+	runCtx := runtimeContext{CWD: cwd, Loader: integrationLoader{}}
+
+	outcome := runWithCapture((&ScheduleCreateCommand{
+		clientFlags: clientFlags{Host: host},
+		Cron:        "0 3 * * *",
+		MaxDuration: 10 * time.Minute,
+		Command:     []string{"trivy", "fs", "/workspace"},
+	}).Run, nil, runCtx)
+	if outcome.cause != nil || outcome.err != nil {
+		t.Fatalf("schedule create failed: %v, %v", outcome.cause, outcome.err)
+	}
+	id, next, ok := strings.Cut(strings.TrimSpace(outcome.stdout), ": next run ")
+	if !ok || !strings.HasPrefix(id, "sched_") || !strings.HasSuffix(next, "T03:00:00Z") {
+		t.Fatalf("unexpected schedule create output: %q", outcome.stdout)
+	}
+
+	outcome = runWithCapture((&ScheduleListCommand{clientFlags: clientFlags{Host: host}}).Run, nil, runCtx)
+	if outcome.err != nil || !strings.Contains(outcome.stdout, id) || !strings.Contains(outcome.stdout, "trivy fs /workspace") {
+		t.Fatalf("unexpected schedule ls output: %q, %v", outcome.stdout, outcome.err)
+	}
+
+	outcome = runWithCapture((&ScheduleRemoveCommand{clientFlags: clientFlags{Host: host}, ScheduleIDs: []string{id}}).Run, nil, runCtx)
+	if outcome.err != nil || strings.TrimSpace(outcome.stdout) != id+": deleted" {
+		t.Fatalf("unexpected schedule rm output: %q, %v", outcome.stdout, outcome.err)
+	}
+	outcome = runWithCapture((&ScheduleListCommand{clientFlags: clientFlags{Host: host}}).Run, nil, runCtx)
+	if outcome.err != nil || strings.TrimSpace(outcome.stdout) != "no schedules" {
+		t.Fatalf("unexpected schedule ls output after rm: %q, %v", outcome.stdout, outcome.err)
+	}
+}
+
+func TestWriteScheduleDetailListsRuns(t *testing.T) {
+	t.Parallel()
+
+	started := time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC)
+	var out bytes.Buffer
+	err := writeScheduleDetail(&out, &cleanroomv1.GetScheduleResponse{
+		Schedule: &cleanroomv1.Schedule{ScheduleId: "sched_1", Cron: "0 3 * * *", Command: []string{"scan"}},
+		Runs: []*cleanroomv1.ScheduleRun{
+			{StartedAt: timestamppb.New(started), FinishedAt: timestamppb.New(started.Add(90 * time.Second)), Status: cleanroomv1.ExecutionStatus_EXECUTION_STATUS_FAILED, ExitCode: 2, RunId: "run_1"},
+			{StartedAt: timestamppb.New(started.AddDate(0, 0, -1)), FinishedAt: timestamppb.New(started.AddDate(0, 0, -1)), Error: "create sandbox: no capacity"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("writeScheduleDetail returned error: %v", err)
+	}
+	for _, want := range []string{
+		"Cron:      0 3 * * * (UTC)",
+		"2026-10-17T03:00:00Z  1m30s     failed (exit 2)  run_1   -",
+		"2026-10-16T03:00:00Z  0s        error            -       create sandbox: no capacity",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
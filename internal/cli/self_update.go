@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/selfupdate"
+)
+
+type SelfUpdateCommand struct {
+	Version   string `help:"Release to install, for example v0.5.0 (default latest)"`
+	Repo      string `default:"buildkite/cleanroom" help:"GitHub repository to download releases from"`
+	PublicKey string `name:"public-key" help:"Base64 Ed25519 key the release checksums must be signed with (defaults to the key this build was released with)"`
+	Check     bool   `help:"Only report whether another release is available"`
+	Force     bool   `help:"Reinstall even when the release matches this build"`
+}
+
+// selfUpdateCodesign signs the darwin-vz helper with its entitlements, as
+// scripts/install.sh does. Tests replace it.
+var selfUpdateCodesign = func(ctx context.Context, path, entitlements string) error {
+	out, err := exec.CommandContext(ctx, "codesign", "--force", "--sign", "-", "--entitlements", entitlements, path).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("codesign %s: %w: %s", filepath.Base(path), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (c *SelfUpdateCommand) Run(ctx *runtimeContext) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate cleanroom binary: %w", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return fmt.Errorf("locate cleanroom binary: %w", err)
+	}
+	return c.update(ctx.commandContext(), selfupdate.Source{Repo: c.Repo}, ctx.Version, exe, runtime.GOOS, runtime.GOARCH, ctx.Stdout, os.Stderr)
+}
+
+// update installs the selected release over exe and its companion binaries
+// in the same directory: the guest agent, and on macOS the darwin-vz
+// helper. All of them come from one archive so their versions match.
+func (c *SelfUpdateCommand) update(ctx context.Context, source selfupdate.Source, current, exe, goos, goarch string, stdout, stderr io.Writer) error {
+	key, err := c.releaseKey()
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		_, _ = fmt.Fprintln(stderr, "warning: this build has no release signing key, so only checksums are verified; pass --public-key to verify the signature")
+	}
+
+	target := strings.TrimSpace(c.Version)
+	if target == "" || target == "latest" {
+		if target, err = source.LatestVersion(ctx); err != nil {
+			return err
+		}
+	} else if !strings.HasPrefix(target, "v") {
+		target = "v" + target
+	}
+	if target == current && !c.Force {
+		_, err := fmt.Fprintf(stdout, "cleanroom %s is up to date\n", current)
+		return err
+	}
+	if c.Check {
+		_, err := fmt.Fprintf(stdout, "cleanroom %s is available (installed %s)\n", target, current)
+		return err
+	}
+
+	asset, err := selfupdate.AssetName(goos, goarch)
+	if err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp("", "cleanroom-self-update-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	archive, err := source.Download(ctx, target, asset, key, tmpDir)
+	if err != nil {
+		return err
+	}
+
+	// Stage next to the targets so the final renames stay on one
+	// filesystem.
+	dir := filepath.Dir(exe)
+	targets := map[string]string{
+		"cleanroom":             exe,
+		"cleanroom-guest-agent": filepath.Join(dir, "cleanroom-guest-agent"),
+	}
+	if goos == "darwin" {
+		targets["cleanroom-darwin-vz"] = filepath.Join(dir, "cleanroom-darwin-vz")
+	}
+	extract := map[string]string{}
+	staged := map[string]string{}
+	for name, path := range targets {
+		stagedPath := filepath.Join(dir, "."+filepath.Base(path)+".new")
+		extract[name] = stagedPath
+		staged[path] = stagedPath
+	}
+	defer func() {
+		for _, stagedPath := range staged {
+			_ = os.Remove(stagedPath)
+		}
+	}()
+	entitlements := filepath.Join(tmpDir, "entitlements.plist")
+	if goos == "darwin" {
+		extract["entitlements.plist"] = entitlements
+	}
+	if err := selfupdate.Extract(archive, extract); err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return fmt.Errorf("cannot write to %s: rerun with sudo (%w)", dir, err)
+		}
+		return err
+	}
+	if goos == "darwin" {
+		if err := selfUpdateCodesign(ctx, extract["cleanroom-darwin-vz"], entitlements); err != nil {
+			return err
+		}
+	}
+	if err := selfupdate.Swap(staged); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(stdout, "updated cleanroom %s -> %s in %s\n", current, target, dir); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, "restart cleanroom serve to run the new version, then run cleanroom sandbox upgrade-agent --all to move running sandboxes to the new guest agent")
+	return err
+}
+
+func (c *SelfUpdateCommand) releaseKey() (ed25519.PublicKey, error) {
+	if strings.TrimSpace(c.PublicKey) != "" {
+		return selfupdate.ParsePublicKey(c.PublicKey)
+	}
+	return selfupdate.ReleasePublicKey()
+}
@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/selfupdate"
+)
+
+func startSelfUpdateRelease(t *testing.T, version string, files map[string]string) (selfupdate.Source, string) {
+	t.Helper()
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(archive.Bytes())
+	checksums := []byte(hex.EncodeToString(sum[:]) + "  cleanroom_Linux_x86_64.tar.gz\n")
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := "/acme/cleanroom/releases/download/" + version + "/"
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/acme/cleanroom/releases/latest", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, `{"tag_name":%q}`, version)
+	})
+	mux.HandleFunc(base+"checksums.txt", func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write(checksums) })
+	mux.HandleFunc(base+"checksums.txt.sig", func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write(ed25519.Sign(priv, checksums)) })
+	mux.HandleFunc(base+"cleanroom_Linux_x86_64.tar.gz", func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write(archive.Bytes()) })
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return selfupdate.Source{Repo: "acme/cleanroom", DownloadURL: server.URL, APIURL: server.URL}, base64.StdEncoding.EncodeToString(pub)
+}
+
+func TestSelfUpdateReplacesBinaryAndGuestAgentTogether(t *testing.T) {
+	source, key := startSelfUpdateRelease(t, "v1.1.0", map[string]string{
+		"cleanroom":             "cleanroom v1.1.0",
+		"cleanroom-guest-agent": "agent v1.1.0",
+	})
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "cleanroom")
+	for path, content := range map[string]string{exe: "cleanroom v1.0.0", filepath.Join(dir, "cleanroom-guest-agent"): "agent v1.0.0"} {
+		if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := SelfUpdateCommand{PublicKey: key}
+	if err := cmd.update(context.Background(), source, "v1.0.0", exe, "linux", "amd64", &stdout, &stderr); err != nil {
+		t.Fatalf("update returned error: %v", err)
+	}
+	for path, want := range map[string]string{exe: "cleanroom v1.1.0", filepath.Join(dir, "cleanroom-guest-agent"): "agent v1.1.0"} {
+		got, err := os.ReadFile(path)
+		if err != nil || string(got) != want {
+			t.Fatalf("%s = %q, %v; want %q", filepath.Base(path), got, err, want)
+		}
+	}
+	if !strings.Contains(stdout.String(), "updated cleanroom v1.0.0 -> v1.1.0") {
+		t.Fatalf("unexpected output %q", stdout.String())
+	}
+	if stderr.Len() != 0 {
+		t.Fatalf("unexpected warning %q", stderr.String())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("expected only the two binaries to remain, got %v (%v)", entries, err)
+	}
+}
+
+func TestSelfUpdateLeavesBinariesWhenArchiveIsIncomplete(t *testing.T) {
+	source, key := startSelfUpdateRelease(t, "v1.1.0", map[string]string{"cleanroom": "cleanroom v1.1.0"})
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "cleanroom")
+	if err := os.WriteFile(exe, []byte("cleanroom v1.0.0"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := SelfUpdateCommand{PublicKey: key}
+	err := cmd.update(context.Background(), source, "v1.0.0", exe, "linux", "amd64", &bytes.Buffer{}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "cleanroom-guest-agent is missing") {
+		t.Fatalf("expected a missing guest agent error, got %v", err)
+	}
+	if got, _ := os.ReadFile(exe); string(got) != "cleanroom v1.0.0" {
+		t.Fatalf("binary replaced despite the failed update: %q", got)
+	}
+}
+
+func TestSelfUpdateCheckReportsAvailableRelease(t *testing.T) {
+	source, key := startSelfUpdateRelease(t, "v1.1.0", nil)
+
+	var stdout bytes.Buffer
+	cmd := SelfUpdateCommand{PublicKey: key, Check: true}
+	if err := cmd.update(context.Background(), source, "v1.0.0", filepath.Join(t.TempDir(), "cleanroom"), "linux", "amd64", &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("update returned error: %v", err)
+	}
+	if got := stdout.String(); got != "cleanroom v1.1.0 is available (installed v1.0.0)\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+
+	stdout.Reset()
+	if err := cmd.update(context.Background(), source, "v1.1.0", filepath.Join(t.TempDir(), "cleanroom"), "linux", "amd64", &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("update returned error: %v", err)
+	}
+	if got := stdout.String(); got != "cleanroom v1.1.0 is up to date\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/buildkite/cleanroom/internal/controlservice"
+	"github.com/charmbracelet/log"
+)
+
+// reloadConfigOnSIGHUP re-reads the runtime config, with the same profile
+// and working directory serve started with, each time the process receives
+// SIGHUP.
+func reloadConfigOnSIGHUP(ctx context.Context, runtimeCtx *runtimeContext, service *controlservice.Service, logger *log.Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			reloadServeConfig(runtimeCtx, service, logger)
+		}
+	}
+}
+
+func reloadServeConfig(runtimeCtx *runtimeContext, service *controlservice.Service, logger *log.Logger) controlservice.ConfigReload {
+	cfg, path, _, err := loadRuntimeConfig(runtimeCtx.Config.Profile, runtimeCtx.CWD)
+	if err != nil {
+		// Keep serving with the config already in effect.
+		logger.Error("runtime config reload failed", "path", path, "error", err)
+		return controlservice.ConfigReload{}
+	}
+	result := service.ReloadConfig(cfg)
+	logger.Info("runtime config reloaded",
+		"audit", true,
+		"path", path,
+		"profile", cfg.Profile,
+		"applied", result.Applied,
+		"restart_required", result.RestartRequired,
+	)
+	for _, key := range result.RestartRequired {
+		logger.Warn("runtime config setting changed but requires a serve restart", "setting", key)
+	}
+	return result
+}
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/controlservice"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+	"github.com/charmbracelet/log"
+)
+
+func TestReloadServeConfigReadsConfigFromDisk(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tmp)
+	configPath := filepath.Join(tmp, "cleanroom", "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o755); err != nil {
+		t.Fatalf("mkdir config dir: %v", err)
+	}
+
+	service := &controlservice.Service{Config: runtimeconfig.Config{DefaultBackend: "firecracker"}}
+	runtimeCtx := &runtimeContext{CWD: tmp, Config: service.Config}
+	logger := log.New(io.Discard)
+
+	if err := os.WriteFile(configPath, []byte("default_backend: firecracker\nbackends:\n  firecracker:\n    memory_mib: 4096\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	result := reloadServeConfig(runtimeCtx, service, logger)
+	if len(result.Applied) != 1 || result.Applied[0] != "backends.firecracker.memory_mib" {
+		t.Fatalf("unexpected reload result: %+v", result)
+	}
+	if got, want := service.Config.Backends.Firecracker.MemoryMiB, int64(4096); got != want {
+		t.Fatalf("unexpected memory_mib: got %d want %d", got, want)
+	}
+
+	if err := os.WriteFile(configPath, []byte("backends: [\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	reloadServeConfig(runtimeCtx, service, logger)
+	if got, want := service.Config.Backends.Firecracker.MemoryMiB, int64(4096); got != want {
+		t.Fatalf("expected a broken config to leave settings unchanged: got %d want %d", got, want)
+	}
+}
@@ -157,6 +157,9 @@ func TestServeInstallForceOverwritesAndEnablesService(t *testing.T) {
 	if !strings.Contains(content, "ExecStart=/usr/local/bin/cleanroom serve") {
 		t.Fatalf("expected serve exec start, got:\n%s", content)
 	}
+	if !strings.Contains(content, "ExecReload=/bin/kill -HUP $MAINPID") {
+		t.Fatalf("expected SIGHUP reload in unit, got:\n%s", content)
+	}
 	if !strings.Contains(content, "--listen unix:///var/run/cleanroom/cleanroom.sock") {
 		t.Fatalf("expected explicit default --listen in unit, got:\n%s", content)
 	}
@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+	"github.com/buildkite/cleanroom/internal/sharedstate"
+	"github.com/charmbracelet/log"
+)
+
+// openSharedState registers this serve instance in the etcd cluster the
+// state section names. The host ID defaults to the hostname.
+func openSharedState(cfg runtimeconfig.State, logger *log.Logger) (*sharedstate.State, error) {
+	etcd, err := sharedstate.NewEtcd(cfg.Etcd, nil)
+	if err != nil {
+		return nil, fmt.Errorf("state.etcd: %w", err)
+	}
+	hostID := strings.TrimSpace(cfg.HostID)
+	if hostID == "" {
+		if hostID, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("state.host_id: %w", err)
+		}
+	}
+	return sharedstate.New(etcd, sharedstate.Options{
+		Prefix:   strings.TrimSpace(cfg.Prefix),
+		Host:     sharedstate.Host{ID: hostID, URL: strings.TrimRight(strings.TrimSpace(cfg.AdvertiseURL), "/")},
+		LeaseTTL: time.Duration(cfg.LeaseSeconds) * time.Second,
+	}, logger)
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/buildkite/cleanroom/internal/controlclient"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/charmbracelet/log"
+)
+
+// timeoutExitCode matches timeout(1), so scripts can tell a command that
+// ran out of time from one that failed.
+const timeoutExitCode = 124
+
+// executionCancelTimeout bounds the best-effort cancel sent for an
+// execution whose command timed out.
+const executionCancelTimeout = 10 * time.Second
+
+// commandTimeoutError reports that a client command ran past --timeout.
+type commandTimeoutError struct {
+	timeout time.Duration
+}
+
+func (e *commandTimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s (--timeout)", e.timeout)
+}
+
+func (e *commandTimeoutError) ExitCode() int {
+	return timeoutExitCode
+}
+
+func (f *clientFlags) commandTimeout() time.Duration {
+	return f.Timeout
+}
+
+// newCommandContext returns the context cmd's RPCs run under, with the
+// command's --timeout as its deadline when it has one.
+func newCommandContext(cmd any) (context.Context, context.CancelFunc) {
+	if c, ok := cmd.(interface{ commandTimeout() time.Duration }); ok {
+		if timeout := c.commandTimeout(); timeout > 0 {
+			return context.WithTimeoutCause(context.Background(), timeout, &commandTimeoutError{timeout: timeout})
+		}
+	}
+	return context.WithCancel(context.Background())
+}
+
+// timedOut replaces err with the --timeout error when ctx's deadline is
+// what ended the command. A command's own exit status is kept.
+func timedOut(ctx context.Context, err error) error {
+	if err == nil {
+		return err
+	}
+	awaitServerDeadline(ctx, err)
+	if ctx.Err() == nil {
+		return err
+	}
+	var exitErr exitCodeError
+	if errors.As(err, &exitErr) {
+		return err
+	}
+	var timeoutErr *commandTimeoutError
+	if errors.As(context.Cause(ctx), &timeoutErr) {
+		return timeoutErr
+	}
+	return err
+}
+
+// awaitServerDeadline waits for ctx to expire when err is the server
+// reporting ctx's deadline. The server enforces the deadline it was sent
+// and can report it just before our own timer fires.
+func awaitServerDeadline(ctx context.Context, err error) {
+	if err == nil || ctx.Err() != nil {
+		return
+	}
+	if !errors.Is(err, context.DeadlineExceeded) && connect.CodeOf(err) != connect.CodeDeadlineExceeded {
+		return
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < time.Second {
+		<-ctx.Done()
+	}
+}
+
+// cancelTimedOutExecution asks the server to stop an execution the client
+// gave up on, so it does not keep running unattended.
+func cancelTimedOutExecution(client *controlclient.Client, sandboxID, executionID string, logger *log.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), executionCancelTimeout)
+	defer cancel()
+	_, err := client.CancelExecution(ctx, &cleanroomv1.CancelExecutionRequest{
+		SandboxId:   sandboxID,
+		ExecutionId: executionID,
+		Signal:      15,
+	})
+	if err != nil && logger != nil {
+		logger.Warn("cancel timed out execution failed", "sandbox_id", sandboxID, "execution_id", executionID, "error", err)
+	}
+}
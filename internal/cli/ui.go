@@ -69,8 +69,13 @@ func renderDoctorReport(backendName string, checks []backend.DoctorCheck, color
 		name = "unknown"
 	}
 
+	return renderCheckReport(fmt.Sprintf("doctor report (%s)", name), checks, color)
+}
+
+// renderCheckReport prints checks one per line under title, with a fix
+// hint under each one that did not pass and a summary line.
+func renderCheckReport(title string, checks []backend.DoctorCheck, color bool) string {
 	var out strings.Builder
-	title := fmt.Sprintf("doctor report (%s)", name)
 	if color {
 		title = ansiWrap("1;36", title)
 	}
@@ -131,6 +136,14 @@ func renderDoctorReport(backendName string, checks []backend.DoctorCheck, color
 		out.WriteString(": ")
 		out.WriteString(message)
 		out.WriteByte('\n')
+		if remediation := strings.TrimSpace(check.Remediation); remediation != "" && status != "pass" {
+			hint := "    fix: " + remediation
+			if color {
+				hint = ansiWrap("38;5;246", hint)
+			}
+			out.WriteString(hint)
+			out.WriteByte('\n')
+		}
 	}
 
 	summary := fmt.Sprintf("summary: %d pass, %d warn, %d fail", passCount, warnCount, failCount)
@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type UsageCommand struct {
+	clientFlags
+	Since     string   `default:"30d" help:"Start of the report: a duration back from now (30d, 12h) or a date or RFC 3339 time"`
+	GroupBy   []string `name:"group-by" help:"Group by identity, namespace, backend, sandbox or label:<key> (comma-separated or repeatable)"`
+	Kind      string   `enum:"sandbox,execution" default:"sandbox" help:"Report sandboxes, or the executions that ran in them"`
+	Namespace string   `help:"Only report this namespace (only admins may name another namespace)"`
+	Format    string   `enum:"table,csv,json" default:"table" help:"Output format: csv and json suit chargeback exports"`
+}
+
+func (c *UsageCommand) Run(ctx *runtimeContext) error {
+	since, err := parseUsageSince(c.Since, time.Now())
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	resp, err := client.GetUsage(ctx.commandContext(), &cleanroomv1.GetUsageRequest{
+		Since:     timestamppb.New(since),
+		GroupBy:   c.GroupBy,
+		Kind:      c.Kind,
+		Namespace: c.Namespace,
+	})
+	if err != nil {
+		return err
+	}
+	switch c.Format {
+	case "csv":
+		return writeUsageCSV(ctx.Stdout, resp)
+	case "json":
+		return writeUsageJSON(ctx.Stdout, resp)
+	}
+	return writeUsageTable(ctx.Stdout, resp)
+}
+
+// parseUsageSince reads --since as a duration back from now, where a d
+// suffix counts days, or as a date or RFC 3339 time.
+func parseUsageSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: want a duration such as 30d or 12h, a date (2006-01-02) or an RFC 3339 time", value)
+}
+
+var usageColumns = []string{"count", "vcpu_seconds", "memory_mib_seconds", "disk_gb_hours"}
+
+func usageValues(row *cleanroomv1.UsageRow) []string {
+	return []string{
+		strconv.FormatInt(row.GetCount(), 10),
+		strconv.FormatFloat(row.GetVcpuSeconds(), 'f', 0, 64),
+		strconv.FormatFloat(row.GetMemoryMibSeconds(), 'f', 0, 64),
+		strconv.FormatFloat(row.GetDiskGbHours(), 'f', 3, 64),
+	}
+}
+
+func writeUsageTable(w io.Writer, resp *cleanroomv1.GetUsageResponse) error {
+	if len(resp.GetRows()) == 0 {
+		_, err := fmt.Fprintf(w, "no %s usage since %s\n", resp.GetKind(), resp.GetSince().AsTime().Local().Format(time.DateTime))
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	header := make([]string, 0, len(resp.GetGroupBy())+len(usageColumns))
+	for _, key := range append(append([]string(nil), resp.GetGroupBy()...), usageColumns...) {
+		header = append(header, strings.ToUpper(key))
+	}
+	if _, err := fmt.Fprintln(tw, strings.Join(header, "\t")); err != nil {
+		return err
+	}
+	for _, row := range resp.GetRows() {
+		group := make([]string, 0, len(row.GetGroup()))
+		for _, value := range row.GetGroup() {
+			if value == "" {
+				value = "-"
+			}
+			group = append(group, value)
+		}
+		if _, err := fmt.Fprintln(tw, strings.Join(append(group, usageValues(row)...), "\t")); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+func writeUsageCSV(w io.Writer, resp *cleanroomv1.GetUsageResponse) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(append(append([]string(nil), resp.GetGroupBy()...), usageColumns...)); err != nil {
+		return err
+	}
+	for _, row := range resp.GetRows() {
+		if err := out.Write(append(append([]string(nil), row.GetGroup()...), usageValues(row)...)); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+type usageReport struct {
+	Since   time.Time        `json:"since"`
+	Until   time.Time        `json:"until"`
+	Kind    string           `json:"kind"`
+	GroupBy []string         `json:"group_by"`
+	Rows    []usageReportRow `json:"rows"`
+}
+
+type usageReportRow struct {
+	Group            map[string]string `json:"group"`
+	Count            int64             `json:"count"`
+	VCPUSeconds      float64           `json:"vcpu_seconds"`
+	MemoryMiBSeconds float64           `json:"memory_mib_seconds"`
+	DiskGBHours      float64           `json:"disk_gb_hours"`
+}
+
+func writeUsageJSON(w io.Writer, resp *cleanroomv1.GetUsageResponse) error {
+	report := usageReport{
+		Since:   resp.GetSince().AsTime(),
+		Until:   resp.GetUntil().AsTime(),
+		Kind:    resp.GetKind(),
+		GroupBy: append([]string{}, resp.GetGroupBy()...),
+		Rows:    []usageReportRow{},
+	}
+	for _, row := range resp.GetRows() {
+		group := map[string]string{}
+		for i, key := range resp.GetGroupBy() {
+			if i < len(row.GetGroup()) {
+				group[key] = row.GetGroup()[i]
+			}
+		}
+		report.Rows = append(report.Rows, usageReportRow{
+			Group:            group,
+			Count:            row.GetCount(),
+			VCPUSeconds:      row.GetVcpuSeconds(),
+			MemoryMiBSeconds: row.GetMemoryMibSeconds(),
+			DiskGBHours:      row.GetDiskGbHours(),
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+func TestParseUsageSince(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 10, 31, 12, 0, 0, 0, time.UTC)
+	for value, want := range map[string]time.Time{
+		"30d":                  now.AddDate(0, 0, -30),
+		"12h":                  now.Add(-12 * time.Hour),
+		"2026-10-01T00:00:00Z": time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		got, err := parseUsageSince(value, now)
+		if err != nil || !got.Equal(want) {
+			t.Fatalf("parseUsageSince(%q) = %s, %v; want %s", value, got, err, want)
+		}
+	}
+	if got, err := parseUsageSince("2026-10-01", now); err != nil || got.Day() != 1 {
+		t.Fatalf("parseUsageSince(date) = %s, %v", got, err)
+	}
+	for _, bad := range []string{"", "-3d", "last month"} {
+		if _, err := parseUsageSince(bad, now); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestWriteUsageCSV(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	err := writeUsageCSV(&out, &cleanroomv1.GetUsageResponse{
+		GroupBy: []string{"label:team"},
+		Rows:    []*cleanroomv1.UsageRow{{Group: []string{"web, api"}, Count: 2, VcpuSeconds: 7200, MemoryMibSeconds: 3686400, DiskGbHours: 1.5}},
+	})
+	if err != nil {
+		t.Fatalf("writeUsageCSV returned error: %v", err)
+	}
+	want := "label:team,count,vcpu_seconds,memory_mib_seconds,disk_gb_hours\n\"web, api\",2,7200,3686400,1.500\n"
+	if out.String() != want {
+		t.Fatalf("unexpected CSV:\n%s", out.String())
+	}
+}
+
+func TestWriteUsageJSONNamesGroups(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	err := writeUsageJSON(&out, &cleanroomv1.GetUsageResponse{
+		Kind:    "sandbox",
+		GroupBy: []string{"identity"},
+		Rows:    []*cleanroomv1.UsageRow{{Group: []string{"uid:1001"}, Count: 1, VcpuSeconds: 60}},
+	})
+	if err != nil {
+		t.Fatalf("writeUsageJSON returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), `"identity": "uid:1001"`) || !strings.Contains(out.String(), `"vcpu_seconds": 60`) {
+		t.Fatalf("unexpected JSON:\n%s", out.String())
+	}
+}
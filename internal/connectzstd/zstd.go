@@ -0,0 +1,78 @@
+// Package connectzstd registers zstd compression with Connect clients and
+// handlers. Clients that register it prefer it over gzip, and handlers
+// answer with it when the client accepts it, so text-heavy streams such as
+// execution output cross slow links several times smaller.
+package connectzstd
+
+import (
+	"io"
+
+	"connectrpc.com/connect"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Name is the encoding name clients and handlers negotiate.
+const Name = "zstd"
+
+const (
+	// minBytes leaves messages smaller than this uncompressed; compressing
+	// them costs more than the bytes it saves.
+	minBytes = 1024
+	// maxWindowBytes bounds the memory a peer's zstd stream may make the
+	// decoder allocate.
+	maxWindowBytes = 8 << 20
+	// maxDecodedBytes bounds one decompressed message.
+	maxDecodedBytes = 64 << 20
+)
+
+// HandlerOptions registers zstd with a Connect handler, which then
+// answers with it clients that accept it.
+func HandlerOptions() []connect.HandlerOption {
+	return []connect.HandlerOption{
+		connect.WithCompression(Name, newDecompressor, newCompressor),
+		connect.WithCompressMinBytes(minBytes),
+	}
+}
+
+// ClientOptions registers zstd with a Connect client as its preferred
+// response encoding. Requests stay uncompressed, so servers without zstd
+// still understand them and answer with gzip or nothing.
+func ClientOptions() []connect.ClientOption {
+	return []connect.ClientOption{
+		connect.WithAcceptCompression(Name, newDecompressor, newCompressor),
+		connect.WithCompressMinBytes(minBytes),
+	}
+}
+
+func newCompressor() connect.Compressor {
+	// Errors only come from invalid options.
+	enc, _ := zstd.NewWriter(nil,
+		zstd.WithEncoderLevel(zstd.SpeedFastest),
+		zstd.WithEncoderConcurrency(1),
+		zstd.WithLowerEncoderMem(true),
+	)
+	return enc
+}
+
+func newDecompressor() connect.Decompressor {
+	// Errors only come from invalid options.
+	dec, _ := zstd.NewReader(nil,
+		zstd.WithDecoderConcurrency(1),
+		zstd.WithDecoderLowmem(true),
+		zstd.WithDecoderMaxWindow(maxWindowBytes),
+		zstd.WithDecoderMaxMemory(maxDecodedBytes),
+	)
+	return &decompressor{dec: dec}
+}
+
+// decompressor adapts zstd.Decoder, whose Close ends it for good, to the
+// pooled reuse Connect expects: Close only releases the source.
+type decompressor struct {
+	dec *zstd.Decoder
+}
+
+func (d *decompressor) Read(p []byte) (int, error) { return d.dec.Read(p) }
+
+func (d *decompressor) Reset(r io.Reader) error { return d.dec.Reset(r) }
+
+func (d *decompressor) Close() error { return d.dec.Reset(nil) }
@@ -0,0 +1,83 @@
+package connectzstd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/gen/cleanroom/v1/cleanroomv1connect"
+	"github.com/klauspost/compress/zstd"
+)
+
+type digestServer struct {
+	cleanroomv1connect.UnimplementedServerServiceHandler
+}
+
+func (digestServer) GetServerInfo(context.Context, *connect.Request[cleanroomv1.GetServerInfoRequest]) (*connect.Response[cleanroomv1.GetServerInfoResponse], error) {
+	res := &cleanroomv1.GetServerInfoResponse{Version: "dev"}
+	for i := range 200 {
+		res.CachedImageDigests = append(res.CachedImageDigests, fmt.Sprintf("sha256:%064d", i))
+	}
+	return connect.NewResponse(res), nil
+}
+
+func TestNegotiatesZstd(t *testing.T) {
+	t.Parallel()
+
+	path, handler := cleanroomv1connect.NewServerServiceHandler(digestServer{}, HandlerOptions()...)
+	var accepted []string
+	mux := http.NewServeMux()
+	mux.Handle(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted = append(accepted, r.Header.Get("Accept-Encoding"))
+		handler.ServeHTTP(w, r)
+	}))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := cleanroomv1connect.NewServerServiceClient(server.Client(), server.URL, ClientOptions()...)
+	for range 3 { // reuses pooled decompressors
+		res, err := client.GetServerInfo(context.Background(), connect.NewRequest(&cleanroomv1.GetServerInfoRequest{}))
+		if err != nil {
+			t.Fatalf("GetServerInfo: %v", err)
+		}
+		if got := len(res.Msg.GetCachedImageDigests()); got != 200 {
+			t.Fatalf("expected 200 digests, got %d", got)
+		}
+	}
+	if !strings.HasPrefix(accepted[0], Name+",") {
+		t.Fatalf("expected the client to prefer zstd, got Accept-Encoding %q", accepted[0])
+	}
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+cleanroomv1connect.ServerServiceGetServerInfoProcedure, strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Encoding", "zstd, gzip")
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Encoding"); got != Name {
+		t.Fatalf("expected a zstd response, got %q", got)
+	}
+	dec, err := zstd.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+	body, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("decompress response: %v", err)
+	}
+	if !strings.Contains(string(body), "sha256:") {
+		t.Fatalf("unexpected response body %q", body)
+	}
+}
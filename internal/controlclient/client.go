@@ -7,8 +7,10 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"connectrpc.com/connect"
+	"github.com/buildkite/cleanroom/internal/connectzstd"
 	"github.com/buildkite/cleanroom/internal/endpoint"
 	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
 	"github.com/buildkite/cleanroom/internal/gen/cleanroom/v1/cleanroomv1connect"
@@ -16,18 +18,36 @@ import (
 	"golang.org/x/net/http2"
 )
 
+// Connection tuning. Each Client keeps its connections open between calls;
+// HTTP/2 pings detect a dead connection so the next call dials a fresh one
+// instead of hanging on it.
+const (
+	dialTimeout         = 10 * time.Second
+	tcpKeepAlive        = 30 * time.Second
+	tlsHandshakeTimeout = 10 * time.Second
+	idleConnTimeout     = 90 * time.Second
+	maxIdleConnsPerHost = 8
+	h2ReadIdleTimeout   = 30 * time.Second
+	h2PingTimeout       = 15 * time.Second
+)
+
 type Client struct {
 	httpClient      *http.Client
 	baseURL         string
+	retry           RetryPolicy
 	sandboxClient   cleanroomv1connect.SandboxServiceClient
 	executionClient cleanroomv1connect.ExecutionServiceClient
+	serverClient    cleanroomv1connect.ServerServiceClient
 }
 
 // Option configures the client.
 type Option func(*options)
 
 type options struct {
-	tlsOpts tlsconfig.Options
+	tlsOpts        tlsconfig.Options
+	retry          *RetryPolicy
+	headers        http.Header
+	contextHeaders map[string]func(context.Context) string
 }
 
 // WithTLS configures TLS options for the client.
@@ -37,6 +57,35 @@ func WithTLS(opts tlsconfig.Options) Option {
 	}
 }
 
+// WithRetryPolicy replaces DefaultRetryPolicy for read-only RPCs. A zero
+// policy disables retries.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = &policy
+	}
+}
+
+// WithHeader sends name: value with every call.
+func WithHeader(name, value string) Option {
+	return func(o *options) {
+		if o.headers == nil {
+			o.headers = http.Header{}
+		}
+		o.headers.Add(name, value)
+	}
+}
+
+// WithContextHeader sends name with every call, its value taken from the
+// call's context by value. Calls for which value returns "" omit it.
+func WithContextHeader(name string, value func(context.Context) string) Option {
+	return func(o *options) {
+		if o.contextHeaders == nil {
+			o.contextHeaders = map[string]func(context.Context) string{}
+		}
+		o.contextHeaders[name] = value
+	}
+}
+
 func New(ep endpoint.Endpoint, opts ...Option) (*Client, error) {
 	var o options
 	for _, opt := range opts {
@@ -48,17 +97,93 @@ func New(ep endpoint.Endpoint, opts ...Option) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
+	retry := DefaultRetryPolicy
+	if o.retry != nil {
+		retry = *o.retry
+	}
 	httpClient := &http.Client{Transport: transport}
+	clientOpts := connectzstd.ClientOptions()
+	if len(o.headers) > 0 {
+		clientOpts = append(clientOpts, connect.WithInterceptors(headerInterceptor(o.headers)))
+	}
+	if len(o.contextHeaders) > 0 {
+		clientOpts = append(clientOpts, connect.WithInterceptors(contextHeaderInterceptor(o.contextHeaders)))
+	}
 	return &Client{
 		httpClient:      httpClient,
 		baseURL:         baseURL,
-		sandboxClient:   cleanroomv1connect.NewSandboxServiceClient(httpClient, baseURL),
-		executionClient: cleanroomv1connect.NewExecutionServiceClient(httpClient, baseURL),
+		retry:           retry,
+		sandboxClient:   cleanroomv1connect.NewSandboxServiceClient(httpClient, baseURL, clientOpts...),
+		executionClient: cleanroomv1connect.NewExecutionServiceClient(httpClient, baseURL, clientOpts...),
+		serverClient:    cleanroomv1connect.NewServerServiceClient(httpClient, baseURL, clientOpts...),
 	}, nil
 }
 
+// CloseIdleConnections closes pooled connections that are not carrying a
+// request.
+func (c *Client) CloseIdleConnections() {
+	c.httpClient.CloseIdleConnections()
+}
+
+// headerInterceptor adds its headers to every request.
+type headerInterceptor http.Header
+
+func (h headerInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		for name, values := range h {
+			req.Header()[name] = append(req.Header()[name], values...)
+		}
+		return next(ctx, req)
+	}
+}
+
+func (h headerInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		conn := next(ctx, spec)
+		for name, values := range h {
+			conn.RequestHeader()[name] = append(conn.RequestHeader()[name], values...)
+		}
+		return conn
+	}
+}
+
+func (h headerInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+// contextHeaderInterceptor adds headers whose values come from each call's
+// context.
+type contextHeaderInterceptor map[string]func(context.Context) string
+
+func (h contextHeaderInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		for name, value := range h {
+			if v := value(ctx); v != "" {
+				req.Header().Set(name, v)
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+func (h contextHeaderInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		conn := next(ctx, spec)
+		for name, value := range h {
+			if v := value(ctx); v != "" {
+				conn.RequestHeader().Set(name, v)
+			}
+		}
+		return conn
+	}
+}
+
+func (h contextHeaderInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
 func buildTransport(ep endpoint.Endpoint, baseURL string, tlsOpts tlsconfig.Options) (http.RoundTripper, error) {
-	dialer := &net.Dialer{}
+	dialer := &net.Dialer{Timeout: dialTimeout, KeepAlive: tcpKeepAlive}
 
 	if ep.Scheme == "https" {
 		tlsCfg, err := tlsconfig.ResolveClient(tlsOpts)
@@ -68,16 +193,30 @@ func buildTransport(ep endpoint.Endpoint, baseURL string, tlsOpts tlsconfig.Opti
 		if tlsCfg == nil {
 			tlsCfg = &tls.Config{MinVersion: tls.VersionTLS13}
 		}
-		return &http.Transport{
-			Proxy:             http.ProxyFromEnvironment,
-			TLSClientConfig:   tlsCfg,
-			ForceAttemptHTTP2: true,
-		}, nil
+		transport := &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         dialer.DialContext,
+			TLSClientConfig:     tlsCfg,
+			TLSHandshakeTimeout: tlsHandshakeTimeout,
+			IdleConnTimeout:     idleConnTimeout,
+			MaxIdleConnsPerHost: maxIdleConnsPerHost,
+			ForceAttemptHTTP2:   true,
+		}
+		h2, err := http2.ConfigureTransports(transport)
+		if err != nil {
+			return nil, err
+		}
+		h2.ReadIdleTimeout = h2ReadIdleTimeout
+		h2.PingTimeout = h2PingTimeout
+		return transport, nil
 	}
 
 	if ep.Scheme == "unix" {
 		return &http2.Transport{
-			AllowHTTP: true,
+			AllowHTTP:       true,
+			ReadIdleTimeout: h2ReadIdleTimeout,
+			PingTimeout:     h2PingTimeout,
+			IdleConnTimeout: idleConnTimeout,
 			DialTLSContext: func(ctx context.Context, _, _ string, _ *tls.Config) (net.Conn, error) {
 				return dialer.DialContext(ctx, "unix", ep.Address)
 			},
@@ -90,7 +229,10 @@ func buildTransport(ep endpoint.Endpoint, baseURL string, tlsOpts tlsconfig.Opti
 	}
 	host := parsed.Host
 	return &http2.Transport{
-		AllowHTTP: true,
+		AllowHTTP:       true,
+		ReadIdleTimeout: h2ReadIdleTimeout,
+		PingTimeout:     h2PingTimeout,
+		IdleConnTimeout: idleConnTimeout,
 		DialTLSContext: func(ctx context.Context, _, _ string, _ *tls.Config) (net.Conn, error) {
 			return dialer.DialContext(ctx, "tcp", host)
 		},
@@ -105,24 +247,78 @@ func (c *Client) CreateSandbox(ctx context.Context, req *cleanroomv1.CreateSandb
 	return resp.Msg, nil
 }
 
-func (c *Client) GetSandbox(ctx context.Context, req *cleanroomv1.GetSandboxRequest) (*cleanroomv1.GetSandboxResponse, error) {
-	resp, err := c.sandboxClient.GetSandbox(ctx, connect.NewRequest(req))
+func (c *Client) CreateSandboxGroup(ctx context.Context, req *cleanroomv1.CreateSandboxGroupRequest) (*cleanroomv1.CreateSandboxGroupResponse, error) {
+	resp, err := c.sandboxClient.CreateSandboxGroup(ctx, connect.NewRequest(req))
 	if err != nil {
 		return nil, err
 	}
 	return resp.Msg, nil
 }
 
+func (c *Client) GetSandbox(ctx context.Context, req *cleanroomv1.GetSandboxRequest) (*cleanroomv1.GetSandboxResponse, error) {
+	return callWithRetry(ctx, c.retry, func(ctx context.Context) (*cleanroomv1.GetSandboxResponse, error) {
+		resp, err := c.sandboxClient.GetSandbox(ctx, connect.NewRequest(req))
+		if err != nil {
+			return nil, err
+		}
+		return resp.Msg, nil
+	})
+}
+
 func (c *Client) ListSandboxes(ctx context.Context, req *cleanroomv1.ListSandboxesRequest) (*cleanroomv1.ListSandboxesResponse, error) {
-	resp, err := c.sandboxClient.ListSandboxes(ctx, connect.NewRequest(req))
+	return callWithRetry(ctx, c.retry, func(ctx context.Context) (*cleanroomv1.ListSandboxesResponse, error) {
+		resp, err := c.sandboxClient.ListSandboxes(ctx, connect.NewRequest(req))
+		if err != nil {
+			return nil, err
+		}
+		return resp.Msg, nil
+	})
+}
+
+func (c *Client) DownloadSandboxFile(ctx context.Context, req *cleanroomv1.DownloadSandboxFileRequest) (*cleanroomv1.DownloadSandboxFileResponse, error) {
+	return callWithRetry(ctx, c.retry, func(ctx context.Context) (*cleanroomv1.DownloadSandboxFileResponse, error) {
+		resp, err := c.sandboxClient.DownloadSandboxFile(ctx, connect.NewRequest(req))
+		if err != nil {
+			return nil, err
+		}
+		return resp.Msg, nil
+	})
+}
+
+func (c *Client) CommitSandbox(ctx context.Context, req *cleanroomv1.CommitSandboxRequest) (*cleanroomv1.CommitSandboxResponse, error) {
+	resp, err := c.sandboxClient.CommitSandbox(ctx, connect.NewRequest(req))
 	if err != nil {
 		return nil, err
 	}
 	return resp.Msg, nil
 }
 
-func (c *Client) DownloadSandboxFile(ctx context.Context, req *cleanroomv1.DownloadSandboxFileRequest) (*cleanroomv1.DownloadSandboxFileResponse, error) {
-	resp, err := c.sandboxClient.DownloadSandboxFile(ctx, connect.NewRequest(req))
+func (c *Client) CloneSandbox(ctx context.Context, req *cleanroomv1.CloneSandboxRequest) (*cleanroomv1.CloneSandboxResponse, error) {
+	resp, err := c.sandboxClient.CloneSandbox(ctx, connect.NewRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Msg, nil
+}
+
+func (c *Client) UpgradeSandboxAgent(ctx context.Context, req *cleanroomv1.UpgradeSandboxAgentRequest) (*cleanroomv1.UpgradeSandboxAgentResponse, error) {
+	resp, err := c.sandboxClient.UpgradeSandboxAgent(ctx, connect.NewRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Msg, nil
+}
+
+func (c *Client) PauseSandbox(ctx context.Context, req *cleanroomv1.PauseSandboxRequest) (*cleanroomv1.PauseSandboxResponse, error) {
+	resp, err := c.sandboxClient.PauseSandbox(ctx, connect.NewRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Msg, nil
+}
+
+func (c *Client) ResumeSandbox(ctx context.Context, req *cleanroomv1.ResumeSandboxRequest) (*cleanroomv1.ResumeSandboxResponse, error) {
+	resp, err := c.sandboxClient.ResumeSandbox(ctx, connect.NewRequest(req))
 	if err != nil {
 		return nil, err
 	}
@@ -158,15 +354,25 @@ func (c *Client) OpenInteractiveExecution(ctx context.Context, req *cleanroomv1.
 }
 
 func (c *Client) GetExecution(ctx context.Context, req *cleanroomv1.GetExecutionRequest) (*cleanroomv1.GetExecutionResponse, error) {
-	resp, err := c.executionClient.GetExecution(ctx, connect.NewRequest(req))
+	return callWithRetry(ctx, c.retry, func(ctx context.Context) (*cleanroomv1.GetExecutionResponse, error) {
+		resp, err := c.executionClient.GetExecution(ctx, connect.NewRequest(req))
+		if err != nil {
+			return nil, err
+		}
+		return resp.Msg, nil
+	})
+}
+
+func (c *Client) CancelExecution(ctx context.Context, req *cleanroomv1.CancelExecutionRequest) (*cleanroomv1.CancelExecutionResponse, error) {
+	resp, err := c.executionClient.CancelExecution(ctx, connect.NewRequest(req))
 	if err != nil {
 		return nil, err
 	}
 	return resp.Msg, nil
 }
 
-func (c *Client) CancelExecution(ctx context.Context, req *cleanroomv1.CancelExecutionRequest) (*cleanroomv1.CancelExecutionResponse, error) {
-	resp, err := c.executionClient.CancelExecution(ctx, connect.NewRequest(req))
+func (c *Client) WriteExecutionStdin(ctx context.Context, req *cleanroomv1.WriteExecutionStdinRequest) (*cleanroomv1.WriteExecutionStdinResponse, error) {
+	resp, err := c.executionClient.WriteExecutionStdin(ctx, connect.NewRequest(req))
 	if err != nil {
 		return nil, err
 	}
@@ -176,3 +382,97 @@ func (c *Client) CancelExecution(ctx context.Context, req *cleanroomv1.CancelExe
 func (c *Client) StreamExecution(ctx context.Context, req *cleanroomv1.StreamExecutionRequest) (*connect.ServerStreamForClient[cleanroomv1.ExecutionStreamEvent], error) {
 	return c.executionClient.StreamExecution(ctx, connect.NewRequest(req))
 }
+
+func (c *Client) ListPendingApprovals(ctx context.Context, req *cleanroomv1.ListPendingApprovalsRequest) (*cleanroomv1.ListPendingApprovalsResponse, error) {
+	return callWithRetry(ctx, c.retry, func(ctx context.Context) (*cleanroomv1.ListPendingApprovalsResponse, error) {
+		resp, err := c.executionClient.ListPendingApprovals(ctx, connect.NewRequest(req))
+		if err != nil {
+			return nil, err
+		}
+		return resp.Msg, nil
+	})
+}
+
+func (c *Client) ResolveExecutionApproval(ctx context.Context, req *cleanroomv1.ResolveExecutionApprovalRequest) (*cleanroomv1.ResolveExecutionApprovalResponse, error) {
+	resp, err := c.executionClient.ResolveExecutionApproval(ctx, connect.NewRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Msg, nil
+}
+
+func (c *Client) AnnotateExecution(ctx context.Context, req *cleanroomv1.AnnotateExecutionRequest) (*cleanroomv1.AnnotateExecutionResponse, error) {
+	resp, err := c.executionClient.AnnotateExecution(ctx, connect.NewRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Msg, nil
+}
+
+func (c *Client) ListExecutions(ctx context.Context, req *cleanroomv1.ListExecutionsRequest) (*cleanroomv1.ListExecutionsResponse, error) {
+	return callWithRetry(ctx, c.retry, func(ctx context.Context) (*cleanroomv1.ListExecutionsResponse, error) {
+		resp, err := c.executionClient.ListExecutions(ctx, connect.NewRequest(req))
+		if err != nil {
+			return nil, err
+		}
+		return resp.Msg, nil
+	})
+}
+
+func (c *Client) GetUsage(ctx context.Context, req *cleanroomv1.GetUsageRequest) (*cleanroomv1.GetUsageResponse, error) {
+	return callWithRetry(ctx, c.retry, func(ctx context.Context) (*cleanroomv1.GetUsageResponse, error) {
+		resp, err := c.serverClient.GetUsage(ctx, connect.NewRequest(req))
+		if err != nil {
+			return nil, err
+		}
+		return resp.Msg, nil
+	})
+}
+
+// CreateSchedule is not retried, so a retry cannot create the schedule
+// twice.
+func (c *Client) CreateSchedule(ctx context.Context, req *cleanroomv1.CreateScheduleRequest) (*cleanroomv1.CreateScheduleResponse, error) {
+	resp, err := c.serverClient.CreateSchedule(ctx, connect.NewRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Msg, nil
+}
+
+func (c *Client) ListSchedules(ctx context.Context, req *cleanroomv1.ListSchedulesRequest) (*cleanroomv1.ListSchedulesResponse, error) {
+	return callWithRetry(ctx, c.retry, func(ctx context.Context) (*cleanroomv1.ListSchedulesResponse, error) {
+		resp, err := c.serverClient.ListSchedules(ctx, connect.NewRequest(req))
+		if err != nil {
+			return nil, err
+		}
+		return resp.Msg, nil
+	})
+}
+
+func (c *Client) GetSchedule(ctx context.Context, req *cleanroomv1.GetScheduleRequest) (*cleanroomv1.GetScheduleResponse, error) {
+	return callWithRetry(ctx, c.retry, func(ctx context.Context) (*cleanroomv1.GetScheduleResponse, error) {
+		resp, err := c.serverClient.GetSchedule(ctx, connect.NewRequest(req))
+		if err != nil {
+			return nil, err
+		}
+		return resp.Msg, nil
+	})
+}
+
+func (c *Client) DeleteSchedule(ctx context.Context, req *cleanroomv1.DeleteScheduleRequest) (*cleanroomv1.DeleteScheduleResponse, error) {
+	resp, err := c.serverClient.DeleteSchedule(ctx, connect.NewRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Msg, nil
+}
+
+// GetServerInfo is not retried, so a compatibility probe against an
+// unreachable server fails fast and leaves the error to the call after it.
+func (c *Client) GetServerInfo(ctx context.Context, req *cleanroomv1.GetServerInfoRequest) (*cleanroomv1.GetServerInfoResponse, error) {
+	resp, err := c.serverClient.GetServerInfo(ctx, connect.NewRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Msg, nil
+}
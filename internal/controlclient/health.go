@@ -0,0 +1,39 @@
+package controlclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HealthResponse is the server's answer on its health endpoint. The
+// headers carry the server's version, clock and view of the caller.
+type HealthResponse struct {
+	Header http.Header
+	// Sent and Received bracket the request on the local clock.
+	Sent     time.Time
+	Received time.Time
+}
+
+// Health asks the server whether it is up, over the same connection pool
+// as RPCs. It is not retried, so callers see the first failure.
+func (c *Client) Health(ctx context.Context) (*HealthResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/healthz", nil)
+	if err != nil {
+		return nil, err
+	}
+	sent := time.Now()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	received := time.Now()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("health check returned %s", resp.Status)
+	}
+	return &HealthResponse{Header: resp.Header, Sent: sent, Received: received}, nil
+}
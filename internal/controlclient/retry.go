@@ -0,0 +1,64 @@
+package controlclient
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// RetryPolicy controls how read-only RPCs are retried when the server
+// cannot be reached. RPCs that change state are never retried, since the
+// server may have applied a request whose response was lost.
+type RetryPolicy struct {
+	// MaxAttempts includes the first call. Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry. It doubles after
+	// each attempt up to MaxBackoff, and each wait is jittered by up to half.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy rides out a server restart or a dropped connection
+// without holding a caller up for more than a few seconds.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    4,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+}
+
+// IsRetriable reports whether err is a transient failure, such as the
+// server being unreachable or restarting, that may succeed if the same
+// call is made again. Other errors are terminal: retrying gives the same
+// answer.
+func IsRetriable(err error) bool {
+	return connect.CodeOf(err) == connect.CodeUnavailable
+}
+
+// callWithRetry runs call, retrying per policy while it fails with a
+// retriable error and ctx is live. The last error is returned as is.
+func callWithRetry[T any](ctx context.Context, policy RetryPolicy, call func(context.Context) (T, error)) (T, error) {
+	backoff := policy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		resp, err := call(ctx)
+		if err == nil || attempt >= policy.MaxAttempts || !IsRetriable(err) || ctx.Err() != nil {
+			return resp, err
+		}
+		timer := time.NewTimer(jitter(backoff))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, err
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, policy.MaxBackoff)
+	}
+}
+
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
@@ -0,0 +1,94 @@
+package controlclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+var testRetryPolicy = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+func TestCallWithRetryRetriesUnavailable(t *testing.T) {
+	calls := 0
+	got, err := callWithRetry(context.Background(), testRetryPolicy, func(context.Context) (string, error) {
+		calls++
+		if calls < 3 {
+			return "", connect.NewError(connect.CodeUnavailable, errors.New("connection refused"))
+		}
+		return "ok", nil
+	})
+	if err != nil {
+		t.Fatalf("callWithRetry returned error: %v", err)
+	}
+	if got != "ok" || calls != 3 {
+		t.Fatalf("got %q after %d calls, want ok after 3", got, calls)
+	}
+}
+
+func TestCallWithRetryStopsAfterMaxAttempts(t *testing.T) {
+	calls := 0
+	_, err := callWithRetry(context.Background(), testRetryPolicy, func(context.Context) (string, error) {
+		calls++
+		return "", connect.NewError(connect.CodeUnavailable, errors.New("connection refused"))
+	})
+	if !IsRetriable(err) {
+		t.Fatalf("expected the last retriable error, got %v", err)
+	}
+	if calls != testRetryPolicy.MaxAttempts {
+		t.Fatalf("got %d calls, want %d", calls, testRetryPolicy.MaxAttempts)
+	}
+}
+
+func TestCallWithRetryDoesNotRetryTerminalErrors(t *testing.T) {
+	calls := 0
+	_, err := callWithRetry(context.Background(), testRetryPolicy, func(context.Context) (string, error) {
+		calls++
+		return "", connect.NewError(connect.CodeNotFound, errors.New("unknown sandbox"))
+	})
+	if IsRetriable(err) || connect.CodeOf(err) != connect.CodeNotFound {
+		t.Fatalf("expected terminal not found error, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("got %d calls, want 1", calls)
+	}
+}
+
+func TestCallWithRetryStopsWhenContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+	calls := 0
+	done := make(chan error, 1)
+	go func() {
+		_, err := callWithRetry(ctx, policy, func(context.Context) (string, error) {
+			calls++
+			return "", connect.NewError(connect.CodeUnavailable, errors.New("connection refused"))
+		})
+		done <- err
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		if !IsRetriable(err) {
+			t.Fatalf("expected the last call's error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("callWithRetry kept waiting after its context was canceled")
+	}
+	if calls != 1 {
+		t.Fatalf("got %d calls, want 1", calls)
+	}
+}
+
+func TestZeroRetryPolicyMakesOneCall(t *testing.T) {
+	calls := 0
+	_, _ = callWithRetry(context.Background(), RetryPolicy{}, func(context.Context) (string, error) {
+		calls++
+		return "", connect.NewError(connect.CodeUnavailable, errors.New("connection refused"))
+	})
+	if calls != 1 {
+		t.Fatalf("got %d calls, want 1", calls)
+	}
+}
@@ -0,0 +1,111 @@
+package controlclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/buildkite/cleanroom/internal/blockdelta"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// SyncStats describes one SyncSandboxFile transfer.
+type SyncStats struct {
+	SizeBytes        int64 // bytes in the file
+	TransferredBytes int64 // bytes of data or delta the server sent
+	Delta            bool  // the server sent a delta against the local copy
+	// SHA256 is the hex digest the guest agent computed and the local copy
+	// was checked against. It is empty when the server sent none.
+	SHA256 string
+}
+
+// ErrChecksumMismatch is returned when downloaded data does not match the
+// SHA-256 the guest agent computed.
+var ErrChecksumMismatch = errors.New("downloaded file does not match its checksum")
+
+// SyncSandboxFile copies the sandbox file at path to localPath. When
+// localPath already holds a copy, the server is asked for a delta against
+// it so only changed blocks are sent. localPath is replaced once the new
+// copy is complete and matches the guest agent's checksum.
+func (c *Client) SyncSandboxFile(ctx context.Context, sandboxID, path, localPath string, maxBytes int64) (SyncStats, error) {
+	req := &cleanroomv1.DownloadSandboxFileRequest{SandboxId: sandboxID, Path: path, MaxBytes: maxBytes}
+	mode := fs.FileMode(0o644)
+	base, err := os.Open(localPath)
+	switch {
+	case err == nil:
+		defer base.Close()
+		info, err := base.Stat()
+		if err != nil {
+			return SyncStats{}, err
+		}
+		if !info.Mode().IsRegular() {
+			return SyncStats{}, fmt.Errorf("%s is not a regular file", localPath)
+		}
+		mode = info.Mode().Perm()
+		blockSize := blockdelta.BlockSize(info.Size())
+		sigs, err := blockdelta.Sign(bufio.NewReaderSize(base, 256*1024), blockSize)
+		if err != nil {
+			return SyncStats{}, fmt.Errorf("sign %s: %w", localPath, err)
+		}
+		req.DeltaBase = &cleanroomv1.FileDeltaBase{BlockSize: int64(blockSize), Signatures: sigs}
+	case !errors.Is(err, fs.ErrNotExist):
+		return SyncStats{}, err
+	}
+
+	resp, err := c.DownloadSandboxFile(ctx, req)
+	if err != nil {
+		return SyncStats{}, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(localPath), "."+filepath.Base(localPath)+".*")
+	if err != nil {
+		return SyncStats{}, err
+	}
+	defer os.Remove(tmp.Name())
+	stats := SyncStats{SizeBytes: resp.GetSizeBytes(), SHA256: resp.GetSha256()}
+	if delta := resp.GetDelta(); len(delta) > 0 && base != nil {
+		// Patch checks the result against the checksum in the delta.
+		stats.TransferredBytes, stats.Delta = int64(len(delta)), true
+		w := bufio.NewWriterSize(tmp, 256*1024)
+		if _, err = blockdelta.Patch(base, int(req.GetDeltaBase().GetBlockSize()), delta, w); err == nil {
+			err = w.Flush()
+		}
+	} else {
+		stats.TransferredBytes = int64(len(resp.GetData()))
+		err = checkSHA256(resp.GetData(), stats.SHA256)
+		if err == nil {
+			_, err = tmp.Write(resp.GetData())
+		}
+	}
+	if err == nil {
+		err = tmp.Chmod(mode)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return SyncStats{}, fmt.Errorf("write %s: %w", localPath, err)
+	}
+	if err := os.Rename(tmp.Name(), localPath); err != nil {
+		return SyncStats{}, err
+	}
+	return stats, nil
+}
+
+// checkSHA256 compares data with want, a hex digest. An empty want is not
+// checked.
+func checkSHA256(data []byte, want string) error {
+	if want == "" {
+		return nil
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != want {
+		return fmt.Errorf("%w: got sha256 %x, want %s", ErrChecksumMismatch, sum, want)
+	}
+	return nil
+}
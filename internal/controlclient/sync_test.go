@@ -0,0 +1,117 @@
+package controlclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/buildkite/cleanroom/internal/blockdelta"
+	"github.com/buildkite/cleanroom/internal/endpoint"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/gen/cleanroom/v1/cleanroomv1connect"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// fileServer serves file, as a delta whenever the request carries a base.
+// It reports sha256 as the file's checksum when set.
+type fileServer struct {
+	cleanroomv1connect.UnimplementedSandboxServiceHandler
+	file   []byte
+	sha256 string
+}
+
+func (s *fileServer) DownloadSandboxFile(_ context.Context, req *connect.Request[cleanroomv1.DownloadSandboxFileRequest]) (*connect.Response[cleanroomv1.DownloadSandboxFileResponse], error) {
+	resp := &cleanroomv1.DownloadSandboxFileResponse{SizeBytes: int64(len(s.file)), Sha256: s.sha256}
+	if base := req.Msg.GetDeltaBase(); base != nil {
+		var delta bytes.Buffer
+		if err := blockdelta.Diff(bytes.NewReader(s.file), int(base.GetBlockSize()), base.GetSignatures(), 0, &delta); err != nil {
+			return nil, err
+		}
+		resp.Delta = delta.Bytes()
+	} else {
+		resp.Data = s.file
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func newFileServerClient(t *testing.T, server *fileServer) *Client {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle(cleanroomv1connect.NewSandboxServiceHandler(server))
+	httpServer := httptest.NewServer(h2c.NewHandler(mux, &http2.Server{}))
+	t.Cleanup(httpServer.Close)
+	ep, err := endpoint.Resolve(httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := New(ep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestSyncSandboxFileSendsOnlyChangedBlocks(t *testing.T) {
+	t.Parallel()
+
+	server := &fileServer{file: bytes.Repeat([]byte("0123456789abcdef"), 64*1024)}
+	client := newFileServerClient(t, server)
+	local := filepath.Join(t.TempDir(), "app.bin")
+
+	stats, err := client.SyncSandboxFile(context.Background(), "sb-1", "/out/app.bin", local, 0)
+	if err != nil {
+		t.Fatalf("SyncSandboxFile returned error: %v", err)
+	}
+	if stats.Delta || stats.TransferredBytes != int64(len(server.file)) {
+		t.Fatalf("expected a full first download, got %+v", stats)
+	}
+
+	server.file = append(server.file, "appended"...)
+	stats, err = client.SyncSandboxFile(context.Background(), "sb-1", "/out/app.bin", local, 0)
+	if err != nil {
+		t.Fatalf("SyncSandboxFile returned error: %v", err)
+	}
+	if !stats.Delta || stats.TransferredBytes > 128 || stats.SizeBytes != int64(len(server.file)) {
+		t.Fatalf("expected a small delta, got %+v", stats)
+	}
+	got, err := os.ReadFile(local)
+	if err != nil || !bytes.Equal(got, server.file) {
+		t.Fatalf("local copy differs from the sandbox file: %v", err)
+	}
+}
+
+func TestSyncSandboxFileChecksData(t *testing.T) {
+	t.Parallel()
+
+	server := &fileServer{file: []byte("artifact")}
+	sum := sha256.Sum256(server.file)
+	server.sha256 = hex.EncodeToString(sum[:])
+	client := newFileServerClient(t, server)
+	local := filepath.Join(t.TempDir(), "app.bin")
+
+	stats, err := client.SyncSandboxFile(context.Background(), "sb-1", "/out/app.bin", local, 0)
+	if err != nil || stats.SHA256 != server.sha256 {
+		t.Fatalf("SyncSandboxFile = %+v, %v", stats, err)
+	}
+	if err := os.Remove(local); err != nil {
+		t.Fatal(err)
+	}
+
+	server.file = []byte("corrupted")
+	if _, err := client.SyncSandboxFile(context.Background(), "sb-1", "/out/app.bin", local, 0); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+	if _, err := os.Stat(local); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected no local file after a mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+package controlserver
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// AutoscalePath serves the service's AutoscaleStatus as JSON.
+const AutoscalePath = "/autoscale"
+
+func (s *Server) handleAutoscale(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if r.Method == http.MethodHead {
+		return
+	}
+	_ = json.NewEncoder(w).Encode(s.service.AutoscaleStatus())
+}
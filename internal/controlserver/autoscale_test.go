@@ -0,0 +1,35 @@
+package controlserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/controlservice"
+)
+
+func TestAutoscaleEndpoint(t *testing.T) {
+	t.Parallel()
+
+	handler := New(&controlservice.Service{}, nil).Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, AutoscalePath, nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response: %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var status controlservice.AutoscaleStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if status.SchemaVersion != controlservice.AutoscaleSchemaVersion || status.Capacity.VCPUs.Total <= 0 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, AutoscalePath, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected POST to be rejected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,106 @@
+package controlserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"connectrpc.com/connect"
+	"github.com/buildkite/cleanroom/internal/controlclient"
+	"github.com/buildkite/cleanroom/internal/controlservice"
+	"github.com/buildkite/cleanroom/internal/endpoint"
+	"github.com/buildkite/cleanroom/internal/tlsconfig"
+)
+
+// ForwardedHeader marks a call one serve instance forwarded to another,
+// naming the forwarding host. Forwarded calls are never forwarded again.
+const ForwardedHeader = "Cleanroom-Forwarded-By"
+
+// ForwardedForHeader carries the identity of the caller a forwarded call
+// was made for. The owner only honours it from peers that authenticated
+// with a client certificate (see withForwardedIdentity).
+const ForwardedForHeader = "Cleanroom-Forwarded-For"
+
+// peers caches a client per serve instance calls are forwarded to.
+type peers struct {
+	hostID  string
+	tlsOpts tlsconfig.Options
+
+	mu      sync.Mutex
+	clients map[string]*controlclient.Client
+}
+
+// ForwardToOwners forwards calls that need a sandbox's VM to the serve
+// instance running it, when the sandbox is not on this instance and the
+// service's shared state names another. Peers are dialed with tlsOpts,
+// whose certificate lets them act for the original caller.
+func (s *Server) ForwardToOwners(hostID string, tlsOpts tlsconfig.Options) {
+	s.peers = &peers{hostID: hostID, tlsOpts: tlsOpts, clients: map[string]*controlclient.Client{}}
+}
+
+func (p *peers) client(baseURL string) (*controlclient.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if client, ok := p.clients[baseURL]; ok {
+		return client, nil
+	}
+	ep, err := endpoint.Resolve(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	client, err := controlclient.New(ep,
+		controlclient.WithTLS(p.tlsOpts),
+		controlclient.WithHeader(ForwardedHeader, p.hostID),
+		controlclient.WithContextHeader(ForwardedForHeader, controlservice.CallerIdentity),
+	)
+	if err != nil {
+		return nil, err
+	}
+	p.clients[baseURL] = client
+	return client, nil
+}
+
+// owner returns a client for the instance running sandboxID when err says
+// this instance does not know the sandbox or execution, and shared state
+// says another instance runs it. It returns nil when the call should fail
+// here as it is.
+func (s *Server) owner(ctx context.Context, header http.Header, sandboxID string, err error) *controlclient.Client {
+	if err == nil || s.peers == nil || header.Get(ForwardedHeader) != "" {
+		return nil
+	}
+	if !errors.Is(err, controlservice.ErrUnknownSandbox) && !errors.Is(err, controlservice.ErrUnknownExecution) {
+		return nil
+	}
+	baseURL, ok := s.service.SandboxOwner(ctx, sandboxID)
+	if !ok {
+		return nil
+	}
+	client, err := s.peers.client(baseURL)
+	if err != nil {
+		if s.logger != nil {
+			s.logger.Warn("cannot forward call to sandbox owner", "sandbox_id", sandboxID, "owner", baseURL, "error", err)
+		}
+		return nil
+	}
+	return client
+}
+
+func forwardResponse[T any](resp *T, err error) (*connect.Response[T], error) {
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(resp), nil
+}
+
+// relayStream sends each message from upstream with send until upstream
+// ends, returning upstream's error.
+func relayStream[T any](upstream *connect.ServerStreamForClient[T], send func(*T) error) error {
+	defer upstream.Close()
+	for upstream.Receive() {
+		if err := send(upstream.Msg()); err != nil {
+			return err
+		}
+	}
+	return upstream.Err()
+}
@@ -0,0 +1,121 @@
+package controlserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/buildkite/cleanroom/internal/controlclient"
+	"github.com/buildkite/cleanroom/internal/controlservice"
+	"github.com/buildkite/cleanroom/internal/endpoint"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/gen/cleanroom/v1/cleanroomv1connect"
+	"github.com/buildkite/cleanroom/internal/sharedstate"
+	"github.com/buildkite/cleanroom/internal/tlsconfig"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// ownerOnly is a SharedState in which host-a runs one sandbox.
+type ownerOnly struct {
+	sandbox sharedstate.SandboxRecord
+}
+
+func (o ownerOnly) HostID() string                      { return "host-b" }
+func (o ownerOnly) PutSandbox(*cleanroomv1.Sandbox)     {}
+func (o ownerOnly) PutExecution(*cleanroomv1.Execution) {}
+func (o ownerOnly) DeleteSandbox(string)                {}
+func (o ownerOnly) DeleteExecution(string, string)      {}
+func (o ownerOnly) Sandboxes(context.Context) ([]sharedstate.SandboxRecord, error) {
+	return []sharedstate.SandboxRecord{o.sandbox}, nil
+}
+
+func (o ownerOnly) Sandbox(_ context.Context, sandboxID string) (sharedstate.SandboxRecord, error) {
+	if sandboxID != o.sandbox.Sandbox.GetSandboxId() {
+		return sharedstate.SandboxRecord{}, sharedstate.ErrNotFound
+	}
+	return o.sandbox, nil
+}
+
+func (o ownerOnly) Execution(context.Context, string, string) (sharedstate.ExecutionRecord, error) {
+	return sharedstate.ExecutionRecord{}, sharedstate.ErrNotFound
+}
+
+// pausingOwner answers PauseSandbox as the instance running the sandbox.
+type pausingOwner struct {
+	cleanroomv1connect.UnimplementedSandboxServiceHandler
+	mu        sync.Mutex
+	forwarded []string
+}
+
+func (p *pausingOwner) PauseSandbox(_ context.Context, req *connect.Request[cleanroomv1.PauseSandboxRequest]) (*connect.Response[cleanroomv1.PauseSandboxResponse], error) {
+	p.mu.Lock()
+	p.forwarded = append(p.forwarded, req.Header().Get(ForwardedHeader)+" for "+req.Header().Get(ForwardedForHeader))
+	p.mu.Unlock()
+	return connect.NewResponse(&cleanroomv1.PauseSandboxResponse{Sandbox: &cleanroomv1.Sandbox{
+		SandboxId: req.Msg.GetSandboxId(),
+		Status:    cleanroomv1.SandboxStatus_SANDBOX_STATUS_PAUSED,
+	}}), nil
+}
+
+func TestVMCallsAreForwardedToTheSandboxOwner(t *testing.T) {
+	t.Parallel()
+
+	owner := &pausingOwner{}
+	mux := http.NewServeMux()
+	mux.Handle(cleanroomv1connect.NewSandboxServiceHandler(owner))
+	ownerServer := httptest.NewServer(h2c.NewHandler(mux, &http2.Server{}))
+	defer ownerServer.Close()
+
+	service := &controlservice.Service{Shared: ownerOnly{sandbox: sharedstate.SandboxRecord{
+		Host:    sharedstate.Host{ID: "host-a", URL: ownerServer.URL},
+		Sandbox: &cleanroomv1.Sandbox{SandboxId: "sb-1"},
+	}}}
+	front := New(service, nil)
+	front.ForwardToOwners("host-b", tlsconfig.Options{})
+	frontServer := httptest.NewUnstartedServer(front.Handler())
+	frontServer.Config.ConnContext = withConnIdentity
+	frontServer.Start()
+	defer frontServer.Close()
+
+	ep, err := endpoint.Resolve(frontServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := controlclient.New(ep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	resp, err := client.PauseSandbox(ctx, &cleanroomv1.PauseSandboxRequest{SandboxId: "sb-1"})
+	if err != nil {
+		t.Fatalf("PauseSandbox returned error: %v", err)
+	}
+	if resp.GetSandbox().GetStatus() != cleanroomv1.SandboxStatus_SANDBOX_STATUS_PAUSED {
+		t.Fatalf("expected the owner's response, got %v", resp)
+	}
+	if _, err := client.PauseSandbox(ctx, &cleanroomv1.PauseSandboxRequest{SandboxId: "sb-2"}); connect.CodeOf(err) != connect.CodeNotFound {
+		t.Fatalf("expected a sandbox no instance runs to be not found, got %v", err)
+	}
+
+	// A call already forwarded once fails where it lands rather than
+	// bouncing between instances.
+	forwarded, err := controlclient.New(ep, controlclient.WithHeader(ForwardedHeader, "host-c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := forwarded.PauseSandbox(ctx, &cleanroomv1.PauseSandboxRequest{SandboxId: "sb-1"}); connect.CodeOf(err) != connect.CodeNotFound {
+		t.Fatalf("expected a forwarded call not to be forwarded again, got %v", err)
+	}
+
+	owner.mu.Lock()
+	defer owner.mu.Unlock()
+	if got := fmt.Sprint(owner.forwarded); got != "[host-b for ip:127.0.0.1]" {
+		t.Fatalf("expected one call forwarded by host-b for the original caller, got %s", got)
+	}
+}
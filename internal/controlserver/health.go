@@ -0,0 +1,37 @@
+package controlserver
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/controlservice"
+)
+
+// HealthPath answers "ok" while the server is up. Its response headers
+// carry what cleanroom ping reports about the server.
+const HealthPath = "/healthz"
+
+const (
+	// ServerVersionHeader is the server's cleanroom release.
+	ServerVersionHeader = "Cleanroom-Server-Version"
+	// ServerTimeHeader is the server's clock in RFC 3339 with nanoseconds,
+	// finer than the Date header, for measuring clock skew.
+	ServerTimeHeader = "Cleanroom-Server-Time"
+	// CallerIdentityHeader is who the server believes made the request,
+	// such as "uid:1000" or "ip:100.64.0.7".
+	CallerIdentityHeader = "Cleanroom-Caller-Identity"
+)
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if version := s.service.Version; version != "" {
+		w.Header().Set(ServerVersionHeader, version)
+	}
+	if identity := controlservice.CallerIdentity(r.Context()); identity != "" {
+		w.Header().Set(CallerIdentityHeader, identity)
+	}
+	w.Header().Set(ServerTimeHeader, time.Now().UTC().Format(time.RFC3339Nano))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.WriteString(w, "ok\n")
+}
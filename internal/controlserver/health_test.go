@@ -0,0 +1,34 @@
+package controlserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/controlservice"
+)
+
+func TestHealthEndpointReportsVersionIdentityAndTime(t *testing.T) {
+	t.Parallel()
+
+	handler := New(&controlservice.Service{Version: "v1.2.3"}, nil).Handler()
+	req := httptest.NewRequest(http.MethodGet, HealthPath, nil)
+	req = req.WithContext(controlservice.WithCallerIdentity(req.Context(), "uid:1000"))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok\n" {
+		t.Fatalf("unexpected response: %d %q", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get(ServerVersionHeader); got != "v1.2.3" {
+		t.Fatalf("unexpected version header %q", got)
+	}
+	if got := rec.Header().Get(CallerIdentityHeader); got != "uid:1000" {
+		t.Fatalf("unexpected identity header %q", got)
+	}
+	serverTime, err := time.Parse(time.RFC3339Nano, rec.Header().Get(ServerTimeHeader))
+	if err != nil || time.Since(serverTime) > time.Minute {
+		t.Fatalf("unexpected server time %q: %v", rec.Header().Get(ServerTimeHeader), err)
+	}
+}
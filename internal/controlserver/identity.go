@@ -0,0 +1,52 @@
+package controlserver
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/buildkite/cleanroom/internal/controlservice"
+)
+
+// withConnIdentity tags requests on conn with the caller's identity: the
+// peer uid ("uid:1000") for unix sockets, or the peer address
+// ("ip:100.64.0.7") for TCP.
+func withConnIdentity(ctx context.Context, conn net.Conn) context.Context {
+	if identity := connIdentity(conn); identity != "" {
+		return controlservice.WithCallerIdentity(ctx, identity)
+	}
+	return ctx
+}
+
+func connIdentity(conn net.Conn) string {
+	if unixConn, ok := conn.(*net.UnixConn); ok {
+		uid, ok := peerUID(unixConn)
+		if !ok {
+			return ""
+		}
+		return "uid:" + strconv.FormatUint(uint64(uid), 10)
+	}
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil || host == "" {
+		return ""
+	}
+	return "ip:" + host
+}
+
+// withForwardedIdentity makes a call forwarded by another serve instance
+// run as the caller it was forwarded for. The header is only honoured on
+// connections whose client certificate chained to the peer CA; anyone else
+// keeps the identity of their connection.
+func withForwardedIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if identity := r.Header.Get(ForwardedForHeader); identity != "" && isAuthenticatedPeer(r) {
+			r = r.WithContext(controlservice.WithCallerIdentity(r.Context(), identity))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isAuthenticatedPeer(r *http.Request) bool {
+	return r.TLS != nil && len(r.TLS.VerifiedChains) > 0
+}
@@ -0,0 +1,104 @@
+package controlserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/controlservice"
+)
+
+func TestConnIdentityUsesUnixPeerUID(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("peer credentials are only read on linux and darwin")
+	}
+	socket := filepath.Join(t.TempDir(), "s.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn
+	}()
+	client, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+	server, ok := <-accepted
+	if !ok {
+		t.Fatal("accept failed")
+	}
+	defer server.Close()
+
+	if got, want := connIdentity(server), "uid:"+strconv.Itoa(os.Getuid()); got != want {
+		t.Fatalf("unexpected identity: got %q want %q", got, want)
+	}
+}
+
+func TestConnIdentityUsesTCPPeerAddress(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err == nil {
+			defer conn.Close()
+		}
+	}()
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	defer server.Close()
+
+	if got, want := connIdentity(server), "ip:127.0.0.1"; got != want {
+		t.Fatalf("unexpected identity: got %q want %q", got, want)
+	}
+}
+
+func TestForwardedIdentityIsOnlyHonouredFromAuthenticatedPeers(t *testing.T) {
+	t.Parallel()
+
+	var got string
+	handler := withForwardedIdentity(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = controlservice.CallerIdentity(r.Context())
+	}))
+	call := func(state *tls.ConnectionState) string {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req = req.WithContext(controlservice.WithCallerIdentity(req.Context(), "ip:10.0.0.2"))
+		req.Header.Set(ForwardedForHeader, "uid:1001")
+		req.TLS = state
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		return got
+	}
+
+	if identity := call(nil); identity != "ip:10.0.0.2" {
+		t.Fatalf("plaintext caller claimed identity %q", identity)
+	}
+	if identity := call(&tls.ConnectionState{}); identity != "ip:10.0.0.2" {
+		t.Fatalf("caller without a client certificate claimed identity %q", identity)
+	}
+	peer := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+	if identity := call(peer); identity != "uid:1001" {
+		t.Fatalf("expected an authenticated peer to forward for uid:1001, got %q", identity)
+	}
+}
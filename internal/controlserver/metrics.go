@@ -0,0 +1,95 @@
+package controlserver
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/controlservice"
+)
+
+// MetricsPath serves sandbox, execution and VM metrics in the Prometheus
+// text exposition format.
+const MetricsPath = "/metrics"
+
+// vmCounters are the per-sandbox VM counters, in exposition order.
+var vmCounters = []struct {
+	name  string
+	help  string
+	value func(backend.VMStats) int64
+}{
+	{"cleanroom_vm_net_rx_bytes_total", "Bytes received by the guest's network interfaces.", func(s backend.VMStats) int64 { return s.NetRxBytes }},
+	{"cleanroom_vm_net_tx_bytes_total", "Bytes sent by the guest's network interfaces.", func(s backend.VMStats) int64 { return s.NetTxBytes }},
+	{"cleanroom_vm_net_rx_packets_total", "Packets received by the guest's network interfaces.", func(s backend.VMStats) int64 { return s.NetRxPackets }},
+	{"cleanroom_vm_net_tx_packets_total", "Packets sent by the guest's network interfaces.", func(s backend.VMStats) int64 { return s.NetTxPackets }},
+	{"cleanroom_vm_block_read_bytes_total", "Bytes read from the guest's block devices.", func(s backend.VMStats) int64 { return s.BlockReadBytes }},
+	{"cleanroom_vm_block_write_bytes_total", "Bytes written to the guest's block devices.", func(s backend.VMStats) int64 { return s.BlockWriteBytes }},
+	{"cleanroom_vm_block_reads_total", "Read requests to the guest's block devices.", func(s backend.VMStats) int64 { return s.BlockReads }},
+	{"cleanroom_vm_block_writes_total", "Write requests to the guest's block devices.", func(s backend.VMStats) int64 { return s.BlockWrites }},
+	{"cleanroom_vm_vcpu_exits_total", "vCPU exits to the VMM.", func(s backend.VMStats) int64 { return s.VCPUExits }},
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if r.Method == http.MethodHead {
+		return
+	}
+	out := bufio.NewWriter(w)
+	defer out.Flush()
+	writeMetrics(out, s.service.AutoscaleStatus(), s.service.SandboxVMStats(r.Context()), s.service.StaleSocketsRemoved())
+}
+
+func writeMetrics(w *bufio.Writer, status controlservice.AutoscaleStatus, vmStats []controlservice.SandboxVMStats, staleSockets int64) {
+	gauge := func(name, help string, samples ...string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		for _, sample := range samples {
+			fmt.Fprintf(w, "%s%s\n", name, sample)
+		}
+	}
+	labelled := func(label, value string, v int64) string {
+		return fmt.Sprintf("{%s=%s} %d", label, labelValue(value), v)
+	}
+	gauge("cleanroom_sandboxes", "Sandboxes by status.",
+		labelled("status", "provisioning", int64(status.Sandboxes.Provisioning)),
+		labelled("status", "ready", int64(status.Sandboxes.Ready)),
+		labelled("status", "paused", int64(status.Sandboxes.Paused)),
+		labelled("status", "stopping", int64(status.Sandboxes.Stopping)),
+	)
+	gauge("cleanroom_sandboxes_idle", "Ready sandboxes with nothing running.", fmt.Sprintf(" %d", status.Sandboxes.Idle))
+	gauge("cleanroom_executions", "Unfinished executions by status.",
+		labelled("status", "queued", int64(status.Executions.Queued)),
+		labelled("status", "pending_approval", int64(status.Executions.PendingApproval)),
+		labelled("status", "running", int64(status.Executions.Running)),
+	)
+	gauge("cleanroom_vcpus", "Host vCPUs by allocation.",
+		labelled("state", "total", status.Capacity.VCPUs.Total),
+		labelled("state", "allocated", status.Capacity.VCPUs.Allocated),
+	)
+	gauge("cleanroom_memory_mib", "Host memory in MiB by allocation.",
+		labelled("state", "total", status.Capacity.MemoryMiB.Total),
+		labelled("state", "allocated", status.Capacity.MemoryMiB.Allocated),
+	)
+	fmt.Fprintf(w, "# HELP cleanroom_stale_sockets_removed_total Sockets left by dead VM processes that run directory sweeps removed.\n# TYPE cleanroom_stale_sockets_removed_total counter\ncleanroom_stale_sockets_removed_total %d\n", staleSockets)
+
+	for _, counter := range vmCounters {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", counter.name, counter.help, counter.name)
+		for _, sb := range vmStats {
+			fmt.Fprintf(w, "%s{sandbox_id=%s,backend=%s} %d\n", counter.name, labelValue(sb.SandboxID), labelValue(sb.Backend), counter.value(sb.Stats))
+		}
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labelValue quotes a label value as the exposition format expects.
+func labelValue(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
@@ -0,0 +1,56 @@
+package controlserver
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/controlservice"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	t.Parallel()
+
+	handler := New(&controlservice.Service{}, nil).Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, MetricsPath, nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("unexpected response: %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `cleanroom_sandboxes{status="ready"} 0`) || !strings.Contains(body, "# TYPE cleanroom_vm_vcpu_exits_total counter") {
+		t.Fatalf("unexpected body:\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, MetricsPath, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected POST to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestWriteMetricsLabelsVMCounters(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+	w := bufio.NewWriter(&b)
+	writeMetrics(w, controlservice.AutoscaleStatus{}, []controlservice.SandboxVMStats{{
+		SandboxID: "cr-1",
+		Backend:   "firecracker",
+		Stats:     backend.VMStats{NetRxBytes: 1024, VCPUExits: 7},
+	}}, 3)
+	_ = w.Flush()
+
+	for _, want := range []string{
+		`cleanroom_vm_net_rx_bytes_total{sandbox_id="cr-1",backend="firecracker"} 1024`,
+		`cleanroom_vm_vcpu_exits_total{sandbox_id="cr-1",backend="firecracker"} 7`,
+		`cleanroom_stale_sockets_removed_total 3`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Fatalf("missing %q in:\n%s", want, b.String())
+		}
+	}
+}
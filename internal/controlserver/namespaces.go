@@ -0,0 +1,69 @@
+package controlserver
+
+import (
+	"context"
+
+	"connectrpc.com/connect"
+	"github.com/buildkite/cleanroom/internal/controlservice"
+)
+
+// sandboxRequest is a request naming the sandbox it acts on.
+type sandboxRequest interface {
+	GetSandboxId() string
+}
+
+// namespaceInterceptor turns away calls naming a sandbox in a namespace
+// the caller cannot access, before any handler runs.
+type namespaceInterceptor struct {
+	service *controlservice.Service
+}
+
+func (i namespaceInterceptor) check(ctx context.Context, msg any) error {
+	req, ok := msg.(sandboxRequest)
+	if !ok {
+		return nil
+	}
+	return toConnectError(i.service.CheckSandboxAccess(ctx, req.GetSandboxId()))
+}
+
+func (i namespaceInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if err := i.check(ctx, req.Any()); err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+func (i namespaceInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i namespaceInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		return next(ctx, &namespaceConn{StreamingHandlerConn: conn, ctx: ctx, check: i.check})
+	}
+}
+
+// namespaceConn checks each message a stream receives, once per sandbox.
+type namespaceConn struct {
+	connect.StreamingHandlerConn
+	ctx     context.Context
+	check   func(context.Context, any) error
+	checked string
+}
+
+func (c *namespaceConn) Receive(msg any) error {
+	if err := c.StreamingHandlerConn.Receive(msg); err != nil {
+		return err
+	}
+	req, ok := msg.(sandboxRequest)
+	if !ok || req.GetSandboxId() == c.checked {
+		return nil
+	}
+	if err := c.check(c.ctx, msg); err != nil {
+		return err
+	}
+	c.checked = req.GetSandboxId()
+	return nil
+}
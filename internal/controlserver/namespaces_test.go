@@ -0,0 +1,53 @@
+package controlserver
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/buildkite/cleanroom/internal/controlclient"
+	"github.com/buildkite/cleanroom/internal/controlservice"
+	"github.com/buildkite/cleanroom/internal/endpoint"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+	"github.com/buildkite/cleanroom/internal/sharedstate"
+)
+
+func TestSandboxesInOtherNamespacesAreNotFound(t *testing.T) {
+	t.Parallel()
+
+	service := &controlservice.Service{Shared: ownerOnly{sandbox: sharedstate.SandboxRecord{
+		Host:    sharedstate.Host{ID: "host-a"},
+		Sandbox: &cleanroomv1.Sandbox{SandboxId: "sb-1", Namespace: "team-a"},
+	}}}
+	service.Config.Namespaces = runtimeconfig.Namespaces{Identities: map[string]string{"ip:127.0.0.1": "team-b"}}
+	server := httptest.NewServer(New(service, nil).Handler())
+	defer server.Close()
+
+	ep, err := endpoint.Resolve(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := controlclient.New(ep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := client.GetSandbox(ctx, &cleanroomv1.GetSandboxRequest{SandboxId: "sb-1"}); connect.CodeOf(err) != connect.CodeNotFound {
+		t.Fatalf("expected team-a's sandbox to be not found, got %v", err)
+	}
+	stream, err := client.StreamSandboxEvents(ctx, &cleanroomv1.StreamSandboxEventsRequest{SandboxId: "sb-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for stream.Receive() {
+	}
+	if connect.CodeOf(stream.Err()) != connect.CodeNotFound {
+		t.Fatalf("expected team-a's sandbox events to be not found, got %v", stream.Err())
+	}
+	if _, err := client.ListSandboxes(ctx, &cleanroomv1.ListSandboxesRequest{Namespace: "team-a"}); connect.CodeOf(err) != connect.CodePermissionDenied {
+		t.Fatalf("expected listing another namespace to be denied, got %v", err)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package controlserver
+
+import "net"
+
+func peerUID(*net.UnixConn) (uint32, bool) {
+	return 0, false
+}
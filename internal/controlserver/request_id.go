@@ -0,0 +1,27 @@
+package controlserver
+
+import (
+	"net/http"
+
+	"github.com/buildkite/cleanroom/internal/controlservice"
+	"github.com/buildkite/cleanroom/internal/logging"
+)
+
+// withRequestID tags each request with an ID, keeping a usable one the
+// client sent in X-Request-Id, and echoes it in the response. Everything
+// logged on the request's behalf, including by backends, carries it as
+// request_id.
+func (s *Server) withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := logging.SanitizeRequestID(r.Header.Get(logging.RequestIDHeader))
+		if id == "" {
+			id = logging.NewRequestID()
+		}
+		w.Header().Set(logging.RequestIDHeader, id)
+		ctx := logging.WithRequestID(r.Context(), id)
+		if logger := logging.Logger(ctx, s.logger); logger != nil {
+			logger.Debug("request", "method", r.Method, "path", r.URL.Path, "caller", controlservice.CallerIdentity(ctx))
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,44 @@
+package controlserver
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/logging"
+	"github.com/charmbracelet/log"
+)
+
+func TestWithRequestIDKeepsClientIDAndTagsLogs(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := log.NewWithOptions(&buf, log.Options{Level: log.DebugLevel, Formatter: log.JSONFormatter})
+	s := &Server{logger: logger}
+
+	var seen string
+	handler := s.withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logging.RequestID(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/cleanroom.v1.SandboxService/CreateSandbox", nil)
+	req.Header.Set(logging.RequestIDHeader, "ci-build-42")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if seen != "ci-build-42" || rec.Header().Get(logging.RequestIDHeader) != "ci-build-42" {
+		t.Fatalf("expected client request ID to be kept, handler saw %q, response header %q", seen, rec.Header().Get(logging.RequestIDHeader))
+	}
+	if !strings.Contains(buf.String(), `"request_id":"ci-build-42"`) {
+		t.Fatalf("expected request log line to carry request_id, got %q", buf.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set(logging.RequestIDHeader, "bad id\nwith newline")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if !strings.HasPrefix(seen, "req-") || rec.Header().Get(logging.RequestIDHeader) != seen {
+		t.Fatalf("expected a generated request ID for an unusable client ID, handler saw %q, response header %q", seen, rec.Header().Get(logging.RequestIDHeader))
+	}
+}
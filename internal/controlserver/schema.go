@@ -0,0 +1,18 @@
+package controlserver
+
+import (
+	"net/http"
+
+	"github.com/buildkite/cleanroom/internal/policy"
+)
+
+// PolicySchemaPath serves the JSON Schema for policy files, versioned with
+// the policy format, so editors and CI can fetch the schema matching the
+// server they run against.
+const PolicySchemaPath = "/schemas/policy/v1.json"
+
+func (s *Server) handlePolicySchema(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(policy.Schema())
+}
@@ -0,0 +1,28 @@
+package controlserver
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/controlservice"
+	"github.com/buildkite/cleanroom/internal/policy"
+)
+
+func TestPolicySchemaEndpointServesEmbeddedSchema(t *testing.T) {
+	t.Parallel()
+
+	handler := New(&controlservice.Service{}, nil).Handler()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PolicySchemaPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/schema+json" {
+		t.Fatalf("unexpected content type %q", got)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), policy.Schema()) {
+		t.Fatal("expected the embedded policy schema")
+	}
+}
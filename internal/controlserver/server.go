@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
 	"net"
 	"net/http"
 	"os"
@@ -15,6 +14,7 @@ import (
 	"crypto/tls"
 
 	"connectrpc.com/connect"
+	"github.com/buildkite/cleanroom/internal/connectzstd"
 	"github.com/buildkite/cleanroom/internal/controlservice"
 	"github.com/buildkite/cleanroom/internal/endpoint"
 	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
@@ -29,11 +29,15 @@ import (
 type TLSOptions struct {
 	CertPath string
 	KeyPath  string
+	// PeerCAPath, when set, lets other serve instances present a client
+	// certificate signed by it, and forward calls for their callers.
+	PeerCAPath string
 }
 
 type Server struct {
 	service *controlservice.Service
 	logger  *log.Logger
+	peers   *peers
 }
 
 func New(service *controlservice.Service, logger *log.Logger) *Server {
@@ -43,20 +47,38 @@ func New(service *controlservice.Service, logger *log.Logger) *Server {
 func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
 
-	sandboxPath, sandboxHandler := cleanroomv1connect.NewSandboxServiceHandler(s)
-	executionPath, executionHandler := cleanroomv1connect.NewExecutionServiceHandler(s)
+	compression := connectzstd.HandlerOptions()
+	opts := append([]connect.HandlerOption{connect.WithInterceptors(namespaceInterceptor{service: s.service})}, compression...)
+	sandboxPath, sandboxHandler := cleanroomv1connect.NewSandboxServiceHandler(s, opts...)
+	executionPath, executionHandler := cleanroomv1connect.NewExecutionServiceHandler(s, opts...)
+	serverPath, serverHandler := cleanroomv1connect.NewServerServiceHandler(s, compression...)
 	mux.Handle(sandboxPath, sandboxHandler)
 	mux.Handle(executionPath, executionHandler)
+	mux.Handle(serverPath, serverHandler)
 
-	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		_, _ = io.WriteString(w, "ok\n")
-	})
-	return h2c.NewHandler(mux, &http2.Server{})
+	mux.HandleFunc(HealthPath, s.handleHealth)
+	mux.HandleFunc(AutoscalePath, s.handleAutoscale)
+	mux.HandleFunc(MetricsPath, s.handleMetrics)
+	mux.HandleFunc(PolicySchemaPath, s.handlePolicySchema)
+	return h2c.NewHandler(withForwardedIdentity(s.withRequestID(mux)), &http2.Server{})
 }
 
 func (s *Server) CreateSandbox(ctx context.Context, req *connect.Request[cleanroomv1.CreateSandboxRequest]) (*connect.Response[cleanroomv1.CreateSandboxResponse], error) {
 	resp, err := s.service.CreateSandbox(ctx, req.Msg)
+	if errors.Is(err, controlservice.ErrSandboxNameTaken) {
+		return nil, connect.NewError(connect.CodeAlreadyExists, err)
+	}
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (s *Server) CreateSandboxGroup(ctx context.Context, req *connect.Request[cleanroomv1.CreateSandboxGroupRequest]) (*connect.Response[cleanroomv1.CreateSandboxGroupResponse], error) {
+	resp, err := s.service.CreateSandboxGroup(ctx, req.Msg)
+	if errors.Is(err, controlservice.ErrSandboxNameTaken) {
+		return nil, connect.NewError(connect.CodeAlreadyExists, err)
+	}
 	if err != nil {
 		return nil, toConnectError(err)
 	}
@@ -81,6 +103,64 @@ func (s *Server) ListSandboxes(ctx context.Context, req *connect.Request[cleanro
 
 func (s *Server) DownloadSandboxFile(ctx context.Context, req *connect.Request[cleanroomv1.DownloadSandboxFileRequest]) (*connect.Response[cleanroomv1.DownloadSandboxFileResponse], error) {
 	resp, err := s.service.DownloadSandboxFile(ctx, req.Msg)
+	if owner := s.owner(ctx, req.Header(), req.Msg.GetSandboxId(), err); owner != nil {
+		resp, err = owner.DownloadSandboxFile(ctx, req.Msg)
+	}
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (s *Server) CommitSandbox(ctx context.Context, req *connect.Request[cleanroomv1.CommitSandboxRequest]) (*connect.Response[cleanroomv1.CommitSandboxResponse], error) {
+	resp, err := s.service.CommitSandbox(ctx, req.Msg)
+	if owner := s.owner(ctx, req.Header(), req.Msg.GetSandboxId(), err); owner != nil {
+		resp, err = owner.CommitSandbox(ctx, req.Msg)
+	}
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (s *Server) CloneSandbox(ctx context.Context, req *connect.Request[cleanroomv1.CloneSandboxRequest]) (*connect.Response[cleanroomv1.CloneSandboxResponse], error) {
+	resp, err := s.service.CloneSandbox(ctx, req.Msg)
+	if owner := s.owner(ctx, req.Header(), req.Msg.GetSandboxId(), err); owner != nil {
+		resp, err = owner.CloneSandbox(ctx, req.Msg)
+	}
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (s *Server) UpgradeSandboxAgent(ctx context.Context, req *connect.Request[cleanroomv1.UpgradeSandboxAgentRequest]) (*connect.Response[cleanroomv1.UpgradeSandboxAgentResponse], error) {
+	resp, err := s.service.UpgradeSandboxAgent(ctx, req.Msg)
+	if owner := s.owner(ctx, req.Header(), req.Msg.GetSandboxId(), err); owner != nil {
+		resp, err = owner.UpgradeSandboxAgent(ctx, req.Msg)
+	}
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (s *Server) PauseSandbox(ctx context.Context, req *connect.Request[cleanroomv1.PauseSandboxRequest]) (*connect.Response[cleanroomv1.PauseSandboxResponse], error) {
+	resp, err := s.service.PauseSandbox(ctx, req.Msg)
+	if owner := s.owner(ctx, req.Header(), req.Msg.GetSandboxId(), err); owner != nil {
+		resp, err = owner.PauseSandbox(ctx, req.Msg)
+	}
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (s *Server) ResumeSandbox(ctx context.Context, req *connect.Request[cleanroomv1.ResumeSandboxRequest]) (*connect.Response[cleanroomv1.ResumeSandboxResponse], error) {
+	resp, err := s.service.ResumeSandbox(ctx, req.Msg)
+	if owner := s.owner(ctx, req.Header(), req.Msg.GetSandboxId(), err); owner != nil {
+		resp, err = owner.ResumeSandbox(ctx, req.Msg)
+	}
 	if err != nil {
 		return nil, toConnectError(err)
 	}
@@ -89,6 +169,9 @@ func (s *Server) DownloadSandboxFile(ctx context.Context, req *connect.Request[c
 
 func (s *Server) TerminateSandbox(ctx context.Context, req *connect.Request[cleanroomv1.TerminateSandboxRequest]) (*connect.Response[cleanroomv1.TerminateSandboxResponse], error) {
 	resp, err := s.service.TerminateSandbox(ctx, req.Msg)
+	if owner := s.owner(ctx, req.Header(), req.Msg.GetSandboxId(), err); owner != nil {
+		resp, err = owner.TerminateSandbox(ctx, req.Msg)
+	}
 	if err != nil {
 		return nil, toConnectError(err)
 	}
@@ -97,6 +180,13 @@ func (s *Server) TerminateSandbox(ctx context.Context, req *connect.Request[clea
 
 func (s *Server) StreamSandboxEvents(ctx context.Context, req *connect.Request[cleanroomv1.StreamSandboxEventsRequest], stream *connect.ServerStream[cleanroomv1.SandboxEvent]) error {
 	history, updates, done, unsubscribe, err := s.service.SubscribeSandboxEvents(req.Msg.GetSandboxId())
+	if owner := s.owner(ctx, req.Header(), req.Msg.GetSandboxId(), err); owner != nil {
+		upstream, err := owner.StreamSandboxEvents(ctx, req.Msg)
+		if err != nil {
+			return err
+		}
+		return relayStream(upstream, stream.Send)
+	}
 	if err != nil {
 		return toConnectError(err)
 	}
@@ -130,6 +220,9 @@ func (s *Server) StreamSandboxEvents(ctx context.Context, req *connect.Request[c
 
 func (s *Server) CreateExecution(ctx context.Context, req *connect.Request[cleanroomv1.CreateExecutionRequest]) (*connect.Response[cleanroomv1.CreateExecutionResponse], error) {
 	resp, err := s.service.CreateExecution(ctx, req.Msg)
+	if owner := s.owner(ctx, req.Header(), req.Msg.GetSandboxId(), err); owner != nil {
+		resp, err = owner.CreateExecution(ctx, req.Msg)
+	}
 	if err != nil {
 		return nil, toConnectError(err)
 	}
@@ -138,6 +231,9 @@ func (s *Server) CreateExecution(ctx context.Context, req *connect.Request[clean
 
 func (s *Server) OpenInteractiveExecution(ctx context.Context, req *connect.Request[cleanroomv1.OpenInteractiveExecutionRequest]) (*connect.Response[cleanroomv1.OpenInteractiveExecutionResponse], error) {
 	resp, err := s.service.OpenInteractiveExecution(ctx, req.Msg)
+	if owner := s.owner(ctx, req.Header(), req.Msg.GetSandboxId(), err); owner != nil {
+		resp, err = owner.OpenInteractiveExecution(ctx, req.Msg)
+	}
 	if err != nil {
 		return nil, toConnectError(err)
 	}
@@ -154,14 +250,139 @@ func (s *Server) GetExecution(ctx context.Context, req *connect.Request[cleanroo
 
 func (s *Server) CancelExecution(ctx context.Context, req *connect.Request[cleanroomv1.CancelExecutionRequest]) (*connect.Response[cleanroomv1.CancelExecutionResponse], error) {
 	resp, err := s.service.CancelExecution(ctx, req.Msg)
+	if owner := s.owner(ctx, req.Header(), req.Msg.GetSandboxId(), err); owner != nil {
+		resp, err = owner.CancelExecution(ctx, req.Msg)
+	}
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (s *Server) ListPendingApprovals(ctx context.Context, req *connect.Request[cleanroomv1.ListPendingApprovalsRequest]) (*connect.Response[cleanroomv1.ListPendingApprovalsResponse], error) {
+	resp, err := s.service.ListPendingApprovals(ctx, req.Msg)
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (s *Server) ResolveExecutionApproval(ctx context.Context, req *connect.Request[cleanroomv1.ResolveExecutionApprovalRequest]) (*connect.Response[cleanroomv1.ResolveExecutionApprovalResponse], error) {
+	resp, err := s.service.ResolveExecutionApproval(ctx, req.Msg)
+	if owner := s.owner(ctx, req.Header(), req.Msg.GetSandboxId(), err); owner != nil {
+		resp, err = owner.ResolveExecutionApproval(ctx, req.Msg)
+	}
+	if errors.Is(err, controlservice.ErrExecutionNotPendingApproval) {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, err)
+	}
 	if err != nil {
 		return nil, toConnectError(err)
 	}
 	return connect.NewResponse(resp), nil
 }
 
+func (s *Server) AnnotateExecution(ctx context.Context, req *connect.Request[cleanroomv1.AnnotateExecutionRequest]) (*connect.Response[cleanroomv1.AnnotateExecutionResponse], error) {
+	resp, err := s.service.AnnotateExecution(ctx, req.Msg)
+	if owner := s.owner(ctx, req.Header(), req.Msg.GetSandboxId(), err); owner != nil {
+		resp, err = owner.AnnotateExecution(ctx, req.Msg)
+	}
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (s *Server) ListExecutions(ctx context.Context, req *connect.Request[cleanroomv1.ListExecutionsRequest]) (*connect.Response[cleanroomv1.ListExecutionsResponse], error) {
+	resp, err := s.service.ListExecutions(ctx, req.Msg)
+	if owner := s.owner(ctx, req.Header(), req.Msg.GetSandboxId(), err); owner != nil {
+		resp, err = owner.ListExecutions(ctx, req.Msg)
+	}
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (s *Server) GetServerInfo(ctx context.Context, req *connect.Request[cleanroomv1.GetServerInfoRequest]) (*connect.Response[cleanroomv1.GetServerInfoResponse], error) {
+	resp, err := s.service.GetServerInfo(ctx, req.Msg)
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (s *Server) GetUsage(ctx context.Context, req *connect.Request[cleanroomv1.GetUsageRequest]) (*connect.Response[cleanroomv1.GetUsageResponse], error) {
+	resp, err := s.service.GetUsage(ctx, req.Msg)
+	if errors.Is(err, controlservice.ErrUsageDisabled) {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, err)
+	}
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (s *Server) CreateSchedule(ctx context.Context, req *connect.Request[cleanroomv1.CreateScheduleRequest]) (*connect.Response[cleanroomv1.CreateScheduleResponse], error) {
+	resp, err := s.service.CreateSchedule(ctx, req.Msg)
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (s *Server) ListSchedules(ctx context.Context, req *connect.Request[cleanroomv1.ListSchedulesRequest]) (*connect.Response[cleanroomv1.ListSchedulesResponse], error) {
+	resp, err := s.service.ListSchedules(ctx, req.Msg)
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (s *Server) GetSchedule(ctx context.Context, req *connect.Request[cleanroomv1.GetScheduleRequest]) (*connect.Response[cleanroomv1.GetScheduleResponse], error) {
+	resp, err := s.service.GetSchedule(ctx, req.Msg)
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (s *Server) DeleteSchedule(ctx context.Context, req *connect.Request[cleanroomv1.DeleteScheduleRequest]) (*connect.Response[cleanroomv1.DeleteScheduleResponse], error) {
+	resp, err := s.service.DeleteSchedule(ctx, req.Msg)
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (s *Server) WriteExecutionStdin(ctx context.Context, req *connect.Request[cleanroomv1.WriteExecutionStdinRequest]) (*connect.Response[cleanroomv1.WriteExecutionStdinResponse], error) {
+	sandboxID := req.Msg.GetSandboxId()
+	executionID := req.Msg.GetExecutionId()
+	if err := s.service.WriteExecutionStdin(sandboxID, executionID, req.Msg.GetData()); err != nil {
+		if owner := s.owner(ctx, req.Header(), sandboxID, err); owner != nil {
+			return forwardResponse(owner.WriteExecutionStdin(ctx, req.Msg))
+		}
+		return nil, toStdinConnectError(err)
+	}
+	if req.Msg.GetEof() {
+		if err := s.service.CloseExecutionStdin(sandboxID, executionID); err != nil {
+			return nil, toStdinConnectError(err)
+		}
+	}
+	return connect.NewResponse(&cleanroomv1.WriteExecutionStdinResponse{
+		SandboxId:   sandboxID,
+		ExecutionId: executionID,
+	}), nil
+}
+
 func (s *Server) StreamExecution(ctx context.Context, req *connect.Request[cleanroomv1.StreamExecutionRequest], stream *connect.ServerStream[cleanroomv1.ExecutionStreamEvent]) error {
 	history, updates, done, unsubscribe, err := s.service.SubscribeExecutionEvents(req.Msg.GetSandboxId(), req.Msg.GetExecutionId())
+	if owner := s.owner(ctx, req.Header(), req.Msg.GetSandboxId(), err); owner != nil {
+		upstream, err := owner.StreamExecution(ctx, req.Msg)
+		if err != nil {
+			return err
+		}
+		return relayStream(upstream, stream.Send)
+	}
 	if err != nil {
 		return toConnectError(err)
 	}
@@ -253,9 +474,19 @@ func toConnectError(err error) error {
 		code = connect.CodeCanceled
 	case errors.Is(err, context.DeadlineExceeded):
 		code = connect.CodeDeadlineExceeded
+	case errors.Is(err, controlservice.ErrNamespaceDenied), errors.Is(err, controlservice.ErrApprovalNotPermitted):
+		code = connect.CodePermissionDenied
+	case errors.Is(err, controlservice.ErrRunIDInUse):
+		code = connect.CodeAlreadyExists
+	case errors.Is(err, controlservice.ErrBudgetExceeded):
+		code = connect.CodeResourceExhausted
+	case errors.Is(err, controlservice.ErrBudgetUnavailable):
+		code = connect.CodeUnavailable
+	case errors.Is(err, controlservice.ErrSchedulesDisabled):
+		code = connect.CodeFailedPrecondition
 	case strings.Contains(message, "missing "), strings.Contains(message, "invalid"):
 		code = connect.CodeInvalidArgument
-	case strings.Contains(message, "unknown sandbox"), strings.Contains(message, "unknown cleanroom"), strings.Contains(message, "unknown execution"):
+	case strings.Contains(message, "unknown sandbox"), strings.Contains(message, "unknown cleanroom"), strings.Contains(message, "unknown execution"), strings.Contains(message, "unknown schedule"):
 		code = connect.CodeNotFound
 	case strings.Contains(message, "not ready"):
 		code = connect.CodeFailedPrecondition
@@ -263,6 +494,18 @@ func toConnectError(err error) error {
 	return connect.NewError(code, err)
 }
 
+func toStdinConnectError(err error) error {
+	switch {
+	case errors.Is(err, controlservice.ErrExecutionStdinNotOpen):
+		return connect.NewError(connect.CodeFailedPrecondition, err)
+	case errors.Is(err, controlservice.ErrExecutionStdinUnsupported):
+		return connect.NewError(connect.CodeUnimplemented, err)
+	case errors.Is(err, controlservice.ErrExecutionNotRunning):
+		return connect.NewError(connect.CodeFailedPrecondition, err)
+	}
+	return toConnectError(err)
+}
+
 func Serve(ctx context.Context, ep endpoint.Endpoint, handler http.Handler, logger *log.Logger, tlsOpts *TLSOptions) error {
 	listener, cleanup, err := listen(ep, tlsOpts)
 	if err != nil {
@@ -281,6 +524,7 @@ func Serve(ctx context.Context, ep endpoint.Endpoint, handler http.Handler, logg
 	httpServer := &http.Server{
 		Handler:           handler,
 		ReadHeaderTimeout: 5 * time.Second,
+		ConnContext:       withConnIdentity,
 	}
 	if ep.Scheme == "https" {
 		if err := http2.ConfigureServer(httpServer, nil); err != nil {
@@ -341,6 +585,7 @@ func listen(ep endpoint.Endpoint, tlsOpts *TLSOptions) (net.Listener, func() err
 			opts = tlsconfig.Options{
 				CertPath: tlsOpts.CertPath,
 				KeyPath:  tlsOpts.KeyPath,
+				CAPath:   tlsOpts.PeerCAPath,
 			}
 		}
 		tlsCfg, err := tlsconfig.ResolveServer(opts)
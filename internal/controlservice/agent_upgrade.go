@@ -0,0 +1,64 @@
+package controlservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// UpgradeSandboxAgent replaces a ready sandbox's guest agent with the one
+// the server would install in a new sandbox, without recreating the VM. The
+// sandbox is busy until the restarted agent answers.
+func (s *Service) UpgradeSandboxAgent(ctx context.Context, req *cleanroomv1.UpgradeSandboxAgentRequest) (*cleanroomv1.UpgradeSandboxAgentResponse, error) {
+	if req == nil {
+		return nil, errors.New("missing request")
+	}
+	sandboxID := strings.TrimSpace(req.GetSandboxId())
+	if sandboxID == "" {
+		return nil, errors.New("missing sandbox_id")
+	}
+
+	backendName, adapter, release, err := s.beginSandboxOperation(sandboxID, "agent upgrade")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	upgrader, ok := adapter.(backend.SandboxAgentUpgradeAdapter)
+	if !ok {
+		return nil, fmt.Errorf("backend %q does not support guest agent upgrades", backendName)
+	}
+
+	hash, err := upgrader.UpgradeSandboxAgent(ctx, sandboxID)
+	if err != nil {
+		return nil, fmt.Errorf("upgrade guest agent: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.sandboxes[sandboxID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownSandbox, sandboxID)
+	}
+	previous := state.AgentHash
+	upgraded := hash != previous
+	if upgraded {
+		state.AgentHash = hash
+		s.recordSandboxEventLocked(state, state.Status, fmt.Sprintf("guest agent upgraded to %s", shortAgentHash(hash)))
+	}
+	return &cleanroomv1.UpgradeSandboxAgentResponse{
+		Sandbox:           cloneSandboxLocked(state),
+		PreviousAgentHash: previous,
+		Upgraded:          upgraded,
+	}, nil
+}
+
+func shortAgentHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
@@ -0,0 +1,87 @@
+package controlservice
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// agentUpgradeAdapter tracks one agent hash shared by every sandbox.
+type agentUpgradeAdapter struct {
+	*stubAdapter
+	hash     string
+	hostHash string
+	upgrades int
+}
+
+func (a *agentUpgradeAdapter) SandboxAgentHash(string) (string, error) {
+	return a.hash, nil
+}
+
+func (a *agentUpgradeAdapter) UpgradeSandboxAgent(context.Context, string) (string, error) {
+	if a.hash != a.hostHash {
+		a.upgrades++
+		a.hash = a.hostHash
+	}
+	return a.hash, nil
+}
+
+func TestUpgradeSandboxAgentRecordsNewHash(t *testing.T) {
+	adapter := &agentUpgradeAdapter{stubAdapter: &stubAdapter{}, hash: "0123456789abcdef-old", hostHash: "fedcba9876543210-new"}
+	svc := newTestService(adapter)
+
+	created, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sandboxID := created.GetSandbox().GetSandboxId()
+	if got := created.GetSandbox().GetAgentHash(); got != "0123456789abcdef-old" {
+		t.Fatalf("created sandbox agent hash = %q", got)
+	}
+
+	resp, err := svc.UpgradeSandboxAgent(context.Background(), &cleanroomv1.UpgradeSandboxAgentRequest{SandboxId: sandboxID})
+	if err != nil {
+		t.Fatalf("UpgradeSandboxAgent returned error: %v", err)
+	}
+	if !resp.GetUpgraded() || resp.GetPreviousAgentHash() != "0123456789abcdef-old" || resp.GetSandbox().GetAgentHash() != "fedcba9876543210-new" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if resp.GetSandbox().GetStatus() != cleanroomv1.SandboxStatus_SANDBOX_STATUS_READY {
+		t.Fatalf("sandbox status = %v, want ready", resp.GetSandbox().GetStatus())
+	}
+	got, err := svc.GetSandbox(context.Background(), &cleanroomv1.GetSandboxRequest{SandboxId: sandboxID})
+	if err != nil || got.GetSandbox().GetAgentHash() != "fedcba9876543210-new" {
+		t.Fatalf("GetSandbox agent hash = %q, %v", got.GetSandbox().GetAgentHash(), err)
+	}
+	history, _, _, unsubscribe, err := svc.SubscribeSandboxEvents(sandboxID)
+	if err != nil {
+		t.Fatalf("SubscribeSandboxEvents returned error: %v", err)
+	}
+	unsubscribe()
+	if last := history[len(history)-1].GetMessage(); last != "guest agent upgraded to fedcba987654" {
+		t.Fatalf("unexpected last event %q", last)
+	}
+
+	resp, err = svc.UpgradeSandboxAgent(context.Background(), &cleanroomv1.UpgradeSandboxAgentRequest{SandboxId: sandboxID})
+	if err != nil {
+		t.Fatalf("second UpgradeSandboxAgent returned error: %v", err)
+	}
+	if resp.GetUpgraded() || adapter.upgrades != 1 {
+		t.Fatalf("expected the second upgrade to be a no-op, got upgraded=%v upgrades=%d", resp.GetUpgraded(), adapter.upgrades)
+	}
+}
+
+func TestUpgradeSandboxAgentRequiresBackendSupport(t *testing.T) {
+	svc := newTestService(&stubAdapter{})
+	created, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+
+	_, err = svc.UpgradeSandboxAgent(context.Background(), &cleanroomv1.UpgradeSandboxAgentRequest{SandboxId: created.GetSandbox().GetSandboxId()})
+	if err == nil || !strings.Contains(err.Error(), "does not support guest agent upgrades") {
+		t.Fatalf("expected an unsupported backend error, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package controlservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// AnnotateExecution sets annotations on an execution, during its run or
+// after it. An empty value removes the key.
+func (s *Service) AnnotateExecution(_ context.Context, req *cleanroomv1.AnnotateExecutionRequest) (*cleanroomv1.AnnotateExecutionResponse, error) {
+	if req == nil {
+		return nil, errors.New("missing request")
+	}
+	sandboxID := strings.TrimSpace(req.GetSandboxId())
+	executionID := strings.TrimSpace(req.GetExecutionId())
+	if sandboxID == "" {
+		return nil, errors.New("missing sandbox_id")
+	}
+	if executionID == "" {
+		return nil, errors.New("missing execution_id")
+	}
+	if len(req.GetAnnotations()) == 0 {
+		return nil, errors.New("missing annotations")
+	}
+	updates, err := keyValuesFromProto("annotation", req.GetAnnotations())
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ex, ok := s.executions[executionKey(sandboxID, executionID)]
+	if !ok {
+		return nil, fmt.Errorf("%w %q in sandbox %q", ErrUnknownExecution, executionID, sandboxID)
+	}
+	merged := maps.Clone(ex.Annotations)
+	if merged == nil {
+		merged = map[string]string{}
+	}
+	for key, value := range updates {
+		if value == "" {
+			delete(merged, key)
+		} else {
+			merged[key] = value
+		}
+	}
+	if len(merged) > maxSandboxLabels {
+		return nil, fmt.Errorf("invalid annotations: at most %d allowed, got %d", maxSandboxLabels, len(merged))
+	}
+	ex.Annotations = merged
+
+	changes := make([]string, 0, len(updates))
+	for _, key := range slices.Sorted(maps.Keys(updates)) {
+		changes = append(changes, key+"="+updates[key])
+	}
+	s.recordExecutionEventLocked(ex, &cleanroomv1.ExecutionStreamEvent{
+		SandboxId:   sandboxID,
+		ExecutionId: executionID,
+		Status:      ex.Status,
+		Payload:     &cleanroomv1.ExecutionStreamEvent_Message{Message: "annotations updated: " + strings.Join(changes, ", ")},
+		OccurredAt:  timestamppb.New(time.Now().UTC()),
+	})
+	return &cleanroomv1.AnnotateExecutionResponse{Execution: cloneExecutionLocked(ex)}, nil
+}
+
+// ListExecutions lists the executions this instance holds, oldest first:
+// a sandbox's, or those of every sandbox in the caller's namespace, that
+// carry all of req's annotations.
+func (s *Service) ListExecutions(ctx context.Context, req *cleanroomv1.ListExecutionsRequest) (*cleanroomv1.ListExecutionsResponse, error) {
+	if req == nil {
+		return nil, errors.New("missing request")
+	}
+	sandboxID := strings.TrimSpace(req.GetSandboxId())
+	filter := req.GetAnnotations()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if sandboxID != "" {
+		if _, ok := s.sandboxes[sandboxID]; !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownSandbox, sandboxID)
+		}
+	}
+	resp := &cleanroomv1.ListExecutionsResponse{}
+	for _, ex := range s.executions {
+		if sandboxID != "" && ex.SandboxID != sandboxID {
+			continue
+		}
+		if !canAccessNamespace(ctx, s.Config.Namespaces, namespaceOrDefault(ex.Namespace)) {
+			continue
+		}
+		if !annotationsMatch(ex.Annotations, filter) {
+			continue
+		}
+		resp.Executions = append(resp.Executions, cloneExecutionLocked(ex))
+	}
+	// Execution IDs are time-ordered.
+	sort.Slice(resp.Executions, func(i, j int) bool {
+		return resp.Executions[i].GetExecutionId() < resp.Executions[j].GetExecutionId()
+	})
+	return resp, nil
+}
+
+// annotationsMatch reports whether annotations carries every key in filter
+// with the same value.
+func annotationsMatch(annotations, filter map[string]string) bool {
+	for key, value := range filter {
+		if got, ok := annotations[key]; !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package controlservice
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+func TestExecutionAnnotationsAreSearchable(t *testing.T) {
+	t.Parallel()
+
+	adapter := &stubAdapter{
+		runFn: func(_ context.Context, req backend.RunRequest) (*backend.RunResult, error) {
+			return &backend.RunResult{RunID: req.RunID}, nil
+		},
+	}
+	svc := newTestService(adapter)
+	ctx := context.Background()
+
+	createResp, err := svc.CreateSandbox(ctx, &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sandboxID := createResp.GetSandbox().GetSandboxId()
+
+	run := func(shard string) string {
+		t.Helper()
+		resp, err := svc.CreateExecution(ctx, &cleanroomv1.CreateExecutionRequest{
+			SandboxId:   sandboxID,
+			Command:     []string{"go", "test", "./..."},
+			Annotations: map[string]string{"test-shard": shard},
+		})
+		if err != nil {
+			t.Fatalf("CreateExecution returned error: %v", err)
+		}
+		waitForExecutionStatus(t, svc, sandboxID, resp.GetExecution().GetExecutionId())
+		return resp.GetExecution().GetExecutionId()
+	}
+	first := run("1")
+	second := run("3")
+
+	annotated, err := svc.AnnotateExecution(ctx, &cleanroomv1.AnnotateExecutionRequest{
+		SandboxId:   sandboxID,
+		ExecutionId: second,
+		Annotations: map[string]string{"coverage": "87%"},
+	})
+	if err != nil {
+		t.Fatalf("AnnotateExecution returned error: %v", err)
+	}
+	if got := annotated.GetExecution().GetAnnotations(); got["test-shard"] != "3" || got["coverage"] != "87%" {
+		t.Fatalf("expected the annotations to be merged, got %v", got)
+	}
+
+	list := func(filter map[string]string) []string {
+		t.Helper()
+		resp, err := svc.ListExecutions(ctx, &cleanroomv1.ListExecutionsRequest{SandboxId: sandboxID, Annotations: filter})
+		if err != nil {
+			t.Fatalf("ListExecutions returned error: %v", err)
+		}
+		var ids []string
+		for _, ex := range resp.GetExecutions() {
+			ids = append(ids, ex.GetExecutionId())
+		}
+		return ids
+	}
+	if got := list(nil); len(got) != 2 || got[0] != first || got[1] != second {
+		t.Fatalf("expected both executions oldest first, got %v", got)
+	}
+	if got := list(map[string]string{"test-shard": "3", "coverage": "87%"}); len(got) != 1 || got[0] != second {
+		t.Fatalf("expected only the annotated shard, got %v", got)
+	}
+	if got := list(map[string]string{"coverage": "90%"}); len(got) != 0 {
+		t.Fatalf("expected no match, got %v", got)
+	}
+
+	// An empty value removes the key.
+	if _, err := svc.AnnotateExecution(ctx, &cleanroomv1.AnnotateExecutionRequest{
+		SandboxId:   sandboxID,
+		ExecutionId: second,
+		Annotations: map[string]string{"coverage": ""},
+	}); err != nil {
+		t.Fatalf("AnnotateExecution returned error: %v", err)
+	}
+	if got := list(map[string]string{"coverage": "87%"}); len(got) != 0 {
+		t.Fatalf("expected the removed annotation not to match, got %v", got)
+	}
+
+	if _, err := svc.AnnotateExecution(ctx, &cleanroomv1.AnnotateExecutionRequest{
+		SandboxId:   sandboxID,
+		ExecutionId: first,
+		Annotations: map[string]string{"bad key": "x"},
+	}); err == nil || !strings.Contains(err.Error(), "invalid annotation key") {
+		t.Fatalf("expected an invalid key to be rejected, got %v", err)
+	}
+	if _, err := svc.ListExecutions(ctx, &cleanroomv1.ListExecutionsRequest{SandboxId: "missing"}); err == nil || !strings.Contains(err.Error(), "unknown sandbox") {
+		t.Fatalf("expected an unknown sandbox, got %v", err)
+	}
+}
@@ -0,0 +1,299 @@
+package controlservice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultApprovalTimeout = 10 * time.Minute
+	approvalWebhookTimeout = 10 * time.Second
+)
+
+// ErrExecutionNotPendingApproval is returned when resolving an execution that
+// is not waiting for approval.
+var ErrExecutionNotPendingApproval = errors.New("execution is not pending approval")
+
+// ErrApprovalNotPermitted is returned when the caller may not decide an
+// execution's approval: it is not a configured approver, or it requested
+// the execution itself.
+var ErrApprovalNotPermitted = errors.New("approval decision not permitted")
+
+type callerIdentityKey struct{}
+
+// WithCallerIdentity records who made a request, such as "uid:1000" for a
+// unix socket peer. Approval rules match against it.
+func WithCallerIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, callerIdentityKey{}, identity)
+}
+
+// CallerIdentity returns the identity recorded by WithCallerIdentity, or "".
+func CallerIdentity(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	identity, _ := ctx.Value(callerIdentityKey{}).(string)
+	return identity
+}
+
+// approvalRules returns the configured rules that require an execution by
+// identity in a sandbox with labels to be approved first.
+func approvalRules(cfg runtimeconfig.Approval, identity string, labels map[string]string) []string {
+	var rules []string
+	for _, want := range cfg.Identities {
+		if want = strings.TrimSpace(want); want != "" && want == identity {
+			rules = append(rules, "identity "+want)
+		}
+	}
+	keys := make([]string, 0, len(cfg.Labels))
+	for key := range cfg.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if value, ok := labels[key]; ok && value == cfg.Labels[key] {
+			rules = append(rules, "label "+key+"="+value)
+		}
+	}
+	return rules
+}
+
+func approvalTimeout(cfg runtimeconfig.Approval) time.Duration {
+	if cfg.TimeoutSeconds > 0 {
+		return time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	return defaultApprovalTimeout
+}
+
+func isPendingExecutionStatus(status cleanroomv1.ExecutionStatus) bool {
+	return status == cleanroomv1.ExecutionStatus_EXECUTION_STATUS_QUEUED ||
+		status == cleanroomv1.ExecutionStatus_EXECUTION_STATUS_PENDING_APPROVAL
+}
+
+// holdForApprovalLocked parks a new execution in PENDING_APPROVAL and arms
+// the timeout that denies it if nobody decides in time.
+func (s *Service) holdForApprovalLocked(ex *executionState, identity string, rules []string, timeout time.Duration, now time.Time) {
+	ex.Status = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_PENDING_APPROVAL
+	ex.Approval = &cleanroomv1.ExecutionApproval{
+		RequestedBy: identity,
+		Rules:       append([]string(nil), rules...),
+		RequestedAt: timestamppb.New(now),
+	}
+	sandboxID, executionID := ex.SandboxID, ex.ID
+	ex.ApprovalTimer = time.AfterFunc(timeout, func() {
+		_, _ = s.resolveApproval(context.Background(), sandboxID, executionID, false, "server", fmt.Sprintf("approval timed out after %s", timeout), nil)
+	})
+	s.recordExecutionEventLocked(ex, &cleanroomv1.ExecutionStreamEvent{
+		SandboxId:   sandboxID,
+		ExecutionId: executionID,
+		Status:      ex.Status,
+		Payload:     &cleanroomv1.ExecutionStreamEvent_Message{Message: "execution awaiting approval (" + strings.Join(rules, ", ") + ")"},
+		OccurredAt:  timestamppb.New(now),
+	})
+}
+
+// ListPendingApprovals lists the executions awaiting approval in the
+// namespaces the caller can access.
+func (s *Service) ListPendingApprovals(ctx context.Context, _ *cleanroomv1.ListPendingApprovalsRequest) (*cleanroomv1.ListPendingApprovalsResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	resp := &cleanroomv1.ListPendingApprovalsResponse{}
+	for _, ex := range s.executions {
+		if ex.Status != cleanroomv1.ExecutionStatus_EXECUTION_STATUS_PENDING_APPROVAL {
+			continue
+		}
+		if !canAccessNamespace(ctx, s.Config.Namespaces, namespaceOrDefault(ex.Namespace)) {
+			continue
+		}
+		resp.Approvals = append(resp.Approvals, pendingApprovalLocked(ex, s.sandboxes[ex.SandboxID]))
+	}
+	sort.Slice(resp.Approvals, func(i, j int) bool {
+		a, b := resp.Approvals[i].GetExecution().GetApproval(), resp.Approvals[j].GetExecution().GetApproval()
+		return a.GetRequestedAt().AsTime().Before(b.GetRequestedAt().AsTime())
+	})
+	return resp, nil
+}
+
+func (s *Service) ResolveExecutionApproval(ctx context.Context, req *cleanroomv1.ResolveExecutionApprovalRequest) (*cleanroomv1.ResolveExecutionApprovalResponse, error) {
+	if req == nil {
+		return nil, errors.New("missing request")
+	}
+	sandboxID := strings.TrimSpace(req.GetSandboxId())
+	executionID := strings.TrimSpace(req.GetExecutionId())
+	if sandboxID == "" {
+		return nil, errors.New("missing sandbox_id")
+	}
+	if executionID == "" {
+		return nil, errors.New("missing execution_id")
+	}
+	identity := CallerIdentity(ctx)
+	decidedBy := identity
+	if decidedBy == "" {
+		decidedBy = "unknown"
+	}
+	cfg := s.runtimeConfig().Approval
+	authorize := func(ex *executionState) error {
+		return checkApprover(cfg, identity, ex.Approval.GetRequestedBy())
+	}
+	execution, err := s.resolveApproval(ctx, sandboxID, executionID, req.GetApprove(), decidedBy, strings.TrimSpace(req.GetReason()), authorize)
+	if err != nil {
+		return nil, err
+	}
+	return &cleanroomv1.ResolveExecutionApprovalResponse{Execution: execution}, nil
+}
+
+// checkApprover reports whether identity may decide an execution that
+// requestedBy is waiting on. When approvers are configured only they may
+// decide, and nobody may decide their own execution.
+func checkApprover(cfg runtimeconfig.Approval, identity, requestedBy string) error {
+	if identity != "" && identity == requestedBy {
+		return fmt.Errorf("%w: %s cannot decide its own execution", ErrApprovalNotPermitted, identity)
+	}
+	if len(cfg.Approvers) == 0 {
+		return nil
+	}
+	for _, approver := range cfg.Approvers {
+		if approver = strings.TrimSpace(approver); approver != "" && approver == identity {
+			return nil
+		}
+	}
+	if identity == "" {
+		identity = "unknown caller"
+	}
+	return fmt.Errorf("%w: %s is not an approver", ErrApprovalNotPermitted, identity)
+}
+
+// resolveApproval records the decision on a pending execution and then runs
+// or fails it. authorize, when set, vets the decision first.
+func (s *Service) resolveApproval(ctx context.Context, sandboxID, executionID string, approve bool, decidedBy, reason string, authorize func(*executionState) error) (*cleanroomv1.Execution, error) {
+	now := time.Now().UTC()
+	s.mu.Lock()
+	ex, ok := s.executions[executionKey(sandboxID, executionID)]
+	if !ok {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w %q in sandbox %q", ErrUnknownExecution, executionID, sandboxID)
+	}
+	if ex.Status != cleanroomv1.ExecutionStatus_EXECUTION_STATUS_PENDING_APPROVAL {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: execution %q is %s", ErrExecutionNotPendingApproval, executionID, ex.Status)
+	}
+	if authorize != nil {
+		if err := authorize(ex); err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+	}
+	if ex.ApprovalTimer != nil {
+		ex.ApprovalTimer.Stop()
+		ex.ApprovalTimer = nil
+	}
+	ex.Approval.Decided = true
+	ex.Approval.Approved = approve
+	ex.Approval.DecidedBy = decidedBy
+	ex.Approval.Reason = reason
+	ex.Approval.DecidedAt = timestamppb.New(now)
+
+	if !approve {
+		message := "execution denied by " + decidedBy
+		if reason != "" {
+			message += ": " + reason
+		}
+		ex.FailureReason = cleanroomv1.ExecutionFailureReason_EXECUTION_FAILURE_REASON_APPROVAL_DENIED
+		s.finalizeExecutionLocked(ex, cleanroomv1.ExecutionStatus_EXECUTION_STATUS_FAILED, 1, message, message, now)
+		out := cloneExecutionLocked(ex)
+		s.mu.Unlock()
+		s.logApproval(ctx, sandboxID, executionID, false, decidedBy, reason)
+		return out, nil
+	}
+
+	ex.Status = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_QUEUED
+	s.recordExecutionEventLocked(ex, &cleanroomv1.ExecutionStreamEvent{
+		SandboxId:   sandboxID,
+		ExecutionId: executionID,
+		Status:      ex.Status,
+		Payload:     &cleanroomv1.ExecutionStreamEvent_Message{Message: "execution approved by " + decidedBy},
+		OccurredAt:  timestamppb.New(now),
+	})
+	out := cloneExecutionLocked(ex)
+	s.mu.Unlock()
+
+	s.logApproval(ctx, sandboxID, executionID, true, decidedBy, reason)
+	go s.runExecution(sandboxID, executionID)
+	return out, nil
+}
+
+func (s *Service) logApproval(ctx context.Context, sandboxID, executionID string, approved bool, decidedBy, reason string) {
+	logger := s.logger(ctx)
+	if logger == nil {
+		return
+	}
+	logger.Info("execution approval resolved",
+		"sandbox_id", sandboxID,
+		"execution_id", executionID,
+		"approved", approved,
+		"decided_by", decidedBy,
+		"reason", reason,
+	)
+}
+
+func pendingApprovalLocked(ex *executionState, sb *sandboxState) *cleanroomv1.PendingApproval {
+	out := &cleanroomv1.PendingApproval{
+		Execution: cloneExecutionLocked(ex),
+		Sandbox:   cloneSandboxLocked(sb),
+	}
+	if sb != nil && sb.Policy != nil {
+		out.ImageRef = sb.Policy.ImageRef
+		out.NetworkDefault = sb.Policy.NetworkDefault
+		for _, rule := range sb.Policy.Allow {
+			out.AllowedHosts = append(out.AllowedHosts, rule.String())
+		}
+	}
+	return out
+}
+
+// notifyApprovalWebhook posts the pending approval as JSON to the configured
+// webhook. Delivery is best effort; the execution stays pending either way.
+func (s *Service) notifyApprovalWebhook(url string, pending *cleanroomv1.PendingApproval) {
+	if err := postApprovalWebhook(url, pending); err != nil && s.Logger != nil {
+		s.Logger.Warn("approval webhook failed",
+			"sandbox_id", pending.GetExecution().GetSandboxId(),
+			"execution_id", pending.GetExecution().GetExecutionId(),
+			"error", err,
+		)
+	}
+}
+
+func postApprovalWebhook(url string, pending *cleanroomv1.PendingApproval) error {
+	body, err := protojson.Marshal(pending)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), approvalWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
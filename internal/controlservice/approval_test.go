@@ -0,0 +1,299 @@
+package controlservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+)
+
+func newApprovalTestService(t *testing.T, approval runtimeconfig.Approval, labels map[string]string) (*Service, string, *atomic.Int32) {
+	t.Helper()
+	var runs atomic.Int32
+	adapter := &stubAdapter{
+		runFn: func(_ context.Context, req backend.RunRequest) (*backend.RunResult, error) {
+			runs.Add(1)
+			return &backend.RunResult{RunID: req.RunID, Message: "done"}, nil
+		},
+	}
+	svc := newTestService(adapter)
+	svc.Config.Approval = approval
+	resp, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy(), Labels: labels})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	return svc, resp.GetSandbox().GetSandboxId(), &runs
+}
+
+func waitForExecutionStatus(t *testing.T, svc *Service, sandboxID, executionID string) *cleanroomv1.Execution {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ex, err := svc.WaitExecution(ctx, sandboxID, executionID)
+	if err != nil {
+		t.Fatalf("WaitExecution returned error: %v", err)
+	}
+	return ex
+}
+
+func TestLabelledSandboxExecutionWaitsForApproval(t *testing.T) {
+	t.Parallel()
+
+	svc, sandboxID, runs := newApprovalTestService(t, runtimeconfig.Approval{Labels: map[string]string{"agent": "true"}}, map[string]string{"agent": "true"})
+
+	created, err := svc.CreateExecution(WithCallerIdentity(context.Background(), "uid:1001"), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   []string{"make", "deploy"},
+	})
+	if err != nil {
+		t.Fatalf("CreateExecution returned error: %v", err)
+	}
+	execution := created.GetExecution()
+	if got, want := execution.GetStatus(), cleanroomv1.ExecutionStatus_EXECUTION_STATUS_PENDING_APPROVAL; got != want {
+		t.Fatalf("unexpected status: got %v want %v", got, want)
+	}
+	if got := execution.GetApproval().GetRules(); len(got) != 1 || got[0] != "label agent=true" {
+		t.Fatalf("unexpected approval rules: %v", got)
+	}
+
+	pending, err := svc.ListPendingApprovals(context.Background(), &cleanroomv1.ListPendingApprovalsRequest{})
+	if err != nil {
+		t.Fatalf("ListPendingApprovals returned error: %v", err)
+	}
+	if len(pending.GetApprovals()) != 1 {
+		t.Fatalf("expected one pending approval, got %d", len(pending.GetApprovals()))
+	}
+	if got := pending.GetApprovals()[0].GetExecution().GetApproval().GetRequestedBy(); got != "uid:1001" {
+		t.Fatalf("unexpected requester: %q", got)
+	}
+	if got := pending.GetApprovals()[0].GetNetworkDefault(); got != "deny" {
+		t.Fatalf("expected policy context in pending approval, got network default %q", got)
+	}
+	if runs.Load() != 0 {
+		t.Fatal("expected execution not to run before approval")
+	}
+
+	if _, err := svc.ResolveExecutionApproval(WithCallerIdentity(context.Background(), "uid:1000"), &cleanroomv1.ResolveExecutionApprovalRequest{
+		SandboxId:   sandboxID,
+		ExecutionId: execution.GetExecutionId(),
+		Approve:     true,
+	}); err != nil {
+		t.Fatalf("ResolveExecutionApproval returned error: %v", err)
+	}
+
+	final := waitForExecutionStatus(t, svc, sandboxID, execution.GetExecutionId())
+	if got, want := final.GetStatus(), cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SUCCEEDED; got != want {
+		t.Fatalf("unexpected final status: got %v want %v", got, want)
+	}
+	if got := final.GetApproval(); !got.GetApproved() || got.GetDecidedBy() != "uid:1000" {
+		t.Fatalf("unexpected approval record: %v", got)
+	}
+	if runs.Load() != 1 {
+		t.Fatalf("expected one run after approval, got %d", runs.Load())
+	}
+}
+
+func TestDeniedExecutionFailsWithoutRunning(t *testing.T) {
+	t.Parallel()
+
+	svc, sandboxID, runs := newApprovalTestService(t, runtimeconfig.Approval{Identities: []string{"uid:1001"}}, nil)
+
+	created, err := svc.CreateExecution(WithCallerIdentity(context.Background(), "uid:1001"), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   []string{"rm", "-rf", "/workspace"},
+	})
+	if err != nil {
+		t.Fatalf("CreateExecution returned error: %v", err)
+	}
+	executionID := created.GetExecution().GetExecutionId()
+
+	if _, err := svc.ResolveExecutionApproval(context.Background(), &cleanroomv1.ResolveExecutionApprovalRequest{
+		SandboxId:   sandboxID,
+		ExecutionId: executionID,
+		Reason:      "too broad",
+	}); err != nil {
+		t.Fatalf("ResolveExecutionApproval returned error: %v", err)
+	}
+
+	final := waitForExecutionStatus(t, svc, sandboxID, executionID)
+	if got, want := final.GetStatus(), cleanroomv1.ExecutionStatus_EXECUTION_STATUS_FAILED; got != want {
+		t.Fatalf("unexpected final status: got %v want %v", got, want)
+	}
+	if got, want := final.GetFailureReason(), cleanroomv1.ExecutionFailureReason_EXECUTION_FAILURE_REASON_APPROVAL_DENIED; got != want {
+		t.Fatalf("unexpected failure reason: got %v want %v", got, want)
+	}
+	if got := final.GetApproval().GetReason(); got != "too broad" {
+		t.Fatalf("unexpected denial reason: %q", got)
+	}
+	if runs.Load() != 0 {
+		t.Fatal("expected denied execution never to run")
+	}
+
+	if _, err := svc.ResolveExecutionApproval(context.Background(), &cleanroomv1.ResolveExecutionApprovalRequest{
+		SandboxId:   sandboxID,
+		ExecutionId: executionID,
+		Approve:     true,
+	}); err == nil {
+		t.Fatal("expected resolving a finished execution to fail")
+	}
+}
+
+func TestExecutionFromOtherIdentityRunsWithoutApproval(t *testing.T) {
+	t.Parallel()
+
+	svc, sandboxID, _ := newApprovalTestService(t, runtimeconfig.Approval{Identities: []string{"uid:1001"}}, nil)
+
+	created, err := svc.CreateExecution(WithCallerIdentity(context.Background(), "uid:1000"), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   []string{"true"},
+	})
+	if err != nil {
+		t.Fatalf("CreateExecution returned error: %v", err)
+	}
+	if created.GetExecution().GetApproval() != nil {
+		t.Fatalf("expected no approval record, got %v", created.GetExecution().GetApproval())
+	}
+	final := waitForExecutionStatus(t, svc, sandboxID, created.GetExecution().GetExecutionId())
+	if got, want := final.GetStatus(), cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SUCCEEDED; got != want {
+		t.Fatalf("unexpected final status: got %v want %v", got, want)
+	}
+}
+
+func TestApprovalRejectsSelfApprovalAndNonApprovers(t *testing.T) {
+	t.Parallel()
+
+	svc, sandboxID, runs := newApprovalTestService(t, runtimeconfig.Approval{
+		Identities: []string{"uid:1001", "uid:1002"},
+		Approvers:  []string{"uid:1002", "uid:1500"},
+	}, nil)
+	agent := WithCallerIdentity(context.Background(), "uid:1001")
+	created, err := svc.CreateExecution(agent, &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   []string{"make", "deploy"},
+	})
+	if err != nil {
+		t.Fatalf("CreateExecution returned error: %v", err)
+	}
+	executionID := created.GetExecution().GetExecutionId()
+	resolve := func(ctx context.Context, approve bool) error {
+		_, err := svc.ResolveExecutionApproval(ctx, &cleanroomv1.ResolveExecutionApprovalRequest{
+			SandboxId:   sandboxID,
+			ExecutionId: executionID,
+			Approve:     approve,
+		})
+		return err
+	}
+
+	if err := resolve(agent, true); !errors.Is(err, ErrApprovalNotPermitted) {
+		t.Fatalf("expected the requester's own approval to be rejected, got %v", err)
+	}
+	if err := resolve(WithCallerIdentity(context.Background(), "uid:1000"), true); !errors.Is(err, ErrApprovalNotPermitted) {
+		t.Fatalf("expected a non-approver's approval to be rejected, got %v", err)
+	}
+	if err := resolve(context.Background(), false); !errors.Is(err, ErrApprovalNotPermitted) {
+		t.Fatalf("expected an anonymous denial to be rejected, got %v", err)
+	}
+	pending, err := svc.ListPendingApprovals(context.Background(), &cleanroomv1.ListPendingApprovalsRequest{})
+	if err != nil || len(pending.GetApprovals()) != 1 {
+		t.Fatalf("expected the execution to stay pending, got %v, %v", pending, err)
+	}
+
+	if err := resolve(WithCallerIdentity(context.Background(), "uid:1500"), true); err != nil {
+		t.Fatalf("ResolveExecutionApproval by an approver returned error: %v", err)
+	}
+	final := waitForExecutionStatus(t, svc, sandboxID, executionID)
+	if got := final.GetApproval(); final.GetStatus() != cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SUCCEEDED || got.GetDecidedBy() != "uid:1500" {
+		t.Fatalf("unexpected final execution: %v", final)
+	}
+	if runs.Load() != 1 {
+		t.Fatalf("expected one run after approval, got %d", runs.Load())
+	}
+
+	// An approver held by an identity rule still cannot approve itself.
+	held, err := svc.CreateExecution(WithCallerIdentity(context.Background(), "uid:1002"), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   []string{"true"},
+	})
+	if err != nil {
+		t.Fatalf("CreateExecution returned error: %v", err)
+	}
+	if _, err := svc.ResolveExecutionApproval(WithCallerIdentity(context.Background(), "uid:1002"), &cleanroomv1.ResolveExecutionApprovalRequest{
+		SandboxId:   sandboxID,
+		ExecutionId: held.GetExecution().GetExecutionId(),
+		Approve:     true,
+	}); !errors.Is(err, ErrApprovalNotPermitted) {
+		t.Fatalf("expected an approver's own execution to need someone else, got %v", err)
+	}
+}
+
+func TestPendingApprovalNotifiesWebhook(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan map[string]any, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload map[string]any
+		_ = json.Unmarshal(body, &payload)
+		received <- payload
+	}))
+	defer server.Close()
+
+	svc, sandboxID, _ := newApprovalTestService(t, runtimeconfig.Approval{
+		Labels:     map[string]string{"agent": "true"},
+		WebhookURL: server.URL,
+	}, map[string]string{"agent": "true"})
+
+	if _, err := svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   []string{"npm", "publish"},
+	}); err != nil {
+		t.Fatalf("CreateExecution returned error: %v", err)
+	}
+
+	select {
+	case payload := <-received:
+		execution, _ := payload["execution"].(map[string]any)
+		command, _ := execution["command"].([]any)
+		if len(command) != 2 || command[0] != "npm" {
+			t.Fatalf("unexpected webhook payload: %v", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+}
+
+func TestCancelPendingApprovalExecution(t *testing.T) {
+	t.Parallel()
+
+	svc, sandboxID, runs := newApprovalTestService(t, runtimeconfig.Approval{Labels: map[string]string{"agent": "true"}}, map[string]string{"agent": "true"})
+
+	created, err := svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   []string{"true"},
+	})
+	if err != nil {
+		t.Fatalf("CreateExecution returned error: %v", err)
+	}
+	resp, err := svc.CancelExecution(context.Background(), &cleanroomv1.CancelExecutionRequest{
+		SandboxId:   sandboxID,
+		ExecutionId: created.GetExecution().GetExecutionId(),
+	})
+	if err != nil {
+		t.Fatalf("CancelExecution returned error: %v", err)
+	}
+	if got, want := resp.GetStatus(), cleanroomv1.ExecutionStatus_EXECUTION_STATUS_CANCELED; got != want {
+		t.Fatalf("unexpected status after cancel: got %v want %v", got, want)
+	}
+	if runs.Load() != 0 {
+		t.Fatal("expected canceled execution never to run")
+	}
+}
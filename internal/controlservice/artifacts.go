@@ -0,0 +1,109 @@
+package controlservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+const (
+	maxArtifactManifestBytes int64 = 1024 * 1024
+	maxManifestArtifacts           = 1000
+	artifactManifestTimeout        = 10 * time.Second
+)
+
+// artifactManifest is the file a command writes to backend.ArtifactManifestPath.
+type artifactManifest struct {
+	Artifacts []struct {
+		Path      string `json:"path"`
+		Name      string `json:"name"`
+		MediaType string `json:"media_type"`
+	} `json:"artifacts"`
+}
+
+func parseArtifactManifest(raw []byte) ([]*cleanroomv1.ExecutionArtifact, error) {
+	var manifest artifactManifest
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&manifest); err != nil {
+		return nil, err
+	}
+	if len(manifest.Artifacts) > maxManifestArtifacts {
+		return nil, fmt.Errorf("lists %d artifacts, at most %d are allowed", len(manifest.Artifacts), maxManifestArtifacts)
+	}
+
+	out := make([]*cleanroomv1.ExecutionArtifact, 0, len(manifest.Artifacts))
+	for i, entry := range manifest.Artifacts {
+		p := strings.TrimSpace(entry.Path)
+		if !strings.HasPrefix(p, "/") {
+			return nil, fmt.Errorf("artifacts[%d].path %q must be absolute", i, entry.Path)
+		}
+		p = path.Clean(p)
+		name := strings.TrimSpace(entry.Name)
+		if name == "" {
+			name = path.Base(p)
+		}
+		out = append(out, &cleanroomv1.ExecutionArtifact{
+			Path:      p,
+			Name:      name,
+			MediaType: strings.TrimSpace(entry.MediaType),
+		})
+	}
+	return out, nil
+}
+
+// takeExecutionArtifacts reads the artifact manifest a finished execution left
+// in its sandbox. Backends that cannot read one report no artifacts.
+func takeExecutionArtifacts(adapter backend.Adapter, sandboxID string) ([]*cleanroomv1.ExecutionArtifact, error) {
+	if _, ok := adapter.(backend.PersistentSandboxAdapter); !ok {
+		return nil, nil
+	}
+	reader, ok := adapter.(backend.ArtifactManifestAdapter)
+	if !ok {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), artifactManifestTimeout)
+	defer cancel()
+	raw, err := reader.TakeArtifactManifest(ctx, sandboxID, maxArtifactManifestBytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, nil
+	}
+	return parseArtifactManifest(raw)
+}
+
+// checkChangeCapture rejects change capture on backends whose guest cannot
+// publish the record as artifacts.
+func checkChangeCapture(mode, backendName string, adapter backend.Adapter) error {
+	if mode == backend.ChangeCaptureNone {
+		return nil
+	}
+	if !backend.CapabilitiesForAdapter(adapter)[backend.CapabilityExecCaptureChanges] {
+		return fmt.Errorf("backend %q does not support capturing filesystem changes", backendName)
+	}
+	return nil
+}
+
+func cloneArtifacts(in []*cleanroomv1.ExecutionArtifact) []*cleanroomv1.ExecutionArtifact {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make([]*cleanroomv1.ExecutionArtifact, 0, len(in))
+	for _, artifact := range in {
+		out = append(out, &cleanroomv1.ExecutionArtifact{
+			Path:      artifact.GetPath(),
+			Name:      artifact.GetName(),
+			MediaType: artifact.GetMediaType(),
+		})
+	}
+	return out
+}
@@ -0,0 +1,171 @@
+package controlservice
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+func TestParseArtifactManifest(t *testing.T) {
+	t.Parallel()
+
+	artifacts, err := parseArtifactManifest([]byte(`{"artifacts": [
+		{"path": "/workspace/dist/../dist/app.tar.gz", "media_type": "application/gzip"},
+		{"path": "/workspace/report.xml", "name": "junit"}
+	]}`))
+	if err != nil {
+		t.Fatalf("parseArtifactManifest returned error: %v", err)
+	}
+	if got, want := len(artifacts), 2; got != want {
+		t.Fatalf("unexpected artifact count: got %d want %d", got, want)
+	}
+	if got, want := artifacts[0].GetPath(), "/workspace/dist/app.tar.gz"; got != want {
+		t.Fatalf("unexpected path: got %q want %q", got, want)
+	}
+	if got, want := artifacts[0].GetName(), "app.tar.gz"; got != want {
+		t.Fatalf("unexpected default name: got %q want %q", got, want)
+	}
+	if got, want := artifacts[0].GetMediaType(), "application/gzip"; got != want {
+		t.Fatalf("unexpected media type: got %q want %q", got, want)
+	}
+	if got, want := artifacts[1].GetName(), "junit"; got != want {
+		t.Fatalf("unexpected name: got %q want %q", got, want)
+	}
+}
+
+func TestParseArtifactManifestRejectsInvalidEntries(t *testing.T) {
+	t.Parallel()
+
+	for name, raw := range map[string]string{
+		"relative path": `{"artifacts": [{"path": "dist/app"}]}`,
+		"unknown field": `{"artifacts": [{"path": "/a", "size": 1}]}`,
+		"not json":      `dist/app`,
+	} {
+		if _, err := parseArtifactManifest([]byte(raw)); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestExecutionRecordsArtifactManifest(t *testing.T) {
+	adapter := &stubAdapter{
+		manifestFn: func(context.Context, string) ([]byte, error) {
+			return []byte(`{"artifacts": [{"path": "/workspace/out.bin"}]}`), nil
+		},
+	}
+	svc := newTestService(adapter)
+	sandboxID, executionID := runExecutionToCompletion(t, svc)
+
+	getResp, err := svc.GetExecution(context.Background(), &cleanroomv1.GetExecutionRequest{
+		SandboxId:   sandboxID,
+		ExecutionId: executionID,
+	})
+	if err != nil {
+		t.Fatalf("GetExecution returned error: %v", err)
+	}
+	artifacts := getResp.GetExecution().GetArtifacts()
+	if len(artifacts) != 1 || artifacts[0].GetPath() != "/workspace/out.bin" || artifacts[0].GetName() != "out.bin" {
+		t.Fatalf("unexpected execution artifacts: %v", artifacts)
+	}
+}
+
+func TestExecutionReportsUnreadableArtifactManifestOnStderr(t *testing.T) {
+	adapter := &stubAdapter{
+		manifestFn: func(context.Context, string) ([]byte, error) {
+			return nil, errors.New("head: permission denied")
+		},
+	}
+	svc := newTestService(adapter)
+	sandboxID, executionID := runExecutionToCompletion(t, svc)
+
+	getResp, err := svc.GetExecution(context.Background(), &cleanroomv1.GetExecutionRequest{
+		SandboxId:   sandboxID,
+		ExecutionId: executionID,
+	})
+	if err != nil {
+		t.Fatalf("GetExecution returned error: %v", err)
+	}
+	if got := getResp.GetExecution().GetStatus(); got != cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SUCCEEDED {
+		t.Fatalf("unexpected status: got %v want succeeded", got)
+	}
+	if got := getResp.GetExecution().GetArtifacts(); len(got) != 0 {
+		t.Fatalf("expected no artifacts, got %v", got)
+	}
+
+	svc.mu.Lock()
+	stderr := svc.executions[executionKey(sandboxID, executionID)].Stderr
+	svc.mu.Unlock()
+	if !strings.Contains(stderr, "ignoring artifact manifest") {
+		t.Fatalf("expected manifest warning on stderr, got %q", stderr)
+	}
+}
+
+func runExecutionToCompletion(t *testing.T, svc *Service) (string, string) {
+	t.Helper()
+
+	createSandboxResp, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sandboxID := createSandboxResp.GetSandbox().GetSandboxId()
+	createExecutionResp, err := svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   []string{"make", "dist"},
+	})
+	if err != nil {
+		t.Fatalf("CreateExecution returned error: %v", err)
+	}
+	executionID := createExecutionResp.GetExecution().GetExecutionId()
+
+	history, updates, done, unsubscribe, err := svc.SubscribeExecutionEvents(sandboxID, executionID)
+	if err != nil {
+		t.Fatalf("SubscribeExecutionEvents returned error: %v", err)
+	}
+	defer unsubscribe()
+	collectExecutionEvents(t, history, updates, done)
+	return sandboxID, executionID
+}
+
+func TestCreateExecutionChecksChangeCaptureSupport(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		adapter backend.Adapter
+		wantErr bool
+	}{
+		{&stubAdapter{}, true},
+		{&changeCaptureAdapter{}, false},
+	} {
+		svc := newTestService(tc.adapter)
+		createSandboxResp, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+		if err != nil {
+			t.Fatalf("CreateSandbox returned error: %v", err)
+		}
+		_, err = svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+			SandboxId: createSandboxResp.GetSandbox().GetSandboxId(),
+			Command:   []string{"make"},
+			Options:   &cleanroomv1.ExecutionOptions{CaptureChanges: cleanroomv1.ExecutionChangeCapture_EXECUTION_CHANGE_CAPTURE_ARCHIVE},
+		})
+		if tc.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "capturing filesystem changes") {
+				t.Fatalf("expected unsupported change capture error, got %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("CreateExecution returned error: %v", err)
+		}
+	}
+}
+
+type changeCaptureAdapter struct {
+	stubAdapter
+}
+
+func (*changeCaptureAdapter) Capabilities() map[string]bool {
+	return map[string]bool{backend.CapabilityExecCaptureChanges: true}
+}
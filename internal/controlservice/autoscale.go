@@ -0,0 +1,108 @@
+package controlservice
+
+import (
+	"runtime"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// AutoscaleSchemaVersion is bumped only for incompatible changes to
+// AutoscaleStatus. New fields may be added without a bump.
+const AutoscaleSchemaVersion = 1
+
+// AutoscaleStatus summarises a server's load and spare capacity for
+// autoscalers deciding whether to add or remove hosts.
+type AutoscaleStatus struct {
+	SchemaVersion int                 `json:"schema_version"`
+	Sandboxes     AutoscaleSandboxes  `json:"sandboxes"`
+	Executions    AutoscaleExecutions `json:"executions"`
+	Capacity      AutoscaleCapacity   `json:"capacity"`
+}
+
+// AutoscaleSandboxes counts sandboxes that hold or are about to hold a VM.
+type AutoscaleSandboxes struct {
+	// Provisioning sandboxes are still being created.
+	Provisioning int `json:"provisioning"`
+	Ready        int `json:"ready"`
+	// Idle sandboxes are ready with nothing running in them.
+	Idle int `json:"idle"`
+	// Paused sandboxes keep their memory but use no CPU.
+	Paused   int `json:"paused"`
+	Stopping int `json:"stopping"`
+}
+
+// AutoscaleExecutions counts executions that have not finished. Queued and
+// PendingApproval ones are waiting to start.
+type AutoscaleExecutions struct {
+	Queued          int `json:"queued"`
+	PendingApproval int `json:"pending_approval"`
+	Running         int `json:"running"`
+}
+
+// AutoscaleCapacity compares the host's CPUs and memory with what live
+// sandboxes were sized to. Sandboxes may be sized beyond the host, so
+// Available can be negative.
+type AutoscaleCapacity struct {
+	VCPUs     AutoscaleResource `json:"vcpus"`
+	MemoryMiB AutoscaleResource `json:"memory_mib"`
+}
+
+// AutoscaleResource is one resource's host total and sandbox allocation.
+type AutoscaleResource struct {
+	Total     int64 `json:"total"`
+	Allocated int64 `json:"allocated"`
+	Available int64 `json:"available"`
+}
+
+// AutoscaleStatus reports the server's current load and capacity.
+func (s *Service) AutoscaleStatus() AutoscaleStatus {
+	status := AutoscaleStatus{SchemaVersion: AutoscaleSchemaVersion}
+	status.Capacity.VCPUs.Total = int64(runtime.NumCPU())
+	status.Capacity.MemoryMiB.Total = hostMemoryBytes() / (1024 * 1024)
+
+	s.mu.RLock()
+	status.Sandboxes.Provisioning = s.provisioning
+	for _, sb := range s.sandboxes {
+		switch sb.Status {
+		case cleanroomv1.SandboxStatus_SANDBOX_STATUS_PROVISIONING:
+			status.Sandboxes.Provisioning++
+		case cleanroomv1.SandboxStatus_SANDBOX_STATUS_READY:
+			status.Sandboxes.Ready++
+			if sb.ActiveExecutionID == "" && sb.BusyWith == "" {
+				status.Sandboxes.Idle++
+			}
+		case cleanroomv1.SandboxStatus_SANDBOX_STATUS_PAUSED:
+			status.Sandboxes.Paused++
+		case cleanroomv1.SandboxStatus_SANDBOX_STATUS_STOPPING:
+			status.Sandboxes.Stopping++
+		default:
+			continue
+		}
+		status.Capacity.VCPUs.Allocated += sandboxSize(sb.Firecracker.VCPUs, backend.DefaultVCPUs)
+		status.Capacity.MemoryMiB.Allocated += sandboxSize(sb.Firecracker.MemoryMiB, backend.DefaultMemoryMiB)
+	}
+	for _, ex := range s.executions {
+		switch ex.Status {
+		case cleanroomv1.ExecutionStatus_EXECUTION_STATUS_QUEUED:
+			status.Executions.Queued++
+		case cleanroomv1.ExecutionStatus_EXECUTION_STATUS_PENDING_APPROVAL:
+			status.Executions.PendingApproval++
+		case cleanroomv1.ExecutionStatus_EXECUTION_STATUS_RUNNING:
+			status.Executions.Running++
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, resource := range []*AutoscaleResource{&status.Capacity.VCPUs, &status.Capacity.MemoryMiB} {
+		resource.Available = resource.Total - resource.Allocated
+	}
+	return status
+}
+
+func sandboxSize(configured, fallback int64) int64 {
+	if configured > 0 {
+		return configured
+	}
+	return fallback
+}
@@ -0,0 +1,71 @@
+package controlservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+func TestAutoscaleStatusCountsLoadAndAllocation(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	adapter := &stubAdapter{
+		runFn: func(_ context.Context, req backend.RunRequest) (*backend.RunResult, error) {
+			close(started)
+			<-release
+			return &backend.RunResult{RunID: req.RunID}, nil
+		},
+	}
+	svc := newTestService(adapter)
+	svc.Config.Backends.Firecracker.VCPUs = 2
+
+	var sandboxIDs []string
+	for range 2 {
+		resp, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+		if err != nil {
+			t.Fatalf("CreateSandbox returned error: %v", err)
+		}
+		sandboxIDs = append(sandboxIDs, resp.GetSandbox().GetSandboxId())
+	}
+	created, err := svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxIDs[0],
+		Command:   []string{"sleep", "1"},
+	})
+	if err != nil {
+		t.Fatalf("CreateExecution returned error: %v", err)
+	}
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("execution did not start")
+	}
+
+	status := svc.AutoscaleStatus()
+	if status.SchemaVersion != AutoscaleSchemaVersion {
+		t.Fatalf("unexpected schema version %d", status.SchemaVersion)
+	}
+	if got, want := status.Sandboxes, (AutoscaleSandboxes{Ready: 2, Idle: 1}); got != want {
+		t.Fatalf("unexpected sandbox counts: got %+v want %+v", got, want)
+	}
+	if got, want := status.Executions, (AutoscaleExecutions{Running: 1}); got != want {
+		t.Fatalf("unexpected execution counts: got %+v want %+v", got, want)
+	}
+	vcpus := status.Capacity.VCPUs
+	if vcpus.Allocated != 4 || vcpus.Total <= 0 || vcpus.Available != vcpus.Total-4 {
+		t.Fatalf("unexpected vcpu capacity: %+v", vcpus)
+	}
+	if got, want := status.Capacity.MemoryMiB.Allocated, 2*backend.DefaultMemoryMiB; got != want {
+		t.Fatalf("unexpected allocated memory: got %d want %d", got, want)
+	}
+
+	close(release)
+	waitForExecutionStatus(t, svc, sandboxIDs[0], created.GetExecution().GetExecutionId())
+	if got, want := svc.AutoscaleStatus().Sandboxes, (AutoscaleSandboxes{Ready: 2, Idle: 2}); got != want {
+		t.Fatalf("unexpected sandbox counts after execution: got %+v want %+v", got, want)
+	}
+}
@@ -0,0 +1,185 @@
+package controlservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+	"github.com/buildkite/cleanroom/internal/usage"
+)
+
+// ErrBudgetExceeded is returned by CreateSandbox when the caller's
+// namespace or identity has used up its monthly budget.
+var ErrBudgetExceeded = errors.New("monthly budget exceeded")
+
+// ErrBudgetUnavailable is returned by CreateSandbox when a budget with a
+// limit applies but this month's usage cannot be read to check it.
+var ErrBudgetUnavailable = errors.New("monthly budget cannot be checked")
+
+const defaultBudgetSoftPercent = 80
+
+// budgetAlert is posted to budgets.webhook_url the first time in a month a
+// budget passes its soft or hard threshold.
+type budgetAlert struct {
+	Threshold      string  `json:"threshold"` // soft or hard
+	Subject        string  `json:"subject"`   // namespace or identity
+	Name           string  `json:"name"`
+	Month          string  `json:"month"` // 2006-01, UTC
+	Percent        float64 `json:"percent"`
+	VCPUHours      float64 `json:"vcpu_hours"`
+	VCPUHoursLimit float64 `json:"vcpu_hours_limit,omitempty"`
+	MemoryGiBHours float64 `json:"memory_gib_hours"`
+	MemoryGiBLimit float64 `json:"memory_gib_hours_limit,omitempty"`
+}
+
+// describe says how much of which limit the alert's budget has used.
+func (a budgetAlert) describe() string {
+	var used []string
+	if a.VCPUHoursLimit > 0 {
+		used = append(used, fmt.Sprintf("%.1f of %g vCPU-hours", a.VCPUHours, a.VCPUHoursLimit))
+	}
+	if a.MemoryGiBLimit > 0 {
+		used = append(used, fmt.Sprintf("%.1f of %g memory GiB-hours", a.MemoryGiBHours, a.MemoryGiBLimit))
+	}
+	return fmt.Sprintf("%s %q has used %.0f%% of its budget for %s (%s)", a.Subject, a.Name, a.Percent, a.Month, strings.Join(used, ", "))
+}
+
+// checkBudgets measures this month's usage against the budgets of the
+// namespace and identity a new sandbox would be charged to. It fails with
+// ErrBudgetExceeded once either is used up, and otherwise returns a
+// warning for each past its soft threshold. When usage cannot be read it
+// fails closed with ErrBudgetUnavailable if either budget sets a limit.
+func (s *Service) checkBudgets(ctx context.Context, cfg runtimeconfig.Budgets, namespace, identity string) ([]string, error) {
+	type applicable struct {
+		subject, name string
+		budget        runtimeconfig.Budget
+		match         func(usage.Record) bool
+	}
+	var budgets []applicable
+	if budget, ok := cfg.Namespaces[namespace]; ok {
+		budgets = append(budgets, applicable{"namespace", namespace, budget, func(r usage.Record) bool { return r.Namespace == namespace }})
+	}
+	if budget, ok := cfg.Identities[identity]; ok && identity != "" {
+		budgets = append(budgets, applicable{"identity", identity, budget, func(r usage.Record) bool { return r.Identity == identity }})
+	}
+	if len(budgets) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now().UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	var records []usage.Record
+	if s.Usage != nil {
+		stored, err := s.Usage.Records(ctx, usage.KindSandbox, monthStart)
+		if err != nil {
+			for _, b := range budgets {
+				if b.budget.VCPUHours > 0 || b.budget.MemoryGiBHours > 0 {
+					if logger := s.logger(ctx); logger != nil {
+						logger.Error("budget check failed, refusing sandbox", "subject", b.subject, "name", b.name, "error", err)
+					}
+					return nil, fmt.Errorf("%w: cannot read usage for %s %q: %v", ErrBudgetUnavailable, b.subject, b.name, err)
+				}
+			}
+			return nil, nil
+		}
+		records = stored
+	}
+	s.mu.RLock()
+	records = append(records, s.liveUsageLocked(usage.KindSandbox, now)...)
+	s.mu.RUnlock()
+
+	var warnings []string
+	for _, b := range budgets {
+		var totals usage.Totals
+		for _, r := range records {
+			if b.match(r) {
+				within := r.Within(monthStart, now)
+				totals.VCPUSeconds += within.VCPUSeconds
+				totals.MemoryMiBSeconds += within.MemoryMiBSeconds
+			}
+		}
+		alert := budgetAlert{
+			Subject:        b.subject,
+			Name:           b.name,
+			Month:          monthStart.Format("2006-01"),
+			VCPUHours:      totals.VCPUSeconds / 3600,
+			VCPUHoursLimit: b.budget.VCPUHours,
+			MemoryGiBHours: totals.MemoryMiBSeconds / 1024 / 3600,
+			MemoryGiBLimit: b.budget.MemoryGiBHours,
+		}
+		if alert.VCPUHoursLimit > 0 {
+			alert.Percent = max(alert.Percent, 100*alert.VCPUHours/alert.VCPUHoursLimit)
+		}
+		if alert.MemoryGiBLimit > 0 {
+			alert.Percent = max(alert.Percent, 100*alert.MemoryGiBHours/alert.MemoryGiBLimit)
+		}
+		soft := float64(defaultBudgetSoftPercent)
+		if b.budget.SoftPercent > 0 {
+			soft = float64(b.budget.SoftPercent)
+		}
+		switch {
+		case alert.Percent >= 100:
+			alert.Threshold = "hard"
+			s.sendBudgetAlert(cfg.WebhookURL, alert)
+			return nil, fmt.Errorf("%w: %s", ErrBudgetExceeded, alert.describe())
+		case alert.Percent >= soft:
+			alert.Threshold = "soft"
+			s.sendBudgetAlert(cfg.WebhookURL, alert)
+			warnings = append(warnings, "budget warning: "+alert.describe())
+		}
+	}
+	return warnings, nil
+}
+
+// sendBudgetAlert posts alert to url in the background, once per budget,
+// threshold and month. Delivery is best effort.
+func (s *Service) sendBudgetAlert(url string, alert budgetAlert) {
+	if strings.TrimSpace(url) == "" {
+		return
+	}
+	key := strings.Join([]string{alert.Threshold, alert.Subject, alert.Name, alert.Month}, "\x00")
+	s.mu.Lock()
+	if s.budgetAlerts == nil {
+		s.budgetAlerts = map[string]struct{}{}
+	}
+	_, sent := s.budgetAlerts[key]
+	s.budgetAlerts[key] = struct{}{}
+	s.mu.Unlock()
+	if sent {
+		return
+	}
+	go func() {
+		if err := postBudgetAlert(url, alert); err != nil && s.Logger != nil {
+			s.Logger.Warn("budget webhook failed", "subject", alert.Subject, "name", alert.Name, "threshold", alert.Threshold, "error", err)
+		}
+	}()
+}
+
+func postBudgetAlert(url string, alert budgetAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), approvalWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
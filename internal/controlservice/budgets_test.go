@@ -0,0 +1,126 @@
+package controlservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+	"github.com/buildkite/cleanroom/internal/usage"
+)
+
+func TestCreateSandboxEnforcesMonthlyBudgets(t *testing.T) {
+	now := time.Now().UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if now.Sub(monthStart) < time.Minute {
+		t.Skip("too close to the start of the month to have used a budget")
+	}
+	// team-a's earlier sandbox reserved 4 vCPUs for the month so far.
+	store := &memoryUsageStore{}
+	_ = store.Add(context.Background(), usage.Record{
+		Kind: usage.KindSandbox, ID: "earlier", SandboxID: "earlier", Namespace: "team-a", Identity: "uid:1001",
+		StartedAt: monthStart.Add(-time.Hour), EndedAt: now, VCPUs: 4,
+	})
+	used := 4 * now.Sub(monthStart).Hours()
+
+	var mu sync.Mutex
+	var alerts []budgetAlert
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert budgetAlert
+		_ = json.NewDecoder(r.Body).Decode(&alert)
+		mu.Lock()
+		alerts = append(alerts, alert)
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+
+	svc := newTestService(&stubAdapter{})
+	svc.Usage = store
+	svc.Config.Namespaces = runtimeconfig.Namespaces{Identities: map[string]string{"uid:1001": "team-a"}}
+	svc.Config.Budgets = runtimeconfig.Budgets{
+		Namespaces: map[string]runtimeconfig.Budget{"team-a": {VCPUHours: used / 0.9}},
+		WebhookURL: webhook.URL,
+	}
+	teamA := WithCallerIdentity(context.Background(), "uid:1001")
+
+	for range 2 {
+		resp, err := svc.CreateSandbox(teamA, &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+		if err != nil {
+			t.Fatalf("CreateSandbox returned error: %v", err)
+		}
+		if !strings.Contains(resp.GetMessage(), `budget warning: namespace "team-a" has used 90% of its budget`) {
+			t.Fatalf("expected a budget warning, got %q", resp.GetMessage())
+		}
+		if _, err := svc.TerminateSandbox(teamA, &cleanroomv1.TerminateSandboxRequest{SandboxId: resp.GetSandbox().GetSandboxId()}); err != nil {
+			t.Fatalf("TerminateSandbox returned error: %v", err)
+		}
+	}
+	// Other namespaces have no budget.
+	if _, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()}); err != nil {
+		t.Fatalf("CreateSandbox in the default namespace returned error: %v", err)
+	}
+
+	svc.Config.Budgets.Namespaces["team-a"] = runtimeconfig.Budget{VCPUHours: used / 2}
+	_, err := svc.CreateSandbox(teamA, &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if !errors.Is(err, ErrBudgetExceeded) || !strings.Contains(err.Error(), "vCPU-hours") {
+		t.Fatalf("expected the used-up budget to reject the sandbox, got %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		got := append([]budgetAlert(nil), alerts...)
+		mu.Unlock()
+		if len(got) == 2 {
+			thresholds := map[string]bool{}
+			for _, alert := range got {
+				if alert.Name != "team-a" || alert.Month != monthStart.Format("2006-01") {
+					t.Fatalf("unexpected alert: %+v", alert)
+				}
+				thresholds[alert.Threshold] = true
+			}
+			if !thresholds["soft"] || !thresholds["hard"] {
+				t.Fatalf("expected one soft and one hard alert, got %+v", got)
+			}
+			break
+		}
+		if len(got) > 2 || time.Now().After(deadline) {
+			t.Fatalf("expected one soft and one hard alert, got %+v", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type failingUsageStore struct{}
+
+func (failingUsageStore) Add(context.Context, usage.Record) error { return nil }
+
+func (failingUsageStore) Records(context.Context, string, time.Time) ([]usage.Record, error) {
+	return nil, errors.New("database is locked")
+}
+
+func TestCreateSandboxFailsClosedWhenUsageCannotBeRead(t *testing.T) {
+	svc := newTestService(&stubAdapter{})
+	svc.Usage = failingUsageStore{}
+	svc.Config.Namespaces = runtimeconfig.Namespaces{Identities: map[string]string{"uid:1001": "team-a", "uid:1002": "team-b"}}
+	svc.Config.Budgets = runtimeconfig.Budgets{Namespaces: map[string]runtimeconfig.Budget{
+		"team-a": {VCPUHours: 100},
+		"team-b": {SoftPercent: 50},
+	}}
+
+	_, err := svc.CreateSandbox(WithCallerIdentity(context.Background(), "uid:1001"), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if !errors.Is(err, ErrBudgetUnavailable) || !strings.Contains(err.Error(), "database is locked") {
+		t.Fatalf("expected an unreadable budget to refuse the sandbox, got %v", err)
+	}
+	// A budget without limits has nothing to enforce.
+	if _, err := svc.CreateSandbox(WithCallerIdentity(context.Background(), "uid:1002"), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()}); err != nil {
+		t.Fatalf("CreateSandbox under a budget without limits returned error: %v", err)
+	}
+}
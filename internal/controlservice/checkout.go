@@ -0,0 +1,139 @@
+package controlservice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/checkout"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/policy"
+)
+
+const checkoutChunkSize = 256 * 1024
+
+// CheckoutFetcher fetches a repository ref on the host. *checkout.Fetcher
+// implements it.
+type CheckoutFetcher interface {
+	Fetch(ctx context.Context, repository, ref string) (*checkout.Archive, error)
+}
+
+// prepareCheckout validates a requested checkout and fetches it on the host
+// before any VM is provisioned, so a bad ref fails fast. It returns nil when
+// no checkout was requested. The caller closes the returned archive.
+func (s *Service) prepareCheckout(ctx context.Context, req *cleanroomv1.SandboxCheckout, compiled *policy.CompiledPolicy, adapter backend.Adapter) (*cleanroomv1.SandboxCheckout, *checkout.Archive, error) {
+	if req == nil {
+		return nil, nil, nil
+	}
+	repository := strings.TrimSpace(req.GetRepository())
+	if repository == "" {
+		return nil, nil, errors.New("checkout requires a repository")
+	}
+	host, err := checkout.RepositoryHost(repository)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !compiled.Allows(host, 443) {
+		return nil, nil, fmt.Errorf("checkout repository host %q is not allowed by sandbox.network.allow", host)
+	}
+	if _, ok := adapter.(backend.PersistentSandboxAdapter); !ok {
+		return nil, nil, fmt.Errorf("checkout needs a backend with persistent sandboxes; %q boots a VM per execution", adapter.Name())
+	}
+	dest := strings.TrimSpace(req.GetPath())
+	if dest == "" {
+		dest = checkout.DefaultPath
+	}
+	if !path.IsAbs(dest) || path.Clean(dest) == "/" {
+		return nil, nil, fmt.Errorf("invalid checkout path %q: must be an absolute directory other than /", req.GetPath())
+	}
+	if s.Checkout == nil {
+		return nil, nil, errors.New("checkout is not configured on this server")
+	}
+
+	ref := strings.TrimSpace(req.GetRef())
+	archive, err := s.Checkout.Fetch(ctx, repository, ref)
+	if err != nil {
+		return nil, nil, fmt.Errorf("checkout %s: %w", repository, err)
+	}
+	return &cleanroomv1.SandboxCheckout{
+		Repository: repository,
+		Ref:        ref,
+		Path:       path.Clean(dest),
+		Commit:     archive.Commit,
+	}, archive, nil
+}
+
+// extractCheckout streams a fetched tree into the sandbox and unpacks it at
+// the checkout path.
+func extractCheckout(ctx context.Context, adapter backend.PersistentSandboxAdapter, sandboxID string, cfg backend.FirecrackerConfig, co *cleanroomv1.SandboxCheckout, archivePath string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var stderr bytes.Buffer
+	var attached atomic.Bool
+	copied := make(chan error, 1)
+	result, err := adapter.RunInSandbox(ctx, backend.RunRequest{
+		SandboxID:         sandboxID,
+		RunID:             newRunID(),
+		Command:           []string{"sh", "-c", `mkdir -p "$1" && tar -x -f - -C "$1"`, "sh", co.GetPath()},
+		Stdin:             true,
+		FirecrackerConfig: cfg,
+	}, backend.OutputStream{
+		OnStderr: func(chunk []byte) {
+			if stderr.Len() < 4096 {
+				_, _ = stderr.Write(chunk)
+			}
+		},
+		OnAttach: func(attach backend.AttachIO) {
+			attached.Store(true)
+			go func() {
+				copied <- copyToStdin(f, attach)
+			}()
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("extract checkout: %w", err)
+	}
+	if result.ExitCode != 0 {
+		msg := strings.TrimSpace(stderr.String() + result.Stderr)
+		if msg == "" {
+			msg = fmt.Sprintf("tar exited %d", result.ExitCode)
+		}
+		return fmt.Errorf("extract checkout: %s", msg)
+	}
+	if !attached.Load() {
+		return errors.New("stream checkout: backend did not accept stdin")
+	}
+	if err := <-copied; err != nil {
+		return fmt.Errorf("stream checkout: %w", err)
+	}
+	return nil
+}
+
+func copyToStdin(r io.Reader, attach backend.AttachIO) error {
+	buf := make([]byte, checkoutChunkSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if writeErr := attach.WriteStdin(append([]byte(nil), buf[:n]...)); writeErr != nil {
+				return writeErr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return attach.CloseStdin()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
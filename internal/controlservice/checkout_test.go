@@ -0,0 +1,139 @@
+package controlservice
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/checkout"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+type stubCheckoutFetcher struct {
+	archive string
+	calls   int
+	ref     string
+}
+
+func (f *stubCheckoutFetcher) Fetch(_ context.Context, _ string, ref string) (*checkout.Archive, error) {
+	f.calls++
+	f.ref = ref
+	return &checkout.Archive{Commit: "0123abcd", Path: f.archive}, nil
+}
+
+func newCheckoutTestService(t *testing.T, adapter *stubAdapter) (*Service, *stubCheckoutFetcher) {
+	t.Helper()
+	archive := filepath.Join(t.TempDir(), "tree.tar")
+	if err := os.WriteFile(archive, []byte("tar-bytes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fetcher := &stubCheckoutFetcher{archive: archive}
+	svc := newTestService(adapter)
+	svc.Checkout = fetcher
+	return svc, fetcher
+}
+
+func checkoutTestPolicy() *cleanroomv1.Policy {
+	pb := testPolicy()
+	pb.Allow = []*cleanroomv1.PolicyAllowRule{{Host: "github.com", Ports: []int32{443}}}
+	return pb
+}
+
+func TestCreateSandboxStreamsCheckoutIntoSandbox(t *testing.T) {
+	t.Parallel()
+
+	var streamed bytes.Buffer
+	adapter := &stubAdapter{}
+	adapter.runStreamFn = func(_ context.Context, req backend.RunRequest, stream backend.OutputStream) (*backend.RunResult, error) {
+		if !req.Stdin {
+			t.Errorf("expected checkout extraction to keep stdin open")
+		}
+		closed := make(chan struct{})
+		stream.OnAttach(backend.AttachIO{
+			WriteStdin: func(b []byte) error { streamed.Write(b); return nil },
+			CloseStdin: func() error { close(closed); return nil },
+		})
+		<-closed
+		return &backend.RunResult{ExitCode: 0}, nil
+	}
+	svc, fetcher := newCheckoutTestService(t, adapter)
+
+	resp, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{
+		Policy:   checkoutTestPolicy(),
+		Checkout: &cleanroomv1.SandboxCheckout{Repository: "https://github.com/org/repo.git", Ref: "main"},
+	})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	if got := streamed.String(); got != "tar-bytes" {
+		t.Fatalf("unexpected streamed archive: %q", got)
+	}
+	if got := strings.Join(adapter.req.Command, " "); !strings.Contains(got, "tar -x") || !strings.HasSuffix(got, checkout.DefaultPath) {
+		t.Fatalf("unexpected extraction command: %q", got)
+	}
+	if fetcher.ref != "main" {
+		t.Fatalf("unexpected fetched ref: got %q want %q", fetcher.ref, "main")
+	}
+
+	co := resp.GetSandbox().GetCheckout()
+	if co.GetCommit() != "0123abcd" || co.GetPath() != checkout.DefaultPath {
+		t.Fatalf("unexpected recorded checkout: %+v", co)
+	}
+	if !strings.Contains(resp.GetMessage(), "checked out https://github.com/org/repo.git at 0123abcd") {
+		t.Fatalf("expected checkout in create message, got %q", resp.GetMessage())
+	}
+	got, err := svc.GetSandbox(context.Background(), &cleanroomv1.GetSandboxRequest{SandboxId: resp.GetSandbox().GetSandboxId()})
+	if err != nil {
+		t.Fatalf("GetSandbox returned error: %v", err)
+	}
+	if got.GetSandbox().GetCheckout().GetCommit() != "0123abcd" {
+		t.Fatalf("expected GetSandbox to report the checkout commit, got %+v", got.GetSandbox().GetCheckout())
+	}
+}
+
+func TestCreateSandboxCheckoutRequiresAllowedHost(t *testing.T) {
+	t.Parallel()
+
+	adapter := &stubAdapter{}
+	svc, fetcher := newCheckoutTestService(t, adapter)
+
+	_, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{
+		Policy:   checkoutTestPolicy(),
+		Checkout: &cleanroomv1.SandboxCheckout{Repository: "https://gitlab.com/org/repo.git"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "sandbox.network.allow") {
+		t.Fatalf("expected host policy error, got %v", err)
+	}
+	if fetcher.calls != 0 || adapter.provisionCalls != 0 {
+		t.Fatalf("expected no fetch or provision: fetches=%d provisions=%d", fetcher.calls, adapter.provisionCalls)
+	}
+}
+
+func TestCreateSandboxTerminatesWhenCheckoutExtractionFails(t *testing.T) {
+	t.Parallel()
+
+	adapter := &stubAdapter{}
+	adapter.runStreamFn = func(_ context.Context, _ backend.RunRequest, _ backend.OutputStream) (*backend.RunResult, error) {
+		return &backend.RunResult{ExitCode: 2, Stderr: "tar: short read"}, nil
+	}
+	svc, _ := newCheckoutTestService(t, adapter)
+
+	_, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{
+		Policy:   checkoutTestPolicy(),
+		Checkout: &cleanroomv1.SandboxCheckout{Repository: "https://github.com/org/repo.git"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "tar: short read") {
+		t.Fatalf("expected extraction error, got %v", err)
+	}
+	if adapter.terminateCalls != 1 {
+		t.Fatalf("expected sandbox to be terminated after failed checkout, got %d terminate calls", adapter.terminateCalls)
+	}
+	list, err := svc.ListSandboxes(context.Background(), &cleanroomv1.ListSandboxesRequest{})
+	if err != nil || len(list.GetSandboxes()) != 0 {
+		t.Fatalf("expected no sandboxes after failed checkout: %v %v", list.GetSandboxes(), err)
+	}
+}
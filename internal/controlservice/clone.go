@@ -0,0 +1,135 @@
+package controlservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/ociref"
+	"github.com/buildkite/cleanroom/internal/policy"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxSandboxClones bounds how many clones one CloneSandbox call creates.
+const maxSandboxClones = 16
+
+// clonedFromLabel names the sandbox a clone was copied from.
+const clonedFromLabel = "cloned-from"
+
+// CloneSandbox creates sandboxes with the policy, backend, labels,
+// checkout and pinned host resolutions of an existing one, optionally
+// booted from a commit of its rootfs. Clones are created one after another
+// in the source's namespace and get no name; if one fails, those already
+// created are terminated.
+func (s *Service) CloneSandbox(ctx context.Context, req *cleanroomv1.CloneSandboxRequest) (*cleanroomv1.CloneSandboxResponse, error) {
+	if req == nil {
+		return nil, errors.New("missing request")
+	}
+	sandboxID := strings.TrimSpace(req.GetSandboxId())
+	if sandboxID == "" {
+		return nil, errors.New("missing sandbox_id")
+	}
+	count := int(req.GetCount())
+	if count == 0 {
+		count = 1
+	}
+	if count < 0 || count > maxSandboxClones {
+		return nil, fmt.Errorf("invalid count %d: must be between 1 and %d", count, maxSandboxClones)
+	}
+	labels, err := sandboxLabelsFromProto(req.GetLabels())
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := s.runtimeConfig()
+	s.mu.RLock()
+	source, ok := s.sandboxes[sandboxID]
+	var template *cleanroomv1.CreateSandboxRequest
+	if ok && canAccessNamespace(ctx, cfg.Namespaces, namespaceOrDefault(source.Namespace)) {
+		template = cloneTemplateLocked(source)
+	}
+	s.mu.RUnlock()
+	if template == nil {
+		return nil, fmt.Errorf("%w %q", ErrUnknownSandbox, sandboxID)
+	}
+	if template.GetPolicy() == nil {
+		return nil, fmt.Errorf("sandbox %q has no policy to clone", sandboxID)
+	}
+	maps.Copy(template.Labels, labels)
+
+	resp := &cleanroomv1.CloneSandboxResponse{}
+	if ref := strings.TrimSpace(req.GetSnapshotRef()); ref != "" {
+		committed, err := s.CommitSandbox(ctx, &cleanroomv1.CommitSandboxRequest{SandboxId: sandboxID, Ref: ref})
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := ociref.ParseDigestReference(committed.GetImageRef())
+		if err != nil {
+			return nil, fmt.Errorf("snapshot %q: %w", committed.GetImageRef(), err)
+		}
+		template.Policy.ImageRef = parsed.Original
+		template.Policy.ImageDigest = parsed.Digest()
+		template.Policy.Hash = ""
+		if _, err := policy.FromProto(template.Policy); err != nil {
+			return nil, fmt.Errorf("boot clones from snapshot: %w", err)
+		}
+		// The snapshot already holds the source's checkout, along with any
+		// changes made to it since.
+		template.Checkout = nil
+		resp.ImageRef = parsed.Original
+	}
+
+	for range count {
+		created, err := s.CreateSandbox(ctx, proto.Clone(template).(*cleanroomv1.CreateSandboxRequest))
+		if err != nil {
+			s.terminateClones(ctx, resp.Sandboxes)
+			return nil, fmt.Errorf("clone %d of %d: %w", len(resp.Sandboxes)+1, count, err)
+		}
+		resp.Sandboxes = append(resp.Sandboxes, created.GetSandbox())
+	}
+	return resp, nil
+}
+
+// terminateClones removes the clones a failed CloneSandbox already created,
+// so a partial batch is not left running unseen.
+func (s *Service) terminateClones(ctx context.Context, clones []*cleanroomv1.Sandbox) {
+	for _, sb := range clones {
+		if _, err := s.TerminateSandbox(ctx, &cleanroomv1.TerminateSandboxRequest{SandboxId: sb.GetSandboxId()}); err != nil && s.Logger != nil {
+			s.Logger.Warn("terminate partial clone failed", "sandbox_id", sb.GetSandboxId(), "error", err)
+		}
+	}
+}
+
+// cloneTemplateLocked returns the request that creates a copy of sb. A
+// checkout is fetched at the commit sb unpacked, so clones get the same
+// tree even if the branch has moved since.
+func cloneTemplateLocked(sb *sandboxState) *cleanroomv1.CreateSandboxRequest {
+	req := &cleanroomv1.CreateSandboxRequest{
+		Backend:           sb.Backend,
+		Options:           &cleanroomv1.SandboxOptions{LaunchSeconds: sb.Firecracker.LaunchSeconds},
+		Labels:            maps.Clone(sb.Labels),
+		Namespace:         namespaceOrDefault(sb.Namespace),
+		PinnedResolutions: hostResolutionsToProto(sb.Resolutions),
+	}
+	if req.Labels == nil {
+		req.Labels = map[string]string{}
+	}
+	req.Labels[clonedFromLabel] = sb.ID
+	if sb.Policy != nil {
+		req.Policy = sb.Policy.ToProto()
+	}
+	if checkout := sb.Checkout; checkout != nil {
+		req.Checkout = &cleanroomv1.SandboxCheckout{
+			Repository: checkout.GetRepository(),
+			Ref:        checkout.GetRef(),
+			Path:       checkout.GetPath(),
+		}
+		if commit := checkout.GetCommit(); commit != "" {
+			req.Checkout.Ref = commit
+		}
+	}
+	return req
+}
@@ -0,0 +1,113 @@
+package controlservice
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+)
+
+func TestCloneSandboxCopiesDefinition(t *testing.T) {
+	adapter := &stubAdapter{}
+	svc := newTestService(adapter)
+	svc.Config.Namespaces = runtimeconfig.Namespaces{Identities: map[string]string{"uid:1001": "team-a"}}
+	teamA := WithCallerIdentity(context.Background(), "uid:1001")
+
+	created, err := svc.CreateSandbox(teamA, &cleanroomv1.CreateSandboxRequest{
+		Policy: testPolicy(),
+		Name:   "flaky-test",
+		Labels: map[string]string{"ticket": "BK-1"},
+	})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sourceID := created.GetSandbox().GetSandboxId()
+
+	if _, err := svc.CloneSandbox(teamA, &cleanroomv1.CloneSandboxRequest{SandboxId: sourceID, Count: maxSandboxClones + 1}); err == nil || !strings.Contains(err.Error(), "invalid count") {
+		t.Fatalf("expected an invalid count error, got %v", err)
+	}
+	if _, err := svc.CloneSandbox(context.Background(), &cleanroomv1.CloneSandboxRequest{SandboxId: sourceID}); err == nil || !strings.Contains(err.Error(), "unknown sandbox") {
+		t.Fatalf("expected another namespace's sandbox to be unknown, got %v", err)
+	}
+
+	resp, err := svc.CloneSandbox(teamA, &cleanroomv1.CloneSandboxRequest{
+		SandboxId: sourceID,
+		Count:     3,
+		Labels:    map[string]string{"worker": "parallel"},
+	})
+	if err != nil {
+		t.Fatalf("CloneSandbox returned error: %v", err)
+	}
+	if len(resp.GetSandboxes()) != 3 || resp.GetImageRef() != "" {
+		t.Fatalf("unexpected clone response: %+v", resp)
+	}
+	for _, sb := range resp.GetSandboxes() {
+		if sb.GetSandboxId() == sourceID || sb.GetName() != "" || sb.GetNamespace() != "team-a" || sb.GetPolicyHash() != created.GetSandbox().GetPolicyHash() {
+			t.Fatalf("unexpected clone: %+v", sb)
+		}
+		labels := sb.GetLabels()
+		if labels[clonedFromLabel] != sourceID || labels["ticket"] != "BK-1" || labels["worker"] != "parallel" {
+			t.Fatalf("unexpected clone labels: %v", labels)
+		}
+	}
+}
+
+func TestCloneSandboxBootsFromSnapshot(t *testing.T) {
+	const digest = "sha256:fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+	var committedRef string
+	adapter := &stubAdapter{
+		commitFn: func(_ context.Context, _, ref string) (string, error) {
+			committedRef = ref
+			return ref + "@" + digest, nil
+		},
+	}
+	svc := newTestService(adapter)
+	created, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+
+	resp, err := svc.CloneSandbox(context.Background(), &cleanroomv1.CloneSandboxRequest{
+		SandboxId:   created.GetSandbox().GetSandboxId(),
+		SnapshotRef: "ghcr.io/acme/debug:repro",
+	})
+	if err != nil {
+		t.Fatalf("CloneSandbox returned error: %v", err)
+	}
+	if committedRef != "ghcr.io/acme/debug:repro" || resp.GetImageRef() != "ghcr.io/acme/debug:repro@"+digest {
+		t.Fatalf("unexpected snapshot: committed %q, response %+v", committedRef, resp)
+	}
+	if got := adapter.provisionReq.Policy; got.ImageDigest != digest || got.ImageRef != resp.GetImageRef() {
+		t.Fatalf("expected the clone to boot from the snapshot, got %s (%s)", got.ImageRef, got.ImageDigest)
+	}
+	if resp.GetSandboxes()[0].GetPolicyHash() == created.GetSandbox().GetPolicyHash() {
+		t.Fatal("expected the clone's policy hash to cover the snapshot image")
+	}
+}
+
+func TestCloneSandboxTerminatesPartialClonesOnFailure(t *testing.T) {
+	adapter := &stubAdapter{}
+	svc := newTestService(adapter)
+	created, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	adapter.provisionFn = func(context.Context, backend.ProvisionRequest) error {
+		if adapter.provisionCalls == 3 {
+			return errors.New("no capacity")
+		}
+		return nil
+	}
+
+	_, err = svc.CloneSandbox(context.Background(), &cleanroomv1.CloneSandboxRequest{SandboxId: created.GetSandbox().GetSandboxId(), Count: 4})
+	if err == nil || !strings.Contains(err.Error(), "clone 2 of 4") || !strings.Contains(err.Error(), "no capacity") {
+		t.Fatalf("expected the second clone to fail, got %v", err)
+	}
+	if adapter.terminateCalls != 1 {
+		t.Fatalf("expected the first clone to be terminated, got %d terminate calls", adapter.terminateCalls)
+	}
+}
@@ -0,0 +1,75 @@
+package controlservice
+
+import (
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+)
+
+// restartRequiredSettings are runtime config keys that serve only reads at
+// startup, such as the privileged mode used to install the gateway
+// firewall. Reloading keeps their old values (see ReloadConfig).
+var restartRequiredSettings = map[string]bool{
+	"backends.firecracker.privileged_mode":        true,
+	"backends.firecracker.privileged_helper_path": true,
+}
+
+// restartRequiredSections are runtime config sections serve reads at
+// startup: logging builds its loggers, events dials the message bus,
+// cache_sharing starts the peer listener, log_shipping builds the sink,
+// storage opens the run store and state connects to the shared store.
+var restartRequiredSections = []string{"logging.", "events.", "cache_sharing.", "log_shipping.", "storage.", "state."}
+
+func restartRequired(key string) bool {
+	if restartRequiredSettings[key] {
+		return true
+	}
+	for _, prefix := range restartRequiredSections {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigReload describes the outcome of ReloadConfig.
+type ConfigReload struct {
+	// Applied settings take effect for sandboxes created from now on;
+	// existing sandboxes keep the config they were created with.
+	Applied []string
+	// RestartRequired settings changed on disk but were not applied.
+	RestartRequired []string
+}
+
+func (s *Service) runtimeConfig() runtimeconfig.Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Config
+}
+
+// ReloadConfig swaps in cfg, except for settings that need a restart.
+func (s *Service) ReloadConfig(cfg runtimeconfig.Config) ConfigReload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result ConfigReload
+	for _, key := range runtimeconfig.ChangedSettings(s.Config, cfg) {
+		if restartRequired(key) {
+			result.RestartRequired = append(result.RestartRequired, key)
+			continue
+		}
+		result.Applied = append(result.Applied, key)
+	}
+
+	next := cfg
+	next.Backends.Firecracker.PrivilegedMode = s.Config.Backends.Firecracker.PrivilegedMode
+	next.Backends.Firecracker.PrivilegedHelperPath = s.Config.Backends.Firecracker.PrivilegedHelperPath
+	next.Logging = s.Config.Logging
+	next.Events = s.Config.Events
+	next.CacheSharing = s.Config.CacheSharing
+	next.LogShipping = s.Config.LogShipping
+	next.Storage = s.Config.Storage
+	next.State = s.Config.State
+	s.Config = next
+	return result
+}
@@ -0,0 +1,137 @@
+package controlservice
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+)
+
+func TestReloadConfigAppliesSafeSettingsToNewSandboxes(t *testing.T) {
+	t.Parallel()
+
+	adapter := &stubAdapter{}
+	svc := newTestService(adapter)
+	svc.Config.Backends.Firecracker = runtimeconfig.FirecrackerConfig{
+		KernelImage:    "/kernels/old",
+		VCPUs:          2,
+		PrivilegedMode: "sudo",
+	}
+
+	first, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+
+	next := svc.Config
+	next.Backends.Firecracker.KernelImage = "/kernels/new"
+	next.Backends.Firecracker.VCPUs = 4
+	next.Backends.Firecracker.PrivilegedMode = "helper"
+	result := svc.ReloadConfig(next)
+
+	if got, want := strings.Join(result.Applied, ","), "backends.firecracker.kernel_image,backends.firecracker.vcpus"; got != want {
+		t.Fatalf("unexpected applied settings: got %q want %q", got, want)
+	}
+	if got, want := strings.Join(result.RestartRequired, ","), "backends.firecracker.privileged_mode"; got != want {
+		t.Fatalf("unexpected restart-required settings: got %q want %q", got, want)
+	}
+
+	if _, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()}); err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	gotCfg := adapter.provisionReq.FirecrackerConfig
+	if got, want := gotCfg.KernelImagePath, "/kernels/new"; got != want {
+		t.Fatalf("unexpected kernel image after reload: got %q want %q", got, want)
+	}
+	if got, want := gotCfg.VCPUs, int64(4); got != want {
+		t.Fatalf("unexpected vcpus after reload: got %d want %d", got, want)
+	}
+	if got, want := gotCfg.PrivilegedMode, "sudo"; got != want {
+		t.Fatalf("expected privileged mode to keep its startup value: got %q want %q", got, want)
+	}
+
+	svc.mu.RLock()
+	existing := svc.sandboxes[first.GetSandbox().GetSandboxId()].Firecracker
+	svc.mu.RUnlock()
+	if got, want := existing.KernelImagePath, "/kernels/old"; got != want {
+		t.Fatalf("expected existing sandbox to keep its config: got %q want %q", got, want)
+	}
+}
+
+func TestReloadConfigKeepsLoggingSettings(t *testing.T) {
+	t.Parallel()
+
+	svc := newTestService(&stubAdapter{})
+	next := svc.Config
+	next.Logging.Levels = map[string]string{"gateway": "debug"}
+	result := svc.ReloadConfig(next)
+	if got, want := strings.Join(result.RestartRequired, ","), "logging.levels"; got != want {
+		t.Fatalf("unexpected restart-required settings: got %q want %q", got, want)
+	}
+	if len(svc.runtimeConfig().Logging.Levels) != 0 {
+		t.Fatalf("expected logging levels to keep their startup value, got %v", svc.runtimeConfig().Logging.Levels)
+	}
+}
+
+func TestReloadConfigKeepsStartupOnlySections(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name   string
+		change func(*runtimeconfig.Config)
+		want   string
+		kept   func(runtimeconfig.Config) bool
+	}{
+		{
+			name:   "cache sharing",
+			change: func(cfg *runtimeconfig.Config) { cfg.CacheSharing.Peers = []string{"https://host-b:8171"} },
+			want:   "cache_sharing.peers",
+			kept:   func(cfg runtimeconfig.Config) bool { return cfg.CacheSharing.Peers == nil },
+		},
+		{
+			name:   "log shipping",
+			change: func(cfg *runtimeconfig.Config) { cfg.LogShipping.URL = "s3://job-logs/cleanroom" },
+			want:   "log_shipping.url",
+			kept:   func(cfg runtimeconfig.Config) bool { return cfg.LogShipping.URL == "" },
+		},
+		{
+			name:   "storage",
+			change: func(cfg *runtimeconfig.Config) { cfg.Storage.Runs = "s3://cleanroom-runs/host-a" },
+			want:   "storage.runs",
+			kept:   func(cfg runtimeconfig.Config) bool { return cfg.Storage.Runs == "" },
+		},
+		{
+			name:   "state",
+			change: func(cfg *runtimeconfig.Config) { cfg.State.Etcd = []string{"https://etcd-1:2379"} },
+			want:   "state.etcd",
+			kept:   func(cfg runtimeconfig.Config) bool { return cfg.State.Etcd == nil },
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			svc := newTestService(&stubAdapter{})
+			next := svc.Config
+			tc.change(&next)
+			result := svc.ReloadConfig(next)
+			if got := strings.Join(result.RestartRequired, ","); got != tc.want || len(result.Applied) != 0 {
+				t.Fatalf("unexpected reload result: %+v", result)
+			}
+			if !tc.kept(svc.runtimeConfig()) {
+				t.Fatalf("expected %s to keep its startup value, got %+v", tc.want, svc.runtimeConfig())
+			}
+		})
+	}
+}
+
+func TestReloadConfigWithoutChanges(t *testing.T) {
+	t.Parallel()
+
+	svc := newTestService(&stubAdapter{})
+	result := svc.ReloadConfig(svc.Config)
+	if len(result.Applied) != 0 || len(result.RestartRequired) != 0 {
+		t.Fatalf("expected no changes, got %+v", result)
+	}
+}
@@ -0,0 +1,47 @@
+package controlservice
+
+import (
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// executionEnvironmentLocked describes what an execution in sb runs on with
+// cfg. What the backend reports about the running sandbox wins over the
+// server's own record, which only knows what was asked for.
+func (s *Service) executionEnvironmentLocked(sb *sandboxState, adapter backend.Adapter, cfg backend.FirecrackerConfig) *cleanroomv1.ExecutionEnvironment {
+	env := &cleanroomv1.ExecutionEnvironment{
+		Backend:          sb.Backend,
+		ServerVersion:    s.Version,
+		GuestAgentSha256: sb.AgentHash,
+		Vcpus:            cfg.VCPUs,
+		MemoryMib:        cfg.MemoryMiB,
+		DiskMib:          cfg.DiskMiB,
+	}
+	if sb.Policy != nil {
+		env.ImageRef = sb.Policy.ImageRef
+		env.ImageDigest = sb.Policy.ImageDigest
+		env.PolicyHash = sb.Policy.Hash
+	}
+	reporter, ok := adapter.(backend.SandboxEnvironmentAdapter)
+	if !ok {
+		return env
+	}
+	reported, err := reporter.SandboxEnvironment(sb.ID)
+	if err != nil {
+		return env
+	}
+	if reported.GuestAgentSHA256 != "" {
+		env.GuestAgentSha256 = reported.GuestAgentSHA256
+	}
+	env.KernelVersion = reported.KernelVersion
+	if reported.VCPUs != 0 {
+		env.Vcpus = reported.VCPUs
+	}
+	if reported.MemoryMiB != 0 {
+		env.MemoryMib = reported.MemoryMiB
+	}
+	if reported.DiskMiB != 0 {
+		env.DiskMib = reported.DiskMiB
+	}
+	return env
+}
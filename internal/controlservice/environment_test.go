@@ -0,0 +1,68 @@
+package controlservice
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+type environmentAdapter struct {
+	*stubAdapter
+	env backend.SandboxEnvironment
+}
+
+func (a environmentAdapter) SandboxEnvironment(string) (backend.SandboxEnvironment, error) {
+	return a.env, nil
+}
+
+func TestExecutionStreamStartsWithEnvironment(t *testing.T) {
+	adapter := environmentAdapter{
+		stubAdapter: &stubAdapter{},
+		env:         backend.SandboxEnvironment{GuestAgentSHA256: "abc123", KernelVersion: "6.1.155", VCPUs: 4, MemoryMiB: 2048, DiskMiB: 8192},
+	}
+	svc := newTestService(adapter)
+	svc.Version = "v1.2.3"
+
+	createSandboxResp, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sandboxID := createSandboxResp.GetSandbox().GetSandboxId()
+	createExecutionResp, err := svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   []string{"--", "echo", "hi"},
+	})
+	if err != nil {
+		t.Fatalf("CreateExecution returned error: %v", err)
+	}
+
+	history, updates, done, unsubscribe, err := svc.SubscribeExecutionEvents(sandboxID, createExecutionResp.GetExecution().GetExecutionId())
+	if err != nil {
+		t.Fatalf("SubscribeExecutionEvents returned error: %v", err)
+	}
+	defer unsubscribe()
+
+	var envs []*cleanroomv1.ExecutionEnvironment
+	var sawStarted bool
+	for _, event := range collectExecutionEvents(t, history, updates, done) {
+		if env := event.GetEnvironment(); env != nil {
+			if sawStarted {
+				t.Fatal("expected the environment event before execution started")
+			}
+			envs = append(envs, env)
+		}
+		sawStarted = sawStarted || event.GetMessage() == "execution started"
+	}
+	if len(envs) != 1 {
+		t.Fatalf("expected one environment event, got %d", len(envs))
+	}
+	env := envs[0]
+	if env.GetImageDigest() != testPolicy().GetImageDigest() || env.GetPolicyHash() == "" || env.GetBackend() != "firecracker" || env.GetServerVersion() != "v1.2.3" {
+		t.Fatalf("unexpected environment identity: %+v", env)
+	}
+	if env.GetGuestAgentSha256() != "abc123" || env.GetKernelVersion() != "6.1.155" || env.GetVcpus() != 4 || env.GetMemoryMib() != 2048 || env.GetDiskMib() != 8192 {
+		t.Fatalf("unexpected reported environment: %+v", env)
+	}
+}
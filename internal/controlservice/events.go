@@ -0,0 +1,58 @@
+package controlservice
+
+import (
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// EventPublisher mirrors sandbox and execution events off the host.
+// *eventbus.NATS implements it. Publish is called with the service lock
+// held, so it must not block.
+type EventPublisher interface {
+	Publish(subject string, data []byte)
+}
+
+// LogShipper keeps each execution's full event stream, output included,
+// beyond the host. *logship.Shipper implements it. Record is called with
+// the service lock held, so it must not block.
+type LogShipper interface {
+	Record(event *cleanroomv1.ExecutionStreamEvent)
+}
+
+// publishSandboxEventLocked publishes event as JSON on
+// sandboxes.<sandbox_id>.
+func (s *Service) publishSandboxEventLocked(event *cleanroomv1.SandboxEvent) {
+	if s.Events == nil {
+		return
+	}
+	data, err := protojson.Marshal(event)
+	if err != nil {
+		return
+	}
+	s.Events.Publish("sandboxes."+event.GetSandboxId(), data)
+}
+
+// publishExecutionEventLocked publishes event as JSON on
+// executions.<sandbox_id>.<execution_id>. Output chunks stay on the host:
+// they can be large and carry whatever the command printed.
+func (s *Service) publishExecutionEventLocked(event *cleanroomv1.ExecutionStreamEvent) {
+	if s.Events == nil {
+		return
+	}
+	switch event.GetPayload().(type) {
+	case *cleanroomv1.ExecutionStreamEvent_Stdout, *cleanroomv1.ExecutionStreamEvent_Stderr:
+		return
+	}
+	data, err := protojson.Marshal(event)
+	if err != nil {
+		return
+	}
+	s.Events.Publish("executions."+event.GetSandboxId()+"."+event.GetExecutionId(), data)
+}
+
+func (s *Service) shipExecutionEventLocked(event *cleanroomv1.ExecutionStreamEvent) {
+	if s.Logs == nil {
+		return
+	}
+	s.Logs.Record(event)
+}
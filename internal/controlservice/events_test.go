@@ -0,0 +1,130 @@
+package controlservice
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+type recordingPublisher struct {
+	mu       sync.Mutex
+	subjects []string
+	payloads [][]byte
+}
+
+func (p *recordingPublisher) Publish(subject string, data []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.subjects = append(p.subjects, subject)
+	p.payloads = append(p.payloads, data)
+}
+
+func TestEventsArePublishedWithoutOutput(t *testing.T) {
+	adapter := &stubAdapter{
+		runFn: func(_ context.Context, req backend.RunRequest) (*backend.RunResult, error) {
+			return &backend.RunResult{RunID: req.RunID, ExitCode: 3, Stdout: "secret output\n"}, nil
+		},
+	}
+	publisher := &recordingPublisher{}
+	svc := newTestService(adapter)
+	svc.Events = publisher
+
+	sandbox, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sandboxID := sandbox.GetSandbox().GetSandboxId()
+	execution, err := svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   []string{"echo", "hi"},
+	})
+	if err != nil {
+		t.Fatalf("CreateExecution returned error: %v", err)
+	}
+	executionID := execution.GetExecution().GetExecutionId()
+	waitForExecutionStatus(t, svc, sandboxID, executionID)
+
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+	if len(publisher.subjects) == 0 || publisher.subjects[0] != "sandboxes."+sandboxID {
+		t.Fatalf("expected a sandbox event first, got %v", publisher.subjects)
+	}
+	executionSubject := "executions." + sandboxID + "." + executionID
+	var exit *cleanroomv1.ExecutionExit
+	for i, subject := range publisher.subjects {
+		if strings.Contains(string(publisher.payloads[i]), "secret output") {
+			t.Fatalf("expected output to stay on the host, got %s on %s", publisher.payloads[i], subject)
+		}
+		if subject != executionSubject {
+			continue
+		}
+		var event cleanroomv1.ExecutionStreamEvent
+		if err := protojson.Unmarshal(publisher.payloads[i], &event); err != nil {
+			t.Fatalf("expected a JSON execution event on %s: %v", subject, err)
+		}
+		if event.GetExit() != nil {
+			exit = event.GetExit()
+		}
+	}
+	if exit == nil || exit.GetExitCode() != 3 {
+		t.Fatalf("expected the exit event to be published, got %v on %v", exit, publisher.subjects)
+	}
+}
+
+type recordingShipper struct {
+	mu     sync.Mutex
+	events []*cleanroomv1.ExecutionStreamEvent
+}
+
+func (s *recordingShipper) Record(event *cleanroomv1.ExecutionStreamEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+func TestExecutionLogsAreShippedWithOutput(t *testing.T) {
+	adapter := &stubAdapter{
+		runFn: func(_ context.Context, req backend.RunRequest) (*backend.RunResult, error) {
+			return &backend.RunResult{RunID: req.RunID, ExitCode: 0, Stdout: "build output\n"}, nil
+		},
+	}
+	shipper := &recordingShipper{}
+	svc := newTestService(adapter)
+	svc.Logs = shipper
+
+	sandbox, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sandboxID := sandbox.GetSandbox().GetSandboxId()
+	execution, err := svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   []string{"make"},
+	})
+	if err != nil {
+		t.Fatalf("CreateExecution returned error: %v", err)
+	}
+	executionID := execution.GetExecution().GetExecutionId()
+	waitForExecutionStatus(t, svc, sandboxID, executionID)
+
+	shipper.mu.Lock()
+	defer shipper.mu.Unlock()
+	var stdout string
+	for _, event := range shipper.events {
+		if event.GetExecutionId() != executionID {
+			t.Fatalf("expected only events of %s, got %v", executionID, event)
+		}
+		stdout += string(event.GetStdout())
+	}
+	if stdout != "build output\n" {
+		t.Fatalf("expected the output to be shipped, got %q", stdout)
+	}
+	if last := shipper.events[len(shipper.events)-1]; last.GetExit() == nil {
+		t.Fatalf("expected the exit event last, got %v", last)
+	}
+}
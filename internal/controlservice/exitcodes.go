@@ -0,0 +1,37 @@
+package controlservice
+
+import (
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/policy"
+)
+
+// applyExitCodeRuleLocked applies the policy's rule for the code ex's
+// command exited with: it marks and annotates ex, and returns the status
+// to finalize it with in place of status.
+func applyExitCodeRuleLocked(ex *executionState, p *policy.CompiledPolicy, exitCode int, status cleanroomv1.ExecutionStatus) cleanroomv1.ExecutionStatus {
+	rule := p.ExitCodeRule(exitCode)
+	if rule == nil {
+		return status
+	}
+	ex.Retriable = rule.Retriable
+	for key, value := range rule.Annotations {
+		if ex.Annotations == nil {
+			ex.Annotations = map[string]string{}
+		}
+		if _, ok := ex.Annotations[key]; !ok && len(ex.Annotations) >= maxSandboxLabels {
+			continue
+		}
+		ex.Annotations[key] = value
+	}
+	switch rule.Status {
+	case policy.ExitStatusSucceeded:
+		return cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SUCCEEDED
+	case policy.ExitStatusFailed:
+		return cleanroomv1.ExecutionStatus_EXECUTION_STATUS_FAILED
+	case policy.ExitStatusSkipped:
+		return cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SKIPPED
+	case policy.ExitStatusSoftFailed:
+		return cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SOFT_FAILED
+	}
+	return status
+}
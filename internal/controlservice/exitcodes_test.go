@@ -0,0 +1,65 @@
+package controlservice
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+func TestPolicyExitCodeRulesSetExecutionStatus(t *testing.T) {
+	t.Parallel()
+
+	adapter := &stubAdapter{
+		runFn: func(_ context.Context, req backend.RunRequest) (*backend.RunResult, error) {
+			code := 0
+			switch req.Command[0] {
+			case "skip":
+				code = 77
+			case "flaky":
+				code = 75
+			}
+			return &backend.RunResult{RunID: req.RunID, ExitCode: code}, nil
+		},
+	}
+	svc := newTestService(adapter)
+	ctx := context.Background()
+	pol := testPolicy()
+	pol.ExitCodes = []*cleanroomv1.PolicyExitCodeRule{
+		{Codes: []int32{75}, Status: "soft_failed", Retriable: true},
+		{Codes: []int32{77}, Status: "skipped", Annotations: map[string]string{"reason": "no-tests"}},
+	}
+	createResp, err := svc.CreateSandbox(ctx, &cleanroomv1.CreateSandboxRequest{Policy: pol})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sandboxID := createResp.GetSandbox().GetSandboxId()
+
+	run := func(command string) *cleanroomv1.Execution {
+		t.Helper()
+		resp, err := svc.CreateExecution(ctx, &cleanroomv1.CreateExecutionRequest{SandboxId: sandboxID, Command: []string{command}})
+		if err != nil {
+			t.Fatalf("CreateExecution returned error: %v", err)
+		}
+		return waitForExecutionStatus(t, svc, sandboxID, resp.GetExecution().GetExecutionId())
+	}
+
+	skipped := run("skip")
+	if skipped.GetStatus() != cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SKIPPED || skipped.GetExitCode() != 77 {
+		t.Fatalf("expected a skipped execution exiting 77, got %v %d", skipped.GetStatus(), skipped.GetExitCode())
+	}
+	if skipped.GetAnnotations()["reason"] != "no-tests" || skipped.GetRetriable() {
+		t.Fatalf("unexpected skipped execution: %v", skipped)
+	}
+
+	flaky := run("flaky")
+	if flaky.GetStatus() != cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SOFT_FAILED || !flaky.GetRetriable() {
+		t.Fatalf("expected a retriable soft failure, got %v retriable=%v", flaky.GetStatus(), flaky.GetRetriable())
+	}
+
+	ok := run("true")
+	if ok.GetStatus() != cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SUCCEEDED || ok.GetRetriable() {
+		t.Fatalf("expected an unmapped exit code to keep its status, got %v", ok)
+	}
+}
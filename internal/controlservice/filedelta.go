@@ -0,0 +1,71 @@
+package controlservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/blockdelta"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// checkDeltaBase rejects delta bases no guest agent would accept.
+func checkDeltaBase(base *cleanroomv1.FileDeltaBase) error {
+	if base == nil {
+		return nil
+	}
+	if base.GetBlockSize() < 1 || base.GetBlockSize() > blockdelta.MaxBlockSize {
+		return fmt.Errorf("invalid delta_base.block_size %d: must be between 1 and %d", base.GetBlockSize(), blockdelta.MaxBlockSize)
+	}
+	sigs := len(base.GetSignatures())
+	if sigs%blockdelta.SignatureSize != 0 || sigs/blockdelta.SignatureSize > blockdelta.MaxBlocks {
+		return fmt.Errorf("invalid delta_base.signatures: must be at most %d signatures of %d bytes", blockdelta.MaxBlocks, blockdelta.SignatureSize)
+	}
+	return nil
+}
+
+// downloadFileDelta answers a download with a delta against base, or with
+// the file's data when base is nil. Either way the guest agent computes the
+// file's SHA-256. It returns backend.ErrFileDeltaUnsupported unwrapped so
+// the caller can fall back to an unchecked download.
+func downloadFileDelta(ctx context.Context, deltas backend.SandboxFileDeltaAdapter, sandboxID, path string, maxBytes int64, base *cleanroomv1.FileDeltaBase) (*cleanroomv1.DownloadSandboxFileResponse, error) {
+	blockSize := int(base.GetBlockSize())
+	if base == nil {
+		// A delta against an empty base carries every byte of the file.
+		blockSize = blockdelta.MinBlockSize
+	}
+	delta, err := deltas.DownloadSandboxFileDelta(ctx, sandboxID, path, maxBytes, blockSize, base.GetSignatures())
+	if errors.Is(err, backend.ErrFileDeltaUnsupported) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("download sandbox file delta: %w", err)
+	}
+	if base == nil {
+		// The file is rebuilt in the delta's own buffer, so a plain
+		// download holds it in memory once.
+		data, stats, err := blockdelta.Assemble(delta)
+		if err != nil {
+			return nil, fmt.Errorf("download sandbox file: %w", err)
+		}
+		return &cleanroomv1.DownloadSandboxFileResponse{
+			SandboxId: sandboxID,
+			Path:      path,
+			Data:      data,
+			SizeBytes: stats.Size,
+			Sha256:    stats.SHA256,
+		}, nil
+	}
+	stats, err := blockdelta.Inspect(delta, blockSize)
+	if err != nil {
+		return nil, fmt.Errorf("download sandbox file delta: %w", err)
+	}
+	return &cleanroomv1.DownloadSandboxFileResponse{
+		SandboxId: sandboxID,
+		Path:      path,
+		Delta:     delta,
+		SizeBytes: stats.Size,
+		Sha256:    stats.SHA256,
+	}, nil
+}
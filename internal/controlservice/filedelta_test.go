@@ -0,0 +1,99 @@
+package controlservice
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/blockdelta"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// deltaAdapter diffs file against the caller's base, or reports an old
+// guest agent when oldAgent is set.
+type deltaAdapter struct {
+	*stubAdapter
+	file     []byte
+	oldAgent bool
+}
+
+func (a *deltaAdapter) DownloadSandboxFileDelta(_ context.Context, _, _ string, maxBytes int64, blockSize int, signatures []byte) ([]byte, error) {
+	if a.oldAgent {
+		return nil, backend.ErrFileDeltaUnsupported
+	}
+	var delta bytes.Buffer
+	err := blockdelta.Diff(bytes.NewReader(a.file), blockSize, signatures, maxBytes, &delta)
+	return delta.Bytes(), err
+}
+
+func TestDownloadSandboxFileSendsDeltaAgainstBase(t *testing.T) {
+	base := bytes.Repeat([]byte("0123456789abcdef"), 1024)
+	file := append(append([]byte(nil), base...), "appended"...)
+	adapter := &deltaAdapter{
+		stubAdapter: &stubAdapter{downloadFn: func(context.Context, string, string, int64) ([]byte, error) {
+			return file, nil
+		}},
+		file: file,
+	}
+	svc := newTestService(adapter)
+	created, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sigs, err := blockdelta.Sign(bytes.NewReader(base), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &cleanroomv1.DownloadSandboxFileRequest{
+		SandboxId: created.GetSandbox().GetSandboxId(),
+		Path:      "/out/app.bin",
+		DeltaBase: &cleanroomv1.FileDeltaBase{BlockSize: 1024, Signatures: sigs},
+	}
+
+	resp, err := svc.DownloadSandboxFile(context.Background(), req)
+	if err != nil {
+		t.Fatalf("DownloadSandboxFile returned error: %v", err)
+	}
+	if len(resp.GetData()) != 0 || resp.GetSizeBytes() != int64(len(file)) {
+		t.Fatalf("expected a delta for %d bytes, got %d bytes of data and size %d", len(file), len(resp.GetData()), resp.GetSizeBytes())
+	}
+	var patched bytes.Buffer
+	stats, err := blockdelta.Patch(bytes.NewReader(base), 1024, resp.GetDelta(), &patched)
+	if err != nil || !bytes.Equal(patched.Bytes(), file) {
+		t.Fatalf("Patch = %+v, %v", stats, err)
+	}
+	if stats.LiteralBytes != int64(len("appended")) {
+		t.Fatalf("expected only the appended bytes to be sent, got %+v", stats)
+	}
+
+	sum := sha256.Sum256(file)
+	if resp.GetSha256() != hex.EncodeToString(sum[:]) {
+		t.Fatalf("unexpected sha256 %q", resp.GetSha256())
+	}
+
+	full, err := svc.DownloadSandboxFile(context.Background(), &cleanroomv1.DownloadSandboxFileRequest{SandboxId: req.GetSandboxId(), Path: req.GetPath()})
+	if err != nil {
+		t.Fatalf("DownloadSandboxFile returned error: %v", err)
+	}
+	if !bytes.Equal(full.GetData(), file) || full.GetSha256() != resp.GetSha256() {
+		t.Fatal("expected full downloads to carry the agent's checksum")
+	}
+
+	adapter.oldAgent = true
+	resp, err = svc.DownloadSandboxFile(context.Background(), req)
+	if err != nil {
+		t.Fatalf("DownloadSandboxFile returned error: %v", err)
+	}
+	if !bytes.Equal(resp.GetData(), file) || len(resp.GetDelta()) != 0 || resp.GetSha256() != "" {
+		t.Fatal("expected old guest agents to get the whole file, unchecked")
+	}
+
+	req.DeltaBase.Signatures = sigs[:len(sigs)-1]
+	if _, err := svc.DownloadSandboxFile(context.Background(), req); err == nil || !strings.Contains(err.Error(), "invalid delta_base.signatures") {
+		t.Fatalf("expected truncated signatures to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package controlservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// maxSandboxGroupMembers bounds a group's size; every member pair gets its
+// own host rules.
+const maxSandboxGroupMembers = 16
+
+// CreateSandboxGroup creates each member as CreateSandbox would, then links
+// them so every member's guest can connect to the others on the ports they
+// declare. If any member fails to start, or the link cannot be made, the
+// members already created are terminated.
+func (s *Service) CreateSandboxGroup(ctx context.Context, req *cleanroomv1.CreateSandboxGroupRequest) (*cleanroomv1.CreateSandboxGroupResponse, error) {
+	if req == nil {
+		return nil, errors.New("missing request")
+	}
+	members := req.GetMembers()
+	if len(members) < 2 {
+		return nil, errors.New("a sandbox group needs at least two members")
+	}
+	if len(members) > maxSandboxGroupMembers {
+		return nil, fmt.Errorf("a sandbox group has at most %d members", maxSandboxGroupMembers)
+	}
+
+	cfg := s.runtimeConfig()
+	backendName := ""
+	for i, member := range members {
+		if member.GetSandbox() == nil {
+			return nil, fmt.Errorf("member %d: missing sandbox", i)
+		}
+		name := resolveBackendName(strings.TrimSpace(member.GetSandbox().GetBackend()), cfg.DefaultBackend)
+		if backendName != "" && name != backendName {
+			return nil, fmt.Errorf("member %d: sandbox group members must use the same backend, got %q and %q", i, backendName, name)
+		}
+		backendName = name
+		for _, port := range member.GetPorts() {
+			if port < 1 || port > 65535 {
+				return nil, fmt.Errorf("member %d: invalid port %d", i, port)
+			}
+		}
+	}
+	adapter, ok := s.Backends[backendName]
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %q", backendName)
+	}
+	linker, ok := adapter.(backend.SandboxGroupAdapter)
+	if !ok {
+		return nil, fmt.Errorf("backend %q does not support sandbox groups", backendName)
+	}
+
+	groupID := newSandboxGroupID()
+	created := make([]*cleanroomv1.CreateSandboxResponse, 0, len(members))
+	rollback := func() {
+		for _, resp := range created {
+			_, _ = s.TerminateSandbox(context.WithoutCancel(ctx), &cleanroomv1.TerminateSandboxRequest{SandboxId: resp.GetSandbox().GetSandboxId()})
+		}
+	}
+	linkMembers := make([]backend.SandboxGroupMember, 0, len(members))
+	for i, member := range members {
+		resp, err := s.CreateSandbox(ctx, member.GetSandbox())
+		if err != nil {
+			rollback()
+			return nil, fmt.Errorf("create member %d: %w", i, err)
+		}
+		created = append(created, resp)
+		ports := make([]int, 0, len(member.GetPorts()))
+		for _, port := range member.GetPorts() {
+			ports = append(ports, int(port))
+		}
+		linkMembers = append(linkMembers, backend.SandboxGroupMember{SandboxID: resp.GetSandbox().GetSandboxId(), Ports: ports})
+	}
+
+	addresses, err := linker.LinkSandboxGroup(ctx, backend.SandboxGroupRequest{
+		GroupID:           groupID,
+		Members:           linkMembers,
+		FirecrackerConfig: mergeBackendConfig(backendName, executionOptions{}, cfg),
+	})
+	if err != nil {
+		rollback()
+		return nil, fmt.Errorf("link sandbox group: %w", err)
+	}
+
+	s.mu.Lock()
+	for _, resp := range created {
+		state, ok := s.sandboxes[resp.GetSandbox().GetSandboxId()]
+		if !ok {
+			continue
+		}
+		state.GroupID = groupID
+		state.GroupAddress = addresses[state.ID]
+		s.recordSandboxEventLocked(state, state.Status, "joined sandbox group "+groupID)
+		resp.Sandbox = cloneSandboxLocked(state)
+	}
+	s.mu.Unlock()
+
+	if logger := s.logger(ctx); logger != nil {
+		logger.Info("sandbox group created", "group_id", groupID, "backend", backendName, "members", len(created))
+	}
+	return &cleanroomv1.CreateSandboxGroupResponse{GroupId: groupID, Sandboxes: created}, nil
+}
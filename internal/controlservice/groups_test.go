@@ -0,0 +1,99 @@
+package controlservice
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// groupAdapter links every group it is given, or fails with linkErr.
+type groupAdapter struct {
+	*stubAdapter
+	linkReq backend.SandboxGroupRequest
+	linkErr error
+}
+
+func (a *groupAdapter) LinkSandboxGroup(_ context.Context, req backend.SandboxGroupRequest) (map[string]string, error) {
+	a.linkReq = req
+	if a.linkErr != nil {
+		return nil, a.linkErr
+	}
+	addresses := map[string]string{}
+	for i, member := range req.Members {
+		addresses[member.SandboxID] = []string{"10.200.0.2", "10.200.1.2"}[i]
+	}
+	return addresses, nil
+}
+
+func TestCreateSandboxGroupLinksMembers(t *testing.T) {
+	t.Parallel()
+
+	adapter := &groupAdapter{stubAdapter: &stubAdapter{}}
+	svc := newTestService(adapter)
+	resp, err := svc.CreateSandboxGroup(context.Background(), &cleanroomv1.CreateSandboxGroupRequest{Members: []*cleanroomv1.SandboxGroupMember{
+		{Sandbox: &cleanroomv1.CreateSandboxRequest{Policy: testPolicy(), Name: "app"}},
+		{Sandbox: &cleanroomv1.CreateSandboxRequest{Policy: testPolicy(), Name: "db"}, Ports: []int32{5432}},
+	}})
+	if err != nil {
+		t.Fatalf("CreateSandboxGroup returned error: %v", err)
+	}
+	if !strings.HasPrefix(resp.GetGroupId(), "crg_") || len(resp.GetSandboxes()) != 2 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	db := resp.GetSandboxes()[1].GetSandbox()
+	if db.GetGroupId() != resp.GetGroupId() || db.GetGroupAddress() != "10.200.1.2" || db.GetName() != "db" {
+		t.Fatalf("unexpected db member %+v", db)
+	}
+	if got := adapter.linkReq.Members[1]; got.SandboxID != db.GetSandboxId() || len(got.Ports) != 1 || got.Ports[0] != 5432 {
+		t.Fatalf("unexpected link member %+v", got)
+	}
+	fetched, err := svc.GetSandbox(context.Background(), &cleanroomv1.GetSandboxRequest{SandboxId: db.GetSandboxId()})
+	if err != nil || fetched.GetSandbox().GetGroupId() != resp.GetGroupId() {
+		t.Fatalf("GetSandbox group = %q, %v", fetched.GetSandbox().GetGroupId(), err)
+	}
+}
+
+func TestCreateSandboxGroupTerminatesMembersWhenLinkFails(t *testing.T) {
+	t.Parallel()
+
+	adapter := &groupAdapter{stubAdapter: &stubAdapter{}, linkErr: errors.New("iptables failed")}
+	svc := newTestService(adapter)
+	_, err := svc.CreateSandboxGroup(context.Background(), &cleanroomv1.CreateSandboxGroupRequest{Members: []*cleanroomv1.SandboxGroupMember{
+		{Sandbox: &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()}, Ports: []int32{8080}},
+		{Sandbox: &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()}},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "link sandbox group: iptables failed") {
+		t.Fatalf("expected link error, got %v", err)
+	}
+	if adapter.terminateCalls != 2 {
+		t.Fatalf("expected both members to be terminated, got %d terminations", adapter.terminateCalls)
+	}
+}
+
+func TestCreateSandboxGroupValidatesRequest(t *testing.T) {
+	t.Parallel()
+
+	member := func(ports ...int32) *cleanroomv1.SandboxGroupMember {
+		return &cleanroomv1.SandboxGroupMember{Sandbox: &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()}, Ports: ports}
+	}
+	cases := []struct {
+		adapter backend.Adapter
+		members []*cleanroomv1.SandboxGroupMember
+		want    string
+	}{
+		{adapter: &groupAdapter{stubAdapter: &stubAdapter{}}, members: []*cleanroomv1.SandboxGroupMember{member()}, want: "at least two members"},
+		{adapter: &groupAdapter{stubAdapter: &stubAdapter{}}, members: []*cleanroomv1.SandboxGroupMember{member(70000), member()}, want: "invalid port 70000"},
+		{adapter: &stubAdapter{}, members: []*cleanroomv1.SandboxGroupMember{member(), member()}, want: "does not support sandbox groups"},
+	}
+	for _, tc := range cases {
+		svc := newTestService(tc.adapter)
+		_, err := svc.CreateSandboxGroup(context.Background(), &cleanroomv1.CreateSandboxGroupRequest{Members: tc.members})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("expected %q error, got %v", tc.want, err)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build darwin
+
+package controlservice
+
+import "golang.org/x/sys/unix"
+
+func hostMemoryBytes() int64 {
+	size, err := unix.SysctlUint64("hw.memsize")
+	if err != nil {
+		return 0
+	}
+	return int64(size)
+}
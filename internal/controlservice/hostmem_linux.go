@@ -0,0 +1,13 @@
+//go:build linux
+
+package controlservice
+
+import "golang.org/x/sys/unix"
+
+func hostMemoryBytes() int64 {
+	var info unix.Sysinfo_t
+	if err := unix.Sysinfo(&info); err != nil {
+		return 0
+	}
+	return int64(info.Totalram) * int64(info.Unit)
+}
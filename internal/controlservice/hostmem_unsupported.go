@@ -0,0 +1,7 @@
+//go:build !linux && !darwin
+
+package controlservice
+
+func hostMemoryBytes() int64 {
+	return 0
+}
@@ -20,6 +20,10 @@ func newSandboxID() string {
 	return newID("cr")
 }
 
+func newSandboxGroupID() string {
+	return newID("crg")
+}
+
 func newExecutionID() string {
 	return newID("exec")
 }
@@ -28,6 +32,19 @@ func newRunID() string {
 	return newID("run")
 }
 
+// normalizeRunID checks a caller-supplied run ID, which names the run's
+// directory. It accepts a TypeID, such as run_01h2xcejqtf2nbrexx3vqjhp41,
+// or a UUID, which it returns in canonical lowercase form.
+func normalizeRunID(id string) (string, error) {
+	if _, err := typeid.FromString(id); err == nil {
+		return id, nil
+	}
+	if uid, err := typeid.FromUUIDWithPrefix("", id); err == nil {
+		return uid.UUID(), nil
+	}
+	return "", fmt.Errorf("invalid run_id %q: must be a TypeID or UUID", id)
+}
+
 func newInteractiveSessionID() string {
 	return newID("isess")
 }
@@ -1,10 +1,13 @@
 package controlservice
 
 import (
+	"context"
 	"errors"
 	"strings"
 	"testing"
 
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
 	"go.jetify.com/typeid"
 )
 
@@ -47,3 +50,45 @@ func TestNewIDFallsBackToTimestampShapeWhenGeneratorFails(t *testing.T) {
 		t.Fatalf("expected fallback id to keep legacy shape, got %q", id)
 	}
 }
+
+func TestCallerSuppliedRunIDsAreValidatedAndUnique(t *testing.T) {
+	adapter := &stubAdapter{
+		runFn: func(_ context.Context, req backend.RunRequest) (*backend.RunResult, error) {
+			return &backend.RunResult{RunID: req.RunID}, nil
+		},
+	}
+	svc := newTestService(adapter)
+	ctx := context.Background()
+	created, err := svc.CreateSandbox(ctx, &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sandboxID := created.GetSandbox().GetSandboxId()
+	run := func(runID string) (*cleanroomv1.Execution, error) {
+		resp, err := svc.CreateExecution(ctx, &cleanroomv1.CreateExecutionRequest{SandboxId: sandboxID, Command: []string{"true"}, RunId: runID})
+		if err != nil {
+			return nil, err
+		}
+		return waitForExecutionStatus(t, svc, sandboxID, resp.GetExecution().GetExecutionId()), nil
+	}
+
+	// A Buildkite job ID is a UUID; it is kept in canonical form.
+	jobID := "0190F2A4-8C3B-7D4E-9A1B-2C3D4E5F6A7B"
+	ex, err := run(jobID)
+	if err != nil {
+		t.Fatalf("CreateExecution returned error: %v", err)
+	}
+	if ex.GetRunId() != strings.ToLower(jobID) {
+		t.Fatalf("expected run ID %q, got %q", strings.ToLower(jobID), ex.GetRunId())
+	}
+	if _, err := run(strings.ToLower(jobID)); !errors.Is(err, ErrRunIDInUse) {
+		t.Fatalf("expected a reused run ID to conflict, got %v", err)
+	}
+	if _, err := run("../../etc"); err == nil || !strings.Contains(err.Error(), "invalid run_id") {
+		t.Fatalf("expected an invalid run ID error, got %v", err)
+	}
+	ex, err = run("run_01h2xcejqtf2nbrexx3vqjhp41")
+	if err != nil || ex.GetRunId() != "run_01h2xcejqtf2nbrexx3vqjhp41" {
+		t.Fatalf("expected the TypeID to be used as is, got %q %v", ex.GetRunId(), err)
+	}
+}
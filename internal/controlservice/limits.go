@@ -0,0 +1,90 @@
+package controlservice
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+)
+
+const (
+	minExecutionNice           = -20
+	maxExecutionNice           = 19
+	maxExecutionIOPriority     = 7
+	maxExecutionCPUWeight      = 10000
+	minExecutionMemoryMaxBytes = 4 * 1024 * 1024
+)
+
+func executionLimitsFromProto(in *cleanroomv1.ExecutionResourceLimits) (backend.ResourceLimits, error) {
+	if in == nil {
+		return backend.ResourceLimits{}, nil
+	}
+	out := backend.ResourceLimits{
+		Nice:           int(in.GetNice()),
+		IOClass:        strings.ToLower(strings.TrimSpace(in.GetIoClass())),
+		IOPriority:     int(in.GetIoPriority()),
+		CPUWeight:      in.GetCpuWeight(),
+		MemoryMaxBytes: in.GetMemoryMaxBytes(),
+	}
+	if out.Nice < minExecutionNice || out.Nice > maxExecutionNice {
+		return backend.ResourceLimits{}, fmt.Errorf("invalid limits.nice %d: must be between %d and %d", out.Nice, minExecutionNice, maxExecutionNice)
+	}
+	switch out.IOClass {
+	case "", "realtime", "best-effort", "idle":
+	default:
+		return backend.ResourceLimits{}, fmt.Errorf("invalid limits.io_class %q: must be realtime, best-effort, or idle", in.GetIoClass())
+	}
+	if out.IOPriority < 0 || out.IOPriority > maxExecutionIOPriority {
+		return backend.ResourceLimits{}, fmt.Errorf("invalid limits.io_priority %d: must be between 0 and %d", out.IOPriority, maxExecutionIOPriority)
+	}
+	if out.IOPriority != 0 && (out.IOClass == "" || out.IOClass == "idle") {
+		return backend.ResourceLimits{}, fmt.Errorf("invalid limits.io_priority %d: requires io_class realtime or best-effort", out.IOPriority)
+	}
+	if out.CPUWeight < 0 || out.CPUWeight > maxExecutionCPUWeight {
+		return backend.ResourceLimits{}, fmt.Errorf("invalid limits.cpu_weight %d: must be between 1 and %d", out.CPUWeight, maxExecutionCPUWeight)
+	}
+	if out.MemoryMaxBytes < 0 || (out.MemoryMaxBytes > 0 && out.MemoryMaxBytes < minExecutionMemoryMaxBytes) {
+		return backend.ResourceLimits{}, fmt.Errorf("invalid limits.memory_max_bytes %d: must be at least %d", out.MemoryMaxBytes, minExecutionMemoryMaxBytes)
+	}
+	return out, nil
+}
+
+// checkCommandSize rejects commands over the configured limits, or the
+// guest agent's where none are configured, before they reach a sandbox.
+func checkCommandSize(cfg runtimeconfig.Executions, command []string) error {
+	maxArgs := configuredLimit(cfg.MaxArgs, vsockexec.MaxCommandArgs)
+	if len(command) > maxArgs {
+		return fmt.Errorf("invalid command: %d arguments, at most %d allowed", len(command), maxArgs)
+	}
+	maxBytes := configuredLimit(cfg.MaxCommandBytes, vsockexec.MaxCommandBytes)
+	total := 0
+	for i, arg := range command {
+		if len(arg) > vsockexec.MaxArgBytes {
+			return fmt.Errorf("invalid command: argument %d is %d bytes, at most %d allowed", i, len(arg), vsockexec.MaxArgBytes)
+		}
+		total += len(arg) + 1
+	}
+	if total > maxBytes {
+		return fmt.Errorf("invalid command: arguments total %d bytes, at most %d allowed", total, maxBytes)
+	}
+	return nil
+}
+
+// checkStdinSize rejects a stdin write over the configured limit.
+func checkStdinSize(cfg runtimeconfig.Executions, data []byte) error {
+	maxBytes := configuredLimit(cfg.MaxStdinFrameBytes, vsockexec.MaxStdinFrameBytes)
+	if len(data) > maxBytes {
+		return fmt.Errorf("invalid stdin: %d bytes in one write, at most %d allowed", len(data), maxBytes)
+	}
+	return nil
+}
+
+func configuredLimit(configured int64, agentMax int) int {
+	if configured <= 0 || configured > int64(agentMax) {
+		return agentMax
+	}
+	return int(configured)
+}
@@ -0,0 +1,195 @@
+package controlservice
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+)
+
+func TestExecutionLimitsFromProtoNormalizesValues(t *testing.T) {
+	t.Parallel()
+
+	got, err := executionLimitsFromProto(&cleanroomv1.ExecutionResourceLimits{
+		Nice:           10,
+		IoClass:        " Best-Effort ",
+		IoPriority:     6,
+		CpuWeight:      50,
+		MemoryMaxBytes: 512 * 1024 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("executionLimitsFromProto returned error: %v", err)
+	}
+	want := backend.ResourceLimits{Nice: 10, IOClass: "best-effort", IOPriority: 6, CPUWeight: 50, MemoryMaxBytes: 512 * 1024 * 1024}
+	if got != want {
+		t.Fatalf("unexpected limits: got %+v want %+v", got, want)
+	}
+}
+
+func TestExecutionLimitsFromProtoNilIsUnlimited(t *testing.T) {
+	t.Parallel()
+
+	got, err := executionLimitsFromProto(nil)
+	if err != nil {
+		t.Fatalf("executionLimitsFromProto returned error: %v", err)
+	}
+	if got != (backend.ResourceLimits{}) {
+		t.Fatalf("expected zero limits, got %+v", got)
+	}
+}
+
+func TestExecutionLimitsFromProtoRejectsInvalidValues(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name   string
+		limits *cleanroomv1.ExecutionResourceLimits
+		want   string
+	}{
+		{name: "nice", limits: &cleanroomv1.ExecutionResourceLimits{Nice: 20}, want: "limits.nice"},
+		{name: "io class", limits: &cleanroomv1.ExecutionResourceLimits{IoClass: "fast"}, want: "limits.io_class"},
+		{name: "io priority range", limits: &cleanroomv1.ExecutionResourceLimits{IoClass: "best-effort", IoPriority: 8}, want: "limits.io_priority"},
+		{name: "io priority without class", limits: &cleanroomv1.ExecutionResourceLimits{IoPriority: 3}, want: "requires io_class"},
+		{name: "cpu weight", limits: &cleanroomv1.ExecutionResourceLimits{CpuWeight: 10001}, want: "limits.cpu_weight"},
+		{name: "memory", limits: &cleanroomv1.ExecutionResourceLimits{MemoryMaxBytes: 1024}, want: "limits.memory_max_bytes"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := executionLimitsFromProto(tc.limits)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestCreateExecutionPassesGuestOptionsToBackend(t *testing.T) {
+	requests := make(chan backend.RunRequest, 1)
+	adapter := &stubAdapter{
+		runFn: func(_ context.Context, req backend.RunRequest) (*backend.RunResult, error) {
+			requests <- req
+			return &backend.RunResult{RunID: req.RunID, Message: "ok"}, nil
+		},
+	}
+	svc := newTestService(adapter)
+
+	createSandboxResp, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+
+	_, err = svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: createSandboxResp.GetSandbox().GetSandboxId(),
+		Command:   []string{"make", "test"},
+		Options: &cleanroomv1.ExecutionOptions{
+			Limits:                  &cleanroomv1.ExecutionResourceLimits{Nice: 5, IoClass: "idle", MemoryMaxBytes: 256 * 1024 * 1024},
+			KeepBackgroundProcesses: true,
+			FreshHome:               true,
+			Launcher:                cleanroomv1.ExecutionLauncher_EXECUTION_LAUNCHER_SYSTEMD,
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateExecution returned error: %v", err)
+	}
+
+	select {
+	case req := <-requests:
+		want := backend.ResourceLimits{Nice: 5, IOClass: "idle", MemoryMaxBytes: 256 * 1024 * 1024}
+		if req.Limits != want {
+			t.Fatalf("unexpected run request limits: got %+v want %+v", req.Limits, want)
+		}
+		if !req.KeepBackgroundProcesses {
+			t.Fatal("expected keep_background_processes to reach the backend")
+		}
+		if !req.FreshHome {
+			t.Fatal("expected fresh_home to reach the backend")
+		}
+		if got, want := req.Launcher, backend.ExecLauncherSystemd; got != want {
+			t.Fatalf("unexpected launcher: got %q want %q", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for backend run")
+	}
+}
+
+func TestCreateExecutionRejectsInvalidResourceLimits(t *testing.T) {
+	svc := newTestService(&stubAdapter{})
+
+	createSandboxResp, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+
+	_, err = svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: createSandboxResp.GetSandbox().GetSandboxId(),
+		Command:   []string{"make", "test"},
+		Options: &cleanroomv1.ExecutionOptions{
+			Limits: &cleanroomv1.ExecutionResourceLimits{Nice: -21},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected invalid limits to be rejected")
+	}
+	if !strings.Contains(err.Error(), "limits.nice") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCreateExecutionRejectsOversizedCommands(t *testing.T) {
+	svc := newTestService(&stubAdapter{})
+	svc.Config.Executions = runtimeconfig.Executions{MaxArgs: 3, MaxCommandBytes: 64, MaxStdinFrameBytes: 4}
+
+	createSandboxResp, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sandboxID := createSandboxResp.GetSandbox().GetSandboxId()
+
+	for command, want := range map[string]string{
+		"echo a b c":                      "4 arguments, at most 3 allowed",
+		"echo " + strings.Repeat("x", 64): "arguments total 70 bytes, at most 64 allowed",
+	} {
+		_, err := svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+			SandboxId: sandboxID,
+			Command:   strings.Fields(command),
+		})
+		if err == nil || !strings.Contains(err.Error(), "invalid command: "+want) {
+			t.Fatalf("expected %q to be rejected with %q, got %v", command, want, err)
+		}
+	}
+
+	err = svc.WriteExecutionStdin(sandboxID, "exec-1", []byte("hello"))
+	if err == nil || !strings.Contains(err.Error(), "invalid stdin: 5 bytes in one write, at most 4 allowed") {
+		t.Fatalf("expected oversized stdin write to be rejected, got %v", err)
+	}
+}
+
+func TestResolveExecutionLauncher(t *testing.T) {
+	t.Parallel()
+
+	cases := map[cleanroomv1.ExecutionLauncher]string{
+		cleanroomv1.ExecutionLauncher_EXECUTION_LAUNCHER_UNSPECIFIED: backend.ExecLauncherAuto,
+		cleanroomv1.ExecutionLauncher_EXECUTION_LAUNCHER_DIRECT:      backend.ExecLauncherDirect,
+		cleanroomv1.ExecutionLauncher_EXECUTION_LAUNCHER_SYSTEMD:     backend.ExecLauncherSystemd,
+	}
+	for in, want := range cases {
+		got, err := resolveExecutionLauncher(in)
+		if err != nil {
+			t.Fatalf("resolveExecutionLauncher(%v) returned error: %v", in, err)
+		}
+		if got != want {
+			t.Fatalf("resolveExecutionLauncher(%v) = %q, want %q", in, got, want)
+		}
+	}
+	if _, err := resolveExecutionLauncher(cleanroomv1.ExecutionLauncher(99)); err == nil {
+		t.Fatal("expected unknown launcher to be rejected")
+	}
+}
@@ -0,0 +1,78 @@
+package controlservice
+
+import (
+	"fmt"
+	"regexp"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+const (
+	maxSandboxLabels          = 32
+	maxSandboxLabelValueBytes = 256
+)
+
+// sandboxNamePattern also applies to label keys. Names are at most 63
+// characters so they fit in a DNS label.
+var sandboxNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+
+func validateSandboxName(name string) error {
+	if name == "" {
+		return nil
+	}
+	if !sandboxNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid name %q: must be 1-63 letters, digits, '.', '_' or '-', starting with a letter or digit", name)
+	}
+	return nil
+}
+
+func sandboxLabelsFromProto(in map[string]string) (map[string]string, error) {
+	return keyValuesFromProto("label", in)
+}
+
+// keyValuesFromProto validates the labels or annotations (what) a request
+// carries: keys follow sandboxNamePattern and values are bounded.
+func keyValuesFromProto(what string, in map[string]string) (map[string]string, error) {
+	if len(in) == 0 {
+		return nil, nil
+	}
+	if len(in) > maxSandboxLabels {
+		return nil, fmt.Errorf("invalid %ss: at most %d allowed, got %d", what, maxSandboxLabels, len(in))
+	}
+	out := make(map[string]string, len(in))
+	for key, value := range in {
+		if !sandboxNamePattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid %s key %q: must be 1-63 letters, digits, '.', '_' or '-', starting with a letter or digit", what, key)
+		}
+		if len(value) > maxSandboxLabelValueBytes {
+			return nil, fmt.Errorf("invalid %s %q: value exceeds %d bytes", what, key, maxSandboxLabelValueBytes)
+		}
+		out[key] = value
+	}
+	return out, nil
+}
+
+// pendingNameKey keys pendingNames: names are unique per namespace.
+func pendingNameKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// sandboxNameInUseLocked reports whether a live sandbox in namespace, or a
+// create still provisioning there, already holds name. Stopped and failed
+// sandboxes release their names.
+func (s *Service) sandboxNameInUseLocked(namespace, name string) bool {
+	if _, ok := s.pendingNames[pendingNameKey(namespace, name)]; ok {
+		return true
+	}
+	for _, sb := range s.sandboxes {
+		if sb.Name != name || sb.Namespace != namespace {
+			continue
+		}
+		switch sb.Status {
+		case cleanroomv1.SandboxStatus_SANDBOX_STATUS_STOPPED, cleanroomv1.SandboxStatus_SANDBOX_STATUS_FAILED:
+		default:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package controlservice
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+)
+
+// DefaultNamespace holds the sandboxes of callers namespaces.identities
+// does not name.
+const DefaultNamespace = "default"
+
+// namespaceOrDefault returns namespace, or DefaultNamespace for records
+// that carry none.
+func namespaceOrDefault(namespace string) string {
+	if namespace == "" {
+		return DefaultNamespace
+	}
+	return namespace
+}
+
+// callerNamespace returns the namespace ctx's caller works in, and whether
+// the caller administers every namespace.
+func callerNamespace(ctx context.Context, cfg runtimeconfig.Namespaces) (string, bool) {
+	identity := CallerIdentity(ctx)
+	admin := identity != "" && slices.Contains(cfg.Admins, identity)
+	if namespace := cfg.Identities[identity]; namespace != "" {
+		return namespace, admin
+	}
+	return DefaultNamespace, admin
+}
+
+// requestNamespace returns the namespace a call naming requested works in:
+// the caller's own when requested is empty. Only admins may name another.
+func requestNamespace(ctx context.Context, cfg runtimeconfig.Namespaces, requested string) (string, error) {
+	own, admin := callerNamespace(ctx, cfg)
+	requested = strings.TrimSpace(requested)
+	if requested == "" || requested == own {
+		return own, nil
+	}
+	if !runtimeconfig.ValidNamespace(requested) {
+		return "", fmt.Errorf("invalid namespace %q: must be 1-63 lowercase letters, digits or '-', starting and ending with a letter or digit", requested)
+	}
+	if !admin {
+		return "", fmt.Errorf("%w: namespace %q is not the caller's namespace %q", ErrNamespaceDenied, requested, own)
+	}
+	return requested, nil
+}
+
+// canAccessNamespace reports whether ctx's caller may see and act on
+// sandboxes in namespace.
+func canAccessNamespace(ctx context.Context, cfg runtimeconfig.Namespaces, namespace string) bool {
+	own, admin := callerNamespace(ctx, cfg)
+	return admin || namespace == own
+}
+
+// CheckSandboxAccess fails with the error an unknown sandbox gets when
+// sandboxID is in a namespace ctx's caller cannot access, so callers learn
+// nothing about other teams' sandboxes. Sandboxes no instance knows are
+// left for the call itself to report.
+func (s *Service) CheckSandboxAccess(ctx context.Context, sandboxID string) error {
+	sandboxID = strings.TrimSpace(sandboxID)
+	if sandboxID == "" {
+		return nil
+	}
+	s.mu.RLock()
+	cfg := s.Config.Namespaces
+	sb, local := s.sandboxes[sandboxID]
+	namespace := ""
+	if local {
+		namespace = sb.Namespace
+	}
+	s.mu.RUnlock()
+	if !local {
+		rec, ok := s.remoteSandbox(ctx, sandboxID)
+		if !ok {
+			return nil
+		}
+		namespace = rec.Sandbox.GetNamespace()
+	}
+	if !canAccessNamespace(ctx, cfg, namespaceOrDefault(namespace)) {
+		return fmt.Errorf("%w %q", ErrUnknownSandbox, sandboxID)
+	}
+	return nil
+}
+
+// listNamespaceFilter returns a filter reporting whether something in a
+// namespace belongs in the answer to a list call naming namespace, or
+// every namespace.
+func listNamespaceFilter(ctx context.Context, cfg runtimeconfig.Namespaces, requested string, all bool) (func(namespace string) bool, error) {
+	if all {
+		if _, admin := callerNamespace(ctx, cfg); !admin {
+			return nil, fmt.Errorf("%w: listing every namespace needs an admin", ErrNamespaceDenied)
+		}
+		return func(string) bool { return true }, nil
+	}
+	namespace, err := requestNamespace(ctx, cfg, requested)
+	if err != nil {
+		return nil, err
+	}
+	return func(got string) bool { return namespaceOrDefault(got) == namespace }, nil
+}
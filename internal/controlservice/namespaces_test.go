@@ -0,0 +1,85 @@
+package controlservice
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+)
+
+func TestNamespacesKeepTeamsApart(t *testing.T) {
+	t.Parallel()
+
+	adapter := &stubAdapter{
+		runFn: func(_ context.Context, req backend.RunRequest) (*backend.RunResult, error) {
+			return &backend.RunResult{RunID: req.RunID}, nil
+		},
+	}
+	svc := newTestService(adapter)
+	svc.Config.Namespaces = runtimeconfig.Namespaces{
+		Identities: map[string]string{"uid:1001": "team-a", "uid:1002": "team-b"},
+		Admins:     []string{"uid:0"},
+	}
+	teamA := WithCallerIdentity(context.Background(), "uid:1001")
+	teamB := WithCallerIdentity(context.Background(), "uid:1002")
+	admin := WithCallerIdentity(context.Background(), "uid:0")
+
+	create := func(ctx context.Context, namespace string) (*cleanroomv1.Sandbox, error) {
+		resp, err := svc.CreateSandbox(ctx, &cleanroomv1.CreateSandboxRequest{Policy: testPolicy(), Name: "web", Namespace: namespace})
+		return resp.GetSandbox(), err
+	}
+	a, err := create(teamA, "")
+	if err != nil {
+		t.Fatalf("CreateSandbox for team-a returned error: %v", err)
+	}
+	if a.GetNamespace() != "team-a" {
+		t.Fatalf("expected team-a's sandbox in its namespace, got %q", a.GetNamespace())
+	}
+	// Names are unique per namespace, so team-b can use the same one.
+	if _, err := create(teamB, ""); err != nil {
+		t.Fatalf("CreateSandbox for team-b returned error: %v", err)
+	}
+	if _, err := create(teamB, "team-a"); !errors.Is(err, ErrNamespaceDenied) {
+		t.Fatalf("expected team-b to be refused team-a's namespace, got %v", err)
+	}
+	if _, err := create(admin, "team-a"); !errors.Is(err, ErrSandboxNameTaken) {
+		t.Fatalf("expected the admin's create in team-a to clash with its name, got %v", err)
+	}
+
+	list, err := svc.ListSandboxes(teamB, &cleanroomv1.ListSandboxesRequest{})
+	if err != nil {
+		t.Fatalf("ListSandboxes returned error: %v", err)
+	}
+	if len(list.GetSandboxes()) != 1 || list.GetSandboxes()[0].GetNamespace() != "team-b" {
+		t.Fatalf("expected only team-b's sandbox, got %v", list.GetSandboxes())
+	}
+	if _, err := svc.ListSandboxes(teamB, &cleanroomv1.ListSandboxesRequest{AllNamespaces: true}); !errors.Is(err, ErrNamespaceDenied) {
+		t.Fatalf("expected listing every namespace to need an admin, got %v", err)
+	}
+	list, err = svc.ListSandboxes(admin, &cleanroomv1.ListSandboxesRequest{AllNamespaces: true})
+	if err != nil || len(list.GetSandboxes()) != 2 {
+		t.Fatalf("expected the admin to see both sandboxes, got %v %v", list.GetSandboxes(), err)
+	}
+	list, err = svc.ListSandboxes(admin, &cleanroomv1.ListSandboxesRequest{Namespace: "team-a"})
+	if err != nil || len(list.GetSandboxes()) != 1 || list.GetSandboxes()[0].GetSandboxId() != a.GetSandboxId() {
+		t.Fatalf("expected the admin to see team-a's sandbox, got %v %v", list.GetSandboxes(), err)
+	}
+
+	if err := svc.CheckSandboxAccess(teamB, a.GetSandboxId()); err == nil || !strings.Contains(err.Error(), "unknown sandbox") {
+		t.Fatalf("expected team-a's sandbox to be unknown to team-b, got %v", err)
+	}
+	for _, ctx := range []context.Context{teamA, admin} {
+		if err := svc.CheckSandboxAccess(ctx, a.GetSandboxId()); err != nil {
+			t.Fatalf("CheckSandboxAccess returned error: %v", err)
+		}
+	}
+	// Callers nobody mapped share the default namespace.
+	list, err = svc.ListSandboxes(context.Background(), &cleanroomv1.ListSandboxesRequest{})
+	if err != nil || len(list.GetSandboxes()) != 0 {
+		t.Fatalf("expected the default namespace to be empty, got %v %v", list.GetSandboxes(), err)
+	}
+}
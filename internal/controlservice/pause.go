@@ -0,0 +1,102 @@
+package controlservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// PauseSandbox stops an idle sandbox's vCPUs, keeping it in memory until
+// ResumeSandbox. A paused sandbox accepts no executions or other
+// operations; it can still be terminated.
+func (s *Service) PauseSandbox(ctx context.Context, req *cleanroomv1.PauseSandboxRequest) (*cleanroomv1.PauseSandboxResponse, error) {
+	if req == nil {
+		return nil, errors.New("missing request")
+	}
+	sandboxID := strings.TrimSpace(req.GetSandboxId())
+	if sandboxID == "" {
+		return nil, errors.New("missing sandbox_id")
+	}
+
+	backendName, adapter, release, err := s.beginSandboxOperation(sandboxID, "pause")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	pauser, ok := adapter.(backend.SandboxPauseAdapter)
+	if !ok {
+		return nil, fmt.Errorf("backend %q does not support pausing sandboxes", backendName)
+	}
+	if err := pauser.PauseSandbox(ctx, sandboxID); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.sandboxes[sandboxID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownSandbox, sandboxID)
+	}
+	// A sandbox terminated while pausing stays terminated.
+	if state.Status == cleanroomv1.SandboxStatus_SANDBOX_STATUS_READY {
+		state.Status = cleanroomv1.SandboxStatus_SANDBOX_STATUS_PAUSED
+		s.recordSandboxEventLocked(state, state.Status, "sandbox paused")
+	}
+	return &cleanroomv1.PauseSandboxResponse{Sandbox: cloneSandboxLocked(state)}, nil
+}
+
+// ResumeSandbox restarts a paused sandbox's vCPUs and makes it ready again.
+func (s *Service) ResumeSandbox(ctx context.Context, req *cleanroomv1.ResumeSandboxRequest) (*cleanroomv1.ResumeSandboxResponse, error) {
+	if req == nil {
+		return nil, errors.New("missing request")
+	}
+	sandboxID := strings.TrimSpace(req.GetSandboxId())
+	if sandboxID == "" {
+		return nil, errors.New("missing sandbox_id")
+	}
+
+	s.mu.Lock()
+	state, ok := s.sandboxes[sandboxID]
+	switch {
+	case !ok:
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w %q", ErrUnknownSandbox, sandboxID)
+	case state.Status != cleanroomv1.SandboxStatus_SANDBOX_STATUS_PAUSED:
+		s.mu.Unlock()
+		return nil, fmt.Errorf("sandbox %q is not paused", sandboxID)
+	case state.BusyWith != "":
+		s.mu.Unlock()
+		return nil, fmt.Errorf("sandbox_busy: sandbox %q already has an active %s", sandboxID, state.BusyWith)
+	}
+	backendName := state.Backend
+	pauser, ok := s.Backends[backendName].(backend.SandboxPauseAdapter)
+	if !ok {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("backend %q does not support pausing sandboxes", backendName)
+	}
+	state.BusyWith = "resume"
+	s.mu.Unlock()
+
+	err := pauser.ResumeSandbox(ctx, sandboxID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok = s.sandboxes[sandboxID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownSandbox, sandboxID)
+	}
+	state.BusyWith = ""
+	if err != nil {
+		return nil, err
+	}
+	// A sandbox terminated while resuming stays terminated.
+	if state.Status == cleanroomv1.SandboxStatus_SANDBOX_STATUS_PAUSED {
+		state.Status = cleanroomv1.SandboxStatus_SANDBOX_STATUS_READY
+		s.recordSandboxEventLocked(state, state.Status, "sandbox resumed")
+	}
+	return &cleanroomv1.ResumeSandboxResponse{Sandbox: cloneSandboxLocked(state)}, nil
+}
@@ -0,0 +1,72 @@
+package controlservice
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// pauseAdapter records which sandboxes the backend has paused.
+type pauseAdapter struct {
+	*stubAdapter
+	paused map[string]bool
+}
+
+func (a *pauseAdapter) PauseSandbox(_ context.Context, sandboxID string) error {
+	a.paused[sandboxID] = true
+	return nil
+}
+
+func (a *pauseAdapter) ResumeSandbox(_ context.Context, sandboxID string) error {
+	delete(a.paused, sandboxID)
+	return nil
+}
+
+func TestPauseSandboxHoldsExecutionsUntilResumed(t *testing.T) {
+	adapter := &pauseAdapter{stubAdapter: &stubAdapter{}, paused: map[string]bool{}}
+	svc := newTestService(adapter)
+	created, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sandboxID := created.GetSandbox().GetSandboxId()
+
+	paused, err := svc.PauseSandbox(context.Background(), &cleanroomv1.PauseSandboxRequest{SandboxId: sandboxID})
+	if err != nil {
+		t.Fatalf("PauseSandbox returned error: %v", err)
+	}
+	if paused.GetSandbox().GetStatus() != cleanroomv1.SandboxStatus_SANDBOX_STATUS_PAUSED || !adapter.paused[sandboxID] {
+		t.Fatalf("expected sandbox to be paused, got %s", paused.GetSandbox().GetStatus())
+	}
+	if _, err := svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{SandboxId: sandboxID, Command: []string{"true"}}); err == nil || !strings.Contains(err.Error(), "is not ready") {
+		t.Fatalf("expected executions to be rejected while paused, got %v", err)
+	}
+	if _, err := svc.PauseSandbox(context.Background(), &cleanroomv1.PauseSandboxRequest{SandboxId: sandboxID}); err == nil {
+		t.Fatal("expected pausing a paused sandbox to fail")
+	}
+
+	resumed, err := svc.ResumeSandbox(context.Background(), &cleanroomv1.ResumeSandboxRequest{SandboxId: sandboxID})
+	if err != nil {
+		t.Fatalf("ResumeSandbox returned error: %v", err)
+	}
+	if resumed.GetSandbox().GetStatus() != cleanroomv1.SandboxStatus_SANDBOX_STATUS_READY || adapter.paused[sandboxID] {
+		t.Fatalf("expected sandbox to be ready, got %s", resumed.GetSandbox().GetStatus())
+	}
+	if _, err := svc.ResumeSandbox(context.Background(), &cleanroomv1.ResumeSandboxRequest{SandboxId: sandboxID}); err == nil || !strings.Contains(err.Error(), "is not paused") {
+		t.Fatalf("expected resuming a ready sandbox to fail, got %v", err)
+	}
+}
+
+func TestPauseSandboxRequiresBackendSupport(t *testing.T) {
+	svc := newTestService(&stubAdapter{})
+	created, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	_, err = svc.PauseSandbox(context.Background(), &cleanroomv1.PauseSandboxRequest{SandboxId: created.GetSandbox().GetSandboxId()})
+	if err == nil || !strings.Contains(err.Error(), "does not support pausing") {
+		t.Fatalf("expected unsupported backend error, got %v", err)
+	}
+}
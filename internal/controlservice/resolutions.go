@@ -0,0 +1,52 @@
+package controlservice
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// pinnedResolutionsFromProto validates the resolutions a create request
+// pins. Backends that cannot honour them reject the request rather than
+// silently resolving with DNS.
+func pinnedResolutionsFromProto(in []*cleanroomv1.HostResolution, backendName string, adapter backend.Adapter) ([]backend.HostResolution, error) {
+	if len(in) == 0 {
+		return nil, nil
+	}
+	if !backend.CapabilitiesForAdapter(adapter)[backend.CapabilityNetworkPinnedDNS] {
+		return nil, fmt.Errorf("backend %q does not support pinned resolutions", backendName)
+	}
+	out := make([]backend.HostResolution, 0, len(in))
+	for _, entry := range in {
+		host := strings.TrimSpace(strings.ToLower(entry.GetHost()))
+		if host == "" {
+			return nil, errors.New("pinned resolution is missing a host")
+		}
+		if len(entry.GetAddresses()) == 0 {
+			return nil, fmt.Errorf("pinned resolution for %q has no addresses", host)
+		}
+		for _, address := range entry.GetAddresses() {
+			if ip := net.ParseIP(address); ip == nil || ip.To4() == nil {
+				return nil, fmt.Errorf("pinned resolution for %q: %q is not an IPv4 address", host, address)
+			}
+		}
+		out = append(out, backend.HostResolution{Host: host, Addresses: slices.Clone(entry.GetAddresses())})
+	}
+	return out, nil
+}
+
+func hostResolutionsToProto(in []backend.HostResolution) []*cleanroomv1.HostResolution {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make([]*cleanroomv1.HostResolution, 0, len(in))
+	for _, entry := range in {
+		out = append(out, &cleanroomv1.HostResolution{Host: entry.Host, Addresses: slices.Clone(entry.Addresses)})
+	}
+	return out
+}
@@ -0,0 +1,75 @@
+package controlservice
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// resolvingAdapter records the pins it was given as the sandbox's
+// resolutions, falling back to a fixed DNS answer.
+type resolvingAdapter struct {
+	*stubAdapter
+}
+
+func (a *resolvingAdapter) SandboxResolutions(string) ([]backend.HostResolution, error) {
+	if pinned := a.provisionReq.PinnedResolutions; len(pinned) > 0 {
+		return pinned, nil
+	}
+	return []backend.HostResolution{{Host: "api.github.com", Addresses: []string{"203.0.113.10"}}}, nil
+}
+
+func TestCreateSandboxRecordsAndPinsResolutions(t *testing.T) {
+	adapter := &resolvingAdapter{stubAdapter: &stubAdapter{}}
+	svc := newTestService(adapter)
+
+	first, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	got, err := svc.GetSandbox(context.Background(), &cleanroomv1.GetSandboxRequest{SandboxId: first.GetSandbox().GetSandboxId()})
+	if err != nil {
+		t.Fatalf("GetSandbox returned error: %v", err)
+	}
+	recorded := got.GetSandbox().GetResolutions()
+	if len(recorded) != 1 || recorded[0].GetHost() != "api.github.com" || strings.Join(recorded[0].GetAddresses(), ",") != "203.0.113.10" {
+		t.Fatalf("unexpected recorded resolutions %v", recorded)
+	}
+
+	second, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{
+		Policy:            testPolicy(),
+		PinnedResolutions: []*cleanroomv1.HostResolution{{Host: "API.github.com", Addresses: []string{"198.51.100.4"}}},
+	})
+	if err != nil {
+		t.Fatalf("CreateSandbox with pins returned error: %v", err)
+	}
+	if pinned := adapter.provisionReq.PinnedResolutions; len(pinned) != 1 || pinned[0].Host != "api.github.com" || pinned[0].Addresses[0] != "198.51.100.4" {
+		t.Fatalf("unexpected pins passed to the backend %+v", pinned)
+	}
+	if got := second.GetSandbox().GetResolutions(); len(got) != 1 || got[0].GetAddresses()[0] != "198.51.100.4" {
+		t.Fatalf("unexpected pinned sandbox resolutions %v", got)
+	}
+
+	_, err = svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{
+		Policy:            testPolicy(),
+		PinnedResolutions: []*cleanroomv1.HostResolution{{Host: "api.github.com", Addresses: []string{"2001:db8::1"}}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "not an IPv4 address") {
+		t.Fatalf("expected an invalid pin error, got %v", err)
+	}
+}
+
+func TestCreateSandboxRejectsPinsWithoutBackendSupport(t *testing.T) {
+	svc := newTestService(&stubAdapter{})
+
+	_, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{
+		Policy:            testPolicy(),
+		PinnedResolutions: []*cleanroomv1.HostResolution{{Host: "api.github.com", Addresses: []string{"203.0.113.10"}}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "does not support pinned resolutions") {
+		t.Fatalf("expected an unsupported backend error, got %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+package controlservice
+
+import (
+	"fmt"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	"github.com/buildkite/cleanroom/internal/policy"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+)
+
+// applyPolicyResources sizes the sandbox VM from the policy's resource
+// request, clamped to the backend's configured maxima. A zero maximum leaves
+// that resource unclamped. It returns a note for every value it had to
+// change so the caller can tell the user.
+func applyPolicyResources(out *backend.FirecrackerConfig, backendName string, req *policy.Resources, cfg runtimeconfig.Config) []string {
+	if req == nil {
+		return nil
+	}
+	maxVCPUs := cfg.Backends.Firecracker.MaxVCPUs
+	maxMemoryMiB := cfg.Backends.Firecracker.MaxMemoryMiB
+	if backendName == "darwin-vz" {
+		maxVCPUs = cfg.Backends.DarwinVZ.MaxVCPUs
+		maxMemoryMiB = cfg.Backends.DarwinVZ.MaxMemoryMiB
+	}
+
+	var notes []string
+	clamp := func(key string, requested, limit int64) int64 {
+		if limit > 0 && requested > limit {
+			notes = append(notes, fmt.Sprintf("sandbox.resources.%s=%d exceeds the server maximum, using %d", key, requested, limit))
+			return limit
+		}
+		return requested
+	}
+	if req.VCPUs > 0 {
+		out.VCPUs = clamp("vcpus", req.VCPUs, maxVCPUs)
+	}
+	if req.MemoryMiB > 0 {
+		out.MemoryMiB = clamp("memory_mib", req.MemoryMiB, maxMemoryMiB)
+	}
+	// These resources are only enforced by firecracker.
+	firecrackerOnly := func(key string, requested, limit int64, dst *int64) {
+		if requested <= 0 {
+			return
+		}
+		if backendName == "darwin-vz" {
+			notes = append(notes, fmt.Sprintf("sandbox.resources.%s=%d is not supported by the darwin-vz backend, ignoring it", key, requested))
+			return
+		}
+		*dst = clamp(key, requested, limit)
+	}
+	fc := cfg.Backends.Firecracker
+	firecrackerOnly("disk_mib", req.DiskMiB, fc.MaxDiskMiB, &out.DiskMiB)
+	firecrackerOnly("egress_mbps", req.EgressMbps, fc.MaxEgressMbps, &out.EgressMbps)
+	firecrackerOnly("ingress_mbps", req.IngressMbps, fc.MaxIngressMbps, &out.IngressMbps)
+	firecrackerOnly("disk_iops", req.DiskIOPS, fc.MaxDiskIOPS, &out.DiskIOPS)
+	firecrackerOnly("disk_mibps", req.DiskMiBps, fc.MaxDiskMiBps, &out.DiskMiBps)
+	return notes
+}
+
+// checkExecutionVMSize validates an execution's vcpus and memory_mib
+// overrides. They only apply to backends that boot a VM per execution, and
+// may not exceed the sandbox's size, which already reflects the policy's
+// sandbox.resources. When the sandbox size is unset the backend's server
+// maximum is the bound instead.
+func checkExecutionVMSize(opts executionOptions, sandbox *sandboxState, adapter backend.Adapter, cfg runtimeconfig.Config) error {
+	if opts.VCPUs == 0 && opts.MemoryMiB == 0 {
+		return nil
+	}
+	if opts.VCPUs < 0 {
+		return fmt.Errorf("invalid vcpus %d: must not be negative", opts.VCPUs)
+	}
+	if opts.MemoryMiB < 0 {
+		return fmt.Errorf("invalid memory_mib %d: must not be negative", opts.MemoryMiB)
+	}
+	if _, ok := adapter.(backend.PersistentSandboxAdapter); ok {
+		return fmt.Errorf("vcpus and memory_mib overrides need a backend that boots a VM per execution; %q runs every execution in the sandbox's VM", sandbox.Backend)
+	}
+
+	maxVCPUs := cfg.Backends.Firecracker.MaxVCPUs
+	maxMemoryMiB := cfg.Backends.Firecracker.MaxMemoryMiB
+	if sandbox.Backend == "darwin-vz" {
+		maxVCPUs = cfg.Backends.DarwinVZ.MaxVCPUs
+		maxMemoryMiB = cfg.Backends.DarwinVZ.MaxMemoryMiB
+	}
+	check := func(key string, requested, sandboxSize, serverMax int64) error {
+		limit := sandboxSize
+		if limit == 0 {
+			limit = serverMax
+		}
+		if limit > 0 && requested > limit {
+			return fmt.Errorf("%s %d exceeds the sandbox's limit of %d; raise sandbox.resources.%s in the policy", key, requested, limit, key)
+		}
+		return nil
+	}
+	if err := check("vcpus", opts.VCPUs, sandbox.Firecracker.VCPUs, maxVCPUs); err != nil {
+		return err
+	}
+	return check("memory_mib", opts.MemoryMiB, sandbox.Firecracker.MemoryMiB, maxMemoryMiB)
+}
+
+// checkPolicyDevices rejects a policy that asks for passthrough devices the
+// server has not configured, or that the backend cannot attach.
+func checkPolicyDevices(compiled *policy.CompiledPolicy, backendName string, adapter backend.Adapter, cfg runtimeconfig.Config) error {
+	if len(compiled.VFIODevices) == 0 {
+		return nil
+	}
+	configured := map[string]bool{}
+	for _, device := range cfg.Devices.VFIO {
+		configured[device.Name] = true
+	}
+	for _, name := range compiled.VFIODevices {
+		if !configured[name] {
+			return fmt.Errorf("policy requests vfio device %q, which is not configured on this server", name)
+		}
+	}
+	if !backend.CapabilitiesForAdapter(adapter)[backend.CapabilityDeviceVFIO] {
+		return fmt.Errorf("policy requests vfio devices, which backend %q cannot attach", backendName)
+	}
+	return nil
+}
+
+// nestedVirtualizationWarning is added to the creation message of sandboxes
+// whose policy enables nested virtualization.
+const nestedVirtualizationWarning = "nested virtualization enabled: the guest has /dev/kvm and can run its own VMs, which reduces isolation from the host"
+
+// checkDockerPreload rejects a policy that preloads docker images on a
+// backend that cannot load them at provision time.
+func checkDockerPreload(compiled *policy.CompiledPolicy, backendName string, adapter backend.Adapter) error {
+	if len(compiled.Services.Docker.Preload) == 0 {
+		return nil
+	}
+	if !backend.CapabilitiesForAdapter(adapter)[backend.CapabilityDockerPreload] {
+		return fmt.Errorf("policy preloads docker images, which backend %q does not support", backendName)
+	}
+	return nil
+}
+
+// checkNestedVirtualization rejects a policy that enables nested
+// virtualization on a backend or host that cannot provide it.
+func checkNestedVirtualization(compiled *policy.CompiledPolicy, backendName string, adapter backend.Adapter) error {
+	if !compiled.NestedVirtualization {
+		return nil
+	}
+	if !backend.CapabilitiesForAdapter(adapter)[backend.CapabilityNestedVirtualization] {
+		return fmt.Errorf("policy enables nested virtualization, which backend %q does not support on this host", backendName)
+	}
+	return nil
+}
+
+// checkReadOnlyRootFS rejects a policy that boots the rootfs read-only on a
+// backend that always attaches it writable.
+func checkReadOnlyRootFS(compiled *policy.CompiledPolicy, backendName string, adapter backend.Adapter) error {
+	if compiled.ReadOnlyRootFS == nil {
+		return nil
+	}
+	if !backend.CapabilitiesForAdapter(adapter)[backend.CapabilityReadOnlyRootFS] {
+		return fmt.Errorf("policy makes the rootfs read-only, which backend %q does not support", backendName)
+	}
+	return nil
+}
+
+// checkSetup rejects a policy with setup commands on a backend that would
+// otherwise start sandboxes without running them.
+func checkSetup(compiled *policy.CompiledPolicy, backendName string, adapter backend.Adapter) error {
+	if len(compiled.Setup) == 0 {
+		return nil
+	}
+	if !backend.CapabilitiesForAdapter(adapter)[backend.CapabilitySandboxSetup] {
+		return fmt.Errorf("policy declares sandbox.setup commands, which backend %q does not support", backendName)
+	}
+	return nil
+}
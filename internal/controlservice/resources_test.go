@@ -0,0 +1,265 @@
+package controlservice
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/policy"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+)
+
+func TestCreateSandboxClampsPolicyResourcesToServerMaxima(t *testing.T) {
+	t.Parallel()
+
+	adapter := &stubAdapter{}
+	svc := newTestService(adapter)
+	svc.Config.Backends.Firecracker = runtimeconfig.FirecrackerConfig{
+		VCPUs:        1,
+		MemoryMiB:    512,
+		MaxVCPUs:     8,
+		MaxMemoryMiB: 4096,
+	}
+
+	pol := testPolicy()
+	pol.Resources = &cleanroomv1.PolicyResources{Vcpus: 16, MemoryMib: 2048, DiskMib: 10240}
+	resp, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: pol})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+
+	gotCfg := adapter.provisionReq.FirecrackerConfig
+	if got, want := gotCfg.VCPUs, int64(8); got != want {
+		t.Fatalf("unexpected vcpus: got %d want %d", got, want)
+	}
+	if got, want := gotCfg.MemoryMiB, int64(2048); got != want {
+		t.Fatalf("unexpected memory_mib: got %d want %d", got, want)
+	}
+	// No max_disk_mib is configured, so the request is honoured as is.
+	if got, want := gotCfg.DiskMiB, int64(10240); got != want {
+		t.Fatalf("unexpected disk_mib: got %d want %d", got, want)
+	}
+	if !strings.Contains(resp.GetMessage(), "sandbox.resources.vcpus=16 exceeds the server maximum, using 8") {
+		t.Fatalf("expected clamp note in message, got %q", resp.GetMessage())
+	}
+}
+
+func TestApplyPolicyResourcesUsesDarwinVZMaximaAndIgnoresDisk(t *testing.T) {
+	t.Parallel()
+
+	cfg := runtimeconfig.Config{}
+	cfg.Backends.Firecracker.MaxVCPUs = 1
+	cfg.Backends.DarwinVZ.MaxVCPUs = 6
+
+	var out backend.FirecrackerConfig
+	notes := applyPolicyResources(&out, "darwin-vz", &policy.Resources{VCPUs: 4, DiskMiB: 4096}, cfg)
+	if got, want := out.VCPUs, int64(4); got != want {
+		t.Fatalf("unexpected vcpus: got %d want %d", got, want)
+	}
+	if got, want := out.DiskMiB, int64(0); got != want {
+		t.Fatalf("unexpected disk_mib: got %d want %d", got, want)
+	}
+	if len(notes) != 1 || !strings.Contains(notes[0], "not supported by the darwin-vz backend") {
+		t.Fatalf("unexpected notes: %v", notes)
+	}
+}
+
+func TestApplyPolicyResourcesClampsBandwidth(t *testing.T) {
+	t.Parallel()
+
+	cfg := runtimeconfig.Config{}
+	cfg.Backends.Firecracker.MaxEgressMbps = 100
+
+	out := backend.FirecrackerConfig{EgressMbps: 50, IngressMbps: 50}
+	notes := applyPolicyResources(&out, "firecracker", &policy.Resources{EgressMbps: 400, IngressMbps: 800}, cfg)
+	if got, want := out.EgressMbps, int64(100); got != want {
+		t.Fatalf("unexpected egress_mbps: got %d want %d", got, want)
+	}
+	// No max_ingress_mbps is configured, so the request is honoured as is.
+	if got, want := out.IngressMbps, int64(800); got != want {
+		t.Fatalf("unexpected ingress_mbps: got %d want %d", got, want)
+	}
+	if len(notes) != 1 || !strings.Contains(notes[0], "sandbox.resources.egress_mbps=400 exceeds the server maximum, using 100") {
+		t.Fatalf("unexpected notes: %v", notes)
+	}
+
+	out = backend.FirecrackerConfig{}
+	notes = applyPolicyResources(&out, "darwin-vz", &policy.Resources{EgressMbps: 400}, cfg)
+	if out.EgressMbps != 0 {
+		t.Fatalf("expected darwin-vz to ignore egress_mbps, got %d", out.EgressMbps)
+	}
+	if len(notes) != 1 || !strings.Contains(notes[0], "not supported by the darwin-vz backend") {
+		t.Fatalf("unexpected notes: %v", notes)
+	}
+}
+
+func TestApplyPolicyResourcesClampsDiskIO(t *testing.T) {
+	t.Parallel()
+
+	cfg := runtimeconfig.Config{}
+	cfg.Backends.Firecracker.MaxDiskIOPS = 2000
+	cfg.Backends.Firecracker.MaxDiskMiBps = 200
+
+	var out backend.FirecrackerConfig
+	notes := applyPolicyResources(&out, "firecracker", &policy.Resources{DiskIOPS: 5000, DiskMiBps: 100}, cfg)
+	if got, want := out.DiskIOPS, int64(2000); got != want {
+		t.Fatalf("unexpected disk_iops: got %d want %d", got, want)
+	}
+	if got, want := out.DiskMiBps, int64(100); got != want {
+		t.Fatalf("unexpected disk_mibps: got %d want %d", got, want)
+	}
+	if len(notes) != 1 || !strings.Contains(notes[0], "sandbox.resources.disk_iops=5000 exceeds the server maximum, using 2000") {
+		t.Fatalf("unexpected notes: %v", notes)
+	}
+}
+
+type oneShotAdapter struct {
+	req backend.RunRequest
+}
+
+func (a *oneShotAdapter) Name() string { return "one-shot" }
+
+func (a *oneShotAdapter) Run(_ context.Context, req backend.RunRequest) (*backend.RunResult, error) {
+	a.req = req
+	return &backend.RunResult{RunID: req.RunID}, nil
+}
+
+func TestExecutionVMSizeOverridesStayWithinPolicyResources(t *testing.T) {
+	t.Parallel()
+
+	adapter := &oneShotAdapter{}
+	svc := newTestService(adapter)
+	pol := testPolicy()
+	pol.Resources = &cleanroomv1.PolicyResources{Vcpus: 8, MemoryMib: 8192}
+	createResp, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: pol})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sandboxID := createResp.GetSandbox().GetSandboxId()
+
+	_, err = svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   []string{"make"},
+		Options:   &cleanroomv1.ExecutionOptions{Vcpus: 16},
+	})
+	if err == nil || !strings.Contains(err.Error(), "vcpus 16 exceeds the sandbox's limit of 8") {
+		t.Fatalf("expected vcpus bound error, got %v", err)
+	}
+
+	execResp, err := svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   []string{"make", "lint"},
+		Options:   &cleanroomv1.ExecutionOptions{Vcpus: 1, MemoryMib: 1024},
+	})
+	if err != nil {
+		t.Fatalf("CreateExecution returned error: %v", err)
+	}
+	history, updates, done, unsubscribe, err := svc.SubscribeExecutionEvents(sandboxID, execResp.GetExecution().GetExecutionId())
+	if err != nil {
+		t.Fatalf("SubscribeExecutionEvents returned error: %v", err)
+	}
+	defer unsubscribe()
+	collectExecutionEvents(t, history, updates, done)
+
+	if got, want := adapter.req.FirecrackerConfig.VCPUs, int64(1); got != want {
+		t.Fatalf("unexpected vcpus: got %d want %d", got, want)
+	}
+	if got, want := adapter.req.FirecrackerConfig.MemoryMiB, int64(1024); got != want {
+		t.Fatalf("unexpected memory_mib: got %d want %d", got, want)
+	}
+}
+
+func TestExecutionVMSizeOverridesRejectedForPersistentSandboxes(t *testing.T) {
+	t.Parallel()
+
+	svc := newTestService(&stubAdapter{})
+	createResp, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	_, err = svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: createResp.GetSandbox().GetSandboxId(),
+		Command:   []string{"make"},
+		Options:   &cleanroomv1.ExecutionOptions{MemoryMib: 512},
+	})
+	if err == nil || !strings.Contains(err.Error(), "boots a VM per execution") {
+		t.Fatalf("expected persistent sandbox error, got %v", err)
+	}
+}
+
+func TestCreateSandboxRejectsUnattachableVFIODevices(t *testing.T) {
+	t.Parallel()
+
+	svc := newTestService(&stubAdapter{})
+	pol := testPolicy()
+	pol.VfioDevices = []string{"gpu"}
+
+	_, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: pol})
+	if err == nil || !strings.Contains(err.Error(), `vfio device "gpu", which is not configured`) {
+		t.Fatalf("expected unconfigured device error, got %v", err)
+	}
+
+	svc.Config.Devices.VFIO = []runtimeconfig.VFIODevice{{Name: "gpu", PCIAddress: "0000:65:00.0"}}
+	_, err = svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: pol})
+	if err == nil || !strings.Contains(err.Error(), `backend "firecracker" cannot attach`) {
+		t.Fatalf("expected unsupported backend error, got %v", err)
+	}
+}
+
+func TestCreateSandboxReportsNestedVirtualization(t *testing.T) {
+	t.Parallel()
+
+	pol := testPolicy()
+	pol.NestedVirtualization = true
+
+	svc := newTestService(&stubAdapter{})
+	_, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: pol})
+	if err == nil || !strings.Contains(err.Error(), "does not support") {
+		t.Fatalf("expected unsupported nested virtualization error, got %v", err)
+	}
+
+	svc = newTestService(&nestedVirtAdapter{})
+	resp, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: pol})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	if !strings.Contains(resp.GetMessage(), "reduces isolation") {
+		t.Fatalf("expected isolation warning in message, got %q", resp.GetMessage())
+	}
+}
+
+type nestedVirtAdapter struct {
+	stubAdapter
+}
+
+func (*nestedVirtAdapter) Capabilities() map[string]bool {
+	return map[string]bool{backend.CapabilityNestedVirtualization: true}
+}
+
+func TestCreateSandboxRejectsReadOnlyRootFSOnUnsupportedBackend(t *testing.T) {
+	t.Parallel()
+
+	pol := testPolicy()
+	pol.ReadOnlyRootfs = &cleanroomv1.PolicyReadOnlyRootFS{}
+
+	svc := newTestService(&stubAdapter{})
+	_, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: pol})
+	if err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Fatalf("expected unsupported read-only rootfs error, got %v", err)
+	}
+}
+
+func TestCreateSandboxRejectsSetupOnUnsupportedBackend(t *testing.T) {
+	t.Parallel()
+
+	pol := testPolicy()
+	pol.Setup = []string{"apt-get install -y jq"}
+
+	svc := newTestService(&stubAdapter{})
+	_, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: pol})
+	if err == nil || !strings.Contains(err.Error(), "sandbox.setup") {
+		t.Fatalf("expected unsupported setup error, got %v", err)
+	}
+}
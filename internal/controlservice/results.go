@@ -0,0 +1,277 @@
+package controlservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+const (
+	maxJUnitPaths        = 16
+	maxJUnitReportBytes  = 8 * 1024 * 1024
+	maxFailedTestNames   = 50
+	maxParsedTests       = 100000
+	maxGoTestJSONLine    = 64 * 1024
+	junitDownloadTimeout = 30 * time.Second
+)
+
+const goTestJSONSource = "go-test-json"
+
+// resultParsers are the result parsers an execution opted into.
+type resultParsers struct {
+	GoTestJSON bool
+	JUnitPaths []string
+}
+
+func (p resultParsers) enabled() bool {
+	return p.GoTestJSON || len(p.JUnitPaths) > 0
+}
+
+func resolveResultParsers(in *cleanroomv1.ExecutionResultParsers) (resultParsers, error) {
+	out := resultParsers{GoTestJSON: in.GetGoTestJson()}
+	if len(in.GetJunitPaths()) > maxJUnitPaths {
+		return resultParsers{}, fmt.Errorf("invalid result_parsers.junit_paths: at most %d allowed, got %d", maxJUnitPaths, len(in.GetJunitPaths()))
+	}
+	for _, p := range in.GetJunitPaths() {
+		p = strings.TrimSpace(p)
+		if !strings.HasPrefix(p, "/") {
+			return resultParsers{}, fmt.Errorf("invalid result_parsers.junit_paths %q: must be absolute", p)
+		}
+		out.JUnitPaths = append(out.JUnitPaths, path.Clean(p))
+	}
+	return out, nil
+}
+
+// checkResultParsers rejects JUnit reports on backends that cannot copy
+// files out of a sandbox.
+func checkResultParsers(parsers resultParsers, backendName string, adapter backend.Adapter) error {
+	if len(parsers.JUnitPaths) == 0 {
+		return nil
+	}
+	if _, ok := adapter.(backend.SandboxFileDownloadAdapter); !ok {
+		return fmt.Errorf("backend %q does not support sandbox file downloads, which JUnit result parsing needs", backendName)
+	}
+	return nil
+}
+
+// testTally counts test outcomes keyed by test name, so a test reported
+// more than once counts once, with its last outcome.
+type testTally struct {
+	outcomes map[string]string // test -> pass, fail or skip
+}
+
+func (t *testTally) record(test, outcome string) {
+	if t.outcomes == nil {
+		t.outcomes = map[string]string{}
+	}
+	if _, ok := t.outcomes[test]; !ok && len(t.outcomes) >= maxParsedTests {
+		return
+	}
+	t.outcomes[test] = outcome
+}
+
+// goTestJSONParser reads `go test -json` events from an execution's stdout
+// as it arrives. Lines that are not test events are ignored.
+type goTestJSONParser struct {
+	partial  []byte
+	overlong bool // the partial line exceeded maxGoTestJSONLine
+	tests    testTally
+	found    bool
+}
+
+func (p *goTestJSONParser) write(chunk []byte) {
+	for len(chunk) > 0 {
+		i := bytes.IndexByte(chunk, '\n')
+		if i < 0 {
+			if len(p.partial)+len(chunk) > maxGoTestJSONLine {
+				// No test event is this long.
+				p.partial, p.overlong = p.partial[:0], true
+			} else if !p.overlong {
+				p.partial = append(p.partial, chunk...)
+			}
+			return
+		}
+		line := chunk[:i]
+		if len(p.partial) > 0 {
+			line = append(p.partial, line...)
+		}
+		if !p.overlong {
+			p.parseLine(line)
+		}
+		p.partial, p.overlong = p.partial[:0], false
+		chunk = chunk[i+1:]
+	}
+}
+
+// flush parses a last line that did not end in a newline.
+func (p *goTestJSONParser) flush() {
+	if len(p.partial) > 0 && !p.overlong {
+		p.parseLine(p.partial)
+	}
+	p.partial, p.overlong = nil, false
+}
+
+func (p *goTestJSONParser) parseLine(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return
+	}
+	var event struct {
+		Action  string
+		Package string
+		Test    string
+	}
+	if err := json.Unmarshal(line, &event); err != nil || event.Test == "" {
+		return
+	}
+	switch event.Action {
+	case "pass", "fail", "skip":
+		p.found = true
+		p.tests.record(event.Package+"."+event.Test, event.Action)
+	}
+}
+
+// parseJUnitReport tallies the testcases in a JUnit XML report, however
+// its testsuites nest.
+func parseJUnitReport(raw []byte) (testTally, error) {
+	var tally testTally
+	dec := xml.NewDecoder(bytes.NewReader(raw))
+	var current, outcome string
+	inCase := false
+	sawSuite := false
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return testTally{}, err
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "testsuites", "testsuite":
+				sawSuite = true
+			case "testcase":
+				inCase = true
+				outcome = "pass"
+				var class, name string
+				for _, attr := range el.Attr {
+					switch attr.Name.Local {
+					case "classname":
+						class = attr.Value
+					case "name":
+						name = attr.Value
+					}
+				}
+				current = name
+				if class != "" {
+					current = class + "." + name
+				}
+			case "failure", "error":
+				if inCase {
+					outcome = "fail"
+				}
+			case "skipped":
+				if inCase && outcome != "fail" {
+					outcome = "skip"
+				}
+			}
+		case xml.EndElement:
+			if el.Name.Local == "testcase" && inCase {
+				tally.record(current, outcome)
+				inCase = false
+			}
+		}
+	}
+	if !sawSuite {
+		return testTally{}, errors.New("no testsuite element")
+	}
+	return tally, nil
+}
+
+// junitReport is what reading one JUnit report found.
+type junitReport struct {
+	Path  string
+	Tests testTally
+	Err   error
+}
+
+// readJUnitReports copies the JUnit reports at paths out of a sandbox
+// whose execution has finished and parses them.
+func readJUnitReports(adapter backend.Adapter, sandboxID string, paths []string) []junitReport {
+	downloader, ok := adapter.(backend.SandboxFileDownloadAdapter)
+	if !ok || len(paths) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), junitDownloadTimeout)
+	defer cancel()
+	reports := make([]junitReport, 0, len(paths))
+	for _, p := range paths {
+		report := junitReport{Path: p}
+		raw, err := downloader.DownloadSandboxFile(ctx, sandboxID, p, maxJUnitReportBytes)
+		if err == nil {
+			report.Tests, err = parseJUnitReport(raw)
+		}
+		report.Err = err
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// executionTestResults summarizes what an execution's result parsers
+// found. It returns nil when the execution asked for none.
+func executionTestResults(parsers resultParsers, goTest *goTestJSONParser, junit []junitReport) *cleanroomv1.ExecutionTestResults {
+	if !parsers.enabled() {
+		return nil
+	}
+	out := &cleanroomv1.ExecutionTestResults{}
+	var failed []string
+	add := func(source string, tally testTally) {
+		out.Sources = append(out.Sources, source)
+		for test, outcome := range tally.outcomes {
+			out.Total++
+			switch outcome {
+			case "pass":
+				out.Passed++
+			case "fail":
+				out.Failed++
+				failed = append(failed, test)
+			case "skip":
+				out.Skipped++
+			}
+		}
+	}
+	if goTest != nil {
+		goTest.flush()
+		if goTest.found {
+			add(goTestJSONSource, goTest.tests)
+		} else {
+			out.Errors = append(out.Errors, goTestJSONSource+": no test events on stdout")
+		}
+	}
+	for _, report := range junit {
+		if report.Err != nil {
+			out.Errors = append(out.Errors, fmt.Sprintf("junit:%s: %v", report.Path, report.Err))
+			continue
+		}
+		add("junit:"+report.Path, report.Tests)
+	}
+	sort.Strings(failed)
+	if len(failed) > maxFailedTestNames {
+		failed = failed[:maxFailedTestNames]
+	}
+	out.FailedTests = failed
+	return out
+}
@@ -0,0 +1,125 @@
+package controlservice
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+const goTestJSONOutput = `{"Action":"start","Package":"example.com/m"}
+{"Action":"run","Package":"example.com/m","Test":"TestA"}
+{"Action":"pass","Package":"example.com/m","Test":"TestA","Elapsed":0.01}
+building...
+{"Action":"fail","Package":"example.com/m","Test":"TestB","Elapsed":0.02}
+{"Action":"skip","Package":"example.com/m","Test":"TestC","Elapsed":0}
+{"Action":"fail","Package":"example.com/m","Elapsed":0.05}
+`
+
+const junitReportXML = `<?xml version="1.0"?>
+<testsuites>
+  <testsuite name="web">
+    <testcase classname="web.Login" name="accepts valid password"/>
+    <testcase classname="web.Login" name="rejects bad password"><failure message="expected 401"/></testcase>
+    <testsuite name="nested">
+      <testcase classname="web.Cart" name="empty"><skipped/></testcase>
+    </testsuite>
+  </testsuite>
+</testsuites>
+`
+
+func TestGoTestJSONParserHandlesSplitLines(t *testing.T) {
+	t.Parallel()
+
+	var p goTestJSONParser
+	// Feed the output in small chunks so events straddle them.
+	for rest := goTestJSONOutput; rest != ""; {
+		n := min(7, len(rest))
+		p.write([]byte(rest[:n]))
+		rest = rest[n:]
+	}
+	got := executionTestResults(resultParsers{GoTestJSON: true}, &p, nil)
+	if got.GetTotal() != 3 || got.GetPassed() != 1 || got.GetFailed() != 1 || got.GetSkipped() != 1 {
+		t.Fatalf("unexpected counts: %v", got)
+	}
+	if !reflect.DeepEqual(got.GetFailedTests(), []string{"example.com/m.TestB"}) {
+		t.Fatalf("unexpected failed tests: %v", got.GetFailedTests())
+	}
+}
+
+func TestParseJUnitReport(t *testing.T) {
+	t.Parallel()
+
+	tally, err := parseJUnitReport([]byte(junitReportXML))
+	if err != nil {
+		t.Fatalf("parseJUnitReport returned error: %v", err)
+	}
+	want := map[string]string{
+		"web.Login.accepts valid password": "pass",
+		"web.Login.rejects bad password":   "fail",
+		"web.Cart.empty":                   "skip",
+	}
+	if !reflect.DeepEqual(tally.outcomes, want) {
+		t.Fatalf("unexpected outcomes: %v", tally.outcomes)
+	}
+	if _, err := parseJUnitReport([]byte("<html></html>")); err == nil {
+		t.Fatal("expected a document without a testsuite to be rejected")
+	}
+}
+
+func TestExecutionRecordsTestResults(t *testing.T) {
+	t.Parallel()
+
+	adapter := &stubAdapter{
+		runFn: func(_ context.Context, req backend.RunRequest) (*backend.RunResult, error) {
+			return &backend.RunResult{RunID: req.RunID, ExitCode: 1, Stdout: goTestJSONOutput}, nil
+		},
+		downloadFn: func(_ context.Context, _ string, path string, _ int64) ([]byte, error) {
+			if path == "/workspace/junit.xml" {
+				return []byte(junitReportXML), nil
+			}
+			return nil, context.DeadlineExceeded
+		},
+	}
+	svc := newTestService(adapter)
+	ctx := context.Background()
+	createResp, err := svc.CreateSandbox(ctx, &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sandboxID := createResp.GetSandbox().GetSandboxId()
+
+	if _, err := svc.CreateExecution(ctx, &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   []string{"go", "test", "-json", "./..."},
+		Options:   &cleanroomv1.ExecutionOptions{ResultParsers: &cleanroomv1.ExecutionResultParsers{JunitPaths: []string{"junit.xml"}}},
+	}); err == nil || !strings.Contains(err.Error(), "must be absolute") {
+		t.Fatalf("expected a relative report path to be rejected, got %v", err)
+	}
+
+	resp, err := svc.CreateExecution(ctx, &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   []string{"go", "test", "-json", "./..."},
+		Options: &cleanroomv1.ExecutionOptions{ResultParsers: &cleanroomv1.ExecutionResultParsers{
+			GoTestJson: true,
+			JunitPaths: []string{"/workspace/junit.xml", "/workspace/missing.xml"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("CreateExecution returned error: %v", err)
+	}
+	ex := waitForExecutionStatus(t, svc, sandboxID, resp.GetExecution().GetExecutionId())
+	got := ex.GetTestResults()
+	if got.GetTotal() != 6 || got.GetPassed() != 2 || got.GetFailed() != 2 || got.GetSkipped() != 2 {
+		t.Fatalf("unexpected counts: %v", got)
+	}
+	if !reflect.DeepEqual(got.GetSources(), []string{"go-test-json", "junit:/workspace/junit.xml"}) {
+		t.Fatalf("unexpected sources: %v", got.GetSources())
+	}
+	if len(got.GetErrors()) != 1 || !strings.Contains(got.GetErrors()[0], "/workspace/missing.xml") {
+		t.Fatalf("expected the missing report to be reported, got %v", got.GetErrors())
+	}
+}
@@ -0,0 +1,164 @@
+package controlservice
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/paths"
+	"github.com/buildkite/cleanroom/internal/rundir"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+)
+
+const (
+	defaultRunMaxAge        = 7 * 24 * time.Hour
+	defaultRunSweepInterval = 10 * time.Minute
+	runArchiveTimeout       = 5 * time.Minute
+)
+
+// runRetentionPolicy applies the runs defaults to cfg.
+func runRetentionPolicy(cfg runtimeconfig.Runs) (rundir.Policy, time.Duration) {
+	policy := rundir.Policy{
+		MaxTotalBytes: cfg.MaxTotalMiB * 1024 * 1024,
+		MaxAge:        time.Duration(cfg.MaxAgeHours) * time.Hour,
+	}
+	if policy.MaxAge <= 0 {
+		policy.MaxAge = defaultRunMaxAge
+	}
+	interval := time.Duration(cfg.SweepIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = defaultRunSweepInterval
+	}
+	return policy, interval
+}
+
+// SweepRunDirs enforces the runs retention policy on the run directories
+// under baseDir until ctx is done, sweeping once straight away. The policy
+// is re-read before every sweep, so config reloads apply to the next one.
+// Each sweep also removes sockets left behind by VM processes that died,
+// in the run directories and the configured socket_dir.
+func (s *Service) SweepRunDirs(ctx context.Context, baseDir string) {
+	for {
+		cfg := s.runtimeConfig()
+		policy, interval := runRetentionPolicy(cfg.Runs)
+		now := time.Now()
+		s.sweepRunDirs(baseDir, policy, now)
+		s.scavengeSockets(baseDir, now)
+		if cfg.SocketDir != "" {
+			s.scavengeSockets(cfg.SocketDir, now)
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *Service) sweepRunDirs(baseDir string, policy rundir.Policy, now time.Time) rundir.SweepResult {
+	result, err := rundir.Sweep(baseDir, policy, s.runIsActive, now)
+	if s.Logger == nil {
+		return result
+	}
+	if err != nil {
+		s.Logger.Warn("run directory sweep failed", "dir", baseDir, "error", err)
+		return result
+	}
+	if len(result.Removed) > 0 {
+		s.Logger.Info("removed expired run directories",
+			"dir", baseDir,
+			"removed", len(result.Removed),
+			"freed_bytes", result.FreedBytes,
+			"remaining_bytes", result.TotalBytes,
+		)
+	}
+	return result
+}
+
+func (s *Service) scavengeSockets(baseDir string, now time.Time) {
+	result, err := rundir.ScavengeSockets(baseDir, 0, now)
+	s.staleSockets.Add(int64(len(result.Removed)))
+	if s.Logger == nil {
+		return
+	}
+	if err != nil {
+		s.Logger.Warn("stale socket scavenge failed", "dir", baseDir, "error", err)
+		return
+	}
+	if len(result.Removed) > 0 {
+		s.Logger.Info("removed stale sockets", "dir", baseDir, "removed", len(result.Removed), "scanned", result.Scanned)
+	}
+}
+
+// StaleSocketsRemoved returns how many sockets without a listening process
+// run directory sweeps have removed since serve started.
+func (s *Service) StaleSocketsRemoved() int64 {
+	return s.staleSockets.Load()
+}
+
+// archiveRun copies the records in runID's directory to RunStore. It runs
+// in the background once the backend returns, failed launches included, so
+// diagnostics are kept too.
+func (s *Service) archiveRun(ctx context.Context, runID, dir string) {
+	if strings.TrimSpace(dir) == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), runArchiveTimeout)
+	defer cancel()
+	if err := rundir.Archive(ctx, s.RunStore, dir, runID); err != nil {
+		if logger := s.logger(ctx); logger != nil {
+			logger.Warn("archive run directory failed", "dir", dir, "error", err)
+		}
+	}
+}
+
+// runIDInUseLocked reports whether an execution the service still holds
+// has runID, finished or not.
+func (s *Service) runIDInUseLocked(runID string) bool {
+	for _, ex := range s.executions {
+		if ex.RunID == runID {
+			return true
+		}
+	}
+	return false
+}
+
+// runIDRecorded reports whether runID was used before serve last started:
+// a run directory with that name is on the host, or RunStore holds an
+// archived run under it. Runs are archived when their execution ends, so
+// a run still under way on another serve instance is not seen; run IDs
+// are only guaranteed unique across instances once their runs finish.
+func (s *Service) runIDRecorded(ctx context.Context, runID string) (bool, error) {
+	if baseDir, err := paths.RunBaseDir(); err == nil {
+		if _, err := os.Lstat(filepath.Join(baseDir, runID)); err == nil {
+			return true, nil
+		}
+	}
+	if s.RunStore == nil {
+		return false, nil
+	}
+	keys, err := s.RunStore.List(ctx, runID+"/")
+	if err != nil {
+		return false, fmt.Errorf("check archived runs for run ID %q: %w", runID, err)
+	}
+	return len(keys) > 0, nil
+}
+
+// runIsActive reports whether an execution that has not finished owns
+// runID's directory.
+func (s *Service) runIsActive(runID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, ex := range s.executions {
+		if ex.RunID == runID && !isFinalExecutionStatus(ex.Status) {
+			return true
+		}
+	}
+	return false
+}
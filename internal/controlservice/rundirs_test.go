@@ -0,0 +1,138 @@
+package controlservice
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/objectstore"
+	"github.com/buildkite/cleanroom/internal/rundir"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+)
+
+func TestSweepRunDirsKeepsRunningExecutions(t *testing.T) {
+	t.Parallel()
+
+	base := t.TempDir()
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	for _, runID := range []string{"run-running", "run-finished"} {
+		dir := filepath.Join(base, runID)
+		if err := rundir.Create(dir, rundir.Manifest{RunID: runID}); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filepath.Join(dir, rundir.ManifestFile), old, old); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(dir, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	svc := &Service{executions: map[string]*executionState{
+		executionKey("cr-1", "exec-1"): {RunID: "run-running", Status: cleanroomv1.ExecutionStatus_EXECUTION_STATUS_RUNNING},
+		executionKey("cr-1", "exec-2"): {RunID: "run-finished", Status: cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SUCCEEDED},
+	}}
+	policy, _ := runRetentionPolicy(runtimeconfig.Runs{})
+	result := svc.sweepRunDirs(base, policy, time.Now())
+	if want := []string{"run-finished"}; !reflect.DeepEqual(result.Removed, want) {
+		t.Fatalf("unexpected removed runs: got %v want %v", result.Removed, want)
+	}
+	if _, err := os.Stat(filepath.Join(base, "run-running")); err != nil {
+		t.Fatalf("expected running execution's run directory to be kept: %v", err)
+	}
+}
+
+func TestRunRetentionPolicyDefaults(t *testing.T) {
+	t.Parallel()
+
+	policy, interval := runRetentionPolicy(runtimeconfig.Runs{})
+	if policy.MaxAge != defaultRunMaxAge || policy.MaxTotalBytes != 0 || interval != defaultRunSweepInterval {
+		t.Fatalf("unexpected defaults: %+v every %s", policy, interval)
+	}
+
+	policy, interval = runRetentionPolicy(runtimeconfig.Runs{MaxTotalMiB: 2, MaxAgeHours: 3, SweepIntervalSeconds: 60})
+	if policy.MaxAge != 3*time.Hour || policy.MaxTotalBytes != 2*1024*1024 || interval != time.Minute {
+		t.Fatalf("unexpected policy: %+v every %s", policy, interval)
+	}
+}
+
+func TestFinishedRunsAreArchived(t *testing.T) {
+	t.Parallel()
+
+	runBase := t.TempDir()
+	adapter := &stubAdapter{
+		runFn: func(_ context.Context, req backend.RunRequest) (*backend.RunResult, error) {
+			dir := filepath.Join(runBase, req.RunID)
+			if err := rundir.Create(dir, rundir.Manifest{RunID: req.RunID}); err != nil {
+				return nil, err
+			}
+			return &backend.RunResult{RunID: req.RunID, RunDir: dir}, nil
+		},
+	}
+	store := &objectstore.Dir{Root: t.TempDir()}
+	svc := newTestService(adapter)
+	svc.RunStore = store
+
+	sandbox, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sandboxID := sandbox.GetSandbox().GetSandboxId()
+	execution, err := svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   []string{"true"},
+	})
+	if err != nil {
+		t.Fatalf("CreateExecution returned error: %v", err)
+	}
+	runID := waitForExecutionStatus(t, svc, sandboxID, execution.GetExecution().GetExecutionId()).GetRunId()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		runs, err := rundir.ArchivedRuns(context.Background(), store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reflect.DeepEqual(runs, []string{runID}) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected run %s to be archived, got %v", runID, runs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestArchivedRunIDsAreInUse(t *testing.T) {
+	t.Parallel()
+
+	const runID = "run_01h2xcejqtf2nbrexx3vqjhp41"
+	store := &objectstore.Dir{Root: t.TempDir()}
+	if err := os.MkdirAll(filepath.Join(store.Root, runID), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(store.Root, runID, rundir.ManifestFile), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	svc := newTestService(&stubAdapter{})
+	svc.RunStore = store
+
+	sandbox, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	_, err = svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandbox.GetSandbox().GetSandboxId(),
+		Command:   []string{"true"},
+		RunId:     runID,
+	})
+	if !errors.Is(err, ErrRunIDInUse) {
+		t.Fatalf("expected an archived run ID to conflict, got %v", err)
+	}
+}
@@ -0,0 +1,424 @@
+package controlservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/policy"
+	"github.com/buildkite/cleanroom/internal/schedule"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ScheduleStore keeps schedules and the outcome of their runs.
+type ScheduleStore interface {
+	Create(ctx context.Context, sch schedule.Schedule) error
+	Get(ctx context.Context, id string) (schedule.Schedule, bool, error)
+	List(ctx context.Context) ([]schedule.Schedule, error)
+	Delete(ctx context.Context, id string) (bool, error)
+	AddRun(ctx context.Context, r schedule.Run) error
+	Runs(ctx context.Context, scheduleID string, limit int) ([]schedule.Run, error)
+}
+
+// ErrSchedulesDisabled is returned by the schedule calls when the server
+// keeps no schedules.
+var ErrSchedulesDisabled = errors.New("schedules are not enabled on this server")
+
+const (
+	// defaultScheduleTimeout bounds a scheduled command that did not set
+	// its own timeout.
+	defaultScheduleTimeout = time.Hour
+	// scheduleCancelGrace is how long a timed-out command has to exit
+	// after it is canceled.
+	scheduleCancelGrace     = 30 * time.Second
+	defaultScheduleRunLimit = 20
+	// scheduleLabel names the schedule a scheduled sandbox and execution
+	// were created for.
+	scheduleLabel = "schedule"
+)
+
+func newScheduleID() string {
+	return newID("sched")
+}
+
+// CreateSchedule saves a command to run in a fresh sandbox whenever a
+// cron expression matches. Runs act as the caller.
+func (s *Service) CreateSchedule(ctx context.Context, req *cleanroomv1.CreateScheduleRequest) (*cleanroomv1.CreateScheduleResponse, error) {
+	if req == nil {
+		return nil, errors.New("missing request")
+	}
+	if s.Schedules == nil {
+		return nil, ErrSchedulesDisabled
+	}
+	cron, err := schedule.ParseCron(req.GetCron())
+	if err != nil {
+		return nil, err
+	}
+	if cron.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("invalid cron expression %q: it never matches", req.GetCron())
+	}
+	if len(req.GetCommand()) == 0 {
+		return nil, errors.New("missing command")
+	}
+	if req.GetPolicy() == nil {
+		return nil, errors.New("missing policy")
+	}
+	if _, err := policy.FromProto(req.GetPolicy()); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+	labels, err := sandboxLabelsFromProto(req.GetLabels())
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := labels[scheduleLabel]; ok {
+		return nil, fmt.Errorf("invalid label %q: it is set to the schedule's ID", scheduleLabel)
+	}
+	if req.GetTimeoutSeconds() < 0 {
+		return nil, fmt.Errorf("invalid timeout_seconds %d: must not be negative", req.GetTimeoutSeconds())
+	}
+	cfg := s.runtimeConfig()
+	namespace, err := requestNamespace(ctx, cfg.Namespaces, req.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	backendName := resolveBackendName(strings.TrimSpace(req.GetBackend()), cfg.DefaultBackend)
+	if _, ok := s.Backends[backendName]; !ok {
+		return nil, fmt.Errorf("unknown backend %q", backendName)
+	}
+	policyBytes, err := proto.Marshal(req.GetPolicy())
+	if err != nil {
+		return nil, err
+	}
+
+	sch := schedule.Schedule{
+		ID:             newScheduleID(),
+		Cron:           cron.String(),
+		Namespace:      namespace,
+		Identity:       CallerIdentity(ctx),
+		Backend:        strings.TrimSpace(req.GetBackend()),
+		Policy:         policyBytes,
+		Command:        append([]string(nil), req.GetCommand()...),
+		Labels:         labels,
+		TimeoutSeconds: req.GetTimeoutSeconds(),
+		CreatedAt:      time.Now().UTC(),
+	}
+	if err := s.Schedules.Create(ctx, sch); err != nil {
+		return nil, err
+	}
+	if logger := s.logger(ctx); logger != nil {
+		logger.Info("schedule created", "schedule_id", sch.ID, "cron", sch.Cron, "namespace", namespace)
+	}
+	return &cleanroomv1.CreateScheduleResponse{Schedule: scheduleToProto(sch, nil, time.Now())}, nil
+}
+
+// ListSchedules returns the schedules in the caller's namespace, or the
+// namespaces an admin asks for, with their last runs.
+func (s *Service) ListSchedules(ctx context.Context, req *cleanroomv1.ListSchedulesRequest) (*cleanroomv1.ListSchedulesResponse, error) {
+	if s.Schedules == nil {
+		return nil, ErrSchedulesDisabled
+	}
+	inScope, err := listNamespaceFilter(ctx, s.runtimeConfig().Namespaces, req.GetNamespace(), req.GetAllNamespaces())
+	if err != nil {
+		return nil, err
+	}
+	schedules, err := s.Schedules.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	resp := &cleanroomv1.ListSchedulesResponse{}
+	for _, sch := range schedules {
+		if !inScope(sch.Namespace) {
+			continue
+		}
+		runs, err := s.Schedules.Runs(ctx, sch.ID, 1)
+		if err != nil {
+			return nil, err
+		}
+		var last *schedule.Run
+		if len(runs) > 0 {
+			last = &runs[0]
+		}
+		resp.Schedules = append(resp.Schedules, scheduleToProto(sch, last, now))
+	}
+	return resp, nil
+}
+
+// GetSchedule returns a schedule and its recent runs.
+func (s *Service) GetSchedule(ctx context.Context, req *cleanroomv1.GetScheduleRequest) (*cleanroomv1.GetScheduleResponse, error) {
+	sch, err := s.accessibleSchedule(ctx, req.GetScheduleId())
+	if err != nil {
+		return nil, err
+	}
+	limit := int(req.GetRunLimit())
+	if limit <= 0 {
+		limit = defaultScheduleRunLimit
+	}
+	runs, err := s.Schedules.Runs(ctx, sch.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	var last *schedule.Run
+	if len(runs) > 0 {
+		last = &runs[0]
+	}
+	resp := &cleanroomv1.GetScheduleResponse{Schedule: scheduleToProto(sch, last, time.Now())}
+	for _, r := range runs {
+		resp.Runs = append(resp.Runs, scheduleRunToProto(r))
+	}
+	return resp, nil
+}
+
+// DeleteSchedule removes a schedule and its run history. A run already
+// under way finishes.
+func (s *Service) DeleteSchedule(ctx context.Context, req *cleanroomv1.DeleteScheduleRequest) (*cleanroomv1.DeleteScheduleResponse, error) {
+	sch, err := s.accessibleSchedule(ctx, req.GetScheduleId())
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := s.Schedules.Delete(ctx, sch.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !deleted {
+		return nil, fmt.Errorf("unknown schedule %q", sch.ID)
+	}
+	if logger := s.logger(ctx); logger != nil {
+		logger.Info("schedule deleted", "schedule_id", sch.ID)
+	}
+	return &cleanroomv1.DeleteScheduleResponse{}, nil
+}
+
+// accessibleSchedule returns the schedule with id, failing as if there
+// were none when it is in a namespace the caller cannot access.
+func (s *Service) accessibleSchedule(ctx context.Context, id string) (schedule.Schedule, error) {
+	if s.Schedules == nil {
+		return schedule.Schedule{}, ErrSchedulesDisabled
+	}
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return schedule.Schedule{}, errors.New("missing schedule_id")
+	}
+	sch, ok, err := s.Schedules.Get(ctx, id)
+	if err != nil {
+		return schedule.Schedule{}, err
+	}
+	if !ok || !canAccessNamespace(ctx, s.runtimeConfig().Namespaces, sch.Namespace) {
+		return schedule.Schedule{}, fmt.Errorf("unknown schedule %q", id)
+	}
+	return sch, nil
+}
+
+func scheduleToProto(sch schedule.Schedule, last *schedule.Run, now time.Time) *cleanroomv1.Schedule {
+	out := &cleanroomv1.Schedule{
+		ScheduleId:     sch.ID,
+		Cron:           sch.Cron,
+		Command:        append([]string(nil), sch.Command...),
+		Backend:        sch.Backend,
+		Labels:         maps.Clone(sch.Labels),
+		TimeoutSeconds: sch.TimeoutSeconds,
+		Namespace:      sch.Namespace,
+		Identity:       sch.Identity,
+		CreatedAt:      timestamppb.New(sch.CreatedAt),
+	}
+	if cron, err := schedule.ParseCron(sch.Cron); err == nil {
+		if next := cron.Next(now); !next.IsZero() {
+			out.NextRunAt = timestamppb.New(next)
+		}
+	}
+	var pol cleanroomv1.Policy
+	if err := proto.Unmarshal(sch.Policy, &pol); err == nil {
+		if compiled, err := policy.FromProto(&pol); err == nil {
+			out.PolicyHash = compiled.Hash
+		}
+	}
+	if last != nil {
+		out.LastRun = scheduleRunToProto(*last)
+	}
+	return out
+}
+
+func scheduleRunToProto(r schedule.Run) *cleanroomv1.ScheduleRun {
+	return &cleanroomv1.ScheduleRun{
+		ScheduleId:  r.ScheduleID,
+		SandboxId:   r.SandboxID,
+		ExecutionId: r.ExecutionID,
+		RunId:       r.RunID,
+		StartedAt:   timestamppb.New(r.StartedAt),
+		FinishedAt:  timestamppb.New(r.FinishedAt),
+		Status:      cleanroomv1.ExecutionStatus(cleanroomv1.ExecutionStatus_value[r.Status]),
+		ExitCode:    r.ExitCode,
+		Error:       r.Error,
+	}
+}
+
+// RunSchedules starts the runs of schedules as their cron expressions
+// match, checking at the top of every minute until ctx is done. Times the
+// server was down for are skipped rather than caught up, and a schedule
+// whose previous run is still going skips the next.
+func (s *Service) RunSchedules(ctx context.Context) {
+	last := time.Now()
+	for {
+		timer := time.NewTimer(time.Until(last.Truncate(time.Minute).Add(time.Minute)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		now := time.Now()
+		s.startDueSchedules(ctx, last, now)
+		last = now
+	}
+}
+
+// startDueSchedules starts a run of each schedule that matched a time after
+// from and no later than to.
+func (s *Service) startDueSchedules(ctx context.Context, from, to time.Time) {
+	if s.Schedules == nil {
+		return
+	}
+	schedules, err := s.Schedules.List(ctx)
+	if err != nil {
+		if s.Logger != nil {
+			s.Logger.Warn("list schedules failed", "error", err)
+		}
+		return
+	}
+	for _, sch := range schedules {
+		cron, err := schedule.ParseCron(sch.Cron)
+		if err != nil {
+			continue
+		}
+		if next := cron.Next(from); next.IsZero() || next.After(to) {
+			continue
+		}
+		s.mu.Lock()
+		if s.scheduleRuns == nil {
+			s.scheduleRuns = map[string]struct{}{}
+		}
+		_, running := s.scheduleRuns[sch.ID]
+		s.scheduleRuns[sch.ID] = struct{}{}
+		s.mu.Unlock()
+		if running {
+			if s.Logger != nil {
+				s.Logger.Warn("scheduled run skipped: the previous run is still going", "schedule_id", sch.ID)
+			}
+			continue
+		}
+		go func() {
+			defer func() {
+				s.mu.Lock()
+				delete(s.scheduleRuns, sch.ID)
+				s.mu.Unlock()
+			}()
+			s.runSchedule(ctx, sch)
+		}()
+	}
+}
+
+// runSchedule provisions a sandbox for sch, runs its command, records the
+// outcome and terminates the sandbox.
+func (s *Service) runSchedule(ctx context.Context, sch schedule.Schedule) schedule.Run {
+	run := schedule.Run{ScheduleID: sch.ID, StartedAt: time.Now().UTC()}
+	err := s.runScheduledCommand(ctx, sch, &run)
+	if err != nil {
+		run.Error = err.Error()
+	}
+	run.FinishedAt = time.Now().UTC()
+	if err := s.Schedules.AddRun(context.WithoutCancel(ctx), run); err != nil && s.Logger != nil {
+		s.Logger.Warn("record scheduled run failed", "schedule_id", sch.ID, "error", err)
+	}
+	if s.Logger != nil {
+		s.Logger.Info("scheduled run finished",
+			"schedule_id", sch.ID,
+			"sandbox_id", run.SandboxID,
+			"execution_id", run.ExecutionID,
+			"status", run.Status,
+			"exit_code", run.ExitCode,
+			"error", run.Error,
+		)
+	}
+	return run
+}
+
+func (s *Service) runScheduledCommand(ctx context.Context, sch schedule.Schedule, run *schedule.Run) error {
+	var pol cleanroomv1.Policy
+	if err := proto.Unmarshal(sch.Policy, &pol); err != nil {
+		return fmt.Errorf("read schedule policy: %w", err)
+	}
+	callerCtx := WithCallerIdentity(context.WithoutCancel(ctx), sch.Identity)
+	labels := maps.Clone(sch.Labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[scheduleLabel] = sch.ID
+	created, err := s.CreateSandbox(callerCtx, &cleanroomv1.CreateSandboxRequest{
+		Backend:   sch.Backend,
+		Policy:    &pol,
+		Labels:    labels,
+		Namespace: sch.Namespace,
+	})
+	if err != nil {
+		return fmt.Errorf("create sandbox: %w", err)
+	}
+	sandboxID := created.GetSandbox().GetSandboxId()
+	run.SandboxID = sandboxID
+	defer func() {
+		if _, err := s.TerminateSandbox(callerCtx, &cleanroomv1.TerminateSandboxRequest{SandboxId: sandboxID}); err != nil && s.Logger != nil {
+			s.Logger.Warn("terminate scheduled sandbox failed", "schedule_id", sch.ID, "sandbox_id", sandboxID, "error", err)
+		}
+	}()
+
+	started, err := s.CreateExecution(callerCtx, &cleanroomv1.CreateExecutionRequest{
+		SandboxId:   sandboxID,
+		Command:     sch.Command,
+		Kind:        cleanroomv1.ExecutionKind_EXECUTION_KIND_BATCH,
+		Annotations: map[string]string{scheduleLabel: sch.ID},
+	})
+	if err != nil {
+		return fmt.Errorf("create execution: %w", err)
+	}
+	executionID := started.GetExecution().GetExecutionId()
+	run.ExecutionID = executionID
+	done, err := s.executionDoneChannel(sandboxID, executionID)
+	if err != nil {
+		return err
+	}
+
+	timeout := time.Duration(sch.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultScheduleTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var stopped error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		stopped = errors.New("canceled: the server is shutting down")
+	case <-timer.C:
+		stopped = fmt.Errorf("timed out after %s", timeout)
+	}
+	if stopped != nil {
+		_, _ = s.CancelExecution(callerCtx, &cleanroomv1.CancelExecutionRequest{SandboxId: sandboxID, ExecutionId: executionID})
+		select {
+		case <-done:
+		case <-time.After(scheduleCancelGrace):
+		}
+	}
+
+	got, err := s.GetExecution(callerCtx, &cleanroomv1.GetExecutionRequest{SandboxId: sandboxID, ExecutionId: executionID})
+	if err != nil {
+		return err
+	}
+	run.RunID = got.GetExecution().GetRunId()
+	run.Status = got.GetExecution().GetStatus().String()
+	run.ExitCode = got.GetExecution().GetExitCode()
+	return stopped
+}
@@ -0,0 +1,193 @@
+package controlservice
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+	"github.com/buildkite/cleanroom/internal/schedule"
+)
+
+func newScheduleTestService(t *testing.T, adapter backend.Adapter) *Service {
+	t.Helper()
+	store, err := schedule.Open(context.Background(), filepath.Join(t.TempDir(), "schedules.db"))
+	if err != nil {
+		t.Fatalf("open schedule store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	svc := newTestService(adapter)
+	svc.Schedules = store
+	svc.Config.Namespaces = runtimeconfig.Namespaces{Identities: map[string]string{"uid:1001": "team-a"}}
+	return svc
+}
+
+// runDueSchedule starts the runs of schedules due at sch's next time and
+// waits for sch's to be recorded.
+func runDueSchedule(t *testing.T, svc *Service, sch *cleanroomv1.Schedule) schedule.Run {
+	t.Helper()
+	next := sch.GetNextRunAt().AsTime()
+	svc.startDueSchedules(context.Background(), next.Add(-time.Minute), next)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		runs, err := svc.Schedules.Runs(context.Background(), sch.GetScheduleId(), 1)
+		if err != nil {
+			t.Fatalf("Runs returned error: %v", err)
+		}
+		if len(runs) == 1 {
+			return runs[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("schedule %s did not record a run", sch.GetScheduleId())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSchedulesAreScopedToNamespaces(t *testing.T) {
+	svc := newScheduleTestService(t, &stubAdapter{})
+	teamA := WithCallerIdentity(context.Background(), "uid:1001")
+
+	if _, err := svc.CreateSchedule(teamA, &cleanroomv1.CreateScheduleRequest{Cron: "0 3 * *", Policy: testPolicy(), Command: []string{"scan"}}); err == nil || !strings.Contains(err.Error(), "invalid cron expression") {
+		t.Fatalf("expected an invalid cron expression error, got %v", err)
+	}
+	if _, err := svc.CreateSchedule(teamA, &cleanroomv1.CreateScheduleRequest{Cron: "0 3 * * *", Policy: testPolicy()}); err == nil || !strings.Contains(err.Error(), "missing command") {
+		t.Fatalf("expected a missing command error, got %v", err)
+	}
+
+	created, err := svc.CreateSchedule(teamA, &cleanroomv1.CreateScheduleRequest{
+		Cron:    "0 3 * * *",
+		Policy:  testPolicy(),
+		Command: []string{"scan", "--all"},
+		Labels:  map[string]string{"team": "security"},
+	})
+	if err != nil {
+		t.Fatalf("CreateSchedule returned error: %v", err)
+	}
+	sch := created.GetSchedule()
+	if sch.GetNamespace() != "team-a" || sch.GetIdentity() != "uid:1001" || sch.GetPolicyHash() == "" {
+		t.Fatalf("unexpected schedule: %+v", sch)
+	}
+	if next := sch.GetNextRunAt().AsTime(); next.Hour() != 3 || next.Minute() != 0 || !next.After(time.Now()) {
+		t.Fatalf("next run at %s, want the next 03:00 UTC", next)
+	}
+
+	listed, err := svc.ListSchedules(teamA, &cleanroomv1.ListSchedulesRequest{})
+	if err != nil || len(listed.GetSchedules()) != 1 {
+		t.Fatalf("ListSchedules in team-a = %v, %v", listed, err)
+	}
+	listed, err = svc.ListSchedules(context.Background(), &cleanroomv1.ListSchedulesRequest{})
+	if err != nil || len(listed.GetSchedules()) != 0 {
+		t.Fatalf("ListSchedules in the default namespace = %v, %v", listed, err)
+	}
+	if _, err := svc.GetSchedule(context.Background(), &cleanroomv1.GetScheduleRequest{ScheduleId: sch.GetScheduleId()}); err == nil || !strings.Contains(err.Error(), "unknown schedule") {
+		t.Fatalf("expected another namespace's schedule to be unknown, got %v", err)
+	}
+	if _, err := svc.DeleteSchedule(context.Background(), &cleanroomv1.DeleteScheduleRequest{ScheduleId: sch.GetScheduleId()}); err == nil {
+		t.Fatal("expected deleting another namespace's schedule to fail")
+	}
+
+	if _, err := svc.DeleteSchedule(teamA, &cleanroomv1.DeleteScheduleRequest{ScheduleId: sch.GetScheduleId()}); err != nil {
+		t.Fatalf("DeleteSchedule returned error: %v", err)
+	}
+	if _, err := svc.GetSchedule(teamA, &cleanroomv1.GetScheduleRequest{ScheduleId: sch.GetScheduleId()}); err == nil {
+		t.Fatal("expected a deleted schedule to be unknown")
+	}
+
+	svc.Schedules = nil
+	if _, err := svc.ListSchedules(teamA, &cleanroomv1.ListSchedulesRequest{}); !errors.Is(err, ErrSchedulesDisabled) {
+		t.Fatalf("expected ErrSchedulesDisabled without a store, got %v", err)
+	}
+}
+
+func TestScheduledRunRecordsOutcomeAndTerminatesSandbox(t *testing.T) {
+	adapter := &stubAdapter{
+		runStreamFn: func(_ context.Context, req backend.RunRequest, _ backend.OutputStream) (*backend.RunResult, error) {
+			return &backend.RunResult{RunID: req.RunID, ExitCode: 3, LaunchedVM: true}, nil
+		},
+	}
+	svc := newScheduleTestService(t, adapter)
+	teamA := WithCallerIdentity(context.Background(), "uid:1001")
+	created, err := svc.CreateSchedule(teamA, &cleanroomv1.CreateScheduleRequest{
+		Cron:    "*/5 * * * *",
+		Policy:  testPolicy(),
+		Command: []string{"scan"},
+		Labels:  map[string]string{"team": "security"},
+	})
+	if err != nil {
+		t.Fatalf("CreateSchedule returned error: %v", err)
+	}
+
+	run := runDueSchedule(t, svc, created.GetSchedule())
+	if run.Error != "" || run.Status != cleanroomv1.ExecutionStatus_EXECUTION_STATUS_FAILED.String() || run.ExitCode != 3 || run.ExecutionID == "" || run.RunID == "" {
+		t.Fatalf("unexpected run: %+v", run)
+	}
+	sandbox, err := svc.GetSandbox(teamA, &cleanroomv1.GetSandboxRequest{SandboxId: run.SandboxID})
+	if err != nil {
+		t.Fatalf("GetSandbox returned error: %v", err)
+	}
+	sb := sandbox.GetSandbox()
+	if sb.GetStatus() != cleanroomv1.SandboxStatus_SANDBOX_STATUS_STOPPED || sb.GetNamespace() != "team-a" {
+		t.Fatalf("expected a stopped team-a sandbox, got %+v", sb)
+	}
+	if sb.GetLabels()["schedule"] != created.GetSchedule().GetScheduleId() || sb.GetLabels()["team"] != "security" {
+		t.Fatalf("unexpected sandbox labels: %v", sb.GetLabels())
+	}
+
+	got, err := svc.GetSchedule(teamA, &cleanroomv1.GetScheduleRequest{ScheduleId: created.GetSchedule().GetScheduleId()})
+	if err != nil {
+		t.Fatalf("GetSchedule returned error: %v", err)
+	}
+	if len(got.GetRuns()) != 1 || got.GetSchedule().GetLastRun().GetExitCode() != 3 || got.GetRuns()[0].GetStatus() != cleanroomv1.ExecutionStatus_EXECUTION_STATUS_FAILED {
+		t.Fatalf("unexpected schedule runs: %+v", got)
+	}
+}
+
+func TestScheduledRunIsCanceledAfterItsTimeout(t *testing.T) {
+	adapter := &stubAdapter{
+		runStreamFn: func(ctx context.Context, req backend.RunRequest, _ backend.OutputStream) (*backend.RunResult, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	svc := newScheduleTestService(t, adapter)
+	created, err := svc.CreateSchedule(context.Background(), &cleanroomv1.CreateScheduleRequest{
+		Cron:           "@hourly",
+		Policy:         testPolicy(),
+		Command:        []string{"sleep", "infinity"},
+		TimeoutSeconds: 1,
+	})
+	if err != nil {
+		t.Fatalf("CreateSchedule returned error: %v", err)
+	}
+	run := runDueSchedule(t, svc, created.GetSchedule())
+	if run.Error != "timed out after 1s" || run.Status != cleanroomv1.ExecutionStatus_EXECUTION_STATUS_CANCELED.String() {
+		t.Fatalf("unexpected run: %+v", run)
+	}
+}
+
+func TestScheduledRunRecordsSandboxFailures(t *testing.T) {
+	adapter := &stubAdapter{
+		provisionFn: func(context.Context, backend.ProvisionRequest) error {
+			return errors.New("no capacity")
+		},
+	}
+	svc := newScheduleTestService(t, adapter)
+	created, err := svc.CreateSchedule(context.Background(), &cleanroomv1.CreateScheduleRequest{
+		Cron:    "@daily",
+		Policy:  testPolicy(),
+		Command: []string{"scan"},
+	})
+	if err != nil {
+		t.Fatalf("CreateSchedule returned error: %v", err)
+	}
+	run := runDueSchedule(t, svc, created.GetSchedule())
+	if !strings.HasPrefix(run.Error, "create sandbox: ") || !strings.Contains(run.Error, "no capacity") || run.Status != "" || run.ExecutionID != "" {
+		t.Fatalf("unexpected run: %+v", run)
+	}
+}
@@ -0,0 +1,35 @@
+package controlservice
+
+import (
+	"context"
+
+	"github.com/buildkite/cleanroom/internal/apiversion"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/imagemgr"
+)
+
+// ImageCache lists the images cached on the host.
+type ImageCache interface {
+	List(ctx context.Context) ([]imagemgr.Record, error)
+}
+
+// GetServerInfo reports the server's release and control API schema, so a
+// client from another release can tell whether it is compatible, and the
+// images it has cached, so a client choosing between servers can prefer
+// one that boots the sandbox's image without a pull.
+func (s *Service) GetServerInfo(ctx context.Context, _ *cleanroomv1.GetServerInfoRequest) (*cleanroomv1.GetServerInfoResponse, error) {
+	info := apiversion.Info(s.Version)
+	if s.Images == nil {
+		return info, nil
+	}
+	records, err := s.Images.List(ctx)
+	if err != nil {
+		// Cache residency only guides placement; report the version anyway.
+		s.logger(ctx).Warn("list cached images for server info", "error", err)
+		return info, nil
+	}
+	for _, record := range records {
+		info.CachedImageDigests = append(info.CachedImageDigests, record.Digest)
+	}
+	return info, nil
+}
@@ -6,18 +6,23 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"maps"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/buildkite/cleanroom/internal/backend"
 	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/logging"
+	"github.com/buildkite/cleanroom/internal/objectstore"
 	"github.com/buildkite/cleanroom/internal/paths"
 	"github.com/buildkite/cleanroom/internal/policy"
 	"github.com/buildkite/cleanroom/internal/runtimeconfig"
 	"github.com/charmbracelet/log"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -26,45 +31,92 @@ type Service struct {
 	Config   runtimeconfig.Config
 	Backends map[string]backend.Adapter
 	Logger   *log.Logger
+	// Checkout fetches repositories for CreateSandbox checkouts. Nil
+	// disables the feature.
+	Checkout CheckoutFetcher
+	// Events mirrors sandbox and execution events to a message bus. Nil
+	// disables it.
+	Events EventPublisher
+	// Logs ships each execution's events and output to long-term storage.
+	// Nil disables it.
+	Logs LogShipper
+	// Version is the cleanroom release serving requests, reported to
+	// clients for compatibility checks.
+	Version string
+	// RunStore keeps each run directory's records once its execution
+	// ends. Nil keeps them only on the host.
+	RunStore objectstore.Store
+	// Images is the host's image cache, whose digests GetServerInfo
+	// reports. Nil reports none.
+	Images ImageCache
+	// Shared shares sandbox and execution records with other serve
+	// instances, so each can answer reads for all. Nil keeps them local.
+	Shared SharedState
+	// Usage keeps the resources sandboxes and executions reserved, for
+	// GetUsage. Nil disables usage accounting.
+	Usage UsageStore
+	// Schedules keeps the commands RunSchedules runs on cron schedules.
+	// Nil disables schedules.
+	Schedules ScheduleStore
 
 	mu                  sync.RWMutex
 	sandboxes           map[string]*sandboxState
 	executions          map[string]*executionState
 	interactiveSessions map[string]*interactiveSessionState
 	interactiveAttached map[string]struct{}
+	pendingNames        map[string]struct{}
+	budgetAlerts        map[string]struct{} // sent this run, see sendBudgetAlert
+	scheduleRuns        map[string]struct{} // schedules with a run under way
+	provisioning        int
 	interactiveEndpoint string
 	interactiveALPN     string
 	interactiveCertPin  string
+	staleSockets        atomic.Int64 // removed by run directory sweeps
 }
 
 type sandboxState struct {
-	ID                 string
-	Backend            string
-	Policy             *policy.CompiledPolicy
-	Firecracker        backend.FirecrackerConfig
-	ActiveExecutionID  string
-	DownloadInProgress bool
-	CreatedAt          time.Time
-	UpdatedAt          time.Time
-	LastExecutionID    string
-	Status             cleanroomv1.SandboxStatus
-	EventHistory       []*cleanroomv1.SandboxEvent
-	EventSubscribers   map[int]chan *cleanroomv1.SandboxEvent
-	NextSubID          int
-	Done               chan struct{}
-	DoneClosed         bool
+	ID                string
+	Name              string
+	Namespace         string
+	Labels            map[string]string
+	Identity          string // of the caller that created it
+	Backend           string
+	Policy            *policy.CompiledPolicy
+	Firecracker       backend.FirecrackerConfig
+	ActiveExecutionID string
+	// BusyWith names the host-side operation, such as a file download,
+	// that currently holds the sandbox. Empty when idle.
+	BusyWith         string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	LastExecutionID  string
+	Checkout         *cleanroomv1.SandboxCheckout
+	AgentHash        string
+	Resolutions      []backend.HostResolution
+	GroupID          string
+	GroupAddress     string
+	Status           cleanroomv1.SandboxStatus
+	EventHistory     []*cleanroomv1.SandboxEvent
+	EventSubscribers map[int]chan *cleanroomv1.SandboxEvent
+	NextSubID        int
+	Done             chan struct{}
+	DoneClosed       bool
 }
 
 type executionState struct {
 	ID               string
 	SandboxID        string
+	Namespace        string // of the sandbox
 	RunID            string
+	RequestID        string // of the CreateExecution call, for log correlation
+	Identity         string // of the caller that created it
 	ImageRef         string
 	ImageDigest      string
 	Command          []string
 	Options          executionOptions
 	TTY              bool
 	Kind             cleanroomv1.ExecutionKind
+	Environment      *cleanroomv1.ExecutionEnvironment
 	Status           cleanroomv1.ExecutionStatus
 	ExitCode         int32
 	StartedAt        *time.Time
@@ -75,10 +127,21 @@ type executionState struct {
 	LaunchedVM       bool
 	PlanPath         string
 	RunDir           string
+	ExitMetadata     *backend.ExitMetadata
+	Timings          *backend.RunTimings
+	Artifacts        []*cleanroomv1.ExecutionArtifact
+	Annotations      map[string]string
+	GoTestJSON       *goTestJSONParser // set when the execution opted into go test -json parsing
+	TestResults      *cleanroomv1.ExecutionTestResults
+	Retriable        bool
+	FailureReason    cleanroomv1.ExecutionFailureReason
+	Approval         *cleanroomv1.ExecutionApproval
+	ApprovalTimer    *time.Timer
 	CancelRequested  bool
 	CancelSignal     int32
 	Cancel           context.CancelFunc
 	AttachStdin      func([]byte) error
+	AttachCloseStdin func() error
 	AttachResize     func(cols, rows uint32) error
 	EventHistory     []*cleanroomv1.ExecutionStreamEvent
 	EventSubscribers map[int]chan *cleanroomv1.ExecutionStreamEvent
@@ -110,7 +173,16 @@ type loader interface {
 }
 
 type executionOptions struct {
-	LaunchSeconds int64
+	LaunchSeconds           int64
+	Limits                  backend.ResourceLimits
+	KeepBackgroundProcesses bool
+	FreshHome               bool
+	Launcher                string
+	Stdin                   bool
+	VCPUs                   int64
+	MemoryMiB               int64
+	CaptureChanges          string
+	ResultParsers           resultParsers
 }
 
 type executionSnapshot struct {
@@ -135,6 +207,13 @@ var (
 
 	ErrExecutionStdinUnsupported  = errors.New("execution stdin attach is not supported by the current backend")
 	ErrExecutionResizeUnsupported = errors.New("execution resize is not supported by the current backend")
+	ErrExecutionStdinNotOpen      = errors.New("execution was not created with stdin open")
+	ErrExecutionNotRunning        = errors.New("execution is not running")
+	ErrSandboxNameTaken           = errors.New("sandbox name is already in use")
+	ErrNamespaceDenied            = errors.New("namespace not permitted")
+	ErrRunIDInUse                 = errors.New("run ID is already in use")
+	ErrUnknownSandbox             = errors.New("unknown sandbox")
+	ErrUnknownExecution           = errors.New("unknown execution")
 )
 
 const (
@@ -145,6 +224,12 @@ const (
 	defaultDownloadMaxBytes      int64 = 10 * 1024 * 1024
 )
 
+// logger returns s.Logger with the request, sandbox and execution IDs
+// attached to ctx, or nil when the service has no logger.
+func (s *Service) logger(ctx context.Context) *log.Logger {
+	return logging.Logger(ctx, s.Logger)
+}
+
 func (s *Service) CreateSandbox(ctx context.Context, req *cleanroomv1.CreateSandboxRequest) (*cleanroomv1.CreateSandboxResponse, error) {
 	if req == nil {
 		return nil, errors.New("missing request")
@@ -158,38 +243,140 @@ func (s *Service) CreateSandbox(ctx context.Context, req *cleanroomv1.CreateSand
 		return nil, fmt.Errorf("invalid policy: %w", err)
 	}
 
-	backendName := resolveBackendName(strings.TrimSpace(req.GetBackend()), s.Config.DefaultBackend)
+	name := strings.TrimSpace(req.GetName())
+	if err := validateSandboxName(name); err != nil {
+		return nil, err
+	}
+	labels, err := sandboxLabelsFromProto(req.GetLabels())
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := s.runtimeConfig()
+	namespace, err := requestNamespace(ctx, cfg.Namespaces, req.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	budgetWarnings, err := s.checkBudgets(ctx, cfg.Budgets, namespace, CallerIdentity(ctx))
+	if err != nil {
+		return nil, err
+	}
+	backendName := resolveBackendName(strings.TrimSpace(req.GetBackend()), cfg.DefaultBackend)
 	adapter, ok := s.Backends[backendName]
 	if !ok {
 		return nil, fmt.Errorf("unknown backend %q", backendName)
 	}
+	if err := checkPolicyDevices(compiled, backendName, adapter, cfg); err != nil {
+		return nil, err
+	}
+	if err := checkNestedVirtualization(compiled, backendName, adapter); err != nil {
+		return nil, err
+	}
+	if err := checkDockerPreload(compiled, backendName, adapter); err != nil {
+		return nil, err
+	}
+	if err := checkReadOnlyRootFS(compiled, backendName, adapter); err != nil {
+		return nil, err
+	}
+	if err := checkSetup(compiled, backendName, adapter); err != nil {
+		return nil, err
+	}
+	pinned, err := pinnedResolutionsFromProto(req.GetPinnedResolutions(), backendName, adapter)
+	if err != nil {
+		return nil, err
+	}
+
+	if name != "" {
+		s.mu.Lock()
+		s.ensureMapsLocked()
+		if s.sandboxNameInUseLocked(namespace, name) {
+			s.mu.Unlock()
+			return nil, fmt.Errorf("%w: %q", ErrSandboxNameTaken, name)
+		}
+		// Hold the name while the backend provisions so a concurrent create
+		// cannot claim it.
+		s.pendingNames[pendingNameKey(namespace, name)] = struct{}{}
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			delete(s.pendingNames, pendingNameKey(namespace, name))
+			s.mu.Unlock()
+		}()
+	}
 
 	opts := req.GetOptions()
 	execOpts := executionOptions{}
 	if opts != nil {
 		execOpts.LaunchSeconds = opts.GetLaunchSeconds()
 	}
-	firecrackerCfg := mergeBackendConfig(backendName, execOpts, s.Config)
+	firecrackerCfg := mergeBackendConfig(backendName, execOpts, cfg)
 	firecrackerCfg.RunDir = ""
+	createNotes := applyPolicyResources(&firecrackerCfg, backendName, compiled.Resources, cfg)
+	if compiled.NestedVirtualization {
+		createNotes = append(createNotes, nestedVirtualizationWarning)
+	}
+	createNotes = append(createNotes, budgetWarnings...)
+
+	s.mu.Lock()
+	s.provisioning++
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.provisioning--
+		s.mu.Unlock()
+	}()
+
+	co, archive, err := s.prepareCheckout(ctx, req.GetCheckout(), compiled, adapter)
+	if err != nil {
+		return nil, err
+	}
+	if archive != nil {
+		defer archive.Close()
+	}
 
 	now := time.Now().UTC()
 	sandboxID := newSandboxID()
+	ctx = logging.WithFields(ctx, "sandbox_id", sandboxID)
 
+	agentHash := ""
+	var resolutions []backend.HostResolution
 	if persistentAdapter, ok := adapter.(backend.PersistentSandboxAdapter); ok {
 		if err := persistentAdapter.ProvisionSandbox(ctx, backend.ProvisionRequest{
 			SandboxID:         sandboxID,
+			Namespace:         namespace,
 			Policy:            compiled,
+			PinnedResolutions: pinned,
 			FirecrackerConfig: firecrackerCfg,
 		}); err != nil {
 			return nil, fmt.Errorf("provision sandbox: %w", err)
 		}
+		if co != nil {
+			if err := extractCheckout(ctx, persistentAdapter, sandboxID, firecrackerCfg, co, archive.Path); err != nil {
+				_ = persistentAdapter.TerminateSandbox(context.WithoutCancel(ctx), sandboxID)
+				return nil, err
+			}
+			createNotes = append(createNotes, fmt.Sprintf("checked out %s at %s into %s", co.GetRepository(), co.GetCommit(), co.GetPath()))
+		}
+		if upgrader, ok := adapter.(backend.SandboxAgentUpgradeAdapter); ok {
+			agentHash, _ = upgrader.SandboxAgentHash(sandboxID)
+		}
+		if resolver, ok := adapter.(backend.SandboxResolutionAdapter); ok {
+			resolutions, _ = resolver.SandboxResolutions(sandboxID)
+		}
 	}
 
 	state := &sandboxState{
 		ID:               sandboxID,
+		Name:             name,
+		Namespace:        namespace,
+		Labels:           labels,
+		Identity:         CallerIdentity(ctx),
 		Backend:          backendName,
 		Policy:           compiled,
 		Firecracker:      firecrackerCfg,
+		Checkout:         co,
+		AgentHash:        agentHash,
+		Resolutions:      resolutions,
 		CreatedAt:        now,
 		UpdatedAt:        now,
 		Status:           cleanroomv1.SandboxStatus_SANDBOX_STATUS_READY,
@@ -200,17 +387,23 @@ func (s *Service) CreateSandbox(ctx context.Context, req *cleanroomv1.CreateSand
 	s.mu.Lock()
 	s.ensureMapsLocked()
 	s.sandboxes[sandboxID] = state
-	s.recordSandboxEventLocked(state, cleanroomv1.SandboxStatus_SANDBOX_STATUS_READY, "sandbox created and ready")
+	message := "sandbox created and ready"
+	for _, note := range createNotes {
+		message += "; " + note
+	}
+	s.recordSandboxEventLocked(state, cleanroomv1.SandboxStatus_SANDBOX_STATUS_READY, message)
 	s.pruneStateLocked(now)
 	resp := &cleanroomv1.CreateSandboxResponse{
 		Sandbox: cloneSandboxLocked(state),
-		Message: "sandbox created and ready",
+		Message: message,
 	}
 	s.mu.Unlock()
 
-	if s.Logger != nil {
-		s.Logger.Info("sandbox created",
-			"sandbox_id", sandboxID,
+	if logger := s.logger(ctx); logger != nil {
+		for _, note := range createNotes {
+			logger.Warn("sandbox created with warning", "note", note)
+		}
+		logger.Info("sandbox created",
 			"backend", backendName,
 			"policy_hash", compiled.Hash,
 		)
@@ -219,7 +412,7 @@ func (s *Service) CreateSandbox(ctx context.Context, req *cleanroomv1.CreateSand
 	return resp, nil
 }
 
-func (s *Service) GetSandbox(_ context.Context, req *cleanroomv1.GetSandboxRequest) (*cleanroomv1.GetSandboxResponse, error) {
+func (s *Service) GetSandbox(ctx context.Context, req *cleanroomv1.GetSandboxRequest) (*cleanroomv1.GetSandboxResponse, error) {
 	if req == nil || strings.TrimSpace(req.GetSandboxId()) == "" {
 		return nil, errors.New("missing sandbox_id")
 	}
@@ -228,30 +421,41 @@ func (s *Service) GetSandbox(_ context.Context, req *cleanroomv1.GetSandboxReque
 	state, ok := s.sandboxes[strings.TrimSpace(req.GetSandboxId())]
 	if !ok {
 		s.mu.RUnlock()
-		return nil, fmt.Errorf("unknown sandbox %q", req.GetSandboxId())
+		if rec, ok := s.remoteSandbox(ctx, strings.TrimSpace(req.GetSandboxId())); ok {
+			return &cleanroomv1.GetSandboxResponse{Sandbox: rec.Sandbox}, nil
+		}
+		return nil, fmt.Errorf("%w %q", ErrUnknownSandbox, req.GetSandboxId())
 	}
 	resp := &cleanroomv1.GetSandboxResponse{Sandbox: cloneSandboxLocked(state)}
 	s.mu.RUnlock()
 	return resp, nil
 }
 
-func (s *Service) ListSandboxes(_ context.Context, _ *cleanroomv1.ListSandboxesRequest) (*cleanroomv1.ListSandboxesResponse, error) {
+func (s *Service) ListSandboxes(ctx context.Context, req *cleanroomv1.ListSandboxesRequest) (*cleanroomv1.ListSandboxesResponse, error) {
+	inScope, err := listNamespaceFilter(ctx, s.runtimeConfig().Namespaces, req.GetNamespace(), req.GetAllNamespaces())
+	if err != nil {
+		return nil, err
+	}
 	s.mu.RLock()
-	items := make([]*sandboxState, 0, len(s.sandboxes))
-	for _, sb := range s.sandboxes {
-		items = append(items, sb)
+	items := make([]*cleanroomv1.Sandbox, 0, len(s.sandboxes))
+	local := make(map[string]bool, len(s.sandboxes))
+	for id, sb := range s.sandboxes {
+		local[id] = true
+		if inScope(sb.Namespace) {
+			items = append(items, cloneSandboxLocked(sb))
+		}
 	}
 	s.mu.RUnlock()
+	for _, sb := range s.remoteSandboxes(ctx) {
+		if !local[sb.GetSandboxId()] && inScope(sb.GetNamespace()) {
+			items = append(items, sb)
+		}
+	}
 
-	sort.Slice(items, func(i, j int) bool {
-		return items[i].CreatedAt.Before(items[j].CreatedAt)
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].GetCreatedAt().AsTime().Before(items[j].GetCreatedAt().AsTime())
 	})
-
-	resp := &cleanroomv1.ListSandboxesResponse{Sandboxes: make([]*cleanroomv1.Sandbox, 0, len(items))}
-	for _, sb := range items {
-		resp.Sandboxes = append(resp.Sandboxes, cloneSandboxLocked(sb))
-	}
-	return resp, nil
+	return &cleanroomv1.ListSandboxesResponse{Sandboxes: items}, nil
 }
 
 func (s *Service) DownloadSandboxFile(ctx context.Context, req *cleanroomv1.DownloadSandboxFileRequest) (*cleanroomv1.DownloadSandboxFileResponse, error) {
@@ -274,57 +478,108 @@ func (s *Service) DownloadSandboxFile(ctx context.Context, req *cleanroomv1.Down
 	if maxBytes <= 0 {
 		maxBytes = defaultDownloadMaxBytes
 	}
+	if err := checkDeltaBase(req.GetDeltaBase()); err != nil {
+		return nil, err
+	}
 
+	backendName, adapter, release, err := s.beginSandboxOperation(sandboxID, "file download")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	downloader, ok := adapter.(backend.SandboxFileDownloadAdapter)
+	if !ok {
+		return nil, fmt.Errorf("backend %q does not support sandbox file downloads", backendName)
+	}
+
+	if deltas, ok := adapter.(backend.SandboxFileDeltaAdapter); ok {
+		resp, err := downloadFileDelta(ctx, deltas, sandboxID, path, maxBytes, req.GetDeltaBase())
+		if !errors.Is(err, backend.ErrFileDeltaUnsupported) {
+			return resp, err
+		}
+		// The guest agent predates deltas; send the whole file, unchecked.
+	}
+
+	data, err := downloader.DownloadSandboxFile(ctx, sandboxID, path, maxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("download sandbox file: %w", err)
+	}
+	return &cleanroomv1.DownloadSandboxFileResponse{
+		SandboxId: sandboxID,
+		Path:      path,
+		Data:      data,
+		SizeBytes: int64(len(data)),
+	}, nil
+}
+
+func (s *Service) CommitSandbox(ctx context.Context, req *cleanroomv1.CommitSandboxRequest) (*cleanroomv1.CommitSandboxResponse, error) {
+	if req == nil {
+		return nil, errors.New("missing request")
+	}
+	sandboxID := strings.TrimSpace(req.GetSandboxId())
+	if sandboxID == "" {
+		return nil, errors.New("missing sandbox_id")
+	}
+	ref := strings.TrimSpace(req.GetRef())
+	if ref == "" {
+		return nil, errors.New("missing ref")
+	}
+
+	backendName, adapter, release, err := s.beginSandboxOperation(sandboxID, "commit")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	committer, ok := adapter.(backend.SandboxCommitAdapter)
+	if !ok {
+		return nil, fmt.Errorf("backend %q does not support sandbox commits", backendName)
+	}
+
+	imageRef, err := committer.CommitSandbox(ctx, sandboxID, ref)
+	if err != nil {
+		return nil, fmt.Errorf("commit sandbox: %w", err)
+	}
+	return &cleanroomv1.CommitSandboxResponse{
+		SandboxId: sandboxID,
+		ImageRef:  imageRef,
+	}, nil
+}
+
+// beginSandboxOperation marks a ready sandbox busy with a host-side
+// operation such as a file download, so executions and other operations are
+// refused until the returned release func is called. It returns the
+// sandbox's backend name and adapter.
+func (s *Service) beginSandboxOperation(sandboxID, operation string) (string, backend.Adapter, func(), error) {
 	s.mu.Lock()
+	defer s.mu.Unlock()
 	state, ok := s.sandboxes[sandboxID]
 	if !ok {
-		s.mu.Unlock()
-		return nil, fmt.Errorf("unknown sandbox %q", sandboxID)
+		return "", nil, nil, fmt.Errorf("%w %q", ErrUnknownSandbox, sandboxID)
 	}
 	if state.Status != cleanroomv1.SandboxStatus_SANDBOX_STATUS_READY {
-		s.mu.Unlock()
-		return nil, fmt.Errorf("sandbox %q is not ready", sandboxID)
+		return "", nil, nil, fmt.Errorf("sandbox %q is not ready", sandboxID)
 	}
 	adapter, ok := s.Backends[state.Backend]
 	if !ok {
-		s.mu.Unlock()
-		return nil, fmt.Errorf("unknown backend %q", state.Backend)
+		return "", nil, nil, fmt.Errorf("unknown backend %q", state.Backend)
 	}
-	downloader, ok := adapter.(backend.SandboxFileDownloadAdapter)
-	if !ok {
-		s.mu.Unlock()
-		return nil, fmt.Errorf("backend %q does not support sandbox file downloads", state.Backend)
-	}
-	if state.DownloadInProgress {
-		s.mu.Unlock()
-		return nil, fmt.Errorf("sandbox_busy: sandbox %q already has an active file download", sandboxID)
+	if state.BusyWith != "" {
+		return "", nil, nil, fmt.Errorf("sandbox_busy: sandbox %q already has an active %s", sandboxID, state.BusyWith)
 	}
 	if activeID := strings.TrimSpace(state.ActiveExecutionID); activeID != "" {
 		if activeExecution, ok := s.executions[executionKey(sandboxID, activeID)]; ok && !isFinalExecutionStatus(activeExecution.Status) {
-			s.mu.Unlock()
-			return nil, fmt.Errorf("sandbox_busy: sandbox %q already has active execution %q", sandboxID, activeID)
+			return "", nil, nil, fmt.Errorf("sandbox_busy: sandbox %q already has active execution %q", sandboxID, activeID)
 		}
 	}
-	state.DownloadInProgress = true
-	s.mu.Unlock()
-	defer func() {
+	state.BusyWith = operation
+	release := func() {
 		s.mu.Lock()
 		if current, ok := s.sandboxes[sandboxID]; ok {
-			current.DownloadInProgress = false
+			current.BusyWith = ""
 		}
 		s.mu.Unlock()
-	}()
-
-	data, err := downloader.DownloadSandboxFile(ctx, sandboxID, path, maxBytes)
-	if err != nil {
-		return nil, fmt.Errorf("download sandbox file: %w", err)
 	}
-	return &cleanroomv1.DownloadSandboxFileResponse{
-		SandboxId: sandboxID,
-		Path:      path,
-		Data:      data,
-		SizeBytes: int64(len(data)),
-	}, nil
+	return state.Backend, adapter, release, nil
 }
 
 func (s *Service) TerminateSandbox(ctx context.Context, req *cleanroomv1.TerminateSandboxRequest) (*cleanroomv1.TerminateSandboxResponse, error) {
@@ -346,7 +601,7 @@ func (s *Service) TerminateSandbox(ctx context.Context, req *cleanroomv1.Termina
 	state, ok := s.sandboxes[sandboxID]
 	if !ok {
 		s.mu.Unlock()
-		return nil, fmt.Errorf("unknown sandbox %q", sandboxID)
+		return nil, fmt.Errorf("%w %q", ErrUnknownSandbox, sandboxID)
 	}
 	backendName = state.Backend
 
@@ -381,7 +636,7 @@ func (s *Service) TerminateSandbox(ctx context.Context, req *cleanroomv1.Termina
 				Payload:     &cleanroomv1.ExecutionStreamEvent_Message{Message: "execution canceled due to sandbox termination"},
 				OccurredAt:  timestamppb.Now(),
 			})
-			if ex.Status == cleanroomv1.ExecutionStatus_EXECUTION_STATUS_QUEUED {
+			if isPendingExecutionStatus(ex.Status) {
 				finished := terminatedAt
 				s.finalizeExecutionWithoutPruneLocked(
 					ex,
@@ -406,8 +661,8 @@ func (s *Service) TerminateSandbox(ctx context.Context, req *cleanroomv1.Termina
 
 	if !alreadyStopped && persistentAdapter != nil {
 		if err := persistentAdapter.TerminateSandbox(ctx, sandboxID); err != nil {
-			if s.Logger != nil {
-				s.Logger.Warn("terminate backend sandbox failed", "sandbox_id", sandboxID, "backend", backendName, "error", err)
+			if logger := s.logger(ctx); logger != nil {
+				logger.Warn("terminate backend sandbox failed", "sandbox_id", sandboxID, "backend", backendName, "error", err)
 			}
 			return nil, fmt.Errorf("terminate backend sandbox: %w", err)
 		}
@@ -419,6 +674,7 @@ func (s *Service) TerminateSandbox(ctx context.Context, req *cleanroomv1.Termina
 	if ok && state.Status != cleanroomv1.SandboxStatus_SANDBOX_STATUS_STOPPED {
 		state.Status = cleanroomv1.SandboxStatus_SANDBOX_STATUS_STOPPED
 		s.recordSandboxEventLocked(state, cleanroomv1.SandboxStatus_SANDBOX_STATUS_STOPPED, "sandbox terminated")
+		s.recordUsageLocked(sandboxUsageLocked(state, now))
 		closeSandboxDoneLocked(state)
 	}
 	s.pruneStateLocked(now)
@@ -430,8 +686,8 @@ func (s *Service) TerminateSandbox(ctx context.Context, req *cleanroomv1.Termina
 		Message:    "sandbox terminated",
 	}
 
-	if s.Logger != nil {
-		s.Logger.Info("sandbox terminated",
+	if logger := s.logger(ctx); logger != nil {
+		logger.Info("sandbox terminated",
 			"sandbox_id", sandboxID,
 			"backend", backendName,
 		)
@@ -439,7 +695,7 @@ func (s *Service) TerminateSandbox(ctx context.Context, req *cleanroomv1.Termina
 	return resp, nil
 }
 
-func (s *Service) CreateExecution(_ context.Context, req *cleanroomv1.CreateExecutionRequest) (*cleanroomv1.CreateExecutionResponse, error) {
+func (s *Service) CreateExecution(ctx context.Context, req *cleanroomv1.CreateExecutionRequest) (*cleanroomv1.CreateExecutionResponse, error) {
 	if req == nil {
 		return nil, errors.New("missing request")
 	}
@@ -451,12 +707,40 @@ func (s *Service) CreateExecution(_ context.Context, req *cleanroomv1.CreateExec
 	if len(command) == 0 {
 		return nil, errors.New("missing command")
 	}
+	if err := checkCommandSize(s.Config.Executions, command); err != nil {
+		return nil, err
+	}
 
 	execOpts := executionOptions{}
 	tty := false
 	if opts := req.GetOptions(); opts != nil {
+		limits, err := executionLimitsFromProto(opts.GetLimits())
+		if err != nil {
+			return nil, err
+		}
+		launcher, err := resolveExecutionLauncher(opts.GetLauncher())
+		if err != nil {
+			return nil, err
+		}
+		captureChanges, err := resolveChangeCapture(opts.GetCaptureChanges())
+		if err != nil {
+			return nil, err
+		}
+		parsers, err := resolveResultParsers(opts.GetResultParsers())
+		if err != nil {
+			return nil, err
+		}
 		execOpts = executionOptions{
-			LaunchSeconds: opts.GetLaunchSeconds(),
+			LaunchSeconds:           opts.GetLaunchSeconds(),
+			Limits:                  limits,
+			KeepBackgroundProcesses: opts.GetKeepBackgroundProcesses(),
+			FreshHome:               opts.GetFreshHome(),
+			Launcher:                launcher,
+			Stdin:                   opts.GetStdin(),
+			VCPUs:                   opts.GetVcpus(),
+			MemoryMiB:               opts.GetMemoryMib(),
+			CaptureChanges:          captureChanges,
+			ResultParsers:           parsers,
 		}
 		tty = opts.GetTty()
 	}
@@ -467,6 +751,23 @@ func (s *Service) CreateExecution(_ context.Context, req *cleanroomv1.CreateExec
 	if kind == cleanroomv1.ExecutionKind_EXECUTION_KIND_INTERACTIVE {
 		tty = true
 	}
+	annotations, err := keyValuesFromProto("annotation", req.GetAnnotations())
+	if err != nil {
+		return nil, err
+	}
+	runID := ""
+	if requested := strings.TrimSpace(req.GetRunId()); requested != "" {
+		if runID, err = normalizeRunID(requested); err != nil {
+			return nil, err
+		}
+		recorded, err := s.runIDRecorded(ctx, runID)
+		if err != nil {
+			return nil, err
+		}
+		if recorded {
+			return nil, fmt.Errorf("%w: %q", ErrRunIDInUse, runID)
+		}
+	}
 
 	now := time.Now().UTC()
 	executionID := newExecutionID()
@@ -477,16 +778,29 @@ func (s *Service) CreateExecution(_ context.Context, req *cleanroomv1.CreateExec
 	sandbox, ok := s.sandboxes[sandboxID]
 	if !ok {
 		s.mu.Unlock()
-		return nil, fmt.Errorf("unknown sandbox %q", sandboxID)
+		return nil, fmt.Errorf("%w %q", ErrUnknownSandbox, sandboxID)
 	}
 	if sandbox.Status != cleanroomv1.SandboxStatus_SANDBOX_STATUS_READY {
 		s.mu.Unlock()
 		return nil, fmt.Errorf("sandbox %q is not ready", sandboxID)
 	}
-	if _, ok := s.Backends[sandbox.Backend]; !ok {
+	adapter, ok := s.Backends[sandbox.Backend]
+	if !ok {
 		s.mu.Unlock()
 		return nil, fmt.Errorf("unknown backend %q", sandbox.Backend)
 	}
+	if err := checkExecutionVMSize(execOpts, sandbox, adapter, s.Config); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	if err := checkChangeCapture(execOpts.CaptureChanges, sandbox.Backend, adapter); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	if err := checkResultParsers(execOpts.ResultParsers, sandbox.Backend, adapter); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
 	if strings.TrimSpace(sandbox.ActiveExecutionID) != "" {
 		if activeExecution, ok := s.executions[executionKey(sandboxID, sandbox.ActiveExecutionID)]; ok && !isFinalExecutionStatus(activeExecution.Status) {
 			s.mu.Unlock()
@@ -494,9 +808,13 @@ func (s *Service) CreateExecution(_ context.Context, req *cleanroomv1.CreateExec
 		}
 		sandbox.ActiveExecutionID = ""
 	}
-	if sandbox.DownloadInProgress {
+	if sandbox.BusyWith != "" {
 		s.mu.Unlock()
-		return nil, fmt.Errorf("sandbox_busy: sandbox %q currently has an active file download", sandboxID)
+		return nil, fmt.Errorf("sandbox_busy: sandbox %q currently has an active %s", sandboxID, sandbox.BusyWith)
+	}
+	if runID != "" && s.runIDInUseLocked(runID) {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: %q", ErrRunIDInUse, runID)
 	}
 	imageRef := ""
 	imageDigest := ""
@@ -508,41 +826,63 @@ func (s *Service) CreateExecution(_ context.Context, req *cleanroomv1.CreateExec
 	ex := &executionState{
 		ID:               executionID,
 		SandboxID:        sandboxID,
+		Namespace:        sandbox.Namespace,
+		RunID:            runID,
 		ImageRef:         imageRef,
 		ImageDigest:      imageDigest,
 		Command:          append([]string(nil), command...),
 		Options:          execOpts,
 		TTY:              tty,
 		Kind:             kind,
+		Annotations:      annotations,
 		Status:           cleanroomv1.ExecutionStatus_EXECUTION_STATUS_QUEUED,
+		RequestID:        logging.RequestID(ctx),
+		Identity:         CallerIdentity(ctx),
 		EventSubscribers: map[int]chan *cleanroomv1.ExecutionStreamEvent{},
 		Done:             make(chan struct{}),
 	}
+	if execOpts.ResultParsers.GoTestJSON {
+		ex.GoTestJSON = &goTestJSONParser{}
+	}
 	s.executions[executionKey(sandboxID, executionID)] = ex
 	sandbox.LastExecutionID = executionID
 	sandbox.ActiveExecutionID = executionID
 	sandbox.UpdatedAt = now
-	s.recordExecutionEventLocked(ex, &cleanroomv1.ExecutionStreamEvent{
-		SandboxId:   sandboxID,
-		ExecutionId: executionID,
-		Status:      cleanroomv1.ExecutionStatus_EXECUTION_STATUS_QUEUED,
-		Payload:     &cleanroomv1.ExecutionStreamEvent_Message{Message: "execution queued"},
-		OccurredAt:  timestamppb.New(now),
-	})
+	identity := CallerIdentity(ctx)
+	approval := s.Config.Approval
+	rules := approvalRules(approval, identity, sandbox.Labels)
+	var pending *cleanroomv1.PendingApproval
+	if len(rules) > 0 {
+		s.holdForApprovalLocked(ex, identity, rules, approvalTimeout(approval), now)
+		pending = pendingApprovalLocked(ex, sandbox)
+	} else {
+		s.recordExecutionEventLocked(ex, &cleanroomv1.ExecutionStreamEvent{
+			SandboxId:   sandboxID,
+			ExecutionId: executionID,
+			Status:      cleanroomv1.ExecutionStatus_EXECUTION_STATUS_QUEUED,
+			Payload:     &cleanroomv1.ExecutionStreamEvent_Message{Message: "execution queued"},
+			OccurredAt:  timestamppb.New(now),
+		})
+	}
 	s.pruneStateLocked(now)
 
 	resp := &cleanroomv1.CreateExecutionResponse{Execution: cloneExecutionLocked(ex)}
 	s.mu.Unlock()
 
-	go s.runExecution(sandboxID, executionID)
+	if pending == nil {
+		go s.runExecution(sandboxID, executionID)
+	} else if url := strings.TrimSpace(approval.WebhookURL); url != "" {
+		go s.notifyApprovalWebhook(url, pending)
+	}
 
-	if s.Logger != nil {
-		s.Logger.Info("execution created",
+	if logger := s.logger(ctx); logger != nil {
+		logger.Info("execution created",
 			"sandbox_id", sandboxID,
 			"execution_id", executionID,
 			"command_argc", len(command),
 			"tty", tty,
 			"kind", kind.String(),
+			"pending_approval", pending != nil,
 		)
 	}
 	return resp, nil
@@ -578,7 +918,7 @@ func (s *Service) OpenInteractiveExecution(_ context.Context, req *cleanroomv1.O
 
 	ex, ok := s.executions[executionKey(sandboxID, executionID)]
 	if !ok {
-		return nil, fmt.Errorf("unknown execution %q in sandbox %q", executionID, sandboxID)
+		return nil, fmt.Errorf("%w %q in sandbox %q", ErrUnknownExecution, executionID, sandboxID)
 	}
 	if ex.Kind != cleanroomv1.ExecutionKind_EXECUTION_KIND_INTERACTIVE {
 		return nil, fmt.Errorf("execution %q is not interactive", executionID)
@@ -653,7 +993,7 @@ func (s *Service) ConsumeInteractiveSession(sessionID, token string) (*Interacti
 	ex, ok := s.executions[execKey]
 	if !ok {
 		delete(s.interactiveSessions, id)
-		return nil, fmt.Errorf("unknown execution %q in sandbox %q", session.ExecutionID, session.SandboxID)
+		return nil, fmt.Errorf("%w %q in sandbox %q", ErrUnknownExecution, session.ExecutionID, session.SandboxID)
 	}
 	if ex.Kind != cleanroomv1.ExecutionKind_EXECUTION_KIND_INTERACTIVE {
 		delete(s.interactiveSessions, id)
@@ -692,7 +1032,7 @@ func (s *Service) ReleaseInteractiveExecution(sandboxID, executionID string) {
 	delete(s.interactiveAttached, execKey)
 }
 
-func (s *Service) GetExecution(_ context.Context, req *cleanroomv1.GetExecutionRequest) (*cleanroomv1.GetExecutionResponse, error) {
+func (s *Service) GetExecution(ctx context.Context, req *cleanroomv1.GetExecutionRequest) (*cleanroomv1.GetExecutionResponse, error) {
 	if req == nil {
 		return nil, errors.New("missing request")
 	}
@@ -709,7 +1049,10 @@ func (s *Service) GetExecution(_ context.Context, req *cleanroomv1.GetExecutionR
 	ex, ok := s.executions[executionKey(sandboxID, executionID)]
 	if !ok {
 		s.mu.RUnlock()
-		return nil, fmt.Errorf("unknown execution %q in sandbox %q", executionID, sandboxID)
+		if remote, ok := s.remoteExecution(ctx, sandboxID, executionID); ok {
+			return &cleanroomv1.GetExecutionResponse{Execution: remote}, nil
+		}
+		return nil, fmt.Errorf("%w %q in sandbox %q", ErrUnknownExecution, executionID, sandboxID)
 	}
 	resp := &cleanroomv1.GetExecutionResponse{Execution: cloneExecutionLocked(ex)}
 	s.mu.RUnlock()
@@ -742,7 +1085,7 @@ func (s *Service) CancelExecution(_ context.Context, req *cleanroomv1.CancelExec
 	ex, ok := s.executions[executionKey(sandboxID, executionID)]
 	if !ok {
 		s.mu.Unlock()
-		return nil, fmt.Errorf("unknown execution %q in sandbox %q", executionID, sandboxID)
+		return nil, fmt.Errorf("%w %q in sandbox %q", ErrUnknownExecution, executionID, sandboxID)
 	}
 	status = ex.Status
 	if isFinalExecutionStatus(ex.Status) {
@@ -766,7 +1109,11 @@ func (s *Service) CancelExecution(_ context.Context, req *cleanroomv1.CancelExec
 		OccurredAt:  timestamppb.New(now),
 	})
 
-	if ex.Status == cleanroomv1.ExecutionStatus_EXECUTION_STATUS_QUEUED {
+	if isPendingExecutionStatus(ex.Status) {
+		if ex.ApprovalTimer != nil {
+			ex.ApprovalTimer.Stop()
+			ex.ApprovalTimer = nil
+		}
 		finished := now
 		s.finalizeExecutionLocked(
 			ex,
@@ -816,6 +1163,9 @@ func (s *Service) WriteExecutionStdin(sandboxID, executionID string, data []byte
 	if executionID == "" {
 		return errors.New("missing execution_id")
 	}
+	if err := checkStdinSize(s.Config.Executions, data); err != nil {
+		return err
+	}
 
 	payload := append([]byte(nil), data...)
 	deadline := time.Now().Add(attachStdinRegistrationWait)
@@ -828,11 +1178,15 @@ func (s *Service) WriteExecutionStdin(sandboxID, executionID string, data []byte
 		ex, ok := s.executions[executionKey(sandboxID, executionID)]
 		if !ok {
 			s.mu.RUnlock()
-			return fmt.Errorf("unknown execution %q in sandbox %q", executionID, sandboxID)
+			return fmt.Errorf("%w %q in sandbox %q", ErrUnknownExecution, executionID, sandboxID)
 		}
 		if isFinalExecutionStatus(ex.Status) {
 			s.mu.RUnlock()
-			return errors.New("execution is not running")
+			return ErrExecutionNotRunning
+		}
+		if !ex.TTY && !ex.Options.Stdin {
+			s.mu.RUnlock()
+			return ErrExecutionStdinNotOpen
 		}
 		writeFn = ex.AttachStdin
 		done = ex.Done
@@ -851,6 +1205,55 @@ func (s *Service) WriteExecutionStdin(sandboxID, executionID string, data []byte
 	}
 }
 
+// CloseExecutionStdin signals EOF on the stdin of an execution created with
+// stdin open.
+func (s *Service) CloseExecutionStdin(sandboxID, executionID string) error {
+	sandboxID = strings.TrimSpace(sandboxID)
+	executionID = strings.TrimSpace(executionID)
+	if sandboxID == "" {
+		return errors.New("missing sandbox_id")
+	}
+	if executionID == "" {
+		return errors.New("missing execution_id")
+	}
+
+	deadline := time.Now().Add(attachStdinRegistrationWait)
+	for {
+		var (
+			closeFn func() error
+			done    <-chan struct{}
+		)
+		s.mu.RLock()
+		ex, ok := s.executions[executionKey(sandboxID, executionID)]
+		if !ok {
+			s.mu.RUnlock()
+			return fmt.Errorf("%w %q in sandbox %q", ErrUnknownExecution, executionID, sandboxID)
+		}
+		if isFinalExecutionStatus(ex.Status) {
+			s.mu.RUnlock()
+			return ErrExecutionNotRunning
+		}
+		if !ex.TTY && !ex.Options.Stdin {
+			s.mu.RUnlock()
+			return ErrExecutionStdinNotOpen
+		}
+		closeFn = ex.AttachCloseStdin
+		done = ex.Done
+		s.mu.RUnlock()
+
+		if closeFn != nil {
+			return closeFn()
+		}
+		if time.Now().After(deadline) {
+			return ErrExecutionStdinUnsupported
+		}
+		select {
+		case <-done:
+		case <-time.After(attachPollInterval):
+		}
+	}
+}
+
 func (s *Service) ResizeExecutionTTY(sandboxID, executionID string, cols, rows uint32) error {
 	sandboxID = strings.TrimSpace(sandboxID)
 	executionID = strings.TrimSpace(executionID)
@@ -871,11 +1274,11 @@ func (s *Service) ResizeExecutionTTY(sandboxID, executionID string, cols, rows u
 		ex, ok := s.executions[executionKey(sandboxID, executionID)]
 		if !ok {
 			s.mu.RUnlock()
-			return fmt.Errorf("unknown execution %q in sandbox %q", executionID, sandboxID)
+			return fmt.Errorf("%w %q in sandbox %q", ErrUnknownExecution, executionID, sandboxID)
 		}
 		if isFinalExecutionStatus(ex.Status) {
 			s.mu.RUnlock()
-			return errors.New("execution is not running")
+			return ErrExecutionNotRunning
 		}
 		resizeFn = ex.AttachResize
 		done = ex.Done
@@ -905,7 +1308,7 @@ func (s *Service) SubscribeSandboxEvents(sandboxID string) ([]*cleanroomv1.Sandb
 
 	sb, ok := s.sandboxes[sandboxID]
 	if !ok {
-		return nil, nil, nil, nil, fmt.Errorf("unknown sandbox %q", sandboxID)
+		return nil, nil, nil, nil, fmt.Errorf("%w %q", ErrUnknownSandbox, sandboxID)
 	}
 
 	history := append([]*cleanroomv1.SandboxEvent(nil), sb.EventHistory...)
@@ -958,7 +1361,7 @@ func (s *Service) SubscribeExecutionEvents(sandboxID, executionID string) ([]*cl
 
 	ex, ok := s.executions[executionKey(sandboxID, executionID)]
 	if !ok {
-		return nil, nil, nil, nil, fmt.Errorf("unknown execution %q in sandbox %q", executionID, sandboxID)
+		return nil, nil, nil, nil, fmt.Errorf("%w %q in sandbox %q", ErrUnknownExecution, executionID, sandboxID)
 	}
 
 	history := append([]*cleanroomv1.ExecutionStreamEvent(nil), ex.EventHistory...)
@@ -1011,7 +1414,7 @@ func (s *Service) WaitExecution(ctx context.Context, sandboxID, executionID stri
 	ex, ok := s.executions[executionKey(sandboxID, executionID)]
 	if !ok {
 		s.mu.RUnlock()
-		return nil, fmt.Errorf("unknown execution %q in sandbox %q", executionID, sandboxID)
+		return nil, fmt.Errorf("%w %q in sandbox %q", ErrUnknownExecution, executionID, sandboxID)
 	}
 	out := cloneExecutionLocked(ex)
 	s.mu.RUnlock()
@@ -1023,7 +1426,7 @@ func (s *Service) ExecutionSnapshot(sandboxID, executionID string) (*executionSn
 	defer s.mu.RUnlock()
 	ex, ok := s.executions[executionKey(sandboxID, executionID)]
 	if !ok {
-		return nil, fmt.Errorf("unknown execution %q in sandbox %q", executionID, sandboxID)
+		return nil, fmt.Errorf("%w %q in sandbox %q", ErrUnknownExecution, executionID, sandboxID)
 	}
 	return &executionSnapshot{
 		Execution:   cloneExecutionLocked(ex),
@@ -1099,25 +1502,25 @@ func (s *Service) runExecution(sandboxID, executionID string) {
 		return
 	}
 
-	runCtx, cancel := context.WithCancel(context.Background())
+	if ex.RunID == "" {
+		ex.RunID = newRunID()
+	}
+	// The backend logs through runCtx, so its lines carry the same IDs.
+	runCtx := logging.WithFields(logging.WithRequestID(context.Background(), ex.RequestID),
+		"sandbox_id", sandboxID,
+		"execution_id", executionID,
+		"run_id", ex.RunID,
+	)
+	runCtx, cancel := context.WithCancel(runCtx)
 	ex.Cancel = cancel
 
 	started := time.Now().UTC()
 	ex.StartedAt = &started
 	ex.Status = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_RUNNING
-	ex.RunID = newRunID()
 	if sb.Policy != nil {
 		ex.ImageRef = sb.Policy.ImageRef
 		ex.ImageDigest = sb.Policy.ImageDigest
 	}
-	s.recordExecutionEventLocked(ex, &cleanroomv1.ExecutionStreamEvent{
-		SandboxId:   sandboxID,
-		ExecutionId: executionID,
-		Status:      ex.Status,
-		Payload:     &cleanroomv1.ExecutionStreamEvent_Message{Message: "execution started"},
-		OccurredAt:  timestamppb.New(started),
-	})
-
 	firecrackerCfg := sb.Firecracker
 	if strings.TrimSpace(firecrackerCfg.RunDir) == "" {
 		if runBaseDir, err := paths.RunBaseDir(); err == nil {
@@ -1127,18 +1530,62 @@ func (s *Service) runExecution(sandboxID, executionID string) {
 	if ex.Options.LaunchSeconds != 0 {
 		firecrackerCfg.LaunchSeconds = ex.Options.LaunchSeconds
 	}
+	if ex.Options.VCPUs != 0 {
+		firecrackerCfg.VCPUs = ex.Options.VCPUs
+	}
+	if ex.Options.MemoryMiB != 0 {
+		firecrackerCfg.MemoryMiB = ex.Options.MemoryMiB
+	}
+
+	ex.Environment = s.executionEnvironmentLocked(sb, adapter, firecrackerCfg)
+	s.recordExecutionEventLocked(ex, &cleanroomv1.ExecutionStreamEvent{
+		SandboxId:   sandboxID,
+		ExecutionId: executionID,
+		Status:      ex.Status,
+		Payload:     &cleanroomv1.ExecutionStreamEvent_Environment{Environment: ex.Environment},
+		OccurredAt:  timestamppb.New(started),
+	})
+	s.recordExecutionEventLocked(ex, &cleanroomv1.ExecutionStreamEvent{
+		SandboxId:   sandboxID,
+		ExecutionId: executionID,
+		Status:      ex.Status,
+		Payload:     &cleanroomv1.ExecutionStreamEvent_Message{Message: "execution started"},
+		OccurredAt:  timestamppb.New(started),
+	})
 
 	runReq := backend.RunRequest{
-		SandboxID:         sandboxID,
-		RunID:             ex.RunID,
-		Command:           append([]string(nil), ex.Command...),
-		TTY:               ex.TTY,
-		Policy:            sb.Policy,
-		FirecrackerConfig: firecrackerCfg,
-	}
+		SandboxID:               sandboxID,
+		RunID:                   ex.RunID,
+		Namespace:               sb.Namespace,
+		Command:                 append([]string(nil), ex.Command...),
+		TTY:                     ex.TTY,
+		Policy:                  sb.Policy,
+		Limits:                  ex.Options.Limits,
+		KeepBackgroundProcesses: ex.Options.KeepBackgroundProcesses,
+		FreshHome:               ex.Options.FreshHome,
+		Launcher:                ex.Options.Launcher,
+		Stdin:                   ex.Options.Stdin && !ex.TTY,
+		CaptureChanges:          ex.Options.CaptureChanges,
+		FirecrackerConfig:       firecrackerCfg,
+	}
+	junitPaths := ex.Options.ResultParsers.JUnitPaths
 	s.mu.Unlock()
 
 	result, usedStreaming, err := s.runAdapterExecution(runCtx, adapter, runReq, key)
+	if s.RunStore != nil {
+		runDir := firecrackerCfg.RunDir
+		if err == nil && strings.TrimSpace(result.RunDir) != "" {
+			runDir = result.RunDir
+		}
+		go s.archiveRun(runCtx, runReq.RunID, runDir)
+	}
+	var artifacts []*cleanroomv1.ExecutionArtifact
+	var artifactsErr error
+	var junit []junitReport
+	if err == nil {
+		artifacts, artifactsErr = takeExecutionArtifacts(adapter, sandboxID)
+		junit = readJUnitReports(adapter, sandboxID, junitPaths)
+	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -1158,16 +1605,21 @@ func (s *Service) runExecution(sandboxID, executionID string) {
 
 	if err != nil {
 		finalStatus, exitCode := executionRunErrorStatus(ex, runCtx)
+		var failure *backend.GuestFailure
+		if errors.As(err, &failure) && finalStatus == cleanroomv1.ExecutionStatus_EXECUTION_STATUS_FAILED {
+			ex.FailureReason = executionFailureReason(failure)
+			if failure.Reason == backend.GuestFailureOOM {
+				exitCode = oomKilledExitCode
+			}
+		}
 		if strings.TrimSpace(err.Error()) != "" {
 			s.appendExecutionStderrLocked(ex, finalStatus, []byte(err.Error()+"\n"))
 		}
+		ex.TestResults = executionTestResults(ex.Options.ResultParsers, ex.GoTestJSON, nil)
 		finished := time.Now().UTC()
 		s.finalizeExecutionLocked(ex, finalStatus, exitCode, err.Error(), "", finished)
-		if s.Logger != nil {
-			s.Logger.Warn("execution failed",
-				"sandbox_id", ex.SandboxID,
-				"execution_id", ex.ID,
-				"run_id", ex.RunID,
+		if logger := s.logger(runCtx); logger != nil {
+			logger.Warn("execution failed",
 				"image_ref", ex.ImageRef,
 				"image_digest", ex.ImageDigest,
 				"status", ex.Status.String(),
@@ -1188,29 +1640,44 @@ func (s *Service) runExecution(sandboxID, executionID string) {
 		ex.ImageDigest = result.ImageDigest
 	}
 	ex.Message = result.Message
+	ex.ExitMetadata = result.ExitMetadata
+	ex.Timings = result.Timings
+	ex.Artifacts = artifacts
+	ex.FailureReason = executionFailureReason(result.Failure)
 	s.mergeBufferedResultOutputLocked(ex, result, usedStreaming)
+	if artifactsErr != nil {
+		msg := fmt.Sprintf("cleanroom: ignoring artifact manifest %s: %v\n", backend.ArtifactManifestPath, artifactsErr)
+		s.appendExecutionStderrLocked(ex, cleanroomv1.ExecutionStatus_EXECUTION_STATUS_RUNNING, []byte(msg))
+		if logger := s.logger(runCtx); logger != nil {
+			logger.Warn("read artifact manifest failed", "error", artifactsErr)
+		}
+	}
 
 	if result.ExitCode != 0 && strings.TrimSpace(result.Message) != "" && !strings.Contains(ex.Stderr, result.Message) {
 		msg := result.Message + "\n"
 		s.appendExecutionStderrLocked(ex, cleanroomv1.ExecutionStatus_EXECUTION_STATUS_RUNNING, []byte(msg))
 	}
 
+	ex.TestResults = executionTestResults(ex.Options.ResultParsers, ex.GoTestJSON, junit)
+
 	finalStatus := cleanroomv1.ExecutionStatus_EXECUTION_STATUS_FAILED
 	finalExitCode := int32(result.ExitCode)
 	if ex.CancelRequested {
 		finalStatus = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_CANCELED
 		finalExitCode = cancelExitCode(ex.CancelSignal)
-	} else if result.ExitCode == 0 {
-		finalStatus = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SUCCEEDED
+	} else {
+		if result.ExitCode == 0 {
+			finalStatus = cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SUCCEEDED
+		}
+		if sb, ok := s.sandboxes[sandboxID]; ok {
+			finalStatus = applyExitCodeRuleLocked(ex, sb.Policy, result.ExitCode, finalStatus)
+		}
 	}
 	finished := time.Now().UTC()
 	s.finalizeExecutionLocked(ex, finalStatus, finalExitCode, ex.Message, "", finished)
 
-	if s.Logger != nil {
-		s.Logger.Info("execution completed",
-			"sandbox_id", ex.SandboxID,
-			"execution_id", ex.ID,
-			"run_id", ex.RunID,
+	if logger := s.logger(runCtx); logger != nil {
+		logger.Info("execution completed",
 			"image_ref", ex.ImageRef,
 			"image_digest", ex.ImageDigest,
 			"exit_code", ex.ExitCode,
@@ -1247,6 +1714,23 @@ func (s *Service) executionOutputStream(key string) backend.OutputStream {
 	}
 }
 
+// oomKilledExitCode is what a shell reports for a SIGKILLed command.
+const oomKilledExitCode = 137
+
+func executionFailureReason(failure *backend.GuestFailure) cleanroomv1.ExecutionFailureReason {
+	if failure == nil {
+		return cleanroomv1.ExecutionFailureReason_EXECUTION_FAILURE_REASON_UNSPECIFIED
+	}
+	switch failure.Reason {
+	case backend.GuestFailureOOM:
+		return cleanroomv1.ExecutionFailureReason_EXECUTION_FAILURE_REASON_GUEST_OOM
+	case backend.GuestFailureKernelPanic:
+		return cleanroomv1.ExecutionFailureReason_EXECUTION_FAILURE_REASON_GUEST_KERNEL_PANIC
+	default:
+		return cleanroomv1.ExecutionFailureReason_EXECUTION_FAILURE_REASON_UNSPECIFIED
+	}
+}
+
 func executionRunErrorStatus(ex *executionState, runCtx context.Context) (cleanroomv1.ExecutionStatus, int32) {
 	if ex == nil {
 		return cleanroomv1.ExecutionStatus_EXECUTION_STATUS_FAILED, 1
@@ -1273,6 +1757,9 @@ func (s *Service) ensureMapsLocked() {
 	if s.interactiveAttached == nil {
 		s.interactiveAttached = map[string]struct{}{}
 	}
+	if s.pendingNames == nil {
+		s.pendingNames = map[string]struct{}{}
+	}
 }
 
 func (s *Service) pruneExpiredInteractiveSessionsLocked(now time.Time) {
@@ -1354,6 +1841,9 @@ func (s *Service) appendExecutionStdoutLocked(ex *executionState, status cleanro
 		return
 	}
 	ex.Stdout = appendRetainedOutput(ex.Stdout, string(chunk), maxRetainedExecutionOutputBytes)
+	if ex.GoTestJSON != nil {
+		ex.GoTestJSON.write(chunk)
+	}
 	s.recordExecutionEventLocked(ex, &cleanroomv1.ExecutionStreamEvent{
 		SandboxId:   ex.SandboxID,
 		ExecutionId: ex.ID,
@@ -1410,7 +1900,7 @@ func (s *Service) executionDoneChannel(sandboxID, executionID string) (<-chan st
 	defer s.mu.RUnlock()
 	ex, ok := s.executions[executionKey(sandboxID, executionID)]
 	if !ok {
-		return nil, fmt.Errorf("unknown execution %q in sandbox %q", executionID, sandboxID)
+		return nil, fmt.Errorf("%w %q in sandbox %q", ErrUnknownExecution, executionID, sandboxID)
 	}
 	return ex.Done, nil
 }
@@ -1436,6 +1926,7 @@ func clearExecutionAttachIOLocked(ex *executionState) {
 		return
 	}
 	ex.AttachStdin = nil
+	ex.AttachCloseStdin = nil
 	ex.AttachResize = nil
 }
 
@@ -1448,6 +1939,7 @@ func (s *Service) setExecutionAttachIO(key string, io backend.AttachIO) {
 		return
 	}
 	ex.AttachStdin = io.WriteStdin
+	ex.AttachCloseStdin = io.CloseStdin
 	ex.AttachResize = io.ResizeTTY
 }
 
@@ -1472,6 +1964,9 @@ func (s *Service) dropSandboxLocked(sandboxID string, sb *sandboxState) {
 	closeSandboxSubscribersLocked(sb)
 	closeSandboxDoneLocked(sb)
 	delete(s.sandboxes, sandboxID)
+	if s.Shared != nil {
+		s.Shared.DeleteSandbox(sandboxID)
+	}
 }
 
 func (s *Service) dropExecutionLocked(key string, ex *executionState) {
@@ -1482,6 +1977,9 @@ func (s *Service) dropExecutionLocked(key string, ex *executionState) {
 	closeExecutionDoneLocked(ex)
 	s.clearInteractiveExecutionStateLocked(key)
 	delete(s.executions, key)
+	if s.Shared != nil {
+		s.Shared.DeleteExecution(ex.SandboxID, ex.ID)
+	}
 }
 
 func (s *Service) hasActiveExecutionLocked(sandboxID string) bool {
@@ -1644,6 +2142,8 @@ func isFinalExecutionStatus(status cleanroomv1.ExecutionStatus) bool {
 	switch status {
 	case cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SUCCEEDED,
 		cleanroomv1.ExecutionStatus_EXECUTION_STATUS_FAILED,
+		cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SKIPPED,
+		cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SOFT_FAILED,
 		cleanroomv1.ExecutionStatus_EXECUTION_STATUS_CANCELED,
 		cleanroomv1.ExecutionStatus_EXECUTION_STATUS_TIMED_OUT:
 		return true
@@ -1672,12 +2172,20 @@ func cloneSandboxLocked(state *sandboxState) *cleanroomv1.Sandbox {
 		policyHash = state.Policy.Hash
 	}
 	return &cleanroomv1.Sandbox{
-		SandboxId:  state.ID,
-		Status:     state.Status,
-		Backend:    state.Backend,
-		PolicyHash: policyHash,
-		CreatedAt:  timestamppb.New(state.CreatedAt),
-		UpdatedAt:  timestamppb.New(state.UpdatedAt),
+		SandboxId:    state.ID,
+		Status:       state.Status,
+		Backend:      state.Backend,
+		PolicyHash:   policyHash,
+		CreatedAt:    timestamppb.New(state.CreatedAt),
+		UpdatedAt:    timestamppb.New(state.UpdatedAt),
+		Name:         state.Name,
+		Namespace:    state.Namespace,
+		Labels:       maps.Clone(state.Labels),
+		Checkout:     proto.Clone(state.Checkout).(*cleanroomv1.SandboxCheckout),
+		AgentHash:    state.AgentHash,
+		Resolutions:  hostResolutionsToProto(state.Resolutions),
+		GroupId:      state.GroupID,
+		GroupAddress: state.GroupAddress,
 	}
 }
 
@@ -1686,14 +2194,27 @@ func cloneExecutionLocked(state *executionState) *cleanroomv1.Execution {
 		return nil
 	}
 	out := &cleanroomv1.Execution{
-		ExecutionId: state.ID,
-		SandboxId:   state.SandboxID,
-		Status:      state.Status,
-		Command:     append([]string(nil), state.Command...),
-		ExitCode:    state.ExitCode,
-		Tty:         state.TTY,
-		RunId:       state.RunID,
-		Kind:        state.Kind,
+		ExecutionId:   state.ID,
+		SandboxId:     state.SandboxID,
+		Status:        state.Status,
+		Command:       append([]string(nil), state.Command...),
+		ExitCode:      state.ExitCode,
+		Tty:           state.TTY,
+		RunId:         state.RunID,
+		Kind:          state.Kind,
+		ExitMetadata:  executionExitMetadata(state.ExitMetadata),
+		Artifacts:     cloneArtifacts(state.Artifacts),
+		FailureReason: state.FailureReason,
+		Timings:       executionTimings(state.Timings),
+		Namespace:     state.Namespace,
+		Annotations:   maps.Clone(state.Annotations),
+		Retriable:     state.Retriable,
+	}
+	if state.TestResults != nil {
+		out.TestResults = proto.Clone(state.TestResults).(*cleanroomv1.ExecutionTestResults)
+	}
+	if state.Approval != nil {
+		out.Approval = proto.Clone(state.Approval).(*cleanroomv1.ExecutionApproval)
 	}
 	if state.StartedAt != nil {
 		out.StartedAt = timestamppb.New(*state.StartedAt)
@@ -1704,6 +2225,42 @@ func cloneExecutionLocked(state *executionState) *cleanroomv1.Execution {
 	return out
 }
 
+// executionExitMetadata converts the resource usage a backend reported for
+// the guest process. It returns nil when the backend reported none.
+func executionExitMetadata(m *backend.ExitMetadata) *cleanroomv1.ExecutionExitMetadata {
+	if m == nil {
+		return nil
+	}
+	return &cleanroomv1.ExecutionExitMetadata{
+		UserCpuMs:   m.UserCPU.Milliseconds(),
+		SystemCpuMs: m.SystemCPU.Milliseconds(),
+		MaxRssBytes: m.MaxRSSBytes,
+		WallMs:      m.Wall.Milliseconds(),
+		Signaled:    m.Signal != 0,
+		Signal:      int32(m.Signal),
+		SignalName:  m.SignalName,
+	}
+}
+
+// executionTimings converts the phase timings a backend reported for a run.
+// It returns nil when the backend reported none.
+func executionTimings(t *backend.RunTimings) *cleanroomv1.ExecutionTimings {
+	if t == nil {
+		return nil
+	}
+	return &cleanroomv1.ExecutionTimings{
+		PolicyResolveMs: t.PolicyResolve.Milliseconds(),
+		RootfsCopyMs:    t.RootFSCopy.Milliseconds(),
+		VmStartMs:       t.VMStart.Milliseconds(),
+		NetworkSetupMs:  t.NetworkSetup.Milliseconds(),
+		VmReadyMs:       t.VMReady.Milliseconds(),
+		VsockWaitMs:     t.VsockWait.Milliseconds(),
+		GuestExecMs:     t.GuestExec.Milliseconds(),
+		TotalMs:         t.Total.Milliseconds(),
+		ImageCacheHit:   t.ImageCacheHit,
+	}
+}
+
 func resolveExecutionKind(kind cleanroomv1.ExecutionKind, tty bool) (cleanroomv1.ExecutionKind, error) {
 	if kind == cleanroomv1.ExecutionKind_EXECUTION_KIND_UNSPECIFIED {
 		if tty {
@@ -1721,6 +2278,32 @@ func resolveExecutionKind(kind cleanroomv1.ExecutionKind, tty bool) (cleanroomv1
 	}
 }
 
+func resolveExecutionLauncher(launcher cleanroomv1.ExecutionLauncher) (string, error) {
+	switch launcher {
+	case cleanroomv1.ExecutionLauncher_EXECUTION_LAUNCHER_UNSPECIFIED:
+		return backend.ExecLauncherAuto, nil
+	case cleanroomv1.ExecutionLauncher_EXECUTION_LAUNCHER_DIRECT:
+		return backend.ExecLauncherDirect, nil
+	case cleanroomv1.ExecutionLauncher_EXECUTION_LAUNCHER_SYSTEMD:
+		return backend.ExecLauncherSystemd, nil
+	default:
+		return "", fmt.Errorf("unsupported execution launcher %q", launcher.String())
+	}
+}
+
+func resolveChangeCapture(capture cleanroomv1.ExecutionChangeCapture) (string, error) {
+	switch capture {
+	case cleanroomv1.ExecutionChangeCapture_EXECUTION_CHANGE_CAPTURE_UNSPECIFIED:
+		return backend.ChangeCaptureNone, nil
+	case cleanroomv1.ExecutionChangeCapture_EXECUTION_CHANGE_CAPTURE_MANIFEST:
+		return backend.ChangeCaptureManifest, nil
+	case cleanroomv1.ExecutionChangeCapture_EXECUTION_CHANGE_CAPTURE_ARCHIVE:
+		return backend.ChangeCaptureArchive, nil
+	default:
+		return "", fmt.Errorf("unsupported execution change capture %q", capture.String())
+	}
+}
+
 func newSessionToken() (string, error) {
 	buf := make([]byte, 24)
 	if _, err := rand.Read(buf); err != nil {
@@ -1740,6 +2323,8 @@ func (s *Service) recordSandboxEventLocked(sb *sandboxState, status cleanroomv1.
 		OccurredAt: timestamppb.New(now),
 	}
 	sb.EventHistory = appendBounded(sb.EventHistory, event, maxRetainedSandboxEvents)
+	s.publishSandboxEventLocked(event)
+	s.shareSandboxLocked(sb)
 
 	for id, ch := range sb.EventSubscribers {
 		select {
@@ -1765,6 +2350,9 @@ func (s *Service) recordExecutionEventLocked(ex *executionState, event *cleanroo
 		event.OccurredAt = timestamppb.Now()
 	}
 	ex.EventHistory = appendBounded(ex.EventHistory, event, maxRetainedExecutionEvents)
+	s.publishExecutionEventLocked(event)
+	s.shipExecutionEventLocked(event)
+	s.shareExecutionLocked(ex, event)
 
 	for id, ch := range ex.EventSubscribers {
 		select {
@@ -1803,12 +2391,19 @@ func (s *Service) finalizeExecutionInternalLocked(ex *executionState, status cle
 		ExecutionId: ex.ID,
 		Status:      ex.Status,
 		Payload: &cleanroomv1.ExecutionStreamEvent_Exit{Exit: &cleanroomv1.ExecutionExit{
-			ExitCode: ex.ExitCode,
-			Status:   ex.Status,
-			Message:  exitMessage,
+			ExitCode:      ex.ExitCode,
+			Status:        ex.Status,
+			Message:       exitMessage,
+			Metadata:      executionExitMetadata(ex.ExitMetadata),
+			Artifacts:     cloneArtifacts(ex.Artifacts),
+			FailureReason: ex.FailureReason,
+			Timings:       executionTimings(ex.Timings),
+			TestResults:   ex.TestResults,
+			Retriable:     ex.Retriable,
 		}},
 		OccurredAt: timestamppb.New(finished),
 	})
+	s.recordExecutionUsageLocked(ex, finished)
 	closeExecutionDoneLocked(ex)
 	s.clearInteractiveExecutionStateLocked(executionKey(ex.SandboxID, ex.ID))
 	if prune {
@@ -1909,6 +2504,7 @@ func mergeBackendConfig(backendName string, opts executionOptions, cfg runtimeco
 	out := backend.FirecrackerConfig{
 		BinaryPath:           cfg.Backends.Firecracker.BinaryPath,
 		KernelImagePath:      cfg.Backends.Firecracker.KernelImage,
+		KernelSource:         cfg.Backends.Firecracker.KernelSource.Source(),
 		RootFSPath:           cfg.Backends.Firecracker.RootFS,
 		DockerStartupSeconds: cfg.Backends.Firecracker.Services.Docker.StartupTimeoutSeconds,
 		DockerStorageDriver:  cfg.Backends.Firecracker.Services.Docker.StorageDriver,
@@ -1920,10 +2516,23 @@ func mergeBackendConfig(backendName string, opts executionOptions, cfg runtimeco
 		GuestCID:             cfg.Backends.Firecracker.GuestCID,
 		GuestPort:            cfg.Backends.Firecracker.GuestPort,
 		LaunchSeconds:        cfg.Backends.Firecracker.LaunchSeconds,
+		CPUTemplate:          cfg.Backends.Firecracker.CPUTemplate,
+		NetworkPool:          cfg.Backends.Firecracker.NetworkPool,
+		MTU:                  cfg.Backends.Firecracker.MTU,
+		EgressMbps:           cfg.Backends.Firecracker.EgressMbps,
+		IngressMbps:          cfg.Backends.Firecracker.IngressMbps,
+		DiskIOPS:             cfg.Backends.Firecracker.DiskIOPS,
+		DiskMiBps:            cfg.Backends.Firecracker.DiskMiBps,
+		NetworkNamespaces:    cfg.Backends.Firecracker.NetworkNamespaces,
+		ScratchFiles:         cfg.Backends.Firecracker.ScratchFiles,
+		SMT:                  cfg.Backends.Firecracker.SMT,
+		SocketDir:            cfg.SocketDir,
 	}
 	if backendName == "darwin-vz" {
 		out.KernelImagePath = cfg.Backends.DarwinVZ.KernelImage
+		out.KernelSource = cfg.Backends.DarwinVZ.KernelSource.Source()
 		out.RootFSPath = cfg.Backends.DarwinVZ.RootFS
+		out.RootFSSource = cfg.Backends.DarwinVZ.RootFSSource.Source()
 		out.DockerStartupSeconds = cfg.Backends.DarwinVZ.Services.Docker.StartupTimeoutSeconds
 		out.DockerStorageDriver = cfg.Backends.DarwinVZ.Services.Docker.StorageDriver
 		out.DockerIPTables = cfg.Backends.DarwinVZ.Services.Docker.IPTables
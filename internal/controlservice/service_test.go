@@ -14,6 +14,7 @@ import (
 	"github.com/buildkite/cleanroom/internal/policy"
 	"github.com/buildkite/cleanroom/internal/runtimeconfig"
 	"go.jetify.com/typeid"
+	"google.golang.org/protobuf/proto"
 )
 
 type stubAdapter struct {
@@ -23,6 +24,8 @@ type stubAdapter struct {
 	provisionFn    func(context.Context, backend.ProvisionRequest) error
 	terminateFn    func(context.Context, string) error
 	downloadFn     func(context.Context, string, string, int64) ([]byte, error)
+	commitFn       func(context.Context, string, string) (string, error)
+	manifestFn     func(context.Context, string) ([]byte, error)
 	req            backend.RunRequest
 	provisionReq   backend.ProvisionRequest
 	runCalls       int
@@ -116,6 +119,20 @@ func (s *stubAdapter) DownloadSandboxFile(ctx context.Context, sandboxID, path s
 	return nil, errors.New("download not configured")
 }
 
+func (s *stubAdapter) CommitSandbox(ctx context.Context, sandboxID, ref string) (string, error) {
+	if s.commitFn != nil {
+		return s.commitFn(ctx, sandboxID, ref)
+	}
+	return "", errors.New("commit not configured")
+}
+
+func (s *stubAdapter) TakeArtifactManifest(ctx context.Context, sandboxID string, _ int64) ([]byte, error) {
+	if s.manifestFn != nil {
+		return s.manifestFn(ctx, sandboxID)
+	}
+	return nil, nil
+}
+
 type stubLoader struct {
 	compiled *policy.CompiledPolicy
 	source   string
@@ -230,6 +247,141 @@ func TestExecutionStreamIncludesExitEvent(t *testing.T) {
 	}
 }
 
+func TestExecutionExitCarriesGuestMetadataAndTimings(t *testing.T) {
+	adapter := &stubAdapter{
+		result: &backend.RunResult{
+			ExitCode: 143,
+			ExitMetadata: &backend.ExitMetadata{
+				UserCPU:     120 * time.Millisecond,
+				SystemCPU:   30 * time.Millisecond,
+				Wall:        2 * time.Second,
+				MaxRSSBytes: 8 << 20,
+				Signal:      15,
+				SignalName:  "SIGTERM",
+			},
+			Timings: &backend.RunTimings{
+				VMReady:       850 * time.Millisecond,
+				VsockWait:     40 * time.Millisecond,
+				GuestExec:     2 * time.Second,
+				Total:         3 * time.Second,
+				ImageCacheHit: true,
+			},
+		},
+	}
+	svc := newTestService(adapter)
+
+	createSandboxResp, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sandboxID := createSandboxResp.GetSandbox().GetSandboxId()
+	createExecutionResp, err := svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   []string{"sleep", "60"},
+	})
+	if err != nil {
+		t.Fatalf("CreateExecution returned error: %v", err)
+	}
+	executionID := createExecutionResp.GetExecution().GetExecutionId()
+
+	history, updates, done, unsubscribe, err := svc.SubscribeExecutionEvents(sandboxID, executionID)
+	if err != nil {
+		t.Fatalf("SubscribeExecutionEvents returned error: %v", err)
+	}
+	defer unsubscribe()
+
+	var exit *cleanroomv1.ExecutionExit
+	for _, event := range collectExecutionEvents(t, history, updates, done) {
+		if payload, ok := event.Payload.(*cleanroomv1.ExecutionStreamEvent_Exit); ok {
+			exit = payload.Exit
+		}
+	}
+	want := &cleanroomv1.ExecutionExitMetadata{
+		UserCpuMs:   120,
+		SystemCpuMs: 30,
+		MaxRssBytes: 8 << 20,
+		WallMs:      2000,
+		Signaled:    true,
+		Signal:      15,
+		SignalName:  "SIGTERM",
+	}
+	if got := exit.GetMetadata(); !proto.Equal(got, want) {
+		t.Fatalf("unexpected exit metadata: got %v want %v", got, want)
+	}
+
+	getResp, err := svc.GetExecution(context.Background(), &cleanroomv1.GetExecutionRequest{
+		SandboxId:   sandboxID,
+		ExecutionId: executionID,
+	})
+	if err != nil {
+		t.Fatalf("GetExecution returned error: %v", err)
+	}
+	if got := getResp.GetExecution().GetExitMetadata(); !proto.Equal(got, want) {
+		t.Fatalf("unexpected execution exit metadata: got %v want %v", got, want)
+	}
+	wantTimings := &cleanroomv1.ExecutionTimings{VmReadyMs: 850, VsockWaitMs: 40, GuestExecMs: 2000, TotalMs: 3000, ImageCacheHit: true}
+	if got := exit.GetTimings(); !proto.Equal(got, wantTimings) {
+		t.Fatalf("unexpected exit timings: got %v want %v", got, wantTimings)
+	}
+	if got := getResp.GetExecution().GetTimings(); !proto.Equal(got, wantTimings) {
+		t.Fatalf("unexpected execution timings: got %v want %v", got, wantTimings)
+	}
+}
+
+func TestExecutionGuestOOMFailsWithTypedReason(t *testing.T) {
+	adapter := &stubAdapter{
+		runFn: func(context.Context, backend.RunRequest) (*backend.RunResult, error) {
+			return nil, &backend.GuestFailure{
+				Reason:    backend.GuestFailureOOM,
+				Excerpt:   "Out of memory: Killed process 93 (cleanroom-guest)",
+				MemoryMiB: 512,
+				Err:       errors.New("decode guest exec response: EOF"),
+			}
+		},
+	}
+	svc := newTestService(adapter)
+
+	createSandboxResp, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sandboxID := createSandboxResp.GetSandbox().GetSandboxId()
+	createExecutionResp, err := svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   []string{"node", "build.js"},
+	})
+	if err != nil {
+		t.Fatalf("CreateExecution returned error: %v", err)
+	}
+	executionID := createExecutionResp.GetExecution().GetExecutionId()
+
+	history, updates, done, unsubscribe, err := svc.SubscribeExecutionEvents(sandboxID, executionID)
+	if err != nil {
+		t.Fatalf("SubscribeExecutionEvents returned error: %v", err)
+	}
+	defer unsubscribe()
+
+	var exit *cleanroomv1.ExecutionExit
+	var stderr strings.Builder
+	for _, event := range collectExecutionEvents(t, history, updates, done) {
+		switch payload := event.Payload.(type) {
+		case *cleanroomv1.ExecutionStreamEvent_Stderr:
+			stderr.Write(payload.Stderr)
+		case *cleanroomv1.ExecutionStreamEvent_Exit:
+			exit = payload.Exit
+		}
+	}
+	if got, want := exit.GetFailureReason(), cleanroomv1.ExecutionFailureReason_EXECUTION_FAILURE_REASON_GUEST_OOM; got != want {
+		t.Fatalf("unexpected failure reason: got %v want %v", got, want)
+	}
+	if got, want := exit.GetExitCode(), int32(137); got != want {
+		t.Fatalf("unexpected exit code: got %d want %d", got, want)
+	}
+	if !strings.Contains(stderr.String(), "guest OOM-killed (memory_mib=512)") {
+		t.Fatalf("expected OOM summary on stderr, got %q", stderr.String())
+	}
+}
+
 func TestCancelExecutionTransitionsToCanceled(t *testing.T) {
 	started := make(chan struct{}, 1)
 	adapter := &stubAdapter{
@@ -646,6 +798,63 @@ func TestCreateExecutionRejectsWhileSandboxFileDownloadInProgress(t *testing.T)
 	}
 }
 
+func TestCommitSandboxHoldsSandboxUntilPushed(t *testing.T) {
+	commitStarted := make(chan struct{}, 1)
+	allowCommitFinish := make(chan struct{})
+	commitDone := make(chan *cleanroomv1.CommitSandboxResponse, 1)
+
+	var gotRef string
+	adapter := &stubAdapter{
+		commitFn: func(_ context.Context, _, ref string) (string, error) {
+			gotRef = ref
+			commitStarted <- struct{}{}
+			<-allowCommitFinish
+			return ref + "@sha256:abc", nil
+		},
+	}
+	svc := newTestService(adapter)
+
+	createSandboxResp, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sandboxID := createSandboxResp.GetSandbox().GetSandboxId()
+
+	go func() {
+		resp, err := svc.CommitSandbox(context.Background(), &cleanroomv1.CommitSandboxRequest{
+			SandboxId: sandboxID,
+			Ref:       " ghcr.io/acme/img:ci ",
+		})
+		if err != nil {
+			t.Errorf("CommitSandbox returned error: %v", err)
+		}
+		commitDone <- resp
+	}()
+
+	select {
+	case <-commitStarted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for commit to start")
+	}
+
+	_, err = svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+		SandboxId: sandboxID,
+		Command:   []string{"echo", "hi"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "sandbox_busy") || !strings.Contains(err.Error(), "commit") {
+		t.Fatalf("expected sandbox_busy error naming the commit, got: %v", err)
+	}
+
+	close(allowCommitFinish)
+	resp := <-commitDone
+	if gotRef != "ghcr.io/acme/img:ci" {
+		t.Fatalf("unexpected ref passed to backend: %q", gotRef)
+	}
+	if got, want := resp.GetImageRef(), "ghcr.io/acme/img:ci@sha256:abc"; got != want {
+		t.Fatalf("unexpected image ref: got %q want %q", got, want)
+	}
+}
+
 func TestDownloadSandboxFilePreservesPathWhitespace(t *testing.T) {
 	expectedPath := "/home/sprite/artifacts/result.txt "
 	adapter := &stubAdapter{
@@ -1003,6 +1212,91 @@ func TestExecutionAttachIOWaitsForDelayedAttachRegistration(t *testing.T) {
 	}
 }
 
+func TestBatchExecutionStdinRequiresStdinOption(t *testing.T) {
+	started := make(chan struct{}, 1)
+	runStdin := make(chan bool, 1)
+	closed := make(chan struct{}, 1)
+	adapter := &stubAdapter{
+		runStreamFn: func(ctx context.Context, req backend.RunRequest, stream backend.OutputStream) (*backend.RunResult, error) {
+			runStdin <- req.Stdin
+			if stream.OnAttach != nil {
+				stream.OnAttach(backend.AttachIO{
+					WriteStdin: func([]byte) error { return nil },
+					CloseStdin: func() error {
+						closed <- struct{}{}
+						return nil
+					},
+				})
+			}
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	svc := newTestService(adapter)
+
+	createSandboxResp, err := svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sandboxID := createSandboxResp.GetSandbox().GetSandboxId()
+
+	for _, stdin := range []bool{false, true} {
+		createExecutionResp, err := svc.CreateExecution(context.Background(), &cleanroomv1.CreateExecutionRequest{
+			SandboxId: sandboxID,
+			Command:   []string{"cat"},
+			Options:   &cleanroomv1.ExecutionOptions{Stdin: stdin},
+		})
+		if err != nil {
+			t.Fatalf("CreateExecution returned error: %v", err)
+		}
+		executionID := createExecutionResp.GetExecution().GetExecutionId()
+
+		select {
+		case <-started:
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for execution to start")
+		}
+		if got := <-runStdin; got != stdin {
+			t.Fatalf("unexpected run request stdin: got %v want %v", got, stdin)
+		}
+
+		writeErr := svc.WriteExecutionStdin(sandboxID, executionID, []byte("select 1;\n"))
+		closeErr := svc.CloseExecutionStdin(sandboxID, executionID)
+		if stdin {
+			if writeErr != nil || closeErr != nil {
+				t.Fatalf("unexpected stdin errors: write %v close %v", writeErr, closeErr)
+			}
+			select {
+			case <-closed:
+			case <-time.After(2 * time.Second):
+				t.Fatal("timed out waiting for stdin close")
+			}
+		} else {
+			if !errors.Is(writeErr, ErrExecutionStdinNotOpen) {
+				t.Fatalf("unexpected write error: got %v want %v", writeErr, ErrExecutionStdinNotOpen)
+			}
+			if !errors.Is(closeErr, ErrExecutionStdinNotOpen) {
+				t.Fatalf("unexpected close error: got %v want %v", closeErr, ErrExecutionStdinNotOpen)
+			}
+		}
+
+		if _, err := svc.CancelExecution(context.Background(), &cleanroomv1.CancelExecutionRequest{
+			SandboxId:   sandboxID,
+			ExecutionId: executionID,
+			Signal:      2,
+		}); err != nil {
+			t.Fatalf("CancelExecution returned error: %v", err)
+		}
+		if _, err := svc.WaitExecution(context.Background(), sandboxID, executionID); err != nil {
+			t.Fatalf("WaitExecution returned error: %v", err)
+		}
+	}
+}
+
 func TestExecutionAttachIOUnsupportedWhenBackendDoesNotExposeHandlers(t *testing.T) {
 	started := make(chan struct{}, 1)
 	adapter := &stubAdapter{
@@ -1911,3 +2205,54 @@ func collectExecutionEvents(t *testing.T, history []*cleanroomv1.ExecutionStream
 		}
 	}
 }
+
+func TestCreateSandboxNamesAreUniqueUntilTerminated(t *testing.T) {
+	t.Parallel()
+
+	svc := newTestService(&stubAdapter{})
+	create := func(name string) (*cleanroomv1.CreateSandboxResponse, error) {
+		return svc.CreateSandbox(context.Background(), &cleanroomv1.CreateSandboxRequest{
+			Policy: testPolicy(),
+			Name:   name,
+			Labels: map[string]string{"branch": "main"},
+		})
+	}
+
+	first, err := create("feature-x")
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	if got := first.GetSandbox().GetName(); got != "feature-x" {
+		t.Fatalf("unexpected sandbox name: got %q want %q", got, "feature-x")
+	}
+	if got := first.GetSandbox().GetLabels()["branch"]; got != "main" {
+		t.Fatalf("unexpected branch label: got %q want %q", got, "main")
+	}
+
+	if _, err := create("feature-x"); !errors.Is(err, ErrSandboxNameTaken) {
+		t.Fatalf("expected ErrSandboxNameTaken, got %v", err)
+	}
+
+	if _, err := svc.TerminateSandbox(context.Background(), &cleanroomv1.TerminateSandboxRequest{SandboxId: first.GetSandbox().GetSandboxId()}); err != nil {
+		t.Fatalf("TerminateSandbox returned error: %v", err)
+	}
+	if _, err := create("feature-x"); err != nil {
+		t.Fatalf("expected name to be reusable after terminate, got %v", err)
+	}
+}
+
+func TestCreateSandboxRejectsInvalidNamesAndLabels(t *testing.T) {
+	t.Parallel()
+
+	svc := newTestService(&stubAdapter{})
+	for _, req := range []*cleanroomv1.CreateSandboxRequest{
+		{Policy: testPolicy(), Name: "-leading-dash"},
+		{Policy: testPolicy(), Name: "has space"},
+		{Policy: testPolicy(), Labels: map[string]string{"bad key": "v"}},
+		{Policy: testPolicy(), Labels: map[string]string{"k": strings.Repeat("v", maxSandboxLabelValueBytes+1)}},
+	} {
+		if _, err := svc.CreateSandbox(context.Background(), req); err == nil || !strings.Contains(err.Error(), "invalid") {
+			t.Fatalf("expected invalid argument error for name=%q labels=%v, got %v", req.GetName(), req.GetLabels(), err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package controlservice
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/sharedstate"
+)
+
+// SharedState shares sandbox and execution records with the other serve
+// instances behind a load balancer. *sharedstate.State implements it. The
+// Put and Delete methods are called with the service lock held, so they
+// must not block.
+type SharedState interface {
+	HostID() string
+	PutSandbox(sb *cleanroomv1.Sandbox)
+	PutExecution(ex *cleanroomv1.Execution)
+	DeleteSandbox(sandboxID string)
+	DeleteExecution(sandboxID, executionID string)
+	Sandbox(ctx context.Context, sandboxID string) (sharedstate.SandboxRecord, error)
+	Sandboxes(ctx context.Context) ([]sharedstate.SandboxRecord, error)
+	Execution(ctx context.Context, sandboxID, executionID string) (sharedstate.ExecutionRecord, error)
+}
+
+func (s *Service) shareSandboxLocked(sb *sandboxState) {
+	if s.Shared == nil {
+		return
+	}
+	s.Shared.PutSandbox(cloneSandboxLocked(sb))
+}
+
+// shareExecutionLocked shares ex after event. Output chunks change nothing
+// in the record, so they are not written.
+func (s *Service) shareExecutionLocked(ex *executionState, event *cleanroomv1.ExecutionStreamEvent) {
+	if s.Shared == nil {
+		return
+	}
+	switch event.GetPayload().(type) {
+	case *cleanroomv1.ExecutionStreamEvent_Stdout, *cleanroomv1.ExecutionStreamEvent_Stderr:
+		return
+	}
+	s.Shared.PutExecution(cloneExecutionLocked(ex))
+}
+
+// remoteSandbox returns the shared record of a sandbox another instance
+// runs.
+func (s *Service) remoteSandbox(ctx context.Context, sandboxID string) (sharedstate.SandboxRecord, bool) {
+	if s.Shared == nil {
+		return sharedstate.SandboxRecord{}, false
+	}
+	rec, err := s.Shared.Sandbox(ctx, sandboxID)
+	if err != nil {
+		if !errors.Is(err, sharedstate.ErrNotFound) {
+			if logger := s.logger(ctx); logger != nil {
+				logger.Warn("read shared sandbox record", "sandbox_id", sandboxID, "error", err)
+			}
+		}
+		return sharedstate.SandboxRecord{}, false
+	}
+	if rec.Host.ID == s.Shared.HostID() {
+		// This instance's own record, left from before a restart: its VM
+		// is gone.
+		return sharedstate.SandboxRecord{}, false
+	}
+	return rec, true
+}
+
+// SandboxOwner returns the control API URL of the other serve instance
+// running sandboxID, when shared state says one does. Calls that need the
+// sandbox's VM are forwarded there.
+func (s *Service) SandboxOwner(ctx context.Context, sandboxID string) (string, bool) {
+	sandboxID = strings.TrimSpace(sandboxID)
+	if sandboxID == "" {
+		return "", false
+	}
+	s.mu.RLock()
+	_, local := s.sandboxes[sandboxID]
+	s.mu.RUnlock()
+	if local {
+		return "", false
+	}
+	rec, ok := s.remoteSandbox(ctx, sandboxID)
+	if !ok || rec.Host.URL == "" {
+		return "", false
+	}
+	return rec.Host.URL, true
+}
+
+// remoteSandboxes returns the sandboxes other instances run.
+func (s *Service) remoteSandboxes(ctx context.Context) []*cleanroomv1.Sandbox {
+	if s.Shared == nil {
+		return nil
+	}
+	records, err := s.Shared.Sandboxes(ctx)
+	if err != nil {
+		if logger := s.logger(ctx); logger != nil {
+			logger.Warn("list shared sandbox records", "error", err)
+		}
+		return nil
+	}
+	var out []*cleanroomv1.Sandbox
+	for _, rec := range records {
+		if rec.Host.ID != s.Shared.HostID() {
+			out = append(out, rec.Sandbox)
+		}
+	}
+	return out
+}
+
+// remoteExecution returns an execution in a sandbox another instance runs.
+func (s *Service) remoteExecution(ctx context.Context, sandboxID, executionID string) (*cleanroomv1.Execution, bool) {
+	if s.Shared == nil {
+		return nil, false
+	}
+	rec, err := s.Shared.Execution(ctx, sandboxID, executionID)
+	if err != nil {
+		if !errors.Is(err, sharedstate.ErrNotFound) {
+			if logger := s.logger(ctx); logger != nil {
+				logger.Warn("read shared execution record", "sandbox_id", sandboxID, "execution_id", executionID, "error", err)
+			}
+		}
+		return nil, false
+	}
+	if rec.Host.ID == s.Shared.HostID() {
+		return nil, false
+	}
+	return rec.Execution, true
+}
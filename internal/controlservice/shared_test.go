@@ -0,0 +1,154 @@
+package controlservice
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/sharedstate"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// memoryShared is a SharedState holding every host's records in memory.
+type memoryShared struct {
+	host       sharedstate.Host
+	mu         sync.Mutex
+	sandboxes  map[string]sharedstate.SandboxRecord
+	executions map[string]sharedstate.ExecutionRecord
+}
+
+func newMemoryShared(hostID string) *memoryShared {
+	return &memoryShared{
+		host:       sharedstate.Host{ID: hostID, URL: "https://" + hostID + ":7777"},
+		sandboxes:  map[string]sharedstate.SandboxRecord{},
+		executions: map[string]sharedstate.ExecutionRecord{},
+	}
+}
+
+func (m *memoryShared) HostID() string { return m.host.ID }
+
+func (m *memoryShared) PutSandbox(sb *cleanroomv1.Sandbox) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sandboxes[sb.GetSandboxId()] = sharedstate.SandboxRecord{Host: m.host, Sandbox: sb}
+}
+
+func (m *memoryShared) PutExecution(ex *cleanroomv1.Execution) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.executions[ex.GetSandboxId()+"/"+ex.GetExecutionId()] = sharedstate.ExecutionRecord{Host: m.host, Execution: ex}
+}
+
+func (m *memoryShared) DeleteSandbox(sandboxID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sandboxes, sandboxID)
+}
+
+func (m *memoryShared) DeleteExecution(sandboxID, executionID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.executions, sandboxID+"/"+executionID)
+}
+
+func (m *memoryShared) Sandbox(_ context.Context, sandboxID string) (sharedstate.SandboxRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.sandboxes[sandboxID]
+	if !ok {
+		return rec, fmt.Errorf("sandbox %q: %w", sandboxID, sharedstate.ErrNotFound)
+	}
+	return rec, nil
+}
+
+func (m *memoryShared) Sandboxes(context.Context) ([]sharedstate.SandboxRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []sharedstate.SandboxRecord
+	for _, rec := range m.sandboxes {
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+func (m *memoryShared) Execution(_ context.Context, sandboxID, executionID string) (sharedstate.ExecutionRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.executions[sandboxID+"/"+executionID]
+	if !ok {
+		return rec, fmt.Errorf("execution %q: %w", executionID, sharedstate.ErrNotFound)
+	}
+	return rec, nil
+}
+
+func TestSharedStateAnswersForOtherHosts(t *testing.T) {
+	adapter := &stubAdapter{
+		runFn: func(_ context.Context, req backend.RunRequest) (*backend.RunResult, error) {
+			return &backend.RunResult{RunID: req.RunID, ExitCode: 0}, nil
+		},
+	}
+	shared := newMemoryShared("host-a")
+	svc := newTestService(adapter)
+	svc.Shared = shared
+	ctx := context.Background()
+
+	created, err := svc.CreateSandbox(ctx, &cleanroomv1.CreateSandboxRequest{Policy: testPolicy()})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sandboxID := created.GetSandbox().GetSandboxId()
+	execution, err := svc.CreateExecution(ctx, &cleanroomv1.CreateExecutionRequest{SandboxId: sandboxID, Command: []string{"true"}})
+	if err != nil {
+		t.Fatalf("CreateExecution returned error: %v", err)
+	}
+	executionID := execution.GetExecution().GetExecutionId()
+	waitForExecutionStatus(t, svc, sandboxID, executionID)
+
+	// A second instance on host-b sees host-a's records, and names host-a
+	// as the place to run anything against the sandbox.
+	other := newTestService(adapter)
+	other.Shared = &memoryShared{
+		host:       sharedstate.Host{ID: "host-b", URL: "https://host-b:7777"},
+		sandboxes:  shared.sandboxes,
+		executions: shared.executions,
+	}
+	got, err := other.GetSandbox(ctx, &cleanroomv1.GetSandboxRequest{SandboxId: sandboxID})
+	if err != nil {
+		t.Fatalf("GetSandbox on the other host returned error: %v", err)
+	}
+	if got.GetSandbox().GetStatus() != cleanroomv1.SandboxStatus_SANDBOX_STATUS_READY {
+		t.Fatalf("expected the shared sandbox to be ready, got %v", got.GetSandbox())
+	}
+	ex, err := other.GetExecution(ctx, &cleanroomv1.GetExecutionRequest{SandboxId: sandboxID, ExecutionId: executionID})
+	if err != nil {
+		t.Fatalf("GetExecution on the other host returned error: %v", err)
+	}
+	if ex.GetExecution().GetStatus() != cleanroomv1.ExecutionStatus_EXECUTION_STATUS_SUCCEEDED {
+		t.Fatalf("expected the shared execution to have succeeded, got %v", ex.GetExecution())
+	}
+	if owner, ok := other.SandboxOwner(ctx, sandboxID); !ok || owner != "https://host-a:7777" {
+		t.Fatalf("expected host-a to own the sandbox, got %q %v", owner, ok)
+	}
+	if _, ok := svc.SandboxOwner(ctx, sandboxID); ok {
+		t.Fatal("expected the running host not to forward its own sandbox")
+	}
+
+	shared.PutSandbox(&cleanroomv1.Sandbox{SandboxId: "stale", CreatedAt: timestamppb.Now()})
+	list, err := svc.ListSandboxes(ctx, &cleanroomv1.ListSandboxesRequest{})
+	if err != nil {
+		t.Fatalf("ListSandboxes returned error: %v", err)
+	}
+	if len(list.GetSandboxes()) != 1 {
+		t.Fatalf("expected only the local sandbox, not this host's stale record, got %v", list.GetSandboxes())
+	}
+	list, err = other.ListSandboxes(ctx, &cleanroomv1.ListSandboxesRequest{})
+	if err != nil {
+		t.Fatalf("ListSandboxes on the other host returned error: %v", err)
+	}
+	if len(list.GetSandboxes()) != 2 || list.GetSandboxes()[0].GetSandboxId() != sandboxID {
+		t.Fatalf("expected host-a's sandboxes, oldest first, got %v", list.GetSandboxes())
+	}
+}
@@ -0,0 +1,190 @@
+package controlservice
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/usage"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// UsageStore keeps the resources finished sandboxes and executions
+// reserved.
+type UsageStore interface {
+	Add(ctx context.Context, r usage.Record) error
+	Records(ctx context.Context, kind string, since time.Time) ([]usage.Record, error)
+}
+
+// ErrUsageDisabled is returned by GetUsage when the server keeps no usage
+// records.
+var ErrUsageDisabled = errors.New("usage accounting is not enabled on this server")
+
+// defaultUsageWindow is how far back GetUsage reports without a since.
+const defaultUsageWindow = 30 * 24 * time.Hour
+
+// sandboxUsageLocked is sb's usage from creation until ended.
+func sandboxUsageLocked(sb *sandboxState, ended time.Time) usage.Record {
+	return usage.Record{
+		Kind:      usage.KindSandbox,
+		ID:        sb.ID,
+		SandboxID: sb.ID,
+		Namespace: namespaceOrDefault(sb.Namespace),
+		Identity:  sb.Identity,
+		Backend:   sb.Backend,
+		Labels:    maps.Clone(sb.Labels),
+		StartedAt: sb.CreatedAt,
+		EndedAt:   ended,
+		VCPUs:     sb.Firecracker.VCPUs,
+		MemoryMiB: sb.Firecracker.MemoryMiB,
+		DiskMiB:   sb.Firecracker.DiskMiB,
+	}
+}
+
+// executionUsageLocked is ex's usage from its start until ended, attributed
+// to its sandbox's labels. sb is nil once the sandbox has been pruned.
+func executionUsageLocked(ex *executionState, sb *sandboxState, ended time.Time) usage.Record {
+	r := usage.Record{
+		Kind:      usage.KindExecution,
+		ID:        ex.ID,
+		SandboxID: ex.SandboxID,
+		Namespace: namespaceOrDefault(ex.Namespace),
+		Identity:  ex.Identity,
+		StartedAt: *ex.StartedAt,
+		EndedAt:   ended,
+	}
+	if sb != nil {
+		r.Backend = sb.Backend
+		r.Labels = maps.Clone(sb.Labels)
+		r.VCPUs = sb.Firecracker.VCPUs
+		r.MemoryMiB = sb.Firecracker.MemoryMiB
+		r.DiskMiB = sb.Firecracker.DiskMiB
+	}
+	if env := ex.Environment; env != nil {
+		r.Backend = cmp.Or(env.GetBackend(), r.Backend)
+		r.VCPUs = cmp.Or(env.GetVcpus(), r.VCPUs)
+		r.MemoryMiB = cmp.Or(env.GetMemoryMib(), r.MemoryMiB)
+		r.DiskMiB = cmp.Or(env.GetDiskMib(), r.DiskMiB)
+	}
+	return r
+}
+
+// recordUsageLocked saves r in the background, so the database write does
+// not hold s.mu.
+func (s *Service) recordUsageLocked(r usage.Record) {
+	if s.Usage == nil {
+		return
+	}
+	store, logger := s.Usage, s.Logger
+	go func() {
+		if err := store.Add(context.Background(), r); err != nil && logger != nil {
+			logger.Warn("record usage failed", "kind", r.Kind, "id", r.ID, "error", err)
+		}
+	}()
+}
+
+// recordExecutionUsageLocked records the usage of an execution that ran
+// and has just finished.
+func (s *Service) recordExecutionUsageLocked(ex *executionState, finished time.Time) {
+	if ex.StartedAt == nil {
+		return
+	}
+	s.recordUsageLocked(executionUsageLocked(ex, s.sandboxes[ex.SandboxID], finished))
+}
+
+// liveUsageLocked returns the usage so far of the sandboxes or executions
+// of kind that are still running.
+func (s *Service) liveUsageLocked(kind string, now time.Time) []usage.Record {
+	var out []usage.Record
+	switch kind {
+	case usage.KindSandbox:
+		for _, sb := range s.sandboxes {
+			if sb.Status != cleanroomv1.SandboxStatus_SANDBOX_STATUS_STOPPED {
+				out = append(out, sandboxUsageLocked(sb, now))
+			}
+		}
+	case usage.KindExecution:
+		for _, ex := range s.executions {
+			if ex.StartedAt != nil && ex.FinishedAt == nil {
+				out = append(out, executionUsageLocked(ex, s.sandboxes[ex.SandboxID], now))
+			}
+		}
+	}
+	return out
+}
+
+// GetUsage sums the resources sandboxes or executions reserved since a
+// time, including those still running. Callers other than admins only see
+// their own namespace.
+func (s *Service) GetUsage(ctx context.Context, req *cleanroomv1.GetUsageRequest) (*cleanroomv1.GetUsageResponse, error) {
+	if req == nil {
+		return nil, errors.New("missing request")
+	}
+	if s.Usage == nil {
+		return nil, ErrUsageDisabled
+	}
+	groupBy, err := usage.ParseGroupBy(req.GetGroupBy())
+	if err != nil {
+		return nil, err
+	}
+	kind := cmp.Or(strings.TrimSpace(req.GetKind()), usage.KindSandbox)
+	if kind != usage.KindSandbox && kind != usage.KindExecution {
+		return nil, fmt.Errorf("invalid usage kind %q: want %s or %s", kind, usage.KindSandbox, usage.KindExecution)
+	}
+	until := time.Now().UTC()
+	since := until.Add(-defaultUsageWindow)
+	if req.GetSince() != nil {
+		since = req.GetSince().AsTime()
+	}
+	if since.After(until) {
+		return nil, fmt.Errorf("invalid since %s: it is in the future", since.Format(time.RFC3339))
+	}
+
+	cfg := s.runtimeConfig()
+	namespace := strings.TrimSpace(req.GetNamespace())
+	if own, admin := callerNamespace(ctx, cfg.Namespaces); !admin {
+		if namespace != "" && namespace != own {
+			return nil, fmt.Errorf("%w: namespace %q is not the caller's namespace %q", ErrNamespaceDenied, namespace, own)
+		}
+		namespace = own
+	}
+
+	records, err := s.Usage.Records(ctx, kind, since)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	records = append(records, s.liveUsageLocked(kind, until)...)
+	s.mu.RUnlock()
+	if namespace != "" {
+		kept := records[:0]
+		for _, r := range records {
+			if r.Namespace == namespace {
+				kept = append(kept, r)
+			}
+		}
+		records = kept
+	}
+
+	resp := &cleanroomv1.GetUsageResponse{
+		Since:   timestamppb.New(since),
+		Until:   timestamppb.New(until),
+		GroupBy: groupBy,
+		Kind:    kind,
+	}
+	for _, row := range usage.Summarize(records, since, until, groupBy) {
+		resp.Rows = append(resp.Rows, &cleanroomv1.UsageRow{
+			Group:            row.Group,
+			Count:            row.Count,
+			VcpuSeconds:      row.VCPUSeconds,
+			MemoryMibSeconds: row.MemoryMiBSeconds,
+			DiskGbHours:      row.DiskGBHours,
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,157 @@
+package controlservice
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/buildkite/cleanroom/internal/runtimeconfig"
+	"github.com/buildkite/cleanroom/internal/usage"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type memoryUsageStore struct {
+	mu      sync.Mutex
+	records []usage.Record
+}
+
+func (m *memoryUsageStore) Add(_ context.Context, r usage.Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, r)
+	return nil
+}
+
+func (m *memoryUsageStore) Records(_ context.Context, kind string, since time.Time) ([]usage.Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []usage.Record
+	for _, r := range m.records {
+		if r.Kind == kind && !r.EndedAt.Before(since) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryUsageStore) waitFor(t *testing.T, kind string) usage.Record {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		records, _ := m.Records(context.Background(), kind, time.Time{})
+		if len(records) > 0 {
+			return records[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no %s usage was recorded", kind)
+	return usage.Record{}
+}
+
+func TestTerminatedSandboxRecordsUsage(t *testing.T) {
+	store := &memoryUsageStore{}
+	svc := newTestService(&stubAdapter{})
+	svc.Usage = store
+	ctx := WithCallerIdentity(context.Background(), "uid:1001")
+
+	created, err := svc.CreateSandbox(ctx, &cleanroomv1.CreateSandboxRequest{
+		Policy: testPolicy(),
+		Labels: map[string]string{"team": "payments"},
+	})
+	if err != nil {
+		t.Fatalf("CreateSandbox returned error: %v", err)
+	}
+	sandboxID := created.GetSandbox().GetSandboxId()
+	svc.mu.Lock()
+	sb := svc.sandboxes[sandboxID]
+	sb.CreatedAt = sb.CreatedAt.Add(-time.Hour)
+	sb.Firecracker.VCPUs = 2
+	sb.Firecracker.MemoryMiB = 1024
+	sb.Firecracker.DiskMiB = 2048
+	svc.mu.Unlock()
+
+	live, err := svc.GetUsage(ctx, &cleanroomv1.GetUsageRequest{GroupBy: []string{"label:team"}})
+	if err != nil {
+		t.Fatalf("GetUsage returned error: %v", err)
+	}
+	if len(live.GetRows()) != 1 || live.GetRows()[0].GetGroup()[0] != "payments" || live.GetRows()[0].GetVcpuSeconds() < 7200 {
+		t.Fatalf("expected the running sandbox's usage so far, got %+v", live.GetRows())
+	}
+
+	if _, err := svc.TerminateSandbox(ctx, &cleanroomv1.TerminateSandboxRequest{SandboxId: sandboxID}); err != nil {
+		t.Fatalf("TerminateSandbox returned error: %v", err)
+	}
+	record := store.waitFor(t, usage.KindSandbox)
+	if record.ID != sandboxID || record.Identity != "uid:1001" || record.Namespace != DefaultNamespace || record.Labels["team"] != "payments" {
+		t.Fatalf("unexpected usage attribution: %+v", record)
+	}
+	if record.VCPUs != 2 || record.MemoryMiB != 1024 || record.DiskMiB != 2048 || record.EndedAt.Sub(record.StartedAt) < time.Hour {
+		t.Fatalf("unexpected usage resources: %+v", record)
+	}
+
+	report, err := svc.GetUsage(ctx, &cleanroomv1.GetUsageRequest{GroupBy: []string{"identity"}})
+	if err != nil {
+		t.Fatalf("GetUsage returned error: %v", err)
+	}
+	if len(report.GetRows()) != 1 || report.GetRows()[0].GetCount() != 1 || report.GetRows()[0].GetGroup()[0] != "uid:1001" {
+		t.Fatalf("expected one sandbox counted once, got %+v", report.GetRows())
+	}
+	if got := report.GetRows()[0].GetDiskGbHours(); got < 2 || got > 2.1 {
+		t.Fatalf("expected about 2 disk GB-hours, got %v", got)
+	}
+}
+
+func TestGetUsageScopesToCallerNamespace(t *testing.T) {
+	store := &memoryUsageStore{}
+	now := time.Now().UTC()
+	for _, namespace := range []string{"team-a", "team-b"} {
+		_ = store.Add(context.Background(), usage.Record{
+			Kind: usage.KindSandbox, ID: namespace, SandboxID: namespace, Namespace: namespace,
+			StartedAt: now.Add(-2 * time.Hour), EndedAt: now.Add(-time.Hour), VCPUs: 1,
+		})
+	}
+	svc := newTestService(&stubAdapter{})
+	svc.Usage = store
+	svc.Config.Namespaces = runtimeconfig.Namespaces{
+		Identities: map[string]string{"uid:1001": "team-a"},
+		Admins:     []string{"uid:0"},
+	}
+	teamA := WithCallerIdentity(context.Background(), "uid:1001")
+	admin := WithCallerIdentity(context.Background(), "uid:0")
+
+	own, err := svc.GetUsage(teamA, &cleanroomv1.GetUsageRequest{GroupBy: []string{"namespace"}})
+	if err != nil {
+		t.Fatalf("GetUsage returned error: %v", err)
+	}
+	if len(own.GetRows()) != 1 || own.GetRows()[0].GetGroup()[0] != "team-a" {
+		t.Fatalf("expected only team-a's usage, got %+v", own.GetRows())
+	}
+	if _, err := svc.GetUsage(teamA, &cleanroomv1.GetUsageRequest{Namespace: "team-b"}); !errors.Is(err, ErrNamespaceDenied) {
+		t.Fatalf("expected another namespace to be denied, got %v", err)
+	}
+	all, err := svc.GetUsage(admin, &cleanroomv1.GetUsageRequest{GroupBy: []string{"namespace"}})
+	if err != nil {
+		t.Fatalf("GetUsage returned error: %v", err)
+	}
+	if len(all.GetRows()) != 2 {
+		t.Fatalf("expected admins to see every namespace, got %+v", all.GetRows())
+	}
+	recent, err := svc.GetUsage(admin, &cleanroomv1.GetUsageRequest{Since: timestamppb.New(now.Add(-30 * time.Minute))})
+	if err != nil {
+		t.Fatalf("GetUsage returned error: %v", err)
+	}
+	if len(recent.GetRows()) != 0 {
+		t.Fatalf("expected no usage in the last 30 minutes, got %+v", recent.GetRows())
+	}
+	if _, err := svc.GetUsage(admin, &cleanroomv1.GetUsageRequest{GroupBy: []string{"colour"}}); err == nil {
+		t.Fatal("expected an invalid group-by key to be rejected")
+	}
+
+	svc.Usage = nil
+	if _, err := svc.GetUsage(admin, &cleanroomv1.GetUsageRequest{}); !errors.Is(err, ErrUsageDisabled) {
+		t.Fatalf("expected usage to be disabled, got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package controlservice
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/backend"
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// SandboxVMStats is one sandbox's VM counters.
+type SandboxVMStats struct {
+	SandboxID string
+	Backend   string
+	Stats     backend.VMStats
+}
+
+// SandboxVMStats reads the VM counters of every sandbox that has not
+// stopped, from the backends that report them, ordered by sandbox ID.
+func (s *Service) SandboxVMStats(ctx context.Context) []SandboxVMStats {
+	s.mu.RLock()
+	backends := make(map[string]string)
+	for id, sb := range s.sandboxes {
+		if sb.Status != cleanroomv1.SandboxStatus_SANDBOX_STATUS_STOPPED {
+			backends[id] = sb.Backend
+		}
+	}
+	s.mu.RUnlock()
+
+	var out []SandboxVMStats
+	for name, adapter := range s.Backends {
+		reporter, ok := adapter.(backend.VMStatsAdapter)
+		if !ok {
+			continue
+		}
+		for id, stats := range reporter.SandboxVMStats(ctx) {
+			if backendName, ok := backends[id]; ok && backendName == name {
+				out = append(out, SandboxVMStats{SandboxID: id, Backend: name, Stats: stats})
+			}
+		}
+	}
+	slices.SortFunc(out, func(a, b SandboxVMStats) int { return strings.Compare(a.SandboxID, b.SandboxID) })
+	return out
+}
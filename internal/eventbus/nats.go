@@ -0,0 +1,351 @@
+// Package eventbus mirrors serve's sandbox and execution events to an
+// external message bus, so fleet-wide dashboards and autoscalers can follow
+// many hosts without polling each one.
+package eventbus
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	defaultNATSPort  = "4222"
+	natsDialTimeout  = 5 * time.Second
+	natsMaxBackoff   = 30 * time.Second
+	natsQueueLength  = 4096
+	natsClientName   = "cleanroom"
+	natsClientLang   = "go"
+	natsProtocolVers = 1
+)
+
+// NATS publishes to a NATS server over its core protocol. Publish never
+// blocks: messages are queued and written by a background goroutine that
+// reconnects with backoff. Messages are queued while the server is
+// unreachable; once the queue is full, further ones are dropped and counted,
+// as are messages larger than the server's max_payload.
+type NATS struct {
+	url    *url.URL
+	prefix string
+	logger *log.Logger
+
+	queue   chan natsMessage
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+	dropped atomic.Int64
+}
+
+type natsMessage struct {
+	subject string
+	data    []byte
+}
+
+// ValidateNATSURL checks that rawURL names a NATS server, as
+// nats://[user:pass@]host[:port] or tls://... for a TLS connection.
+func ValidateNATSURL(rawURL string) error {
+	_, err := parseNATSURL(rawURL)
+	return err
+}
+
+func parseNATSURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS URL: %w", err)
+	}
+	if (u.Scheme != "nats" && u.Scheme != "tls") || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid NATS URL %q: expected nats://host[:port] or tls://host[:port]", rawURL)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), defaultNATSPort)
+	}
+	return u, nil
+}
+
+// DialNATS starts publishing to the server at rawURL. Subjects passed to
+// Publish are prefixed with prefix and a dot. The connection is made in the
+// background, so an unreachable server does not stop serve from starting.
+func DialNATS(rawURL, prefix string, logger *log.Logger) (*NATS, error) {
+	u, err := parseNATSURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	n := &NATS{
+		url:     u,
+		prefix:  strings.TrimSuffix(strings.TrimSpace(prefix), "."),
+		logger:  logger,
+		queue:   make(chan natsMessage, natsQueueLength),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go n.run()
+	return n, nil
+}
+
+// Publish queues data for subject.
+func (n *NATS) Publish(subject string, data []byte) {
+	if n.prefix != "" {
+		subject = n.prefix + "." + subject
+	}
+	select {
+	case <-n.done:
+		return
+	default:
+	}
+	select {
+	case n.queue <- natsMessage{subject: subject, data: data}:
+	default:
+		n.dropped.Add(1)
+	}
+}
+
+// Dropped returns how many messages were not delivered to the server.
+func (n *NATS) Dropped() int64 {
+	return n.dropped.Load()
+}
+
+// Close stops publishing, writing out whatever is already queued if the
+// server is connected.
+func (n *NATS) Close() error {
+	n.once.Do(func() { close(n.done) })
+	<-n.stopped
+	return nil
+}
+
+func (n *NATS) run() {
+	defer close(n.stopped)
+	backoff := time.Second
+	for {
+		conn, err := n.connect()
+		if err != nil {
+			n.logger.Warn("nats connect failed", "server", n.url.Host, "error", err, "retry_in", backoff)
+			if !n.sleep(backoff) {
+				return
+			}
+			backoff = min(backoff*2, natsMaxBackoff)
+			continue
+		}
+		backoff = time.Second
+		n.logger.Info("nats connected", "server", n.url.Host)
+		err = conn.publish(n.queue, n.done, &n.dropped)
+		_ = conn.Close()
+		if err == nil {
+			return
+		}
+		n.logger.Warn("nats connection lost", "server", n.url.Host, "error", err)
+	}
+}
+
+// sleep waits for d, returning false if Close was called meanwhile.
+func (n *NATS) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-n.done:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+type natsInfo struct {
+	TLSRequired bool  `json:"tls_required"`
+	MaxPayload  int64 `json:"max_payload"`
+}
+
+type natsConnectOptions struct {
+	Verbose   bool   `json:"verbose"`
+	Pedantic  bool   `json:"pedantic"`
+	Name      string `json:"name"`
+	Lang      string `json:"lang"`
+	Protocol  int    `json:"protocol"`
+	User      string `json:"user,omitempty"`
+	Pass      string `json:"pass,omitempty"`
+	AuthToken string `json:"auth_token,omitempty"`
+}
+
+type natsConn struct {
+	net.Conn
+	r          *bufio.Reader
+	writeMu    sync.Mutex
+	w          *bufio.Writer
+	maxPayload int64
+	readErr    chan error
+}
+
+// connect dials the server, upgrades to TLS when asked, and completes the
+// CONNECT/PING handshake so authentication failures surface here.
+func (n *NATS) connect() (*natsConn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), natsDialTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	raw, err := dialer.DialContext(ctx, "tcp", n.url.Host)
+	if err != nil {
+		return nil, err
+	}
+	_ = raw.SetDeadline(time.Now().Add(natsDialTimeout))
+
+	conn := &natsConn{Conn: raw, r: bufio.NewReader(raw)}
+	line, err := conn.readLine()
+	if err != nil {
+		_ = raw.Close()
+		return nil, fmt.Errorf("read server info: %w", err)
+	}
+	infoJSON, ok := strings.CutPrefix(line, "INFO ")
+	if !ok {
+		_ = raw.Close()
+		return nil, fmt.Errorf("unexpected greeting %q", line)
+	}
+	var info natsInfo
+	if err := json.Unmarshal([]byte(infoJSON), &info); err != nil {
+		_ = raw.Close()
+		return nil, fmt.Errorf("parse server info: %w", err)
+	}
+	conn.maxPayload = info.MaxPayload
+
+	if n.url.Scheme == "tls" || info.TLSRequired {
+		tlsConn := tls.Client(raw, &tls.Config{ServerName: n.url.Hostname(), MinVersion: tls.VersionTLS12})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = raw.Close()
+			return nil, fmt.Errorf("tls handshake: %w", err)
+		}
+		conn.Conn = tlsConn
+		conn.r = bufio.NewReader(tlsConn)
+	}
+	conn.w = bufio.NewWriter(conn.Conn)
+
+	opts := natsConnectOptions{Name: natsClientName, Lang: natsClientLang, Protocol: natsProtocolVers}
+	if user := n.url.User; user != nil {
+		if pass, ok := user.Password(); ok {
+			opts.User, opts.Pass = user.Username(), pass
+		} else {
+			opts.AuthToken = user.Username()
+		}
+	}
+	connectJSON, err := json.Marshal(opts)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(conn.w, "CONNECT %s\r\nPING\r\n", connectJSON); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if err := conn.w.Flush(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	for {
+		line, err := conn.readLine()
+		if err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("read handshake reply: %w", err)
+		}
+		switch {
+		case line == "PONG":
+			_ = conn.SetDeadline(time.Time{})
+			conn.readErr = make(chan error, 1)
+			go conn.readLoop()
+			return conn, nil
+		case strings.HasPrefix(line, "-ERR"):
+			_ = conn.Close()
+			return nil, errors.New(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+func (c *natsConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readLoop answers the server's keepalive PINGs and reports the first
+// error it sees, including -ERR lines, on readErr.
+func (c *natsConn) readLoop() {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			c.readErr <- err
+			return
+		}
+		switch {
+		case line == "PING":
+			c.writeMu.Lock()
+			_, err = c.w.WriteString("PONG\r\n")
+			if err == nil {
+				err = c.w.Flush()
+			}
+			c.writeMu.Unlock()
+			if err != nil {
+				c.readErr <- err
+				return
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			c.readErr <- fmt.Errorf("server error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+			return
+		}
+	}
+}
+
+// publish writes queued messages until done is closed, returning nil, or
+// the connection fails. Writes are flushed whenever the queue drains.
+func (c *natsConn) publish(queue <-chan natsMessage, done <-chan struct{}, dropped *atomic.Int64) error {
+	for {
+		select {
+		case err := <-c.readErr:
+			return err
+		case msg := <-queue:
+			if err := c.write(msg, len(queue) == 0, dropped); err != nil {
+				return err
+			}
+		case <-done:
+			for {
+				select {
+				case msg := <-queue:
+					if err := c.write(msg, len(queue) == 0, dropped); err != nil {
+						return nil
+					}
+				default:
+					return nil
+				}
+			}
+		}
+	}
+}
+
+func (c *natsConn) write(msg natsMessage, flush bool, dropped *atomic.Int64) error {
+	if c.maxPayload > 0 && int64(len(msg.data)) > c.maxPayload {
+		dropped.Add(1)
+		return nil
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	fmt.Fprintf(c.w, "PUB %s %d\r\n", msg.subject, len(msg.data))
+	c.w.Write(msg.data)
+	c.w.WriteString("\r\n")
+	if !flush {
+		return nil
+	}
+	if err := c.w.Flush(); err != nil {
+		dropped.Add(1)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package eventbus
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+func TestNATSPublishesWithPrefixAndCredentials(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	type received struct {
+		connect natsConnectOptions
+		pubs    []string
+		err     error
+	}
+	result := make(chan received, 1)
+	go func() {
+		var got received
+		defer func() { result <- got }()
+		conn, err := ln.Accept()
+		if err != nil {
+			got.err = err
+			return
+		}
+		defer conn.Close()
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+		fmt.Fprintf(conn, "INFO {\"server_id\":\"test\",\"max_payload\":16}\r\n")
+
+		line, _ := r.ReadString('\n')
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(line), "CONNECT ")), &got.connect); err != nil {
+			got.err = fmt.Errorf("parse %q: %w", line, err)
+			return
+		}
+		if line, _ := r.ReadString('\n'); strings.TrimSpace(line) != "PING" {
+			got.err = fmt.Errorf("expected PING, got %q", line)
+			return
+		}
+		fmt.Fprintf(conn, "PONG\r\nPING\r\n")
+		for len(got.pubs) < 2 {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				got.err = err
+				return
+			}
+			line = strings.TrimSpace(line)
+			if line == "PONG" {
+				continue
+			}
+			var subject string
+			var size int
+			if _, err := fmt.Sscanf(line, "PUB %s %d", &subject, &size); err != nil {
+				got.err = fmt.Errorf("parse %q: %w", line, err)
+				return
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				got.err = err
+				return
+			}
+			got.pubs = append(got.pubs, subject+" "+string(payload[:size]))
+		}
+	}()
+
+	publisher, err := DialNATS("nats://svc:hunter2@"+ln.Addr().String(), "cleanroom.host-1.", log.New(io.Discard))
+	if err != nil {
+		t.Fatalf("DialNATS returned error: %v", err)
+	}
+	publisher.Publish("sandboxes.cr-1", []byte(`{"status":1}`))
+	publisher.Publish("sandboxes.cr-1", []byte(`{"message":"over max_payload"}`))
+	publisher.Publish("executions.cr-1.ex-1", []byte(`{}`))
+
+	got := <-result
+	if err := publisher.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if got.err != nil {
+		t.Fatalf("server: %v", got.err)
+	}
+	if got.connect.User != "svc" || got.connect.Pass != "hunter2" || got.connect.Name != "cleanroom" {
+		t.Fatalf("unexpected CONNECT options: %+v", got.connect)
+	}
+	want := []string{`cleanroom.host-1.sandboxes.cr-1 {"status":1}`, `cleanroom.host-1.executions.cr-1.ex-1 {}`}
+	if strings.Join(got.pubs, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected publishes:\ngot  %q\nwant %q", got.pubs, want)
+	}
+	if publisher.Dropped() != 1 {
+		t.Fatalf("expected the oversized message to be dropped, dropped %d", publisher.Dropped())
+	}
+}
+
+func TestValidateNATSURL(t *testing.T) {
+	t.Parallel()
+
+	for _, raw := range []string{"nats://localhost", "tls://token@nats.example.com:4443"} {
+		if err := ValidateNATSURL(raw); err != nil {
+			t.Fatalf("expected %q to be valid: %v", raw, err)
+		}
+	}
+	for _, raw := range []string{"", "localhost:4222", "amqp://broker:5672", "nats://"} {
+		if err := ValidateNATSURL(raw); err == nil {
+			t.Fatalf("expected %q to be rejected", raw)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package gateway
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var errDigestMismatch = errors.New("upstream content does not match its digest")
+
+// storeVerified streams body into dir/want, where want is the hex-encoded
+// hash the content must have. Content that hashes differently is discarded
+// with errDigestMismatch. The file appears atomically, so concurrent fetches
+// of the same content are safe.
+func storeVerified(dir, want string, body io.Reader, hasher hash.Hash) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, ".fetch-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(io.MultiWriter(tmp, hasher), body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("download %s: %w", want, err)
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) != want {
+		return "", errDigestMismatch
+	}
+	path := filepath.Join(dir, want)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -23,6 +23,11 @@ func TestUpstreamTransportIsolationDisablesSharedPooling(t *testing.T) {
 	if !transport.DisableKeepAlives {
 		t.Fatal("expected upstream keep-alives to be disabled to avoid cross-sandbox pool sharing")
 	}
+
+	oci := newOCIRegistryHandler("", 0, nil, nil)
+	if transport, ok := oci.client.Transport.(*http.Transport); !ok || !transport.DisableKeepAlives {
+		t.Fatal("expected oci registry upstream keep-alives to be disabled to avoid cross-sandbox pool sharing")
+	}
 }
 
 // --- Cross-sandbox isolation ---
@@ -15,8 +15,10 @@ type CredentialProvider interface {
 // EnvCredentialProvider reads credentials from environment variables at
 // construction time. It maps upstream hosts to env var names:
 //
-//	github.com -> CLEANROOM_GITHUB_TOKEN
-//	gitlab.com -> CLEANROOM_GITLAB_TOKEN
+//	github.com             -> CLEANROOM_GITHUB_TOKEN
+//	gitlab.com             -> CLEANROOM_GITLAB_TOKEN
+//	s3.amazonaws.com       -> CLEANROOM_S3_CREDENTIALS (for log shipping and run storage)
+//	storage.googleapis.com -> CLEANROOM_GCS_TOKEN (for log shipping)
 type EnvCredentialProvider struct {
 	hostTokens map[string]string
 }
@@ -27,8 +29,10 @@ func NewEnvCredentialProvider() *EnvCredentialProvider {
 		hostTokens: make(map[string]string),
 	}
 	hostEnvMap := map[string]string{
-		"github.com": "CLEANROOM_GITHUB_TOKEN",
-		"gitlab.com": "CLEANROOM_GITLAB_TOKEN",
+		"github.com":             "CLEANROOM_GITHUB_TOKEN",
+		"gitlab.com":             "CLEANROOM_GITLAB_TOKEN",
+		"s3.amazonaws.com":       "CLEANROOM_S3_CREDENTIALS",
+		"storage.googleapis.com": "CLEANROOM_GCS_TOKEN",
 	}
 	for host, envVar := range hostEnvMap {
 		if v := strings.TrimSpace(os.Getenv(envVar)); v != "" {
@@ -33,14 +33,7 @@ func newGitHandler(creds CredentialProvider, logger *log.Logger) *gitHandler {
 		credentials: creds,
 		logger:      logger,
 		client: &http.Client{
-			Transport: &http.Transport{
-				DialContext:           (&net.Dialer{Timeout: defaultUpstreamTimeout}).DialContext,
-				TLSHandshakeTimeout:   10 * time.Second,
-				ResponseHeaderTimeout: defaultUpstreamTimeout,
-				// Disable keep-alives to avoid sharing any upstream connection pool
-				// across sandbox identities.
-				DisableKeepAlives: true,
-			},
+			Transport: newUpstreamTransport(),
 			CheckRedirect: func(*http.Request, []*http.Request) error {
 				return http.ErrUseLastResponse
 			},
@@ -48,6 +41,17 @@ func newGitHandler(creds CredentialProvider, logger *log.Logger) *gitHandler {
 	}
 }
 
+func newUpstreamTransport() *http.Transport {
+	return &http.Transport{
+		DialContext:           (&net.Dialer{Timeout: defaultUpstreamTimeout}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: defaultUpstreamTimeout,
+		// Disable keep-alives to avoid sharing any upstream connection pool
+		// across sandbox identities.
+		DisableKeepAlives: true,
+	}
+}
+
 // ServeHTTP handles /git/<upstream-host>/<owner>/<repo>[.git]/...
 func (h *gitHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	scope, ok := ScopeFromContext(r.Context())
@@ -0,0 +1,123 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/buildkite/cleanroom/internal/policy"
+	"golang.org/x/mod/sumdb"
+)
+
+const (
+	goSumDBName = "sum.golang.org"
+	// goSumDBKey is the verifier key the go command ships for sum.golang.org.
+	goSumDBKey = "sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ze6/EyJWi5hXDgt6Ap"
+
+	// goSumDBMaxBytes bounds a single lookup record or tile.
+	goSumDBMaxBytes = 1 << 20
+)
+
+// goSumDBOps lets a sumdb.Client reach the checksum database through the Go
+// module upstream. The latest signed tree head is kept in memory; records
+// and tiles are cached on disk under cacheDir when it is set.
+type goSumDBOps struct {
+	h *packageHandler
+
+	mu     sync.Mutex
+	latest []byte
+}
+
+func (o *goSumDBOps) ReadRemote(path string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultUpstreamTimeout)
+	defer cancel()
+	resp, err := o.h.get(ctx, o.h.upstreams[policy.PackageEcosystemGo]+"/sumdb/"+goSumDBName+path, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &upstreamStatusError{status: resp.StatusCode}
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, goSumDBMaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > goSumDBMaxBytes {
+		return nil, fmt.Errorf("checksum database response for %s is too large", path)
+	}
+	return data, nil
+}
+
+func (o *goSumDBOps) ReadConfig(file string) ([]byte, error) {
+	if file == "key" {
+		return []byte(o.h.goSumDBKey), nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.latest, nil
+}
+
+func (o *goSumDBOps) WriteConfig(_ string, old, new []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !bytes.Equal(o.latest, old) {
+		return sumdb.ErrWriteConflict
+	}
+	o.latest = new
+	return nil
+}
+
+func (o *goSumDBOps) ReadCache(file string) ([]byte, error) {
+	path, ok := o.cachePath(file)
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return os.ReadFile(path)
+}
+
+func (o *goSumDBOps) WriteCache(file string, data []byte) {
+	path, ok := o.cachePath(file)
+	if !ok {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".fetch-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil && closeErr == nil {
+		_ = os.Rename(tmp.Name(), path)
+	}
+}
+
+// cachePath maps a client cache file such as sum.golang.org/tile/8/0/001
+// under the package cache, refusing names that would leave it.
+func (o *goSumDBOps) cachePath(file string) (string, bool) {
+	if o.h.cacheDir == "" || !filepath.IsLocal(filepath.FromSlash(file)) {
+		return "", false
+	}
+	return filepath.Join(o.h.cacheDir, "go-sumdb", filepath.FromSlash(file)), true
+}
+
+func (o *goSumDBOps) Log(msg string) {
+	if o.h.logger != nil {
+		o.h.logger.Debug("go checksum database", "message", msg)
+	}
+}
+
+func (o *goSumDBOps) SecurityError(msg string) {
+	if o.h.logger != nil {
+		o.h.logger.Error("go checksum database returned inconsistent data", "message", strings.TrimSpace(msg))
+	}
+}
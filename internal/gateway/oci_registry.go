@@ -0,0 +1,531 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	ociKindManifests = "manifests"
+	ociKindBlobs     = "blobs"
+
+	// ociManifestMaxBytes matches the manifest size limit registries enforce.
+	ociManifestMaxBytes = 4 << 20
+
+	// defaultOCIRegistryCacheMaxBytes bounds the on-disk cache when the
+	// server config does not.
+	defaultOCIRegistryCacheMaxBytes = 20 << 30
+
+	reasonRepositoryNotAllowed = "repository_not_allowed"
+	reasonReferenceNotAllowed  = "reference_not_allowed"
+	reasonDigestMismatch       = "digest_mismatch"
+	reasonManifestTooLarge     = "manifest_too_large"
+)
+
+var (
+	ociManifestAccept = strings.Join([]string{
+		"application/vnd.oci.image.index.v1+json",
+		"application/vnd.oci.image.manifest.v1+json",
+		"application/vnd.docker.distribution.manifest.list.v2+json",
+		"application/vnd.docker.distribution.manifest.v2+json",
+	}, ", ")
+
+	sha256DigestPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+)
+
+// upstreamStatusError is returned when the upstream registry answers with
+// anything other than 200 OK.
+type upstreamStatusError struct {
+	status int
+}
+
+func (e *upstreamStatusError) Error() string {
+	return fmt.Sprintf("upstream registry returned %s", http.StatusText(e.status))
+}
+
+// ociRegistryHandler is a pull-only registry mirror. Guests address images
+// as <gateway>/<registry-host>/<repository>, so the first path component of
+// every repository names the upstream registry. Content fetched by digest is
+// verified and cached on disk per repository, shared across sandboxes: a
+// digest fetched from one repository, possibly with credentials, is never
+// served for another. The least recently used files are evicted once the
+// cache outgrows maxCacheBytes.
+type ociRegistryHandler struct {
+	cacheDir      string
+	maxCacheBytes int64
+	credentials   CredentialProvider
+	logger        *log.Logger
+	client        *http.Client
+
+	evictMu sync.Mutex
+}
+
+func newOCIRegistryHandler(cacheDir string, maxCacheBytes int64, creds CredentialProvider, logger *log.Logger) *ociRegistryHandler {
+	if maxCacheBytes <= 0 {
+		maxCacheBytes = defaultOCIRegistryCacheMaxBytes
+	}
+	return &ociRegistryHandler{
+		cacheDir:      cacheDir,
+		maxCacheBytes: maxCacheBytes,
+		credentials:   creds,
+		logger:        logger,
+		// Registries commonly redirect blob downloads to a CDN. Redirects are
+		// followed host-side only, and Authorization is dropped when the
+		// redirect leaves the registry's domain. Content is verified by digest.
+		client: &http.Client{Transport: newUpstreamTransport()},
+	}
+}
+
+// ServeHTTP handles /v2/<registry-host>/<repository>/(manifests|blobs)/<reference>
+func (h *ociRegistryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	scope, ok := ScopeFromContext(r.Context())
+	if !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		h.auditLog(scope.SandboxID, r.URL.Path, "", "deny", reasonMethodNotAllowed)
+		writeReasonError(w, http.StatusForbidden, reasonMethodNotAllowed, "only image pulls are permitted")
+		return
+	}
+	if r.URL.Path == "/v2" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	repository, kind, reference, err := parseOCIRegistryPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	pinned, ok := scope.Policy.AllowsOCIRepository(repository)
+	if !ok {
+		h.auditLog(scope.SandboxID, repository, reference, "deny", reasonRepositoryNotAllowed)
+		writeReasonError(w, http.StatusForbidden, reasonRepositoryNotAllowed, "repository is not allowed by sandbox policy")
+		return
+	}
+
+	if !sha256DigestPattern.MatchString(reference) {
+		if kind == ociKindBlobs {
+			http.Error(w, "bad request: blob reference must be a sha256 digest", http.StatusBadRequest)
+			return
+		}
+		if len(pinned) > 0 {
+			h.auditLog(scope.SandboxID, repository, reference, "deny", reasonReferenceNotAllowed)
+			writeReasonError(w, http.StatusForbidden, reasonReferenceNotAllowed, "sandbox policy pins this repository to digests")
+			return
+		}
+		h.auditLog(scope.SandboxID, repository, reference, "allow", "proxied")
+		h.proxyTag(w, r, repository, reference)
+		return
+	}
+
+	if len(pinned) > 0 {
+		allowed, err := h.referencedByPinned(r.Context(), repository, pinned, reference)
+		if err != nil {
+			h.writeFetchError(w, err)
+			return
+		}
+		if !allowed {
+			h.auditLog(scope.SandboxID, repository, reference, "deny", reasonReferenceNotAllowed)
+			writeReasonError(w, http.StatusForbidden, reasonReferenceNotAllowed, "digest is not referenced by the manifests sandbox policy pins")
+			return
+		}
+	}
+
+	path, cached, err := h.fetch(r.Context(), repository, kind, reference)
+	if err != nil {
+		h.auditLog(scope.SandboxID, repository, reference, "allow", reasonUpstreamError)
+		h.writeFetchError(w, err)
+		return
+	}
+	reason := "fetched"
+	if cached {
+		reason = "cache_hit"
+	}
+	h.auditLog(scope.SandboxID, repository, reference, "allow", reason)
+	h.serveCached(w, r, path, kind, reference)
+}
+
+// parseOCIRegistryPath splits a distribution API path into the repository,
+// including its registry host, the content kind and the reference.
+func parseOCIRegistryPath(p string) (repository, kind, reference string, err error) {
+	rest := strings.TrimPrefix(p, RouteOCIRegistry)
+	for _, candidate := range []string{ociKindManifests, ociKindBlobs} {
+		i := strings.LastIndex(rest, "/"+candidate+"/")
+		if i <= 0 {
+			continue
+		}
+		repository, reference = rest[:i], rest[i+len(candidate)+2:]
+		if reference == "" || strings.Contains(reference, "/") {
+			break
+		}
+		if !strings.Contains(repository, "/") {
+			return "", "", "", errors.New("repository must start with its registry host")
+		}
+		return repository, candidate, reference, nil
+	}
+	return "", "", "", errors.New("expected /v2/<registry-host>/<repository>/manifests/<reference> or /blobs/<digest>")
+}
+
+// referencedByPinned reports whether digest is a pinned manifest or content
+// one references, following an index down to its platform manifests.
+func (h *ociRegistryHandler) referencedByPinned(ctx context.Context, repository string, pinned []string, digest string) (bool, error) {
+	frontier := pinned
+	for depth := 0; depth < 2 && len(frontier) > 0; depth++ {
+		var next []string
+		for _, manifest := range frontier {
+			if manifest == digest {
+				return true, nil
+			}
+			path, _, err := h.fetch(ctx, repository, ociKindManifests, manifest)
+			if err != nil {
+				return false, err
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return false, err
+			}
+			var refs struct {
+				Manifests []struct{ Digest string } `json:"manifests"`
+				Config    struct{ Digest string }   `json:"config"`
+				Layers    []struct{ Digest string } `json:"layers"`
+			}
+			if err := json.Unmarshal(data, &refs); err != nil {
+				return false, fmt.Errorf("parse manifest %s: %w", manifest, err)
+			}
+			if refs.Config.Digest == digest {
+				return true, nil
+			}
+			for _, layer := range refs.Layers {
+				if layer.Digest == digest {
+					return true, nil
+				}
+			}
+			for _, child := range refs.Manifests {
+				if child.Digest == digest {
+					return true, nil
+				}
+				if sha256DigestPattern.MatchString(child.Digest) {
+					next = append(next, child.Digest)
+				}
+			}
+		}
+		frontier = next
+	}
+	return false, nil
+}
+
+// fetch returns the cache path holding digest's content in repository,
+// downloading and verifying it on a miss. cached reports whether it was
+// already present.
+func (h *ociRegistryHandler) fetch(ctx context.Context, repository, kind, digest string) (path string, cached bool, err error) {
+	if h.cacheDir == "" {
+		return "", false, errors.New("registry cache directory is not configured")
+	}
+	// PathEscape turns the repository into a single path component, so no
+	// repository name can reach outside the cache.
+	dir := filepath.Join(h.cacheDir, "repositories", url.PathEscape(repository), "sha256")
+	path = filepath.Join(dir, strings.TrimPrefix(digest, "sha256:"))
+	if _, err := os.Stat(path); err == nil {
+		now := time.Now()
+		_ = os.Chtimes(path, now, now)
+		return path, true, nil
+	}
+
+	resp, err := h.upstream(ctx, http.MethodGet, repository, kind, digest)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", false, &upstreamStatusError{status: resp.StatusCode}
+	}
+
+	// A truncated manifest fails verification like any other mismatch.
+	body := io.Reader(resp.Body)
+	if kind == ociKindManifests {
+		body = io.LimitReader(resp.Body, ociManifestMaxBytes)
+	}
+	if _, err := storeVerified(dir, strings.TrimPrefix(digest, "sha256:"), body, sha256.New()); err != nil {
+		return "", false, err
+	}
+	h.evict(path)
+	return path, false, nil
+}
+
+// evict removes the least recently used cache files until the cache fits in
+// maxCacheBytes. keep, the file just stored, is never removed.
+func (h *ociRegistryHandler) evict(keep string) {
+	h.evictMu.Lock()
+	defer h.evictMu.Unlock()
+
+	type entry struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var (
+		entries []entry
+		total   int64
+	)
+	_ = filepath.WalkDir(h.cacheDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), ".fetch-") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		total += info.Size()
+		if path != keep {
+			entries = append(entries, entry{path: path, size: info.Size(), modTime: info.ModTime()})
+		}
+		return nil
+	})
+	if total <= h.maxCacheBytes {
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].modTime.Before(entries[j].modTime) })
+	for _, e := range entries {
+		if total <= h.maxCacheBytes {
+			break
+		}
+		if err := os.Remove(e.path); err != nil {
+			continue
+		}
+		total -= e.size
+		if h.logger != nil {
+			h.logger.Debug("evicted oci registry cache entry", "path", e.path, "size_bytes", e.size)
+		}
+	}
+}
+
+func (h *ociRegistryHandler) serveCached(w http.ResponseWriter, r *http.Request, path, kind, digest string) {
+	data, err := os.Open(path)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	defer data.Close()
+
+	w.Header().Set("Docker-Content-Digest", digest)
+	content := io.ReadSeeker(data)
+	if kind == ociKindManifests {
+		manifest, err := io.ReadAll(data)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", manifestMediaType(manifest))
+		content = bytes.NewReader(manifest)
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	http.ServeContent(w, r, "", time.Time{}, content)
+}
+
+// manifestMediaType recovers a cached manifest's media type from its body.
+// The field is optional for OCI manifests, so fall back on the shape.
+func manifestMediaType(manifest []byte) string {
+	var probe struct {
+		MediaType string          `json:"mediaType"`
+		Manifests json.RawMessage `json:"manifests"`
+	}
+	_ = json.Unmarshal(manifest, &probe)
+	switch {
+	case probe.MediaType != "":
+		return probe.MediaType
+	case probe.Manifests != nil:
+		return "application/vnd.oci.image.index.v1+json"
+	default:
+		return "application/vnd.oci.image.manifest.v1+json"
+	}
+}
+
+// proxyTag forwards a manifest request by tag without caching it, since a
+// tag can move between requests. A manifest over ociManifestMaxBytes fails
+// rather than being cut short.
+func (h *ociRegistryHandler) proxyTag(w http.ResponseWriter, r *http.Request, repository, tag string) {
+	resp, err := h.upstream(r.Context(), r.Method, repository, ociKindManifests, tag)
+	if err != nil {
+		writeReasonError(w, http.StatusBadGateway, reasonUpstreamError, "upstream error")
+		return
+	}
+	defer resp.Body.Close()
+
+	var manifest []byte
+	if r.Method != http.MethodHead {
+		manifest, err = io.ReadAll(io.LimitReader(resp.Body, ociManifestMaxBytes+1))
+		if err != nil {
+			writeReasonError(w, http.StatusBadGateway, reasonUpstreamError, "upstream error")
+			return
+		}
+		if len(manifest) > ociManifestMaxBytes {
+			writeReasonError(w, http.StatusBadGateway, reasonManifestTooLarge, "upstream manifest exceeds the size limit")
+			return
+		}
+	}
+
+	for _, key := range []string{"Content-Type", "Content-Length", "Docker-Content-Digest"} {
+		if v := resp.Header.Get(key); v != "" {
+			w.Header().Set(key, v)
+		}
+	}
+	if r.Method != http.MethodHead {
+		w.Header().Set("Content-Length", strconv.Itoa(len(manifest)))
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write(manifest)
+}
+
+func (h *ociRegistryHandler) writeFetchError(w http.ResponseWriter, err error) {
+	var statusErr *upstreamStatusError
+	switch {
+	case errors.Is(err, errDigestMismatch):
+		writeReasonError(w, http.StatusBadGateway, reasonDigestMismatch, err.Error())
+	case errors.As(err, &statusErr) && statusErr.status == http.StatusNotFound:
+		writeReasonError(w, http.StatusNotFound, reasonUpstreamError, err.Error())
+	default:
+		writeReasonError(w, http.StatusBadGateway, reasonUpstreamError, "upstream error")
+	}
+}
+
+// upstream issues a request to the repository's registry. An anonymous or
+// configured-credential token is exchanged when the registry challenges.
+func (h *ociRegistryHandler) upstream(ctx context.Context, method, repository, kind, reference string) (*http.Response, error) {
+	host, name, _ := strings.Cut(repository, "/")
+	upstreamHost := host
+	if host == "docker.io" {
+		upstreamHost = "registry-1.docker.io"
+		if !strings.Contains(name, "/") {
+			name = "library/" + name
+		}
+	}
+	target := fmt.Sprintf("https://%s/v2/%s/%s/%s", upstreamHost, name, kind, reference)
+
+	credential := ""
+	if h.credentials != nil {
+		var err error
+		if credential, err = h.credentials.Resolve(ctx, host); err != nil {
+			return nil, err
+		}
+	}
+
+	send := func(token string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, method, target, nil)
+		if err != nil {
+			return nil, err
+		}
+		if kind == ociKindManifests {
+			req.Header.Set("Accept", ociManifestAccept)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return h.client.Do(req)
+	}
+
+	resp, err := send(credential)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	token, err := h.exchangeToken(ctx, challenge, name, credential)
+	if err != nil {
+		return nil, err
+	}
+	return send(token)
+}
+
+// exchangeToken answers a Bearer challenge with a pull-scoped token. The
+// scope is always pull so a sandbox can never obtain push access.
+func (h *ociRegistryHandler) exchangeToken(ctx context.Context, challenge, name, credential string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported registry auth challenge %q", scheme)
+	}
+	realm, service := "", ""
+	for _, param := range strings.Split(params, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		switch key {
+		case "realm":
+			realm = strings.Trim(value, `"`)
+		case "service":
+			service = strings.Trim(value, `"`)
+		}
+	}
+	realmURL, err := url.Parse(realm)
+	if err != nil || realmURL.Scheme != "https" {
+		return "", fmt.Errorf("registry token realm %q must be an https URL", realm)
+	}
+	query := realmURL.Query()
+	if service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", "repository:"+name+":pull")
+	realmURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realmURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if credential != "" {
+		req.SetBasicAuth("cleanroom", credential)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &upstreamStatusError{status: resp.StatusCode}
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode registry token: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+func (h *ociRegistryHandler) auditLog(sandboxID, repository, reference, action, reason string) {
+	if h.logger == nil {
+		return
+	}
+	h.logger.Info("gateway oci registry request",
+		"sandbox_id", sandboxID,
+		"service", "oci-registry",
+		"repository", repository,
+		"reference", reference,
+		"action", action,
+		"reason_code", reason,
+	)
+}
@@ -0,0 +1,310 @@
+package gateway
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/policy"
+)
+
+func testDigest(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+type fakeRegistry struct {
+	server  *httptest.Server
+	host    string
+	content map[string]string // path under /v2/ -> body
+	fetches atomic.Int32
+}
+
+// newFakeRegistry serves an index pointing at one image manifest with a
+// config and a layer, behind an anonymous bearer-token challenge.
+func newFakeRegistry(t *testing.T) (*fakeRegistry, map[string]string) {
+	t.Helper()
+
+	layer := "layer-bytes"
+	config := `{"architecture":"amd64"}`
+	manifest := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"digest":%q},"layers":[{"digest":%q}]}`, testDigest(config), testDigest(layer))
+	index := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[{"digest":%q}]}`, testDigest(manifest))
+	digests := map[string]string{
+		"layer":    testDigest(layer),
+		"config":   testDigest(config),
+		"manifest": testDigest(manifest),
+		"index":    testDigest(index),
+	}
+
+	reg := &fakeRegistry{content: map[string]string{
+		"org/tool/manifests/" + digests["index"]:    index,
+		"org/tool/manifests/" + digests["manifest"]: manifest,
+		"org/tool/manifests/v1":                     index,
+		"org/tool/blobs/" + digests["config"]:       config,
+		"org/tool/blobs/" + digests["layer"]:        layer,
+		"org/tool/blobs/" + testDigest("stray"):     "stray",
+	}}
+	reg.server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if got := r.URL.Query().Get("scope"); !strings.HasPrefix(got, "repository:org/") || !strings.HasSuffix(got, ":pull") {
+				t.Errorf("unexpected token scope: got %q want a pull scope for an org repository", got)
+			}
+			_, _ = w.Write([]byte(`{"token":"pull-token"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer pull-token" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake"`, reg.server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, ok := reg.content[strings.TrimPrefix(r.URL.Path, "/v2/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		reg.fetches.Add(1)
+		w.Header().Set("Docker-Content-Digest", testDigest(body))
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(reg.server.Close)
+	reg.host = strings.TrimPrefix(reg.server.URL, "https://")
+	return reg, digests
+}
+
+func ociTestScope(allow ...string) *SandboxScope {
+	return &SandboxScope{
+		SandboxID: "sandbox-test",
+		GuestIP:   "10.1.1.2",
+		Policy: &policy.CompiledPolicy{
+			Version:        1,
+			NetworkDefault: "deny",
+			Services: policy.Services{
+				OCIRegistry: &policy.OCIRegistryService{Allow: allow},
+			},
+		},
+	}
+}
+
+func serveOCI(h *ociRegistryHandler, method, path string, scope *SandboxScope) *httptest.ResponseRecorder {
+	req := withScope(httptest.NewRequest(method, path, nil), scope)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func newTestOCIRegistryHandler(t *testing.T, reg *fakeRegistry) *ociRegistryHandler {
+	h := newOCIRegistryHandler(t.TempDir(), 0, nil, nil)
+	h.client = reg.server.Client()
+	return h
+}
+
+func TestOCIRegistryPinnedRepositoryServesReferencedContent(t *testing.T) {
+	t.Parallel()
+
+	reg, digests := newFakeRegistry(t)
+	h := newTestOCIRegistryHandler(t, reg)
+	repo := reg.host + "/org/tool"
+	scope := ociTestScope(repo + "@" + digests["index"])
+
+	for _, tc := range []struct {
+		path      string
+		mediaType string
+	}{
+		{"/v2/" + repo + "/manifests/" + digests["index"], "application/vnd.oci.image.index.v1+json"},
+		{"/v2/" + repo + "/manifests/" + digests["manifest"], "application/vnd.oci.image.manifest.v1+json"},
+		{"/v2/" + repo + "/blobs/" + digests["config"], "application/octet-stream"},
+		{"/v2/" + repo + "/blobs/" + digests["layer"], "application/octet-stream"},
+	} {
+		w := serveOCI(h, http.MethodGet, tc.path, scope)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: unexpected status: got %d want %d (%s)", tc.path, w.Code, http.StatusOK, w.Body.String())
+		}
+		if got := w.Header().Get("Content-Type"); got != tc.mediaType {
+			t.Fatalf("GET %s: unexpected content type: got %q want %q", tc.path, got, tc.mediaType)
+		}
+		if got, want := testDigest(w.Body.String()), w.Header().Get("Docker-Content-Digest"); got != want {
+			t.Fatalf("GET %s: body digest %s does not match header %s", tc.path, got, want)
+		}
+	}
+
+	fetches := reg.fetches.Load()
+	if w := serveOCI(h, http.MethodGet, "/v2/"+repo+"/blobs/"+digests["layer"], scope); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status for cached layer: got %d want %d", w.Code, http.StatusOK)
+	}
+	if got := reg.fetches.Load(); got != fetches {
+		t.Fatalf("expected cached layer to be served without an upstream fetch: got %d fetches want %d", got, fetches)
+	}
+}
+
+func TestOCIRegistryPinnedRepositoryDeniesOtherReferences(t *testing.T) {
+	t.Parallel()
+
+	reg, digests := newFakeRegistry(t)
+	h := newTestOCIRegistryHandler(t, reg)
+	repo := reg.host + "/org/tool"
+	scope := ociTestScope(repo + "@" + digests["index"])
+
+	for _, path := range []string{
+		"/v2/" + repo + "/manifests/v1",
+		"/v2/" + repo + "/blobs/" + testDigest("stray"),
+	} {
+		w := serveOCI(h, http.MethodGet, path, scope)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("GET %s: unexpected status: got %d want %d", path, w.Code, http.StatusForbidden)
+		}
+		if got := w.Header().Get(reasonCodeHeader); got != reasonReferenceNotAllowed {
+			t.Fatalf("GET %s: unexpected reason code: got %q want %q", path, got, reasonReferenceNotAllowed)
+		}
+	}
+}
+
+func TestOCIRegistryDeniesUnlistedRepositoryAndPushes(t *testing.T) {
+	t.Parallel()
+
+	h := newOCIRegistryHandler(t.TempDir(), 0, nil, nil)
+	scope := ociTestScope("ghcr.io/org/tool")
+
+	w := serveOCI(h, http.MethodGet, "/v2/ghcr.io/org/other/manifests/latest", scope)
+	if w.Code != http.StatusForbidden || w.Header().Get(reasonCodeHeader) != reasonRepositoryNotAllowed {
+		t.Fatalf("unexpected response for unlisted repository: got %d %q", w.Code, w.Header().Get(reasonCodeHeader))
+	}
+
+	w = serveOCI(h, http.MethodPost, "/v2/ghcr.io/org/tool/blobs/uploads/", scope)
+	if w.Code != http.StatusForbidden || w.Header().Get(reasonCodeHeader) != reasonMethodNotAllowed {
+		t.Fatalf("unexpected response for blob upload: got %d %q", w.Code, w.Header().Get(reasonCodeHeader))
+	}
+
+	w = serveOCI(h, http.MethodGet, "/v2/ghcr.io/org/tool/manifests/latest", gitTestScope())
+	if w.Code != http.StatusForbidden || w.Header().Get(reasonCodeHeader) != reasonRepositoryNotAllowed {
+		t.Fatalf("unexpected response for policy without oci_registry: got %d %q", w.Code, w.Header().Get(reasonCodeHeader))
+	}
+}
+
+func TestOCIRegistryUnpinnedRepositoryProxiesTags(t *testing.T) {
+	t.Parallel()
+
+	reg, digests := newFakeRegistry(t)
+	h := newTestOCIRegistryHandler(t, reg)
+	scope := ociTestScope(reg.host + "/org/tool")
+
+	w := serveOCI(h, http.MethodGet, "/v2/"+reg.host+"/org/tool/manifests/v1", scope)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: got %d want %d (%s)", w.Code, http.StatusOK, w.Body.String())
+	}
+	if got := w.Header().Get("Docker-Content-Digest"); got != digests["index"] {
+		t.Fatalf("unexpected digest: got %q want %q", got, digests["index"])
+	}
+}
+
+func TestOCIRegistryRejectsDigestMismatch(t *testing.T) {
+	t.Parallel()
+
+	reg, _ := newFakeRegistry(t)
+	wrong := testDigest("something else")
+	reg.content["org/tool/blobs/"+wrong] = "tampered"
+	h := newTestOCIRegistryHandler(t, reg)
+
+	w := serveOCI(h, http.MethodGet, "/v2/"+reg.host+"/org/tool/blobs/"+wrong, ociTestScope(reg.host+"/org/tool"))
+	if w.Code != http.StatusBadGateway || w.Header().Get(reasonCodeHeader) != reasonDigestMismatch {
+		t.Fatalf("unexpected response: got %d %q", w.Code, w.Header().Get(reasonCodeHeader))
+	}
+}
+
+func TestOCIRegistryCacheIsKeptPerRepository(t *testing.T) {
+	t.Parallel()
+
+	reg, digests := newFakeRegistry(t)
+	h := newTestOCIRegistryHandler(t, reg)
+	scope := ociTestScope(reg.host+"/org/tool", reg.host+"/org/other")
+
+	if w := serveOCI(h, http.MethodGet, "/v2/"+reg.host+"/org/tool/blobs/"+digests["layer"], scope); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status for org/tool: got %d want %d", w.Code, http.StatusOK)
+	}
+	// org/other does not hold the layer upstream, so the copy cached for
+	// org/tool must not be served for it.
+	if w := serveOCI(h, http.MethodGet, "/v2/"+reg.host+"/org/other/blobs/"+digests["layer"], scope); w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status for org/other: got %d want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestOCIRegistryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	t.Parallel()
+
+	reg, digests := newFakeRegistry(t)
+	h := newTestOCIRegistryHandler(t, reg)
+	h.maxCacheBytes = int64(len("layer-bytes"))
+	repo := reg.host + "/org/tool"
+	scope := ociTestScope(repo)
+
+	for _, name := range []string{"config", "layer"} {
+		if w := serveOCI(h, http.MethodGet, "/v2/"+repo+"/blobs/"+digests[name], scope); w.Code != http.StatusOK {
+			t.Fatalf("GET %s: unexpected status: got %d want %d", name, w.Code, http.StatusOK)
+		}
+	}
+	var files []string
+	_ = filepath.WalkDir(h.cacheDir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, filepath.Base(path))
+		}
+		return nil
+	})
+	if want := strings.TrimPrefix(digests["layer"], "sha256:"); len(files) != 1 || files[0] != want {
+		t.Fatalf("expected only the latest blob to stay cached, got %v", files)
+	}
+}
+
+func TestOCIRegistryRejectsOversizedTagManifest(t *testing.T) {
+	t.Parallel()
+
+	reg, _ := newFakeRegistry(t)
+	reg.content["org/tool/manifests/huge"] = strings.Repeat("x", ociManifestMaxBytes+1)
+	h := newTestOCIRegistryHandler(t, reg)
+
+	w := serveOCI(h, http.MethodGet, "/v2/"+reg.host+"/org/tool/manifests/huge", ociTestScope(reg.host+"/org/tool"))
+	if w.Code != http.StatusBadGateway || w.Header().Get(reasonCodeHeader) != reasonManifestTooLarge {
+		t.Fatalf("unexpected response: got %d %q", w.Code, w.Header().Get(reasonCodeHeader))
+	}
+}
+
+func TestOCIRegistryVersionCheck(t *testing.T) {
+	t.Parallel()
+
+	reg := NewRegistry()
+	if err := reg.Register("10.1.1.2", "sandbox-1", &policy.CompiledPolicy{Version: 1, NetworkDefault: "deny"}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	srv := NewServer(ServerConfig{ListenAddr: "127.0.0.1:0", Registry: reg})
+
+	req := httptest.NewRequest(http.MethodGet, "/v2/", nil)
+	req.RemoteAddr = "10.1.1.2:12345"
+	w := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: got %d want %d", w.Code, http.StatusOK)
+	}
+	if got := w.Header().Get("Docker-Distribution-API-Version"); got != "registry/2.0" {
+		t.Fatalf("unexpected API version header: %q", got)
+	}
+}
+
+func TestParseOCIRegistryPath(t *testing.T) {
+	t.Parallel()
+
+	repo, kind, ref, err := parseOCIRegistryPath("/v2/ghcr.io/org/tool/blobs/sha256:abc")
+	if err != nil || repo != "ghcr.io/org/tool" || kind != ociKindBlobs || ref != "sha256:abc" {
+		t.Fatalf("unexpected parse: %q %q %q %v", repo, kind, ref, err)
+	}
+	for _, path := range []string{"/v2/alpine/manifests/latest", "/v2/ghcr.io/org/tool/tags/list", "/v2/ghcr.io/org/tool/manifests/"} {
+		if _, _, _, err := parseOCIRegistryPath(path); err == nil {
+			t.Fatalf("expected %s to be rejected", path)
+		}
+	}
+}
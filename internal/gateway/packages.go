@@ -0,0 +1,578 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/policy"
+	"github.com/charmbracelet/log"
+	"golang.org/x/mod/sumdb"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+const (
+	// packageMetadataMaxBytes bounds the package documents the proxy reads
+	// to rewrite or to look up checksums. Full npm packuments can be large.
+	packageMetadataMaxBytes = 64 << 20
+
+	npmAbbreviatedMetadata = "application/vnd.npm.install-v1+json"
+	pypiSimpleJSON         = "application/vnd.pypi.simple.v1+json"
+
+	reasonPackageNotAllowed   = "package_not_allowed"
+	reasonChecksumMismatch    = "checksum_mismatch"
+	reasonChecksumUnavailable = "checksum_unavailable"
+
+	upstreamPyPIFiles = "pypi-files"
+)
+
+var defaultPackageUpstreams = map[string]string{
+	policy.PackageEcosystemNPM:  "https://registry.npmjs.org",
+	policy.PackageEcosystemPyPI: "https://pypi.org",
+	upstreamPyPIFiles:           "https://files.pythonhosted.org",
+	policy.PackageEcosystemGo:   "https://proxy.golang.org",
+}
+
+var (
+	npmNamePattern  = regexp.MustCompile(`^(@[a-z0-9][a-z0-9._~-]*/)?[a-z0-9._~-]+$`)
+	pypiNameRunes   = regexp.MustCompile(`[-_.]+`)
+	errNoChecksum   = errors.New("upstream metadata has no usable checksum for this file")
+	errFileNotFound = errors.New("file is not listed in the package metadata")
+)
+
+// packageHandler proxies npm, PyPI and Go module fetches for the package
+// names each sandbox's policy allows. Metadata is always fetched fresh and
+// rewritten to point back at the gateway. npm tarballs and PyPI files are
+// verified against the checksum in that metadata, and Go module zips and
+// go.mod files against the checksum database, before they are cached and
+// served.
+type packageHandler struct {
+	cacheDir   string
+	logger     *log.Logger
+	client     *http.Client
+	upstreams  map[string]string
+	goSumDBKey string
+	goSumDB    *sumdb.Client
+}
+
+func newPackageHandler(cacheDir string, logger *log.Logger) *packageHandler {
+	h := &packageHandler{
+		cacheDir:   cacheDir,
+		logger:     logger,
+		client:     &http.Client{Transport: newUpstreamTransport()},
+		upstreams:  defaultPackageUpstreams,
+		goSumDBKey: goSumDBKey,
+	}
+	h.goSumDB = sumdb.NewClient(&goSumDBOps{h: h})
+	return h
+}
+
+// ServeHTTP handles /registry/<ecosystem>/...
+func (h *packageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	scope, ok := ScopeFromContext(r.Context())
+	if !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	ecosystem, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, RouteRegistry), "/")
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		h.auditLog(scope.SandboxID, ecosystem, rest, "deny", reasonMethodNotAllowed)
+		writeReasonError(w, http.StatusForbidden, reasonMethodNotAllowed, "only package downloads are permitted")
+		return
+	}
+	patterns := scope.Policy.PackagePatterns(ecosystem)
+	if patterns == nil {
+		h.auditLog(scope.SandboxID, ecosystem, rest, "deny", reasonPackageNotAllowed)
+		writeReasonError(w, http.StatusForbidden, reasonPackageNotAllowed, "package ecosystem is not allowed by sandbox policy")
+		return
+	}
+
+	// Rewritten metadata points the guest back at the address it used.
+	gatewayURL := "http://" + r.Host + RouteRegistry + ecosystem
+	switch ecosystem {
+	case policy.PackageEcosystemNPM:
+		h.serveNPM(w, r, scope.SandboxID, patterns, rest, gatewayURL)
+	case policy.PackageEcosystemPyPI:
+		h.servePyPI(w, r, scope.SandboxID, patterns, rest, gatewayURL)
+	case policy.PackageEcosystemGo:
+		h.serveGo(w, r, scope.SandboxID, patterns, rest)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveNPM handles /registry/npm/<name> and /registry/npm/<name>/-/<file>.tgz
+func (h *packageHandler) serveNPM(w http.ResponseWriter, r *http.Request, sandboxID string, patterns []string, rest, gatewayURL string) {
+	name, file, isTarball := strings.Cut(rest, "/-/")
+	if !npmNamePattern.MatchString(name) || strings.Contains(file, "/") {
+		http.Error(w, "not found: expected /registry/npm/<name> or /registry/npm/<name>/-/<file>", http.StatusNotFound)
+		return
+	}
+	if !matchesAnyPattern(patterns, name) {
+		h.auditLog(sandboxID, policy.PackageEcosystemNPM, name, "deny", reasonPackageNotAllowed)
+		writeReasonError(w, http.StatusForbidden, reasonPackageNotAllowed, "package is not allowed by sandbox policy")
+		return
+	}
+
+	upstream := h.upstreams[policy.PackageEcosystemNPM]
+	// Scoped names keep their slash encoded in registry URLs.
+	metadataURL := upstream + "/" + strings.Replace(name, "/", "%2f", 1)
+	if !isTarball {
+		h.auditLog(sandboxID, policy.PackageEcosystemNPM, name, "allow", "proxied")
+		h.proxyMetadata(w, r, metadataURL, r.Header.Get("Accept"), `"`+upstream+"/", `"`+gatewayURL+"/")
+		return
+	}
+
+	h.serveVerified(w, r, sandboxID, policy.PackageEcosystemNPM, name+"/-/"+file, func(ctx context.Context) (string, string, hash.Hash, error) {
+		return h.npmTarball(ctx, metadataURL, upstream+"/"+name+"/-/"+file)
+	})
+}
+
+// npmTarball looks up the integrity the registry published for a tarball.
+func (h *packageHandler) npmTarball(ctx context.Context, metadataURL, tarballURL string) (string, string, hash.Hash, error) {
+	var doc struct {
+		Versions map[string]struct {
+			Dist struct {
+				Tarball   string `json:"tarball"`
+				Integrity string `json:"integrity"`
+			} `json:"dist"`
+		} `json:"versions"`
+	}
+	if err := h.getJSON(ctx, metadataURL, npmAbbreviatedMetadata, &doc); err != nil {
+		return "", "", nil, err
+	}
+	for _, version := range doc.Versions {
+		if version.Dist.Tarball != tarballURL {
+			continue
+		}
+		// Only sha512 integrity is trusted; sha1 shasums are too weak.
+		encoded, ok := strings.CutPrefix(version.Dist.Integrity, "sha512-")
+		if !ok {
+			return "", "", nil, errNoChecksum
+		}
+		sum, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(sum) != sha512.Size {
+			return "", "", nil, errNoChecksum
+		}
+		return tarballURL, hex.EncodeToString(sum), sha512.New(), nil
+	}
+	return "", "", nil, errFileNotFound
+}
+
+// servePyPI handles /registry/pypi/simple/<project> and
+// /registry/pypi/files/<project>/<path>
+func (h *packageHandler) servePyPI(w http.ResponseWriter, r *http.Request, sandboxID string, patterns []string, rest, gatewayURL string) {
+	kind, rest, _ := strings.Cut(rest, "/")
+	project, filePath, _ := strings.Cut(rest, "/")
+	project = normalizePyPIName(project)
+	if project == "" || (kind == "simple" && filePath != "") || (kind == "files" && filePath == "") || (kind != "simple" && kind != "files") {
+		http.Error(w, "not found: expected /registry/pypi/simple/<project>/ or /registry/pypi/files/<project>/<path>", http.StatusNotFound)
+		return
+	}
+	if !matchesAnyPattern(patterns, project) {
+		h.auditLog(sandboxID, policy.PackageEcosystemPyPI, project, "deny", reasonPackageNotAllowed)
+		writeReasonError(w, http.StatusForbidden, reasonPackageNotAllowed, "project is not allowed by sandbox policy")
+		return
+	}
+
+	indexURL := h.upstreams[policy.PackageEcosystemPyPI] + "/simple/" + project + "/"
+	filesUpstream := h.upstreams[upstreamPyPIFiles]
+	if kind == "simple" {
+		h.auditLog(sandboxID, policy.PackageEcosystemPyPI, project, "allow", "proxied")
+		h.proxyMetadata(w, r, indexURL, pypiSimpleJSON, `"`+filesUpstream+"/", `"`+gatewayURL+"/files/"+project+"/")
+		return
+	}
+
+	h.serveVerified(w, r, sandboxID, policy.PackageEcosystemPyPI, project+"/"+filePath, func(ctx context.Context) (string, string, hash.Hash, error) {
+		return h.pypiFile(ctx, indexURL, filesUpstream+"/"+filePath)
+	})
+}
+
+// pypiFile looks up the sha256 the index published for a distribution file.
+func (h *packageHandler) pypiFile(ctx context.Context, indexURL, fileURL string) (string, string, hash.Hash, error) {
+	var doc struct {
+		Files []struct {
+			URL    string            `json:"url"`
+			Hashes map[string]string `json:"hashes"`
+		} `json:"files"`
+	}
+	if err := h.getJSON(ctx, indexURL, pypiSimpleJSON, &doc); err != nil {
+		return "", "", nil, err
+	}
+	for _, file := range doc.Files {
+		if url, _, _ := strings.Cut(file.URL, "#"); url != fileURL {
+			continue
+		}
+		sum := strings.ToLower(file.Hashes["sha256"])
+		if decoded, err := hex.DecodeString(sum); err != nil || len(decoded) != sha256.Size {
+			return "", "", nil, errNoChecksum
+		}
+		return fileURL, sum, sha256.New(), nil
+	}
+	return "", "", nil, errFileNotFound
+}
+
+// serveGo handles the GOPROXY protocol under /registry/go/, including the
+// checksum database the go command reaches through its proxy. Module zips
+// and go.mod files are verified against the checksum database here too, so
+// a guest that sets GOSUMDB=off or GONOSUMDB still gets verified code.
+func (h *packageHandler) serveGo(w http.ResponseWriter, r *http.Request, sandboxID string, patterns []string, rest string) {
+	upstream := h.upstreams[policy.PackageEcosystemGo] + "/" + rest
+	if sumdbPath, ok := strings.CutPrefix(rest, "sumdb/"+goSumDBName+"/"); ok {
+		h.serveGoSumDB(w, r, sandboxID, patterns, sumdbPath, upstream)
+		return
+	}
+
+	escaped, file, isVersion := strings.Cut(rest, "/@v/")
+	ok := isVersion
+	if !ok {
+		escaped, ok = strings.CutSuffix(rest, "/@latest")
+	}
+	module, err := unescapeModulePath(escaped)
+	if !ok || err != nil {
+		http.Error(w, "not found: expected /registry/go/<module>/@v/... or /registry/go/<module>/@latest", http.StatusNotFound)
+		return
+	}
+	if !matchesAnyModulePattern(patterns, module) {
+		h.auditLog(sandboxID, policy.PackageEcosystemGo, module, "deny", reasonPackageNotAllowed)
+		writeReasonError(w, http.StatusForbidden, reasonPackageNotAllowed, "module is not allowed by sandbox policy")
+		return
+	}
+	if ext := path.Ext(file); isVersion && (ext == ".zip" || ext == ".mod") {
+		version, err := unescapeModulePath(strings.TrimSuffix(file, ext))
+		if err != nil {
+			http.Error(w, "not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		h.serveGoModuleFile(w, r, sandboxID, module, version, ext, upstream)
+		return
+	}
+	h.auditLog(sandboxID, policy.PackageEcosystemGo, module, "allow", "proxied")
+	h.proxyMetadata(w, r, upstream, "", "", "")
+}
+
+// serveGoSumDB proxies the checksum database. Lookups name a module and are
+// held to the policy; tiles and the tree head are shared by every module.
+func (h *packageHandler) serveGoSumDB(w http.ResponseWriter, r *http.Request, sandboxID string, patterns []string, rest, upstreamURL string) {
+	name := "sumdb"
+	if lookup, ok := strings.CutPrefix(rest, "lookup/"); ok {
+		escaped, _, _ := strings.Cut(lookup, "@")
+		module, err := unescapeModulePath(escaped)
+		if err != nil {
+			http.Error(w, "not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		if !matchesAnyModulePattern(patterns, module) {
+			h.auditLog(sandboxID, policy.PackageEcosystemGo, module, "deny", reasonPackageNotAllowed)
+			writeReasonError(w, http.StatusForbidden, reasonPackageNotAllowed, "module is not allowed by sandbox policy")
+			return
+		}
+		name = module
+	}
+	h.auditLog(sandboxID, policy.PackageEcosystemGo, name, "allow", "proxied")
+	h.proxyMetadata(w, r, upstreamURL, "", "", "")
+}
+
+// serveGoModuleFile serves a module's .zip or .mod file once its go.sum
+// hash matches the checksum database. Verified files are cached under that
+// hash.
+func (h *packageHandler) serveGoModuleFile(w http.ResponseWriter, r *http.Request, sandboxID, module, version, ext, fileURL string) {
+	name := module + "@" + version + ext
+	want, err := h.goSum(module, version, ext)
+	if err != nil {
+		h.auditLog(sandboxID, policy.PackageEcosystemGo, name, "deny", reasonChecksumUnavailable)
+		h.writeError(w, err)
+		return
+	}
+	if h.cacheDir == "" {
+		writeReasonError(w, http.StatusBadGateway, reasonUpstreamError, "package cache directory is not configured")
+		return
+	}
+
+	key := sha256.Sum256([]byte(want))
+	dir := filepath.Join(h.cacheDir, policy.PackageEcosystemGo)
+	path := filepath.Join(dir, hex.EncodeToString(key[:]))
+	reason := "cache_hit"
+	if _, err := os.Stat(path); err != nil {
+		reason = "fetched"
+		if err := h.downloadGoModuleFile(r.Context(), fileURL, path, ext, want); err != nil {
+			h.auditLog(sandboxID, policy.PackageEcosystemGo, name, "allow", reasonUpstreamError)
+			h.writeError(w, err)
+			return
+		}
+	}
+	h.auditLog(sandboxID, policy.PackageEcosystemGo, name, "allow", reason)
+	h.serveCachedFile(w, r, path)
+}
+
+// goSum returns the checksum database's h1: hash for a module's zip, or
+// for its go.mod file when ext is ".mod".
+func (h *packageHandler) goSum(module, version, ext string) (string, error) {
+	vers := version
+	if ext == ".mod" {
+		vers += "/go.mod"
+	}
+	lines, err := h.goSumDB.Lookup(module, vers)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errNoChecksum, err)
+	}
+	prefix := module + " " + vers + " h1:"
+	for _, line := range lines {
+		if strings.HasPrefix(line, prefix) {
+			return strings.TrimPrefix(line, module+" "+vers+" "), nil
+		}
+	}
+	return "", errNoChecksum
+}
+
+// downloadGoModuleFile fetches fileURL to path when its go.sum hash is want.
+func (h *packageHandler) downloadGoModuleFile(ctx context.Context, fileURL, path, ext, want string) error {
+	resp, err := h.get(ctx, fileURL, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &upstreamStatusError{status: resp.StatusCode}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".fetch-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("download %s: %w", fileURL, err)
+	}
+
+	var got string
+	if ext == ".mod" {
+		got, err = dirhash.Hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) { return os.Open(tmp.Name()) })
+	} else {
+		got, err = dirhash.HashZip(tmp.Name(), dirhash.Hash1)
+	}
+	if err != nil || got != want {
+		return errDigestMismatch
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// serveVerified serves a file from the cache, downloading it first when it
+// is missing. lookup names the upstream URL and the checksum it must match.
+func (h *packageHandler) serveVerified(w http.ResponseWriter, r *http.Request, sandboxID, ecosystem, name string, lookup func(context.Context) (string, string, hash.Hash, error)) {
+	fileURL, sum, hasher, err := lookup(r.Context())
+	if err != nil {
+		h.auditLog(sandboxID, ecosystem, name, "deny", reasonChecksumUnavailable)
+		h.writeError(w, err)
+		return
+	}
+	if h.cacheDir == "" {
+		writeReasonError(w, http.StatusBadGateway, reasonUpstreamError, "package cache directory is not configured")
+		return
+	}
+
+	dir := filepath.Join(h.cacheDir, ecosystem)
+	path := filepath.Join(dir, sum)
+	reason := "cache_hit"
+	if _, err := os.Stat(path); err != nil {
+		reason = "fetched"
+		if err := h.download(r.Context(), fileURL, dir, sum, hasher); err != nil {
+			h.auditLog(sandboxID, ecosystem, name, "allow", reasonUpstreamError)
+			h.writeError(w, err)
+			return
+		}
+	}
+	h.auditLog(sandboxID, ecosystem, name, "allow", reason)
+	h.serveCachedFile(w, r, path)
+}
+
+func (h *packageHandler) serveCachedFile(w http.ResponseWriter, r *http.Request, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", time.Time{}, f)
+}
+
+func (h *packageHandler) download(ctx context.Context, fileURL, dir, sum string, hasher hash.Hash) error {
+	resp, err := h.get(ctx, fileURL, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &upstreamStatusError{status: resp.StatusCode}
+	}
+	_, err = storeVerified(dir, sum, resp.Body, hasher)
+	return err
+}
+
+// proxyMetadata forwards a request upstream. When from is set, the body is
+// buffered and every occurrence of from replaced with to.
+func (h *packageHandler) proxyMetadata(w http.ResponseWriter, r *http.Request, upstreamURL, accept, from, to string) {
+	resp, err := h.get(r.Context(), upstreamURL, accept)
+	if err != nil {
+		writeReasonError(w, http.StatusBadGateway, reasonUpstreamError, "upstream error")
+		return
+	}
+	defer resp.Body.Close()
+
+	if v := resp.Header.Get("Content-Type"); v != "" {
+		w.Header().Set("Content-Type", v)
+	}
+	if from == "" || resp.StatusCode != http.StatusOK {
+		w.WriteHeader(resp.StatusCode)
+		if r.Method != http.MethodHead {
+			_, _ = io.Copy(w, resp.Body)
+		}
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, packageMetadataMaxBytes+1))
+	if err != nil || len(body) > packageMetadataMaxBytes {
+		writeReasonError(w, http.StatusBadGateway, reasonUpstreamError, "upstream metadata is unreadable or too large")
+		return
+	}
+	body = bytes.ReplaceAll(body, []byte(from), []byte(to))
+	w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(body)
+	}
+}
+
+func (h *packageHandler) getJSON(ctx context.Context, url, accept string, out any) error {
+	resp, err := h.get(ctx, url, accept)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &upstreamStatusError{status: resp.StatusCode}
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, packageMetadataMaxBytes)).Decode(out); err != nil {
+		return fmt.Errorf("decode %s: %w", url, err)
+	}
+	return nil
+}
+
+func (h *packageHandler) get(ctx context.Context, url, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	return h.client.Do(req)
+}
+
+func (h *packageHandler) writeError(w http.ResponseWriter, err error) {
+	var statusErr *upstreamStatusError
+	switch {
+	case errors.Is(err, errDigestMismatch):
+		writeReasonError(w, http.StatusBadGateway, reasonChecksumMismatch, "upstream file does not match its published checksum")
+	case errors.Is(err, errNoChecksum):
+		writeReasonError(w, http.StatusForbidden, reasonChecksumUnavailable, err.Error())
+	case errors.Is(err, errFileNotFound):
+		writeReasonError(w, http.StatusNotFound, reasonChecksumUnavailable, err.Error())
+	case errors.As(err, &statusErr) && statusErr.status == http.StatusNotFound:
+		writeReasonError(w, http.StatusNotFound, reasonUpstreamError, err.Error())
+	default:
+		writeReasonError(w, http.StatusBadGateway, reasonUpstreamError, "upstream error")
+	}
+}
+
+func (h *packageHandler) auditLog(sandboxID, ecosystem, name, action, reason string) {
+	if h.logger == nil {
+		return
+	}
+	h.logger.Info("gateway package request",
+		"sandbox_id", sandboxID,
+		"service", "packages",
+		"ecosystem", ecosystem,
+		"package", name,
+		"action", action,
+		"reason_code", reason,
+	)
+}
+
+func matchesAnyPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAnyModulePattern matches like GOPRIVATE: a pattern matches a module
+// path when it matches that path's leading elements.
+func matchesAnyModulePattern(patterns []string, module string) bool {
+	elems := strings.Split(module, "/")
+	for _, pattern := range patterns {
+		n := strings.Count(pattern, "/") + 1
+		if n > len(elems) {
+			continue
+		}
+		if ok, _ := path.Match(pattern, strings.Join(elems[:n], "/")); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizePyPIName applies the PEP 503 project name normalization.
+func normalizePyPIName(name string) string {
+	return pypiNameRunes.ReplaceAllString(strings.ToLower(name), "-")
+}
+
+// unescapeModulePath reverses the GOPROXY case encoding, where "!a" stands
+// for "A".
+func unescapeModulePath(escaped string) (string, error) {
+	if escaped == "" {
+		return "", errors.New("empty module path")
+	}
+	var b strings.Builder
+	for i := 0; i < len(escaped); i++ {
+		c := escaped[i]
+		if c >= 'A' && c <= 'Z' {
+			return "", fmt.Errorf("module path %q is not case-encoded", escaped)
+		}
+		if c != '!' {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i == len(escaped) || escaped[i] < 'a' || escaped[i] > 'z' {
+			return "", fmt.Errorf("module path %q has an invalid escape", escaped)
+		}
+		b.WriteByte(escaped[i] - 'a' + 'A')
+	}
+	return b.String(), nil
+}
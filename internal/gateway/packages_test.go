@@ -0,0 +1,325 @@
+package gateway
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/buildkite/cleanroom/internal/policy"
+	"golang.org/x/mod/sumdb"
+	"golang.org/x/mod/sumdb/dirhash"
+	"golang.org/x/mod/sumdb/note"
+)
+
+type fakePackageUpstream struct {
+	server    *httptest.Server
+	content   map[string]string
+	fetches   atomic.Int32
+	sumDBKey  string
+	sumDBRoot http.Handler
+}
+
+// newFakePackageUpstream serves npm, PyPI and Go proxy documents from one
+// TLS server. Bodies may reference the server's own URL as {{base}}.
+func newFakePackageUpstream(t *testing.T) *fakePackageUpstream {
+	t.Helper()
+
+	up := &fakePackageUpstream{content: map[string]string{}}
+	up.server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := up.content[r.URL.EscapedPath()]
+		if !ok && strings.HasPrefix(r.URL.Path, "/sumdb/sum.golang.org/") {
+			http.StripPrefix("/sumdb/sum.golang.org", up.sumDBRoot).ServeHTTP(w, r)
+			return
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		up.fetches.Add(1)
+		_, _ = w.Write([]byte(strings.ReplaceAll(body, "{{base}}", up.server.URL)))
+	}))
+	t.Cleanup(up.server.Close)
+
+	tarball := "npm-tarball"
+	sum512 := sha512.Sum512([]byte(tarball))
+	up.content["/@org%2fwidget"] = fmt.Sprintf(`{"versions":{"1.0.0":{"dist":{"tarball":"{{base}}/@org/widget/-/widget-1.0.0.tgz","integrity":"sha512-%s"}}}}`, base64.StdEncoding.EncodeToString(sum512[:]))
+	up.content["/@org/widget/-/widget-1.0.0.tgz"] = tarball
+	up.content["/left-pad"] = `{"versions":{"1.0.0":{"dist":{"tarball":"{{base}}/left-pad/-/left-pad-1.0.0.tgz","integrity":"sha512-AAAA"}}}}`
+
+	wheel := "python-wheel"
+	sum256 := sha256.Sum256([]byte(wheel))
+	up.content["/simple/requests/"] = fmt.Sprintf(`{"files":[{"url":"{{base}}/packages/ab/requests-2.0-py3-none-any.whl","hashes":{"sha256":%q}}]}`, hex.EncodeToString(sum256[:]))
+	up.content["/packages/ab/requests-2.0-py3-none-any.whl"] = "tampered-wheel"
+
+	up.content["/github.com/!org/mod/@v/list"] = "v1.0.0\n"
+	up.content["/sumdb/sum.golang.org/supported"] = ""
+
+	// v1.0.0 is served as published; v1.0.1 is tampered with after the
+	// checksum database recorded it.
+	goMod := "module github.com/Org/mod\n"
+	goSums := map[string]string{}
+	for _, version := range []string{"v1.0.0", "v1.0.1"} {
+		zipData := goModuleZip(t, "github.com/Org/mod@"+version, goMod)
+		goSums[version] = fmt.Sprintf("github.com/Org/mod %s %s\ngithub.com/Org/mod %s/go.mod %s\n", version, goZipHash(t, zipData), version, goModHash(t, goMod))
+		up.content["/github.com/!org/mod/@v/"+version+".zip"] = string(zipData)
+		up.content["/github.com/!org/mod/@v/"+version+".mod"] = goMod
+	}
+	up.content["/github.com/!org/mod/@v/v1.0.1.zip"] = string(goModuleZip(t, "github.com/Org/mod@v1.0.1", "module github.com/Evil/mod\n"))
+	skey, vkey, err := note.GenerateKey(rand.Reader, "sum.golang.org")
+	if err != nil {
+		t.Fatalf("generate checksum database key: %v", err)
+	}
+	up.sumDBKey = vkey
+	up.sumDBRoot = sumdb.NewServer(sumdb.NewTestServer(skey, func(path, vers string) ([]byte, error) {
+		if path != "github.com/Org/mod" || goSums[vers] == "" {
+			return nil, os.ErrNotExist
+		}
+		return []byte(goSums[vers]), nil
+	}))
+	return up
+}
+
+func goModuleZip(t *testing.T, prefix, goMod string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.Create(prefix + "/go.mod")
+	if err == nil {
+		_, err = io.WriteString(f, goMod)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		t.Fatalf("build module zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func goZipHash(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mod.zip")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write module zip: %v", err)
+	}
+	sum, err := dirhash.HashZip(path, dirhash.Hash1)
+	if err != nil {
+		t.Fatalf("hash module zip: %v", err)
+	}
+	return sum
+}
+
+func goModHash(t *testing.T, goMod string) string {
+	t.Helper()
+	sum, err := dirhash.Hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(goMod)), nil
+	})
+	if err != nil {
+		t.Fatalf("hash go.mod: %v", err)
+	}
+	return sum
+}
+
+func newTestPackageHandler(t *testing.T, up *fakePackageUpstream) *packageHandler {
+	h := newPackageHandler(t.TempDir(), nil)
+	h.client = up.server.Client()
+	h.upstreams = map[string]string{
+		policy.PackageEcosystemNPM:  up.server.URL,
+		policy.PackageEcosystemPyPI: up.server.URL,
+		upstreamPyPIFiles:           up.server.URL,
+		policy.PackageEcosystemGo:   up.server.URL,
+	}
+	h.goSumDBKey = up.sumDBKey
+	return h
+}
+
+func packagesTestScope() *SandboxScope {
+	return &SandboxScope{
+		SandboxID: "sandbox-test",
+		GuestIP:   "10.1.1.2",
+		Policy: &policy.CompiledPolicy{
+			Version:        1,
+			NetworkDefault: "deny",
+			Services: policy.Services{
+				Packages: &policy.PackagesService{
+					NPM:  []string{"@org/*", "left-pad"},
+					PyPI: []string{"requests"},
+					Go:   []string{"github.com/Org"},
+				},
+			},
+		},
+	}
+}
+
+func servePackages(h *packageHandler, method, path string) *httptest.ResponseRecorder {
+	req := withScope(httptest.NewRequest(method, path, nil), packagesTestScope())
+	req.Host = "10.1.1.1:8170"
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestPackageHandlerNPMRewritesMetadataAndVerifiesTarballs(t *testing.T) {
+	t.Parallel()
+
+	up := newFakePackageUpstream(t)
+	h := newTestPackageHandler(t, up)
+
+	w := servePackages(h, http.MethodGet, "/registry/npm/@org/widget")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected packument status: got %d want %d (%s)", w.Code, http.StatusOK, w.Body.String())
+	}
+	if want := `"http://10.1.1.1:8170/registry/npm/@org/widget/-/widget-1.0.0.tgz"`; !strings.Contains(w.Body.String(), want) {
+		t.Fatalf("expected tarball URL rewritten to %s, got %s", want, w.Body.String())
+	}
+
+	for range 2 {
+		w = servePackages(h, http.MethodGet, "/registry/npm/@org/widget/-/widget-1.0.0.tgz")
+		if w.Code != http.StatusOK || w.Body.String() != "npm-tarball" {
+			t.Fatalf("unexpected tarball response: got %d %q", w.Code, w.Body.String())
+		}
+	}
+	// Packument, packument and tarball, then packument only once cached.
+	if got := up.fetches.Load(); got != 4 {
+		t.Fatalf("unexpected upstream fetch count: got %d want 4", got)
+	}
+
+	w = servePackages(h, http.MethodGet, "/registry/npm/left-pad/-/left-pad-1.0.0.tgz")
+	if w.Code != http.StatusForbidden || w.Header().Get(reasonCodeHeader) != reasonChecksumUnavailable {
+		t.Fatalf("expected tarball without sha512 integrity to be refused: got %d %q", w.Code, w.Header().Get(reasonCodeHeader))
+	}
+}
+
+func TestPackageHandlerPyPIRejectsChecksumMismatch(t *testing.T) {
+	t.Parallel()
+
+	up := newFakePackageUpstream(t)
+	h := newTestPackageHandler(t, up)
+
+	w := servePackages(h, http.MethodGet, "/registry/pypi/simple/Requests")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected index status: got %d want %d (%s)", w.Code, http.StatusOK, w.Body.String())
+	}
+	if want := `"http://10.1.1.1:8170/registry/pypi/files/requests/packages/ab/requests-2.0-py3-none-any.whl"`; !strings.Contains(w.Body.String(), want) {
+		t.Fatalf("expected file URL rewritten to %s, got %s", want, w.Body.String())
+	}
+
+	w = servePackages(h, http.MethodGet, "/registry/pypi/files/requests/packages/ab/requests-2.0-py3-none-any.whl")
+	if w.Code != http.StatusBadGateway || w.Header().Get(reasonCodeHeader) != reasonChecksumMismatch {
+		t.Fatalf("expected checksum mismatch: got %d %q", w.Code, w.Header().Get(reasonCodeHeader))
+	}
+}
+
+func TestPackageHandlerGoProxy(t *testing.T) {
+	t.Parallel()
+
+	up := newFakePackageUpstream(t)
+	h := newTestPackageHandler(t, up)
+
+	w := servePackages(h, http.MethodGet, "/registry/go/github.com/!org/mod/@v/list")
+	if w.Code != http.StatusOK || w.Body.String() != "v1.0.0\n" {
+		t.Fatalf("unexpected module list response: got %d %q", w.Code, w.Body.String())
+	}
+	w = servePackages(h, http.MethodGet, "/registry/go/sumdb/sum.golang.org/supported")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected sumdb status: got %d want %d", w.Code, http.StatusOK)
+	}
+	for _, path := range []string{
+		"/registry/go/github.com/other/mod/@latest",
+		"/registry/go/sumdb/sum.golang.org/lookup/github.com/other/mod@v1.0.0",
+	} {
+		w = servePackages(h, http.MethodGet, path)
+		if w.Code != http.StatusForbidden || w.Header().Get(reasonCodeHeader) != reasonPackageNotAllowed {
+			t.Fatalf("GET %s: expected module outside policy to be denied: got %d %q", path, w.Code, w.Header().Get(reasonCodeHeader))
+		}
+	}
+	w = servePackages(h, http.MethodGet, "/registry/go/sumdb/sum.golang.org/lookup/github.com/!org/mod@v1.0.0")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected sumdb lookup status: got %d want %d (%s)", w.Code, http.StatusOK, w.Body.String())
+	}
+}
+
+func TestPackageHandlerGoVerifiesModulesAgainstChecksumDatabase(t *testing.T) {
+	t.Parallel()
+
+	up := newFakePackageUpstream(t)
+	h := newTestPackageHandler(t, up)
+
+	for _, file := range []string{"v1.0.0.zip", "v1.0.0.mod"} {
+		path := "/registry/go/github.com/!org/mod/@v/" + file
+		w := servePackages(h, http.MethodGet, path)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: unexpected status: got %d want %d (%s)", path, w.Code, http.StatusOK, w.Body.String())
+		}
+		if got, want := w.Body.String(), up.content["/github.com/!org/mod/@v/"+file]; got != want {
+			t.Fatalf("GET %s: unexpected body", path)
+		}
+	}
+	fetches := up.fetches.Load()
+	if w := servePackages(h, http.MethodGet, "/registry/go/github.com/!org/mod/@v/v1.0.0.zip"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status for cached zip: got %d", w.Code)
+	}
+	if got := up.fetches.Load(); got != fetches {
+		t.Fatalf("expected the verified zip to be served from the cache: got %d fetches want %d", got, fetches)
+	}
+
+	w := servePackages(h, http.MethodGet, "/registry/go/github.com/!org/mod/@v/v1.0.1.zip")
+	if w.Code != http.StatusBadGateway || w.Header().Get(reasonCodeHeader) != reasonChecksumMismatch {
+		t.Fatalf("expected tampered zip to be refused: got %d %q", w.Code, w.Header().Get(reasonCodeHeader))
+	}
+	w = servePackages(h, http.MethodGet, "/registry/go/github.com/!org/mod/@v/v9.0.0.zip")
+	if w.Code != http.StatusForbidden || w.Header().Get(reasonCodeHeader) != reasonChecksumUnavailable {
+		t.Fatalf("expected a version missing from the checksum database to be refused: got %d %q", w.Code, w.Header().Get(reasonCodeHeader))
+	}
+}
+
+func TestPackageHandlerDeniesUnlistedPackagesAndMethods(t *testing.T) {
+	t.Parallel()
+
+	h := newPackageHandler(t.TempDir(), nil)
+	for _, tc := range []struct {
+		method string
+		path   string
+		reason string
+	}{
+		{http.MethodGet, "/registry/npm/lodash", reasonPackageNotAllowed},
+		{http.MethodGet, "/registry/pypi/simple/django", reasonPackageNotAllowed},
+		{http.MethodGet, "/registry/cargo/crates/serde", reasonPackageNotAllowed},
+		{http.MethodPut, "/registry/npm/@org/widget", reasonMethodNotAllowed},
+	} {
+		w := servePackages(h, tc.method, tc.path)
+		if w.Code != http.StatusForbidden || w.Header().Get(reasonCodeHeader) != tc.reason {
+			t.Fatalf("%s %s: unexpected response: got %d %q want 403 %q", tc.method, tc.path, w.Code, w.Header().Get(reasonCodeHeader), tc.reason)
+		}
+	}
+}
+
+func TestMatchesAnyModulePattern(t *testing.T) {
+	t.Parallel()
+
+	patterns := []string{"golang.org/x/*", "github.com/org"}
+	for module, want := range map[string]bool{
+		"golang.org/x/mod":          true,
+		"golang.org/x/tools/gopls":  true,
+		"golang.org/y/mod":          false,
+		"github.com/org/repo":       true,
+		"github.com/organisation/r": false,
+	} {
+		if got := matchesAnyModulePattern(patterns, module); got != want {
+			t.Fatalf("matchesAnyModulePattern(%q): got %v want %v", module, got, want)
+		}
+	}
+}
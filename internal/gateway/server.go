@@ -20,11 +20,15 @@ const (
 	RouteRegistry = "/registry/"
 	RouteSecrets  = "/secrets/"
 	RouteMeta     = "/meta/"
+	// RouteOCIRegistry serves the OCI distribution API, which clients
+	// always address at /v2/ on the registry host.
+	RouteOCIRegistry = "/v2/"
 )
 
 var serviceRoutes = []string{
 	RouteGit,
 	RouteRegistry,
+	RouteOCIRegistry,
 	RouteSecrets,
 	RouteMeta,
 }
@@ -56,6 +60,15 @@ type ServerConfig struct {
 	Registry    *Registry
 	Credentials CredentialProvider
 	Logger      *log.Logger
+	// OCIRegistryCacheDir holds verified manifests and blobs fetched by the
+	// OCI registry proxy. The proxy refuses digest requests when it is empty.
+	OCIRegistryCacheDir string
+	// OCIRegistryCacheMaxBytes bounds OCIRegistryCacheDir. The least recently
+	// used content is evicted past it; zero means 20 GiB.
+	OCIRegistryCacheMaxBytes int64
+	// PackageCacheDir holds npm and PyPI files the package proxy verified
+	// against their published checksums.
+	PackageCacheDir string
 }
 
 // Server is the host gateway HTTP server.
@@ -84,7 +97,11 @@ func NewServer(cfg ServerConfig) *Server {
 
 	mux := http.NewServeMux()
 	mux.Handle(RouteGit, newGitHandler(cfg.Credentials, cfg.Logger))
-	mux.HandleFunc(RouteRegistry, stubHandler("registry"))
+	mux.Handle(RouteRegistry, newPackageHandler(cfg.PackageCacheDir, cfg.Logger))
+	ociRegistry := newOCIRegistryHandler(cfg.OCIRegistryCacheDir, cfg.OCIRegistryCacheMaxBytes, cfg.Credentials, cfg.Logger)
+	mux.Handle(RouteOCIRegistry, ociRegistry)
+	// pathMiddleware strips the trailing slash from the /v2/ version check.
+	mux.Handle(strings.TrimSuffix(RouteOCIRegistry, "/"), ociRegistry)
 	mux.HandleFunc(RouteSecrets, stubHandler("secrets"))
 	mux.HandleFunc(RouteMeta, stubHandler("meta"))
 
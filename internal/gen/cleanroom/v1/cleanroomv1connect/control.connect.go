@@ -25,6 +25,8 @@ const (
 	SandboxServiceName = "cleanroom.v1.SandboxService"
 	// ExecutionServiceName is the fully-qualified name of the ExecutionService service.
 	ExecutionServiceName = "cleanroom.v1.ExecutionService"
+	// ServerServiceName is the fully-qualified name of the ServerService service.
+	ServerServiceName = "cleanroom.v1.ServerService"
 )
 
 // These constants are the fully-qualified names of the RPCs defined in this package. They're
@@ -38,6 +40,9 @@ const (
 	// SandboxServiceCreateSandboxProcedure is the fully-qualified name of the SandboxService's
 	// CreateSandbox RPC.
 	SandboxServiceCreateSandboxProcedure = "/cleanroom.v1.SandboxService/CreateSandbox"
+	// SandboxServiceCreateSandboxGroupProcedure is the fully-qualified name of the SandboxService's
+	// CreateSandboxGroup RPC.
+	SandboxServiceCreateSandboxGroupProcedure = "/cleanroom.v1.SandboxService/CreateSandboxGroup"
 	// SandboxServiceGetSandboxProcedure is the fully-qualified name of the SandboxService's GetSandbox
 	// RPC.
 	SandboxServiceGetSandboxProcedure = "/cleanroom.v1.SandboxService/GetSandbox"
@@ -47,6 +52,21 @@ const (
 	// SandboxServiceDownloadSandboxFileProcedure is the fully-qualified name of the SandboxService's
 	// DownloadSandboxFile RPC.
 	SandboxServiceDownloadSandboxFileProcedure = "/cleanroom.v1.SandboxService/DownloadSandboxFile"
+	// SandboxServiceCommitSandboxProcedure is the fully-qualified name of the SandboxService's
+	// CommitSandbox RPC.
+	SandboxServiceCommitSandboxProcedure = "/cleanroom.v1.SandboxService/CommitSandbox"
+	// SandboxServiceCloneSandboxProcedure is the fully-qualified name of the SandboxService's
+	// CloneSandbox RPC.
+	SandboxServiceCloneSandboxProcedure = "/cleanroom.v1.SandboxService/CloneSandbox"
+	// SandboxServiceUpgradeSandboxAgentProcedure is the fully-qualified name of the SandboxService's
+	// UpgradeSandboxAgent RPC.
+	SandboxServiceUpgradeSandboxAgentProcedure = "/cleanroom.v1.SandboxService/UpgradeSandboxAgent"
+	// SandboxServicePauseSandboxProcedure is the fully-qualified name of the SandboxService's
+	// PauseSandbox RPC.
+	SandboxServicePauseSandboxProcedure = "/cleanroom.v1.SandboxService/PauseSandbox"
+	// SandboxServiceResumeSandboxProcedure is the fully-qualified name of the SandboxService's
+	// ResumeSandbox RPC.
+	SandboxServiceResumeSandboxProcedure = "/cleanroom.v1.SandboxService/ResumeSandbox"
 	// SandboxServiceTerminateSandboxProcedure is the fully-qualified name of the SandboxService's
 	// TerminateSandbox RPC.
 	SandboxServiceTerminateSandboxProcedure = "/cleanroom.v1.SandboxService/TerminateSandbox"
@@ -65,17 +85,55 @@ const (
 	// ExecutionServiceCancelExecutionProcedure is the fully-qualified name of the ExecutionService's
 	// CancelExecution RPC.
 	ExecutionServiceCancelExecutionProcedure = "/cleanroom.v1.ExecutionService/CancelExecution"
+	// ExecutionServiceWriteExecutionStdinProcedure is the fully-qualified name of the
+	// ExecutionService's WriteExecutionStdin RPC.
+	ExecutionServiceWriteExecutionStdinProcedure = "/cleanroom.v1.ExecutionService/WriteExecutionStdin"
 	// ExecutionServiceStreamExecutionProcedure is the fully-qualified name of the ExecutionService's
 	// StreamExecution RPC.
 	ExecutionServiceStreamExecutionProcedure = "/cleanroom.v1.ExecutionService/StreamExecution"
+	// ExecutionServiceListPendingApprovalsProcedure is the fully-qualified name of the
+	// ExecutionService's ListPendingApprovals RPC.
+	ExecutionServiceListPendingApprovalsProcedure = "/cleanroom.v1.ExecutionService/ListPendingApprovals"
+	// ExecutionServiceResolveExecutionApprovalProcedure is the fully-qualified name of the
+	// ExecutionService's ResolveExecutionApproval RPC.
+	ExecutionServiceResolveExecutionApprovalProcedure = "/cleanroom.v1.ExecutionService/ResolveExecutionApproval"
+	// ExecutionServiceAnnotateExecutionProcedure is the fully-qualified name of the ExecutionService's
+	// AnnotateExecution RPC.
+	ExecutionServiceAnnotateExecutionProcedure = "/cleanroom.v1.ExecutionService/AnnotateExecution"
+	// ExecutionServiceListExecutionsProcedure is the fully-qualified name of the ExecutionService's
+	// ListExecutions RPC.
+	ExecutionServiceListExecutionsProcedure = "/cleanroom.v1.ExecutionService/ListExecutions"
+	// ServerServiceGetServerInfoProcedure is the fully-qualified name of the ServerService's
+	// GetServerInfo RPC.
+	ServerServiceGetServerInfoProcedure = "/cleanroom.v1.ServerService/GetServerInfo"
+	// ServerServiceGetUsageProcedure is the fully-qualified name of the ServerService's GetUsage RPC.
+	ServerServiceGetUsageProcedure = "/cleanroom.v1.ServerService/GetUsage"
+	// ServerServiceCreateScheduleProcedure is the fully-qualified name of the ServerService's
+	// CreateSchedule RPC.
+	ServerServiceCreateScheduleProcedure = "/cleanroom.v1.ServerService/CreateSchedule"
+	// ServerServiceListSchedulesProcedure is the fully-qualified name of the ServerService's
+	// ListSchedules RPC.
+	ServerServiceListSchedulesProcedure = "/cleanroom.v1.ServerService/ListSchedules"
+	// ServerServiceGetScheduleProcedure is the fully-qualified name of the ServerService's GetSchedule
+	// RPC.
+	ServerServiceGetScheduleProcedure = "/cleanroom.v1.ServerService/GetSchedule"
+	// ServerServiceDeleteScheduleProcedure is the fully-qualified name of the ServerService's
+	// DeleteSchedule RPC.
+	ServerServiceDeleteScheduleProcedure = "/cleanroom.v1.ServerService/DeleteSchedule"
 )
 
 // SandboxServiceClient is a client for the cleanroom.v1.SandboxService service.
 type SandboxServiceClient interface {
 	CreateSandbox(context.Context, *connect.Request[v1.CreateSandboxRequest]) (*connect.Response[v1.CreateSandboxResponse], error)
+	CreateSandboxGroup(context.Context, *connect.Request[v1.CreateSandboxGroupRequest]) (*connect.Response[v1.CreateSandboxGroupResponse], error)
 	GetSandbox(context.Context, *connect.Request[v1.GetSandboxRequest]) (*connect.Response[v1.GetSandboxResponse], error)
 	ListSandboxes(context.Context, *connect.Request[v1.ListSandboxesRequest]) (*connect.Response[v1.ListSandboxesResponse], error)
 	DownloadSandboxFile(context.Context, *connect.Request[v1.DownloadSandboxFileRequest]) (*connect.Response[v1.DownloadSandboxFileResponse], error)
+	CommitSandbox(context.Context, *connect.Request[v1.CommitSandboxRequest]) (*connect.Response[v1.CommitSandboxResponse], error)
+	CloneSandbox(context.Context, *connect.Request[v1.CloneSandboxRequest]) (*connect.Response[v1.CloneSandboxResponse], error)
+	UpgradeSandboxAgent(context.Context, *connect.Request[v1.UpgradeSandboxAgentRequest]) (*connect.Response[v1.UpgradeSandboxAgentResponse], error)
+	PauseSandbox(context.Context, *connect.Request[v1.PauseSandboxRequest]) (*connect.Response[v1.PauseSandboxResponse], error)
+	ResumeSandbox(context.Context, *connect.Request[v1.ResumeSandboxRequest]) (*connect.Response[v1.ResumeSandboxResponse], error)
 	TerminateSandbox(context.Context, *connect.Request[v1.TerminateSandboxRequest]) (*connect.Response[v1.TerminateSandboxResponse], error)
 	StreamSandboxEvents(context.Context, *connect.Request[v1.StreamSandboxEventsRequest]) (*connect.ServerStreamForClient[v1.SandboxEvent], error)
 }
@@ -97,6 +155,12 @@ func NewSandboxServiceClient(httpClient connect.HTTPClient, baseURL string, opts
 			connect.WithSchema(sandboxServiceMethods.ByName("CreateSandbox")),
 			connect.WithClientOptions(opts...),
 		),
+		createSandboxGroup: connect.NewClient[v1.CreateSandboxGroupRequest, v1.CreateSandboxGroupResponse](
+			httpClient,
+			baseURL+SandboxServiceCreateSandboxGroupProcedure,
+			connect.WithSchema(sandboxServiceMethods.ByName("CreateSandboxGroup")),
+			connect.WithClientOptions(opts...),
+		),
 		getSandbox: connect.NewClient[v1.GetSandboxRequest, v1.GetSandboxResponse](
 			httpClient,
 			baseURL+SandboxServiceGetSandboxProcedure,
@@ -115,6 +179,36 @@ func NewSandboxServiceClient(httpClient connect.HTTPClient, baseURL string, opts
 			connect.WithSchema(sandboxServiceMethods.ByName("DownloadSandboxFile")),
 			connect.WithClientOptions(opts...),
 		),
+		commitSandbox: connect.NewClient[v1.CommitSandboxRequest, v1.CommitSandboxResponse](
+			httpClient,
+			baseURL+SandboxServiceCommitSandboxProcedure,
+			connect.WithSchema(sandboxServiceMethods.ByName("CommitSandbox")),
+			connect.WithClientOptions(opts...),
+		),
+		cloneSandbox: connect.NewClient[v1.CloneSandboxRequest, v1.CloneSandboxResponse](
+			httpClient,
+			baseURL+SandboxServiceCloneSandboxProcedure,
+			connect.WithSchema(sandboxServiceMethods.ByName("CloneSandbox")),
+			connect.WithClientOptions(opts...),
+		),
+		upgradeSandboxAgent: connect.NewClient[v1.UpgradeSandboxAgentRequest, v1.UpgradeSandboxAgentResponse](
+			httpClient,
+			baseURL+SandboxServiceUpgradeSandboxAgentProcedure,
+			connect.WithSchema(sandboxServiceMethods.ByName("UpgradeSandboxAgent")),
+			connect.WithClientOptions(opts...),
+		),
+		pauseSandbox: connect.NewClient[v1.PauseSandboxRequest, v1.PauseSandboxResponse](
+			httpClient,
+			baseURL+SandboxServicePauseSandboxProcedure,
+			connect.WithSchema(sandboxServiceMethods.ByName("PauseSandbox")),
+			connect.WithClientOptions(opts...),
+		),
+		resumeSandbox: connect.NewClient[v1.ResumeSandboxRequest, v1.ResumeSandboxResponse](
+			httpClient,
+			baseURL+SandboxServiceResumeSandboxProcedure,
+			connect.WithSchema(sandboxServiceMethods.ByName("ResumeSandbox")),
+			connect.WithClientOptions(opts...),
+		),
 		terminateSandbox: connect.NewClient[v1.TerminateSandboxRequest, v1.TerminateSandboxResponse](
 			httpClient,
 			baseURL+SandboxServiceTerminateSandboxProcedure,
@@ -133,9 +227,15 @@ func NewSandboxServiceClient(httpClient connect.HTTPClient, baseURL string, opts
 // sandboxServiceClient implements SandboxServiceClient.
 type sandboxServiceClient struct {
 	createSandbox       *connect.Client[v1.CreateSandboxRequest, v1.CreateSandboxResponse]
+	createSandboxGroup  *connect.Client[v1.CreateSandboxGroupRequest, v1.CreateSandboxGroupResponse]
 	getSandbox          *connect.Client[v1.GetSandboxRequest, v1.GetSandboxResponse]
 	listSandboxes       *connect.Client[v1.ListSandboxesRequest, v1.ListSandboxesResponse]
 	downloadSandboxFile *connect.Client[v1.DownloadSandboxFileRequest, v1.DownloadSandboxFileResponse]
+	commitSandbox       *connect.Client[v1.CommitSandboxRequest, v1.CommitSandboxResponse]
+	cloneSandbox        *connect.Client[v1.CloneSandboxRequest, v1.CloneSandboxResponse]
+	upgradeSandboxAgent *connect.Client[v1.UpgradeSandboxAgentRequest, v1.UpgradeSandboxAgentResponse]
+	pauseSandbox        *connect.Client[v1.PauseSandboxRequest, v1.PauseSandboxResponse]
+	resumeSandbox       *connect.Client[v1.ResumeSandboxRequest, v1.ResumeSandboxResponse]
 	terminateSandbox    *connect.Client[v1.TerminateSandboxRequest, v1.TerminateSandboxResponse]
 	streamSandboxEvents *connect.Client[v1.StreamSandboxEventsRequest, v1.SandboxEvent]
 }
@@ -145,6 +245,11 @@ func (c *sandboxServiceClient) CreateSandbox(ctx context.Context, req *connect.R
 	return c.createSandbox.CallUnary(ctx, req)
 }
 
+// CreateSandboxGroup calls cleanroom.v1.SandboxService.CreateSandboxGroup.
+func (c *sandboxServiceClient) CreateSandboxGroup(ctx context.Context, req *connect.Request[v1.CreateSandboxGroupRequest]) (*connect.Response[v1.CreateSandboxGroupResponse], error) {
+	return c.createSandboxGroup.CallUnary(ctx, req)
+}
+
 // GetSandbox calls cleanroom.v1.SandboxService.GetSandbox.
 func (c *sandboxServiceClient) GetSandbox(ctx context.Context, req *connect.Request[v1.GetSandboxRequest]) (*connect.Response[v1.GetSandboxResponse], error) {
 	return c.getSandbox.CallUnary(ctx, req)
@@ -160,6 +265,31 @@ func (c *sandboxServiceClient) DownloadSandboxFile(ctx context.Context, req *con
 	return c.downloadSandboxFile.CallUnary(ctx, req)
 }
 
+// CommitSandbox calls cleanroom.v1.SandboxService.CommitSandbox.
+func (c *sandboxServiceClient) CommitSandbox(ctx context.Context, req *connect.Request[v1.CommitSandboxRequest]) (*connect.Response[v1.CommitSandboxResponse], error) {
+	return c.commitSandbox.CallUnary(ctx, req)
+}
+
+// CloneSandbox calls cleanroom.v1.SandboxService.CloneSandbox.
+func (c *sandboxServiceClient) CloneSandbox(ctx context.Context, req *connect.Request[v1.CloneSandboxRequest]) (*connect.Response[v1.CloneSandboxResponse], error) {
+	return c.cloneSandbox.CallUnary(ctx, req)
+}
+
+// UpgradeSandboxAgent calls cleanroom.v1.SandboxService.UpgradeSandboxAgent.
+func (c *sandboxServiceClient) UpgradeSandboxAgent(ctx context.Context, req *connect.Request[v1.UpgradeSandboxAgentRequest]) (*connect.Response[v1.UpgradeSandboxAgentResponse], error) {
+	return c.upgradeSandboxAgent.CallUnary(ctx, req)
+}
+
+// PauseSandbox calls cleanroom.v1.SandboxService.PauseSandbox.
+func (c *sandboxServiceClient) PauseSandbox(ctx context.Context, req *connect.Request[v1.PauseSandboxRequest]) (*connect.Response[v1.PauseSandboxResponse], error) {
+	return c.pauseSandbox.CallUnary(ctx, req)
+}
+
+// ResumeSandbox calls cleanroom.v1.SandboxService.ResumeSandbox.
+func (c *sandboxServiceClient) ResumeSandbox(ctx context.Context, req *connect.Request[v1.ResumeSandboxRequest]) (*connect.Response[v1.ResumeSandboxResponse], error) {
+	return c.resumeSandbox.CallUnary(ctx, req)
+}
+
 // TerminateSandbox calls cleanroom.v1.SandboxService.TerminateSandbox.
 func (c *sandboxServiceClient) TerminateSandbox(ctx context.Context, req *connect.Request[v1.TerminateSandboxRequest]) (*connect.Response[v1.TerminateSandboxResponse], error) {
 	return c.terminateSandbox.CallUnary(ctx, req)
@@ -173,9 +303,15 @@ func (c *sandboxServiceClient) StreamSandboxEvents(ctx context.Context, req *con
 // SandboxServiceHandler is an implementation of the cleanroom.v1.SandboxService service.
 type SandboxServiceHandler interface {
 	CreateSandbox(context.Context, *connect.Request[v1.CreateSandboxRequest]) (*connect.Response[v1.CreateSandboxResponse], error)
+	CreateSandboxGroup(context.Context, *connect.Request[v1.CreateSandboxGroupRequest]) (*connect.Response[v1.CreateSandboxGroupResponse], error)
 	GetSandbox(context.Context, *connect.Request[v1.GetSandboxRequest]) (*connect.Response[v1.GetSandboxResponse], error)
 	ListSandboxes(context.Context, *connect.Request[v1.ListSandboxesRequest]) (*connect.Response[v1.ListSandboxesResponse], error)
 	DownloadSandboxFile(context.Context, *connect.Request[v1.DownloadSandboxFileRequest]) (*connect.Response[v1.DownloadSandboxFileResponse], error)
+	CommitSandbox(context.Context, *connect.Request[v1.CommitSandboxRequest]) (*connect.Response[v1.CommitSandboxResponse], error)
+	CloneSandbox(context.Context, *connect.Request[v1.CloneSandboxRequest]) (*connect.Response[v1.CloneSandboxResponse], error)
+	UpgradeSandboxAgent(context.Context, *connect.Request[v1.UpgradeSandboxAgentRequest]) (*connect.Response[v1.UpgradeSandboxAgentResponse], error)
+	PauseSandbox(context.Context, *connect.Request[v1.PauseSandboxRequest]) (*connect.Response[v1.PauseSandboxResponse], error)
+	ResumeSandbox(context.Context, *connect.Request[v1.ResumeSandboxRequest]) (*connect.Response[v1.ResumeSandboxResponse], error)
 	TerminateSandbox(context.Context, *connect.Request[v1.TerminateSandboxRequest]) (*connect.Response[v1.TerminateSandboxResponse], error)
 	StreamSandboxEvents(context.Context, *connect.Request[v1.StreamSandboxEventsRequest], *connect.ServerStream[v1.SandboxEvent]) error
 }
@@ -193,6 +329,12 @@ func NewSandboxServiceHandler(svc SandboxServiceHandler, opts ...connect.Handler
 		connect.WithSchema(sandboxServiceMethods.ByName("CreateSandbox")),
 		connect.WithHandlerOptions(opts...),
 	)
+	sandboxServiceCreateSandboxGroupHandler := connect.NewUnaryHandler(
+		SandboxServiceCreateSandboxGroupProcedure,
+		svc.CreateSandboxGroup,
+		connect.WithSchema(sandboxServiceMethods.ByName("CreateSandboxGroup")),
+		connect.WithHandlerOptions(opts...),
+	)
 	sandboxServiceGetSandboxHandler := connect.NewUnaryHandler(
 		SandboxServiceGetSandboxProcedure,
 		svc.GetSandbox,
@@ -211,6 +353,36 @@ func NewSandboxServiceHandler(svc SandboxServiceHandler, opts ...connect.Handler
 		connect.WithSchema(sandboxServiceMethods.ByName("DownloadSandboxFile")),
 		connect.WithHandlerOptions(opts...),
 	)
+	sandboxServiceCommitSandboxHandler := connect.NewUnaryHandler(
+		SandboxServiceCommitSandboxProcedure,
+		svc.CommitSandbox,
+		connect.WithSchema(sandboxServiceMethods.ByName("CommitSandbox")),
+		connect.WithHandlerOptions(opts...),
+	)
+	sandboxServiceCloneSandboxHandler := connect.NewUnaryHandler(
+		SandboxServiceCloneSandboxProcedure,
+		svc.CloneSandbox,
+		connect.WithSchema(sandboxServiceMethods.ByName("CloneSandbox")),
+		connect.WithHandlerOptions(opts...),
+	)
+	sandboxServiceUpgradeSandboxAgentHandler := connect.NewUnaryHandler(
+		SandboxServiceUpgradeSandboxAgentProcedure,
+		svc.UpgradeSandboxAgent,
+		connect.WithSchema(sandboxServiceMethods.ByName("UpgradeSandboxAgent")),
+		connect.WithHandlerOptions(opts...),
+	)
+	sandboxServicePauseSandboxHandler := connect.NewUnaryHandler(
+		SandboxServicePauseSandboxProcedure,
+		svc.PauseSandbox,
+		connect.WithSchema(sandboxServiceMethods.ByName("PauseSandbox")),
+		connect.WithHandlerOptions(opts...),
+	)
+	sandboxServiceResumeSandboxHandler := connect.NewUnaryHandler(
+		SandboxServiceResumeSandboxProcedure,
+		svc.ResumeSandbox,
+		connect.WithSchema(sandboxServiceMethods.ByName("ResumeSandbox")),
+		connect.WithHandlerOptions(opts...),
+	)
 	sandboxServiceTerminateSandboxHandler := connect.NewUnaryHandler(
 		SandboxServiceTerminateSandboxProcedure,
 		svc.TerminateSandbox,
@@ -227,12 +399,24 @@ func NewSandboxServiceHandler(svc SandboxServiceHandler, opts ...connect.Handler
 		switch r.URL.Path {
 		case SandboxServiceCreateSandboxProcedure:
 			sandboxServiceCreateSandboxHandler.ServeHTTP(w, r)
+		case SandboxServiceCreateSandboxGroupProcedure:
+			sandboxServiceCreateSandboxGroupHandler.ServeHTTP(w, r)
 		case SandboxServiceGetSandboxProcedure:
 			sandboxServiceGetSandboxHandler.ServeHTTP(w, r)
 		case SandboxServiceListSandboxesProcedure:
 			sandboxServiceListSandboxesHandler.ServeHTTP(w, r)
 		case SandboxServiceDownloadSandboxFileProcedure:
 			sandboxServiceDownloadSandboxFileHandler.ServeHTTP(w, r)
+		case SandboxServiceCommitSandboxProcedure:
+			sandboxServiceCommitSandboxHandler.ServeHTTP(w, r)
+		case SandboxServiceCloneSandboxProcedure:
+			sandboxServiceCloneSandboxHandler.ServeHTTP(w, r)
+		case SandboxServiceUpgradeSandboxAgentProcedure:
+			sandboxServiceUpgradeSandboxAgentHandler.ServeHTTP(w, r)
+		case SandboxServicePauseSandboxProcedure:
+			sandboxServicePauseSandboxHandler.ServeHTTP(w, r)
+		case SandboxServiceResumeSandboxProcedure:
+			sandboxServiceResumeSandboxHandler.ServeHTTP(w, r)
 		case SandboxServiceTerminateSandboxProcedure:
 			sandboxServiceTerminateSandboxHandler.ServeHTTP(w, r)
 		case SandboxServiceStreamSandboxEventsProcedure:
@@ -250,6 +434,10 @@ func (UnimplementedSandboxServiceHandler) CreateSandbox(context.Context, *connec
 	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.SandboxService.CreateSandbox is not implemented"))
 }
 
+func (UnimplementedSandboxServiceHandler) CreateSandboxGroup(context.Context, *connect.Request[v1.CreateSandboxGroupRequest]) (*connect.Response[v1.CreateSandboxGroupResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.SandboxService.CreateSandboxGroup is not implemented"))
+}
+
 func (UnimplementedSandboxServiceHandler) GetSandbox(context.Context, *connect.Request[v1.GetSandboxRequest]) (*connect.Response[v1.GetSandboxResponse], error) {
 	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.SandboxService.GetSandbox is not implemented"))
 }
@@ -262,6 +450,26 @@ func (UnimplementedSandboxServiceHandler) DownloadSandboxFile(context.Context, *
 	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.SandboxService.DownloadSandboxFile is not implemented"))
 }
 
+func (UnimplementedSandboxServiceHandler) CommitSandbox(context.Context, *connect.Request[v1.CommitSandboxRequest]) (*connect.Response[v1.CommitSandboxResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.SandboxService.CommitSandbox is not implemented"))
+}
+
+func (UnimplementedSandboxServiceHandler) CloneSandbox(context.Context, *connect.Request[v1.CloneSandboxRequest]) (*connect.Response[v1.CloneSandboxResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.SandboxService.CloneSandbox is not implemented"))
+}
+
+func (UnimplementedSandboxServiceHandler) UpgradeSandboxAgent(context.Context, *connect.Request[v1.UpgradeSandboxAgentRequest]) (*connect.Response[v1.UpgradeSandboxAgentResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.SandboxService.UpgradeSandboxAgent is not implemented"))
+}
+
+func (UnimplementedSandboxServiceHandler) PauseSandbox(context.Context, *connect.Request[v1.PauseSandboxRequest]) (*connect.Response[v1.PauseSandboxResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.SandboxService.PauseSandbox is not implemented"))
+}
+
+func (UnimplementedSandboxServiceHandler) ResumeSandbox(context.Context, *connect.Request[v1.ResumeSandboxRequest]) (*connect.Response[v1.ResumeSandboxResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.SandboxService.ResumeSandbox is not implemented"))
+}
+
 func (UnimplementedSandboxServiceHandler) TerminateSandbox(context.Context, *connect.Request[v1.TerminateSandboxRequest]) (*connect.Response[v1.TerminateSandboxResponse], error) {
 	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.SandboxService.TerminateSandbox is not implemented"))
 }
@@ -276,7 +484,12 @@ type ExecutionServiceClient interface {
 	OpenInteractiveExecution(context.Context, *connect.Request[v1.OpenInteractiveExecutionRequest]) (*connect.Response[v1.OpenInteractiveExecutionResponse], error)
 	GetExecution(context.Context, *connect.Request[v1.GetExecutionRequest]) (*connect.Response[v1.GetExecutionResponse], error)
 	CancelExecution(context.Context, *connect.Request[v1.CancelExecutionRequest]) (*connect.Response[v1.CancelExecutionResponse], error)
+	WriteExecutionStdin(context.Context, *connect.Request[v1.WriteExecutionStdinRequest]) (*connect.Response[v1.WriteExecutionStdinResponse], error)
 	StreamExecution(context.Context, *connect.Request[v1.StreamExecutionRequest]) (*connect.ServerStreamForClient[v1.ExecutionStreamEvent], error)
+	ListPendingApprovals(context.Context, *connect.Request[v1.ListPendingApprovalsRequest]) (*connect.Response[v1.ListPendingApprovalsResponse], error)
+	ResolveExecutionApproval(context.Context, *connect.Request[v1.ResolveExecutionApprovalRequest]) (*connect.Response[v1.ResolveExecutionApprovalResponse], error)
+	AnnotateExecution(context.Context, *connect.Request[v1.AnnotateExecutionRequest]) (*connect.Response[v1.AnnotateExecutionResponse], error)
+	ListExecutions(context.Context, *connect.Request[v1.ListExecutionsRequest]) (*connect.Response[v1.ListExecutionsResponse], error)
 }
 
 // NewExecutionServiceClient constructs a client for the cleanroom.v1.ExecutionService service. By
@@ -314,12 +527,42 @@ func NewExecutionServiceClient(httpClient connect.HTTPClient, baseURL string, op
 			connect.WithSchema(executionServiceMethods.ByName("CancelExecution")),
 			connect.WithClientOptions(opts...),
 		),
+		writeExecutionStdin: connect.NewClient[v1.WriteExecutionStdinRequest, v1.WriteExecutionStdinResponse](
+			httpClient,
+			baseURL+ExecutionServiceWriteExecutionStdinProcedure,
+			connect.WithSchema(executionServiceMethods.ByName("WriteExecutionStdin")),
+			connect.WithClientOptions(opts...),
+		),
 		streamExecution: connect.NewClient[v1.StreamExecutionRequest, v1.ExecutionStreamEvent](
 			httpClient,
 			baseURL+ExecutionServiceStreamExecutionProcedure,
 			connect.WithSchema(executionServiceMethods.ByName("StreamExecution")),
 			connect.WithClientOptions(opts...),
 		),
+		listPendingApprovals: connect.NewClient[v1.ListPendingApprovalsRequest, v1.ListPendingApprovalsResponse](
+			httpClient,
+			baseURL+ExecutionServiceListPendingApprovalsProcedure,
+			connect.WithSchema(executionServiceMethods.ByName("ListPendingApprovals")),
+			connect.WithClientOptions(opts...),
+		),
+		resolveExecutionApproval: connect.NewClient[v1.ResolveExecutionApprovalRequest, v1.ResolveExecutionApprovalResponse](
+			httpClient,
+			baseURL+ExecutionServiceResolveExecutionApprovalProcedure,
+			connect.WithSchema(executionServiceMethods.ByName("ResolveExecutionApproval")),
+			connect.WithClientOptions(opts...),
+		),
+		annotateExecution: connect.NewClient[v1.AnnotateExecutionRequest, v1.AnnotateExecutionResponse](
+			httpClient,
+			baseURL+ExecutionServiceAnnotateExecutionProcedure,
+			connect.WithSchema(executionServiceMethods.ByName("AnnotateExecution")),
+			connect.WithClientOptions(opts...),
+		),
+		listExecutions: connect.NewClient[v1.ListExecutionsRequest, v1.ListExecutionsResponse](
+			httpClient,
+			baseURL+ExecutionServiceListExecutionsProcedure,
+			connect.WithSchema(executionServiceMethods.ByName("ListExecutions")),
+			connect.WithClientOptions(opts...),
+		),
 	}
 }
 
@@ -329,7 +572,12 @@ type executionServiceClient struct {
 	openInteractiveExecution *connect.Client[v1.OpenInteractiveExecutionRequest, v1.OpenInteractiveExecutionResponse]
 	getExecution             *connect.Client[v1.GetExecutionRequest, v1.GetExecutionResponse]
 	cancelExecution          *connect.Client[v1.CancelExecutionRequest, v1.CancelExecutionResponse]
+	writeExecutionStdin      *connect.Client[v1.WriteExecutionStdinRequest, v1.WriteExecutionStdinResponse]
 	streamExecution          *connect.Client[v1.StreamExecutionRequest, v1.ExecutionStreamEvent]
+	listPendingApprovals     *connect.Client[v1.ListPendingApprovalsRequest, v1.ListPendingApprovalsResponse]
+	resolveExecutionApproval *connect.Client[v1.ResolveExecutionApprovalRequest, v1.ResolveExecutionApprovalResponse]
+	annotateExecution        *connect.Client[v1.AnnotateExecutionRequest, v1.AnnotateExecutionResponse]
+	listExecutions           *connect.Client[v1.ListExecutionsRequest, v1.ListExecutionsResponse]
 }
 
 // CreateExecution calls cleanroom.v1.ExecutionService.CreateExecution.
@@ -352,18 +600,48 @@ func (c *executionServiceClient) CancelExecution(ctx context.Context, req *conne
 	return c.cancelExecution.CallUnary(ctx, req)
 }
 
+// WriteExecutionStdin calls cleanroom.v1.ExecutionService.WriteExecutionStdin.
+func (c *executionServiceClient) WriteExecutionStdin(ctx context.Context, req *connect.Request[v1.WriteExecutionStdinRequest]) (*connect.Response[v1.WriteExecutionStdinResponse], error) {
+	return c.writeExecutionStdin.CallUnary(ctx, req)
+}
+
 // StreamExecution calls cleanroom.v1.ExecutionService.StreamExecution.
 func (c *executionServiceClient) StreamExecution(ctx context.Context, req *connect.Request[v1.StreamExecutionRequest]) (*connect.ServerStreamForClient[v1.ExecutionStreamEvent], error) {
 	return c.streamExecution.CallServerStream(ctx, req)
 }
 
+// ListPendingApprovals calls cleanroom.v1.ExecutionService.ListPendingApprovals.
+func (c *executionServiceClient) ListPendingApprovals(ctx context.Context, req *connect.Request[v1.ListPendingApprovalsRequest]) (*connect.Response[v1.ListPendingApprovalsResponse], error) {
+	return c.listPendingApprovals.CallUnary(ctx, req)
+}
+
+// ResolveExecutionApproval calls cleanroom.v1.ExecutionService.ResolveExecutionApproval.
+func (c *executionServiceClient) ResolveExecutionApproval(ctx context.Context, req *connect.Request[v1.ResolveExecutionApprovalRequest]) (*connect.Response[v1.ResolveExecutionApprovalResponse], error) {
+	return c.resolveExecutionApproval.CallUnary(ctx, req)
+}
+
+// AnnotateExecution calls cleanroom.v1.ExecutionService.AnnotateExecution.
+func (c *executionServiceClient) AnnotateExecution(ctx context.Context, req *connect.Request[v1.AnnotateExecutionRequest]) (*connect.Response[v1.AnnotateExecutionResponse], error) {
+	return c.annotateExecution.CallUnary(ctx, req)
+}
+
+// ListExecutions calls cleanroom.v1.ExecutionService.ListExecutions.
+func (c *executionServiceClient) ListExecutions(ctx context.Context, req *connect.Request[v1.ListExecutionsRequest]) (*connect.Response[v1.ListExecutionsResponse], error) {
+	return c.listExecutions.CallUnary(ctx, req)
+}
+
 // ExecutionServiceHandler is an implementation of the cleanroom.v1.ExecutionService service.
 type ExecutionServiceHandler interface {
 	CreateExecution(context.Context, *connect.Request[v1.CreateExecutionRequest]) (*connect.Response[v1.CreateExecutionResponse], error)
 	OpenInteractiveExecution(context.Context, *connect.Request[v1.OpenInteractiveExecutionRequest]) (*connect.Response[v1.OpenInteractiveExecutionResponse], error)
 	GetExecution(context.Context, *connect.Request[v1.GetExecutionRequest]) (*connect.Response[v1.GetExecutionResponse], error)
 	CancelExecution(context.Context, *connect.Request[v1.CancelExecutionRequest]) (*connect.Response[v1.CancelExecutionResponse], error)
+	WriteExecutionStdin(context.Context, *connect.Request[v1.WriteExecutionStdinRequest]) (*connect.Response[v1.WriteExecutionStdinResponse], error)
 	StreamExecution(context.Context, *connect.Request[v1.StreamExecutionRequest], *connect.ServerStream[v1.ExecutionStreamEvent]) error
+	ListPendingApprovals(context.Context, *connect.Request[v1.ListPendingApprovalsRequest]) (*connect.Response[v1.ListPendingApprovalsResponse], error)
+	ResolveExecutionApproval(context.Context, *connect.Request[v1.ResolveExecutionApprovalRequest]) (*connect.Response[v1.ResolveExecutionApprovalResponse], error)
+	AnnotateExecution(context.Context, *connect.Request[v1.AnnotateExecutionRequest]) (*connect.Response[v1.AnnotateExecutionResponse], error)
+	ListExecutions(context.Context, *connect.Request[v1.ListExecutionsRequest]) (*connect.Response[v1.ListExecutionsResponse], error)
 }
 
 // NewExecutionServiceHandler builds an HTTP handler from the service implementation. It returns the
@@ -397,12 +675,42 @@ func NewExecutionServiceHandler(svc ExecutionServiceHandler, opts ...connect.Han
 		connect.WithSchema(executionServiceMethods.ByName("CancelExecution")),
 		connect.WithHandlerOptions(opts...),
 	)
+	executionServiceWriteExecutionStdinHandler := connect.NewUnaryHandler(
+		ExecutionServiceWriteExecutionStdinProcedure,
+		svc.WriteExecutionStdin,
+		connect.WithSchema(executionServiceMethods.ByName("WriteExecutionStdin")),
+		connect.WithHandlerOptions(opts...),
+	)
 	executionServiceStreamExecutionHandler := connect.NewServerStreamHandler(
 		ExecutionServiceStreamExecutionProcedure,
 		svc.StreamExecution,
 		connect.WithSchema(executionServiceMethods.ByName("StreamExecution")),
 		connect.WithHandlerOptions(opts...),
 	)
+	executionServiceListPendingApprovalsHandler := connect.NewUnaryHandler(
+		ExecutionServiceListPendingApprovalsProcedure,
+		svc.ListPendingApprovals,
+		connect.WithSchema(executionServiceMethods.ByName("ListPendingApprovals")),
+		connect.WithHandlerOptions(opts...),
+	)
+	executionServiceResolveExecutionApprovalHandler := connect.NewUnaryHandler(
+		ExecutionServiceResolveExecutionApprovalProcedure,
+		svc.ResolveExecutionApproval,
+		connect.WithSchema(executionServiceMethods.ByName("ResolveExecutionApproval")),
+		connect.WithHandlerOptions(opts...),
+	)
+	executionServiceAnnotateExecutionHandler := connect.NewUnaryHandler(
+		ExecutionServiceAnnotateExecutionProcedure,
+		svc.AnnotateExecution,
+		connect.WithSchema(executionServiceMethods.ByName("AnnotateExecution")),
+		connect.WithHandlerOptions(opts...),
+	)
+	executionServiceListExecutionsHandler := connect.NewUnaryHandler(
+		ExecutionServiceListExecutionsProcedure,
+		svc.ListExecutions,
+		connect.WithSchema(executionServiceMethods.ByName("ListExecutions")),
+		connect.WithHandlerOptions(opts...),
+	)
 	return "/cleanroom.v1.ExecutionService/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
 		case ExecutionServiceCreateExecutionProcedure:
@@ -413,8 +721,18 @@ func NewExecutionServiceHandler(svc ExecutionServiceHandler, opts ...connect.Han
 			executionServiceGetExecutionHandler.ServeHTTP(w, r)
 		case ExecutionServiceCancelExecutionProcedure:
 			executionServiceCancelExecutionHandler.ServeHTTP(w, r)
+		case ExecutionServiceWriteExecutionStdinProcedure:
+			executionServiceWriteExecutionStdinHandler.ServeHTTP(w, r)
 		case ExecutionServiceStreamExecutionProcedure:
 			executionServiceStreamExecutionHandler.ServeHTTP(w, r)
+		case ExecutionServiceListPendingApprovalsProcedure:
+			executionServiceListPendingApprovalsHandler.ServeHTTP(w, r)
+		case ExecutionServiceResolveExecutionApprovalProcedure:
+			executionServiceResolveExecutionApprovalHandler.ServeHTTP(w, r)
+		case ExecutionServiceAnnotateExecutionProcedure:
+			executionServiceAnnotateExecutionHandler.ServeHTTP(w, r)
+		case ExecutionServiceListExecutionsProcedure:
+			executionServiceListExecutionsHandler.ServeHTTP(w, r)
 		default:
 			http.NotFound(w, r)
 		}
@@ -440,6 +758,226 @@ func (UnimplementedExecutionServiceHandler) CancelExecution(context.Context, *co
 	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.ExecutionService.CancelExecution is not implemented"))
 }
 
+func (UnimplementedExecutionServiceHandler) WriteExecutionStdin(context.Context, *connect.Request[v1.WriteExecutionStdinRequest]) (*connect.Response[v1.WriteExecutionStdinResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.ExecutionService.WriteExecutionStdin is not implemented"))
+}
+
 func (UnimplementedExecutionServiceHandler) StreamExecution(context.Context, *connect.Request[v1.StreamExecutionRequest], *connect.ServerStream[v1.ExecutionStreamEvent]) error {
 	return connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.ExecutionService.StreamExecution is not implemented"))
 }
+
+func (UnimplementedExecutionServiceHandler) ListPendingApprovals(context.Context, *connect.Request[v1.ListPendingApprovalsRequest]) (*connect.Response[v1.ListPendingApprovalsResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.ExecutionService.ListPendingApprovals is not implemented"))
+}
+
+func (UnimplementedExecutionServiceHandler) ResolveExecutionApproval(context.Context, *connect.Request[v1.ResolveExecutionApprovalRequest]) (*connect.Response[v1.ResolveExecutionApprovalResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.ExecutionService.ResolveExecutionApproval is not implemented"))
+}
+
+func (UnimplementedExecutionServiceHandler) AnnotateExecution(context.Context, *connect.Request[v1.AnnotateExecutionRequest]) (*connect.Response[v1.AnnotateExecutionResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.ExecutionService.AnnotateExecution is not implemented"))
+}
+
+func (UnimplementedExecutionServiceHandler) ListExecutions(context.Context, *connect.Request[v1.ListExecutionsRequest]) (*connect.Response[v1.ListExecutionsResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.ExecutionService.ListExecutions is not implemented"))
+}
+
+// ServerServiceClient is a client for the cleanroom.v1.ServerService service.
+type ServerServiceClient interface {
+	GetServerInfo(context.Context, *connect.Request[v1.GetServerInfoRequest]) (*connect.Response[v1.GetServerInfoResponse], error)
+	GetUsage(context.Context, *connect.Request[v1.GetUsageRequest]) (*connect.Response[v1.GetUsageResponse], error)
+	CreateSchedule(context.Context, *connect.Request[v1.CreateScheduleRequest]) (*connect.Response[v1.CreateScheduleResponse], error)
+	ListSchedules(context.Context, *connect.Request[v1.ListSchedulesRequest]) (*connect.Response[v1.ListSchedulesResponse], error)
+	GetSchedule(context.Context, *connect.Request[v1.GetScheduleRequest]) (*connect.Response[v1.GetScheduleResponse], error)
+	DeleteSchedule(context.Context, *connect.Request[v1.DeleteScheduleRequest]) (*connect.Response[v1.DeleteScheduleResponse], error)
+}
+
+// NewServerServiceClient constructs a client for the cleanroom.v1.ServerService service. By
+// default, it uses the Connect protocol with the binary Protobuf Codec, asks for gzipped responses,
+// and sends uncompressed requests. To use the gRPC or gRPC-Web protocols, supply the
+// connect.WithGRPC() or connect.WithGRPCWeb() options.
+//
+// The URL supplied here should be the base URL for the Connect or gRPC server (for example,
+// http://api.acme.com or https://acme.com/grpc).
+func NewServerServiceClient(httpClient connect.HTTPClient, baseURL string, opts ...connect.ClientOption) ServerServiceClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	serverServiceMethods := v1.File_proto_cleanroom_v1_control_proto.Services().ByName("ServerService").Methods()
+	return &serverServiceClient{
+		getServerInfo: connect.NewClient[v1.GetServerInfoRequest, v1.GetServerInfoResponse](
+			httpClient,
+			baseURL+ServerServiceGetServerInfoProcedure,
+			connect.WithSchema(serverServiceMethods.ByName("GetServerInfo")),
+			connect.WithClientOptions(opts...),
+		),
+		getUsage: connect.NewClient[v1.GetUsageRequest, v1.GetUsageResponse](
+			httpClient,
+			baseURL+ServerServiceGetUsageProcedure,
+			connect.WithSchema(serverServiceMethods.ByName("GetUsage")),
+			connect.WithClientOptions(opts...),
+		),
+		createSchedule: connect.NewClient[v1.CreateScheduleRequest, v1.CreateScheduleResponse](
+			httpClient,
+			baseURL+ServerServiceCreateScheduleProcedure,
+			connect.WithSchema(serverServiceMethods.ByName("CreateSchedule")),
+			connect.WithClientOptions(opts...),
+		),
+		listSchedules: connect.NewClient[v1.ListSchedulesRequest, v1.ListSchedulesResponse](
+			httpClient,
+			baseURL+ServerServiceListSchedulesProcedure,
+			connect.WithSchema(serverServiceMethods.ByName("ListSchedules")),
+			connect.WithClientOptions(opts...),
+		),
+		getSchedule: connect.NewClient[v1.GetScheduleRequest, v1.GetScheduleResponse](
+			httpClient,
+			baseURL+ServerServiceGetScheduleProcedure,
+			connect.WithSchema(serverServiceMethods.ByName("GetSchedule")),
+			connect.WithClientOptions(opts...),
+		),
+		deleteSchedule: connect.NewClient[v1.DeleteScheduleRequest, v1.DeleteScheduleResponse](
+			httpClient,
+			baseURL+ServerServiceDeleteScheduleProcedure,
+			connect.WithSchema(serverServiceMethods.ByName("DeleteSchedule")),
+			connect.WithClientOptions(opts...),
+		),
+	}
+}
+
+// serverServiceClient implements ServerServiceClient.
+type serverServiceClient struct {
+	getServerInfo  *connect.Client[v1.GetServerInfoRequest, v1.GetServerInfoResponse]
+	getUsage       *connect.Client[v1.GetUsageRequest, v1.GetUsageResponse]
+	createSchedule *connect.Client[v1.CreateScheduleRequest, v1.CreateScheduleResponse]
+	listSchedules  *connect.Client[v1.ListSchedulesRequest, v1.ListSchedulesResponse]
+	getSchedule    *connect.Client[v1.GetScheduleRequest, v1.GetScheduleResponse]
+	deleteSchedule *connect.Client[v1.DeleteScheduleRequest, v1.DeleteScheduleResponse]
+}
+
+// GetServerInfo calls cleanroom.v1.ServerService.GetServerInfo.
+func (c *serverServiceClient) GetServerInfo(ctx context.Context, req *connect.Request[v1.GetServerInfoRequest]) (*connect.Response[v1.GetServerInfoResponse], error) {
+	return c.getServerInfo.CallUnary(ctx, req)
+}
+
+// GetUsage calls cleanroom.v1.ServerService.GetUsage.
+func (c *serverServiceClient) GetUsage(ctx context.Context, req *connect.Request[v1.GetUsageRequest]) (*connect.Response[v1.GetUsageResponse], error) {
+	return c.getUsage.CallUnary(ctx, req)
+}
+
+// CreateSchedule calls cleanroom.v1.ServerService.CreateSchedule.
+func (c *serverServiceClient) CreateSchedule(ctx context.Context, req *connect.Request[v1.CreateScheduleRequest]) (*connect.Response[v1.CreateScheduleResponse], error) {
+	return c.createSchedule.CallUnary(ctx, req)
+}
+
+// ListSchedules calls cleanroom.v1.ServerService.ListSchedules.
+func (c *serverServiceClient) ListSchedules(ctx context.Context, req *connect.Request[v1.ListSchedulesRequest]) (*connect.Response[v1.ListSchedulesResponse], error) {
+	return c.listSchedules.CallUnary(ctx, req)
+}
+
+// GetSchedule calls cleanroom.v1.ServerService.GetSchedule.
+func (c *serverServiceClient) GetSchedule(ctx context.Context, req *connect.Request[v1.GetScheduleRequest]) (*connect.Response[v1.GetScheduleResponse], error) {
+	return c.getSchedule.CallUnary(ctx, req)
+}
+
+// DeleteSchedule calls cleanroom.v1.ServerService.DeleteSchedule.
+func (c *serverServiceClient) DeleteSchedule(ctx context.Context, req *connect.Request[v1.DeleteScheduleRequest]) (*connect.Response[v1.DeleteScheduleResponse], error) {
+	return c.deleteSchedule.CallUnary(ctx, req)
+}
+
+// ServerServiceHandler is an implementation of the cleanroom.v1.ServerService service.
+type ServerServiceHandler interface {
+	GetServerInfo(context.Context, *connect.Request[v1.GetServerInfoRequest]) (*connect.Response[v1.GetServerInfoResponse], error)
+	GetUsage(context.Context, *connect.Request[v1.GetUsageRequest]) (*connect.Response[v1.GetUsageResponse], error)
+	CreateSchedule(context.Context, *connect.Request[v1.CreateScheduleRequest]) (*connect.Response[v1.CreateScheduleResponse], error)
+	ListSchedules(context.Context, *connect.Request[v1.ListSchedulesRequest]) (*connect.Response[v1.ListSchedulesResponse], error)
+	GetSchedule(context.Context, *connect.Request[v1.GetScheduleRequest]) (*connect.Response[v1.GetScheduleResponse], error)
+	DeleteSchedule(context.Context, *connect.Request[v1.DeleteScheduleRequest]) (*connect.Response[v1.DeleteScheduleResponse], error)
+}
+
+// NewServerServiceHandler builds an HTTP handler from the service implementation. It returns the
+// path on which to mount the handler and the handler itself.
+//
+// By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
+// and JSON codecs. They also support gzip compression.
+func NewServerServiceHandler(svc ServerServiceHandler, opts ...connect.HandlerOption) (string, http.Handler) {
+	serverServiceMethods := v1.File_proto_cleanroom_v1_control_proto.Services().ByName("ServerService").Methods()
+	serverServiceGetServerInfoHandler := connect.NewUnaryHandler(
+		ServerServiceGetServerInfoProcedure,
+		svc.GetServerInfo,
+		connect.WithSchema(serverServiceMethods.ByName("GetServerInfo")),
+		connect.WithHandlerOptions(opts...),
+	)
+	serverServiceGetUsageHandler := connect.NewUnaryHandler(
+		ServerServiceGetUsageProcedure,
+		svc.GetUsage,
+		connect.WithSchema(serverServiceMethods.ByName("GetUsage")),
+		connect.WithHandlerOptions(opts...),
+	)
+	serverServiceCreateScheduleHandler := connect.NewUnaryHandler(
+		ServerServiceCreateScheduleProcedure,
+		svc.CreateSchedule,
+		connect.WithSchema(serverServiceMethods.ByName("CreateSchedule")),
+		connect.WithHandlerOptions(opts...),
+	)
+	serverServiceListSchedulesHandler := connect.NewUnaryHandler(
+		ServerServiceListSchedulesProcedure,
+		svc.ListSchedules,
+		connect.WithSchema(serverServiceMethods.ByName("ListSchedules")),
+		connect.WithHandlerOptions(opts...),
+	)
+	serverServiceGetScheduleHandler := connect.NewUnaryHandler(
+		ServerServiceGetScheduleProcedure,
+		svc.GetSchedule,
+		connect.WithSchema(serverServiceMethods.ByName("GetSchedule")),
+		connect.WithHandlerOptions(opts...),
+	)
+	serverServiceDeleteScheduleHandler := connect.NewUnaryHandler(
+		ServerServiceDeleteScheduleProcedure,
+		svc.DeleteSchedule,
+		connect.WithSchema(serverServiceMethods.ByName("DeleteSchedule")),
+		connect.WithHandlerOptions(opts...),
+	)
+	return "/cleanroom.v1.ServerService/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case ServerServiceGetServerInfoProcedure:
+			serverServiceGetServerInfoHandler.ServeHTTP(w, r)
+		case ServerServiceGetUsageProcedure:
+			serverServiceGetUsageHandler.ServeHTTP(w, r)
+		case ServerServiceCreateScheduleProcedure:
+			serverServiceCreateScheduleHandler.ServeHTTP(w, r)
+		case ServerServiceListSchedulesProcedure:
+			serverServiceListSchedulesHandler.ServeHTTP(w, r)
+		case ServerServiceGetScheduleProcedure:
+			serverServiceGetScheduleHandler.ServeHTTP(w, r)
+		case ServerServiceDeleteScheduleProcedure:
+			serverServiceDeleteScheduleHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// UnimplementedServerServiceHandler returns CodeUnimplemented from all methods.
+type UnimplementedServerServiceHandler struct{}
+
+func (UnimplementedServerServiceHandler) GetServerInfo(context.Context, *connect.Request[v1.GetServerInfoRequest]) (*connect.Response[v1.GetServerInfoResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.ServerService.GetServerInfo is not implemented"))
+}
+
+func (UnimplementedServerServiceHandler) GetUsage(context.Context, *connect.Request[v1.GetUsageRequest]) (*connect.Response[v1.GetUsageResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.ServerService.GetUsage is not implemented"))
+}
+
+func (UnimplementedServerServiceHandler) CreateSchedule(context.Context, *connect.Request[v1.CreateScheduleRequest]) (*connect.Response[v1.CreateScheduleResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.ServerService.CreateSchedule is not implemented"))
+}
+
+func (UnimplementedServerServiceHandler) ListSchedules(context.Context, *connect.Request[v1.ListSchedulesRequest]) (*connect.Response[v1.ListSchedulesResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.ServerService.ListSchedules is not implemented"))
+}
+
+func (UnimplementedServerServiceHandler) GetSchedule(context.Context, *connect.Request[v1.GetScheduleRequest]) (*connect.Response[v1.GetScheduleResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.ServerService.GetSchedule is not implemented"))
+}
+
+func (UnimplementedServerServiceHandler) DeleteSchedule(context.Context, *connect.Request[v1.DeleteScheduleRequest]) (*connect.Response[v1.DeleteScheduleResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("cleanroom.v1.ServerService.DeleteSchedule is not implemented"))
+}
@@ -31,6 +31,8 @@ const (
 	SandboxStatus_SANDBOX_STATUS_STOPPING     SandboxStatus = 3
 	SandboxStatus_SANDBOX_STATUS_STOPPED      SandboxStatus = 4
 	SandboxStatus_SANDBOX_STATUS_FAILED       SandboxStatus = 5
+	// The VM is kept in memory with its vCPUs stopped until it is resumed.
+	SandboxStatus_SANDBOX_STATUS_PAUSED SandboxStatus = 6
 )
 
 // Enum value maps for SandboxStatus.
@@ -42,6 +44,7 @@ var (
 		3: "SANDBOX_STATUS_STOPPING",
 		4: "SANDBOX_STATUS_STOPPED",
 		5: "SANDBOX_STATUS_FAILED",
+		6: "SANDBOX_STATUS_PAUSED",
 	}
 	SandboxStatus_value = map[string]int32{
 		"SANDBOX_STATUS_UNSPECIFIED":  0,
@@ -50,6 +53,7 @@ var (
 		"SANDBOX_STATUS_STOPPING":     3,
 		"SANDBOX_STATUS_STOPPED":      4,
 		"SANDBOX_STATUS_FAILED":       5,
+		"SANDBOX_STATUS_PAUSED":       6,
 	}
 )
 
@@ -80,16 +84,74 @@ func (SandboxStatus) EnumDescriptor() ([]byte, []int) {
 	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{0}
 }
 
+type ExecutionFailureReason int32
+
+const (
+	ExecutionFailureReason_EXECUTION_FAILURE_REASON_UNSPECIFIED        ExecutionFailureReason = 0
+	ExecutionFailureReason_EXECUTION_FAILURE_REASON_GUEST_OOM          ExecutionFailureReason = 1
+	ExecutionFailureReason_EXECUTION_FAILURE_REASON_GUEST_KERNEL_PANIC ExecutionFailureReason = 2
+	ExecutionFailureReason_EXECUTION_FAILURE_REASON_APPROVAL_DENIED    ExecutionFailureReason = 3
+)
+
+// Enum value maps for ExecutionFailureReason.
+var (
+	ExecutionFailureReason_name = map[int32]string{
+		0: "EXECUTION_FAILURE_REASON_UNSPECIFIED",
+		1: "EXECUTION_FAILURE_REASON_GUEST_OOM",
+		2: "EXECUTION_FAILURE_REASON_GUEST_KERNEL_PANIC",
+		3: "EXECUTION_FAILURE_REASON_APPROVAL_DENIED",
+	}
+	ExecutionFailureReason_value = map[string]int32{
+		"EXECUTION_FAILURE_REASON_UNSPECIFIED":        0,
+		"EXECUTION_FAILURE_REASON_GUEST_OOM":          1,
+		"EXECUTION_FAILURE_REASON_GUEST_KERNEL_PANIC": 2,
+		"EXECUTION_FAILURE_REASON_APPROVAL_DENIED":    3,
+	}
+)
+
+func (x ExecutionFailureReason) Enum() *ExecutionFailureReason {
+	p := new(ExecutionFailureReason)
+	*p = x
+	return p
+}
+
+func (x ExecutionFailureReason) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ExecutionFailureReason) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_cleanroom_v1_control_proto_enumTypes[1].Descriptor()
+}
+
+func (ExecutionFailureReason) Type() protoreflect.EnumType {
+	return &file_proto_cleanroom_v1_control_proto_enumTypes[1]
+}
+
+func (x ExecutionFailureReason) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ExecutionFailureReason.Descriptor instead.
+func (ExecutionFailureReason) EnumDescriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{1}
+}
+
 type ExecutionStatus int32
 
 const (
-	ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED ExecutionStatus = 0
-	ExecutionStatus_EXECUTION_STATUS_QUEUED      ExecutionStatus = 1
-	ExecutionStatus_EXECUTION_STATUS_RUNNING     ExecutionStatus = 2
-	ExecutionStatus_EXECUTION_STATUS_SUCCEEDED   ExecutionStatus = 3
-	ExecutionStatus_EXECUTION_STATUS_FAILED      ExecutionStatus = 4
-	ExecutionStatus_EXECUTION_STATUS_CANCELED    ExecutionStatus = 5
-	ExecutionStatus_EXECUTION_STATUS_TIMED_OUT   ExecutionStatus = 6
+	ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED      ExecutionStatus = 0
+	ExecutionStatus_EXECUTION_STATUS_QUEUED           ExecutionStatus = 1
+	ExecutionStatus_EXECUTION_STATUS_RUNNING          ExecutionStatus = 2
+	ExecutionStatus_EXECUTION_STATUS_SUCCEEDED        ExecutionStatus = 3
+	ExecutionStatus_EXECUTION_STATUS_FAILED           ExecutionStatus = 4
+	ExecutionStatus_EXECUTION_STATUS_CANCELED         ExecutionStatus = 5
+	ExecutionStatus_EXECUTION_STATUS_TIMED_OUT        ExecutionStatus = 6
+	ExecutionStatus_EXECUTION_STATUS_PENDING_APPROVAL ExecutionStatus = 7
+	// The command exited with a code the policy maps to skipped.
+	ExecutionStatus_EXECUTION_STATUS_SKIPPED ExecutionStatus = 8
+	// The command failed with a code the policy marks as a soft failure,
+	// one CI should report without failing the build.
+	ExecutionStatus_EXECUTION_STATUS_SOFT_FAILED ExecutionStatus = 9
 )
 
 // Enum value maps for ExecutionStatus.
@@ -102,15 +164,21 @@ var (
 		4: "EXECUTION_STATUS_FAILED",
 		5: "EXECUTION_STATUS_CANCELED",
 		6: "EXECUTION_STATUS_TIMED_OUT",
+		7: "EXECUTION_STATUS_PENDING_APPROVAL",
+		8: "EXECUTION_STATUS_SKIPPED",
+		9: "EXECUTION_STATUS_SOFT_FAILED",
 	}
 	ExecutionStatus_value = map[string]int32{
-		"EXECUTION_STATUS_UNSPECIFIED": 0,
-		"EXECUTION_STATUS_QUEUED":      1,
-		"EXECUTION_STATUS_RUNNING":     2,
-		"EXECUTION_STATUS_SUCCEEDED":   3,
-		"EXECUTION_STATUS_FAILED":      4,
-		"EXECUTION_STATUS_CANCELED":    5,
-		"EXECUTION_STATUS_TIMED_OUT":   6,
+		"EXECUTION_STATUS_UNSPECIFIED":      0,
+		"EXECUTION_STATUS_QUEUED":           1,
+		"EXECUTION_STATUS_RUNNING":          2,
+		"EXECUTION_STATUS_SUCCEEDED":        3,
+		"EXECUTION_STATUS_FAILED":           4,
+		"EXECUTION_STATUS_CANCELED":         5,
+		"EXECUTION_STATUS_TIMED_OUT":        6,
+		"EXECUTION_STATUS_PENDING_APPROVAL": 7,
+		"EXECUTION_STATUS_SKIPPED":          8,
+		"EXECUTION_STATUS_SOFT_FAILED":      9,
 	}
 )
 
@@ -125,11 +193,11 @@ func (x ExecutionStatus) String() string {
 }
 
 func (ExecutionStatus) Descriptor() protoreflect.EnumDescriptor {
-	return file_proto_cleanroom_v1_control_proto_enumTypes[1].Descriptor()
+	return file_proto_cleanroom_v1_control_proto_enumTypes[2].Descriptor()
 }
 
 func (ExecutionStatus) Type() protoreflect.EnumType {
-	return &file_proto_cleanroom_v1_control_proto_enumTypes[1]
+	return &file_proto_cleanroom_v1_control_proto_enumTypes[2]
 }
 
 func (x ExecutionStatus) Number() protoreflect.EnumNumber {
@@ -138,7 +206,7 @@ func (x ExecutionStatus) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use ExecutionStatus.Descriptor instead.
 func (ExecutionStatus) EnumDescriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{1}
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{2}
 }
 
 type ExecutionKind int32
@@ -174,11 +242,11 @@ func (x ExecutionKind) String() string {
 }
 
 func (ExecutionKind) Descriptor() protoreflect.EnumDescriptor {
-	return file_proto_cleanroom_v1_control_proto_enumTypes[2].Descriptor()
+	return file_proto_cleanroom_v1_control_proto_enumTypes[3].Descriptor()
 }
 
 func (ExecutionKind) Type() protoreflect.EnumType {
-	return &file_proto_cleanroom_v1_control_proto_enumTypes[2]
+	return &file_proto_cleanroom_v1_control_proto_enumTypes[3]
 }
 
 func (x ExecutionKind) Number() protoreflect.EnumNumber {
@@ -187,17 +255,133 @@ func (x ExecutionKind) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use ExecutionKind.Descriptor instead.
 func (ExecutionKind) EnumDescriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{2}
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{3}
+}
+
+// ExecutionChangeCapture asks the guest to record which files the execution
+// created, modified or deleted and publish the record as execution artifacts.
+type ExecutionChangeCapture int32
+
+const (
+	ExecutionChangeCapture_EXECUTION_CHANGE_CAPTURE_UNSPECIFIED ExecutionChangeCapture = 0
+	ExecutionChangeCapture_EXECUTION_CHANGE_CAPTURE_MANIFEST    ExecutionChangeCapture = 1
+	ExecutionChangeCapture_EXECUTION_CHANGE_CAPTURE_ARCHIVE     ExecutionChangeCapture = 2
+)
+
+// Enum value maps for ExecutionChangeCapture.
+var (
+	ExecutionChangeCapture_name = map[int32]string{
+		0: "EXECUTION_CHANGE_CAPTURE_UNSPECIFIED",
+		1: "EXECUTION_CHANGE_CAPTURE_MANIFEST",
+		2: "EXECUTION_CHANGE_CAPTURE_ARCHIVE",
+	}
+	ExecutionChangeCapture_value = map[string]int32{
+		"EXECUTION_CHANGE_CAPTURE_UNSPECIFIED": 0,
+		"EXECUTION_CHANGE_CAPTURE_MANIFEST":    1,
+		"EXECUTION_CHANGE_CAPTURE_ARCHIVE":     2,
+	}
+)
+
+func (x ExecutionChangeCapture) Enum() *ExecutionChangeCapture {
+	p := new(ExecutionChangeCapture)
+	*p = x
+	return p
+}
+
+func (x ExecutionChangeCapture) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ExecutionChangeCapture) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_cleanroom_v1_control_proto_enumTypes[4].Descriptor()
+}
+
+func (ExecutionChangeCapture) Type() protoreflect.EnumType {
+	return &file_proto_cleanroom_v1_control_proto_enumTypes[4]
+}
+
+func (x ExecutionChangeCapture) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ExecutionChangeCapture.Descriptor instead.
+func (ExecutionChangeCapture) EnumDescriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{4}
+}
+
+type ExecutionLauncher int32
+
+const (
+	ExecutionLauncher_EXECUTION_LAUNCHER_UNSPECIFIED ExecutionLauncher = 0
+	ExecutionLauncher_EXECUTION_LAUNCHER_DIRECT      ExecutionLauncher = 1
+	ExecutionLauncher_EXECUTION_LAUNCHER_SYSTEMD     ExecutionLauncher = 2
+)
+
+// Enum value maps for ExecutionLauncher.
+var (
+	ExecutionLauncher_name = map[int32]string{
+		0: "EXECUTION_LAUNCHER_UNSPECIFIED",
+		1: "EXECUTION_LAUNCHER_DIRECT",
+		2: "EXECUTION_LAUNCHER_SYSTEMD",
+	}
+	ExecutionLauncher_value = map[string]int32{
+		"EXECUTION_LAUNCHER_UNSPECIFIED": 0,
+		"EXECUTION_LAUNCHER_DIRECT":      1,
+		"EXECUTION_LAUNCHER_SYSTEMD":     2,
+	}
+)
+
+func (x ExecutionLauncher) Enum() *ExecutionLauncher {
+	p := new(ExecutionLauncher)
+	*p = x
+	return p
+}
+
+func (x ExecutionLauncher) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ExecutionLauncher) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_cleanroom_v1_control_proto_enumTypes[5].Descriptor()
+}
+
+func (ExecutionLauncher) Type() protoreflect.EnumType {
+	return &file_proto_cleanroom_v1_control_proto_enumTypes[5]
+}
+
+func (x ExecutionLauncher) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ExecutionLauncher.Descriptor instead.
+func (ExecutionLauncher) EnumDescriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{5}
 }
 
 type Sandbox struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
-	Status        SandboxStatus          `protobuf:"varint,2,opt,name=status,proto3,enum=cleanroom.v1.SandboxStatus" json:"status,omitempty"`
-	Backend       string                 `protobuf:"bytes,3,opt,name=backend,proto3" json:"backend,omitempty"`
-	PolicyHash    string                 `protobuf:"bytes,4,opt,name=policy_hash,json=policyHash,proto3" json:"policy_hash,omitempty"`
-	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
-	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId  string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	Status     SandboxStatus          `protobuf:"varint,2,opt,name=status,proto3,enum=cleanroom.v1.SandboxStatus" json:"status,omitempty"`
+	Backend    string                 `protobuf:"bytes,3,opt,name=backend,proto3" json:"backend,omitempty"`
+	PolicyHash string                 `protobuf:"bytes,4,opt,name=policy_hash,json=policyHash,proto3" json:"policy_hash,omitempty"`
+	CreatedAt  *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt  *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Name       string                 `protobuf:"bytes,7,opt,name=name,proto3" json:"name,omitempty"`
+	Labels     map[string]string      `protobuf:"bytes,8,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Checkout   *SandboxCheckout       `protobuf:"bytes,9,opt,name=checkout,proto3" json:"checkout,omitempty"`
+	// SHA-256 of the guest agent binary the sandbox runs. Empty when the
+	// backend does not track it.
+	AgentHash string `protobuf:"bytes,10,opt,name=agent_hash,json=agentHash,proto3" json:"agent_hash,omitempty"`
+	// Addresses each policy allow host resolved to when the sandbox's egress
+	// rules were built. Empty when the backend does not record them.
+	Resolutions []*HostResolution `protobuf:"bytes,11,rep,name=resolutions,proto3" json:"resolutions,omitempty"`
+	// The sandbox group the sandbox was created in. Empty otherwise.
+	GroupId string `protobuf:"bytes,12,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	// Address the other members of the sandbox's group connect to.
+	GroupAddress string `protobuf:"bytes,13,opt,name=group_address,json=groupAddress,proto3" json:"group_address,omitempty"`
+	// The namespace the sandbox was created in. Only callers working in it,
+	// and admins, can see or act on the sandbox.
+	Namespace     string `protobuf:"bytes,14,opt,name=namespace,proto3" json:"namespace,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -274,28 +458,86 @@ func (x *Sandbox) GetUpdatedAt() *timestamppb.Timestamp {
 	return nil
 }
 
-type PolicyAllowRule struct {
+func (x *Sandbox) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Sandbox) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *Sandbox) GetCheckout() *SandboxCheckout {
+	if x != nil {
+		return x.Checkout
+	}
+	return nil
+}
+
+func (x *Sandbox) GetAgentHash() string {
+	if x != nil {
+		return x.AgentHash
+	}
+	return ""
+}
+
+func (x *Sandbox) GetResolutions() []*HostResolution {
+	if x != nil {
+		return x.Resolutions
+	}
+	return nil
+}
+
+func (x *Sandbox) GetGroupId() string {
+	if x != nil {
+		return x.GroupId
+	}
+	return ""
+}
+
+func (x *Sandbox) GetGroupAddress() string {
+	if x != nil {
+		return x.GroupAddress
+	}
+	return ""
+}
+
+func (x *Sandbox) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+// HostResolution is the set of IPv4 addresses a policy allow host resolved
+// to.
+type HostResolution struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Host          string                 `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
-	Ports         []int32                `protobuf:"varint,2,rep,packed,name=ports,proto3" json:"ports,omitempty"`
+	Addresses     []string               `protobuf:"bytes,2,rep,name=addresses,proto3" json:"addresses,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *PolicyAllowRule) Reset() {
-	*x = PolicyAllowRule{}
+func (x *HostResolution) Reset() {
+	*x = HostResolution{}
 	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[1]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PolicyAllowRule) String() string {
+func (x *HostResolution) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PolicyAllowRule) ProtoMessage() {}
+func (*HostResolution) ProtoMessage() {}
 
-func (x *PolicyAllowRule) ProtoReflect() protoreflect.Message {
+func (x *HostResolution) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[1]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -307,46 +549,49 @@ func (x *PolicyAllowRule) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PolicyAllowRule.ProtoReflect.Descriptor instead.
-func (*PolicyAllowRule) Descriptor() ([]byte, []int) {
+// Deprecated: Use HostResolution.ProtoReflect.Descriptor instead.
+func (*HostResolution) Descriptor() ([]byte, []int) {
 	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{1}
 }
 
-func (x *PolicyAllowRule) GetHost() string {
+func (x *HostResolution) GetHost() string {
 	if x != nil {
 		return x.Host
 	}
 	return ""
 }
 
-func (x *PolicyAllowRule) GetPorts() []int32 {
+func (x *HostResolution) GetAddresses() []string {
 	if x != nil {
-		return x.Ports
+		return x.Addresses
 	}
 	return nil
 }
 
-type PolicyDockerService struct {
+type SandboxCheckout struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Required      bool                   `protobuf:"varint,1,opt,name=required,proto3" json:"required,omitempty"`
+	Repository    string                 `protobuf:"bytes,1,opt,name=repository,proto3" json:"repository,omitempty"`
+	Ref           string                 `protobuf:"bytes,2,opt,name=ref,proto3" json:"ref,omitempty"`
+	Path          string                 `protobuf:"bytes,3,opt,name=path,proto3" json:"path,omitempty"`
+	Commit        string                 `protobuf:"bytes,4,opt,name=commit,proto3" json:"commit,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *PolicyDockerService) Reset() {
-	*x = PolicyDockerService{}
+func (x *SandboxCheckout) Reset() {
+	*x = SandboxCheckout{}
 	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PolicyDockerService) String() string {
+func (x *SandboxCheckout) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PolicyDockerService) ProtoMessage() {}
+func (*SandboxCheckout) ProtoMessage() {}
 
-func (x *PolicyDockerService) ProtoReflect() protoreflect.Message {
+func (x *SandboxCheckout) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -358,39 +603,64 @@ func (x *PolicyDockerService) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PolicyDockerService.ProtoReflect.Descriptor instead.
-func (*PolicyDockerService) Descriptor() ([]byte, []int) {
+// Deprecated: Use SandboxCheckout.ProtoReflect.Descriptor instead.
+func (*SandboxCheckout) Descriptor() ([]byte, []int) {
 	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{2}
 }
 
-func (x *PolicyDockerService) GetRequired() bool {
+func (x *SandboxCheckout) GetRepository() string {
 	if x != nil {
-		return x.Required
+		return x.Repository
 	}
-	return false
+	return ""
 }
 
-type PolicyServices struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Docker        *PolicyDockerService   `protobuf:"bytes,1,opt,name=docker,proto3" json:"docker,omitempty"`
+func (x *SandboxCheckout) GetRef() string {
+	if x != nil {
+		return x.Ref
+	}
+	return ""
+}
+
+func (x *SandboxCheckout) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *SandboxCheckout) GetCommit() string {
+	if x != nil {
+		return x.Commit
+	}
+	return ""
+}
+
+type PolicyAllowRule struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Host  string                 `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+	Ports []int32                `protobuf:"varint,2,rep,packed,name=ports,proto3" json:"ports,omitempty"`
+	// protocol is "tcp", "udp" or "icmp"; empty allows both tcp and udp.
+	Protocol      string             `protobuf:"bytes,3,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	PortRanges    []*PolicyPortRange `protobuf:"bytes,4,rep,name=port_ranges,json=portRanges,proto3" json:"port_ranges,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *PolicyServices) Reset() {
-	*x = PolicyServices{}
+func (x *PolicyAllowRule) Reset() {
+	*x = PolicyAllowRule{}
 	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PolicyServices) String() string {
+func (x *PolicyAllowRule) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PolicyServices) ProtoMessage() {}
+func (*PolicyAllowRule) ProtoMessage() {}
 
-func (x *PolicyServices) ProtoReflect() protoreflect.Message {
+func (x *PolicyAllowRule) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -402,45 +672,62 @@ func (x *PolicyServices) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PolicyServices.ProtoReflect.Descriptor instead.
-func (*PolicyServices) Descriptor() ([]byte, []int) {
+// Deprecated: Use PolicyAllowRule.ProtoReflect.Descriptor instead.
+func (*PolicyAllowRule) Descriptor() ([]byte, []int) {
 	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{3}
 }
 
-func (x *PolicyServices) GetDocker() *PolicyDockerService {
+func (x *PolicyAllowRule) GetHost() string {
 	if x != nil {
-		return x.Docker
+		return x.Host
+	}
+	return ""
+}
+
+func (x *PolicyAllowRule) GetPorts() []int32 {
+	if x != nil {
+		return x.Ports
 	}
 	return nil
 }
 
-type Policy struct {
-	state          protoimpl.MessageState `protogen:"open.v1"`
-	Version        int32                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
-	ImageRef       string                 `protobuf:"bytes,2,opt,name=image_ref,json=imageRef,proto3" json:"image_ref,omitempty"`
-	ImageDigest    string                 `protobuf:"bytes,3,opt,name=image_digest,json=imageDigest,proto3" json:"image_digest,omitempty"`
-	NetworkDefault string                 `protobuf:"bytes,4,opt,name=network_default,json=networkDefault,proto3" json:"network_default,omitempty"`
-	Allow          []*PolicyAllowRule     `protobuf:"bytes,5,rep,name=allow,proto3" json:"allow,omitempty"`
-	Hash           string                 `protobuf:"bytes,6,opt,name=hash,proto3" json:"hash,omitempty"`
-	Services       *PolicyServices        `protobuf:"bytes,7,opt,name=services,proto3" json:"services,omitempty"`
-	unknownFields  protoimpl.UnknownFields
-	sizeCache      protoimpl.SizeCache
+func (x *PolicyAllowRule) GetProtocol() string {
+	if x != nil {
+		return x.Protocol
+	}
+	return ""
 }
 
-func (x *Policy) Reset() {
-	*x = Policy{}
+func (x *PolicyAllowRule) GetPortRanges() []*PolicyPortRange {
+	if x != nil {
+		return x.PortRanges
+	}
+	return nil
+}
+
+// PolicyPortRange is an inclusive range of destination ports.
+type PolicyPortRange struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	From          int32                  `protobuf:"varint,1,opt,name=from,proto3" json:"from,omitempty"`
+	To            int32                  `protobuf:"varint,2,opt,name=to,proto3" json:"to,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PolicyPortRange) Reset() {
+	*x = PolicyPortRange{}
 	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Policy) String() string {
+func (x *PolicyPortRange) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Policy) ProtoMessage() {}
+func (*PolicyPortRange) ProtoMessage() {}
 
-func (x *Policy) ProtoReflect() protoreflect.Message {
+func (x *PolicyPortRange) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -452,81 +739,47 @@ func (x *Policy) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Policy.ProtoReflect.Descriptor instead.
-func (*Policy) Descriptor() ([]byte, []int) {
+// Deprecated: Use PolicyPortRange.ProtoReflect.Descriptor instead.
+func (*PolicyPortRange) Descriptor() ([]byte, []int) {
 	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{4}
 }
 
-func (x *Policy) GetVersion() int32 {
+func (x *PolicyPortRange) GetFrom() int32 {
 	if x != nil {
-		return x.Version
+		return x.From
 	}
 	return 0
 }
 
-func (x *Policy) GetImageRef() string {
+func (x *PolicyPortRange) GetTo() int32 {
 	if x != nil {
-		return x.ImageRef
+		return x.To
 	}
-	return ""
+	return 0
 }
 
-func (x *Policy) GetImageDigest() string {
-	if x != nil {
-		return x.ImageDigest
-	}
-	return ""
+type PolicyDockerService struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Required      bool                   `protobuf:"varint,1,opt,name=required,proto3" json:"required,omitempty"`
+	Preload       []string               `protobuf:"bytes,2,rep,name=preload,proto3" json:"preload,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Policy) GetNetworkDefault() string {
-	if x != nil {
-		return x.NetworkDefault
-	}
-	return ""
+func (x *PolicyDockerService) Reset() {
+	*x = PolicyDockerService{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-func (x *Policy) GetAllow() []*PolicyAllowRule {
-	if x != nil {
-		return x.Allow
-	}
-	return nil
-}
-
-func (x *Policy) GetHash() string {
-	if x != nil {
-		return x.Hash
-	}
-	return ""
-}
-
-func (x *Policy) GetServices() *PolicyServices {
-	if x != nil {
-		return x.Services
-	}
-	return nil
-}
-
-type SandboxOptions struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	LaunchSeconds int64                  `protobuf:"varint,3,opt,name=launch_seconds,json=launchSeconds,proto3" json:"launch_seconds,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
-}
-
-func (x *SandboxOptions) Reset() {
-	*x = SandboxOptions{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[5]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
-}
-
-func (x *SandboxOptions) String() string {
+func (x *PolicyDockerService) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SandboxOptions) ProtoMessage() {}
+func (*PolicyDockerService) ProtoMessage() {}
 
-func (x *SandboxOptions) ProtoReflect() protoreflect.Message {
+func (x *PolicyDockerService) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -538,41 +791,46 @@ func (x *SandboxOptions) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SandboxOptions.ProtoReflect.Descriptor instead.
-func (*SandboxOptions) Descriptor() ([]byte, []int) {
+// Deprecated: Use PolicyDockerService.ProtoReflect.Descriptor instead.
+func (*PolicyDockerService) Descriptor() ([]byte, []int) {
 	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{5}
 }
 
-func (x *SandboxOptions) GetLaunchSeconds() int64 {
+func (x *PolicyDockerService) GetRequired() bool {
 	if x != nil {
-		return x.LaunchSeconds
+		return x.Required
 	}
-	return 0
+	return false
 }
 
-type CreateSandboxRequest struct {
+func (x *PolicyDockerService) GetPreload() []string {
+	if x != nil {
+		return x.Preload
+	}
+	return nil
+}
+
+type PolicyOCIRegistryService struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Backend       string                 `protobuf:"bytes,2,opt,name=backend,proto3" json:"backend,omitempty"`
-	Options       *SandboxOptions        `protobuf:"bytes,3,opt,name=options,proto3" json:"options,omitempty"`
-	Policy        *Policy                `protobuf:"bytes,4,opt,name=policy,proto3" json:"policy,omitempty"`
+	Allow         []string               `protobuf:"bytes,1,rep,name=allow,proto3" json:"allow,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *CreateSandboxRequest) Reset() {
-	*x = CreateSandboxRequest{}
+func (x *PolicyOCIRegistryService) Reset() {
+	*x = PolicyOCIRegistryService{}
 	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *CreateSandboxRequest) String() string {
+func (x *PolicyOCIRegistryService) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CreateSandboxRequest) ProtoMessage() {}
+func (*PolicyOCIRegistryService) ProtoMessage() {}
 
-func (x *CreateSandboxRequest) ProtoReflect() protoreflect.Message {
+func (x *PolicyOCIRegistryService) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -584,55 +842,41 @@ func (x *CreateSandboxRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CreateSandboxRequest.ProtoReflect.Descriptor instead.
-func (*CreateSandboxRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use PolicyOCIRegistryService.ProtoReflect.Descriptor instead.
+func (*PolicyOCIRegistryService) Descriptor() ([]byte, []int) {
 	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{6}
 }
 
-func (x *CreateSandboxRequest) GetBackend() string {
-	if x != nil {
-		return x.Backend
-	}
-	return ""
-}
-
-func (x *CreateSandboxRequest) GetOptions() *SandboxOptions {
-	if x != nil {
-		return x.Options
-	}
-	return nil
-}
-
-func (x *CreateSandboxRequest) GetPolicy() *Policy {
+func (x *PolicyOCIRegistryService) GetAllow() []string {
 	if x != nil {
-		return x.Policy
+		return x.Allow
 	}
 	return nil
 }
 
-type CreateSandboxResponse struct {
+type PolicyPackagesService struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Sandbox       *Sandbox               `protobuf:"bytes,1,opt,name=sandbox,proto3" json:"sandbox,omitempty"`
-	PolicySource  string                 `protobuf:"bytes,2,opt,name=policy_source,json=policySource,proto3" json:"policy_source,omitempty"`
-	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	Npm           []string               `protobuf:"bytes,1,rep,name=npm,proto3" json:"npm,omitempty"`
+	Pypi          []string               `protobuf:"bytes,2,rep,name=pypi,proto3" json:"pypi,omitempty"`
+	Go            []string               `protobuf:"bytes,3,rep,name=go,proto3" json:"go,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *CreateSandboxResponse) Reset() {
-	*x = CreateSandboxResponse{}
+func (x *PolicyPackagesService) Reset() {
+	*x = PolicyPackagesService{}
 	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *CreateSandboxResponse) String() string {
+func (x *PolicyPackagesService) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CreateSandboxResponse) ProtoMessage() {}
+func (*PolicyPackagesService) ProtoMessage() {}
 
-func (x *CreateSandboxResponse) ProtoReflect() protoreflect.Message {
+func (x *PolicyPackagesService) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -644,53 +888,55 @@ func (x *CreateSandboxResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CreateSandboxResponse.ProtoReflect.Descriptor instead.
-func (*CreateSandboxResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use PolicyPackagesService.ProtoReflect.Descriptor instead.
+func (*PolicyPackagesService) Descriptor() ([]byte, []int) {
 	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{7}
 }
 
-func (x *CreateSandboxResponse) GetSandbox() *Sandbox {
+func (x *PolicyPackagesService) GetNpm() []string {
 	if x != nil {
-		return x.Sandbox
+		return x.Npm
 	}
 	return nil
 }
 
-func (x *CreateSandboxResponse) GetPolicySource() string {
+func (x *PolicyPackagesService) GetPypi() []string {
 	if x != nil {
-		return x.PolicySource
+		return x.Pypi
 	}
-	return ""
+	return nil
 }
 
-func (x *CreateSandboxResponse) GetMessage() string {
+func (x *PolicyPackagesService) GetGo() []string {
 	if x != nil {
-		return x.Message
+		return x.Go
 	}
-	return ""
+	return nil
 }
 
-type GetSandboxRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+type PolicyServices struct {
+	state         protoimpl.MessageState    `protogen:"open.v1"`
+	Docker        *PolicyDockerService      `protobuf:"bytes,1,opt,name=docker,proto3" json:"docker,omitempty"`
+	OciRegistry   *PolicyOCIRegistryService `protobuf:"bytes,2,opt,name=oci_registry,json=ociRegistry,proto3" json:"oci_registry,omitempty"`
+	Packages      *PolicyPackagesService    `protobuf:"bytes,3,opt,name=packages,proto3" json:"packages,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetSandboxRequest) Reset() {
-	*x = GetSandboxRequest{}
+func (x *PolicyServices) Reset() {
+	*x = PolicyServices{}
 	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetSandboxRequest) String() string {
+func (x *PolicyServices) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetSandboxRequest) ProtoMessage() {}
+func (*PolicyServices) ProtoMessage() {}
 
-func (x *GetSandboxRequest) ProtoReflect() protoreflect.Message {
+func (x *PolicyServices) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -702,83 +948,60 @@ func (x *GetSandboxRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetSandboxRequest.ProtoReflect.Descriptor instead.
-func (*GetSandboxRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use PolicyServices.ProtoReflect.Descriptor instead.
+func (*PolicyServices) Descriptor() ([]byte, []int) {
 	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{8}
 }
 
-func (x *GetSandboxRequest) GetSandboxId() string {
+func (x *PolicyServices) GetDocker() *PolicyDockerService {
 	if x != nil {
-		return x.SandboxId
+		return x.Docker
 	}
-	return ""
-}
-
-type GetSandboxResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Sandbox       *Sandbox               `protobuf:"bytes,1,opt,name=sandbox,proto3" json:"sandbox,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
-}
-
-func (x *GetSandboxResponse) Reset() {
-	*x = GetSandboxResponse{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[9]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
-}
-
-func (x *GetSandboxResponse) String() string {
-	return protoimpl.X.MessageStringOf(x)
+	return nil
 }
 
-func (*GetSandboxResponse) ProtoMessage() {}
-
-func (x *GetSandboxResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[9]
+func (x *PolicyServices) GetOciRegistry() *PolicyOCIRegistryService {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.OciRegistry
 	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use GetSandboxResponse.ProtoReflect.Descriptor instead.
-func (*GetSandboxResponse) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{9}
+	return nil
 }
 
-func (x *GetSandboxResponse) GetSandbox() *Sandbox {
+func (x *PolicyServices) GetPackages() *PolicyPackagesService {
 	if x != nil {
-		return x.Sandbox
+		return x.Packages
 	}
 	return nil
 }
 
-type ListSandboxesRequest struct {
+type PolicyResources struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
+	Vcpus         int64                  `protobuf:"varint,1,opt,name=vcpus,proto3" json:"vcpus,omitempty"`
+	MemoryMib     int64                  `protobuf:"varint,2,opt,name=memory_mib,json=memoryMib,proto3" json:"memory_mib,omitempty"`
+	DiskMib       int64                  `protobuf:"varint,3,opt,name=disk_mib,json=diskMib,proto3" json:"disk_mib,omitempty"`
+	EgressMbps    int64                  `protobuf:"varint,4,opt,name=egress_mbps,json=egressMbps,proto3" json:"egress_mbps,omitempty"`
+	IngressMbps   int64                  `protobuf:"varint,5,opt,name=ingress_mbps,json=ingressMbps,proto3" json:"ingress_mbps,omitempty"`
+	DiskIops      int64                  `protobuf:"varint,6,opt,name=disk_iops,json=diskIops,proto3" json:"disk_iops,omitempty"`
+	DiskMibps     int64                  `protobuf:"varint,7,opt,name=disk_mibps,json=diskMibps,proto3" json:"disk_mibps,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ListSandboxesRequest) Reset() {
-	*x = ListSandboxesRequest{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[10]
+func (x *PolicyResources) Reset() {
+	*x = PolicyResources{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ListSandboxesRequest) String() string {
+func (x *PolicyResources) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ListSandboxesRequest) ProtoMessage() {}
+func (*PolicyResources) ProtoMessage() {}
 
-func (x *ListSandboxesRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[10]
+func (x *PolicyResources) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -789,79 +1012,105 @@ func (x *ListSandboxesRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ListSandboxesRequest.ProtoReflect.Descriptor instead.
-func (*ListSandboxesRequest) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{10}
+// Deprecated: Use PolicyResources.ProtoReflect.Descriptor instead.
+func (*PolicyResources) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{9}
 }
 
-type ListSandboxesResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Sandboxes     []*Sandbox             `protobuf:"bytes,1,rep,name=sandboxes,proto3" json:"sandboxes,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *PolicyResources) GetVcpus() int64 {
+	if x != nil {
+		return x.Vcpus
+	}
+	return 0
 }
 
-func (x *ListSandboxesResponse) Reset() {
-	*x = ListSandboxesResponse{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[11]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+func (x *PolicyResources) GetMemoryMib() int64 {
+	if x != nil {
+		return x.MemoryMib
+	}
+	return 0
 }
 
-func (x *ListSandboxesResponse) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *PolicyResources) GetDiskMib() int64 {
+	if x != nil {
+		return x.DiskMib
+	}
+	return 0
 }
 
-func (*ListSandboxesResponse) ProtoMessage() {}
+func (x *PolicyResources) GetEgressMbps() int64 {
+	if x != nil {
+		return x.EgressMbps
+	}
+	return 0
+}
 
-func (x *ListSandboxesResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[11]
+func (x *PolicyResources) GetIngressMbps() int64 {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.IngressMbps
 	}
-	return mi.MessageOf(x)
+	return 0
 }
 
-// Deprecated: Use ListSandboxesResponse.ProtoReflect.Descriptor instead.
-func (*ListSandboxesResponse) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{11}
+func (x *PolicyResources) GetDiskIops() int64 {
+	if x != nil {
+		return x.DiskIops
+	}
+	return 0
 }
 
-func (x *ListSandboxesResponse) GetSandboxes() []*Sandbox {
+func (x *PolicyResources) GetDiskMibps() int64 {
 	if x != nil {
-		return x.Sandboxes
+		return x.DiskMibps
 	}
-	return nil
+	return 0
 }
 
-type DownloadSandboxFileRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
-	Path          string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
-	MaxBytes      int64                  `protobuf:"varint,3,opt,name=max_bytes,json=maxBytes,proto3" json:"max_bytes,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+type Policy struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	Version              int32                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	ImageRef             string                 `protobuf:"bytes,2,opt,name=image_ref,json=imageRef,proto3" json:"image_ref,omitempty"`
+	ImageDigest          string                 `protobuf:"bytes,3,opt,name=image_digest,json=imageDigest,proto3" json:"image_digest,omitempty"`
+	NetworkDefault       string                 `protobuf:"bytes,4,opt,name=network_default,json=networkDefault,proto3" json:"network_default,omitempty"`
+	Allow                []*PolicyAllowRule     `protobuf:"bytes,5,rep,name=allow,proto3" json:"allow,omitempty"`
+	Hash                 string                 `protobuf:"bytes,6,opt,name=hash,proto3" json:"hash,omitempty"`
+	Services             *PolicyServices        `protobuf:"bytes,7,opt,name=services,proto3" json:"services,omitempty"`
+	Resources            *PolicyResources       `protobuf:"bytes,8,opt,name=resources,proto3" json:"resources,omitempty"`
+	VfioDevices          []string               `protobuf:"bytes,9,rep,name=vfio_devices,json=vfioDevices,proto3" json:"vfio_devices,omitempty"`
+	NestedVirtualization bool                   `protobuf:"varint,10,opt,name=nested_virtualization,json=nestedVirtualization,proto3" json:"nested_virtualization,omitempty"`
+	ReadOnlyRootfs       *PolicyReadOnlyRootFS  `protobuf:"bytes,11,opt,name=read_only_rootfs,json=readOnlyRootfs,proto3" json:"read_only_rootfs,omitempty"`
+	Setup                []string               `protobuf:"bytes,12,rep,name=setup,proto3" json:"setup,omitempty"`
+	// Repository-relative path of the policy file the policy was compiled
+	// from. Covered by hash.
+	Source string `protobuf:"bytes,13,opt,name=source,proto3" json:"source,omitempty"`
+	// Values the policy file's variables resolved to. Covered by hash.
+	Variables map[string]string     `protobuf:"bytes,14,rep,name=variables,proto3" json:"variables,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	ExitCodes []*PolicyExitCodeRule `protobuf:"bytes,15,rep,name=exit_codes,json=exitCodes,proto3" json:"exit_codes,omitempty"`
+	// Text shown at the start of interactive console sessions.
+	ConsoleBanner string `protobuf:"bytes,16,opt,name=console_banner,json=consoleBanner,proto3" json:"console_banner,omitempty"`
+	// Interactive console sessions end after this many seconds, or after
+	// this many seconds without input. Zero means no limit.
+	ConsoleMaxSessionSeconds  int64 `protobuf:"varint,17,opt,name=console_max_session_seconds,json=consoleMaxSessionSeconds,proto3" json:"console_max_session_seconds,omitempty"`
+	ConsoleIdleTimeoutSeconds int64 `protobuf:"varint,18,opt,name=console_idle_timeout_seconds,json=consoleIdleTimeoutSeconds,proto3" json:"console_idle_timeout_seconds,omitempty"`
+	unknownFields             protoimpl.UnknownFields
+	sizeCache                 protoimpl.SizeCache
 }
 
-func (x *DownloadSandboxFileRequest) Reset() {
-	*x = DownloadSandboxFileRequest{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[12]
+func (x *Policy) Reset() {
+	*x = Policy{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *DownloadSandboxFileRequest) String() string {
+func (x *Policy) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*DownloadSandboxFileRequest) ProtoMessage() {}
+func (*Policy) ProtoMessage() {}
 
-func (x *DownloadSandboxFileRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[12]
+func (x *Policy) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -872,57 +1121,168 @@ func (x *DownloadSandboxFileRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use DownloadSandboxFileRequest.ProtoReflect.Descriptor instead.
-func (*DownloadSandboxFileRequest) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{12}
+// Deprecated: Use Policy.ProtoReflect.Descriptor instead.
+func (*Policy) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{10}
 }
 
-func (x *DownloadSandboxFileRequest) GetSandboxId() string {
+func (x *Policy) GetVersion() int32 {
 	if x != nil {
-		return x.SandboxId
+		return x.Version
 	}
-	return ""
+	return 0
 }
 
-func (x *DownloadSandboxFileRequest) GetPath() string {
+func (x *Policy) GetImageRef() string {
 	if x != nil {
-		return x.Path
+		return x.ImageRef
 	}
 	return ""
 }
 
-func (x *DownloadSandboxFileRequest) GetMaxBytes() int64 {
+func (x *Policy) GetImageDigest() string {
 	if x != nil {
-		return x.MaxBytes
+		return x.ImageDigest
+	}
+	return ""
+}
+
+func (x *Policy) GetNetworkDefault() string {
+	if x != nil {
+		return x.NetworkDefault
+	}
+	return ""
+}
+
+func (x *Policy) GetAllow() []*PolicyAllowRule {
+	if x != nil {
+		return x.Allow
+	}
+	return nil
+}
+
+func (x *Policy) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+func (x *Policy) GetServices() *PolicyServices {
+	if x != nil {
+		return x.Services
+	}
+	return nil
+}
+
+func (x *Policy) GetResources() *PolicyResources {
+	if x != nil {
+		return x.Resources
+	}
+	return nil
+}
+
+func (x *Policy) GetVfioDevices() []string {
+	if x != nil {
+		return x.VfioDevices
+	}
+	return nil
+}
+
+func (x *Policy) GetNestedVirtualization() bool {
+	if x != nil {
+		return x.NestedVirtualization
+	}
+	return false
+}
+
+func (x *Policy) GetReadOnlyRootfs() *PolicyReadOnlyRootFS {
+	if x != nil {
+		return x.ReadOnlyRootfs
+	}
+	return nil
+}
+
+func (x *Policy) GetSetup() []string {
+	if x != nil {
+		return x.Setup
+	}
+	return nil
+}
+
+func (x *Policy) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Policy) GetVariables() map[string]string {
+	if x != nil {
+		return x.Variables
+	}
+	return nil
+}
+
+func (x *Policy) GetExitCodes() []*PolicyExitCodeRule {
+	if x != nil {
+		return x.ExitCodes
+	}
+	return nil
+}
+
+func (x *Policy) GetConsoleBanner() string {
+	if x != nil {
+		return x.ConsoleBanner
+	}
+	return ""
+}
+
+func (x *Policy) GetConsoleMaxSessionSeconds() int64 {
+	if x != nil {
+		return x.ConsoleMaxSessionSeconds
 	}
 	return 0
 }
 
-type DownloadSandboxFileResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
-	Path          string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
-	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
-	SizeBytes     int64                  `protobuf:"varint,4,opt,name=size_bytes,json=sizeBytes,proto3" json:"size_bytes,omitempty"`
+func (x *Policy) GetConsoleIdleTimeoutSeconds() int64 {
+	if x != nil {
+		return x.ConsoleIdleTimeoutSeconds
+	}
+	return 0
+}
+
+// PolicyExitCodeRule says how the server finalizes an execution whose
+// command exits with one of codes.
+type PolicyExitCodeRule struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Codes []int32                `protobuf:"varint,1,rep,packed,name=codes,proto3" json:"codes,omitempty"`
+	// "succeeded", "failed", "skipped" or "soft_failed". Empty keeps the
+	// status the exit code gives.
+	Status string `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	// Mark the execution as worth retrying.
+	Retriable bool `protobuf:"varint,3,opt,name=retriable,proto3" json:"retriable,omitempty"`
+	// Annotations to set on the execution.
+	Annotations   map[string]string `protobuf:"bytes,4,rep,name=annotations,proto3" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *DownloadSandboxFileResponse) Reset() {
-	*x = DownloadSandboxFileResponse{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[13]
+func (x *PolicyExitCodeRule) Reset() {
+	*x = PolicyExitCodeRule{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *DownloadSandboxFileResponse) String() string {
+func (x *PolicyExitCodeRule) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*DownloadSandboxFileResponse) ProtoMessage() {}
+func (*PolicyExitCodeRule) ProtoMessage() {}
 
-func (x *DownloadSandboxFileResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[13]
+func (x *PolicyExitCodeRule) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -933,61 +1293,61 @@ func (x *DownloadSandboxFileResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use DownloadSandboxFileResponse.ProtoReflect.Descriptor instead.
-func (*DownloadSandboxFileResponse) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{13}
+// Deprecated: Use PolicyExitCodeRule.ProtoReflect.Descriptor instead.
+func (*PolicyExitCodeRule) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{11}
 }
 
-func (x *DownloadSandboxFileResponse) GetSandboxId() string {
+func (x *PolicyExitCodeRule) GetCodes() []int32 {
 	if x != nil {
-		return x.SandboxId
+		return x.Codes
 	}
-	return ""
+	return nil
 }
 
-func (x *DownloadSandboxFileResponse) GetPath() string {
+func (x *PolicyExitCodeRule) GetStatus() string {
 	if x != nil {
-		return x.Path
+		return x.Status
 	}
 	return ""
 }
 
-func (x *DownloadSandboxFileResponse) GetData() []byte {
+func (x *PolicyExitCodeRule) GetRetriable() bool {
 	if x != nil {
-		return x.Data
+		return x.Retriable
 	}
-	return nil
+	return false
 }
 
-func (x *DownloadSandboxFileResponse) GetSizeBytes() int64 {
+func (x *PolicyExitCodeRule) GetAnnotations() map[string]string {
 	if x != nil {
-		return x.SizeBytes
+		return x.Annotations
 	}
-	return 0
+	return nil
 }
 
-type TerminateSandboxRequest struct {
+type PolicyReadOnlyRootFS struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	Writable      []*PolicyWritablePath  `protobuf:"bytes,1,rep,name=writable,proto3" json:"writable,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *TerminateSandboxRequest) Reset() {
-	*x = TerminateSandboxRequest{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[14]
+func (x *PolicyReadOnlyRootFS) Reset() {
+	*x = PolicyReadOnlyRootFS{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *TerminateSandboxRequest) String() string {
+func (x *PolicyReadOnlyRootFS) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*TerminateSandboxRequest) ProtoMessage() {}
+func (*PolicyReadOnlyRootFS) ProtoMessage() {}
 
-func (x *TerminateSandboxRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[14]
+func (x *PolicyReadOnlyRootFS) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -998,42 +1358,42 @@ func (x *TerminateSandboxRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use TerminateSandboxRequest.ProtoReflect.Descriptor instead.
-func (*TerminateSandboxRequest) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{14}
+// Deprecated: Use PolicyReadOnlyRootFS.ProtoReflect.Descriptor instead.
+func (*PolicyReadOnlyRootFS) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{12}
 }
 
-func (x *TerminateSandboxRequest) GetSandboxId() string {
+func (x *PolicyReadOnlyRootFS) GetWritable() []*PolicyWritablePath {
 	if x != nil {
-		return x.SandboxId
+		return x.Writable
 	}
-	return ""
+	return nil
 }
 
-type TerminateSandboxResponse struct {
+type PolicyWritablePath struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
-	Terminated    bool                   `protobuf:"varint,2,opt,name=terminated,proto3" json:"terminated,omitempty"`
-	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Disk          bool                   `protobuf:"varint,2,opt,name=disk,proto3" json:"disk,omitempty"`
+	SizeMib       int64                  `protobuf:"varint,3,opt,name=size_mib,json=sizeMib,proto3" json:"size_mib,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *TerminateSandboxResponse) Reset() {
-	*x = TerminateSandboxResponse{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[15]
+func (x *PolicyWritablePath) Reset() {
+	*x = PolicyWritablePath{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *TerminateSandboxResponse) String() string {
+func (x *PolicyWritablePath) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*TerminateSandboxResponse) ProtoMessage() {}
+func (*PolicyWritablePath) ProtoMessage() {}
 
-func (x *TerminateSandboxResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[15]
+func (x *PolicyWritablePath) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1044,55 +1404,109 @@ func (x *TerminateSandboxResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use TerminateSandboxResponse.ProtoReflect.Descriptor instead.
-func (*TerminateSandboxResponse) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{15}
+// Deprecated: Use PolicyWritablePath.ProtoReflect.Descriptor instead.
+func (*PolicyWritablePath) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{13}
 }
 
-func (x *TerminateSandboxResponse) GetSandboxId() string {
+func (x *PolicyWritablePath) GetPath() string {
 	if x != nil {
-		return x.SandboxId
+		return x.Path
 	}
 	return ""
 }
 
-func (x *TerminateSandboxResponse) GetTerminated() bool {
+func (x *PolicyWritablePath) GetDisk() bool {
 	if x != nil {
-		return x.Terminated
+		return x.Disk
 	}
 	return false
 }
 
-func (x *TerminateSandboxResponse) GetMessage() string {
+func (x *PolicyWritablePath) GetSizeMib() int64 {
 	if x != nil {
-		return x.Message
+		return x.SizeMib
 	}
-	return ""
+	return 0
 }
 
-type StreamSandboxEventsRequest struct {
+type SandboxOptions struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
-	Follow        bool                   `protobuf:"varint,2,opt,name=follow,proto3" json:"follow,omitempty"`
+	LaunchSeconds int64                  `protobuf:"varint,3,opt,name=launch_seconds,json=launchSeconds,proto3" json:"launch_seconds,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *StreamSandboxEventsRequest) Reset() {
-	*x = StreamSandboxEventsRequest{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[16]
+func (x *SandboxOptions) Reset() {
+	*x = SandboxOptions{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *StreamSandboxEventsRequest) String() string {
+func (x *SandboxOptions) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*StreamSandboxEventsRequest) ProtoMessage() {}
+func (*SandboxOptions) ProtoMessage() {}
 
-func (x *StreamSandboxEventsRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[16]
+func (x *SandboxOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SandboxOptions.ProtoReflect.Descriptor instead.
+func (*SandboxOptions) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *SandboxOptions) GetLaunchSeconds() int64 {
+	if x != nil {
+		return x.LaunchSeconds
+	}
+	return 0
+}
+
+type CreateSandboxRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Backend  string                 `protobuf:"bytes,2,opt,name=backend,proto3" json:"backend,omitempty"`
+	Options  *SandboxOptions        `protobuf:"bytes,3,opt,name=options,proto3" json:"options,omitempty"`
+	Policy   *Policy                `protobuf:"bytes,4,opt,name=policy,proto3" json:"policy,omitempty"`
+	Name     string                 `protobuf:"bytes,5,opt,name=name,proto3" json:"name,omitempty"`
+	Labels   map[string]string      `protobuf:"bytes,6,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Checkout *SandboxCheckout       `protobuf:"bytes,7,opt,name=checkout,proto3" json:"checkout,omitempty"`
+	// Use these addresses instead of DNS for the listed allow hosts, for
+	// example the resolutions recorded on another sandbox.
+	PinnedResolutions []*HostResolution `protobuf:"bytes,8,rep,name=pinned_resolutions,json=pinnedResolutions,proto3" json:"pinned_resolutions,omitempty"`
+	// Create the sandbox in this namespace instead of the caller's. Only
+	// admins may name another namespace.
+	Namespace     string `protobuf:"bytes,9,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateSandboxRequest) Reset() {
+	*x = CreateSandboxRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateSandboxRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSandboxRequest) ProtoMessage() {}
+
+func (x *CreateSandboxRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[15]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1100,127 +1514,3819 @@ func (x *StreamSandboxEventsRequest) ProtoReflect() protoreflect.Message {
 		}
 		return ms
 	}
-	return mi.MessageOf(x)
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSandboxRequest.ProtoReflect.Descriptor instead.
+func (*CreateSandboxRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *CreateSandboxRequest) GetBackend() string {
+	if x != nil {
+		return x.Backend
+	}
+	return ""
+}
+
+func (x *CreateSandboxRequest) GetOptions() *SandboxOptions {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+func (x *CreateSandboxRequest) GetPolicy() *Policy {
+	if x != nil {
+		return x.Policy
+	}
+	return nil
+}
+
+func (x *CreateSandboxRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CreateSandboxRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *CreateSandboxRequest) GetCheckout() *SandboxCheckout {
+	if x != nil {
+		return x.Checkout
+	}
+	return nil
+}
+
+func (x *CreateSandboxRequest) GetPinnedResolutions() []*HostResolution {
+	if x != nil {
+		return x.PinnedResolutions
+	}
+	return nil
+}
+
+func (x *CreateSandboxRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+type CreateSandboxResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sandbox       *Sandbox               `protobuf:"bytes,1,opt,name=sandbox,proto3" json:"sandbox,omitempty"`
+	PolicySource  string                 `protobuf:"bytes,2,opt,name=policy_source,json=policySource,proto3" json:"policy_source,omitempty"`
+	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateSandboxResponse) Reset() {
+	*x = CreateSandboxResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateSandboxResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSandboxResponse) ProtoMessage() {}
+
+func (x *CreateSandboxResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSandboxResponse.ProtoReflect.Descriptor instead.
+func (*CreateSandboxResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *CreateSandboxResponse) GetSandbox() *Sandbox {
+	if x != nil {
+		return x.Sandbox
+	}
+	return nil
+}
+
+func (x *CreateSandboxResponse) GetPolicySource() string {
+	if x != nil {
+		return x.PolicySource
+	}
+	return ""
+}
+
+func (x *CreateSandboxResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// SandboxGroupMember is a sandbox to create in a group and the ports its
+// guest accepts tcp and udp connections on from the other members.
+type SandboxGroupMember struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sandbox       *CreateSandboxRequest  `protobuf:"bytes,1,opt,name=sandbox,proto3" json:"sandbox,omitempty"`
+	Ports         []int32                `protobuf:"varint,2,rep,packed,name=ports,proto3" json:"ports,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SandboxGroupMember) Reset() {
+	*x = SandboxGroupMember{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SandboxGroupMember) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SandboxGroupMember) ProtoMessage() {}
+
+func (x *SandboxGroupMember) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SandboxGroupMember.ProtoReflect.Descriptor instead.
+func (*SandboxGroupMember) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *SandboxGroupMember) GetSandbox() *CreateSandboxRequest {
+	if x != nil {
+		return x.Sandbox
+	}
+	return nil
+}
+
+func (x *SandboxGroupMember) GetPorts() []int32 {
+	if x != nil {
+		return x.Ports
+	}
+	return nil
+}
+
+type CreateSandboxGroupRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Members       []*SandboxGroupMember  `protobuf:"bytes,1,rep,name=members,proto3" json:"members,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateSandboxGroupRequest) Reset() {
+	*x = CreateSandboxGroupRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateSandboxGroupRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSandboxGroupRequest) ProtoMessage() {}
+
+func (x *CreateSandboxGroupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSandboxGroupRequest.ProtoReflect.Descriptor instead.
+func (*CreateSandboxGroupRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *CreateSandboxGroupRequest) GetMembers() []*SandboxGroupMember {
+	if x != nil {
+		return x.Members
+	}
+	return nil
+}
+
+type CreateSandboxGroupResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	GroupId string                 `protobuf:"bytes,1,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	// One response per member, in request order.
+	Sandboxes     []*CreateSandboxResponse `protobuf:"bytes,2,rep,name=sandboxes,proto3" json:"sandboxes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateSandboxGroupResponse) Reset() {
+	*x = CreateSandboxGroupResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateSandboxGroupResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSandboxGroupResponse) ProtoMessage() {}
+
+func (x *CreateSandboxGroupResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSandboxGroupResponse.ProtoReflect.Descriptor instead.
+func (*CreateSandboxGroupResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *CreateSandboxGroupResponse) GetGroupId() string {
+	if x != nil {
+		return x.GroupId
+	}
+	return ""
+}
+
+func (x *CreateSandboxGroupResponse) GetSandboxes() []*CreateSandboxResponse {
+	if x != nil {
+		return x.Sandboxes
+	}
+	return nil
+}
+
+type GetSandboxRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSandboxRequest) Reset() {
+	*x = GetSandboxRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSandboxRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSandboxRequest) ProtoMessage() {}
+
+func (x *GetSandboxRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSandboxRequest.ProtoReflect.Descriptor instead.
+func (*GetSandboxRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *GetSandboxRequest) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+type GetSandboxResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sandbox       *Sandbox               `protobuf:"bytes,1,opt,name=sandbox,proto3" json:"sandbox,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSandboxResponse) Reset() {
+	*x = GetSandboxResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSandboxResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSandboxResponse) ProtoMessage() {}
+
+func (x *GetSandboxResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSandboxResponse.ProtoReflect.Descriptor instead.
+func (*GetSandboxResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *GetSandboxResponse) GetSandbox() *Sandbox {
+	if x != nil {
+		return x.Sandbox
+	}
+	return nil
+}
+
+type ListSandboxesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// List this namespace instead of the caller's. Only admins may name
+	// another namespace.
+	Namespace string `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	// List every namespace. Admins only.
+	AllNamespaces bool `protobuf:"varint,2,opt,name=all_namespaces,json=allNamespaces,proto3" json:"all_namespaces,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSandboxesRequest) Reset() {
+	*x = ListSandboxesRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSandboxesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSandboxesRequest) ProtoMessage() {}
+
+func (x *ListSandboxesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSandboxesRequest.ProtoReflect.Descriptor instead.
+func (*ListSandboxesRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *ListSandboxesRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *ListSandboxesRequest) GetAllNamespaces() bool {
+	if x != nil {
+		return x.AllNamespaces
+	}
+	return false
+}
+
+type ListSandboxesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sandboxes     []*Sandbox             `protobuf:"bytes,1,rep,name=sandboxes,proto3" json:"sandboxes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSandboxesResponse) Reset() {
+	*x = ListSandboxesResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSandboxesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSandboxesResponse) ProtoMessage() {}
+
+func (x *ListSandboxesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSandboxesResponse.ProtoReflect.Descriptor instead.
+func (*ListSandboxesResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *ListSandboxesResponse) GetSandboxes() []*Sandbox {
+	if x != nil {
+		return x.Sandboxes
+	}
+	return nil
+}
+
+type DownloadSandboxFileRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	Path      string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	MaxBytes  int64                  `protobuf:"varint,3,opt,name=max_bytes,json=maxBytes,proto3" json:"max_bytes,omitempty"`
+	// A copy of the file the caller already has. When set, the server may
+	// answer with a delta against it instead of the file's data.
+	DeltaBase     *FileDeltaBase `protobuf:"bytes,4,opt,name=delta_base,json=deltaBase,proto3" json:"delta_base,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DownloadSandboxFileRequest) Reset() {
+	*x = DownloadSandboxFileRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DownloadSandboxFileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DownloadSandboxFileRequest) ProtoMessage() {}
+
+func (x *DownloadSandboxFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DownloadSandboxFileRequest.ProtoReflect.Descriptor instead.
+func (*DownloadSandboxFileRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *DownloadSandboxFileRequest) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *DownloadSandboxFileRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *DownloadSandboxFileRequest) GetMaxBytes() int64 {
+	if x != nil {
+		return x.MaxBytes
+	}
+	return 0
+}
+
+func (x *DownloadSandboxFileRequest) GetDeltaBase() *FileDeltaBase {
+	if x != nil {
+		return x.DeltaBase
+	}
+	return nil
+}
+
+// FileDeltaBase describes a local copy of a file by the signatures of its
+// full blocks, as internal/blockdelta computes them.
+type FileDeltaBase struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BlockSize     int64                  `protobuf:"varint,1,opt,name=block_size,json=blockSize,proto3" json:"block_size,omitempty"`
+	Signatures    []byte                 `protobuf:"bytes,2,opt,name=signatures,proto3" json:"signatures,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileDeltaBase) Reset() {
+	*x = FileDeltaBase{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileDeltaBase) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileDeltaBase) ProtoMessage() {}
+
+func (x *FileDeltaBase) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileDeltaBase.ProtoReflect.Descriptor instead.
+func (*FileDeltaBase) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *FileDeltaBase) GetBlockSize() int64 {
+	if x != nil {
+		return x.BlockSize
+	}
+	return 0
+}
+
+func (x *FileDeltaBase) GetSignatures() []byte {
+	if x != nil {
+		return x.Signatures
+	}
+	return nil
+}
+
+type DownloadSandboxFileResponse struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	Path      string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	Data      []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	SizeBytes int64                  `protobuf:"varint,4,opt,name=size_bytes,json=sizeBytes,proto3" json:"size_bytes,omitempty"`
+	// Set instead of data when the server answered with a delta against the
+	// request's delta_base. size_bytes is still the file's size.
+	Delta []byte `protobuf:"bytes,5,opt,name=delta,proto3" json:"delta,omitempty"`
+	// Hex SHA-256 of the file, computed by the guest agent as it read the
+	// file. Empty when the backend or guest agent cannot compute one.
+	Sha256        string `protobuf:"bytes,6,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DownloadSandboxFileResponse) Reset() {
+	*x = DownloadSandboxFileResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DownloadSandboxFileResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DownloadSandboxFileResponse) ProtoMessage() {}
+
+func (x *DownloadSandboxFileResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DownloadSandboxFileResponse.ProtoReflect.Descriptor instead.
+func (*DownloadSandboxFileResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *DownloadSandboxFileResponse) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *DownloadSandboxFileResponse) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *DownloadSandboxFileResponse) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *DownloadSandboxFileResponse) GetSizeBytes() int64 {
+	if x != nil {
+		return x.SizeBytes
+	}
+	return 0
+}
+
+func (x *DownloadSandboxFileResponse) GetDelta() []byte {
+	if x != nil {
+		return x.Delta
+	}
+	return nil
+}
+
+func (x *DownloadSandboxFileResponse) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+type CommitSandboxRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	// Tag to push the image to, for example ghcr.io/org/img:tag.
+	Ref           string `protobuf:"bytes,2,opt,name=ref,proto3" json:"ref,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CommitSandboxRequest) Reset() {
+	*x = CommitSandboxRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CommitSandboxRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommitSandboxRequest) ProtoMessage() {}
+
+func (x *CommitSandboxRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommitSandboxRequest.ProtoReflect.Descriptor instead.
+func (*CommitSandboxRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *CommitSandboxRequest) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *CommitSandboxRequest) GetRef() string {
+	if x != nil {
+		return x.Ref
+	}
+	return ""
+}
+
+type CommitSandboxResponse struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	// The pushed image, pinned to its digest.
+	ImageRef      string `protobuf:"bytes,2,opt,name=image_ref,json=imageRef,proto3" json:"image_ref,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CommitSandboxResponse) Reset() {
+	*x = CommitSandboxResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CommitSandboxResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommitSandboxResponse) ProtoMessage() {}
+
+func (x *CommitSandboxResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommitSandboxResponse.ProtoReflect.Descriptor instead.
+func (*CommitSandboxResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *CommitSandboxResponse) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *CommitSandboxResponse) GetImageRef() string {
+	if x != nil {
+		return x.ImageRef
+	}
+	return ""
+}
+
+type CloneSandboxRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The sandbox to copy.
+	SandboxId string `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	// How many clones to create. Defaults to 1.
+	Count int32 `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	// Commit the source's rootfs to this tag first, as CommitSandbox does,
+	// and boot the clones from the pushed image so they start with its files
+	// instead of the policy's image. Needs a backend that supports commits.
+	SnapshotRef string `protobuf:"bytes,3,opt,name=snapshot_ref,json=snapshotRef,proto3" json:"snapshot_ref,omitempty"`
+	// Labels to set on the clones, on top of the source's.
+	Labels        map[string]string `protobuf:"bytes,4,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CloneSandboxRequest) Reset() {
+	*x = CloneSandboxRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CloneSandboxRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CloneSandboxRequest) ProtoMessage() {}
+
+func (x *CloneSandboxRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CloneSandboxRequest.ProtoReflect.Descriptor instead.
+func (*CloneSandboxRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *CloneSandboxRequest) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *CloneSandboxRequest) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *CloneSandboxRequest) GetSnapshotRef() string {
+	if x != nil {
+		return x.SnapshotRef
+	}
+	return ""
+}
+
+func (x *CloneSandboxRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+type CloneSandboxResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The clones, in the order they were created.
+	Sandboxes []*Sandbox `protobuf:"bytes,1,rep,name=sandboxes,proto3" json:"sandboxes,omitempty"`
+	// The snapshot the clones booted from, pinned to its digest. Empty
+	// without snapshot_ref.
+	ImageRef      string `protobuf:"bytes,2,opt,name=image_ref,json=imageRef,proto3" json:"image_ref,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CloneSandboxResponse) Reset() {
+	*x = CloneSandboxResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CloneSandboxResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CloneSandboxResponse) ProtoMessage() {}
+
+func (x *CloneSandboxResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CloneSandboxResponse.ProtoReflect.Descriptor instead.
+func (*CloneSandboxResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *CloneSandboxResponse) GetSandboxes() []*Sandbox {
+	if x != nil {
+		return x.Sandboxes
+	}
+	return nil
+}
+
+func (x *CloneSandboxResponse) GetImageRef() string {
+	if x != nil {
+		return x.ImageRef
+	}
+	return ""
+}
+
+type UpgradeSandboxAgentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpgradeSandboxAgentRequest) Reset() {
+	*x = UpgradeSandboxAgentRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpgradeSandboxAgentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpgradeSandboxAgentRequest) ProtoMessage() {}
+
+func (x *UpgradeSandboxAgentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpgradeSandboxAgentRequest.ProtoReflect.Descriptor instead.
+func (*UpgradeSandboxAgentRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *UpgradeSandboxAgentRequest) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+type UpgradeSandboxAgentResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The sandbox after the upgrade, with its new agent_hash.
+	Sandbox           *Sandbox `protobuf:"bytes,1,opt,name=sandbox,proto3" json:"sandbox,omitempty"`
+	PreviousAgentHash string   `protobuf:"bytes,2,opt,name=previous_agent_hash,json=previousAgentHash,proto3" json:"previous_agent_hash,omitempty"`
+	// False when the sandbox already ran the server's guest agent.
+	Upgraded      bool `protobuf:"varint,3,opt,name=upgraded,proto3" json:"upgraded,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpgradeSandboxAgentResponse) Reset() {
+	*x = UpgradeSandboxAgentResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpgradeSandboxAgentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpgradeSandboxAgentResponse) ProtoMessage() {}
+
+func (x *UpgradeSandboxAgentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpgradeSandboxAgentResponse.ProtoReflect.Descriptor instead.
+func (*UpgradeSandboxAgentResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *UpgradeSandboxAgentResponse) GetSandbox() *Sandbox {
+	if x != nil {
+		return x.Sandbox
+	}
+	return nil
+}
+
+func (x *UpgradeSandboxAgentResponse) GetPreviousAgentHash() string {
+	if x != nil {
+		return x.PreviousAgentHash
+	}
+	return ""
+}
+
+func (x *UpgradeSandboxAgentResponse) GetUpgraded() bool {
+	if x != nil {
+		return x.Upgraded
+	}
+	return false
+}
+
+type PauseSandboxRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PauseSandboxRequest) Reset() {
+	*x = PauseSandboxRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PauseSandboxRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PauseSandboxRequest) ProtoMessage() {}
+
+func (x *PauseSandboxRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PauseSandboxRequest.ProtoReflect.Descriptor instead.
+func (*PauseSandboxRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *PauseSandboxRequest) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+type PauseSandboxResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sandbox       *Sandbox               `protobuf:"bytes,1,opt,name=sandbox,proto3" json:"sandbox,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PauseSandboxResponse) Reset() {
+	*x = PauseSandboxResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PauseSandboxResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PauseSandboxResponse) ProtoMessage() {}
+
+func (x *PauseSandboxResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PauseSandboxResponse.ProtoReflect.Descriptor instead.
+func (*PauseSandboxResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *PauseSandboxResponse) GetSandbox() *Sandbox {
+	if x != nil {
+		return x.Sandbox
+	}
+	return nil
+}
+
+type ResumeSandboxRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResumeSandboxRequest) Reset() {
+	*x = ResumeSandboxRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumeSandboxRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumeSandboxRequest) ProtoMessage() {}
+
+func (x *ResumeSandboxRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumeSandboxRequest.ProtoReflect.Descriptor instead.
+func (*ResumeSandboxRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *ResumeSandboxRequest) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+type ResumeSandboxResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sandbox       *Sandbox               `protobuf:"bytes,1,opt,name=sandbox,proto3" json:"sandbox,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResumeSandboxResponse) Reset() {
+	*x = ResumeSandboxResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumeSandboxResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumeSandboxResponse) ProtoMessage() {}
+
+func (x *ResumeSandboxResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumeSandboxResponse.ProtoReflect.Descriptor instead.
+func (*ResumeSandboxResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *ResumeSandboxResponse) GetSandbox() *Sandbox {
+	if x != nil {
+		return x.Sandbox
+	}
+	return nil
+}
+
+type TerminateSandboxRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TerminateSandboxRequest) Reset() {
+	*x = TerminateSandboxRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TerminateSandboxRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TerminateSandboxRequest) ProtoMessage() {}
+
+func (x *TerminateSandboxRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TerminateSandboxRequest.ProtoReflect.Descriptor instead.
+func (*TerminateSandboxRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *TerminateSandboxRequest) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+type TerminateSandboxResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	Terminated    bool                   `protobuf:"varint,2,opt,name=terminated,proto3" json:"terminated,omitempty"`
+	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TerminateSandboxResponse) Reset() {
+	*x = TerminateSandboxResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TerminateSandboxResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TerminateSandboxResponse) ProtoMessage() {}
+
+func (x *TerminateSandboxResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TerminateSandboxResponse.ProtoReflect.Descriptor instead.
+func (*TerminateSandboxResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *TerminateSandboxResponse) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *TerminateSandboxResponse) GetTerminated() bool {
+	if x != nil {
+		return x.Terminated
+	}
+	return false
+}
+
+func (x *TerminateSandboxResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type StreamSandboxEventsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	Follow        bool                   `protobuf:"varint,2,opt,name=follow,proto3" json:"follow,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamSandboxEventsRequest) Reset() {
+	*x = StreamSandboxEventsRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamSandboxEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamSandboxEventsRequest) ProtoMessage() {}
+
+func (x *StreamSandboxEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamSandboxEventsRequest.ProtoReflect.Descriptor instead.
+func (*StreamSandboxEventsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *StreamSandboxEventsRequest) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *StreamSandboxEventsRequest) GetFollow() bool {
+	if x != nil {
+		return x.Follow
+	}
+	return false
+}
+
+type SandboxEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	Status        SandboxStatus          `protobuf:"varint,2,opt,name=status,proto3,enum=cleanroom.v1.SandboxStatus" json:"status,omitempty"`
+	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	OccurredAt    *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=occurred_at,json=occurredAt,proto3" json:"occurred_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SandboxEvent) Reset() {
+	*x = SandboxEvent{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SandboxEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SandboxEvent) ProtoMessage() {}
+
+func (x *SandboxEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SandboxEvent.ProtoReflect.Descriptor instead.
+func (*SandboxEvent) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *SandboxEvent) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *SandboxEvent) GetStatus() SandboxStatus {
+	if x != nil {
+		return x.Status
+	}
+	return SandboxStatus_SANDBOX_STATUS_UNSPECIFIED
+}
+
+func (x *SandboxEvent) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *SandboxEvent) GetOccurredAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.OccurredAt
+	}
+	return nil
+}
+
+type Execution struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ExecutionId   string                 `protobuf:"bytes,1,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
+	SandboxId     string                 `protobuf:"bytes,2,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	Status        ExecutionStatus        `protobuf:"varint,3,opt,name=status,proto3,enum=cleanroom.v1.ExecutionStatus" json:"status,omitempty"`
+	Command       []string               `protobuf:"bytes,4,rep,name=command,proto3" json:"command,omitempty"`
+	ExitCode      int32                  `protobuf:"varint,5,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	StartedAt     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	FinishedAt    *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=finished_at,json=finishedAt,proto3" json:"finished_at,omitempty"`
+	Tty           bool                   `protobuf:"varint,8,opt,name=tty,proto3" json:"tty,omitempty"`
+	RunId         string                 `protobuf:"bytes,9,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
+	Kind          ExecutionKind          `protobuf:"varint,10,opt,name=kind,proto3,enum=cleanroom.v1.ExecutionKind" json:"kind,omitempty"`
+	ExitMetadata  *ExecutionExitMetadata `protobuf:"bytes,11,opt,name=exit_metadata,json=exitMetadata,proto3" json:"exit_metadata,omitempty"`
+	Artifacts     []*ExecutionArtifact   `protobuf:"bytes,12,rep,name=artifacts,proto3" json:"artifacts,omitempty"`
+	FailureReason ExecutionFailureReason `protobuf:"varint,13,opt,name=failure_reason,json=failureReason,proto3,enum=cleanroom.v1.ExecutionFailureReason" json:"failure_reason,omitempty"`
+	Approval      *ExecutionApproval     `protobuf:"bytes,14,opt,name=approval,proto3" json:"approval,omitempty"`
+	Timings       *ExecutionTimings      `protobuf:"bytes,15,opt,name=timings,proto3" json:"timings,omitempty"`
+	// The namespace of the execution's sandbox.
+	Namespace string `protobuf:"bytes,16,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	// Key/value metadata set at create or by AnnotateExecution, such as
+	// test-shard=3 or coverage=87%. ListExecutions can filter on them.
+	Annotations map[string]string `protobuf:"bytes,17,rep,name=annotations,proto3" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Set when the execution asked for result parsers, once it exits.
+	TestResults *ExecutionTestResults `protobuf:"bytes,18,opt,name=test_results,json=testResults,proto3" json:"test_results,omitempty"`
+	// Set when the policy marks the exit code as worth retrying.
+	Retriable     bool `protobuf:"varint,19,opt,name=retriable,proto3" json:"retriable,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Execution) Reset() {
+	*x = Execution{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Execution) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Execution) ProtoMessage() {}
+
+func (x *Execution) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Execution.ProtoReflect.Descriptor instead.
+func (*Execution) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *Execution) GetExecutionId() string {
+	if x != nil {
+		return x.ExecutionId
+	}
+	return ""
+}
+
+func (x *Execution) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *Execution) GetStatus() ExecutionStatus {
+	if x != nil {
+		return x.Status
+	}
+	return ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED
+}
+
+func (x *Execution) GetCommand() []string {
+	if x != nil {
+		return x.Command
+	}
+	return nil
+}
+
+func (x *Execution) GetExitCode() int32 {
+	if x != nil {
+		return x.ExitCode
+	}
+	return 0
+}
+
+func (x *Execution) GetStartedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartedAt
+	}
+	return nil
+}
+
+func (x *Execution) GetFinishedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FinishedAt
+	}
+	return nil
+}
+
+func (x *Execution) GetTty() bool {
+	if x != nil {
+		return x.Tty
+	}
+	return false
+}
+
+func (x *Execution) GetRunId() string {
+	if x != nil {
+		return x.RunId
+	}
+	return ""
+}
+
+func (x *Execution) GetKind() ExecutionKind {
+	if x != nil {
+		return x.Kind
+	}
+	return ExecutionKind_EXECUTION_KIND_UNSPECIFIED
+}
+
+func (x *Execution) GetExitMetadata() *ExecutionExitMetadata {
+	if x != nil {
+		return x.ExitMetadata
+	}
+	return nil
+}
+
+func (x *Execution) GetArtifacts() []*ExecutionArtifact {
+	if x != nil {
+		return x.Artifacts
+	}
+	return nil
+}
+
+func (x *Execution) GetFailureReason() ExecutionFailureReason {
+	if x != nil {
+		return x.FailureReason
+	}
+	return ExecutionFailureReason_EXECUTION_FAILURE_REASON_UNSPECIFIED
+}
+
+func (x *Execution) GetApproval() *ExecutionApproval {
+	if x != nil {
+		return x.Approval
+	}
+	return nil
+}
+
+func (x *Execution) GetTimings() *ExecutionTimings {
+	if x != nil {
+		return x.Timings
+	}
+	return nil
+}
+
+func (x *Execution) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *Execution) GetAnnotations() map[string]string {
+	if x != nil {
+		return x.Annotations
+	}
+	return nil
+}
+
+func (x *Execution) GetTestResults() *ExecutionTestResults {
+	if x != nil {
+		return x.TestResults
+	}
+	return nil
+}
+
+func (x *Execution) GetRetriable() bool {
+	if x != nil {
+		return x.Retriable
+	}
+	return false
+}
+
+// ExecutionApproval is set on executions that matched a server approval
+// rule and had to wait in EXECUTION_STATUS_PENDING_APPROVAL.
+type ExecutionApproval struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestedBy   string                 `protobuf:"bytes,1,opt,name=requested_by,json=requestedBy,proto3" json:"requested_by,omitempty"`
+	Rules         []string               `protobuf:"bytes,2,rep,name=rules,proto3" json:"rules,omitempty"`
+	Decided       bool                   `protobuf:"varint,3,opt,name=decided,proto3" json:"decided,omitempty"`
+	Approved      bool                   `protobuf:"varint,4,opt,name=approved,proto3" json:"approved,omitempty"`
+	DecidedBy     string                 `protobuf:"bytes,5,opt,name=decided_by,json=decidedBy,proto3" json:"decided_by,omitempty"`
+	Reason        string                 `protobuf:"bytes,6,opt,name=reason,proto3" json:"reason,omitempty"`
+	RequestedAt   *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=requested_at,json=requestedAt,proto3" json:"requested_at,omitempty"`
+	DecidedAt     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=decided_at,json=decidedAt,proto3" json:"decided_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecutionApproval) Reset() {
+	*x = ExecutionApproval{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[42]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecutionApproval) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecutionApproval) ProtoMessage() {}
+
+func (x *ExecutionApproval) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[42]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecutionApproval.ProtoReflect.Descriptor instead.
+func (*ExecutionApproval) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *ExecutionApproval) GetRequestedBy() string {
+	if x != nil {
+		return x.RequestedBy
+	}
+	return ""
+}
+
+func (x *ExecutionApproval) GetRules() []string {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+func (x *ExecutionApproval) GetDecided() bool {
+	if x != nil {
+		return x.Decided
+	}
+	return false
+}
+
+func (x *ExecutionApproval) GetApproved() bool {
+	if x != nil {
+		return x.Approved
+	}
+	return false
+}
+
+func (x *ExecutionApproval) GetDecidedBy() string {
+	if x != nil {
+		return x.DecidedBy
+	}
+	return ""
+}
+
+func (x *ExecutionApproval) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *ExecutionApproval) GetRequestedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.RequestedAt
+	}
+	return nil
+}
+
+func (x *ExecutionApproval) GetDecidedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DecidedAt
+	}
+	return nil
+}
+
+type ExecutionArtifact struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	MediaType     string                 `protobuf:"bytes,3,opt,name=media_type,json=mediaType,proto3" json:"media_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecutionArtifact) Reset() {
+	*x = ExecutionArtifact{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[43]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecutionArtifact) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecutionArtifact) ProtoMessage() {}
+
+func (x *ExecutionArtifact) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[43]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecutionArtifact.ProtoReflect.Descriptor instead.
+func (*ExecutionArtifact) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *ExecutionArtifact) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *ExecutionArtifact) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ExecutionArtifact) GetMediaType() string {
+	if x != nil {
+		return x.MediaType
+	}
+	return ""
+}
+
+type ExecutionOptions struct {
+	state                   protoimpl.MessageState   `protogen:"open.v1"`
+	LaunchSeconds           int64                    `protobuf:"varint,5,opt,name=launch_seconds,json=launchSeconds,proto3" json:"launch_seconds,omitempty"`
+	Tty                     bool                     `protobuf:"varint,6,opt,name=tty,proto3" json:"tty,omitempty"`
+	Limits                  *ExecutionResourceLimits `protobuf:"bytes,8,opt,name=limits,proto3" json:"limits,omitempty"`
+	KeepBackgroundProcesses bool                     `protobuf:"varint,9,opt,name=keep_background_processes,json=keepBackgroundProcesses,proto3" json:"keep_background_processes,omitempty"`
+	FreshHome               bool                     `protobuf:"varint,10,opt,name=fresh_home,json=freshHome,proto3" json:"fresh_home,omitempty"`
+	Launcher                ExecutionLauncher        `protobuf:"varint,11,opt,name=launcher,proto3,enum=cleanroom.v1.ExecutionLauncher" json:"launcher,omitempty"`
+	Stdin                   bool                     `protobuf:"varint,12,opt,name=stdin,proto3" json:"stdin,omitempty"`
+	Vcpus                   int64                    `protobuf:"varint,13,opt,name=vcpus,proto3" json:"vcpus,omitempty"`
+	MemoryMib               int64                    `protobuf:"varint,14,opt,name=memory_mib,json=memoryMib,proto3" json:"memory_mib,omitempty"`
+	CaptureChanges          ExecutionChangeCapture   `protobuf:"varint,15,opt,name=capture_changes,json=captureChanges,proto3,enum=cleanroom.v1.ExecutionChangeCapture" json:"capture_changes,omitempty"`
+	ResultParsers           *ExecutionResultParsers  `protobuf:"bytes,16,opt,name=result_parsers,json=resultParsers,proto3" json:"result_parsers,omitempty"`
+	unknownFields           protoimpl.UnknownFields
+	sizeCache               protoimpl.SizeCache
+}
+
+func (x *ExecutionOptions) Reset() {
+	*x = ExecutionOptions{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecutionOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecutionOptions) ProtoMessage() {}
+
+func (x *ExecutionOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[44]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecutionOptions.ProtoReflect.Descriptor instead.
+func (*ExecutionOptions) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *ExecutionOptions) GetLaunchSeconds() int64 {
+	if x != nil {
+		return x.LaunchSeconds
+	}
+	return 0
+}
+
+func (x *ExecutionOptions) GetTty() bool {
+	if x != nil {
+		return x.Tty
+	}
+	return false
+}
+
+func (x *ExecutionOptions) GetLimits() *ExecutionResourceLimits {
+	if x != nil {
+		return x.Limits
+	}
+	return nil
+}
+
+func (x *ExecutionOptions) GetKeepBackgroundProcesses() bool {
+	if x != nil {
+		return x.KeepBackgroundProcesses
+	}
+	return false
+}
+
+func (x *ExecutionOptions) GetFreshHome() bool {
+	if x != nil {
+		return x.FreshHome
+	}
+	return false
+}
+
+func (x *ExecutionOptions) GetLauncher() ExecutionLauncher {
+	if x != nil {
+		return x.Launcher
+	}
+	return ExecutionLauncher_EXECUTION_LAUNCHER_UNSPECIFIED
+}
+
+func (x *ExecutionOptions) GetStdin() bool {
+	if x != nil {
+		return x.Stdin
+	}
+	return false
+}
+
+func (x *ExecutionOptions) GetVcpus() int64 {
+	if x != nil {
+		return x.Vcpus
+	}
+	return 0
+}
+
+func (x *ExecutionOptions) GetMemoryMib() int64 {
+	if x != nil {
+		return x.MemoryMib
+	}
+	return 0
+}
+
+func (x *ExecutionOptions) GetCaptureChanges() ExecutionChangeCapture {
+	if x != nil {
+		return x.CaptureChanges
+	}
+	return ExecutionChangeCapture_EXECUTION_CHANGE_CAPTURE_UNSPECIFIED
+}
+
+func (x *ExecutionOptions) GetResultParsers() *ExecutionResultParsers {
+	if x != nil {
+		return x.ResultParsers
+	}
+	return nil
+}
+
+// ExecutionResultParsers asks the server to summarize the tests an
+// execution ran into Execution.test_results.
+type ExecutionResultParsers struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Read `go test -json` events from stdout.
+	GoTestJson bool `protobuf:"varint,1,opt,name=go_test_json,json=goTestJson,proto3" json:"go_test_json,omitempty"`
+	// Absolute paths of JUnit XML reports in the sandbox to read once the
+	// command exits. Needs a backend that supports sandbox file downloads.
+	JunitPaths    []string `protobuf:"bytes,2,rep,name=junit_paths,json=junitPaths,proto3" json:"junit_paths,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecutionResultParsers) Reset() {
+	*x = ExecutionResultParsers{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[45]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecutionResultParsers) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecutionResultParsers) ProtoMessage() {}
+
+func (x *ExecutionResultParsers) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[45]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecutionResultParsers.ProtoReflect.Descriptor instead.
+func (*ExecutionResultParsers) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *ExecutionResultParsers) GetGoTestJson() bool {
+	if x != nil {
+		return x.GoTestJson
+	}
+	return false
+}
+
+func (x *ExecutionResultParsers) GetJunitPaths() []string {
+	if x != nil {
+		return x.JunitPaths
+	}
+	return nil
+}
+
+// ExecutionTestResults counts the tests an execution's result parsers saw.
+type ExecutionTestResults struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Total   int32                  `protobuf:"varint,1,opt,name=total,proto3" json:"total,omitempty"`
+	Passed  int32                  `protobuf:"varint,2,opt,name=passed,proto3" json:"passed,omitempty"`
+	Failed  int32                  `protobuf:"varint,3,opt,name=failed,proto3" json:"failed,omitempty"`
+	Skipped int32                  `protobuf:"varint,4,opt,name=skipped,proto3" json:"skipped,omitempty"`
+	// Names of failed tests, at most 50.
+	FailedTests []string `protobuf:"bytes,5,rep,name=failed_tests,json=failedTests,proto3" json:"failed_tests,omitempty"`
+	// The parsers that found results: "go-test-json" or "junit:<path>".
+	Sources []string `protobuf:"bytes,6,rep,name=sources,proto3" json:"sources,omitempty"`
+	// Why a requested parser found nothing, such as a missing report.
+	Errors        []string `protobuf:"bytes,7,rep,name=errors,proto3" json:"errors,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecutionTestResults) Reset() {
+	*x = ExecutionTestResults{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[46]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecutionTestResults) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecutionTestResults) ProtoMessage() {}
+
+func (x *ExecutionTestResults) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[46]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecutionTestResults.ProtoReflect.Descriptor instead.
+func (*ExecutionTestResults) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *ExecutionTestResults) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *ExecutionTestResults) GetPassed() int32 {
+	if x != nil {
+		return x.Passed
+	}
+	return 0
+}
+
+func (x *ExecutionTestResults) GetFailed() int32 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+func (x *ExecutionTestResults) GetSkipped() int32 {
+	if x != nil {
+		return x.Skipped
+	}
+	return 0
+}
+
+func (x *ExecutionTestResults) GetFailedTests() []string {
+	if x != nil {
+		return x.FailedTests
+	}
+	return nil
+}
+
+func (x *ExecutionTestResults) GetSources() []string {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
+func (x *ExecutionTestResults) GetErrors() []string {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+type ExecutionResourceLimits struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Nice           int32                  `protobuf:"varint,1,opt,name=nice,proto3" json:"nice,omitempty"`
+	IoClass        string                 `protobuf:"bytes,2,opt,name=io_class,json=ioClass,proto3" json:"io_class,omitempty"`
+	IoPriority     int32                  `protobuf:"varint,3,opt,name=io_priority,json=ioPriority,proto3" json:"io_priority,omitempty"`
+	CpuWeight      int64                  `protobuf:"varint,4,opt,name=cpu_weight,json=cpuWeight,proto3" json:"cpu_weight,omitempty"`
+	MemoryMaxBytes int64                  `protobuf:"varint,5,opt,name=memory_max_bytes,json=memoryMaxBytes,proto3" json:"memory_max_bytes,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ExecutionResourceLimits) Reset() {
+	*x = ExecutionResourceLimits{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[47]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecutionResourceLimits) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecutionResourceLimits) ProtoMessage() {}
+
+func (x *ExecutionResourceLimits) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[47]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecutionResourceLimits.ProtoReflect.Descriptor instead.
+func (*ExecutionResourceLimits) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{47}
+}
+
+func (x *ExecutionResourceLimits) GetNice() int32 {
+	if x != nil {
+		return x.Nice
+	}
+	return 0
+}
+
+func (x *ExecutionResourceLimits) GetIoClass() string {
+	if x != nil {
+		return x.IoClass
+	}
+	return ""
+}
+
+func (x *ExecutionResourceLimits) GetIoPriority() int32 {
+	if x != nil {
+		return x.IoPriority
+	}
+	return 0
+}
+
+func (x *ExecutionResourceLimits) GetCpuWeight() int64 {
+	if x != nil {
+		return x.CpuWeight
+	}
+	return 0
+}
+
+func (x *ExecutionResourceLimits) GetMemoryMaxBytes() int64 {
+	if x != nil {
+		return x.MemoryMaxBytes
+	}
+	return 0
+}
+
+type CreateExecutionRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	Command   []string               `protobuf:"bytes,2,rep,name=command,proto3" json:"command,omitempty"`
+	Options   *ExecutionOptions      `protobuf:"bytes,3,opt,name=options,proto3" json:"options,omitempty"`
+	Kind      ExecutionKind          `protobuf:"varint,4,opt,name=kind,proto3,enum=cleanroom.v1.ExecutionKind" json:"kind,omitempty"`
+	// Use this run ID instead of a generated one, such as a CI job's UUID, so
+	// the run directory and observability carry the caller's identifier.
+	// Must be a TypeID or UUID that no other run on the server has used.
+	RunId string `protobuf:"bytes,5,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
+	// Annotations the execution starts with.
+	Annotations   map[string]string `protobuf:"bytes,6,rep,name=annotations,proto3" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateExecutionRequest) Reset() {
+	*x = CreateExecutionRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[48]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateExecutionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateExecutionRequest) ProtoMessage() {}
+
+func (x *CreateExecutionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[48]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateExecutionRequest.ProtoReflect.Descriptor instead.
+func (*CreateExecutionRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{48}
+}
+
+func (x *CreateExecutionRequest) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *CreateExecutionRequest) GetCommand() []string {
+	if x != nil {
+		return x.Command
+	}
+	return nil
+}
+
+func (x *CreateExecutionRequest) GetOptions() *ExecutionOptions {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+func (x *CreateExecutionRequest) GetKind() ExecutionKind {
+	if x != nil {
+		return x.Kind
+	}
+	return ExecutionKind_EXECUTION_KIND_UNSPECIFIED
+}
+
+func (x *CreateExecutionRequest) GetRunId() string {
+	if x != nil {
+		return x.RunId
+	}
+	return ""
+}
+
+func (x *CreateExecutionRequest) GetAnnotations() map[string]string {
+	if x != nil {
+		return x.Annotations
+	}
+	return nil
+}
+
+type CreateExecutionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Execution     *Execution             `protobuf:"bytes,1,opt,name=execution,proto3" json:"execution,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateExecutionResponse) Reset() {
+	*x = CreateExecutionResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[49]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateExecutionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateExecutionResponse) ProtoMessage() {}
+
+func (x *CreateExecutionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[49]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateExecutionResponse.ProtoReflect.Descriptor instead.
+func (*CreateExecutionResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{49}
+}
+
+func (x *CreateExecutionResponse) GetExecution() *Execution {
+	if x != nil {
+		return x.Execution
+	}
+	return nil
+}
+
+type OpenInteractiveExecutionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	ExecutionId   string                 `protobuf:"bytes,2,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
+	InitialCols   uint32                 `protobuf:"varint,3,opt,name=initial_cols,json=initialCols,proto3" json:"initial_cols,omitempty"`
+	InitialRows   uint32                 `protobuf:"varint,4,opt,name=initial_rows,json=initialRows,proto3" json:"initial_rows,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OpenInteractiveExecutionRequest) Reset() {
+	*x = OpenInteractiveExecutionRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[50]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OpenInteractiveExecutionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpenInteractiveExecutionRequest) ProtoMessage() {}
+
+func (x *OpenInteractiveExecutionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[50]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpenInteractiveExecutionRequest.ProtoReflect.Descriptor instead.
+func (*OpenInteractiveExecutionRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{50}
+}
+
+func (x *OpenInteractiveExecutionRequest) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *OpenInteractiveExecutionRequest) GetExecutionId() string {
+	if x != nil {
+		return x.ExecutionId
+	}
+	return ""
+}
+
+func (x *OpenInteractiveExecutionRequest) GetInitialCols() uint32 {
+	if x != nil {
+		return x.InitialCols
+	}
+	return 0
+}
+
+func (x *OpenInteractiveExecutionRequest) GetInitialRows() uint32 {
+	if x != nil {
+		return x.InitialRows
+	}
+	return 0
+}
+
+type OpenInteractiveExecutionResponse struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	SessionId           string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	SessionToken        string                 `protobuf:"bytes,2,opt,name=session_token,json=sessionToken,proto3" json:"session_token,omitempty"`
+	ExpiresAt           *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	QuicEndpoint        string                 `protobuf:"bytes,4,opt,name=quic_endpoint,json=quicEndpoint,proto3" json:"quic_endpoint,omitempty"`
+	Alpn                string                 `protobuf:"bytes,5,opt,name=alpn,proto3" json:"alpn,omitempty"`
+	ServerCertPinSha256 string                 `protobuf:"bytes,6,opt,name=server_cert_pin_sha256,json=serverCertPinSha256,proto3" json:"server_cert_pin_sha256,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *OpenInteractiveExecutionResponse) Reset() {
+	*x = OpenInteractiveExecutionResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[51]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OpenInteractiveExecutionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpenInteractiveExecutionResponse) ProtoMessage() {}
+
+func (x *OpenInteractiveExecutionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[51]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpenInteractiveExecutionResponse.ProtoReflect.Descriptor instead.
+func (*OpenInteractiveExecutionResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{51}
+}
+
+func (x *OpenInteractiveExecutionResponse) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *OpenInteractiveExecutionResponse) GetSessionToken() string {
+	if x != nil {
+		return x.SessionToken
+	}
+	return ""
+}
+
+func (x *OpenInteractiveExecutionResponse) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *OpenInteractiveExecutionResponse) GetQuicEndpoint() string {
+	if x != nil {
+		return x.QuicEndpoint
+	}
+	return ""
+}
+
+func (x *OpenInteractiveExecutionResponse) GetAlpn() string {
+	if x != nil {
+		return x.Alpn
+	}
+	return ""
+}
+
+func (x *OpenInteractiveExecutionResponse) GetServerCertPinSha256() string {
+	if x != nil {
+		return x.ServerCertPinSha256
+	}
+	return ""
+}
+
+type GetExecutionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	ExecutionId   string                 `protobuf:"bytes,2,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetExecutionRequest) Reset() {
+	*x = GetExecutionRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[52]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetExecutionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetExecutionRequest) ProtoMessage() {}
+
+func (x *GetExecutionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[52]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetExecutionRequest.ProtoReflect.Descriptor instead.
+func (*GetExecutionRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{52}
+}
+
+func (x *GetExecutionRequest) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *GetExecutionRequest) GetExecutionId() string {
+	if x != nil {
+		return x.ExecutionId
+	}
+	return ""
+}
+
+type GetExecutionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Execution     *Execution             `protobuf:"bytes,1,opt,name=execution,proto3" json:"execution,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetExecutionResponse) Reset() {
+	*x = GetExecutionResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[53]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetExecutionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetExecutionResponse) ProtoMessage() {}
+
+func (x *GetExecutionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[53]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetExecutionResponse.ProtoReflect.Descriptor instead.
+func (*GetExecutionResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{53}
+}
+
+func (x *GetExecutionResponse) GetExecution() *Execution {
+	if x != nil {
+		return x.Execution
+	}
+	return nil
+}
+
+type CancelExecutionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	ExecutionId   string                 `protobuf:"bytes,2,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
+	Signal        int32                  `protobuf:"varint,3,opt,name=signal,proto3" json:"signal,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelExecutionRequest) Reset() {
+	*x = CancelExecutionRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[54]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelExecutionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelExecutionRequest) ProtoMessage() {}
+
+func (x *CancelExecutionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[54]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelExecutionRequest.ProtoReflect.Descriptor instead.
+func (*CancelExecutionRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{54}
+}
+
+func (x *CancelExecutionRequest) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *CancelExecutionRequest) GetExecutionId() string {
+	if x != nil {
+		return x.ExecutionId
+	}
+	return ""
+}
+
+func (x *CancelExecutionRequest) GetSignal() int32 {
+	if x != nil {
+		return x.Signal
+	}
+	return 0
+}
+
+type CancelExecutionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	ExecutionId   string                 `protobuf:"bytes,2,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
+	Accepted      bool                   `protobuf:"varint,3,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	Status        ExecutionStatus        `protobuf:"varint,4,opt,name=status,proto3,enum=cleanroom.v1.ExecutionStatus" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelExecutionResponse) Reset() {
+	*x = CancelExecutionResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[55]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelExecutionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelExecutionResponse) ProtoMessage() {}
+
+func (x *CancelExecutionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[55]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelExecutionResponse.ProtoReflect.Descriptor instead.
+func (*CancelExecutionResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{55}
+}
+
+func (x *CancelExecutionResponse) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *CancelExecutionResponse) GetExecutionId() string {
+	if x != nil {
+		return x.ExecutionId
+	}
+	return ""
+}
+
+func (x *CancelExecutionResponse) GetAccepted() bool {
+	if x != nil {
+		return x.Accepted
+	}
+	return false
+}
+
+func (x *CancelExecutionResponse) GetStatus() ExecutionStatus {
+	if x != nil {
+		return x.Status
+	}
+	return ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED
+}
+
+type ListPendingApprovalsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPendingApprovalsRequest) Reset() {
+	*x = ListPendingApprovalsRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[56]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPendingApprovalsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPendingApprovalsRequest) ProtoMessage() {}
+
+func (x *ListPendingApprovalsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[56]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPendingApprovalsRequest.ProtoReflect.Descriptor instead.
+func (*ListPendingApprovalsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{56}
+}
+
+type PendingApproval struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Execution      *Execution             `protobuf:"bytes,1,opt,name=execution,proto3" json:"execution,omitempty"`
+	Sandbox        *Sandbox               `protobuf:"bytes,2,opt,name=sandbox,proto3" json:"sandbox,omitempty"`
+	ImageRef       string                 `protobuf:"bytes,3,opt,name=image_ref,json=imageRef,proto3" json:"image_ref,omitempty"`
+	NetworkDefault string                 `protobuf:"bytes,4,opt,name=network_default,json=networkDefault,proto3" json:"network_default,omitempty"`
+	AllowedHosts   []string               `protobuf:"bytes,5,rep,name=allowed_hosts,json=allowedHosts,proto3" json:"allowed_hosts,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *PendingApproval) Reset() {
+	*x = PendingApproval{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[57]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PendingApproval) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PendingApproval) ProtoMessage() {}
+
+func (x *PendingApproval) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[57]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PendingApproval.ProtoReflect.Descriptor instead.
+func (*PendingApproval) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{57}
+}
+
+func (x *PendingApproval) GetExecution() *Execution {
+	if x != nil {
+		return x.Execution
+	}
+	return nil
+}
+
+func (x *PendingApproval) GetSandbox() *Sandbox {
+	if x != nil {
+		return x.Sandbox
+	}
+	return nil
+}
+
+func (x *PendingApproval) GetImageRef() string {
+	if x != nil {
+		return x.ImageRef
+	}
+	return ""
+}
+
+func (x *PendingApproval) GetNetworkDefault() string {
+	if x != nil {
+		return x.NetworkDefault
+	}
+	return ""
+}
+
+func (x *PendingApproval) GetAllowedHosts() []string {
+	if x != nil {
+		return x.AllowedHosts
+	}
+	return nil
+}
+
+type ListPendingApprovalsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Approvals     []*PendingApproval     `protobuf:"bytes,1,rep,name=approvals,proto3" json:"approvals,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPendingApprovalsResponse) Reset() {
+	*x = ListPendingApprovalsResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[58]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPendingApprovalsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPendingApprovalsResponse) ProtoMessage() {}
+
+func (x *ListPendingApprovalsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[58]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPendingApprovalsResponse.ProtoReflect.Descriptor instead.
+func (*ListPendingApprovalsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{58}
+}
+
+func (x *ListPendingApprovalsResponse) GetApprovals() []*PendingApproval {
+	if x != nil {
+		return x.Approvals
+	}
+	return nil
+}
+
+type ResolveExecutionApprovalRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	ExecutionId   string                 `protobuf:"bytes,2,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
+	Approve       bool                   `protobuf:"varint,3,opt,name=approve,proto3" json:"approve,omitempty"`
+	Reason        string                 `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResolveExecutionApprovalRequest) Reset() {
+	*x = ResolveExecutionApprovalRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[59]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResolveExecutionApprovalRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResolveExecutionApprovalRequest) ProtoMessage() {}
+
+func (x *ResolveExecutionApprovalRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[59]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResolveExecutionApprovalRequest.ProtoReflect.Descriptor instead.
+func (*ResolveExecutionApprovalRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{59}
+}
+
+func (x *ResolveExecutionApprovalRequest) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *ResolveExecutionApprovalRequest) GetExecutionId() string {
+	if x != nil {
+		return x.ExecutionId
+	}
+	return ""
+}
+
+func (x *ResolveExecutionApprovalRequest) GetApprove() bool {
+	if x != nil {
+		return x.Approve
+	}
+	return false
+}
+
+func (x *ResolveExecutionApprovalRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type ResolveExecutionApprovalResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Execution     *Execution             `protobuf:"bytes,1,opt,name=execution,proto3" json:"execution,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResolveExecutionApprovalResponse) Reset() {
+	*x = ResolveExecutionApprovalResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[60]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResolveExecutionApprovalResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResolveExecutionApprovalResponse) ProtoMessage() {}
+
+func (x *ResolveExecutionApprovalResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[60]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResolveExecutionApprovalResponse.ProtoReflect.Descriptor instead.
+func (*ResolveExecutionApprovalResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{60}
+}
+
+func (x *ResolveExecutionApprovalResponse) GetExecution() *Execution {
+	if x != nil {
+		return x.Execution
+	}
+	return nil
+}
+
+type AnnotateExecutionRequest struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId   string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	ExecutionId string                 `protobuf:"bytes,2,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
+	// Annotations to set, replacing any with the same key. An empty value
+	// removes the key.
+	Annotations   map[string]string `protobuf:"bytes,3,rep,name=annotations,proto3" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnnotateExecutionRequest) Reset() {
+	*x = AnnotateExecutionRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[61]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnnotateExecutionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnnotateExecutionRequest) ProtoMessage() {}
+
+func (x *AnnotateExecutionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[61]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnnotateExecutionRequest.ProtoReflect.Descriptor instead.
+func (*AnnotateExecutionRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{61}
+}
+
+func (x *AnnotateExecutionRequest) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *AnnotateExecutionRequest) GetExecutionId() string {
+	if x != nil {
+		return x.ExecutionId
+	}
+	return ""
+}
+
+func (x *AnnotateExecutionRequest) GetAnnotations() map[string]string {
+	if x != nil {
+		return x.Annotations
+	}
+	return nil
+}
+
+type AnnotateExecutionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Execution     *Execution             `protobuf:"bytes,1,opt,name=execution,proto3" json:"execution,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnnotateExecutionResponse) Reset() {
+	*x = AnnotateExecutionResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[62]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnnotateExecutionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnnotateExecutionResponse) ProtoMessage() {}
+
+func (x *AnnotateExecutionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[62]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnnotateExecutionResponse.ProtoReflect.Descriptor instead.
+func (*AnnotateExecutionResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{62}
+}
+
+func (x *AnnotateExecutionResponse) GetExecution() *Execution {
+	if x != nil {
+		return x.Execution
+	}
+	return nil
+}
+
+type ListExecutionsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only list this sandbox's executions. Empty lists every sandbox in the
+	// caller's namespace.
+	SandboxId string `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	// Only list executions carrying every one of these annotations with the
+	// same value.
+	Annotations   map[string]string `protobuf:"bytes,2,rep,name=annotations,proto3" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListExecutionsRequest) Reset() {
+	*x = ListExecutionsRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[63]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListExecutionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListExecutionsRequest) ProtoMessage() {}
+
+func (x *ListExecutionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[63]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListExecutionsRequest.ProtoReflect.Descriptor instead.
+func (*ListExecutionsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{63}
+}
+
+func (x *ListExecutionsRequest) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *ListExecutionsRequest) GetAnnotations() map[string]string {
+	if x != nil {
+		return x.Annotations
+	}
+	return nil
+}
+
+type ListExecutionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Executions    []*Execution           `protobuf:"bytes,1,rep,name=executions,proto3" json:"executions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListExecutionsResponse) Reset() {
+	*x = ListExecutionsResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[64]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListExecutionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListExecutionsResponse) ProtoMessage() {}
+
+func (x *ListExecutionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[64]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListExecutionsResponse.ProtoReflect.Descriptor instead.
+func (*ListExecutionsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{64}
+}
+
+func (x *ListExecutionsResponse) GetExecutions() []*Execution {
+	if x != nil {
+		return x.Executions
+	}
+	return nil
+}
+
+type WriteExecutionStdinRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	ExecutionId   string                 `protobuf:"bytes,2,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
+	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	Eof           bool                   `protobuf:"varint,4,opt,name=eof,proto3" json:"eof,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WriteExecutionStdinRequest) Reset() {
+	*x = WriteExecutionStdinRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[65]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WriteExecutionStdinRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WriteExecutionStdinRequest) ProtoMessage() {}
+
+func (x *WriteExecutionStdinRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[65]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WriteExecutionStdinRequest.ProtoReflect.Descriptor instead.
+func (*WriteExecutionStdinRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{65}
+}
+
+func (x *WriteExecutionStdinRequest) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *WriteExecutionStdinRequest) GetExecutionId() string {
+	if x != nil {
+		return x.ExecutionId
+	}
+	return ""
+}
+
+func (x *WriteExecutionStdinRequest) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *WriteExecutionStdinRequest) GetEof() bool {
+	if x != nil {
+		return x.Eof
+	}
+	return false
+}
+
+type WriteExecutionStdinResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	ExecutionId   string                 `protobuf:"bytes,2,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WriteExecutionStdinResponse) Reset() {
+	*x = WriteExecutionStdinResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[66]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WriteExecutionStdinResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WriteExecutionStdinResponse) ProtoMessage() {}
+
+func (x *WriteExecutionStdinResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[66]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WriteExecutionStdinResponse.ProtoReflect.Descriptor instead.
+func (*WriteExecutionStdinResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{66}
+}
+
+func (x *WriteExecutionStdinResponse) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *WriteExecutionStdinResponse) GetExecutionId() string {
+	if x != nil {
+		return x.ExecutionId
+	}
+	return ""
+}
+
+type StreamExecutionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	ExecutionId   string                 `protobuf:"bytes,2,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
+	Follow        bool                   `protobuf:"varint,3,opt,name=follow,proto3" json:"follow,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamExecutionRequest) Reset() {
+	*x = StreamExecutionRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[67]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamExecutionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamExecutionRequest) ProtoMessage() {}
+
+func (x *StreamExecutionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[67]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamExecutionRequest.ProtoReflect.Descriptor instead.
+func (*StreamExecutionRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{67}
+}
+
+func (x *StreamExecutionRequest) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *StreamExecutionRequest) GetExecutionId() string {
+	if x != nil {
+		return x.ExecutionId
+	}
+	return ""
+}
+
+func (x *StreamExecutionRequest) GetFollow() bool {
+	if x != nil {
+		return x.Follow
+	}
+	return false
+}
+
+type ExecutionExit struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ExitCode      int32                  `protobuf:"varint,1,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	Status        ExecutionStatus        `protobuf:"varint,2,opt,name=status,proto3,enum=cleanroom.v1.ExecutionStatus" json:"status,omitempty"`
+	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	Metadata      *ExecutionExitMetadata `protobuf:"bytes,4,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	Artifacts     []*ExecutionArtifact   `protobuf:"bytes,5,rep,name=artifacts,proto3" json:"artifacts,omitempty"`
+	FailureReason ExecutionFailureReason `protobuf:"varint,6,opt,name=failure_reason,json=failureReason,proto3,enum=cleanroom.v1.ExecutionFailureReason" json:"failure_reason,omitempty"`
+	Timings       *ExecutionTimings      `protobuf:"bytes,7,opt,name=timings,proto3" json:"timings,omitempty"`
+	TestResults   *ExecutionTestResults  `protobuf:"bytes,8,opt,name=test_results,json=testResults,proto3" json:"test_results,omitempty"`
+	Retriable     bool                   `protobuf:"varint,9,opt,name=retriable,proto3" json:"retriable,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecutionExit) Reset() {
+	*x = ExecutionExit{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[68]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecutionExit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecutionExit) ProtoMessage() {}
+
+func (x *ExecutionExit) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[68]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecutionExit.ProtoReflect.Descriptor instead.
+func (*ExecutionExit) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{68}
+}
+
+func (x *ExecutionExit) GetExitCode() int32 {
+	if x != nil {
+		return x.ExitCode
+	}
+	return 0
+}
+
+func (x *ExecutionExit) GetStatus() ExecutionStatus {
+	if x != nil {
+		return x.Status
+	}
+	return ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED
+}
+
+func (x *ExecutionExit) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ExecutionExit) GetMetadata() *ExecutionExitMetadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *ExecutionExit) GetArtifacts() []*ExecutionArtifact {
+	if x != nil {
+		return x.Artifacts
+	}
+	return nil
+}
+
+func (x *ExecutionExit) GetFailureReason() ExecutionFailureReason {
+	if x != nil {
+		return x.FailureReason
+	}
+	return ExecutionFailureReason_EXECUTION_FAILURE_REASON_UNSPECIFIED
+}
+
+func (x *ExecutionExit) GetTimings() *ExecutionTimings {
+	if x != nil {
+		return x.Timings
+	}
+	return nil
+}
+
+func (x *ExecutionExit) GetTestResults() *ExecutionTestResults {
+	if x != nil {
+		return x.TestResults
+	}
+	return nil
+}
+
+func (x *ExecutionExit) GetRetriable() bool {
+	if x != nil {
+		return x.Retriable
+	}
+	return false
+}
+
+// ExecutionTimings breaks down where an execution's wall time went on the
+// server. Phases a backend did not go through, such as VM boot for a command
+// run in an already running sandbox, are zero.
+type ExecutionTimings struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	PolicyResolveMs int64                  `protobuf:"varint,1,opt,name=policy_resolve_ms,json=policyResolveMs,proto3" json:"policy_resolve_ms,omitempty"`
+	RootfsCopyMs    int64                  `protobuf:"varint,2,opt,name=rootfs_copy_ms,json=rootfsCopyMs,proto3" json:"rootfs_copy_ms,omitempty"`
+	VmStartMs       int64                  `protobuf:"varint,3,opt,name=vm_start_ms,json=vmStartMs,proto3" json:"vm_start_ms,omitempty"`
+	NetworkSetupMs  int64                  `protobuf:"varint,4,opt,name=network_setup_ms,json=networkSetupMs,proto3" json:"network_setup_ms,omitempty"`
+	VmReadyMs       int64                  `protobuf:"varint,5,opt,name=vm_ready_ms,json=vmReadyMs,proto3" json:"vm_ready_ms,omitempty"`
+	VsockWaitMs     int64                  `protobuf:"varint,6,opt,name=vsock_wait_ms,json=vsockWaitMs,proto3" json:"vsock_wait_ms,omitempty"`
+	GuestExecMs     int64                  `protobuf:"varint,7,opt,name=guest_exec_ms,json=guestExecMs,proto3" json:"guest_exec_ms,omitempty"`
+	TotalMs         int64                  `protobuf:"varint,8,opt,name=total_ms,json=totalMs,proto3" json:"total_ms,omitempty"`
+	ImageCacheHit   bool                   `protobuf:"varint,9,opt,name=image_cache_hit,json=imageCacheHit,proto3" json:"image_cache_hit,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ExecutionTimings) Reset() {
+	*x = ExecutionTimings{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[69]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecutionTimings) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecutionTimings) ProtoMessage() {}
+
+func (x *ExecutionTimings) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[69]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecutionTimings.ProtoReflect.Descriptor instead.
+func (*ExecutionTimings) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{69}
+}
+
+func (x *ExecutionTimings) GetPolicyResolveMs() int64 {
+	if x != nil {
+		return x.PolicyResolveMs
+	}
+	return 0
+}
+
+func (x *ExecutionTimings) GetRootfsCopyMs() int64 {
+	if x != nil {
+		return x.RootfsCopyMs
+	}
+	return 0
+}
+
+func (x *ExecutionTimings) GetVmStartMs() int64 {
+	if x != nil {
+		return x.VmStartMs
+	}
+	return 0
+}
+
+func (x *ExecutionTimings) GetNetworkSetupMs() int64 {
+	if x != nil {
+		return x.NetworkSetupMs
+	}
+	return 0
+}
+
+func (x *ExecutionTimings) GetVmReadyMs() int64 {
+	if x != nil {
+		return x.VmReadyMs
+	}
+	return 0
+}
+
+func (x *ExecutionTimings) GetVsockWaitMs() int64 {
+	if x != nil {
+		return x.VsockWaitMs
+	}
+	return 0
+}
+
+func (x *ExecutionTimings) GetGuestExecMs() int64 {
+	if x != nil {
+		return x.GuestExecMs
+	}
+	return 0
+}
+
+func (x *ExecutionTimings) GetTotalMs() int64 {
+	if x != nil {
+		return x.TotalMs
+	}
+	return 0
+}
+
+func (x *ExecutionTimings) GetImageCacheHit() bool {
+	if x != nil {
+		return x.ImageCacheHit
+	}
+	return false
+}
+
+type ExecutionExitMetadata struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserCpuMs     int64                  `protobuf:"varint,1,opt,name=user_cpu_ms,json=userCpuMs,proto3" json:"user_cpu_ms,omitempty"`
+	SystemCpuMs   int64                  `protobuf:"varint,2,opt,name=system_cpu_ms,json=systemCpuMs,proto3" json:"system_cpu_ms,omitempty"`
+	MaxRssBytes   int64                  `protobuf:"varint,3,opt,name=max_rss_bytes,json=maxRssBytes,proto3" json:"max_rss_bytes,omitempty"`
+	WallMs        int64                  `protobuf:"varint,4,opt,name=wall_ms,json=wallMs,proto3" json:"wall_ms,omitempty"`
+	Signaled      bool                   `protobuf:"varint,5,opt,name=signaled,proto3" json:"signaled,omitempty"`
+	Signal        int32                  `protobuf:"varint,6,opt,name=signal,proto3" json:"signal,omitempty"`
+	SignalName    string                 `protobuf:"bytes,7,opt,name=signal_name,json=signalName,proto3" json:"signal_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecutionExitMetadata) Reset() {
+	*x = ExecutionExitMetadata{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[70]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecutionExitMetadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecutionExitMetadata) ProtoMessage() {}
+
+func (x *ExecutionExitMetadata) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[70]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecutionExitMetadata.ProtoReflect.Descriptor instead.
+func (*ExecutionExitMetadata) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{70}
+}
+
+func (x *ExecutionExitMetadata) GetUserCpuMs() int64 {
+	if x != nil {
+		return x.UserCpuMs
+	}
+	return 0
+}
+
+func (x *ExecutionExitMetadata) GetSystemCpuMs() int64 {
+	if x != nil {
+		return x.SystemCpuMs
+	}
+	return 0
+}
+
+func (x *ExecutionExitMetadata) GetMaxRssBytes() int64 {
+	if x != nil {
+		return x.MaxRssBytes
+	}
+	return 0
+}
+
+func (x *ExecutionExitMetadata) GetWallMs() int64 {
+	if x != nil {
+		return x.WallMs
+	}
+	return 0
+}
+
+func (x *ExecutionExitMetadata) GetSignaled() bool {
+	if x != nil {
+		return x.Signaled
+	}
+	return false
+}
+
+func (x *ExecutionExitMetadata) GetSignal() int32 {
+	if x != nil {
+		return x.Signal
+	}
+	return 0
+}
+
+func (x *ExecutionExitMetadata) GetSignalName() string {
+	if x != nil {
+		return x.SignalName
+	}
+	return ""
+}
+
+type ExecutionStreamEvent struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	SandboxId   string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	ExecutionId string                 `protobuf:"bytes,2,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
+	Status      ExecutionStatus        `protobuf:"varint,3,opt,name=status,proto3,enum=cleanroom.v1.ExecutionStatus" json:"status,omitempty"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*ExecutionStreamEvent_Stdout
+	//	*ExecutionStreamEvent_Stderr
+	//	*ExecutionStreamEvent_Exit
+	//	*ExecutionStreamEvent_Message
+	//	*ExecutionStreamEvent_Environment
+	Payload       isExecutionStreamEvent_Payload `protobuf_oneof:"payload"`
+	OccurredAt    *timestamppb.Timestamp         `protobuf:"bytes,8,opt,name=occurred_at,json=occurredAt,proto3" json:"occurred_at,omitempty"`
+	ImageRef      string                         `protobuf:"bytes,9,opt,name=image_ref,json=imageRef,proto3" json:"image_ref,omitempty"`
+	ImageDigest   string                         `protobuf:"bytes,10,opt,name=image_digest,json=imageDigest,proto3" json:"image_digest,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecutionStreamEvent) Reset() {
+	*x = ExecutionStreamEvent{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[71]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecutionStreamEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecutionStreamEvent) ProtoMessage() {}
+
+func (x *ExecutionStreamEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[71]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecutionStreamEvent.ProtoReflect.Descriptor instead.
+func (*ExecutionStreamEvent) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{71}
+}
+
+func (x *ExecutionStreamEvent) GetSandboxId() string {
+	if x != nil {
+		return x.SandboxId
+	}
+	return ""
+}
+
+func (x *ExecutionStreamEvent) GetExecutionId() string {
+	if x != nil {
+		return x.ExecutionId
+	}
+	return ""
+}
+
+func (x *ExecutionStreamEvent) GetStatus() ExecutionStatus {
+	if x != nil {
+		return x.Status
+	}
+	return ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED
+}
+
+func (x *ExecutionStreamEvent) GetPayload() isExecutionStreamEvent_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *ExecutionStreamEvent) GetStdout() []byte {
+	if x != nil {
+		if x, ok := x.Payload.(*ExecutionStreamEvent_Stdout); ok {
+			return x.Stdout
+		}
+	}
+	return nil
+}
+
+func (x *ExecutionStreamEvent) GetStderr() []byte {
+	if x != nil {
+		if x, ok := x.Payload.(*ExecutionStreamEvent_Stderr); ok {
+			return x.Stderr
+		}
+	}
+	return nil
+}
+
+func (x *ExecutionStreamEvent) GetExit() *ExecutionExit {
+	if x != nil {
+		if x, ok := x.Payload.(*ExecutionStreamEvent_Exit); ok {
+			return x.Exit
+		}
+	}
+	return nil
+}
+
+func (x *ExecutionStreamEvent) GetMessage() string {
+	if x != nil {
+		if x, ok := x.Payload.(*ExecutionStreamEvent_Message); ok {
+			return x.Message
+		}
+	}
+	return ""
+}
+
+func (x *ExecutionStreamEvent) GetEnvironment() *ExecutionEnvironment {
+	if x != nil {
+		if x, ok := x.Payload.(*ExecutionStreamEvent_Environment); ok {
+			return x.Environment
+		}
+	}
+	return nil
 }
 
-// Deprecated: Use StreamSandboxEventsRequest.ProtoReflect.Descriptor instead.
-func (*StreamSandboxEventsRequest) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{16}
+func (x *ExecutionStreamEvent) GetOccurredAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.OccurredAt
+	}
+	return nil
 }
 
-func (x *StreamSandboxEventsRequest) GetSandboxId() string {
+func (x *ExecutionStreamEvent) GetImageRef() string {
 	if x != nil {
-		return x.SandboxId
+		return x.ImageRef
 	}
 	return ""
 }
 
-func (x *StreamSandboxEventsRequest) GetFollow() bool {
+func (x *ExecutionStreamEvent) GetImageDigest() string {
 	if x != nil {
-		return x.Follow
+		return x.ImageDigest
 	}
-	return false
+	return ""
 }
 
-type SandboxEvent struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
-	Status        SandboxStatus          `protobuf:"varint,2,opt,name=status,proto3,enum=cleanroom.v1.SandboxStatus" json:"status,omitempty"`
-	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
-	OccurredAt    *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=occurred_at,json=occurredAt,proto3" json:"occurred_at,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+type isExecutionStreamEvent_Payload interface {
+	isExecutionStreamEvent_Payload()
 }
 
-func (x *SandboxEvent) Reset() {
-	*x = SandboxEvent{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[17]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+type ExecutionStreamEvent_Stdout struct {
+	Stdout []byte `protobuf:"bytes,4,opt,name=stdout,proto3,oneof"`
 }
 
-func (x *SandboxEvent) String() string {
-	return protoimpl.X.MessageStringOf(x)
+type ExecutionStreamEvent_Stderr struct {
+	Stderr []byte `protobuf:"bytes,5,opt,name=stderr,proto3,oneof"`
 }
 
-func (*SandboxEvent) ProtoMessage() {}
-
-func (x *SandboxEvent) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[17]
-	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
+type ExecutionStreamEvent_Exit struct {
+	Exit *ExecutionExit `protobuf:"bytes,6,opt,name=exit,proto3,oneof"`
 }
 
-// Deprecated: Use SandboxEvent.ProtoReflect.Descriptor instead.
-func (*SandboxEvent) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{17}
+type ExecutionStreamEvent_Message struct {
+	Message string `protobuf:"bytes,7,opt,name=message,proto3,oneof"`
 }
 
-func (x *SandboxEvent) GetSandboxId() string {
-	if x != nil {
-		return x.SandboxId
-	}
-	return ""
+type ExecutionStreamEvent_Environment struct {
+	Environment *ExecutionEnvironment `protobuf:"bytes,11,opt,name=environment,proto3,oneof"`
 }
 
-func (x *SandboxEvent) GetStatus() SandboxStatus {
-	if x != nil {
-		return x.Status
-	}
-	return SandboxStatus_SANDBOX_STATUS_UNSPECIFIED
-}
+func (*ExecutionStreamEvent_Stdout) isExecutionStreamEvent_Payload() {}
 
-func (x *SandboxEvent) GetMessage() string {
-	if x != nil {
-		return x.Message
-	}
-	return ""
-}
+func (*ExecutionStreamEvent_Stderr) isExecutionStreamEvent_Payload() {}
 
-func (x *SandboxEvent) GetOccurredAt() *timestamppb.Timestamp {
-	if x != nil {
-		return x.OccurredAt
-	}
-	return nil
-}
+func (*ExecutionStreamEvent_Exit) isExecutionStreamEvent_Payload() {}
 
-type Execution struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	ExecutionId   string                 `protobuf:"bytes,1,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
-	SandboxId     string                 `protobuf:"bytes,2,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
-	Status        ExecutionStatus        `protobuf:"varint,3,opt,name=status,proto3,enum=cleanroom.v1.ExecutionStatus" json:"status,omitempty"`
-	Command       []string               `protobuf:"bytes,4,rep,name=command,proto3" json:"command,omitempty"`
-	ExitCode      int32                  `protobuf:"varint,5,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
-	StartedAt     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
-	FinishedAt    *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=finished_at,json=finishedAt,proto3" json:"finished_at,omitempty"`
-	Tty           bool                   `protobuf:"varint,8,opt,name=tty,proto3" json:"tty,omitempty"`
-	RunId         string                 `protobuf:"bytes,9,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
-	Kind          ExecutionKind          `protobuf:"varint,10,opt,name=kind,proto3,enum=cleanroom.v1.ExecutionKind" json:"kind,omitempty"`
+func (*ExecutionStreamEvent_Message) isExecutionStreamEvent_Payload() {}
+
+func (*ExecutionStreamEvent_Environment) isExecutionStreamEvent_Payload() {}
+
+// ExecutionEnvironment is sent once per execution, when it starts running,
+// so consumers of the stream have the context to reproduce the run without
+// another request. Fields the backend cannot report are left empty.
+type ExecutionEnvironment struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	ImageRef    string                 `protobuf:"bytes,1,opt,name=image_ref,json=imageRef,proto3" json:"image_ref,omitempty"`
+	ImageDigest string                 `protobuf:"bytes,2,opt,name=image_digest,json=imageDigest,proto3" json:"image_digest,omitempty"`
+	PolicyHash  string                 `protobuf:"bytes,3,opt,name=policy_hash,json=policyHash,proto3" json:"policy_hash,omitempty"`
+	Backend     string                 `protobuf:"bytes,4,opt,name=backend,proto3" json:"backend,omitempty"`
+	// Release of the server running the execution.
+	ServerVersion string `protobuf:"bytes,5,opt,name=server_version,json=serverVersion,proto3" json:"server_version,omitempty"`
+	// SHA-256 of the guest agent binary the sandbox runs.
+	GuestAgentSha256 string `protobuf:"bytes,6,opt,name=guest_agent_sha256,json=guestAgentSha256,proto3" json:"guest_agent_sha256,omitempty"`
+	// Release of the guest kernel, such as 6.1.155.
+	KernelVersion string `protobuf:"bytes,7,opt,name=kernel_version,json=kernelVersion,proto3" json:"kernel_version,omitempty"`
+	// Resources the sandbox's VM was given.
+	Vcpus     int64 `protobuf:"varint,8,opt,name=vcpus,proto3" json:"vcpus,omitempty"`
+	MemoryMib int64 `protobuf:"varint,9,opt,name=memory_mib,json=memoryMib,proto3" json:"memory_mib,omitempty"`
+	// Minimum root filesystem size; 0 means the image's own size.
+	DiskMib       int64 `protobuf:"varint,10,opt,name=disk_mib,json=diskMib,proto3" json:"disk_mib,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Execution) Reset() {
-	*x = Execution{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[18]
+func (x *ExecutionEnvironment) Reset() {
+	*x = ExecutionEnvironment{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[72]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Execution) String() string {
+func (x *ExecutionEnvironment) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Execution) ProtoMessage() {}
+func (*ExecutionEnvironment) ProtoMessage() {}
 
-func (x *Execution) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[18]
+func (x *ExecutionEnvironment) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[72]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1231,104 +5337,102 @@ func (x *Execution) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Execution.ProtoReflect.Descriptor instead.
-func (*Execution) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{18}
+// Deprecated: Use ExecutionEnvironment.ProtoReflect.Descriptor instead.
+func (*ExecutionEnvironment) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{72}
 }
 
-func (x *Execution) GetExecutionId() string {
+func (x *ExecutionEnvironment) GetImageRef() string {
 	if x != nil {
-		return x.ExecutionId
+		return x.ImageRef
 	}
 	return ""
 }
 
-func (x *Execution) GetSandboxId() string {
+func (x *ExecutionEnvironment) GetImageDigest() string {
 	if x != nil {
-		return x.SandboxId
+		return x.ImageDigest
 	}
 	return ""
 }
 
-func (x *Execution) GetStatus() ExecutionStatus {
+func (x *ExecutionEnvironment) GetPolicyHash() string {
 	if x != nil {
-		return x.Status
+		return x.PolicyHash
 	}
-	return ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED
+	return ""
 }
 
-func (x *Execution) GetCommand() []string {
+func (x *ExecutionEnvironment) GetBackend() string {
 	if x != nil {
-		return x.Command
+		return x.Backend
 	}
-	return nil
+	return ""
 }
 
-func (x *Execution) GetExitCode() int32 {
+func (x *ExecutionEnvironment) GetServerVersion() string {
 	if x != nil {
-		return x.ExitCode
+		return x.ServerVersion
 	}
-	return 0
+	return ""
 }
 
-func (x *Execution) GetStartedAt() *timestamppb.Timestamp {
+func (x *ExecutionEnvironment) GetGuestAgentSha256() string {
 	if x != nil {
-		return x.StartedAt
+		return x.GuestAgentSha256
 	}
-	return nil
+	return ""
 }
 
-func (x *Execution) GetFinishedAt() *timestamppb.Timestamp {
+func (x *ExecutionEnvironment) GetKernelVersion() string {
 	if x != nil {
-		return x.FinishedAt
+		return x.KernelVersion
 	}
-	return nil
+	return ""
 }
 
-func (x *Execution) GetTty() bool {
+func (x *ExecutionEnvironment) GetVcpus() int64 {
 	if x != nil {
-		return x.Tty
+		return x.Vcpus
 	}
-	return false
+	return 0
 }
 
-func (x *Execution) GetRunId() string {
+func (x *ExecutionEnvironment) GetMemoryMib() int64 {
 	if x != nil {
-		return x.RunId
+		return x.MemoryMib
 	}
-	return ""
+	return 0
 }
 
-func (x *Execution) GetKind() ExecutionKind {
+func (x *ExecutionEnvironment) GetDiskMib() int64 {
 	if x != nil {
-		return x.Kind
+		return x.DiskMib
 	}
-	return ExecutionKind_EXECUTION_KIND_UNSPECIFIED
+	return 0
 }
 
-type ExecutionOptions struct {
+type GetServerInfoRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	LaunchSeconds int64                  `protobuf:"varint,5,opt,name=launch_seconds,json=launchSeconds,proto3" json:"launch_seconds,omitempty"`
-	Tty           bool                   `protobuf:"varint,6,opt,name=tty,proto3" json:"tty,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ExecutionOptions) Reset() {
-	*x = ExecutionOptions{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[19]
+func (x *GetServerInfoRequest) Reset() {
+	*x = GetServerInfoRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[73]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ExecutionOptions) String() string {
+func (x *GetServerInfoRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ExecutionOptions) ProtoMessage() {}
+func (*GetServerInfoRequest) ProtoMessage() {}
 
-func (x *ExecutionOptions) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[19]
+func (x *GetServerInfoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[73]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1339,50 +5443,118 @@ func (x *ExecutionOptions) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ExecutionOptions.ProtoReflect.Descriptor instead.
-func (*ExecutionOptions) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{19}
+// Deprecated: Use GetServerInfoRequest.ProtoReflect.Descriptor instead.
+func (*GetServerInfoRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{73}
 }
 
-func (x *ExecutionOptions) GetLaunchSeconds() int64 {
+type GetServerInfoResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Release of the server, such as "v0.4.0" or "dev".
+	Version string `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	// Bumped for incompatible changes to the control API.
+	SchemaVersion uint32 `protobuf:"varint,2,opt,name=schema_version,json=schemaVersion,proto3" json:"schema_version,omitempty"`
+	// Oldest client schema_version the server still works with.
+	MinClientSchemaVersion uint32 `protobuf:"varint,3,opt,name=min_client_schema_version,json=minClientSchemaVersion,proto3" json:"min_client_schema_version,omitempty"`
+	// Image digests in the server's cache. A sandbox whose image is cached
+	// boots without a registry pull, so schedulers spreading sandboxes over
+	// several servers prefer one that lists the image.
+	CachedImageDigests []string `protobuf:"bytes,4,rep,name=cached_image_digests,json=cachedImageDigests,proto3" json:"cached_image_digests,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *GetServerInfoResponse) Reset() {
+	*x = GetServerInfoResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[74]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetServerInfoResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetServerInfoResponse) ProtoMessage() {}
+
+func (x *GetServerInfoResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[74]
 	if x != nil {
-		return x.LaunchSeconds
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetServerInfoResponse.ProtoReflect.Descriptor instead.
+func (*GetServerInfoResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{74}
+}
+
+func (x *GetServerInfoResponse) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *GetServerInfoResponse) GetSchemaVersion() uint32 {
+	if x != nil {
+		return x.SchemaVersion
 	}
 	return 0
 }
 
-func (x *ExecutionOptions) GetTty() bool {
+func (x *GetServerInfoResponse) GetMinClientSchemaVersion() uint32 {
 	if x != nil {
-		return x.Tty
+		return x.MinClientSchemaVersion
 	}
-	return false
+	return 0
 }
 
-type CreateExecutionRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
-	Command       []string               `protobuf:"bytes,2,rep,name=command,proto3" json:"command,omitempty"`
-	Options       *ExecutionOptions      `protobuf:"bytes,3,opt,name=options,proto3" json:"options,omitempty"`
-	Kind          ExecutionKind          `protobuf:"varint,4,opt,name=kind,proto3,enum=cleanroom.v1.ExecutionKind" json:"kind,omitempty"`
+func (x *GetServerInfoResponse) GetCachedImageDigests() []string {
+	if x != nil {
+		return x.CachedImageDigests
+	}
+	return nil
+}
+
+type GetUsageRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Start of the report. Defaults to 30 days ago.
+	Since *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=since,proto3" json:"since,omitempty"`
+	// Keys to group by: "identity", "namespace", "backend", "sandbox" or
+	// "label:<key>". Empty sums everything into one row.
+	GroupBy []string `protobuf:"bytes,2,rep,name=group_by,json=groupBy,proto3" json:"group_by,omitempty"`
+	// "sandbox" (the default) or "execution". Executions run inside
+	// sandboxes, so their usage breaks a sandbox's down rather than adding
+	// to it.
+	Kind string `protobuf:"bytes,3,opt,name=kind,proto3" json:"kind,omitempty"`
+	// Only report this namespace. Callers other than admins only ever see
+	// their own.
+	Namespace     string `protobuf:"bytes,4,opt,name=namespace,proto3" json:"namespace,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *CreateExecutionRequest) Reset() {
-	*x = CreateExecutionRequest{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[20]
+func (x *GetUsageRequest) Reset() {
+	*x = GetUsageRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[75]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *CreateExecutionRequest) String() string {
+func (x *GetUsageRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CreateExecutionRequest) ProtoMessage() {}
+func (*GetUsageRequest) ProtoMessage() {}
 
-func (x *CreateExecutionRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[20]
+func (x *GetUsageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[75]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1393,61 +5565,65 @@ func (x *CreateExecutionRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CreateExecutionRequest.ProtoReflect.Descriptor instead.
-func (*CreateExecutionRequest) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{20}
+// Deprecated: Use GetUsageRequest.ProtoReflect.Descriptor instead.
+func (*GetUsageRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{75}
 }
 
-func (x *CreateExecutionRequest) GetSandboxId() string {
+func (x *GetUsageRequest) GetSince() *timestamppb.Timestamp {
 	if x != nil {
-		return x.SandboxId
+		return x.Since
 	}
-	return ""
+	return nil
 }
 
-func (x *CreateExecutionRequest) GetCommand() []string {
+func (x *GetUsageRequest) GetGroupBy() []string {
 	if x != nil {
-		return x.Command
+		return x.GroupBy
 	}
 	return nil
 }
 
-func (x *CreateExecutionRequest) GetOptions() *ExecutionOptions {
+func (x *GetUsageRequest) GetKind() string {
 	if x != nil {
-		return x.Options
+		return x.Kind
 	}
-	return nil
+	return ""
 }
 
-func (x *CreateExecutionRequest) GetKind() ExecutionKind {
+func (x *GetUsageRequest) GetNamespace() string {
 	if x != nil {
-		return x.Kind
+		return x.Namespace
 	}
-	return ExecutionKind_EXECUTION_KIND_UNSPECIFIED
+	return ""
 }
 
-type CreateExecutionResponse struct {
+type GetUsageResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Execution     *Execution             `protobuf:"bytes,1,opt,name=execution,proto3" json:"execution,omitempty"`
+	Since         *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=since,proto3" json:"since,omitempty"`
+	Until         *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=until,proto3" json:"until,omitempty"`
+	GroupBy       []string               `protobuf:"bytes,3,rep,name=group_by,json=groupBy,proto3" json:"group_by,omitempty"`
+	Kind          string                 `protobuf:"bytes,4,opt,name=kind,proto3" json:"kind,omitempty"`
+	Rows          []*UsageRow            `protobuf:"bytes,5,rep,name=rows,proto3" json:"rows,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *CreateExecutionResponse) Reset() {
-	*x = CreateExecutionResponse{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[21]
+func (x *GetUsageResponse) Reset() {
+	*x = GetUsageResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[76]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *CreateExecutionResponse) String() string {
+func (x *GetUsageResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CreateExecutionResponse) ProtoMessage() {}
+func (*GetUsageResponse) ProtoMessage() {}
 
-func (x *CreateExecutionResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[21]
+func (x *GetUsageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[76]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1458,43 +5634,75 @@ func (x *CreateExecutionResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CreateExecutionResponse.ProtoReflect.Descriptor instead.
-func (*CreateExecutionResponse) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{21}
+// Deprecated: Use GetUsageResponse.ProtoReflect.Descriptor instead.
+func (*GetUsageResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{76}
+}
+
+func (x *GetUsageResponse) GetSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Since
+	}
+	return nil
+}
+
+func (x *GetUsageResponse) GetUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Until
+	}
+	return nil
+}
+
+func (x *GetUsageResponse) GetGroupBy() []string {
+	if x != nil {
+		return x.GroupBy
+	}
+	return nil
+}
+
+func (x *GetUsageResponse) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
 }
 
-func (x *CreateExecutionResponse) GetExecution() *Execution {
+func (x *GetUsageResponse) GetRows() []*UsageRow {
 	if x != nil {
-		return x.Execution
+		return x.Rows
 	}
 	return nil
 }
 
-type OpenInteractiveExecutionRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
-	ExecutionId   string                 `protobuf:"bytes,2,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
-	InitialCols   uint32                 `protobuf:"varint,3,opt,name=initial_cols,json=initialCols,proto3" json:"initial_cols,omitempty"`
-	InitialRows   uint32                 `protobuf:"varint,4,opt,name=initial_rows,json=initialRows,proto3" json:"initial_rows,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+// UsageRow sums the resources a group of sandboxes or executions reserved
+// within the report's window.
+type UsageRow struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Values of the request's group_by keys, in order.
+	Group            []string `protobuf:"bytes,1,rep,name=group,proto3" json:"group,omitempty"`
+	Count            int64    `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	VcpuSeconds      float64  `protobuf:"fixed64,3,opt,name=vcpu_seconds,json=vcpuSeconds,proto3" json:"vcpu_seconds,omitempty"`
+	MemoryMibSeconds float64  `protobuf:"fixed64,4,opt,name=memory_mib_seconds,json=memoryMibSeconds,proto3" json:"memory_mib_seconds,omitempty"`
+	DiskGbHours      float64  `protobuf:"fixed64,5,opt,name=disk_gb_hours,json=diskGbHours,proto3" json:"disk_gb_hours,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
 }
 
-func (x *OpenInteractiveExecutionRequest) Reset() {
-	*x = OpenInteractiveExecutionRequest{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[22]
+func (x *UsageRow) Reset() {
+	*x = UsageRow{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[77]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *OpenInteractiveExecutionRequest) String() string {
+func (x *UsageRow) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*OpenInteractiveExecutionRequest) ProtoMessage() {}
+func (*UsageRow) ProtoMessage() {}
 
-func (x *OpenInteractiveExecutionRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[22]
+func (x *UsageRow) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[77]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1505,66 +5713,88 @@ func (x *OpenInteractiveExecutionRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use OpenInteractiveExecutionRequest.ProtoReflect.Descriptor instead.
-func (*OpenInteractiveExecutionRequest) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{22}
+// Deprecated: Use UsageRow.ProtoReflect.Descriptor instead.
+func (*UsageRow) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{77}
 }
 
-func (x *OpenInteractiveExecutionRequest) GetSandboxId() string {
+func (x *UsageRow) GetGroup() []string {
 	if x != nil {
-		return x.SandboxId
+		return x.Group
 	}
-	return ""
+	return nil
 }
 
-func (x *OpenInteractiveExecutionRequest) GetExecutionId() string {
+func (x *UsageRow) GetCount() int64 {
 	if x != nil {
-		return x.ExecutionId
+		return x.Count
 	}
-	return ""
+	return 0
 }
 
-func (x *OpenInteractiveExecutionRequest) GetInitialCols() uint32 {
+func (x *UsageRow) GetVcpuSeconds() float64 {
 	if x != nil {
-		return x.InitialCols
+		return x.VcpuSeconds
 	}
 	return 0
 }
 
-func (x *OpenInteractiveExecutionRequest) GetInitialRows() uint32 {
+func (x *UsageRow) GetMemoryMibSeconds() float64 {
 	if x != nil {
-		return x.InitialRows
+		return x.MemoryMibSeconds
 	}
 	return 0
 }
 
-type OpenInteractiveExecutionResponse struct {
-	state               protoimpl.MessageState `protogen:"open.v1"`
-	SessionId           string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
-	SessionToken        string                 `protobuf:"bytes,2,opt,name=session_token,json=sessionToken,proto3" json:"session_token,omitempty"`
-	ExpiresAt           *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
-	QuicEndpoint        string                 `protobuf:"bytes,4,opt,name=quic_endpoint,json=quicEndpoint,proto3" json:"quic_endpoint,omitempty"`
-	Alpn                string                 `protobuf:"bytes,5,opt,name=alpn,proto3" json:"alpn,omitempty"`
-	ServerCertPinSha256 string                 `protobuf:"bytes,6,opt,name=server_cert_pin_sha256,json=serverCertPinSha256,proto3" json:"server_cert_pin_sha256,omitempty"`
-	unknownFields       protoimpl.UnknownFields
-	sizeCache           protoimpl.SizeCache
+func (x *UsageRow) GetDiskGbHours() float64 {
+	if x != nil {
+		return x.DiskGbHours
+	}
+	return 0
 }
 
-func (x *OpenInteractiveExecutionResponse) Reset() {
-	*x = OpenInteractiveExecutionResponse{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[23]
+// Schedule runs a command in a fresh sandbox whenever its cron expression
+// matches, then terminates the sandbox. Schedules belong to the server
+// that holds them.
+type Schedule struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	ScheduleId string                 `protobuf:"bytes,1,opt,name=schedule_id,json=scheduleId,proto3" json:"schedule_id,omitempty"`
+	// Five-field cron expression, matched in UTC.
+	Cron    string   `protobuf:"bytes,2,opt,name=cron,proto3" json:"cron,omitempty"`
+	Command []string `protobuf:"bytes,3,rep,name=command,proto3" json:"command,omitempty"`
+	Backend string   `protobuf:"bytes,4,opt,name=backend,proto3" json:"backend,omitempty"`
+	// Labels the schedule's sandboxes are created with, besides schedule.
+	Labels map[string]string `protobuf:"bytes,5,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// How long a run's command may take before it is canceled. 0 means the
+	// server default.
+	TimeoutSeconds int64  `protobuf:"varint,6,opt,name=timeout_seconds,json=timeoutSeconds,proto3" json:"timeout_seconds,omitempty"`
+	Namespace      string `protobuf:"bytes,7,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	// The caller that created the schedule. Runs act as it.
+	Identity   string                 `protobuf:"bytes,8,opt,name=identity,proto3" json:"identity,omitempty"`
+	PolicyHash string                 `protobuf:"bytes,9,opt,name=policy_hash,json=policyHash,proto3" json:"policy_hash,omitempty"`
+	CreatedAt  *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	NextRunAt  *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=next_run_at,json=nextRunAt,proto3" json:"next_run_at,omitempty"`
+	// The most recent run, if there has been one.
+	LastRun       *ScheduleRun `protobuf:"bytes,12,opt,name=last_run,json=lastRun,proto3" json:"last_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Schedule) Reset() {
+	*x = Schedule{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[78]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *OpenInteractiveExecutionResponse) String() string {
+func (x *Schedule) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*OpenInteractiveExecutionResponse) ProtoMessage() {}
+func (*Schedule) ProtoMessage() {}
 
-func (x *OpenInteractiveExecutionResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[23]
+func (x *Schedule) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[78]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1575,76 +5805,129 @@ func (x *OpenInteractiveExecutionResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use OpenInteractiveExecutionResponse.ProtoReflect.Descriptor instead.
-func (*OpenInteractiveExecutionResponse) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{23}
+// Deprecated: Use Schedule.ProtoReflect.Descriptor instead.
+func (*Schedule) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{78}
 }
 
-func (x *OpenInteractiveExecutionResponse) GetSessionId() string {
+func (x *Schedule) GetScheduleId() string {
 	if x != nil {
-		return x.SessionId
+		return x.ScheduleId
 	}
 	return ""
 }
 
-func (x *OpenInteractiveExecutionResponse) GetSessionToken() string {
+func (x *Schedule) GetCron() string {
 	if x != nil {
-		return x.SessionToken
+		return x.Cron
 	}
 	return ""
 }
 
-func (x *OpenInteractiveExecutionResponse) GetExpiresAt() *timestamppb.Timestamp {
+func (x *Schedule) GetCommand() []string {
 	if x != nil {
-		return x.ExpiresAt
+		return x.Command
 	}
 	return nil
 }
 
-func (x *OpenInteractiveExecutionResponse) GetQuicEndpoint() string {
+func (x *Schedule) GetBackend() string {
 	if x != nil {
-		return x.QuicEndpoint
+		return x.Backend
 	}
 	return ""
 }
 
-func (x *OpenInteractiveExecutionResponse) GetAlpn() string {
+func (x *Schedule) GetLabels() map[string]string {
 	if x != nil {
-		return x.Alpn
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *Schedule) GetTimeoutSeconds() int64 {
+	if x != nil {
+		return x.TimeoutSeconds
+	}
+	return 0
+}
+
+func (x *Schedule) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
 	}
 	return ""
 }
 
-func (x *OpenInteractiveExecutionResponse) GetServerCertPinSha256() string {
+func (x *Schedule) GetIdentity() string {
 	if x != nil {
-		return x.ServerCertPinSha256
+		return x.Identity
 	}
 	return ""
 }
 
-type GetExecutionRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
-	ExecutionId   string                 `protobuf:"bytes,2,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
+func (x *Schedule) GetPolicyHash() string {
+	if x != nil {
+		return x.PolicyHash
+	}
+	return ""
+}
+
+func (x *Schedule) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Schedule) GetNextRunAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NextRunAt
+	}
+	return nil
+}
+
+func (x *Schedule) GetLastRun() *ScheduleRun {
+	if x != nil {
+		return x.LastRun
+	}
+	return nil
+}
+
+// ScheduleRun is the outcome of one run of a schedule.
+type ScheduleRun struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	ScheduleId  string                 `protobuf:"bytes,1,opt,name=schedule_id,json=scheduleId,proto3" json:"schedule_id,omitempty"`
+	SandboxId   string                 `protobuf:"bytes,2,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
+	ExecutionId string                 `protobuf:"bytes,3,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
+	// The run directory of the execution, for cleanroom status --run-id.
+	RunId      string                 `protobuf:"bytes,4,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
+	StartedAt  *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	FinishedAt *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=finished_at,json=finishedAt,proto3" json:"finished_at,omitempty"`
+	// Unspecified when the run failed before its command started.
+	Status   ExecutionStatus `protobuf:"varint,7,opt,name=status,proto3,enum=cleanroom.v1.ExecutionStatus" json:"status,omitempty"`
+	ExitCode int32           `protobuf:"varint,8,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	// Why the run failed or was stopped, if it was.
+	Error         string `protobuf:"bytes,9,opt,name=error,proto3" json:"error,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetExecutionRequest) Reset() {
-	*x = GetExecutionRequest{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[24]
+func (x *ScheduleRun) Reset() {
+	*x = ScheduleRun{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[79]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetExecutionRequest) String() string {
+func (x *ScheduleRun) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetExecutionRequest) ProtoMessage() {}
+func (*ScheduleRun) ProtoMessage() {}
 
-func (x *GetExecutionRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[24]
+func (x *ScheduleRun) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[79]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1655,93 +5938,104 @@ func (x *GetExecutionRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetExecutionRequest.ProtoReflect.Descriptor instead.
-func (*GetExecutionRequest) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{24}
+// Deprecated: Use ScheduleRun.ProtoReflect.Descriptor instead.
+func (*ScheduleRun) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{79}
 }
 
-func (x *GetExecutionRequest) GetSandboxId() string {
+func (x *ScheduleRun) GetScheduleId() string {
+	if x != nil {
+		return x.ScheduleId
+	}
+	return ""
+}
+
+func (x *ScheduleRun) GetSandboxId() string {
 	if x != nil {
 		return x.SandboxId
 	}
 	return ""
 }
 
-func (x *GetExecutionRequest) GetExecutionId() string {
+func (x *ScheduleRun) GetExecutionId() string {
 	if x != nil {
 		return x.ExecutionId
 	}
 	return ""
 }
 
-type GetExecutionResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Execution     *Execution             `protobuf:"bytes,1,opt,name=execution,proto3" json:"execution,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *ScheduleRun) GetRunId() string {
+	if x != nil {
+		return x.RunId
+	}
+	return ""
 }
 
-func (x *GetExecutionResponse) Reset() {
-	*x = GetExecutionResponse{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[25]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+func (x *ScheduleRun) GetStartedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartedAt
+	}
+	return nil
 }
 
-func (x *GetExecutionResponse) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *ScheduleRun) GetFinishedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FinishedAt
+	}
+	return nil
 }
 
-func (*GetExecutionResponse) ProtoMessage() {}
-
-func (x *GetExecutionResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[25]
+func (x *ScheduleRun) GetStatus() ExecutionStatus {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.Status
 	}
-	return mi.MessageOf(x)
+	return ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED
 }
 
-// Deprecated: Use GetExecutionResponse.ProtoReflect.Descriptor instead.
-func (*GetExecutionResponse) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{25}
+func (x *ScheduleRun) GetExitCode() int32 {
+	if x != nil {
+		return x.ExitCode
+	}
+	return 0
 }
 
-func (x *GetExecutionResponse) GetExecution() *Execution {
+func (x *ScheduleRun) GetError() string {
 	if x != nil {
-		return x.Execution
+		return x.Error
 	}
-	return nil
+	return ""
 }
 
-type CancelExecutionRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
-	ExecutionId   string                 `protobuf:"bytes,2,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
-	Signal        int32                  `protobuf:"varint,3,opt,name=signal,proto3" json:"signal,omitempty"`
+type CreateScheduleRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Cron           string                 `protobuf:"bytes,1,opt,name=cron,proto3" json:"cron,omitempty"`
+	Policy         *Policy                `protobuf:"bytes,2,opt,name=policy,proto3" json:"policy,omitempty"`
+	Command        []string               `protobuf:"bytes,3,rep,name=command,proto3" json:"command,omitempty"`
+	Backend        string                 `protobuf:"bytes,4,opt,name=backend,proto3" json:"backend,omitempty"`
+	Labels         map[string]string      `protobuf:"bytes,5,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	TimeoutSeconds int64                  `protobuf:"varint,6,opt,name=timeout_seconds,json=timeoutSeconds,proto3" json:"timeout_seconds,omitempty"`
+	// Run in this namespace instead of the caller's. Only admins may name
+	// another namespace.
+	Namespace     string `protobuf:"bytes,7,opt,name=namespace,proto3" json:"namespace,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *CancelExecutionRequest) Reset() {
-	*x = CancelExecutionRequest{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[26]
+func (x *CreateScheduleRequest) Reset() {
+	*x = CreateScheduleRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[80]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *CancelExecutionRequest) String() string {
+func (x *CreateScheduleRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CancelExecutionRequest) ProtoMessage() {}
+func (*CreateScheduleRequest) ProtoMessage() {}
 
-func (x *CancelExecutionRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[26]
+func (x *CreateScheduleRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[80]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1752,57 +6046,130 @@ func (x *CancelExecutionRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CancelExecutionRequest.ProtoReflect.Descriptor instead.
-func (*CancelExecutionRequest) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{26}
+// Deprecated: Use CreateScheduleRequest.ProtoReflect.Descriptor instead.
+func (*CreateScheduleRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{80}
 }
 
-func (x *CancelExecutionRequest) GetSandboxId() string {
+func (x *CreateScheduleRequest) GetCron() string {
 	if x != nil {
-		return x.SandboxId
+		return x.Cron
 	}
 	return ""
 }
 
-func (x *CancelExecutionRequest) GetExecutionId() string {
+func (x *CreateScheduleRequest) GetPolicy() *Policy {
 	if x != nil {
-		return x.ExecutionId
+		return x.Policy
+	}
+	return nil
+}
+
+func (x *CreateScheduleRequest) GetCommand() []string {
+	if x != nil {
+		return x.Command
+	}
+	return nil
+}
+
+func (x *CreateScheduleRequest) GetBackend() string {
+	if x != nil {
+		return x.Backend
 	}
 	return ""
 }
 
-func (x *CancelExecutionRequest) GetSignal() int32 {
+func (x *CreateScheduleRequest) GetLabels() map[string]string {
 	if x != nil {
-		return x.Signal
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *CreateScheduleRequest) GetTimeoutSeconds() int64 {
+	if x != nil {
+		return x.TimeoutSeconds
 	}
 	return 0
 }
 
-type CancelExecutionResponse struct {
+func (x *CreateScheduleRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+type CreateScheduleResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
-	ExecutionId   string                 `protobuf:"bytes,2,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
-	Accepted      bool                   `protobuf:"varint,3,opt,name=accepted,proto3" json:"accepted,omitempty"`
-	Status        ExecutionStatus        `protobuf:"varint,4,opt,name=status,proto3,enum=cleanroom.v1.ExecutionStatus" json:"status,omitempty"`
+	Schedule      *Schedule              `protobuf:"bytes,1,opt,name=schedule,proto3" json:"schedule,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateScheduleResponse) Reset() {
+	*x = CreateScheduleResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[81]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateScheduleResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateScheduleResponse) ProtoMessage() {}
+
+func (x *CreateScheduleResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[81]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateScheduleResponse.ProtoReflect.Descriptor instead.
+func (*CreateScheduleResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{81}
+}
+
+func (x *CreateScheduleResponse) GetSchedule() *Schedule {
+	if x != nil {
+		return x.Schedule
+	}
+	return nil
+}
+
+type ListSchedulesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// List this namespace instead of the caller's. Only admins may name
+	// another namespace.
+	Namespace string `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	// List every namespace. Admins only.
+	AllNamespaces bool `protobuf:"varint,2,opt,name=all_namespaces,json=allNamespaces,proto3" json:"all_namespaces,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *CancelExecutionResponse) Reset() {
-	*x = CancelExecutionResponse{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[27]
+func (x *ListSchedulesRequest) Reset() {
+	*x = ListSchedulesRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[82]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *CancelExecutionResponse) String() string {
+func (x *ListSchedulesRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CancelExecutionResponse) ProtoMessage() {}
+func (*ListSchedulesRequest) ProtoMessage() {}
 
-func (x *CancelExecutionResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[27]
+func (x *ListSchedulesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[82]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1813,63 +6180,47 @@ func (x *CancelExecutionResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CancelExecutionResponse.ProtoReflect.Descriptor instead.
-func (*CancelExecutionResponse) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{27}
-}
-
-func (x *CancelExecutionResponse) GetSandboxId() string {
-	if x != nil {
-		return x.SandboxId
-	}
-	return ""
+// Deprecated: Use ListSchedulesRequest.ProtoReflect.Descriptor instead.
+func (*ListSchedulesRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{82}
 }
 
-func (x *CancelExecutionResponse) GetExecutionId() string {
+func (x *ListSchedulesRequest) GetNamespace() string {
 	if x != nil {
-		return x.ExecutionId
+		return x.Namespace
 	}
 	return ""
 }
 
-func (x *CancelExecutionResponse) GetAccepted() bool {
+func (x *ListSchedulesRequest) GetAllNamespaces() bool {
 	if x != nil {
-		return x.Accepted
+		return x.AllNamespaces
 	}
 	return false
 }
 
-func (x *CancelExecutionResponse) GetStatus() ExecutionStatus {
-	if x != nil {
-		return x.Status
-	}
-	return ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED
-}
-
-type StreamExecutionRequest struct {
+type ListSchedulesResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
-	ExecutionId   string                 `protobuf:"bytes,2,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
-	Follow        bool                   `protobuf:"varint,3,opt,name=follow,proto3" json:"follow,omitempty"`
+	Schedules     []*Schedule            `protobuf:"bytes,1,rep,name=schedules,proto3" json:"schedules,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *StreamExecutionRequest) Reset() {
-	*x = StreamExecutionRequest{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[28]
+func (x *ListSchedulesResponse) Reset() {
+	*x = ListSchedulesResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[83]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *StreamExecutionRequest) String() string {
+func (x *ListSchedulesResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*StreamExecutionRequest) ProtoMessage() {}
+func (*ListSchedulesResponse) ProtoMessage() {}
 
-func (x *StreamExecutionRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[28]
+func (x *ListSchedulesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[83]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1880,56 +6231,42 @@ func (x *StreamExecutionRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use StreamExecutionRequest.ProtoReflect.Descriptor instead.
-func (*StreamExecutionRequest) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{28}
-}
-
-func (x *StreamExecutionRequest) GetSandboxId() string {
-	if x != nil {
-		return x.SandboxId
-	}
-	return ""
-}
-
-func (x *StreamExecutionRequest) GetExecutionId() string {
-	if x != nil {
-		return x.ExecutionId
-	}
-	return ""
+// Deprecated: Use ListSchedulesResponse.ProtoReflect.Descriptor instead.
+func (*ListSchedulesResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{83}
 }
 
-func (x *StreamExecutionRequest) GetFollow() bool {
+func (x *ListSchedulesResponse) GetSchedules() []*Schedule {
 	if x != nil {
-		return x.Follow
+		return x.Schedules
 	}
-	return false
+	return nil
 }
 
-type ExecutionExit struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	ExitCode      int32                  `protobuf:"varint,1,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
-	Status        ExecutionStatus        `protobuf:"varint,2,opt,name=status,proto3,enum=cleanroom.v1.ExecutionStatus" json:"status,omitempty"`
-	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+type GetScheduleRequest struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	ScheduleId string                 `protobuf:"bytes,1,opt,name=schedule_id,json=scheduleId,proto3" json:"schedule_id,omitempty"`
+	// How many recent runs to return. Defaults to 20.
+	RunLimit      int32 `protobuf:"varint,2,opt,name=run_limit,json=runLimit,proto3" json:"run_limit,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ExecutionExit) Reset() {
-	*x = ExecutionExit{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[29]
+func (x *GetScheduleRequest) Reset() {
+	*x = GetScheduleRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[84]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ExecutionExit) String() string {
+func (x *GetScheduleRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ExecutionExit) ProtoMessage() {}
+func (*GetScheduleRequest) ProtoMessage() {}
 
-func (x *ExecutionExit) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[29]
+func (x *GetScheduleRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[84]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1940,66 +6277,49 @@ func (x *ExecutionExit) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ExecutionExit.ProtoReflect.Descriptor instead.
-func (*ExecutionExit) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{29}
-}
-
-func (x *ExecutionExit) GetExitCode() int32 {
-	if x != nil {
-		return x.ExitCode
-	}
-	return 0
+// Deprecated: Use GetScheduleRequest.ProtoReflect.Descriptor instead.
+func (*GetScheduleRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{84}
 }
 
-func (x *ExecutionExit) GetStatus() ExecutionStatus {
+func (x *GetScheduleRequest) GetScheduleId() string {
 	if x != nil {
-		return x.Status
+		return x.ScheduleId
 	}
-	return ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED
+	return ""
 }
 
-func (x *ExecutionExit) GetMessage() string {
+func (x *GetScheduleRequest) GetRunLimit() int32 {
 	if x != nil {
-		return x.Message
+		return x.RunLimit
 	}
-	return ""
+	return 0
 }
 
-type ExecutionStreamEvent struct {
-	state       protoimpl.MessageState `protogen:"open.v1"`
-	SandboxId   string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
-	ExecutionId string                 `protobuf:"bytes,2,opt,name=execution_id,json=executionId,proto3" json:"execution_id,omitempty"`
-	Status      ExecutionStatus        `protobuf:"varint,3,opt,name=status,proto3,enum=cleanroom.v1.ExecutionStatus" json:"status,omitempty"`
-	// Types that are valid to be assigned to Payload:
-	//
-	//	*ExecutionStreamEvent_Stdout
-	//	*ExecutionStreamEvent_Stderr
-	//	*ExecutionStreamEvent_Exit
-	//	*ExecutionStreamEvent_Message
-	Payload       isExecutionStreamEvent_Payload `protobuf_oneof:"payload"`
-	OccurredAt    *timestamppb.Timestamp         `protobuf:"bytes,8,opt,name=occurred_at,json=occurredAt,proto3" json:"occurred_at,omitempty"`
-	ImageRef      string                         `protobuf:"bytes,9,opt,name=image_ref,json=imageRef,proto3" json:"image_ref,omitempty"`
-	ImageDigest   string                         `protobuf:"bytes,10,opt,name=image_digest,json=imageDigest,proto3" json:"image_digest,omitempty"`
+type GetScheduleResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Schedule *Schedule              `protobuf:"bytes,1,opt,name=schedule,proto3" json:"schedule,omitempty"`
+	// Recent runs, newest first.
+	Runs          []*ScheduleRun `protobuf:"bytes,2,rep,name=runs,proto3" json:"runs,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ExecutionStreamEvent) Reset() {
-	*x = ExecutionStreamEvent{}
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[30]
+func (x *GetScheduleResponse) Reset() {
+	*x = GetScheduleResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[85]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ExecutionStreamEvent) String() string {
+func (x *GetScheduleResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ExecutionStreamEvent) ProtoMessage() {}
+func (*GetScheduleResponse) ProtoMessage() {}
 
-func (x *ExecutionStreamEvent) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[30]
+func (x *GetScheduleResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[85]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2010,129 +6330,110 @@ func (x *ExecutionStreamEvent) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ExecutionStreamEvent.ProtoReflect.Descriptor instead.
-func (*ExecutionStreamEvent) Descriptor() ([]byte, []int) {
-	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{30}
-}
-
-func (x *ExecutionStreamEvent) GetSandboxId() string {
-	if x != nil {
-		return x.SandboxId
-	}
-	return ""
-}
-
-func (x *ExecutionStreamEvent) GetExecutionId() string {
-	if x != nil {
-		return x.ExecutionId
-	}
-	return ""
+// Deprecated: Use GetScheduleResponse.ProtoReflect.Descriptor instead.
+func (*GetScheduleResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{85}
 }
 
-func (x *ExecutionStreamEvent) GetStatus() ExecutionStatus {
+func (x *GetScheduleResponse) GetSchedule() *Schedule {
 	if x != nil {
-		return x.Status
+		return x.Schedule
 	}
-	return ExecutionStatus_EXECUTION_STATUS_UNSPECIFIED
+	return nil
 }
 
-func (x *ExecutionStreamEvent) GetPayload() isExecutionStreamEvent_Payload {
+func (x *GetScheduleResponse) GetRuns() []*ScheduleRun {
 	if x != nil {
-		return x.Payload
+		return x.Runs
 	}
 	return nil
 }
 
-func (x *ExecutionStreamEvent) GetStdout() []byte {
-	if x != nil {
-		if x, ok := x.Payload.(*ExecutionStreamEvent_Stdout); ok {
-			return x.Stdout
-		}
-	}
-	return nil
+type DeleteScheduleRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ScheduleId    string                 `protobuf:"bytes,1,opt,name=schedule_id,json=scheduleId,proto3" json:"schedule_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ExecutionStreamEvent) GetStderr() []byte {
-	if x != nil {
-		if x, ok := x.Payload.(*ExecutionStreamEvent_Stderr); ok {
-			return x.Stderr
-		}
-	}
-	return nil
+func (x *DeleteScheduleRequest) Reset() {
+	*x = DeleteScheduleRequest{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[86]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-func (x *ExecutionStreamEvent) GetExit() *ExecutionExit {
-	if x != nil {
-		if x, ok := x.Payload.(*ExecutionStreamEvent_Exit); ok {
-			return x.Exit
-		}
-	}
-	return nil
+func (x *DeleteScheduleRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-func (x *ExecutionStreamEvent) GetMessage() string {
+func (*DeleteScheduleRequest) ProtoMessage() {}
+
+func (x *DeleteScheduleRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[86]
 	if x != nil {
-		if x, ok := x.Payload.(*ExecutionStreamEvent_Message); ok {
-			return x.Message
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
+		return ms
 	}
-	return ""
+	return mi.MessageOf(x)
 }
 
-func (x *ExecutionStreamEvent) GetOccurredAt() *timestamppb.Timestamp {
-	if x != nil {
-		return x.OccurredAt
-	}
-	return nil
+// Deprecated: Use DeleteScheduleRequest.ProtoReflect.Descriptor instead.
+func (*DeleteScheduleRequest) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{86}
 }
 
-func (x *ExecutionStreamEvent) GetImageRef() string {
+func (x *DeleteScheduleRequest) GetScheduleId() string {
 	if x != nil {
-		return x.ImageRef
+		return x.ScheduleId
 	}
 	return ""
 }
 
-func (x *ExecutionStreamEvent) GetImageDigest() string {
-	if x != nil {
-		return x.ImageDigest
-	}
-	return ""
+type DeleteScheduleResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-type isExecutionStreamEvent_Payload interface {
-	isExecutionStreamEvent_Payload()
+func (x *DeleteScheduleResponse) Reset() {
+	*x = DeleteScheduleResponse{}
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[87]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-type ExecutionStreamEvent_Stdout struct {
-	Stdout []byte `protobuf:"bytes,4,opt,name=stdout,proto3,oneof"`
+func (x *DeleteScheduleResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-type ExecutionStreamEvent_Stderr struct {
-	Stderr []byte `protobuf:"bytes,5,opt,name=stderr,proto3,oneof"`
-}
+func (*DeleteScheduleResponse) ProtoMessage() {}
 
-type ExecutionStreamEvent_Exit struct {
-	Exit *ExecutionExit `protobuf:"bytes,6,opt,name=exit,proto3,oneof"`
+func (x *DeleteScheduleResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_cleanroom_v1_control_proto_msgTypes[87]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
 
-type ExecutionStreamEvent_Message struct {
-	Message string `protobuf:"bytes,7,opt,name=message,proto3,oneof"`
+// Deprecated: Use DeleteScheduleResponse.ProtoReflect.Descriptor instead.
+func (*DeleteScheduleResponse) Descriptor() ([]byte, []int) {
+	return file_proto_cleanroom_v1_control_proto_rawDescGZIP(), []int{87}
 }
 
-func (*ExecutionStreamEvent_Stdout) isExecutionStreamEvent_Payload() {}
-
-func (*ExecutionStreamEvent_Stderr) isExecutionStreamEvent_Payload() {}
-
-func (*ExecutionStreamEvent_Exit) isExecutionStreamEvent_Payload() {}
-
-func (*ExecutionStreamEvent_Message) isExecutionStreamEvent_Payload() {}
-
 var File_proto_cleanroom_v1_control_proto protoreflect.FileDescriptor
 
 const file_proto_cleanroom_v1_control_proto_rawDesc = "" +
 	"\n" +
-	" proto/cleanroom/v1/control.proto\x12\fcleanroom.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x8e\x02\n" +
+	" proto/cleanroom/v1/control.proto\x12\fcleanroom.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x90\x05\n" +
 	"\aSandbox\x12\x1d\n" +
 	"\n" +
 	"sandbox_id\x18\x01 \x01(\tR\tsandboxId\x123\n" +
@@ -2143,14 +6444,63 @@ const file_proto_cleanroom_v1_control_proto_rawDesc = "" +
 	"\n" +
 	"created_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
 	"\n" +
-	"updated_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\";\n" +
+	"updated_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12\x12\n" +
+	"\x04name\x18\a \x01(\tR\x04name\x129\n" +
+	"\x06labels\x18\b \x03(\v2!.cleanroom.v1.Sandbox.LabelsEntryR\x06labels\x129\n" +
+	"\bcheckout\x18\t \x01(\v2\x1d.cleanroom.v1.SandboxCheckoutR\bcheckout\x12\x1d\n" +
+	"\n" +
+	"agent_hash\x18\n" +
+	" \x01(\tR\tagentHash\x12>\n" +
+	"\vresolutions\x18\v \x03(\v2\x1c.cleanroom.v1.HostResolutionR\vresolutions\x12\x19\n" +
+	"\bgroup_id\x18\f \x01(\tR\agroupId\x12#\n" +
+	"\rgroup_address\x18\r \x01(\tR\fgroupAddress\x12\x1c\n" +
+	"\tnamespace\x18\x0e \x01(\tR\tnamespace\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"B\n" +
+	"\x0eHostResolution\x12\x12\n" +
+	"\x04host\x18\x01 \x01(\tR\x04host\x12\x1c\n" +
+	"\taddresses\x18\x02 \x03(\tR\taddresses\"o\n" +
+	"\x0fSandboxCheckout\x12\x1e\n" +
+	"\n" +
+	"repository\x18\x01 \x01(\tR\n" +
+	"repository\x12\x10\n" +
+	"\x03ref\x18\x02 \x01(\tR\x03ref\x12\x12\n" +
+	"\x04path\x18\x03 \x01(\tR\x04path\x12\x16\n" +
+	"\x06commit\x18\x04 \x01(\tR\x06commit\"\x97\x01\n" +
 	"\x0fPolicyAllowRule\x12\x12\n" +
 	"\x04host\x18\x01 \x01(\tR\x04host\x12\x14\n" +
-	"\x05ports\x18\x02 \x03(\x05R\x05ports\"1\n" +
+	"\x05ports\x18\x02 \x03(\x05R\x05ports\x12\x1a\n" +
+	"\bprotocol\x18\x03 \x01(\tR\bprotocol\x12>\n" +
+	"\vport_ranges\x18\x04 \x03(\v2\x1d.cleanroom.v1.PolicyPortRangeR\n" +
+	"portRanges\"5\n" +
+	"\x0fPolicyPortRange\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\x05R\x04from\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\x05R\x02to\"K\n" +
 	"\x13PolicyDockerService\x12\x1a\n" +
-	"\brequired\x18\x01 \x01(\bR\brequired\"K\n" +
+	"\brequired\x18\x01 \x01(\bR\brequired\x12\x18\n" +
+	"\apreload\x18\x02 \x03(\tR\apreload\"0\n" +
+	"\x18PolicyOCIRegistryService\x12\x14\n" +
+	"\x05allow\x18\x01 \x03(\tR\x05allow\"M\n" +
+	"\x15PolicyPackagesService\x12\x10\n" +
+	"\x03npm\x18\x01 \x03(\tR\x03npm\x12\x12\n" +
+	"\x04pypi\x18\x02 \x03(\tR\x04pypi\x12\x0e\n" +
+	"\x02go\x18\x03 \x03(\tR\x02go\"\xd7\x01\n" +
 	"\x0ePolicyServices\x129\n" +
-	"\x06docker\x18\x01 \x01(\v2!.cleanroom.v1.PolicyDockerServiceR\x06docker\"\x8e\x02\n" +
+	"\x06docker\x18\x01 \x01(\v2!.cleanroom.v1.PolicyDockerServiceR\x06docker\x12I\n" +
+	"\foci_registry\x18\x02 \x01(\v2&.cleanroom.v1.PolicyOCIRegistryServiceR\vociRegistry\x12?\n" +
+	"\bpackages\x18\x03 \x01(\v2#.cleanroom.v1.PolicyPackagesServiceR\bpackages\"\xe1\x01\n" +
+	"\x0fPolicyResources\x12\x14\n" +
+	"\x05vcpus\x18\x01 \x01(\x03R\x05vcpus\x12\x1d\n" +
+	"\n" +
+	"memory_mib\x18\x02 \x01(\x03R\tmemoryMib\x12\x19\n" +
+	"\bdisk_mib\x18\x03 \x01(\x03R\adiskMib\x12\x1f\n" +
+	"\vegress_mbps\x18\x04 \x01(\x03R\n" +
+	"egressMbps\x12!\n" +
+	"\fingress_mbps\x18\x05 \x01(\x03R\vingressMbps\x12\x1b\n" +
+	"\tdisk_iops\x18\x06 \x01(\x03R\bdiskIops\x12\x1d\n" +
+	"\n" +
+	"disk_mibps\x18\a \x01(\x03R\tdiskMibps\"\x88\a\n" +
 	"\x06Policy\x12\x18\n" +
 	"\aversion\x18\x01 \x01(\x05R\aversion\x12\x1b\n" +
 	"\timage_ref\x18\x02 \x01(\tR\bimageRef\x12!\n" +
@@ -2158,37 +6508,132 @@ const file_proto_cleanroom_v1_control_proto_rawDesc = "" +
 	"\x0fnetwork_default\x18\x04 \x01(\tR\x0enetworkDefault\x123\n" +
 	"\x05allow\x18\x05 \x03(\v2\x1d.cleanroom.v1.PolicyAllowRuleR\x05allow\x12\x12\n" +
 	"\x04hash\x18\x06 \x01(\tR\x04hash\x128\n" +
-	"\bservices\x18\a \x01(\v2\x1c.cleanroom.v1.PolicyServicesR\bservices\"R\n" +
+	"\bservices\x18\a \x01(\v2\x1c.cleanroom.v1.PolicyServicesR\bservices\x12;\n" +
+	"\tresources\x18\b \x01(\v2\x1d.cleanroom.v1.PolicyResourcesR\tresources\x12!\n" +
+	"\fvfio_devices\x18\t \x03(\tR\vvfioDevices\x123\n" +
+	"\x15nested_virtualization\x18\n" +
+	" \x01(\bR\x14nestedVirtualization\x12L\n" +
+	"\x10read_only_rootfs\x18\v \x01(\v2\".cleanroom.v1.PolicyReadOnlyRootFSR\x0ereadOnlyRootfs\x12\x14\n" +
+	"\x05setup\x18\f \x03(\tR\x05setup\x12\x16\n" +
+	"\x06source\x18\r \x01(\tR\x06source\x12A\n" +
+	"\tvariables\x18\x0e \x03(\v2#.cleanroom.v1.Policy.VariablesEntryR\tvariables\x12?\n" +
+	"\n" +
+	"exit_codes\x18\x0f \x03(\v2 .cleanroom.v1.PolicyExitCodeRuleR\texitCodes\x12%\n" +
+	"\x0econsole_banner\x18\x10 \x01(\tR\rconsoleBanner\x12=\n" +
+	"\x1bconsole_max_session_seconds\x18\x11 \x01(\x03R\x18consoleMaxSessionSeconds\x12?\n" +
+	"\x1cconsole_idle_timeout_seconds\x18\x12 \x01(\x03R\x19consoleIdleTimeoutSeconds\x1a<\n" +
+	"\x0eVariablesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xf5\x01\n" +
+	"\x12PolicyExitCodeRule\x12\x14\n" +
+	"\x05codes\x18\x01 \x03(\x05R\x05codes\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x1c\n" +
+	"\tretriable\x18\x03 \x01(\bR\tretriable\x12S\n" +
+	"\vannotations\x18\x04 \x03(\v21.cleanroom.v1.PolicyExitCodeRule.AnnotationsEntryR\vannotations\x1a>\n" +
+	"\x10AnnotationsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"T\n" +
+	"\x14PolicyReadOnlyRootFS\x12<\n" +
+	"\bwritable\x18\x01 \x03(\v2 .cleanroom.v1.PolicyWritablePathR\bwritable\"W\n" +
+	"\x12PolicyWritablePath\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04disk\x18\x02 \x01(\bR\x04disk\x12\x19\n" +
+	"\bsize_mib\x18\x03 \x01(\x03R\asizeMib\"R\n" +
 	"\x0eSandboxOptions\x12%\n" +
-	"\x0elaunch_seconds\x18\x03 \x01(\x03R\rlaunchSecondsJ\x04\b\x02\x10\x03R\x13read_only_workspace\"\xa1\x01\n" +
+	"\x0elaunch_seconds\x18\x03 \x01(\x03R\rlaunchSecondsJ\x04\b\x02\x10\x03R\x13read_only_workspace\"\xde\x03\n" +
 	"\x14CreateSandboxRequest\x12\x18\n" +
 	"\abackend\x18\x02 \x01(\tR\abackend\x126\n" +
 	"\aoptions\x18\x03 \x01(\v2\x1c.cleanroom.v1.SandboxOptionsR\aoptions\x12,\n" +
-	"\x06policy\x18\x04 \x01(\v2\x14.cleanroom.v1.PolicyR\x06policyJ\x04\b\x01\x10\x02R\x03cwd\"\x87\x01\n" +
+	"\x06policy\x18\x04 \x01(\v2\x14.cleanroom.v1.PolicyR\x06policy\x12\x12\n" +
+	"\x04name\x18\x05 \x01(\tR\x04name\x12F\n" +
+	"\x06labels\x18\x06 \x03(\v2..cleanroom.v1.CreateSandboxRequest.LabelsEntryR\x06labels\x129\n" +
+	"\bcheckout\x18\a \x01(\v2\x1d.cleanroom.v1.SandboxCheckoutR\bcheckout\x12K\n" +
+	"\x12pinned_resolutions\x18\b \x03(\v2\x1c.cleanroom.v1.HostResolutionR\x11pinnedResolutions\x12\x1c\n" +
+	"\tnamespace\x18\t \x01(\tR\tnamespace\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01J\x04\b\x01\x10\x02R\x03cwd\"\x87\x01\n" +
 	"\x15CreateSandboxResponse\x12/\n" +
 	"\asandbox\x18\x01 \x01(\v2\x15.cleanroom.v1.SandboxR\asandbox\x12#\n" +
 	"\rpolicy_source\x18\x02 \x01(\tR\fpolicySource\x12\x18\n" +
-	"\amessage\x18\x03 \x01(\tR\amessage\"2\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\"h\n" +
+	"\x12SandboxGroupMember\x12<\n" +
+	"\asandbox\x18\x01 \x01(\v2\".cleanroom.v1.CreateSandboxRequestR\asandbox\x12\x14\n" +
+	"\x05ports\x18\x02 \x03(\x05R\x05ports\"W\n" +
+	"\x19CreateSandboxGroupRequest\x12:\n" +
+	"\amembers\x18\x01 \x03(\v2 .cleanroom.v1.SandboxGroupMemberR\amembers\"z\n" +
+	"\x1aCreateSandboxGroupResponse\x12\x19\n" +
+	"\bgroup_id\x18\x01 \x01(\tR\agroupId\x12A\n" +
+	"\tsandboxes\x18\x02 \x03(\v2#.cleanroom.v1.CreateSandboxResponseR\tsandboxes\"2\n" +
 	"\x11GetSandboxRequest\x12\x1d\n" +
 	"\n" +
 	"sandbox_id\x18\x01 \x01(\tR\tsandboxId\"E\n" +
 	"\x12GetSandboxResponse\x12/\n" +
-	"\asandbox\x18\x01 \x01(\v2\x15.cleanroom.v1.SandboxR\asandbox\"\x16\n" +
-	"\x14ListSandboxesRequest\"L\n" +
+	"\asandbox\x18\x01 \x01(\v2\x15.cleanroom.v1.SandboxR\asandbox\"[\n" +
+	"\x14ListSandboxesRequest\x12\x1c\n" +
+	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12%\n" +
+	"\x0eall_namespaces\x18\x02 \x01(\bR\rallNamespaces\"L\n" +
 	"\x15ListSandboxesResponse\x123\n" +
-	"\tsandboxes\x18\x01 \x03(\v2\x15.cleanroom.v1.SandboxR\tsandboxes\"l\n" +
+	"\tsandboxes\x18\x01 \x03(\v2\x15.cleanroom.v1.SandboxR\tsandboxes\"\xa8\x01\n" +
 	"\x1aDownloadSandboxFileRequest\x12\x1d\n" +
 	"\n" +
 	"sandbox_id\x18\x01 \x01(\tR\tsandboxId\x12\x12\n" +
 	"\x04path\x18\x02 \x01(\tR\x04path\x12\x1b\n" +
-	"\tmax_bytes\x18\x03 \x01(\x03R\bmaxBytes\"\x83\x01\n" +
+	"\tmax_bytes\x18\x03 \x01(\x03R\bmaxBytes\x12:\n" +
+	"\n" +
+	"delta_base\x18\x04 \x01(\v2\x1b.cleanroom.v1.FileDeltaBaseR\tdeltaBase\"N\n" +
+	"\rFileDeltaBase\x12\x1d\n" +
+	"\n" +
+	"block_size\x18\x01 \x01(\x03R\tblockSize\x12\x1e\n" +
+	"\n" +
+	"signatures\x18\x02 \x01(\fR\n" +
+	"signatures\"\xb1\x01\n" +
 	"\x1bDownloadSandboxFileResponse\x12\x1d\n" +
 	"\n" +
 	"sandbox_id\x18\x01 \x01(\tR\tsandboxId\x12\x12\n" +
 	"\x04path\x18\x02 \x01(\tR\x04path\x12\x12\n" +
 	"\x04data\x18\x03 \x01(\fR\x04data\x12\x1d\n" +
 	"\n" +
-	"size_bytes\x18\x04 \x01(\x03R\tsizeBytes\"8\n" +
+	"size_bytes\x18\x04 \x01(\x03R\tsizeBytes\x12\x14\n" +
+	"\x05delta\x18\x05 \x01(\fR\x05delta\x12\x16\n" +
+	"\x06sha256\x18\x06 \x01(\tR\x06sha256\"G\n" +
+	"\x14CommitSandboxRequest\x12\x1d\n" +
+	"\n" +
+	"sandbox_id\x18\x01 \x01(\tR\tsandboxId\x12\x10\n" +
+	"\x03ref\x18\x02 \x01(\tR\x03ref\"S\n" +
+	"\x15CommitSandboxResponse\x12\x1d\n" +
+	"\n" +
+	"sandbox_id\x18\x01 \x01(\tR\tsandboxId\x12\x1b\n" +
+	"\timage_ref\x18\x02 \x01(\tR\bimageRef\"\xef\x01\n" +
+	"\x13CloneSandboxRequest\x12\x1d\n" +
+	"\n" +
+	"sandbox_id\x18\x01 \x01(\tR\tsandboxId\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x05R\x05count\x12!\n" +
+	"\fsnapshot_ref\x18\x03 \x01(\tR\vsnapshotRef\x12E\n" +
+	"\x06labels\x18\x04 \x03(\v2-.cleanroom.v1.CloneSandboxRequest.LabelsEntryR\x06labels\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"h\n" +
+	"\x14CloneSandboxResponse\x123\n" +
+	"\tsandboxes\x18\x01 \x03(\v2\x15.cleanroom.v1.SandboxR\tsandboxes\x12\x1b\n" +
+	"\timage_ref\x18\x02 \x01(\tR\bimageRef\";\n" +
+	"\x1aUpgradeSandboxAgentRequest\x12\x1d\n" +
+	"\n" +
+	"sandbox_id\x18\x01 \x01(\tR\tsandboxId\"\x9a\x01\n" +
+	"\x1bUpgradeSandboxAgentResponse\x12/\n" +
+	"\asandbox\x18\x01 \x01(\v2\x15.cleanroom.v1.SandboxR\asandbox\x12.\n" +
+	"\x13previous_agent_hash\x18\x02 \x01(\tR\x11previousAgentHash\x12\x1a\n" +
+	"\bupgraded\x18\x03 \x01(\bR\bupgraded\"4\n" +
+	"\x13PauseSandboxRequest\x12\x1d\n" +
+	"\n" +
+	"sandbox_id\x18\x01 \x01(\tR\tsandboxId\"G\n" +
+	"\x14PauseSandboxResponse\x12/\n" +
+	"\asandbox\x18\x01 \x01(\v2\x15.cleanroom.v1.SandboxR\asandbox\"5\n" +
+	"\x14ResumeSandboxRequest\x12\x1d\n" +
+	"\n" +
+	"sandbox_id\x18\x01 \x01(\tR\tsandboxId\"H\n" +
+	"\x15ResumeSandboxResponse\x12/\n" +
+	"\asandbox\x18\x01 \x01(\v2\x15.cleanroom.v1.SandboxR\asandbox\"8\n" +
 	"\x17TerminateSandboxRequest\x12\x1d\n" +
 	"\n" +
 	"sandbox_id\x18\x01 \x01(\tR\tsandboxId\"s\n" +
@@ -2209,7 +6654,7 @@ const file_proto_cleanroom_v1_control_proto_rawDesc = "" +
 	"\x06status\x18\x02 \x01(\x0e2\x1b.cleanroom.v1.SandboxStatusR\x06status\x12\x18\n" +
 	"\amessage\x18\x03 \x01(\tR\amessage\x12;\n" +
 	"\voccurred_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
-	"occurredAt\"\x8d\x03\n" +
+	"occurredAt\"\xe9\a\n" +
 	"\tExecution\x12!\n" +
 	"\fexecution_id\x18\x01 \x01(\tR\vexecutionId\x12\x1d\n" +
 	"\n" +
@@ -2224,16 +6669,82 @@ const file_proto_cleanroom_v1_control_proto_rawDesc = "" +
 	"\x03tty\x18\b \x01(\bR\x03tty\x12\x15\n" +
 	"\x06run_id\x18\t \x01(\tR\x05runId\x12/\n" +
 	"\x04kind\x18\n" +
-	" \x01(\x0e2\x1b.cleanroom.v1.ExecutionKindR\x04kind\"q\n" +
+	" \x01(\x0e2\x1b.cleanroom.v1.ExecutionKindR\x04kind\x12H\n" +
+	"\rexit_metadata\x18\v \x01(\v2#.cleanroom.v1.ExecutionExitMetadataR\fexitMetadata\x12=\n" +
+	"\tartifacts\x18\f \x03(\v2\x1f.cleanroom.v1.ExecutionArtifactR\tartifacts\x12K\n" +
+	"\x0efailure_reason\x18\r \x01(\x0e2$.cleanroom.v1.ExecutionFailureReasonR\rfailureReason\x12;\n" +
+	"\bapproval\x18\x0e \x01(\v2\x1f.cleanroom.v1.ExecutionApprovalR\bapproval\x128\n" +
+	"\atimings\x18\x0f \x01(\v2\x1e.cleanroom.v1.ExecutionTimingsR\atimings\x12\x1c\n" +
+	"\tnamespace\x18\x10 \x01(\tR\tnamespace\x12J\n" +
+	"\vannotations\x18\x11 \x03(\v2(.cleanroom.v1.Execution.AnnotationsEntryR\vannotations\x12E\n" +
+	"\ftest_results\x18\x12 \x01(\v2\".cleanroom.v1.ExecutionTestResultsR\vtestResults\x12\x1c\n" +
+	"\tretriable\x18\x13 \x01(\bR\tretriable\x1a>\n" +
+	"\x10AnnotationsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xb3\x02\n" +
+	"\x11ExecutionApproval\x12!\n" +
+	"\frequested_by\x18\x01 \x01(\tR\vrequestedBy\x12\x14\n" +
+	"\x05rules\x18\x02 \x03(\tR\x05rules\x12\x18\n" +
+	"\adecided\x18\x03 \x01(\bR\adecided\x12\x1a\n" +
+	"\bapproved\x18\x04 \x01(\bR\bapproved\x12\x1d\n" +
+	"\n" +
+	"decided_by\x18\x05 \x01(\tR\tdecidedBy\x12\x16\n" +
+	"\x06reason\x18\x06 \x01(\tR\x06reason\x12=\n" +
+	"\frequested_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\vrequestedAt\x129\n" +
+	"\n" +
+	"decided_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tdecidedAt\"Z\n" +
+	"\x11ExecutionArtifact\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1d\n" +
+	"\n" +
+	"media_type\x18\x03 \x01(\tR\tmediaType\"\xaf\x04\n" +
 	"\x10ExecutionOptions\x12%\n" +
 	"\x0elaunch_seconds\x18\x05 \x01(\x03R\rlaunchSeconds\x12\x10\n" +
-	"\x03tty\x18\x06 \x01(\bR\x03ttyJ\x04\b\x02\x10\x03J\x04\b\a\x10\bR\x13read_only_workspaceR\x03cwd\"\xbc\x01\n" +
+	"\x03tty\x18\x06 \x01(\bR\x03tty\x12=\n" +
+	"\x06limits\x18\b \x01(\v2%.cleanroom.v1.ExecutionResourceLimitsR\x06limits\x12:\n" +
+	"\x19keep_background_processes\x18\t \x01(\bR\x17keepBackgroundProcesses\x12\x1d\n" +
+	"\n" +
+	"fresh_home\x18\n" +
+	" \x01(\bR\tfreshHome\x12;\n" +
+	"\blauncher\x18\v \x01(\x0e2\x1f.cleanroom.v1.ExecutionLauncherR\blauncher\x12\x14\n" +
+	"\x05stdin\x18\f \x01(\bR\x05stdin\x12\x14\n" +
+	"\x05vcpus\x18\r \x01(\x03R\x05vcpus\x12\x1d\n" +
+	"\n" +
+	"memory_mib\x18\x0e \x01(\x03R\tmemoryMib\x12M\n" +
+	"\x0fcapture_changes\x18\x0f \x01(\x0e2$.cleanroom.v1.ExecutionChangeCaptureR\x0ecaptureChanges\x12K\n" +
+	"\x0eresult_parsers\x18\x10 \x01(\v2$.cleanroom.v1.ExecutionResultParsersR\rresultParsersJ\x04\b\x02\x10\x03J\x04\b\a\x10\bR\x13read_only_workspaceR\x03cwd\"[\n" +
+	"\x16ExecutionResultParsers\x12 \n" +
+	"\fgo_test_json\x18\x01 \x01(\bR\n" +
+	"goTestJson\x12\x1f\n" +
+	"\vjunit_paths\x18\x02 \x03(\tR\n" +
+	"junitPaths\"\xcb\x01\n" +
+	"\x14ExecutionTestResults\x12\x14\n" +
+	"\x05total\x18\x01 \x01(\x05R\x05total\x12\x16\n" +
+	"\x06passed\x18\x02 \x01(\x05R\x06passed\x12\x16\n" +
+	"\x06failed\x18\x03 \x01(\x05R\x06failed\x12\x18\n" +
+	"\askipped\x18\x04 \x01(\x05R\askipped\x12!\n" +
+	"\ffailed_tests\x18\x05 \x03(\tR\vfailedTests\x12\x18\n" +
+	"\asources\x18\x06 \x03(\tR\asources\x12\x16\n" +
+	"\x06errors\x18\a \x03(\tR\x06errors\"\xb2\x01\n" +
+	"\x17ExecutionResourceLimits\x12\x12\n" +
+	"\x04nice\x18\x01 \x01(\x05R\x04nice\x12\x19\n" +
+	"\bio_class\x18\x02 \x01(\tR\aioClass\x12\x1f\n" +
+	"\vio_priority\x18\x03 \x01(\x05R\n" +
+	"ioPriority\x12\x1d\n" +
+	"\n" +
+	"cpu_weight\x18\x04 \x01(\x03R\tcpuWeight\x12(\n" +
+	"\x10memory_max_bytes\x18\x05 \x01(\x03R\x0ememoryMaxBytes\"\xec\x02\n" +
 	"\x16CreateExecutionRequest\x12\x1d\n" +
 	"\n" +
 	"sandbox_id\x18\x01 \x01(\tR\tsandboxId\x12\x18\n" +
 	"\acommand\x18\x02 \x03(\tR\acommand\x128\n" +
 	"\aoptions\x18\x03 \x01(\v2\x1e.cleanroom.v1.ExecutionOptionsR\aoptions\x12/\n" +
-	"\x04kind\x18\x04 \x01(\x0e2\x1b.cleanroom.v1.ExecutionKindR\x04kind\"P\n" +
+	"\x04kind\x18\x04 \x01(\x0e2\x1b.cleanroom.v1.ExecutionKindR\x04kind\x12\x15\n" +
+	"\x06run_id\x18\x05 \x01(\tR\x05runId\x12W\n" +
+	"\vannotations\x18\x06 \x03(\v25.cleanroom.v1.CreateExecutionRequest.AnnotationsEntryR\vannotations\x1a>\n" +
+	"\x10AnnotationsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"P\n" +
 	"\x17CreateExecutionResponse\x125\n" +
 	"\texecution\x18\x01 \x01(\v2\x17.cleanroom.v1.ExecutionR\texecution\"\xa9\x01\n" +
 	"\x1fOpenInteractiveExecutionRequest\x12\x1d\n" +
@@ -2267,16 +6778,89 @@ const file_proto_cleanroom_v1_control_proto_rawDesc = "" +
 	"sandbox_id\x18\x01 \x01(\tR\tsandboxId\x12!\n" +
 	"\fexecution_id\x18\x02 \x01(\tR\vexecutionId\x12\x1a\n" +
 	"\baccepted\x18\x03 \x01(\bR\baccepted\x125\n" +
-	"\x06status\x18\x04 \x01(\x0e2\x1d.cleanroom.v1.ExecutionStatusR\x06status\"r\n" +
+	"\x06status\x18\x04 \x01(\x0e2\x1d.cleanroom.v1.ExecutionStatusR\x06status\"\x1d\n" +
+	"\x1bListPendingApprovalsRequest\"\xe4\x01\n" +
+	"\x0fPendingApproval\x125\n" +
+	"\texecution\x18\x01 \x01(\v2\x17.cleanroom.v1.ExecutionR\texecution\x12/\n" +
+	"\asandbox\x18\x02 \x01(\v2\x15.cleanroom.v1.SandboxR\asandbox\x12\x1b\n" +
+	"\timage_ref\x18\x03 \x01(\tR\bimageRef\x12'\n" +
+	"\x0fnetwork_default\x18\x04 \x01(\tR\x0enetworkDefault\x12#\n" +
+	"\rallowed_hosts\x18\x05 \x03(\tR\fallowedHosts\"[\n" +
+	"\x1cListPendingApprovalsResponse\x12;\n" +
+	"\tapprovals\x18\x01 \x03(\v2\x1d.cleanroom.v1.PendingApprovalR\tapprovals\"\x95\x01\n" +
+	"\x1fResolveExecutionApprovalRequest\x12\x1d\n" +
+	"\n" +
+	"sandbox_id\x18\x01 \x01(\tR\tsandboxId\x12!\n" +
+	"\fexecution_id\x18\x02 \x01(\tR\vexecutionId\x12\x18\n" +
+	"\aapprove\x18\x03 \x01(\bR\aapprove\x12\x16\n" +
+	"\x06reason\x18\x04 \x01(\tR\x06reason\"Y\n" +
+	" ResolveExecutionApprovalResponse\x125\n" +
+	"\texecution\x18\x01 \x01(\v2\x17.cleanroom.v1.ExecutionR\texecution\"\xf7\x01\n" +
+	"\x18AnnotateExecutionRequest\x12\x1d\n" +
+	"\n" +
+	"sandbox_id\x18\x01 \x01(\tR\tsandboxId\x12!\n" +
+	"\fexecution_id\x18\x02 \x01(\tR\vexecutionId\x12Y\n" +
+	"\vannotations\x18\x03 \x03(\v27.cleanroom.v1.AnnotateExecutionRequest.AnnotationsEntryR\vannotations\x1a>\n" +
+	"\x10AnnotationsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"R\n" +
+	"\x19AnnotateExecutionResponse\x125\n" +
+	"\texecution\x18\x01 \x01(\v2\x17.cleanroom.v1.ExecutionR\texecution\"\xce\x01\n" +
+	"\x15ListExecutionsRequest\x12\x1d\n" +
+	"\n" +
+	"sandbox_id\x18\x01 \x01(\tR\tsandboxId\x12V\n" +
+	"\vannotations\x18\x02 \x03(\v24.cleanroom.v1.ListExecutionsRequest.AnnotationsEntryR\vannotations\x1a>\n" +
+	"\x10AnnotationsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"Q\n" +
+	"\x16ListExecutionsResponse\x127\n" +
+	"\n" +
+	"executions\x18\x01 \x03(\v2\x17.cleanroom.v1.ExecutionR\n" +
+	"executions\"\x84\x01\n" +
+	"\x1aWriteExecutionStdinRequest\x12\x1d\n" +
+	"\n" +
+	"sandbox_id\x18\x01 \x01(\tR\tsandboxId\x12!\n" +
+	"\fexecution_id\x18\x02 \x01(\tR\vexecutionId\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\x12\x10\n" +
+	"\x03eof\x18\x04 \x01(\bR\x03eof\"_\n" +
+	"\x1bWriteExecutionStdinResponse\x12\x1d\n" +
+	"\n" +
+	"sandbox_id\x18\x01 \x01(\tR\tsandboxId\x12!\n" +
+	"\fexecution_id\x18\x02 \x01(\tR\vexecutionId\"r\n" +
 	"\x16StreamExecutionRequest\x12\x1d\n" +
 	"\n" +
 	"sandbox_id\x18\x01 \x01(\tR\tsandboxId\x12!\n" +
 	"\fexecution_id\x18\x02 \x01(\tR\vexecutionId\x12\x16\n" +
-	"\x06follow\x18\x03 \x01(\bR\x06follow\"}\n" +
+	"\x06follow\x18\x03 \x01(\bR\x06follow\"\xe9\x03\n" +
 	"\rExecutionExit\x12\x1b\n" +
 	"\texit_code\x18\x01 \x01(\x05R\bexitCode\x125\n" +
 	"\x06status\x18\x02 \x01(\x0e2\x1d.cleanroom.v1.ExecutionStatusR\x06status\x12\x18\n" +
-	"\amessage\x18\x03 \x01(\tR\amessage\"\x9a\x03\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\x12?\n" +
+	"\bmetadata\x18\x04 \x01(\v2#.cleanroom.v1.ExecutionExitMetadataR\bmetadata\x12=\n" +
+	"\tartifacts\x18\x05 \x03(\v2\x1f.cleanroom.v1.ExecutionArtifactR\tartifacts\x12K\n" +
+	"\x0efailure_reason\x18\x06 \x01(\x0e2$.cleanroom.v1.ExecutionFailureReasonR\rfailureReason\x128\n" +
+	"\atimings\x18\a \x01(\v2\x1e.cleanroom.v1.ExecutionTimingsR\atimings\x12E\n" +
+	"\ftest_results\x18\b \x01(\v2\".cleanroom.v1.ExecutionTestResultsR\vtestResults\x12\x1c\n" +
+	"\tretriable\x18\t \x01(\bR\tretriable\"\xd9\x02\n" +
+	"\x10ExecutionTimings\x12*\n" +
+	"\x11policy_resolve_ms\x18\x01 \x01(\x03R\x0fpolicyResolveMs\x12$\n" +
+	"\x0erootfs_copy_ms\x18\x02 \x01(\x03R\frootfsCopyMs\x12\x1e\n" +
+	"\vvm_start_ms\x18\x03 \x01(\x03R\tvmStartMs\x12(\n" +
+	"\x10network_setup_ms\x18\x04 \x01(\x03R\x0enetworkSetupMs\x12\x1e\n" +
+	"\vvm_ready_ms\x18\x05 \x01(\x03R\tvmReadyMs\x12\"\n" +
+	"\rvsock_wait_ms\x18\x06 \x01(\x03R\vvsockWaitMs\x12\"\n" +
+	"\rguest_exec_ms\x18\a \x01(\x03R\vguestExecMs\x12\x19\n" +
+	"\btotal_ms\x18\b \x01(\x03R\atotalMs\x12&\n" +
+	"\x0fimage_cache_hit\x18\t \x01(\bR\rimageCacheHit\"\xed\x01\n" +
+	"\x15ExecutionExitMetadata\x12\x1e\n" +
+	"\vuser_cpu_ms\x18\x01 \x01(\x03R\tuserCpuMs\x12\"\n" +
+	"\rsystem_cpu_ms\x18\x02 \x01(\x03R\vsystemCpuMs\x12\"\n" +
+	"\rmax_rss_bytes\x18\x03 \x01(\x03R\vmaxRssBytes\x12\x17\n" +
+	"\awall_ms\x18\x04 \x01(\x03R\x06wallMs\x12\x1a\n" +
+	"\bsignaled\x18\x05 \x01(\bR\bsignaled\x12\x16\n" +
+	"\x06signal\x18\x06 \x01(\x05R\x06signal\x12\x1f\n" +
+	"\vsignal_name\x18\a \x01(\tR\n" +
+	"signalName\"\xe2\x03\n" +
 	"\x14ExecutionStreamEvent\x12\x1d\n" +
 	"\n" +
 	"sandbox_id\x18\x01 \x01(\tR\tsandboxId\x12!\n" +
@@ -2285,20 +6869,127 @@ const file_proto_cleanroom_v1_control_proto_rawDesc = "" +
 	"\x06stdout\x18\x04 \x01(\fH\x00R\x06stdout\x12\x18\n" +
 	"\x06stderr\x18\x05 \x01(\fH\x00R\x06stderr\x121\n" +
 	"\x04exit\x18\x06 \x01(\v2\x1b.cleanroom.v1.ExecutionExitH\x00R\x04exit\x12\x1a\n" +
-	"\amessage\x18\a \x01(\tH\x00R\amessage\x12;\n" +
+	"\amessage\x18\a \x01(\tH\x00R\amessage\x12F\n" +
+	"\venvironment\x18\v \x01(\v2\".cleanroom.v1.ExecutionEnvironmentH\x00R\venvironment\x12;\n" +
 	"\voccurred_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\n" +
 	"occurredAt\x12\x1b\n" +
 	"\timage_ref\x18\t \x01(\tR\bimageRef\x12!\n" +
 	"\fimage_digest\x18\n" +
 	" \x01(\tR\vimageDigestB\t\n" +
-	"\apayload*\xbe\x01\n" +
+	"\apayload\"\xdd\x02\n" +
+	"\x14ExecutionEnvironment\x12\x1b\n" +
+	"\timage_ref\x18\x01 \x01(\tR\bimageRef\x12!\n" +
+	"\fimage_digest\x18\x02 \x01(\tR\vimageDigest\x12\x1f\n" +
+	"\vpolicy_hash\x18\x03 \x01(\tR\n" +
+	"policyHash\x12\x18\n" +
+	"\abackend\x18\x04 \x01(\tR\abackend\x12%\n" +
+	"\x0eserver_version\x18\x05 \x01(\tR\rserverVersion\x12,\n" +
+	"\x12guest_agent_sha256\x18\x06 \x01(\tR\x10guestAgentSha256\x12%\n" +
+	"\x0ekernel_version\x18\a \x01(\tR\rkernelVersion\x12\x14\n" +
+	"\x05vcpus\x18\b \x01(\x03R\x05vcpus\x12\x1d\n" +
+	"\n" +
+	"memory_mib\x18\t \x01(\x03R\tmemoryMib\x12\x19\n" +
+	"\bdisk_mib\x18\n" +
+	" \x01(\x03R\adiskMib\"\x16\n" +
+	"\x14GetServerInfoRequest\"\xc5\x01\n" +
+	"\x15GetServerInfoResponse\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\tR\aversion\x12%\n" +
+	"\x0eschema_version\x18\x02 \x01(\rR\rschemaVersion\x129\n" +
+	"\x19min_client_schema_version\x18\x03 \x01(\rR\x16minClientSchemaVersion\x120\n" +
+	"\x14cached_image_digests\x18\x04 \x03(\tR\x12cachedImageDigests\"\x90\x01\n" +
+	"\x0fGetUsageRequest\x120\n" +
+	"\x05since\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05since\x12\x19\n" +
+	"\bgroup_by\x18\x02 \x03(\tR\agroupBy\x12\x12\n" +
+	"\x04kind\x18\x03 \x01(\tR\x04kind\x12\x1c\n" +
+	"\tnamespace\x18\x04 \x01(\tR\tnamespace\"\xd1\x01\n" +
+	"\x10GetUsageResponse\x120\n" +
+	"\x05since\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05since\x120\n" +
+	"\x05until\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x05until\x12\x19\n" +
+	"\bgroup_by\x18\x03 \x03(\tR\agroupBy\x12\x12\n" +
+	"\x04kind\x18\x04 \x01(\tR\x04kind\x12*\n" +
+	"\x04rows\x18\x05 \x03(\v2\x16.cleanroom.v1.UsageRowR\x04rows\"\xab\x01\n" +
+	"\bUsageRow\x12\x14\n" +
+	"\x05group\x18\x01 \x03(\tR\x05group\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x03R\x05count\x12!\n" +
+	"\fvcpu_seconds\x18\x03 \x01(\x01R\vvcpuSeconds\x12,\n" +
+	"\x12memory_mib_seconds\x18\x04 \x01(\x01R\x10memoryMibSeconds\x12\"\n" +
+	"\rdisk_gb_hours\x18\x05 \x01(\x01R\vdiskGbHours\"\x9b\x04\n" +
+	"\bSchedule\x12\x1f\n" +
+	"\vschedule_id\x18\x01 \x01(\tR\n" +
+	"scheduleId\x12\x12\n" +
+	"\x04cron\x18\x02 \x01(\tR\x04cron\x12\x18\n" +
+	"\acommand\x18\x03 \x03(\tR\acommand\x12\x18\n" +
+	"\abackend\x18\x04 \x01(\tR\abackend\x12:\n" +
+	"\x06labels\x18\x05 \x03(\v2\".cleanroom.v1.Schedule.LabelsEntryR\x06labels\x12'\n" +
+	"\x0ftimeout_seconds\x18\x06 \x01(\x03R\x0etimeoutSeconds\x12\x1c\n" +
+	"\tnamespace\x18\a \x01(\tR\tnamespace\x12\x1a\n" +
+	"\bidentity\x18\b \x01(\tR\bidentity\x12\x1f\n" +
+	"\vpolicy_hash\x18\t \x01(\tR\n" +
+	"policyHash\x129\n" +
+	"\n" +
+	"created_at\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12:\n" +
+	"\vnext_run_at\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\tnextRunAt\x124\n" +
+	"\blast_run\x18\f \x01(\v2\x19.cleanroom.v1.ScheduleRunR\alastRun\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xe9\x02\n" +
+	"\vScheduleRun\x12\x1f\n" +
+	"\vschedule_id\x18\x01 \x01(\tR\n" +
+	"scheduleId\x12\x1d\n" +
+	"\n" +
+	"sandbox_id\x18\x02 \x01(\tR\tsandboxId\x12!\n" +
+	"\fexecution_id\x18\x03 \x01(\tR\vexecutionId\x12\x15\n" +
+	"\x06run_id\x18\x04 \x01(\tR\x05runId\x129\n" +
+	"\n" +
+	"started_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tstartedAt\x12;\n" +
+	"\vfinished_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"finishedAt\x125\n" +
+	"\x06status\x18\a \x01(\x0e2\x1d.cleanroom.v1.ExecutionStatusR\x06status\x12\x1b\n" +
+	"\texit_code\x18\b \x01(\x05R\bexitCode\x12\x14\n" +
+	"\x05error\x18\t \x01(\tR\x05error\"\xd8\x02\n" +
+	"\x15CreateScheduleRequest\x12\x12\n" +
+	"\x04cron\x18\x01 \x01(\tR\x04cron\x12,\n" +
+	"\x06policy\x18\x02 \x01(\v2\x14.cleanroom.v1.PolicyR\x06policy\x12\x18\n" +
+	"\acommand\x18\x03 \x03(\tR\acommand\x12\x18\n" +
+	"\abackend\x18\x04 \x01(\tR\abackend\x12G\n" +
+	"\x06labels\x18\x05 \x03(\v2/.cleanroom.v1.CreateScheduleRequest.LabelsEntryR\x06labels\x12'\n" +
+	"\x0ftimeout_seconds\x18\x06 \x01(\x03R\x0etimeoutSeconds\x12\x1c\n" +
+	"\tnamespace\x18\a \x01(\tR\tnamespace\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"L\n" +
+	"\x16CreateScheduleResponse\x122\n" +
+	"\bschedule\x18\x01 \x01(\v2\x16.cleanroom.v1.ScheduleR\bschedule\"[\n" +
+	"\x14ListSchedulesRequest\x12\x1c\n" +
+	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12%\n" +
+	"\x0eall_namespaces\x18\x02 \x01(\bR\rallNamespaces\"M\n" +
+	"\x15ListSchedulesResponse\x124\n" +
+	"\tschedules\x18\x01 \x03(\v2\x16.cleanroom.v1.ScheduleR\tschedules\"R\n" +
+	"\x12GetScheduleRequest\x12\x1f\n" +
+	"\vschedule_id\x18\x01 \x01(\tR\n" +
+	"scheduleId\x12\x1b\n" +
+	"\trun_limit\x18\x02 \x01(\x05R\brunLimit\"x\n" +
+	"\x13GetScheduleResponse\x122\n" +
+	"\bschedule\x18\x01 \x01(\v2\x16.cleanroom.v1.ScheduleR\bschedule\x12-\n" +
+	"\x04runs\x18\x02 \x03(\v2\x19.cleanroom.v1.ScheduleRunR\x04runs\"8\n" +
+	"\x15DeleteScheduleRequest\x12\x1f\n" +
+	"\vschedule_id\x18\x01 \x01(\tR\n" +
+	"scheduleId\"\x18\n" +
+	"\x16DeleteScheduleResponse*\xd9\x01\n" +
 	"\rSandboxStatus\x12\x1e\n" +
 	"\x1aSANDBOX_STATUS_UNSPECIFIED\x10\x00\x12\x1f\n" +
 	"\x1bSANDBOX_STATUS_PROVISIONING\x10\x01\x12\x18\n" +
 	"\x14SANDBOX_STATUS_READY\x10\x02\x12\x1b\n" +
 	"\x17SANDBOX_STATUS_STOPPING\x10\x03\x12\x1a\n" +
 	"\x16SANDBOX_STATUS_STOPPED\x10\x04\x12\x19\n" +
-	"\x15SANDBOX_STATUS_FAILED\x10\x05*\xea\x01\n" +
+	"\x15SANDBOX_STATUS_FAILED\x10\x05\x12\x19\n" +
+	"\x15SANDBOX_STATUS_PAUSED\x10\x06*\xc9\x01\n" +
+	"\x16ExecutionFailureReason\x12(\n" +
+	"$EXECUTION_FAILURE_REASON_UNSPECIFIED\x10\x00\x12&\n" +
+	"\"EXECUTION_FAILURE_REASON_GUEST_OOM\x10\x01\x12/\n" +
+	"+EXECUTION_FAILURE_REASON_GUEST_KERNEL_PANIC\x10\x02\x12,\n" +
+	"(EXECUTION_FAILURE_REASON_APPROVAL_DENIED\x10\x03*\xd1\x02\n" +
 	"\x0fExecutionStatus\x12 \n" +
 	"\x1cEXECUTION_STATUS_UNSPECIFIED\x10\x00\x12\x1b\n" +
 	"\x17EXECUTION_STATUS_QUEUED\x10\x01\x12\x1c\n" +
@@ -2306,25 +6997,54 @@ const file_proto_cleanroom_v1_control_proto_rawDesc = "" +
 	"\x1aEXECUTION_STATUS_SUCCEEDED\x10\x03\x12\x1b\n" +
 	"\x17EXECUTION_STATUS_FAILED\x10\x04\x12\x1d\n" +
 	"\x19EXECUTION_STATUS_CANCELED\x10\x05\x12\x1e\n" +
-	"\x1aEXECUTION_STATUS_TIMED_OUT\x10\x06*i\n" +
+	"\x1aEXECUTION_STATUS_TIMED_OUT\x10\x06\x12%\n" +
+	"!EXECUTION_STATUS_PENDING_APPROVAL\x10\a\x12\x1c\n" +
+	"\x18EXECUTION_STATUS_SKIPPED\x10\b\x12 \n" +
+	"\x1cEXECUTION_STATUS_SOFT_FAILED\x10\t*i\n" +
 	"\rExecutionKind\x12\x1e\n" +
 	"\x1aEXECUTION_KIND_UNSPECIFIED\x10\x00\x12\x18\n" +
 	"\x14EXECUTION_KIND_BATCH\x10\x01\x12\x1e\n" +
-	"\x1aEXECUTION_KIND_INTERACTIVE\x10\x022\xc3\x04\n" +
+	"\x1aEXECUTION_KIND_INTERACTIVE\x10\x02*\x8f\x01\n" +
+	"\x16ExecutionChangeCapture\x12(\n" +
+	"$EXECUTION_CHANGE_CAPTURE_UNSPECIFIED\x10\x00\x12%\n" +
+	"!EXECUTION_CHANGE_CAPTURE_MANIFEST\x10\x01\x12$\n" +
+	" EXECUTION_CHANGE_CAPTURE_ARCHIVE\x10\x02*v\n" +
+	"\x11ExecutionLauncher\x12\"\n" +
+	"\x1eEXECUTION_LAUNCHER_UNSPECIFIED\x10\x00\x12\x1d\n" +
+	"\x19EXECUTION_LAUNCHER_DIRECT\x10\x01\x12\x1e\n" +
+	"\x1aEXECUTION_LAUNCHER_SYSTEMD\x10\x022\xfa\b\n" +
 	"\x0eSandboxService\x12X\n" +
-	"\rCreateSandbox\x12\".cleanroom.v1.CreateSandboxRequest\x1a#.cleanroom.v1.CreateSandboxResponse\x12O\n" +
+	"\rCreateSandbox\x12\".cleanroom.v1.CreateSandboxRequest\x1a#.cleanroom.v1.CreateSandboxResponse\x12g\n" +
+	"\x12CreateSandboxGroup\x12'.cleanroom.v1.CreateSandboxGroupRequest\x1a(.cleanroom.v1.CreateSandboxGroupResponse\x12O\n" +
 	"\n" +
 	"GetSandbox\x12\x1f.cleanroom.v1.GetSandboxRequest\x1a .cleanroom.v1.GetSandboxResponse\x12X\n" +
 	"\rListSandboxes\x12\".cleanroom.v1.ListSandboxesRequest\x1a#.cleanroom.v1.ListSandboxesResponse\x12j\n" +
-	"\x13DownloadSandboxFile\x12(.cleanroom.v1.DownloadSandboxFileRequest\x1a).cleanroom.v1.DownloadSandboxFileResponse\x12a\n" +
+	"\x13DownloadSandboxFile\x12(.cleanroom.v1.DownloadSandboxFileRequest\x1a).cleanroom.v1.DownloadSandboxFileResponse\x12X\n" +
+	"\rCommitSandbox\x12\".cleanroom.v1.CommitSandboxRequest\x1a#.cleanroom.v1.CommitSandboxResponse\x12U\n" +
+	"\fCloneSandbox\x12!.cleanroom.v1.CloneSandboxRequest\x1a\".cleanroom.v1.CloneSandboxResponse\x12j\n" +
+	"\x13UpgradeSandboxAgent\x12(.cleanroom.v1.UpgradeSandboxAgentRequest\x1a).cleanroom.v1.UpgradeSandboxAgentResponse\x12U\n" +
+	"\fPauseSandbox\x12!.cleanroom.v1.PauseSandboxRequest\x1a\".cleanroom.v1.PauseSandboxResponse\x12X\n" +
+	"\rResumeSandbox\x12\".cleanroom.v1.ResumeSandboxRequest\x1a#.cleanroom.v1.ResumeSandboxResponse\x12a\n" +
 	"\x10TerminateSandbox\x12%.cleanroom.v1.TerminateSandboxRequest\x1a&.cleanroom.v1.TerminateSandboxResponse\x12]\n" +
-	"\x13StreamSandboxEvents\x12(.cleanroom.v1.StreamSandboxEventsRequest\x1a\x1a.cleanroom.v1.SandboxEvent0\x012\x83\x04\n" +
+	"\x13StreamSandboxEvents\x12(.cleanroom.v1.StreamSandboxEventsRequest\x1a\x1a.cleanroom.v1.SandboxEvent0\x012\x9c\b\n" +
 	"\x10ExecutionService\x12^\n" +
 	"\x0fCreateExecution\x12$.cleanroom.v1.CreateExecutionRequest\x1a%.cleanroom.v1.CreateExecutionResponse\x12y\n" +
 	"\x18OpenInteractiveExecution\x12-.cleanroom.v1.OpenInteractiveExecutionRequest\x1a..cleanroom.v1.OpenInteractiveExecutionResponse\x12U\n" +
 	"\fGetExecution\x12!.cleanroom.v1.GetExecutionRequest\x1a\".cleanroom.v1.GetExecutionResponse\x12^\n" +
-	"\x0fCancelExecution\x12$.cleanroom.v1.CancelExecutionRequest\x1a%.cleanroom.v1.CancelExecutionResponse\x12]\n" +
-	"\x0fStreamExecution\x12$.cleanroom.v1.StreamExecutionRequest\x1a\".cleanroom.v1.ExecutionStreamEvent0\x01BFZDgithub.com/buildkite/cleanroom/internal/gen/cleanroom/v1;cleanroomv1b\x06proto3"
+	"\x0fCancelExecution\x12$.cleanroom.v1.CancelExecutionRequest\x1a%.cleanroom.v1.CancelExecutionResponse\x12j\n" +
+	"\x13WriteExecutionStdin\x12(.cleanroom.v1.WriteExecutionStdinRequest\x1a).cleanroom.v1.WriteExecutionStdinResponse\x12]\n" +
+	"\x0fStreamExecution\x12$.cleanroom.v1.StreamExecutionRequest\x1a\".cleanroom.v1.ExecutionStreamEvent0\x01\x12m\n" +
+	"\x14ListPendingApprovals\x12).cleanroom.v1.ListPendingApprovalsRequest\x1a*.cleanroom.v1.ListPendingApprovalsResponse\x12y\n" +
+	"\x18ResolveExecutionApproval\x12-.cleanroom.v1.ResolveExecutionApprovalRequest\x1a..cleanroom.v1.ResolveExecutionApprovalResponse\x12d\n" +
+	"\x11AnnotateExecution\x12&.cleanroom.v1.AnnotateExecutionRequest\x1a'.cleanroom.v1.AnnotateExecutionResponse\x12[\n" +
+	"\x0eListExecutions\x12#.cleanroom.v1.ListExecutionsRequest\x1a$.cleanroom.v1.ListExecutionsResponse2\x9c\x04\n" +
+	"\rServerService\x12X\n" +
+	"\rGetServerInfo\x12\".cleanroom.v1.GetServerInfoRequest\x1a#.cleanroom.v1.GetServerInfoResponse\x12I\n" +
+	"\bGetUsage\x12\x1d.cleanroom.v1.GetUsageRequest\x1a\x1e.cleanroom.v1.GetUsageResponse\x12[\n" +
+	"\x0eCreateSchedule\x12#.cleanroom.v1.CreateScheduleRequest\x1a$.cleanroom.v1.CreateScheduleResponse\x12X\n" +
+	"\rListSchedules\x12\".cleanroom.v1.ListSchedulesRequest\x1a#.cleanroom.v1.ListSchedulesResponse\x12R\n" +
+	"\vGetSchedule\x12 .cleanroom.v1.GetScheduleRequest\x1a!.cleanroom.v1.GetScheduleResponse\x12[\n" +
+	"\x0eDeleteSchedule\x12#.cleanroom.v1.DeleteScheduleRequest\x1a$.cleanroom.v1.DeleteScheduleResponseBFZDgithub.com/buildkite/cleanroom/internal/gen/cleanroom/v1;cleanroomv1b\x06proto3"
 
 var (
 	file_proto_cleanroom_v1_control_proto_rawDescOnce sync.Once
@@ -2338,100 +7058,274 @@ func file_proto_cleanroom_v1_control_proto_rawDescGZIP() []byte {
 	return file_proto_cleanroom_v1_control_proto_rawDescData
 }
 
-var file_proto_cleanroom_v1_control_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
-var file_proto_cleanroom_v1_control_proto_msgTypes = make([]protoimpl.MessageInfo, 31)
+var file_proto_cleanroom_v1_control_proto_enumTypes = make([]protoimpl.EnumInfo, 6)
+var file_proto_cleanroom_v1_control_proto_msgTypes = make([]protoimpl.MessageInfo, 99)
 var file_proto_cleanroom_v1_control_proto_goTypes = []any{
 	(SandboxStatus)(0),                       // 0: cleanroom.v1.SandboxStatus
-	(ExecutionStatus)(0),                     // 1: cleanroom.v1.ExecutionStatus
-	(ExecutionKind)(0),                       // 2: cleanroom.v1.ExecutionKind
-	(*Sandbox)(nil),                          // 3: cleanroom.v1.Sandbox
-	(*PolicyAllowRule)(nil),                  // 4: cleanroom.v1.PolicyAllowRule
-	(*PolicyDockerService)(nil),              // 5: cleanroom.v1.PolicyDockerService
-	(*PolicyServices)(nil),                   // 6: cleanroom.v1.PolicyServices
-	(*Policy)(nil),                           // 7: cleanroom.v1.Policy
-	(*SandboxOptions)(nil),                   // 8: cleanroom.v1.SandboxOptions
-	(*CreateSandboxRequest)(nil),             // 9: cleanroom.v1.CreateSandboxRequest
-	(*CreateSandboxResponse)(nil),            // 10: cleanroom.v1.CreateSandboxResponse
-	(*GetSandboxRequest)(nil),                // 11: cleanroom.v1.GetSandboxRequest
-	(*GetSandboxResponse)(nil),               // 12: cleanroom.v1.GetSandboxResponse
-	(*ListSandboxesRequest)(nil),             // 13: cleanroom.v1.ListSandboxesRequest
-	(*ListSandboxesResponse)(nil),            // 14: cleanroom.v1.ListSandboxesResponse
-	(*DownloadSandboxFileRequest)(nil),       // 15: cleanroom.v1.DownloadSandboxFileRequest
-	(*DownloadSandboxFileResponse)(nil),      // 16: cleanroom.v1.DownloadSandboxFileResponse
-	(*TerminateSandboxRequest)(nil),          // 17: cleanroom.v1.TerminateSandboxRequest
-	(*TerminateSandboxResponse)(nil),         // 18: cleanroom.v1.TerminateSandboxResponse
-	(*StreamSandboxEventsRequest)(nil),       // 19: cleanroom.v1.StreamSandboxEventsRequest
-	(*SandboxEvent)(nil),                     // 20: cleanroom.v1.SandboxEvent
-	(*Execution)(nil),                        // 21: cleanroom.v1.Execution
-	(*ExecutionOptions)(nil),                 // 22: cleanroom.v1.ExecutionOptions
-	(*CreateExecutionRequest)(nil),           // 23: cleanroom.v1.CreateExecutionRequest
-	(*CreateExecutionResponse)(nil),          // 24: cleanroom.v1.CreateExecutionResponse
-	(*OpenInteractiveExecutionRequest)(nil),  // 25: cleanroom.v1.OpenInteractiveExecutionRequest
-	(*OpenInteractiveExecutionResponse)(nil), // 26: cleanroom.v1.OpenInteractiveExecutionResponse
-	(*GetExecutionRequest)(nil),              // 27: cleanroom.v1.GetExecutionRequest
-	(*GetExecutionResponse)(nil),             // 28: cleanroom.v1.GetExecutionResponse
-	(*CancelExecutionRequest)(nil),           // 29: cleanroom.v1.CancelExecutionRequest
-	(*CancelExecutionResponse)(nil),          // 30: cleanroom.v1.CancelExecutionResponse
-	(*StreamExecutionRequest)(nil),           // 31: cleanroom.v1.StreamExecutionRequest
-	(*ExecutionExit)(nil),                    // 32: cleanroom.v1.ExecutionExit
-	(*ExecutionStreamEvent)(nil),             // 33: cleanroom.v1.ExecutionStreamEvent
-	(*timestamppb.Timestamp)(nil),            // 34: google.protobuf.Timestamp
+	(ExecutionFailureReason)(0),              // 1: cleanroom.v1.ExecutionFailureReason
+	(ExecutionStatus)(0),                     // 2: cleanroom.v1.ExecutionStatus
+	(ExecutionKind)(0),                       // 3: cleanroom.v1.ExecutionKind
+	(ExecutionChangeCapture)(0),              // 4: cleanroom.v1.ExecutionChangeCapture
+	(ExecutionLauncher)(0),                   // 5: cleanroom.v1.ExecutionLauncher
+	(*Sandbox)(nil),                          // 6: cleanroom.v1.Sandbox
+	(*HostResolution)(nil),                   // 7: cleanroom.v1.HostResolution
+	(*SandboxCheckout)(nil),                  // 8: cleanroom.v1.SandboxCheckout
+	(*PolicyAllowRule)(nil),                  // 9: cleanroom.v1.PolicyAllowRule
+	(*PolicyPortRange)(nil),                  // 10: cleanroom.v1.PolicyPortRange
+	(*PolicyDockerService)(nil),              // 11: cleanroom.v1.PolicyDockerService
+	(*PolicyOCIRegistryService)(nil),         // 12: cleanroom.v1.PolicyOCIRegistryService
+	(*PolicyPackagesService)(nil),            // 13: cleanroom.v1.PolicyPackagesService
+	(*PolicyServices)(nil),                   // 14: cleanroom.v1.PolicyServices
+	(*PolicyResources)(nil),                  // 15: cleanroom.v1.PolicyResources
+	(*Policy)(nil),                           // 16: cleanroom.v1.Policy
+	(*PolicyExitCodeRule)(nil),               // 17: cleanroom.v1.PolicyExitCodeRule
+	(*PolicyReadOnlyRootFS)(nil),             // 18: cleanroom.v1.PolicyReadOnlyRootFS
+	(*PolicyWritablePath)(nil),               // 19: cleanroom.v1.PolicyWritablePath
+	(*SandboxOptions)(nil),                   // 20: cleanroom.v1.SandboxOptions
+	(*CreateSandboxRequest)(nil),             // 21: cleanroom.v1.CreateSandboxRequest
+	(*CreateSandboxResponse)(nil),            // 22: cleanroom.v1.CreateSandboxResponse
+	(*SandboxGroupMember)(nil),               // 23: cleanroom.v1.SandboxGroupMember
+	(*CreateSandboxGroupRequest)(nil),        // 24: cleanroom.v1.CreateSandboxGroupRequest
+	(*CreateSandboxGroupResponse)(nil),       // 25: cleanroom.v1.CreateSandboxGroupResponse
+	(*GetSandboxRequest)(nil),                // 26: cleanroom.v1.GetSandboxRequest
+	(*GetSandboxResponse)(nil),               // 27: cleanroom.v1.GetSandboxResponse
+	(*ListSandboxesRequest)(nil),             // 28: cleanroom.v1.ListSandboxesRequest
+	(*ListSandboxesResponse)(nil),            // 29: cleanroom.v1.ListSandboxesResponse
+	(*DownloadSandboxFileRequest)(nil),       // 30: cleanroom.v1.DownloadSandboxFileRequest
+	(*FileDeltaBase)(nil),                    // 31: cleanroom.v1.FileDeltaBase
+	(*DownloadSandboxFileResponse)(nil),      // 32: cleanroom.v1.DownloadSandboxFileResponse
+	(*CommitSandboxRequest)(nil),             // 33: cleanroom.v1.CommitSandboxRequest
+	(*CommitSandboxResponse)(nil),            // 34: cleanroom.v1.CommitSandboxResponse
+	(*CloneSandboxRequest)(nil),              // 35: cleanroom.v1.CloneSandboxRequest
+	(*CloneSandboxResponse)(nil),             // 36: cleanroom.v1.CloneSandboxResponse
+	(*UpgradeSandboxAgentRequest)(nil),       // 37: cleanroom.v1.UpgradeSandboxAgentRequest
+	(*UpgradeSandboxAgentResponse)(nil),      // 38: cleanroom.v1.UpgradeSandboxAgentResponse
+	(*PauseSandboxRequest)(nil),              // 39: cleanroom.v1.PauseSandboxRequest
+	(*PauseSandboxResponse)(nil),             // 40: cleanroom.v1.PauseSandboxResponse
+	(*ResumeSandboxRequest)(nil),             // 41: cleanroom.v1.ResumeSandboxRequest
+	(*ResumeSandboxResponse)(nil),            // 42: cleanroom.v1.ResumeSandboxResponse
+	(*TerminateSandboxRequest)(nil),          // 43: cleanroom.v1.TerminateSandboxRequest
+	(*TerminateSandboxResponse)(nil),         // 44: cleanroom.v1.TerminateSandboxResponse
+	(*StreamSandboxEventsRequest)(nil),       // 45: cleanroom.v1.StreamSandboxEventsRequest
+	(*SandboxEvent)(nil),                     // 46: cleanroom.v1.SandboxEvent
+	(*Execution)(nil),                        // 47: cleanroom.v1.Execution
+	(*ExecutionApproval)(nil),                // 48: cleanroom.v1.ExecutionApproval
+	(*ExecutionArtifact)(nil),                // 49: cleanroom.v1.ExecutionArtifact
+	(*ExecutionOptions)(nil),                 // 50: cleanroom.v1.ExecutionOptions
+	(*ExecutionResultParsers)(nil),           // 51: cleanroom.v1.ExecutionResultParsers
+	(*ExecutionTestResults)(nil),             // 52: cleanroom.v1.ExecutionTestResults
+	(*ExecutionResourceLimits)(nil),          // 53: cleanroom.v1.ExecutionResourceLimits
+	(*CreateExecutionRequest)(nil),           // 54: cleanroom.v1.CreateExecutionRequest
+	(*CreateExecutionResponse)(nil),          // 55: cleanroom.v1.CreateExecutionResponse
+	(*OpenInteractiveExecutionRequest)(nil),  // 56: cleanroom.v1.OpenInteractiveExecutionRequest
+	(*OpenInteractiveExecutionResponse)(nil), // 57: cleanroom.v1.OpenInteractiveExecutionResponse
+	(*GetExecutionRequest)(nil),              // 58: cleanroom.v1.GetExecutionRequest
+	(*GetExecutionResponse)(nil),             // 59: cleanroom.v1.GetExecutionResponse
+	(*CancelExecutionRequest)(nil),           // 60: cleanroom.v1.CancelExecutionRequest
+	(*CancelExecutionResponse)(nil),          // 61: cleanroom.v1.CancelExecutionResponse
+	(*ListPendingApprovalsRequest)(nil),      // 62: cleanroom.v1.ListPendingApprovalsRequest
+	(*PendingApproval)(nil),                  // 63: cleanroom.v1.PendingApproval
+	(*ListPendingApprovalsResponse)(nil),     // 64: cleanroom.v1.ListPendingApprovalsResponse
+	(*ResolveExecutionApprovalRequest)(nil),  // 65: cleanroom.v1.ResolveExecutionApprovalRequest
+	(*ResolveExecutionApprovalResponse)(nil), // 66: cleanroom.v1.ResolveExecutionApprovalResponse
+	(*AnnotateExecutionRequest)(nil),         // 67: cleanroom.v1.AnnotateExecutionRequest
+	(*AnnotateExecutionResponse)(nil),        // 68: cleanroom.v1.AnnotateExecutionResponse
+	(*ListExecutionsRequest)(nil),            // 69: cleanroom.v1.ListExecutionsRequest
+	(*ListExecutionsResponse)(nil),           // 70: cleanroom.v1.ListExecutionsResponse
+	(*WriteExecutionStdinRequest)(nil),       // 71: cleanroom.v1.WriteExecutionStdinRequest
+	(*WriteExecutionStdinResponse)(nil),      // 72: cleanroom.v1.WriteExecutionStdinResponse
+	(*StreamExecutionRequest)(nil),           // 73: cleanroom.v1.StreamExecutionRequest
+	(*ExecutionExit)(nil),                    // 74: cleanroom.v1.ExecutionExit
+	(*ExecutionTimings)(nil),                 // 75: cleanroom.v1.ExecutionTimings
+	(*ExecutionExitMetadata)(nil),            // 76: cleanroom.v1.ExecutionExitMetadata
+	(*ExecutionStreamEvent)(nil),             // 77: cleanroom.v1.ExecutionStreamEvent
+	(*ExecutionEnvironment)(nil),             // 78: cleanroom.v1.ExecutionEnvironment
+	(*GetServerInfoRequest)(nil),             // 79: cleanroom.v1.GetServerInfoRequest
+	(*GetServerInfoResponse)(nil),            // 80: cleanroom.v1.GetServerInfoResponse
+	(*GetUsageRequest)(nil),                  // 81: cleanroom.v1.GetUsageRequest
+	(*GetUsageResponse)(nil),                 // 82: cleanroom.v1.GetUsageResponse
+	(*UsageRow)(nil),                         // 83: cleanroom.v1.UsageRow
+	(*Schedule)(nil),                         // 84: cleanroom.v1.Schedule
+	(*ScheduleRun)(nil),                      // 85: cleanroom.v1.ScheduleRun
+	(*CreateScheduleRequest)(nil),            // 86: cleanroom.v1.CreateScheduleRequest
+	(*CreateScheduleResponse)(nil),           // 87: cleanroom.v1.CreateScheduleResponse
+	(*ListSchedulesRequest)(nil),             // 88: cleanroom.v1.ListSchedulesRequest
+	(*ListSchedulesResponse)(nil),            // 89: cleanroom.v1.ListSchedulesResponse
+	(*GetScheduleRequest)(nil),               // 90: cleanroom.v1.GetScheduleRequest
+	(*GetScheduleResponse)(nil),              // 91: cleanroom.v1.GetScheduleResponse
+	(*DeleteScheduleRequest)(nil),            // 92: cleanroom.v1.DeleteScheduleRequest
+	(*DeleteScheduleResponse)(nil),           // 93: cleanroom.v1.DeleteScheduleResponse
+	nil,                                      // 94: cleanroom.v1.Sandbox.LabelsEntry
+	nil,                                      // 95: cleanroom.v1.Policy.VariablesEntry
+	nil,                                      // 96: cleanroom.v1.PolicyExitCodeRule.AnnotationsEntry
+	nil,                                      // 97: cleanroom.v1.CreateSandboxRequest.LabelsEntry
+	nil,                                      // 98: cleanroom.v1.CloneSandboxRequest.LabelsEntry
+	nil,                                      // 99: cleanroom.v1.Execution.AnnotationsEntry
+	nil,                                      // 100: cleanroom.v1.CreateExecutionRequest.AnnotationsEntry
+	nil,                                      // 101: cleanroom.v1.AnnotateExecutionRequest.AnnotationsEntry
+	nil,                                      // 102: cleanroom.v1.ListExecutionsRequest.AnnotationsEntry
+	nil,                                      // 103: cleanroom.v1.Schedule.LabelsEntry
+	nil,                                      // 104: cleanroom.v1.CreateScheduleRequest.LabelsEntry
+	(*timestamppb.Timestamp)(nil),            // 105: google.protobuf.Timestamp
 }
 var file_proto_cleanroom_v1_control_proto_depIdxs = []int32{
-	0,  // 0: cleanroom.v1.Sandbox.status:type_name -> cleanroom.v1.SandboxStatus
-	34, // 1: cleanroom.v1.Sandbox.created_at:type_name -> google.protobuf.Timestamp
-	34, // 2: cleanroom.v1.Sandbox.updated_at:type_name -> google.protobuf.Timestamp
-	5,  // 3: cleanroom.v1.PolicyServices.docker:type_name -> cleanroom.v1.PolicyDockerService
-	4,  // 4: cleanroom.v1.Policy.allow:type_name -> cleanroom.v1.PolicyAllowRule
-	6,  // 5: cleanroom.v1.Policy.services:type_name -> cleanroom.v1.PolicyServices
-	8,  // 6: cleanroom.v1.CreateSandboxRequest.options:type_name -> cleanroom.v1.SandboxOptions
-	7,  // 7: cleanroom.v1.CreateSandboxRequest.policy:type_name -> cleanroom.v1.Policy
-	3,  // 8: cleanroom.v1.CreateSandboxResponse.sandbox:type_name -> cleanroom.v1.Sandbox
-	3,  // 9: cleanroom.v1.GetSandboxResponse.sandbox:type_name -> cleanroom.v1.Sandbox
-	3,  // 10: cleanroom.v1.ListSandboxesResponse.sandboxes:type_name -> cleanroom.v1.Sandbox
-	0,  // 11: cleanroom.v1.SandboxEvent.status:type_name -> cleanroom.v1.SandboxStatus
-	34, // 12: cleanroom.v1.SandboxEvent.occurred_at:type_name -> google.protobuf.Timestamp
-	1,  // 13: cleanroom.v1.Execution.status:type_name -> cleanroom.v1.ExecutionStatus
-	34, // 14: cleanroom.v1.Execution.started_at:type_name -> google.protobuf.Timestamp
-	34, // 15: cleanroom.v1.Execution.finished_at:type_name -> google.protobuf.Timestamp
-	2,  // 16: cleanroom.v1.Execution.kind:type_name -> cleanroom.v1.ExecutionKind
-	22, // 17: cleanroom.v1.CreateExecutionRequest.options:type_name -> cleanroom.v1.ExecutionOptions
-	2,  // 18: cleanroom.v1.CreateExecutionRequest.kind:type_name -> cleanroom.v1.ExecutionKind
-	21, // 19: cleanroom.v1.CreateExecutionResponse.execution:type_name -> cleanroom.v1.Execution
-	34, // 20: cleanroom.v1.OpenInteractiveExecutionResponse.expires_at:type_name -> google.protobuf.Timestamp
-	21, // 21: cleanroom.v1.GetExecutionResponse.execution:type_name -> cleanroom.v1.Execution
-	1,  // 22: cleanroom.v1.CancelExecutionResponse.status:type_name -> cleanroom.v1.ExecutionStatus
-	1,  // 23: cleanroom.v1.ExecutionExit.status:type_name -> cleanroom.v1.ExecutionStatus
-	1,  // 24: cleanroom.v1.ExecutionStreamEvent.status:type_name -> cleanroom.v1.ExecutionStatus
-	32, // 25: cleanroom.v1.ExecutionStreamEvent.exit:type_name -> cleanroom.v1.ExecutionExit
-	34, // 26: cleanroom.v1.ExecutionStreamEvent.occurred_at:type_name -> google.protobuf.Timestamp
-	9,  // 27: cleanroom.v1.SandboxService.CreateSandbox:input_type -> cleanroom.v1.CreateSandboxRequest
-	11, // 28: cleanroom.v1.SandboxService.GetSandbox:input_type -> cleanroom.v1.GetSandboxRequest
-	13, // 29: cleanroom.v1.SandboxService.ListSandboxes:input_type -> cleanroom.v1.ListSandboxesRequest
-	15, // 30: cleanroom.v1.SandboxService.DownloadSandboxFile:input_type -> cleanroom.v1.DownloadSandboxFileRequest
-	17, // 31: cleanroom.v1.SandboxService.TerminateSandbox:input_type -> cleanroom.v1.TerminateSandboxRequest
-	19, // 32: cleanroom.v1.SandboxService.StreamSandboxEvents:input_type -> cleanroom.v1.StreamSandboxEventsRequest
-	23, // 33: cleanroom.v1.ExecutionService.CreateExecution:input_type -> cleanroom.v1.CreateExecutionRequest
-	25, // 34: cleanroom.v1.ExecutionService.OpenInteractiveExecution:input_type -> cleanroom.v1.OpenInteractiveExecutionRequest
-	27, // 35: cleanroom.v1.ExecutionService.GetExecution:input_type -> cleanroom.v1.GetExecutionRequest
-	29, // 36: cleanroom.v1.ExecutionService.CancelExecution:input_type -> cleanroom.v1.CancelExecutionRequest
-	31, // 37: cleanroom.v1.ExecutionService.StreamExecution:input_type -> cleanroom.v1.StreamExecutionRequest
-	10, // 38: cleanroom.v1.SandboxService.CreateSandbox:output_type -> cleanroom.v1.CreateSandboxResponse
-	12, // 39: cleanroom.v1.SandboxService.GetSandbox:output_type -> cleanroom.v1.GetSandboxResponse
-	14, // 40: cleanroom.v1.SandboxService.ListSandboxes:output_type -> cleanroom.v1.ListSandboxesResponse
-	16, // 41: cleanroom.v1.SandboxService.DownloadSandboxFile:output_type -> cleanroom.v1.DownloadSandboxFileResponse
-	18, // 42: cleanroom.v1.SandboxService.TerminateSandbox:output_type -> cleanroom.v1.TerminateSandboxResponse
-	20, // 43: cleanroom.v1.SandboxService.StreamSandboxEvents:output_type -> cleanroom.v1.SandboxEvent
-	24, // 44: cleanroom.v1.ExecutionService.CreateExecution:output_type -> cleanroom.v1.CreateExecutionResponse
-	26, // 45: cleanroom.v1.ExecutionService.OpenInteractiveExecution:output_type -> cleanroom.v1.OpenInteractiveExecutionResponse
-	28, // 46: cleanroom.v1.ExecutionService.GetExecution:output_type -> cleanroom.v1.GetExecutionResponse
-	30, // 47: cleanroom.v1.ExecutionService.CancelExecution:output_type -> cleanroom.v1.CancelExecutionResponse
-	33, // 48: cleanroom.v1.ExecutionService.StreamExecution:output_type -> cleanroom.v1.ExecutionStreamEvent
-	38, // [38:49] is the sub-list for method output_type
-	27, // [27:38] is the sub-list for method input_type
-	27, // [27:27] is the sub-list for extension type_name
-	27, // [27:27] is the sub-list for extension extendee
-	0,  // [0:27] is the sub-list for field type_name
+	0,   // 0: cleanroom.v1.Sandbox.status:type_name -> cleanroom.v1.SandboxStatus
+	105, // 1: cleanroom.v1.Sandbox.created_at:type_name -> google.protobuf.Timestamp
+	105, // 2: cleanroom.v1.Sandbox.updated_at:type_name -> google.protobuf.Timestamp
+	94,  // 3: cleanroom.v1.Sandbox.labels:type_name -> cleanroom.v1.Sandbox.LabelsEntry
+	8,   // 4: cleanroom.v1.Sandbox.checkout:type_name -> cleanroom.v1.SandboxCheckout
+	7,   // 5: cleanroom.v1.Sandbox.resolutions:type_name -> cleanroom.v1.HostResolution
+	10,  // 6: cleanroom.v1.PolicyAllowRule.port_ranges:type_name -> cleanroom.v1.PolicyPortRange
+	11,  // 7: cleanroom.v1.PolicyServices.docker:type_name -> cleanroom.v1.PolicyDockerService
+	12,  // 8: cleanroom.v1.PolicyServices.oci_registry:type_name -> cleanroom.v1.PolicyOCIRegistryService
+	13,  // 9: cleanroom.v1.PolicyServices.packages:type_name -> cleanroom.v1.PolicyPackagesService
+	9,   // 10: cleanroom.v1.Policy.allow:type_name -> cleanroom.v1.PolicyAllowRule
+	14,  // 11: cleanroom.v1.Policy.services:type_name -> cleanroom.v1.PolicyServices
+	15,  // 12: cleanroom.v1.Policy.resources:type_name -> cleanroom.v1.PolicyResources
+	18,  // 13: cleanroom.v1.Policy.read_only_rootfs:type_name -> cleanroom.v1.PolicyReadOnlyRootFS
+	95,  // 14: cleanroom.v1.Policy.variables:type_name -> cleanroom.v1.Policy.VariablesEntry
+	17,  // 15: cleanroom.v1.Policy.exit_codes:type_name -> cleanroom.v1.PolicyExitCodeRule
+	96,  // 16: cleanroom.v1.PolicyExitCodeRule.annotations:type_name -> cleanroom.v1.PolicyExitCodeRule.AnnotationsEntry
+	19,  // 17: cleanroom.v1.PolicyReadOnlyRootFS.writable:type_name -> cleanroom.v1.PolicyWritablePath
+	20,  // 18: cleanroom.v1.CreateSandboxRequest.options:type_name -> cleanroom.v1.SandboxOptions
+	16,  // 19: cleanroom.v1.CreateSandboxRequest.policy:type_name -> cleanroom.v1.Policy
+	97,  // 20: cleanroom.v1.CreateSandboxRequest.labels:type_name -> cleanroom.v1.CreateSandboxRequest.LabelsEntry
+	8,   // 21: cleanroom.v1.CreateSandboxRequest.checkout:type_name -> cleanroom.v1.SandboxCheckout
+	7,   // 22: cleanroom.v1.CreateSandboxRequest.pinned_resolutions:type_name -> cleanroom.v1.HostResolution
+	6,   // 23: cleanroom.v1.CreateSandboxResponse.sandbox:type_name -> cleanroom.v1.Sandbox
+	21,  // 24: cleanroom.v1.SandboxGroupMember.sandbox:type_name -> cleanroom.v1.CreateSandboxRequest
+	23,  // 25: cleanroom.v1.CreateSandboxGroupRequest.members:type_name -> cleanroom.v1.SandboxGroupMember
+	22,  // 26: cleanroom.v1.CreateSandboxGroupResponse.sandboxes:type_name -> cleanroom.v1.CreateSandboxResponse
+	6,   // 27: cleanroom.v1.GetSandboxResponse.sandbox:type_name -> cleanroom.v1.Sandbox
+	6,   // 28: cleanroom.v1.ListSandboxesResponse.sandboxes:type_name -> cleanroom.v1.Sandbox
+	31,  // 29: cleanroom.v1.DownloadSandboxFileRequest.delta_base:type_name -> cleanroom.v1.FileDeltaBase
+	98,  // 30: cleanroom.v1.CloneSandboxRequest.labels:type_name -> cleanroom.v1.CloneSandboxRequest.LabelsEntry
+	6,   // 31: cleanroom.v1.CloneSandboxResponse.sandboxes:type_name -> cleanroom.v1.Sandbox
+	6,   // 32: cleanroom.v1.UpgradeSandboxAgentResponse.sandbox:type_name -> cleanroom.v1.Sandbox
+	6,   // 33: cleanroom.v1.PauseSandboxResponse.sandbox:type_name -> cleanroom.v1.Sandbox
+	6,   // 34: cleanroom.v1.ResumeSandboxResponse.sandbox:type_name -> cleanroom.v1.Sandbox
+	0,   // 35: cleanroom.v1.SandboxEvent.status:type_name -> cleanroom.v1.SandboxStatus
+	105, // 36: cleanroom.v1.SandboxEvent.occurred_at:type_name -> google.protobuf.Timestamp
+	2,   // 37: cleanroom.v1.Execution.status:type_name -> cleanroom.v1.ExecutionStatus
+	105, // 38: cleanroom.v1.Execution.started_at:type_name -> google.protobuf.Timestamp
+	105, // 39: cleanroom.v1.Execution.finished_at:type_name -> google.protobuf.Timestamp
+	3,   // 40: cleanroom.v1.Execution.kind:type_name -> cleanroom.v1.ExecutionKind
+	76,  // 41: cleanroom.v1.Execution.exit_metadata:type_name -> cleanroom.v1.ExecutionExitMetadata
+	49,  // 42: cleanroom.v1.Execution.artifacts:type_name -> cleanroom.v1.ExecutionArtifact
+	1,   // 43: cleanroom.v1.Execution.failure_reason:type_name -> cleanroom.v1.ExecutionFailureReason
+	48,  // 44: cleanroom.v1.Execution.approval:type_name -> cleanroom.v1.ExecutionApproval
+	75,  // 45: cleanroom.v1.Execution.timings:type_name -> cleanroom.v1.ExecutionTimings
+	99,  // 46: cleanroom.v1.Execution.annotations:type_name -> cleanroom.v1.Execution.AnnotationsEntry
+	52,  // 47: cleanroom.v1.Execution.test_results:type_name -> cleanroom.v1.ExecutionTestResults
+	105, // 48: cleanroom.v1.ExecutionApproval.requested_at:type_name -> google.protobuf.Timestamp
+	105, // 49: cleanroom.v1.ExecutionApproval.decided_at:type_name -> google.protobuf.Timestamp
+	53,  // 50: cleanroom.v1.ExecutionOptions.limits:type_name -> cleanroom.v1.ExecutionResourceLimits
+	5,   // 51: cleanroom.v1.ExecutionOptions.launcher:type_name -> cleanroom.v1.ExecutionLauncher
+	4,   // 52: cleanroom.v1.ExecutionOptions.capture_changes:type_name -> cleanroom.v1.ExecutionChangeCapture
+	51,  // 53: cleanroom.v1.ExecutionOptions.result_parsers:type_name -> cleanroom.v1.ExecutionResultParsers
+	50,  // 54: cleanroom.v1.CreateExecutionRequest.options:type_name -> cleanroom.v1.ExecutionOptions
+	3,   // 55: cleanroom.v1.CreateExecutionRequest.kind:type_name -> cleanroom.v1.ExecutionKind
+	100, // 56: cleanroom.v1.CreateExecutionRequest.annotations:type_name -> cleanroom.v1.CreateExecutionRequest.AnnotationsEntry
+	47,  // 57: cleanroom.v1.CreateExecutionResponse.execution:type_name -> cleanroom.v1.Execution
+	105, // 58: cleanroom.v1.OpenInteractiveExecutionResponse.expires_at:type_name -> google.protobuf.Timestamp
+	47,  // 59: cleanroom.v1.GetExecutionResponse.execution:type_name -> cleanroom.v1.Execution
+	2,   // 60: cleanroom.v1.CancelExecutionResponse.status:type_name -> cleanroom.v1.ExecutionStatus
+	47,  // 61: cleanroom.v1.PendingApproval.execution:type_name -> cleanroom.v1.Execution
+	6,   // 62: cleanroom.v1.PendingApproval.sandbox:type_name -> cleanroom.v1.Sandbox
+	63,  // 63: cleanroom.v1.ListPendingApprovalsResponse.approvals:type_name -> cleanroom.v1.PendingApproval
+	47,  // 64: cleanroom.v1.ResolveExecutionApprovalResponse.execution:type_name -> cleanroom.v1.Execution
+	101, // 65: cleanroom.v1.AnnotateExecutionRequest.annotations:type_name -> cleanroom.v1.AnnotateExecutionRequest.AnnotationsEntry
+	47,  // 66: cleanroom.v1.AnnotateExecutionResponse.execution:type_name -> cleanroom.v1.Execution
+	102, // 67: cleanroom.v1.ListExecutionsRequest.annotations:type_name -> cleanroom.v1.ListExecutionsRequest.AnnotationsEntry
+	47,  // 68: cleanroom.v1.ListExecutionsResponse.executions:type_name -> cleanroom.v1.Execution
+	2,   // 69: cleanroom.v1.ExecutionExit.status:type_name -> cleanroom.v1.ExecutionStatus
+	76,  // 70: cleanroom.v1.ExecutionExit.metadata:type_name -> cleanroom.v1.ExecutionExitMetadata
+	49,  // 71: cleanroom.v1.ExecutionExit.artifacts:type_name -> cleanroom.v1.ExecutionArtifact
+	1,   // 72: cleanroom.v1.ExecutionExit.failure_reason:type_name -> cleanroom.v1.ExecutionFailureReason
+	75,  // 73: cleanroom.v1.ExecutionExit.timings:type_name -> cleanroom.v1.ExecutionTimings
+	52,  // 74: cleanroom.v1.ExecutionExit.test_results:type_name -> cleanroom.v1.ExecutionTestResults
+	2,   // 75: cleanroom.v1.ExecutionStreamEvent.status:type_name -> cleanroom.v1.ExecutionStatus
+	74,  // 76: cleanroom.v1.ExecutionStreamEvent.exit:type_name -> cleanroom.v1.ExecutionExit
+	78,  // 77: cleanroom.v1.ExecutionStreamEvent.environment:type_name -> cleanroom.v1.ExecutionEnvironment
+	105, // 78: cleanroom.v1.ExecutionStreamEvent.occurred_at:type_name -> google.protobuf.Timestamp
+	105, // 79: cleanroom.v1.GetUsageRequest.since:type_name -> google.protobuf.Timestamp
+	105, // 80: cleanroom.v1.GetUsageResponse.since:type_name -> google.protobuf.Timestamp
+	105, // 81: cleanroom.v1.GetUsageResponse.until:type_name -> google.protobuf.Timestamp
+	83,  // 82: cleanroom.v1.GetUsageResponse.rows:type_name -> cleanroom.v1.UsageRow
+	103, // 83: cleanroom.v1.Schedule.labels:type_name -> cleanroom.v1.Schedule.LabelsEntry
+	105, // 84: cleanroom.v1.Schedule.created_at:type_name -> google.protobuf.Timestamp
+	105, // 85: cleanroom.v1.Schedule.next_run_at:type_name -> google.protobuf.Timestamp
+	85,  // 86: cleanroom.v1.Schedule.last_run:type_name -> cleanroom.v1.ScheduleRun
+	105, // 87: cleanroom.v1.ScheduleRun.started_at:type_name -> google.protobuf.Timestamp
+	105, // 88: cleanroom.v1.ScheduleRun.finished_at:type_name -> google.protobuf.Timestamp
+	2,   // 89: cleanroom.v1.ScheduleRun.status:type_name -> cleanroom.v1.ExecutionStatus
+	16,  // 90: cleanroom.v1.CreateScheduleRequest.policy:type_name -> cleanroom.v1.Policy
+	104, // 91: cleanroom.v1.CreateScheduleRequest.labels:type_name -> cleanroom.v1.CreateScheduleRequest.LabelsEntry
+	84,  // 92: cleanroom.v1.CreateScheduleResponse.schedule:type_name -> cleanroom.v1.Schedule
+	84,  // 93: cleanroom.v1.ListSchedulesResponse.schedules:type_name -> cleanroom.v1.Schedule
+	84,  // 94: cleanroom.v1.GetScheduleResponse.schedule:type_name -> cleanroom.v1.Schedule
+	85,  // 95: cleanroom.v1.GetScheduleResponse.runs:type_name -> cleanroom.v1.ScheduleRun
+	21,  // 96: cleanroom.v1.SandboxService.CreateSandbox:input_type -> cleanroom.v1.CreateSandboxRequest
+	24,  // 97: cleanroom.v1.SandboxService.CreateSandboxGroup:input_type -> cleanroom.v1.CreateSandboxGroupRequest
+	26,  // 98: cleanroom.v1.SandboxService.GetSandbox:input_type -> cleanroom.v1.GetSandboxRequest
+	28,  // 99: cleanroom.v1.SandboxService.ListSandboxes:input_type -> cleanroom.v1.ListSandboxesRequest
+	30,  // 100: cleanroom.v1.SandboxService.DownloadSandboxFile:input_type -> cleanroom.v1.DownloadSandboxFileRequest
+	33,  // 101: cleanroom.v1.SandboxService.CommitSandbox:input_type -> cleanroom.v1.CommitSandboxRequest
+	35,  // 102: cleanroom.v1.SandboxService.CloneSandbox:input_type -> cleanroom.v1.CloneSandboxRequest
+	37,  // 103: cleanroom.v1.SandboxService.UpgradeSandboxAgent:input_type -> cleanroom.v1.UpgradeSandboxAgentRequest
+	39,  // 104: cleanroom.v1.SandboxService.PauseSandbox:input_type -> cleanroom.v1.PauseSandboxRequest
+	41,  // 105: cleanroom.v1.SandboxService.ResumeSandbox:input_type -> cleanroom.v1.ResumeSandboxRequest
+	43,  // 106: cleanroom.v1.SandboxService.TerminateSandbox:input_type -> cleanroom.v1.TerminateSandboxRequest
+	45,  // 107: cleanroom.v1.SandboxService.StreamSandboxEvents:input_type -> cleanroom.v1.StreamSandboxEventsRequest
+	54,  // 108: cleanroom.v1.ExecutionService.CreateExecution:input_type -> cleanroom.v1.CreateExecutionRequest
+	56,  // 109: cleanroom.v1.ExecutionService.OpenInteractiveExecution:input_type -> cleanroom.v1.OpenInteractiveExecutionRequest
+	58,  // 110: cleanroom.v1.ExecutionService.GetExecution:input_type -> cleanroom.v1.GetExecutionRequest
+	60,  // 111: cleanroom.v1.ExecutionService.CancelExecution:input_type -> cleanroom.v1.CancelExecutionRequest
+	71,  // 112: cleanroom.v1.ExecutionService.WriteExecutionStdin:input_type -> cleanroom.v1.WriteExecutionStdinRequest
+	73,  // 113: cleanroom.v1.ExecutionService.StreamExecution:input_type -> cleanroom.v1.StreamExecutionRequest
+	62,  // 114: cleanroom.v1.ExecutionService.ListPendingApprovals:input_type -> cleanroom.v1.ListPendingApprovalsRequest
+	65,  // 115: cleanroom.v1.ExecutionService.ResolveExecutionApproval:input_type -> cleanroom.v1.ResolveExecutionApprovalRequest
+	67,  // 116: cleanroom.v1.ExecutionService.AnnotateExecution:input_type -> cleanroom.v1.AnnotateExecutionRequest
+	69,  // 117: cleanroom.v1.ExecutionService.ListExecutions:input_type -> cleanroom.v1.ListExecutionsRequest
+	79,  // 118: cleanroom.v1.ServerService.GetServerInfo:input_type -> cleanroom.v1.GetServerInfoRequest
+	81,  // 119: cleanroom.v1.ServerService.GetUsage:input_type -> cleanroom.v1.GetUsageRequest
+	86,  // 120: cleanroom.v1.ServerService.CreateSchedule:input_type -> cleanroom.v1.CreateScheduleRequest
+	88,  // 121: cleanroom.v1.ServerService.ListSchedules:input_type -> cleanroom.v1.ListSchedulesRequest
+	90,  // 122: cleanroom.v1.ServerService.GetSchedule:input_type -> cleanroom.v1.GetScheduleRequest
+	92,  // 123: cleanroom.v1.ServerService.DeleteSchedule:input_type -> cleanroom.v1.DeleteScheduleRequest
+	22,  // 124: cleanroom.v1.SandboxService.CreateSandbox:output_type -> cleanroom.v1.CreateSandboxResponse
+	25,  // 125: cleanroom.v1.SandboxService.CreateSandboxGroup:output_type -> cleanroom.v1.CreateSandboxGroupResponse
+	27,  // 126: cleanroom.v1.SandboxService.GetSandbox:output_type -> cleanroom.v1.GetSandboxResponse
+	29,  // 127: cleanroom.v1.SandboxService.ListSandboxes:output_type -> cleanroom.v1.ListSandboxesResponse
+	32,  // 128: cleanroom.v1.SandboxService.DownloadSandboxFile:output_type -> cleanroom.v1.DownloadSandboxFileResponse
+	34,  // 129: cleanroom.v1.SandboxService.CommitSandbox:output_type -> cleanroom.v1.CommitSandboxResponse
+	36,  // 130: cleanroom.v1.SandboxService.CloneSandbox:output_type -> cleanroom.v1.CloneSandboxResponse
+	38,  // 131: cleanroom.v1.SandboxService.UpgradeSandboxAgent:output_type -> cleanroom.v1.UpgradeSandboxAgentResponse
+	40,  // 132: cleanroom.v1.SandboxService.PauseSandbox:output_type -> cleanroom.v1.PauseSandboxResponse
+	42,  // 133: cleanroom.v1.SandboxService.ResumeSandbox:output_type -> cleanroom.v1.ResumeSandboxResponse
+	44,  // 134: cleanroom.v1.SandboxService.TerminateSandbox:output_type -> cleanroom.v1.TerminateSandboxResponse
+	46,  // 135: cleanroom.v1.SandboxService.StreamSandboxEvents:output_type -> cleanroom.v1.SandboxEvent
+	55,  // 136: cleanroom.v1.ExecutionService.CreateExecution:output_type -> cleanroom.v1.CreateExecutionResponse
+	57,  // 137: cleanroom.v1.ExecutionService.OpenInteractiveExecution:output_type -> cleanroom.v1.OpenInteractiveExecutionResponse
+	59,  // 138: cleanroom.v1.ExecutionService.GetExecution:output_type -> cleanroom.v1.GetExecutionResponse
+	61,  // 139: cleanroom.v1.ExecutionService.CancelExecution:output_type -> cleanroom.v1.CancelExecutionResponse
+	72,  // 140: cleanroom.v1.ExecutionService.WriteExecutionStdin:output_type -> cleanroom.v1.WriteExecutionStdinResponse
+	77,  // 141: cleanroom.v1.ExecutionService.StreamExecution:output_type -> cleanroom.v1.ExecutionStreamEvent
+	64,  // 142: cleanroom.v1.ExecutionService.ListPendingApprovals:output_type -> cleanroom.v1.ListPendingApprovalsResponse
+	66,  // 143: cleanroom.v1.ExecutionService.ResolveExecutionApproval:output_type -> cleanroom.v1.ResolveExecutionApprovalResponse
+	68,  // 144: cleanroom.v1.ExecutionService.AnnotateExecution:output_type -> cleanroom.v1.AnnotateExecutionResponse
+	70,  // 145: cleanroom.v1.ExecutionService.ListExecutions:output_type -> cleanroom.v1.ListExecutionsResponse
+	80,  // 146: cleanroom.v1.ServerService.GetServerInfo:output_type -> cleanroom.v1.GetServerInfoResponse
+	82,  // 147: cleanroom.v1.ServerService.GetUsage:output_type -> cleanroom.v1.GetUsageResponse
+	87,  // 148: cleanroom.v1.ServerService.CreateSchedule:output_type -> cleanroom.v1.CreateScheduleResponse
+	89,  // 149: cleanroom.v1.ServerService.ListSchedules:output_type -> cleanroom.v1.ListSchedulesResponse
+	91,  // 150: cleanroom.v1.ServerService.GetSchedule:output_type -> cleanroom.v1.GetScheduleResponse
+	93,  // 151: cleanroom.v1.ServerService.DeleteSchedule:output_type -> cleanroom.v1.DeleteScheduleResponse
+	124, // [124:152] is the sub-list for method output_type
+	96,  // [96:124] is the sub-list for method input_type
+	96,  // [96:96] is the sub-list for extension type_name
+	96,  // [96:96] is the sub-list for extension extendee
+	0,   // [0:96] is the sub-list for field type_name
 }
 
 func init() { file_proto_cleanroom_v1_control_proto_init() }
@@ -2439,21 +7333,22 @@ func file_proto_cleanroom_v1_control_proto_init() {
 	if File_proto_cleanroom_v1_control_proto != nil {
 		return
 	}
-	file_proto_cleanroom_v1_control_proto_msgTypes[30].OneofWrappers = []any{
+	file_proto_cleanroom_v1_control_proto_msgTypes[71].OneofWrappers = []any{
 		(*ExecutionStreamEvent_Stdout)(nil),
 		(*ExecutionStreamEvent_Stderr)(nil),
 		(*ExecutionStreamEvent_Exit)(nil),
 		(*ExecutionStreamEvent_Message)(nil),
+		(*ExecutionStreamEvent_Environment)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_cleanroom_v1_control_proto_rawDesc), len(file_proto_cleanroom_v1_control_proto_rawDesc)),
-			NumEnums:      3,
-			NumMessages:   31,
+			NumEnums:      6,
+			NumMessages:   99,
 			NumExtensions: 0,
-			NumServices:   2,
+			NumServices:   3,
 		},
 		GoTypes:           file_proto_cleanroom_v1_control_proto_goTypes,
 		DependencyIndexes: file_proto_cleanroom_v1_control_proto_depIdxs,
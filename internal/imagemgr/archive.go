@@ -0,0 +1,409 @@
+package imagemgr
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// archiveSchemaVersion is the manifest version written by Save. Load refuses
+// archives with any other version.
+const archiveSchemaVersion = 1
+
+const archiveManifestName = "manifest.json"
+
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// errArchiveChecksumMismatch is wrapped by errors for archive entries whose
+// contents do not match the manifest.
+var errArchiveChecksumMismatch = errors.New("checksum mismatch")
+
+// archiveManifest describes the cache entries in an archive. It is written
+// after the files it lists, so a truncated archive has no manifest and is
+// refused.
+type archiveManifest struct {
+	SchemaVersion int            `json:"schema_version"`
+	Images        []archiveImage `json:"images"`
+}
+
+type archiveImage struct {
+	Digest    string        `json:"digest"`
+	Ref       string        `json:"ref"`
+	SizeBytes int64         `json:"size_bytes"`
+	CreatedAt time.Time     `json:"created_at"`
+	OCIConfig OCIConfig     `json:"oci_config"`
+	RootFS    archiveFile   `json:"rootfs"`
+	Prepared  []archiveFile `json:"prepared,omitempty"`
+}
+
+type archiveFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// Save writes the cached images matching selectors, and the prepared rootfs
+// recorded for them, to w as a zstd-compressed tar that Load can restore on
+// another host.
+func (m *Manager) Save(ctx context.Context, selectors []string, w io.Writer) ([]Record, error) {
+	if len(selectors) == 0 {
+		return nil, fmt.Errorf("at least one image selector is required")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.initDB(ctx); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", m.metadataDBPath)
+	if err != nil {
+		return nil, fmt.Errorf("open image metadata database %q: %w", m.metadataDBPath, err)
+	}
+	defer db.Close()
+
+	var records []Record
+	seen := map[string]bool{}
+	for _, selector := range selectors {
+		sel := strings.TrimSpace(selector)
+		if sel == "" {
+			return nil, fmt.Errorf("image selector cannot be empty")
+		}
+		matched, err := queryRecordsBySelector(ctx, db, sel)
+		if err != nil {
+			return nil, err
+		}
+		if len(matched) == 0 {
+			return nil, fmt.Errorf("no cached image matches %q", sel)
+		}
+		for _, record := range matched {
+			if !seen[record.Digest] {
+				seen[record.Digest] = true
+				records = append(records, record)
+			}
+		}
+	}
+
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return nil, fmt.Errorf("create zstd writer: %w", err)
+	}
+	tw := tar.NewWriter(zw)
+
+	manifest := archiveManifest{SchemaVersion: archiveSchemaVersion}
+	for _, record := range records {
+		image := archiveImage{
+			Digest:    record.Digest,
+			Ref:       record.Ref,
+			SizeBytes: record.SizeBytes,
+			CreatedAt: record.CreatedAt,
+			OCIConfig: record.OCIConfig,
+		}
+		image.RootFS, err = writeArchiveFile(ctx, tw, "rootfs/"+strings.TrimPrefix(record.Digest, "sha256:")+".ext4", record.RootFSPath)
+		if err != nil {
+			return nil, err
+		}
+
+		prepared, err := queryPrepared(ctx, db, record.Digest)
+		if err != nil {
+			return nil, err
+		}
+		for _, rel := range prepared {
+			file, err := writeArchiveFile(ctx, tw, "prepared/"+filepath.ToSlash(rel), filepath.Join(m.preparedDir, rel))
+			if errors.Is(err, os.ErrNotExist) {
+				// Prepared rootfs may be removed by hand; they are rebuilt
+				// on demand, so a missing one is not worth failing over.
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			image.Prepared = append(image.Prepared, file)
+		}
+		manifest.Images = append(manifest.Images, image)
+	}
+
+	rawManifest, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode archive manifest: %w", err)
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     archiveManifestName,
+		Mode:     0o644,
+		Size:     int64(len(rawManifest)),
+		Typeflag: tar.TypeReg,
+		ModTime:  m.now().UTC(),
+	}); err != nil {
+		return nil, fmt.Errorf("write archive manifest: %w", err)
+	}
+	if _, err := tw.Write(rawManifest); err != nil {
+		return nil, fmt.Errorf("write archive manifest: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("close archive: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("close archive compression: %w", err)
+	}
+	return records, nil
+}
+
+func writeArchiveFile(ctx context.Context, tw *tar.Writer, name, sourcePath string) (archiveFile, error) {
+	if err := ctx.Err(); err != nil {
+		return archiveFile{}, err
+	}
+	f, err := os.Open(sourcePath)
+	if err != nil {
+		return archiveFile{}, fmt.Errorf("open %q: %w", sourcePath, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return archiveFile{}, fmt.Errorf("stat %q: %w", sourcePath, err)
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0o644,
+		Size:     info.Size(),
+		Typeflag: tar.TypeReg,
+		ModTime:  info.ModTime().UTC(),
+	}); err != nil {
+		return archiveFile{}, fmt.Errorf("write archive entry %s: %w", name, err)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tw, hash), f); err != nil {
+		return archiveFile{}, fmt.Errorf("write archive entry %s: %w", name, err)
+	}
+	return archiveFile{Path: name, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// Load restores the cache entries in an archive written by Save. Compressed
+// and plain tar streams are both accepted. Every file is checked against the
+// manifest's checksum before anything is moved into the cache.
+func (m *Manager) Load(ctx context.Context, r io.Reader) ([]Record, error) {
+	staged, err := m.stageArchive(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer staged.cleanup()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.commitArchive(ctx, staged, "load")
+}
+
+// stagedArchive is an archive unpacked and verified in a directory under
+// the cache, ready to be moved into place.
+type stagedArchive struct {
+	dir      string
+	manifest archiveManifest
+}
+
+func (s *stagedArchive) cleanup() {
+	_ = os.RemoveAll(s.dir)
+}
+
+func (m *Manager) stageArchive(ctx context.Context, r io.Reader) (*stagedArchive, error) {
+	br := bufio.NewReader(r)
+	var stream io.Reader = br
+	if magic, _ := br.Peek(len(zstdMagic)); bytes.Equal(magic, zstdMagic) {
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("open zstd stream: %w", err)
+		}
+		defer zr.Close()
+		stream = zr
+	}
+
+	dir, err := os.MkdirTemp(m.cacheDir, ".load-*")
+	if err != nil {
+		return nil, fmt.Errorf("create staging directory: %w", err)
+	}
+	staged := &stagedArchive{dir: dir}
+	if err := staged.unpack(ctx, stream); err != nil {
+		staged.cleanup()
+		return nil, err
+	}
+	return staged, nil
+}
+
+func (s *stagedArchive) unpack(ctx context.Context, stream io.Reader) error {
+	sums := map[string]string{}
+	var manifest *archiveManifest
+	tr := tar.NewReader(stream)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read archive: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return fmt.Errorf("archive entry %q is not a regular file", hdr.Name)
+		}
+		name := path.Clean(hdr.Name)
+		if name == archiveManifestName {
+			manifest = &archiveManifest{}
+			if err := json.NewDecoder(io.LimitReader(tr, 16<<20)).Decode(manifest); err != nil {
+				return fmt.Errorf("decode archive manifest: %w", err)
+			}
+			continue
+		}
+		if !filepath.IsLocal(filepath.FromSlash(name)) || (!strings.HasPrefix(name, "rootfs/") && !strings.HasPrefix(name, "prepared/")) {
+			return fmt.Errorf("archive entry %q is outside the image cache", hdr.Name)
+		}
+		sum, err := stageArchiveFile(tr, filepath.Join(s.dir, filepath.FromSlash(name)))
+		if err != nil {
+			return err
+		}
+		sums[name] = sum
+	}
+	if manifest == nil {
+		return fmt.Errorf("archive has no %s; it may be truncated", archiveManifestName)
+	}
+	if manifest.SchemaVersion != archiveSchemaVersion {
+		return fmt.Errorf("archive schema version %d is not supported (want %d)", manifest.SchemaVersion, archiveSchemaVersion)
+	}
+
+	for i, image := range manifest.Images {
+		digest, ok := normalizeDigestSelector(image.Digest)
+		if !ok {
+			return fmt.Errorf("archive image digest %q is invalid", image.Digest)
+		}
+		if image.RootFS.Path != "rootfs/"+strings.TrimPrefix(digest, "sha256:")+".ext4" {
+			return fmt.Errorf("archive image %s has unexpected rootfs path %q", digest, image.RootFS.Path)
+		}
+		for _, file := range append([]archiveFile{image.RootFS}, image.Prepared...) {
+			got, ok := sums[file.Path]
+			if !ok {
+				return fmt.Errorf("archive image %s is missing %s", digest, file.Path)
+			}
+			if got != file.SHA256 {
+				return fmt.Errorf("archive entry %s %w: got sha256:%s, want sha256:%s", file.Path, errArchiveChecksumMismatch, got, file.SHA256)
+			}
+		}
+		manifest.Images[i].Digest = digest
+	}
+	s.manifest = *manifest
+	return nil
+}
+
+// commitArchive moves a staged archive's files into the cache and records
+// them with source. The caller holds m.mu.
+func (m *Manager) commitArchive(ctx context.Context, staged *stagedArchive, source string) ([]Record, error) {
+	if err := m.initDB(ctx); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", m.metadataDBPath)
+	if err != nil {
+		return nil, fmt.Errorf("open image metadata database %q: %w", m.metadataDBPath, err)
+	}
+	defer db.Close()
+
+	now := m.now().UTC()
+	records := make([]Record, 0, len(staged.manifest.Images))
+	for _, image := range staged.manifest.Images {
+		rootfsPath := filepath.Join(m.cacheDir, strings.TrimPrefix(image.Digest, "sha256:")+".ext4")
+		if err := moveFile(filepath.Join(staged.dir, filepath.FromSlash(image.RootFS.Path)), rootfsPath); err != nil {
+			return nil, err
+		}
+		createdAt := image.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = now
+		}
+		record := Record{
+			Digest:     image.Digest,
+			Ref:        image.Ref,
+			RootFSPath: rootfsPath,
+			SizeBytes:  image.SizeBytes,
+			CreatedAt:  createdAt,
+			LastUsedAt: now,
+			Source:     source,
+			OCIConfig:  image.OCIConfig,
+		}
+		if err := m.upsertRecord(ctx, record); err != nil {
+			return nil, err
+		}
+
+		for _, file := range image.Prepared {
+			rel := filepath.FromSlash(strings.TrimPrefix(file.Path, "prepared/"))
+			dest := filepath.Join(m.preparedDir, rel)
+			if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+				return nil, fmt.Errorf("create prepared rootfs directory: %w", err)
+			}
+			if err := moveFile(filepath.Join(staged.dir, filepath.FromSlash(file.Path)), dest); err != nil {
+				return nil, err
+			}
+			if err := insertPrepared(ctx, db, image.Digest, rel); err != nil {
+				return nil, err
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func stageArchiveFile(r io.Reader, dest string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return "", fmt.Errorf("create staging directory: %w", err)
+	}
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("stage archive entry: %w", err)
+	}
+	hash := sha256.New()
+	_, copyErr := io.Copy(io.MultiWriter(f, hash), r)
+	closeErr := f.Close()
+	if err := errors.Join(copyErr, closeErr); err != nil {
+		return "", fmt.Errorf("stage archive entry %q: %w", dest, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// moveFile renames src to dest, copying when they are on different
+// filesystems.
+func moveFile(src, dest string) error {
+	if err := os.Rename(src, dest); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open staged file %q: %w", src, err)
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create %q: %w", dest, err)
+	}
+	defer os.Remove(tmp.Name())
+	_, copyErr := io.Copy(tmp, in)
+	closeErr := tmp.Close()
+	if err := errors.Join(copyErr, closeErr); err != nil {
+		return fmt.Errorf("copy to %q: %w", dest, err)
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return fmt.Errorf("move to %q: %w", dest, err)
+	}
+	return nil
+}
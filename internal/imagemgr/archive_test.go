@@ -0,0 +1,170 @@
+package imagemgr
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/log"
+)
+
+func TestSaveLoadRoundTripsImageAndPreparedRootFS(t *testing.T) {
+	t.Parallel()
+
+	src := newTestManager(t, nil)
+	src.preparedDir = t.TempDir()
+	if _, err := src.Import(context.Background(), testImageRef, "-", bytes.NewReader(testRootFSTar(t))); err != nil {
+		t.Fatalf("Import returned error: %v", err)
+	}
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	preparedPath := filepath.Join(src.preparedDir, "firecracker", "runtime-rootfs", "abc.ext4")
+	if err := os.MkdirAll(filepath.Dir(preparedPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(preparedPath, []byte("prepared"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.RecordPrepared(context.Background(), digest, preparedPath); err != nil {
+		t.Fatalf("RecordPrepared returned error: %v", err)
+	}
+
+	var archive bytes.Buffer
+	saved, err := src.Save(context.Background(), []string{digest}, &archive)
+	if err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	if len(saved) != 1 {
+		t.Fatalf("expected one saved image, got %d", len(saved))
+	}
+	if !bytes.HasPrefix(archive.Bytes(), zstdMagic) {
+		t.Fatal("expected a zstd-compressed archive")
+	}
+
+	dst := newTestManager(t, nil)
+	dst.preparedDir = t.TempDir()
+	loaded, err := dst.Load(context.Background(), &archive)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if len(loaded) != 1 || loaded[0].Digest != digest || loaded[0].Ref != testImageRef {
+		t.Fatalf("unexpected loaded records: %+v", loaded)
+	}
+	if got, err := os.ReadFile(loaded[0].RootFSPath); err != nil || string(got) != "fake-ext4" {
+		t.Fatalf("unexpected loaded rootfs %q: %v", got, err)
+	}
+	loadedPrepared := filepath.Join(dst.preparedDir, "firecracker", "runtime-rootfs", "abc.ext4")
+	if got, err := os.ReadFile(loadedPrepared); err != nil || string(got) != "prepared" {
+		t.Fatalf("unexpected loaded prepared rootfs %q: %v", got, err)
+	}
+
+	result, err := dst.Ensure(context.Background(), testImageRef)
+	if err != nil {
+		t.Fatalf("Ensure after load returned error: %v", err)
+	}
+	if !result.CacheHit {
+		t.Fatal("expected loaded image to be a cache hit")
+	}
+
+	if _, err := dst.Remove(context.Background(), digest); err != nil {
+		t.Fatalf("Remove returned error: %v", err)
+	}
+	if _, err := os.Stat(loadedPrepared); !os.IsNotExist(err) {
+		t.Fatalf("expected prepared rootfs to be removed with its image, stat err=%v", err)
+	}
+}
+
+func TestLoadRejectsEntriesOutsideCache(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	content := []byte("x")
+	if err := tw.WriteHeader(&tar.Header{Name: "prepared/../../escape", Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	manager := newTestManager(t, nil)
+	_, err := manager.Load(context.Background(), &buf)
+	if err == nil || !strings.Contains(err.Error(), "outside the image cache") {
+		t.Fatalf("expected path rejection, got %v", err)
+	}
+}
+
+func TestLoadRejectsChecksumMismatch(t *testing.T) {
+	t.Parallel()
+
+	manager := newTestManager(t, nil)
+	_, err := manager.Load(context.Background(), bytes.NewReader(checksumMismatchArchive(t)))
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	if items, _ := manager.List(context.Background()); len(items) != 0 {
+		t.Fatalf("expected nothing loaded, got %d images", len(items))
+	}
+}
+
+type fakePeerSource struct {
+	archive []byte
+}
+
+func (f fakePeerSource) FetchArchive(context.Context, string) (io.ReadCloser, string, error) {
+	return io.NopCloser(bytes.NewReader(f.archive)), "https://peer-a:7777", nil
+}
+
+func TestEnsureFromPeersLogsChecksumMismatch(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	manager := newTestManager(t, nil)
+	manager.peers = fakePeerSource{archive: checksumMismatchArchive(t)}
+	manager.logger = log.New(&logs)
+
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	if _, ok := manager.ensureFromPeers(context.Background(), testImageRef, digest); ok {
+		t.Fatal("expected a corrupt peer archive to be refused")
+	}
+	got := logs.String()
+	for _, want := range []string{"ERRO", "peer=https://peer-a:7777", "digest=" + digest, "checksum mismatch"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected log to contain %q, got %q", want, got)
+		}
+	}
+}
+
+// checksumMismatchArchive returns an archive whose rootfs does not match the
+// checksum in its manifest.
+func checksumMismatchArchive(t *testing.T) []byte {
+	t.Helper()
+
+	const hexDigest = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	manifest := `{"schema_version":1,"images":[{"digest":"sha256:` + hexDigest + `","rootfs":{"path":"rootfs/` + hexDigest + `.ext4","sha256":"00"}}]}`
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, entry := range []struct{ name, body string }{
+		{"rootfs/" + hexDigest + ".ext4", "fake-ext4"},
+		{archiveManifestName, manifest},
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: entry.name, Mode: 0o644, Size: int64(len(entry.body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(entry.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
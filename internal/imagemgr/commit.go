@@ -0,0 +1,160 @@
+package imagemgr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/ociref"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// CommitRequest describes a root filesystem to publish as a new image.
+type CommitRequest struct {
+	// Ref is the tag to push to, for example ghcr.io/org/img:tag.
+	Ref string
+	// BaseRef is the digest-pinned image the filesystem started from. When
+	// it is cached, its entrypoint, command, environment, working directory
+	// and user carry over to the new image.
+	BaseRef string
+	// RootFS is an uncompressed tarball of the filesystem.
+	RootFS io.Reader
+}
+
+// Commit packs a root filesystem into a single-layer OCI image, pushes it to
+// req.Ref and caches it so it can run without a pull. The returned record's
+// Ref is pinned to the pushed digest.
+func (m *Manager) Commit(ctx context.Context, req CommitRequest) (Record, error) {
+	tag, err := parseCommitTag(req.Ref)
+	if err != nil {
+		return Record{}, err
+	}
+	if req.RootFS == nil {
+		return Record{}, fmt.Errorf("missing rootfs stream")
+	}
+
+	var config OCIConfig
+	if strings.TrimSpace(req.BaseRef) != "" {
+		base, err := ociref.ParseDigestReference(req.BaseRef)
+		if err != nil {
+			return Record{}, fmt.Errorf("parse base image: %w", err)
+		}
+		m.mu.Lock()
+		record, found, err := m.lookupByDigest(ctx, base.Digest())
+		m.mu.Unlock()
+		if err != nil {
+			return Record{}, err
+		}
+		if found {
+			config = record.OCIConfig
+		}
+	}
+
+	// The layer is read once to compute its digest and again to upload and
+	// cache it, so the stream is spooled to disk first.
+	spool, err := os.CreateTemp(m.cacheDir, ".commit-*.tar")
+	if err != nil {
+		return Record{}, fmt.Errorf("create commit spool file: %w", err)
+	}
+	spoolPath := spool.Name()
+	defer os.Remove(spoolPath)
+	if _, err := io.Copy(spool, req.RootFS); err != nil {
+		_ = spool.Close()
+		return Record{}, fmt.Errorf("read rootfs: %w", err)
+	}
+	if err := spool.Close(); err != nil {
+		return Record{}, err
+	}
+
+	now := m.now().UTC()
+	img, err := buildCommitImage(spoolPath, config, now)
+	if err != nil {
+		return Record{}, err
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return Record{}, fmt.Errorf("compute image digest: %w", err)
+	}
+	if err := remote.Write(tag, img, remote.WithContext(ctx), remote.WithAuthFromKeychain(authn.DefaultKeychain)); err != nil {
+		return Record{}, fmt.Errorf("push image %q: %w", tag.Name(), err)
+	}
+
+	layerTar, err := os.Open(spoolPath)
+	if err != nil {
+		return Record{}, err
+	}
+	defer layerTar.Close()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.persistFromTarStream(ctx, persistFromTarRequest{
+		Ref:        tag.Name() + "@" + digest.String(),
+		Digest:     digest.String(),
+		TarStream:  layerTar,
+		OCIConfig:  config,
+		Source:     "commit",
+		CreatedAt:  now,
+		LastUsedAt: now,
+	})
+}
+
+// parseCommitTag accepts a tag reference. Digests are rejected because the
+// digest of a new image is only known once it is built.
+func parseCommitTag(ref string) (name.Tag, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return name.Tag{}, fmt.Errorf("missing image reference")
+	}
+	if strings.Contains(ref, "@") {
+		return name.Tag{}, fmt.Errorf("image reference %q must be a tag, not a digest", ref)
+	}
+	tag, err := name.NewTag(ref)
+	if err != nil {
+		return name.Tag{}, fmt.Errorf("parse image reference %q: %w", ref, err)
+	}
+	return tag, nil
+}
+
+func buildCommitImage(layerPath string, config OCIConfig, created time.Time) (v1.Image, error) {
+	layer, err := tarball.LayerFromFile(layerPath, tarball.WithMediaType(types.OCILayer))
+	if err != nil {
+		return nil, fmt.Errorf("create image layer: %w", err)
+	}
+	platform := hostLinuxPlatform()
+	base := mutate.ConfigMediaType(mutate.MediaType(empty.Image, types.OCIManifestSchema1), types.OCIConfigJSON)
+	base, err = mutate.ConfigFile(base, &v1.ConfigFile{
+		Architecture: platform.Architecture,
+		OS:           platform.OS,
+		Variant:      platform.Variant,
+		Created:      v1.Time{Time: created},
+		RootFS:       v1.RootFS{Type: "layers"},
+		Config: v1.Config{
+			Entrypoint: config.Entrypoint,
+			Cmd:        config.Cmd,
+			Env:        config.Env,
+			WorkingDir: config.Workdir,
+			User:       config.User,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mutate.Append(base, mutate.Addendum{
+		Layer:     layer,
+		MediaType: types.OCILayer,
+		History: v1.History{
+			Created:   v1.Time{Time: created},
+			CreatedBy: "cleanroom sandbox commit",
+		},
+	})
+}
@@ -0,0 +1,100 @@
+package imagemgr
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestCommitPushesAndCachesImage(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	manager := newTestManager(t, func(_ context.Context, _ string) (io.ReadCloser, OCIConfig, error) {
+		return io.NopCloser(bytes.NewReader(testRootFSTar(t))), OCIConfig{
+			Entrypoint: []string{"/bin/sh"},
+			Env:        []string{"PATH=/usr/bin"},
+			Workdir:    "/workspace",
+		}, nil
+	})
+	if _, err := manager.Ensure(context.Background(), testImageRef); err != nil {
+		t.Fatalf("Ensure returned error: %v", err)
+	}
+
+	record, err := manager.Commit(context.Background(), CommitRequest{
+		Ref:     host + "/org/img:ci",
+		BaseRef: testImageRef,
+		RootFS:  bytes.NewReader(testRootFSTar(t)),
+	})
+	if err != nil {
+		t.Fatalf("Commit returned error: %v", err)
+	}
+	if got, want := record.Ref, host+"/org/img:ci@"+record.Digest; got != want {
+		t.Fatalf("unexpected ref: got %q want %q", got, want)
+	}
+	if record.Source != "commit" {
+		t.Fatalf("unexpected source %q", record.Source)
+	}
+
+	pushedRef, err := name.ParseReference(record.Ref)
+	if err != nil {
+		t.Fatalf("parse committed ref: %v", err)
+	}
+	pushed, err := remote.Image(pushedRef)
+	if err != nil {
+		t.Fatalf("fetch pushed image: %v", err)
+	}
+	digest, err := pushed.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest.String() != record.Digest {
+		t.Fatalf("pushed digest %s does not match record %s", digest, record.Digest)
+	}
+	cfg, err := pushed.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.Config.Entrypoint, []string{"/bin/sh"}) || cfg.Config.WorkingDir != "/workspace" {
+		t.Fatalf("expected base image config to carry over, got %+v", cfg.Config)
+	}
+	layers, err := pushed.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 1 {
+		t.Fatalf("expected a single layer, got %d", len(layers))
+	}
+
+	items, err := manager.List(context.Background())
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected base and committed images to be cached, got %d", len(items))
+	}
+}
+
+func TestCommitRejectsDigestReference(t *testing.T) {
+	t.Parallel()
+
+	manager := newTestManager(t, nil)
+	_, err := manager.Commit(context.Background(), CommitRequest{
+		Ref:    testImageRef,
+		RootFS: bytes.NewReader(testRootFSTar(t)),
+	})
+	if err == nil || !strings.Contains(err.Error(), "must be a tag") {
+		t.Fatalf("expected digest reference to be rejected, got %v", err)
+	}
+}
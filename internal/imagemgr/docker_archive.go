@@ -0,0 +1,84 @@
+package imagemgr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/ociref"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+const dockerArchiveDir = "docker-archives"
+
+// DockerArchive returns the path of a `docker load` tarball for a
+// digest-pinned ref, pulling it into the cache on first use. The image is
+// tagged with the ref's tag, or latest when it has none, so guests can run
+// it by name without reaching the registry.
+func (m *Manager) DockerArchive(ctx context.Context, ref string) (string, error) {
+	parsed, err := ociref.ParseDigestReference(ref)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(m.cacheDir, dockerArchiveDir)
+	path := filepath.Join(dir, parsed.DigestAlgorithm+"-"+parsed.DigestHex+".tar")
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create docker archive cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".pull-*.tar")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if err := m.pullDockerArchive(ctx, parsed.Original, tmp); err != nil {
+		_ = tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// dockerArchiveTag returns the tag to load a digest-pinned repository under.
+func dockerArchiveTag(repository string) (name.Tag, error) {
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		return name.NewTag(repository)
+	}
+	return name.NewTag(repository + ":latest")
+}
+
+func pullDockerArchiveFromRegistry(ctx context.Context, ref string, w io.Writer) error {
+	parsed, err := ociref.ParseDigestReference(ref)
+	if err != nil {
+		return err
+	}
+	tag, err := dockerArchiveTag(parsed.Repository)
+	if err != nil {
+		return fmt.Errorf("parse repository %q: %w", parsed.Repository, err)
+	}
+	digestRef, err := name.NewDigest(tag.Context().Name() + "@" + parsed.Digest())
+	if err != nil {
+		return fmt.Errorf("parse digest reference %q: %w", ref, err)
+	}
+	img, err := remote.Image(digestRef, remote.WithContext(ctx), remote.WithPlatform(hostLinuxPlatform()))
+	if err != nil {
+		return fmt.Errorf("pull OCI image %q: %w", ref, err)
+	}
+	if err := tarball.Write(tag, img, w); err != nil {
+		return fmt.Errorf("write docker archive for %q: %w", ref, err)
+	}
+	return nil
+}
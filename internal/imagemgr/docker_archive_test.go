@@ -0,0 +1,79 @@
+package imagemgr
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDockerArchiveCachesByDigest(t *testing.T) {
+	t.Parallel()
+
+	manager := newTestManager(t, nil)
+	var pulls int
+	manager.pullDockerArchive = func(_ context.Context, ref string, w io.Writer) error {
+		pulls++
+		_, err := io.WriteString(w, "archive:"+ref)
+		return err
+	}
+
+	first, err := manager.DockerArchive(context.Background(), testImageRef)
+	if err != nil {
+		t.Fatalf("DockerArchive returned error: %v", err)
+	}
+	second, err := manager.DockerArchive(context.Background(), testImageRef)
+	if err != nil {
+		t.Fatalf("DockerArchive returned error: %v", err)
+	}
+	if first != second || pulls != 1 {
+		t.Fatalf("expected one pull and a stable path, got pulls=%d paths %q %q", pulls, first, second)
+	}
+	data, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatalf("read archive: %v", err)
+	}
+	if got, want := string(data), "archive:"+testImageRef; got != want {
+		t.Fatalf("unexpected archive content: got %q want %q", got, want)
+	}
+}
+
+func TestDockerArchiveLeavesNoPartialFileOnPullFailure(t *testing.T) {
+	t.Parallel()
+
+	manager := newTestManager(t, nil)
+	manager.pullDockerArchive = func(_ context.Context, _ string, w io.Writer) error {
+		_, _ = io.WriteString(w, "partial")
+		return errors.New("registry unavailable")
+	}
+	if _, err := manager.DockerArchive(context.Background(), testImageRef); err == nil {
+		t.Fatal("expected pull error")
+	}
+	entries, err := os.ReadDir(manager.cacheDir + "/" + dockerArchiveDir)
+	if err != nil {
+		t.Fatalf("read archive dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected no archives after failure, got %d entries", len(entries))
+	}
+}
+
+func TestDockerArchiveTag(t *testing.T) {
+	t.Parallel()
+
+	for repository, want := range map[string]string{
+		"alpine":                      "index.docker.io/library/alpine:latest",
+		"ghcr.io/acme/tool:1.2":       "ghcr.io/acme/tool:1.2",
+		"localhost:5000/acme/service": "localhost:5000/acme/service:latest",
+	} {
+		tag, err := dockerArchiveTag(repository)
+		if err != nil {
+			t.Fatalf("%s: %v", repository, err)
+		}
+		if got := tag.Name(); !strings.EqualFold(got, want) {
+			t.Fatalf("%s: unexpected tag: got %q want %q", repository, got, want)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -16,8 +17,10 @@ import (
 	"time"
 
 	"github.com/buildkite/cleanroom/internal/hosttools"
+	"github.com/buildkite/cleanroom/internal/logging"
 	"github.com/buildkite/cleanroom/internal/ociref"
 	"github.com/buildkite/cleanroom/internal/paths"
+	"github.com/charmbracelet/log"
 	_ "modernc.org/sqlite"
 )
 
@@ -50,21 +53,42 @@ type EnsureResult struct {
 type Options struct {
 	CacheDir       string
 	MetadataDBPath string
-	MkfsBinary     string
-	Now            func() time.Time
+	// PreparedDir holds backend-prepared rootfs derived from cached images,
+	// recorded with RecordPrepared. It defaults to the cache base directory.
+	PreparedDir string
+	MkfsBinary  string
+	Now         func() time.Time
 
 	PullImage         func(context.Context, string) (io.ReadCloser, OCIConfig, error)
 	MaterializeRootFS func(context.Context, io.Reader, string) (int64, error)
+	PullDockerArchive func(context.Context, string, io.Writer) error
+
+	// Peers, if set, is asked for an image before the registry.
+	Peers PeerSource
+	// Logger receives peer fetch failures; nil uses the default logger.
+	Logger *log.Logger
+}
+
+// PeerSource fetches cache archives, as written by Save, from other hosts.
+// It returns the archive and the peer that served it, or an error when no
+// peer has the digest; Ensure then pulls from the registry.
+type PeerSource interface {
+	FetchArchive(ctx context.Context, digest string) (archive io.ReadCloser, peer string, err error)
 }
 
 type Manager struct {
 	cacheDir       string
 	metadataDBPath string
+	preparedDir    string
 	mkfsBinary     string
 	now            func() time.Time
 	pullImage      func(context.Context, string) (io.ReadCloser, OCIConfig, error)
 	materialize    func(context.Context, io.Reader, string) (int64, error)
 
+	pullDockerArchive func(context.Context, string, io.Writer) error
+	peers             PeerSource
+	logger            *log.Logger
+
 	mu sync.Mutex
 }
 
@@ -93,6 +117,15 @@ func New(opts Options) (*Manager, error) {
 		return nil, fmt.Errorf("create image metadata directory for %q: %w", metadataDBPath, err)
 	}
 
+	preparedDir := strings.TrimSpace(opts.PreparedDir)
+	if preparedDir == "" {
+		var err error
+		preparedDir, err = paths.CacheBaseDir()
+		if err != nil {
+			return nil, fmt.Errorf("resolve cache base directory: %w", err)
+		}
+	}
+
 	now := opts.Now
 	if now == nil {
 		now = time.Now
@@ -110,14 +143,25 @@ func New(opts Options) (*Manager, error) {
 	manager := &Manager{
 		cacheDir:       cacheDir,
 		metadataDBPath: metadataDBPath,
+		preparedDir:    preparedDir,
 		mkfsBinary:     mkfsBinary,
 		now:            now,
+		peers:          opts.Peers,
+		logger:         opts.Logger,
+	}
+	if manager.logger == nil {
+		manager.logger = log.Default()
 	}
 	if opts.PullImage != nil {
 		manager.pullImage = opts.PullImage
 	} else {
 		manager.pullImage = pullImageFromRegistry
 	}
+	if opts.PullDockerArchive != nil {
+		manager.pullDockerArchive = opts.PullDockerArchive
+	} else {
+		manager.pullDockerArchive = pullDockerArchiveFromRegistry
+	}
 	if opts.MaterializeRootFS != nil {
 		manager.materialize = opts.MaterializeRootFS
 	} else {
@@ -164,6 +208,10 @@ func (m *Manager) Ensure(ctx context.Context, ref string) (EnsureResult, error)
 		}
 	}
 
+	if record, ok := m.ensureFromPeers(ctx, parsedRef.Original, parsedRef.Digest()); ok {
+		return EnsureResult{Record: record, CacheHit: false}, nil
+	}
+
 	tarStream, config, err := m.pullImage(ctx, parsedRef.Original)
 	if err != nil {
 		return EnsureResult{}, err
@@ -186,6 +234,43 @@ func (m *Manager) Ensure(ctx context.Context, ref string) (EnsureResult, error)
 	return EnsureResult{Record: record, CacheHit: false}, nil
 }
 
+// ensureFromPeers loads digest from a peer's cache. Any failure is treated
+// as a miss, so the image is pulled from the registry instead. The caller
+// holds m.mu.
+func (m *Manager) ensureFromPeers(ctx context.Context, ref, digest string) (Record, bool) {
+	if m.peers == nil {
+		return Record{}, false
+	}
+	archive, peer, err := m.peers.FetchArchive(ctx, digest)
+	if err != nil {
+		return Record{}, false
+	}
+	defer archive.Close()
+
+	logger := logging.Logger(ctx, m.logger).With("peer", peer, "digest", digest)
+	staged, err := m.stageArchive(ctx, archive)
+	if err != nil {
+		if errors.Is(err, errArchiveChecksumMismatch) {
+			logger.Error("peer served a corrupt image archive, pulling from the registry", "err", err)
+		} else {
+			logger.Warn("staging image from peer failed, pulling from the registry", "err", err)
+		}
+		return Record{}, false
+	}
+	defer staged.cleanup()
+	if len(staged.manifest.Images) != 1 || staged.manifest.Images[0].Digest != digest {
+		logger.Error("peer served an archive for a different image, pulling from the registry", "images", len(staged.manifest.Images))
+		return Record{}, false
+	}
+	staged.manifest.Images[0].Ref = ref
+	records, err := m.commitArchive(ctx, staged, "peer")
+	if err != nil {
+		logger.Warn("caching image from peer failed, pulling from the registry", "err", err)
+		return Record{}, false
+	}
+	return records[0], true
+}
+
 func (m *Manager) Pull(ctx context.Context, ref string) (EnsureResult, error) {
 	return m.Ensure(ctx, ref)
 }
@@ -295,17 +380,81 @@ func (m *Manager) Remove(ctx context.Context, selector string) ([]Record, error)
 		if err := os.Remove(record.RootFSPath); err != nil && !os.IsNotExist(err) {
 			return nil, fmt.Errorf("remove cached rootfs %q: %w", record.RootFSPath, err)
 		}
+		prepared, err := queryPrepared(ctx, db, record.Digest)
+		if err != nil {
+			return nil, err
+		}
+		for _, rel := range prepared {
+			if err := os.Remove(filepath.Join(m.preparedDir, rel)); err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("remove prepared rootfs %q: %w", rel, err)
+			}
+		}
 	}
 
 	for _, record := range records {
 		if _, err := db.ExecContext(ctx, `DELETE FROM images WHERE digest = ?`, record.Digest); err != nil {
 			return nil, fmt.Errorf("delete cached image metadata for %s: %w", record.Digest, err)
 		}
+		if _, err := db.ExecContext(ctx, `DELETE FROM prepared WHERE digest = ?`, record.Digest); err != nil {
+			return nil, fmt.Errorf("delete prepared rootfs metadata for %s: %w", record.Digest, err)
+		}
 	}
 
 	return records, nil
 }
 
+// RecordPrepared notes that path, under the prepared directory, was derived
+// from the cached image digest, such as a rootfs with a backend's guest
+// runtime installed. Prepared files are removed with their image and
+// carried along by Save and Load.
+func (m *Manager) RecordPrepared(ctx context.Context, digest, path string) error {
+	rel, err := filepath.Rel(m.preparedDir, path)
+	if err != nil || !filepath.IsLocal(rel) {
+		return fmt.Errorf("prepared rootfs %q is not under %q", path, m.preparedDir)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.initDB(ctx); err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite", m.metadataDBPath)
+	if err != nil {
+		return fmt.Errorf("open image metadata database %q: %w", m.metadataDBPath, err)
+	}
+	defer db.Close()
+	return insertPrepared(ctx, db, digest, rel)
+}
+
+func insertPrepared(ctx context.Context, db *sql.DB, digest, rel string) error {
+	if _, err := db.ExecContext(ctx, `
+		INSERT INTO prepared (path, digest) VALUES (?, ?)
+		ON CONFLICT(path) DO UPDATE SET digest = excluded.digest
+	`, filepath.ToSlash(rel), digest); err != nil {
+		return fmt.Errorf("record prepared rootfs %q for %s: %w", rel, digest, err)
+	}
+	return nil
+}
+
+// queryPrepared returns the prepared files recorded for digest, relative
+// to the prepared directory.
+func queryPrepared(ctx context.Context, db *sql.DB, digest string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT path FROM prepared WHERE digest = ? ORDER BY path`, digest)
+	if err != nil {
+		return nil, fmt.Errorf("query prepared rootfs for %s: %w", digest, err)
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var rel string
+		if err := rows.Scan(&rel); err != nil {
+			return nil, err
+		}
+		out = append(out, filepath.FromSlash(rel))
+	}
+	return out, rows.Err()
+}
+
 type persistFromTarRequest struct {
 	Ref        string
 	Digest     string
@@ -393,6 +542,11 @@ func (m *Manager) initDB(ctx context.Context) error {
 			oci_user TEXT NOT NULL
 		);
 		CREATE INDEX IF NOT EXISTS idx_images_ref ON images(ref);
+		CREATE TABLE IF NOT EXISTS prepared (
+			path TEXT PRIMARY KEY,
+			digest TEXT NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_prepared_digest ON prepared(digest);
 	`)
 	if err != nil {
 		return fmt.Errorf("initialise image metadata schema: %w", err)
@@ -0,0 +1,101 @@
+package imagemgr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/buildkite/cleanroom/internal/ociref"
+)
+
+// setupImageVersion is part of every setup digest. Bump it when the way
+// setup images are built changes so older ones are not reused.
+const setupImageVersion = 2
+
+// SetupDigest identifies the image produced by running the setup commands,
+// in order, on the base image with baseDigest. The commands' output depends
+// on the network and package settings they ran under, so the digest also
+// covers policyHash (the policy without its setup commands) and the
+// namespace, and a setup image is never shared across either.
+func SetupDigest(baseDigest, policyHash, namespace string, setup []string) string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "cleanroom-setup-v%d\n%s\n%s\n%s\n", setupImageVersion, baseDigest, policyHash, namespace)
+	for _, command := range setup {
+		_, _ = io.WriteString(h, command)
+		_, _ = h.Write([]byte{0})
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// SetupImageRequest describes the filesystem left behind by setup commands.
+type SetupImageRequest struct {
+	// BaseRef is the digest-pinned image the commands ran on. Its OCI
+	// config carries over to the setup image.
+	BaseRef string
+	// Digest is the SetupDigest of the base image and commands.
+	Digest string
+	// RootFS is an uncompressed tarball of the filesystem.
+	RootFS io.Reader
+}
+
+// LookupSetupImage returns the cached setup image with digest and marks it
+// used. A record whose rootfs was removed from disk is dropped and reported
+// as missing.
+func (m *Manager) LookupSetupImage(ctx context.Context, digest string) (Record, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, found, err := m.lookupByDigest(ctx, digest)
+	if err != nil || !found {
+		return Record{}, false, err
+	}
+	if _, err := os.Stat(record.RootFSPath); err != nil {
+		if !os.IsNotExist(err) {
+			return Record{}, false, fmt.Errorf("stat cached rootfs %q: %w", record.RootFSPath, err)
+		}
+		return Record{}, false, m.deleteByDigest(ctx, record.Digest)
+	}
+	record.LastUsedAt = m.now().UTC()
+	if err := m.upsertRecord(ctx, record); err != nil {
+		return Record{}, false, err
+	}
+	return record, true, nil
+}
+
+// StoreSetupImage caches the filesystem a setup run produced. Setup images
+// only exist locally, so their ref is the base repository with a "+setup"
+// suffix and never resolves in a registry.
+func (m *Manager) StoreSetupImage(ctx context.Context, req SetupImageRequest) (Record, error) {
+	base, err := ociref.ParseDigestReference(req.BaseRef)
+	if err != nil {
+		return Record{}, fmt.Errorf("parse base image: %w", err)
+	}
+	if req.RootFS == nil {
+		return Record{}, fmt.Errorf("missing rootfs stream")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var config OCIConfig
+	baseRecord, found, err := m.lookupByDigest(ctx, base.Digest())
+	if err != nil {
+		return Record{}, err
+	}
+	if found {
+		config = baseRecord.OCIConfig
+	}
+	now := m.now().UTC()
+	return m.persistFromTarStream(ctx, persistFromTarRequest{
+		Ref:        base.Repository + "+setup@" + req.Digest,
+		Digest:     req.Digest,
+		TarStream:  req.RootFS,
+		OCIConfig:  config,
+		Source:     "setup",
+		CreatedAt:  now,
+		LastUsedAt: now,
+	})
+}
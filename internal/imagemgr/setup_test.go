@@ -0,0 +1,81 @@
+package imagemgr
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSetupDigestDependsOnBaseCommandsPolicyAndNamespace(t *testing.T) {
+	t.Parallel()
+
+	const base = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	setup := []string{"apt-get update", "apt-get install -y jq"}
+	first := SetupDigest(base, "policy-a", "default", setup)
+	if first != SetupDigest(base, "policy-a", "default", []string{"apt-get update", "apt-get install -y jq"}) {
+		t.Fatal("expected setup digest to be deterministic")
+	}
+	if first == SetupDigest(base, "policy-a", "default", []string{"apt-get install -y jq", "apt-get update"}) {
+		t.Fatal("expected command order to change the setup digest")
+	}
+	if first == SetupDigest(base, "policy-a", "default", []string{"apt-get update apt-get install -y jq"}) {
+		t.Fatal("expected command boundaries to change the setup digest")
+	}
+	if first == SetupDigest("sha256:"+strings.Repeat("f", 64), "policy-a", "default", setup) {
+		t.Fatal("expected the base digest to change the setup digest")
+	}
+	if first == SetupDigest(base, "policy-b", "default", setup) {
+		t.Fatal("expected the policy hash to change the setup digest")
+	}
+	if first == SetupDigest(base, "policy-a", "team-a", setup) {
+		t.Fatal("expected the namespace to change the setup digest")
+	}
+}
+
+func TestStoreAndLookupSetupImage(t *testing.T) {
+	t.Parallel()
+
+	manager := newTestManager(t, func(_ context.Context, _ string) (io.ReadCloser, OCIConfig, error) {
+		return io.NopCloser(bytes.NewReader(testRootFSTar(t))), OCIConfig{Workdir: "/workspace"}, nil
+	})
+	if _, err := manager.Ensure(context.Background(), testImageRef); err != nil {
+		t.Fatalf("Ensure returned error: %v", err)
+	}
+	digest := SetupDigest("sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", "policy", "default", []string{"make deps"})
+
+	if _, found, err := manager.LookupSetupImage(context.Background(), digest); err != nil || found {
+		t.Fatalf("expected no setup image before it is stored, got found=%v err=%v", found, err)
+	}
+	stored, err := manager.StoreSetupImage(context.Background(), SetupImageRequest{
+		BaseRef: testImageRef,
+		Digest:  digest,
+		RootFS:  bytes.NewReader(testRootFSTar(t)),
+	})
+	if err != nil {
+		t.Fatalf("StoreSetupImage returned error: %v", err)
+	}
+	if got, want := stored.Ref, "ghcr.io/buildkite/cleanroom-base/alpine+setup@"+digest; got != want {
+		t.Fatalf("unexpected ref: got %q want %q", got, want)
+	}
+	if stored.OCIConfig.Workdir != "/workspace" {
+		t.Fatalf("expected base OCI config to carry over, got %+v", stored.OCIConfig)
+	}
+
+	found, ok, err := manager.LookupSetupImage(context.Background(), digest)
+	if err != nil || !ok {
+		t.Fatalf("expected stored setup image to be found, got ok=%v err=%v", ok, err)
+	}
+	if found.RootFSPath != stored.RootFSPath {
+		t.Fatalf("unexpected rootfs path %q", found.RootFSPath)
+	}
+
+	if err := os.Remove(stored.RootFSPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := manager.LookupSetupImage(context.Background(), digest); err != nil || ok {
+		t.Fatalf("expected setup image with a missing rootfs to be dropped, got ok=%v err=%v", ok, err)
+	}
+}
@@ -0,0 +1,175 @@
+// Package logging carries the fields that identify a unit of work, such as
+// the request, sandbox and execution IDs, through contexts so every log line
+// written on its behalf can be tied back to it.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// RequestIDHeader carries a request ID between clients and serve. serve
+// keeps an ID the client sent and generates one otherwise.
+const RequestIDHeader = "X-Request-Id"
+
+// Log output formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// New returns a logger writing to w at the given level ("" means info) and
+// format ("" means text).
+func New(w io.Writer, level, format string) (*log.Logger, error) {
+	parsedLevel, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	formatter := log.TextFormatter
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", FormatText:
+	case FormatJSON:
+		formatter = log.JSONFormatter
+	default:
+		return nil, fmt.Errorf("invalid log format %q (expected text or json)", format)
+	}
+	return log.NewWithOptions(w, log.Options{
+		Level:           parsedLevel,
+		Formatter:       formatter,
+		ReportTimestamp: formatter == log.JSONFormatter,
+	}), nil
+}
+
+// ParseLevel parses a level name (debug|info|warn|error); "" means info.
+func ParseLevel(level string) (log.Level, error) {
+	level = strings.TrimSpace(strings.ToLower(level))
+	if level == "" {
+		return log.InfoLevel, nil
+	}
+	parsed, err := log.ParseLevel(level)
+	if err != nil {
+		return 0, fmt.Errorf("invalid log level %q (expected debug, info, warn or error)", level)
+	}
+	return parsed, nil
+}
+
+// Subsystems are the names serve tags its loggers with. Each can be given
+// its own level.
+var Subsystems = []string{"cache-sharing", "config", "darwin-vz", "events", "firecracker", "gateway", "http", "interactive-quic", "log-shipping", "network", "service", "shared-state"}
+
+// ForSubsystem returns base tagged with subsystem=name, at the level levels
+// sets for name, if any. Invalid levels are left to config validation and
+// keep base's level.
+func ForSubsystem(base *log.Logger, name string, levels map[string]string) *log.Logger {
+	logger := base.With("subsystem", name)
+	if raw, ok := levels[name]; ok {
+		if level, err := ParseLevel(raw); err == nil {
+			logger.SetLevel(level)
+		}
+	}
+	return logger
+}
+
+type fieldsKey struct{}
+type requestIDKey struct{}
+
+// WithFields returns ctx with keyvals added to the fields attached to log
+// lines written for it. Later values for a key replace earlier ones.
+func WithFields(ctx context.Context, keyvals ...any) context.Context {
+	if len(keyvals) == 0 {
+		return ctx
+	}
+	existing := Fields(ctx)
+	merged := make([]any, 0, len(existing)+len(keyvals))
+	for i := 0; i+1 < len(existing); i += 2 {
+		if !hasKey(keyvals, existing[i]) {
+			merged = append(merged, existing[i], existing[i+1])
+		}
+	}
+	merged = append(merged, keyvals...)
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+func hasKey(keyvals []any, key any) bool {
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		if keyvals[i] == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Fields returns the fields attached to ctx by WithFields.
+func Fields(ctx context.Context) []any {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsKey{}).([]any)
+	return fields
+}
+
+// Logger returns base with ctx's fields attached, or nil when base is nil.
+func Logger(ctx context.Context, base *log.Logger) *log.Logger {
+	if base == nil {
+		return nil
+	}
+	if fields := Fields(ctx); len(fields) > 0 {
+		return base.With(fields...)
+	}
+	return base
+}
+
+// WithRequestID records id as ctx's request ID and attaches it as the
+// request_id log field.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return ctx
+	}
+	return WithFields(context.WithValue(ctx, requestIDKey{}, id), "request_id", id)
+}
+
+// RequestID returns the ID recorded by WithRequestID, or "".
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID returns a random request ID.
+func NewRequestID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return "req-" + hex.EncodeToString(b[:])
+}
+
+// maxRequestIDLength bounds IDs taken from clients, which end up in every
+// log line for the request.
+const maxRequestIDLength = 128
+
+// SanitizeRequestID returns id if it is safe to log as a request ID, or "".
+func SanitizeRequestID(id string) string {
+	id = strings.TrimSpace(id)
+	if id == "" || len(id) > maxRequestIDLength {
+		return ""
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return ""
+		}
+	}
+	return id
+}
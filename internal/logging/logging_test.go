@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestWithFieldsReplacesKeys(t *testing.T) {
+	t.Parallel()
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	ctx = WithFields(ctx, "sandbox_id", "cr-1", "run_id", "run-1")
+	ctx = WithFields(ctx, "run_id", "run-2")
+
+	want := []any{"request_id", "req-1", "sandbox_id", "cr-1", "run_id", "run-2"}
+	if got := Fields(ctx); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected fields: got %v want %v", got, want)
+	}
+	if got := RequestID(ctx); got != "req-1" {
+		t.Fatalf("unexpected request ID %q", got)
+	}
+	if Logger(ctx, nil) != nil {
+		t.Fatal("expected a nil base logger to stay nil")
+	}
+}
+
+func TestNewJSONLoggerWritesContextFields(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger, err := New(&buf, "debug", "json")
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	ctx := WithFields(WithRequestID(context.Background(), "req-1"), "execution_id", "exec-1")
+	Logger(ctx, logger).Info("execution created", "tty", false)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("expected a JSON log line, got %q: %v", buf.String(), err)
+	}
+	if line["msg"] != "execution created" || line["request_id"] != "req-1" || line["execution_id"] != "exec-1" || line["tty"] != false {
+		t.Fatalf("unexpected log line: %v", line)
+	}
+	if _, ok := line["time"]; !ok {
+		t.Fatalf("expected JSON log line to carry a timestamp: %v", line)
+	}
+
+	if _, err := New(&buf, "loud", ""); err == nil {
+		t.Fatal("expected an unknown level to be rejected")
+	}
+	if _, err := New(&buf, "", "xml"); err == nil {
+		t.Fatal("expected an unknown format to be rejected")
+	}
+}
+
+func TestSanitizeRequestID(t *testing.T) {
+	t.Parallel()
+
+	for id, want := range map[string]string{
+		" build-7:step_2.a ": "build-7:step_2.a",
+		"":                   "",
+		"has space":          "",
+		"quote\"":            "",
+		string(make([]byte, maxRequestIDLength+1)): "",
+	} {
+		if got := SanitizeRequestID(id); got != want {
+			t.Fatalf("SanitizeRequestID(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestForSubsystemSetsLevel(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	base, err := New(&buf, "info", "json")
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	levels := map[string]string{"gateway": "debug", "http": "error"}
+	ForSubsystem(base, "gateway", levels).Debug("gateway detail")
+	ForSubsystem(base, "http", levels).Warn("http warning")
+	ForSubsystem(base, "service", levels).Debug("service detail")
+	base.Debug("base detail")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected exactly one JSON log line, got %q: %v", buf.String(), err)
+	}
+	if entry["msg"] != "gateway detail" || entry["subsystem"] != "gateway" {
+		t.Fatalf("unexpected log entry: %v", entry)
+	}
+}
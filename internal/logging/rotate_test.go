@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestRotatingFileKeepsMaxFiles(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "logs", "serve.log")
+	file, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile returned error: %v", err)
+	}
+	defer file.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("Write returned error: %v", err)
+		}
+	}
+
+	want := map[string]string{
+		"serve.log":   "fourth\n",
+		"serve.log.1": "third\n",
+		"serve.log.2": "second\n",
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	if got, wantNames := names, []string{"serve.log", "serve.log.1", "serve.log.2"}; !reflect.DeepEqual(got, wantNames) {
+		t.Fatalf("unexpected files: got %v want %v", got, wantNames)
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(path), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Fatalf("unexpected %s content: %q", name, data)
+		}
+	}
+}
+
+func TestRotatingFileAppendsToExistingFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "serve.log")
+	if err := os.WriteFile(path, []byte("earlier\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	file, err := OpenRotatingFile(path, 1024, 1)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile returned error: %v", err)
+	}
+	if _, err := file.Write([]byte("later\n")); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "earlier\nlater\n" {
+		t.Fatalf("unexpected content: %q", data)
+	}
+	if _, err := file.Write([]byte("closed\n")); err == nil {
+		t.Fatal("expected a write after Close to fail")
+	}
+}
@@ -0,0 +1,239 @@
+// Package logship ships each execution's full event stream, output
+// included, to long-term storage once the execution finishes, so job logs
+// outlive the host's run directories and serve's in-memory tails.
+package logship
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/charmbracelet/log"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	queueLength    = 16384
+	uploadAttempts = 5
+	uploadTimeout  = 5 * time.Minute
+	spoolSuffix    = ".ndjson"
+)
+
+// Shipper spools execution events to a file per execution and uploads the
+// file to a Sink when the execution's exit event arrives. Each line of the
+// file is one ExecutionStreamEvent as JSON. Record never blocks: events are
+// queued for a background goroutine, and dropped and counted once the queue
+// is full. Files that fail to upload stay in the spool directory and are
+// retried the next time a Shipper starts on it.
+type Shipper struct {
+	sink   Sink
+	dir    string
+	logger *log.Logger
+
+	queue   chan *cleanroomv1.ExecutionStreamEvent
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+	uploads sync.WaitGroup
+	dropped atomic.Int64
+
+	// retryDelay is the wait before the second upload attempt; it doubles
+	// after each failure.
+	retryDelay time.Duration
+}
+
+// New starts a Shipper spooling under dir, and queues uploads of any logs an
+// earlier Shipper left there.
+func New(sink Sink, dir string, logger *log.Logger) (*Shipper, error) {
+	if sink == nil {
+		return nil, errors.New("log shipping requires a sink")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create log spool directory: %w", err)
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	s := &Shipper{
+		sink:       sink,
+		dir:        dir,
+		logger:     logger,
+		queue:      make(chan *cleanroomv1.ExecutionStreamEvent, queueLength),
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+		retryDelay: 2 * time.Second,
+	}
+	leftovers, err := filepath.Glob(filepath.Join(dir, "*", "*"+spoolSuffix))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range leftovers {
+		s.upload(path)
+	}
+	// Sandbox directories are only removed here, once empty, so the writer
+	// never races a removal.
+	if entries, err := os.ReadDir(dir); err == nil {
+		for _, entry := range entries {
+			_ = os.Remove(filepath.Join(dir, entry.Name()))
+		}
+	}
+	go s.run()
+	return s, nil
+}
+
+// Record queues event for its execution's log. The event must not be
+// modified afterwards.
+func (s *Shipper) Record(event *cleanroomv1.ExecutionStreamEvent) {
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+	select {
+	case s.queue <- event:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Dropped returns how many events were left out of shipped logs.
+func (s *Shipper) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close writes out queued events, uploads the logs of executions that are
+// still running as they stand, and waits for uploads in flight. Uploads
+// that fail after Close are not retried until the next start.
+func (s *Shipper) Close() error {
+	s.once.Do(func() { close(s.done) })
+	<-s.stopped
+	s.uploads.Wait()
+	if dropped := s.dropped.Load(); dropped > 0 {
+		s.logger.Warn("execution log events were dropped", "count", dropped)
+	}
+	return nil
+}
+
+func (s *Shipper) run() {
+	defer close(s.stopped)
+	files := map[string]*os.File{}
+	finish := func(path string) {
+		if err := files[path].Close(); err != nil {
+			s.logger.Warn("close execution log", "path", path, "error", err)
+		}
+		delete(files, path)
+		s.upload(path)
+	}
+	write := func(event *cleanroomv1.ExecutionStreamEvent) {
+		path := s.spoolPath(event.GetSandboxId(), event.GetExecutionId())
+		f, ok := files[path]
+		if !ok {
+			if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+				s.logger.Warn("create execution log", "path", path, "error", err)
+				return
+			}
+			var err error
+			if f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600); err != nil {
+				s.logger.Warn("create execution log", "path", path, "error", err)
+				return
+			}
+			files[path] = f
+		}
+		line, err := protojson.Marshal(event)
+		if err != nil {
+			return
+		}
+		if _, err := f.Write(append(line, '\n')); err != nil {
+			s.logger.Warn("write execution log", "path", path, "error", err)
+		}
+		if _, ok := event.GetPayload().(*cleanroomv1.ExecutionStreamEvent_Exit); ok {
+			finish(path)
+		}
+	}
+
+	for {
+		select {
+		case event := <-s.queue:
+			write(event)
+		case <-s.done:
+			for {
+				select {
+				case event := <-s.queue:
+					write(event)
+				default:
+					for path := range files {
+						finish(path)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// spoolPath keeps IDs from escaping the spool directory; the service only
+// issues IDs without separators.
+func (s *Shipper) spoolPath(sandboxID, executionID string) string {
+	clean := func(id string) string {
+		id = strings.NewReplacer("/", "_", "\\", "_").Replace(id)
+		if id == "" || id == "." || id == ".." {
+			return "_"
+		}
+		return id
+	}
+	return filepath.Join(s.dir, clean(sandboxID), clean(executionID)+spoolSuffix)
+}
+
+// upload ships the spool file at path as <sandbox_id>/<execution_id>.ndjson
+// in the background, removing it once stored.
+func (s *Shipper) upload(path string) {
+	name := filepath.Base(filepath.Dir(path)) + "/" + filepath.Base(path)
+	s.uploads.Add(1)
+	go func() {
+		defer s.uploads.Done()
+		delay := s.retryDelay
+		var err error
+		for attempt := 1; attempt <= uploadAttempts; attempt++ {
+			if err = s.uploadOnce(path, name); err == nil {
+				if err := os.Remove(path); err != nil {
+					s.logger.Warn("remove shipped execution log", "path", path, "error", err)
+				}
+				s.logger.Debug("shipped execution log", "name", name)
+				return
+			}
+			if attempt == uploadAttempts {
+				break
+			}
+			select {
+			case <-time.After(delay):
+				delay *= 2
+			case <-s.done:
+				// Shutting down: leave the rest to the next start.
+				attempt = uploadAttempts
+			}
+		}
+		s.logger.Warn("ship execution log failed; kept for the next start", "name", name, "path", path, "error", err)
+	}()
+}
+
+func (s *Shipper) uploadOnce(path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), uploadTimeout)
+	defer cancel()
+	return s.sink.Upload(ctx, name, f, info.Size())
+}
@@ -0,0 +1,211 @@
+package logship
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/objectstore"
+)
+
+const (
+	// GCSCredentialHost is the host a Cloud Storage OAuth access token is
+	// resolved for.
+	GCSCredentialHost = "storage.googleapis.com"
+
+	gcsEndpoint      = "https://storage.googleapis.com"
+	ndjsonType       = "application/x-ndjson"
+	maxErrorBodySize = 4096
+)
+
+// CredentialResolver returns the credential to present to a host, or "" for
+// none. gateway.CredentialProvider satisfies it.
+type CredentialResolver = objectstore.CredentialResolver
+
+// Sink stores a finished log under name. Upload may be called again for the
+// same name after a failure, so it must overwrite rather than append.
+type Sink interface {
+	Upload(ctx context.Context, name string, body io.ReadSeeker, size int64) error
+}
+
+// Options configure NewSink.
+type Options struct {
+	// Region is the S3 bucket's region; "" is us-east-1.
+	Region string
+	// Endpoint replaces the AWS endpoint for S3-compatible stores such as
+	// MinIO. Buckets are then addressed path-style.
+	Endpoint    string
+	Credentials CredentialResolver
+	Client      *http.Client
+}
+
+// ValidateURL checks that rawURL names a supported sink: s3://bucket[/prefix],
+// gs://bucket[/prefix], or an http(s) endpoint.
+func ValidateURL(rawURL string) error {
+	_, err := parseSinkURL(rawURL)
+	return err
+}
+
+func parseSinkURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("invalid log sink URL: %w", err)
+	}
+	switch u.Scheme {
+	case "s3", "gs", "https", "http":
+	default:
+		return nil, fmt.Errorf("invalid log sink URL %q: expected s3://bucket/prefix, gs://bucket/prefix or https://host/path", rawURL)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid log sink URL %q: missing bucket or host", rawURL)
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("invalid log sink URL %q: credentials in the URL are not allowed", rawURL)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("invalid log sink URL %q: query and fragment are not supported", rawURL)
+	}
+	return u, nil
+}
+
+// NewSink returns the sink rawURL names. Objects are stored under the URL's
+// path as a prefix; an http(s) endpoint receives a PUT to <url>/<name>.
+func NewSink(rawURL string, opts Options) (Sink, error) {
+	u, err := parseSinkURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	prefix := strings.Trim(u.Path, "/")
+	switch u.Scheme {
+	case "s3":
+		store, err := objectstore.NewS3(u.Host, prefix, objectstore.Options{
+			Region:      opts.Region,
+			Endpoint:    opts.Endpoint,
+			Credentials: opts.Credentials,
+			Client:      client,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return storeSink{store}, nil
+	case "gs":
+		return &gcsSink{
+			endpoint:    gcsEndpoint,
+			bucket:      u.Host,
+			prefix:      prefix,
+			credentials: opts.Credentials,
+			client:      client,
+		}, nil
+	default:
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		return &httpSink{url: u, credentials: opts.Credentials, client: client}, nil
+	}
+}
+
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "/" + name
+}
+
+func resolve(ctx context.Context, credentials CredentialResolver, host string) (string, error) {
+	if credentials == nil {
+		return "", nil
+	}
+	credential, err := credentials.Resolve(ctx, host)
+	if err != nil {
+		return "", fmt.Errorf("resolve credentials for %s: %w", host, err)
+	}
+	return strings.TrimSpace(credential), nil
+}
+
+// put sends req, whose body is body, and treats any non-2xx reply as an
+// error carrying the start of the response body.
+func put(client *http.Client, req *http.Request, body io.ReadSeeker, size int64) error {
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	req.Body = io.NopCloser(body)
+	req.ContentLength = size
+	req.Header.Set("Content-Type", ndjsonType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Redacted(), resp.Status, strings.TrimSpace(string(detail)))
+}
+
+// storeSink uploads logs as objects in a Store.
+type storeSink struct {
+	store objectstore.Store
+}
+
+func (s storeSink) Upload(ctx context.Context, name string, body io.ReadSeeker, size int64) error {
+	return s.store.Put(ctx, name, body, size)
+}
+
+type httpSink struct {
+	url         *url.URL
+	credentials CredentialResolver
+	client      *http.Client
+}
+
+func (s *httpSink) Upload(ctx context.Context, name string, body io.ReadSeeker, size int64) error {
+	target := *s.url
+	target.Path = s.url.Path + "/" + name
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target.String(), nil)
+	if err != nil {
+		return err
+	}
+	token, err := resolve(ctx, s.credentials, s.url.Hostname())
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return put(s.client, req, body, size)
+}
+
+type gcsSink struct {
+	endpoint    string
+	bucket      string
+	prefix      string
+	credentials CredentialResolver
+	client      *http.Client
+}
+
+// Upload uses the XML API, which accepts an OAuth access token as a bearer
+// token on a plain PUT.
+func (s *gcsSink) Upload(ctx context.Context, name string, body io.ReadSeeker, size int64) error {
+	target := s.endpoint + "/" + s.bucket + "/" + objectstore.EscapeKey(joinKey(s.prefix, name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, nil)
+	if err != nil {
+		return err
+	}
+	token, err := resolve(ctx, s.credentials, GCSCredentialHost)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return errors.New("no credentials for " + GCSCredentialHost)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return put(s.client, req, body, size)
+}
@@ -0,0 +1,164 @@
+// Package objectstore stores files by key in a local directory or an S3
+// bucket, so records serve writes can outlive the host that wrote them.
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CredentialResolver returns the credential to present to a host, or "" for
+// none. gateway.CredentialProvider satisfies it.
+type CredentialResolver interface {
+	Resolve(ctx context.Context, upstreamHost string) (string, error)
+}
+
+// Store holds objects under slash-separated keys.
+type Store interface {
+	// Put stores body, which is size bytes long, under key, replacing any
+	// object already there.
+	Put(ctx context.Context, key string, body io.ReadSeeker, size int64) error
+	// Get opens the object under key. A missing object returns an error
+	// satisfying errors.Is(err, fs.ErrNotExist).
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns the keys starting with prefix, sorted.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Options configure Open.
+type Options struct {
+	// Region is the S3 bucket's region; "" is us-east-1.
+	Region string
+	// Endpoint replaces the AWS endpoint for S3-compatible stores such as
+	// MinIO. Buckets are then addressed path-style.
+	Endpoint    string
+	Credentials CredentialResolver
+	Client      *http.Client
+}
+
+// ValidateURL checks that rawURL names a supported store: s3://bucket[/prefix],
+// or an absolute directory, optionally as file:///path.
+func ValidateURL(rawURL string) error {
+	_, err := Open(rawURL, Options{})
+	return err
+}
+
+// Open returns the store rawURL names. Keys are stored under the URL's path.
+func Open(rawURL string, opts Options) (Store, error) {
+	raw := strings.TrimSpace(rawURL)
+	if strings.HasPrefix(raw, "/") {
+		return &Dir{Root: filepath.Clean(raw)}, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid store URL: %w", err)
+	}
+	switch u.Scheme {
+	case "file":
+		if u.Host != "" || !strings.HasPrefix(u.Path, "/") {
+			return nil, fmt.Errorf("invalid store URL %q: expected file:///absolute/path", rawURL)
+		}
+		return &Dir{Root: filepath.Clean(u.Path)}, nil
+	case "s3":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid store URL %q: missing bucket", rawURL)
+		}
+		if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+			return nil, fmt.Errorf("invalid store URL %q: expected s3://bucket/prefix", rawURL)
+		}
+		return NewS3(u.Host, strings.Trim(u.Path, "/"), opts)
+	default:
+		return nil, fmt.Errorf("invalid store URL %q: expected s3://bucket/prefix or an absolute directory", rawURL)
+	}
+}
+
+// Dir is a Store in a local directory, such as a shared network mount.
+// Keys map to paths under Root.
+type Dir struct {
+	Root string
+}
+
+func (d *Dir) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if key == "" || clean == "/" || clean != "/"+key {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(d.Root, filepath.FromSlash(clean)), nil
+}
+
+func (d *Dir) Put(_ context.Context, key string, body io.ReadSeeker, _ int64) error {
+	p, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func (d *Dir) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	p, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (d *Dir) List(_ context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(d.Root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && p == d.Root {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			return nil
+		}
+		rel, err := filepath.Rel(d.Root, p)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
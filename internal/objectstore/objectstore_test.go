@@ -0,0 +1,194 @@
+package objectstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type staticCredentials map[string]string
+
+func (c staticCredentials) Resolve(_ context.Context, host string) (string, error) {
+	return c[host], nil
+}
+
+func TestOpenParsesStoreURLs(t *testing.T) {
+	t.Parallel()
+
+	for _, valid := range []string{"/mnt/runs", "file:///mnt/runs", "s3://bucket", "s3://bucket/runs/host-1"} {
+		if err := ValidateURL(valid); err != nil {
+			t.Errorf("expected %q to be valid, got %v", valid, err)
+		}
+	}
+	for _, invalid := range []string{"runs", "gs://bucket", "file://host/runs", "s3:///runs", "s3://bucket?x=1"} {
+		if err := ValidateURL(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestDirRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	store := &Dir{Root: t.TempDir()}
+	ctx := context.Background()
+	if keys, err := store.List(ctx, ""); err != nil || len(keys) != 0 {
+		t.Fatalf("expected an empty listing, got %v (%v)", keys, err)
+	}
+	for _, key := range []string{"run-b/a.json", "run-a/diagnostics/console.log", "run-a/a.json"} {
+		if err := store.Put(ctx, key, strings.NewReader(key), int64(len(key))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keys, err := store.List(ctx, "run-a/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"run-a/a.json", "run-a/diagnostics/console.log"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("expected %v, got %v", want, keys)
+	}
+	rc, err := store.Get(ctx, "run-b/a.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "run-b/a.json" {
+		t.Fatalf("unexpected content %q", data)
+	}
+	if _, err := store.Get(ctx, "run-c/a.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist, got %v", err)
+	}
+	if err := store.Put(ctx, "../escape", strings.NewReader(""), 0); err == nil {
+		t.Fatal("expected a key escaping the root to be rejected")
+	}
+}
+
+// fakeS3 serves path-style requests for one bucket from memory, paging
+// listings one key at a time.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]string
+	headers []http.Header
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.headers = append(f.headers, r.Header.Clone())
+	key, ok := strings.CutPrefix(r.URL.Path, "/bucket/")
+	switch {
+	case r.Method == http.MethodPut && ok:
+		body, _ := io.ReadAll(r.Body)
+		sum := sha256.Sum256(body)
+		if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+			http.Error(w, "bad payload hash", http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = string(body)
+	case r.Method == http.MethodGet && ok:
+		body, found := f.objects[key]
+		if !found {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		_, _ = io.WriteString(w, body)
+	case r.Method == http.MethodGet && r.URL.Path == "/bucket" && r.URL.Query().Get("list-type") == "2":
+		var keys []string
+		for key := range f.objects {
+			if strings.HasPrefix(key, r.URL.Query().Get("prefix")) && key > r.URL.Query().Get("continuation-token") {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			_, _ = io.WriteString(w, `<ListBucketResult><IsTruncated>false</IsTruncated></ListBucketResult>`)
+			return
+		}
+		first := keys[0]
+		for _, key := range keys {
+			first = min(first, key)
+		}
+		fmt.Fprintf(w, `<ListBucketResult><Contents><Key>%s</Key></Contents><IsTruncated>%t</IsTruncated><NextContinuationToken>%s</NextContinuationToken></ListBucketResult>`, first, len(keys) > 1, first)
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func TestS3RoundTripPathStyle(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeS3{objects: map[string]string{"other/x": "not ours"}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store, err := NewS3("bucket", "runs", Options{
+		Region:      "eu-west-1",
+		Endpoint:    server.URL,
+		Credentials: staticCredentials{S3CredentialHost: "AKIDEXAMPLE:secret:session"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.now = func() time.Time { return time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	for _, key := range []string{"run-1/run-manifest.json", "run-2/diagnostics/serial console.log"} {
+		if err := store.Put(ctx, key, strings.NewReader("data "+key), int64(len("data "+key))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keys, err := store.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"run-1/run-manifest.json", "run-2/diagnostics/serial console.log"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("expected %v, got %v", want, keys)
+	}
+	rc, err := store.Get(ctx, "run-2/diagnostics/serial console.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "data run-2/diagnostics/serial console.log" {
+		t.Fatalf("unexpected content %q", data)
+	}
+	if _, err := store.Get(ctx, "run-3/run-manifest.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist, got %v", err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	wantAuth := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20261016/eu-west-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token, Signature="
+	for _, h := range fake.headers {
+		if auth := h.Get("Authorization"); !strings.HasPrefix(auth, wantAuth) || len(auth) != len(wantAuth)+64 {
+			t.Fatalf("unexpected authorization %q", auth)
+		}
+		if h.Get("X-Amz-Security-Token") != "session" {
+			t.Fatalf("expected the session token, got %v", h)
+		}
+	}
+}
+
+func TestS3RequiresCredentials(t *testing.T) {
+	t.Parallel()
+
+	store, err := NewS3("bucket", "", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(context.Background(), "k", strings.NewReader(""), 0); err == nil || !strings.Contains(err.Error(), S3CredentialHost) {
+		t.Fatalf("expected a missing credentials error, got %v", err)
+	}
+}
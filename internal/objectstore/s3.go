@@ -0,0 +1,291 @@
+package objectstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// S3CredentialHost is the host S3 credentials are resolved for, custom
+	// endpoints included. The credential is
+	// ACCESS_KEY_ID:SECRET_ACCESS_KEY[:SESSION_TOKEN].
+	S3CredentialHost = "s3.amazonaws.com"
+
+	defaultS3Region  = "us-east-1"
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	maxErrorBodySize = 4096
+)
+
+// S3 is a Store in an S3 bucket, or an S3-compatible store, under a key
+// prefix. Requests are signed with AWS Signature Version 4.
+type S3 struct {
+	bucket      string
+	prefix      string
+	region      string
+	endpoint    *url.URL
+	credentials CredentialResolver
+	client      *http.Client
+	now         func() time.Time
+}
+
+// NewS3 returns a Store for bucket, keeping objects under prefix.
+func NewS3(bucket, prefix string, opts Options) (*S3, error) {
+	s := &S3{
+		bucket:      bucket,
+		prefix:      strings.Trim(prefix, "/"),
+		region:      opts.Region,
+		credentials: opts.Credentials,
+		client:      opts.Client,
+		now:         time.Now,
+	}
+	if s.region == "" {
+		s.region = defaultS3Region
+	}
+	if s.client == nil {
+		s.client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	if endpoint := strings.TrimSpace(opts.Endpoint); endpoint != "" {
+		e, err := url.Parse(endpoint)
+		if err != nil || (e.Scheme != "https" && e.Scheme != "http") || e.Host == "" {
+			return nil, fmt.Errorf("invalid S3 endpoint %q: expected https://host[:port]", opts.Endpoint)
+		}
+		s.endpoint = e
+	}
+	return s, nil
+}
+
+func (s *S3) key(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return s.prefix + "/" + key
+}
+
+// url addresses objectKey, or the bucket itself when objectKey is "".
+func (s *S3) url(objectKey string, query url.Values) *url.URL {
+	var u url.URL
+	if s.endpoint != nil {
+		u = *s.endpoint
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket
+		if objectKey != "" {
+			u.Path += "/" + objectKey
+		}
+	} else {
+		u = url.URL{
+			Scheme: "https",
+			Host:   fmt.Sprintf("%s.s3.%s.amazonaws.com", s.bucket, s.region),
+			Path:   "/" + objectKey,
+		}
+	}
+	u.RawPath = EscapeKey(u.Path)
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+func (s *S3) do(ctx context.Context, method string, u *url.URL, body io.ReadSeeker, size int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	credential := ""
+	if s.credentials != nil {
+		if credential, err = s.credentials.Resolve(ctx, S3CredentialHost); err != nil {
+			return nil, fmt.Errorf("resolve credentials for %s: %w", S3CredentialHost, err)
+		}
+	}
+	keyID, secret, ok := strings.Cut(strings.TrimSpace(credential), ":")
+	if !ok || keyID == "" || secret == "" {
+		return nil, fmt.Errorf("no credentials for %s: expected ACCESS_KEY_ID:SECRET_ACCESS_KEY[:SESSION_TOKEN]", S3CredentialHost)
+	}
+	secret, sessionToken, _ := strings.Cut(secret, ":")
+
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		hash := sha256.New()
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(hash, body); err != nil {
+			return nil, err
+		}
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		payloadHash = hex.EncodeToString(hash.Sum(nil))
+		req.Body = io.NopCloser(body)
+		req.ContentLength = size
+	}
+	signS3Request(req, payloadHash, s.region, keyID, secret, sessionToken, s.now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && method == http.MethodGet {
+		return nil, fmt.Errorf("%s: %w", u.Path, fs.ErrNotExist)
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	return nil, fmt.Errorf("%s %s: %s: %s", method, u.Redacted(), resp.Status, strings.TrimSpace(string(detail)))
+}
+
+func (s *S3) Put(ctx context.Context, key string, body io.ReadSeeker, size int64) error {
+	resp, err := s.do(ctx, http.MethodPut, s.url(s.key(key), nil), body, size)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.url(s.key(key), nil), nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]string, error) {
+	full := s.key(prefix)
+	if s.prefix != "" && prefix == "" {
+		full = s.prefix + "/"
+	}
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {full}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, s.url("", query), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		var page listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("parse bucket listing: %w", err)
+		}
+		for _, object := range page.Contents {
+			key := object.Key
+			if s.prefix != "" {
+				key = strings.TrimPrefix(key, s.prefix+"/")
+			}
+			keys = append(keys, key)
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// signS3Request adds AWS Signature Version 4 headers to req, signing host
+// and the x-amz-* headers it sets. The query must already be canonical.
+func signS3Request(req *http.Request, payloadHash, region, keyID, secret, sessionToken string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	headers := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	values := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+		headers = append(headers, "x-amz-security-token")
+		values["x-amz-security-token"] = sessionToken
+	}
+
+	var canonical strings.Builder
+	canonical.WriteString(req.Method + "\n" + req.URL.EscapedPath() + "\n" + req.URL.RawQuery + "\n")
+	for _, h := range headers {
+		canonical.WriteString(h + ":" + values[h] + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+	canonical.WriteString("\n" + signedHeaders + "\n" + payloadHash)
+
+	scope := day + "/" + region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonical.String()))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := []byte("AWS4" + secret)
+	for _, part := range []string{day, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", keyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query sorted by key with every reserved byte
+// escaped, as Signature Version 4 requires.
+func canonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, escape(name, false)+"="+escape(value, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// EscapeKey percent-encodes every byte of an object key except unreserved
+// characters and slashes, as S3 signatures and GCS object names expect.
+func EscapeKey(key string) string {
+	return escape(key, true)
+}
+
+func escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~", c) >= 0 || keepSlash && c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -41,6 +41,22 @@ func ImageCacheDir() (string, error) {
 	return filepath.Join(base, "images"), nil
 }
 
+func OCIRegistryCacheDir() (string, error) {
+	base, err := CacheBaseDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "oci-registry"), nil
+}
+
+func PackageCacheDir() (string, error) {
+	base, err := CacheBaseDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "packages"), nil
+}
+
 func ImageMetadataDBPath() (string, error) {
 	base, err := StateBaseDir()
 	if err != nil {
@@ -0,0 +1,19 @@
+package paths
+
+import (
+	"path/filepath"
+)
+
+// LogSpoolDir resolves the directory where serve spools execution logs until
+// they are shipped.
+// Preference order:
+// 1. $XDG_STATE_HOME/cleanroom/log-spool
+// 2. ~/.local/state/cleanroom/log-spool
+// 3. $XDG_RUNTIME_DIR/cleanroom/log-spool
+func LogSpoolDir() (string, error) {
+	base, err := StateBaseDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "log-spool"), nil
+}
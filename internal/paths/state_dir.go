@@ -47,3 +47,22 @@ func TLSDir() (string, error) {
 	}
 	return filepath.Join(home, ".config", "cleanroom", "tls"), nil
 }
+
+// UsageDBPath returns the database where serve keeps usage records.
+func UsageDBPath() (string, error) {
+	base, err := StateBaseDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "usage", "usage.db"), nil
+}
+
+// ScheduleDBPath returns the database where serve keeps schedules and the
+// outcome of their runs.
+func ScheduleDBPath() (string, error) {
+	base, err := StateBaseDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "schedules", "schedules.db"), nil
+}
@@ -0,0 +1,163 @@
+package policy
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// Statuses an exit code rule can give an execution.
+const (
+	ExitStatusSucceeded  = "succeeded"
+	ExitStatusFailed     = "failed"
+	ExitStatusSkipped    = "skipped"
+	ExitStatusSoftFailed = "soft_failed"
+)
+
+const (
+	maxExitCodeRules           = 32
+	maxExitCodeAnnotations     = 32
+	maxExitCodeAnnotationBytes = 256
+)
+
+// annotationKeyPattern matches the server's rule for execution annotation
+// keys.
+var annotationKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+
+// ExitCodeRule says how the server finalizes an execution whose command
+// exits with one of Codes: with Status instead of the one the code gives,
+// marked as worth retrying, and with Annotations set on it.
+type ExitCodeRule struct {
+	Codes       []int             `json:"codes"`
+	Status      string            `json:"status,omitempty"`
+	Retriable   bool              `json:"retriable,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type rawExitCodeRule struct {
+	Codes       []int             `yaml:"codes"`
+	Status      string            `yaml:"status"`
+	Retriable   bool              `yaml:"retriable"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+// ExitCodeRule returns the rule for an execution that exited with code, or
+// nil when the policy has none.
+func (p *CompiledPolicy) ExitCodeRule(code int) *ExitCodeRule {
+	if p == nil {
+		return nil
+	}
+	for i := range p.ExitCodes {
+		if slices.Contains(p.ExitCodes[i].Codes, code) {
+			return &p.ExitCodes[i]
+		}
+	}
+	return nil
+}
+
+// compileExitCodes validates exit code rules and sorts them, and their
+// codes, so equivalent policies hash the same. It returns nil when there
+// are none so such policies keep their hash.
+func compileExitCodes(field string, rules []ExitCodeRule) ([]ExitCodeRule, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	if len(rules) > maxExitCodeRules {
+		return nil, fmt.Errorf("%s has %d rules, at most %d are supported", field, len(rules), maxExitCodeRules)
+	}
+	seen := map[int]int{}
+	out := make([]ExitCodeRule, 0, len(rules))
+	for i, rule := range rules {
+		if len(rule.Codes) == 0 {
+			return nil, fmt.Errorf("invalid %s[%d]: codes must not be empty", field, i)
+		}
+		codes := make([]int, 0, len(rule.Codes))
+		for _, code := range rule.Codes {
+			if code < 0 || code > 255 {
+				return nil, fmt.Errorf("invalid %s[%d]: exit code %d is outside 0-255", field, i, code)
+			}
+			if prev, ok := seen[code]; ok && prev != i {
+				return nil, fmt.Errorf("invalid %s[%d]: exit code %d is already mapped by %s[%d]", field, i, code, field, prev)
+			}
+			seen[code] = i
+			if !slices.Contains(codes, code) {
+				codes = append(codes, code)
+			}
+		}
+		sort.Ints(codes)
+		status := strings.TrimSpace(strings.ToLower(rule.Status))
+		switch status {
+		case "", ExitStatusSucceeded, ExitStatusFailed, ExitStatusSkipped, ExitStatusSoftFailed:
+		default:
+			return nil, fmt.Errorf("invalid %s[%d].status %q: expected succeeded, failed, skipped or soft_failed", field, i, rule.Status)
+		}
+		if len(rule.Annotations) > maxExitCodeAnnotations {
+			return nil, fmt.Errorf("invalid %s[%d].annotations: at most %d allowed, got %d", field, i, maxExitCodeAnnotations, len(rule.Annotations))
+		}
+		for key, value := range rule.Annotations {
+			if !annotationKeyPattern.MatchString(key) {
+				return nil, fmt.Errorf("invalid %s[%d].annotations key %q: must be 1-63 letters, digits, '.', '_' or '-', starting with a letter or digit", field, i, key)
+			}
+			if value == "" || len(value) > maxExitCodeAnnotationBytes {
+				return nil, fmt.Errorf("invalid %s[%d].annotations %q: value must be 1-%d bytes", field, i, key, maxExitCodeAnnotationBytes)
+			}
+		}
+		if status == "" && !rule.Retriable && len(rule.Annotations) == 0 {
+			return nil, fmt.Errorf("invalid %s[%d]: set status, retriable or annotations", field, i)
+		}
+		var annotations map[string]string
+		if len(rule.Annotations) > 0 {
+			annotations = maps.Clone(rule.Annotations)
+		}
+		out = append(out, ExitCodeRule{Codes: codes, Status: status, Retriable: rule.Retriable, Annotations: annotations})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Codes[0] < out[j].Codes[0] })
+	return out, nil
+}
+
+func exitCodeRulesFromRaw(raw []rawExitCodeRule) []ExitCodeRule {
+	out := make([]ExitCodeRule, 0, len(raw))
+	for _, rule := range raw {
+		out = append(out, ExitCodeRule(rule))
+	}
+	return out
+}
+
+func exitCodeRulesToProto(rules []ExitCodeRule) []*cleanroomv1.PolicyExitCodeRule {
+	var out []*cleanroomv1.PolicyExitCodeRule
+	for _, rule := range rules {
+		codes := make([]int32, 0, len(rule.Codes))
+		for _, code := range rule.Codes {
+			codes = append(codes, int32(code))
+		}
+		out = append(out, &cleanroomv1.PolicyExitCodeRule{
+			Codes:       codes,
+			Status:      rule.Status,
+			Retriable:   rule.Retriable,
+			Annotations: maps.Clone(rule.Annotations),
+		})
+	}
+	return out
+}
+
+func exitCodeRulesFromProto(rules []*cleanroomv1.PolicyExitCodeRule) []ExitCodeRule {
+	out := make([]ExitCodeRule, 0, len(rules))
+	for _, rule := range rules {
+		codes := make([]int, 0, len(rule.GetCodes()))
+		for _, code := range rule.GetCodes() {
+			codes = append(codes, int(code))
+		}
+		out = append(out, ExitCodeRule{
+			Codes:       codes,
+			Status:      rule.GetStatus(),
+			Retriable:   rule.GetRetriable(),
+			Annotations: rule.GetAnnotations(),
+		})
+	}
+	return out
+}
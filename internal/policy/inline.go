@@ -0,0 +1,28 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Inline compiles a policy for runs without a policy file. It boots
+// imageRef, which must be digest-pinned, and denies egress except to the
+// allow entries, each host:port or host:from-to, with several ports joined
+// by commas. The result is checked exactly like a policy file's.
+func Inline(imageRef string, allow []string) (*CompiledPolicy, error) {
+	var raw rawPolicy
+	raw.Version = 1
+	raw.Sandbox.Image.Ref = imageRef
+	raw.Sandbox.Network.Default = "deny"
+	for _, entry := range allow {
+		i := strings.LastIndex(entry, ":")
+		if i <= 0 || i == len(entry)-1 {
+			return nil, fmt.Errorf("allow entry %q is not host:port", entry)
+		}
+		raw.Sandbox.Network.Allow = append(raw.Sandbox.Network.Allow, rawAllowRule{
+			Host:  entry[:i],
+			Ports: strings.Split(entry[i+1:], ","),
+		})
+	}
+	return Compile(raw)
+}
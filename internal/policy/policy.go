@@ -6,10 +6,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"net/netip"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
 	"github.com/buildkite/cleanroom/internal/ociref"
@@ -21,16 +29,72 @@ const (
 	FallbackPolicyPath = ".buildkite/cleanroom.yaml"
 )
 
-type Loader struct{}
+// ErrPolicyNotFound is returned by Loader when neither the directory, its
+// parents up to the repository root, nor the user default has a policy
+// file.
+var ErrPolicyNotFound = errors.New("policy not found")
+
+// Loader finds the policy for a directory: the nearest PrimaryPolicyPath or
+// FallbackPolicyPath at or above it, stopping at the enclosing git
+// checkout's root, and otherwise UserPolicyPath.
+type Loader struct {
+	// UserPolicyPath is the policy used when the repository has none.
+	// Empty disables the user default.
+	UserPolicyPath string
+	// Vars sets policy variables, taking precedence over the environment.
+	Vars map[string]string
+	// LookupEnv reads variables from the environment; nil uses
+	// os.LookupEnv.
+	LookupEnv func(string) (string, bool)
+}
+
+// Candidate is a policy file Loader considers, in order of precedence.
+type Candidate struct {
+	Path   string `json:"path"`
+	Exists bool   `json:"exists"`
+}
+
+// UserPolicyPath returns the default location of the user-level policy:
+// $XDG_CONFIG_HOME/cleanroom/policy.yaml or ~/.config/cleanroom/policy.yaml.
+func UserPolicyPath() (string, error) {
+	configHome := strings.TrimSpace(os.Getenv("XDG_CONFIG_HOME"))
+	if configHome != "" {
+		return filepath.Join(configHome, "cleanroom", "policy.yaml"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "cleanroom", "policy.yaml"), nil
+}
 
 type rawPolicy struct {
 	Version int `yaml:"version"`
-	Sandbox struct {
+	// Variables declares the ${NAME} references the policy may use, each
+	// with a default or null when it must be set.
+	Variables map[string]*string `yaml:"variables"`
+	// variables holds the resolved values of Variables.
+	variables map[string]string
+	Sandbox   struct {
 		Image struct {
 			Ref string `yaml:"ref"`
 		} `yaml:"image"`
-		Services rawServices `yaml:"services"`
-		Network  struct {
+		Services  rawServices  `yaml:"services"`
+		Resources rawResources `yaml:"resources"`
+		Devices   struct {
+			VFIO []string `yaml:"vfio"`
+		} `yaml:"devices"`
+		NestedVirtualization bool              `yaml:"nested_virtualization"`
+		RootFS               rawRootFS         `yaml:"rootfs"`
+		Setup                []string          `yaml:"setup"`
+		ExitCodes            []rawExitCodeRule `yaml:"exit_codes"`
+		Console              struct {
+			Banner             string `yaml:"banner"`
+			MaxSessionSeconds  int64  `yaml:"max_session_seconds"`
+			IdleTimeoutSeconds int64  `yaml:"idle_timeout_seconds"`
+		} `yaml:"console"`
+		Network struct {
 			Default string         `yaml:"default"`
 			Allow   []rawAllowRule `yaml:"allow"`
 		} `yaml:"network"`
@@ -38,39 +102,230 @@ type rawPolicy struct {
 }
 
 type rawServices struct {
-	Docker rawDockerService `yaml:"docker"`
+	Docker      rawDockerService      `yaml:"docker"`
+	OCIRegistry rawOCIRegistryService `yaml:"oci_registry"`
+	Packages    rawPackagesService    `yaml:"packages"`
+}
+
+type rawPackagesService struct {
+	NPM  []string `yaml:"npm"`
+	PyPI []string `yaml:"pypi"`
+	Go   []string `yaml:"go"`
+}
+
+type rawOCIRegistryService struct {
+	Allow []string `yaml:"allow"`
 }
 
 type rawDockerService struct {
-	Required bool `yaml:"required"`
+	Required bool     `yaml:"required"`
+	Preload  []string `yaml:"preload"`
+}
+
+type rawRootFS struct {
+	ReadOnly bool              `yaml:"read_only"`
+	Writable []rawWritablePath `yaml:"writable"`
+}
+
+type rawWritablePath struct {
+	Path    string `yaml:"path"`
+	Disk    bool   `yaml:"disk"`
+	SizeMiB int64  `yaml:"size_mib"`
+}
+
+type rawResources struct {
+	VCPUs       int64 `yaml:"vcpus"`
+	MemoryMiB   int64 `yaml:"memory_mib"`
+	DiskMiB     int64 `yaml:"disk_mib"`
+	EgressMbps  int64 `yaml:"egress_mbps"`
+	IngressMbps int64 `yaml:"ingress_mbps"`
+	DiskIOPS    int64 `yaml:"disk_iops"`
+	DiskMiBps   int64 `yaml:"disk_mibps"`
 }
 
 type rawAllowRule struct {
-	Host  string `yaml:"host"`
-	Ports []int  `yaml:"ports"`
+	Host     string `yaml:"host"`
+	Protocol string `yaml:"protocol"`
+	// Ports holds single ports and "from-to" ranges.
+	Ports []string `yaml:"ports"`
 }
 
 type CompiledPolicy struct {
-	Version        int         `json:"version"`
-	ImageRef       string      `json:"image_ref"`
-	ImageDigest    string      `json:"image_digest"`
-	Services       Services    `json:"services"`
+	Version     int        `json:"version"`
+	ImageRef    string     `json:"image_ref"`
+	ImageDigest string     `json:"image_digest"`
+	Services    Services   `json:"services"`
+	Resources   *Resources `json:"resources,omitempty"`
+	// VFIODevices names host devices, configured by the server operator,
+	// to pass through to the sandbox. Experimental.
+	VFIODevices []string `json:"vfio_devices,omitempty"`
+	// NestedVirtualization gives the guest /dev/kvm. The guest can then run
+	// its own VMs directly on the host's hypervisor, which weakens isolation.
+	NestedVirtualization bool `json:"nested_virtualization,omitempty"`
+	// ReadOnlyRootFS is nil unless the policy boots the root drive
+	// read-only, so executions cannot modify the image's system files.
+	ReadOnlyRootFS *ReadOnlyRootFS `json:"read_only_rootfs,omitempty"`
+	// Setup lists shell commands that prepare the image, run in order
+	// before the first execution. Backends may run them once and reuse the
+	// resulting filesystem for every sandbox with the same image and setup.
+	Setup []string `json:"setup,omitempty"`
+	// ExitCodes maps command exit codes to how the server finalizes
+	// executions that end with them.
+	ExitCodes []ExitCodeRule `json:"exit_codes,omitempty"`
+	// ConsoleBanner is shown at the start of interactive console sessions.
+	// See RenderConsoleBanner.
+	ConsoleBanner string `json:"console_banner,omitempty"`
+	// ConsoleMaxSessionSeconds ends interactive console sessions that run
+	// this long, and ConsoleIdleTimeoutSeconds ends those that get no input
+	// for this long. Zero means no limit.
+	ConsoleMaxSessionSeconds  int64 `json:"console_max_session_seconds,omitempty"`
+	ConsoleIdleTimeoutSeconds int64 `json:"console_idle_timeout_seconds,omitempty"`
+	// Variables holds the value each of the policy file's variables
+	// resolved to, so the hash pins them and a run can be reproduced.
+	Variables map[string]string `json:"variables,omitempty"`
+	// Source records which policy file the policy was compiled from,
+	// relative to its repository root (for example
+	// services/api/cleanroom.yaml), so that the same rules in two
+	// directories of a monorepo hash differently. It is empty for policies
+	// not loaded from a file.
+	Source         string      `json:"source,omitempty"`
 	NetworkDefault string      `json:"network_default"`
 	Allow          []AllowRule `json:"allow"`
 	Hash           string      `json:"hash"`
 }
 
+// ReadOnlyRootFS lists the paths that stay writable when the root drive is
+// read-only, in addition to the /run and /tmp tmpfs mounts every guest has.
+type ReadOnlyRootFS struct {
+	Writable []WritablePath `json:"writable,omitempty"`
+}
+
+// WritablePath is a directory the guest mounts over a read-only rootfs. It
+// is a tmpfs unless Disk is set, in which case it is backed by a scratch
+// disk of SizeMiB so large writes do not use guest memory. Either way its
+// contents are discarded when the sandbox is terminated. The directory must
+// already exist in the image.
+type WritablePath struct {
+	Path    string `json:"path"`
+	Disk    bool   `json:"disk,omitempty"`
+	SizeMiB int64  `json:"size_mib,omitempty"`
+}
+
+// Resources are the VM size a repository asks for. Zero fields leave the
+// server's configured default in place, and servers clamp requests to their
+// configured maxima.
+type Resources struct {
+	VCPUs     int64 `json:"vcpus,omitempty"`
+	MemoryMiB int64 `json:"memory_mib,omitempty"`
+	DiskMiB   int64 `json:"disk_mib,omitempty"`
+	// EgressMbps and IngressMbps limit the bandwidth the sandbox may send
+	// and receive, in megabits per second.
+	EgressMbps  int64 `json:"egress_mbps,omitempty"`
+	IngressMbps int64 `json:"ingress_mbps,omitempty"`
+	// DiskIOPS and DiskMiBps limit the operations and MiB per second each
+	// of the sandbox's drives may serve.
+	DiskIOPS  int64 `json:"disk_iops,omitempty"`
+	DiskMiBps int64 `json:"disk_mibps,omitempty"`
+}
+
 type Services struct {
 	Docker DockerService `json:"docker"`
+	// OCIRegistry is nil unless the policy lets the sandbox pull images
+	// through the gateway's registry proxy.
+	OCIRegistry *OCIRegistryService `json:"oci_registry,omitempty"`
+	// Packages is nil unless the policy lets the sandbox fetch dependencies
+	// through the gateway's package proxy.
+	Packages *PackagesService `json:"packages,omitempty"`
+}
+
+// Package ecosystems the gateway's package proxy serves.
+const (
+	PackageEcosystemNPM  = "npm"
+	PackageEcosystemPyPI = "pypi"
+	PackageEcosystemGo   = "go"
+)
+
+// PackagesService lists, per ecosystem, the package names (npm), project
+// names (pypi) or module paths (go) the gateway will fetch for the sandbox.
+// Entries are path.Match patterns, so "@org/*" allows a whole npm scope; a
+// go pattern matches a module path and everything below it.
+type PackagesService struct {
+	NPM  []string `json:"npm,omitempty"`
+	PyPI []string `json:"pypi,omitempty"`
+	Go   []string `json:"go,omitempty"`
+}
+
+// OCIRegistryService lists the repositories, registry host included, that
+// the gateway will fetch for the sandbox. A digest-pinned entry limits its
+// repository to that manifest and the content it references.
+type OCIRegistryService struct {
+	Allow []string `json:"allow"`
 }
 
 type DockerService struct {
 	Required bool `json:"required"`
+	// Preload lists digest-pinned images loaded into the guest's dockerd
+	// from the host when the sandbox is provisioned.
+	Preload []string `json:"preload,omitempty"`
 }
 
 type AllowRule struct {
-	Host  string `json:"host"`
-	Ports []int  `json:"ports"`
+	Host string `json:"host"`
+	// Protocol limits the rule to "tcp", "udp" or "icmp". Empty allows both
+	// tcp and udp.
+	Protocol string `json:"protocol,omitempty"`
+	Ports    []int  `json:"ports"`
+	// PortRanges are inclusive ranges allowed alongside Ports.
+	PortRanges []PortRange `json:"port_ranges,omitempty"`
+}
+
+type PortRange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// AllowsPort reports whether the rule lets protocol traffic reach port.
+// ICMP has no ports, so an icmp rule matches any port.
+func (r AllowRule) AllowsPort(protocol string, port int) bool {
+	if r.Protocol != "" && r.Protocol != protocol {
+		return false
+	}
+	if r.Protocol == "" && protocol != "tcp" && protocol != "udp" {
+		return false
+	}
+	if protocol == "icmp" {
+		return true
+	}
+	if slices.Contains(r.Ports, port) {
+		return true
+	}
+	for _, pr := range r.PortRanges {
+		if port >= pr.From && port <= pr.To {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the rule as host:ports, with the protocol appended when
+// the rule is not for both tcp and udp, e.g. "ntp.example.com:123/udp" or
+// "10.0.0.1/icmp".
+func (r AllowRule) String() string {
+	if r.Protocol == "icmp" {
+		return r.Host + "/icmp"
+	}
+	ports := make([]string, 0, len(r.Ports)+len(r.PortRanges))
+	for _, port := range r.Ports {
+		ports = append(ports, strconv.Itoa(port))
+	}
+	for _, pr := range r.PortRanges {
+		ports = append(ports, fmt.Sprintf("%d-%d", pr.From, pr.To))
+	}
+	out := r.Host + ":" + strings.Join(ports, ",")
+	if r.Protocol != "" {
+		out += "/" + r.Protocol
+	}
+	return out
 }
 
 func (l Loader) LoadAndCompile(root string) (*CompiledPolicy, string, error) {
@@ -83,33 +338,111 @@ func (l Loader) LoadAndCompile(root string) (*CompiledPolicy, string, error) {
 	if err != nil {
 		return nil, source, err
 	}
+	compiled.Source, err = provenance(source)
+	if err != nil {
+		return nil, source, err
+	}
+	if compiled.Hash, err = hashPolicy(compiled); err != nil {
+		return nil, source, err
+	}
 
 	return compiled, source, nil
 }
 
 func (l Loader) Load(root string) (rawPolicy, string, error) {
-	primary := filepath.Join(root, PrimaryPolicyPath)
-	fallback := filepath.Join(root, FallbackPolicyPath)
+	path, err := l.Find(root)
+	if err != nil {
+		return rawPolicy{}, "", err
+	}
+	p, err := l.readPolicy(path)
+	return p, path, err
+}
+
+// Find returns the path of the policy that applies to root.
+func (l Loader) Find(root string) (string, error) {
+	paths, err := l.searchPaths(root)
+	if err != nil {
+		return "", err
+	}
+	for _, path := range paths {
+		ok, err := exists(path)
+		if err != nil {
+			return "", fmt.Errorf("check policy %s: %w", path, err)
+		}
+		if ok {
+			return path, nil
+		}
+	}
+
+	msg := fmt.Sprintf("expected %s or %s in %s or a parent directory up to the repository root", PrimaryPolicyPath, FallbackPolicyPath, root)
+	if l.UserPolicyPath != "" {
+		msg += ", or " + l.UserPolicyPath
+	}
+	return "", fmt.Errorf("%w: %s", ErrPolicyNotFound, msg)
+}
 
-	primaryExists, err := exists(primary)
+// Candidates returns every policy file considered for root in order of
+// precedence; the first that exists is the one Load uses.
+func (l Loader) Candidates(root string) ([]Candidate, error) {
+	paths, err := l.searchPaths(root)
 	if err != nil {
-		return rawPolicy{}, "", fmt.Errorf("check policy %s: %w", primary, err)
+		return nil, err
 	}
-	if primaryExists {
-		p, err := readPolicy(primary)
-		return p, primary, err
+	candidates := make([]Candidate, 0, len(paths))
+	for _, path := range paths {
+		ok, err := exists(path)
+		if err != nil {
+			return nil, fmt.Errorf("check policy %s: %w", path, err)
+		}
+		candidates = append(candidates, Candidate{Path: path, Exists: ok})
 	}
+	return candidates, nil
+}
 
-	fallbackExists, err := exists(fallback)
+// provenance returns path relative to the root of the git checkout holding
+// it, or path itself outside a checkout.
+func provenance(path string) (string, error) {
+	abs, err := filepath.Abs(path)
 	if err != nil {
-		return rawPolicy{}, "", fmt.Errorf("check policy %s: %w", fallback, err)
+		return "", err
 	}
-	if fallbackExists {
-		p, err := readPolicy(fallback)
-		return p, fallback, err
+	for dir := filepath.Dir(abs); ; {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			rel, err := filepath.Rel(dir, abs)
+			if err != nil {
+				return "", err
+			}
+			return filepath.ToSlash(rel), nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return abs, nil
+		}
+		dir = parent
 	}
+}
 
-	return rawPolicy{}, "", fmt.Errorf("policy not found: expected %s or %s", primary, fallback)
+func (l Loader) searchPaths(root string) ([]string, error) {
+	dir, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for {
+		paths = append(paths, filepath.Join(dir, PrimaryPolicyPath), filepath.Join(dir, FallbackPolicyPath))
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	if l.UserPolicyPath != "" {
+		paths = append(paths, l.UserPolicyPath)
+	}
+	return paths, nil
 }
 
 func Compile(raw rawPolicy) (*CompiledPolicy, error) {
@@ -140,32 +473,88 @@ func Compile(raw rawPolicy) (*CompiledPolicy, error) {
 	allow := make([]AllowRule, 0, len(raw.Sandbox.Network.Allow))
 	for _, rule := range raw.Sandbox.Network.Allow {
 		host := strings.TrimSpace(strings.ToLower(rule.Host))
-		if host == "" {
-			return nil, errors.New("allow rule host cannot be empty")
-		}
-		if len(rule.Ports) == 0 {
-			return nil, fmt.Errorf("allow rule for host %q must include at least one port", host)
-		}
-
-		ports := make([]int, 0, len(rule.Ports))
-		seen := map[int]struct{}{}
-		for _, port := range rule.Ports {
-			if port < 1 || port > 65535 {
-				return nil, fmt.Errorf("allow rule for host %q contains invalid port %d", host, port)
+		var ports []int
+		var ranges []PortRange
+		for _, spec := range rule.Ports {
+			from, to, err := parsePortSpec(spec)
+			if err != nil {
+				return nil, fmt.Errorf("allow rule for host %q: %w", host, err)
 			}
-			if _, ok := seen[port]; ok {
-				continue
+			if from == to {
+				ports = append(ports, from)
+			} else {
+				ranges = append(ranges, PortRange{From: from, To: to})
 			}
-			seen[port] = struct{}{}
-			ports = append(ports, port)
 		}
-		sort.Ints(ports)
-		allow = append(allow, AllowRule{Host: host, Ports: ports})
+		compiledRule, err := compileAllowRule(host, rule.Protocol, ports, ranges)
+		if err != nil {
+			return nil, err
+		}
+		allow = append(allow, compiledRule)
+	}
+	sortAllowRules(allow)
+
+	resources, err := compileResources("sandbox.resources", Resources{
+		VCPUs:       raw.Sandbox.Resources.VCPUs,
+		MemoryMiB:   raw.Sandbox.Resources.MemoryMiB,
+		DiskMiB:     raw.Sandbox.Resources.DiskMiB,
+		EgressMbps:  raw.Sandbox.Resources.EgressMbps,
+		IngressMbps: raw.Sandbox.Resources.IngressMbps,
+		DiskIOPS:    raw.Sandbox.Resources.DiskIOPS,
+		DiskMiBps:   raw.Sandbox.Resources.DiskMiBps,
+	})
+	if err != nil {
+		return nil, err
+	}
+	vfioDevices, err := compileDeviceNames("sandbox.devices.vfio", raw.Sandbox.Devices.VFIO)
+	if err != nil {
+		return nil, err
+	}
+	dockerPreload, err := compileDockerPreload("sandbox.services.docker", raw.Sandbox.Services.Docker.Required, raw.Sandbox.Services.Docker.Preload)
+	if err != nil {
+		return nil, err
 	}
 
-	sort.Slice(allow, func(i, j int) bool {
-		return allow[i].Host < allow[j].Host
+	ociRegistry, err := compileOCIRegistry("sandbox.services.oci_registry", raw.Sandbox.Services.OCIRegistry.Allow)
+	if err != nil {
+		return nil, err
+	}
+	packages, err := compilePackages("sandbox.services.packages", PackagesService{
+		NPM:  raw.Sandbox.Services.Packages.NPM,
+		PyPI: raw.Sandbox.Services.Packages.PyPI,
+		Go:   raw.Sandbox.Services.Packages.Go,
 	})
+	if err != nil {
+		return nil, err
+	}
+	writable := make([]WritablePath, 0, len(raw.Sandbox.RootFS.Writable))
+	for _, entry := range raw.Sandbox.RootFS.Writable {
+		writable = append(writable, WritablePath{Path: entry.Path, Disk: entry.Disk, SizeMiB: entry.SizeMiB})
+	}
+	readOnlyRootFS, err := compileReadOnlyRootFS("sandbox.rootfs", raw.Sandbox.RootFS.ReadOnly, writable, raw.Sandbox.Services.Docker.Required)
+	if err != nil {
+		return nil, err
+	}
+	setup, err := compileSetup("sandbox.setup", raw.Sandbox.Setup)
+	if err != nil {
+		return nil, err
+	}
+	exitCodes, err := compileExitCodes("sandbox.exit_codes", exitCodeRulesFromRaw(raw.Sandbox.ExitCodes))
+	if err != nil {
+		return nil, err
+	}
+	banner, err := compileConsoleBanner("sandbox.console.banner", raw.Sandbox.Console.Banner)
+	if err != nil {
+		return nil, err
+	}
+	maxSession, err := compileConsoleLimit("sandbox.console.max_session_seconds", raw.Sandbox.Console.MaxSessionSeconds)
+	if err != nil {
+		return nil, err
+	}
+	idleTimeout, err := compileConsoleLimit("sandbox.console.idle_timeout_seconds", raw.Sandbox.Console.IdleTimeoutSeconds)
+	if err != nil {
+		return nil, err
+	}
 
 	compiled := &CompiledPolicy{
 		Version:     raw.Version,
@@ -174,10 +563,23 @@ func Compile(raw rawPolicy) (*CompiledPolicy, error) {
 		Services: Services{
 			Docker: DockerService{
 				Required: raw.Sandbox.Services.Docker.Required,
+				Preload:  dockerPreload,
 			},
+			OCIRegistry: ociRegistry,
+			Packages:    packages,
 		},
-		NetworkDefault: networkDefault,
-		Allow:          allow,
+		Resources:                 resources,
+		VFIODevices:               vfioDevices,
+		NestedVirtualization:      raw.Sandbox.NestedVirtualization,
+		ReadOnlyRootFS:            readOnlyRootFS,
+		Setup:                     setup,
+		ExitCodes:                 exitCodes,
+		ConsoleBanner:             banner,
+		ConsoleMaxSessionSeconds:  maxSession,
+		ConsoleIdleTimeoutSeconds: idleTimeout,
+		Variables:                 maps.Clone(raw.variables),
+		NetworkDefault:            networkDefault,
+		Allow:                     allow,
 	}
 
 	hash, err := hashPolicy(compiled)
@@ -195,15 +597,52 @@ func (p *CompiledPolicy) Allows(host string, port int) bool {
 		if rule.Host != host {
 			continue
 		}
-		for _, candidate := range rule.Ports {
-			if candidate == port {
-				return true
-			}
+		if rule.AllowsPort("tcp", port) {
+			return true
 		}
 	}
 	return false
 }
 
+// AllowsOCIRepository reports whether the gateway may fetch from repository,
+// given as "<registry-host>/<path>". When the allowing entries are all
+// digest-pinned, pinned lists those digests and only they and the content
+// they reference may be fetched; an empty pinned list allows any reference.
+func (p *CompiledPolicy) AllowsOCIRepository(repository string) (pinned []string, ok bool) {
+	if p == nil || p.Services.OCIRegistry == nil {
+		return nil, false
+	}
+	for _, entry := range p.Services.OCIRegistry.Allow {
+		repo, digest, hasDigest := strings.Cut(entry, "@")
+		if repo != repository {
+			continue
+		}
+		if !hasDigest {
+			return nil, true
+		}
+		ok = true
+		pinned = append(pinned, digest)
+	}
+	return pinned, ok
+}
+
+// PackagePatterns returns the patterns the policy allows for a package
+// ecosystem, or nil when the sandbox may not fetch from it.
+func (p *CompiledPolicy) PackagePatterns(ecosystem string) []string {
+	if p == nil || p.Services.Packages == nil {
+		return nil
+	}
+	switch ecosystem {
+	case PackageEcosystemNPM:
+		return p.Services.Packages.NPM
+	case PackageEcosystemPyPI:
+		return p.Services.Packages.PyPI
+	case PackageEcosystemGo:
+		return p.Services.Packages.Go
+	}
+	return nil
+}
+
 func (p *CompiledPolicy) RequiresDockerService() bool {
 	if p == nil {
 		return false
@@ -211,16 +650,48 @@ func (p *CompiledPolicy) RequiresDockerService() bool {
 	return p.Services.Docker.Required
 }
 
-func readPolicy(path string) (rawPolicy, error) {
+// SetupEnvironmentHash hashes the policy without its setup commands. It
+// identifies the network, registry and package settings setup commands run
+// under.
+func (p *CompiledPolicy) SetupEnvironmentHash() (string, error) {
+	clone := *p
+	clone.Setup = nil
+	return hashPolicy(&clone)
+}
+
+func (l Loader) readPolicy(path string) (rawPolicy, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
 		return rawPolicy{}, err
 	}
 
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return rawPolicy{}, fmt.Errorf("parse %s: %w", path, err)
+	}
 	var raw rawPolicy
-	if err := yaml.Unmarshal(b, &raw); err != nil {
+	if doc.Kind == 0 {
+		return raw, nil
+	}
+	// Variables are read first: until they are substituted, a ${NAME}
+	// may sit where the policy needs a number.
+	var declared struct {
+		Variables map[string]*string `yaml:"variables"`
+	}
+	if err := doc.Decode(&declared); err != nil {
 		return rawPolicy{}, fmt.Errorf("parse %s: %w", path, err)
 	}
+	vars, err := l.resolveVariables(declared.Variables)
+	if err != nil {
+		return rawPolicy{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := substituteVariables(&doc, vars); err != nil {
+		return rawPolicy{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := doc.Decode(&raw); err != nil {
+		return rawPolicy{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	raw.variables = vars
 
 	return raw, nil
 }
@@ -247,11 +718,54 @@ func (p *CompiledPolicy) ToProto() *cleanroomv1.Policy {
 		for _, port := range rule.Ports {
 			ports = append(ports, int32(port))
 		}
+		ranges := make([]*cleanroomv1.PolicyPortRange, 0, len(rule.PortRanges))
+		for _, pr := range rule.PortRanges {
+			ranges = append(ranges, &cleanroomv1.PolicyPortRange{From: int32(pr.From), To: int32(pr.To)})
+		}
 		allow = append(allow, &cleanroomv1.PolicyAllowRule{
-			Host:  rule.Host,
-			Ports: ports,
+			Host:       rule.Host,
+			Ports:      ports,
+			Protocol:   rule.Protocol,
+			PortRanges: ranges,
 		})
 	}
+	var resources *cleanroomv1.PolicyResources
+	if p.Resources != nil {
+		resources = &cleanroomv1.PolicyResources{
+			Vcpus:       p.Resources.VCPUs,
+			MemoryMib:   p.Resources.MemoryMiB,
+			DiskMib:     p.Resources.DiskMiB,
+			EgressMbps:  p.Resources.EgressMbps,
+			IngressMbps: p.Resources.IngressMbps,
+			DiskIops:    p.Resources.DiskIOPS,
+			DiskMibps:   p.Resources.DiskMiBps,
+		}
+	}
+	var ociRegistry *cleanroomv1.PolicyOCIRegistryService
+	if p.Services.OCIRegistry != nil {
+		ociRegistry = &cleanroomv1.PolicyOCIRegistryService{
+			Allow: append([]string(nil), p.Services.OCIRegistry.Allow...),
+		}
+	}
+	var packages *cleanroomv1.PolicyPackagesService
+	if p.Services.Packages != nil {
+		packages = &cleanroomv1.PolicyPackagesService{
+			Npm:  append([]string(nil), p.Services.Packages.NPM...),
+			Pypi: append([]string(nil), p.Services.Packages.PyPI...),
+			Go:   append([]string(nil), p.Services.Packages.Go...),
+		}
+	}
+	var readOnlyRootFS *cleanroomv1.PolicyReadOnlyRootFS
+	if p.ReadOnlyRootFS != nil {
+		readOnlyRootFS = &cleanroomv1.PolicyReadOnlyRootFS{}
+		for _, entry := range p.ReadOnlyRootFS.Writable {
+			readOnlyRootFS.Writable = append(readOnlyRootFS.Writable, &cleanroomv1.PolicyWritablePath{
+				Path:    entry.Path,
+				Disk:    entry.Disk,
+				SizeMib: entry.SizeMiB,
+			})
+		}
+	}
 	return &cleanroomv1.Policy{
 		Version:     int32(p.Version),
 		ImageRef:    p.ImageRef,
@@ -259,11 +773,25 @@ func (p *CompiledPolicy) ToProto() *cleanroomv1.Policy {
 		Services: &cleanroomv1.PolicyServices{
 			Docker: &cleanroomv1.PolicyDockerService{
 				Required: p.Services.Docker.Required,
+				Preload:  append([]string(nil), p.Services.Docker.Preload...),
 			},
+			OciRegistry: ociRegistry,
+			Packages:    packages,
 		},
-		NetworkDefault: p.NetworkDefault,
-		Allow:          allow,
-		Hash:           p.Hash,
+		Resources:                 resources,
+		VfioDevices:               append([]string(nil), p.VFIODevices...),
+		NestedVirtualization:      p.NestedVirtualization,
+		ReadOnlyRootfs:            readOnlyRootFS,
+		Setup:                     append([]string(nil), p.Setup...),
+		ExitCodes:                 exitCodeRulesToProto(p.ExitCodes),
+		ConsoleBanner:             p.ConsoleBanner,
+		ConsoleMaxSessionSeconds:  p.ConsoleMaxSessionSeconds,
+		ConsoleIdleTimeoutSeconds: p.ConsoleIdleTimeoutSeconds,
+		Variables:                 maps.Clone(p.Variables),
+		Source:                    p.Source,
+		NetworkDefault:            p.NetworkDefault,
+		Allow:                     allow,
+		Hash:                      p.Hash,
 	}
 }
 
@@ -300,32 +828,87 @@ func FromProto(pb *cleanroomv1.Policy) (*CompiledPolicy, error) {
 	allow := make([]AllowRule, 0, len(pb.GetAllow()))
 	for _, rule := range pb.GetAllow() {
 		host := strings.TrimSpace(strings.ToLower(rule.GetHost()))
-		if host == "" {
-			return nil, errors.New("allow rule host cannot be empty")
-		}
 		ports := make([]int, 0, len(rule.GetPorts()))
-		seen := map[int]struct{}{}
 		for _, port := range rule.GetPorts() {
-			if port < 1 || port > 65535 {
-				return nil, fmt.Errorf("allow rule for host %q contains invalid port %d", host, port)
-			}
-			candidate := int(port)
-			if _, ok := seen[candidate]; ok {
-				continue
-			}
-			seen[candidate] = struct{}{}
-			ports = append(ports, candidate)
+			ports = append(ports, int(port))
+		}
+		ranges := make([]PortRange, 0, len(rule.GetPortRanges()))
+		for _, pr := range rule.GetPortRanges() {
+			ranges = append(ranges, PortRange{From: int(pr.GetFrom()), To: int(pr.GetTo())})
 		}
-		if len(ports) == 0 {
-			return nil, fmt.Errorf("allow rule for host %q must include at least one port", host)
+		compiledRule, err := compileAllowRule(host, rule.GetProtocol(), ports, ranges)
+		if err != nil {
+			return nil, err
 		}
-		sort.Ints(ports)
-		allow = append(allow, AllowRule{Host: host, Ports: ports})
+		allow = append(allow, compiledRule)
 	}
+	sortAllowRules(allow)
 
-	sort.Slice(allow, func(i, j int) bool {
-		return allow[i].Host < allow[j].Host
+	resources, err := compileResources("policy resources", Resources{
+		VCPUs:       pb.GetResources().GetVcpus(),
+		MemoryMiB:   pb.GetResources().GetMemoryMib(),
+		DiskMiB:     pb.GetResources().GetDiskMib(),
+		EgressMbps:  pb.GetResources().GetEgressMbps(),
+		IngressMbps: pb.GetResources().GetIngressMbps(),
+		DiskIOPS:    pb.GetResources().GetDiskIops(),
+		DiskMiBps:   pb.GetResources().GetDiskMibps(),
 	})
+	if err != nil {
+		return nil, err
+	}
+	vfioDevices, err := compileDeviceNames("policy vfio_devices", pb.GetVfioDevices())
+	if err != nil {
+		return nil, err
+	}
+	dockerPreload, err := compileDockerPreload("policy docker service", pb.GetServices().GetDocker().GetRequired(), pb.GetServices().GetDocker().GetPreload())
+	if err != nil {
+		return nil, err
+	}
+
+	ociRegistry, err := compileOCIRegistry("policy oci_registry service", pb.GetServices().GetOciRegistry().GetAllow())
+	if err != nil {
+		return nil, err
+	}
+	packages, err := compilePackages("policy packages service", PackagesService{
+		NPM:  pb.GetServices().GetPackages().GetNpm(),
+		PyPI: pb.GetServices().GetPackages().GetPypi(),
+		Go:   pb.GetServices().GetPackages().GetGo(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	writable := make([]WritablePath, 0, len(pb.GetReadOnlyRootfs().GetWritable()))
+	for _, entry := range pb.GetReadOnlyRootfs().GetWritable() {
+		writable = append(writable, WritablePath{Path: entry.GetPath(), Disk: entry.GetDisk(), SizeMiB: entry.GetSizeMib()})
+	}
+	readOnlyRootFS, err := compileReadOnlyRootFS("policy read_only_rootfs", pb.GetReadOnlyRootfs() != nil, writable, pb.GetServices().GetDocker().GetRequired())
+	if err != nil {
+		return nil, err
+	}
+	setup, err := compileSetup("policy setup", pb.GetSetup())
+	if err != nil {
+		return nil, err
+	}
+	exitCodes, err := compileExitCodes("policy exit_codes", exitCodeRulesFromProto(pb.GetExitCodes()))
+	if err != nil {
+		return nil, err
+	}
+	banner, err := compileConsoleBanner("policy console_banner", pb.GetConsoleBanner())
+	if err != nil {
+		return nil, err
+	}
+	maxSession, err := compileConsoleLimit("policy console_max_session_seconds", pb.GetConsoleMaxSessionSeconds())
+	if err != nil {
+		return nil, err
+	}
+	idleTimeout, err := compileConsoleLimit("policy console_idle_timeout_seconds", pb.GetConsoleIdleTimeoutSeconds())
+	if err != nil {
+		return nil, err
+	}
+	var variables map[string]string
+	if len(pb.GetVariables()) > 0 {
+		variables = maps.Clone(pb.GetVariables())
+	}
 
 	compiled := &CompiledPolicy{
 		Version:     int(pb.GetVersion()),
@@ -334,10 +917,24 @@ func FromProto(pb *cleanroomv1.Policy) (*CompiledPolicy, error) {
 		Services: Services{
 			Docker: DockerService{
 				Required: pb.GetServices().GetDocker().GetRequired(),
+				Preload:  dockerPreload,
 			},
+			OCIRegistry: ociRegistry,
+			Packages:    packages,
 		},
-		NetworkDefault: networkDefault,
-		Allow:          allow,
+		Resources:                 resources,
+		VFIODevices:               vfioDevices,
+		NestedVirtualization:      pb.GetNestedVirtualization(),
+		ReadOnlyRootFS:            readOnlyRootFS,
+		Setup:                     setup,
+		ExitCodes:                 exitCodes,
+		ConsoleBanner:             banner,
+		ConsoleMaxSessionSeconds:  maxSession,
+		ConsoleIdleTimeoutSeconds: idleTimeout,
+		Variables:                 variables,
+		Source:                    strings.TrimSpace(pb.GetSource()),
+		NetworkDefault:            networkDefault,
+		Allow:                     allow,
 	}
 
 	hash, err := hashPolicy(compiled)
@@ -352,6 +949,476 @@ func FromProto(pb *cleanroomv1.Policy) (*CompiledPolicy, error) {
 	return compiled, nil
 }
 
+// compileResources validates requested resources. It returns nil when none
+// were requested so policies without resources keep their hash.
+// compileAllowRule validates an allow entry and canonicalises its ports:
+// single ports and ranges are de-duplicated and sorted, and single ports a
+// range already covers are dropped. ICMP entries take no ports.
+func compileAllowRule(host, protocol string, ports []int, ranges []PortRange) (AllowRule, error) {
+	if host == "" {
+		return AllowRule{}, errors.New("allow rule host cannot be empty")
+	}
+	host, err := validateAllowHost(host)
+	if err != nil {
+		return AllowRule{}, err
+	}
+	protocol = strings.TrimSpace(strings.ToLower(protocol))
+	switch protocol {
+	case "", "tcp", "udp":
+		if len(ports) == 0 && len(ranges) == 0 {
+			return AllowRule{}, fmt.Errorf("allow rule for host %q must include at least one port", host)
+		}
+	case "icmp":
+		if len(ports) > 0 || len(ranges) > 0 {
+			return AllowRule{}, fmt.Errorf("allow rule for host %q: icmp rules take no ports", host)
+		}
+		return AllowRule{Host: host, Protocol: protocol}, nil
+	default:
+		return AllowRule{}, fmt.Errorf("allow rule for host %q has unsupported protocol %q (expected tcp, udp or icmp)", host, protocol)
+	}
+
+	var outRanges []PortRange
+	for _, pr := range ranges {
+		if pr.From < 1 || pr.To > 65535 || pr.From > pr.To {
+			return AllowRule{}, fmt.Errorf("allow rule for host %q contains invalid port range %d-%d", host, pr.From, pr.To)
+		}
+		if !slices.Contains(outRanges, pr) {
+			outRanges = append(outRanges, pr)
+		}
+	}
+	sort.Slice(outRanges, func(i, j int) bool {
+		if outRanges[i].From != outRanges[j].From {
+			return outRanges[i].From < outRanges[j].From
+		}
+		return outRanges[i].To < outRanges[j].To
+	})
+
+	rule := AllowRule{Host: host, Protocol: protocol, PortRanges: outRanges}
+	outPorts := make([]int, 0, len(ports))
+	for _, port := range ports {
+		if port < 1 || port > 65535 {
+			return AllowRule{}, fmt.Errorf("allow rule for host %q contains invalid port %d", host, port)
+		}
+		if slices.Contains(outPorts, port) {
+			continue
+		}
+		covered := false
+		for _, pr := range outRanges {
+			covered = covered || (port >= pr.From && port <= pr.To)
+		}
+		if !covered {
+			outPorts = append(outPorts, port)
+		}
+	}
+	sort.Ints(outPorts)
+	rule.Ports = outPorts
+	return rule, nil
+}
+
+// validateAllowHost checks an allow entry's host, which the firecracker
+// backend resolves and turns into iptables rules, and returns it in
+// canonical form. It must be an IPv4 address or an RFC 1123 hostname.
+func validateAllowHost(host string) (string, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if addr = addr.Unmap(); !addr.Is4() {
+			return "", fmt.Errorf("allow rule host %q: IPv6 addresses are not supported", host)
+		}
+		return addr.String(), nil
+	}
+	if len(host) > 253 {
+		return "", fmt.Errorf("allow rule host %q is longer than 253 characters", host)
+	}
+	labels := strings.Split(host, ".")
+	for _, label := range labels {
+		if !hostnameLabelPattern.MatchString(label) {
+			return "", fmt.Errorf("allow rule host %q is not a valid hostname or IPv4 address", host)
+		}
+	}
+	// An all-numeric last label would make the name look like a malformed
+	// address, which resolvers may treat as one.
+	if isDigits(labels[len(labels)-1]) {
+		return "", fmt.Errorf("allow rule host %q is not a valid hostname or IPv4 address", host)
+	}
+	return host, nil
+}
+
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// parsePortSpec parses a port ("443") or an inclusive range ("8000-8100").
+func parsePortSpec(spec string) (int, int, error) {
+	spec = strings.TrimSpace(spec)
+	fromText, toText, isRange := strings.Cut(spec, "-")
+	fromText, toText = strings.TrimSpace(fromText), strings.TrimSpace(toText)
+	from, err := strconv.Atoi(fromText)
+	if err != nil || !isDigits(fromText) {
+		return 0, 0, fmt.Errorf("invalid port %q: expected a number or a range like 8000-8100", spec)
+	}
+	if !isRange {
+		return from, from, nil
+	}
+	to, err := strconv.Atoi(toText)
+	if err != nil || !isDigits(toText) {
+		return 0, 0, fmt.Errorf("invalid port %q: expected a number or a range like 8000-8100", spec)
+	}
+	return from, to, nil
+}
+
+// sortAllowRules orders rules by host, then protocol, so equivalent
+// policies hash the same.
+func sortAllowRules(allow []AllowRule) {
+	sort.SliceStable(allow, func(i, j int) bool {
+		if allow[i].Host != allow[j].Host {
+			return allow[i].Host < allow[j].Host
+		}
+		return allow[i].Protocol < allow[j].Protocol
+	})
+}
+
+func compileResources(field string, r Resources) (*Resources, error) {
+	if r.VCPUs < 0 {
+		return nil, fmt.Errorf("invalid %s.vcpus %d: must not be negative", field, r.VCPUs)
+	}
+	if r.MemoryMiB < 0 {
+		return nil, fmt.Errorf("invalid %s.memory_mib %d: must not be negative", field, r.MemoryMiB)
+	}
+	if r.DiskMiB < 0 {
+		return nil, fmt.Errorf("invalid %s.disk_mib %d: must not be negative", field, r.DiskMiB)
+	}
+	if r.EgressMbps < 0 {
+		return nil, fmt.Errorf("invalid %s.egress_mbps %d: must not be negative", field, r.EgressMbps)
+	}
+	if r.IngressMbps < 0 {
+		return nil, fmt.Errorf("invalid %s.ingress_mbps %d: must not be negative", field, r.IngressMbps)
+	}
+	if r.DiskIOPS < 0 {
+		return nil, fmt.Errorf("invalid %s.disk_iops %d: must not be negative", field, r.DiskIOPS)
+	}
+	if r.DiskMiBps < 0 {
+		return nil, fmt.Errorf("invalid %s.disk_mibps %d: must not be negative", field, r.DiskMiBps)
+	}
+	if r == (Resources{}) {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+// compileDeviceNames validates, sorts and de-duplicates device names. Names
+// refer to devices the server operator has configured, never to host paths or
+// PCI addresses, so a policy stays portable between hosts.
+func compileDeviceNames(field string, names []string) ([]string, error) {
+	var out []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if !deviceNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid %s entry %q: use lowercase letters, digits and dashes", field, name)
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// compileDockerPreload validates, sorts and de-duplicates the images to load
+// into the guest's dockerd. They must be digest-pinned so the host cache
+// entry cannot drift from what the policy reviewed.
+func compileDockerPreload(field string, required bool, refs []string) ([]string, error) {
+	if len(refs) == 0 {
+		return nil, nil
+	}
+	if !required {
+		return nil, fmt.Errorf("%s.preload requires %s.required: true", field, field)
+	}
+	var out []string
+	seen := map[string]bool{}
+	for _, ref := range refs {
+		parsed, err := ociref.ParseDigestReference(ref)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s.preload entry: %w", field, err)
+		}
+		if seen[parsed.Original] {
+			continue
+		}
+		seen[parsed.Original] = true
+		out = append(out, parsed.Original)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// compileOCIRegistry validates, sorts and de-duplicates the repositories the
+// gateway's registry proxy may fetch. It returns nil when none are listed so
+// policies without the service keep their hash.
+func compileOCIRegistry(field string, entries []string) (*OCIRegistryService, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	var out []string
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		repo := entry
+		if strings.Contains(entry, "@") {
+			parsed, err := ociref.ParseDigestReference(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s.allow entry: %w", field, err)
+			}
+			repo = parsed.Repository
+			entry = parsed.Repository + "@" + parsed.Digest()
+		}
+		if err := validateOCIRepository(repo); err != nil {
+			return nil, fmt.Errorf("invalid %s.allow entry %q: %w", field, entry, err)
+		}
+		if seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		out = append(out, entry)
+	}
+	sort.Strings(out)
+	return &OCIRegistryService{Allow: out}, nil
+}
+
+// compilePackages validates, sorts and de-duplicates each ecosystem's
+// patterns. It returns nil when none are listed so policies without the
+// service keep their hash.
+func compilePackages(field string, in PackagesService) (*PackagesService, error) {
+	var out PackagesService
+	var err error
+	if out.NPM, err = compilePackagePatterns(field+".npm", in.NPM, false); err != nil {
+		return nil, err
+	}
+	// PyPI project names compare case-insensitively.
+	if out.PyPI, err = compilePackagePatterns(field+".pypi", in.PyPI, true); err != nil {
+		return nil, err
+	}
+	if out.Go, err = compilePackagePatterns(field+".go", in.Go, false); err != nil {
+		return nil, err
+	}
+	if out.NPM == nil && out.PyPI == nil && out.Go == nil {
+		return nil, nil
+	}
+	return &out, nil
+}
+
+func compilePackagePatterns(field string, patterns []string, lower bool) ([]string, error) {
+	var out []string
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if lower {
+			pattern = strings.ToLower(pattern)
+		}
+		if pattern == "" || strings.ContainsAny(pattern, " \t\n\r") {
+			return nil, fmt.Errorf("invalid %s entry %q: must be a non-empty name or pattern", field, pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q: %w", field, pattern, err)
+		}
+		if seen[pattern] {
+			continue
+		}
+		seen[pattern] = true
+		out = append(out, pattern)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// MaxScratchDisks caps the scratch disks a read-only rootfs policy may ask
+// for; each one is an extra block device attached to the VM.
+const MaxScratchDisks = 8
+
+// guestManagedPaths are set up by the guest itself and cannot be declared
+// writable: /run and /tmp are always tmpfs, the rest are kernel filesystems.
+var guestManagedPaths = []string{"/dev", "/proc", "/run", "/sys", "/tmp"}
+
+// compileReadOnlyRootFS validates and sorts the writable paths of a read-only
+// rootfs. It returns nil when the rootfs stays writable so such policies
+// keep their hash. Paths are sorted so a parent is mounted before anything
+// nested inside it.
+func compileReadOnlyRootFS(field string, readOnly bool, writable []WritablePath, dockerRequired bool) (*ReadOnlyRootFS, error) {
+	if !readOnly {
+		if len(writable) > 0 {
+			return nil, fmt.Errorf("%s.writable requires %s.read_only: true", field, field)
+		}
+		return nil, nil
+	}
+	out := &ReadOnlyRootFS{}
+	seen := map[string]bool{}
+	disks := 0
+	for _, entry := range writable {
+		p := strings.TrimSpace(entry.Path)
+		if !writablePathPattern.MatchString(p) || path.Clean(p) != p {
+			return nil, fmt.Errorf("invalid %s.writable path %q: must be a clean absolute path of letters, digits, '.', '_' and '-'", field, p)
+		}
+		for _, managed := range guestManagedPaths {
+			if p == managed || strings.HasPrefix(p, managed+"/") {
+				return nil, fmt.Errorf("invalid %s.writable path %q: %s is managed by the guest", field, p, managed)
+			}
+		}
+		if seen[p] {
+			return nil, fmt.Errorf("duplicate %s.writable path %q", field, p)
+		}
+		seen[p] = true
+		if entry.SizeMiB < 0 {
+			return nil, fmt.Errorf("invalid %s.writable size_mib %d for %q: must not be negative", field, entry.SizeMiB, p)
+		}
+		if entry.Disk {
+			if entry.SizeMiB == 0 {
+				return nil, fmt.Errorf("%s.writable path %q is a scratch disk and needs size_mib", field, p)
+			}
+			disks++
+		}
+		out.Writable = append(out.Writable, WritablePath{Path: p, Disk: entry.Disk, SizeMiB: entry.SizeMiB})
+	}
+	if disks > MaxScratchDisks {
+		return nil, fmt.Errorf("%s.writable asks for %d scratch disks, at most %d are supported", field, disks, MaxScratchDisks)
+	}
+	if dockerRequired && !out.IsWritable("/var/lib/docker") {
+		return nil, fmt.Errorf("docker service needs /var/lib/docker (or a parent) in %s.writable when the rootfs is read-only", field)
+	}
+	sort.Slice(out.Writable, func(i, j int) bool {
+		return out.Writable[i].Path < out.Writable[j].Path
+	})
+	return out, nil
+}
+
+// MaxConsoleBannerBytes caps sandbox.console.banner.
+const MaxConsoleBannerBytes = 4096
+
+// consoleBannerEgress is replaced with the allowed destinations when a
+// banner is rendered.
+const consoleBannerEgress = "{egress}"
+
+// compileConsoleBanner drops trailing whitespace from the banner and
+// rejects control characters other than newlines and tabs, so a policy
+// cannot send escape sequences to an operator's terminal.
+func compileConsoleBanner(field, banner string) (string, error) {
+	banner = strings.TrimRight(banner, " \t\r\n")
+	if len(banner) > MaxConsoleBannerBytes {
+		return "", fmt.Errorf("%s is %d bytes, at most %d are supported", field, len(banner), MaxConsoleBannerBytes)
+	}
+	if !utf8.ValidString(banner) {
+		return "", fmt.Errorf("%s is not valid UTF-8", field)
+	}
+	for _, r := range banner {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return "", fmt.Errorf("%s contains control character %U", field, r)
+		}
+	}
+	return banner, nil
+}
+
+// RenderConsoleBanner returns the banner to show at the start of an
+// interactive session, with {egress} replaced by the destinations the
+// sandbox may reach. It is empty when the policy sets no banner.
+func (p *CompiledPolicy) RenderConsoleBanner() string {
+	if p == nil || p.ConsoleBanner == "" {
+		return ""
+	}
+	egress := "none"
+	if len(p.Allow) > 0 {
+		rules := make([]string, 0, len(p.Allow))
+		for _, rule := range p.Allow {
+			rules = append(rules, rule.String())
+		}
+		egress = strings.Join(rules, ", ")
+	}
+	return strings.ReplaceAll(p.ConsoleBanner, consoleBannerEgress, egress)
+}
+
+// MinConsoleLimitSeconds is the shortest console session or idle limit, so
+// there is time for the one-minute warning before a session ends.
+const MinConsoleLimitSeconds = 120
+
+// compileConsoleLimit checks a console session limit in seconds, where zero
+// means no limit.
+func compileConsoleLimit(field string, seconds int64) (int64, error) {
+	if seconds != 0 && seconds < MinConsoleLimitSeconds {
+		return 0, fmt.Errorf("%s must be 0 or at least %d, got %d", field, MinConsoleLimitSeconds, seconds)
+	}
+	return seconds, nil
+}
+
+// MaxSetupCommands caps sandbox.setup; each command is a separate guest exec.
+const MaxSetupCommands = 32
+
+// compileSetup trims the setup commands and keeps their order, which is
+// significant. It returns nil when there are none so such policies keep
+// their hash.
+func compileSetup(field string, commands []string) ([]string, error) {
+	if len(commands) == 0 {
+		return nil, nil
+	}
+	if len(commands) > MaxSetupCommands {
+		return nil, fmt.Errorf("%s has %d commands, at most %d are supported", field, len(commands), MaxSetupCommands)
+	}
+	out := make([]string, 0, len(commands))
+	for i, command := range commands {
+		command = strings.TrimSpace(command)
+		if command == "" {
+			return nil, fmt.Errorf("invalid %s[%d]: command must not be empty", field, i)
+		}
+		out = append(out, command)
+	}
+	return out, nil
+}
+
+// IsWritable reports whether dir is one of the writable paths or inside one.
+func (r *ReadOnlyRootFS) IsWritable(dir string) bool {
+	if r == nil {
+		return true
+	}
+	for _, entry := range r.Writable {
+		if dir == entry.Path || strings.HasPrefix(dir, entry.Path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// validateOCIRepository checks a "<registry-host>/<path>" repository name.
+// The registry host is required so the gateway never guesses a default.
+func validateOCIRepository(repo string) error {
+	host, path, ok := strings.Cut(repo, "/")
+	if !ok || path == "" {
+		return errors.New("must include a registry host and repository path (for example ghcr.io/org/image)")
+	}
+	if host != "localhost" && !strings.ContainsAny(host, ".:") {
+		return fmt.Errorf("%q is not a registry host (for example ghcr.io)", host)
+	}
+	if !ociRegistryHostPattern.MatchString(host) {
+		return fmt.Errorf("invalid registry host %q", host)
+	}
+	for _, component := range strings.Split(path, "/") {
+		if !ociPathComponentPattern.MatchString(component) {
+			return fmt.Errorf("invalid repository path component %q", component)
+		}
+	}
+	return nil
+}
+
+var (
+	ociRegistryHostPattern  = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]*[a-z0-9])?(:[0-9]+)?$`)
+	ociPathComponentPattern = regexp.MustCompile(`^[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*$`)
+)
+
+// hostnameLabelPattern matches one label of a lowercased RFC 1123 hostname.
+var hostnameLabelPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+var deviceNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// writablePathPattern keeps writable paths safe to pass on the kernel
+// command line.
+var writablePathPattern = regexp.MustCompile(`^(/[A-Za-z0-9._-]+)+$`)
+
 func hashPolicy(p *CompiledPolicy) (string, error) {
 	clone := *p
 	clone.Hash = ""
@@ -1,8 +1,11 @@
 package policy
 
 import (
+	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -124,8 +127,8 @@ func TestCompileHashStable(t *testing.T) {
 
 	raw := baseRawPolicy()
 	raw.Sandbox.Network.Allow = []rawAllowRule{
-		{Host: "api.github.com", Ports: []int{443, 443, 80}},
-		{Host: "registry.npmjs.org", Ports: []int{443}},
+		{Host: "api.github.com", Ports: []string{"443", "443", "80"}},
+		{Host: "registry.npmjs.org", Ports: []string{"443"}},
 	}
 
 	compiledA, err := Compile(raw)
@@ -234,6 +237,97 @@ func TestFromProtoCanonicalisesAllowRules(t *testing.T) {
 	}
 }
 
+func TestCompileAllowRuleProtocolsAndPortRanges(t *testing.T) {
+	t.Parallel()
+
+	raw := baseRawPolicy()
+	raw.Sandbox.Network.Allow = []rawAllowRule{
+		{Host: "dns.internal", Protocol: "UDP", Ports: []string{"5353", "8000-8100", "8050", "60000-60000"}},
+		{Host: "dns.internal", Protocol: "icmp"},
+	}
+	compiled, err := Compile(raw)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if got, want := compiled.Allow[0].String(), "dns.internal/icmp"; got != want {
+		t.Fatalf("expected icmp rule first: got %q want %q", got, want)
+	}
+	if got, want := compiled.Allow[1].String(), "dns.internal:5353,60000,8000-8100/udp"; got != want {
+		t.Fatalf("unexpected udp rule: got %q want %q", got, want)
+	}
+	udp := compiled.Allow[1]
+	if !udp.AllowsPort("udp", 8099) || udp.AllowsPort("tcp", 8099) || udp.AllowsPort("udp", 8101) {
+		t.Fatalf("unexpected port matching for %s", udp)
+	}
+
+	roundTripped, err := FromProto(compiled.ToProto())
+	if err != nil {
+		t.Fatalf("FromProto returned error: %v", err)
+	}
+	if roundTripped.Hash != compiled.Hash {
+		t.Fatalf("expected round trip to keep the hash: got %s want %s", roundTripped.Hash, compiled.Hash)
+	}
+
+	for _, tc := range []struct {
+		rule rawAllowRule
+		want string
+	}{
+		{rule: rawAllowRule{Host: "a.example", Protocol: "icmp", Ports: []string{"1"}}, want: "icmp rules take no ports"},
+		{rule: rawAllowRule{Host: "a.example", Protocol: "sctp", Ports: []string{"1"}}, want: "unsupported protocol"},
+		{rule: rawAllowRule{Host: "a.example", Ports: []string{"9000-8000"}}, want: "invalid port range 9000-8000"},
+		{rule: rawAllowRule{Host: "a.example", Ports: []string{"https"}}, want: "invalid port \"https\""},
+		{rule: rawAllowRule{Host: "a.example", Ports: []string{"+443"}}, want: "invalid port \"+443\""},
+		{rule: rawAllowRule{Host: "a.example", Ports: []string{"0"}}, want: "invalid port 0"},
+		{rule: rawAllowRule{Host: "a.example", Ports: []string{"65536"}}, want: "invalid port 65536"},
+		{rule: rawAllowRule{Host: "a.example -j ACCEPT", Ports: []string{"443"}}, want: "not a valid hostname"},
+		{rule: rawAllowRule{Host: "--help", Ports: []string{"443"}}, want: "not a valid hostname"},
+		{rule: rawAllowRule{Host: "a.example;reboot", Ports: []string{"443"}}, want: "not a valid hostname"},
+		{rule: rawAllowRule{Host: "a.example\n-A INPUT", Ports: []string{"443"}}, want: "not a valid hostname"},
+		{rule: rawAllowRule{Host: "-a.example", Ports: []string{"443"}}, want: "not a valid hostname"},
+		{rule: rawAllowRule{Host: "a..example", Ports: []string{"443"}}, want: "not a valid hostname"},
+		{rule: rawAllowRule{Host: "a_b.example", Ports: []string{"443"}}, want: "not a valid hostname"},
+		{rule: rawAllowRule{Host: "10.0.0.0/8", Ports: []string{"443"}}, want: "not a valid hostname"},
+		{rule: rawAllowRule{Host: "256.1.1.1", Ports: []string{"443"}}, want: "not a valid hostname"},
+		{rule: rawAllowRule{Host: "0x7f.1", Ports: []string{"443"}}, want: "not a valid hostname"},
+		{rule: rawAllowRule{Host: strings.Repeat("a", 64) + ".example", Ports: []string{"443"}}, want: "not a valid hostname"},
+		{rule: rawAllowRule{Host: strings.Repeat("a.", 127) + "example", Ports: []string{"443"}}, want: "longer than 253"},
+		{rule: rawAllowRule{Host: "::1", Ports: []string{"443"}}, want: "IPv6 addresses are not supported"},
+	} {
+		raw.Sandbox.Network.Allow = []rawAllowRule{tc.rule}
+		if _, err := Compile(raw); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("compile %+v: expected %q error, got %v", tc.rule, tc.want, err)
+		}
+	}
+}
+
+func TestCompileCanonicalisesAllowHostAddresses(t *testing.T) {
+	t.Parallel()
+
+	for host, want := range map[string]string{
+		"API.Example.com ": "api.example.com",
+		"10.0.0.1":         "10.0.0.1",
+		"::ffff:10.0.0.1":  "10.0.0.1",
+		"xn--bcher-kva.de": "xn--bcher-kva.de",
+	} {
+		rule, err := compileAllowRule(strings.TrimSpace(strings.ToLower(host)), "", []int{443}, nil)
+		if err != nil {
+			t.Fatalf("compile host %q: %v", host, err)
+		}
+		if rule.Host != want {
+			t.Fatalf("compile host %q: got %q want %q", host, rule.Host, want)
+		}
+	}
+
+	if _, err := FromProto(&cleanroomv1.Policy{
+		Version:        1,
+		ImageRef:       validImageRef,
+		NetworkDefault: "deny",
+		Allow:          []*cleanroomv1.PolicyAllowRule{{Host: "1.1.1.1 -j ACCEPT", Ports: []int32{443}}},
+	}); err == nil || !strings.Contains(err.Error(), "not a valid hostname") {
+		t.Fatalf("expected FromProto to reject a hostile host, got %v", err)
+	}
+}
+
 func TestFromProtoPropagatesDockerServiceRequirement(t *testing.T) {
 	t.Parallel()
 
@@ -254,3 +348,694 @@ func TestFromProtoPropagatesDockerServiceRequirement(t *testing.T) {
 		t.Fatal("expected docker service requirement from proto policy")
 	}
 }
+
+func TestCompileCapturesResources(t *testing.T) {
+	t.Parallel()
+
+	plain, err := Compile(baseRawPolicy())
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if plain.Resources != nil {
+		t.Fatalf("expected no resources, got %+v", plain.Resources)
+	}
+
+	raw := baseRawPolicy()
+	raw.Sandbox.Resources = rawResources{VCPUs: 4, MemoryMiB: 8192, DiskMiB: 20480, EgressMbps: 200, IngressMbps: 500, DiskIOPS: 3000, DiskMiBps: 100}
+	compiled, err := Compile(raw)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if got, want := *compiled.Resources, (Resources{VCPUs: 4, MemoryMiB: 8192, DiskMiB: 20480, EgressMbps: 200, IngressMbps: 500, DiskIOPS: 3000, DiskMiBps: 100}); got != want {
+		t.Fatalf("unexpected resources: got %+v want %+v", got, want)
+	}
+	if compiled.Hash == plain.Hash {
+		t.Fatal("expected resources to change the policy hash")
+	}
+
+	roundTripped, err := FromProto(compiled.ToProto())
+	if err != nil {
+		t.Fatalf("FromProto returned error: %v", err)
+	}
+	if got, want := *roundTripped.Resources, *compiled.Resources; got != want {
+		t.Fatalf("unexpected round-tripped resources: got %+v want %+v", got, want)
+	}
+}
+
+func TestCompileConsoleBanner(t *testing.T) {
+	t.Parallel()
+
+	raw := baseRawPolicy()
+	raw.Sandbox.Network.Allow = []rawAllowRule{{Host: "api.github.com", Protocol: "tcp", Ports: []string{"443"}}}
+	raw.Sandbox.Console.Banner = "You are in a cleanroom.\nEgress: {egress}\n\n"
+	compiled, err := Compile(raw)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if got, want := compiled.RenderConsoleBanner(), "You are in a cleanroom.\nEgress: api.github.com:443/tcp"; got != want {
+		t.Fatalf("unexpected banner: got %q want %q", got, want)
+	}
+	roundTripped, err := FromProto(compiled.ToProto())
+	if err != nil {
+		t.Fatalf("FromProto returned error: %v", err)
+	}
+	if roundTripped.ConsoleBanner != compiled.ConsoleBanner || roundTripped.Hash != compiled.Hash {
+		t.Fatalf("expected the banner to round trip, got %q", roundTripped.ConsoleBanner)
+	}
+
+	raw.Sandbox.Network.Allow = nil
+	compiled, err = Compile(raw)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if got := compiled.RenderConsoleBanner(); !strings.HasSuffix(got, "Egress: none") {
+		t.Fatalf("expected no egress in the banner, got %q", got)
+	}
+	if got := (*CompiledPolicy)(nil).RenderConsoleBanner(); got != "" {
+		t.Fatalf("expected no banner without a policy, got %q", got)
+	}
+
+	for banner, want := range map[string]string{
+		"\x1b]0;pwned\x07": "control character",
+		"clear\rscreen":    "control character",
+		"\xff":             "not valid UTF-8",
+		strings.Repeat("x", MaxConsoleBannerBytes+1): "at most 4096",
+	} {
+		raw.Sandbox.Console.Banner = banner
+		if _, err := Compile(raw); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("compile banner %q: expected %q error, got %v", banner, want, err)
+		}
+	}
+}
+
+func TestCompileConsoleLimits(t *testing.T) {
+	t.Parallel()
+
+	raw := baseRawPolicy()
+	raw.Sandbox.Console.MaxSessionSeconds = 8 * 60 * 60
+	raw.Sandbox.Console.IdleTimeoutSeconds = 30 * 60
+	compiled, err := Compile(raw)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if compiled.ConsoleMaxSessionSeconds != 8*60*60 || compiled.ConsoleIdleTimeoutSeconds != 30*60 {
+		t.Fatalf("unexpected console limits: %+v", compiled)
+	}
+	roundTripped, err := FromProto(compiled.ToProto())
+	if err != nil {
+		t.Fatalf("FromProto returned error: %v", err)
+	}
+	if roundTripped.ConsoleMaxSessionSeconds != compiled.ConsoleMaxSessionSeconds || roundTripped.ConsoleIdleTimeoutSeconds != compiled.ConsoleIdleTimeoutSeconds || roundTripped.Hash != compiled.Hash {
+		t.Fatalf("expected the console limits to round trip, got %+v", roundTripped)
+	}
+
+	unlimited, err := Compile(baseRawPolicy())
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if unlimited.Hash == compiled.Hash {
+		t.Fatal("expected console limits to change the policy hash")
+	}
+
+	for _, seconds := range []int64{-1, 1, MinConsoleLimitSeconds - 1} {
+		raw := baseRawPolicy()
+		raw.Sandbox.Console.IdleTimeoutSeconds = seconds
+		if _, err := Compile(raw); err == nil || !strings.Contains(err.Error(), "sandbox.console.idle_timeout_seconds") {
+			t.Fatalf("compile idle timeout %d: expected an error, got %v", seconds, err)
+		}
+	}
+}
+
+func TestCompileRejectsNegativeResources(t *testing.T) {
+	t.Parallel()
+
+	raw := baseRawPolicy()
+	raw.Sandbox.Resources.MemoryMiB = -1
+	_, err := Compile(raw)
+	if err == nil || !strings.Contains(err.Error(), "sandbox.resources.memory_mib") {
+		t.Fatalf("expected memory_mib error, got %v", err)
+	}
+
+	raw = baseRawPolicy()
+	raw.Sandbox.Resources.IngressMbps = -1
+	_, err = Compile(raw)
+	if err == nil || !strings.Contains(err.Error(), "sandbox.resources.ingress_mbps") {
+		t.Fatalf("expected ingress_mbps error, got %v", err)
+	}
+}
+
+func TestCompileCanonicalisesVFIODevices(t *testing.T) {
+	t.Parallel()
+
+	raw := baseRawPolicy()
+	raw.Sandbox.Devices.VFIO = []string{"gpu", "fpga", "gpu"}
+	compiled, err := Compile(raw)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if got, want := strings.Join(compiled.VFIODevices, ","), "fpga,gpu"; got != want {
+		t.Fatalf("unexpected vfio devices: got %q want %q", got, want)
+	}
+
+	raw.Sandbox.Devices.VFIO = []string{"/dev/vfio/12"}
+	if _, err := Compile(raw); err == nil || !strings.Contains(err.Error(), "sandbox.devices.vfio") {
+		t.Fatalf("expected device name error, got %v", err)
+	}
+}
+
+func TestCompileValidatesDockerPreload(t *testing.T) {
+	t.Parallel()
+
+	raw := baseRawPolicy()
+	raw.Sandbox.Services.Docker.Preload = []string{validImageRef}
+	if _, err := Compile(raw); err == nil || !strings.Contains(err.Error(), "requires sandbox.services.docker.required") {
+		t.Fatalf("expected preload without docker to fail, got %v", err)
+	}
+
+	raw.Sandbox.Services.Docker.Required = true
+	raw.Sandbox.Services.Docker.Preload = []string{"postgres:16"}
+	if _, err := Compile(raw); err == nil || !strings.Contains(err.Error(), "not digest-pinned") {
+		t.Fatalf("expected tag-only preload to fail, got %v", err)
+	}
+
+	raw.Sandbox.Services.Docker.Preload = []string{validImageRef, validImageRef}
+	compiled, err := Compile(raw)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if got := compiled.Services.Docker.Preload; len(got) != 1 || got[0] != validImageRef {
+		t.Fatalf("unexpected preload list: %v", got)
+	}
+	roundTripped, err := FromProto(compiled.ToProto())
+	if err != nil {
+		t.Fatalf("FromProto returned error: %v", err)
+	}
+	if roundTripped.Hash != compiled.Hash {
+		t.Fatalf("hash changed over proto round trip: %s != %s", roundTripped.Hash, compiled.Hash)
+	}
+}
+
+func TestCompileOCIRegistryAllow(t *testing.T) {
+	t.Parallel()
+
+	raw := baseRawPolicy()
+	raw.Sandbox.Services.OCIRegistry.Allow = []string{"alpine"}
+	if _, err := Compile(raw); err == nil || !strings.Contains(err.Error(), "registry host") {
+		t.Fatalf("expected repository without registry host to fail, got %v", err)
+	}
+
+	raw.Sandbox.Services.OCIRegistry.Allow = []string{"ghcr.io/org/tool:latest"}
+	if _, err := Compile(raw); err == nil || !strings.Contains(err.Error(), "sandbox.services.oci_registry.allow") {
+		t.Fatalf("expected tag reference to fail, got %v", err)
+	}
+
+	pinned := "ghcr.io/org/tool@sha256:" + strings.Repeat("a", 64)
+	raw.Sandbox.Services.OCIRegistry.Allow = []string{pinned, "ghcr.io/org/cache", pinned}
+	compiled, err := Compile(raw)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if got, want := strings.Join(compiled.Services.OCIRegistry.Allow, ","), "ghcr.io/org/cache,"+pinned; got != want {
+		t.Fatalf("unexpected oci_registry allow list: got %q want %q", got, want)
+	}
+
+	if digests, ok := compiled.AllowsOCIRepository("ghcr.io/org/tool"); !ok || len(digests) != 1 || digests[0] != "sha256:"+strings.Repeat("a", 64) {
+		t.Fatalf("unexpected pinned digests: got %v ok=%v", digests, ok)
+	}
+	if digests, ok := compiled.AllowsOCIRepository("ghcr.io/org/cache"); !ok || digests != nil {
+		t.Fatalf("expected unpinned repository to allow any reference: got %v ok=%v", digests, ok)
+	}
+	if _, ok := compiled.AllowsOCIRepository("ghcr.io/org/other"); ok {
+		t.Fatal("expected unlisted repository to be denied")
+	}
+
+	roundTripped, err := FromProto(compiled.ToProto())
+	if err != nil {
+		t.Fatalf("FromProto returned error: %v", err)
+	}
+	if roundTripped.Hash != compiled.Hash {
+		t.Fatalf("hash changed over proto round trip: %s != %s", roundTripped.Hash, compiled.Hash)
+	}
+}
+
+func TestCompilePackagesService(t *testing.T) {
+	t.Parallel()
+
+	raw := baseRawPolicy()
+	raw.Sandbox.Services.Packages.Go = []string{"github.com/org/["}
+	if _, err := Compile(raw); err == nil || !strings.Contains(err.Error(), "sandbox.services.packages.go") {
+		t.Fatalf("expected malformed pattern to fail, got %v", err)
+	}
+
+	raw.Sandbox.Services.Packages.Go = []string{"golang.org/x/*"}
+	raw.Sandbox.Services.Packages.NPM = []string{"@org/*", "left-pad", "@org/*"}
+	raw.Sandbox.Services.Packages.PyPI = []string{"Requests"}
+	compiled, err := Compile(raw)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if got, want := strings.Join(compiled.PackagePatterns(PackageEcosystemNPM), ","), "@org/*,left-pad"; got != want {
+		t.Fatalf("unexpected npm patterns: got %q want %q", got, want)
+	}
+	if got, want := strings.Join(compiled.PackagePatterns(PackageEcosystemPyPI), ","), "requests"; got != want {
+		t.Fatalf("unexpected pypi patterns: got %q want %q", got, want)
+	}
+	if got := compiled.PackagePatterns("cargo"); got != nil {
+		t.Fatalf("expected unknown ecosystem to have no patterns, got %v", got)
+	}
+
+	roundTripped, err := FromProto(compiled.ToProto())
+	if err != nil {
+		t.Fatalf("FromProto returned error: %v", err)
+	}
+	if roundTripped.Hash != compiled.Hash {
+		t.Fatalf("hash changed over proto round trip: %s != %s", roundTripped.Hash, compiled.Hash)
+	}
+
+	if compiled, err := Compile(baseRawPolicy()); err != nil || compiled.Services.Packages != nil {
+		t.Fatalf("expected policy without packages to leave the service unset: %+v %v", compiled, err)
+	}
+}
+
+func TestCompileReadOnlyRootFS(t *testing.T) {
+	t.Parallel()
+
+	raw := baseRawPolicy()
+	raw.Sandbox.RootFS.Writable = []rawWritablePath{{Path: "/workspace"}}
+	if _, err := Compile(raw); err == nil || !strings.Contains(err.Error(), "requires sandbox.rootfs.read_only") {
+		t.Fatalf("expected writable paths without read_only to fail, got %v", err)
+	}
+
+	raw.Sandbox.RootFS.ReadOnly = true
+	for _, tc := range []struct {
+		entry rawWritablePath
+		want  string
+	}{
+		{rawWritablePath{Path: "workspace"}, "clean absolute path"},
+		{rawWritablePath{Path: "/workspace/../etc"}, "clean absolute path"},
+		{rawWritablePath{Path: "/tmp/cache"}, "managed by the guest"},
+		{rawWritablePath{Path: "/workspace", Disk: true}, "needs size_mib"},
+	} {
+		raw.Sandbox.RootFS.Writable = []rawWritablePath{tc.entry}
+		if _, err := Compile(raw); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("expected %q to fail with %q, got %v", tc.entry.Path, tc.want, err)
+		}
+	}
+
+	raw.Sandbox.RootFS.Writable = []rawWritablePath{{Path: "/workspace"}}
+	raw.Sandbox.Services.Docker.Required = true
+	if _, err := Compile(raw); err == nil || !strings.Contains(err.Error(), "/var/lib/docker") {
+		t.Fatalf("expected docker without a writable /var/lib/docker to fail, got %v", err)
+	}
+
+	raw.Sandbox.RootFS.Writable = []rawWritablePath{
+		{Path: "/workspace", Disk: true, SizeMiB: 2048},
+		{Path: "/var/lib"},
+	}
+	compiled, err := Compile(raw)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if got := compiled.ReadOnlyRootFS.Writable; len(got) != 2 || got[0].Path != "/var/lib" || !got[1].Disk {
+		t.Fatalf("unexpected writable paths: %+v", got)
+	}
+
+	roundTripped, err := FromProto(compiled.ToProto())
+	if err != nil {
+		t.Fatalf("FromProto returned error: %v", err)
+	}
+	if roundTripped.Hash != compiled.Hash {
+		t.Fatalf("hash changed over proto round trip: %s != %s", roundTripped.Hash, compiled.Hash)
+	}
+
+	if compiled, err := Compile(baseRawPolicy()); err != nil || compiled.ReadOnlyRootFS != nil {
+		t.Fatalf("expected policy without rootfs settings to keep a writable rootfs: %+v %v", compiled, err)
+	}
+}
+
+func TestCompileSetup(t *testing.T) {
+	t.Parallel()
+
+	raw := baseRawPolicy()
+	raw.Sandbox.Setup = []string{"apt-get update", "  "}
+	if _, err := Compile(raw); err == nil || !strings.Contains(err.Error(), "sandbox.setup[1]") {
+		t.Fatalf("expected an empty setup command to fail, got %v", err)
+	}
+
+	raw.Sandbox.Setup = []string{" apt-get install -y jq ", "poetry install"}
+	compiled, err := Compile(raw)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if got, want := compiled.Setup, []string{"apt-get install -y jq", "poetry install"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected setup: got %q want %q", got, want)
+	}
+	roundTripped, err := FromProto(compiled.ToProto())
+	if err != nil {
+		t.Fatalf("FromProto returned error: %v", err)
+	}
+	if roundTripped.Hash != compiled.Hash {
+		t.Fatalf("hash changed over proto round trip: %s != %s", roundTripped.Hash, compiled.Hash)
+	}
+
+	raw.Sandbox.Setup = []string{"poetry install", "apt-get install -y jq"}
+	reordered, err := Compile(raw)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if reordered.Hash == compiled.Hash {
+		t.Fatal("expected setup order to change the policy hash")
+	}
+}
+
+func TestInlineCompilesAllowEntries(t *testing.T) {
+	t.Parallel()
+
+	compiled, err := Inline(validImageRef, []string{"github.com:443", "registry.internal:80,8000-8100"})
+	if err != nil {
+		t.Fatalf("Inline returned error: %v", err)
+	}
+	if compiled.ImageRef != validImageRef || compiled.NetworkDefault != "deny" {
+		t.Fatalf("unexpected inline policy: %+v", compiled)
+	}
+	if !compiled.Allows("github.com", 443) || compiled.Allows("github.com", 80) || !compiled.Allows("registry.internal", 8050) {
+		t.Fatalf("unexpected allow rules: %v", compiled.Allow)
+	}
+
+	for _, entry := range []string{"github.com", "github.com:", ":443"} {
+		if _, err := Inline(validImageRef, []string{entry}); err == nil || !strings.Contains(err.Error(), "is not host:port") {
+			t.Fatalf("Inline(%q): expected host:port error, got %v", entry, err)
+		}
+	}
+	if _, err := Inline("ghcr.io/buildkite/cleanroom-base/alpine:latest", nil); err == nil {
+		t.Fatal("expected a tag-only image ref to be rejected")
+	}
+}
+
+func TestLoadReportsMissingPolicy(t *testing.T) {
+	t.Parallel()
+
+	_, _, err := Loader{}.LoadAndCompile(t.TempDir())
+	if !errors.Is(err, ErrPolicyNotFound) {
+		t.Fatalf("expected ErrPolicyNotFound, got %v", err)
+	}
+}
+
+func TestLoaderSearchesParentsUpToRepositoryRoot(t *testing.T) {
+	t.Parallel()
+
+	writePolicy := func(path, host string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		content := "version: 1\nsandbox:\n  image:\n    ref: " + validImageRef + "\n  network:\n    default: deny\n    allow:\n      - host: " + host + "\n        ports: [443]\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outside := t.TempDir()
+	writePolicy(filepath.Join(outside, PrimaryPolicyPath), "outside.example")
+	repo := filepath.Join(outside, "repo")
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(repo, "services", "api")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	userPolicy := filepath.Join(t.TempDir(), "policy.yaml")
+	writePolicy(userPolicy, "user.example")
+	loader := Loader{UserPolicyPath: userPolicy}
+
+	if _, source, err := loader.LoadAndCompile(sub); err != nil || source != userPolicy {
+		t.Fatalf("expected the user default past the repository root, got %q: %v", source, err)
+	}
+
+	writePolicy(filepath.Join(repo, FallbackPolicyPath), "repo.example")
+	compiled, source, err := loader.LoadAndCompile(sub)
+	if err != nil {
+		t.Fatalf("load and compile: %v", err)
+	}
+	if source != filepath.Join(repo, FallbackPolicyPath) || !compiled.Allows("repo.example", 443) {
+		t.Fatalf("expected the repository root policy, got %q", source)
+	}
+
+	candidates, err := loader.Candidates(sub)
+	if err != nil {
+		t.Fatalf("Candidates returned error: %v", err)
+	}
+	want := []Candidate{
+		{Path: filepath.Join(sub, PrimaryPolicyPath)},
+		{Path: filepath.Join(sub, FallbackPolicyPath)},
+		{Path: filepath.Join(repo, "services", PrimaryPolicyPath)},
+		{Path: filepath.Join(repo, "services", FallbackPolicyPath)},
+		{Path: filepath.Join(repo, PrimaryPolicyPath)},
+		{Path: filepath.Join(repo, FallbackPolicyPath), Exists: true},
+		{Path: userPolicy, Exists: true},
+	}
+	if !reflect.DeepEqual(candidates, want) {
+		t.Fatalf("unexpected candidates:\n got %+v\nwant %+v", candidates, want)
+	}
+}
+
+func TestLoaderRecordsSourceInHash(t *testing.T) {
+	t.Parallel()
+
+	repo := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("version: 1\nsandbox:\n  image:\n    ref: " + validImageRef + "\n  network:\n    default: deny\n")
+	var hashes []string
+	for _, service := range []string{"api", "web"} {
+		dir := filepath.Join(repo, "services", service)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, PrimaryPolicyPath), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		compiled, _, err := Loader{}.LoadAndCompile(dir)
+		if err != nil {
+			t.Fatalf("load %s: %v", service, err)
+		}
+		if got, want := compiled.Source, "services/"+service+"/cleanroom.yaml"; got != want {
+			t.Fatalf("unexpected source: got %q want %q", got, want)
+		}
+		roundTripped, err := FromProto(compiled.ToProto())
+		if err != nil {
+			t.Fatalf("FromProto returned error: %v", err)
+		}
+		if roundTripped.Source != compiled.Source || roundTripped.Hash != compiled.Hash {
+			t.Fatalf("round trip lost provenance: got %q/%s want %q/%s", roundTripped.Source, roundTripped.Hash, compiled.Source, compiled.Hash)
+		}
+		hashes = append(hashes, compiled.Hash)
+	}
+	if hashes[0] == hashes[1] {
+		t.Fatal("expected identical rules from different files to hash differently")
+	}
+}
+
+func TestLoaderSubstitutesDeclaredVariables(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, PrimaryPolicyPath), []byte(`
+version: 1
+variables:
+  IMAGE_DIGEST:
+  REGION: us-east-1
+sandbox:
+  image:
+    ref: ghcr.io/buildkite/cleanroom-base/alpine@${IMAGE_DIGEST}
+  setup:
+    - echo $${HOME}
+  network:
+    default: deny
+    allow:
+      - host: s3.${REGION}.amazonaws.com
+        ports: [443]
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	digest := "sha256:" + strings.Repeat("0123456789abcdef", 4)
+	env := map[string]string{"IMAGE_DIGEST": digest, "REGION": "ap-southeast-2", "HOME": "/root"}
+	loader := Loader{LookupEnv: func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}}
+
+	compiled, _, err := loader.LoadAndCompile(dir)
+	if err != nil {
+		t.Fatalf("load and compile: %v", err)
+	}
+	if compiled.ImageDigest != digest || !compiled.Allows("s3.ap-southeast-2.amazonaws.com", 443) {
+		t.Fatalf("variables not substituted: %+v", compiled)
+	}
+	if got, want := compiled.Setup, []string{"echo ${HOME}"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected setup: got %q want %q", got, want)
+	}
+	if got, want := compiled.Variables, map[string]string{"IMAGE_DIGEST": digest, "REGION": "ap-southeast-2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected variables: got %v want %v", got, want)
+	}
+
+	loader.Vars = map[string]string{"REGION": "eu-west-1"}
+	overridden, _, err := loader.LoadAndCompile(dir)
+	if err != nil {
+		t.Fatalf("load and compile with --var: %v", err)
+	}
+	if !overridden.Allows("s3.eu-west-1.amazonaws.com", 443) || overridden.Hash == compiled.Hash {
+		t.Fatalf("expected --var to take precedence and change the hash: %+v", overridden)
+	}
+	roundTripped, err := FromProto(overridden.ToProto())
+	if err != nil || roundTripped.Hash != overridden.Hash {
+		t.Fatalf("round trip lost variables: %v", err)
+	}
+
+	for _, tc := range []struct {
+		loader Loader
+		want   string
+	}{
+		{loader: Loader{LookupEnv: func(string) (string, bool) { return "", false }}, want: "variable IMAGE_DIGEST is not set"},
+		{loader: Loader{LookupEnv: loader.LookupEnv, Vars: map[string]string{"HOME": "/tmp"}}, want: "--var HOME is not declared"},
+	} {
+		if _, _, err := tc.loader.LoadAndCompile(dir); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("expected %q error, got %v", tc.want, err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, PrimaryPolicyPath), []byte(`
+version: 1
+variables:
+  VCPUS: "4"
+  PORT: "8443"
+sandbox:
+  image:
+    ref: `+validImageRef+`
+  resources:
+    vcpus: ${VCPUS}
+  network:
+    default: deny
+    allow:
+      - host: api.internal
+        ports:
+          - ${PORT}
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	numeric, _, err := (Loader{}).LoadAndCompile(dir)
+	if err != nil {
+		t.Fatalf("load and compile numeric variables: %v", err)
+	}
+	if numeric.Resources == nil || numeric.Resources.VCPUs != 4 || !numeric.Allows("api.internal", 8443) {
+		t.Fatalf("expected variables to set numbers: %+v", numeric)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, PrimaryPolicyPath), []byte("version: 1\nsandbox:\n  image:\n    ref: ${IMAGE}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := (Loader{}).LoadAndCompile(dir); err == nil || !strings.Contains(err.Error(), "${IMAGE} is not declared in variables") {
+		t.Fatalf("expected an undeclared variable error, got %v", err)
+	}
+}
+
+func TestSchemaCoversPolicyFields(t *testing.T) {
+	t.Parallel()
+
+	var doc map[string]any
+	if err := json.Unmarshal(Schema(), &doc); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+	defs, _ := doc["$defs"].(map[string]any)
+
+	var walk func(path string, typ reflect.Type, node map[string]any)
+	walk = func(path string, typ reflect.Type, node map[string]any) {
+		if ref, ok := node["$ref"].(string); ok {
+			node, _ = defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any)
+		}
+		for typ.Kind() == reflect.Slice || typ.Kind() == reflect.Pointer {
+			if typ.Kind() == reflect.Slice {
+				if items, ok := node["items"].(map[string]any); ok {
+					node = items
+				}
+			}
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct {
+			return
+		}
+		props, _ := node["properties"].(map[string]any)
+		fields := map[string]bool{}
+		for i := 0; i < typ.NumField(); i++ {
+			name, _, _ := strings.Cut(typ.Field(i).Tag.Get("yaml"), ",")
+			if name == "" {
+				continue
+			}
+			fields[name] = true
+			child, ok := props[name].(map[string]any)
+			if !ok {
+				t.Errorf("schema does not describe %s%s", path, name)
+				continue
+			}
+			walk(path+name+".", typ.Field(i).Type, child)
+		}
+		for name := range props {
+			if !fields[name] {
+				t.Errorf("schema describes %s%s, which policies do not have", path, name)
+			}
+		}
+	}
+	walk("", reflect.TypeOf(rawPolicy{}), doc)
+}
+
+func TestCompileExitCodes(t *testing.T) {
+	t.Parallel()
+
+	raw := baseRawPolicy()
+	raw.Sandbox.ExitCodes = []rawExitCodeRule{
+		{Codes: []int{75, 69, 75}, Retriable: true},
+		{Codes: []int{77}, Status: "Skipped", Annotations: map[string]string{"reason": "no-tests"}},
+	}
+	compiled, err := Compile(raw)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	want := []ExitCodeRule{
+		{Codes: []int{69, 75}, Retriable: true},
+		{Codes: []int{77}, Status: ExitStatusSkipped, Annotations: map[string]string{"reason": "no-tests"}},
+	}
+	if !reflect.DeepEqual(compiled.ExitCodes, want) {
+		t.Fatalf("unexpected exit codes: %+v", compiled.ExitCodes)
+	}
+	if rule := compiled.ExitCodeRule(69); rule == nil || !rule.Retriable {
+		t.Fatalf("expected exit 69 to be retriable, got %+v", rule)
+	}
+	if rule := compiled.ExitCodeRule(1); rule != nil {
+		t.Fatalf("expected no rule for exit 1, got %+v", rule)
+	}
+	roundTripped, err := FromProto(compiled.ToProto())
+	if err != nil {
+		t.Fatalf("FromProto returned error: %v", err)
+	}
+	if roundTripped.Hash != compiled.Hash {
+		t.Fatalf("hash changed over proto round trip: %s != %s", roundTripped.Hash, compiled.Hash)
+	}
+
+	for _, tc := range []struct {
+		rules []rawExitCodeRule
+		want  string
+	}{
+		{[]rawExitCodeRule{{Status: "skipped"}}, "codes must not be empty"},
+		{[]rawExitCodeRule{{Codes: []int{256}, Status: "skipped"}}, "outside 0-255"},
+		{[]rawExitCodeRule{{Codes: []int{3}, Status: "flaky"}}, "status \"flaky\""},
+		{[]rawExitCodeRule{{Codes: []int{3}}}, "set status, retriable or annotations"},
+		{[]rawExitCodeRule{{Codes: []int{3}, Retriable: true}, {Codes: []int{3}, Status: "skipped"}}, "already mapped"},
+	} {
+		raw.Sandbox.ExitCodes = tc.rules
+		if _, err := Compile(raw); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("Compile(%+v): expected %q, got %v", tc.rules, tc.want, err)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package policy
+
+import _ "embed"
+
+// schema is the JSON Schema for policy files. Keep it in step with
+// rawPolicy; TestSchemaCoversPolicyFields checks that every field is
+// described.
+//
+//go:embed schema.json
+var schema []byte
+
+// Schema returns the JSON Schema (draft 2020-12) describing version 1
+// policy files, for editors and CI to validate cleanroom.yaml against.
+func Schema() []byte {
+	return append([]byte(nil), schema...)
+}
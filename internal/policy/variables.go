@@ -0,0 +1,118 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	variableName = regexp.MustCompile(`^[A-Z_][A-Z0-9_]*$`)
+	// variableRef matches ${NAME}, and $$ as an escaped dollar sign.
+	variableRef = regexp.MustCompile(`\$\$|\$\{([^}]*)\}`)
+)
+
+// resolveVariables returns the value of each variable the policy declares:
+// Loader.Vars first, then the environment, then the declared default.
+// Only declared variables are read, so a policy cannot pull arbitrary
+// environment into the sandbox.
+func (l Loader) resolveVariables(declared map[string]*string) (map[string]string, error) {
+	for name := range l.Vars {
+		if _, ok := declared[name]; !ok {
+			return nil, fmt.Errorf("--var %s is not declared in the policy's variables", name)
+		}
+	}
+	if len(declared) == 0 {
+		return nil, nil
+	}
+	lookupEnv := l.LookupEnv
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+
+	names := make([]string, 0, len(declared))
+	for name := range declared {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	resolved := make(map[string]string, len(declared))
+	for _, name := range names {
+		if !variableName.MatchString(name) {
+			return nil, fmt.Errorf("variables: name %q must be upper case letters, digits and underscores", name)
+		}
+		if value, ok := l.Vars[name]; ok {
+			resolved[name] = value
+		} else if value, ok := lookupEnv(name); ok {
+			resolved[name] = value
+		} else if def := declared[name]; def != nil {
+			resolved[name] = *def
+		} else {
+			return nil, fmt.Errorf("variable %s is not set: pass --var %s=VALUE or set %s in the environment", name, name, name)
+		}
+	}
+	return resolved, nil
+}
+
+// substituteVariables replaces ${NAME} in the scalar values of the policy
+// document doc. Keys are left alone, as is the variables section itself,
+// and values are substituted after parsing so they can never change the
+// document's shape.
+func substituteVariables(doc *yaml.Node, vars map[string]string) error {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return substituteNode(doc, vars)
+	}
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "variables" {
+			continue
+		}
+		if err := substituteNode(root.Content[i+1], vars); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func substituteNode(node *yaml.Node, vars map[string]string) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := substituteNode(child, vars); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := substituteNode(node.Content[i], vars); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "$") {
+			return nil
+		}
+		var err error
+		node.Value = variableRef.ReplaceAllStringFunc(node.Value, func(ref string) string {
+			if ref == "$$" {
+				return "$"
+			}
+			name := ref[2 : len(ref)-1]
+			value, ok := vars[name]
+			if !ok && err == nil {
+				err = fmt.Errorf("line %d: ${%s} is not declared in variables", node.Line, name)
+			}
+			return value
+		})
+		if node.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+			// Resolve a plain value's type from its substituted text, so
+			// a variable can set numbers as well as strings.
+			node.Tag = ""
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package rundir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/objectstore"
+)
+
+// archivedFiles are the well-known entries Archive copies, besides the
+// manifest and everything under DiagnosticsDir. Backend files such as
+// sockets, disk images and VM configs stay on the host.
+var archivedFiles = []string{ObservabilityFile, RequestedCommandFile, PlanFile, ResolutionsFile}
+
+// Archive copies the records in the run directory dir to store under
+// <runID>/, so run history outlives the host. Entries the run did not
+// write are skipped. The manifest is copied last, so an archived run with
+// a manifest is complete.
+func Archive(ctx context.Context, store objectstore.Store, dir, runID string) error {
+	names := append([]string(nil), archivedFiles...)
+	diagnostics := filepath.Join(dir, DiagnosticsDir)
+	err := filepath.WalkDir(diagnostics, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == diagnostics {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if entry.Type().IsRegular() {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			names = append(names, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	names = append(names, ManifestFile)
+
+	for _, name := range names {
+		if err := archiveFile(ctx, store, filepath.Join(dir, filepath.FromSlash(name)), runID+"/"+name); err != nil {
+			return fmt.Errorf("archive %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func archiveFile(ctx context.Context, store objectstore.Store, path, key string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return store.Put(ctx, key, f, info.Size())
+}
+
+// ReadArchived returns the entry name of runID's archived copy. A missing
+// run or entry returns an error satisfying errors.Is(err, fs.ErrNotExist).
+func ReadArchived(ctx context.Context, store objectstore.Store, runID, name string) ([]byte, error) {
+	rc, err := store.Get(ctx, runID+"/"+name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// ArchivedRuns returns the IDs of runs with a complete archived copy in
+// store, sorted.
+func ArchivedRuns(ctx context.Context, store objectstore.Store) ([]string, error) {
+	keys, err := store.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	var runs []string
+	for _, key := range keys {
+		if runID, name, ok := strings.Cut(key, "/"); ok && name == ManifestFile {
+			runs = append(runs, runID)
+		}
+	}
+	return runs, nil
+}
@@ -0,0 +1,92 @@
+// Package rundir defines the layout of the per-execution run directories
+// under paths.RunBaseDir, the retention sweep that bounds them, and the
+// archive that keeps their records in an object store.
+package rundir
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LayoutVersion is bumped whenever the meaning of a well-known entry
+// changes, so tools reading old run directories can tell them apart.
+const LayoutVersion = 1
+
+// Well-known entries of a run directory. Backends may add their own files
+// (sockets, VM configs, logs) alongside these.
+const (
+	ManifestFile         = "run-manifest.json"
+	ObservabilityFile    = "run-observability.json"
+	RequestedCommandFile = "requested-command.json"
+	PlanFile             = "plan.json"
+	ResolutionsFile      = "policy-resolutions.json"
+	DiagnosticsDir       = "diagnostics"
+)
+
+// layout describes the well-known entries in every manifest.
+var layout = map[string]string{
+	ManifestFile:         "this manifest",
+	ObservabilityFile:    "phase timings and outcome written when the run ends",
+	RequestedCommandFile: "the command the run was asked to execute",
+	PlanFile:             "the execution plan, for plan-only runs",
+	ResolutionsFile:      "the addresses each policy allow host resolved to for egress rules",
+	DiagnosticsDir:       "host and guest diagnostics collected when the VM failed",
+}
+
+// Manifest identifies a run directory and the layout it was written with.
+type Manifest struct {
+	Version   int               `json:"version"`
+	RunID     string            `json:"run_id"`
+	Backend   string            `json:"backend,omitempty"`
+	SandboxID string            `json:"sandbox_id,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	Layout    map[string]string `json:"layout"`
+}
+
+// Create makes dir and writes its manifest. Version and Layout are filled
+// in, and CreatedAt defaults to now.
+func Create(dir string, m Manifest) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create run directory: %w", err)
+	}
+	m.Version = LayoutVersion
+	m.Layout = layout
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now().UTC()
+	}
+	raw, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, ManifestFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(raw, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write run manifest: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("write run manifest: %w", err)
+	}
+	return nil
+}
+
+// ReadManifest reads dir's manifest. Run directories written before
+// manifests existed return an error satisfying errors.Is(err, fs.ErrNotExist).
+func ReadManifest(dir string) (Manifest, error) {
+	path := filepath.Join(dir, ManifestFile)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Manifest{}, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return Manifest{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if m.Version > LayoutVersion {
+		return m, fmt.Errorf("%s has layout version %d, newer than supported version %d", path, m.Version, LayoutVersion)
+	}
+	return m, nil
+}
@@ -0,0 +1,102 @@
+package rundir
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buildkite/cleanroom/internal/objectstore"
+)
+
+func TestCreateWritesManifest(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "run-1")
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := Create(dir, Manifest{RunID: "run-1", Backend: "firecracker", SandboxID: "cr-1", CreatedAt: created}); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	got, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatalf("ReadManifest returned error: %v", err)
+	}
+	if got.Version != LayoutVersion || got.RunID != "run-1" || got.Backend != "firecracker" || got.SandboxID != "cr-1" || !got.CreatedAt.Equal(created) {
+		t.Fatalf("unexpected manifest: %+v", got)
+	}
+	if _, ok := got.Layout[ObservabilityFile]; !ok {
+		t.Fatalf("expected layout to describe %s, got %v", ObservabilityFile, got.Layout)
+	}
+
+	if _, err := ReadManifest(t.TempDir()); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected a missing manifest to be ErrNotExist, got %v", err)
+	}
+}
+
+func TestArchiveCopiesRecordsManifestLast(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "run-1")
+	if err := Create(dir, Manifest{RunID: "run-1"}); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		ObservabilityFile:                   `{"outcome":"ok"}`,
+		"firecracker.sock":                  "",
+		DiagnosticsDir + "/serial.log":      "panic",
+		DiagnosticsDir + "/guest/dmesg.txt": "oom",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := &recordingStore{Dir: objectstore.Dir{Root: t.TempDir()}}
+	ctx := context.Background()
+	if err := Archive(ctx, store, dir, "run-1"); err != nil {
+		t.Fatalf("Archive returned error: %v", err)
+	}
+	want := []string{
+		"run-1/" + ObservabilityFile,
+		"run-1/" + DiagnosticsDir + "/guest/dmesg.txt",
+		"run-1/" + DiagnosticsDir + "/serial.log",
+		"run-1/" + ManifestFile,
+	}
+	if !reflect.DeepEqual(store.puts, want) {
+		t.Fatalf("expected puts %v, got %v", want, store.puts)
+	}
+	if got, err := ReadArchived(ctx, store, "run-1", ObservabilityFile); err != nil || string(got) != `{"outcome":"ok"}` {
+		t.Fatalf("unexpected archived observability %q (%v)", got, err)
+	}
+	if _, err := ReadArchived(ctx, store, "run-2", ObservabilityFile); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected a missing run to be ErrNotExist, got %v", err)
+	}
+
+	// A run whose manifest never made it is incomplete and not listed.
+	if err := store.Put(ctx, "run-0/"+ObservabilityFile, strings.NewReader("{}"), 2); err != nil {
+		t.Fatal(err)
+	}
+	if runs, err := ArchivedRuns(ctx, store); err != nil || !reflect.DeepEqual(runs, []string{"run-1"}) {
+		t.Fatalf("unexpected archived runs %v (%v)", runs, err)
+	}
+}
+
+type recordingStore struct {
+	objectstore.Dir
+	puts []string
+}
+
+func (s *recordingStore) Put(ctx context.Context, key string, body io.ReadSeeker, size int64) error {
+	s.puts = append(s.puts, key)
+	return s.Dir.Put(ctx, key, body, size)
+}
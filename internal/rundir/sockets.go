@@ -0,0 +1,149 @@
+package rundir
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// SocketPath returns where a backend binds the unix socket name for the
+// run in runDir. With socketDir empty it is runDir/name. Otherwise it is in
+// a per-run directory under socketDir whose short name keeps the path
+// within the platform's limit however deep runDir is, and runDir/name is a
+// symlink to it. A path that still does not fit is an error.
+func SocketPath(runDir, socketDir, name string) (string, error) {
+	path := filepath.Join(runDir, name)
+	if socketDir != "" {
+		dir := runSocketDir(runDir, socketDir)
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return "", fmt.Errorf("create socket directory: %w", err)
+		}
+		path = filepath.Join(dir, name)
+		link := filepath.Join(runDir, name)
+		_ = os.Remove(link)
+		if err := os.Symlink(path, link); err != nil {
+			return "", fmt.Errorf("link socket into run directory: %w", err)
+		}
+	}
+	if err := CheckSocketPath(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// RemoveSockets removes the per-run directory SocketPath made under
+// socketDir for runDir, with any sockets still in it.
+func RemoveSockets(runDir, socketDir string) {
+	if socketDir == "" {
+		return
+	}
+	_ = os.RemoveAll(runSocketDir(runDir, socketDir))
+}
+
+// runSocketDir names runDir's directory under socketDir after a hash of
+// its path, so runs with the same ID under different bases stay apart.
+func runSocketDir(runDir, socketDir string) string {
+	abs, err := filepath.Abs(runDir)
+	if err != nil {
+		abs = runDir
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(socketDir, hex.EncodeToString(sum[:8]))
+}
+
+// maxSocketPathLen is the longest path a unix socket can be bound at:
+// sun_path less its terminating NUL.
+func maxSocketPathLen() int {
+	if runtime.GOOS == "linux" {
+		return 107
+	}
+	return 103
+}
+
+// CheckSocketPath fails when path is too long to bind a unix socket at.
+func CheckSocketPath(path string) error {
+	if max := maxSocketPathLen(); len(path) > max {
+		return fmt.Errorf("socket path %q is too long: max length is %d bytes, got %d; set socket_dir to a short directory such as /run/cleanroom", path, max, len(path))
+	}
+	return nil
+}
+
+// DefaultSocketMinAge is how old a socket must be before a scavenge may
+// remove it. A younger one may belong to a process that has bound it but
+// is not listening yet.
+const DefaultSocketMinAge = time.Minute
+
+// socketDialTimeout bounds the probe of each socket.
+const socketDialTimeout = time.Second
+
+// SocketScavengeResult reports what a socket scavenge removed.
+type SocketScavengeResult struct {
+	Scanned int
+	Removed []string // paths
+}
+
+// ScavengeSockets removes the unix sockets under the run directories in
+// baseDir, or the per-run directories of a socket dir, that no live
+// process listens on, such as the Firecracker API and vsock sockets a
+// crashed VM leaves behind. A socket is only removed when a connection to
+// it is refused; sockets that accept, or cannot be probed, for example
+// because another user owns them, are left alone, as are those modified
+// within minAge. Directories a removal leaves empty are removed too.
+func ScavengeSockets(baseDir string, minAge time.Duration, now time.Time) (SocketScavengeResult, error) {
+	var result SocketScavengeResult
+	if minAge <= 0 {
+		minAge = DefaultSocketMinAge
+	}
+	err := filepath.WalkDir(baseDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == baseDir {
+				return err
+			}
+			// Run directories come and go while the walk runs.
+			return nil
+		}
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), deletingPrefix) {
+			return fs.SkipDir
+		}
+		if entry.Type()&fs.ModeSocket == 0 {
+			return nil
+		}
+		result.Scanned++
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) < minAge || !socketIsStale(path) {
+			return nil
+		}
+		if err := os.Remove(path); err == nil {
+			result.Removed = append(result.Removed, path)
+			if dir := filepath.Dir(path); dir != baseDir {
+				// Fails unless the directory is now empty.
+				_ = os.Remove(dir)
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return result, nil
+	}
+	return result, err
+}
+
+// socketIsStale reports whether connecting to the socket at path is
+// refused, which means nothing listens on it.
+func socketIsStale(path string) bool {
+	conn, err := net.DialTimeout("unix", path, socketDialTimeout)
+	if err == nil {
+		_ = conn.Close()
+		return false
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
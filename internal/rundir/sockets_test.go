@@ -0,0 +1,93 @@
+package rundir
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenUnix binds a socket at path; closing the listener leaves the file
+// behind, as a crashed process would.
+func listenUnix(t *testing.T, path string) *net.UnixListener {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.SetUnlinkOnClose(false)
+	return l
+}
+
+func TestScavengeSocketsRemovesOnlyStaleSockets(t *testing.T) {
+	t.Parallel()
+
+	base := t.TempDir()
+	now := time.Now()
+	old := now.Add(-time.Hour)
+
+	live := listenUnix(t, filepath.Join(base, "live", "firecracker.sock"))
+	defer live.Close()
+	crashed := filepath.Join(base, "crashed", "vsock.sock")
+	listenUnix(t, crashed).Close()
+	young := filepath.Join(base, "young", "vsock.sock")
+	listenUnix(t, young).Close()
+	for _, path := range []string{filepath.Join(base, "live", "firecracker.sock"), crashed} {
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := ScavengeSockets(base, 0, now)
+	if err != nil {
+		t.Fatalf("ScavengeSockets returned error: %v", err)
+	}
+	if result.Scanned != 3 || !reflect.DeepEqual(result.Removed, []string{crashed}) {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	for _, path := range []string{filepath.Join(base, "live", "firecracker.sock"), young} {
+		if _, err := os.Lstat(path); err != nil {
+			t.Fatalf("expected %s to be kept: %v", path, err)
+		}
+	}
+
+	if _, err := ScavengeSockets(filepath.Join(base, "missing"), 0, now); err != nil {
+		t.Fatalf("expected a missing base directory to be fine, got %v", err)
+	}
+}
+
+func TestSocketPathBindsInSocketDirAndLinksFromRunDir(t *testing.T) {
+	t.Parallel()
+
+	socketDir := t.TempDir()
+	runDir := filepath.Join(t.TempDir(), strings.Repeat("nested-", 12), "run-1")
+	if err := os.MkdirAll(runDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SocketPath(runDir, "", "vsock.sock"); err == nil || !strings.Contains(err.Error(), "socket_dir") {
+		t.Fatalf("expected a deep run directory to need socket_dir, got %v", err)
+	}
+
+	path, err := SocketPath(runDir, socketDir, "vsock.sock")
+	if err != nil {
+		t.Fatalf("SocketPath returned error: %v", err)
+	}
+	if filepath.Dir(filepath.Dir(path)) != socketDir {
+		t.Fatalf("expected the socket under %s, got %s", socketDir, path)
+	}
+	listenUnix(t, path).Close()
+	if target, err := os.Readlink(filepath.Join(runDir, "vsock.sock")); err != nil || target != path {
+		t.Fatalf("expected the run directory to link to %s, got %q %v", path, target, err)
+	}
+
+	RemoveSockets(runDir, socketDir)
+	if _, err := os.Lstat(filepath.Dir(path)); !os.IsNotExist(err) {
+		t.Fatalf("expected the run's socket directory to be removed, got %v", err)
+	}
+}
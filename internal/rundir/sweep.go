@@ -0,0 +1,152 @@
+package rundir
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// deletingPrefix marks a run directory that a sweep has claimed. Renaming
+// is atomic, so a directory is either still in place under its run ID or
+// already out of reach of anything that looks it up by ID.
+const deletingPrefix = ".deleting-"
+
+// DefaultMinIdle is how recently a run directory may have been written and
+// still be left alone when Policy.MinIdle is unset.
+const DefaultMinIdle = 10 * time.Minute
+
+// Policy bounds the run directories under a base directory. Zero limits
+// are unbounded.
+type Policy struct {
+	MaxTotalBytes int64
+	MaxAge        time.Duration
+	// MinIdle protects directories written to this recently, which may
+	// belong to a run in another process that the active check cannot see.
+	MinIdle time.Duration
+}
+
+// SweepResult reports what a sweep removed.
+type SweepResult struct {
+	Scanned    int
+	Removed    []string // run IDs
+	FreedBytes int64
+	// TotalBytes is the size of what is left, including active runs.
+	TotalBytes int64
+}
+
+type runDirInfo struct {
+	name         string
+	bytes        int64
+	lastModified time.Time
+}
+
+// Sweep removes run directories under baseDir that are older than
+// policy.MaxAge, then the least recently written ones until the rest fit in
+// policy.MaxTotalBytes. Directories for which active returns true, or that
+// were written within policy.MinIdle, are never removed. Sizes are apparent
+// file sizes, so sparse disk images count at their full length.
+//
+// Each directory is renamed aside before it is deleted, so concurrent sweeps
+// and readers never see a half-removed run under its ID. Leftovers from an
+// interrupted sweep are removed first.
+func Sweep(baseDir string, policy Policy, active func(runID string) bool, now time.Time) (SweepResult, error) {
+	var result SweepResult
+	entries, err := os.ReadDir(baseDir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return result, nil
+		}
+		return result, err
+	}
+	minIdle := policy.MinIdle
+	if minIdle <= 0 {
+		minIdle = DefaultMinIdle
+	}
+
+	var candidates []runDirInfo
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, deletingPrefix) {
+			_ = os.RemoveAll(filepath.Join(baseDir, name))
+			continue
+		}
+		if !entry.IsDir() {
+			continue
+		}
+		info, ok := scanRunDir(filepath.Join(baseDir, name))
+		if !ok {
+			continue
+		}
+		info.name = name
+		result.Scanned++
+		result.TotalBytes += info.bytes
+		if active(name) || now.Sub(info.lastModified) < minIdle {
+			continue
+		}
+		candidates = append(candidates, info)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].lastModified.Before(candidates[j].lastModified)
+	})
+	for _, candidate := range candidates {
+		expired := policy.MaxAge > 0 && now.Sub(candidate.lastModified) > policy.MaxAge
+		overBudget := policy.MaxTotalBytes > 0 && result.TotalBytes > policy.MaxTotalBytes
+		if !expired && !overBudget {
+			// Candidates are oldest first, so nothing later is expired either.
+			break
+		}
+		// The run may have been picked up again since the scan.
+		if active(candidate.name) || !removeRunDir(baseDir, candidate.name) {
+			continue
+		}
+		result.Removed = append(result.Removed, candidate.name)
+		result.FreedBytes += candidate.bytes
+		result.TotalBytes -= candidate.bytes
+	}
+	return result, nil
+}
+
+// scanRunDir sums the sizes of the files under dir and finds the most
+// recent modification time of dir or anything in it. Entries that vanish
+// mid-walk are ignored.
+func scanRunDir(dir string) (runDirInfo, bool) {
+	var info runDirInfo
+	root, err := os.Lstat(dir)
+	if err != nil {
+		return info, false
+	}
+	info.lastModified = root.ModTime()
+	_ = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if fi.Mode().IsRegular() {
+			info.bytes += fi.Size()
+		}
+		if fi.ModTime().After(info.lastModified) {
+			info.lastModified = fi.ModTime()
+		}
+		return nil
+	})
+	return info, true
+}
+
+// removeRunDir claims name by renaming it aside, then deletes it. It returns
+// false if the directory was already gone or could not be claimed.
+func removeRunDir(baseDir, name string) bool {
+	claimed := filepath.Join(baseDir, deletingPrefix+name)
+	if err := os.Rename(filepath.Join(baseDir, name), claimed); err != nil {
+		return false
+	}
+	_ = os.RemoveAll(claimed)
+	return true
+}
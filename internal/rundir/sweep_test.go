@@ -0,0 +1,138 @@
+package rundir
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSweepRemovesExpiredRuns(t *testing.T) {
+	t.Parallel()
+
+	base := t.TempDir()
+	now := time.Now()
+	writeRun(t, base, "old", 10, now.Add(-48*time.Hour))
+	writeRun(t, base, "old-active", 10, now.Add(-48*time.Hour))
+	writeRun(t, base, "fresh", 10, now.Add(-time.Hour))
+
+	result, err := Sweep(base, Policy{MaxAge: 24 * time.Hour}, isOneOf("old-active"), now)
+	if err != nil {
+		t.Fatalf("Sweep returned error: %v", err)
+	}
+	if want := []string{"old"}; !reflect.DeepEqual(result.Removed, want) {
+		t.Fatalf("unexpected removed runs: got %v want %v", result.Removed, want)
+	}
+	if result.Scanned != 3 || result.FreedBytes != 10 || result.TotalBytes != 20 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if got, want := runNames(t, base), []string{"fresh", "old-active"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected runs left: got %v want %v", got, want)
+	}
+}
+
+func TestSweepRemovesOldestRunsOverBudget(t *testing.T) {
+	t.Parallel()
+
+	base := t.TempDir()
+	now := time.Now()
+	writeRun(t, base, "a", 100, now.Add(-4*time.Hour))
+	writeRun(t, base, "b", 100, now.Add(-3*time.Hour))
+	writeRun(t, base, "c", 100, now.Add(-2*time.Hour))
+	writeRun(t, base, "d", 100, now.Add(-time.Minute))
+
+	result, err := Sweep(base, Policy{MaxTotalBytes: 150}, isOneOf(), now)
+	if err != nil {
+		t.Fatalf("Sweep returned error: %v", err)
+	}
+	// d is within MinIdle, so it stays even though the total is still over.
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(result.Removed, want) {
+		t.Fatalf("unexpected removed runs: got %v want %v", result.Removed, want)
+	}
+	if result.TotalBytes != 100 {
+		t.Fatalf("unexpected remaining bytes: %d", result.TotalBytes)
+	}
+
+	writeRun(t, base, "e", 100, now.Add(-time.Hour))
+	result, err = Sweep(base, Policy{MaxTotalBytes: 250}, isOneOf(), now)
+	if err != nil {
+		t.Fatalf("Sweep returned error: %v", err)
+	}
+	if len(result.Removed) != 0 {
+		t.Fatalf("expected runs under budget to be kept, removed %v", result.Removed)
+	}
+}
+
+func TestSweepFinishesInterruptedDeletes(t *testing.T) {
+	t.Parallel()
+
+	base := t.TempDir()
+	now := time.Now()
+	writeRun(t, base, deletingPrefix+"half-gone", 10, now)
+	if err := os.WriteFile(filepath.Join(base, "stray-file"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Sweep(base, Policy{}, isOneOf(), now)
+	if err != nil {
+		t.Fatalf("Sweep returned error: %v", err)
+	}
+	if result.Scanned != 0 {
+		t.Fatalf("expected no runs scanned, got %+v", result)
+	}
+	if got, want := runNames(t, base), []string{"stray-file"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected entries left: got %v want %v", got, want)
+	}
+
+	if _, err := Sweep(filepath.Join(base, "missing"), Policy{MaxAge: time.Hour}, isOneOf(), now); err != nil {
+		t.Fatalf("expected a missing base directory to be ignored, got %v", err)
+	}
+}
+
+func writeRun(t *testing.T, base, name string, size int, modified time.Time) {
+	t.Helper()
+	dir := filepath.Join(base, name)
+	if err := Create(dir, Manifest{RunID: name}); err != nil {
+		t.Fatal(err)
+	}
+	// Leave only the payload so sizes are exact.
+	if err := os.Remove(filepath.Join(dir, ManifestFile)); err != nil {
+		t.Fatal(err)
+	}
+	payload := filepath.Join(dir, "rootfs-ephemeral.ext4")
+	if err := os.WriteFile(payload, make([]byte, size), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{payload, dir} {
+		if err := os.Chtimes(p, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func runNames(t *testing.T, base string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isOneOf(ids ...string) func(string) bool {
+	return func(id string) bool {
+		for _, candidate := range ids {
+			if id == candidate {
+				return true
+			}
+		}
+		return false
+	}
+}
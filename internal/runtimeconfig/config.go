@@ -6,14 +6,38 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 
+	"github.com/buildkite/cleanroom/internal/bootassets"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	DefaultBackend string   `yaml:"default_backend"`
-	Backends       Backends `yaml:"backends"`
+	DefaultBackend string       `yaml:"default_backend"`
+	Host           string       `yaml:"host,omitempty"`
+	TLSCA          string       `yaml:"tls_ca,omitempty"`
+	SocketDir      string       `yaml:"socket_dir,omitempty"` // short directory for VM sockets, e.g. /run/cleanroom; default the run directory
+	Backends       Backends     `yaml:"backends"`
+	Devices        Devices      `yaml:"devices,omitempty"`
+	Approval       Approval     `yaml:"approval,omitempty"`
+	Namespaces     Namespaces   `yaml:"namespaces,omitempty"`
+	Budgets        Budgets      `yaml:"budgets,omitempty"`
+	Runs           Runs         `yaml:"runs,omitempty"`
+	Executions     Executions   `yaml:"executions,omitempty"`
+	Logging        Logging      `yaml:"logging,omitempty"`
+	Events         Events       `yaml:"events,omitempty"`
+	LogShipping    LogShipping  `yaml:"log_shipping,omitempty"`
+	Storage        Storage      `yaml:"storage,omitempty"`
+	CacheSharing   CacheSharing `yaml:"cache_sharing,omitempty"`
+	State          State        `yaml:"state,omitempty"`
+
+	// Profiles hold partial configs keyed by name. Selecting one overlays
+	// the keys it sets onto the top-level config.
+	Profiles map[string]yaml.Node `yaml:"profiles,omitempty"`
+
+	// Profile is the name of the applied profile, if any.
+	Profile string `yaml:"-"`
 }
 
 type Backends struct {
@@ -24,12 +48,30 @@ type Backends struct {
 type FirecrackerConfig struct {
 	BinaryPath           string         `yaml:"binary_path"`
 	KernelImage          string         `yaml:"kernel_image"`
+	KernelSource         *AssetSource   `yaml:"kernel_source,omitempty"` // fetched and pinned instead of the managed kernel
 	RootFS               string         `yaml:"rootfs"`
 	Services             ServicesConfig `yaml:"services"`
 	PrivilegedMode       string         `yaml:"privileged_mode"`
 	PrivilegedHelperPath string         `yaml:"privileged_helper_path"`
 	VCPUs                int64          `yaml:"vcpus"`
 	MemoryMiB            int64          `yaml:"memory_mib"`
+	MaxVCPUs             int64          `yaml:"max_vcpus,omitempty"`          // cap on policy sandbox.resources.vcpus
+	MaxMemoryMiB         int64          `yaml:"max_memory_mib,omitempty"`     // cap on policy sandbox.resources.memory_mib
+	MaxDiskMiB           int64          `yaml:"max_disk_mib,omitempty"`       // cap on policy sandbox.resources.disk_mib
+	EgressMbps           int64          `yaml:"egress_mbps,omitempty"`        // guest transmit limit; 0 is unlimited
+	IngressMbps          int64          `yaml:"ingress_mbps,omitempty"`       // guest receive limit; 0 is unlimited
+	MaxEgressMbps        int64          `yaml:"max_egress_mbps,omitempty"`    // cap on policy sandbox.resources.egress_mbps
+	MaxIngressMbps       int64          `yaml:"max_ingress_mbps,omitempty"`   // cap on policy sandbox.resources.ingress_mbps
+	DiskIOPS             int64          `yaml:"disk_iops,omitempty"`          // per-drive operations per second; 0 is unlimited
+	DiskMiBps            int64          `yaml:"disk_mibps,omitempty"`         // per-drive MiB per second; 0 is unlimited
+	MaxDiskIOPS          int64          `yaml:"max_disk_iops,omitempty"`      // cap on policy sandbox.resources.disk_iops
+	MaxDiskMiBps         int64          `yaml:"max_disk_mibps,omitempty"`     // cap on policy sandbox.resources.disk_mibps
+	CPUTemplate          string         `yaml:"cpu_template,omitempty"`       // Firecracker static CPU template, e.g. T2S or T2A
+	SMT                  bool           `yaml:"smt,omitempty"`                // expose SMT siblings to guests (x86_64 only)
+	NetworkPool          string         `yaml:"network_pool,omitempty"`       // IPv4 CIDR split into per-sandbox /24s
+	MTU                  int            `yaml:"mtu,omitempty"`                // TAP and guest interface MTU; 0 keeps 1500
+	NetworkNamespaces    bool           `yaml:"network_namespaces,omitempty"` // one network namespace per sandbox; requires privileged_mode sudo
+	ScratchFiles         int64          `yaml:"scratch_files,omitempty"`      // per-run rootfs files kept for reuse; 0 removes each after its run
 	GuestCID             uint32         `yaml:"guest_cid"`
 	GuestPort            uint32         `yaml:"guest_port"`
 	LaunchSeconds        int64          `yaml:"launch_seconds"` // VM boot/guest-agent readiness timeout
@@ -37,14 +79,185 @@ type FirecrackerConfig struct {
 
 type DarwinVZConfig struct {
 	KernelImage   string         `yaml:"kernel_image"`
+	KernelSource  *AssetSource   `yaml:"kernel_source,omitempty"` // fetched and pinned instead of the managed kernel
 	RootFS        string         `yaml:"rootfs"`
+	RootFSSource  *AssetSource   `yaml:"rootfs_source,omitempty"` // fetched and pinned instead of deriving one from the image
 	Services      ServicesConfig `yaml:"services"`
 	VCPUs         int64          `yaml:"vcpus"`
 	MemoryMiB     int64          `yaml:"memory_mib"`
+	MaxVCPUs      int64          `yaml:"max_vcpus,omitempty"`      // cap on policy sandbox.resources.vcpus
+	MaxMemoryMiB  int64          `yaml:"max_memory_mib,omitempty"` // cap on policy sandbox.resources.memory_mib
 	GuestPort     uint32         `yaml:"guest_port"`
 	LaunchSeconds int64          `yaml:"launch_seconds"` // VM boot/guest-agent readiness timeout
 }
 
+// AssetSource is where a backend fetches a boot asset from instead of the
+// managed defaults, such as an internal mirror for air-gapped hosts. The
+// SHA256 of the file pins it; a verified cached copy is used offline.
+type AssetSource struct {
+	URL    string `yaml:"url,omitempty"` // https://, http:// or file://
+	OCI    string `yaml:"oci,omitempty"` // repo@sha256:<digest> artifact whose layer is the file
+	SHA256 string `yaml:"sha256"`
+}
+
+// Source converts s for bootassets; a nil s is the zero Source.
+func (s *AssetSource) Source() bootassets.Source {
+	if s == nil {
+		return bootassets.Source{}
+	}
+	return bootassets.Source{URL: s.URL, OCI: s.OCI, SHA256: s.SHA256}
+}
+
+// Devices lists host devices that policies may ask to have passed through
+// to a sandbox. Passthrough is experimental and no current backend can
+// attach devices yet.
+type Devices struct {
+	VFIO []VFIODevice `yaml:"vfio,omitempty"`
+}
+
+// VFIODevice is a host PCI device bound to vfio-pci. Policies refer to it by
+// Name.
+type VFIODevice struct {
+	Name       string `yaml:"name"`
+	PCIAddress string `yaml:"pci_address"` // e.g. 0000:65:00.0
+}
+
+// Approval holds executions in PENDING_APPROVAL until a human approves or
+// denies them. An execution needs approval when its caller matches one of
+// Identities or its sandbox carries any of Labels. When Approvers is set,
+// only those identities may decide; nobody may decide their own execution.
+type Approval struct {
+	Identities     []string          `yaml:"identities,omitempty"` // e.g. uid:1001
+	Approvers      []string          `yaml:"approvers,omitempty"`  // identities allowed to approve or deny (default: anyone else)
+	Labels         map[string]string `yaml:"labels,omitempty"`
+	WebhookURL     string            `yaml:"webhook_url,omitempty"`     // notified with the execution and policy context
+	TimeoutSeconds int64             `yaml:"timeout_seconds,omitempty"` // pending executions fail after this (default 600)
+}
+
+// Namespaces keeps teams sharing a serve host apart. Each caller works in
+// the namespace Identities maps it to, or "default", and sees and acts on
+// only that namespace's sandboxes. Admins may work in any namespace.
+type Namespaces struct {
+	Identities map[string]string `yaml:"identities,omitempty"` // caller identity, e.g. uid:1001, to namespace
+	Admins     []string          `yaml:"admins,omitempty"`     // identities that may list and act on every namespace
+}
+
+// Budgets caps the resources each namespace or identity may reserve in a
+// calendar month (UTC), counted as in usage reports. A sandbox created once
+// a budget passes its soft threshold gets a warning; once it is used up,
+// CreateSandbox is rejected until the next month.
+type Budgets struct {
+	Namespaces map[string]Budget `yaml:"namespaces,omitempty"`
+	Identities map[string]Budget `yaml:"identities,omitempty"`  // caller identity, e.g. uid:1001
+	WebhookURL string            `yaml:"webhook_url,omitempty"` // notified once a month when a budget passes a threshold
+}
+
+// Budget is one monthly budget. An unset limit is unlimited.
+type Budget struct {
+	VCPUHours      float64 `yaml:"vcpu_hours,omitempty"`
+	MemoryGiBHours float64 `yaml:"memory_gib_hours,omitempty"`
+	SoftPercent    int64   `yaml:"soft_percent,omitempty"` // warn at this share of a limit (default 80)
+}
+
+// ValidNamespace reports whether name can name a namespace: 1-63 lowercase
+// letters, digits or '-', starting and ending with a letter or digit.
+func ValidNamespace(name string) bool {
+	return namespacePattern.MatchString(name)
+}
+
+// Runs bounds the per-execution run directories serve keeps under the
+// state directory. Directories of executions still running are never
+// removed.
+type Runs struct {
+	MaxTotalMiB          int64 `yaml:"max_total_mib,omitempty"`          // remove the oldest runs beyond this (default unbounded)
+	MaxAgeHours          int64 `yaml:"max_age_hours,omitempty"`          // remove runs idle for longer (default 168)
+	SweepIntervalSeconds int64 `yaml:"sweep_interval_seconds,omitempty"` // how often serve checks (default 600)
+}
+
+// Executions bounds what callers may send with an execution, so a huge
+// command or stdin write fails with a clear error instead of overrunning
+// the guest agent's protocol. Unset limits default to the guest agent's
+// own, which are also the highest allowed.
+type Executions struct {
+	MaxArgs            int64 `yaml:"max_args,omitempty"`              // command arguments (default 32768)
+	MaxCommandBytes    int64 `yaml:"max_command_bytes,omitempty"`     // command arguments together (default 2 MiB)
+	MaxStdinFrameBytes int64 `yaml:"max_stdin_frame_bytes,omitempty"` // one stdin write (default 1 MiB)
+}
+
+// Logging configures serve's logs. The --log-level and --log-format flags
+// override Level and Format.
+type Logging struct {
+	Level  string            `yaml:"level,omitempty"`  // debug|info|warn|error (default info)
+	Format string            `yaml:"format,omitempty"` // text|json (default text)
+	Levels map[string]string `yaml:"levels,omitempty"` // per subsystem, e.g. gateway: debug
+	// File receives the logs instead of stderr. It is rotated once it
+	// reaches MaxSizeMiB, keeping MaxFiles old files.
+	File       string `yaml:"file,omitempty"`
+	MaxSizeMiB int64  `yaml:"max_size_mib,omitempty"` // default 100
+	MaxFiles   int64  `yaml:"max_files,omitempty"`    // default 5
+}
+
+// Events mirrors sandbox and execution events to a NATS server for
+// fleet-wide dashboards and autoscalers. Output chunks are not published.
+type Events struct {
+	NATSURL       string `yaml:"nats_url,omitempty"`       // nats://[user:pass@]host[:port], or tls://
+	SubjectPrefix string `yaml:"subject_prefix,omitempty"` // default cleanroom
+}
+
+// LogShipping uploads each execution's events and output, one NDJSON object
+// per execution, once it finishes. Credentials come from the gateway's
+// credential provider.
+type LogShipping struct {
+	URL      string `yaml:"url,omitempty"`      // s3://bucket/prefix, gs://bucket/prefix or https://host/path
+	Region   string `yaml:"region,omitempty"`   // S3 region (default us-east-1)
+	Endpoint string `yaml:"endpoint,omitempty"` // S3-compatible endpoint, e.g. https://minio.internal:9000
+}
+
+// Storage keeps records serve writes on the host in durable storage too,
+// so hosts that are replaced, such as autoscaled VMs, do not lose run
+// history. S3 credentials come from the gateway's credential provider.
+type Storage struct {
+	Runs     string `yaml:"runs,omitempty"`     // s3://bucket/prefix or an absolute directory; each run's records are copied there when it ends
+	Region   string `yaml:"region,omitempty"`   // S3 region (default us-east-1)
+	Endpoint string `yaml:"endpoint,omitempty"` // S3-compatible endpoint, e.g. https://minio.internal:9000
+}
+
+// CacheSharing lets serve hosts fetch cached images, and the rootfs their
+// backends prepared from them, from each other instead of the registry.
+// Peers authenticate each other with certificates signed by TLSCA.
+type CacheSharing struct {
+	Listen  string   `yaml:"listen,omitempty"` // host:port serving this host's cache to peers
+	Peers   []string `yaml:"peers,omitempty"`  // https://host:port of other hosts' listeners
+	TLSCert string   `yaml:"tls_cert,omitempty"`
+	TLSKey  string   `yaml:"tls_key,omitempty"`
+	TLSCA   string   `yaml:"tls_ca,omitempty"`
+}
+
+// State shares sandbox and execution records between serve instances
+// through etcd, so instances behind one load balancer can each answer
+// reads, and forward calls that need a sandbox's VM to the instance
+// running it.
+type State struct {
+	Etcd         []string `yaml:"etcd,omitempty"`          // https://host:2379 endpoints of one etcd cluster
+	Prefix       string   `yaml:"prefix,omitempty"`        // key prefix (default /cleanroom)
+	HostID       string   `yaml:"host_id,omitempty"`       // this instance's name (default the hostname)
+	AdvertiseURL string   `yaml:"advertise_url,omitempty"` // where other instances reach this one's control API
+	LeaseSeconds int      `yaml:"lease_seconds,omitempty"` // how long records outlive an instance that stops renewing them (default 10)
+	TLSCA        string   `yaml:"tls_ca,omitempty"`        // CA for other instances' control API and client certificates
+	TLSCert      string   `yaml:"tls_cert,omitempty"`      // client certificate presented when forwarding calls, signed by tls_ca
+	TLSKey       string   `yaml:"tls_key,omitempty"`
+}
+
+// Enabled reports whether serve shares its state.
+func (s State) Enabled() bool {
+	return len(s.Etcd) > 0
+}
+
+// Enabled reports whether serve shares its cache or fetches from peers.
+func (c CacheSharing) Enabled() bool {
+	return strings.TrimSpace(c.Listen) != "" || len(c.Peers) > 0
+}
+
 type ServicesConfig struct {
 	Docker DockerServiceConfig `yaml:"docker"`
 }
@@ -80,6 +293,12 @@ func Path() (string, error) {
 }
 
 func Load() (Config, string, error) {
+	return LoadProfile("")
+}
+
+// LoadProfile loads the runtime config and applies the named profile. An
+// empty name applies no profile; an unknown name is an error.
+func LoadProfile(profile string) (Config, string, error) {
 	path, err := Path()
 	if err != nil {
 		return Config{}, "", err
@@ -88,14 +307,35 @@ func Load() (Config, string, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return Config{}, path, nil
+			cfg, err := Config{}.WithProfile(profile)
+			return cfg, path, err
 		}
 		return Config{}, path, fmt.Errorf("read %s: %w", path, err)
 	}
 
+	cfg, err := Parse(b)
+	if err != nil {
+		return Config{}, path, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	cfg, err = cfg.WithProfile(profile)
+	if err != nil {
+		return Config{}, path, fmt.Errorf("%s: %w", path, err)
+	}
+
+	cfg.DefaultBackend = strings.TrimSpace(cfg.DefaultBackend)
+	if cfg.DefaultBackend == "" {
+		cfg.DefaultBackend = DefaultBackendForHost()
+	}
+	return cfg, path, nil
+}
+
+// Parse decodes a runtime config file without applying a profile or
+// defaults. Unknown keys are ignored; see CheckKeys.
+func Parse(b []byte) (Config, error) {
 	cfg := Config{}
 	if err := yaml.Unmarshal(b, &cfg); err != nil {
-		return Config{}, path, fmt.Errorf("parse %s: %w", path, err)
+		return Config{}, err
 	}
 	if darwinVZConfigIsZero(cfg.Backends.DarwinVZ) {
 		legacyCfg := struct {
@@ -107,22 +347,64 @@ func Load() (Config, string, error) {
 			cfg.Backends.DarwinVZ = legacyCfg.Backends.DarwinVZ
 		}
 	}
+	return cfg, nil
+}
 
-	cfg.DefaultBackend = strings.TrimSpace(cfg.DefaultBackend)
-	if cfg.DefaultBackend == "" {
-		cfg.DefaultBackend = DefaultBackendForHost()
+// WithProfile returns c with the named profile overlaid. Keys the profile
+// leaves out keep their top-level values.
+func (c Config) WithProfile(name string) (Config, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return c, nil
 	}
-	return cfg, path, nil
+	node, ok := c.Profiles[name]
+	if !ok {
+		if len(c.Profiles) == 0 {
+			return Config{}, fmt.Errorf("unknown profile %q (no profiles are configured)", name)
+		}
+		return Config{}, fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(c.ProfileNames(), ", "))
+	}
+
+	var overlay struct {
+		Profiles yaml.Node `yaml:"profiles"`
+	}
+	if err := node.Decode(&overlay); err != nil {
+		return Config{}, fmt.Errorf("parse profile %q: %w", name, err)
+	}
+	if !overlay.Profiles.IsZero() {
+		return Config{}, fmt.Errorf("profile %q: profiles cannot be nested", name)
+	}
+
+	out := c
+	if err := node.Decode(&out); err != nil {
+		return Config{}, fmt.Errorf("parse profile %q: %w", name, err)
+	}
+	out.Profiles = c.Profiles
+	out.Profile = name
+	return out, nil
+}
+
+func (c Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 func darwinVZConfigIsZero(cfg DarwinVZConfig) bool {
 	return strings.TrimSpace(cfg.KernelImage) == "" &&
+		cfg.KernelSource == nil &&
 		strings.TrimSpace(cfg.RootFS) == "" &&
+		cfg.RootFSSource == nil &&
 		cfg.Services.Docker.StartupTimeoutSeconds == 0 &&
 		strings.TrimSpace(cfg.Services.Docker.StorageDriver) == "" &&
 		!cfg.Services.Docker.IPTables &&
 		cfg.VCPUs == 0 &&
 		cfg.MemoryMiB == 0 &&
+		cfg.MaxVCPUs == 0 &&
+		cfg.MaxMemoryMiB == 0 &&
 		cfg.GuestPort == 0 &&
 		cfg.LaunchSeconds == 0
 }
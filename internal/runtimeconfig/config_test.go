@@ -3,6 +3,7 @@ package runtimeconfig
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -109,3 +110,90 @@ func TestLoadDefaultsBackendWhenMissing(t *testing.T) {
 		t.Fatalf("unexpected default backend: got %q want %q", got, want)
 	}
 }
+
+func TestLoadProfileOverlaysSelectedProfile(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tmp)
+	configPath := filepath.Join(tmp, "cleanroom", "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o755); err != nil {
+		t.Fatalf("mkdir config dir: %v", err)
+	}
+
+	content := `default_backend: firecracker
+host: unix:///run/cleanroom.sock
+backends:
+  firecracker:
+    kernel_image: /opt/kernel
+    vcpus: 2
+profiles:
+  work:
+    host: https://cleanroom.example.com:7777
+    tls_ca: /etc/cleanroom/ca.pem
+    backends:
+      firecracker:
+        vcpus: 8
+  personal:
+    default_backend: darwin-vz
+`
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, _, err := LoadProfile("work")
+	if err != nil {
+		t.Fatalf("LoadProfile returned error: %v", err)
+	}
+	if got, want := cfg.Host, "https://cleanroom.example.com:7777"; got != want {
+		t.Fatalf("unexpected host: got %q want %q", got, want)
+	}
+	if got, want := cfg.TLSCA, "/etc/cleanroom/ca.pem"; got != want {
+		t.Fatalf("unexpected tls ca: got %q want %q", got, want)
+	}
+	if got, want := cfg.Backends.Firecracker.VCPUs, int64(8); got != want {
+		t.Fatalf("unexpected vcpus: got %d want %d", got, want)
+	}
+	if got, want := cfg.Backends.Firecracker.KernelImage, "/opt/kernel"; got != want {
+		t.Fatalf("expected kernel to be inherited: got %q want %q", got, want)
+	}
+	if got, want := cfg.Profile, "work"; got != want {
+		t.Fatalf("unexpected profile: got %q want %q", got, want)
+	}
+
+	base, _, err := Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if got, want := base.Host, "unix:///run/cleanroom.sock"; got != want {
+		t.Fatalf("unexpected base host: got %q want %q", got, want)
+	}
+	if got, want := base.Backends.Firecracker.VCPUs, int64(2); got != want {
+		t.Fatalf("unexpected base vcpus: got %d want %d", got, want)
+	}
+
+	_, _, err = LoadProfile("missing")
+	if err == nil || !strings.Contains(err.Error(), `unknown profile "missing" (available: personal, work)`) {
+		t.Fatalf("unexpected error for unknown profile: %v", err)
+	}
+}
+
+func TestLoadProfileWithoutConfigFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	if _, _, err := LoadProfile("work"); err == nil || !strings.Contains(err.Error(), "no profiles are configured") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestChangedSettings(t *testing.T) {
+	before := Config{DefaultBackend: "firecracker"}
+	before.Backends.Firecracker.VCPUs = 2
+	after := before
+	after.Backends.Firecracker.VCPUs = 4
+	after.Backends.DarwinVZ.Services.Docker.IPTables = true
+	after.Profile = "work"
+
+	got := strings.Join(ChangedSettings(before, after), ",")
+	if want := "backends.darwin-vz.services.docker.iptables,backends.firecracker.vcpus"; got != want {
+		t.Fatalf("unexpected changed settings: got %q want %q", got, want)
+	}
+}
@@ -0,0 +1,73 @@
+package runtimeconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RepoConfigPath is the repository-relative path of the per-repo config.
+const RepoConfigPath = ".cleanroom/config.yaml"
+
+// RepoConfig holds the defaults a repository may override. It is
+// deliberately narrow: a checked-out repository must not be able to point
+// the CLI at other binaries, kernels or servers.
+type RepoConfig struct {
+	DefaultBackend string `yaml:"default_backend"`
+	LaunchSeconds  int64  `yaml:"launch_seconds"` // VM boot/guest-agent readiness timeout
+}
+
+// LoadRepo finds the nearest RepoConfigPath at or above dir, stopping at the
+// enclosing git checkout's root. It returns the zero config and an empty
+// path when there is none.
+func LoadRepo(dir string) (RepoConfig, string, error) {
+	path, err := findRepoConfig(dir)
+	if err != nil || path == "" {
+		return RepoConfig{}, "", err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return RepoConfig{}, path, fmt.Errorf("read %s: %w", path, err)
+	}
+	var cfg RepoConfig
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return RepoConfig{}, path, fmt.Errorf("parse %s (only default_backend and launch_seconds are allowed): %w", path, err)
+	}
+	cfg.DefaultBackend = strings.TrimSpace(cfg.DefaultBackend)
+	if cfg.LaunchSeconds < 0 {
+		return RepoConfig{}, path, fmt.Errorf("parse %s: launch_seconds must not be negative", path)
+	}
+	return cfg, path, nil
+}
+
+func findRepoConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		candidate := filepath.Join(dir, RepoConfigPath)
+		if st, err := os.Stat(candidate); err == nil && !st.IsDir() {
+			return candidate, nil
+		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("stat %s: %w", candidate, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
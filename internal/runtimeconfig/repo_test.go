@@ -0,0 +1,72 @@
+package runtimeconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeRepoConfig(t *testing.T, dir, content string) {
+	t.Helper()
+	path := filepath.Join(dir, RepoConfigPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir repo config dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write repo config: %v", err)
+	}
+}
+
+func TestLoadRepoFindsConfigInParent(t *testing.T) {
+	t.Parallel()
+
+	repo := t.TempDir()
+	writeRepoConfig(t, repo, "default_backend: darwin-vz\nlaunch_seconds: 90\n")
+	sub := filepath.Join(repo, "pkg", "api")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	cfg, path, err := LoadRepo(sub)
+	if err != nil {
+		t.Fatalf("LoadRepo returned error: %v", err)
+	}
+	if got, want := path, filepath.Join(repo, RepoConfigPath); got != want {
+		t.Fatalf("unexpected path: got %q want %q", got, want)
+	}
+	if cfg.DefaultBackend != "darwin-vz" || cfg.LaunchSeconds != 90 {
+		t.Fatalf("unexpected repo config: %+v", cfg)
+	}
+}
+
+func TestLoadRepoStopsAtGitRoot(t *testing.T) {
+	t.Parallel()
+
+	outer := t.TempDir()
+	writeRepoConfig(t, outer, "launch_seconds: 90\n")
+	repo := filepath.Join(outer, "checkout")
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	cfg, path, err := LoadRepo(repo)
+	if err != nil {
+		t.Fatalf("LoadRepo returned error: %v", err)
+	}
+	if path != "" || cfg != (RepoConfig{}) {
+		t.Fatalf("expected no repo config above the git root, got %q %+v", path, cfg)
+	}
+}
+
+func TestLoadRepoRejectsUnsupportedKeys(t *testing.T) {
+	t.Parallel()
+
+	repo := t.TempDir()
+	writeRepoConfig(t, repo, "backends:\n  firecracker:\n    binary_path: /tmp/evil\n")
+
+	_, _, err := LoadRepo(repo)
+	if err == nil || !strings.Contains(err.Error(), "only default_backend and launch_seconds are allowed") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
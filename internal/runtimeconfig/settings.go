@@ -0,0 +1,50 @@
+package runtimeconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Settings flattens c into dotted yaml keys, such as
+// "backends.firecracker.vcpus", mapped to their formatted values. Profiles
+// are omitted; they are already applied to c.
+func (c Config) Settings() map[string]string {
+	out := map[string]string{}
+	flattenSettings(reflect.ValueOf(c), "", out)
+	return out
+}
+
+// ChangedSettings returns the sorted keys whose values differ between a and b.
+func ChangedSettings(a, b Config) []string {
+	before, after := a.Settings(), b.Settings()
+	var changed []string
+	for key, value := range after {
+		if before[key] != value {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func flattenSettings(v reflect.Value, prefix string, out map[string]string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" || name == "profiles" {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		if field.Type.Kind() == reflect.Struct {
+			flattenSettings(v.Field(i), key, out)
+			continue
+		}
+		out[key] = fmt.Sprint(v.Field(i).Interface())
+	}
+}
@@ -0,0 +1,592 @@
+package runtimeconfig
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/buildkite/cleanroom/internal/eventbus"
+	"github.com/buildkite/cleanroom/internal/logging"
+	"github.com/buildkite/cleanroom/internal/logship"
+	"github.com/buildkite/cleanroom/internal/objectstore"
+	"github.com/buildkite/cleanroom/internal/vsockexec"
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is one issue found while validating a runtime config.
+type Problem struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	return p.Key + ": " + p.Message
+}
+
+// CheckKeys reports keys in a runtime config file that Config does not
+// define, which would otherwise be ignored and leave the setting at its
+// default. It suggests the closest known key where one is near.
+func CheckKeys(b []byte) ([]Problem, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	known := map[string]bool{}
+	for key := range (Config{}).Settings() {
+		known[key] = true
+		for parent := key; strings.Contains(parent, "."); {
+			parent = parent[:strings.LastIndex(parent, ".")]
+			known[parent] = true
+		}
+	}
+
+	var problems []Problem
+	root := doc.Content[0]
+	forEachMappingEntry(root, func(key string, value *yaml.Node) {
+		if key != "profiles" {
+			return
+		}
+		forEachMappingEntry(value, func(name string, profile *yaml.Node) {
+			problems = append(problems, checkMappingKeys(profile, "", "profiles."+name+".", known)...)
+		})
+	})
+	problems = append(problems, checkMappingKeys(root, "", "", known)...)
+	sort.Slice(problems, func(i, j int) bool { return problems[i].Key < problems[j].Key })
+	return problems, nil
+}
+
+func checkMappingKeys(node *yaml.Node, prefix, display string, known map[string]bool) []Problem {
+	var problems []Problem
+	forEachMappingEntry(node, func(key string, value *yaml.Node) {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if path == "profiles" {
+			return
+		}
+		if path == "backends.darwin_vz" {
+			// Legacy spelling, still accepted by Parse.
+			path = "backends.darwin-vz"
+		}
+		if !known[path] {
+			msg := "unknown key"
+			if suggestion := closestKey(path, known); suggestion != "" {
+				msg += fmt.Sprintf(" (did you mean %q?)", suggestion[strings.LastIndex(suggestion, ".")+1:])
+			}
+			problems = append(problems, Problem{Key: display + prefixed(prefix, key), Message: msg})
+			return
+		}
+		if value.Kind == yaml.MappingNode && hasKnownChildren(path, known) {
+			// Map-valued settings such as approval.labels take arbitrary keys.
+			problems = append(problems, checkMappingKeys(value, path, display, known)...)
+		}
+	})
+	return problems
+}
+
+func hasKnownChildren(path string, known map[string]bool) bool {
+	for key := range known {
+		if strings.HasPrefix(key, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func prefixed(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func forEachMappingEntry(node *yaml.Node, fn func(key string, value *yaml.Node)) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		fn(node.Content[i].Value, node.Content[i+1])
+	}
+}
+
+// closestKey returns the known sibling of path with the smallest edit
+// distance, if that distance is small enough to be a likely typo.
+func closestKey(path string, known map[string]bool) string {
+	parent := ""
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		parent = path[:i]
+	}
+	best, bestDistance := "", 4
+	for candidate := range known {
+		candidateParent := ""
+		if i := strings.LastIndex(candidate, "."); i >= 0 {
+			candidateParent = candidate[:i]
+		}
+		if candidateParent != parent {
+			continue
+		}
+		if d := editDistance(path, candidate); d < bestDistance || (d == bestDistance && candidate < best) {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// CheckValues reports out-of-range values and configured paths that do not
+// exist. backends lists the backend names default_backend may take; the
+// check is skipped when it is empty.
+func (c Config) CheckValues(backends []string) []Problem {
+	var problems []Problem
+	add := func(key, format string, args ...any) {
+		problems = append(problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if name := strings.TrimSpace(c.DefaultBackend); name != "" && len(backends) > 0 && !slices.Contains(backends, name) {
+		add("default_backend", "unknown backend %q (expected one of %s)", name, strings.Join(backends, ", "))
+	}
+	checkFile(add, "tls_ca", c.TLSCA)
+	if c.SocketDir != "" && !filepath.IsAbs(c.SocketDir) {
+		add("socket_dir", "%q is not an absolute path", c.SocketDir)
+	}
+
+	fc := c.Backends.Firecracker
+	if fc.BinaryPath != "" {
+		if _, err := exec.LookPath(fc.BinaryPath); err != nil {
+			add("backends.firecracker.binary_path", "%q not found", fc.BinaryPath)
+		}
+	}
+	checkFile(add, "backends.firecracker.kernel_image", fc.KernelImage)
+	checkAssetSource(add, "backends.firecracker.kernel_source", fc.KernelSource, "kernel_image", fc.KernelImage)
+	checkFile(add, "backends.firecracker.rootfs", fc.RootFS)
+	switch strings.ToLower(strings.TrimSpace(fc.PrivilegedMode)) {
+	case "", "sudo":
+	case "helper", "caps":
+		checkFile(add, "backends.firecracker.privileged_helper_path", fc.PrivilegedHelperPath)
+	default:
+		add("backends.firecracker.privileged_mode", "unsupported value %q (expected sudo, helper or caps)", fc.PrivilegedMode)
+	}
+	if fc.NetworkNamespaces {
+		switch strings.ToLower(strings.TrimSpace(fc.PrivilegedMode)) {
+		case "helper":
+			add("backends.firecracker.network_namespaces", "requires privileged_mode sudo; the root helper cannot start firecracker in a namespace")
+		case "caps":
+			add("backends.firecracker.network_namespaces", "requires privileged_mode sudo; the capability helper cannot start firecracker in a namespace")
+		}
+	}
+	switch strings.TrimSpace(fc.CPUTemplate) {
+	case "", "None", "C3", "T2", "T2S", "T2CL", "T2A", "V1N1":
+	default:
+		add("backends.firecracker.cpu_template", "unsupported value %q (expected None, C3, T2, T2S, T2CL, T2A or V1N1)", fc.CPUTemplate)
+	}
+	if pool := strings.TrimSpace(fc.NetworkPool); pool != "" {
+		_, poolNet, err := net.ParseCIDR(pool)
+		if err != nil || poolNet.IP.To4() == nil {
+			add("backends.firecracker.network_pool", "%q is not an IPv4 CIDR like 10.200.0.0/16", fc.NetworkPool)
+		} else if ones, _ := poolNet.Mask.Size(); ones > 24 {
+			add("backends.firecracker.network_pool", "%s is smaller than a /24", pool)
+		}
+	}
+	if fc.MTU != 0 && (fc.MTU < 576 || fc.MTU > 9000) {
+		add("backends.firecracker.mtu", "must be between 576 and 9000")
+	}
+	checkVMSizing(add, "backends.firecracker", fc.VCPUs, fc.MemoryMiB, fc.LaunchSeconds, fc.Services)
+	checkResourceMaxima(add, "backends.firecracker", map[string]int64{
+		"max_vcpus":        fc.MaxVCPUs,
+		"max_memory_mib":   fc.MaxMemoryMiB,
+		"max_disk_mib":     fc.MaxDiskMiB,
+		"egress_mbps":      fc.EgressMbps,
+		"ingress_mbps":     fc.IngressMbps,
+		"max_egress_mbps":  fc.MaxEgressMbps,
+		"max_ingress_mbps": fc.MaxIngressMbps,
+		"disk_iops":        fc.DiskIOPS,
+		"disk_mibps":       fc.DiskMiBps,
+		"max_disk_iops":    fc.MaxDiskIOPS,
+		"max_disk_mibps":   fc.MaxDiskMiBps,
+		"scratch_files":    fc.ScratchFiles,
+	})
+
+	vz := c.Backends.DarwinVZ
+	checkFile(add, "backends.darwin-vz.kernel_image", vz.KernelImage)
+	checkFile(add, "backends.darwin-vz.rootfs", vz.RootFS)
+	checkAssetSource(add, "backends.darwin-vz.kernel_source", vz.KernelSource, "kernel_image", vz.KernelImage)
+	checkAssetSource(add, "backends.darwin-vz.rootfs_source", vz.RootFSSource, "rootfs", vz.RootFS)
+	checkVMSizing(add, "backends.darwin-vz", vz.VCPUs, vz.MemoryMiB, vz.LaunchSeconds, vz.Services)
+	checkResourceMaxima(add, "backends.darwin-vz", map[string]int64{
+		"max_vcpus":      vz.MaxVCPUs,
+		"max_memory_mib": vz.MaxMemoryMiB,
+	})
+	checkVFIODevices(add, c.Devices.VFIO)
+	checkApproval(add, c.Approval)
+	checkNamespaces(add, c.Namespaces)
+	checkBudgets(add, c.Budgets)
+	checkLogging(add, c.Logging)
+	checkEvents(add, c.Events)
+	checkLogShipping(add, c.LogShipping)
+	checkStorage(add, c.Storage)
+	checkCacheSharing(add, c.CacheSharing)
+	checkState(add, c.State)
+	checkExecutions(add, c.Executions)
+	checkResourceMaxima(add, "runs", map[string]int64{
+		"max_total_mib":          c.Runs.MaxTotalMiB,
+		"max_age_hours":          c.Runs.MaxAgeHours,
+		"sweep_interval_seconds": c.Runs.SweepIntervalSeconds,
+	})
+	return problems
+}
+
+var pciAddressPattern = regexp.MustCompile(`^[0-9a-f]{4}:[0-9a-f]{2}:[0-9a-f]{2}\.[0-7]$`)
+
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+func checkVFIODevices(add func(key, format string, args ...any), devices []VFIODevice) {
+	seen := map[string]bool{}
+	for i, device := range devices {
+		key := fmt.Sprintf("devices.vfio[%d]", i)
+		switch {
+		case strings.TrimSpace(device.Name) == "":
+			add(key+".name", "must be set")
+		case seen[device.Name]:
+			add(key+".name", "duplicate device name %q", device.Name)
+		}
+		seen[device.Name] = true
+		if !pciAddressPattern.MatchString(device.PCIAddress) {
+			add(key+".pci_address", "%q is not a PCI address like 0000:65:00.0", device.PCIAddress)
+		}
+	}
+}
+
+func checkApproval(add func(key, format string, args ...any), approval Approval) {
+	for i, identity := range approval.Identities {
+		if strings.TrimSpace(identity) == "" {
+			add(fmt.Sprintf("approval.identities[%d]", i), "must not be empty")
+		}
+	}
+	for i, identity := range approval.Approvers {
+		if strings.TrimSpace(identity) == "" {
+			add(fmt.Sprintf("approval.approvers[%d]", i), "must not be empty")
+		}
+	}
+	if raw := strings.TrimSpace(approval.WebhookURL); raw != "" {
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("approval.webhook_url", "%q is not an http(s) URL", raw)
+		}
+	}
+	if approval.TimeoutSeconds < 0 {
+		add("approval.timeout_seconds", "must not be negative")
+	}
+}
+
+func checkNamespaces(add func(key, format string, args ...any), cfg Namespaces) {
+	identities := make([]string, 0, len(cfg.Identities))
+	for identity := range cfg.Identities {
+		identities = append(identities, identity)
+	}
+	sort.Strings(identities)
+	for _, identity := range identities {
+		if strings.TrimSpace(identity) == "" {
+			add("namespaces.identities", "identity must not be empty")
+			continue
+		}
+		if namespace := cfg.Identities[identity]; !ValidNamespace(namespace) {
+			add("namespaces.identities."+identity, "%q is not a namespace name like team-a", namespace)
+		}
+	}
+	for i, identity := range cfg.Admins {
+		if strings.TrimSpace(identity) == "" {
+			add(fmt.Sprintf("namespaces.admins[%d]", i), "must not be empty")
+		}
+	}
+}
+
+func checkBudgets(add func(key, format string, args ...any), cfg Budgets) {
+	for _, section := range []struct {
+		key     string
+		budgets map[string]Budget
+	}{{"budgets.namespaces", cfg.Namespaces}, {"budgets.identities", cfg.Identities}} {
+		names := make([]string, 0, len(section.budgets))
+		for name := range section.budgets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			key := section.key + "." + name
+			if section.key == "budgets.namespaces" && !ValidNamespace(name) {
+				add(key, "%q is not a namespace name like team-a", name)
+			}
+			if strings.TrimSpace(name) == "" {
+				add(section.key, "identity must not be empty")
+			}
+			budget := section.budgets[name]
+			if budget.VCPUHours < 0 {
+				add(key+".vcpu_hours", "must not be negative")
+			}
+			if budget.MemoryGiBHours < 0 {
+				add(key+".memory_gib_hours", "must not be negative")
+			}
+			if budget.SoftPercent < 0 || budget.SoftPercent > 100 {
+				add(key+".soft_percent", "must be between 0 and 100")
+			}
+		}
+	}
+	if raw := strings.TrimSpace(cfg.WebhookURL); raw != "" {
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("budgets.webhook_url", "%q is not an http(s) URL", raw)
+		}
+	}
+}
+
+func checkLogging(add func(key, format string, args ...any), cfg Logging) {
+	if _, err := logging.ParseLevel(cfg.Level); err != nil {
+		add("logging.level", "unsupported value %q (expected debug, info, warn or error)", cfg.Level)
+	}
+	switch strings.ToLower(strings.TrimSpace(cfg.Format)) {
+	case "", logging.FormatText, logging.FormatJSON:
+	default:
+		add("logging.format", "unsupported value %q (expected text or json)", cfg.Format)
+	}
+	subsystems := make([]string, 0, len(cfg.Levels))
+	for name := range cfg.Levels {
+		subsystems = append(subsystems, name)
+	}
+	sort.Strings(subsystems)
+	for _, name := range subsystems {
+		key := "logging.levels." + name
+		if !slices.Contains(logging.Subsystems, name) {
+			add(key, "unknown subsystem (expected one of %s)", strings.Join(logging.Subsystems, ", "))
+			continue
+		}
+		if _, err := logging.ParseLevel(cfg.Levels[name]); err != nil {
+			add(key, "unsupported value %q (expected debug, info, warn or error)", cfg.Levels[name])
+		}
+	}
+	checkResourceMaxima(add, "logging", map[string]int64{
+		"max_size_mib": cfg.MaxSizeMiB,
+		"max_files":    cfg.MaxFiles,
+	})
+}
+
+func checkEvents(add func(key, format string, args ...any), cfg Events) {
+	if cfg.NATSURL != "" {
+		if err := eventbus.ValidateNATSURL(cfg.NATSURL); err != nil {
+			add("events.nats_url", "%q is not a NATS URL like nats://host:4222", cfg.NATSURL)
+		}
+	}
+	if prefix := cfg.SubjectPrefix; prefix != "" {
+		for _, token := range strings.Split(prefix, ".") {
+			if token == "" || token == "*" || token == ">" || strings.ContainsAny(token, " \t\r\n") {
+				add("events.subject_prefix", "%q is not a NATS subject like cleanroom.host-1", prefix)
+				break
+			}
+		}
+	}
+}
+
+func checkLogShipping(add func(key, format string, args ...any), cfg LogShipping) {
+	if cfg.URL == "" {
+		if cfg.Region != "" || cfg.Endpoint != "" {
+			add("log_shipping.url", "must be set when region or endpoint is")
+		}
+		return
+	}
+	if err := logship.ValidateURL(cfg.URL); err != nil {
+		add("log_shipping.url", "%q is not a sink URL like s3://bucket/prefix, gs://bucket/prefix or https://host/path", cfg.URL)
+		return
+	}
+	if (cfg.Region != "" || cfg.Endpoint != "") && !strings.HasPrefix(strings.TrimSpace(cfg.URL), "s3://") {
+		add("log_shipping.url", "region and endpoint only apply to s3:// URLs")
+	}
+	if endpoint := strings.TrimSpace(cfg.Endpoint); endpoint != "" {
+		if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			add("log_shipping.endpoint", "%q is not a URL like https://minio.internal:9000", cfg.Endpoint)
+		}
+	}
+}
+
+func checkStorage(add func(key, format string, args ...any), cfg Storage) {
+	if cfg.Runs == "" {
+		if cfg.Region != "" || cfg.Endpoint != "" {
+			add("storage.runs", "must be set when region or endpoint is")
+		}
+		return
+	}
+	if err := objectstore.ValidateURL(cfg.Runs); err != nil {
+		add("storage.runs", "%q is not a store like s3://bucket/prefix or /mnt/cleanroom-runs", cfg.Runs)
+		return
+	}
+	if (cfg.Region != "" || cfg.Endpoint != "") && !strings.HasPrefix(strings.TrimSpace(cfg.Runs), "s3://") {
+		add("storage.runs", "region and endpoint only apply to s3:// stores")
+	}
+	if endpoint := strings.TrimSpace(cfg.Endpoint); endpoint != "" {
+		if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			add("storage.endpoint", "%q is not a URL like https://minio.internal:9000", cfg.Endpoint)
+		}
+	}
+}
+
+func checkCacheSharing(add func(key, format string, args ...any), cfg CacheSharing) {
+	if !cfg.Enabled() {
+		return
+	}
+	if listen := strings.TrimSpace(cfg.Listen); listen != "" {
+		if _, _, err := net.SplitHostPort(listen); err != nil {
+			add("cache_sharing.listen", "%q is not a host:port like :8171", cfg.Listen)
+		}
+	}
+	for i, peer := range cfg.Peers {
+		if u, err := url.Parse(strings.TrimSpace(peer)); err != nil || u.Scheme != "https" || u.Host == "" {
+			add(fmt.Sprintf("cache_sharing.peers[%d]", i), "%q is not an https URL like https://host-b:8171", peer)
+		}
+	}
+	for _, setting := range []struct{ key, path string }{
+		{"tls_cert", cfg.TLSCert},
+		{"tls_key", cfg.TLSKey},
+		{"tls_ca", cfg.TLSCA},
+	} {
+		if strings.TrimSpace(setting.path) == "" {
+			add("cache_sharing."+setting.key, "must be set; peers authenticate each other with mutual TLS")
+			continue
+		}
+		checkFile(add, "cache_sharing."+setting.key, setting.path)
+	}
+}
+
+func checkState(add func(key, format string, args ...any), cfg State) {
+	if !cfg.Enabled() {
+		if cfg.Prefix != "" || cfg.HostID != "" || cfg.AdvertiseURL != "" || cfg.LeaseSeconds != 0 || cfg.TLSCA != "" || cfg.TLSCert != "" || cfg.TLSKey != "" {
+			add("state.etcd", "must be set when other state settings are")
+		}
+		return
+	}
+	for i, endpoint := range cfg.Etcd {
+		if u, err := url.Parse(strings.TrimSpace(endpoint)); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			add(fmt.Sprintf("state.etcd[%d]", i), "%q is not an etcd endpoint like https://etcd-1:2379", endpoint)
+		}
+	}
+	if prefix := strings.TrimSpace(cfg.Prefix); prefix != "" && !strings.HasPrefix(prefix, "/") {
+		add("state.prefix", "%q must start with /", cfg.Prefix)
+	}
+	if strings.Contains(cfg.HostID, "/") {
+		add("state.host_id", "%q must not contain /", cfg.HostID)
+	}
+	if advertise := strings.TrimSpace(cfg.AdvertiseURL); advertise == "" {
+		add("state.advertise_url", "must be set; other instances forward calls for this instance's sandboxes there")
+	} else if u, err := url.Parse(advertise); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		add("state.advertise_url", "%q is not a URL like https://cleanroom-1.internal:7777", cfg.AdvertiseURL)
+	}
+	if cfg.LeaseSeconds < 0 {
+		add("state.lease_seconds", "must not be negative")
+	} else if cfg.LeaseSeconds > 0 && cfg.LeaseSeconds < 3 {
+		add("state.lease_seconds", "must be at least 3")
+	}
+	checkFile(add, "state.tls_ca", cfg.TLSCA)
+	if (strings.TrimSpace(cfg.TLSCert) == "") != (strings.TrimSpace(cfg.TLSKey) == "") {
+		add("state.tls_key", "must be set together with tls_cert")
+	}
+	if strings.TrimSpace(cfg.TLSCert) != "" && strings.TrimSpace(cfg.TLSCA) == "" {
+		add("state.tls_ca", "must be set with tls_cert; instances only trust forwarded callers from certificates signed by it")
+	}
+	checkFile(add, "state.tls_cert", cfg.TLSCert)
+	checkFile(add, "state.tls_key", cfg.TLSKey)
+}
+
+func checkExecutions(add func(key, format string, args ...any), cfg Executions) {
+	for _, setting := range []struct {
+		key        string
+		value, max int64
+	}{
+		{"max_args", cfg.MaxArgs, vsockexec.MaxCommandArgs},
+		{"max_command_bytes", cfg.MaxCommandBytes, vsockexec.MaxCommandBytes},
+		{"max_stdin_frame_bytes", cfg.MaxStdinFrameBytes, vsockexec.MaxStdinFrameBytes},
+	} {
+		switch {
+		case setting.value < 0:
+			add("executions."+setting.key, "must not be negative")
+		case setting.value > setting.max:
+			add("executions."+setting.key, "must be at most %d, the guest agent's limit", setting.max)
+		}
+	}
+}
+
+func checkFile(add func(key, format string, args ...any), key, path string) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		add(key, "%s does not exist or is not readable", path)
+	}
+}
+
+// checkAssetSource reports a source that cannot be fetched or verified, or
+// one set alongside the local path it would replace.
+func checkAssetSource(add func(key, format string, args ...any), key string, src *AssetSource, pathKey, path string) {
+	if src == nil {
+		return
+	}
+	if strings.TrimSpace(path) != "" {
+		add(key, "set either %s or %s, not both", pathKey, key[strings.LastIndex(key, ".")+1:])
+	}
+	if err := src.Source().Validate(); err != nil {
+		add(key, "%v", err)
+	}
+}
+
+func checkVMSizing(add func(key, format string, args ...any), prefix string, vcpus, memoryMiB, launchSeconds int64, services ServicesConfig) {
+	if vcpus < 0 {
+		add(prefix+".vcpus", "must not be negative")
+	}
+	if memoryMiB < 0 {
+		add(prefix+".memory_mib", "must not be negative")
+	}
+	if launchSeconds < 0 {
+		add(prefix+".launch_seconds", "must not be negative")
+	}
+	if services.Docker.StartupTimeoutSeconds < 0 {
+		add(prefix+".services.docker.startup_timeout_seconds", "must not be negative")
+	}
+}
+
+func checkResourceMaxima(add func(key, format string, args ...any), prefix string, maxima map[string]int64) {
+	keys := make([]string, 0, len(maxima))
+	for key := range maxima {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if maxima[key] < 0 {
+			add(prefix+"."+key, "must not be negative")
+		}
+	}
+}
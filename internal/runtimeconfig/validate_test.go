@@ -0,0 +1,151 @@
+package runtimeconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func problemStrings(problems []Problem) string {
+	out := make([]string, 0, len(problems))
+	for _, p := range problems {
+		out = append(out, p.String())
+	}
+	return strings.Join(out, "\n")
+}
+
+func TestCheckKeysReportsTyposWithSuggestions(t *testing.T) {
+	t.Parallel()
+
+	raw := []byte(`default_backend: firecracker
+defualt_backend: darwin-vz
+backends:
+  firecracker:
+    memory_mb: 2048
+    services:
+      docker:
+        iptables: true
+  darwin_vz:
+    vcpus: 2
+  qemu: {}
+approval:
+  labels:
+    agent: "true"
+  webhok_url: https://hooks.example.com/approve
+profiles:
+  work:
+    tls-ca: /etc/ca.pem
+`)
+	problems, err := CheckKeys(raw)
+	if err != nil {
+		t.Fatalf("CheckKeys returned error: %v", err)
+	}
+	want := strings.Join([]string{
+		`approval.webhok_url: unknown key (did you mean "webhook_url"?)`,
+		`backends.firecracker.memory_mb: unknown key (did you mean "memory_mib"?)`,
+		`backends.qemu: unknown key`,
+		`defualt_backend: unknown key (did you mean "default_backend"?)`,
+		`profiles.work.tls-ca: unknown key (did you mean "tls_ca"?)`,
+	}, "\n")
+	if got := problemStrings(problems); got != want {
+		t.Fatalf("unexpected problems:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCheckKeysAcceptsEmptyFile(t *testing.T) {
+	t.Parallel()
+
+	problems, err := CheckKeys(nil)
+	if err != nil || len(problems) != 0 {
+		t.Fatalf("unexpected result for empty config: %v %v", problems, err)
+	}
+}
+
+func TestCheckValues(t *testing.T) {
+	t.Parallel()
+
+	kernel := filepath.Join(t.TempDir(), "vmlinux")
+	if err := os.WriteFile(kernel, nil, 0o644); err != nil {
+		t.Fatalf("write kernel: %v", err)
+	}
+
+	cfg := Config{DefaultBackend: "qemu", SocketDir: "run/cleanroom"}
+	cfg.Backends.Firecracker.KernelImage = kernel
+	cfg.Backends.Firecracker.KernelSource = &AssetSource{URL: "https://mirror.internal/vmlinux", SHA256: "abc"}
+	cfg.Backends.Firecracker.RootFS = "/does/not/exist.ext4"
+	cfg.Backends.Firecracker.PrivilegedMode = "helper"
+	cfg.Backends.Firecracker.PrivilegedHelperPath = "/does/not/exist-helper"
+	cfg.Backends.Firecracker.MaxDiskMiB = -1
+	cfg.Backends.Firecracker.CPUTemplate = "t2s"
+	cfg.Backends.Firecracker.NetworkPool = "10.200.0.0/26"
+	cfg.Backends.Firecracker.MTU = 100
+	cfg.Backends.Firecracker.NetworkNamespaces = true
+	cfg.Backends.DarwinVZ.MemoryMiB = -1
+	cfg.Backends.DarwinVZ.RootFSSource = &AssetSource{URL: "ftp://mirror.internal/rootfs.ext4", SHA256: strings.Repeat("a", 64)}
+	cfg.Devices.VFIO = []VFIODevice{{Name: "gpu", PCIAddress: "0000:65:00.0"}, {Name: "gpu", PCIAddress: "65:00.0"}}
+	cfg.Approval = Approval{Identities: []string{"uid:1001", " "}, Approvers: []string{""}, WebhookURL: "hooks.example.com/approve", TimeoutSeconds: -1}
+	cfg.Namespaces = Namespaces{Identities: map[string]string{"uid:1001": "team-a", "uid:1002": "Team B"}, Admins: []string{""}}
+	cfg.Budgets = Budgets{
+		Namespaces: map[string]Budget{"Team B": {VCPUHours: 10}, "team-a": {VCPUHours: -1, SoftPercent: 120}},
+		WebhookURL: "hooks.example.com/budget",
+	}
+	cfg.Logging = Logging{Format: "xml", Levels: map[string]string{"gateway": "verbose", "vm": "debug"}, MaxFiles: -1}
+	cfg.Events = Events{NATSURL: "amqp://broker:5672", SubjectPrefix: "cleanroom.>"}
+	cfg.LogShipping = LogShipping{URL: "gs://job-logs", Region: "eu-west-1"}
+	cfg.Storage = Storage{Runs: "runs", Endpoint: "https://minio.internal"}
+	cfg.CacheSharing = CacheSharing{Listen: "8171", Peers: []string{"http://host-b:8171"}, TLSCert: kernel, TLSKey: kernel}
+	cfg.State = State{Etcd: []string{"etcd-1:2379"}, LeaseSeconds: 1, TLSCert: kernel}
+	cfg.Runs.MaxAgeHours = -1
+	cfg.Executions = Executions{MaxArgs: -1, MaxStdinFrameBytes: 64 * 1024 * 1024}
+
+	want := strings.Join([]string{
+		`default_backend: unknown backend "qemu" (expected one of darwin-vz, firecracker)`,
+		`socket_dir: "run/cleanroom" is not an absolute path`,
+		`backends.firecracker.kernel_source: set either kernel_image or kernel_source, not both`,
+		`backends.firecracker.kernel_source: sha256 must be 64 lowercase hex characters`,
+		`backends.firecracker.rootfs: /does/not/exist.ext4 does not exist or is not readable`,
+		`backends.firecracker.privileged_helper_path: /does/not/exist-helper does not exist or is not readable`,
+		`backends.firecracker.network_namespaces: requires privileged_mode sudo; the root helper cannot start firecracker in a namespace`,
+		`backends.firecracker.cpu_template: unsupported value "t2s" (expected None, C3, T2, T2S, T2CL, T2A or V1N1)`,
+		`backends.firecracker.network_pool: 10.200.0.0/26 is smaller than a /24`,
+		`backends.firecracker.mtu: must be between 576 and 9000`,
+		`backends.firecracker.max_disk_mib: must not be negative`,
+		`backends.darwin-vz.rootfs_source: url scheme "ftp" is not supported (expected https, http or file)`,
+		`backends.darwin-vz.memory_mib: must not be negative`,
+		`devices.vfio[1].name: duplicate device name "gpu"`,
+		`devices.vfio[1].pci_address: "65:00.0" is not a PCI address like 0000:65:00.0`,
+		`approval.identities[1]: must not be empty`,
+		`approval.approvers[0]: must not be empty`,
+		`approval.webhook_url: "hooks.example.com/approve" is not an http(s) URL`,
+		`approval.timeout_seconds: must not be negative`,
+		"namespaces.identities.uid:1002: \"Team B\" is not a namespace name like team-a",
+		`namespaces.admins[0]: must not be empty`,
+		`budgets.namespaces.Team B: "Team B" is not a namespace name like team-a`,
+		`budgets.namespaces.team-a.vcpu_hours: must not be negative`,
+		`budgets.namespaces.team-a.soft_percent: must be between 0 and 100`,
+		`budgets.webhook_url: "hooks.example.com/budget" is not an http(s) URL`,
+		`logging.format: unsupported value "xml" (expected text or json)`,
+		`logging.levels.gateway: unsupported value "verbose" (expected debug, info, warn or error)`,
+		`logging.levels.vm: unknown subsystem (expected one of cache-sharing, config, darwin-vz, events, firecracker, gateway, http, interactive-quic, log-shipping, network, service, shared-state)`,
+		`logging.max_files: must not be negative`,
+		`events.nats_url: "amqp://broker:5672" is not a NATS URL like nats://host:4222`,
+		`events.subject_prefix: "cleanroom.>" is not a NATS subject like cleanroom.host-1`,
+		`log_shipping.url: region and endpoint only apply to s3:// URLs`,
+		`storage.runs: "runs" is not a store like s3://bucket/prefix or /mnt/cleanroom-runs`,
+		`cache_sharing.listen: "8171" is not a host:port like :8171`,
+		`cache_sharing.peers[0]: "http://host-b:8171" is not an https URL like https://host-b:8171`,
+		`cache_sharing.tls_ca: must be set; peers authenticate each other with mutual TLS`,
+		`state.etcd[0]: "etcd-1:2379" is not an etcd endpoint like https://etcd-1:2379`,
+		`state.advertise_url: must be set; other instances forward calls for this instance's sandboxes there`,
+		`state.lease_seconds: must be at least 3`,
+		`state.tls_key: must be set together with tls_cert`,
+		`state.tls_ca: must be set with tls_cert; instances only trust forwarded callers from certificates signed by it`,
+		`executions.max_args: must not be negative`,
+		`executions.max_stdin_frame_bytes: must be at most 1048576, the guest agent's limit`,
+		`runs.max_age_hours: must not be negative`,
+	}, "\n")
+	if got := problemStrings(cfg.CheckValues([]string{"darwin-vz", "firecracker"})); got != want {
+		t.Fatalf("unexpected problems:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,125 @@
+package schedule
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// A Saturday.
+	from := time.Date(2026, 10, 17, 10, 30, 0, 0, time.UTC)
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"0 3 * * *", time.Date(2026, 10, 18, 3, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 17, 10, 45, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 10, 17, 11, 0, 0, 0, time.UTC)},
+		{"30 9-17/4 * * mon-fri", time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan,jul *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted.
+		{"0 12 1 * sun", time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tc := range cases {
+		c, err := ParseCron(tc.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q) returned error: %v", tc.expr, err)
+		}
+		if got := c.Next(from); !got.Equal(tc.want) {
+			t.Errorf("Next(%q) = %s, want %s", tc.expr, got, tc.want)
+		}
+	}
+
+	c, _ := ParseCron("0 3 * * *")
+	at := time.Date(2026, 10, 18, 3, 0, 0, 0, time.UTC)
+	if got := c.Next(at); !got.Equal(at.AddDate(0, 0, 1)) {
+		t.Errorf("Next of a matching time = %s, want the following day", got)
+	}
+}
+
+func TestParseCronRejectsInvalid(t *testing.T) {
+	for expr, want := range map[string]string{
+		"0 3 * *":      "want 5 fields",
+		"60 * * * *":   "minute \"60\" must be between 0 and 59",
+		"* 5-1 * * *":  "hour range \"5-1\" runs backwards",
+		"*/0 * * * *":  "minute step \"0\" must be a positive number",
+		"* * * foo *":  "month \"foo\"",
+		"* * 0 * *":    "day of month \"0\"",
+		"@fortnightly": "want 5 fields",
+	} {
+		if _, err := ParseCron(expr); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseCron(%q) error = %v, want it to mention %q", expr, err, want)
+		}
+	}
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(ctx, filepath.Join(t.TempDir(), "schedules", "schedules.db"))
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer store.Close()
+
+	created := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	sch := Schedule{
+		ID: "sched_1", Cron: "0 3 * * *", Namespace: "team-a", Identity: "uid:1001", Backend: "firecracker",
+		Policy: []byte{1, 2, 3}, Command: []string{"scan", "--all"}, Labels: map[string]string{"team": "security"},
+		TimeoutSeconds: 600, CreatedAt: created,
+	}
+	if err := store.Create(ctx, sch); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	if err := store.Create(ctx, Schedule{ID: "sched_2", Cron: "@daily", Policy: []byte{4}, Command: []string{"true"}, CreatedAt: created.Add(time.Hour)}); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	got, ok, err := store.Get(ctx, "sched_1")
+	if err != nil || !ok || !reflect.DeepEqual(got, sch) {
+		t.Fatalf("Get = %+v, %v, %v; want %+v", got, ok, err, sch)
+	}
+	if _, ok, err := store.Get(ctx, "sched_missing"); ok || err != nil {
+		t.Fatalf("Get of a missing schedule = %v, %v", ok, err)
+	}
+	list, err := store.List(ctx)
+	if err != nil || len(list) != 2 || list[0].ID != "sched_1" || list[1].ID != "sched_2" {
+		t.Fatalf("List = %+v, %v", list, err)
+	}
+
+	for i := range KeepRuns + 5 {
+		run := Run{ScheduleID: "sched_1", SandboxID: "cr_1", StartedAt: created.Add(time.Duration(i) * time.Hour), Status: "EXECUTION_STATUS_SUCCEEDED"}
+		run.FinishedAt = run.StartedAt.Add(time.Minute)
+		if err := store.AddRun(ctx, run); err != nil {
+			t.Fatalf("AddRun returned error: %v", err)
+		}
+	}
+	if err := store.AddRun(ctx, Run{ScheduleID: "sched_2", StartedAt: created, FinishedAt: created, Error: "create sandbox: boom"}); err != nil {
+		t.Fatalf("AddRun returned error: %v", err)
+	}
+	runs, err := store.Runs(ctx, "sched_1", KeepRuns*2)
+	if err != nil || len(runs) != KeepRuns {
+		t.Fatalf("Runs returned %d runs, %v; want %d", len(runs), err, KeepRuns)
+	}
+	if want := created.Add(time.Duration(KeepRuns+4) * time.Hour); !runs[0].StartedAt.Equal(want) {
+		t.Fatalf("newest run started at %s, want %s", runs[0].StartedAt, want)
+	}
+
+	if deleted, err := store.Delete(ctx, "sched_1"); !deleted || err != nil {
+		t.Fatalf("Delete = %v, %v", deleted, err)
+	}
+	if deleted, err := store.Delete(ctx, "sched_1"); deleted || err != nil {
+		t.Fatalf("second Delete = %v, %v", deleted, err)
+	}
+	if runs, _ := store.Runs(ctx, "sched_1", 10); len(runs) != 0 {
+		t.Fatalf("runs of a deleted schedule remain: %+v", runs)
+	}
+	if runs, _ := store.Runs(ctx, "sched_2", 10); len(runs) != 1 || runs[0].Error != "create sandbox: boom" {
+		t.Fatalf("runs of sched_2 = %+v", runs)
+	}
+}
@@ -0,0 +1,253 @@
+package schedule
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// KeepRuns is how many runs of each schedule the store keeps. Older runs
+// are dropped as new ones are added.
+const KeepRuns = 100
+
+// Schedule is a command to run in a fresh sandbox whenever Cron matches.
+type Schedule struct {
+	ID        string
+	Cron      string
+	Namespace string
+	Identity  string // the caller that created the schedule; runs act as it
+	Backend   string
+	// Policy is the sandbox's cleanroom.v1.Policy in protobuf wire format.
+	Policy         []byte
+	Command        []string
+	Labels         map[string]string
+	TimeoutSeconds int64
+	CreatedAt      time.Time
+}
+
+// Run is the outcome of one scheduled run.
+type Run struct {
+	ScheduleID  string
+	SandboxID   string
+	ExecutionID string
+	RunID       string
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	// Status is the execution's final cleanroom.v1.ExecutionStatus name.
+	// It is empty when the run failed before its command started.
+	Status   string
+	ExitCode int32
+	// Error says why the run failed or was stopped, if it was.
+	Error string
+}
+
+// Store keeps schedules and their runs in a SQLite database.
+type Store struct {
+	db   *sql.DB
+	path string
+}
+
+// Open opens the schedule database at path, creating it if needed.
+func Open(ctx context.Context, path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create schedule database directory: %w", err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open schedule database %q: %w", path, err)
+	}
+	// One connection serialises writers, which SQLite would otherwise
+	// reject as busy.
+	db.SetMaxOpenConns(1)
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schedules (
+			id TEXT PRIMARY KEY,
+			cron TEXT NOT NULL,
+			namespace TEXT NOT NULL,
+			identity TEXT NOT NULL,
+			backend TEXT NOT NULL,
+			policy BLOB NOT NULL,
+			command_json TEXT NOT NULL,
+			labels_json TEXT NOT NULL,
+			timeout_seconds INTEGER NOT NULL,
+			created_at_unix_ms INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS schedule_runs (
+			schedule_id TEXT NOT NULL,
+			sandbox_id TEXT NOT NULL,
+			execution_id TEXT NOT NULL,
+			run_id TEXT NOT NULL,
+			started_at_unix_ms INTEGER NOT NULL,
+			finished_at_unix_ms INTEGER NOT NULL,
+			status TEXT NOT NULL,
+			exit_code INTEGER NOT NULL,
+			error TEXT NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_schedule_runs_schedule ON schedule_runs(schedule_id, started_at_unix_ms);
+	`)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("initialise schedule schema: %w", err)
+	}
+	return &Store{db: db, path: path}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Create saves a new schedule.
+func (s *Store) Create(ctx context.Context, sch Schedule) error {
+	command, err := json.Marshal(sch.Command)
+	if err != nil {
+		return err
+	}
+	labels, err := json.Marshal(sch.Labels)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO schedules (
+			id, cron, namespace, identity, backend, policy, command_json,
+			labels_json, timeout_seconds, created_at_unix_ms
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		sch.ID, sch.Cron, sch.Namespace, sch.Identity, sch.Backend, sch.Policy, string(command),
+		string(labels), sch.TimeoutSeconds, sch.CreatedAt.UnixMilli(),
+	)
+	if err != nil {
+		return fmt.Errorf("create schedule in %q: %w", s.path, err)
+	}
+	return nil
+}
+
+// Get returns the schedule with id, and false if there is none.
+func (s *Store) Get(ctx context.Context, id string) (Schedule, bool, error) {
+	schedules, err := s.query(ctx, `WHERE id = ?`, id)
+	if err != nil || len(schedules) == 0 {
+		return Schedule{}, false, err
+	}
+	return schedules[0], true, nil
+}
+
+// List returns every schedule, oldest first.
+func (s *Store) List(ctx context.Context) ([]Schedule, error) {
+	return s.query(ctx, `ORDER BY created_at_unix_ms, id`)
+}
+
+func (s *Store) query(ctx context.Context, where string, args ...any) ([]Schedule, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, cron, namespace, identity, backend, policy, command_json,
+			labels_json, timeout_seconds, created_at_unix_ms
+		FROM schedules `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query schedules in %q: %w", s.path, err)
+	}
+	defer rows.Close()
+
+	var out []Schedule
+	for rows.Next() {
+		var (
+			sch             Schedule
+			command, labels string
+			created         int64
+		)
+		if err := rows.Scan(&sch.ID, &sch.Cron, &sch.Namespace, &sch.Identity, &sch.Backend, &sch.Policy, &command,
+			&labels, &sch.TimeoutSeconds, &created); err != nil {
+			return nil, fmt.Errorf("read schedules in %q: %w", s.path, err)
+		}
+		if err := json.Unmarshal([]byte(command), &sch.Command); err != nil {
+			return nil, fmt.Errorf("read command of schedule %s: %w", sch.ID, err)
+		}
+		if err := json.Unmarshal([]byte(labels), &sch.Labels); err != nil {
+			return nil, fmt.Errorf("read labels of schedule %s: %w", sch.ID, err)
+		}
+		sch.CreatedAt = time.UnixMilli(created).UTC()
+		out = append(out, sch)
+	}
+	return out, rows.Err()
+}
+
+// Delete removes the schedule with id and its runs. It reports whether
+// there was one.
+func (s *Store) Delete(ctx context.Context, id string) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("delete schedule in %q: %w", s.path, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	result, err := tx.ExecContext(ctx, `DELETE FROM schedules WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("delete schedule in %q: %w", s.path, err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schedule_runs WHERE schedule_id = ?`, id); err != nil {
+		return false, fmt.Errorf("delete schedule runs in %q: %w", s.path, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("delete schedule in %q: %w", s.path, err)
+	}
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
+
+// AddRun saves the outcome of a run, dropping the schedule's runs beyond
+// the newest KeepRuns.
+func (s *Store) AddRun(ctx context.Context, r Run) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO schedule_runs (
+			schedule_id, sandbox_id, execution_id, run_id, started_at_unix_ms,
+			finished_at_unix_ms, status, exit_code, error
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.ScheduleID, r.SandboxID, r.ExecutionID, r.RunID, r.StartedAt.UnixMilli(),
+		r.FinishedAt.UnixMilli(), r.Status, r.ExitCode, r.Error,
+	)
+	if err != nil {
+		return fmt.Errorf("record schedule run in %q: %w", s.path, err)
+	}
+	_, err = s.db.ExecContext(ctx, `
+		DELETE FROM schedule_runs WHERE schedule_id = ? AND rowid NOT IN (
+			SELECT rowid FROM schedule_runs WHERE schedule_id = ?
+			ORDER BY started_at_unix_ms DESC, rowid DESC LIMIT ?
+		)`, r.ScheduleID, r.ScheduleID, KeepRuns)
+	if err != nil {
+		return fmt.Errorf("prune schedule runs in %q: %w", s.path, err)
+	}
+	return nil
+}
+
+// Runs returns up to limit of the schedule's runs, newest first.
+func (s *Store) Runs(ctx context.Context, scheduleID string, limit int) ([]Run, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT schedule_id, sandbox_id, execution_id, run_id, started_at_unix_ms,
+			finished_at_unix_ms, status, exit_code, error
+		FROM schedule_runs
+		WHERE schedule_id = ?
+		ORDER BY started_at_unix_ms DESC, rowid DESC
+		LIMIT ?`, scheduleID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query schedule runs in %q: %w", s.path, err)
+	}
+	defer rows.Close()
+
+	var out []Run
+	for rows.Next() {
+		var (
+			r                 Run
+			started, finished int64
+		)
+		if err := rows.Scan(&r.ScheduleID, &r.SandboxID, &r.ExecutionID, &r.RunID, &started,
+			&finished, &r.Status, &r.ExitCode, &r.Error); err != nil {
+			return nil, fmt.Errorf("read schedule runs in %q: %w", s.path, err)
+		}
+		r.StartedAt = time.UnixMilli(started).UTC()
+		r.FinishedAt = time.UnixMilli(finished).UTC()
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,380 @@
+// Package selfupdate replaces the installed cleanroom binaries with those
+// from a GitHub release, after checking the release archive against its
+// signed checksums.
+package selfupdate
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	DefaultRepo        = "buildkite/cleanroom"
+	defaultDownloadURL = "https://github.com"
+	defaultAPIURL      = "https://api.github.com"
+
+	checksumsAsset = "checksums.txt"
+	signatureAsset = "checksums.txt.sig"
+
+	maxChecksumsBytes = 1 << 20
+	maxArchiveBytes   = 512 << 20
+	maxFileBytes      = 256 << 20
+)
+
+// releasePublicKey is the base64 Ed25519 public key release checksums are
+// signed with. Release builds set it with -ldflags -X; development builds
+// leave it empty and can only check checksums.
+var releasePublicKey string
+
+// ReleasePublicKey returns the key this build was released with, or nil
+// for a development build.
+func ReleasePublicKey() (ed25519.PublicKey, error) {
+	return ParsePublicKey(releasePublicKey)
+}
+
+// ParsePublicKey decodes a base64 Ed25519 public key. An empty string
+// returns nil.
+func ParsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	encoded = strings.TrimSpace(encoded)
+	if encoded == "" {
+		return nil, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode release public key: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("release public key is %d bytes, want %d", len(raw), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// AssetName is the release archive for goos and goarch, as named by
+// .goreleaser.yml.
+func AssetName(goos, goarch string) (string, error) {
+	var osName, archName string
+	switch goos {
+	case "linux":
+		osName = "Linux"
+	case "darwin":
+		osName = "Darwin"
+	default:
+		return "", fmt.Errorf("no cleanroom release for %s", goos)
+	}
+	switch goarch {
+	case "amd64":
+		archName = "x86_64"
+	case "arm64":
+		archName = "arm64"
+	default:
+		return "", fmt.Errorf("no cleanroom release for %s/%s", goos, goarch)
+	}
+	return fmt.Sprintf("cleanroom_%s_%s.tar.gz", osName, archName), nil
+}
+
+// Source downloads releases of Repo from GitHub.
+type Source struct {
+	Repo string
+	// DownloadURL and APIURL default to GitHub's. Tests point them at a
+	// fake server.
+	DownloadURL string
+	APIURL      string
+	Client      *http.Client
+}
+
+func (s Source) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return http.DefaultClient
+}
+
+func (s Source) repo() string {
+	if s.Repo == "" {
+		return DefaultRepo
+	}
+	return s.Repo
+}
+
+// LatestVersion returns the tag of Repo's latest release.
+func (s Source) LatestVersion(ctx context.Context) (string, error) {
+	apiURL := strings.TrimRight(s.APIURL, "/")
+	if apiURL == "" {
+		apiURL = defaultAPIURL
+	}
+	body, err := s.get(ctx, fmt.Sprintf("%s/repos/%s/releases/latest", apiURL, s.repo()), maxChecksumsBytes)
+	if err != nil {
+		return "", fmt.Errorf("find latest release: %w", err)
+	}
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.Unmarshal(body, &release); err != nil {
+		return "", fmt.Errorf("find latest release: %w", err)
+	}
+	if release.TagName == "" {
+		return "", errors.New("find latest release: response has no tag_name")
+	}
+	return release.TagName, nil
+}
+
+// Download fetches version's asset into dir and verifies it. The release's
+// checksums file must list the asset's SHA-256 and, when key is not nil,
+// carry a valid signature by key. It returns the path of the verified
+// asset.
+func (s Source) Download(ctx context.Context, version, asset string, key ed25519.PublicKey, dir string) (string, error) {
+	checksums, err := s.fetchAsset(ctx, version, checksumsAsset, maxChecksumsBytes)
+	if err != nil {
+		return "", err
+	}
+	if key != nil {
+		signature, err := s.fetchAsset(ctx, version, signatureAsset, ed25519.SignatureSize)
+		if err != nil {
+			return "", err
+		}
+		if !ed25519.Verify(key, checksums, signature) {
+			return "", fmt.Errorf("%s for %s is not signed by the release key", checksumsAsset, version)
+		}
+	}
+	want, err := lookupChecksum(checksums, asset)
+	if err != nil {
+		return "", fmt.Errorf("%s for %s: %w", checksumsAsset, version, err)
+	}
+
+	resp, err := s.open(ctx, s.assetURL(version, asset))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	path := filepath.Join(dir, asset)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, hash), io.LimitReader(resp.Body, maxArchiveBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("download %s: %w", asset, err)
+	}
+	if n > maxArchiveBytes {
+		return "", fmt.Errorf("download %s: larger than %d bytes", asset, maxArchiveBytes)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return "", fmt.Errorf("checksum mismatch for %s: got %s, want %s", asset, got, want)
+	}
+	return path, f.Close()
+}
+
+func (s Source) assetURL(version, name string) string {
+	downloadURL := strings.TrimRight(s.DownloadURL, "/")
+	if downloadURL == "" {
+		downloadURL = defaultDownloadURL
+	}
+	return fmt.Sprintf("%s/%s/releases/download/%s/%s", downloadURL, s.repo(), version, name)
+}
+
+func (s Source) fetchAsset(ctx context.Context, version, name string, limit int64) ([]byte, error) {
+	body, err := s.get(ctx, s.assetURL(version, name), limit)
+	if err != nil {
+		return nil, fmt.Errorf("download %s for %s: %w", name, version, err)
+	}
+	return body, nil
+}
+
+func (s Source) get(ctx context.Context, url string, limit int64) ([]byte, error) {
+	resp, err := s.open(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("%s: larger than %d bytes", url, limit)
+	}
+	return body, nil
+}
+
+func (s Source) open(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return resp, nil
+}
+
+// lookupChecksum finds name in a sha256sum-style checksums file.
+func lookupChecksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum for %s", name)
+}
+
+// Extract copies the files named in dest out of a release archive, each to
+// its destination path with mode 0755. Every named file must be present.
+// Only a file's base name is matched, so archive paths cannot escape.
+func Extract(archivePath string, dest map[string]string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", filepath.Base(archivePath), err)
+	}
+	defer gz.Close()
+
+	found := map[string]bool{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read %s: %w", filepath.Base(archivePath), err)
+		}
+		name := filepath.Base(hdr.Name)
+		path, ok := dest[name]
+		if !ok || hdr.Typeflag != tar.TypeReg || found[name] {
+			continue
+		}
+		if hdr.Size > maxFileBytes {
+			return fmt.Errorf("%s in %s is larger than %d bytes", name, filepath.Base(archivePath), maxFileBytes)
+		}
+		if err := writeFile(path, tr); err != nil {
+			return err
+		}
+		found[name] = true
+	}
+	for name := range dest {
+		if !found[name] {
+			return fmt.Errorf("%s is missing from %s", name, filepath.Base(archivePath))
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Swap renames each staged file over its target, keyed by target path.
+// Each rename replaces its target atomically, so the target path always
+// holds a complete binary. The previous binary is kept as a hard link (or a
+// copy, where links are not supported) until every target is replaced; if
+// any rename fails, targets already replaced are restored from those
+// backups, so the binaries stay at one version.
+func Swap(staged map[string]string) error {
+	type swapped struct {
+		target, backup string
+	}
+	var done []swapped
+	rollback := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			if done[i].backup == "" {
+				_ = os.Remove(done[i].target)
+				continue
+			}
+			_ = os.Rename(done[i].backup, done[i].target)
+		}
+	}
+	for target, source := range staged {
+		backup, err := backupFile(target)
+		if err != nil {
+			rollback()
+			return fmt.Errorf("back up %s: %w", target, err)
+		}
+		if err := os.Rename(source, target); err != nil {
+			if backup != "" {
+				_ = os.Remove(backup)
+			}
+			rollback()
+			return fmt.Errorf("replace %s: %w", target, err)
+		}
+		done = append(done, swapped{target: target, backup: backup})
+	}
+	for _, s := range done {
+		if s.backup != "" {
+			_ = os.Remove(s.backup)
+		}
+	}
+	return nil
+}
+
+// backupFile preserves target as target+".old" without moving it, and
+// returns the backup's path, or "" when target does not exist.
+func backupFile(target string) (string, error) {
+	info, err := os.Lstat(target)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	backup := target + ".old"
+	if err := os.Remove(backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if err := os.Link(target, backup); err == nil {
+		return backup, nil
+	}
+	src, err := os.Open(target)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	if err := writeFile(backup, src); err != nil {
+		_ = os.Remove(backup)
+		return "", err
+	}
+	if err := os.Chmod(backup, info.Mode().Perm()); err != nil {
+		_ = os.Remove(backup)
+		return "", err
+	}
+	return backup, nil
+}
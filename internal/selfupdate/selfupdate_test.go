@@ -0,0 +1,201 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// startRelease serves a GitHub-shaped release of assets for version.
+func startRelease(t *testing.T, version string, assets map[string][]byte) Source {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/acme/cleanroom/releases/latest", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, `{"tag_name":%q}`, version)
+	})
+	for name, content := range assets {
+		mux.HandleFunc("/acme/cleanroom/releases/download/"+version+"/"+name, func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write(content)
+		})
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return Source{Repo: "acme/cleanroom", DownloadURL: server.URL, APIURL: server.URL}
+}
+
+func signedRelease(t *testing.T, archive []byte, key ed25519.PrivateKey) map[string][]byte {
+	t.Helper()
+	sum := sha256.Sum256(archive)
+	checksums := []byte(hex.EncodeToString(sum[:]) + "  cleanroom_Linux_x86_64.tar.gz\n")
+	assets := map[string][]byte{
+		"cleanroom_Linux_x86_64.tar.gz": archive,
+		checksumsAsset:                  checksums,
+	}
+	if key != nil {
+		assets[signatureAsset] = ed25519.Sign(key, checksums)
+	}
+	return assets
+}
+
+func TestDownloadVerifiesSignedChecksums(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := testArchive(t, map[string]string{"cleanroom": "new"})
+	source := startRelease(t, "v1.2.0", signedRelease(t, archive, priv))
+
+	version, err := source.LatestVersion(context.Background())
+	if err != nil || version != "v1.2.0" {
+		t.Fatalf("LatestVersion = %q, %v", version, err)
+	}
+	path, err := source.Download(context.Background(), version, "cleanroom_Linux_x86_64.tar.gz", pub, t.TempDir())
+	if err != nil {
+		t.Fatalf("Download returned error: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(got, archive) {
+		t.Fatalf("downloaded archive differs: %v", err)
+	}
+}
+
+func TestDownloadRejectsWrongSigningKey(t *testing.T) {
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	_, priv, _ := ed25519.GenerateKey(nil)
+	archive := testArchive(t, map[string]string{"cleanroom": "new"})
+	source := startRelease(t, "v1.2.0", signedRelease(t, archive, priv))
+
+	_, err := source.Download(context.Background(), "v1.2.0", "cleanroom_Linux_x86_64.tar.gz", otherPub, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "not signed by the release key") {
+		t.Fatalf("expected a signature error, got %v", err)
+	}
+}
+
+func TestDownloadRejectsChecksumMismatch(t *testing.T) {
+	archive := testArchive(t, map[string]string{"cleanroom": "new"})
+	assets := signedRelease(t, archive, nil)
+	assets["cleanroom_Linux_x86_64.tar.gz"] = testArchive(t, map[string]string{"cleanroom": "tampered"})
+	source := startRelease(t, "v1.2.0", assets)
+
+	_, err := source.Download(context.Background(), "v1.2.0", "cleanroom_Linux_x86_64.tar.gz", nil, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum error, got %v", err)
+	}
+}
+
+func TestExtractRequiresEveryFile(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "release.tar.gz")
+	if err := os.WriteFile(archivePath, testArchive(t, map[string]string{"nested/cleanroom": "new"}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Extract(archivePath, map[string]string{
+		"cleanroom":             filepath.Join(dir, "cleanroom"),
+		"cleanroom-guest-agent": filepath.Join(dir, "agent"),
+	})
+	if err == nil || !strings.Contains(err.Error(), "cleanroom-guest-agent is missing") {
+		t.Fatalf("expected a missing file error, got %v", err)
+	}
+}
+
+func TestSwapRestoresTargetsWhenARenameFails(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "cleanroom")
+	if err := os.WriteFile(target, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	staged := filepath.Join(dir, ".cleanroom.new")
+	if err := os.WriteFile(staged, []byte("new"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Swap(map[string]string{
+		target:                        staged,
+		filepath.Join(dir, "missing"): filepath.Join(dir, ".missing.new"),
+	})
+	if err == nil {
+		t.Fatal("expected Swap to fail")
+	}
+	got, err := os.ReadFile(target)
+	if err != nil || string(got) != "old" {
+		t.Fatalf("target not restored: %q, %v", got, err)
+	}
+}
+
+func TestSwapRenamesStagedFileOverTarget(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "cleanroom")
+	if err := os.WriteFile(target, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	staged := filepath.Join(dir, ".cleanroom.new")
+	if err := os.WriteFile(staged, []byte("new"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	stagedInfo, err := os.Stat(staged)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Swap(map[string]string{target: staged}); err != nil {
+		t.Fatalf("Swap returned error: %v", err)
+	}
+	targetInfo, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The target is the staged file itself, renamed into place, rather
+	// than a copy written after the old binary was moved away.
+	if !os.SameFile(stagedInfo, targetInfo) {
+		t.Fatal("expected the staged file to be renamed over the target")
+	}
+	if got, err := os.ReadFile(target); err != nil || string(got) != "new" {
+		t.Fatalf("unexpected target contents: %q, %v", got, err)
+	}
+	if _, err := os.Lstat(target + ".old"); !os.IsNotExist(err) {
+		t.Fatalf("expected the backup to be removed, got %v", err)
+	}
+}
+
+func TestParsePublicKeyRejectsWrongLength(t *testing.T) {
+	if _, err := ParsePublicKey("c2hvcnQ="); err == nil {
+		t.Fatal("expected a short key to be rejected")
+	}
+	if key, err := ParsePublicKey(""); key != nil || err != nil {
+		t.Fatalf("empty key = %v, %v", key, err)
+	}
+}
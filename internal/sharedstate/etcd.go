@@ -0,0 +1,194 @@
+package sharedstate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxErrorBodySize = 4096
+
+// Etcd talks to an etcd cluster through its v3 JSON gateway, so serve
+// needs no gRPC client. Requests go to the endpoint that last answered,
+// moving on to the next when one cannot be reached.
+type Etcd struct {
+	endpoints []string
+	client    *http.Client
+
+	mu      sync.Mutex
+	current int
+}
+
+// NewEtcd returns a client for the cluster serving endpoints, such as
+// https://etcd-1:2379. A nil client uses one with a 10 second timeout.
+func NewEtcd(endpoints []string, client *http.Client) (*Etcd, error) {
+	e := &Etcd{client: client}
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(strings.TrimSpace(endpoint))
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fmt.Errorf("invalid etcd endpoint %q: expected https://host:2379", endpoint)
+		}
+		e.endpoints = append(e.endpoints, strings.TrimRight(u.String(), "/"))
+	}
+	if len(e.endpoints) == 0 {
+		return nil, errors.New("no etcd endpoints")
+	}
+	if e.client == nil {
+		e.client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return e, nil
+}
+
+// call posts req as JSON to path and decodes the first JSON object of the
+// response into resp.
+func (e *Etcd) call(ctx context.Context, path string, req, resp any) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	start := e.current
+	e.mu.Unlock()
+
+	var lastErr error
+	for i := range e.endpoints {
+		n := (start + i) % len(e.endpoints)
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoints[n]+path, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpResp, err := e.client.Do(httpReq)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+		e.mu.Lock()
+		e.current = n
+		e.mu.Unlock()
+		defer httpResp.Body.Close()
+		if httpResp.StatusCode != http.StatusOK {
+			detail, _ := io.ReadAll(io.LimitReader(httpResp.Body, maxErrorBodySize))
+			return fmt.Errorf("etcd %s: %s: %s", path, httpResp.Status, strings.TrimSpace(string(detail)))
+		}
+		if err := json.NewDecoder(httpResp.Body).Decode(resp); err != nil {
+			return fmt.Errorf("etcd %s: parse response: %w", path, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("no etcd endpoint reachable: %w", lastErr)
+}
+
+// The gateway encodes 64-bit integers as strings and bytes as base64.
+
+type keyValue struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+type compare struct {
+	Key     []byte `json:"key"`
+	Target  string `json:"target"`
+	Result  string `json:"result"`
+	Lease   string `json:"lease,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+type requestOp struct {
+	Put    *putRequest    `json:"requestPut,omitempty"`
+	Delete *deleteRequest `json:"requestDeleteRange,omitempty"`
+}
+
+type putRequest struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+	Lease string `json:"lease,omitempty"`
+}
+
+type deleteRequest struct {
+	Key []byte `json:"key"`
+}
+
+// grant returns a new lease that expires ttl after its last renewal.
+func (e *Etcd) grant(ctx context.Context, ttl time.Duration) (int64, error) {
+	var resp struct {
+		ID    string `json:"ID"`
+		Error string `json:"error"`
+	}
+	if err := e.call(ctx, "/v3/lease/grant", map[string]any{"TTL": int64(ttl / time.Second)}, &resp); err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseInt(resp.ID, 10, 64)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("etcd granted no lease: %s", resp.Error)
+	}
+	return id, nil
+}
+
+// keepAlive renews lease, reporting false once it has expired.
+func (e *Etcd) keepAlive(ctx context.Context, lease int64) (bool, error) {
+	var resp struct {
+		Result struct {
+			TTL string `json:"TTL"`
+		} `json:"result"`
+	}
+	if err := e.call(ctx, "/v3/lease/keepalive", map[string]string{"ID": strconv.FormatInt(lease, 10)}, &resp); err != nil {
+		return false, err
+	}
+	ttl, _ := strconv.ParseInt(resp.Result.TTL, 10, 64)
+	return ttl > 0, nil
+}
+
+// revoke ends lease, deleting the keys attached to it.
+func (e *Etcd) revoke(ctx context.Context, lease int64) error {
+	var resp struct{}
+	return e.call(ctx, "/v3/lease/revoke", map[string]string{"ID": strconv.FormatInt(lease, 10)}, &resp)
+}
+
+// txn applies ops if every comparison holds, reporting whether they did.
+func (e *Etcd) txn(ctx context.Context, compares []compare, ops []requestOp) (bool, error) {
+	var resp struct {
+		Succeeded bool `json:"succeeded"`
+	}
+	err := e.call(ctx, "/v3/kv/txn", map[string]any{"compare": compares, "success": ops}, &resp)
+	return resp.Succeeded, err
+}
+
+// rangeKeys returns the key, or every key under it when prefix is set.
+func (e *Etcd) rangeKeys(ctx context.Context, key string, prefix bool) ([]keyValue, error) {
+	req := map[string][]byte{"key": []byte(key)}
+	if prefix {
+		req["range_end"] = prefixEnd(key)
+	}
+	var resp struct {
+		KVs []keyValue `json:"kvs"`
+	}
+	if err := e.call(ctx, "/v3/kv/range", req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.KVs, nil
+}
+
+// prefixEnd is the first key after every key starting with prefix.
+func prefixEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return []byte{0}
+}
@@ -0,0 +1,452 @@
+// Package sharedstate shares sandbox and execution records between serve
+// instances through etcd, so any instance behind a load balancer can answer
+// reads while calls that need a sandbox's VM go to the instance running it.
+//
+// Each instance holds a lease on <prefix>/hosts/<host_id> and attaches its
+// records to that lease, so they expire with an instance that stops
+// renewing it. Every write is fenced on the lease: an instance whose lease
+// expired, for example across a network partition, cannot overwrite
+// records until it registers again, at which point it rewrites them all.
+package sharedstate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+	"github.com/charmbracelet/log"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	// DefaultPrefix is the key prefix used when Options.Prefix is empty.
+	DefaultPrefix = "/cleanroom"
+	// DefaultLeaseTTL is how long records outlive an instance that stops
+	// renewing its lease, when Options.LeaseTTL is zero.
+	DefaultLeaseTTL = 10 * time.Second
+
+	requestTimeout = 5 * time.Second
+	maxRetryDelay  = 5 * time.Second
+)
+
+// ErrNotFound is returned for a record no live instance holds.
+var ErrNotFound = errors.New("not in shared state")
+
+// Host is the serve instance that holds a record.
+type Host struct {
+	ID string `json:"id"`
+	// URL is where other instances reach the host's control API.
+	URL string `json:"url"`
+}
+
+// SandboxRecord is a sandbox as last written by the host running it.
+type SandboxRecord struct {
+	Host    Host
+	Sandbox *cleanroomv1.Sandbox
+}
+
+// ExecutionRecord is an execution as last written by the host running it.
+type ExecutionRecord struct {
+	Host      Host
+	Execution *cleanroomv1.Execution
+}
+
+// record is the stored form of a SandboxRecord or ExecutionRecord.
+type record struct {
+	Host      Host            `json:"host"`
+	Sandbox   json.RawMessage `json:"sandbox,omitempty"`
+	Execution json.RawMessage `json:"execution,omitempty"`
+}
+
+// Options configure New.
+type Options struct {
+	Prefix   string
+	Host     Host
+	LeaseTTL time.Duration
+}
+
+// State writes this instance's records to etcd and reads every instance's
+// back. The Put and Delete methods never block: they update an in-memory
+// copy and a background goroutine writes the keys that changed, coalescing
+// updates to the same record.
+type State struct {
+	etcd   *Etcd
+	prefix string
+	host   Host
+	ttl    time.Duration
+	logger *log.Logger
+
+	mu    sync.Mutex
+	local map[string][]byte // this instance's records by key
+	dirty map[string]bool   // keys not yet written since they changed
+	lease int64             // 0 while unregistered
+	wake  chan struct{}
+
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// New registers opts.Host in etcd and starts writing records. Registration
+// is retried in the background, so New does not wait for etcd: a host ID
+// still held by a previous run is taken over once its lease expires.
+func New(etcd *Etcd, opts Options, logger *log.Logger) (*State, error) {
+	if etcd == nil {
+		return nil, errors.New("shared state requires an etcd client")
+	}
+	if opts.Host.ID == "" || strings.Contains(opts.Host.ID, "/") {
+		return nil, fmt.Errorf("invalid host ID %q", opts.Host.ID)
+	}
+	if opts.Host.URL == "" {
+		return nil, errors.New("shared state requires the URL other instances reach this one at")
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	s := &State{
+		etcd:    etcd,
+		prefix:  strings.TrimRight(opts.Prefix, "/"),
+		host:    opts.Host,
+		ttl:     opts.LeaseTTL,
+		logger:  logger,
+		local:   map[string][]byte{},
+		dirty:   map[string]bool{},
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if s.prefix == "" {
+		s.prefix = DefaultPrefix
+	}
+	if s.ttl <= 0 {
+		s.ttl = DefaultLeaseTTL
+	}
+	go s.run()
+	return s, nil
+}
+
+// HostID returns this instance's host ID.
+func (s *State) HostID() string {
+	return s.host.ID
+}
+
+// Registered reports whether this instance currently holds its lease.
+func (s *State) Registered() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lease != 0
+}
+
+// Close revokes the lease, removing this instance's records, and stops
+// writing.
+func (s *State) Close() error {
+	s.once.Do(func() { close(s.done) })
+	<-s.stopped
+	return nil
+}
+
+func (s *State) hostKey() string {
+	return s.prefix + "/hosts/" + s.host.ID
+}
+
+func (s *State) sandboxKey(sandboxID string) string {
+	return s.prefix + "/sandboxes/" + sandboxID
+}
+
+func (s *State) executionKey(sandboxID, executionID string) string {
+	return s.prefix + "/executions/" + sandboxID + "/" + executionID
+}
+
+// PutSandbox records sb as held by this instance.
+func (s *State) PutSandbox(sb *cleanroomv1.Sandbox) {
+	data, err := protojson.Marshal(sb)
+	if err != nil {
+		return
+	}
+	s.set(s.sandboxKey(sb.GetSandboxId()), record{Host: s.host, Sandbox: data})
+}
+
+// PutExecution records ex as held by this instance.
+func (s *State) PutExecution(ex *cleanroomv1.Execution) {
+	data, err := protojson.Marshal(ex)
+	if err != nil {
+		return
+	}
+	s.set(s.executionKey(ex.GetSandboxId(), ex.GetExecutionId()), record{Host: s.host, Execution: data})
+}
+
+// DeleteSandbox removes the record of a sandbox this instance dropped.
+func (s *State) DeleteSandbox(sandboxID string) {
+	s.remove(s.sandboxKey(sandboxID))
+}
+
+// DeleteExecution removes the record of an execution this instance
+// dropped.
+func (s *State) DeleteExecution(sandboxID, executionID string) {
+	s.remove(s.executionKey(sandboxID, executionID))
+}
+
+func (s *State) set(key string, rec record) {
+	value, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.local[key] = value
+	s.dirty[key] = true
+	s.mu.Unlock()
+	s.notify()
+}
+
+func (s *State) remove(key string) {
+	s.mu.Lock()
+	if _, ok := s.local[key]; !ok {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.local, key)
+	s.dirty[key] = true
+	s.mu.Unlock()
+	s.notify()
+}
+
+func (s *State) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Sandbox returns the record of sandboxID, or ErrNotFound.
+func (s *State) Sandbox(ctx context.Context, sandboxID string) (SandboxRecord, error) {
+	kvs, err := s.etcd.rangeKeys(ctx, s.sandboxKey(sandboxID), false)
+	if err != nil {
+		return SandboxRecord{}, err
+	}
+	if len(kvs) == 0 {
+		return SandboxRecord{}, fmt.Errorf("sandbox %q: %w", sandboxID, ErrNotFound)
+	}
+	return decodeSandbox(kvs[0].Value)
+}
+
+// Sandboxes returns the records of every live instance's sandboxes.
+func (s *State) Sandboxes(ctx context.Context) ([]SandboxRecord, error) {
+	kvs, err := s.etcd.rangeKeys(ctx, s.prefix+"/sandboxes/", true)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]SandboxRecord, 0, len(kvs))
+	for _, kv := range kvs {
+		rec, err := decodeSandbox(kv.Value)
+		if err != nil {
+			s.logger.Warn("skipping unreadable shared sandbox record", "key", string(kv.Key), "error", err)
+			continue
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+// Execution returns the record of an execution, or ErrNotFound.
+func (s *State) Execution(ctx context.Context, sandboxID, executionID string) (ExecutionRecord, error) {
+	kvs, err := s.etcd.rangeKeys(ctx, s.executionKey(sandboxID, executionID), false)
+	if err != nil {
+		return ExecutionRecord{}, err
+	}
+	if len(kvs) == 0 {
+		return ExecutionRecord{}, fmt.Errorf("execution %q in sandbox %q: %w", executionID, sandboxID, ErrNotFound)
+	}
+	var rec record
+	if err := json.Unmarshal(kvs[0].Value, &rec); err != nil {
+		return ExecutionRecord{}, err
+	}
+	ex := &cleanroomv1.Execution{}
+	if err := protojson.Unmarshal(rec.Execution, ex); err != nil {
+		return ExecutionRecord{}, err
+	}
+	return ExecutionRecord{Host: rec.Host, Execution: ex}, nil
+}
+
+func decodeSandbox(value []byte) (SandboxRecord, error) {
+	var rec record
+	if err := json.Unmarshal(value, &rec); err != nil {
+		return SandboxRecord{}, err
+	}
+	sb := &cleanroomv1.Sandbox{}
+	if err := protojson.Unmarshal(rec.Sandbox, sb); err != nil {
+		return SandboxRecord{}, err
+	}
+	return SandboxRecord{Host: rec.Host, Sandbox: sb}, nil
+}
+
+// run registers the host, renews its lease and writes changed records
+// until Close.
+func (s *State) run() {
+	defer close(s.stopped)
+	renew := time.NewTicker(s.ttl / 3)
+	defer renew.Stop()
+	retry := time.NewTimer(0)
+	defer retry.Stop()
+	retryDelay := 100 * time.Millisecond
+	backoff := func() {
+		retry.Reset(retryDelay)
+		retryDelay = min(retryDelay*2, maxRetryDelay)
+	}
+
+	for {
+		select {
+		case <-s.done:
+			s.unregister()
+			return
+		case <-renew.C:
+			if s.currentLease() != 0 {
+				s.renew()
+			}
+			continue
+		case <-s.wake:
+		case <-retry.C:
+		}
+		if s.currentLease() == 0 && !s.register() {
+			backoff()
+			continue
+		}
+		if !s.flush() {
+			backoff()
+			continue
+		}
+		retryDelay = 100 * time.Millisecond
+	}
+}
+
+func (s *State) currentLease() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lease
+}
+
+// register takes the host key under a new lease, if no live lease holds
+// it, and marks every record for rewriting.
+func (s *State) register() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	lease, err := s.etcd.grant(ctx, s.ttl)
+	if err != nil {
+		s.logger.Warn("shared state: grant lease", "error", err)
+		return false
+	}
+	hostValue, _ := json.Marshal(s.host)
+	leaseID := strconv.FormatInt(lease, 10)
+	ok, err := s.etcd.txn(ctx,
+		[]compare{{Key: []byte(s.hostKey()), Target: "VERSION", Result: "EQUAL", Version: "0"}},
+		[]requestOp{{Put: &putRequest{Key: []byte(s.hostKey()), Value: hostValue, Lease: leaseID}}},
+	)
+	if err != nil || !ok {
+		_ = s.etcd.revoke(ctx, lease)
+		if err != nil {
+			s.logger.Warn("shared state: register host", "error", err)
+		} else {
+			s.logger.Warn("shared state: host ID is held by a live lease; retrying", "host_id", s.host.ID)
+		}
+		return false
+	}
+	s.mu.Lock()
+	s.lease = lease
+	for key := range s.local {
+		s.dirty[key] = true
+	}
+	s.mu.Unlock()
+	s.logger.Info("shared state: registered host", "host_id", s.host.ID, "url", s.host.URL)
+	return true
+}
+
+func (s *State) renew() {
+	lease := s.currentLease()
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	alive, err := s.etcd.keepAlive(ctx, lease)
+	if err != nil {
+		s.logger.Warn("shared state: renew lease", "error", err)
+		return
+	}
+	if !alive {
+		s.lost(lease)
+	}
+}
+
+// lost forgets lease after etcd expired it, so the next write registers
+// the host again.
+func (s *State) lost(lease int64) {
+	s.mu.Lock()
+	if s.lease == lease {
+		s.lease = 0
+	}
+	s.mu.Unlock()
+	s.logger.Warn("shared state: lease expired; registering again", "host_id", s.host.ID)
+	s.notify()
+}
+
+// flush writes the records that changed, each fenced on the lease still
+// holding the host key. It reports false when a write must be retried.
+func (s *State) flush() bool {
+	s.mu.Lock()
+	lease := s.lease
+	writes := make(map[string][]byte, len(s.dirty))
+	for key := range s.dirty {
+		writes[key] = s.local[key]
+	}
+	clear(s.dirty)
+	s.mu.Unlock()
+
+	leaseID := strconv.FormatInt(lease, 10)
+	fence := []compare{{Key: []byte(s.hostKey()), Target: "LEASE", Result: "EQUAL", Lease: leaseID}}
+	failed := false
+	for key, value := range writes {
+		if failed {
+			s.redo(key)
+			continue
+		}
+		op := requestOp{Delete: &deleteRequest{Key: []byte(key)}}
+		if value != nil {
+			op = requestOp{Put: &putRequest{Key: []byte(key), Value: value, Lease: leaseID}}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		ok, err := s.etcd.txn(ctx, fence, []requestOp{op})
+		cancel()
+		if err != nil || !ok {
+			failed = true
+			s.redo(key)
+			if err != nil {
+				s.logger.Warn("shared state: write record", "key", key, "error", err)
+			} else {
+				s.lost(lease)
+			}
+		}
+	}
+	return !failed
+}
+
+func (s *State) redo(key string) {
+	s.mu.Lock()
+	s.dirty[key] = true
+	s.mu.Unlock()
+}
+
+// unregister revokes the lease, which deletes the host key and every
+// record attached to it.
+func (s *State) unregister() {
+	lease := s.currentLease()
+	if lease == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	if err := s.etcd.revoke(ctx, lease); err != nil {
+		s.logger.Warn("shared state: revoke lease", "error", err)
+	}
+}
@@ -0,0 +1,247 @@
+package sharedstate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cleanroomv1 "github.com/buildkite/cleanroom/internal/gen/cleanroom/v1"
+)
+
+// fakeEtcd serves the subset of etcd's v3 JSON gateway State uses.
+type fakeEtcd struct {
+	mu        sync.Mutex
+	nextLease int64
+	leases    map[int64]bool
+	values    map[string][]byte
+	leaseOf   map[string]int64
+}
+
+func newFakeEtcd() *fakeEtcd {
+	return &fakeEtcd{leases: map[int64]bool{}, values: map[string][]byte{}, leaseOf: map[string]int64{}}
+}
+
+// expire ends lease as etcd does when it is not renewed in time.
+func (f *fakeEtcd) expire(lease int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expireLocked(lease)
+}
+
+func (f *fakeEtcd) expireLocked(lease int64) {
+	delete(f.leases, lease)
+	for key, l := range f.leaseOf {
+		if l == lease {
+			delete(f.values, key)
+			delete(f.leaseOf, key)
+		}
+	}
+}
+
+func (f *fakeEtcd) leaseFor(key string) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.leaseOf[key]
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var req struct {
+		ID       string      `json:"ID"`
+		TTL      int64       `json:"TTL"`
+		Key      []byte      `json:"key"`
+		RangeEnd []byte      `json:"range_end"`
+		Compare  []compare   `json:"compare"`
+		Success  []requestOp `json:"success"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id, _ := strconv.ParseInt(req.ID, 10, 64)
+	var resp any
+	switch r.URL.Path {
+	case "/v3/lease/grant":
+		f.nextLease++
+		f.leases[f.nextLease] = true
+		resp = map[string]string{"ID": strconv.FormatInt(f.nextLease, 10), "TTL": strconv.FormatInt(req.TTL, 10)}
+	case "/v3/lease/keepalive":
+		result := map[string]string{"ID": req.ID}
+		if f.leases[id] {
+			result["TTL"] = "10"
+		}
+		resp = map[string]any{"result": result}
+	case "/v3/lease/revoke":
+		f.expireLocked(id)
+		resp = map[string]any{}
+	case "/v3/kv/txn":
+		succeeded := true
+		for _, c := range req.Compare {
+			key := string(c.Key)
+			switch c.Target {
+			case "VERSION":
+				_, exists := f.values[key]
+				succeeded = succeeded && !exists && c.Version == "0"
+			case "LEASE":
+				lease, _ := strconv.ParseInt(c.Lease, 10, 64)
+				_, exists := f.values[key]
+				succeeded = succeeded && exists && f.leaseOf[key] == lease
+			}
+		}
+		if succeeded {
+			for _, op := range req.Success {
+				switch {
+				case op.Put != nil:
+					lease, _ := strconv.ParseInt(op.Put.Lease, 10, 64)
+					f.values[string(op.Put.Key)] = op.Put.Value
+					f.leaseOf[string(op.Put.Key)] = lease
+				case op.Delete != nil:
+					delete(f.values, string(op.Delete.Key))
+					delete(f.leaseOf, string(op.Delete.Key))
+				}
+			}
+		}
+		resp = map[string]bool{"succeeded": succeeded}
+	case "/v3/kv/range":
+		var kvs []keyValue
+		for key, value := range f.values {
+			if key == string(req.Key) || (req.RangeEnd != nil && key >= string(req.Key) && key < string(req.RangeEnd)) {
+				kvs = append(kvs, keyValue{Key: []byte(key), Value: value})
+			}
+		}
+		resp = map[string]any{"kvs": kvs}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func newTestState(t *testing.T, server *httptest.Server, hostID string) *State {
+	t.Helper()
+	etcd, err := NewEtcd([]string{"http://127.0.0.1:1", server.URL}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := New(etcd, Options{Host: Host{ID: hostID, URL: "https://" + hostID + ":7777"}, LeaseTTL: 3 * time.Second}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = state.Close() })
+	return state
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRecordsAreSharedBetweenHosts(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(newFakeEtcd())
+	defer server.Close()
+	a := newTestState(t, server, "host-a")
+	b := newTestState(t, server, "host-b")
+	ctx := context.Background()
+
+	a.PutSandbox(&cleanroomv1.Sandbox{SandboxId: "sb-1", Status: cleanroomv1.SandboxStatus_SANDBOX_STATUS_READY})
+	a.PutExecution(&cleanroomv1.Execution{SandboxId: "sb-1", ExecutionId: "ex-1", Command: []string{"true"}})
+	b.PutSandbox(&cleanroomv1.Sandbox{SandboxId: "sb-2"})
+
+	waitFor(t, "both sandboxes", func() bool {
+		records, err := b.Sandboxes(ctx)
+		return err == nil && len(records) == 2
+	})
+	rec, err := b.Sandbox(ctx, "sb-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Host.ID != "host-a" || rec.Host.URL != "https://host-a:7777" || rec.Sandbox.GetStatus() != cleanroomv1.SandboxStatus_SANDBOX_STATUS_READY {
+		t.Fatalf("unexpected record %+v", rec)
+	}
+	waitFor(t, "the execution", func() bool {
+		ex, err := b.Execution(ctx, "sb-1", "ex-1")
+		return err == nil && ex.Host.ID == "host-a" && ex.Execution.GetCommand()[0] == "true"
+	})
+
+	a.DeleteSandbox("sb-1")
+	waitFor(t, "the deleted sandbox to go", func() bool {
+		_, err := b.Sandbox(ctx, "sb-1")
+		return errors.Is(err, ErrNotFound)
+	})
+
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Sandbox(ctx, "sb-2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected a closed host's records to be revoked, got %v", err)
+	}
+}
+
+func TestExpiredLeaseFencesWritesUntilReregistered(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeEtcd()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	a := newTestState(t, server, "host-a")
+	ctx := context.Background()
+
+	a.PutSandbox(&cleanroomv1.Sandbox{SandboxId: "sb-1"})
+	waitFor(t, "the first write", func() bool {
+		_, err := a.Sandbox(ctx, "sb-1")
+		return err == nil
+	})
+	first := fake.leaseFor(DefaultPrefix + "/hosts/host-a")
+
+	// The lease expires, as across a partition, and a stand-in takes the
+	// host ID: host-a's writes must not land under the stand-in's lease.
+	fake.expire(first)
+	impostor := newTestState(t, server, "host-a")
+	waitFor(t, "the stand-in to register", impostor.Registered)
+	a.PutSandbox(&cleanroomv1.Sandbox{SandboxId: "sb-2"})
+	time.Sleep(200 * time.Millisecond)
+	if _, err := a.Sandbox(ctx, "sb-2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the fenced write to be refused, got %v", err)
+	}
+
+	// Once the stand-in goes, host-a registers again and rewrites every
+	// record it holds.
+	if err := impostor.Close(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "records to be rewritten", func() bool {
+		records, err := a.Sandboxes(ctx)
+		return err == nil && len(records) == 2
+	})
+	if lease := fake.leaseFor(DefaultPrefix + "/sandboxes/sb-1"); lease == first || lease == 0 {
+		t.Fatalf("expected sb-1 under a new lease, got %d", lease)
+	}
+}
+
+func TestNewRejectsHostIDsWithSlashes(t *testing.T) {
+	t.Parallel()
+
+	etcd, err := NewEtcd([]string{"http://127.0.0.1:2379"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(etcd, Options{Host: Host{ID: "a/b", URL: "https://a"}}, nil); err == nil || !strings.Contains(err.Error(), "host ID") {
+		t.Fatalf("expected an invalid host ID error, got %v", err)
+	}
+}
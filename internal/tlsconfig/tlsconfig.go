@@ -20,7 +20,8 @@ type Options struct {
 
 // ResolveServer returns a tls.Config for the server side. If no explicit paths
 // are provided, it auto-discovers from the XDG TLS directory.
-// Returns nil if no TLS material is found.
+// Returns nil if no TLS material is found. With CAPath set, clients may also
+// present a certificate, which must chain to that CA.
 func ResolveServer(opts Options) (*tls.Config, error) {
 	certPath, keyPath, err := resolveServerPaths(opts)
 	if err != nil {
@@ -40,16 +41,24 @@ func ResolveServer(opts Options) (*tls.Config, error) {
 		MinVersion:   tls.VersionTLS13,
 		NextProtos:   []string{"h2", "http/1.1"},
 	}
+	if opts.CAPath != "" {
+		pool, err := loadCAPool(opts.CAPath)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
 
 	return tlsCfg, nil
 }
 
 // ResolveClient returns a tls.Config for the client side. If no explicit paths
-// are provided, it auto-discovers from the XDG TLS directory.
-// Returns nil if no TLS material is found.
+// are provided, it auto-discovers the CA from the XDG TLS directory. A client
+// certificate is only presented when CertPath and KeyPath are both set.
 func ResolveClient(opts Options) (*tls.Config, error) {
-	if opts.CertPath != "" || opts.KeyPath != "" {
-		return nil, fmt.Errorf("client certificates are not supported")
+	if (opts.CertPath == "") != (opts.KeyPath == "") {
+		return nil, fmt.Errorf("a client certificate needs both a certificate and a key")
 	}
 
 	caPath, err := resolveClientCAPath(opts.CAPath)
@@ -60,6 +69,13 @@ func ResolveClient(opts Options) (*tls.Config, error) {
 	tlsCfg := &tls.Config{
 		MinVersion: tls.VersionTLS13,
 	}
+	if opts.CertPath != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertPath, opts.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
 
 	if caPath != "" {
 		pool, err := loadCAPool(caPath)
@@ -72,6 +88,31 @@ func ResolveClient(opts Options) (*tls.Config, error) {
 	return tlsCfg, nil
 }
 
+// ResolvePeer returns a mutual TLS config for host-to-host traffic, such as
+// cache sharing between serve instances. The same config serves and dials:
+// each side presents its certificate and requires the other's to chain to
+// the CA.
+func ResolvePeer(opts Options) (*tls.Config, error) {
+	if opts.CertPath == "" || opts.KeyPath == "" || opts.CAPath == "" {
+		return nil, fmt.Errorf("peer TLS needs a certificate, key and CA")
+	}
+	cert, err := tls.LoadX509KeyPair(opts.CertPath, opts.KeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("load peer certificate: %w", err)
+	}
+	pool, err := loadCAPool(opts.CAPath)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS13,
+	}, nil
+}
+
 func resolveServerPaths(opts Options) (certPath, keyPath string, err error) {
 	certPath = opts.CertPath
 	keyPath = opts.KeyPath
@@ -0,0 +1,116 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Store keeps usage records in a SQLite database.
+type Store struct {
+	db   *sql.DB
+	path string
+}
+
+// Open opens the usage database at path, creating it if needed.
+func Open(ctx context.Context, path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create usage database directory: %w", err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open usage database %q: %w", path, err)
+	}
+	// One connection serialises writers, which SQLite would otherwise
+	// reject as busy.
+	db.SetMaxOpenConns(1)
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS usage (
+			kind TEXT NOT NULL,
+			id TEXT NOT NULL,
+			sandbox_id TEXT NOT NULL,
+			namespace TEXT NOT NULL,
+			identity TEXT NOT NULL,
+			backend TEXT NOT NULL,
+			labels_json TEXT NOT NULL,
+			started_at_unix_ms INTEGER NOT NULL,
+			ended_at_unix_ms INTEGER NOT NULL,
+			vcpus INTEGER NOT NULL,
+			memory_mib INTEGER NOT NULL,
+			disk_mib INTEGER NOT NULL,
+			PRIMARY KEY (kind, id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_usage_ended_at ON usage(ended_at_unix_ms);
+	`)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("initialise usage schema: %w", err)
+	}
+	return &Store{db: db, path: path}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Add saves r, replacing any earlier record of the same sandbox or
+// execution.
+func (s *Store) Add(ctx context.Context, r Record) error {
+	labels, err := json.Marshal(r.Labels)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO usage (
+			kind, id, sandbox_id, namespace, identity, backend, labels_json,
+			started_at_unix_ms, ended_at_unix_ms, vcpus, memory_mib, disk_mib
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.Kind, r.ID, r.SandboxID, r.Namespace, r.Identity, r.Backend, string(labels),
+		r.StartedAt.UnixMilli(), r.EndedAt.UnixMilli(), r.VCPUs, r.MemoryMiB, r.DiskMiB,
+	)
+	if err != nil {
+		return fmt.Errorf("record usage in %q: %w", s.path, err)
+	}
+	return nil
+}
+
+// Records returns the records of kind that ended at or after since.
+func (s *Store) Records(ctx context.Context, kind string, since time.Time) ([]Record, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT kind, id, sandbox_id, namespace, identity, backend, labels_json,
+			started_at_unix_ms, ended_at_unix_ms, vcpus, memory_mib, disk_mib
+		FROM usage
+		WHERE kind = ? AND ended_at_unix_ms >= ?
+		ORDER BY started_at_unix_ms`, kind, since.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("query usage in %q: %w", s.path, err)
+	}
+	defer rows.Close()
+
+	var out []Record
+	for rows.Next() {
+		var (
+			r                Record
+			labels           string
+			started, stopped int64
+		)
+		if err := rows.Scan(&r.Kind, &r.ID, &r.SandboxID, &r.Namespace, &r.Identity, &r.Backend, &labels,
+			&started, &stopped, &r.VCPUs, &r.MemoryMiB, &r.DiskMiB); err != nil {
+			return nil, fmt.Errorf("read usage in %q: %w", s.path, err)
+		}
+		if err := json.Unmarshal([]byte(labels), &r.Labels); err != nil {
+			return nil, fmt.Errorf("read usage labels of %s %s: %w", r.Kind, r.ID, err)
+		}
+		r.StartedAt = time.UnixMilli(started).UTC()
+		r.EndedAt = time.UnixMilli(stopped).UTC()
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
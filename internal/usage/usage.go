@@ -0,0 +1,163 @@
+// Package usage records the resources sandboxes and executions reserved,
+// and sums them for chargeback reports.
+//
+// Usage is measured from what was reserved, not what was used: a sandbox
+// with 2 vCPUs that lives for an hour accounts for 7200 vCPU-seconds
+// whether or not its processes were busy.
+package usage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Record kinds. Executions run inside sandboxes, so their usage is a
+// breakdown of their sandbox's rather than an addition to it.
+const (
+	KindSandbox   = "sandbox"
+	KindExecution = "execution"
+)
+
+// Record is the usage of one sandbox or execution between StartedAt and
+// EndedAt.
+type Record struct {
+	Kind      string
+	ID        string // sandbox ID, or execution ID for executions
+	SandboxID string
+	Namespace string
+	Identity  string // the caller that created the sandbox or execution
+	Backend   string
+	Labels    map[string]string // of the sandbox
+	StartedAt time.Time
+	EndedAt   time.Time
+	VCPUs     int64
+	MemoryMiB int64
+	DiskMiB   int64
+}
+
+// Totals are reserved resources summed over time.
+type Totals struct {
+	VCPUSeconds      float64
+	MemoryMiBSeconds float64
+	DiskGBHours      float64
+}
+
+// Within returns r's totals for the part of its lifetime between from and
+// to.
+func (r Record) Within(from, to time.Time) Totals {
+	start, end := r.StartedAt, r.EndedAt
+	if start.Before(from) {
+		start = from
+	}
+	if !to.IsZero() && end.After(to) {
+		end = to
+	}
+	if !end.After(start) {
+		return Totals{}
+	}
+	seconds := end.Sub(start).Seconds()
+	return Totals{
+		VCPUSeconds:      float64(r.VCPUs) * seconds,
+		MemoryMiBSeconds: float64(r.MemoryMiB) * seconds,
+		DiskGBHours:      float64(r.DiskMiB) / 1024 * seconds / 3600,
+	}
+}
+
+// Row is the usage of one group of records.
+type Row struct {
+	// Group holds the record's value for each group-by key, in order.
+	Group []string
+	// Count is the number of records in the group.
+	Count int64
+	Totals
+}
+
+// Group-by keys. A label key is written label:<key>.
+const (
+	GroupIdentity  = "identity"
+	GroupNamespace = "namespace"
+	GroupBackend   = "backend"
+	GroupSandbox   = "sandbox"
+	groupLabel     = "label:"
+)
+
+// ParseGroupBy validates group-by keys, which may also be given as one
+// comma-separated list.
+func ParseGroupBy(keys []string) ([]string, error) {
+	var out []string
+	for _, key := range keys {
+		for _, part := range strings.Split(key, ",") {
+			part = strings.TrimSpace(part)
+			switch {
+			case part == "":
+				continue
+			case part == GroupIdentity, part == GroupNamespace, part == GroupBackend, part == GroupSandbox:
+			case strings.HasPrefix(part, groupLabel) && strings.TrimSpace(strings.TrimPrefix(part, groupLabel)) != "":
+				part = groupLabel + strings.TrimSpace(strings.TrimPrefix(part, groupLabel))
+			default:
+				return nil, fmt.Errorf("invalid group-by key %q: want identity, namespace, backend, sandbox or label:<key>", part)
+			}
+			out = append(out, part)
+		}
+	}
+	return out, nil
+}
+
+// groupValue returns r's value for a group-by key.
+func (r Record) groupValue(key string) string {
+	switch key {
+	case GroupIdentity:
+		return r.Identity
+	case GroupNamespace:
+		return r.Namespace
+	case GroupBackend:
+		return r.Backend
+	case GroupSandbox:
+		return r.SandboxID
+	}
+	return r.Labels[strings.TrimPrefix(key, groupLabel)]
+}
+
+// Summarize sums the usage of records between from and to, grouped by the
+// keys groupBy names. Rows are sorted by vCPU-seconds, largest first.
+// Records outside the window are left out.
+func Summarize(records []Record, from, to time.Time, groupBy []string) []Row {
+	rows := map[string]*Row{}
+	for _, r := range records {
+		if !overlaps(r, from, to) {
+			continue
+		}
+		totals := r.Within(from, to)
+		group := make([]string, len(groupBy))
+		for i, key := range groupBy {
+			group[i] = r.groupValue(key)
+		}
+		id := strings.Join(group, "\x00")
+		row := rows[id]
+		if row == nil {
+			row = &Row{Group: group}
+			rows[id] = row
+		}
+		row.Count++
+		row.VCPUSeconds += totals.VCPUSeconds
+		row.MemoryMiBSeconds += totals.MemoryMiBSeconds
+		row.DiskGBHours += totals.DiskGBHours
+	}
+	out := make([]Row, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, *row)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].VCPUSeconds != out[j].VCPUSeconds {
+			return out[i].VCPUSeconds > out[j].VCPUSeconds
+		}
+		return strings.Join(out[i].Group, "\x00") < strings.Join(out[j].Group, "\x00")
+	})
+	return out
+}
+
+func overlaps(r Record, from, to time.Time) bool {
+	return !r.EndedAt.Before(from) && (to.IsZero() || !r.StartedAt.After(to))
+}
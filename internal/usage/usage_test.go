@@ -0,0 +1,92 @@
+package usage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSummarizeClipsToWindowAndGroups(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	records := []Record{
+		// Half of this sandbox's two hours fall before the window.
+		{Kind: KindSandbox, ID: "a", Labels: map[string]string{"team": "web"}, StartedAt: from.Add(-time.Hour), EndedAt: from.Add(time.Hour), VCPUs: 2, MemoryMiB: 1024, DiskMiB: 1024},
+		{Kind: KindSandbox, ID: "b", Labels: map[string]string{"team": "web"}, StartedAt: from.Add(2 * time.Hour), EndedAt: from.Add(3 * time.Hour), VCPUs: 1},
+		{Kind: KindSandbox, ID: "c", StartedAt: from.Add(time.Hour), EndedAt: from.Add(2 * time.Hour), VCPUs: 4},
+		{Kind: KindSandbox, ID: "d", Labels: map[string]string{"team": "web"}, StartedAt: from.Add(-3 * time.Hour), EndedAt: from.Add(-2 * time.Hour), VCPUs: 8},
+	}
+	rows := Summarize(records, from, to, []string{"label:team"})
+	if len(rows) != 2 {
+		t.Fatalf("expected two groups, got %+v", rows)
+	}
+	// Rows are sorted by vCPU-seconds, so the unlabelled sandbox comes first.
+	if rows[0].Group[0] != "" || rows[0].VCPUSeconds != 4*3600 {
+		t.Fatalf("unexpected unlabelled row: %+v", rows[0])
+	}
+	if rows[1].Group[0] != "web" || rows[1].Count != 2 || rows[1].VCPUSeconds != 3*3600 {
+		t.Fatalf("unexpected web row: %+v", rows[1])
+	}
+	if rows[1].MemoryMiBSeconds != 1024*3600 || rows[1].DiskGBHours != 1 {
+		t.Fatalf("unexpected web memory or disk: %+v", rows[1])
+	}
+}
+
+func TestParseGroupBy(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseGroupBy([]string{"identity, label: team", "namespace"})
+	if err != nil {
+		t.Fatalf("ParseGroupBy returned error: %v", err)
+	}
+	if want := []string{"identity", "label:team", "namespace"}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("ParseGroupBy = %q, want %q", got, want)
+	}
+	for _, bad := range []string{"label:", "team"} {
+		if _, err := ParseGroupBy([]string{bad}); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store, err := Open(ctx, filepath.Join(t.TempDir(), "usage", "usage.db"))
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer store.Close()
+
+	started := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	want := Record{
+		Kind: KindSandbox, ID: "sb-1", SandboxID: "sb-1", Namespace: "team-a", Identity: "uid:1001", Backend: "firecracker",
+		Labels: map[string]string{"team": "web"}, StartedAt: started, EndedAt: started.Add(time.Hour), VCPUs: 2, MemoryMiB: 512, DiskMiB: 4096,
+	}
+	if err := store.Add(ctx, want); err != nil {
+		t.Fatalf("Add returned error: %v", err)
+	}
+	// A later record of the same sandbox replaces the first.
+	want.EndedAt = started.Add(2 * time.Hour)
+	if err := store.Add(ctx, want); err != nil {
+		t.Fatalf("Add returned error: %v", err)
+	}
+	if err := store.Add(ctx, Record{Kind: KindExecution, ID: "ex-1", SandboxID: "sb-1", StartedAt: started, EndedAt: started.Add(time.Minute)}); err != nil {
+		t.Fatalf("Add returned error: %v", err)
+	}
+
+	got, err := store.Records(ctx, KindSandbox, started)
+	if err != nil {
+		t.Fatalf("Records returned error: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != "sb-1" || got[0].Labels["team"] != "web" || !got[0].EndedAt.Equal(want.EndedAt) || got[0].DiskMiB != 4096 {
+		t.Fatalf("unexpected records: %+v", got)
+	}
+	if got, err := store.Records(ctx, KindSandbox, started.Add(3*time.Hour)); err != nil || len(got) != 0 {
+		t.Fatalf("expected no records ending after the window start, got %+v (%v)", got, err)
+	}
+}
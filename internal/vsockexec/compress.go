@@ -0,0 +1,70 @@
+package vsockexec
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressionZstd asks the guest agent to compress output frames with
+// zstd. Agents that predate it ignore the request and send plain frames.
+const CompressionZstd = "zstd"
+
+const (
+	// minCompressFrameBytes leaves smaller frames, such as interactive
+	// keystroke echoes, uncompressed.
+	minCompressFrameBytes = 512
+	// maxDecompressedFrameBytes bounds one decompressed output frame. The
+	// agent sends its output in chunks far smaller than this.
+	maxDecompressedFrameBytes = 4 << 20
+)
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+// zstdCodecs returns the shared encoder and decoder. EncodeAll and
+// DecodeAll are safe for concurrent use.
+func zstdCodecs() (*zstd.Encoder, *zstd.Decoder) {
+	zstdOnce.Do(func() {
+		// Errors only come from invalid options.
+		zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithEncoderConcurrency(1))
+		zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(maxDecompressedFrameBytes))
+	})
+	return zstdEncoder, zstdDecoder
+}
+
+// CompressStreamFrame compresses the data of a stdout or stderr frame with
+// zstd when that makes it smaller, and marks the frame's Encoding.
+func CompressStreamFrame(frame ExecStreamFrame) ExecStreamFrame {
+	if (frame.Type != "stdout" && frame.Type != "stderr") || len(frame.Data) < minCompressFrameBytes || frame.Encoding != "" {
+		return frame
+	}
+	enc, _ := zstdCodecs()
+	compressed := enc.EncodeAll(frame.Data, make([]byte, 0, len(frame.Data)/2))
+	if len(compressed) >= len(frame.Data) {
+		return frame
+	}
+	frame.Data = compressed
+	frame.Encoding = CompressionZstd
+	return frame
+}
+
+func decompressFrameData(encoding string, data []byte) ([]byte, error) {
+	switch encoding {
+	case "":
+		return data, nil
+	case CompressionZstd:
+		_, dec := zstdCodecs()
+		out, err := dec.DecodeAll(data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("decompress stream frame: %w", err)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unknown stream frame encoding %q", encoding)
+	}
+}
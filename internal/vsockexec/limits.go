@@ -0,0 +1,139 @@
+package vsockexec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Limits the guest agent enforces on what the host sends it. Hosts may
+// configure lower limits for callers, never higher ones.
+const (
+	// MaxRequestBytes bounds one encoded ExecRequest.
+	MaxRequestBytes = 8 * 1024 * 1024
+	// MaxCommandArgs bounds the number of command arguments.
+	MaxCommandArgs = 32 * 1024
+	// MaxArgBytes bounds one argument or environment variable; Linux
+	// refuses longer ones with E2BIG.
+	MaxArgBytes = 128 * 1024
+	// MaxCommandBytes bounds the command's arguments together.
+	MaxCommandBytes = 2 * 1024 * 1024
+	// MaxEnvBytes bounds the command's environment variables together.
+	MaxEnvBytes = 2 * 1024 * 1024
+	// MaxStdinFrameBytes bounds the data in one stdin input frame.
+	MaxStdinFrameBytes = 1024 * 1024
+)
+
+// maxInputFrameBytes bounds one encoded input frame: base64 stdin data
+// plus the frame's other fields.
+const maxInputFrameBytes = MaxStdinFrameBytes/3*4 + 4 + 1024
+
+// ErrFrameTooLarge is returned when the host sends a request or input
+// frame longer than the protocol allows.
+var ErrFrameTooLarge = errors.New("frame too large")
+
+// CheckCommand rejects commands and environments larger than the guest
+// agent runs, so they fail with a clear error instead of E2BIG or an
+// exhausted guest.
+func CheckCommand(command, env []string) error {
+	if len(command) > MaxCommandArgs {
+		return fmt.Errorf("command has %d arguments, at most %d are supported", len(command), MaxCommandArgs)
+	}
+	if err := checkStrings("command argument", command, MaxCommandBytes); err != nil {
+		return err
+	}
+	return checkStrings("environment variable", env, MaxEnvBytes)
+}
+
+func checkStrings(what string, values []string, maxTotal int) error {
+	total := 0
+	for i, value := range values {
+		if len(value) > MaxArgBytes {
+			return fmt.Errorf("%s %d is %d bytes, at most %d are supported", what, i, len(value), MaxArgBytes)
+		}
+		total += len(value) + 1
+	}
+	if total > maxTotal {
+		return fmt.Errorf("%ss total %d bytes, at most %d are supported", what, total, maxTotal)
+	}
+	return nil
+}
+
+// FrameDecoder decodes the request and input frames the host sends on one
+// connection. It fails with ErrFrameTooLarge instead of buffering a request
+// longer than MaxRequestBytes or an input frame longer than a stdin frame
+// of MaxStdinFrameBytes needs.
+type FrameDecoder struct {
+	*json.Decoder
+	src *frameLimitReader
+}
+
+// NewFrameDecoder returns a decoder for the frames on r, expecting an
+// ExecRequest first.
+func NewFrameDecoder(r io.Reader) *FrameDecoder {
+	src := &frameLimitReader{r: r, max: MaxRequestBytes}
+	src.dec = json.NewDecoder(src)
+	return &FrameDecoder{Decoder: src.dec, src: src}
+}
+
+// DecodeRequest decodes the ExecRequest and switches the decoder to the
+// input frame limit for the frames that follow it.
+func (d *FrameDecoder) DecodeRequest() (ExecRequest, error) {
+	var req ExecRequest
+	if err := d.Decode(&req); err != nil {
+		if errors.Is(err, ErrFrameTooLarge) {
+			return ExecRequest{}, fmt.Errorf("exec request is larger than %d bytes: %w", MaxRequestBytes, err)
+		}
+		return ExecRequest{}, err
+	}
+	d.src.max = maxInputFrameBytes
+	return req, nil
+}
+
+// DecodeInputFrame decodes the next input frame.
+func (d *FrameDecoder) DecodeInputFrame() (ExecInputFrame, error) {
+	var frame ExecInputFrame
+	if err := d.Decode(&frame); err != nil {
+		return ExecInputFrame{}, err
+	}
+	if len(frame.Data) > MaxStdinFrameBytes {
+		return ExecInputFrame{}, fmt.Errorf("stdin frame is %d bytes, at most %d are supported: %w", len(frame.Data), MaxStdinFrameBytes, ErrFrameTooLarge)
+	}
+	return frame, nil
+}
+
+// frameLimitReader refuses to read more once the decoder reading from it
+// holds max bytes of a value it has not finished decoding. The
+// decoder only reads when the value it is decoding is incomplete, so the
+// bytes it holds past its input offset all belong to that value.
+type frameLimitReader struct {
+	r    io.Reader
+	dec  *json.Decoder
+	read int64
+	max  int64
+}
+
+func (f *frameLimitReader) Read(p []byte) (int, error) {
+	room := f.max - (f.read - f.dec.InputOffset())
+	if room <= 0 {
+		return 0, ErrFrameTooLarge
+	}
+	if int64(len(p)) > room {
+		p = p[:room]
+	}
+	n, err := f.r.Read(p)
+	f.read += int64(n)
+	return n, err
+}
+
+// StdinFrames splits data into stdin frames the guest agent accepts.
+func StdinFrames(data []byte) []ExecInputFrame {
+	frames := make([]ExecInputFrame, 0, len(data)/MaxStdinFrameBytes+1)
+	for len(data) > 0 {
+		n := min(len(data), MaxStdinFrameBytes)
+		frames = append(frames, ExecInputFrame{Type: "stdin", Data: data[:n]})
+		data = data[n:]
+	}
+	return frames
+}
@@ -17,13 +17,108 @@ type ExecRequest struct {
 	Env         []string `json:"env,omitempty"`
 	EntropySeed []byte   `json:"entropy_seed,omitempty"`
 	TTY         bool     `json:"tty,omitempty"`
+	// Limits are applied by the guest agent before the command starts. A nil
+	// value runs the command with the agent's own scheduling and cgroup.
+	Limits *ResourceLimits `json:"limits,omitempty"`
+	// KeepBackground leaves processes started by the command running after it
+	// exits. By default the guest agent kills the command's whole process tree.
+	KeepBackground bool `json:"keep_background,omitempty"`
+	// FreshHome runs the command with HOME, and the default working directory,
+	// on a new tmpfs that is discarded when the command exits.
+	FreshHome bool `json:"fresh_home,omitempty"`
+	// Launcher selects how the guest agent starts the command. Empty means
+	// auto-detect.
+	Launcher string `json:"launcher,omitempty"` // direct|systemd
+	// Stdin marks a non-TTY command whose stdin the host streams as input
+	// frames up to an explicit eof frame. Without it the host sends eof
+	// straight after the request.
+	Stdin bool `json:"stdin,omitempty"`
+	// CaptureChanges has the guest agent record the files the command
+	// created, modified or deleted and add the record to the artifact
+	// manifest before reporting the exit.
+	CaptureChanges string `json:"capture_changes,omitempty"` // manifest|archive
+	// StreamStdout marks a command whose stdout may be too large to hold in
+	// memory. It is only forwarded as stream frames, never buffered for a
+	// single-response fallback.
+	StreamStdout bool `json:"stream_stdout,omitempty"`
+	// RequestID identifies the control API request the command runs for.
+	// The guest agent exports it to the command as CLEANROOM_REQUEST_ID so
+	// guest-side logs can be correlated with the host's.
+	RequestID string `json:"request_id,omitempty"`
+	// AgentUpgrade replaces the guest agent instead of running a command.
+	// The host streams the new binary as stdin frames up to eof. The agent
+	// checks it, installs it over its own executable, reports the exit and
+	// then restarts in place. Agents that predate upgrades reject the
+	// request as missing a command.
+	AgentUpgrade *AgentUpgrade `json:"agent_upgrade,omitempty"`
+	// FileDelta has the agent send a file as a delta against a copy the
+	// host already has, as stdout frames, instead of running a command.
+	// Agents that predate it reject the request as missing a command.
+	FileDelta *FileDelta `json:"file_delta,omitempty"`
+	// Compression asks the agent to compress stdout and stderr frames.
+	Compression string `json:"compression,omitempty"` // zstd
+	// Banner is written to a TTY command's terminal before the command
+	// starts. Agents that predate it start the command without one.
+	Banner string `json:"banner,omitempty"`
+	// SessionLimits ends a TTY command that runs too long or gets no input
+	// for too long, after warning in its terminal. Agents that predate it
+	// let the command run.
+	SessionLimits *SessionLimits `json:"session_limits,omitempty"`
+}
+
+// SessionLimits bounds an interactive session. Zero means no limit.
+type SessionLimits struct {
+	MaxSeconds  int64 `json:"max_seconds,omitempty"`
+	IdleSeconds int64 `json:"idle_seconds,omitempty"`
+}
+
+// AgentUpgrade describes the guest agent binary the host is about to send.
+type AgentUpgrade struct {
+	SHA256 string `json:"sha256"` // hex
+	Size   int64  `json:"size"`
+}
+
+// FileDelta asks for the file at Path as a blockdelta delta against a base
+// whose BlockSize blocks were signed as Signatures.
+type FileDelta struct {
+	Path       string `json:"path"`
+	BlockSize  int    `json:"block_size"`
+	Signatures []byte `json:"signatures,omitempty"`
+	MaxBytes   int64  `json:"max_bytes,omitempty"` // fail for larger files
+}
+
+const (
+	LauncherAuto    = ""
+	LauncherDirect  = "direct"
+	LauncherSystemd = "systemd"
+)
+
+const (
+	CaptureChangesManifest = "manifest"
+	CaptureChangesArchive  = "archive"
+)
+
+// ResourceLimits constrains a single guest command. Zero values leave the
+// corresponding limit unset.
+type ResourceLimits struct {
+	Nice           int    `json:"nice,omitempty"`
+	IOClass        string `json:"io_class,omitempty"` // realtime|best-effort|idle
+	IOPriority     int    `json:"io_priority,omitempty"`
+	CPUWeight      int64  `json:"cpu_weight,omitempty"`
+	MemoryMaxBytes int64  `json:"memory_max_bytes,omitempty"`
+}
+
+// IsZero reports whether no limits are set.
+func (l *ResourceLimits) IsZero() bool {
+	return l == nil || *l == ResourceLimits{}
 }
 
 type ExecResponse struct {
-	ExitCode int    `json:"exit_code"`
-	Stdout   string `json:"stdout,omitempty"`
-	Stderr   string `json:"stderr,omitempty"`
-	Error    string `json:"error,omitempty"`
+	ExitCode int           `json:"exit_code"`
+	Stdout   string        `json:"stdout,omitempty"`
+	Stderr   string        `json:"stderr,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Metadata *ExitMetadata `json:"metadata,omitempty"`
 }
 
 // ExecStreamFrame is sent from guest to host. Types: stdout|stderr|exit.
@@ -32,6 +127,23 @@ type ExecStreamFrame struct {
 	Data     []byte `json:"data,omitempty"`
 	ExitCode int    `json:"exit_code,omitempty"`
 	Error    string `json:"error,omitempty"`
+	// Encoding is zstd when Data is compressed. Agents only compress
+	// frames for requests that ask them to.
+	Encoding string `json:"encoding,omitempty"`
+	// Metadata is only set on exit frames. Older guest agents omit it.
+	Metadata *ExitMetadata `json:"metadata,omitempty"`
+}
+
+// ExitMetadata describes how a guest command ended and the resources it
+// used. CPU time and max RSS come from the kernel's rusage for the command
+// and the descendants it waited for.
+type ExitMetadata struct {
+	UserCPUMillis   int64  `json:"user_cpu_ms"`
+	SystemCPUMillis int64  `json:"sys_cpu_ms"`
+	MaxRSSBytes     int64  `json:"max_rss_bytes"`
+	WallMillis      int64  `json:"wall_ms"`
+	Signal          int    `json:"signal,omitempty"`      // signal that terminated the command, 0 if it exited
+	SignalName      string `json:"signal_name,omitempty"` // for example SIGKILL
 }
 
 func DecodeRequest(r io.Reader) (ExecRequest, error) {
@@ -39,12 +151,18 @@ func DecodeRequest(r io.Reader) (ExecRequest, error) {
 	if err := json.NewDecoder(r).Decode(&req); err != nil {
 		return ExecRequest{}, err
 	}
+	if req.AgentUpgrade != nil || req.FileDelta != nil {
+		return req, nil
+	}
 	if len(req.Command) == 0 {
 		return ExecRequest{}, errors.New("missing command")
 	}
 	if strings.TrimSpace(req.Command[0]) == "" {
 		return ExecRequest{}, errors.New("missing command executable")
 	}
+	if err := CheckCommand(req.Command, req.Env); err != nil {
+		return ExecRequest{}, err
+	}
 	return req, nil
 }
 
@@ -93,6 +211,9 @@ func DecodeInputFrame(r io.Reader) (ExecInputFrame, error) {
 type StreamCallbacks struct {
 	OnStdout func([]byte)
 	OnStderr func([]byte)
+	// DiscardStdout leaves stdout out of the returned response. OnStdout
+	// still sees every chunk.
+	DiscardStdout bool
 }
 
 func DecodeStreamResponse(r io.Reader, callbacks StreamCallbacks) (ExecResponse, error) {
@@ -130,11 +251,22 @@ func DecodeStreamResponse(r io.Reader, callbacks StreamCallbacks) (ExecResponse,
 			if err != nil {
 				return ExecResponse{}, err
 			}
+			if encRaw, ok := raw["encoding"]; ok {
+				var encoding string
+				if err := json.Unmarshal(encRaw, &encoding); err != nil {
+					return ExecResponse{}, err
+				}
+				if chunk, err = decompressFrameData(encoding, chunk); err != nil {
+					return ExecResponse{}, err
+				}
+			}
 			if len(chunk) == 0 {
 				continue
 			}
 			if kind == "stdout" {
-				out.Stdout += string(chunk)
+				if !callbacks.DiscardStdout {
+					out.Stdout += string(chunk)
+				}
 				if callbacks.OnStdout != nil {
 					callbacks.OnStdout(append([]byte(nil), chunk...))
 				}
@@ -155,6 +287,11 @@ func DecodeStreamResponse(r io.Reader, callbacks StreamCallbacks) (ExecResponse,
 					return ExecResponse{}, err
 				}
 			}
+			if metaRaw, ok := raw["metadata"]; ok {
+				if err := json.Unmarshal(metaRaw, &out.Metadata); err != nil {
+					return ExecResponse{}, err
+				}
+			}
 			return out, nil
 		default:
 			return ExecResponse{}, fmt.Errorf("unknown stream frame type %q", kind)
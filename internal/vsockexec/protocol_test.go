@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"net"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
@@ -57,6 +58,127 @@ func TestDecodeRequestTTYDefaultsFalse(t *testing.T) {
 	}
 }
 
+func TestDecodeRequestWithLimits(t *testing.T) {
+	t.Parallel()
+	raw := `{"command":["make","test"],"limits":{"nice":10,"io_class":"idle","cpu_weight":50,"memory_max_bytes":1073741824}}`
+	req, err := DecodeRequest(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("DecodeRequest returned error: %v", err)
+	}
+	want := ResourceLimits{Nice: 10, IOClass: "idle", CPUWeight: 50, MemoryMaxBytes: 1 << 30}
+	if req.Limits == nil || *req.Limits != want {
+		t.Fatalf("unexpected limits: got %+v want %+v", req.Limits, want)
+	}
+}
+
+func TestDecodeRequestKeepBackgroundDefaultsFalse(t *testing.T) {
+	t.Parallel()
+	req, err := DecodeRequest(strings.NewReader(`{"command":["sh"]}`))
+	if err != nil {
+		t.Fatalf("DecodeRequest returned error: %v", err)
+	}
+	if req.KeepBackground {
+		t.Fatal("expected KeepBackground to default to false")
+	}
+	if req.FreshHome {
+		t.Fatal("expected FreshHome to default to false")
+	}
+}
+
+func TestDecodeRequestWithFreshHome(t *testing.T) {
+	t.Parallel()
+	req, err := DecodeRequest(strings.NewReader(`{"command":["sh"],"fresh_home":true}`))
+	if err != nil {
+		t.Fatalf("DecodeRequest returned error: %v", err)
+	}
+	if !req.FreshHome {
+		t.Fatal("expected FreshHome to be true")
+	}
+}
+
+func TestDecodeRequestAgentUpgradeNeedsNoCommand(t *testing.T) {
+	t.Parallel()
+	req, err := DecodeRequest(strings.NewReader(`{"agent_upgrade":{"sha256":"abc","size":3}}`))
+	if err != nil {
+		t.Fatalf("DecodeRequest returned error: %v", err)
+	}
+	if req.AgentUpgrade == nil || req.AgentUpgrade.SHA256 != "abc" || req.AgentUpgrade.Size != 3 {
+		t.Fatalf("unexpected agent upgrade %+v", req.AgentUpgrade)
+	}
+}
+
+func TestDecodeRequestRejectsOversizedCommands(t *testing.T) {
+	t.Parallel()
+
+	for name, req := range map[string]ExecRequest{
+		"too many arguments": {Command: make([]string, MaxCommandArgs+1)},
+		"long argument":      {Command: []string{"echo", strings.Repeat("x", MaxArgBytes+1)}},
+		"large environment":  {Command: []string{"env"}, Env: slices.Repeat([]string{strings.Repeat("x", MaxArgBytes-1)}, MaxEnvBytes/MaxArgBytes+1)},
+	} {
+		req.Command[0] = "echo"
+		var buf bytes.Buffer
+		if err := EncodeRequest(&buf, req); err != nil {
+			t.Fatalf("%s: EncodeRequest: %v", name, err)
+		}
+		if _, err := DecodeRequest(&buf); err == nil || !strings.Contains(err.Error(), "are supported") {
+			t.Fatalf("%s: expected a clear size error, got %v", name, err)
+		}
+	}
+}
+
+func TestFrameDecoderBoundsFrames(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := EncodeRequest(&buf, ExecRequest{Command: []string{"cat"}, Stdin: true}); err != nil {
+		t.Fatalf("EncodeRequest: %v", err)
+	}
+	for _, frame := range StdinFrames(make([]byte, MaxStdinFrameBytes+10)) {
+		if err := EncodeInputFrame(&buf, frame); err != nil {
+			t.Fatalf("EncodeInputFrame: %v", err)
+		}
+	}
+	if err := EncodeInputFrame(&buf, ExecInputFrame{Type: "stdin", Data: make([]byte, 2*MaxStdinFrameBytes)}); err != nil {
+		t.Fatalf("EncodeInputFrame: %v", err)
+	}
+
+	dec := NewFrameDecoder(&buf)
+	if _, err := dec.DecodeRequest(); err != nil {
+		t.Fatalf("DecodeRequest: %v", err)
+	}
+	for _, want := range []int{MaxStdinFrameBytes, 10} {
+		frame, err := dec.DecodeInputFrame()
+		if err != nil {
+			t.Fatalf("DecodeInputFrame: %v", err)
+		}
+		if len(frame.Data) != want {
+			t.Fatalf("expected a %d byte frame, got %d", want, len(frame.Data))
+		}
+	}
+	if _, err := dec.DecodeInputFrame(); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("expected an oversized frame to be refused, got %v", err)
+	}
+
+	huge := `{"command":["echo","` + strings.Repeat("x", MaxRequestBytes) + `"]}`
+	if _, err := NewFrameDecoder(strings.NewReader(huge)).DecodeRequest(); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("expected an oversized request to be refused, got %v", err)
+	}
+}
+
+func TestResourceLimitsIsZero(t *testing.T) {
+	t.Parallel()
+	var nilLimits *ResourceLimits
+	if !nilLimits.IsZero() {
+		t.Fatal("expected nil limits to be zero")
+	}
+	if !(&ResourceLimits{}).IsZero() {
+		t.Fatal("expected empty limits to be zero")
+	}
+	if (&ResourceLimits{Nice: 1}).IsZero() {
+		t.Fatal("expected nice limit to be non-zero")
+	}
+}
+
 func TestInputFrameRoundTrip(t *testing.T) {
 	t.Parallel()
 
@@ -254,3 +376,110 @@ func TestProtocolRoundTripResizeFrame(t *testing.T) {
 		t.Fatalf("resize[1]: got %dx%d, want 120x40", resizes[1].Cols, resizes[1].Rows)
 	}
 }
+
+func TestDecodeStreamResponseExitMetadata(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := EncodeStreamFrame(&buf, ExecStreamFrame{
+		Type:     "exit",
+		ExitCode: 137,
+		Metadata: &ExitMetadata{UserCPUMillis: 120, SystemCPUMillis: 30, MaxRSSBytes: 4 << 20, WallMillis: 900, Signal: 9, SignalName: "SIGKILL"},
+	}); err != nil {
+		t.Fatalf("EncodeStreamFrame: %v", err)
+	}
+
+	res, err := DecodeStreamResponse(&buf, StreamCallbacks{})
+	if err != nil {
+		t.Fatalf("DecodeStreamResponse: %v", err)
+	}
+	want := ExitMetadata{UserCPUMillis: 120, SystemCPUMillis: 30, MaxRSSBytes: 4 << 20, WallMillis: 900, Signal: 9, SignalName: "SIGKILL"}
+	if res.Metadata == nil || *res.Metadata != want {
+		t.Fatalf("unexpected metadata: got %+v want %+v", res.Metadata, want)
+	}
+}
+
+func TestDecodeStreamResponseWithoutExitMetadata(t *testing.T) {
+	t.Parallel()
+
+	res, err := DecodeStreamResponse(strings.NewReader(`{"type":"exit","exit_code":3}`+"\n"), StreamCallbacks{})
+	if err != nil {
+		t.Fatalf("DecodeStreamResponse: %v", err)
+	}
+	if res.ExitCode != 3 || res.Metadata != nil {
+		t.Fatalf("unexpected response from an older guest agent: %+v", res)
+	}
+}
+
+func TestDecodeStreamResponseDiscardStdout(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	for _, frame := range []ExecStreamFrame{
+		{Type: "stdout", Data: []byte("large ")},
+		{Type: "stdout", Data: []byte("payload")},
+		{Type: "stderr", Data: []byte("warning")},
+		{Type: "exit"},
+	} {
+		if err := EncodeStreamFrame(&buf, frame); err != nil {
+			t.Fatalf("EncodeStreamFrame: %v", err)
+		}
+	}
+
+	var streamed bytes.Buffer
+	res, err := DecodeStreamResponse(&buf, StreamCallbacks{
+		OnStdout:      func(chunk []byte) { streamed.Write(chunk) },
+		DiscardStdout: true,
+	})
+	if err != nil {
+		t.Fatalf("DecodeStreamResponse: %v", err)
+	}
+	if got := streamed.String(); got != "large payload" {
+		t.Fatalf("unexpected streamed stdout %q", got)
+	}
+	if res.Stdout != "" {
+		t.Fatalf("expected stdout to be left out of the response, got %q", res.Stdout)
+	}
+	if res.Stderr != "warning" {
+		t.Fatalf("expected stderr to be kept, got %q", res.Stderr)
+	}
+}
+
+func TestDecodeStreamResponseCompressedFrames(t *testing.T) {
+	t.Parallel()
+
+	logs := strings.Repeat("ok   example.com/pkg/feature  0.012s\n", 200)
+	var buf bytes.Buffer
+	for _, frame := range []ExecStreamFrame{
+		{Type: "stdout", Data: []byte(logs)},
+		{Type: "stderr", Data: []byte("short")},
+		{Type: "exit", ExitCode: 3},
+	} {
+		frame = CompressStreamFrame(frame)
+		if frame.Type == "stdout" {
+			if frame.Encoding != CompressionZstd || len(frame.Data) >= len(logs)/5 {
+				t.Fatalf("expected the log frame to compress at least 5x, got %s %d bytes", frame.Encoding, len(frame.Data))
+			}
+		}
+		if frame.Type == "stderr" && frame.Encoding != "" {
+			t.Fatal("expected a short frame to be sent uncompressed")
+		}
+		if err := EncodeStreamFrame(&buf, frame); err != nil {
+			t.Fatalf("EncodeStreamFrame: %v", err)
+		}
+	}
+
+	res, err := DecodeStreamResponse(&buf, StreamCallbacks{})
+	if err != nil {
+		t.Fatalf("DecodeStreamResponse: %v", err)
+	}
+	if res.Stdout != logs || res.Stderr != "short" || res.ExitCode != 3 {
+		t.Fatalf("unexpected response: %d bytes of stdout, stderr %q, exit %d", len(res.Stdout), res.Stderr, res.ExitCode)
+	}
+
+	buf.Reset()
+	_ = EncodeStreamFrame(&buf, ExecStreamFrame{Type: "stdout", Data: []byte("x"), Encoding: "br"})
+	if _, err := DecodeStreamResponse(&buf, StreamCallbacks{}); err == nil || !strings.Contains(err.Error(), `unknown stream frame encoding "br"`) {
+		t.Fatalf("expected an unknown encoding to be rejected, got %v", err)
+	}
+}
@@ -39,13 +39,14 @@ purge_stale_cleanroom_resources() {
     kill -9 $stale_pids 2>/dev/null || true
   fi
 
-  # Remove stale TAP devices (prefixed "cr") and their iptables rules.
+  # Remove stale TAP devices (prefixed "cr") and their iptables rules, both
+  # subnet-named ones and the cr<id> names older versions created.
   local taps
-  taps="$(run_privileged ip -o link show 2>/dev/null | grep -oP 'cr[a-z0-9]{1,13}(?=:)' || true)"
+  taps="$(run_privileged ip -o link show 2>/dev/null | grep -oP '(cr-[0-9]{1,3}-[0-9]{1,3}-[0-9]{1,3}|cr[a-z0-9]{1,13})(?=:)' || true)"
   for tap in $taps; do
     echo "removing stale tap device and iptables rules: $tap"
     # Delete all iptables rules referencing this TAP by listing and reversing.
-    for chain in INPUT FORWARD; do
+    for chain in CLEANROOM-INPUT CLEANROOM-FORWARD; do
       local rules
       rules="$(run_privileged iptables -S "$chain" 2>/dev/null | grep -- " $tap " || true)"
       while IFS= read -r rule; do
@@ -57,9 +58,10 @@ purge_stale_cleanroom_resources() {
     run_privileged ip link del "$tap" 2>/dev/null || true
   done
 
-  # Remove stale NAT MASQUERADE rules for cleanroom subnets (10.x.x.0/24).
+  # Remove stale NAT MASQUERADE and sandbox group RETURN rules for cleanroom
+  # subnets (10.x.x.0/24).
   local nat_rules
-  nat_rules="$(run_privileged iptables -t nat -S POSTROUTING 2>/dev/null | grep 'MASQUERADE' | grep -E '10\.[0-9]+\.[0-9]+\.' || true)"
+  nat_rules="$(run_privileged iptables -t nat -S CLEANROOM-POSTROUTING 2>/dev/null | grep -E 'MASQUERADE|RETURN' | grep -E '10\.[0-9]+\.[0-9]+\.' || true)"
   while IFS= read -r rule; do
     [[ -n "$rule" ]] || continue
     # shellcheck disable=SC2086
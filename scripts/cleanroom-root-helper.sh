@@ -6,9 +6,17 @@ die() {
   exit 2
 }
 
+# has_ambient_caps is true when cleanroom-cap-helper started this script
+# with ambient capabilities instead of sudo running it as root.
+has_ambient_caps() {
+  local amb
+  amb="$(awk '/^CapAmb:/ { print $2 }' /proc/self/status 2>/dev/null || true)"
+  [[ -n "$amb" && "$amb" != "0000000000000000" ]]
+}
+
 require_root() {
-  if [[ "$(id -u)" -ne 0 ]]; then
-    die "must run as root"
+  if [[ "$(id -u)" -ne 0 ]] && ! has_ambient_caps; then
+    die "must run as root or from cleanroom-cap-helper"
   fi
 }
 
@@ -29,7 +37,7 @@ is_mounted_rootfs_dest() {
 
 is_tap_name() {
   local v="$1"
-  [[ "$v" =~ ^cr[a-z0-9]{1,13}$ ]]
+  [[ "$v" =~ ^cr-[0-9]{1,3}-[0-9]{1,3}-[0-9]{1,3}$ ]]
 }
 
 is_numeric() {
@@ -37,6 +45,11 @@ is_numeric() {
   [[ "$v" =~ ^[0-9]+$ ]]
 }
 
+is_port_spec() {
+  local v="$1"
+  [[ "$v" =~ ^[0-9]+(:[0-9]+)?$ ]]
+}
+
 is_ipv4() {
   local v="$1"
   [[ "$v" =~ ^([0-9]{1,3}\.){3}[0-9]{1,3}$ ]]
@@ -74,6 +87,11 @@ run_ip() {
         is_tap_name "$3" || die "ip link set: unsupported interface '$3'"
         exec /usr/sbin/ip link set dev "$3" up
       fi
+      if [[ "$#" -eq 5 && "$1" == "set" && "$2" == "dev" && "$4" == "mtu" ]]; then
+        is_tap_name "$3" || die "ip link set: unsupported interface '$3'"
+        is_numeric "$5" || die "ip link set: invalid mtu '$5'"
+        exec /usr/sbin/ip link set dev "$3" mtu "$5"
+      fi
       ;;
     tuntap)
       shift
@@ -99,21 +117,46 @@ run_iptables() {
   [[ "$#" -ge 1 ]] || die "iptables: missing arguments"
 
   # List rules: iptables -S <chain>
-  if [[ "$#" -eq 2 && "$1" == "-S" && ( "$2" == "INPUT" || "$2" == "FORWARD" ) ]]; then
+  if [[ "$#" -eq 2 && "$1" == "-S" && ( "$2" == "INPUT" || "$2" == "FORWARD" || "$2" == "CLEANROOM-INPUT" || "$2" == "CLEANROOM-FORWARD" ) ]]; then
     exec /usr/sbin/iptables "$@"
   fi
 
-  # List NAT rules: iptables -t nat -S POSTROUTING
-  if [[ "$#" -eq 4 && "$1" == "-t" && "$2" == "nat" && "$3" == "-S" && "$4" == "POSTROUTING" ]]; then
+  # List NAT rules: iptables -t nat -S [CLEANROOM-]POSTROUTING
+  if [[ "$#" -eq 4 && "$1" == "-t" && "$2" == "nat" && "$3" == "-S" && ( "$4" == "POSTROUTING" || "$4" == "CLEANROOM-POSTROUTING" ) ]]; then
     exec /usr/sbin/iptables "$@"
   fi
 
-  if [[ "$#" -eq 8 && "$1" == "-t" && "$2" == "nat" && ( "$3" == "-A" || "$3" == "-D" ) && "$4" == "POSTROUTING" && "$5" == "-s" && "$7" == "-j" && "$8" == "MASQUERADE" ]]; then
+  # Cleanroom chains and the jumps to them:
+  #   iptables -t <table> -N <chain>
+  #   iptables -t <table> -C <parent> -j <chain>
+  #   iptables -t <table> -I <parent> 1 -j <chain>
+  if [[ "$#" -ge 4 && "$1" == "-t" ]]; then
+    local pair="$2 ${*: -1}"
+    local parent=""
+    case "$pair" in
+      "filter CLEANROOM-INPUT") parent="INPUT" ;;
+      "filter CLEANROOM-FORWARD") parent="FORWARD" ;;
+      "nat CLEANROOM-POSTROUTING") parent="POSTROUTING" ;;
+    esac
+    if [[ -n "$parent" ]]; then
+      if [[ "$#" -eq 4 && "$3" == "-N" ]]; then
+        exec /usr/sbin/iptables "$@"
+      fi
+      if [[ "$#" -eq 6 && "$3" == "-C" && "$4" == "$parent" && "$5" == "-j" ]]; then
+        exec /usr/sbin/iptables "$@"
+      fi
+      if [[ "$#" -eq 7 && "$3" == "-I" && "$4" == "$parent" && "$5" == "1" && "$6" == "-j" ]]; then
+        exec /usr/sbin/iptables "$@"
+      fi
+    fi
+  fi
+
+  if [[ "$#" -eq 8 && "$1" == "-t" && "$2" == "nat" && ( "$3" == "-A" || "$3" == "-D" ) && "$4" == "CLEANROOM-POSTROUTING" && "$5" == "-s" && "$7" == "-j" && "$8" == "MASQUERADE" ]]; then
     is_cidr "$6" || die "iptables nat: invalid cidr '$6'"
     exec /usr/sbin/iptables "$@"
   fi
 
-  if [[ "$#" -eq 10 && ( "$1" == "-A" || "$1" == "-D" ) && "$2" == "FORWARD" && "$3" == "-o" && "$5" == "-m" && "$9" == "-j" && "${10}" == "ACCEPT" ]]; then
+  if [[ "$#" -eq 10 && ( "$1" == "-A" || "$1" == "-D" ) && "$2" == "CLEANROOM-FORWARD" && "$3" == "-o" && "$5" == "-m" && "$9" == "-j" && "${10}" == "ACCEPT" ]]; then
     is_tap_name "$4" || die "iptables FORWARD -o: unsupported interface '$4'"
     if [[ "$6" == "state" && "$7" == "--state" && "$8" == "RELATED,ESTABLISHED" ]]; then
       exec /usr/sbin/iptables "$@"
@@ -123,55 +166,97 @@ run_iptables() {
     fi
   fi
 
-  if [[ "$#" -eq 6 && ( "$1" == "-A" || "$1" == "-D" ) && ( "$2" == "FORWARD" || "$2" == "INPUT" ) && "$3" == "-i" && "$5" == "-j" && "$6" == "DROP" ]]; then
+  if [[ "$#" -eq 6 && ( "$1" == "-A" || "$1" == "-D" ) && ( "$2" == "CLEANROOM-FORWARD" || "$2" == "CLEANROOM-INPUT" ) && "$3" == "-i" && "$5" == "-j" && "$6" == "DROP" ]]; then
     is_tap_name "$4" || die "iptables $2 drop: unsupported interface '$4'"
     exec /usr/sbin/iptables "$@"
   fi
 
-  if [[ "$#" -eq 12 && ( "$1" == "-A" || "$1" == "-D" ) && "$2" == "FORWARD" && "$3" == "-i" && "$5" == "-p" && ( "$6" == "tcp" || "$6" == "udp" ) && "$7" == "-d" && "$9" == "--dport" && "${11}" == "-j" && "${12}" == "ACCEPT" ]]; then
+  if [[ "$#" -eq 12 && ( "$1" == "-A" || "$1" == "-D" ) && "$2" == "CLEANROOM-FORWARD" && "$3" == "-i" && "$5" == "-p" && ( "$6" == "tcp" || "$6" == "udp" ) && "$7" == "-d" && "$9" == "--dport" && "${11}" == "-j" && "${12}" == "ACCEPT" ]]; then
     is_tap_name "$4" || die "iptables FORWARD allow: unsupported interface '$4'"
     is_ipv4 "$8" || die "iptables FORWARD allow: invalid destination ip '$8'"
-    is_numeric "${10}" || die "iptables FORWARD allow: invalid port '${10}'"
+    is_port_spec "${10}" || die "iptables FORWARD allow: invalid port '${10}'"
     exec /usr/sbin/iptables "$@"
   fi
 
-  # Anti-spoof: iptables -A INPUT -i <tap> ! -s <IP> -j DROP
-  if [[ "$#" -eq 9 && ( "$1" == "-A" || "$1" == "-D" ) && "$2" == "INPUT" && "$3" == "-i" && "$5" == "!" && "$6" == "-s" && "$8" == "-j" && "$9" == "DROP" ]]; then
+  # ICMP allow: iptables -A|-D CLEANROOM-FORWARD -i <tap> -p icmp -d <IP> -j ACCEPT
+  if [[ "$#" -eq 10 && ( "$1" == "-A" || "$1" == "-D" ) && "$2" == "CLEANROOM-FORWARD" && "$3" == "-i" && "$5" == "-p" && "$6" == "icmp" && "$7" == "-d" && "$9" == "-j" && "${10}" == "ACCEPT" ]]; then
+    is_tap_name "$4" || die "iptables FORWARD icmp allow: unsupported interface '$4'"
+    is_ipv4 "$8" || die "iptables FORWARD icmp allow: invalid destination ip '$8'"
+    exec /usr/sbin/iptables "$@"
+  fi
+
+  # Anti-spoof: iptables -A CLEANROOM-INPUT -i <tap> ! -s <IP> -j DROP
+  if [[ "$#" -eq 9 && ( "$1" == "-A" || "$1" == "-D" ) && "$2" == "CLEANROOM-INPUT" && "$3" == "-i" && "$5" == "!" && "$6" == "-s" && "$8" == "-j" && "$9" == "DROP" ]]; then
     is_tap_name "$4" || die "iptables INPUT anti-spoof: unsupported interface '$4'"
     is_ipv4 "$7" || die "iptables INPUT anti-spoof: invalid ip '$7'"
     exec /usr/sbin/iptables "$@"
   fi
 
-  # Gateway accept: iptables -A INPUT -i <tap> -s <IP> -p tcp --dport <port> -j ACCEPT
-  if [[ "$#" -eq 12 && ( "$1" == "-A" || "$1" == "-D" ) && "$2" == "INPUT" && "$3" == "-i" && "$5" == "-s" && "$7" == "-p" && "$8" == "tcp" && "$9" == "--dport" && "${11}" == "-j" && "${12}" == "ACCEPT" ]]; then
+  # Gateway accept: iptables -A CLEANROOM-INPUT -i <tap> -s <IP> -p tcp --dport <port> -j ACCEPT
+  if [[ "$#" -eq 12 && ( "$1" == "-A" || "$1" == "-D" ) && "$2" == "CLEANROOM-INPUT" && "$3" == "-i" && "$5" == "-s" && "$7" == "-p" && "$8" == "tcp" && "$9" == "--dport" && "${11}" == "-j" && "${12}" == "ACCEPT" ]]; then
     is_tap_name "$4" || die "iptables INPUT accept: unsupported interface '$4'"
     is_ipv4 "$6" || die "iptables INPUT accept: invalid ip '$6'"
     is_numeric "${10}" || die "iptables INPUT accept: invalid port '${10}'"
     exec /usr/sbin/iptables "$@"
   fi
 
-  # Global gateway loopback: iptables -A|-D INPUT -i lo -p tcp --dport <port> -j ACCEPT
-  if [[ "$#" -eq 10 && ( "$1" == "-A" || "$1" == "-D" ) && "$2" == "INPUT" && "$3" == "-i" && "$4" == "lo" && "$5" == "-p" && "$6" == "tcp" && "$7" == "--dport" && "$9" == "-j" && "${10}" == "ACCEPT" ]]; then
+  # Global gateway loopback: iptables -A|-D CLEANROOM-INPUT -i lo -p tcp --dport <port> -j ACCEPT
+  if [[ "$#" -eq 10 && ( "$1" == "-A" || "$1" == "-D" ) && "$2" == "CLEANROOM-INPUT" && "$3" == "-i" && "$4" == "lo" && "$5" == "-p" && "$6" == "tcp" && "$7" == "--dport" && "$9" == "-j" && "${10}" == "ACCEPT" ]]; then
     is_numeric "$8" || die "iptables INPUT gateway loopback: invalid port '$8'"
     exec /usr/sbin/iptables "$@"
   fi
 
-  # Global gateway drop (non-TAP): iptables -A|-D INPUT ! -i cr+ -p tcp --dport <port> -j DROP
-  if [[ "$#" -eq 11 && ( "$1" == "-A" || "$1" == "-D" ) && "$2" == "INPUT" && "$3" == "!" && "$4" == "-i" && "$5" == "cr+" && "$6" == "-p" && "$7" == "tcp" && "$8" == "--dport" && "${10}" == "-j" && "${11}" == "DROP" ]]; then
+  # Global gateway drop (non-TAP): iptables -A|-D CLEANROOM-INPUT ! -i cr+ -p tcp --dport <port> -j DROP
+  if [[ "$#" -eq 11 && ( "$1" == "-A" || "$1" == "-D" ) && "$2" == "CLEANROOM-INPUT" && "$3" == "!" && "$4" == "-i" && "$5" == "cr+" && "$6" == "-p" && "$7" == "tcp" && "$8" == "--dport" && "${10}" == "-j" && "${11}" == "DROP" ]]; then
     is_numeric "$9" || die "iptables INPUT gateway drop: invalid port '$9'"
     exec /usr/sbin/iptables "$@"
   fi
 
+  # Sandbox group links, inserted with -I <chain> 1 and removed with -D <chain>.
+  local -a link=()
+  if [[ "$#" -ge 3 && "$1" == "-I" && "$3" == "1" ]]; then
+    link=("$2" "${@:4}")
+  elif [[ "$#" -ge 2 && "$1" == "-D" ]]; then
+    link=("${@:2}")
+  elif [[ "$#" -ge 5 && "$1" == "-t" && "$2" == "nat" && "$3" == "-I" && "$5" == "1" ]]; then
+    link=("$4" "${@:6}")
+  elif [[ "$#" -ge 4 && "$1" == "-t" && "$2" == "nat" && "$3" == "-D" ]]; then
+    link=("${@:4}")
+  fi
+  if [[ "${#link[@]}" -gt 0 ]] && is_group_link "${link[@]}"; then
+    exec /usr/sbin/iptables "$@"
+  fi
+
   die "iptables: unsupported arguments"
 }
 
+# is_group_link accepts a sandbox group rule without its operation:
+#   CLEANROOM-FORWARD -i <tap> -s <IP> -o <tap> -d <IP> -p tcp|udp --dport <port> -j ACCEPT
+#   CLEANROOM-FORWARD -i <tap> -o <tap> -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT
+#   CLEANROOM-POSTROUTING -s <cidr> -d <cidr> -j RETURN (nat table)
+is_group_link() {
+  if [[ "$#" -eq 15 && "$1" == "CLEANROOM-FORWARD" && "$2" == "-i" && "$4" == "-s" && "$6" == "-o" && "$8" == "-d" && "${10}" == "-p" && ( "${11}" == "tcp" || "${11}" == "udp" ) && "${12}" == "--dport" && "${14}" == "-j" && "${15}" == "ACCEPT" ]]; then
+    is_tap_name "$3" && is_ipv4 "$5" && is_tap_name "$7" && is_ipv4 "$9" && is_numeric "${13}"
+    return
+  fi
+  if [[ "$#" -eq 11 && "$1" == "CLEANROOM-FORWARD" && "$2" == "-i" && "$4" == "-o" && "$6" == "-m" && "$7" == "conntrack" && "$8" == "--ctstate" && "$9" == "RELATED,ESTABLISHED" && "${10}" == "-j" && "${11}" == "ACCEPT" ]]; then
+    is_tap_name "$3" && is_tap_name "$5"
+    return
+  fi
+  if [[ "$#" -eq 7 && "$1" == "CLEANROOM-POSTROUTING" && "$2" == "-s" && "$4" == "-d" && "$6" == "-j" && "$7" == "RETURN" ]]; then
+    is_cidr "$3" && is_cidr "$5"
+    return
+  fi
+  return 1
+}
+
 run_sysctl() {
   [[ "$#" -eq 2 ]] || die "sysctl: expected 2 arguments"
   [[ "$1" == "-w" ]] || die "sysctl: unsupported flag '$1'"
   if [[ "$2" == "net.ipv4.ip_forward=1" ]]; then
     exec /usr/sbin/sysctl -w net.ipv4.ip_forward=1
   fi
-  if [[ "$2" =~ ^net\.ipv6\.conf\.cr[a-z0-9]{1,13}\.disable_ipv6=1$ ]]; then
+  if [[ "$2" =~ ^net\.ipv6\.conf\.cr-[0-9]{1,3}-[0-9]{1,3}-[0-9]{1,3}\.disable_ipv6=1$ ]]; then
     exec /usr/sbin/sysctl -w "$2"
   fi
   die "sysctl: unsupported arguments"
@@ -207,7 +292,7 @@ run_install() {
   [[ -f "$src" ]] || die "install: source file not found"
   is_install_source "$src" || die "install: unsupported source path"
   is_mounted_rootfs_dest "$dst" || die "install: unsupported destination path"
-  exec /usr/bin/install -m 0755 "$src" "$dst"
+  exec /usr/bin/install -o root -g root -m 0755 "$src" "$dst"
 }
 
 main() {